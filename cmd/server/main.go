@@ -17,11 +17,13 @@ import (
 
 	"github.com/joho/godotenv"
 	configaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/config_access"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/cmd"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/managementasset"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/redis"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/store"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
@@ -47,6 +49,16 @@ func init() {
 // It parses command-line flags, loads configuration, and starts the appropriate
 // service based on the provided flags (login, codex-login, or server mode).
 func main() {
+	// Subcommands run before the flags of the server are parsed and print only their own output.
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "usage":
+			os.Exit(cmd.RunUsage(os.Args[2:], DefaultConfigPath))
+		case "accounts":
+			os.Exit(cmd.RunAccounts(os.Args[2:], DefaultConfigPath))
+		}
+	}
+
 	fmt.Printf("CLIProxyAPI Version: %s, Commit: %s, BuiltAt: %s\n", Version, Commit, BuildDate)
 
 	// Command-line flags to control the application's behavior.
@@ -56,6 +68,11 @@ func main() {
 	var qwenLogin bool
 	var iflowLogin bool
 	var noBrowser bool
+	var headless bool
+	var encryptAuthFiles bool
+	var decryptAuthFiles bool
+	var exportState string
+	var importState string
 	var projectID string
 	var configPath string
 	var password string
@@ -67,6 +84,11 @@ func main() {
 	flag.BoolVar(&qwenLogin, "qwen-login", false, "Login to Qwen using OAuth")
 	flag.BoolVar(&iflowLogin, "iflow-login", false, "Login to iFlow using OAuth")
 	flag.BoolVar(&noBrowser, "no-browser", false, "Don't open browser automatically for OAuth")
+	flag.BoolVar(&headless, "headless", false, "Complete OAuth login by pasting the redirect URL from a browser on another machine (not a device-code flow; Qwen uses its own)")
+	flag.BoolVar(&encryptAuthFiles, "encrypt-auth-files", false, "Encrypt the plaintext auth files in the auth directory with the configured key")
+	flag.BoolVar(&decryptAuthFiles, "decrypt-auth-files", false, "Decrypt the encrypted auth files in the auth directory back to plaintext")
+	flag.StringVar(&exportState, "export-state", "", "Write the config, auth files and usage history to this encrypted archive for moving to another host")
+	flag.StringVar(&importState, "import-state", "", "Restore the config, auth files and usage history from an archive written by --export-state")
 	flag.StringVar(&projectID, "project_id", "", "Project ID (Gemini only, not required)")
 	flag.StringVar(&configPath, "config", DefaultConfigPath, "Configure File Path")
 	flag.StringVar(&password, "password", "", "")
@@ -378,6 +400,7 @@ func main() {
 		}
 	}
 	usage.SetStatisticsEnabled(cfg.UsageStatisticsEnabled)
+	usage.GetRequestStatistics().SetRetention(usage.NewRetentionPolicy(cfg.UsageRetention))
 
 	metricsFile := cfg.MetricsFile
 	if metricsFile == "" {
@@ -389,9 +412,49 @@ func main() {
 		loopDelay = 10 * time.Minute
 	}
 
-	// Load last saved metrics from file and start periodic save
-	usage.LoadMetricsFromFile(metricsFile)
-	usage.StartPeriodicSaving(metricsFile, loopDelay, cfg.CrashOnError)
+	if cfg.SharedState.Enable {
+		// Keep usage details in Redis and replay those recorded by the other instances.
+		redisClient, errRedis := redis.NewClient(cfg.Redis)
+		if errRedis != nil {
+			log.Fatalf("failed to configure shared state: %v", errRedis)
+		}
+		usageStore, errStore := usage.NewRedisStore(redisClient, cfg.SharedState.MaxUsageEntries)
+		if errStore != nil {
+			log.Fatalf("failed to open shared usage store: %v", errStore)
+		}
+		syncInterval := cfg.SharedState.SyncInterval
+		if syncInterval <= 0 {
+			syncInterval = 5 * time.Second
+		}
+		if errStore = usage.StartStorePersistence(usageStore, usage.StoreOptions{
+			FlushInterval: syncInterval,
+			Retention:     cfg.UsageStore.Retention,
+			CrashOnError:  cfg.CrashOnError,
+		}); errStore != nil {
+			log.Fatalf("failed to start shared usage store: %v", errStore)
+		}
+	} else if cfg.UsageStore.Type != "" {
+		// Restore usage details from the persistent store and flush new ones periodically.
+		storePath := cfg.UsageStore.Path
+		if storePath == "" {
+			storePath = "usage.db"
+		}
+		usageStore, errStore := usage.NewStore(cfg.UsageStore.Type, storePath)
+		if errStore != nil {
+			log.Fatalf("failed to open usage store: %v", errStore)
+		}
+		if errStore = usage.StartStorePersistence(usageStore, usage.StoreOptions{
+			FlushInterval: cfg.UsageStore.FlushInterval,
+			Retention:     cfg.UsageStore.Retention,
+			CrashOnError:  cfg.CrashOnError,
+		}); errStore != nil {
+			log.Fatalf("failed to start usage store persistence: %v", errStore)
+		}
+	} else {
+		// Load last saved metrics from file and start periodic save
+		usage.LoadMetricsFromFile(metricsFile)
+		usage.StartPeriodicSaving(metricsFile, loopDelay, cfg.CrashOnError)
+	}
 
 	coreauth.SetQuotaCooldownDisabled(cfg.DisableCooling)
 
@@ -411,9 +474,14 @@ func main() {
 	}
 	managementasset.SetCurrentConfig(cfg)
 
+	if err = authcrypt.Configure(cfg.AuthEncryption); err != nil {
+		log.Fatalf("failed to configure auth encryption: %v", err)
+	}
+
 	// Create login options to be used in authentication flows.
 	options := &cmd.LoginOptions{
 		NoBrowser: noBrowser,
+		Headless:  headless,
 	}
 
 	// Register the shared token store once so all components use the same persistence backend.
@@ -432,7 +500,15 @@ func main() {
 
 	// Handle different command modes based on the provided flags.
 
-	if login {
+	if encryptAuthFiles {
+		cmd.DoEncryptAuthFiles(cfg)
+	} else if decryptAuthFiles {
+		cmd.DoDecryptAuthFiles(cfg)
+	} else if exportState != "" {
+		cmd.DoExportState(cfg, configFilePath, exportState)
+	} else if importState != "" {
+		cmd.DoImportState(cfg, configFilePath, importState)
+	} else if login {
 		// Handle Google/Gemini login
 		cmd.DoLogin(cfg, projectID, options)
 	} else if codexLogin {
@@ -0,0 +1,139 @@
+// Package main is an external provider plugin that echoes the last user message back. It
+// shows the minimum a plugin needs: describe its format and models, answer non-streaming
+// requests and stream chunks, all in the OpenAI Chat Completions schema the proxy
+// translates to and from.
+//
+// Build it and reference the binary from the proxy configuration:
+//
+//	provider-plugins:
+//	  - name: "echo"
+//	    command: "/usr/local/bin/echo-plugin"
+//	    settings:
+//	      prefix: "echo: "
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/plugin"
+)
+
+type echoPlugin struct {
+	prefix string
+}
+
+func newEchoPlugin(settings map[string]string) (plugin.Plugin, error) {
+	return &echoPlugin{prefix: settings["prefix"]}, nil
+}
+
+func (p *echoPlugin) Describe(context.Context) (plugin.Info, error) {
+	return plugin.Info{
+		Format: "openai",
+		Models: []plugin.Model{{ID: "echo-1", DisplayName: "Echo", ContextLength: 8192}},
+	}, nil
+}
+
+func (p *echoPlugin) Execute(_ context.Context, req plugin.Request) (plugin.Response, error) {
+	reply, err := p.reply(req)
+	if err != nil {
+		return plugin.Response{}, err
+	}
+	payload, _ := json.Marshal(map[string]any{
+		"id":      "echo-" + fmt.Sprint(time.Now().UnixNano()),
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   req.Model,
+		"choices": []any{map[string]any{
+			"index":         0,
+			"message":       map[string]string{"role": "assistant", "content": reply},
+			"finish_reason": "stop",
+		}},
+		"usage": map[string]int{"prompt_tokens": len(reply), "completion_tokens": len(reply), "total_tokens": 2 * len(reply)},
+	})
+	return plugin.Response{Payload: payload}, nil
+}
+
+func (p *echoPlugin) ExecuteStream(ctx context.Context, req plugin.Request) (<-chan plugin.Chunk, error) {
+	reply, err := p.reply(req)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan plugin.Chunk)
+	go func() {
+		defer close(out)
+		id := "echo-" + fmt.Sprint(time.Now().UnixNano())
+		for _, word := range strings.SplitAfter(reply, " ") {
+			event, _ := json.Marshal(map[string]any{
+				"id":      id,
+				"object":  "chat.completion.chunk",
+				"created": time.Now().Unix(),
+				"model":   req.Model,
+				"choices": []any{map[string]any{"index": 0, "delta": map[string]string{"content": word}}},
+			})
+			select {
+			case out <- plugin.Chunk{Data: string(event)}:
+			case <-ctx.Done():
+				return
+			}
+		}
+		event, _ := json.Marshal(map[string]any{
+			"id":      id,
+			"object":  "chat.completion.chunk",
+			"created": time.Now().Unix(),
+			"model":   req.Model,
+			"choices": []any{map[string]any{"index": 0, "delta": map[string]string{}, "finish_reason": "stop"}},
+		})
+		select {
+		case out <- plugin.Chunk{Data: string(event)}:
+		case <-ctx.Done():
+			return
+		}
+		out <- plugin.Chunk{Data: "[DONE]"}
+	}()
+	return out, nil
+}
+
+// reply returns the text of the last user message with the configured prefix.
+func (p *echoPlugin) reply(req plugin.Request) (string, error) {
+	var body struct {
+		Messages []struct {
+			Role    string          `json:"role"`
+			Content json.RawMessage `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(req.Payload, &body); err != nil {
+		return "", &plugin.Error{Status: 400, Message: "invalid request: " + err.Error()}
+	}
+	for i := len(body.Messages) - 1; i >= 0; i-- {
+		if body.Messages[i].Role != "user" {
+			continue
+		}
+		var text string
+		if err := json.Unmarshal(body.Messages[i].Content, &text); err != nil {
+			var parts []struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			}
+			_ = json.Unmarshal(body.Messages[i].Content, &parts)
+			for _, part := range parts {
+				if part.Type == "text" {
+					text += part.Text
+				}
+			}
+		}
+		return p.prefix + text, nil
+	}
+	return "", &plugin.Error{Status: 400, Message: "no user message"}
+}
+
+func main() {
+	if err := plugin.Serve(newEchoPlugin); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,102 @@
+// Package clientcert authenticates requests by their verified TLS client certificate.
+package clientcert
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+)
+
+// ProviderName identifies the client certificate provider in authentication results.
+const ProviderName = "client-cert"
+
+type provider struct {
+	identities []config.ClientCertIdentity
+}
+
+// New returns a provider mapping client certificates to the configured identities, or nil
+// when no identity is configured.
+func New(cfg config.ClientAuthConfig) sdkaccess.Provider {
+	identities := make([]config.ClientCertIdentity, 0, len(cfg.Identities))
+	for _, identity := range cfg.Identities {
+		identity.Subject = strings.TrimSpace(identity.Subject)
+		identity.SAN = strings.TrimSpace(identity.SAN)
+		identity.APIKey = strings.TrimSpace(identity.APIKey)
+		if identity.APIKey == "" || (identity.Subject == "" && identity.SAN == "") {
+			continue
+		}
+		identities = append(identities, identity)
+	}
+	if len(identities) == 0 {
+		return nil
+	}
+	return &provider{identities: identities}
+}
+
+func (p *provider) Identifier() string { return ProviderName }
+
+// Authenticate implements sdkaccess.Provider. Only certificates the TLS handshake verified
+// against the configured CA bundle are considered; requests without one, or whose
+// certificate matches no identity, are left to the other providers.
+func (p *provider) Authenticate(_ context.Context, r *http.Request) (*sdkaccess.Result, error) {
+	if p == nil || r == nil || r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.PeerCertificates) == 0 {
+		return nil, sdkaccess.ErrNotHandled
+	}
+	cert := r.TLS.PeerCertificates[0]
+	for _, identity := range p.identities {
+		if identity.Subject != "" && !subjectMatches(identity.Subject, cert) {
+			continue
+		}
+		if identity.SAN != "" && !sanMatches(identity.SAN, cert) {
+			continue
+		}
+		return &sdkaccess.Result{
+			Provider:  ProviderName,
+			Principal: identity.APIKey,
+			Metadata:  map[string]string{"subject": cert.Subject.String()},
+		}, nil
+	}
+	return nil, sdkaccess.ErrNotHandled
+}
+
+func subjectMatches(pattern string, cert *x509.Certificate) bool {
+	return matches(pattern, cert.Subject.String()) || matches(pattern, cert.Subject.CommonName)
+}
+
+func sanMatches(pattern string, cert *x509.Certificate) bool {
+	for _, name := range cert.DNSNames {
+		if matches(pattern, name) {
+			return true
+		}
+	}
+	for _, email := range cert.EmailAddresses {
+		if matches(pattern, email) {
+			return true
+		}
+	}
+	for _, uri := range cert.URIs {
+		if matches(pattern, uri.String()) {
+			return true
+		}
+	}
+	for _, ip := range cert.IPAddresses {
+		if matches(pattern, ip.String()) {
+			return true
+		}
+	}
+	return false
+}
+
+func matches(pattern, value string) bool {
+	if value == "" {
+		return false
+	}
+	if prefix, wildcard := strings.CutSuffix(pattern, "*"); wildcard {
+		return strings.HasPrefix(strings.ToLower(value), strings.ToLower(prefix))
+	}
+	return strings.EqualFold(pattern, value)
+}
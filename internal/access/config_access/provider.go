@@ -56,13 +56,16 @@ func (p *provider) Authenticate(_ context.Context, r *http.Request) (*sdkaccess.
 	authHeader := r.Header.Get("Authorization")
 	authHeaderGoogle := r.Header.Get("X-Goog-Api-Key")
 	authHeaderAnthropic := r.Header.Get("X-Api-Key")
+	// Browser WebSocket clients of the Realtime API cannot set headers and pass the key as
+	// a subprotocol instead.
+	protocolKey := extractWebsocketProtocolKey(r.Header.Values("Sec-WebSocket-Protocol"))
 	queryKey := ""
 	queryAuthToken := ""
 	if r.URL != nil {
 		queryKey = r.URL.Query().Get("key")
 		queryAuthToken = r.URL.Query().Get("auth_token")
 	}
-	if authHeader == "" && authHeaderGoogle == "" && authHeaderAnthropic == "" && queryKey == "" && queryAuthToken == "" {
+	if authHeader == "" && authHeaderGoogle == "" && authHeaderAnthropic == "" && protocolKey == "" && queryKey == "" && queryAuthToken == "" {
 		return nil, sdkaccess.ErrNoCredentials
 	}
 
@@ -75,6 +78,7 @@ func (p *provider) Authenticate(_ context.Context, r *http.Request) (*sdkaccess.
 		{apiKey, "authorization"},
 		{authHeaderGoogle, "x-goog-api-key"},
 		{authHeaderAnthropic, "x-api-key"},
+		{protocolKey, "websocket-protocol"},
 		{queryKey, "query-key"},
 		{queryAuthToken, "query-auth-token"},
 	}
@@ -97,6 +101,19 @@ func (p *provider) Authenticate(_ context.Context, r *http.Request) (*sdkaccess.
 	return nil, sdkaccess.ErrInvalidCredential
 }
 
+// extractWebsocketProtocolKey returns the API key of an "openai-insecure-api-key.<key>"
+// WebSocket subprotocol.
+func extractWebsocketProtocolKey(headers []string) string {
+	for _, header := range headers {
+		for _, protocol := range strings.Split(header, ",") {
+			if key, found := strings.CutPrefix(strings.TrimSpace(protocol), "openai-insecure-api-key."); found {
+				return key
+			}
+		}
+	}
+	return ""
+}
+
 func extractBearerToken(header string) string {
 	if header == "" {
 		return ""
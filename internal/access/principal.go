@@ -0,0 +1,53 @@
+package access
+
+import (
+	"strings"
+
+	clientcert "github.com/router-for-me/CLIProxyAPI/v6/internal/access/client_cert"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	sdkConfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// PrincipalAllowed reports whether an identity authenticated earlier is still granted by
+// cfg. It is used for work that outlives the request that authenticated it, such as
+// batches, so that removing a key or its provider also stops that work. Keys of the
+// config-api-key providers and client certificate identities are checked against the
+// configured keys; other providers are trusted for as long as they stay configured.
+func PrincipalAllowed(cfg *config.Config, principal sdkaccess.Result) bool {
+	if cfg == nil {
+		return false
+	}
+	entries := collectProviderEntries(cfg)
+	certProvider := clientcert.New(cfg.TLS.ClientAuth)
+	if len(entries) == 0 && certProvider == nil {
+		// Without providers every request is accepted.
+		return true
+	}
+	if principal.Provider == clientcert.ProviderName {
+		if certProvider == nil {
+			return false
+		}
+		for _, identity := range cfg.TLS.ClientAuth.Identities {
+			if strings.TrimSpace(identity.APIKey) == principal.Principal {
+				return true
+			}
+		}
+		return false
+	}
+	for _, entry := range entries {
+		if providerIdentifier(entry) != principal.Provider {
+			continue
+		}
+		if !strings.EqualFold(strings.TrimSpace(entry.Type), sdkConfig.AccessProviderTypeConfigAPIKey) {
+			return true
+		}
+		for _, key := range entry.APIKeys {
+			if key != "" && key == principal.Principal {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
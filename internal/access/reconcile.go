@@ -6,6 +6,7 @@ import (
 	"sort"
 	"strings"
 
+	clientcert "github.com/router-for-me/CLIProxyAPI/v6/internal/access/client_cert"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
 	sdkConfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
@@ -141,12 +142,22 @@ func ApplyAccessProviders(manager *sdkaccess.Manager, oldCfg, newCfg *config.Con
 		return false, nil
 	}
 
-	existing := manager.Providers()
+	// The client certificate provider follows the TLS settings rather than the access
+	// providers and is rebuilt on every update, ahead of the key-based providers.
+	existing := make([]sdkaccess.Provider, 0, len(manager.Providers()))
+	for _, provider := range manager.Providers() {
+		if provider != nil && provider.Identifier() != clientcert.ProviderName {
+			existing = append(existing, provider)
+		}
+	}
 	providers, added, updated, removed, err := ReconcileProviders(oldCfg, newCfg, existing)
 	if err != nil {
 		log.Errorf("failed to reconcile request auth providers: %v", err)
 		return false, fmt.Errorf("reconciling access providers: %w", err)
 	}
+	if certProvider := clientcert.New(newCfg.TLS.ClientAuth); certProvider != nil {
+		providers = append([]sdkaccess.Provider{certProvider}, providers...)
+	}
 
 	manager.SetProviders(providers)
 
@@ -0,0 +1,271 @@
+// Package admission limits the number of requests served concurrently and queues the
+// excess by priority. When every slot is taken, requests of higher priority API keys are
+// admitted before those of lower priority ones as soon as a slot frees up, so interactive
+// traffic is not stuck behind batch jobs while upstream capacity is constrained.
+package admission
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+const (
+	defaultMaxConcurrent = 64
+	defaultQueueDepth    = 256
+	defaultTimeout       = 30 * time.Second
+)
+
+var (
+	// ErrQueueFull is returned when the queue holds QueueDepth requests already.
+	ErrQueueFull = errors.New("admission queue is full")
+	// ErrTimeout is returned when a request waited longer than the queue timeout.
+	ErrTimeout = errors.New("timed out waiting in the admission queue")
+)
+
+// Stats describes the current state of the queue and its counters since startup.
+type Stats struct {
+	Enabled       bool `json:"enabled"`
+	MaxConcurrent int  `json:"max_concurrent"`
+	QueueDepth    int  `json:"queue_depth"`
+	InFlight      int  `json:"in_flight"`
+	// Queued is the number of waiting requests per priority, highest priority first.
+	Queued   []PriorityQueued `json:"queued"`
+	Admitted int64            `json:"admitted"`
+	Rejected int64            `json:"rejected"`
+	TimedOut int64            `json:"timed_out"`
+	// WaitSeconds is the total time admitted requests spent waiting in the queue.
+	WaitSeconds float64 `json:"wait_seconds"`
+}
+
+// PriorityQueued is the number of requests waiting at one priority.
+type PriorityQueued struct {
+	Priority int `json:"priority"`
+	Waiting  int `json:"waiting"`
+}
+
+// waiter is a queued request. granted is set, under the queue lock, when it is handed a slot.
+type waiter struct {
+	priority int
+	seq      uint64
+	ready    chan struct{}
+	granted  bool
+	index    int
+}
+
+// waiterHeap orders waiters by descending priority, then by arrival.
+type waiterHeap []*waiter
+
+func (h waiterHeap) Len() int { return len(h) }
+
+func (h waiterHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h waiterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *waiterHeap) Push(x any) {
+	w := x.(*waiter)
+	w.index = len(*h)
+	*h = append(*h, w)
+}
+
+func (h *waiterHeap) Pop() any {
+	old := *h
+	n := len(old)
+	w := old[n-1]
+	old[n-1] = nil
+	w.index = -1
+	*h = old[:n-1]
+	return w
+}
+
+// Queue is the admission controller. It is safe for concurrent use and can be
+// reconfigured at runtime without dropping waiting requests.
+type Queue struct {
+	mu         sync.Mutex
+	cfg        config.Admission
+	priorities map[string]int
+	inFlight   int
+	waiting    waiterHeap
+	seq        uint64
+	admitted   int64
+	rejected   int64
+	timedOut   int64
+	waitTotal  time.Duration
+}
+
+// NewQueue creates an admission queue for cfg.
+func NewQueue(cfg config.Admission) *Queue {
+	q := &Queue{}
+	q.Configure(cfg)
+	return q
+}
+
+// Configure applies cfg. Raising the concurrency admits waiting requests right away;
+// disabling admission control admits every waiting request.
+func (q *Queue) Configure(cfg config.Admission) {
+	if cfg.MaxConcurrent <= 0 {
+		cfg.MaxConcurrent = defaultMaxConcurrent
+	}
+	if cfg.QueueDepth <= 0 {
+		cfg.QueueDepth = defaultQueueDepth
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	priorities := make(map[string]int, len(cfg.Priorities))
+	for _, entry := range cfg.Priorities {
+		if key := strings.TrimSpace(entry.APIKey); key != "" {
+			priorities[key] = entry.Priority
+		}
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.cfg = cfg
+	q.priorities = priorities
+	q.dispatchLocked()
+}
+
+// Enabled reports whether admission control is on.
+func (q *Queue) Enabled() bool {
+	if q == nil {
+		return false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.cfg.Enable
+}
+
+func (q *Queue) priorityLocked(apiKey string) int {
+	if priority, ok := q.priorities[apiKey]; ok {
+		return priority
+	}
+	return q.cfg.DefaultPriority
+}
+
+// Acquire waits for a slot for a request of apiKey. On success the returned function must
+// be called once the request is done. It fails with ErrQueueFull, ErrTimeout or the
+// context error when the client goes away while waiting.
+func (q *Queue) Acquire(ctx context.Context, apiKey string) (func(), error) {
+	if q == nil {
+		return func() {}, nil
+	}
+	q.mu.Lock()
+	if !q.cfg.Enable {
+		q.mu.Unlock()
+		return func() {}, nil
+	}
+	if q.inFlight < q.cfg.MaxConcurrent && len(q.waiting) == 0 {
+		q.inFlight++
+		q.admitted++
+		q.mu.Unlock()
+		return q.releaseFunc(), nil
+	}
+	if len(q.waiting) >= q.cfg.QueueDepth {
+		q.rejected++
+		q.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+	q.seq++
+	w := &waiter{priority: q.priorityLocked(apiKey), seq: q.seq, ready: make(chan struct{})}
+	heap.Push(&q.waiting, w)
+	timeout := q.cfg.Timeout
+	q.mu.Unlock()
+
+	start := time.Now()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	var err error
+	select {
+	case <-w.ready:
+	case <-timer.C:
+		err = ErrTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if w.granted {
+		if err == nil {
+			q.waitTotal += time.Since(start)
+			return q.releaseFunc(), nil
+		}
+		// The slot was granted while giving up; pass it on.
+		q.inFlight--
+		q.admitted--
+		q.dispatchLocked()
+	} else {
+		heap.Remove(&q.waiting, w.index)
+	}
+	if errors.Is(err, ErrTimeout) {
+		q.timedOut++
+	}
+	return nil, err
+}
+
+func (q *Queue) releaseFunc() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			q.mu.Lock()
+			q.inFlight--
+			q.dispatchLocked()
+			q.mu.Unlock()
+		})
+	}
+}
+
+// dispatchLocked hands free slots to the highest priority waiters.
+func (q *Queue) dispatchLocked() {
+	for len(q.waiting) > 0 && (q.inFlight < q.cfg.MaxConcurrent || !q.cfg.Enable) {
+		w := heap.Pop(&q.waiting).(*waiter)
+		w.granted = true
+		q.inFlight++
+		q.admitted++
+		close(w.ready)
+	}
+}
+
+// Stats returns the current queue state.
+func (q *Queue) Stats() Stats {
+	if q == nil {
+		return Stats{Queued: []PriorityQueued{}}
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	counts := make(map[int]int)
+	for _, w := range q.waiting {
+		counts[w.priority]++
+	}
+	queued := make([]PriorityQueued, 0, len(counts))
+	for priority, waiting := range counts {
+		queued = append(queued, PriorityQueued{Priority: priority, Waiting: waiting})
+	}
+	sort.Slice(queued, func(i, j int) bool { return queued[i].Priority > queued[j].Priority })
+	return Stats{
+		Enabled:       q.cfg.Enable,
+		MaxConcurrent: q.cfg.MaxConcurrent,
+		QueueDepth:    q.cfg.QueueDepth,
+		InFlight:      q.inFlight,
+		Queued:        queued,
+		Admitted:      q.admitted,
+		Rejected:      q.rejected,
+		TimedOut:      q.timedOut,
+		WaitSeconds:   q.waitTotal.Seconds(),
+	}
+}
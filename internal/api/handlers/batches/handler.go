@@ -0,0 +1,104 @@
+// Package batches provides the HTTP handlers of the OpenAI compatible Batches endpoints.
+// Batches are visible to the client key that created them only.
+package batches
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers/files"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/batch"
+)
+
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+// Handler serves the /v1/batches endpoints.
+type Handler struct {
+	manager *batch.Manager
+}
+
+// NewHandler creates the handler for manager.
+func NewHandler(manager *batch.Manager) *Handler {
+	return &Handler{manager: manager}
+}
+
+// Middleware rejects requests while the Batch API is disabled.
+func (h *Handler) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !h.manager.Enabled() {
+			files.WriteError(c, http.StatusNotFound, "the batch API is disabled", "not_found")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// CreateBatch handles POST /v1/batches.
+func (h *Handler) CreateBatch(c *gin.Context) {
+	var req batch.CreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		files.WriteError(c, http.StatusBadRequest, "invalid request body", "invalid_request")
+		return
+	}
+	created, err := h.manager.CreateBatch(files.Principal(c), req)
+	if err != nil {
+		writeManagerError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, created)
+}
+
+// ListBatches handles GET /v1/batches with the after and limit query parameters.
+func (h *Handler) ListBatches(c *gin.Context) {
+	limit := defaultListLimit
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxListLimit {
+			files.WriteError(c, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxListLimit), "invalid_request")
+			return
+		}
+		limit = parsed
+	}
+	c.JSON(http.StatusOK, h.manager.Batches(files.Principal(c).Principal, c.Query("after"), limit))
+}
+
+// GetBatch handles GET /v1/batches/:id.
+func (h *Handler) GetBatch(c *gin.Context) {
+	b, err := h.manager.Batch(files.Principal(c).Principal, c.Param("id"))
+	if err != nil {
+		writeManagerError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, b)
+}
+
+// CancelBatch handles POST /v1/batches/:id/cancel.
+func (h *Handler) CancelBatch(c *gin.Context) {
+	b, err := h.manager.CancelBatch(files.Principal(c).Principal, c.Param("id"))
+	if err != nil {
+		writeManagerError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, b)
+}
+
+func writeManagerError(c *gin.Context, err error) {
+	var validation *batch.ValidationError
+	switch {
+	case errors.As(err, &validation):
+		files.WriteError(c, http.StatusBadRequest, validation.Message, "invalid_request")
+	case errors.Is(err, batch.ErrNotFound):
+		files.WriteError(c, http.StatusNotFound, "no such batch", "not_found")
+	case errors.Is(err, batch.ErrNotCancellable):
+		files.WriteError(c, http.StatusConflict, err.Error(), "conflict")
+	default:
+		files.WriteError(c, http.StatusInternalServerError, err.Error(), "internal_error")
+	}
+}
@@ -0,0 +1,149 @@
+// Package files provides the HTTP handlers of the OpenAI compatible Files endpoints. Files
+// are visible to the client key that uploaded them only.
+package files
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/filestore"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+)
+
+// multipartOverhead allows for the form fields around an uploaded file.
+const multipartOverhead = 1 << 20
+
+// Handler serves the /v1/files endpoints.
+type Handler struct {
+	store *filestore.Store
+}
+
+// NewHandler creates the handler for store.
+func NewHandler(store *filestore.Store) *Handler {
+	return &Handler{store: store}
+}
+
+// Middleware rejects requests while the Files API is disabled.
+func (h *Handler) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !h.store.Enabled() {
+			WriteError(c, http.StatusNotFound, "the files API is disabled", "not_found")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// UploadFile handles POST /v1/files: a multipart form with a file and its purpose.
+func (h *Handler) UploadFile(c *gin.Context) {
+	limit := h.store.MaxFileBytes()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit+multipartOverhead)
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			WriteError(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("file exceeds the maximum size of %d bytes", limit), "file_too_large")
+			return
+		}
+		WriteError(c, http.StatusBadRequest, "a multipart form with a file field is required", "invalid_request")
+		return
+	}
+	content, err := header.Open()
+	if err != nil {
+		WriteError(c, http.StatusBadRequest, "failed to read the uploaded file", "invalid_request")
+		return
+	}
+	defer func() { _ = content.Close() }()
+
+	file, err := h.store.Create(c.Request.Context(), Principal(c).Principal, header.Filename, c.PostForm("purpose"), content)
+	if err != nil {
+		h.writeStoreError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, file)
+}
+
+// ListFiles handles GET /v1/files with the optional purpose query parameter.
+func (h *Handler) ListFiles(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"object": "list", "data": h.store.List(Principal(c).Principal, c.Query("purpose"))})
+}
+
+// GetFile handles GET /v1/files/:id.
+func (h *Handler) GetFile(c *gin.Context) {
+	file, err := h.store.Get(Principal(c).Principal, c.Param("id"))
+	if err != nil {
+		h.writeStoreError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, file)
+}
+
+// GetFileContent handles GET /v1/files/:id/content.
+func (h *Handler) GetFileContent(c *gin.Context) {
+	content, file, err := h.store.Open(c.Request.Context(), Principal(c).Principal, c.Param("id"))
+	if err != nil {
+		h.writeStoreError(c, err)
+		return
+	}
+	defer func() { _ = content.Close() }()
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Length", fmt.Sprint(file.Bytes))
+	_, _ = io.Copy(c.Writer, content)
+}
+
+// DeleteFile handles DELETE /v1/files/:id.
+func (h *Handler) DeleteFile(c *gin.Context) {
+	id := c.Param("id")
+	if err := h.store.Delete(c.Request.Context(), Principal(c).Principal, id); err != nil {
+		h.writeStoreError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id, "object": "file", "deleted": true})
+}
+
+func (h *Handler) writeStoreError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, filestore.ErrNotFound):
+		WriteError(c, http.StatusNotFound, "no such file", "not_found")
+	case errors.Is(err, filestore.ErrInvalidPurpose):
+		WriteError(c, http.StatusBadRequest, err.Error(), "invalid_request")
+	case errors.Is(err, filestore.ErrFileTooLarge):
+		WriteError(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("file exceeds the maximum size of %d bytes", h.store.MaxFileBytes()), "file_too_large")
+	case errors.Is(err, filestore.ErrFileInUse):
+		WriteError(c, http.StatusConflict, err.Error(), "conflict")
+	default:
+		WriteError(c, http.StatusInternalServerError, err.Error(), "internal_error")
+	}
+}
+
+// WriteError writes an error in the OpenAI error format.
+func WriteError(c *gin.Context, status int, message, code string) {
+	errType := "invalid_request_error"
+	if status >= http.StatusInternalServerError {
+		errType = "server_error"
+	}
+	c.JSON(status, handlers.ErrorResponse{Error: handlers.ErrorDetail{Message: message, Type: errType, Code: code}})
+}
+
+// Principal returns the client identity set by the authentication middleware.
+func Principal(c *gin.Context) sdkaccess.Result {
+	var result sdkaccess.Result
+	if value, exists := c.Get("apiKey"); exists {
+		result.Principal = fmt.Sprint(value)
+	}
+	if value, exists := c.Get("accessProvider"); exists {
+		result.Provider = fmt.Sprint(value)
+	}
+	if value, exists := c.Get("accessMetadata"); exists {
+		if metadata, ok := value.(map[string]string); ok {
+			result.Metadata = metadata
+		}
+	}
+	return result
+}
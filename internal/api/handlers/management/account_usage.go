@@ -0,0 +1,46 @@
+package management
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+)
+
+// SetAccountTracker wires the per-account usage tracker used by the account usage endpoint.
+func (h *Handler) SetAccountTracker(tracker *usage.AccountTracker) { h.accountTracker = tracker }
+
+type accountUsageView struct {
+	usage.AccountUsage
+	Label string `json:"label,omitempty"`
+}
+
+// GetAccountsUsage reports the consumption of every upstream account in its five-hour and
+// daily windows, with the projected exhaustion time, soonest first. Optional ?provider= and
+// ?id= filters restrict the response.
+func (h *Handler) GetAccountsUsage(c *gin.Context) {
+	if h.accountTracker == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "account usage tracker unavailable"})
+		return
+	}
+	provider := strings.ToLower(strings.TrimSpace(c.Query("provider")))
+	id := strings.TrimSpace(c.Query("id"))
+	accounts := make([]accountUsageView, 0)
+	for _, account := range h.accountTracker.Snapshot() {
+		if provider != "" && strings.ToLower(account.Provider) != provider {
+			continue
+		}
+		if id != "" && account.AuthID != id {
+			continue
+		}
+		view := accountUsageView{AccountUsage: account}
+		if h.authManager != nil {
+			if auth, ok := h.authManager.GetByID(account.AuthID); ok {
+				view.Label = auth.Label
+			}
+		}
+		accounts = append(accounts, view)
+	}
+	c.JSON(http.StatusOK, gin.H{"accounts": accounts})
+}
@@ -0,0 +1,289 @@
+package management
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// disabledMetadataKey is the auth file field that keeps an account disabled across restarts.
+const disabledMetadataKey = "disabled"
+
+// ErrAccountNotFound is returned when no account has the requested ID.
+var ErrAccountNotFound = errors.New("account not found")
+
+// accountView is the redacted runtime view of an upstream account.
+type accountView struct {
+	*coreauth.Auth
+	Email string `json:"email,omitempty"`
+	Path  string `json:"path,omitempty"`
+}
+
+func newAccountView(auth *coreauth.Auth) accountView {
+	view := accountView{Auth: auth.Clone()}
+	if email, ok := auth.Metadata["email"].(string); ok {
+		view.Email = email
+	}
+	view.Path = auth.Attributes["path"]
+	// Metadata carries tokens and cookies; never echo it back.
+	view.Metadata = nil
+	if apiKey, ok := view.Attributes["api_key"]; ok {
+		view.Attributes["api_key"] = util.HideAPIKey(apiKey)
+	}
+	if view.ProxyURL != "" {
+		view.ProxyURL = coreauth.RedactProxyURL(view.ProxyURL)
+	}
+	return view
+}
+
+// ListAccounts returns every upstream account known to the core auth manager with its
+// runtime status. Pass ?id= to inspect a single account.
+func (h *Handler) ListAccounts(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	if id := strings.TrimSpace(c.Query("id")); id != "" {
+		auth, ok := h.authManager.GetByID(id)
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"account": newAccountView(auth)})
+		return
+	}
+	provider := strings.ToLower(strings.TrimSpace(c.Query("provider")))
+	auths := h.authManager.List()
+	sort.Slice(auths, func(i, j int) bool { return auths[i].ID < auths[j].ID })
+	accounts := make([]accountView, 0, len(auths))
+	for _, auth := range auths {
+		if provider != "" && strings.ToLower(auth.Provider) != provider {
+			continue
+		}
+		accounts = append(accounts, newAccountView(auth))
+	}
+	c.JSON(http.StatusOK, gin.H{"accounts": accounts})
+}
+
+// GetAccountsHealth returns the passive health state of every account: recent error rate,
+// consecutive failures and, for accounts taken out of rotation, when they are re-included.
+func (h *Handler) GetAccountsHealth(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	provider := strings.ToLower(strings.TrimSpace(c.Query("provider")))
+	accounts := make([]coreauth.AuthHealth, 0)
+	for _, health := range h.authManager.HealthSnapshot() {
+		if provider != "" && strings.ToLower(health.Provider) != provider {
+			continue
+		}
+		accounts = append(accounts, health)
+	}
+	c.JSON(http.StatusOK, gin.H{"accounts": accounts})
+}
+
+// GetAccountProxies returns every outbound proxy assigned to an account, the accounts
+// behind it and the result of its latest health checks.
+func (h *Handler) GetAccountProxies(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"enabled": h.cfg != nil && h.cfg.ProxyCheck.Enable, "proxies": h.authManager.ProxyHealthSnapshot()})
+}
+
+// PatchAccountStatus enables or disables an account. For file-backed accounts the flag
+// is written to the auth file so it survives restarts and hot reloads.
+func (h *Handler) PatchAccountStatus(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	var body struct {
+		ID       string `json:"id"`
+		Disabled *bool  `json:"disabled"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || strings.TrimSpace(body.ID) == "" || body.Disabled == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	updated, persisted, err := h.SetAccountDisabled(c.Request.Context(), strings.TrimSpace(body.ID), *body.Disabled)
+	if err != nil {
+		if errors.Is(err, ErrAccountNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "persisted": persisted, "account": newAccountView(updated)})
+}
+
+// SetAccountDisabled enables or disables the account with the given ID and reports whether
+// the flag was written to its auth file.
+func (h *Handler) SetAccountDisabled(ctx context.Context, id string, disabled bool) (*coreauth.Auth, bool, error) {
+	if h.authManager == nil {
+		return nil, false, errors.New("core auth manager unavailable")
+	}
+	auth, ok := h.authManager.GetByID(id)
+	if !ok {
+		return nil, false, ErrAccountNotFound
+	}
+
+	persisted := false
+	if path := auth.Attributes["path"]; path != "" {
+		if err := writeDisabledFlag(path, disabled); err != nil {
+			return nil, false, err
+		}
+		persisted = true
+	}
+
+	if auth.Metadata != nil {
+		if disabled {
+			auth.Metadata[disabledMetadataKey] = true
+		} else {
+			delete(auth.Metadata, disabledMetadataKey)
+		}
+	}
+	auth.Disabled = disabled
+	if disabled {
+		auth.Status = coreauth.StatusDisabled
+		auth.StatusMessage = "disabled via management API"
+	} else {
+		auth.Status = coreauth.StatusActive
+		auth.StatusMessage = ""
+	}
+	auth.UpdatedAt = time.Now()
+	updated, err := h.authManager.Update(ctx, auth)
+	if err != nil {
+		return nil, persisted, fmt.Errorf("failed to update account: %w", err)
+	}
+	return updated, persisted, nil
+}
+
+// writeDisabledFlag sets or clears the disabled field of an auth file in place.
+func writeDisabledFlag(path string, disabled bool) error {
+	data, err := authcrypt.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read auth file: %w", err)
+	}
+	metadata := make(map[string]any)
+	if err = json.Unmarshal(data, &metadata); err != nil {
+		return fmt.Errorf("invalid auth file: %w", err)
+	}
+	if disabled {
+		metadata[disabledMetadataKey] = true
+	} else {
+		delete(metadata, disabledMetadataKey)
+	}
+	raw, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to encode auth file: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err = authcrypt.WriteFile(tmp, raw, 0o600); err != nil {
+		return fmt.Errorf("failed to write auth file: %w", err)
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to replace auth file: %w", err)
+	}
+	return nil
+}
+
+// accountStatusView summarises whether an account can serve requests right now.
+type accountStatusView struct {
+	ID            string          `json:"id"`
+	Provider      string          `json:"provider"`
+	Label         string          `json:"label,omitempty"`
+	Email         string          `json:"email,omitempty"`
+	Status        coreauth.Status `json:"status"`
+	StatusMessage string          `json:"status_message,omitempty"`
+	Disabled      bool            `json:"disabled"`
+	// ExpiresAt is when the account's token expires; omitted for API keys.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// CooldownUntil is set while the whole account is benched; ModelCooldowns lists the
+	// models benched on their own.
+	CooldownUntil  *time.Time            `json:"cooldown_until,omitempty"`
+	ModelCooldowns map[string]time.Time  `json:"model_cooldowns,omitempty"`
+	Healthy        bool                  `json:"healthy"`
+	Samples        int                   `json:"samples"`
+	ErrorRate      float64               `json:"error_rate"`
+	Circuit        coreauth.CircuitState `json:"circuit,omitempty"`
+	LastError      string                `json:"last_error,omitempty"`
+}
+
+// GetAccountsStatus returns a compact status line per account: auth status, token expiry,
+// active cooldowns and recent error rate. An optional ?provider= filter restricts the response.
+func (h *Handler) GetAccountsStatus(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	provider := strings.ToLower(strings.TrimSpace(c.Query("provider")))
+	health := make(map[string]coreauth.AuthHealth)
+	for _, entry := range h.authManager.HealthSnapshot() {
+		health[entry.ID] = entry
+	}
+	now := time.Now()
+	auths := h.authManager.List()
+	sort.Slice(auths, func(i, j int) bool { return auths[i].ID < auths[j].ID })
+	accounts := make([]accountStatusView, 0, len(auths))
+	for _, auth := range auths {
+		if provider != "" && strings.ToLower(auth.Provider) != provider {
+			continue
+		}
+		view := accountStatusView{
+			ID:            auth.ID,
+			Provider:      auth.Provider,
+			Label:         auth.Label,
+			Status:        auth.Status,
+			StatusMessage: auth.StatusMessage,
+			Disabled:      auth.Disabled,
+			Healthy:       true,
+		}
+		if email, ok := auth.Metadata["email"].(string); ok {
+			view.Email = email
+		}
+		if expiresAt, ok := auth.ExpirationTime(); ok {
+			view.ExpiresAt = &expiresAt
+		}
+		if auth.Unavailable && auth.NextRetryAfter.After(now) {
+			until := auth.NextRetryAfter
+			view.CooldownUntil = &until
+		}
+		for model, state := range auth.ModelStates {
+			if state == nil || !state.Unavailable || !state.NextRetryAfter.After(now) {
+				continue
+			}
+			if view.ModelCooldowns == nil {
+				view.ModelCooldowns = make(map[string]time.Time)
+			}
+			view.ModelCooldowns[model] = state.NextRetryAfter
+		}
+		if entry, ok := health[auth.ID]; ok {
+			view.Healthy = entry.Healthy
+			view.Samples = entry.Samples
+			view.ErrorRate = entry.ErrorRate
+			view.Circuit = entry.Circuit
+			view.LastError = entry.LastError
+		}
+		if view.LastError == "" && auth.LastError != nil {
+			view.LastError = auth.LastError.Message
+		}
+		accounts = append(accounts, view)
+	}
+	c.JSON(http.StatusOK, gin.H{"accounts": accounts})
+}
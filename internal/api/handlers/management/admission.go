@@ -0,0 +1,17 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/admission"
+)
+
+// SetAdmissionQueue wires the admission queue reported by the admission endpoint.
+func (h *Handler) SetAdmissionQueue(queue *admission.Queue) { h.admission = queue }
+
+// GetAdmission reports the admission queue: requests in flight, requests waiting per
+// priority and how many were admitted, rejected or timed out since startup.
+func (h *Handler) GetAdmission(c *gin.Context) {
+	c.JSON(http.StatusOK, h.admission.Stats())
+}
@@ -0,0 +1,44 @@
+package management
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/quota"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+// SetQuotaManager wires the per-key quota manager used by the quota endpoints.
+func (h *Handler) SetQuotaManager(manager *quota.Manager) { h.quotaManager = manager }
+
+type apiKeyQuotaStatus struct {
+	APIKey string         `json:"api-key"`
+	Limits []quota.Status `json:"limits"`
+}
+
+// GetAPIKeyQuotas reports the remaining quota per inbound API key.
+// Passing ?api-key=<key> restricts the response to a single key. Keys are masked for
+// callers below the admin role.
+func (h *Handler) GetAPIKeyQuotas(c *gin.Context) {
+	if h.quotaManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "quota manager unavailable"})
+		return
+	}
+	keys := h.quotaManager.Keys()
+	if key := strings.TrimSpace(c.Query("api-key")); key != "" {
+		keys = []string{key}
+	}
+	out := make([]apiKeyQuotaStatus, 0, len(keys))
+	for _, key := range keys {
+		limits := h.quotaManager.Status(key)
+		if limits == nil {
+			continue
+		}
+		if !revealsAPIKeys(c) {
+			key = util.HideAPIKey(key)
+		}
+		out = append(out, apiKeyQuotaStatus{APIKey: key, Limits: limits})
+	}
+	c.JSON(http.StatusOK, gin.H{"api-key-quotas": out})
+}
@@ -0,0 +1,225 @@
+package management
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// sensitiveAuditSegments mark settings whose values are masked in audit entries. Header
+// settings are masked as a whole since they commonly carry credentials, such as the
+// Authorization header of a hook.
+var sensitiveAuditSegments = []string{"key", "secret", "token", "password", "cookie", "credential", "authorization", "headers"}
+
+// SetAuditLogger wires the audit log that records management changes.
+func (h *Handler) SetAuditLogger(logger *logging.AuditLogger) { h.auditLogger = logger }
+
+// GetAuditLog returns recent management changes, newest first. Supports ?since=<RFC3339>,
+// ?action=<substring> and ?limit=<n> (default 100).
+func (h *Handler) GetAuditLog(c *gin.Context) {
+	query := logging.AuditQuery{Action: c.Query("action"), Limit: 100}
+	if since := c.Query("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'since' timestamp format"})
+			return
+		}
+		query.Since = t
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'limit' value"})
+			return
+		}
+		query.Limit = n
+	}
+	c.JSON(http.StatusOK, gin.H{"enabled": h.auditLogger.IsEnabled(), "entries": h.auditLogger.Query(query)})
+}
+
+// Audit runs mutate and records it in the audit log together with the configuration,
+// account and auth file changes it made. It is a plain call of mutate while auditing is off.
+//
+// Parameters:
+//   - actor: Who requested the change
+//   - action: What was requested, e.g. "PUT /v0/management/debug"
+//   - mutate: The change; its error is recorded and returned
+func (h *Handler) Audit(actor logging.AuditActor, action string, mutate func() error) error {
+	logger := h.auditLogger
+	if !logger.IsEnabled() {
+		return mutate()
+	}
+	before := h.auditSnapshot()
+	err := mutate()
+	entry := logging.AuditEntry{
+		Actor:   actor,
+		Action:  action,
+		Changes: diffAuditSnapshots(before, h.auditSnapshot()),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if errRecord := logger.Record(entry); errRecord != nil {
+		log.Errorf("failed to record audit entry: %v", errRecord)
+	}
+	return err
+}
+
+// auditRequest serves a mutating management request and records it in the audit log.
+func (h *Handler) auditRequest(c *gin.Context, credential, role string) {
+	actor := logging.AuditActor{
+		Credential: credential,
+		Role:       role,
+		Transport:  "http",
+		ClientIP:   c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+	}
+	_ = h.Audit(actor, auditAction(c.Request.Method, c.Request.URL), func() error {
+		c.Next()
+		if status := c.Writer.Status(); status >= http.StatusBadRequest {
+			return fmt.Errorf("%d %s", status, http.StatusText(status))
+		}
+		return nil
+	})
+}
+
+// auditAction describes a request as its method and path. Query parameters are kept with
+// their values masked like the settings of the path, since delete endpoints take the key
+// they remove from the query.
+func auditAction(method string, u *url.URL) string {
+	action := method + " " + u.Path
+	query := u.Query()
+	if len(query) == 0 {
+		return action
+	}
+	for name, values := range query {
+		if !isSensitiveAuditPath(u.Path + "." + name) {
+			continue
+		}
+		for i := range values {
+			values[i] = util.HideAPIKey(values[i])
+		}
+	}
+	return action + "?" + query.Encode()
+}
+
+func isMutation(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// auditSnapshot flattens the state a management change can affect into path/value pairs:
+// the configuration, the enabled state of every account and the auth files on disk.
+func (h *Handler) auditSnapshot() map[string]any {
+	values := make(map[string]any)
+	cfg := h.cfg
+	if cfg != nil {
+		if data, err := yaml.Marshal(cfg); err == nil {
+			var doc any
+			if err = yaml.Unmarshal(data, &doc); err == nil {
+				flattenAuditValue("config", doc, values)
+			}
+		}
+	}
+	if h.authManager != nil {
+		for _, auth := range h.authManager.List() {
+			values["accounts."+auth.ID+".disabled"] = auth.Disabled
+		}
+	}
+	if cfg != nil && cfg.AuthDir != "" {
+		if entries, err := os.ReadDir(cfg.AuthDir); err == nil {
+			for _, entry := range entries {
+				if !entry.IsDir() && strings.HasSuffix(strings.ToLower(entry.Name()), ".json") {
+					values["auth-files."+entry.Name()] = true
+				}
+			}
+		}
+	}
+	return values
+}
+
+func flattenAuditValue(path string, value any, out map[string]any) {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, child := range v {
+			flattenAuditValue(path+"."+key, child, out)
+		}
+	case []any:
+		for i, child := range v {
+			flattenAuditValue(fmt.Sprintf("%s[%d]", path, i), child, out)
+		}
+	default:
+		if s, ok := v.(string); ok {
+			if s = stripURLUserinfo(s); isSensitiveAuditPath(path) {
+				s = util.HideAPIKey(s)
+			}
+			v = s
+		}
+		out[path] = v
+	}
+}
+
+// stripURLUserinfo removes the user and password from URL values such as proxy-url, so
+// credentials embedded in a URL are not recorded. URLs that do not parse are replaced and
+// other values are returned unchanged.
+func stripURLUserinfo(value string) string {
+	if !strings.Contains(value, "@") {
+		return value
+	}
+	parsed, err := url.Parse(strings.TrimSpace(value))
+	if err != nil {
+		if strings.Contains(value, "://") {
+			return "<invalid url>"
+		}
+		return value
+	}
+	if parsed.User == nil || parsed.Host == "" {
+		return value
+	}
+	parsed.User = nil
+	return parsed.String()
+}
+
+func isSensitiveAuditPath(path string) bool {
+	path = strings.ToLower(path)
+	for _, segment := range sensitiveAuditSegments {
+		if strings.Contains(path, segment) {
+			return true
+		}
+	}
+	return false
+}
+
+// diffAuditSnapshots lists the paths whose values differ between before and after.
+func diffAuditSnapshots(before, after map[string]any) []logging.AuditChange {
+	var changes []logging.AuditChange
+	for path, old := range before {
+		if current, ok := after[path]; !ok {
+			changes = append(changes, logging.AuditChange{Path: path, Before: old})
+		} else if !reflect.DeepEqual(old, current) {
+			changes = append(changes, logging.AuditChange{Path: path, Before: old, After: current})
+		}
+	}
+	for path, current := range after {
+		if _, ok := before[path]; !ok {
+			changes = append(changes, logging.AuditChange{Path: path, After: current})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
@@ -0,0 +1,149 @@
+package management
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+)
+
+// newAuditTestHandler returns a handler with auditing enabled, saving cfg to a temporary
+// config file.
+func newAuditTestHandler(t *testing.T, cfg *config.Config) *Handler {
+	t.Helper()
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte("port: 8317\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	logger, err := logging.NewAuditLogger(config.AuditLogConfig{Enable: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(cfg, configPath, nil)
+	h.SetAuditLogger(logger)
+	return h
+}
+
+func TestAuditRequestMasksKeysInQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const clientKey = "sk-client-0123456789abcdef"
+	const upstreamKey = "sk-ant-REDACTED"
+	cfg := &config.Config{}
+	cfg.APIKeys = []string{clientKey}
+	cfg.ClaudeKey = []config.ClaudeKey{{APIKey: upstreamKey}}
+	h := newAuditTestHandler(t, cfg)
+
+	engine := gin.New()
+	audited := func(c *gin.Context) { h.auditRequest(c, "secret-key", config.ManagementRoleAdmin) }
+	engine.DELETE("/v0/management/api-keys", audited, h.DeleteAPIKeys)
+	engine.DELETE("/v0/management/claude-api-key", audited, h.DeleteClaudeKey)
+
+	for _, target := range []string{
+		"/v0/management/api-keys?value=" + clientKey,
+		"/v0/management/claude-api-key?api-key=" + upstreamKey,
+	} {
+		recorder := httptest.NewRecorder()
+		engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, target, nil))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("DELETE %s: status %d: %s", target, recorder.Code, recorder.Body.String())
+		}
+	}
+	if len(cfg.APIKeys) != 0 || len(cfg.ClaudeKey) != 0 {
+		t.Fatalf("keys were not deleted: %v %v", cfg.APIKeys, cfg.ClaudeKey)
+	}
+
+	entries := h.auditLogger.Query(logging.AuditQuery{})
+	if len(entries) != 2 {
+		t.Fatalf("got %d audit entries, want 2", len(entries))
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{clientKey, upstreamKey} {
+		if strings.Contains(string(data), key) {
+			t.Errorf("audit log contains plaintext key %q: %s", key, data)
+		}
+	}
+	for _, entry := range entries {
+		if !strings.Contains(entry.Action, "...") {
+			t.Errorf("action %q does not record the masked key", entry.Action)
+		}
+	}
+}
+
+func TestAuditMasksHeadersAndURLCredentials(t *testing.T) {
+	const hookToken = "hook-token-0123456789abcdef"
+	const compatToken = "compat-token-0123456789abcdef"
+	const proxyPassword = "proxy-pass-0123456789"
+	cfg := &config.Config{}
+	h := newAuditTestHandler(t, cfg)
+
+	err := h.Audit(logging.AuditActor{Credential: "secret-key"}, "PUT /v0/management/config", func() error {
+		cfg.ProxyURL = "socks5://proxy-user:" + proxyPassword + "@127.0.0.1:1080"
+		cfg.Hooks = []config.Hook{{
+			Stage:   "pre-request",
+			URL:     "https://hooks.example.com/check",
+			Headers: map[string]string{"Authorization": "Bearer " + hookToken},
+		}}
+		cfg.OpenAICompatibility = []config.OpenAICompatibility{{
+			Name:    "compat",
+			BaseURL: "https://compat.example.com/v1",
+			Headers: map[string]string{"X-Upstream-Auth": compatToken + "-{model}"},
+		}}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := h.auditLogger.Query(logging.AuditQuery{})
+	if len(entries) != 1 {
+		t.Fatalf("got %d audit entries, want 1", len(entries))
+	}
+	changes := make(map[string]any)
+	for _, change := range entries[0].Changes {
+		changes[change.Path] = change.After
+	}
+	for path, want := range map[string]string{
+		"config.proxy-url":                                       "socks5://127.0.0.1:1080",
+		"config.hooks[0].headers.Authorization":                  "Bear...cdef",
+		"config.openai-compatibility[0].headers.X-Upstream-Auth": "comp...del}",
+	} {
+		if got := changes[path]; got != want {
+			t.Errorf("%s = %v, want %q", path, got, want)
+		}
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{hookToken, compatToken, proxyPassword, "proxy-user"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("audit log contains %q: %s", secret, data)
+		}
+	}
+}
+
+func TestIsSensitiveAuditPath(t *testing.T) {
+	for _, path := range []string{
+		"config.api-keys[0]",
+		"config.hooks[1].headers.X-Custom",
+		"config.otel.headers.Authorization",
+		"config.remote-management.authorization",
+	} {
+		if !isSensitiveAuditPath(path) {
+			t.Errorf("%s is not masked", path)
+		}
+	}
+	if isSensitiveAuditPath("config.hooks[0].url") {
+		t.Error("config.hooks[0].url is masked")
+	}
+}
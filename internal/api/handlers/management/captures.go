@@ -0,0 +1,36 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/capture"
+)
+
+// SetCaptureRecorder wires the body capture recorder used by the capture endpoints.
+func (h *Handler) SetCaptureRecorder(recorder *capture.Recorder) { h.captureRecorder = recorder }
+
+// ListCaptures returns the stored body captures, newest first.
+func (h *Handler) ListCaptures(c *gin.Context) {
+	captures := h.captureRecorder.List()
+	if captures == nil {
+		captures = []capture.Summary{}
+	}
+	c.JSON(http.StatusOK, gin.H{"enabled": h.captureRecorder.Enabled(), "captures": captures})
+}
+
+// GetCapture returns one body capture with its full request and response bodies.
+func (h *Handler) GetCapture(c *gin.Context) {
+	entry, ok := h.captureRecorder.Get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "capture not found"})
+		return
+	}
+	c.JSON(http.StatusOK, entry)
+}
+
+// DeleteCaptures removes every stored body capture.
+func (h *Handler) DeleteCaptures(c *gin.Context) {
+	h.captureRecorder.Clear()
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
@@ -1,10 +1,13 @@
 package management
 
 import (
+	"errors"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
@@ -56,52 +59,102 @@ func (h *Handler) PutConfigYAML(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_yaml", "message": "cannot read request body"})
 		return
 	}
+	if err := h.ReplaceConfigYAML(body); err != nil {
+		var errUpdate *ConfigUpdateError
+		if errors.As(err, &errUpdate) {
+			c.JSON(errUpdate.Status, gin.H{"error": errUpdate.Code, "message": errUpdate.Message})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "write_failed", "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true, "changed": []string{"config"}})
+}
+
+// ConfigUpdateError describes why ReplaceConfigYAML rejected or failed to apply a config.
+type ConfigUpdateError struct {
+	// Status is the HTTP status reported by the management API.
+	Status int
+	// Code is the machine-readable error code, e.g. invalid_yaml or invalid_config.
+	Code    string
+	Message string
+}
+
+func (e *ConfigUpdateError) Error() string { return e.Code + ": " + e.Message }
+
+// ReplaceConfigYAML validates body as a complete configuration, writes it to the config
+// file and reloads it into the handler. Failures are reported as *ConfigUpdateError.
+func (h *Handler) ReplaceConfigYAML(body []byte) error {
 	var cfg config.Config
 	if err := yaml.Unmarshal(body, &cfg); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_yaml", "message": err.Error()})
-		return
+		return &ConfigUpdateError{Status: http.StatusBadRequest, Code: "invalid_yaml", Message: err.Error()}
 	}
 	// Validate config using LoadConfigOptional with optional=false to enforce parsing
 	tmpDir := filepath.Dir(h.configFilePath)
 	tmpFile, err := os.CreateTemp(tmpDir, "config-validate-*.yaml")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "write_failed", "message": err.Error()})
-		return
+		return &ConfigUpdateError{Status: http.StatusInternalServerError, Code: "write_failed", Message: err.Error()}
 	}
 	tempFile := tmpFile.Name()
 	if _, err := tmpFile.Write(body); err != nil {
 		tmpFile.Close()
 		os.Remove(tempFile)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "write_failed", "message": err.Error()})
-		return
+		return &ConfigUpdateError{Status: http.StatusInternalServerError, Code: "write_failed", Message: err.Error()}
 	}
 	if err := tmpFile.Close(); err != nil {
 		os.Remove(tempFile)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "write_failed", "message": err.Error()})
-		return
+		return &ConfigUpdateError{Status: http.StatusInternalServerError, Code: "write_failed", Message: err.Error()}
 	}
 	defer os.Remove(tempFile)
-	_, err = config.LoadConfigOptional(tempFile, false)
+	validated, err := config.LoadConfigOptional(tempFile, false)
 	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid_config", "message": err.Error()})
-		return
+		return &ConfigUpdateError{Status: http.StatusUnprocessableEntity, Code: "invalid_config", Message: err.Error()}
 	}
 	h.mu.Lock()
 	defer h.mu.Unlock()
+	// External plugins run commands on the host, so they are only changed in the file itself.
+	if !reflect.DeepEqual(pluginCommands(h.cfg), pluginCommands(validated)) {
+		return &ConfigUpdateError{Status: http.StatusForbidden, Code: "plugin_commands_locked", Message: "provider plugin commands cannot be changed through the management API"}
+	}
+	// The auth encryption key may come from a shell command and is only loaded at startup.
+	var current config.AuthEncryption
+	if h.cfg != nil {
+		current = h.cfg.AuthEncryption
+	}
+	if !reflect.DeepEqual(current, validated.AuthEncryption) {
+		return &ConfigUpdateError{Status: http.StatusForbidden, Code: "auth_encryption_locked", Message: "auth-encryption cannot be changed through the management API; edit the config file and restart"}
+	}
 	if WriteConfig(h.configFilePath, body) != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "write_failed", "message": "failed to write config"})
-		return
+		return &ConfigUpdateError{Status: http.StatusInternalServerError, Code: "write_failed", Message: "failed to write config"}
 	}
 	// Reload into handler to keep memory in sync
 	newCfg, err := config.LoadConfig(h.configFilePath)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "reload_failed", "message": err.Error()})
-		return
+		return &ConfigUpdateError{Status: http.StatusInternalServerError, Code: "reload_failed", Message: err.Error()}
 	}
 	h.cfg = newCfg
-	c.JSON(http.StatusOK, gin.H{"ok": true, "changed": []string{"config"}})
+	return nil
 }
 
+// pluginCommands returns the external provider plugins by name, without their settings.
+func pluginCommands(cfg *config.Config) map[string]config.ProviderPlugin {
+	out := make(map[string]config.ProviderPlugin)
+	if cfg == nil {
+		return out
+	}
+	for _, entry := range cfg.ProviderPlugins {
+		if entry.Command == "" {
+			continue
+		}
+		entry.Settings = nil
+		out[strings.ToLower(entry.Name)] = entry
+	}
+	return out
+}
+
+// ReadConfigFile returns the raw bytes of the config file.
+func (h *Handler) ReadConfigFile() ([]byte, error) { return os.ReadFile(h.configFilePath) }
+
 // GetConfigFile returns the raw config.yaml file bytes without re-encoding.
 // It preserves comments and original formatting/styles.
 func (h *Handler) GetConfigFile(c *gin.Context) {
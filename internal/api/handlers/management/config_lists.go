@@ -427,6 +427,436 @@ func (h *Handler) DeleteCodexKey(c *gin.Context) {
 	c.JSON(400, gin.H{"error": "missing api-key or index"})
 }
 
+// bedrock-api-key: []BedrockKey
+func (h *Handler) GetBedrockKeys(c *gin.Context) {
+	c.JSON(200, gin.H{"bedrock-api-key": h.cfg.BedrockKey})
+}
+func (h *Handler) PutBedrockKeys(c *gin.Context) {
+	data, err := c.GetRawData()
+	if err != nil {
+		c.JSON(400, gin.H{"error": "failed to read body"})
+		return
+	}
+	var arr []config.BedrockKey
+	if err = json.Unmarshal(data, &arr); err != nil {
+		var obj struct {
+			Items []config.BedrockKey `json:"items"`
+		}
+		if err2 := json.Unmarshal(data, &obj); err2 != nil || len(obj.Items) == 0 {
+			c.JSON(400, gin.H{"error": "invalid body"})
+			return
+		}
+		arr = obj.Items
+	}
+	// Filter out bedrock entries with empty region (treat as removed)
+	filtered := make([]config.BedrockKey, 0, len(arr))
+	for i := range arr {
+		entry := arr[i]
+		entry.Region = strings.TrimSpace(entry.Region)
+		if entry.Region == "" {
+			continue
+		}
+		filtered = append(filtered, entry)
+	}
+	h.cfg.BedrockKey = filtered
+	h.persist(c)
+}
+func (h *Handler) PatchBedrockKey(c *gin.Context) {
+	var body struct {
+		Index *int               `json:"index"`
+		Match *string            `json:"match"`
+		Value *config.BedrockKey `json:"value"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.Value == nil {
+		c.JSON(400, gin.H{"error": "invalid body"})
+		return
+	}
+	// If region becomes empty, delete instead of update
+	remove := strings.TrimSpace(body.Value.Region) == ""
+	if body.Index != nil && *body.Index >= 0 && *body.Index < len(h.cfg.BedrockKey) {
+		if remove {
+			h.cfg.BedrockKey = append(h.cfg.BedrockKey[:*body.Index], h.cfg.BedrockKey[*body.Index+1:]...)
+		} else {
+			h.cfg.BedrockKey[*body.Index] = *body.Value
+		}
+		h.persist(c)
+		return
+	}
+	if body.Match != nil {
+		for i := range h.cfg.BedrockKey {
+			if !bedrockKeyMatches(h.cfg.BedrockKey[i], *body.Match) {
+				continue
+			}
+			if remove {
+				h.cfg.BedrockKey = append(h.cfg.BedrockKey[:i], h.cfg.BedrockKey[i+1:]...)
+			} else {
+				h.cfg.BedrockKey[i] = *body.Value
+			}
+			h.persist(c)
+			return
+		}
+	}
+	c.JSON(404, gin.H{"error": "item not found"})
+}
+func (h *Handler) DeleteBedrockKey(c *gin.Context) {
+	if val := c.Query("key"); val != "" {
+		out := make([]config.BedrockKey, 0, len(h.cfg.BedrockKey))
+		for _, v := range h.cfg.BedrockKey {
+			if !bedrockKeyMatches(v, val) {
+				out = append(out, v)
+			}
+		}
+		h.cfg.BedrockKey = out
+		h.persist(c)
+		return
+	}
+	if idxStr := c.Query("index"); idxStr != "" {
+		var idx int
+		_, err := fmt.Sscanf(idxStr, "%d", &idx)
+		if err == nil && idx >= 0 && idx < len(h.cfg.BedrockKey) {
+			h.cfg.BedrockKey = append(h.cfg.BedrockKey[:idx], h.cfg.BedrockKey[idx+1:]...)
+			h.persist(c)
+			return
+		}
+	}
+	c.JSON(400, gin.H{"error": "missing key or index"})
+}
+
+// bedrockKeyMatches reports whether a Bedrock entry is identified by key, its access key ID
+// or its API key.
+func bedrockKeyMatches(entry config.BedrockKey, key string) bool {
+	return (entry.AccessKeyID != "" && entry.AccessKeyID == key) || (entry.APIKey != "" && entry.APIKey == key)
+}
+
+// azure-openai: []AzureOpenAIKey
+func (h *Handler) GetAzureOpenAI(c *gin.Context) {
+	c.JSON(200, gin.H{"azure-openai": h.cfg.AzureOpenAI})
+}
+func (h *Handler) PutAzureOpenAI(c *gin.Context) {
+	data, err := c.GetRawData()
+	if err != nil {
+		c.JSON(400, gin.H{"error": "failed to read body"})
+		return
+	}
+	var arr []config.AzureOpenAIKey
+	if err = json.Unmarshal(data, &arr); err != nil {
+		var obj struct {
+			Items []config.AzureOpenAIKey `json:"items"`
+		}
+		if err2 := json.Unmarshal(data, &obj); err2 != nil || len(obj.Items) == 0 {
+			c.JSON(400, gin.H{"error": "invalid body"})
+			return
+		}
+		arr = obj.Items
+	}
+	// Filter out azure entries with empty base-url (treat as removed)
+	filtered := make([]config.AzureOpenAIKey, 0, len(arr))
+	for i := range arr {
+		entry := arr[i]
+		entry.BaseURL = strings.TrimSpace(entry.BaseURL)
+		if entry.BaseURL == "" {
+			continue
+		}
+		filtered = append(filtered, entry)
+	}
+	h.cfg.AzureOpenAI = filtered
+	h.persist(c)
+}
+func (h *Handler) PatchAzureOpenAI(c *gin.Context) {
+	var body struct {
+		Index *int                   `json:"index"`
+		Match *string                `json:"match"`
+		Value *config.AzureOpenAIKey `json:"value"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.Value == nil {
+		c.JSON(400, gin.H{"error": "invalid body"})
+		return
+	}
+	// If base-url becomes empty, delete instead of update
+	remove := strings.TrimSpace(body.Value.BaseURL) == ""
+	if body.Index != nil && *body.Index >= 0 && *body.Index < len(h.cfg.AzureOpenAI) {
+		if remove {
+			h.cfg.AzureOpenAI = append(h.cfg.AzureOpenAI[:*body.Index], h.cfg.AzureOpenAI[*body.Index+1:]...)
+		} else {
+			h.cfg.AzureOpenAI[*body.Index] = *body.Value
+		}
+		h.persist(c)
+		return
+	}
+	if body.Match != nil {
+		for i := range h.cfg.AzureOpenAI {
+			if h.cfg.AzureOpenAI[i].BaseURL != *body.Match {
+				continue
+			}
+			if remove {
+				h.cfg.AzureOpenAI = append(h.cfg.AzureOpenAI[:i], h.cfg.AzureOpenAI[i+1:]...)
+			} else {
+				h.cfg.AzureOpenAI[i] = *body.Value
+			}
+			h.persist(c)
+			return
+		}
+	}
+	c.JSON(404, gin.H{"error": "item not found"})
+}
+func (h *Handler) DeleteAzureOpenAI(c *gin.Context) {
+	if val := c.Query("base-url"); val != "" {
+		out := make([]config.AzureOpenAIKey, 0, len(h.cfg.AzureOpenAI))
+		for _, v := range h.cfg.AzureOpenAI {
+			if v.BaseURL != val {
+				out = append(out, v)
+			}
+		}
+		h.cfg.AzureOpenAI = out
+		h.persist(c)
+		return
+	}
+	if idxStr := c.Query("index"); idxStr != "" {
+		var idx int
+		_, err := fmt.Sscanf(idxStr, "%d", &idx)
+		if err == nil && idx >= 0 && idx < len(h.cfg.AzureOpenAI) {
+			h.cfg.AzureOpenAI = append(h.cfg.AzureOpenAI[:idx], h.cfg.AzureOpenAI[idx+1:]...)
+			h.persist(c)
+			return
+		}
+	}
+	c.JSON(400, gin.H{"error": "missing base-url or index"})
+}
+
+// vertex-ai: []VertexAIKey
+func (h *Handler) GetVertexAI(c *gin.Context) {
+	c.JSON(200, gin.H{"vertex-ai": h.cfg.VertexAI})
+}
+func (h *Handler) PutVertexAI(c *gin.Context) {
+	data, err := c.GetRawData()
+	if err != nil {
+		c.JSON(400, gin.H{"error": "failed to read body"})
+		return
+	}
+	var arr []config.VertexAIKey
+	if err = json.Unmarshal(data, &arr); err != nil {
+		var obj struct {
+			Items []config.VertexAIKey `json:"items"`
+		}
+		if err2 := json.Unmarshal(data, &obj); err2 != nil || len(obj.Items) == 0 {
+			c.JSON(400, gin.H{"error": "invalid body"})
+			return
+		}
+		arr = obj.Items
+	}
+	for i := range arr {
+		arr[i].ProjectID = strings.TrimSpace(arr[i].ProjectID)
+	}
+	h.cfg.VertexAI = arr
+	h.persist(c)
+}
+func (h *Handler) PatchVertexAI(c *gin.Context) {
+	var body struct {
+		Index *int                `json:"index"`
+		Match *string             `json:"match"`
+		Value *config.VertexAIKey `json:"value"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.Value == nil {
+		c.JSON(400, gin.H{"error": "invalid body"})
+		return
+	}
+	body.Value.ProjectID = strings.TrimSpace(body.Value.ProjectID)
+	if body.Index != nil && *body.Index >= 0 && *body.Index < len(h.cfg.VertexAI) {
+		h.cfg.VertexAI[*body.Index] = *body.Value
+		h.persist(c)
+		return
+	}
+	if body.Match != nil {
+		for i := range h.cfg.VertexAI {
+			if h.cfg.VertexAI[i].ProjectID == *body.Match {
+				h.cfg.VertexAI[i] = *body.Value
+				h.persist(c)
+				return
+			}
+		}
+	}
+	c.JSON(404, gin.H{"error": "item not found"})
+}
+func (h *Handler) DeleteVertexAI(c *gin.Context) {
+	if val := c.Query("project-id"); val != "" {
+		out := make([]config.VertexAIKey, 0, len(h.cfg.VertexAI))
+		for _, v := range h.cfg.VertexAI {
+			if v.ProjectID != val {
+				out = append(out, v)
+			}
+		}
+		h.cfg.VertexAI = out
+		h.persist(c)
+		return
+	}
+	if idxStr := c.Query("index"); idxStr != "" {
+		var idx int
+		_, err := fmt.Sscanf(idxStr, "%d", &idx)
+		if err == nil && idx >= 0 && idx < len(h.cfg.VertexAI) {
+			h.cfg.VertexAI = append(h.cfg.VertexAI[:idx], h.cfg.VertexAI[idx+1:]...)
+			h.persist(c)
+			return
+		}
+	}
+	c.JSON(400, gin.H{"error": "missing project-id or index"})
+}
+
+// xai-api-key: []XAIKey
+func (h *Handler) GetXAIKeys(c *gin.Context) {
+	c.JSON(200, gin.H{"xai-api-key": h.cfg.XAIKey})
+}
+func (h *Handler) PutXAIKeys(c *gin.Context) {
+	data, err := c.GetRawData()
+	if err != nil {
+		c.JSON(400, gin.H{"error": "failed to read body"})
+		return
+	}
+	var arr []config.XAIKey
+	if err = json.Unmarshal(data, &arr); err != nil {
+		var obj struct {
+			Items []config.XAIKey `json:"items"`
+		}
+		if err2 := json.Unmarshal(data, &obj); err2 != nil || len(obj.Items) == 0 {
+			c.JSON(400, gin.H{"error": "invalid body"})
+			return
+		}
+		arr = obj.Items
+	}
+	filtered := make([]config.XAIKey, 0, len(arr))
+	for i := range arr {
+		normalizeXAIKey(&arr[i])
+		if arr[i].APIKey != "" {
+			filtered = append(filtered, arr[i])
+		}
+	}
+	h.cfg.XAIKey = filtered
+	h.persist(c)
+}
+func (h *Handler) PatchXAIKey(c *gin.Context) {
+	var body struct {
+		Index *int           `json:"index"`
+		Match *string        `json:"match"`
+		Value *config.XAIKey `json:"value"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.Value == nil {
+		c.JSON(400, gin.H{"error": "invalid body"})
+		return
+	}
+	normalizeXAIKey(body.Value)
+	if body.Index != nil && *body.Index >= 0 && *body.Index < len(h.cfg.XAIKey) {
+		h.cfg.XAIKey[*body.Index] = *body.Value
+		h.persist(c)
+		return
+	}
+	if body.Match != nil {
+		for i := range h.cfg.XAIKey {
+			if h.cfg.XAIKey[i].APIKey == *body.Match {
+				h.cfg.XAIKey[i] = *body.Value
+				h.persist(c)
+				return
+			}
+		}
+	}
+	c.JSON(404, gin.H{"error": "item not found"})
+}
+func (h *Handler) DeleteXAIKey(c *gin.Context) {
+	if val := c.Query("api-key"); val != "" {
+		out := make([]config.XAIKey, 0, len(h.cfg.XAIKey))
+		for _, v := range h.cfg.XAIKey {
+			if v.APIKey != val {
+				out = append(out, v)
+			}
+		}
+		h.cfg.XAIKey = out
+		h.persist(c)
+		return
+	}
+	if idxStr := c.Query("index"); idxStr != "" {
+		var idx int
+		_, err := fmt.Sscanf(idxStr, "%d", &idx)
+		if err == nil && idx >= 0 && idx < len(h.cfg.XAIKey) {
+			h.cfg.XAIKey = append(h.cfg.XAIKey[:idx], h.cfg.XAIKey[idx+1:]...)
+			h.persist(c)
+			return
+		}
+	}
+	c.JSON(400, gin.H{"error": "missing api-key or index"})
+}
+
+// local-backends: []LocalBackend
+func (h *Handler) GetLocalBackends(c *gin.Context) {
+	c.JSON(200, gin.H{"local-backends": h.cfg.LocalBackends})
+}
+func (h *Handler) PutLocalBackends(c *gin.Context) {
+	data, err := c.GetRawData()
+	if err != nil {
+		c.JSON(400, gin.H{"error": "failed to read body"})
+		return
+	}
+	var arr []config.LocalBackend
+	if err = json.Unmarshal(data, &arr); err != nil {
+		var obj struct {
+			Items []config.LocalBackend `json:"items"`
+		}
+		if err2 := json.Unmarshal(data, &obj); err2 != nil || len(obj.Items) == 0 {
+			c.JSON(400, gin.H{"error": "invalid body"})
+			return
+		}
+		arr = obj.Items
+	}
+	h.cfg.LocalBackends = arr
+	h.persist(c)
+}
+func (h *Handler) PatchLocalBackends(c *gin.Context) {
+	var body struct {
+		Index *int                 `json:"index"`
+		Match *string              `json:"match"`
+		Value *config.LocalBackend `json:"value"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.Value == nil {
+		c.JSON(400, gin.H{"error": "invalid body"})
+		return
+	}
+	if body.Index != nil && *body.Index >= 0 && *body.Index < len(h.cfg.LocalBackends) {
+		h.cfg.LocalBackends[*body.Index] = *body.Value
+		h.persist(c)
+		return
+	}
+	if body.Match != nil {
+		for i := range h.cfg.LocalBackends {
+			if strings.EqualFold(h.cfg.LocalBackends[i].Name, *body.Match) {
+				h.cfg.LocalBackends[i] = *body.Value
+				h.persist(c)
+				return
+			}
+		}
+	}
+	c.JSON(404, gin.H{"error": "item not found"})
+}
+func (h *Handler) DeleteLocalBackends(c *gin.Context) {
+	if name := c.Query("name"); name != "" {
+		out := make([]config.LocalBackend, 0, len(h.cfg.LocalBackends))
+		for _, v := range h.cfg.LocalBackends {
+			if !strings.EqualFold(v.Name, name) {
+				out = append(out, v)
+			}
+		}
+		h.cfg.LocalBackends = out
+		h.persist(c)
+		return
+	}
+	if idxStr := c.Query("index"); idxStr != "" {
+		var idx int
+		_, err := fmt.Sscanf(idxStr, "%d", &idx)
+		if err == nil && idx >= 0 && idx < len(h.cfg.LocalBackends) {
+			h.cfg.LocalBackends = append(h.cfg.LocalBackends[:idx], h.cfg.LocalBackends[idx+1:]...)
+			h.persist(c)
+			return
+		}
+	}
+	c.JSON(400, gin.H{"error": "missing name or index"})
+}
+
 func normalizeOpenAICompatibilityEntry(entry *config.OpenAICompatibility) {
 	if entry == nil {
 		return
@@ -477,6 +907,27 @@ func normalizedOpenAICompatibilityEntries(entries []config.OpenAICompatibility)
 	return out
 }
 
+func normalizeXAIKey(entry *config.XAIKey) {
+	if entry == nil {
+		return
+	}
+	entry.APIKey = strings.TrimSpace(entry.APIKey)
+	entry.BaseURL = strings.TrimSpace(entry.BaseURL)
+	entry.ProxyURL = strings.TrimSpace(entry.ProxyURL)
+	entry.LiveSearch.Mode = strings.ToLower(strings.TrimSpace(entry.LiveSearch.Mode))
+	normalized := make([]config.XAIModel, 0, len(entry.Models))
+	for i := range entry.Models {
+		model := entry.Models[i]
+		model.Name = strings.TrimSpace(model.Name)
+		model.Alias = strings.TrimSpace(model.Alias)
+		if model.Name == "" && model.Alias == "" {
+			continue
+		}
+		normalized = append(normalized, model)
+	}
+	entry.Models = normalized
+}
+
 func normalizeClaudeKey(entry *config.ClaudeKey) {
 	if entry == nil {
 		return
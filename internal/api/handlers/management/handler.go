@@ -4,6 +4,7 @@ package management
 
 import (
 	"crypto/subtle"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -13,7 +14,13 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/admission"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/capture"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/project"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/quota"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/session"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
@@ -34,11 +41,18 @@ type Handler struct {
 	failedAttempts      map[string]*attemptInfo // keyed by client IP
 	authManager         *coreauth.Manager
 	usageStats          *usage.RequestStatistics
+	quotaManager        *quota.Manager
+	projects            *project.Registry
+	admission           *admission.Queue
+	accountTracker      *usage.AccountTracker
+	captureRecorder     *capture.Recorder
+	sessions            *session.Manager
 	tokenStore          coreauth.Store
 	localPassword       string
 	allowRemoteOverride bool
 	envSecret           string
 	logDir              string
+	auditLogger         *logging.AuditLogger
 }
 
 // NewHandler creates a new management handler instance.
@@ -61,9 +75,15 @@ func NewHandler(cfg *config.Config, configFilePath string, manager *coreauth.Man
 // SetConfig updates the in-memory config reference when the server hot-reloads.
 func (h *Handler) SetConfig(cfg *config.Config) { h.cfg = cfg }
 
+// Config returns the configuration currently served by the handler.
+func (h *Handler) Config() *config.Config { return h.cfg }
+
 // SetAuthManager updates the auth manager reference used by management endpoints.
 func (h *Handler) SetAuthManager(manager *coreauth.Manager) { h.authManager = manager }
 
+// AuthManager returns the auth manager used by management endpoints.
+func (h *Handler) AuthManager() *coreauth.Manager { return h.authManager }
+
 // SetUsageStatistics allows replacing the usage statistics reference.
 func (h *Handler) SetUsageStatistics(stats *usage.RequestStatistics) { h.usageStats = stats }
 
@@ -84,73 +104,10 @@ func (h *Handler) SetLogDirectory(dir string) {
 }
 
 // Middleware enforces access control for management endpoints.
-// All requests (local and remote) require a valid management key.
-// Additionally, remote access requires allow-remote-management=true.
+// All requests (local and remote) require a valid management key whose role permits the
+// endpoint. Additionally, remote access requires allow-remote-management=true.
 func (h *Handler) Middleware() gin.HandlerFunc {
-	const maxFailures = 5
-	const banDuration = 30 * time.Minute
-
 	return func(c *gin.Context) {
-		clientIP := c.ClientIP()
-		localClient := clientIP == "127.0.0.1" || clientIP == "::1"
-		cfg := h.cfg
-		var (
-			allowRemote bool
-			secretHash  string
-		)
-		if cfg != nil {
-			allowRemote = cfg.RemoteManagement.AllowRemote
-			secretHash = cfg.RemoteManagement.SecretKey
-		}
-		if h.allowRemoteOverride {
-			allowRemote = true
-		}
-		envSecret := h.envSecret
-
-		fail := func() {}
-		if !localClient {
-			h.attemptsMu.Lock()
-			ai := h.failedAttempts[clientIP]
-			if ai != nil {
-				if !ai.blockedUntil.IsZero() {
-					if time.Now().Before(ai.blockedUntil) {
-						remaining := time.Until(ai.blockedUntil).Round(time.Second)
-						h.attemptsMu.Unlock()
-						c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("IP banned due to too many failed attempts. Try again in %s", remaining)})
-						return
-					}
-					// Ban expired, reset state
-					ai.blockedUntil = time.Time{}
-					ai.count = 0
-				}
-			}
-			h.attemptsMu.Unlock()
-
-			if !allowRemote {
-				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "remote management disabled"})
-				return
-			}
-
-			fail = func() {
-				h.attemptsMu.Lock()
-				aip := h.failedAttempts[clientIP]
-				if aip == nil {
-					aip = &attemptInfo{}
-					h.failedAttempts[clientIP] = aip
-				}
-				aip.count++
-				if aip.count >= maxFailures {
-					aip.blockedUntil = time.Now().Add(banDuration)
-					aip.count = 0
-				}
-				h.attemptsMu.Unlock()
-			}
-		}
-		if secretHash == "" && envSecret == "" {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "remote management key not set"})
-			return
-		}
-
 		// Accept either Authorization: Bearer <key> or X-Management-Key
 		var provided string
 		if ah := c.GetHeader("Authorization"); ah != "" {
@@ -165,55 +122,138 @@ func (h *Handler) Middleware() gin.HandlerFunc {
 			provided = c.GetHeader("X-Management-Key")
 		}
 
-		if provided == "" {
-			if !localClient {
-				fail()
-			}
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing management key"})
+		credential, role, statusCode, err := h.Authorize(c.ClientIP(), provided)
+		if err != nil {
+			c.AbortWithStatusJSON(statusCode, gin.H{"error": err.Error()})
 			return
 		}
+		route := strings.TrimPrefix(c.FullPath(), managementRouteBase)
+		if required := RequiredRole(c.Request.Method, route); !RoleAllows(role, required) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("role %s may not access this endpoint, %s required", role, required)})
+			return
+		}
+		c.Set(roleContextKey, role)
+		if isMutation(c.Request.Method) {
+			h.auditRequest(c, credential, role)
+			return
+		}
+		c.Next()
+	}
+}
 
-		if localClient {
-			if lp := h.localPassword; lp != "" {
-				if subtle.ConstantTimeCompare([]byte(provided), []byte(lp)) == 1 {
-					c.Next()
-					return
+// Authorize checks a management key presented by clientIP. It applies the same rules to
+// every transport: remote clients need allow-remote-management and are banned for a while
+// after repeated failures, local clients may also use the runtime-local password.
+//
+// Returns:
+//   - string: The credential that matched: "secret-key", "env-password", "local-password"
+//     or "token:<name>" for a management token
+//   - string: The role of the credential; see RequiredRole for what each role may access
+//   - int: The HTTP status describing the failure, http.StatusOK when access is granted
+//   - error: The reason access was denied
+func (h *Handler) Authorize(clientIP, provided string) (string, string, int, error) {
+	const maxFailures = 5
+	const banDuration = 30 * time.Minute
+
+	localClient := clientIP == "127.0.0.1" || clientIP == "::1"
+	cfg := h.cfg
+	var (
+		allowRemote bool
+		secretHash  string
+		tokens      []config.ManagementToken
+	)
+	if cfg != nil {
+		allowRemote = cfg.RemoteManagement.AllowRemote
+		secretHash = cfg.RemoteManagement.SecretKey
+		tokens = cfg.RemoteManagement.Tokens
+	}
+	if h.allowRemoteOverride {
+		allowRemote = true
+	}
+	envSecret := h.envSecret
+
+	fail := func() {}
+	if !localClient {
+		h.attemptsMu.Lock()
+		ai := h.failedAttempts[clientIP]
+		if ai != nil {
+			if !ai.blockedUntil.IsZero() {
+				if time.Now().Before(ai.blockedUntil) {
+					remaining := time.Until(ai.blockedUntil).Round(time.Second)
+					h.attemptsMu.Unlock()
+					return "", "", http.StatusForbidden, fmt.Errorf("IP banned due to too many failed attempts. Try again in %s", remaining)
 				}
+				// Ban expired, reset state
+				ai.blockedUntil = time.Time{}
+				ai.count = 0
 			}
 		}
+		h.attemptsMu.Unlock()
 
-		if envSecret != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(envSecret)) == 1 {
-			if !localClient {
-				h.attemptsMu.Lock()
-				if ai := h.failedAttempts[clientIP]; ai != nil {
-					ai.count = 0
-					ai.blockedUntil = time.Time{}
-				}
-				h.attemptsMu.Unlock()
-			}
-			c.Next()
-			return
+		if !allowRemote {
+			return "", "", http.StatusForbidden, errors.New("remote management disabled")
 		}
 
-		if secretHash == "" || bcrypt.CompareHashAndPassword([]byte(secretHash), []byte(provided)) != nil {
-			if !localClient {
-				fail()
+		fail = func() {
+			h.attemptsMu.Lock()
+			aip := h.failedAttempts[clientIP]
+			if aip == nil {
+				aip = &attemptInfo{}
+				h.failedAttempts[clientIP] = aip
 			}
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid management key"})
-			return
+			aip.count++
+			if aip.count >= maxFailures {
+				aip.blockedUntil = time.Now().Add(banDuration)
+				aip.count = 0
+			}
+			h.attemptsMu.Unlock()
 		}
+	}
+	if secretHash == "" && envSecret == "" && len(tokens) == 0 {
+		return "", "", http.StatusForbidden, errors.New("remote management key not set")
+	}
 
+	if provided == "" {
 		if !localClient {
-			h.attemptsMu.Lock()
-			if ai := h.failedAttempts[clientIP]; ai != nil {
-				ai.count = 0
-				ai.blockedUntil = time.Time{}
+			fail()
+		}
+		return "", "", http.StatusUnauthorized, errors.New("missing management key")
+	}
+
+	if localClient {
+		if lp := h.localPassword; lp != "" {
+			if subtle.ConstantTimeCompare([]byte(provided), []byte(lp)) == 1 {
+				return "local-password", config.ManagementRoleAdmin, http.StatusOK, nil
 			}
-			h.attemptsMu.Unlock()
 		}
+	}
 
-		c.Next()
+	credential, role := "", ""
+	switch {
+	case envSecret != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(envSecret)) == 1:
+		credential, role = "env-password", config.ManagementRoleAdmin
+	case secretHash != "" && bcrypt.CompareHashAndPassword([]byte(secretHash), []byte(provided)) == nil:
+		credential, role = "secret-key", config.ManagementRoleAdmin
+	default:
+		token, ok := matchToken(tokens, provided)
+		if !ok {
+			if !localClient {
+				fail()
+			}
+			return "", "", http.StatusUnauthorized, errors.New("invalid management key")
+		}
+		credential, role = "token:"+token.Name, token.Role
+	}
+
+	if !localClient {
+		h.attemptsMu.Lock()
+		if ai := h.failedAttempts[clientIP]; ai != nil {
+			ai.count = 0
+			ai.blockedUntil = time.Time{}
+		}
+		h.attemptsMu.Unlock()
 	}
+	return credential, role, http.StatusOK, nil
 }
 
 // persist saves the current in-memory config to disk.
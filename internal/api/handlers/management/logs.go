@@ -14,6 +14,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
 )
 
 const (
@@ -346,3 +347,31 @@ func timestampRotationOrder(name string) (int64, bool) {
 	}
 	return math.MaxInt64 - parsed.Unix(), true
 }
+
+// GetLogLevel returns the current log level.
+func (h *Handler) GetLogLevel(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"log-level": log.GetLevel().String()})
+}
+
+// PutLogLevel changes the log level at runtime to one of trace, debug, info, warn or error.
+// The level is not written to the configuration: a restart, or a change of the debug
+// setting, resets it.
+func (h *Handler) PutLogLevel(c *gin.Context) {
+	var body struct {
+		Value *string `json:"value"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.Value == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	level, err := log.ParseLevel(strings.TrimSpace(*body.Value))
+	if err != nil || level < log.ErrorLevel {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid log level, expected one of trace, debug, info, warn, error"})
+		return
+	}
+	if previous := log.GetLevel(); previous != level {
+		log.SetLevel(level)
+		log.Infof("log level changed from %s to %s via management API", previous, level)
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
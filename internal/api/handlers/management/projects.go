@@ -0,0 +1,67 @@
+package management
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/project"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/quota"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+// SetProjectRegistry wires the project registry used by the project endpoints.
+func (h *Handler) SetProjectRegistry(registry *project.Registry) { h.projects = registry }
+
+type projectStatus struct {
+	Name          string         `json:"name"`
+	APIKeys       []string       `json:"api-keys"`
+	AllowedModels []string       `json:"allowed-models,omitempty"`
+	Quota         []quota.Status `json:"quota,omitempty"`
+	Usage         projectUsage   `json:"usage"`
+}
+
+type projectUsage struct {
+	TotalRequests int64 `json:"total_requests"`
+	TotalTokens   int64 `json:"total_tokens"`
+}
+
+// GetProjects reports every project with its masked keys, the state of its shared quota
+// and the usage recorded for its keys. Passing ?name=<project> restricts the response to
+// a single project.
+func (h *Handler) GetProjects(c *gin.Context) {
+	if h.projects == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "project registry unavailable"})
+		return
+	}
+	names := h.projects.Names()
+	if name := c.Query("name"); name != "" {
+		if _, ok := h.projects.Get(name); !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+			return
+		}
+		names = []string{name}
+	}
+	snapshot := h.usageStats.Snapshot()
+	out := make([]projectStatus, 0, len(names))
+	for _, name := range names {
+		p, _ := h.projects.Get(name)
+		status := projectStatus{
+			Name:          p.Name,
+			APIKeys:       make([]string, 0, len(p.APIKeys)),
+			AllowedModels: p.AllowedModels,
+			Quota:         h.projects.QuotaStatus(name),
+		}
+		keys := h.projects.Keys(name)
+		for key := range keys {
+			status.APIKeys = append(status.APIKeys, util.HideAPIKey(key))
+			if apiSnapshot, ok := snapshot.APIs[key]; ok {
+				status.Usage.TotalRequests += apiSnapshot.TotalRequests
+				status.Usage.TotalTokens += apiSnapshot.TotalTokens
+			}
+		}
+		sort.Strings(status.APIKeys)
+		out = append(out, status)
+	}
+	c.JSON(http.StatusOK, gin.H{"projects": out})
+}
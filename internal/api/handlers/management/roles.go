@@ -0,0 +1,112 @@
+package management
+
+import (
+	"crypto/subtle"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// managementRouteBase is the prefix of every management route.
+const managementRouteBase = "/v0/management"
+
+// roleRank orders the management roles by privilege.
+var roleRank = map[string]int{
+	config.ManagementRoleReadOnly: 1,
+	config.ManagementRoleOperator: 2,
+	config.ManagementRoleAdmin:    3,
+}
+
+// adminRoutes return or replace credentials, or start logins that create them; every
+// method on them requires the admin role.
+var adminRoutes = map[string]struct{}{
+	"/config":                      {},
+	"/config.yaml":                 {},
+	"/proxy-url":                   {},
+	"/api-keys":                    {},
+	"/generative-language-api-key": {},
+	"/claude-api-key":              {},
+	"/codex-api-key":               {},
+	"/bedrock-api-key":             {},
+	"/azure-openai":                {},
+	"/vertex-ai":                   {},
+	"/xai-api-key":                 {},
+	"/local-backends":              {},
+	"/openai-compatibility":        {},
+	"/auth-files/download":         {},
+	"/anthropic-auth-url":          {},
+	"/codex-auth-url":              {},
+	"/gemini-cli-auth-url":         {},
+	"/qwen-auth-url":               {},
+	"/iflow-auth-url":              {},
+	"/get-auth-status":             {},
+	"/state/export":                {},
+	"/state/import":                {},
+}
+
+// adminMutationRoutes may be inspected by every role but only changed by admins.
+var adminMutationRoutes = map[string]struct{}{
+	"/auth-files": {},
+}
+
+// operatorRoutes expose request contents or server logs, so even reading them requires
+// the operator role.
+var operatorRoutes = map[string]struct{}{
+	"/logs":          {},
+	"/captures":      {},
+	"/captures/:id":  {},
+	"/sessions":      {},
+	"/sessions/:ref": {},
+}
+
+// RequiredRole returns the least privileged role that may call method on route, a
+// management route pattern relative to /v0/management such as "/accounts/status".
+// Reads default to read-only and changes to operator.
+func RequiredRole(method, route string) string {
+	if _, ok := adminRoutes[route]; ok {
+		return config.ManagementRoleAdmin
+	}
+	mutation := isMutation(method)
+	if _, ok := adminMutationRoutes[route]; ok && mutation {
+		return config.ManagementRoleAdmin
+	}
+	if _, ok := operatorRoutes[route]; ok || mutation {
+		return config.ManagementRoleOperator
+	}
+	return config.ManagementRoleReadOnly
+}
+
+// roleContextKey is the gin context key holding the role of the management caller.
+const roleContextKey = "managementRole"
+
+// revealsAPIKeys reports whether the caller of c may see inbound API keys in full; other
+// roles see them masked.
+func revealsAPIKeys(c *gin.Context) bool {
+	return RoleAllows(c.GetString(roleContextKey), config.ManagementRoleAdmin)
+}
+
+// RoleAllows reports whether role grants at least the privileges of required.
+func RoleAllows(role, required string) bool {
+	return roleRank[role] >= roleRank[required] && roleRank[role] > 0
+}
+
+// matchToken returns the configured management token equal to provided.
+func matchToken(tokens []config.ManagementToken, provided string) (config.ManagementToken, bool) {
+	for _, token := range tokens {
+		if looksLikeBcrypt(token.Key) {
+			if bcrypt.CompareHashAndPassword([]byte(token.Key), []byte(provided)) == nil {
+				return token, true
+			}
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(token.Key), []byte(provided)) == 1 {
+			return token, true
+		}
+	}
+	return config.ManagementToken{}, false
+}
+
+func looksLikeBcrypt(s string) bool {
+	return len(s) > 4 && (s[:4] == "$2a$" || s[:4] == "$2b$" || s[:4] == "$2y$")
+}
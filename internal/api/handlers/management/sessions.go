@@ -0,0 +1,66 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/session"
+	log "github.com/sirupsen/logrus"
+)
+
+// SetSessions sets the session manager behind the /sessions endpoints.
+func (h *Handler) SetSessions(manager *session.Manager) { h.sessions = manager }
+
+// ListSessions returns the stored sessions, most recently used first.
+func (h *Handler) ListSessions(c *gin.Context) {
+	sessions, err := h.sessions.List(c.Request.Context())
+	if err != nil {
+		log.Warnf("management: list sessions: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "session store is unavailable"})
+		return
+	}
+	if sessions == nil {
+		sessions = []session.Summary{}
+	}
+	c.JSON(http.StatusOK, gin.H{"enabled": h.sessions.Enabled(), "sessions": sessions})
+}
+
+// GetSession returns one session with its full history.
+func (h *Handler) GetSession(c *gin.Context) {
+	stored, ok, err := h.sessions.Get(c.Request.Context(), c.Param("ref"))
+	if err != nil {
+		log.Warnf("management: get session: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "session store is unavailable"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return
+	}
+	c.JSON(http.StatusOK, stored)
+}
+
+// DeleteSession removes one session.
+func (h *Handler) DeleteSession(c *gin.Context) {
+	deleted, err := h.sessions.Delete(c.Request.Context(), c.Param("ref"))
+	if err != nil {
+		log.Warnf("management: delete session: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "session store is unavailable"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// DeleteSessions removes every session.
+func (h *Handler) DeleteSessions(c *gin.Context) {
+	if err := h.sessions.Clear(c.Request.Context()); err != nil {
+		log.Warnf("management: clear sessions: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "session store is unavailable"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
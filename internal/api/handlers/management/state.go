@@ -0,0 +1,124 @@
+package management
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/statearchive"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+// maxStateArchiveSize bounds the size of an uploaded state archive.
+const maxStateArchiveSize = 512 << 20
+
+// ExportState returns the configuration, auth files and usage history of the server as an
+// archive encrypted with the passphrase given in the JSON body.
+func (h *Handler) ExportState(c *gin.Context) {
+	var body struct {
+		Passphrase string `json:"passphrase"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.Passphrase == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "passphrase is required"})
+		return
+	}
+	authDir := ""
+	if h.cfg != nil {
+		authDir, _ = util.ResolveAuthDir(h.cfg.AuthDir)
+	}
+	state, err := statearchive.Collect(h.configFilePath, authDir, h.usageStats)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	archive, err := statearchive.Seal(state, body.Passphrase)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	name := fmt.Sprintf("cliproxy-state-%s.bin", state.CreatedAt.Format("20060102-150405"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	c.Data(http.StatusOK, "application/octet-stream", archive)
+}
+
+// ImportState restores an archive produced by ExportState, uploaded as the multipart field
+// "file" with its passphrase in the field "passphrase". The configuration is replaced, the
+// auth files are written to the auth directory and registered, and the usage history is
+// merged into the current statistics.
+func (h *Handler) ImportState(c *gin.Context) {
+	passphrase := c.PostForm("passphrase")
+	file, err := c.FormFile("file")
+	if err != nil || passphrase == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "multipart fields file and passphrase are required"})
+		return
+	}
+	if file.Size > maxStateArchiveSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "state archive too large"})
+		return
+	}
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("failed to read upload: %v", err)})
+		return
+	}
+	data, err := io.ReadAll(src)
+	_ = src.Close()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("failed to read upload: %v", err)})
+		return
+	}
+	state, err := statearchive.Open(data, passphrase)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, statearchive.ErrPassphrase) {
+			status = http.StatusUnauthorized
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err = h.ReplaceConfigYAML(state.Config); err != nil {
+		var errUpdate *ConfigUpdateError
+		if errors.As(err, &errUpdate) {
+			c.JSON(errUpdate.Status, gin.H{"error": errUpdate.Code, "message": errUpdate.Message})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// The archive's auth-encryption matches the running one, so the restored auth files are
+	// sealed with the key in effect.
+	cfg := h.cfg
+	authDir, err := util.ResolveAuthDir(cfg.AuthDir)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	paths, err := statearchive.WriteAuthFiles(state, authDir)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var failed []string
+	for _, path := range paths {
+		name := filepath.Base(path)
+		if errReg := h.registerAuthFromFile(c.Request.Context(), path, state.AuthFiles[name]); errReg != nil {
+			failed = append(failed, name)
+		}
+	}
+	imported := h.usageStats.Import(state.Usage)
+	response := gin.H{
+		"status":        "ok",
+		"created_at":    state.CreatedAt.Format(time.RFC3339),
+		"auth_files":    len(paths),
+		"usage_records": imported,
+	}
+	if len(failed) > 0 {
+		response["unregistered"] = failed
+	}
+	c.JSON(http.StatusOK, response)
+}
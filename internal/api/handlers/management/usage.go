@@ -5,16 +5,50 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 )
 
-// GetUsageStatistics returns the in-memory request statistics snapshot.
+// GetUsageStatistics returns the in-memory request statistics snapshot. The API keys it is
+// grouped by are masked for callers below the admin role.
 func (h *Handler) GetUsageStatistics(c *gin.Context) {
 	var snapshot usage.StatisticsSnapshot
 	if h != nil && h.usageStats != nil {
 		snapshot = h.usageStats.Snapshot()
 	}
+	if !revealsAPIKeys(c) {
+		snapshot.APIs = maskedUsageKeys(snapshot.APIs)
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"usage":           snapshot,
 		"failed_requests": snapshot.FailureCount,
 	})
 }
+
+// maskedUsageKeys regroups per-key statistics by masked key, merging keys whose masks collide.
+func maskedUsageKeys(apis map[string]usage.APISnapshot) map[string]usage.APISnapshot {
+	out := make(map[string]usage.APISnapshot, len(apis))
+	for key, api := range apis {
+		masked := util.HideAPIKey(key)
+		existing, ok := out[masked]
+		if !ok {
+			out[masked] = api
+			continue
+		}
+		existing.TotalRequests += api.TotalRequests
+		existing.TotalTokens += api.TotalTokens
+		models := make(map[string]usage.ModelSnapshot, len(existing.Models)+len(api.Models))
+		for _, source := range []map[string]usage.ModelSnapshot{existing.Models, api.Models} {
+			for name, model := range source {
+				merged := models[name]
+				merged.TotalRequests += model.TotalRequests
+				merged.TotalTokens += model.TotalTokens
+				merged.TotalImages += model.TotalImages
+				merged.Details = append(merged.Details, model.Details...)
+				models[name] = merged
+			}
+		}
+		existing.Models = models
+		out[masked] = existing
+	}
+	return out
+}
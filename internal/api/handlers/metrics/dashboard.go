@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// defaultRecentErrors and maxRecentErrors bound the limit query parameter of the errors endpoint.
+const (
+	defaultRecentErrors = 50
+	maxRecentErrors     = 500
+)
+
+// AccountStatus is the dashboard view of an upstream account's health.
+type AccountStatus struct {
+	Provider       string     `json:"provider"`
+	Label          string     `json:"label"` // masked
+	Status         string     `json:"status"`
+	Disabled       bool       `json:"disabled"`
+	Unavailable    bool       `json:"unavailable"`
+	QuotaExceeded  bool       `json:"quota_exceeded"`
+	QuotaReason    string     `json:"quota_reason,omitempty"`
+	NextRecoverAt  *time.Time `json:"next_recover_at,omitempty"`
+	NextRetryAfter *time.Time `json:"next_retry_after,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	Circuit        string     `json:"circuit,omitempty"`
+}
+
+// RecentError describes a failed request recorded by the usage statistics.
+type RecentError struct {
+	Timestamp time.Time `json:"timestamp"`
+	Model     string    `json:"model"`
+	Key       string    `json:"key"` // masked
+	Source    string    `json:"source,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+	LatencyMS int64     `json:"latency_ms,omitempty"`
+}
+
+// SetAuthManager sets the core auth manager used to report account health.
+func (h *Handler) SetAuthManager(manager *coreauth.Manager) { h.authManager = manager }
+
+// GetAccounts is the handler for the /v0/management/dashboard/accounts endpoint.
+func (h *Handler) GetAccounts(c *gin.Context) {
+	accounts := make([]AccountStatus, 0)
+	if h.authManager != nil {
+		circuits := make(map[string]coreauth.CircuitState)
+		for _, circuit := range h.authManager.CircuitSnapshot() {
+			circuits[circuit.ID] = circuit.State
+		}
+		for _, auth := range h.authManager.List() {
+			label := auth.Label
+			if label == "" {
+				label = auth.ID
+			}
+			status := AccountStatus{
+				Provider:      auth.Provider,
+				Label:         util.HideAPIKey(label),
+				Status:        string(auth.Status),
+				Disabled:      auth.Disabled,
+				Unavailable:   auth.Unavailable,
+				QuotaExceeded: auth.Quota.Exceeded,
+				QuotaReason:   auth.Quota.Reason,
+				Circuit:       string(circuits[auth.ID]),
+			}
+			if !auth.Quota.NextRecoverAt.IsZero() {
+				recoverAt := auth.Quota.NextRecoverAt
+				status.NextRecoverAt = &recoverAt
+			}
+			if !auth.NextRetryAfter.IsZero() {
+				retryAfter := auth.NextRetryAfter
+				status.NextRetryAfter = &retryAfter
+			}
+			if auth.LastError != nil {
+				status.LastError = auth.LastError.Message
+			}
+			accounts = append(accounts, status)
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		if accounts[i].Provider != accounts[j].Provider {
+			return accounts[i].Provider < accounts[j].Provider
+		}
+		return accounts[i].Label < accounts[j].Label
+	})
+	c.JSON(http.StatusOK, gin.H{"accounts": accounts})
+}
+
+// GetRecentErrors is the handler for the /v0/management/dashboard/errors endpoint.
+// It returns the most recent failed requests, newest first, capped by the limit query parameter.
+func (h *Handler) GetRecentErrors(c *gin.Context) {
+	limit := defaultRecentErrors
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'limit' value"})
+			return
+		}
+		limit = min(parsed, maxRecentErrors)
+	}
+
+	snapshot := h.Stats.Snapshot()
+	recent := make([]RecentError, 0)
+	for apiKey, apiSnapshot := range snapshot.APIs {
+		for modelName, modelSnapshot := range apiSnapshot.Models {
+			for _, detail := range modelSnapshot.Details {
+				if !detail.Failed {
+					continue
+				}
+				recent = append(recent, RecentError{
+					Timestamp: detail.Timestamp,
+					Model:     modelName,
+					Key:       displayKey(apiKey),
+					Source:    util.HideAPIKey(detail.Source),
+					RequestID: detail.RequestID,
+					LatencyMS: detail.LatencyMS,
+				})
+			}
+		}
+	}
+	sort.Slice(recent, func(i, j int) bool { return recent[i].Timestamp.After(recent[j].Timestamp) })
+	if len(recent) > limit {
+		recent = recent[:limit]
+	}
+	c.JSON(http.StatusOK, gin.H{"errors": recent})
+}
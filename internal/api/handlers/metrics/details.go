@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+// defaultDetailPage and maxDetailPage bound the limit query parameter of the details endpoint.
+const (
+	defaultDetailPage = 100
+	maxDetailPage     = 1000
+)
+
+// DetailRecord is one recorded request as returned by the details endpoint.
+type DetailRecord struct {
+	Key   string `json:"key"` // masked
+	Model string `json:"model"`
+	usage.RequestDetail
+}
+
+// GetDetails is the handler for the /_qs/metrics/details endpoint. It pages through the raw
+// request details matching the period, model and project filters of /_qs/metrics, oldest
+// first; pass the next_cursor of a page as cursor to fetch the page after it.
+func (h *Handler) GetDetails(c *gin.Context) {
+	fromTime, toTime, err := parsePeriod(c.Query("from"), c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	limit := defaultDetailPage
+	if raw := c.Query("limit"); raw != "" {
+		parsed, errAtoi := strconv.Atoi(raw)
+		if errAtoi != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'limit' value"})
+			return
+		}
+		limit = min(parsed, maxDetailPage)
+	}
+	query := usage.DetailQuery{From: fromTime, To: toTime, Model: c.Query("model"), After: c.Query("cursor"), Limit: limit}
+	if projectName := c.Query("project"); projectName != "" {
+		if _, ok := h.projects.Get(projectName); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown project %q", projectName)})
+			return
+		}
+		query.APIs = h.projects.Keys(projectName)
+	}
+
+	details, next, err := h.Stats.DetailPage(query)
+	if errors.Is(err, usage.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'cursor' value"})
+		return
+	}
+	records := make([]DetailRecord, 0, len(details))
+	for _, item := range details {
+		item.Detail.Source = util.HideAPIKey(item.Detail.Source)
+		records = append(records, DetailRecord{Key: displayKey(item.API), Model: item.Model, RequestDetail: item.Detail})
+	}
+	resp := gin.H{"details": records}
+	if next != "" {
+		resp["next_cursor"] = next
+	}
+	c.JSON(http.StatusOK, resp)
+}
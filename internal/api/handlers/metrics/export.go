@@ -0,0 +1,296 @@
+package metrics
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// exportPageSize is the number of details read from the statistics per page of a raw export.
+const exportPageSize = 1000
+
+// RequestExportRow is one request of a raw usage export.
+type RequestExportRow struct {
+	Timestamp       string `json:"timestamp"`
+	Key             string `json:"key"` // masked
+	Model           string `json:"model"`
+	Account         string `json:"account"` // masked
+	Provider        string `json:"provider"`
+	Status          string `json:"status"`
+	ErrorCode       string `json:"error_code"`
+	InputTokens     int64  `json:"input_tokens"`
+	OutputTokens    int64  `json:"output_tokens"`
+	ReasoningTokens int64  `json:"reasoning_tokens"`
+	CachedTokens    int64  `json:"cached_tokens"`
+	TotalTokens     int64  `json:"total_tokens"`
+	LatencyMS       int64  `json:"latency_ms"`
+	TTFTMS          int64  `json:"ttft_ms"`
+	RequestID       string `json:"request_id"`
+}
+
+var requestExportHeader = []string{"timestamp", "key", "model", "account", "provider", "status", "error_code", "input_tokens", "output_tokens", "reasoning_tokens", "cached_tokens", "total_tokens", "latency_ms", "ttft_ms", "request_id"}
+
+func (r RequestExportRow) csvValues() []string {
+	return []string{r.Timestamp, r.Key, r.Model, r.Account, r.Provider, r.Status, r.ErrorCode,
+		formatInt(r.InputTokens), formatInt(r.OutputTokens), formatInt(r.ReasoningTokens), formatInt(r.CachedTokens), formatInt(r.TotalTokens),
+		formatInt(r.LatencyMS), formatInt(r.TTFTMS), r.RequestID}
+}
+
+// AggregateExportRow is one time bucket of one client key and model in an aggregated usage
+// export.
+type AggregateExportRow struct {
+	BucketStart     string  `json:"bucket_start"`
+	Key             string  `json:"key"` // masked
+	Model           string  `json:"model"`
+	Requests        int64   `json:"requests"`
+	Failures        int64   `json:"failures"`
+	InputTokens     int64   `json:"input_tokens"`
+	OutputTokens    int64   `json:"output_tokens"`
+	ReasoningTokens int64   `json:"reasoning_tokens"`
+	CachedTokens    int64   `json:"cached_tokens"`
+	TotalTokens     int64   `json:"total_tokens"`
+	Cost            float64 `json:"cost"`
+	LatencyP50MS    float64 `json:"latency_p50_ms"`
+	LatencyP90MS    float64 `json:"latency_p90_ms"`
+	LatencyP99MS    float64 `json:"latency_p99_ms"`
+}
+
+var aggregateExportHeader = []string{"bucket_start", "key", "model", "requests", "failures", "input_tokens", "output_tokens", "reasoning_tokens", "cached_tokens", "total_tokens", "cost", "latency_p50_ms", "latency_p90_ms", "latency_p99_ms"}
+
+func (r AggregateExportRow) csvValues() []string {
+	return []string{r.BucketStart, r.Key, r.Model, formatInt(r.Requests), formatInt(r.Failures),
+		formatInt(r.InputTokens), formatInt(r.OutputTokens), formatInt(r.ReasoningTokens), formatInt(r.CachedTokens), formatInt(r.TotalTokens),
+		strconv.FormatFloat(r.Cost, 'f', -1, 64), strconv.FormatFloat(r.LatencyP50MS, 'f', -1, 64), strconv.FormatFloat(r.LatencyP90MS, 'f', -1, 64), strconv.FormatFloat(r.LatencyP99MS, 'f', -1, 64)}
+}
+
+// exportEncoder writes export rows as CSV or as JSON lines.
+type exportEncoder struct {
+	csv     *csv.Writer
+	json    *json.Encoder
+	flusher http.Flusher
+}
+
+func newExportEncoder(w io.Writer, format string, header []string) (*exportEncoder, error) {
+	enc := &exportEncoder{}
+	enc.flusher, _ = w.(http.Flusher)
+	if format == "jsonl" {
+		enc.json = json.NewEncoder(w)
+		return enc, nil
+	}
+	enc.csv = csv.NewWriter(w)
+	return enc, enc.csv.Write(header)
+}
+
+func (e *exportEncoder) write(row interface{ csvValues() []string }) error {
+	if e.json != nil {
+		return e.json.Encode(row)
+	}
+	return e.csv.Write(row.csvValues())
+}
+
+// flush sends the rows written so far to the client.
+func (e *exportEncoder) flush() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if e.flusher != nil {
+		e.flusher.Flush()
+	}
+	return nil
+}
+
+// ExportMetrics is the handler for the /_qs/metrics/export endpoint. It downloads the usage
+// of the period, model and project filters of /_qs/metrics as CSV (format=csv, the default)
+// or JSON lines (format=jsonl), either one row per request (type=raw, the default) or one
+// row per time bucket, client key and model (type=aggregated, sized by bucket). Raw exports
+// are streamed page by page, so they cover the retained request details at bounded memory.
+func (h *Handler) ExportMetrics(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "jsonl" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'format' value, expected csv or jsonl"})
+		return
+	}
+	kind := c.DefaultQuery("type", "raw")
+	if kind != "raw" && kind != "aggregated" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'type' value, expected raw or aggregated"})
+		return
+	}
+	bucketSize, ok := BucketSize(c.DefaultQuery("bucket", "1h"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'bucket' value, expected one of 1m, 5m, 1h, 1d"})
+		return
+	}
+	fromTime, toTime, err := parsePeriod(c.Query("from"), c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var projectKeys map[string]struct{}
+	if projectName := c.Query("project"); projectName != "" {
+		if _, exists := h.projects.Get(projectName); !exists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown project %q", projectName)})
+			return
+		}
+		projectKeys = h.projects.Keys(projectName)
+	}
+	modelFilter := c.Query("model")
+
+	header := requestExportHeader
+	if kind == "aggregated" {
+		header = aggregateExportHeader
+	}
+	contentType := "text/csv; charset=utf-8"
+	if format == "jsonl" {
+		contentType = "application/x-ndjson"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"usage-%s-%s.%s\"", kind, time.Now().UTC().Format("20060102T150405Z"), format))
+	c.Status(http.StatusOK)
+	enc, err := newExportEncoder(c.Writer, format, header)
+	if err == nil {
+		if kind == "aggregated" {
+			err = h.exportAggregates(enc, Query{From: fromTime, To: toTime, Model: modelFilter, Bucket: bucketSize}, projectKeys)
+		} else {
+			err = h.exportRequests(enc, usage.DetailQuery{From: fromTime, To: toTime, Model: modelFilter, APIs: projectKeys, Limit: exportPageSize})
+		}
+	}
+	if err == nil {
+		err = enc.flush()
+	}
+	if err != nil {
+		// The status is already sent; the client sees a truncated download.
+		log.Warnf("usage export aborted: %v", err)
+	}
+}
+
+func (h *Handler) exportRequests(enc *exportEncoder, query usage.DetailQuery) error {
+	for {
+		details, next, err := h.Stats.DetailPage(query)
+		if err != nil {
+			return err
+		}
+		for _, item := range details {
+			detail := item.Detail
+			status := "success"
+			if detail.Failed {
+				status = "failed"
+			}
+			row := RequestExportRow{
+				Timestamp:       detail.Timestamp.UTC().Format(time.RFC3339Nano),
+				Key:             displayKey(item.API),
+				Model:           item.Model,
+				Account:         util.HideAPIKey(detail.Source),
+				Provider:        detail.Provider,
+				Status:          status,
+				ErrorCode:       detail.ErrorCode,
+				InputTokens:     detail.Tokens.InputTokens,
+				OutputTokens:    detail.Tokens.OutputTokens,
+				ReasoningTokens: detail.Tokens.ReasoningTokens,
+				CachedTokens:    detail.Tokens.CachedTokens,
+				TotalTokens:     detail.Tokens.TotalTokens,
+				LatencyMS:       detail.LatencyMS,
+				TTFTMS:          detail.TTFTMS,
+				RequestID:       detail.RequestID,
+			}
+			if err = enc.write(row); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		if err = enc.flush(); err != nil {
+			return err
+		}
+		query.After = next
+	}
+}
+
+func (h *Handler) exportAggregates(enc *exportEncoder, q Query, projectKeys map[string]struct{}) error {
+	type rowKey struct {
+		start time.Time
+		api   string
+		model string
+	}
+	type rowState struct {
+		tokens   usage.TokenStats
+		requests int64
+		failures int64
+		latency  usage.Histogram
+	}
+	rows := make(map[rowKey]*rowState)
+	for _, bucket := range h.Stats.Aggregates(q.From, q.To) {
+		if projectKeys != nil {
+			if _, ok := projectKeys[bucket.API]; !ok {
+				continue
+			}
+		}
+		if q.Model != "" && q.Model != bucket.Model {
+			continue
+		}
+		key := rowKey{start: truncateToBucket(bucket.Start, q.Bucket), api: bucket.API, model: bucket.Model}
+		row, ok := rows[key]
+		if !ok {
+			row = &rowState{}
+			rows[key] = row
+		}
+		row.requests += bucket.Requests
+		row.failures += bucket.Failures
+		row.tokens = row.tokens.Add(bucket.Tokens)
+		row.latency.Merge(bucket.Latency)
+	}
+
+	keys := make([]rowKey, 0, len(rows))
+	for key := range rows {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].start.Equal(keys[j].start) {
+			return keys[i].start.Before(keys[j].start)
+		}
+		if keys[i].api != keys[j].api {
+			return keys[i].api < keys[j].api
+		}
+		return keys[i].model < keys[j].model
+	})
+
+	pricing := h.pricing.Load()
+	for _, key := range keys {
+		row := rows[key]
+		out := AggregateExportRow{
+			BucketStart:     key.start.Format(time.RFC3339),
+			Key:             displayKey(key.api),
+			Model:           key.model,
+			Requests:        row.requests,
+			Failures:        row.failures,
+			InputTokens:     row.tokens.InputTokens,
+			OutputTokens:    row.tokens.OutputTokens,
+			ReasoningTokens: row.tokens.ReasoningTokens,
+			CachedTokens:    row.tokens.CachedTokens,
+			TotalTokens:     row.tokens.TotalTokens,
+			Cost:            pricing.EstimateCost(key.model, row.tokens),
+		}
+		if latency := latencyPercentiles(&row.latency); latency != nil {
+			out.LatencyP50MS, out.LatencyP90MS, out.LatencyP99MS = latency.P50, latency.P90, latency.P99
+		}
+		if err := enc.write(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatInt(v int64) string { return strconv.FormatInt(v, 10) }
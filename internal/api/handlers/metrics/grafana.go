@@ -0,0 +1,474 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+)
+
+// grafanaMetrics lists the targets offered to Grafana's metric picker.
+var grafanaMetrics = []string{
+	"requests", "failures", "retries", "success_rate",
+	"tokens.input", "tokens.output", "tokens.reasoning", "tokens.cached", "tokens.total",
+	"cost",
+	"latency.p50", "latency.p90", "latency.p99",
+	"ttft.p50", "ttft.p90", "ttft.p99",
+}
+
+// grafanaProviderMetrics are the targets that can be grouped by provider; the aggregates
+// keep only request counts and latency per provider.
+var grafanaProviderMetrics = map[string]bool{
+	"requests": true, "failures": true, "success_rate": true,
+	"latency.p50": true, "latency.p90": true, "latency.p99": true,
+}
+
+// grafanaMaxAnnotations caps the failed requests returned as annotations by one query.
+const grafanaMaxAnnotations = 1000
+
+// GrafanaRange is the time range of a Grafana query.
+type GrafanaRange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// GrafanaFilter is an ad hoc filter set on a Grafana dashboard; only the "=" operator is
+// supported.
+type GrafanaFilter struct {
+	Key      string `json:"key"`
+	Operator string `json:"operator"`
+	Value    string `json:"value"`
+}
+
+// GrafanaTarget is one query of a Grafana panel. Target names a metric, optionally
+// followed by " by model", " by key" or " by provider" to split it into one series per
+// group; the payload may set the same options as fields.
+type GrafanaTarget struct {
+	Target  string `json:"target"`
+	RefID   string `json:"refId"`
+	Type    string `json:"type"`
+	Payload struct {
+		GroupBy string `json:"group_by"`
+		Model   string `json:"model"`
+		Project string `json:"project"`
+	} `json:"payload"`
+}
+
+// GrafanaQueryRequest is the body of a Grafana JSON datasource /query call.
+type GrafanaQueryRequest struct {
+	Range         GrafanaRange    `json:"range"`
+	IntervalMS    int64           `json:"intervalMs"`
+	MaxDataPoints int64           `json:"maxDataPoints"`
+	Targets       []GrafanaTarget `json:"targets"`
+	AdhocFilters  []GrafanaFilter `json:"adhocFilters"`
+}
+
+// GrafanaSeries is a time series answer: datapoints are [value, unix milliseconds] pairs.
+type GrafanaSeries struct {
+	Target     string       `json:"target"`
+	RefID      string       `json:"refId,omitempty"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+// GrafanaColumn describes one column of a table answer.
+type GrafanaColumn struct {
+	Text string `json:"text"`
+	Type string `json:"type"`
+}
+
+// GrafanaTable is a table answer with one row per group over the whole range.
+type GrafanaTable struct {
+	Type    string          `json:"type"`
+	RefID   string          `json:"refId,omitempty"`
+	Columns []GrafanaColumn `json:"columns"`
+	Rows    [][]any         `json:"rows"`
+}
+
+// GrafanaAnnotation marks a failed request on a Grafana graph.
+type GrafanaAnnotation struct {
+	Annotation any      `json:"annotation"`
+	Time       int64    `json:"time"`
+	Title      string   `json:"title"`
+	Text       string   `json:"text"`
+	Tags       []string `json:"tags"`
+}
+
+// grafanaPoint accumulates the usage of one group in one time bucket.
+type grafanaPoint struct {
+	requests int64
+	failures int64
+	retries  int64
+	tokens   usage.TokenStats
+	cost     float64
+	latency  usage.Histogram
+	ttft     usage.Histogram
+}
+
+func (p *grafanaPoint) value(metric string) (float64, bool) {
+	switch metric {
+	case "requests":
+		return float64(p.requests), true
+	case "failures":
+		return float64(p.failures), true
+	case "retries":
+		return float64(p.retries), true
+	case "success_rate":
+		if p.requests == 0 {
+			return 0, false
+		}
+		return float64(p.requests-p.failures) / float64(p.requests), true
+	case "tokens.input":
+		return float64(p.tokens.InputTokens), true
+	case "tokens.output":
+		return float64(p.tokens.OutputTokens), true
+	case "tokens.reasoning":
+		return float64(p.tokens.ReasoningTokens), true
+	case "tokens.cached":
+		return float64(p.tokens.CachedTokens), true
+	case "tokens.total":
+		return float64(p.tokens.TotalTokens), true
+	case "cost":
+		return p.cost, true
+	}
+	histogram, name, ok := strings.Cut(metric, ".")
+	if !ok {
+		return 0, false
+	}
+	q := map[string]float64{"p50": 0.50, "p90": 0.90, "p99": 0.99}[name]
+	if q == 0 {
+		return 0, false
+	}
+	h := &p.latency
+	if histogram == "ttft" {
+		h = &p.ttft
+	}
+	values := h.Quantiles(q)
+	if values == nil {
+		return 0, false
+	}
+	return values[0], true
+}
+
+// GrafanaHealth answers the connection test of the Grafana JSON datasource.
+func (h *Handler) GrafanaHealth(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// GrafanaMetricOptions lists the metrics for the metric picker of current versions of the
+// Grafana JSON datasource.
+func (h *Handler) GrafanaMetricOptions(c *gin.Context) {
+	var body struct {
+		Metric string `json:"metric"`
+	}
+	_ = c.ShouldBindJSON(&body)
+	options := make([]gin.H, 0, len(grafanaMetrics))
+	for _, metric := range grafanaMetrics {
+		if strings.Contains(metric, strings.ToLower(body.Metric)) {
+			options = append(options, gin.H{"label": metric, "value": metric})
+		}
+	}
+	c.JSON(http.StatusOK, options)
+}
+
+// GrafanaSearch lists the targets, including their groupings, for the metric picker of
+// older versions of the Grafana JSON datasource.
+func (h *Handler) GrafanaSearch(c *gin.Context) {
+	var body struct {
+		Target string `json:"target"`
+	}
+	_ = c.ShouldBindJSON(&body)
+	filter := strings.ToLower(body.Target)
+	targets := make([]string, 0, len(grafanaMetrics)*4)
+	for _, metric := range grafanaMetrics {
+		for _, suffix := range []string{"", " by model", " by key", " by provider"} {
+			if suffix == " by provider" && !grafanaProviderMetrics[metric] {
+				continue
+			}
+			if target := metric + suffix; strings.Contains(target, filter) {
+				targets = append(targets, target)
+			}
+		}
+	}
+	c.JSON(http.StatusOK, targets)
+}
+
+// GrafanaTagKeys lists the keys available as ad hoc filters.
+func (h *Handler) GrafanaTagKeys(c *gin.Context) {
+	c.JSON(http.StatusOK, []gin.H{{"type": "string", "text": "model"}, {"type": "string", "text": "project"}})
+}
+
+// GrafanaTagValues lists the values of an ad hoc filter key.
+func (h *Handler) GrafanaTagValues(c *gin.Context) {
+	var body struct {
+		Key string `json:"key"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	values := make([]gin.H, 0)
+	switch body.Key {
+	case "model":
+		seen := make(map[string]struct{})
+		for _, bucket := range h.Stats.Aggregates(time.Time{}, time.Time{}) {
+			if _, ok := seen[bucket.Model]; ok {
+				continue
+			}
+			seen[bucket.Model] = struct{}{}
+			values = append(values, gin.H{"text": bucket.Model})
+		}
+	case "project":
+		for _, name := range h.projects.Names() {
+			values = append(values, gin.H{"text": name})
+		}
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i]["text"].(string) < values[j]["text"].(string) })
+	c.JSON(http.StatusOK, values)
+}
+
+// GrafanaQuery answers the panels of a Grafana dashboard from the pre-aggregated usage
+// buckets. Time series are bucketed by the panel interval, widened so a panel never gets
+// more points than it asked for; table targets return one row per group over the range.
+func (h *Handler) GrafanaQuery(c *gin.Context) {
+	var req GrafanaQueryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if req.Range.From.IsZero() || req.Range.To.IsZero() || req.Range.To.Before(req.Range.From) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'range'"})
+		return
+	}
+
+	interval := time.Duration(req.IntervalMS) * time.Millisecond
+	if req.MaxDataPoints > 0 {
+		interval = max(interval, req.Range.To.Sub(req.Range.From)/time.Duration(req.MaxDataPoints))
+	}
+	interval = max(interval, time.Minute)
+	if span := req.Range.To.Sub(req.Range.From); span/interval > maxTimeseriesBuckets {
+		interval = span / maxTimeseriesBuckets
+	}
+	interval = interval.Truncate(time.Minute)
+
+	results := make([]any, 0, len(req.Targets))
+	for _, target := range req.Targets {
+		if strings.TrimSpace(target.Target) == "" {
+			continue
+		}
+		result, err := h.grafanaTarget(req, target, interval)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		results = append(results, result...)
+	}
+	c.JSON(http.StatusOK, results)
+}
+
+func (h *Handler) grafanaTarget(req GrafanaQueryRequest, target GrafanaTarget, interval time.Duration) ([]any, error) {
+	metric, groupBy, _ := strings.Cut(strings.TrimSpace(target.Target), " by ")
+	metric = strings.TrimSpace(metric)
+	if groupBy = strings.TrimSpace(groupBy); groupBy == "" {
+		groupBy = target.Payload.GroupBy
+	}
+	if !grafanaMetricKnown(metric) {
+		return nil, fmt.Errorf("unknown metric %q", metric)
+	}
+	switch groupBy {
+	case "", "model", "key":
+	case "provider":
+		if !grafanaProviderMetrics[metric] {
+			return nil, fmt.Errorf("metric %q cannot be grouped by provider", metric)
+		}
+	default:
+		return nil, fmt.Errorf("unknown grouping %q, expected model, key or provider", groupBy)
+	}
+
+	modelFilter, projectName := target.Payload.Model, target.Payload.Project
+	for _, filter := range req.AdhocFilters {
+		if filter.Operator != "" && filter.Operator != "=" {
+			continue
+		}
+		switch filter.Key {
+		case "model":
+			modelFilter = filter.Value
+		case "project":
+			projectName = filter.Value
+		}
+	}
+	var projectKeys map[string]struct{}
+	if projectName != "" {
+		if _, ok := h.projects.Get(projectName); !ok {
+			return nil, fmt.Errorf("unknown project %q", projectName)
+		}
+		projectKeys = h.projects.Keys(projectName)
+	}
+
+	table := target.Type == "table"
+	pricing := h.pricing.Load()
+	points := make(map[string]map[int64]*grafanaPoint)
+	point := func(group string, start time.Time) *grafanaPoint {
+		byTime, ok := points[group]
+		if !ok {
+			byTime = make(map[int64]*grafanaPoint)
+			points[group] = byTime
+		}
+		var at int64
+		if !table {
+			at = start.Truncate(interval).UnixMilli()
+		}
+		p, ok := byTime[at]
+		if !ok {
+			p = &grafanaPoint{}
+			byTime[at] = p
+		}
+		return p
+	}
+	for _, bucket := range h.Stats.Aggregates(req.Range.From, req.Range.To) {
+		if modelFilter != "" && bucket.Model != modelFilter {
+			continue
+		}
+		if projectKeys != nil {
+			if _, ok := projectKeys[bucket.API]; !ok {
+				continue
+			}
+		}
+		if groupBy == "provider" {
+			for providerName, provider := range bucket.Providers {
+				p := point(orUnknown(providerName), bucket.Start)
+				p.requests += provider.Requests
+				p.failures += provider.Failures
+				p.latency.Merge(provider.Latency)
+			}
+			continue
+		}
+		group := metric
+		switch groupBy {
+		case "model":
+			group = bucket.Model
+		case "key":
+			group = displayKey(bucket.API)
+		}
+		p := point(group, bucket.Start)
+		p.requests += bucket.Requests
+		p.failures += bucket.Failures
+		p.retries += bucket.Retries
+		p.tokens = p.tokens.Add(bucket.Tokens)
+		p.cost += pricing.EstimateCost(bucket.Model, bucket.Tokens)
+		p.latency.Merge(bucket.Latency)
+		p.ttft.Merge(bucket.TTFT)
+	}
+
+	groups := make([]string, 0, len(points))
+	for group := range points {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	if table {
+		column := "series"
+		if groupBy != "" {
+			column = groupBy
+		}
+		result := GrafanaTable{
+			Type:    "table",
+			RefID:   target.RefID,
+			Columns: []GrafanaColumn{{Text: column, Type: "string"}, {Text: metric, Type: "number"}},
+			Rows:    make([][]any, 0, len(groups)),
+		}
+		for _, group := range groups {
+			if value, ok := points[group][0].value(metric); ok {
+				result.Rows = append(result.Rows, []any{group, roundValue(value)})
+			}
+		}
+		return []any{result}, nil
+	}
+
+	results := make([]any, 0, len(groups))
+	for _, group := range groups {
+		series := GrafanaSeries{Target: group, RefID: target.RefID, Datapoints: make([][2]float64, 0, len(points[group]))}
+		if groupBy != "" {
+			series.Target = metric + " " + group
+		}
+		for at, p := range points[group] {
+			if value, ok := p.value(metric); ok {
+				series.Datapoints = append(series.Datapoints, [2]float64{roundValue(value), float64(at)})
+			}
+		}
+		sort.Slice(series.Datapoints, func(i, j int) bool { return series.Datapoints[i][1] < series.Datapoints[j][1] })
+		results = append(results, series)
+	}
+	return results, nil
+}
+
+// grafanaMetricKnown reports whether metric is one of the offered targets.
+func grafanaMetricKnown(metric string) bool {
+	for _, name := range grafanaMetrics {
+		if name == metric {
+			return true
+		}
+	}
+	return false
+}
+
+// GrafanaAnnotations marks the failed requests of the range on Grafana graphs. A non-empty
+// annotation query other than "errors" restricts them to that proxy error code.
+func (h *Handler) GrafanaAnnotations(c *gin.Context) {
+	var req struct {
+		Range      GrafanaRange `json:"range"`
+		Annotation struct {
+			Name  string `json:"name"`
+			Query string `json:"query"`
+		} `json:"annotation"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	code := strings.TrimSpace(req.Annotation.Query)
+	if code == "errors" {
+		code = ""
+	}
+
+	annotations := make([]GrafanaAnnotation, 0)
+	query := usage.DetailQuery{From: req.Range.From, To: req.Range.To, Limit: exportPageSize}
+	for len(annotations) < grafanaMaxAnnotations {
+		details, next, err := h.Stats.DetailPage(query)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		for _, item := range details {
+			detail := item.Detail
+			if !detail.Failed || (code != "" && detail.ErrorCode != code) {
+				continue
+			}
+			errorCode := orUnknown(detail.ErrorCode)
+			annotations = append(annotations, GrafanaAnnotation{
+				Annotation: req.Annotation,
+				Time:       detail.Timestamp.UnixMilli(),
+				Title:      errorCode,
+				Text:       fmt.Sprintf("%s via %s (request %s)", item.Model, orUnknown(detail.Provider), detail.RequestID),
+				Tags:       []string{errorCode, orUnknown(detail.Provider), item.Model},
+			})
+			if len(annotations) == grafanaMaxAnnotations {
+				break
+			}
+		}
+		if next == "" {
+			break
+		}
+		query.After = next
+	}
+	c.JSON(http.StatusOK, annotations)
+}
+
+// roundValue rounds a datapoint to four decimals, enough for rates and costs.
+func roundValue(value float64) float64 {
+	return math.Round(value*1e4) / 1e4
+}
@@ -2,19 +2,37 @@
 package metrics
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"sort"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/admission"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/moderation"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/project"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
 )
 
 // Handler holds the dependencies for the metrics handlers.
 type Handler struct {
 	Stats *usage.RequestStatistics
+
+	pricing     atomic.Pointer[usage.PriceTable]
+	authManager *coreauth.Manager
+	projects    *project.Registry
+	admission   *admission.Queue
+	moderator   *moderation.Moderator
+	// clientCancellations reports the requests aborted because the client disconnected.
+	clientCancellations func() int64
+	// errorCounts reports the errors returned to clients per proxy error code.
+	errorCounts func() map[string]int64
 }
 
 // NewHandler creates a new metrics handler.
@@ -22,113 +40,357 @@ func NewHandler(stats *usage.RequestStatistics) *Handler {
 	return &Handler{Stats: stats}
 }
 
+// SetPricing replaces the price table used for cost estimation.
+func (h *Handler) SetPricing(table *usage.PriceTable) { h.pricing.Store(table) }
+
+// SetProjectRegistry wires the project registry that resolves the project filter.
+func (h *Handler) SetProjectRegistry(registry *project.Registry) { h.projects = registry }
+
+// SetAdmissionQueue wires the admission queue whose state is exported to Prometheus.
+func (h *Handler) SetAdmissionQueue(queue *admission.Queue) { h.admission = queue }
+
+// SetModerator wires the moderator whose decisions are exported to Prometheus.
+func (h *Handler) SetModerator(moderator *moderation.Moderator) { h.moderator = moderator }
+
+// SetClientCancellations wires the counter of requests aborted because the client
+// disconnected.
+func (h *Handler) SetClientCancellations(count func() int64) { h.clientCancellations = count }
+
+// SetErrorCounts wires the counters of errors returned to clients per proxy error code.
+func (h *Handler) SetErrorCounts(counts func() map[string]int64) { h.errorCounts = counts }
+
 // MetricsResponse is the top-level struct for the metrics endpoint response.
 type MetricsResponse struct {
-	Totals     TotalsMetrics      `json:"totals"`
-	ByModel    []ModelMetrics     `json:"by_model"`
+	Totals  TotalsMetrics  `json:"totals"`
+	ByModel []ModelMetrics `json:"by_model"`
+	ByKey   []KeyMetrics   `json:"by_key"`
+	// ByProvider aggregates the requests per upstream provider.
+	ByProvider []ProviderMetrics  `json:"by_provider"`
 	Timeseries []TimeseriesBucket `json:"timeseries"`
+	// Errors counts the failed requests per proxy error code, provider and model, most
+	// frequent first.
+	Errors []ErrorMetrics `json:"errors"`
 }
 
 // TotalsMetrics holds the aggregated totals for the queried period.
 type TotalsMetrics struct {
-	Tokens   int64 `json:"tokens"`
-	Requests int64 `json:"requests"`
+	Tokens          int64        `json:"tokens"`
+	EmbeddingTokens int64        `json:"embedding_tokens,omitempty"`
+	Images          int64        `json:"images,omitempty"`
+	Requests        int64        `json:"requests"`
+	Failures        int64        `json:"failures"`
+	Retries         int64        `json:"retries,omitempty"`
+	Cost            float64      `json:"cost"`
+	TTFTMS          *Percentiles `json:"ttft_ms,omitempty"`
+	TokensPerSecond *Percentiles `json:"tokens_per_second,omitempty"`
+	// LatencyMS is the distribution of request durations, from dispatch to the last byte.
+	LatencyMS *LatencyPercentiles `json:"latency_ms,omitempty"`
 }
 
 // ModelMetrics holds the aggregated metrics for a specific model.
 type ModelMetrics struct {
-	Model    string `json:"model"`
-	Tokens   int64  `json:"tokens"`
-	Requests int64  `json:"requests"`
+	Model           string              `json:"model"`
+	Tokens          int64               `json:"tokens"`
+	EmbeddingTokens int64               `json:"embedding_tokens,omitempty"`
+	Images          int64               `json:"images,omitempty"`
+	Requests        int64               `json:"requests"`
+	Retries         int64               `json:"retries,omitempty"`
+	Cost            float64             `json:"cost"`
+	TTFTMS          *Percentiles        `json:"ttft_ms,omitempty"`
+	TokensPerSecond *Percentiles        `json:"tokens_per_second,omitempty"`
+	LatencyMS       *LatencyPercentiles `json:"latency_ms,omitempty"`
+}
+
+// ProviderMetrics holds the aggregated metrics for a specific upstream provider. Requests
+// recorded before providers were tracked are counted under "unknown".
+type ProviderMetrics struct {
+	Provider  string              `json:"provider"`
+	Requests  int64               `json:"requests"`
+	Failures  int64               `json:"failures"`
+	LatencyMS *LatencyPercentiles `json:"latency_ms,omitempty"`
+}
+
+// LatencyPercentiles summarises a distribution of request durations, estimated from a
+// streaming histogram to within one percent. It is omitted when no durations were recorded.
+type LatencyPercentiles struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+}
+
+// Percentiles summarises a distribution of streaming measurements, estimated from a
+// streaming histogram to within one percent.
+// It is omitted when no streaming requests fall in the queried period.
+type Percentiles struct {
+	P50 float64 `json:"p50"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+}
+
+// distributions merges the histograms of the buckets of one model, provider or the totals.
+type distributions struct {
+	latency         usage.Histogram
+	ttft            usage.Histogram
+	tokensPerSecond usage.Histogram
+}
+
+func (d *distributions) merge(bucket usage.Aggregate) {
+	d.latency.Merge(bucket.Latency)
+	d.ttft.Merge(bucket.TTFT)
+	d.tokensPerSecond.Merge(bucket.TokensPerSecond)
+}
+
+// KeyMetrics holds the aggregated metrics for a specific client API key.
+type KeyMetrics struct {
+	Key      string  `json:"key"` // masked
+	Tokens   int64   `json:"tokens"`
+	Requests int64   `json:"requests"`
+	Cost     float64 `json:"cost"`
 }
 
 // TimeseriesBucket holds the aggregated metrics for a specific time bucket.
 type TimeseriesBucket struct {
-	BucketStart string `json:"bucket_start"` // ISO 8601 format
-	Tokens      int64  `json:"tokens"`
-	Requests    int64  `json:"requests"`
+	BucketStart string  `json:"bucket_start"` // ISO 8601 format
+	Tokens      int64   `json:"tokens"`
+	Requests    int64   `json:"requests"`
+	Failures    int64   `json:"failures"`
+	SuccessRate float64 `json:"success_rate"`
+	Cost        float64 `json:"cost"`
+}
+
+// ErrorMetrics counts the failed requests of one proxy error code, provider and model.
+// Requests recorded before errors were classified are counted under "unknown".
+type ErrorMetrics struct {
+	Code     string `json:"code"`
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Count    int64  `json:"count"`
+}
+
+// maxTimeseriesBuckets caps the number of timeseries buckets a single query may produce.
+const maxTimeseriesBuckets = 2000
+
+// bucketGranularities lists the supported values of the bucket query parameter.
+var bucketGranularities = map[string]time.Duration{
+	"1m": time.Minute,
+	"5m": 5 * time.Minute,
+	"1h": time.Hour,
+	"1d": 24 * time.Hour,
 }
 
 // GetMetrics is the handler for the /_qs/metrics endpoint.
+// Passing format=prometheus renders the full snapshot in Prometheus text exposition format instead.
 func (h *Handler) GetMetrics(c *gin.Context) {
+	if c.Query("format") == "prometheus" {
+		h.GetPrometheusMetrics(c)
+		return
+	}
+
 	fromStr := c.Query("from")
 	toStr := c.Query("to")
 	modelFilter := c.Query("model")
+	projectFilter := c.Query("project")
+	bucketStr := c.DefaultQuery("bucket", "1h")
+
+	bucketSize, ok := BucketSize(bucketStr)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'bucket' value, expected one of 1m, 5m, 1h, 1d"})
+		return
+	}
 
+	fromTime, toTime, err := parsePeriod(fromStr, toStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := Query{From: fromTime, To: toTime, Model: modelFilter, Project: projectFilter, Bucket: bucketSize}
+	if err = h.Validate(query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp := h.Compute(query)
+	log.Debugf("metrics query from %s to %s (model %q, project %q): %d requests in %d buckets",
+		fromTime.Format(time.RFC3339), toTime.Format(time.RFC3339), modelFilter, projectFilter, resp.Totals.Requests, len(resp.Timeseries))
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// parsePeriod parses the RFC 3339 from and to query parameters, defaulting to the last 24
+// hours when neither is given.
+func parsePeriod(fromStr, toStr string) (time.Time, time.Time, error) {
 	var fromTime, toTime time.Time
 	var err error
-
-	// Default to last 24 hours if no time range is given
 	if fromStr == "" && toStr == "" {
 		toTime = time.Now()
-		fromTime = toTime.Add(-24 * time.Hour)
-	} else {
-		if fromStr != "" {
-			fromTime, err = time.Parse(time.RFC3339, fromStr)
-			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'from' timestamp format"})
-				return
-			}
+		return toTime.Add(-24 * time.Hour), toTime, nil
+	}
+	if fromStr != "" {
+		if fromTime, err = time.Parse(time.RFC3339, fromStr); err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid 'from' timestamp format")
 		}
-		if toStr != "" {
-			toTime, err = time.Parse(time.RFC3339, toStr)
-			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'to' timestamp format"})
-				return
-			}
+	}
+	if toStr != "" {
+		if toTime, err = time.Parse(time.RFC3339, toStr); err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid 'to' timestamp format")
 		}
 	}
+	return fromTime, toTime, nil
+}
+
+// Query selects the requests aggregated by Compute. Zero bounds leave the period open.
+type Query struct {
+	From  time.Time
+	To    time.Time
+	Model string
+	// Project restricts the query to the client keys currently belonging to the project.
+	Project string
+	Bucket  time.Duration
+}
 
-	snapshot := h.Stats.Snapshot()
+// BucketSize returns the timeseries bucket size for one of the names 1m, 5m, 1h and 1d.
+func BucketSize(name string) (time.Duration, bool) {
+	size, ok := bucketGranularities[name]
+	return size, ok
+}
+
+// Validate rejects inverted periods and periods that would produce too many buckets.
+func (q Query) Validate() error {
+	if !q.From.IsZero() && !q.To.IsZero() && q.To.Before(q.From) {
+		return errors.New("'to' must not be before 'from'")
+	}
+	if !q.From.IsZero() {
+		end := q.To
+		if end.IsZero() {
+			end = time.Now()
+		}
+		if end.Sub(q.From)/q.Bucket > maxTimeseriesBuckets {
+			return fmt.Errorf("requested range exceeds %d buckets, use a coarser 'bucket' or a shorter range", maxTimeseriesBuckets)
+		}
+	}
+	return nil
+}
+
+// Validate checks q like Query.Validate and additionally rejects unknown projects.
+func (h *Handler) Validate(q Query) error {
+	if err := q.Validate(); err != nil {
+		return err
+	}
+	if q.Project != "" {
+		if _, ok := h.projects.Get(q.Project); !ok {
+			return fmt.Errorf("unknown project %q", q.Project)
+		}
+	}
+	return nil
+}
+
+// Compute aggregates the recorded requests matching q by model, client key, provider and
+// time bucket. It reads the pre-aggregated usage buckets, so its cost grows with the
+// number of buckets in the period rather than with the number of requests, and the period
+// is resolved to whole buckets.
+func (h *Handler) Compute(q Query) MetricsResponse {
+	modelFilter, bucketSize := q.Model, q.Bucket
+	var projectKeys map[string]struct{}
+	if q.Project != "" {
+		projectKeys = h.projects.Keys(q.Project)
+	}
+
+	pricing := h.pricing.Load()
 
 	modelMetricsMap := make(map[string]*ModelMetrics)
+	modelDistributions := make(map[string]*distributions)
+	var totalDistributions distributions
+	keyMetricsMap := make(map[string]*KeyMetrics)
+	providerMetricsMap := make(map[string]*ProviderMetrics)
+	providerLatency := make(map[string]*usage.Histogram)
 	timeseriesMap := make(map[time.Time]*TimeseriesBucket)
-	var totalTokens int64
-	var totalRequests int64
+	errorMap := make(map[ErrorMetrics]int64)
+	var totals TotalsMetrics
 
-	for _, apiSnapshot := range snapshot.APIs {
-		for modelName, modelSnapshot := range apiSnapshot.Models {
-			if modelFilter != "" && modelFilter != modelName {
+	for _, bucket := range h.Stats.Aggregates(q.From, q.To) {
+		if projectKeys != nil {
+			if _, ok := projectKeys[bucket.API]; !ok {
 				continue
 			}
+		}
+		modelName := bucket.Model
+		if modelFilter != "" && modelFilter != modelName {
+			continue
+		}
+
+		cost := pricing.EstimateCost(modelName, bucket.Tokens)
+		totals.Requests += bucket.Requests
+		totals.Failures += bucket.Failures
+		totals.Retries += bucket.Retries
+		totals.Tokens += bucket.Tokens.TotalTokens
+		totals.EmbeddingTokens += bucket.Tokens.EmbeddingTokens
+		totals.Images += bucket.Tokens.Images
+		totals.Cost += cost
+		totalDistributions.merge(bucket)
 
-			for _, detail := range modelSnapshot.Details {
-				if !fromTime.IsZero() && detail.Timestamp.Before(fromTime) {
-					continue
-				}
-				if !toTime.IsZero() && detail.Timestamp.After(toTime) {
-					continue
-				}
-
-				totalRequests++
-				totalTokens += detail.Tokens.TotalTokens
-
-				if _, ok := modelMetricsMap[modelName]; !ok {
-					modelMetricsMap[modelName] = &ModelMetrics{Model: modelName}
-				}
-				modelMetricsMap[modelName].Requests++
-				modelMetricsMap[modelName].Tokens += detail.Tokens.TotalTokens
-
-				bucket := detail.Timestamp.Truncate(time.Hour)
-				if _, ok := timeseriesMap[bucket]; !ok {
-					timeseriesMap[bucket] = &TimeseriesBucket{BucketStart: bucket.Format(time.RFC3339)}
-				}
-				timeseriesMap[bucket].Requests++
-				timeseriesMap[bucket].Tokens += detail.Tokens.TotalTokens
+		if _, ok := modelMetricsMap[modelName]; !ok {
+			modelMetricsMap[modelName] = &ModelMetrics{Model: modelName}
+			modelDistributions[modelName] = &distributions{}
+		}
+		mm := modelMetricsMap[modelName]
+		mm.Requests += bucket.Requests
+		mm.Retries += bucket.Retries
+		mm.Tokens += bucket.Tokens.TotalTokens
+		mm.EmbeddingTokens += bucket.Tokens.EmbeddingTokens
+		mm.Images += bucket.Tokens.Images
+		mm.Cost += cost
+		modelDistributions[modelName].merge(bucket)
+
+		if _, ok := keyMetricsMap[bucket.API]; !ok {
+			keyMetricsMap[bucket.API] = &KeyMetrics{Key: displayKey(bucket.API)}
+		}
+		keyMetricsMap[bucket.API].Requests += bucket.Requests
+		keyMetricsMap[bucket.API].Tokens += bucket.Tokens.TotalTokens
+		keyMetricsMap[bucket.API].Cost += cost
+
+		for name, providerBucket := range bucket.Providers {
+			provider := orUnknown(name)
+			if _, ok := providerMetricsMap[provider]; !ok {
+				providerMetricsMap[provider] = &ProviderMetrics{Provider: provider}
+				providerLatency[provider] = &usage.Histogram{}
 			}
+			providerMetricsMap[provider].Requests += providerBucket.Requests
+			providerMetricsMap[provider].Failures += providerBucket.Failures
+			providerLatency[provider].Merge(providerBucket.Latency)
+		}
+
+		start := truncateToBucket(bucket.Start, bucketSize)
+		if _, ok := timeseriesMap[start]; !ok {
+			timeseriesMap[start] = &TimeseriesBucket{BucketStart: start.Format(time.RFC3339)}
+		}
+		timeseriesMap[start].Requests += bucket.Requests
+		timeseriesMap[start].Failures += bucket.Failures
+		timeseriesMap[start].Tokens += bucket.Tokens.TotalTokens
+		timeseriesMap[start].Cost += cost
+
+		for key, count := range bucket.Errors {
+			errorMap[ErrorMetrics{Code: orUnknown(key.Code), Provider: orUnknown(key.Provider), Model: modelName}] += count
 		}
 	}
 
+	totals.TTFTMS = percentiles(&totalDistributions.ttft)
+	totals.TokensPerSecond = percentiles(&totalDistributions.tokensPerSecond)
+	totals.LatencyMS = latencyPercentiles(&totalDistributions.latency)
 	resp := MetricsResponse{
-		Totals: TotalsMetrics{
-			Tokens:   totalTokens,
-			Requests: totalRequests,
-		},
+		Totals:     totals,
 		ByModel:    make([]ModelMetrics, 0, len(modelMetricsMap)),
+		ByKey:      make([]KeyMetrics, 0, len(keyMetricsMap)),
+		ByProvider: make([]ProviderMetrics, 0, len(providerMetricsMap)),
 		Timeseries: make([]TimeseriesBucket, 0, len(timeseriesMap)),
+		Errors:     make([]ErrorMetrics, 0, len(errorMap)),
 	}
 
-	for _, mm := range modelMetricsMap {
+	for modelName, mm := range modelMetricsMap {
+		d := modelDistributions[modelName]
+		mm.TTFTMS = percentiles(&d.ttft)
+		mm.TokensPerSecond = percentiles(&d.tokensPerSecond)
+		mm.LatencyMS = latencyPercentiles(&d.latency)
 		resp.ByModel = append(resp.ByModel, *mm)
 	}
 
@@ -136,7 +398,25 @@ func (h *Handler) GetMetrics(c *gin.Context) {
 		return resp.ByModel[i].Model < resp.ByModel[j].Model
 	})
 
+	for _, km := range keyMetricsMap {
+		resp.ByKey = append(resp.ByKey, *km)
+	}
+
+	sort.Slice(resp.ByKey, func(i, j int) bool {
+		return resp.ByKey[i].Key < resp.ByKey[j].Key
+	})
+
+	for provider, pm := range providerMetricsMap {
+		pm.LatencyMS = latencyPercentiles(providerLatency[provider])
+		resp.ByProvider = append(resp.ByProvider, *pm)
+	}
+
+	sort.Slice(resp.ByProvider, func(i, j int) bool {
+		return resp.ByProvider[i].Provider < resp.ByProvider[j].Provider
+	})
+
 	for _, tb := range timeseriesMap {
+		tb.SuccessRate = float64(tb.Requests-tb.Failures) / float64(tb.Requests)
 		resp.Timeseries = append(resp.Timeseries, *tb)
 	}
 
@@ -144,9 +424,69 @@ func (h *Handler) GetMetrics(c *gin.Context) {
 		return resp.Timeseries[i].BucketStart < resp.Timeseries[j].BucketStart
 	})
 
-	if jsonData, err := json.MarshalIndent(resp, "", "  "); err == nil {
-		fmt.Println(string(jsonData))
+	for key, count := range errorMap {
+		key.Count = count
+		resp.Errors = append(resp.Errors, key)
 	}
 
-	c.JSON(http.StatusOK, resp)
+	sort.Slice(resp.Errors, func(i, j int) bool {
+		a, b := resp.Errors[i], resp.Errors[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.Code != b.Code {
+			return a.Code < b.Code
+		}
+		if a.Provider != b.Provider {
+			return a.Provider < b.Provider
+		}
+		return a.Model < b.Model
+	})
+
+	return resp
+}
+
+func orUnknown(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+	return value
+}
+
+// truncateToBucket aligns ts to the start of its bucket. Daily buckets are aligned
+// to midnight in the timestamp's own location rather than to the Unix epoch.
+func truncateToBucket(ts time.Time, size time.Duration) time.Time {
+	if size == 24*time.Hour {
+		year, month, day := ts.Date()
+		return time.Date(year, month, day, 0, 0, 0, 0, ts.Location())
+	}
+	return ts.Truncate(size)
+}
+
+// percentiles returns the p50, p95 and p99 of a streaming measurement, or nil when none
+// was recorded.
+func percentiles(h *usage.Histogram) *Percentiles {
+	q := h.Quantiles(0.50, 0.95, 0.99)
+	if q == nil {
+		return nil
+	}
+	return &Percentiles{P50: q[0], P95: q[1], P99: q[2]}
+}
+
+// latencyPercentiles returns the p50, p90 and p99 request durations, or nil when none was
+// recorded.
+func latencyPercentiles(h *usage.Histogram) *LatencyPercentiles {
+	q := h.Quantiles(0.50, 0.90, 0.99)
+	if q == nil {
+		return nil
+	}
+	return &LatencyPercentiles{P50: q[0], P90: q[1], P99: q[2]}
+}
+
+// displayKey masks client API keys while leaving route-based fallback identifiers readable.
+func displayKey(key string) string {
+	if strings.Contains(key, " ") || strings.HasPrefix(key, "/") {
+		return key
+	}
+	return util.HideAPIKey(key)
 }
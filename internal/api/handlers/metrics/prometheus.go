@@ -0,0 +1,340 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/admission"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/moderation"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/transport"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// prometheusContentType is the content type of the Prometheus text exposition format (version 0.0.4).
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// latencyBucketsSeconds defines the upper bounds of the request latency histogram.
+var latencyBucketsSeconds = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// modelSeries accumulates the per-model values rendered into the exposition output.
+type modelSeries struct {
+	success         int64
+	failure         int64
+	inputTokens     int64
+	outputTokens    int64
+	reasoningTokens int64
+	cachedTokens    int64
+	cacheCreation   int64
+	totalTokens     int64
+	embeddingTokens int64
+	images          int64
+	retries         int64
+	cost            float64
+	latencyBuckets  []int64
+	latencyCount    int64
+	latencySum      float64
+}
+
+// GetPrometheusMetrics is the handler for the /metrics endpoint.
+// It renders the in-memory usage statistics in Prometheus text exposition format.
+func (h *Handler) GetPrometheusMetrics(c *gin.Context) {
+	snapshot := h.Stats.Snapshot()
+	var runtime runtimeSeries
+	if h.authManager != nil {
+		runtime.circuits = h.authManager.CircuitSnapshot()
+		if stats, enabled := h.authManager.ResponseCacheStats(); enabled {
+			runtime.responseCache = &stats
+		}
+		if stats, enabled := h.authManager.RequestDedupStats(); enabled {
+			runtime.requestDedup = &stats
+		}
+	}
+	if h.admission.Enabled() {
+		stats := h.admission.Stats()
+		runtime.admission = &stats
+	}
+	if h.moderator.Enabled() {
+		runtime.moderation = h.moderator.Stats()
+	}
+	if h.clientCancellations != nil {
+		count := h.clientCancellations()
+		runtime.clientCancellations = &count
+	}
+	if h.errorCounts != nil {
+		runtime.errors = h.errorCounts()
+	}
+	runtime.upstreamPools = transport.Stats()
+	c.Data(http.StatusOK, prometheusContentType, renderPrometheus(snapshot, h.Stats.Aggregates(time.Time{}, time.Time{}), h.pricing.Load(), runtime))
+}
+
+// runtimeSeries holds the auth manager state rendered next to the usage statistics.
+type runtimeSeries struct {
+	circuits      []coreauth.CircuitStatus
+	responseCache *coreauth.ResponseCacheStats
+	requestDedup  *coreauth.RequestDedupStats
+	admission     *admission.Stats
+	moderation    []moderation.DecisionCount
+	// clientCancellations is nil when the counter is not wired.
+	clientCancellations *int64
+	// errors counts the errors returned to clients per proxy error code.
+	errors map[string]int64
+	// upstreamPools is the connection pool state per upstream host or proxy.
+	upstreamPools []transport.HostStats
+}
+
+func renderPrometheus(snapshot usage.StatisticsSnapshot, buckets []usage.Aggregate, pricing *usage.PriceTable, runtime runtimeSeries) []byte {
+	series := make(map[string]*modelSeries)
+	// The per-model series come from the aggregate buckets, which outlive the raw details.
+	for _, bucket := range buckets {
+		modelName := bucket.Model
+		s, ok := series[modelName]
+		if !ok {
+			s = &modelSeries{latencyBuckets: make([]int64, len(latencyBucketsSeconds))}
+			series[modelName] = s
+		}
+		s.failure += bucket.Failures
+		s.success += bucket.Requests - bucket.Failures
+		s.inputTokens += bucket.Tokens.InputTokens
+		s.outputTokens += bucket.Tokens.OutputTokens
+		s.reasoningTokens += bucket.Tokens.ReasoningTokens
+		s.cachedTokens += bucket.Tokens.CachedTokens
+		s.cacheCreation += bucket.Tokens.CacheCreationTokens
+		s.totalTokens += bucket.Tokens.TotalTokens
+		s.embeddingTokens += bucket.Tokens.EmbeddingTokens
+		s.images += bucket.Tokens.Images
+		s.retries += bucket.Retries
+		s.cost += pricing.EstimateCost(modelName, bucket.Tokens)
+
+		// Requests without a measured duration fall into every bucket.
+		unmeasured := bucket.Requests - bucket.Latency.Count()
+		for i, bound := range latencyBucketsSeconds {
+			s.latencyBuckets[i] += unmeasured + bucket.Latency.CountAtMost(bound*1000)
+		}
+		s.latencyCount += bucket.Requests
+		s.latencySum += bucket.Latency.Sum() / 1000
+	}
+
+	models := make([]string, 0, len(series))
+	for modelName := range series {
+		models = append(models, modelName)
+	}
+	sort.Strings(models)
+
+	var buf bytes.Buffer
+
+	writeHeader(&buf, "cliproxy_requests_total", "counter", "Total number of proxied requests.")
+	writeSample(&buf, "cliproxy_requests_total", nil, strconv.FormatInt(snapshot.TotalRequests, 10))
+
+	writeHeader(&buf, "cliproxy_requests_failed_total", "counter", "Total number of failed proxied requests.")
+	writeSample(&buf, "cliproxy_requests_failed_total", nil, strconv.FormatInt(snapshot.FailureCount, 10))
+
+	writeHeader(&buf, "cliproxy_tokens_total", "counter", "Total number of tokens consumed across all requests.")
+	writeSample(&buf, "cliproxy_tokens_total", nil, strconv.FormatInt(snapshot.TotalTokens, 10))
+
+	writeHeader(&buf, "cliproxy_model_requests_total", "counter", "Number of requests per model and outcome.")
+	for _, modelName := range models {
+		s := series[modelName]
+		writeSample(&buf, "cliproxy_model_requests_total", [][2]string{{"model", modelName}, {"status", "success"}}, strconv.FormatInt(s.success, 10))
+		writeSample(&buf, "cliproxy_model_requests_total", [][2]string{{"model", modelName}, {"status", "failure"}}, strconv.FormatInt(s.failure, 10))
+	}
+
+	writeHeader(&buf, "cliproxy_model_tokens_total", "counter", "Number of tokens per model and token type.")
+	for _, modelName := range models {
+		s := series[modelName]
+		for _, entry := range []struct {
+			kind  string
+			value int64
+		}{
+			{"input", s.inputTokens},
+			{"output", s.outputTokens},
+			{"reasoning", s.reasoningTokens},
+			{"cached", s.cachedTokens},
+			{"cache_creation", s.cacheCreation},
+			{"embedding", s.embeddingTokens},
+			{"total", s.totalTokens},
+		} {
+			writeSample(&buf, "cliproxy_model_tokens_total", [][2]string{{"model", modelName}, {"type", entry.kind}}, strconv.FormatInt(entry.value, 10))
+		}
+	}
+
+	writeHeader(&buf, "cliproxy_model_images_total", "counter", "Number of generated images per model.")
+	for _, modelName := range models {
+		writeSample(&buf, "cliproxy_model_images_total", [][2]string{{"model", modelName}}, strconv.FormatInt(series[modelName].images, 10))
+	}
+
+	writeHeader(&buf, "cliproxy_model_retries_total", "counter", "Number of upstream requests per model made by a retry attempt.")
+	for _, modelName := range models {
+		writeSample(&buf, "cliproxy_model_retries_total", [][2]string{{"model", modelName}}, strconv.FormatInt(series[modelName].retries, 10))
+	}
+
+	writeHeader(&buf, "cliproxy_model_cost_total", "counter", "Estimated spend per model based on the configured pricing table.")
+	for _, modelName := range models {
+		writeSample(&buf, "cliproxy_model_cost_total", [][2]string{{"model", modelName}}, formatFloat(series[modelName].cost))
+	}
+
+	writeHeader(&buf, "cliproxy_request_duration_seconds", "histogram", "Request latency per model in seconds.")
+	for _, modelName := range models {
+		s := series[modelName]
+		for i, bound := range latencyBucketsSeconds {
+			writeSample(&buf, "cliproxy_request_duration_seconds_bucket", [][2]string{{"model", modelName}, {"le", formatFloat(bound)}}, strconv.FormatInt(s.latencyBuckets[i], 10))
+		}
+		writeSample(&buf, "cliproxy_request_duration_seconds_bucket", [][2]string{{"model", modelName}, {"le", "+Inf"}}, strconv.FormatInt(s.latencyCount, 10))
+		writeSample(&buf, "cliproxy_request_duration_seconds_sum", [][2]string{{"model", modelName}}, formatFloat(s.latencySum))
+		writeSample(&buf, "cliproxy_request_duration_seconds_count", [][2]string{{"model", modelName}}, strconv.FormatInt(s.latencyCount, 10))
+	}
+
+	if circuits := runtime.circuits; len(circuits) > 0 {
+		states := []coreauth.CircuitState{coreauth.CircuitClosed, coreauth.CircuitHalfOpen, coreauth.CircuitOpen}
+		writeHeader(&buf, "cliproxy_circuit_state", "gauge", "Circuit breaker state per account; 1 for the current state.")
+		for _, circuit := range circuits {
+			for _, state := range states {
+				value := "0"
+				if circuit.State == state {
+					value = "1"
+				}
+				writeSample(&buf, "cliproxy_circuit_state", [][2]string{{"provider", circuit.Provider}, {"account", circuitAccountLabel(circuit)}, {"state", string(state)}}, value)
+			}
+		}
+		writeHeader(&buf, "cliproxy_circuit_transitions_total", "counter", "Circuit breaker state changes per account and target state.")
+		for _, circuit := range circuits {
+			for _, state := range states {
+				writeSample(&buf, "cliproxy_circuit_transitions_total", [][2]string{{"provider", circuit.Provider}, {"account", circuitAccountLabel(circuit)}, {"to", string(state)}}, strconv.FormatInt(circuit.Transitions[state], 10))
+			}
+		}
+	}
+
+	if stats := runtime.responseCache; stats != nil {
+		writeHeader(&buf, "cliproxy_response_cache_lookups_total", "counter", "Response cache lookups by result.")
+		writeSample(&buf, "cliproxy_response_cache_lookups_total", [][2]string{{"result", "hit"}}, strconv.FormatInt(stats.Hits, 10))
+		writeSample(&buf, "cliproxy_response_cache_lookups_total", [][2]string{{"result", "miss"}}, strconv.FormatInt(stats.Misses, 10))
+	}
+
+	if stats := runtime.requestDedup; stats != nil {
+		writeHeader(&buf, "cliproxy_request_dedup_joined_total", "counter", "Requests served by an identical request that was already running.")
+		writeSample(&buf, "cliproxy_request_dedup_joined_total", nil, strconv.FormatInt(stats.Joined, 10))
+	}
+
+	if stats := runtime.admission; stats != nil {
+		writeHeader(&buf, "cliproxy_admission_in_flight", "gauge", "Requests currently holding an admission slot.")
+		writeSample(&buf, "cliproxy_admission_in_flight", nil, strconv.Itoa(stats.InFlight))
+		writeHeader(&buf, "cliproxy_admission_queue_length", "gauge", "Requests waiting in the admission queue.")
+		writeSample(&buf, "cliproxy_admission_queue_length", nil, strconv.Itoa(queuedTotal(stats.Queued)))
+		writeHeader(&buf, "cliproxy_admission_queue_priority_length", "gauge", "Requests waiting in the admission queue per priority.")
+		for _, queued := range stats.Queued {
+			writeSample(&buf, "cliproxy_admission_queue_priority_length", [][2]string{{"priority", strconv.Itoa(queued.Priority)}}, strconv.Itoa(queued.Waiting))
+		}
+		writeHeader(&buf, "cliproxy_admission_requests_total", "counter", "Requests that went through admission control by outcome.")
+		writeSample(&buf, "cliproxy_admission_requests_total", [][2]string{{"result", "admitted"}}, strconv.FormatInt(stats.Admitted, 10))
+		writeSample(&buf, "cliproxy_admission_requests_total", [][2]string{{"result", "rejected"}}, strconv.FormatInt(stats.Rejected, 10))
+		writeSample(&buf, "cliproxy_admission_requests_total", [][2]string{{"result", "timed_out"}}, strconv.FormatInt(stats.TimedOut, 10))
+		writeHeader(&buf, "cliproxy_admission_wait_seconds_total", "counter", "Total time admitted requests waited in the admission queue.")
+		writeSample(&buf, "cliproxy_admission_wait_seconds_total", nil, formatFloat(stats.WaitSeconds))
+	}
+
+	if len(runtime.moderation) > 0 {
+		writeHeader(&buf, "cliproxy_moderation_decisions_total", "counter", "Content moderation decisions per filter and action.")
+		for _, decision := range runtime.moderation {
+			writeSample(&buf, "cliproxy_moderation_decisions_total", [][2]string{{"filter", decision.Filter}, {"action", string(decision.Action)}}, strconv.FormatInt(decision.Count, 10))
+		}
+	}
+
+	if count := runtime.clientCancellations; count != nil {
+		writeHeader(&buf, "cliproxy_client_cancelled_requests_total", "counter", "Requests aborted upstream because the client disconnected.")
+		writeSample(&buf, "cliproxy_client_cancelled_requests_total", nil, strconv.FormatInt(*count, 10))
+	}
+
+	if len(runtime.errors) > 0 {
+		codes := make([]string, 0, len(runtime.errors))
+		for code := range runtime.errors {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		writeHeader(&buf, "cliproxy_errors_total", "counter", "Errors returned to clients per proxy error code.")
+		for _, code := range codes {
+			writeSample(&buf, "cliproxy_errors_total", [][2]string{{"code", code}}, strconv.FormatInt(runtime.errors[code], 10))
+		}
+	}
+
+	if len(runtime.upstreamPools) > 0 {
+		writeHeader(&buf, "cliproxy_upstream_connections_open", "gauge", "Open upstream connections per host; behind a proxy, per proxy.")
+		for _, pool := range runtime.upstreamPools {
+			writeSample(&buf, "cliproxy_upstream_connections_open", [][2]string{{"host", pool.Host}}, strconv.FormatInt(pool.Open, 10))
+		}
+		writeHeader(&buf, "cliproxy_upstream_connections_dialed_total", "counter", "Upstream connections opened per host; behind a proxy, per proxy.")
+		for _, pool := range runtime.upstreamPools {
+			writeSample(&buf, "cliproxy_upstream_connections_dialed_total", [][2]string{{"host", pool.Host}}, strconv.FormatInt(pool.Dialed, 10))
+		}
+		writeHeader(&buf, "cliproxy_upstream_dial_errors_total", "counter", "Failed upstream connection attempts per host; behind a proxy, per proxy.")
+		for _, pool := range runtime.upstreamPools {
+			writeSample(&buf, "cliproxy_upstream_dial_errors_total", [][2]string{{"host", pool.Host}}, strconv.FormatInt(pool.DialErrors, 10))
+		}
+		writeHeader(&buf, "cliproxy_upstream_requests_by_connection_total", "counter", "Upstream requests per host by whether they reused a pooled connection.")
+		for _, pool := range runtime.upstreamPools {
+			if pool.Reused == 0 && pool.New == 0 {
+				continue
+			}
+			writeSample(&buf, "cliproxy_upstream_requests_by_connection_total", [][2]string{{"host", pool.Host}, {"connection", "reused"}}, strconv.FormatInt(pool.Reused, 10))
+			writeSample(&buf, "cliproxy_upstream_requests_by_connection_total", [][2]string{{"host", pool.Host}, {"connection", "new"}}, strconv.FormatInt(pool.New, 10))
+		}
+	}
+
+	return buf.Bytes()
+}
+
+func queuedTotal(queued []admission.PriorityQueued) int {
+	total := 0
+	for _, q := range queued {
+		total += q.Waiting
+	}
+	return total
+}
+
+// circuitAccountLabel identifies an account in metric labels without exposing API keys.
+func circuitAccountLabel(circuit coreauth.CircuitStatus) string {
+	if circuit.Label != "" {
+		return util.HideAPIKey(circuit.Label)
+	}
+	return util.HideAPIKey(circuit.ID)
+}
+
+func writeHeader(buf *bytes.Buffer, name, kind, help string) {
+	_, _ = fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func writeSample(buf *bytes.Buffer, name string, labels [][2]string, value string) {
+	buf.WriteString(name)
+	if len(labels) > 0 {
+		buf.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(label[0])
+			buf.WriteString(`="`)
+			buf.WriteString(escapeLabelValue(label[1]))
+			buf.WriteByte('"')
+		}
+		buf.WriteByte('}')
+	}
+	buf.WriteByte(' ')
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
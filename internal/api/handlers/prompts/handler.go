@@ -0,0 +1,121 @@
+// Package prompts provides the HTTP handlers of the prompt template library. Templates are
+// shared by every client key; only the configured editors may change them.
+package prompts
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers/files"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/prompts"
+)
+
+// Handler serves the /v1/prompts endpoints.
+type Handler struct {
+	library *prompts.Library
+}
+
+// NewHandler creates the handler for library.
+func NewHandler(library *prompts.Library) *Handler {
+	return &Handler{library: library}
+}
+
+// Middleware rejects requests while the prompt library is disabled.
+func (h *Handler) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !h.library.Enabled() {
+			files.WriteError(c, http.StatusNotFound, "the prompt library is disabled", "not_found")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// ListPrompts handles GET /v1/prompts.
+func (h *Handler) ListPrompts(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"object": "list", "data": h.library.List()})
+}
+
+// GetPrompt handles GET /v1/prompts/:name.
+func (h *Handler) GetPrompt(c *gin.Context) {
+	template, err := h.library.Get(c.Param("name"))
+	if err != nil {
+		writeLibraryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, template)
+}
+
+// PutPrompt handles PUT /v1/prompts/:name, creating or replacing the template.
+func (h *Handler) PutPrompt(c *gin.Context) {
+	if !h.library.CanEdit(files.Principal(c).Principal) {
+		files.WriteError(c, http.StatusForbidden, "this API key may not change prompt templates", "permission_denied")
+		return
+	}
+	var req prompts.PutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		files.WriteError(c, http.StatusBadRequest, "invalid request body", "invalid_request")
+		return
+	}
+	template, created, err := h.library.Put(c.Param("name"), req)
+	if err != nil {
+		writeLibraryError(c, err)
+		return
+	}
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, template)
+}
+
+// DeletePrompt handles DELETE /v1/prompts/:name.
+func (h *Handler) DeletePrompt(c *gin.Context) {
+	if !h.library.CanEdit(files.Principal(c).Principal) {
+		files.WriteError(c, http.StatusForbidden, "this API key may not change prompt templates", "permission_denied")
+		return
+	}
+	name := c.Param("name")
+	if err := h.library.Delete(name); err != nil {
+		writeLibraryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"name": name, "deleted": true})
+}
+
+// RenderPrompt handles POST /v1/prompts/:name/render, returning the messages of the
+// template rendered with the variables of the request body.
+func (h *Handler) RenderPrompt(c *gin.Context) {
+	var req struct {
+		Variables map[string]string `json:"variables"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		files.WriteError(c, http.StatusBadRequest, "invalid request body", "invalid_request")
+		return
+	}
+	template, err := h.library.Get(c.Param("name"))
+	if err != nil {
+		writeLibraryError(c, err)
+		return
+	}
+	messages, err := template.Render(req.Variables)
+	if err != nil {
+		writeLibraryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"name": template.Name, "version": template.Version, "messages": messages})
+}
+
+func writeLibraryError(c *gin.Context, err error) {
+	var validation *prompts.ValidationError
+	switch {
+	case errors.As(err, &validation):
+		files.WriteError(c, http.StatusBadRequest, validation.Message, "invalid_request")
+	case errors.Is(err, prompts.ErrNotFound):
+		files.WriteError(c, http.StatusNotFound, "no such prompt template", "not_found")
+	default:
+		files.WriteError(c, http.StatusInternalServerError, err.Error(), "internal_error")
+	}
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// readinessTimeout bounds a /readyz request, so an unreachable backend fails the probe
+// instead of hanging it.
+const readinessTimeout = 3 * time.Second
+
+// ReadinessCheck reports whether a dependency of the proxy can serve requests.
+type ReadinessCheck func(ctx context.Context) error
+
+type namedReadinessCheck struct {
+	name  string
+	check ReadinessCheck
+}
+
+// WithReadinessCheck adds a dependency check to the /readyz endpoint, e.g. for a storage
+// backend created outside the server.
+func WithReadinessCheck(name string, check ReadinessCheck) ServerOption {
+	return func(cfg *serverOptionConfig) {
+		if name == "" || check == nil {
+			return
+		}
+		cfg.readinessChecks = append(cfg.readinessChecks, namedReadinessCheck{name: name, check: check})
+	}
+}
+
+// pinger is implemented by storage backends that can test their connection.
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// CheckResult is the outcome of one readiness check.
+type CheckResult struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// handleLiveness answers /healthz: the process is up and serving HTTP.
+func (s *Server) handleLiveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// handleReadiness answers /readyz with 200 when every dependency check passes and 503
+// otherwise, listing the result of each check.
+func (s *Server) handleReadiness(c *gin.Context) {
+	checks := append([]namedReadinessCheck{
+		{name: "config", check: s.checkConfig},
+		{name: "accounts", check: s.checkAccounts},
+	}, s.readinessChecks...)
+	if store, ok := sdkAuth.GetTokenStore().(pinger); ok {
+		checks = append(checks, namedReadinessCheck{name: "token-store", check: store.Ping})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+	results := make(map[string]CheckResult, len(checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range checks {
+		wg.Add(1)
+		go func(check namedReadinessCheck) {
+			defer wg.Done()
+			result := CheckResult{OK: true}
+			if err := check.check(ctx); err != nil {
+				result = CheckResult{Error: err.Error()}
+			}
+			mu.Lock()
+			results[check.name] = result
+			mu.Unlock()
+		}(check)
+	}
+	wg.Wait()
+
+	status, code := "ready", http.StatusOK
+	for _, result := range results {
+		if !result.OK {
+			status, code = "not ready", http.StatusServiceUnavailable
+			break
+		}
+	}
+	c.JSON(code, gin.H{"status": status, "checks": results})
+}
+
+// checkConfig fails until a configuration has been loaded.
+func (s *Server) checkConfig(context.Context) error {
+	if s.cfg == nil {
+		return fmt.Errorf("configuration not loaded")
+	}
+	return nil
+}
+
+// checkAccounts fails unless every provider with enabled accounts has at least one that
+// can take requests now: not cooling down after errors or an exhausted quota, and not cut
+// off by an open circuit.
+func (s *Server) checkAccounts(context.Context) error {
+	if s.handlers == nil || s.handlers.AuthManager == nil {
+		return fmt.Errorf("auth manager not initialised")
+	}
+	manager := s.handlers.AuthManager
+	openCircuits := make(map[string]bool)
+	for _, circuit := range manager.CircuitSnapshot() {
+		if circuit.State == auth.CircuitOpen {
+			openCircuits[circuit.ID] = true
+		}
+	}
+	now := time.Now()
+	usable := make(map[string]bool)
+	for _, a := range manager.List() {
+		// Disabled accounts, including those removed from the configuration, do not make
+		// their provider a dependency.
+		if a.Disabled || a.Status == auth.StatusDisabled {
+			continue
+		}
+		provider := strings.ToLower(a.Provider)
+		if _, seen := usable[provider]; !seen {
+			usable[provider] = false
+		}
+		switch {
+		case a.Unavailable && a.NextRetryAfter.After(now):
+		case a.Quota.Exceeded && a.Quota.NextRecoverAt.After(now):
+		case openCircuits[a.ID]:
+		default:
+			usable[provider] = true
+		}
+	}
+	if len(usable) == 0 {
+		return fmt.Errorf("no enabled upstream accounts")
+	}
+	var missing []string
+	for provider, ok := range usable {
+		if !ok {
+			missing = append(missing, provider)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("no usable account for provider(s) %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
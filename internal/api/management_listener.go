@@ -0,0 +1,89 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/middleware"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/upgrade"
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultManagementHost keeps a separate management listener on the loopback interface
+// unless another host is configured.
+const defaultManagementHost = "127.0.0.1"
+
+// setupManagementListener creates the engine and HTTP server of the separate management
+// listener when management-listener.port is set. Its middleware stack only logs and
+// traces requests: API key authentication, request logging, body capture and the other
+// data-plane middleware do not apply to it.
+func (s *Server) setupManagementListener(cfg config.ManagementListenerConfig) {
+	if cfg.Port <= 0 {
+		return
+	}
+	host := strings.TrimSpace(cfg.Host)
+	if host == "" {
+		host = defaultManagementHost
+	}
+	engine := gin.New()
+	engine.Use(logging.GinLogrusLogger())
+	engine.Use(logging.GinLogrusRecovery())
+	engine.Use(middleware.TracingMiddleware())
+	engine.Use(middleware.AccessLogMiddleware(s.accessLogger))
+	engine.Use(corsMiddleware())
+	engine.GET("/healthz", s.handleLiveness)
+	engine.GET("/management.html", s.serveManagementControlPanel)
+
+	s.managementRouter = engine
+	s.managementServer = &http.Server{
+		Addr:    fmt.Sprintf("%s:%d", host, cfg.Port),
+		Handler: engine,
+	}
+}
+
+// managementEngine returns the engine that serves the management routes: the one of the
+// separate management listener when it is configured, otherwise the API engine.
+func (s *Server) managementEngine() *gin.Engine {
+	if s.managementRouter != nil {
+		return s.managementRouter
+	}
+	return s.engine
+}
+
+// startManagementListener opens the separate management listener and serves it in the
+// background.
+func (s *Server) startManagementListener() error {
+	if s.managementServer == nil {
+		return nil
+	}
+	tlsCfg := s.cfg.ManagementListener.TLS
+	if tlsCfg.Enable {
+		tlsConfig, err := buildTLSConfig(tlsCfg)
+		if err != nil {
+			return fmt.Errorf("failed to start management listener: %v", err)
+		}
+		s.managementServer.TLSConfig = tlsConfig
+	}
+	ln, err := upgrade.Listen(s.managementServer.Addr, s.cfg.ReusePort)
+	if err != nil {
+		return fmt.Errorf("failed to start management listener: %v", err)
+	}
+	log.Infof("management API listening on %s", s.managementServer.Addr)
+	go func() {
+		var errServe error
+		if tlsCfg.Enable {
+			errServe = s.managementServer.ServeTLS(ln, "", "")
+		} else {
+			errServe = s.managementServer.Serve(ln)
+		}
+		if errServe != nil && !errors.Is(errServe, http.ErrServerClosed) {
+			log.Errorf("management listener stopped: %v", errServe)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,66 @@
+// Package middleware provides HTTP middleware components for the CLI Proxy API server.
+// This file contains the access log middleware that emits one structured record per
+// proxied request once the response has been written.
+package middleware
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
+)
+
+// AccessLogMiddleware creates a Gin middleware that writes a structured access record
+// for every API request. Model, upstream account and token usage are taken from the
+// usage record the executor stores in the Gin context; the client key is masked.
+func AccessLogMiddleware(logger *logging.AccessLogger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !strings.HasPrefix(c.Request.URL.Path, "/v1") || !logger.IsEnabled() {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		c.Next()
+
+		record := logging.AccessRecord{
+			Time:      start,
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.Path,
+			Status:    c.Writer.Status(),
+			LatencyMS: time.Since(start).Milliseconds(),
+			ClientIP:  c.ClientIP(),
+		}
+		if requestID, ok := c.Get("request_id"); ok {
+			record.RequestID = fmt.Sprint(requestID)
+		}
+		if apiKey, ok := c.Get("apiKey"); ok {
+			if key := fmt.Sprint(apiKey); key != "" {
+				record.ClientKey = util.HideAPIKey(key)
+			}
+		}
+		if value, ok := c.Get("API_USAGE"); ok {
+			if rec, isRecord := value.(usage.Record); isRecord {
+				record.Model = rec.Model
+				record.Provider = rec.Provider
+				record.Account = rec.Source
+				record.AuthID = rec.AuthID
+				record.InputTokens = rec.Detail.InputTokens
+				record.OutputTokens = rec.Detail.OutputTokens
+				record.ReasoningTokens = rec.Detail.ReasoningTokens
+				record.CachedTokens = rec.Detail.CachedTokens
+				record.TotalTokens = rec.Detail.TotalTokens
+				record.UpstreamFailed = rec.Failed
+			}
+		}
+
+		if err := logger.Log(record); err != nil {
+			log.Warnf("failed to write access log record: %v", err)
+		}
+	}
+}
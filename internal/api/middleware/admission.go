@@ -0,0 +1,45 @@
+// Package middleware provides HTTP middleware components for the CLI Proxy API server.
+// This file contains the admission middleware that queues requests by API key priority
+// while the concurrency limit is reached.
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/admission"
+)
+
+// AdmissionMiddleware creates a Gin middleware that holds a slot of the admission queue
+// for the whole request, including streamed responses. It must run after authentication
+// so the client key, and with it the request priority, is known. Read-only GET requests
+// are not queued. Requests that find the queue full or wait past the timeout receive 503
+// with Retry-After.
+func AdmissionMiddleware(queue *admission.Queue) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodGet || !queue.Enabled() {
+			c.Next()
+			return
+		}
+		key := ""
+		if value, exists := c.Get("apiKey"); exists {
+			key = fmt.Sprint(value)
+		}
+
+		release, err := queue.Acquire(c.Request.Context(), key)
+		if err != nil {
+			if !errors.Is(err, admission.ErrQueueFull) && !errors.Is(err, admission.ErrTimeout) {
+				// The client went away while waiting; there is nobody to answer.
+				c.Abort()
+				return
+			}
+			c.Header("Retry-After", "1")
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": err.Error() + ", retry later"})
+			return
+		}
+		defer release()
+		c.Next()
+	}
+}
@@ -0,0 +1,103 @@
+// Package middleware provides HTTP middleware components for the CLI Proxy API server.
+// This file contains the body capture middleware that records full request and response
+// bodies for debugging when body capture is enabled.
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/capture"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+// BodyCaptureMiddleware creates a Gin middleware that hands every API exchange to the
+// recorder: the client request, the response as sent to the client with streamed chunks
+// reassembled in order, and the upstream requests and responses recorded by the executors.
+func BodyCaptureMiddleware(recorder *capture.Recorder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !strings.HasPrefix(c.Request.URL.Path, "/v1") || !recorder.Enabled() {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		limit := recorder.MaxBodyBytes()
+		var requestBody []byte
+		if isMultipartRequest(c.Request) {
+			requestBody = multipartPlaceholder(c.Request)
+		} else if c.Request.Body != nil {
+			body, err := io.ReadAll(c.Request.Body)
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			if err == nil {
+				requestBody = body
+			}
+		}
+		writer := &captureWriter{ResponseWriter: c.Writer, limit: limit}
+		c.Writer = writer
+
+		c.Next()
+
+		url := c.Request.URL.Path
+		if query := util.MaskSensitiveQuery(c.Request.URL.RawQuery); query != "" {
+			url += "?" + query
+		}
+		raw := capture.Raw{
+			Start:           start,
+			Method:          c.Request.Method,
+			URL:             url,
+			Status:          writer.Status(),
+			Stream:          strings.Contains(writer.Header().Get("Content-Type"), "text/event-stream"),
+			Chunks:          writer.chunks,
+			RequestHeaders:  c.Request.Header.Clone(),
+			RequestBody:     requestBody,
+			ResponseHeaders: writer.Header().Clone(),
+			ResponseBody:    writer.body.Bytes(),
+			Truncated:       writer.truncated,
+		}
+		if value, ok := c.Get("API_REQUEST"); ok {
+			raw.UpstreamRequest, _ = value.([]byte)
+		}
+		if value, ok := c.Get("API_RESPONSE"); ok {
+			raw.UpstreamResponse, _ = value.([]byte)
+		}
+		recorder.Record(raw)
+	}
+}
+
+// captureWriter copies what is written to the client, up to limit bytes.
+type captureWriter struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	limit     int
+	chunks    int
+	truncated bool
+}
+
+func (w *captureWriter) Write(data []byte) (int, error) {
+	w.record(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *captureWriter) WriteString(data string) (int, error) {
+	w.record([]byte(data))
+	return w.ResponseWriter.WriteString(data)
+}
+
+func (w *captureWriter) record(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	w.chunks++
+	if room := w.limit - w.body.Len(); room < len(data) {
+		w.truncated = true
+		if room > 0 {
+			w.body.Write(data[:room])
+		}
+		return
+	}
+	w.body.Write(data)
+}
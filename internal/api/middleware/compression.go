@@ -0,0 +1,168 @@
+// Package middleware provides HTTP middleware components for the CLI Proxy API server.
+// This file contains the response compression middleware.
+package middleware
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/compression"
+	log "github.com/sirupsen/logrus"
+)
+
+// CompressionMiddleware creates a Gin middleware that compresses JSON responses with the
+// encoding negotiated from the Accept-Encoding header. Responses smaller than the minimum
+// size, streamed responses (event streams, or any response flushed before it is complete)
+// and responses that already carry a Content-Encoding, such as upstream bodies forwarded
+// as they are, are sent unchanged. It must run before the middleware that logs or captures
+// response bodies, so that they see the uncompressed body.
+func CompressionMiddleware(compressor *compression.Compressor) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodHead || c.GetHeader("Upgrade") != "" {
+			c.Next()
+			return
+		}
+		encoding, minSize := compressor.Negotiate(c.Request.URL.Path, c.GetHeader("Accept-Encoding"))
+		if encoding == "" {
+			c.Next()
+			return
+		}
+		writer := &compressWriter{ResponseWriter: c.Writer, compressor: compressor, encoding: encoding, minSize: minSize}
+		c.Writer = writer
+		c.Next()
+		if err := writer.finish(); err != nil {
+			log.Debugf("compression: finish %s response: %v", encoding, err)
+		}
+	}
+}
+
+// compressWriter holds back the start of a response until it knows whether to compress
+// it: when the buffered body reaches the minimum size it starts compressing, while a flush
+// or the end of the response sends it as it is.
+type compressWriter struct {
+	gin.ResponseWriter
+	compressor *compression.Compressor
+	encoding   string
+	minSize    int
+
+	buf         []byte
+	decided     bool
+	passthrough bool
+	encoder     io.WriteCloser
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if !w.decided && !w.compressible() {
+		w.decide(false)
+	}
+	if w.decided {
+		if w.passthrough {
+			return w.ResponseWriter.Write(data)
+		}
+		return w.encoder.Write(data)
+	}
+	w.buf = append(w.buf, data...)
+	if len(w.buf) >= w.minSize {
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *compressWriter) WriteString(data string) (int, error) {
+	return w.Write([]byte(data))
+}
+
+// Flush sends a response that is still held back uncompressed and flushes the encoder of a
+// compressed one.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if err := w.start(false); err != nil {
+			log.Debugf("compression: flush: %v", err)
+		}
+	}
+	if w.encoder != nil {
+		if flusher, ok := w.encoder.(interface{ Flush() error }); ok {
+			if err := flusher.Flush(); err != nil {
+				log.Debugf("compression: flush %s encoder: %v", w.encoding, err)
+			}
+		}
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.decide(false)
+	return w.ResponseWriter.Hijack()
+}
+
+// compressible reports whether the response headers allow compressing the body.
+func (w *compressWriter) compressible() bool {
+	header := w.ResponseWriter.Header()
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	switch status := w.ResponseWriter.Status(); {
+	case status < http.StatusOK, status == http.StatusNoContent, status == http.StatusNotModified:
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func (w *compressWriter) decide(compress bool) {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	w.passthrough = !compress
+	if compress {
+		header := w.ResponseWriter.Header()
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", w.encoding)
+		header.Add("Vary", "Accept-Encoding")
+		w.encoder = w.compressor.NewEncoder(w.ResponseWriter, w.encoding)
+	}
+}
+
+// start decides how to send the response and writes the buffered body.
+func (w *compressWriter) start(compress bool) error {
+	w.decide(compress)
+	buffered := w.buf
+	w.buf = nil
+	if len(buffered) == 0 {
+		return nil
+	}
+	var err error
+	if w.passthrough {
+		_, err = w.ResponseWriter.Write(buffered)
+	} else {
+		_, err = w.encoder.Write(buffered)
+	}
+	return err
+}
+
+// finish sends what is still buffered and completes the compressed stream.
+func (w *compressWriter) finish() error {
+	if !w.decided {
+		if len(w.buf) == 0 {
+			return nil
+		}
+		if err := w.start(false); err != nil {
+			return err
+		}
+	}
+	if w.encoder != nil {
+		return w.encoder.Close()
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+// Package middleware provides HTTP middleware components for the CLI Proxy API server.
+// This file contains the file reference middleware that inlines stored files referenced
+// by ID in OpenAI requests.
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/filestore"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// FileReferenceMiddleware creates a Gin middleware that replaces file_id references to
+// stored files in chat completions "file" parts and responses "input_file" parts with the
+// file content as a data URL, so every provider translation sees inline file data. It must
+// run after authentication since files are resolved for the client key that uploaded them.
+// Requests referencing unknown files are rejected with 400.
+func FileReferenceMiddleware(store *filestore.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodPost || !store.Enabled() || c.Request.Body == nil || !strings.Contains(c.ContentType(), "json") {
+			c.Next()
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		if err != nil || !bytes.Contains(body, []byte(`"file_id"`)) {
+			c.Next()
+			return
+		}
+		owner := ""
+		if value, exists := c.Get("apiKey"); exists {
+			owner = fmt.Sprint(value)
+		}
+
+		resolved, err := resolveFileReferences(c.Request.Context(), store, owner, body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(resolved))
+		c.Request.ContentLength = int64(len(resolved))
+		c.Next()
+	}
+}
+
+// resolveFileReferences inlines the files referenced in messages[].content[].file and
+// input[].content[] parts.
+func resolveFileReferences(ctx context.Context, store *filestore.Store, owner string, body []byte) ([]byte, error) {
+	type inlined struct {
+		dataURL  string
+		filename string
+	}
+	cache := make(map[string]inlined)
+	resolve := func(id string) (inlined, error) {
+		if entry, ok := cache[id]; ok {
+			return entry, nil
+		}
+		dataURL, file, err := store.DataURL(ctx, owner, id)
+		if err != nil {
+			return inlined{}, fmt.Errorf("file %s: %w", id, err)
+		}
+		cache[id] = inlined{dataURL: dataURL, filename: file.Filename}
+		return cache[id], nil
+	}
+	inline := func(out []byte, path string, part gjson.Result) ([]byte, error) {
+		entry, err := resolve(part.Get("file_id").String())
+		if err != nil {
+			return out, err
+		}
+		out, _ = sjson.SetBytes(out, path+".file_data", entry.dataURL)
+		if part.Get("filename").String() == "" {
+			out, _ = sjson.SetBytes(out, path+".filename", entry.filename)
+		}
+		out, _ = sjson.DeleteBytes(out, path+".file_id")
+		return out, nil
+	}
+
+	out := body
+	var err error
+	for i, message := range gjson.GetBytes(body, "messages").Array() {
+		for j, part := range message.Get("content").Array() {
+			if part.Get("type").String() == "file" && part.Get("file.file_id").String() != "" {
+				if out, err = inline(out, fmt.Sprintf("messages.%d.content.%d.file", i, j), part.Get("file")); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	for i, item := range gjson.GetBytes(body, "input").Array() {
+		for j, part := range item.Get("content").Array() {
+			if part.Get("type").String() == "input_file" && part.Get("file_id").String() != "" {
+				if out, err = inline(out, fmt.Sprintf("input.%d.content.%d", i, j), part); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return out, nil
+}
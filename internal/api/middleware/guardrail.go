@@ -0,0 +1,82 @@
+// Package middleware provides HTTP middleware components for the CLI Proxy API server.
+// This file contains the guardrail middleware that bounds output tokens and prompt sizes
+// per API key.
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/guardrail"
+)
+
+// guardrailFormats maps the routes guardrails apply to onto their request format.
+var guardrailFormats = map[string]guardrail.Format{
+	"/v1/chat/completions":   guardrail.FormatOpenAIChat,
+	"/v1/completions":        guardrail.FormatOpenAICompletions,
+	"/v1/responses":          guardrail.FormatOpenAIResponses,
+	"/v1/messages":           guardrail.FormatClaude,
+	"/v1beta/models/:action": guardrail.FormatGemini,
+}
+
+// generationFormat returns the request format of a generation route; Gemini model routes
+// only count for their generateContent actions.
+func generationFormat(c *gin.Context) (guardrail.Format, bool) {
+	format, ok := guardrailFormats[c.FullPath()]
+	if !ok || (format == guardrail.FormatGemini && !strings.HasSuffix(strings.ToLower(c.Param("action")), "generatecontent")) {
+		return "", false
+	}
+	return format, true
+}
+
+// GuardrailMiddleware creates a Gin middleware that applies the guardrail of the client
+// key to generation requests: output token limits are lowered to the configured cap and
+// prompts over the input limit are truncated or rejected with 400, depending on the
+// strategy. Truncated requests are answered with an X-Guardrail-Truncated header holding
+// the number of dropped messages. It must run after authentication.
+func GuardrailMiddleware(guard *guardrail.Guard) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodPost || !guard.Enabled() || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+		format, ok := generationFormat(c)
+		if !ok {
+			c.Next()
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			c.Next()
+			return
+		}
+		key := ""
+		if value, exists := c.Get("apiKey"); exists {
+			key = fmt.Sprint(value)
+		}
+
+		result, err := guard.Apply(key, format, body)
+		if err != nil {
+			var limitErr *guardrail.LimitError
+			if errors.As(err, &limitErr) {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": limitErr.Error()})
+				return
+			}
+			c.Next()
+			return
+		}
+		if result.Dropped > 0 {
+			c.Header("X-Guardrail-Truncated", strconv.Itoa(result.Dropped))
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(result.Body))
+		c.Request.ContentLength = int64(len(result.Body))
+		c.Next()
+	}
+}
@@ -0,0 +1,220 @@
+// Package middleware provides HTTP middleware components for the CLI Proxy API server.
+// This file contains the middleware that runs the pre-request and post-response hooks.
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/hooks"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxStreamedHookBody bounds the part of a streamed response passed to post-response hooks.
+const maxStreamedHookBody = 4 << 20
+
+// HooksMiddleware creates a Gin middleware that runs the configured hooks. Pre-request hooks
+// may change the headers and JSON body of a request or reject it; post-response hooks may
+// change the headers and JSON body of a response or replace it with an error. Streamed
+// responses reach post-response hooks only once they are complete, and the hooks can then
+// only observe them. When a hook fails without failing open the request is answered with
+// 503. It must run after authentication.
+func HooksMiddleware(runner *hooks.Runner) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		pre := runner.Enabled(hooks.StagePreRequest, path)
+		post := runner.Enabled(hooks.StagePostResponse, path)
+		if !pre && !post {
+			c.Next()
+			return
+		}
+		var body []byte
+		if c.Request.Body != nil && !strings.HasPrefix(c.ContentType(), "multipart/") {
+			data, err := io.ReadAll(c.Request.Body)
+			c.Request.Body = io.NopCloser(bytes.NewReader(data))
+			if err == nil {
+				body = data
+			}
+		}
+		key := ""
+		if value, exists := c.Get("apiKey"); exists {
+			key = fmt.Sprint(value)
+		}
+		event := hooks.Event{
+			Method:  c.Request.Method,
+			Path:    path,
+			Query:   c.Request.URL.RawQuery,
+			APIKey:  key,
+			Headers: hooks.FlattenHeaders(c.Request.Header),
+		}
+		if len(body) > 0 && json.Valid(body) {
+			event.Body = body
+		}
+
+		if pre {
+			event.Stage = hooks.StagePreRequest
+			original := hooks.FlattenHeaders(c.Request.Header)
+			originalBody := event.Body
+			if !runHooks(c, runner, &event, key) {
+				return
+			}
+			applyHeaders(c.Request.Header, original, event.Headers)
+			if !bytes.Equal(originalBody, event.Body) {
+				c.Request.Body = io.NopCloser(bytes.NewReader(event.Body))
+				c.Request.ContentLength = int64(len(event.Body))
+				c.Request.Header.Set("Content-Length", strconv.Itoa(len(event.Body)))
+			}
+		}
+		if !post {
+			c.Next()
+			return
+		}
+
+		writer := &hookWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		event.Stage = hooks.StagePostResponse
+		event.Status = writer.Status()
+		event.ResponseHeaders = hooks.FlattenHeaders(writer.Header())
+		event.ResponseBody = hooks.JSONValue(writer.buf.Bytes())
+		if writer.streamed {
+			event.Streamed = true
+			ctx := context.WithoutCancel(c.Request.Context())
+			go func() {
+				if rejection, err := runner.Run(ctx, &event); err != nil {
+					log.Warnf("hooks: streamed %s response of key %s: %v", path, util.HideAPIKey(key), err)
+				} else if rejection != nil {
+					log.Warnf("hooks: hook %s rejected the streamed %s response of key %s after it was sent: %s", rejection.Hook, path, util.HideAPIKey(key), rejection.Message)
+				}
+			}()
+			return
+		}
+
+		originalHeaders := hooks.FlattenHeaders(writer.Header())
+		originalBody := event.ResponseBody
+		rejection, err := runner.Run(c.Request.Context(), &event)
+		out := writer.buf.Bytes()
+		switch {
+		case err != nil:
+			log.Warnf("hooks: %s response of key %s not checked: %v", path, util.HideAPIKey(key), err)
+			out = hookError(writer, http.StatusServiceUnavailable, "response hook is unavailable")
+		case rejection != nil:
+			log.Warnf("hooks: hook %s rejected the %s response of key %s: %s", rejection.Hook, path, util.HideAPIKey(key), rejection.Message)
+			out = hookError(writer, rejection.Status, rejection.Message)
+		default:
+			applyHeaders(writer.Header(), originalHeaders, event.ResponseHeaders)
+			if !bytes.Equal(originalBody, event.ResponseBody) {
+				out = event.ResponseBody
+				writer.Header().Set("Content-Length", strconv.Itoa(len(out)))
+			}
+		}
+		if _, errWrite := writer.ResponseWriter.Write(out); errWrite != nil {
+			log.Debugf("hooks: write %s response: %v", path, errWrite)
+		}
+	}
+}
+
+// runHooks runs the hooks of the event's stage on a request and answers it when a hook
+// rejected it or failed. It reports whether the request may continue.
+func runHooks(c *gin.Context, runner *hooks.Runner, event *hooks.Event, key string) bool {
+	rejection, err := runner.Run(c.Request.Context(), event)
+	if err != nil {
+		log.Warnf("hooks: %s request of key %s not checked: %v", event.Path, util.HideAPIKey(key), err)
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "request hook is unavailable"})
+		return false
+	}
+	if rejection != nil {
+		log.Infof("hooks: hook %s rejected %s request of key %s: %s", rejection.Hook, event.Path, util.HideAPIKey(key), rejection.Message)
+		c.AbortWithStatusJSON(rejection.Status, gin.H{"error": rejection.Message})
+		return false
+	}
+	return true
+}
+
+// applyHeaders updates header with the values a hook changed from original to updated.
+func applyHeaders(header http.Header, original, updated map[string]string) {
+	for name := range original {
+		if _, ok := updated[name]; !ok {
+			header.Del(name)
+		}
+	}
+	for name, value := range updated {
+		if original[name] != value {
+			header.Set(name, value)
+		}
+	}
+}
+
+// hookError replaces the held back response with an error and returns its body.
+func hookError(writer *hookWriter, status int, message string) []byte {
+	header := writer.Header()
+	header.Del("Content-Encoding")
+	header.Set("Content-Type", "application/json; charset=utf-8")
+	body := []byte(fmt.Sprintf(`{"error":%q}`, message))
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	writer.ResponseWriter.WriteHeader(status)
+	return body
+}
+
+// hookWriter holds back a response until the post-response hooks have seen it. A flush
+// switches it to streaming: the held back part is sent and later writes pass through, while
+// up to maxStreamedHookBody bytes are kept for the hooks.
+type hookWriter struct {
+	gin.ResponseWriter
+	buf      bytes.Buffer
+	streamed bool
+}
+
+func (w *hookWriter) Write(data []byte) (int, error) {
+	if !w.streamed {
+		return w.buf.Write(data)
+	}
+	if w.buf.Len() < maxStreamedHookBody {
+		w.buf.Write(data[:min(len(data), maxStreamedHookBody-w.buf.Len())])
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *hookWriter) WriteString(data string) (int, error) {
+	return w.Write([]byte(data))
+}
+
+func (w *hookWriter) Written() bool {
+	return w.ResponseWriter.Written() || w.buf.Len() > 0
+}
+
+func (w *hookWriter) Size() int {
+	if !w.streamed && w.buf.Len() > 0 {
+		return w.buf.Len()
+	}
+	return w.ResponseWriter.Size()
+}
+
+func (w *hookWriter) Flush() {
+	if !w.streamed {
+		w.streamed = true
+		if w.buf.Len() > 0 {
+			if _, err := w.ResponseWriter.Write(w.buf.Bytes()); err != nil {
+				log.Debugf("hooks: flush: %v", err)
+			}
+		}
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *hookWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.streamed = true
+	return w.ResponseWriter.Hijack()
+}
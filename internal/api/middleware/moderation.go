@@ -0,0 +1,69 @@
+// Package middleware provides HTTP middleware components for the CLI Proxy API server.
+// This file contains the content moderation middleware that screens generation requests
+// before they are dispatched upstream.
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/moderation"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// ModerationMiddleware creates a Gin middleware that runs the moderation filters over the
+// text of generation requests. Rejected requests are answered with 400, redacted requests
+// are forwarded with the redacted text and an X-Moderation-Redacted header naming the
+// filters, and requests whose moderation failed are answered with 503 unless moderation
+// fails open. Every rejection and redaction is logged. It must run after authentication.
+func ModerationMiddleware(moderator *moderation.Moderator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodPost || !moderator.Enabled() || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+		if _, ok := generationFormat(c); !ok {
+			c.Next()
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			c.Next()
+			return
+		}
+		key := ""
+		if value, exists := c.Get("apiKey"); exists {
+			key = fmt.Sprint(value)
+		}
+
+		moderated, verdict, err := moderator.Moderate(c.Request.Context(), body)
+		if err != nil {
+			var unavailable *moderation.UnavailableError
+			if errors.As(err, &unavailable) {
+				log.Warnf("moderation: %s request of key %s not checked: %v", c.Request.URL.Path, util.HideAPIKey(key), err)
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "content moderation is unavailable"})
+				return
+			}
+			c.Next()
+			return
+		}
+		switch verdict.Action {
+		case moderation.ActionReject:
+			log.Warnf("moderation: rejected %s request of key %s by %s: %s", c.Request.URL.Path, util.HideAPIKey(key), verdict.Filter, verdict.Reason)
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "request rejected by content moderation: " + verdict.Reason})
+			return
+		case moderation.ActionRedact:
+			log.Infof("moderation: redacted %s request of key %s by %s", c.Request.URL.Path, util.HideAPIKey(key), verdict.Filter)
+			c.Header("X-Moderation-Redacted", verdict.Filter)
+			c.Request.Body = io.NopCloser(bytes.NewReader(moderated))
+			c.Request.ContentLength = int64(len(moderated))
+		}
+		c.Next()
+	}
+}
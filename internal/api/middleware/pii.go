@@ -0,0 +1,110 @@
+// Package middleware provides HTTP middleware components for the CLI Proxy API server.
+// This file contains the PII redaction middleware that masks personal data in prompts and
+// restores it in responses.
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/pii"
+	log "github.com/sirupsen/logrus"
+)
+
+// PIIRedactionMiddleware creates a Gin middleware that replaces emails, phone numbers, card
+// numbers and custom patterns in the text of generation requests with placeholders before
+// the request goes upstream. Redacted requests are answered with an X-PII-Redacted header
+// holding the number of masked values, and unless placeholders are kept, the placeholders
+// the model repeats in its response are replaced with the original values.
+func PIIRedactionMiddleware(redactor *pii.Redactor) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodPost || !redactor.Enabled() || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+		if _, ok := generationFormat(c); !ok {
+			c.Next()
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			c.Next()
+			return
+		}
+		redacted, mapping := redactor.Redact(body)
+		if mapping.Len() == 0 {
+			c.Next()
+			return
+		}
+		log.Debugf("pii redaction: masked %d values in %s request", mapping.Len(), c.Request.URL.Path)
+		c.Request.Body = io.NopCloser(bytes.NewReader(redacted))
+		c.Request.ContentLength = int64(len(redacted))
+		c.Request.Header.Set("Content-Length", strconv.Itoa(len(redacted)))
+		c.Header("X-PII-Redacted", strconv.Itoa(mapping.Len()))
+		if !redactor.Restores() {
+			c.Next()
+			return
+		}
+		writer := &piiRestoreWriter{ResponseWriter: c.Writer, mapping: mapping}
+		c.Writer = writer
+		c.Next()
+		writer.finish()
+	}
+}
+
+// piiRestoreWriter restores the original values of placeholders in the response. Event
+// streams are restored event by event, so placeholders split across events are restored too.
+type piiRestoreWriter struct {
+	gin.ResponseWriter
+	mapping *pii.Mapping
+	stream  *pii.StreamRestorer
+	started bool
+}
+
+// WriteHeader drops the Content-Length, since restored values change the body length.
+func (w *piiRestoreWriter) WriteHeader(code int) {
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *piiRestoreWriter) Write(data []byte) (int, error) {
+	if !w.started {
+		w.started = true
+		w.Header().Del("Content-Length")
+		if strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+			w.stream = w.mapping.NewStreamRestorer()
+		}
+	}
+	var out []byte
+	if w.stream != nil {
+		if out = w.stream.Write(data); len(out) == 0 {
+			return len(data), nil
+		}
+	} else {
+		out = w.mapping.Restore(data)
+	}
+	if _, err := w.ResponseWriter.Write(out); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// finish writes what the stream restorer still holds once the handler returns.
+func (w *piiRestoreWriter) finish() {
+	if w.stream == nil {
+		return
+	}
+	if out := w.stream.Flush(); len(out) > 0 {
+		_, _ = w.ResponseWriter.Write(out)
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *piiRestoreWriter) WriteString(data string) (int, error) {
+	return w.Write([]byte(data))
+}
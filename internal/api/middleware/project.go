@@ -0,0 +1,51 @@
+// Package middleware provides HTTP middleware components for the CLI Proxy API server.
+// This file contains the project middleware that confines API keys to the models and
+// quotas of the project they belong to.
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/project"
+)
+
+// ProjectMiddleware creates a Gin middleware that resolves the project of the client key
+// and stores its name in the context under "project" for routing and usage reporting.
+// It must run after authentication. Requests for models outside the project's allow-list
+// are rejected with 403, and requests beyond the project's shared quota with 429 and the
+// same X-Quota-* headers as per-key quotas. Keys outside every project pass through.
+func ProjectMiddleware(registry *project.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !registry.Enabled() {
+			c.Next()
+			return
+		}
+		value, exists := c.Get("apiKey")
+		if !exists {
+			c.Next()
+			return
+		}
+		p, ok := registry.ForKey(fmt.Sprint(value))
+		if !ok {
+			c.Next()
+			return
+		}
+		c.Set("project", p.Name)
+
+		if model := requestModel(c); model != "" && !project.ModelAllowed(p, model) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": fmt.Sprintf("model %s is not allowed for project %s", model, p.Name),
+			})
+			return
+		}
+		if c.Request.Method == http.MethodGet {
+			c.Next()
+			return
+		}
+		if applyQuotaDecision(c, registry.Admit(p.Name), "project") {
+			c.Next()
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Package middleware provides HTTP middleware components for the CLI Proxy API server.
+// This file contains the middleware that expands prompt templates referenced by requests.
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/prompts"
+	log "github.com/sirupsen/logrus"
+)
+
+// PromptTemplateMiddleware creates a Gin middleware that expands the prompt template named
+// by metadata.prompt_template of generation requests into their messages. Unknown templates
+// and missing variables are rejected with 400. It runs before the session middleware, so a
+// session stores the expanded messages and later turns need not reference the template.
+func PromptTemplateMiddleware(library *prompts.Library) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodPost || !library.Enabled() || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+		format, ok := generationFormat(c)
+		if !ok {
+			c.Next()
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			c.Next()
+			return
+		}
+		out, name, err := library.Expand(format, body)
+		if err != nil {
+			var validation *prompts.ValidationError
+			if errors.As(err, &validation) {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": validation.Message})
+				return
+			}
+			log.Warnf("prompts: expand template %s: %v", name, err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to expand prompt template"})
+			return
+		}
+		if name != "" {
+			log.Debugf("prompts: expanded template %s into %s", name, c.Request.URL.Path)
+			c.Request.Body = io.NopCloser(bytes.NewReader(out))
+			c.Request.ContentLength = int64(len(out))
+			c.Request.Header.Set("Content-Length", strconv.Itoa(len(out)))
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,67 @@
+// Package middleware provides HTTP middleware components for the CLI Proxy API server.
+// This file contains the quota middleware that rejects requests from API keys that
+// have exhausted their configured daily or monthly limits.
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/quota"
+)
+
+// QuotaMiddleware creates a Gin middleware that enforces per-key quotas. It must run
+// after authentication so the client key is available. Read-only GET requests such as
+// model listings are not counted. Every limited response carries X-Quota-* headers
+// describing the most constrained limit; rejected requests receive 429 with Retry-After.
+func QuotaMiddleware(manager *quota.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodGet || !manager.Enabled() {
+			c.Next()
+			return
+		}
+		value, exists := c.Get("apiKey")
+		if !exists {
+			c.Next()
+			return
+		}
+		key := fmt.Sprint(value)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		if applyQuotaDecision(c, manager.Admit(key), "API key") {
+			c.Next()
+		}
+	}
+}
+
+// applyQuotaDecision sets the X-Quota-* headers of a limited decision and rejects the
+// request with 429 when it was denied. It reports whether the request may proceed.
+func applyQuotaDecision(c *gin.Context, decision quota.Decision, subject string) bool {
+	if !decision.Limited {
+		return true
+	}
+	binding := decision.Binding
+	c.Header("X-Quota-Limit", strconv.FormatInt(binding.Limit, 10))
+	c.Header("X-Quota-Remaining", strconv.FormatInt(binding.Remaining, 10))
+	c.Header("X-Quota-Reset", strconv.FormatInt(binding.ResetAt.Unix(), 10))
+	c.Header("X-Quota-Window", binding.Window+"-"+binding.Metric)
+	if decision.Allowed {
+		return true
+	}
+
+	retryAfter := int64(time.Until(binding.ResetAt).Seconds()) + 1
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	c.Header("Retry-After", strconv.FormatInt(retryAfter, 10))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+		"error": fmt.Sprintf("%s %s quota exceeded for this %s, resets at %s", binding.Window, binding.Metric, subject, binding.ResetAt.Format(time.RFC3339)),
+	})
+	return false
+}
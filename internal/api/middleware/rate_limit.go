@@ -0,0 +1,78 @@
+// Package middleware provides HTTP middleware components for the CLI Proxy API server.
+// This file contains the rate limit middleware that throttles requests per API key and model.
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/ratelimit"
+	"github.com/tidwall/gjson"
+)
+
+// RateLimitMiddleware creates a Gin middleware that enforces the configured requests and
+// tokens per minute limits. It must run after authentication so the client key is
+// available. Read-only GET requests are not counted. Every limited response carries
+// X-RateLimit-* headers describing the most constrained bucket; rejected requests receive
+// 429 with Retry-After.
+func RateLimitMiddleware(limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodGet || !limiter.Enabled() {
+			c.Next()
+			return
+		}
+		key := ""
+		if value, exists := c.Get("apiKey"); exists {
+			key = fmt.Sprint(value)
+		}
+
+		decision := limiter.Admit(key, requestModel(c))
+		if !decision.Limited {
+			c.Next()
+			return
+		}
+		binding := decision.Binding
+		c.Header("X-RateLimit-Limit", strconv.FormatInt(binding.Limit, 10))
+		c.Header("X-RateLimit-Remaining", strconv.FormatInt(binding.Remaining, 10))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(binding.ResetAt.Unix(), 10))
+		c.Header("X-RateLimit-Scope", binding.Scope+"-"+binding.Metric)
+		if decision.Allowed {
+			c.Next()
+			return
+		}
+
+		retryAfter := int64((binding.RetryAfter + time.Second - 1) / time.Second)
+		if retryAfter < 1 {
+			retryAfter = 1
+		}
+		c.Header("Retry-After", strconv.FormatInt(retryAfter, 10))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+			"error": fmt.Sprintf("%s rate limit of %d %s per minute exceeded, retry in %ds", binding.Scope, binding.Limit, binding.Metric, retryAfter),
+		})
+	}
+}
+
+// requestModel returns the model a request asks for: the model segment of Gemini style
+// paths, otherwise the "model" field of a JSON body, which is restored for the handler.
+// Other bodies, such as multipart uploads, are left untouched and yield "".
+func requestModel(c *gin.Context) string {
+	if action := strings.TrimPrefix(c.Param("action"), "/"); action != "" {
+		model, _, _ := strings.Cut(action, ":")
+		return model
+	}
+	if c.Request.Body == nil || !strings.Contains(c.ContentType(), "json") {
+		return ""
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+	return gjson.GetBytes(body, "model").String()
+}
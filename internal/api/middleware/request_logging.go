@@ -83,9 +83,12 @@ func captureRequestInfo(c *gin.Context) (*RequestInfo, error) {
 	delete(headers, "Authorization")
 	delete(headers, "Cookie")
 
-	// Capture request body
+	// Capture request body. Multipart uploads are not read, so a spooled upload is not
+	// buffered in memory, and are logged as a placeholder.
 	var body []byte
-	if c.Request.Body != nil {
+	if isMultipartRequest(c.Request) {
+		body = multipartPlaceholder(c.Request)
+	} else if c.Request.Body != nil {
 		// Read the body
 		bodyBytes, err := io.ReadAll(c.Request.Body)
 		if err != nil {
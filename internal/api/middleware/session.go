@@ -0,0 +1,133 @@
+// Package middleware provides HTTP middleware components for the CLI Proxy API server.
+// This file contains the session middleware that keeps conversation history on the server.
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/guardrail"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/session"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	// SessionHeader names the server-side session a request continues.
+	SessionHeader = "X-CLIProxy-Session"
+	// sessionHistoryHeader reports how many stored messages were sent before the request's own.
+	sessionHistoryHeader = "X-CLIProxy-Session-History"
+	// maxSessionIDLength bounds the session IDs clients may send.
+	maxSessionIDLength = 256
+	// maxSessionReplyBytes bounds the response kept to extract the reply; longer replies
+	// are not added to the session.
+	maxSessionReplyBytes = 16 << 20
+)
+
+// sessionFormats maps the request formats sessions support onto their session format.
+var sessionFormats = map[guardrail.Format]session.Format{
+	guardrail.FormatOpenAIChat: session.FormatOpenAIChat,
+	guardrail.FormatClaude:     session.FormatClaude,
+	guardrail.FormatGemini:     session.FormatGemini,
+}
+
+// SessionMiddleware creates a Gin middleware that continues the server-side session named by
+// the X-CLIProxy-Session header: the stored history is sent upstream before the messages of
+// the request, and once the response succeeds the messages and the reply are added to the
+// session. Requests without the header are left alone. It must run after authentication and
+// before middleware that sizes or rewrites prompts.
+func SessionMiddleware(manager *session.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := strings.TrimSpace(c.GetHeader(SessionHeader))
+		if id == "" || c.Request.Method != http.MethodPost || !manager.Enabled() || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+		requestFormat, _ := generationFormat(c)
+		format, ok := sessionFormats[requestFormat]
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s is not supported on %s", SessionHeader, c.Request.URL.Path)})
+			return
+		}
+		if len(id) > maxSessionIDLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s exceeds %d characters", SessionHeader, maxSessionIDLength)})
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			c.Next()
+			return
+		}
+		key := ""
+		if value, exists := c.Get("apiKey"); exists {
+			key = fmt.Sprint(value)
+		}
+
+		turn, err := manager.Prepare(c.Request.Context(), key, id, format, body)
+		if err != nil {
+			var formatErr *session.FormatError
+			if errors.As(err, &formatErr) {
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": formatErr.Error()})
+				return
+			}
+			log.Warnf("sessions: load session of key %s: %v", util.HideAPIKey(key), err)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "session store is unavailable"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(turn.Body))
+		c.Request.ContentLength = int64(len(turn.Body))
+		c.Request.Header.Set("Content-Length", strconv.Itoa(len(turn.Body)))
+		c.Header(sessionHistoryHeader, strconv.Itoa(turn.History))
+
+		writer := &sessionWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		if writer.Status() != http.StatusOK || writer.overflow {
+			return
+		}
+		streamed := gjson.GetBytes(body, "stream").Bool() || strings.Contains(c.Param("action"), "streamGenerateContent")
+		reply := session.Reply(format, writer.buf.Bytes(), streamed)
+		if reply == nil {
+			log.Debugf("sessions: no reply found in %s response, session unchanged", c.Request.URL.Path)
+			return
+		}
+		if err = turn.Complete(context.WithoutCancel(c.Request.Context()), reply); err != nil {
+			log.Warnf("sessions: save session of key %s: %v", util.HideAPIKey(key), err)
+		}
+	}
+}
+
+// sessionWriter keeps a copy of the response, up to maxSessionReplyBytes, while passing it
+// through to the client.
+type sessionWriter struct {
+	gin.ResponseWriter
+	buf      bytes.Buffer
+	overflow bool
+}
+
+func (w *sessionWriter) Write(data []byte) (int, error) {
+	if !w.overflow {
+		if w.buf.Len()+len(data) > maxSessionReplyBytes {
+			w.overflow = true
+			w.buf.Reset()
+		} else {
+			w.buf.Write(data)
+		}
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *sessionWriter) WriteString(data string) (int, error) {
+	return w.Write([]byte(data))
+}
@@ -0,0 +1,65 @@
+// Package middleware provides HTTP middleware components for the CLI Proxy API server.
+// This file contains the middleware that adds configured policy text to system prompts.
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sysprompt"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+// SystemPromptMiddleware creates a Gin middleware that adds the prefixes and suffixes of the
+// matching system prompt rules to generation requests. It must run after the project
+// middleware, so rules can match projects, and after the session middleware, so stored
+// sessions keep the system prompts of the clients.
+func SystemPromptMiddleware(injector *sysprompt.Injector) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodPost || !injector.Enabled() || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+		format, ok := generationFormat(c)
+		if !ok {
+			c.Next()
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			c.Next()
+			return
+		}
+		req := sysprompt.Request{Model: gjson.GetBytes(body, "model").String()}
+		if action := strings.TrimPrefix(c.Param("action"), "/"); action != "" {
+			req.Model, _, _ = strings.Cut(action, ":")
+		}
+		if value, exists := c.Get("apiKey"); exists {
+			req.APIKey = fmt.Sprint(value)
+		}
+		if value, exists := c.Get("project"); exists {
+			req.Project = fmt.Sprint(value)
+		}
+
+		out, applied, err := injector.Apply(format, body, req)
+		if err != nil {
+			log.Warnf("%v, forwarding %s unchanged", err, c.Request.URL.Path)
+			c.Next()
+			return
+		}
+		if len(applied) > 0 {
+			log.Debugf("system prompts: applied %s to %s", strings.Join(applied, ", "), c.Request.URL.Path)
+			c.Request.Body = io.NopCloser(bytes.NewReader(out))
+			c.Request.ContentLength = int64(len(out))
+			c.Request.Header.Set("Content-Length", strconv.Itoa(len(out)))
+		}
+		c.Next()
+	}
+}
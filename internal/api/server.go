@@ -12,6 +12,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -19,12 +20,37 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/access"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/admission"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers/batches"
+	fileHandlers "github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers/files"
 	managementHandlers "github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers/management"
 	metrics "github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers/metrics"
+	promptHandlers "github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers/prompts"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/middleware"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/batch"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/bodylimit"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/capture"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/compression"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/filestore"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/grpcapi"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/guardrail"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/hooks"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/managementasset"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/moderation"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/pii"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/project"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/prompts"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/quota"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/ratelimit"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/session"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/shadow"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/statsd"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sysprompt"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/transport"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/upgrade"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
@@ -33,10 +59,20 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/gemini"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/openai"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/ui"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
 )
 
+const (
+	// defaultDrainTimeout bounds how long shutdown waits for in-flight responses.
+	defaultDrainTimeout = 30 * time.Second
+	// drainLogInterval is how often drain progress is logged.
+	drainLogInterval = 5 * time.Second
+)
+
 const oauthCallbackSuccessHTML = `<html><head><meta charset="utf-8"><title>Authentication successful</title><script>setTimeout(function(){window.close();},5000);</script></head><body><h1>Authentication successful!</h1><p>You can close this window.</p><p>This window will close automatically in 5 seconds.</p></body></html>`
 
 type serverOptionConfig struct {
@@ -48,6 +84,8 @@ type serverOptionConfig struct {
 	keepAliveEnabled     bool
 	keepAliveTimeout     time.Duration
 	keepAliveOnTimeout   func()
+	quotaCounters        quota.SharedCounters
+	readinessChecks      []namedReadinessCheck
 }
 
 // ServerOption customises HTTP server construction.
@@ -108,6 +146,14 @@ func WithRequestLoggerFactory(factory func(*config.Config, string) logging.Reque
 	}
 }
 
+// WithQuotaCounters makes the API key quotas count consumption in shared counters,
+// so that limits hold across several proxy instances.
+func WithQuotaCounters(counters quota.SharedCounters) ServerOption {
+	return func(cfg *serverOptionConfig) {
+		cfg.quotaCounters = counters
+	}
+}
+
 // Server represents the main API server.
 // It encapsulates the Gin engine, HTTP server, handlers, and configuration.
 type Server struct {
@@ -134,6 +180,75 @@ type Server struct {
 	requestLogger logging.RequestLogger
 	loggerToggle  func(bool)
 
+	// accessLogger writes structured per-request access records.
+	accessLogger *logging.AccessLogger
+
+	// statsd pushes request metrics to a StatsD or DogStatsD agent.
+	statsd *statsd.Emitter
+
+	// readinessChecks are the dependency checks added to /readyz by server options.
+	readinessChecks []namedReadinessCheck
+
+	// auditLogger records changes made through the management API.
+	auditLogger *logging.AuditLogger
+
+	// captureRecorder keeps full request and response bodies while body capture is enabled.
+	captureRecorder *capture.Recorder
+
+	// quotaManager enforces per-key request and token quotas.
+	quotaManager *quota.Manager
+
+	// projects resolves the project of each client key and enforces project quotas.
+	projects *project.Registry
+
+	// accountTracker tracks upstream account consumption in quota windows.
+	accountTracker *usage.AccountTracker
+
+	// rateLimiter enforces requests and tokens per minute limits.
+	rateLimiter *ratelimit.Limiter
+
+	// guardrails caps output tokens and prompt sizes per API key.
+	guardrails *guardrail.Guard
+
+	// moderator screens the text of generation requests before dispatch.
+	moderator *moderation.Moderator
+
+	// hooks runs the pre-request and post-response hooks.
+	hooks *hooks.Runner
+
+	// sessions keeps the conversation history of requests that name a session.
+	sessions *session.Manager
+
+	// compressor negotiates the compression of JSON responses.
+	compressor *compression.Compressor
+
+	// bodyLimiter bounds request bodies and spools multipart uploads.
+	bodyLimiter *bodylimit.Limiter
+
+	// piiRedactor masks personal data in prompts and restores it in responses.
+	piiRedactor *pii.Redactor
+
+	// systemPrompts adds configured policy text to the system prompts of requests.
+	systemPrompts *sysprompt.Injector
+
+	// prompts stores the prompt templates requests may reference.
+	prompts *prompts.Library
+
+	// shadow duplicates sampled requests to candidate models for offline comparison.
+	shadow *shadow.Recorder
+
+	// admission caps concurrent requests and queues the excess by API key priority.
+	admission *admission.Queue
+
+	// files stores the files of the Files API.
+	files *filestore.Store
+
+	// batches stores and runs the batches of the Batch API.
+	batches *batch.Manager
+
+	// updateMu serialises configuration updates so a reload is applied as a whole.
+	updateMu sync.Mutex
+
 	// configFilePath is the absolute path to the YAML config file for persistence.
 	configFilePath string
 
@@ -152,6 +267,15 @@ type Server struct {
 	// metrics handler
 	metricsHandler *metrics.Handler
 
+	// grpcServer serves the management plane over gRPC when remote-management.grpc-port is set.
+	grpcServer *grpcapi.Server
+	grpcAddr   string
+
+	// managementRouter and managementServer serve the management plane on its own listener
+	// when management-listener.port is set; nil otherwise.
+	managementRouter *gin.Engine
+	managementServer *http.Server
+
 	// managementRoutesRegistered tracks whether the management routes have been attached to the engine.
 	managementRoutesRegistered atomic.Bool
 	// managementRoutesEnabled controls whether management endpoints serve real handlers.
@@ -200,10 +324,20 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 	// Add middleware
 	engine.Use(logging.GinLogrusLogger())
 	engine.Use(logging.GinLogrusRecovery())
+	engine.Use(middleware.TracingMiddleware())
 	for _, mw := range optionState.extraMiddleware {
 		engine.Use(mw)
 	}
 
+	// Compression wraps the response writer before the middleware that logs or captures
+	// bodies, so that they see the uncompressed responses.
+	compressor := compression.New(cfg.Compression)
+	engine.Use(middleware.CompressionMiddleware(compressor))
+
+	// Body limits apply before any middleware reads the request body.
+	bodyLimiter := bodylimit.New(cfg.RequestLimits)
+	engine.Use(middleware.BodyLimitMiddleware(bodyLimiter))
+
 	// Add request logging middleware (positioned after recovery, before auth)
 	// Resolve logs directory relative to the configuration file directory.
 	var requestLogger logging.RequestLogger
@@ -218,6 +352,26 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 		}
 	}
 
+	accessLogger, errAccessLog := logging.NewAccessLogger(cfg.AccessLog)
+	if errAccessLog != nil {
+		log.Errorf("failed to initialise access log, access logging disabled: %v", errAccessLog)
+		accessLogger = &logging.AccessLogger{}
+	}
+	engine.Use(middleware.AccessLogMiddleware(accessLogger))
+
+	auditLogger, errAuditLog := logging.NewAuditLogger(cfg.AuditLog)
+	if errAuditLog != nil {
+		log.Errorf("failed to initialise audit log, audit logging disabled: %v", errAuditLog)
+		auditLogger = &logging.AuditLogger{}
+	}
+
+	captureRecorder, errCapture := capture.NewRecorder(cfg.BodyCapture)
+	if errCapture != nil {
+		log.Errorf("failed to initialise body capture, body capture disabled: %v", errCapture)
+		captureRecorder = &capture.Recorder{}
+	}
+	engine.Use(middleware.BodyCaptureMiddleware(captureRecorder))
+
 	engine.Use(corsMiddleware())
 	wd, err := os.Getwd()
 	if err != nil {
@@ -240,6 +394,11 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 		accessManager:       accessManager,
 		requestLogger:       requestLogger,
 		loggerToggle:        toggle,
+		accessLogger:        accessLogger,
+		auditLogger:         auditLogger,
+		captureRecorder:     captureRecorder,
+		compressor:          compressor,
+		bodyLimiter:         bodyLimiter,
 		configFilePath:      configFilePath,
 		currentPath:         wd,
 		envManagementSecret: envManagementSecret,
@@ -251,9 +410,73 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 	s.applyAccessConfig(nil, cfg)
 	managementasset.SetCurrentConfig(cfg)
 	auth.SetQuotaCooldownDisabled(cfg.DisableCooling)
+	util.ConfigureMediaFetch(&cfg.SDKConfig)
+	transport.Configure(cfg.UpstreamTransport)
 	// Initialize management handler
 	s.mgmt = managementHandlers.NewHandler(cfg, configFilePath, authManager)
 	s.metricsHandler = metrics.NewHandler(usage.GetRequestStatistics())
+	s.metricsHandler.SetPricing(usage.NewPriceTable(cfg.Pricing))
+	s.metricsHandler.SetAuthManager(authManager)
+	s.quotaManager = quota.NewManager(cfg.APIKeyQuotas)
+	if optionState.quotaCounters != nil {
+		s.quotaManager.SetSharedCounters(optionState.quotaCounters)
+	}
+	s.quotaManager.Seed(usage.GetRequestStatistics().Aggregates(time.Time{}, time.Time{}))
+	coreusage.RegisterPlugin(s.quotaManager)
+	s.projects = project.NewRegistry(cfg.Projects)
+	if optionState.quotaCounters != nil {
+		s.projects.SetSharedCounters(optionState.quotaCounters)
+	}
+	s.projects.Seed(usage.GetRequestStatistics().Aggregates(time.Time{}, time.Time{}))
+	coreusage.RegisterPlugin(s.projects)
+	s.handlers.ModelAllowed = s.projectModelAllowed
+	s.shadow = shadow.NewRecorder(cfg.Shadow)
+	s.handlers.Shadow = s.shadow
+	s.metricsHandler.SetProjectRegistry(s.projects)
+	s.rateLimiter = ratelimit.NewLimiter(cfg.RateLimits)
+	coreusage.RegisterPlugin(s.rateLimiter)
+	s.guardrails = guardrail.New(cfg.Guardrails)
+	s.moderator = moderation.New(cfg.Moderation)
+	s.hooks = hooks.New(cfg.Hooks)
+	sessions, errSessions := session.New(cfg)
+	if errSessions != nil {
+		log.Errorf("failed to initialise sessions, sessions disabled: %v", errSessions)
+	}
+	s.sessions = sessions
+	s.piiRedactor = pii.New(cfg.PIIRedaction)
+	s.systemPrompts = sysprompt.New(cfg.SystemPrompts)
+	s.prompts = prompts.New(cfg.Prompts)
+	s.metricsHandler.SetModerator(s.moderator)
+	s.admission = admission.NewQueue(cfg.Admission)
+	s.metricsHandler.SetAdmissionQueue(s.admission)
+	s.metricsHandler.SetClientCancellations(s.handlers.ClientCancellations)
+	s.metricsHandler.SetErrorCounts(s.handlers.ErrorCounts)
+	files, errFiles := filestore.New(filesConfig(cfg))
+	if errFiles != nil {
+		log.Errorf("files: %v; falling back to local storage", errFiles)
+		fallback := filesConfig(cfg)
+		fallback.Storage = config.FilesStorageLocal
+		files, _ = filestore.New(fallback)
+	}
+	s.files = files
+	s.batches = batch.NewManager(cfg.Batch, s.files)
+	s.batches.SetHandler(engine)
+	s.batches.SetAuthorizer(batchAuthorizer(cfg))
+	s.handlers.Dispatch = engine
+	s.accountTracker = usage.NewAccountTracker(cfg.AccountQuotas)
+	coreusage.RegisterPlugin(s.accountTracker)
+	s.statsd = statsd.New()
+	if err := s.statsd.Configure(cfg.StatsD); err != nil {
+		log.Errorf("failed to configure statsd: %v", err)
+	}
+	coreusage.RegisterPlugin(s.statsd)
+	s.mgmt.SetQuotaManager(s.quotaManager)
+	s.mgmt.SetProjectRegistry(s.projects)
+	s.mgmt.SetAdmissionQueue(s.admission)
+	s.mgmt.SetAccountTracker(s.accountTracker)
+	s.mgmt.SetCaptureRecorder(s.captureRecorder)
+	s.mgmt.SetSessions(s.sessions)
+	s.mgmt.SetAuditLogger(s.auditLogger)
 	if optionState.localPassword != "" {
 		s.mgmt.SetLocalPassword(optionState.localPassword)
 	}
@@ -263,15 +486,17 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 	}
 	s.mgmt.SetLogDirectory(logDir)
 	s.localPassword = optionState.localPassword
+	s.readinessChecks = optionState.readinessChecks
 
 	// Setup routes
+	s.setupManagementListener(cfg.ManagementListener)
 	s.setupRoutes()
 	if optionState.routerConfigurator != nil {
 		optionState.routerConfigurator(engine, s.handlers, cfg)
 	}
 
 	// Register management routes when configuration or environment secrets are available.
-	hasManagementSecret := cfg.RemoteManagement.SecretKey != "" || envManagementSecret
+	hasManagementSecret := hasManagementKey(cfg) || envManagementSecret
 	s.managementRoutesEnabled.Store(hasManagementSecret)
 	if hasManagementSecret {
 		s.registerManagementRoutes()
@@ -291,14 +516,33 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 		Addr:    fmt.Sprintf("%s:%d", bindAddr, cfg.Port),
 		Handler: engine,
 	}
+	if cfg.RemoteManagement.GRPCPort > 0 {
+		s.grpcServer = grpcapi.NewServer(s.mgmt, s.metricsHandler)
+		s.grpcAddr = fmt.Sprintf("%s:%d", bindAddr, cfg.RemoteManagement.GRPCPort)
+	}
 
 	return s
 }
 
+// filesConfig returns the Files API configuration; enabling the Batch API enables the
+// Files API it reads its input from.
+func filesConfig(cfg *config.Config) config.FilesConfig {
+	files := cfg.Files
+	files.Enable = files.Enable || cfg.Batch.Enable
+	return files
+}
+
+// hasManagementKey reports whether cfg configures a management secret key or token.
+func hasManagementKey(cfg *config.Config) bool {
+	return cfg.RemoteManagement.SecretKey != "" || len(cfg.RemoteManagement.Tokens) > 0
+}
+
 // setupRoutes configures the API routes for the server.
 // It defines the endpoints and associates them with their respective handlers.
 func (s *Server) setupRoutes() {
-	s.engine.GET("/management.html", s.serveManagementControlPanel)
+	if s.managementRouter == nil {
+		s.engine.GET("/management.html", s.serveManagementControlPanel)
+	}
 	openaiHandlers := openai.NewOpenAIAPIHandler(s.handlers)
 	geminiHandlers := gemini.NewGeminiAPIHandler(s.handlers)
 	geminiCLIHandlers := gemini.NewGeminiCLIAPIHandler(s.handlers)
@@ -307,19 +551,58 @@ func (s *Server) setupRoutes() {
 
 	// OpenAI compatible API routes
 	v1 := s.engine.Group("/v1")
-	v1.Use(AuthMiddleware(s.accessManager))
+	v1.Use(AuthMiddleware(s.accessManager), middleware.ProjectMiddleware(s.projects), middleware.PromptTemplateMiddleware(s.prompts), middleware.SessionMiddleware(s.sessions), middleware.SystemPromptMiddleware(s.systemPrompts), middleware.GuardrailMiddleware(s.guardrails), middleware.RateLimitMiddleware(s.rateLimiter), middleware.QuotaMiddleware(s.quotaManager), middleware.PIIRedactionMiddleware(s.piiRedactor), middleware.ModerationMiddleware(s.moderator), middleware.HooksMiddleware(s.hooks), middleware.AdmissionMiddleware(s.admission), middleware.FileReferenceMiddleware(s.files))
 	{
 		v1.GET("/models", s.unifiedModelsHandler(openaiHandlers, claudeCodeHandlers))
 		v1.POST("/chat/completions", openaiHandlers.ChatCompletions)
 		v1.POST("/completions", openaiHandlers.Completions)
+		v1.POST("/embeddings", openaiHandlers.Embeddings)
+		v1.POST("/images/generations", openaiHandlers.ImagesGenerations)
+		v1.POST("/audio/transcriptions", openaiHandlers.AudioTranscriptions)
+		v1.POST("/audio/speech", openaiHandlers.AudioSpeech)
 		v1.POST("/messages", claudeCodeHandlers.ClaudeMessages)
 		v1.POST("/messages/count_tokens", claudeCodeHandlers.ClaudeCountTokens)
+		v1.POST("/tokens/count", openaiHandlers.CountTokens)
 		v1.POST("/responses", openaiResponsesHandlers.Responses)
+		v1.GET("/realtime", openaiHandlers.Realtime)
+	}
+
+	// OpenAI compatible Files and Batches API. Managing files and batches is not metered;
+	// the requests of a batch pass the v1 middleware above when they run.
+	filesAPI := fileHandlers.NewHandler(s.files)
+	filesGroup := s.engine.Group("/v1")
+	filesGroup.Use(AuthMiddleware(s.accessManager), filesAPI.Middleware())
+	{
+		filesGroup.POST("/files", filesAPI.UploadFile)
+		filesGroup.GET("/files", filesAPI.ListFiles)
+		filesGroup.GET("/files/:id", filesAPI.GetFile)
+		filesGroup.GET("/files/:id/content", filesAPI.GetFileContent)
+		filesGroup.DELETE("/files/:id", filesAPI.DeleteFile)
+	}
+	batchHandlers := batches.NewHandler(s.batches)
+	batchAPI := s.engine.Group("/v1")
+	batchAPI.Use(AuthMiddleware(s.accessManager), batchHandlers.Middleware())
+	{
+		batchAPI.POST("/batches", batchHandlers.CreateBatch)
+		batchAPI.GET("/batches", batchHandlers.ListBatches)
+		batchAPI.GET("/batches/:id", batchHandlers.GetBatch)
+		batchAPI.POST("/batches/:id/cancel", batchHandlers.CancelBatch)
+	}
+
+	promptsAPI := promptHandlers.NewHandler(s.prompts)
+	promptsGroup := s.engine.Group("/v1")
+	promptsGroup.Use(AuthMiddleware(s.accessManager), promptsAPI.Middleware())
+	{
+		promptsGroup.GET("/prompts", promptsAPI.ListPrompts)
+		promptsGroup.GET("/prompts/:name", promptsAPI.GetPrompt)
+		promptsGroup.PUT("/prompts/:name", promptsAPI.PutPrompt)
+		promptsGroup.DELETE("/prompts/:name", promptsAPI.DeletePrompt)
+		promptsGroup.POST("/prompts/:name/render", promptsAPI.RenderPrompt)
 	}
 
 	// Gemini compatible API routes
 	v1beta := s.engine.Group("/v1beta")
-	v1beta.Use(AuthMiddleware(s.accessManager))
+	v1beta.Use(AuthMiddleware(s.accessManager), middleware.ProjectMiddleware(s.projects), middleware.PromptTemplateMiddleware(s.prompts), middleware.SessionMiddleware(s.sessions), middleware.SystemPromptMiddleware(s.systemPrompts), middleware.GuardrailMiddleware(s.guardrails), middleware.RateLimitMiddleware(s.rateLimiter), middleware.QuotaMiddleware(s.quotaManager), middleware.PIIRedactionMiddleware(s.piiRedactor), middleware.ModerationMiddleware(s.moderator), middleware.HooksMiddleware(s.hooks), middleware.AdmissionMiddleware(s.admission))
 	{
 		v1beta.GET("/models", geminiHandlers.GeminiModels)
 		v1beta.POST("/models/:action", geminiHandlers.GeminiHandler)
@@ -327,15 +610,29 @@ func (s *Server) setupRoutes() {
 	}
 
 	// Root endpoint
+	s.engine.GET("/healthz", s.handleLiveness)
+	s.engine.GET("/readyz", s.handleReadiness)
+
 	s.engine.GET("/", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
 			"message": "CLI Proxy API Server",
 			"endpoints": []string{
 				"POST /v1/chat/completions",
 				"POST /v1/completions",
+				"POST /v1/embeddings",
+				"POST /v1/images/generations",
+				"POST /v1/audio/transcriptions",
+				"POST /v1/audio/speech",
 				"GET /v1/models",
+				"GET /healthz",
+				"GET /readyz",
 				"GET /_qs/health",
 				"GET /_qs/metrics",
+				"GET /_qs/metrics/details",
+				"GET /_qs/metrics/export",
+				"GET /_qs/dashboard",
+				"POST /_qs/grafana/query",
+				"GET /metrics",
 			},
 		})
 	})
@@ -346,8 +643,19 @@ func (s *Server) setupRoutes() {
 			c.JSON(http.StatusOK, gin.H{"ok": true})
 		})
 		qs.GET("/metrics", s.metricsHandler.GetMetrics)
+		qs.GET("/metrics/details", s.metricsHandler.GetDetails)
+		qs.GET("/metrics/export", s.metricsHandler.ExportMetrics)
 		qs.GET("/metrics/ui", s.serveMetricsUI)
+		qs.GET("/dashboard", s.serveDashboard)
+		qs.GET("/grafana", s.metricsHandler.GrafanaHealth)
+		qs.POST("/grafana/search", s.metricsHandler.GrafanaSearch)
+		qs.POST("/grafana/metrics", s.metricsHandler.GrafanaMetricOptions)
+		qs.POST("/grafana/query", s.metricsHandler.GrafanaQuery)
+		qs.POST("/grafana/annotations", s.metricsHandler.GrafanaAnnotations)
+		qs.POST("/grafana/tag-keys", s.metricsHandler.GrafanaTagKeys)
+		qs.POST("/grafana/tag-values", s.metricsHandler.GrafanaTagValues)
 	}
+	s.engine.GET("/metrics", s.metricsHandler.GetPrometheusMetrics)
 
 	s.engine.POST("/v1internal:method", geminiCLIHandlers.CLIHandler)
 
@@ -453,7 +761,7 @@ func (s *Server) registerManagementRoutes() {
 
 	log.Info("management routes registered after secret key configuration")
 
-	mgmt := s.engine.Group("/v0/management")
+	mgmt := s.managementEngine().Group("/v0/management")
 	mgmt.Use(s.managementAvailabilityMiddleware(), s.mgmt.Middleware())
 	{
 		mgmt.GET("/usage", s.mgmt.GetUsageStatistics)
@@ -464,6 +772,9 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.GET("/debug", s.mgmt.GetDebug)
 		mgmt.PUT("/debug", s.mgmt.PutDebug)
 		mgmt.PATCH("/debug", s.mgmt.PutDebug)
+		mgmt.GET("/log-level", s.mgmt.GetLogLevel)
+		mgmt.PUT("/log-level", s.mgmt.PutLogLevel)
+		mgmt.PATCH("/log-level", s.mgmt.PutLogLevel)
 
 		mgmt.GET("/logging-to-file", s.mgmt.GetLoggingToFile)
 		mgmt.PUT("/logging-to-file", s.mgmt.PutLoggingToFile)
@@ -486,6 +797,19 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.PUT("/quota-exceeded/switch-preview-model", s.mgmt.PutSwitchPreviewModel)
 		mgmt.PATCH("/quota-exceeded/switch-preview-model", s.mgmt.PutSwitchPreviewModel)
 
+		mgmt.GET("/api-key-quotas", s.mgmt.GetAPIKeyQuotas)
+		mgmt.GET("/projects", s.mgmt.GetProjects)
+		mgmt.GET("/admission", s.mgmt.GetAdmission)
+
+		mgmt.GET("/captures", s.mgmt.ListCaptures)
+		mgmt.GET("/captures/:id", s.mgmt.GetCapture)
+		mgmt.DELETE("/captures", s.mgmt.DeleteCaptures)
+
+		mgmt.GET("/sessions", s.mgmt.ListSessions)
+		mgmt.GET("/sessions/:ref", s.mgmt.GetSession)
+		mgmt.DELETE("/sessions/:ref", s.mgmt.DeleteSession)
+		mgmt.DELETE("/sessions", s.mgmt.DeleteSessions)
+
 		mgmt.GET("/api-keys", s.mgmt.GetAPIKeys)
 		mgmt.PUT("/api-keys", s.mgmt.PutAPIKeys)
 		mgmt.PATCH("/api-keys", s.mgmt.PatchAPIKeys)
@@ -516,6 +840,28 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.PATCH("/codex-api-key", s.mgmt.PatchCodexKey)
 		mgmt.DELETE("/codex-api-key", s.mgmt.DeleteCodexKey)
 
+		mgmt.GET("/bedrock-api-key", s.mgmt.GetBedrockKeys)
+		mgmt.PUT("/bedrock-api-key", s.mgmt.PutBedrockKeys)
+		mgmt.PATCH("/bedrock-api-key", s.mgmt.PatchBedrockKey)
+		mgmt.DELETE("/bedrock-api-key", s.mgmt.DeleteBedrockKey)
+
+		mgmt.GET("/azure-openai", s.mgmt.GetAzureOpenAI)
+		mgmt.PUT("/azure-openai", s.mgmt.PutAzureOpenAI)
+		mgmt.PATCH("/azure-openai", s.mgmt.PatchAzureOpenAI)
+		mgmt.DELETE("/azure-openai", s.mgmt.DeleteAzureOpenAI)
+		mgmt.GET("/vertex-ai", s.mgmt.GetVertexAI)
+		mgmt.PUT("/vertex-ai", s.mgmt.PutVertexAI)
+		mgmt.PATCH("/vertex-ai", s.mgmt.PatchVertexAI)
+		mgmt.DELETE("/vertex-ai", s.mgmt.DeleteVertexAI)
+		mgmt.GET("/xai-api-key", s.mgmt.GetXAIKeys)
+		mgmt.PUT("/xai-api-key", s.mgmt.PutXAIKeys)
+		mgmt.PATCH("/xai-api-key", s.mgmt.PatchXAIKey)
+		mgmt.DELETE("/xai-api-key", s.mgmt.DeleteXAIKey)
+		mgmt.GET("/local-backends", s.mgmt.GetLocalBackends)
+		mgmt.PUT("/local-backends", s.mgmt.PutLocalBackends)
+		mgmt.PATCH("/local-backends", s.mgmt.PatchLocalBackends)
+		mgmt.DELETE("/local-backends", s.mgmt.DeleteLocalBackends)
+
 		mgmt.GET("/openai-compatibility", s.mgmt.GetOpenAICompat)
 		mgmt.PUT("/openai-compatibility", s.mgmt.PutOpenAICompat)
 		mgmt.PATCH("/openai-compatibility", s.mgmt.PatchOpenAICompat)
@@ -526,6 +872,22 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.POST("/auth-files", s.mgmt.UploadAuthFile)
 		mgmt.DELETE("/auth-files", s.mgmt.DeleteAuthFile)
 
+		mgmt.GET("/accounts", s.mgmt.ListAccounts)
+		mgmt.GET("/accounts/status", s.mgmt.GetAccountsStatus)
+		mgmt.PATCH("/accounts/status", s.mgmt.PatchAccountStatus)
+		mgmt.GET("/accounts/health", s.mgmt.GetAccountsHealth)
+		mgmt.GET("/accounts/proxies", s.mgmt.GetAccountProxies)
+		mgmt.GET("/accounts/usage", s.mgmt.GetAccountsUsage)
+
+		// Account health and recent errors of the /_qs/dashboard page.
+		mgmt.GET("/dashboard/accounts", s.metricsHandler.GetAccounts)
+		mgmt.GET("/dashboard/errors", s.metricsHandler.GetRecentErrors)
+
+		mgmt.GET("/audit-log", s.mgmt.GetAuditLog)
+
+		mgmt.POST("/state/export", s.mgmt.ExportState)
+		mgmt.POST("/state/import", s.mgmt.ImportState)
+
 		mgmt.GET("/anthropic-auth-url", s.mgmt.RequestAnthropicToken)
 		mgmt.GET("/codex-auth-url", s.mgmt.RequestCodexToken)
 		mgmt.GET("/gemini-cli-auth-url", s.mgmt.RequestGeminiCLIToken)
@@ -573,8 +935,11 @@ func (s *Server) serveManagementControlPanel(c *gin.Context) {
 }
 
 func (s *Server) serveMetricsUI(c *gin.Context) {
-	filePath := filepath.Join("ui", "metrics.html")
-	c.File(filePath)
+	c.FileFromFS("metrics.html", http.FS(ui.Assets))
+}
+
+func (s *Server) serveDashboard(c *gin.Context) {
+	c.FileFromFS("dashboard.html", http.FS(ui.Assets))
 }
 
 func (s *Server) enableKeepAlive(timeout time.Duration, onTimeout func()) {
@@ -655,6 +1020,20 @@ func (s *Server) watchKeepAlive() {
 	}
 }
 
+// projectModelAllowed reports whether the project serving ctx may use model. It covers
+// models chosen after the request was admitted, such as realtime session updates.
+func (s *Server) projectModelAllowed(ctx context.Context, model string) bool {
+	name := coreexecutor.Project(ctx)
+	if name == "" {
+		return true
+	}
+	p, ok := s.projects.Get(name)
+	if !ok {
+		return true
+	}
+	return project.ModelAllowed(p, model)
+}
+
 // unifiedModelsHandler creates a unified handler for the /v1/models endpoint
 // that routes to different handlers based on the User-Agent header.
 // If User-Agent starts with "claude-cli", it routes to Claude handler,
@@ -682,8 +1061,73 @@ func (s *Server) unifiedModelsHandler(openaiHandler *openai.OpenAIAPIHandler, cl
 func (s *Server) Start() error {
 	log.Debugf("Starting API server on %s", s.server.Addr)
 
-	// Start the HTTP server.
-	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+	s.files.Start(context.Background())
+	s.batches.Start(context.Background())
+
+	if s.grpcServer != nil {
+		lis, err := upgrade.Listen(s.grpcAddr, s.cfg.ReusePort)
+		if err != nil {
+			return fmt.Errorf("failed to start gRPC management server: %v", err)
+		}
+		log.Infof("gRPC management server listening on %s", s.grpcAddr)
+		go func() {
+			if errServe := s.grpcServer.Serve(lis); errServe != nil {
+				log.Errorf("gRPC management server stopped: %v", errServe)
+			}
+		}()
+	}
+
+	if err := s.startManagementListener(); err != nil {
+		return err
+	}
+
+	if s.cfg.TLS.Enable {
+		tlsConfig, err := buildTLSConfig(s.cfg.TLS)
+		if err != nil {
+			return fmt.Errorf("failed to start HTTPS server: %v", err)
+		}
+		s.server.TLSConfig = tlsConfig
+	}
+
+	// The Unix socket serves plain HTTP, also when the TCP listener uses TLS.
+	unixLn, err := s.listenUnixSocket()
+	if err != nil {
+		return err
+	}
+	if unixLn != nil {
+		if s.cfg.UnixSocket.DisableTCP {
+			upgrade.Ready()
+			if err = s.server.Serve(unixLn); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return fmt.Errorf("failed to serve unix socket: %v", err)
+			}
+			return nil
+		}
+		go func() {
+			if errServe := s.server.Serve(unixLn); errServe != nil && !errors.Is(errServe, http.ErrServerClosed) {
+				log.Errorf("unix socket listener stopped: %v", errServe)
+			}
+		}()
+	}
+
+	if s.cfg.TLS.Enable {
+		ln, err := upgrade.Listen(s.server.Addr, s.cfg.ReusePort)
+		if err != nil {
+			return fmt.Errorf("failed to start HTTPS server: %v", err)
+		}
+		upgrade.Ready()
+		if err = s.server.ServeTLS(ln, "", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("failed to start HTTPS server: %v", err)
+		}
+		return nil
+	}
+
+	// Start the HTTP server. The listener may be inherited from the process this one upgrades.
+	ln, err := upgrade.Listen(s.server.Addr, s.cfg.ReusePort)
+	if err != nil {
+		return fmt.Errorf("failed to start HTTP server: %v", err)
+	}
+	upgrade.Ready()
+	if err = s.server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		return fmt.Errorf("failed to start HTTP server: %v", err)
 	}
 
@@ -708,15 +1152,79 @@ func (s *Server) Stop(ctx context.Context) error {
 		}
 	}
 
-	// Shutdown the HTTP server.
-	if err := s.server.Shutdown(ctx); err != nil {
+	if s.grpcServer != nil {
+		s.grpcServer.Stop(ctx)
+	}
+	s.batches.Stop()
+	s.files.Stop()
+
+	// Shutdown the HTTP server, letting in-flight responses drain.
+	if err := s.drain(ctx); err != nil {
 		return fmt.Errorf("failed to shutdown HTTP server: %v", err)
 	}
+	if s.managementServer != nil {
+		if err := s.managementServer.Shutdown(ctx); err != nil {
+			log.Errorf("failed to shutdown management listener: %v", err)
+		}
+	}
+
+	if err := s.accessLogger.Close(); err != nil {
+		log.Errorf("failed to close access log: %v", err)
+	}
+	if err := s.auditLogger.Close(); err != nil {
+		log.Errorf("failed to close audit log: %v", err)
+	}
+	if err := s.statsd.Close(); err != nil {
+		log.Errorf("failed to close statsd connection: %v", err)
+	}
 
 	log.Debug("API server stopped")
 	return nil
 }
 
+// drain stops accepting requests and waits up to the configured drain timeout for the
+// in-flight ones, logging how many streams remain. Connections still open when the timeout
+// expires are closed.
+func (s *Server) drain(ctx context.Context) error {
+	timeout := DrainTimeout(s.cfg)
+	drainCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- s.server.Shutdown(drainCtx) }()
+	if streams := s.handlers.ActiveStreams(); streams > 0 {
+		log.Infof("draining %d in-flight stream(s), waiting up to %s", streams, timeout)
+	}
+	ticker := time.NewTicker(drainLogInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-done:
+			if err == nil {
+				return nil
+			}
+			if !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) {
+				return err
+			}
+			log.Warnf("drain timeout reached, closing %d in-flight stream(s)", s.handlers.ActiveStreams())
+			if errClose := s.server.Close(); errClose != nil {
+				return errClose
+			}
+			return nil
+		case <-ticker.C:
+			log.Infof("draining: %d stream(s) still in flight", s.handlers.ActiveStreams())
+		}
+	}
+}
+
+// DrainTimeout returns how long shutdown waits for in-flight responses under cfg.
+func DrainTimeout(cfg *config.Config) time.Duration {
+	if cfg != nil && cfg.Shutdown.DrainTimeout > 0 {
+		return cfg.Shutdown.DrainTimeout
+	}
+	return defaultDrainTimeout
+}
+
 // corsMiddleware returns a Gin middleware handler that adds CORS headers
 // to every response, allowing cross-origin requests.
 //
@@ -746,6 +1254,13 @@ func (s *Server) applyAccessConfig(oldCfg, newCfg *config.Config) {
 	}
 }
 
+// batchAuthorizer checks the principals of stored batches against the access settings of cfg.
+func batchAuthorizer(cfg *config.Config) func(sdkaccess.Result) bool {
+	return func(principal sdkaccess.Result) bool {
+		return access.PrincipalAllowed(cfg, principal)
+	}
+}
+
 // UpdateClients updates the server's client list and configuration.
 // This method is called when the configuration or authentication tokens change.
 //
@@ -753,6 +1268,9 @@ func (s *Server) applyAccessConfig(oldCfg, newCfg *config.Config) {
 //   - clients: The new slice of AI service clients
 //   - cfg: The new application configuration
 func (s *Server) UpdateClients(cfg *config.Config) {
+	s.updateMu.Lock()
+	defer s.updateMu.Unlock()
+
 	// Reconstruct old config from YAML snapshot to avoid reference sharing issues
 	var oldCfg *config.Config
 	if len(s.oldConfigYaml) > 0 {
@@ -777,6 +1295,46 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 		}
 	}
 
+	if oldCfg != nil && !reflect.DeepEqual(oldCfg.Tracing, cfg.Tracing) {
+		if err := tracing.Setup(context.Background(), cfg.Tracing); err != nil {
+			log.Errorf("failed to reconfigure tracing: %v", err)
+		} else {
+			log.Debugf("tracing configuration updated (enabled=%t)", cfg.Tracing.Enable)
+		}
+	}
+
+	if oldCfg != nil && !reflect.DeepEqual(oldCfg.StatsD, cfg.StatsD) {
+		if err := s.statsd.Configure(cfg.StatsD); err != nil {
+			log.Errorf("failed to reconfigure statsd: %v", err)
+		} else {
+			log.Debugf("statsd configuration updated (enabled=%t)", cfg.StatsD.Enable)
+		}
+	}
+
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.AccessLog, cfg.AccessLog) {
+		if err := s.accessLogger.Configure(cfg.AccessLog); err != nil {
+			log.Errorf("failed to reconfigure access log: %v", err)
+		} else {
+			log.Debugf("access log configuration updated (enabled=%t)", cfg.AccessLog.Enable)
+		}
+	}
+
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.AuditLog, cfg.AuditLog) {
+		if err := s.auditLogger.Configure(cfg.AuditLog); err != nil {
+			log.Errorf("failed to reconfigure audit log: %v", err)
+		} else {
+			log.Debugf("audit log configuration updated (enabled=%t)", cfg.AuditLog.Enable)
+		}
+	}
+
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.BodyCapture, cfg.BodyCapture) {
+		if err := s.captureRecorder.Configure(cfg.BodyCapture); err != nil {
+			log.Errorf("failed to reconfigure body capture: %v", err)
+		} else {
+			log.Debugf("body capture configuration updated (enabled=%t)", cfg.BodyCapture.Enable)
+		}
+	}
+
 	if oldCfg != nil && oldCfg.LoggingToFile != cfg.LoggingToFile {
 		if err := logging.ConfigureLogOutput(cfg.LoggingToFile); err != nil {
 			log.Errorf("failed to reconfigure log output: %v", err)
@@ -785,6 +1343,11 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 		}
 	}
 
+	if oldCfg != nil && oldCfg.UsageRetention != cfg.UsageRetention {
+		usage.GetRequestStatistics().SetRetention(usage.NewRetentionPolicy(cfg.UsageRetention))
+		log.Debugf("usage retention updated to raw %s, hourly %s, daily %s", cfg.UsageRetention.Raw, cfg.UsageRetention.Hourly, cfg.UsageRetention.Daily)
+	}
+
 	if oldCfg == nil || oldCfg.UsageStatisticsEnabled != cfg.UsageStatisticsEnabled {
 		usage.SetStatisticsEnabled(cfg.UsageStatisticsEnabled)
 		if oldCfg != nil {
@@ -803,6 +1366,11 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 		}
 	}
 
+	if oldCfg == nil || oldCfg.ProxyURL != cfg.ProxyURL || oldCfg.MediaFetch != cfg.MediaFetch {
+		util.ConfigureMediaFetch(&cfg.SDKConfig)
+	}
+	transport.Configure(cfg.UpstreamTransport)
+
 	// Update log level dynamically when debug flag changes
 	if oldCfg == nil || oldCfg.Debug != cfg.Debug {
 		util.SetLogLevel(cfg)
@@ -815,9 +1383,9 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 
 	prevSecretEmpty := true
 	if oldCfg != nil {
-		prevSecretEmpty = oldCfg.RemoteManagement.SecretKey == ""
+		prevSecretEmpty = !hasManagementKey(oldCfg)
 	}
-	newSecretEmpty := cfg.RemoteManagement.SecretKey == ""
+	newSecretEmpty := !hasManagementKey(cfg)
 	if s.envManagementSecret {
 		s.registerManagementRoutes()
 		if s.managementRoutesEnabled.CompareAndSwap(false, true) {
@@ -846,6 +1414,29 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 	}
 
 	s.applyAccessConfig(oldCfg, cfg)
+	s.metricsHandler.SetPricing(usage.NewPriceTable(cfg.Pricing))
+	s.quotaManager.SetLimits(cfg.APIKeyQuotas)
+	s.projects.SetProjects(cfg.Projects)
+	s.rateLimiter.SetLimits(cfg.RateLimits)
+	s.guardrails.SetLimits(cfg.Guardrails)
+	s.moderator.Configure(cfg.Moderation)
+	s.hooks.Configure(cfg.Hooks)
+	if oldCfg == nil || oldCfg.Sessions != cfg.Sessions || oldCfg.Redis != cfg.Redis {
+		if err := s.sessions.Configure(cfg); err != nil {
+			log.Errorf("failed to reconfigure sessions: %v", err)
+		}
+	}
+	s.piiRedactor.Configure(cfg.PIIRedaction)
+	s.systemPrompts.Configure(cfg.SystemPrompts)
+	s.prompts.Configure(cfg.Prompts)
+	s.compressor.Configure(cfg.Compression)
+	s.bodyLimiter.Configure(cfg.RequestLimits)
+	s.shadow.Configure(cfg.Shadow)
+	s.admission.Configure(cfg.Admission)
+	s.files.Configure(filesConfig(cfg))
+	s.batches.SetAuthorizer(batchAuthorizer(cfg))
+	s.batches.Configure(cfg.Batch)
+	s.accountTracker.SetLimits(cfg.AccountQuotas)
 	s.cfg = cfg
 	s.wsAuthEnabled.Store(cfg.WebsocketAuth)
 	if oldCfg != nil && s.wsAuthChanged != nil && oldCfg.WebsocketAuth != cfg.WebsocketAuth {
@@ -877,6 +1468,12 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 	glAPIKeyCount := len(cfg.GlAPIKey)
 	claudeAPIKeyCount := len(cfg.ClaudeKey)
 	codexAPIKeyCount := len(cfg.CodexKey)
+	bedrockKeyCount := len(cfg.BedrockKey)
+	azureOpenAICount := len(cfg.AzureOpenAI)
+	vertexAICount := len(cfg.VertexAI)
+	xaiKeyCount := len(cfg.XAIKey)
+	localBackendCount := len(cfg.LocalBackends)
+	providerPluginCount := len(cfg.ProviderPlugins)
 	openAICompatCount := 0
 	for i := range cfg.OpenAICompatibility {
 		entry := cfg.OpenAICompatibility[i]
@@ -887,14 +1484,20 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 		openAICompatCount += len(entry.APIKeys)
 	}
 
-	total := authFiles + glAPIKeyCount + claudeAPIKeyCount + codexAPIKeyCount + openAICompatCount
-	fmt.Printf("server clients and configuration updated: %d clients (%d auth files + %d GL API keys + %d Claude API keys + %d Codex keys + %d OpenAI-compat)\n",
+	total := authFiles + glAPIKeyCount + claudeAPIKeyCount + codexAPIKeyCount + bedrockKeyCount + azureOpenAICount + vertexAICount + xaiKeyCount + openAICompatCount + localBackendCount + providerPluginCount
+	fmt.Printf("server clients and configuration updated: %d clients (%d auth files + %d GL API keys + %d Claude API keys + %d Codex keys + %d Bedrock keys + %d Azure OpenAI + %d Vertex AI + %d xAI keys + %d OpenAI-compat + %d local + %d plugins)\n",
 		total,
 		authFiles,
 		glAPIKeyCount,
 		claudeAPIKeyCount,
 		codexAPIKeyCount,
+		bedrockKeyCount,
+		azureOpenAICount,
+		vertexAICount,
+		xaiKeyCount,
 		openAICompatCount,
+		localBackendCount,
+		providerPluginCount,
 	)
 }
 
@@ -912,6 +1515,16 @@ func (s *Server) SetWebsocketAuthChangeHandler(fn func(bool, bool)) {
 // it allows all requests (legacy behaviour).
 func AuthMiddleware(manager *sdkaccess.Manager) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// Requests of a batch run as the client that created it.
+		if principal, ok := batch.PrincipalFromContext(c.Request.Context()); ok {
+			c.Set("apiKey", principal.Principal)
+			c.Set("accessProvider", principal.Provider)
+			if len(principal.Metadata) > 0 {
+				c.Set("accessMetadata", principal.Metadata)
+			}
+			c.Next()
+			return
+		}
 		if manager == nil {
 			c.Next()
 			return
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// buildTLSConfig loads the server certificate and, for mutual TLS, the client CA bundle.
+func buildTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	if strings.TrimSpace(cfg.Cert) == "" || strings.TrimSpace(cfg.Key) == "" {
+		return nil, errors.New("tls: cert and key are required")
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.Cert, cfg.Key)
+	if err != nil {
+		return nil, fmt.Errorf("tls: failed to load server certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+
+	mode := strings.ToLower(strings.TrimSpace(cfg.ClientAuth.Mode))
+	switch mode {
+	case "", "none":
+		if len(cfg.ClientAuth.Identities) > 0 {
+			return nil, errors.New("tls: client-auth.identities require client-auth.mode require or optional")
+		}
+		return tlsConfig, nil
+	case "require":
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	case "optional":
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	default:
+		return nil, fmt.Errorf("tls: unsupported client-auth.mode %q", cfg.ClientAuth.Mode)
+	}
+	if strings.TrimSpace(cfg.ClientAuth.CAFile) == "" {
+		return nil, errors.New("tls: client-auth.ca-file is required to verify client certificates")
+	}
+	bundle, err := os.ReadFile(cfg.ClientAuth.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("tls: failed to read client CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bundle) {
+		return nil, errors.New("tls: client CA bundle contains no certificates")
+	}
+	tlsConfig.ClientCAs = pool
+	return tlsConfig, nil
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/upgrade"
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultUnixSocketMode lets the owner and group of the proxy connect to its socket.
+const defaultUnixSocketMode os.FileMode = 0o660
+
+// loopbackAddr is the remote address reported for connections over the Unix socket.
+var loopbackAddr = &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+
+// listenUnixSocket opens the Unix domain socket of unix-socket.path, or returns nil when
+// none is configured.
+func (s *Server) listenUnixSocket() (net.Listener, error) {
+	cfg := s.cfg.UnixSocket
+	path := strings.TrimSpace(cfg.Path)
+	if path == "" {
+		return nil, nil
+	}
+	mode, err := unixSocketMode(cfg)
+	if err != nil {
+		return nil, err
+	}
+	ln, err := upgrade.ListenUnix(path, mode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on unix socket %s: %v", path, err)
+	}
+	log.Infof("API server listening on unix socket %s", path)
+	return localListener{Listener: ln}, nil
+}
+
+// unixSocketMode parses unix-socket.mode as an octal file mode.
+func unixSocketMode(cfg config.UnixSocketConfig) (os.FileMode, error) {
+	raw := strings.TrimSpace(cfg.Mode)
+	if raw == "" {
+		return defaultUnixSocketMode, nil
+	}
+	mode, err := strconv.ParseUint(raw, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("invalid unix-socket.mode %q, expected an octal mode such as 0660", cfg.Mode)
+	}
+	return os.FileMode(mode), nil
+}
+
+// localListener reports the peers of a Unix socket as the loopback address. They are
+// processes on the same host, and the localhost-only checks of the management API and the
+// Gemini CLI endpoint treat them as such.
+type localListener struct {
+	net.Listener
+}
+
+func (l localListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return localConn{Conn: conn}, nil
+}
+
+type localConn struct {
+	net.Conn
+}
+
+func (localConn) RemoteAddr() net.Addr { return loopbackAddr }
@@ -44,6 +44,9 @@ var (
 // It encapsulates the logic for obtaining, storing, and refreshing authentication tokens
 // for Google's Gemini AI services.
 type GeminiAuth struct {
+	// ReadCallback, when set, replaces the local callback server for headless logins: it is
+	// given the authorization URL and returns the redirect the user completed elsewhere.
+	ReadCallback func(authURL string) (util.OAuthCallback, error)
 }
 
 // NewGeminiAuth creates a new instance of GeminiAuth.
@@ -210,6 +213,10 @@ func (g *GeminiAuth) createTokenStorage(ctx context.Context, config *oauth2.Conf
 //   - *oauth2.Token: The OAuth2 token obtained from the authorization flow
 //   - error: An error if the token acquisition fails, nil otherwise
 func (g *GeminiAuth) getTokenFromWeb(ctx context.Context, config *oauth2.Config, noBrowser ...bool) (*oauth2.Token, error) {
+	if g.ReadCallback != nil {
+		return g.getTokenFromPaste(ctx, config)
+	}
+
 	// Use a channel to pass the authorization code from the HTTP handler to the main function.
 	codeChan := make(chan string)
 	errChan := make(chan error)
@@ -299,3 +306,26 @@ func (g *GeminiAuth) getTokenFromWeb(ctx context.Context, config *oauth2.Config,
 	fmt.Println("Authentication successful.")
 	return token, nil
 }
+
+// getTokenFromPaste runs the authorization without a local callback server, reading the
+// redirect through ReadCallback.
+func (g *GeminiAuth) getTokenFromPaste(ctx context.Context, config *oauth2.Config) (*oauth2.Token, error) {
+	config.RedirectURL = "http://localhost:8085/oauth2callback"
+	authURL := config.AuthCodeURL("state-token", oauth2.AccessTypeOffline, oauth2.SetAuthURLParam("prompt", "consent"))
+	result, err := g.ReadCallback(authURL)
+	if err != nil {
+		return nil, err
+	}
+	if result.Error != "" {
+		return nil, fmt.Errorf("authentication failed via callback: %s", result.Error)
+	}
+	if result.State != "state-token" {
+		return nil, fmt.Errorf("authentication failed: state mismatch")
+	}
+	token, err := config.Exchange(ctx, result.Code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange token: %w", err)
+	}
+	fmt.Println("Authentication successful.")
+	return token, nil
+}
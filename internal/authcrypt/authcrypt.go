@@ -0,0 +1,224 @@
+// Package authcrypt encrypts auth files at rest with AES-256-GCM. Encrypted files hold a
+// JSON envelope, so stores that keep auth files as JSON documents work unchanged, and
+// plaintext files are still read so existing auth directories keep loading until they
+// are migrated.
+package authcrypt
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+	"golang.org/x/crypto/scrypt"
+)
+
+// KeyEnv is the environment variable consulted when the configuration names no key.
+const KeyEnv = "AUTH_ENCRYPTION_KEY"
+
+// envelopeField marks an encrypted auth file and names the encryption scheme.
+const (
+	envelopeField   = "cliproxy_encrypted"
+	envelopeVersion = "aes-256-gcm/v1"
+)
+
+// passphraseSalt is fixed so that a passphrase always derives the same key.
+const passphraseSalt = "cliproxy-auth-encryption"
+
+const keyCommandTimeout = 30 * time.Second
+
+// ErrNoKey is returned when an encrypted auth file is read without a configured key.
+var ErrNoKey = errors.New("authcrypt: auth file is encrypted but no encryption key is configured")
+
+type envelope struct {
+	Version string `json:"cliproxy_encrypted"`
+	KeyID   string `json:"key_id"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+type keyState struct {
+	aead cipher.AEAD
+	id   string
+}
+
+var current atomic.Pointer[keyState]
+
+// Configure resolves the encryption key. Without a key, files are written in plaintext.
+//
+// Parameters:
+//   - cfg: The auth encryption configuration
+//
+// Returns:
+//   - error: An error if a key source is set but cannot be read
+func Configure(cfg config.AuthEncryption) error {
+	secret, err := resolveKey(cfg)
+	if err != nil {
+		return err
+	}
+	if secret == "" {
+		current.Store(nil)
+		return nil
+	}
+	key, err := deriveKey(secret)
+	if err != nil {
+		return err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return fmt.Errorf("authcrypt: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return fmt.Errorf("authcrypt: %w", err)
+	}
+	sum := sha256.Sum256(key)
+	current.Store(&keyState{aead: aead, id: hex.EncodeToString(sum[:4])})
+	return nil
+}
+
+// Enabled reports whether auth files are encrypted when written.
+func Enabled() bool { return current.Load() != nil }
+
+// IsEncrypted reports whether data is an encrypted auth file.
+func IsEncrypted(data []byte) bool {
+	return gjson.GetBytes(data, envelopeField).Type == gjson.String
+}
+
+// Seal encrypts an auth file's content when a key is configured and returns it unchanged
+// otherwise or when it is already encrypted.
+func Seal(plain []byte) ([]byte, error) {
+	state := current.Load()
+	if state == nil || len(plain) == 0 || IsEncrypted(plain) {
+		return plain, nil
+	}
+	nonce := make([]byte, state.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("authcrypt: generate nonce: %w", err)
+	}
+	return json.Marshal(envelope{
+		Version: envelopeVersion,
+		KeyID:   state.id,
+		Nonce:   nonce,
+		Data:    state.aead.Seal(nil, nonce, plain, nil),
+	})
+}
+
+// Open decrypts an encrypted auth file's content and returns plaintext content unchanged.
+func Open(data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return data, nil
+	}
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("authcrypt: invalid envelope: %w", err)
+	}
+	if env.Version != envelopeVersion {
+		return nil, fmt.Errorf("authcrypt: unsupported encryption %q", env.Version)
+	}
+	state := current.Load()
+	if state == nil {
+		return nil, ErrNoKey
+	}
+	if env.KeyID != state.id {
+		return nil, fmt.Errorf("authcrypt: auth file was encrypted with a different key (key id %s, configured %s)", env.KeyID, state.id)
+	}
+	if len(env.Nonce) != state.aead.NonceSize() {
+		return nil, fmt.Errorf("authcrypt: invalid nonce")
+	}
+	plain, err := state.aead.Open(nil, env.Nonce, env.Data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("authcrypt: decrypt: %w", err)
+	}
+	return plain, nil
+}
+
+// ReadFile reads an auth file and decrypts it if needed.
+func ReadFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Open(data)
+}
+
+// WriteFile writes an auth file, encrypting it when a key is configured.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	sealed, err := Seal(data)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, sealed, perm)
+}
+
+func resolveKey(cfg config.AuthEncryption) (string, error) {
+	if key := strings.TrimSpace(cfg.Key); key != "" {
+		return key, nil
+	}
+	if path := strings.TrimSpace(cfg.KeyFile); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("authcrypt: read key file: %w", err)
+		}
+		key := strings.TrimSpace(string(data))
+		if key == "" {
+			return "", fmt.Errorf("authcrypt: key file %s is empty", path)
+		}
+		return key, nil
+	}
+	if command := strings.TrimSpace(cfg.KeyCommand); command != "" {
+		return runKeyCommand(command)
+	}
+	return strings.TrimSpace(os.Getenv(KeyEnv)), nil
+}
+
+func runKeyCommand(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), keyCommandTimeout)
+	defer cancel()
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("authcrypt: key command failed: %w", err)
+	}
+	key := strings.TrimSpace(string(out))
+	if key == "" {
+		return "", fmt.Errorf("authcrypt: key command printed no key")
+	}
+	return key, nil
+}
+
+// deriveKey turns the configured secret into a 32-byte key. Encoded 32-byte keys are used
+// directly; anything else is a passphrase stretched with scrypt.
+func deriveKey(secret string) ([]byte, error) {
+	if raw, err := base64.StdEncoding.DecodeString(secret); err == nil && len(raw) == 32 {
+		return raw, nil
+	}
+	if raw, err := hex.DecodeString(secret); err == nil && len(raw) == 32 {
+		return raw, nil
+	}
+	key, err := scrypt.Key([]byte(secret), []byte(passphraseSalt), 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, fmt.Errorf("authcrypt: derive key: %w", err)
+	}
+	return key, nil
+}
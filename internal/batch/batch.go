@@ -0,0 +1,502 @@
+// Package batch implements the OpenAI compatible Batch API. Clients upload JSONL files of
+// requests through the Files API, create batches over them and collect the results later.
+// Batches run in the background against the proxy's own endpoints, so every request passes
+// through the same authentication, project, rate limit, quota and admission checks as
+// interactive traffic of the key that created the batch. Batch state and partial results
+// are kept on disk and running batches resume after a restart.
+package batch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/filestore"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultConcurrency = 4
+	defaultMaxAttempts = 5
+	defaultRetention   = 7 * 24 * time.Hour
+	// completionWindow is the only completion window the API accepts.
+	completionWindow = "24h"
+	// maxRequests is the number of requests a batch may hold.
+	maxRequests = 50000
+	// janitorInterval is how often expired batches are removed.
+	janitorInterval = time.Hour
+)
+
+// Batch statuses.
+const (
+	StatusValidating = "validating"
+	StatusFailed     = "failed"
+	StatusInProgress = "in_progress"
+	StatusFinalizing = "finalizing"
+	StatusCompleted  = "completed"
+	StatusExpired    = "expired"
+	StatusCancelling = "cancelling"
+	StatusCancelled  = "cancelled"
+)
+
+// Endpoints lists the endpoints batch requests may target.
+var Endpoints = []string{"/v1/chat/completions", "/v1/completions", "/v1/embeddings", "/v1/responses"}
+
+var (
+	// ErrNotFound is returned for batches that do not exist or belong to another client.
+	ErrNotFound = errors.New("batch not found")
+	// ErrNotCancellable is returned when cancelling a batch that already finished.
+	ErrNotCancellable = errors.New("batch cannot be cancelled")
+)
+
+// Batch is a batch in the shape of the OpenAI batches API.
+type Batch struct {
+	ID               string            `json:"id"`
+	Object           string            `json:"object"`
+	Endpoint         string            `json:"endpoint"`
+	Errors           *Errors           `json:"errors,omitempty"`
+	InputFileID      string            `json:"input_file_id"`
+	CompletionWindow string            `json:"completion_window"`
+	Status           string            `json:"status"`
+	OutputFileID     string            `json:"output_file_id,omitempty"`
+	ErrorFileID      string            `json:"error_file_id,omitempty"`
+	CreatedAt        int64             `json:"created_at"`
+	InProgressAt     int64             `json:"in_progress_at,omitempty"`
+	ExpiresAt        int64             `json:"expires_at"`
+	FinalizingAt     int64             `json:"finalizing_at,omitempty"`
+	CompletedAt      int64             `json:"completed_at,omitempty"`
+	FailedAt         int64             `json:"failed_at,omitempty"`
+	ExpiredAt        int64             `json:"expired_at,omitempty"`
+	CancellingAt     int64             `json:"cancelling_at,omitempty"`
+	CancelledAt      int64             `json:"cancelled_at,omitempty"`
+	RequestCounts    RequestCounts     `json:"request_counts"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+}
+
+// RequestCounts counts the requests of a batch by outcome.
+type RequestCounts struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+}
+
+// Errors lists the validation errors of a failed batch.
+type Errors struct {
+	Object string       `json:"object"`
+	Data   []ErrorEntry `json:"data"`
+}
+
+// ErrorEntry is one validation error; Line is the 1-based line of the input file.
+type ErrorEntry struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Line    int    `json:"line,omitempty"`
+}
+
+// batchRecord is the stored form of a batch. Principal is the identity the requests of
+// the batch run as.
+type batchRecord struct {
+	Batch
+	Principal sdkaccess.Result `json:"principal"`
+}
+
+// terminal reports whether the batch reached a final status.
+func (b *Batch) terminal() bool {
+	switch b.Status {
+	case StatusFailed, StatusCompleted, StatusExpired, StatusCancelled:
+		return true
+	}
+	return false
+}
+
+// finishedAt returns when a terminal batch finished.
+func (b *Batch) finishedAt() int64 {
+	return max(b.CompletedAt, b.FailedAt, b.ExpiredAt, b.CancelledAt)
+}
+
+// CreateRequest holds the parameters of a new batch.
+type CreateRequest struct {
+	InputFileID      string            `json:"input_file_id"`
+	Endpoint         string            `json:"endpoint"`
+	CompletionWindow string            `json:"completion_window"`
+	Metadata         map[string]string `json:"metadata"`
+}
+
+// ValidationError is returned for malformed batch parameters; the message is meant for the client.
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string { return e.Message }
+
+// Page is one page of a batch listing.
+type Page struct {
+	Object  string  `json:"object"`
+	Data    []Batch `json:"data"`
+	FirstID string  `json:"first_id,omitempty"`
+	LastID  string  `json:"last_id,omitempty"`
+	HasMore bool    `json:"has_more"`
+}
+
+// Manager stores and runs batches. It is safe for concurrent use.
+type Manager struct {
+	mu      sync.Mutex
+	cfg     config.BatchConfig
+	store   *store
+	files   *filestore.Store
+	handler http.Handler
+	// authorize re-checks the principal of a batch before each of its requests.
+	authorize func(sdkaccess.Result) bool
+	batches   map[string]*batchRecord
+	// running holds every batch being executed.
+	running map[string]*runHandle
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// runHandle stops one execution of a batch.
+type runHandle struct {
+	cancel context.CancelFunc
+}
+
+// NewManager creates a batch manager for cfg that reads inputs from and publishes results
+// to files. Stored batches are loaded by Start.
+func NewManager(cfg config.BatchConfig, files *filestore.Store) *Manager {
+	cfg = normalize(cfg)
+	m := &Manager{
+		cfg:     cfg,
+		store:   &store{dir: cfg.Dir},
+		files:   files,
+		batches: make(map[string]*batchRecord),
+		running: make(map[string]*runHandle),
+	}
+	files.SetInUse(m.inputInUse)
+	return m
+}
+
+func normalize(cfg config.BatchConfig) config.BatchConfig {
+	cfg.Dir = strings.TrimSpace(cfg.Dir)
+	if cfg.Dir == "" {
+		cfg.Dir = "batches"
+		if base := util.WritablePath(); base != "" {
+			cfg.Dir = filepath.Join(base, "batches")
+		}
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = defaultConcurrency
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultMaxAttempts
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = defaultRetention
+	}
+	return cfg
+}
+
+// SetHandler sets the HTTP handler batch requests are dispatched to, normally the
+// server's own engine.
+func (m *Manager) SetHandler(handler http.Handler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handler = handler
+}
+
+// SetAuthorizer sets the check the principal of a batch must pass before each request is
+// dispatched. Requests of a batch whose key was revoked since it was created fail instead
+// of running as that key.
+func (m *Manager) SetAuthorizer(authorize func(sdkaccess.Result) bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.authorize = authorize
+}
+
+// Configure applies cfg. The directory is kept from startup. Disabling the Batch API
+// pauses running batches, which resume once it is enabled again.
+func (m *Manager) Configure(cfg config.BatchConfig) {
+	cfg = normalize(cfg)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cfg.Dir = m.cfg.Dir
+	m.cfg = cfg
+	if !cfg.Enable {
+		for id, handle := range m.running {
+			handle.cancel()
+			delete(m.running, id)
+		}
+		return
+	}
+	m.resumeLocked()
+}
+
+// Enabled reports whether the Batch API is on.
+func (m *Manager) Enabled() bool {
+	if m == nil {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.cfg.Enable
+}
+
+// Start loads the stored batches, resumes unfinished ones while the Batch API is enabled
+// and removes expired batches periodically until ctx is done.
+func (m *Manager) Start(parent context.Context) {
+	batches, err := m.store.load()
+	if err != nil {
+		log.Warnf("batch: failed to load stored batches: %v", err)
+	}
+	ctx, cancel := context.WithCancel(parent)
+	m.mu.Lock()
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.ctx, m.cancel = ctx, cancel
+	for _, rec := range batches {
+		m.batches[rec.ID] = rec
+	}
+	if m.cfg.Enable {
+		m.resumeLocked()
+	}
+	m.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(janitorInterval)
+		defer ticker.Stop()
+		for {
+			m.cleanup(time.Now())
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop pauses running batches; they resume on the next start.
+func (m *Manager) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cancel != nil {
+		m.cancel()
+		m.cancel = nil
+	}
+	for id, handle := range m.running {
+		handle.cancel()
+		delete(m.running, id)
+	}
+}
+
+// resumeLocked starts every unfinished batch that is not running yet.
+func (m *Manager) resumeLocked() {
+	if m.ctx == nil || m.ctx.Err() != nil {
+		return
+	}
+	for id, rec := range m.batches {
+		if _, ok := m.running[id]; ok || rec.terminal() {
+			continue
+		}
+		ctx, cancel := context.WithCancel(m.ctx)
+		handle := &runHandle{cancel: cancel}
+		m.running[id] = handle
+		go m.run(ctx, id, handle)
+	}
+}
+
+// CreateBatch creates a batch over an input file of principal and starts it.
+func (m *Manager) CreateBatch(principal sdkaccess.Result, req CreateRequest) (Batch, error) {
+	if strings.TrimSpace(req.InputFileID) == "" {
+		return Batch{}, &ValidationError{Message: "input_file_id is required"}
+	}
+	if !supportedEndpoint(req.Endpoint) {
+		return Batch{}, &ValidationError{Message: "endpoint must be one of " + strings.Join(Endpoints, ", ")}
+	}
+	if req.CompletionWindow != completionWindow {
+		return Batch{}, &ValidationError{Message: "completion_window must be \"24h\""}
+	}
+	file, err := m.files.Get(principal.Principal, req.InputFileID)
+	if err != nil {
+		return Batch{}, &ValidationError{Message: "input file " + req.InputFileID + " not found"}
+	}
+	if file.Purpose != filestore.PurposeBatch {
+		return Batch{}, &ValidationError{Message: "input file must have purpose \"batch\""}
+	}
+
+	now := time.Now()
+	rec := &batchRecord{
+		Batch: Batch{
+			ID:               "batch_" + newID(),
+			Object:           "batch",
+			Endpoint:         req.Endpoint,
+			InputFileID:      req.InputFileID,
+			CompletionWindow: completionWindow,
+			Status:           StatusValidating,
+			CreatedAt:        now.Unix(),
+			ExpiresAt:        now.Add(24 * time.Hour).Unix(),
+			Metadata:         req.Metadata,
+		},
+		Principal: principal,
+	}
+	if err = m.store.saveBatch(rec); err != nil {
+		return Batch{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.batches[rec.ID] = rec
+	if m.cfg.Enable {
+		m.resumeLocked()
+	}
+	return rec.Batch, nil
+}
+
+// Batch returns a batch of owner.
+func (m *Manager) Batch(owner, id string) (Batch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec, ok := m.batches[id]
+	if !ok || rec.Principal.Principal != owner {
+		return Batch{}, ErrNotFound
+	}
+	return rec.Batch, nil
+}
+
+// Batches lists the batches of owner, newest first, starting after the batch with ID
+// after when it is set.
+func (m *Manager) Batches(owner, after string, limit int) Page {
+	m.mu.Lock()
+	all := make([]Batch, 0)
+	for _, rec := range m.batches {
+		if rec.Principal.Principal == owner {
+			all = append(all, rec.Batch)
+		}
+	}
+	m.mu.Unlock()
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].CreatedAt != all[j].CreatedAt {
+			return all[i].CreatedAt > all[j].CreatedAt
+		}
+		return all[i].ID > all[j].ID
+	})
+	if after != "" {
+		for i, b := range all {
+			if b.ID == after {
+				all = all[i+1:]
+				break
+			}
+		}
+	}
+	page := Page{Object: "list", Data: all}
+	if limit > 0 && len(all) > limit {
+		page.Data = all[:limit]
+		page.HasMore = true
+	}
+	if len(page.Data) > 0 {
+		page.FirstID = page.Data[0].ID
+		page.LastID = page.Data[len(page.Data)-1].ID
+	}
+	return page
+}
+
+// CancelBatch cancels a batch of owner. Requests in flight finish; results gathered so
+// far are kept in the output and error files.
+func (m *Manager) CancelBatch(owner, id string) (Batch, error) {
+	m.mu.Lock()
+	rec, ok := m.batches[id]
+	if !ok || rec.Principal.Principal != owner {
+		m.mu.Unlock()
+		return Batch{}, ErrNotFound
+	}
+	if rec.terminal() {
+		m.mu.Unlock()
+		return Batch{}, ErrNotCancellable
+	}
+	if rec.Status != StatusCancelling {
+		rec.Status = StatusCancelling
+		rec.CancellingAt = time.Now().Unix()
+	}
+	handle, running := m.running[id]
+	snapshot := *rec
+	m.mu.Unlock()
+
+	if err := m.store.saveBatch(&snapshot); err != nil {
+		log.Warnf("batch: failed to save batch %s: %v", id, err)
+	}
+	if running {
+		handle.cancel()
+	} else {
+		// Paused batches are finalized right away.
+		m.finalize(id, StatusCancelled)
+	}
+	return m.Batch(owner, id)
+}
+
+// inputInUse reports whether a file is the input of an unfinished batch.
+func (m *Manager) inputInUse(fileID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, rec := range m.batches {
+		if rec.InputFileID == fileID && !rec.terminal() {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanup removes finished batches older than the retention period. Their result files
+// expire on their own.
+func (m *Manager) cleanup(now time.Time) {
+	m.mu.Lock()
+	cutoff := now.Add(-m.cfg.Retention).Unix()
+	var expired []string
+	for id, rec := range m.batches {
+		if rec.terminal() && rec.finishedAt() < cutoff {
+			expired = append(expired, id)
+			delete(m.batches, id)
+		}
+	}
+	m.mu.Unlock()
+	for _, id := range expired {
+		m.store.removeBatch(id)
+	}
+	if len(expired) > 0 {
+		log.Debugf("batch: removed %d expired batches", len(expired))
+	}
+}
+
+func supportedEndpoint(endpoint string) bool {
+	for _, e := range Endpoints {
+		if e == endpoint {
+			return true
+		}
+	}
+	return false
+}
+
+func newID() string {
+	return strings.ReplaceAll(uuid.NewString(), "-", "")
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a context carrying the identity a batch request runs as.
+func WithPrincipal(ctx context.Context, principal sdkaccess.Result) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the identity attached by WithPrincipal. Requests carrying
+// it were created by the batch runner, which checks the principal against the current
+// access settings before each request, and are already authenticated.
+func PrincipalFromContext(ctx context.Context) (sdkaccess.Result, bool) {
+	principal, ok := ctx.Value(principalKey{}).(sdkaccess.Result)
+	return principal, ok
+}
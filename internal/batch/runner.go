@@ -0,0 +1,543 @@
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/filestore"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	// maxValidationErrors caps the errors reported for an invalid input file.
+	maxValidationErrors = 100
+	// maxRetryDelay caps how long a batch pauses after a throttled request.
+	maxRetryDelay = time.Minute
+	// saveInterval throttles how often progress is persisted.
+	saveInterval = time.Second
+)
+
+// errPrincipalRevoked fails the requests of a batch whose key is no longer accepted.
+var errPrincipalRevoked = errors.New("the API key that created the batch is no longer valid")
+
+// requestLine is one line of a batch input file.
+type requestLine struct {
+	CustomID string          `json:"custom_id"`
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Body     json.RawMessage `json:"body"`
+}
+
+// resultLine is one line of a batch output or error file.
+type resultLine struct {
+	ID       string          `json:"id"`
+	CustomID string          `json:"custom_id"`
+	Response *resultResponse `json:"response"`
+	Error    *resultError    `json:"error"`
+}
+
+type resultResponse struct {
+	StatusCode int             `json:"status_code"`
+	RequestID  string          `json:"request_id"`
+	Body       json.RawMessage `json:"body"`
+}
+
+type resultError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// execution is the state shared by the workers of one running batch.
+type execution struct {
+	id       string
+	endpoint string
+	rec      batchRecord
+	attempts int
+
+	mu         sync.Mutex
+	output     *os.File
+	errors     *os.File
+	pauseUntil time.Time
+	lastSave   time.Time
+}
+
+// run executes a batch until it finishes or ctx is cancelled. A cancellation by the
+// client finalizes the batch; a shutdown leaves it to be resumed.
+func (m *Manager) run(ctx context.Context, id string, handle *runHandle) {
+	defer func() {
+		m.mu.Lock()
+		if m.running[id] == handle {
+			delete(m.running, id)
+		}
+		m.mu.Unlock()
+	}()
+	m.mu.Lock()
+	rec, ok := m.batches[id]
+	if !ok {
+		m.mu.Unlock()
+		return
+	}
+	snapshot := *rec
+	attempts, concurrency := m.cfg.MaxAttempts, m.cfg.Concurrency
+	m.mu.Unlock()
+
+	if snapshot.Status == StatusCancelling {
+		m.finalize(id, StatusCancelled)
+		return
+	}
+	if time.Now().Unix() >= snapshot.ExpiresAt {
+		m.finalize(id, StatusExpired)
+		return
+	}
+
+	lines, problems := m.readInput(ctx, snapshot.Principal.Principal, snapshot.InputFileID, snapshot.Endpoint)
+	if snapshot.Status == StatusValidating {
+		if len(problems) > 0 {
+			m.fail(id, problems)
+			return
+		}
+		m.update(id, func(b *batchRecord) {
+			b.Status = StatusInProgress
+			b.InProgressAt = time.Now().Unix()
+			b.RequestCounts = RequestCounts{Total: len(lines)}
+		}, true)
+	} else if len(problems) > 0 && len(lines) == 0 {
+		m.fail(id, problems)
+		return
+	}
+
+	done := make(map[string]bool)
+	for _, kind := range []string{"output", "errors"} {
+		if err := recoverPartial(m.store.partialPath(id, kind), done); err != nil {
+			log.Warnf("batch %s: failed to recover %s: %v", id, kind, err)
+		}
+	}
+	exec := &execution{id: id, endpoint: snapshot.Endpoint, rec: snapshot, attempts: attempts}
+	var err error
+	if exec.output, err = openPartial(m.store.partialPath(id, "output")); err == nil {
+		exec.errors, err = openPartial(m.store.partialPath(id, "errors"))
+	}
+	if err != nil {
+		exec.close()
+		log.Errorf("batch %s: failed to open result files: %v", id, err)
+		m.fail(id, []ErrorEntry{{Code: "internal_error", Message: "failed to store results"}})
+		return
+	}
+
+	runCtx, cancel := context.WithDeadline(ctx, time.Unix(snapshot.ExpiresAt, 0))
+	defer cancel()
+	pending := make(chan requestLine)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for line := range pending {
+				m.execute(runCtx, exec, line)
+			}
+		}()
+	}
+feed:
+	for _, line := range lines {
+		if done[line.CustomID] {
+			continue
+		}
+		select {
+		case pending <- line:
+		case <-runCtx.Done():
+			break feed
+		}
+	}
+	close(pending)
+	wg.Wait()
+
+	expired := errors.Is(runCtx.Err(), context.DeadlineExceeded)
+	if expired {
+		m.expireRemaining(exec, lines)
+	}
+	exec.close()
+	m.update(id, func(*batchRecord) {}, true)
+
+	m.mu.Lock()
+	status := StatusCompleted
+	if current, ok := m.batches[id]; ok && current.Status == StatusCancelling {
+		status = StatusCancelled
+	}
+	m.mu.Unlock()
+	switch {
+	case status == StatusCancelled:
+	case expired:
+		status = StatusExpired
+	case ctx.Err() != nil:
+		// Shutdown or the Batch API was disabled; the batch resumes later.
+		return
+	}
+	m.finalize(id, status)
+}
+
+// readInput parses and validates the input file of a batch.
+func (m *Manager) readInput(ctx context.Context, owner, fileID, endpoint string) ([]requestLine, []ErrorEntry) {
+	rc, _, err := m.files.Open(ctx, owner, fileID)
+	var data []byte
+	if err == nil {
+		data, err = io.ReadAll(rc)
+		_ = rc.Close()
+	}
+	if err != nil {
+		return nil, []ErrorEntry{{Code: "file_not_found", Message: "input file " + fileID + " could not be read"}}
+	}
+	var lines []requestLine
+	var problems []ErrorEntry
+	report := func(line int, code, message string) {
+		if len(problems) < maxValidationErrors {
+			problems = append(problems, ErrorEntry{Code: code, Message: message, Line: line})
+		}
+	}
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), len(data)+1)
+	number := 0
+	for scanner.Scan() {
+		number++
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		var line requestLine
+		if err = json.Unmarshal(raw, &line); err != nil {
+			report(number, "invalid_json_line", "line is not valid JSON")
+			continue
+		}
+		switch {
+		case line.CustomID == "":
+			report(number, "missing_custom_id", "custom_id is required")
+		case seen[line.CustomID]:
+			report(number, "duplicate_custom_id", "custom_id "+line.CustomID+" is used more than once")
+		case !strings.EqualFold(line.Method, http.MethodPost):
+			report(number, "invalid_method", "method must be POST")
+		case line.URL != endpoint:
+			report(number, "mismatched_url", "url must match the batch endpoint "+endpoint)
+		case !gjson.ParseBytes(line.Body).IsObject():
+			report(number, "invalid_body", "body must be a JSON object")
+		default:
+			seen[line.CustomID] = true
+			lines = append(lines, line)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		report(number+1, "invalid_json_line", err.Error())
+	}
+	switch {
+	case len(problems) == 0 && len(lines) == 0:
+		problems = append(problems, ErrorEntry{Code: "empty_file", Message: "input file contains no requests"})
+	case len(lines) > maxRequests:
+		problems = append(problems, ErrorEntry{Code: "too_many_requests", Message: fmt.Sprintf("a batch holds at most %d requests", maxRequests)})
+	}
+	return lines, problems
+}
+
+// execute runs one request, retrying throttled and unavailable responses after the
+// delay the server asked for. The wait pauses the whole batch so it backs off together.
+// Requests interrupted by cancellation are not recorded.
+func (m *Manager) execute(ctx context.Context, exec *execution, line requestLine) {
+	body := line.Body
+	if gjson.GetBytes(body, "stream").Exists() {
+		body, _ = sjson.SetBytes(body, "stream", false)
+	}
+	body, _ = sjson.DeleteBytes(body, "stream_options")
+	for attempt := 1; ; attempt++ {
+		if !exec.waitPause(ctx) {
+			return
+		}
+		resp, err := m.dispatch(ctx, exec.rec, exec.endpoint, body)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			code := "internal_error"
+			if errors.Is(err, errPrincipalRevoked) {
+				code = "invalid_api_key"
+			}
+			m.record(exec, resultLine{CustomID: line.CustomID, Error: &resultError{Code: code, Message: err.Error()}}, false)
+			return
+		}
+		if retryable(resp.status) && attempt < exec.attempts {
+			exec.pause(retryDelay(resp.header, attempt))
+			continue
+		}
+		result := resultLine{CustomID: line.CustomID, Response: &resultResponse{
+			StatusCode: resp.status,
+			RequestID:  resp.header.Get("X-Request-ID"),
+			Body:       jsonBody(resp.body.Bytes()),
+		}}
+		m.record(exec, result, resp.status >= 200 && resp.status < 300)
+		return
+	}
+}
+
+// dispatch sends a request through the server's handler as the batch's client.
+func (m *Manager) dispatch(ctx context.Context, rec batchRecord, endpoint string, body []byte) (*responseBuffer, error) {
+	m.mu.Lock()
+	handler, authorize := m.handler, m.authorize
+	m.mu.Unlock()
+	if handler == nil {
+		return nil, errors.New("batch executor is not ready")
+	}
+	if authorize != nil && !authorize(rec.Principal) {
+		return nil, errPrincipalRevoked
+	}
+	req, err := http.NewRequestWithContext(WithPrincipal(ctx, rec.Principal), http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "cli-proxy-api-batch")
+	req.RemoteAddr = "127.0.0.1:0"
+	resp := &responseBuffer{header: make(http.Header)}
+	handler.ServeHTTP(resp, req)
+	if resp.status == 0 {
+		resp.status = http.StatusOK
+	}
+	return resp, nil
+}
+
+// record appends a result to the output or error file and updates the request counts.
+func (m *Manager) record(exec *execution, result resultLine, ok bool) {
+	result.ID = "batch_req_" + newID()
+	data, err := json.Marshal(result)
+	if err != nil {
+		return
+	}
+	exec.mu.Lock()
+	target := exec.errors
+	if ok {
+		target = exec.output
+	}
+	_, err = target.Write(append(data, '\n'))
+	save := time.Since(exec.lastSave) >= saveInterval
+	if save {
+		exec.lastSave = time.Now()
+	}
+	exec.mu.Unlock()
+	if err != nil {
+		log.Errorf("batch %s: failed to store result of %s: %v", exec.id, result.CustomID, err)
+	}
+	m.update(exec.id, func(b *batchRecord) {
+		if ok {
+			b.RequestCounts.Completed++
+		} else {
+			b.RequestCounts.Failed++
+		}
+	}, save)
+}
+
+// expireRemaining reports the requests a batch did not get to before it expired.
+func (m *Manager) expireRemaining(exec *execution, lines []requestLine) {
+	done := make(map[string]bool)
+	for _, kind := range []string{"output", "errors"} {
+		_ = recoverPartial(m.store.partialPath(exec.id, kind), done)
+	}
+	for _, line := range lines {
+		if !done[line.CustomID] {
+			m.record(exec, resultLine{CustomID: line.CustomID, Error: &resultError{Code: "batch_expired", Message: "the batch expired before this request was executed"}}, false)
+		}
+	}
+}
+
+// fail marks a batch as failed validation.
+func (m *Manager) fail(id string, problems []ErrorEntry) {
+	m.update(id, func(b *batchRecord) {
+		b.Status = StatusFailed
+		b.FailedAt = time.Now().Unix()
+		b.Errors = &Errors{Object: "list", Data: problems}
+	}, true)
+}
+
+// finalize publishes the gathered results as output and error files and moves the
+// batch into its final status.
+func (m *Manager) finalize(id, status string) {
+	m.mu.Lock()
+	rec, ok := m.batches[id]
+	if !ok || rec.terminal() {
+		m.mu.Unlock()
+		return
+	}
+	owner := rec.Principal.Principal
+	m.mu.Unlock()
+	m.update(id, func(b *batchRecord) {
+		b.Status = StatusFinalizing
+		b.FinalizingAt = time.Now().Unix()
+	}, true)
+
+	outputID := m.publish(id, "output", owner)
+	errorID := m.publish(id, "errors", owner)
+	m.update(id, func(b *batchRecord) {
+		b.Status = status
+		b.OutputFileID = outputID
+		b.ErrorFileID = errorID
+		now := time.Now().Unix()
+		switch status {
+		case StatusCompleted:
+			b.CompletedAt = now
+		case StatusExpired:
+			b.ExpiredAt = now
+		case StatusCancelled:
+			b.CancelledAt = now
+		}
+	}, true)
+}
+
+// publish turns a non-empty partial result file into a file of owner and returns its ID.
+// Result files expire together with the batch.
+func (m *Manager) publish(id, kind, owner string) string {
+	path := m.store.partialPath(id, kind)
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(path)
+	}()
+	if info, errStat := f.Stat(); errStat != nil || info.Size() == 0 {
+		return ""
+	}
+	m.mu.Lock()
+	expiresAt := time.Now().Add(m.cfg.Retention).Unix()
+	m.mu.Unlock()
+	file, err := m.files.Put(context.Background(), owner, id+"_"+kind+".jsonl", filestore.PurposeBatchOutput, f, expiresAt)
+	if err != nil {
+		log.Errorf("batch %s: failed to publish %s: %v", id, kind, err)
+		return ""
+	}
+	return file.ID
+}
+
+// update applies fn to a batch and persists it when save is set.
+func (m *Manager) update(id string, fn func(*batchRecord), save bool) {
+	m.mu.Lock()
+	rec, ok := m.batches[id]
+	if !ok {
+		m.mu.Unlock()
+		return
+	}
+	fn(rec)
+	snapshot := *rec
+	m.mu.Unlock()
+	if !save {
+		return
+	}
+	if err := m.store.saveBatch(&snapshot); err != nil {
+		log.Warnf("batch %s: failed to save state: %v", id, err)
+	}
+}
+
+func openPartial(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+}
+
+func (e *execution) close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, f := range []*os.File{e.output, e.errors} {
+		if f != nil {
+			_ = f.Close()
+		}
+	}
+}
+
+// pause holds every worker of the batch for d.
+func (e *execution) pause(d time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if until := time.Now().Add(d); until.After(e.pauseUntil) {
+		e.pauseUntil = until
+	}
+}
+
+// waitPause blocks while the batch is paused. It reports false when ctx is done.
+func (e *execution) waitPause(ctx context.Context) bool {
+	for {
+		e.mu.Lock()
+		wait := time.Until(e.pauseUntil)
+		e.mu.Unlock()
+		if wait <= 0 {
+			return ctx.Err() == nil
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+	}
+}
+
+func retryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryDelay honours Retry-After and otherwise backs off exponentially.
+func retryDelay(header http.Header, attempt int) time.Duration {
+	delay := time.Duration(1<<min(attempt, 6)) * time.Second
+	if seconds, err := strconv.Atoi(strings.TrimSpace(header.Get("Retry-After"))); err == nil && seconds > 0 {
+		delay = time.Duration(seconds) * time.Second
+	}
+	return min(delay, maxRetryDelay)
+}
+
+// jsonBody returns body as JSON, quoting bodies that are not JSON documents.
+func jsonBody(body []byte) json.RawMessage {
+	if json.Valid(body) {
+		return json.RawMessage(body)
+	}
+	quoted, _ := json.Marshal(string(body))
+	return quoted
+}
+
+// responseBuffer collects the response of an in-process request.
+type responseBuffer struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseBuffer) Header() http.Header { return r.header }
+
+func (r *responseBuffer) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+func (r *responseBuffer) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.body.Write(p)
+}
+
+// Flush implements http.Flusher; the response is only read once complete.
+func (r *responseBuffer) Flush() {}
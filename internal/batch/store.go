@@ -0,0 +1,123 @@
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// store keeps batches as files under dir:
+//
+//	batches/<id>.json          batch state
+//	batches/<id>.output.jsonl  results gathered so far
+//	batches/<id>.errors.jsonl  errors gathered so far
+//
+// Everything is written with owner-only permissions since results hold completions.
+type store struct {
+	dir string
+}
+
+func (s *store) batchesDir() string { return filepath.Join(s.dir, "batches") }
+
+func (s *store) partialPath(id, kind string) string {
+	return filepath.Join(s.batchesDir(), id+"."+kind+".jsonl")
+}
+
+func (s *store) saveBatch(rec *batchRecord) error {
+	return writeJSON(filepath.Join(s.batchesDir(), rec.ID+".json"), rec)
+}
+
+func (s *store) removeBatch(id string) {
+	_ = os.Remove(filepath.Join(s.batchesDir(), id+".json"))
+	_ = os.Remove(s.partialPath(id, "output"))
+	_ = os.Remove(s.partialPath(id, "errors"))
+}
+
+// load reads every stored batch.
+func (s *store) load() ([]*batchRecord, error) {
+	var batches []*batchRecord
+	err := readJSONDir(s.batchesDir(), func(data []byte) error {
+		rec := &batchRecord{}
+		if err := json.Unmarshal(data, rec); err != nil {
+			return err
+		}
+		batches = append(batches, rec)
+		return nil
+	})
+	return batches, err
+}
+
+// readJSONDir calls fn with the content of every <id>.json file of dir.
+func readJSONDir(dir string, fn func([]byte) error) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var errs []error
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			err = fn(data)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// writeJSON writes v to path through a temporary file so readers never see a partial write.
+func writeJSON(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// recoverPartial returns the custom IDs recorded in a partial result file. A trailing
+// line left incomplete by a crash is cut off so appended results start on a new line.
+func recoverPartial(path string, done map[string]bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	complete := bytes.LastIndexByte(data, '\n') + 1
+	if complete < len(data) {
+		if err = os.Truncate(path, int64(complete)); err != nil {
+			return err
+		}
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data[:complete]))
+	scanner.Buffer(make([]byte, 64*1024), len(data)+1)
+	for scanner.Scan() {
+		if id := gjson.GetBytes(scanner.Bytes(), "custom_id").String(); id != "" {
+			done[id] = true
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,196 @@
+// Package bodylimit bounds the size of request bodies and spools multipart uploads, so
+// that large uploads are kept in a temporary file instead of in memory.
+package bodylimit
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+const (
+	defaultMaxBodyBytes      = 32 << 20
+	defaultMaxUploadBytes    = 128 << 20
+	defaultUploadMemoryBytes = 4 << 20
+)
+
+// ErrTooLarge is returned when a body is larger than its limit.
+var ErrTooLarge = errors.New("request body too large")
+
+// Limiter holds the body size limits. It is safe for concurrent use.
+type Limiter struct {
+	mu           sync.RWMutex
+	maxBody      int64
+	maxUpload    int64
+	uploadMemory int64
+	spoolDir     string
+	routes       []config.RequestLimitRoute
+}
+
+// New creates a limiter for cfg.
+func New(cfg config.RequestLimitsConfig) *Limiter {
+	l := &Limiter{}
+	l.Configure(cfg)
+	return l
+}
+
+// Configure applies cfg.
+func (l *Limiter) Configure(cfg config.RequestLimitsConfig) {
+	uploadMemory := cfg.UploadMemoryBytes
+	if uploadMemory <= 0 {
+		uploadMemory = defaultUploadMemoryBytes
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.maxBody = limitOr(cfg.MaxBodyBytes, defaultMaxBodyBytes)
+	l.maxUpload = limitOr(cfg.MaxUploadBytes, defaultMaxUploadBytes)
+	l.uploadMemory = uploadMemory
+	l.spoolDir = strings.TrimSpace(cfg.SpoolDir)
+	l.routes = append([]config.RequestLimitRoute(nil), cfg.Routes...)
+}
+
+// Limit returns the largest body in bytes of a request for path, an upload or not, or 0
+// when it is unlimited.
+func (l *Limiter) Limit(path string, upload bool) int64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, route := range l.routes {
+		if matchRoute(route.Path, path) {
+			return max(route.MaxBodyBytes, 0)
+		}
+	}
+	if upload {
+		return l.maxUpload
+	}
+	return l.maxBody
+}
+
+// Spool reads r, at most limit bytes unless limit is 0, into a replayable body. The first
+// bytes are kept in memory and the rest is written to a temporary file. It returns
+// ErrTooLarge when r holds more than limit bytes.
+func (l *Limiter) Spool(r io.Reader, limit int64) (*Body, error) {
+	l.mu.RLock()
+	memory, dir := l.uploadMemory, l.spoolDir
+	l.mu.RUnlock()
+	if limit > 0 && memory > limit {
+		memory = limit
+	}
+
+	head, err := io.ReadAll(io.LimitReader(r, memory+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(head)) <= memory {
+		return newBody(head, nil, int64(len(head))), nil
+	}
+	if limit > 0 && int64(len(head)) > limit {
+		return nil, ErrTooLarge
+	}
+
+	file, err := os.CreateTemp(dir, "cliproxy-upload-*")
+	if err != nil {
+		return nil, err
+	}
+	body := newBody(nil, file, 0)
+	if _, err = file.Write(head); err != nil {
+		_ = body.Remove()
+		return nil, err
+	}
+	rest := r
+	if limit > 0 {
+		rest = io.LimitReader(r, limit-int64(len(head))+1)
+	}
+	n, err := io.Copy(file, rest)
+	if err != nil {
+		_ = body.Remove()
+		return nil, err
+	}
+	body.size = int64(len(head)) + n
+	if limit > 0 && body.size > limit {
+		_ = body.Remove()
+		return nil, ErrTooLarge
+	}
+	body.reader = io.NewSectionReader(file, 0, body.size)
+	return body, nil
+}
+
+// Body is a spooled request body. It reads as the request body once, and Open returns
+// further independent readers, so the body can be sent upstream again on a retry.
+type Body struct {
+	data   []byte
+	file   *os.File
+	size   int64
+	reader io.Reader
+}
+
+func newBody(data []byte, file *os.File, size int64) *Body {
+	return &Body{data: data, file: file, size: size, reader: bytes.NewReader(data)}
+}
+
+// Read reads from the body.
+func (b *Body) Read(p []byte) (int, error) {
+	return b.reader.Read(p)
+}
+
+// Close does nothing: the spool is kept until Remove, so the body can still be opened.
+func (b *Body) Close() error {
+	return nil
+}
+
+// Open returns a new reader of the whole body. The reader reports the body length with a
+// Size method, so the body can be sent with a Content-Length.
+func (b *Body) Open() (io.ReadCloser, error) {
+	if b.file == nil {
+		return sizedReader{Reader: bytes.NewReader(b.data), size: b.size}, nil
+	}
+	return sizedReader{Reader: io.NewSectionReader(b.file, 0, b.size), size: b.size}, nil
+}
+
+// Size returns the length of the body in bytes.
+func (b *Body) Size() int64 {
+	return b.size
+}
+
+// Remove deletes the temporary file of the body, if any.
+func (b *Body) Remove() error {
+	if b.file == nil {
+		return nil
+	}
+	name := b.file.Name()
+	_ = b.file.Close()
+	return os.Remove(name)
+}
+
+type sizedReader struct {
+	io.Reader
+	size int64
+}
+
+func (r sizedReader) Close() error { return nil }
+
+func (r sizedReader) Size() int64 { return r.size }
+
+// limitOr returns value, or fallback when it is 0; a negative value means unlimited.
+func limitOr(value, fallback int64) int64 {
+	switch {
+	case value < 0:
+		return 0
+	case value == 0:
+		return fallback
+	}
+	return value
+}
+
+// matchRoute reports whether path matches pattern, exactly or by the prefix before a
+// trailing "*".
+func matchRoute(pattern, path string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(path, prefix)
+	}
+	return pattern == path
+}
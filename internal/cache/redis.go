@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/redis"
+	log "github.com/sirupsen/logrus"
+)
+
+// Redis stores values in a Redis server shared by all proxy instances.
+// Server errors are logged and treated as cache misses.
+type Redis struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedis returns a cache storing its keys under the client's prefix and namespace.
+func NewRedis(client *redis.Client, namespace string) *Redis {
+	return &Redis{client: client, prefix: client.KeyPrefix() + namespace + ":"}
+}
+
+// Get returns the value stored at key.
+func (c *Redis) Get(ctx context.Context, key string) ([]byte, bool) {
+	value, ok, err := c.client.Get(ctx, c.prefix+key)
+	if err != nil {
+		log.Debugf("cache: redis get failed: %v", err)
+		return nil, false
+	}
+	return value, ok
+}
+
+// Set stores value at key for ttl.
+func (c *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) {
+	if err := c.client.Set(ctx, c.prefix+key, value, ttl); err != nil {
+		log.Debugf("cache: redis set failed: %v", err)
+	}
+}
@@ -0,0 +1,409 @@
+// Package capture keeps full request and response bodies of proxied requests for debugging,
+// with credentials and optionally personal data masked. Captures are held in a ring buffer
+// in memory or written as JSON files to disk, and are listed and retrieved through the
+// management API.
+package capture
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultMaxEntries   = 100
+	defaultMaxBodyBytes = 1 << 20
+)
+
+// Entry is one captured request with everything needed to replay its translation.
+type Entry struct {
+	ID              string              `json:"id"`
+	Timestamp       time.Time           `json:"timestamp"`
+	Method          string              `json:"method"`
+	URL             string              `json:"url"`
+	Status          int                 `json:"status"`
+	DurationMs      int64               `json:"duration_ms"`
+	Stream          bool                `json:"stream"`
+	Chunks          int                 `json:"chunks,omitempty"`
+	RequestHeaders  map[string][]string `json:"request_headers,omitempty"`
+	RequestBody     string              `json:"request_body,omitempty"`
+	ResponseHeaders map[string][]string `json:"response_headers,omitempty"`
+	// ResponseBody is the body sent to the client; streamed responses are reassembled in order.
+	ResponseBody string `json:"response_body,omitempty"`
+	// UpstreamRequest and UpstreamResponse describe every attempt made against the provider.
+	UpstreamRequest  string `json:"upstream_request,omitempty"`
+	UpstreamResponse string `json:"upstream_response,omitempty"`
+	Truncated        bool   `json:"truncated,omitempty"`
+}
+
+// Summary is the listing view of an entry.
+type Summary struct {
+	ID         string    `json:"id"`
+	Timestamp  time.Time `json:"timestamp"`
+	Method     string    `json:"method"`
+	URL        string    `json:"url"`
+	Status     int       `json:"status"`
+	DurationMs int64     `json:"duration_ms"`
+	Stream     bool      `json:"stream"`
+}
+
+func (e *Entry) summary() Summary {
+	return Summary{ID: e.ID, Timestamp: e.Timestamp, Method: e.Method, URL: e.URL, Status: e.Status, DurationMs: e.DurationMs, Stream: e.Stream}
+}
+
+// store keeps captures, dropping the oldest beyond its capacity.
+type store interface {
+	put(entry *Entry)
+	list() []Summary
+	get(id string) (*Entry, bool)
+	clear()
+}
+
+// Raw is the unredacted content of one exchange handed to Record.
+type Raw struct {
+	Start            time.Time
+	Method           string
+	URL              string
+	Status           int
+	Stream           bool
+	Chunks           int
+	RequestHeaders   http.Header
+	RequestBody      []byte
+	ResponseHeaders  http.Header
+	ResponseBody     []byte
+	UpstreamRequest  []byte
+	UpstreamResponse []byte
+	Truncated        bool
+}
+
+// Recorder redacts and stores captures. The zero value, and a disabled recorder, drop them.
+type Recorder struct {
+	mu       sync.RWMutex
+	enabled  bool
+	cfg      config.BodyCaptureConfig
+	redactor *redactor
+	store    store
+	seq      atomic.Uint64
+}
+
+// NewRecorder creates a recorder for cfg.
+func NewRecorder(cfg config.BodyCaptureConfig) (*Recorder, error) {
+	r := &Recorder{}
+	if err := r.Configure(cfg); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Configure applies a new configuration. Stored captures are kept unless the storage changes.
+func (r *Recorder) Configure(cfg config.BodyCaptureConfig) error {
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = defaultMaxEntries
+	}
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	cfg.Storage = strings.ToLower(strings.TrimSpace(cfg.Storage))
+	if cfg.Storage == "" {
+		cfg.Storage = "memory"
+	}
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join("logs", "captures")
+		if base := util.WritablePath(); base != "" {
+			cfg.Dir = filepath.Join(base, "logs", "captures")
+		}
+	}
+	redactor, err := newRedactor(cfg.RedactPII, cfg.RedactPatterns)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current := r.store
+	if current == nil || cfg.Storage != r.cfg.Storage || cfg.Dir != r.cfg.Dir {
+		current = nil
+	}
+	switch cfg.Storage {
+	case "memory":
+		if current == nil {
+			current = newRingStore(cfg.MaxEntries)
+		} else {
+			current.(*ringStore).resize(cfg.MaxEntries)
+		}
+	case "disk":
+		if current == nil {
+			disk, errDisk := newDiskStore(cfg.Dir, cfg.MaxEntries)
+			if errDisk != nil {
+				return errDisk
+			}
+			current = disk
+		} else {
+			current.(*diskStore).resize(cfg.MaxEntries)
+		}
+	default:
+		return fmt.Errorf("capture: unknown storage %q", cfg.Storage)
+	}
+	r.enabled = cfg.Enable
+	r.cfg = cfg
+	r.redactor = redactor
+	r.store = current
+	return nil
+}
+
+// Enabled reports whether new exchanges are captured.
+func (r *Recorder) Enabled() bool {
+	if r == nil {
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.enabled
+}
+
+// MaxBodyBytes returns the size at which captured bodies are truncated.
+func (r *Recorder) MaxBodyBytes() int {
+	if r == nil {
+		return defaultMaxBodyBytes
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cfg.MaxBodyBytes
+}
+
+// Record redacts and stores one exchange.
+func (r *Recorder) Record(raw Raw) {
+	if r == nil {
+		return
+	}
+	r.mu.RLock()
+	enabled, redactor, target, limit := r.enabled, r.redactor, r.store, r.cfg.MaxBodyBytes
+	r.mu.RUnlock()
+	if !enabled || target == nil {
+		return
+	}
+	truncated := raw.Truncated
+	body := func(data []byte) string {
+		if len(data) > limit {
+			data = data[:limit]
+			truncated = true
+		}
+		return redactor.text(string(data))
+	}
+	entry := &Entry{
+		ID:               fmt.Sprintf("%s-%06d", raw.Start.UTC().Format("20060102T150405.000"), r.seq.Add(1)%1000000),
+		Timestamp:        raw.Start,
+		Method:           raw.Method,
+		URL:              redactor.text(raw.URL),
+		Status:           raw.Status,
+		DurationMs:       time.Since(raw.Start).Milliseconds(),
+		Stream:           raw.Stream,
+		Chunks:           raw.Chunks,
+		RequestHeaders:   redactor.headers(raw.RequestHeaders),
+		RequestBody:      body(raw.RequestBody),
+		ResponseHeaders:  redactor.headers(raw.ResponseHeaders),
+		ResponseBody:     body(raw.ResponseBody),
+		UpstreamRequest:  body(raw.UpstreamRequest),
+		UpstreamResponse: body(raw.UpstreamResponse),
+	}
+	entry.Truncated = truncated
+	target.put(entry)
+}
+
+// List returns the stored captures, newest first.
+func (r *Recorder) List() []Summary {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	target := r.store
+	r.mu.RUnlock()
+	if target == nil {
+		return nil
+	}
+	return target.list()
+}
+
+// Get returns the capture with the given ID.
+func (r *Recorder) Get(id string) (*Entry, bool) {
+	if r == nil {
+		return nil, false
+	}
+	r.mu.RLock()
+	target := r.store
+	r.mu.RUnlock()
+	if target == nil {
+		return nil, false
+	}
+	return target.get(id)
+}
+
+// Clear removes every stored capture.
+func (r *Recorder) Clear() {
+	if r == nil {
+		return
+	}
+	r.mu.RLock()
+	target := r.store
+	r.mu.RUnlock()
+	if target != nil {
+		target.clear()
+	}
+}
+
+// ringStore keeps the latest captures in memory.
+type ringStore struct {
+	mu      sync.Mutex
+	entries []*Entry // oldest first
+	max     int
+}
+
+func newRingStore(max int) *ringStore { return &ringStore{max: max} }
+
+func (s *ringStore) resize(max int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.max = max
+	if over := len(s.entries) - max; over > 0 {
+		s.entries = append([]*Entry(nil), s.entries[over:]...)
+	}
+}
+
+func (s *ringStore) put(entry *Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.entries) >= s.max {
+		copy(s.entries, s.entries[1:])
+		s.entries = s.entries[:len(s.entries)-1]
+	}
+	s.entries = append(s.entries, entry)
+}
+
+func (s *ringStore) list() []Summary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Summary, 0, len(s.entries))
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		out = append(out, s.entries[i].summary())
+	}
+	return out
+}
+
+func (s *ringStore) get(id string) (*Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, entry := range s.entries {
+		if entry.ID == id {
+			return entry, true
+		}
+	}
+	return nil, false
+}
+
+func (s *ringStore) clear() {
+	s.mu.Lock()
+	s.entries = nil
+	s.mu.Unlock()
+}
+
+// diskStore writes one JSON file per capture and removes the oldest files beyond max.
+// IDs sort chronologically, so file names double as the index.
+type diskStore struct {
+	mu  sync.Mutex
+	dir string
+	max int
+}
+
+func newDiskStore(dir string, max int) (*diskStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("capture: failed to create directory: %w", err)
+	}
+	return &diskStore{dir: dir, max: max}, nil
+}
+
+func (s *diskStore) resize(max int) {
+	s.mu.Lock()
+	s.max = max
+	s.mu.Unlock()
+}
+
+func (s *diskStore) put(entry *Entry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err = os.WriteFile(filepath.Join(s.dir, entry.ID+".json"), data, 0o600); err != nil {
+		log.Warnf("capture: failed to write %s: %v", entry.ID, err)
+		return
+	}
+	ids := s.ids()
+	for len(ids) > s.max {
+		_ = os.Remove(filepath.Join(s.dir, ids[0]+".json"))
+		ids = ids[1:]
+	}
+}
+
+// ids returns the stored IDs, oldest first. Callers must hold s.mu.
+func (s *diskStore) ids() []string {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil
+	}
+	ids := make([]string, 0, len(files))
+	for _, file := range files {
+		if name := file.Name(); !file.IsDir() && strings.HasSuffix(name, ".json") {
+			ids = append(ids, strings.TrimSuffix(name, ".json"))
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (s *diskStore) list() []Summary {
+	s.mu.Lock()
+	ids := s.ids()
+	s.mu.Unlock()
+	out := make([]Summary, 0, len(ids))
+	for i := len(ids) - 1; i >= 0; i-- {
+		if entry, ok := s.get(ids[i]); ok {
+			out = append(out, entry.summary())
+		}
+	}
+	return out
+}
+
+func (s *diskStore) get(id string) (*Entry, bool) {
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.Contains(id, "..") {
+		return nil, false
+	}
+	data, err := os.ReadFile(filepath.Join(s.dir, id+".json"))
+	if err != nil {
+		return nil, false
+	}
+	var entry Entry
+	if err = json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+	return &entry, true
+}
+
+func (s *diskStore) clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range s.ids() {
+		_ = os.Remove(filepath.Join(s.dir, id+".json"))
+	}
+}
@@ -0,0 +1,93 @@
+package capture
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// redacted replaces every masked value.
+const redacted = "[REDACTED]"
+
+// secretFieldPattern masks the values of JSON fields that hold credentials.
+var secretFieldPattern = regexp.MustCompile(`(?i)("(?:api[_-]?key|access[_-]?token|refresh[_-]?token|id[_-]?token|client[_-]?secret|password|secret)"\s*:\s*")(?:[^"\\]|\\.)*(")`)
+
+// secretPatterns match credentials wherever they appear.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9._~+/=-]{8,}`),
+	regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{16,}`),
+	regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}`),
+	regexp.MustCompile(`\bya29\.[0-9A-Za-z._-]+`),
+	regexp.MustCompile(`\b1//[0-9A-Za-z_-]{20,}`),
+}
+
+// piiPatterns match personal data masked when redact-pii is set.
+var piiPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	regexp.MustCompile(`\+\d{1,3}[ .-]?(?:\(\d{1,4}\)[ .-]?)?\d{2,4}(?:[ .-]?\d{2,4}){2,3}\b`),
+}
+
+// sensitiveHeaders are replaced as a whole.
+var sensitiveHeaders = map[string]struct{}{
+	"authorization":       {},
+	"proxy-authorization": {},
+	"x-api-key":           {},
+	"x-goog-api-key":      {},
+	"cookie":              {},
+	"set-cookie":          {},
+}
+
+// redactor masks credentials and, optionally, personal data in captured content.
+type redactor struct {
+	patterns []*regexp.Regexp
+}
+
+func newRedactor(pii bool, custom []string) (*redactor, error) {
+	r := &redactor{patterns: append([]*regexp.Regexp(nil), secretPatterns...)}
+	if pii {
+		r.patterns = append(r.patterns, piiPatterns...)
+	}
+	for _, expr := range custom {
+		if strings.TrimSpace(expr) == "" {
+			continue
+		}
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("capture: invalid redact pattern %q: %w", expr, err)
+		}
+		r.patterns = append(r.patterns, pattern)
+	}
+	return r, nil
+}
+
+func (r *redactor) text(value string) string {
+	if value == "" {
+		return value
+	}
+	value = secretFieldPattern.ReplaceAllString(value, "${1}"+redacted+"${2}")
+	for _, pattern := range r.patterns {
+		value = pattern.ReplaceAllString(value, redacted)
+	}
+	return value
+}
+
+func (r *redactor) headers(headers http.Header) map[string][]string {
+	if len(headers) == 0 {
+		return nil
+	}
+	out := make(map[string][]string, len(headers))
+	for name, values := range headers {
+		masked := make([]string, len(values))
+		_, sensitive := sensitiveHeaders[strings.ToLower(name)]
+		for i, value := range values {
+			if sensitive {
+				masked[i] = redacted
+			} else {
+				masked[i] = r.text(value)
+			}
+		}
+		out[name] = masked
+	}
+	return out
+}
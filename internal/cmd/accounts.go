@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// accountStatus mirrors one entry of the management accounts status endpoint.
+type accountStatus struct {
+	ID             string               `json:"id"`
+	Provider       string               `json:"provider"`
+	Label          string               `json:"label"`
+	Status         string               `json:"status"`
+	StatusMessage  string               `json:"status_message"`
+	Disabled       bool                 `json:"disabled"`
+	ExpiresAt      *time.Time           `json:"expires_at"`
+	CooldownUntil  *time.Time           `json:"cooldown_until"`
+	ModelCooldowns map[string]time.Time `json:"model_cooldowns"`
+	Samples        int                  `json:"samples"`
+	ErrorRate      float64              `json:"error_rate"`
+	Circuit        string               `json:"circuit"`
+	LastError      string               `json:"last_error"`
+}
+
+// RunAccounts implements the "accounts" subcommand: it lists the upstream accounts of a
+// running proxy with their auth status, token expiry, cooldowns and recent error rate.
+//
+// Parameters:
+//   - args: The arguments following the subcommand name
+//   - defaultConfigPath: The configuration file used to locate the proxy when --url is not set
+//
+// Returns:
+//   - int: The process exit code
+func RunAccounts(args []string, defaultConfigPath string) int {
+	fs := flag.NewFlagSet("accounts", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "Configuration file used to locate the proxy")
+	baseURL := fs.String("url", "", "Base URL of the proxy (default derived from the configuration)")
+	key := fs.String("management-key", os.Getenv("MANAGEMENT_PASSWORD"), "Management key (default $MANAGEMENT_PASSWORD)")
+	provider := fs.String("provider", "", "Only list accounts of this provider")
+	insecure := fs.Bool("insecure", false, "Skip TLS certificate verification")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	endpoint := strings.TrimRight(*baseURL, "/")
+	if endpoint == "" {
+		endpoint = localServerURL(*configPath)
+	}
+	target := endpoint + "/v0/management/accounts/status"
+	if *provider != "" {
+		target += "?" + url.Values{"provider": {*provider}}.Encode()
+	}
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid URL %s: %v\n", endpoint, err)
+		return 2
+	}
+	if *key != "" {
+		req.Header.Set("Authorization", "Bearer "+*key)
+	}
+	resp, err := newCLIClient(*insecure).Do(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to query %s: %v\n", endpoint, err)
+		return 1
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read accounts: %v\n", err)
+		return 1
+	}
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "management endpoint returned %d: %s\n", resp.StatusCode, strings.TrimSpace(string(body)))
+		return 1
+	}
+	var payload struct {
+		Accounts []accountStatus `json:"accounts"`
+	}
+	if err = json.Unmarshal(body, &payload); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to decode accounts: %v\n", err)
+		return 1
+	}
+	renderAccounts(os.Stdout, payload.Accounts, time.Now())
+	return 0
+}
+
+// renderAccounts writes one table row per account to out.
+func renderAccounts(out io.Writer, accounts []accountStatus, now time.Time) {
+	if len(accounts) == 0 {
+		_, _ = fmt.Fprintln(out, "No accounts configured.")
+		return
+	}
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "ID\tPROVIDER\tSTATUS\tEXPIRES\tCOOLDOWN\tERROR RATE\tLAST ERROR")
+	for _, account := range accounts {
+		status := account.Status
+		if account.Disabled {
+			status = "disabled"
+		}
+		if account.Circuit != "" && account.Circuit != "closed" {
+			status += " (circuit " + account.Circuit + ")"
+		}
+		expires := "-"
+		if account.ExpiresAt != nil {
+			expires = relativeTime(*account.ExpiresAt, now)
+		}
+		cooldown := "-"
+		if account.CooldownUntil != nil {
+			cooldown = relativeTime(*account.CooldownUntil, now)
+		} else if n := len(account.ModelCooldowns); n > 0 {
+			cooldown = fmt.Sprintf("%d model(s)", n)
+		}
+		errorRate := "-"
+		if account.Samples > 0 {
+			errorRate = fmt.Sprintf("%.0f%% of %d", account.ErrorRate*100, account.Samples)
+		}
+		lastError := account.LastError
+		if lastError == "" {
+			lastError = account.StatusMessage
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", account.ID, account.Provider, status, expires, cooldown, errorRate, truncate(lastError, 60))
+	}
+	_ = tw.Flush()
+}
+
+// relativeTime describes t relative to now, e.g. "in 2h5m" or "expired 3m ago".
+func relativeTime(t, now time.Time) string {
+	d := t.Sub(now).Round(time.Minute)
+	if d == 0 {
+		return "now"
+	}
+	if d > 0 {
+		return "in " + strings.TrimSuffix(d.String(), "0s")
+	}
+	return "expired " + strings.TrimSuffix((-d).String(), "0s") + " ago"
+}
+
+// truncate shortens s to at most n runes.
+func truncate(s string, n int) string {
+	runes := []rune(strings.TrimSpace(s))
+	if len(runes) <= n {
+		return string(runes)
+	}
+	return string(runes[:n-1]) + "…"
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// DoEncryptAuthFiles rewrites every plaintext auth file in the auth directory encrypted
+// with the configured key. Files that are already encrypted are left untouched.
+//
+// Parameters:
+//   - cfg: The application configuration
+func DoEncryptAuthFiles(cfg *config.Config) {
+	if !authcrypt.Enabled() {
+		log.Fatalf("no auth encryption key configured; set auth-encryption in the config or %s", authcrypt.KeyEnv)
+	}
+	migrateAuthFiles(cfg.AuthDir, "encrypted", func(data []byte) ([]byte, bool, error) {
+		if authcrypt.IsEncrypted(data) {
+			return nil, false, nil
+		}
+		sealed, err := authcrypt.Seal(data)
+		return sealed, true, err
+	})
+}
+
+// DoDecryptAuthFiles rewrites every encrypted auth file in the auth directory as
+// plaintext, for example before disabling encryption or rotating the key.
+//
+// Parameters:
+//   - cfg: The application configuration
+func DoDecryptAuthFiles(cfg *config.Config) {
+	if !authcrypt.Enabled() {
+		log.Fatalf("no auth encryption key configured; set auth-encryption in the config or %s", authcrypt.KeyEnv)
+	}
+	migrateAuthFiles(cfg.AuthDir, "decrypted", func(data []byte) ([]byte, bool, error) {
+		if !authcrypt.IsEncrypted(data) {
+			return nil, false, nil
+		}
+		plain, err := authcrypt.Open(data)
+		return plain, true, err
+	})
+}
+
+// migrateAuthFiles applies convert to each auth file in dir and atomically replaces the
+// files it changed. Files that fail to convert are reported and left as they were.
+func migrateAuthFiles(dir, action string, convert func([]byte) ([]byte, bool, error)) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		log.Fatalf("failed to read auth directory %s: %v", dir, err)
+	}
+	var changed, skipped, failed int
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(strings.ToLower(entry.Name()), ".json") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		ok, errFile := migrateAuthFile(path, convert)
+		switch {
+		case errFile != nil:
+			log.Errorf("%s: %v", entry.Name(), errFile)
+			failed++
+		case ok:
+			changed++
+		default:
+			skipped++
+		}
+	}
+	fmt.Printf("%d auth files %s, %d unchanged, %d failed\n", changed, action, skipped, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// migrateAuthFile converts one auth file and reports whether it was rewritten.
+func migrateAuthFile(path string, convert func([]byte) ([]byte, bool, error)) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	out, ok, err := convert(data)
+	if err != nil || !ok {
+		return false, err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, out, info.Mode().Perm()); err != nil {
+		return false, err
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return false, err
+	}
+	return true, nil
+}
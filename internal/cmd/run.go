@@ -12,6 +12,8 @@ import (
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/upgrade"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy"
 	log "github.com/sirupsen/logrus"
@@ -44,6 +46,15 @@ func StartService(cfg *config.Config, configPath string, localPassword string) {
 		}))
 	}
 
+	// SIGUSR2 hands the listeners over to a freshly started binary and drains this process.
+	runCtx, upgradeCancel := context.WithCancel(runCtx)
+	defer upgradeCancel()
+	go upgrade.WatchSignal(runCtx, upgradeCancel)
+
+	if errTracing := tracing.Setup(context.Background(), cfg.Tracing); errTracing != nil {
+		log.Errorf("failed to initialise tracing: %v", errTracing)
+	}
+
 	service, err := builder.Build()
 	if err != nil {
 		log.Fatalf("failed to build proxy service: %v", err)
@@ -53,6 +64,12 @@ func StartService(cfg *config.Config, configPath string, localPassword string) {
 
 	usage.StopMetricsPersistence()
 
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if errTracing := tracing.Shutdown(shutdownCtx); errTracing != nil {
+		log.Errorf("failed to flush traces: %v", errTracing)
+	}
+	shutdownCancel()
+
 	if err != nil && !errors.Is(err, context.Canceled) {
 		log.Fatalf("proxy service exited with error: %v", err)
 	}
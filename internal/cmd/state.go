@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/statearchive"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/term"
+)
+
+// StatePassphraseEnv is the environment variable holding the state archive passphrase.
+// Without it the passphrase is read from the terminal.
+const StatePassphraseEnv = "CLIPROXY_STATE_PASSPHRASE"
+
+// DoExportState writes the configuration, auth files and usage history of this instance
+// into an encrypted archive, for moving the proxy to another host.
+//
+// Parameters:
+//   - cfg: The application configuration
+//   - configPath: The configuration file to bundle
+//   - archivePath: The archive file to write
+func DoExportState(cfg *config.Config, configPath, archivePath string) {
+	passphrase := statePassphrase(true)
+	state, err := statearchive.Collect(configPath, cfg.AuthDir, usage.GetRequestStatistics())
+	if err != nil {
+		log.Fatalf("failed to collect state: %v", err)
+	}
+	archive, err := statearchive.Seal(state, passphrase)
+	if err != nil {
+		log.Fatalf("failed to seal state archive: %v", err)
+	}
+	if err = os.WriteFile(archivePath, archive, 0o600); err != nil {
+		log.Fatalf("failed to write state archive: %v", err)
+	}
+	fmt.Printf("exported config, %d auth files and %d usage records to %s\n", len(state.AuthFiles), len(state.Usage), archivePath)
+}
+
+// DoImportState restores an archive written by DoExportState: the configuration replaces
+// the one at configPath (the previous file is kept with a .bak suffix), the auth files are
+// written to the restored configuration's auth directory and the usage history is merged
+// into this instance's usage statistics.
+//
+// Parameters:
+//   - cfg: The application configuration in effect before the import
+//   - configPath: The configuration file to replace
+//   - archivePath: The archive file to restore
+func DoImportState(cfg *config.Config, configPath, archivePath string) {
+	data, err := os.ReadFile(archivePath)
+	if err != nil {
+		log.Fatalf("failed to read state archive: %v", err)
+	}
+	state, err := statearchive.Open(data, statePassphrase(false))
+	if err != nil {
+		log.Fatalf("failed to open state archive: %v", err)
+	}
+
+	tmp := configPath + ".import"
+	if err = os.WriteFile(tmp, state.Config, 0o600); err != nil {
+		log.Fatalf("failed to write config: %v", err)
+	}
+	restored, err := config.LoadConfigOptional(tmp, false)
+	if err != nil {
+		_ = os.Remove(tmp)
+		log.Fatalf("archive holds an invalid config: %v", err)
+	}
+	if previous, errRead := os.ReadFile(configPath); errRead == nil {
+		if err = os.WriteFile(configPath+".bak", previous, 0o600); err != nil {
+			_ = os.Remove(tmp)
+			log.Fatalf("failed to back up config: %v", err)
+		}
+	}
+	if err = os.Rename(tmp, configPath); err != nil {
+		_ = os.Remove(tmp)
+		log.Fatalf("failed to replace config: %v", err)
+	}
+
+	if err = authcrypt.Configure(restored.AuthEncryption); err != nil {
+		log.Fatalf("failed to configure auth encryption: %v", err)
+	}
+	authDir, err := util.ResolveAuthDir(restored.AuthDir)
+	if err != nil {
+		log.Fatalf("failed to resolve auth directory: %v", err)
+	}
+	paths, err := statearchive.WriteAuthFiles(state, authDir)
+	if err != nil {
+		log.Fatalf("failed to restore auth files: %v", err)
+	}
+
+	imported := usage.GetRequestStatistics().Import(state.Usage)
+	// Flush the imported history to the usage store before exiting.
+	usage.StopMetricsPersistence()
+	if cfg != nil && (restored.MetricsFile != cfg.MetricsFile || !reflect.DeepEqual(restored.UsageStore, cfg.UsageStore) || restored.SharedState.Enable != cfg.SharedState.Enable) {
+		log.Warnf("the restored config uses a different usage store; usage history was written to the store of the previous config")
+	}
+	fmt.Printf("imported config, %d auth files into %s and %d usage records from %s\n", len(paths), authDir, imported, archivePath)
+}
+
+// statePassphrase returns the archive passphrase from StatePassphraseEnv or the terminal,
+// asking twice when confirm is set.
+func statePassphrase(confirm bool) string {
+	if passphrase := os.Getenv(StatePassphraseEnv); passphrase != "" {
+		return passphrase
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		log.Fatalf("no passphrase: set %s or run in a terminal", StatePassphraseEnv)
+	}
+	fmt.Print("State archive passphrase: ")
+	first, err := term.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		log.Fatalf("failed to read passphrase: %v", err)
+	}
+	passphrase := strings.TrimSpace(string(first))
+	if passphrase == "" {
+		log.Fatalf("passphrase must not be empty")
+	}
+	if confirm {
+		fmt.Print("Repeat passphrase: ")
+		second, errRepeat := term.ReadPassword(fd)
+		fmt.Println()
+		if errRepeat != nil {
+			log.Fatalf("failed to read passphrase: %v", errRepeat)
+		}
+		if strings.TrimSpace(string(second)) != passphrase {
+			log.Fatalf("passphrases do not match")
+		}
+	}
+	return passphrase
+}
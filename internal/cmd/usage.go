@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers/metrics"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// sparkBlocks are the bars of a sparkline, from lowest to highest.
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// RunUsage implements the "usage" subcommand: it queries the metrics endpoint of a running
+// proxy and renders the totals, a per-model table and a sparkline of requests over time.
+//
+// Parameters:
+//   - args: The arguments following the subcommand name
+//   - defaultConfigPath: The configuration file used to locate the proxy when --url is not set
+//
+// Returns:
+//   - int: The process exit code
+func RunUsage(args []string, defaultConfigPath string) int {
+	fs := flag.NewFlagSet("usage", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "Configuration file used to locate the proxy")
+	baseURL := fs.String("url", "", "Base URL of the proxy (default derived from the configuration)")
+	from := fs.String("from", "", "Start of the period: RFC 3339 timestamp or duration ago, e.g. 24h (default 24h)")
+	to := fs.String("to", "", "End of the period: RFC 3339 timestamp or duration ago (default now)")
+	model := fs.String("model", "", "Only count requests for this model")
+	bucket := fs.String("bucket", "1h", "Sparkline bucket size: 1m, 5m, 1h or 1d")
+	insecure := fs.Bool("insecure", false, "Skip TLS certificate verification")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	now := time.Now()
+	fromTime, err := parseUsageTime(*from, now)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid --from: %v\n", err)
+		return 2
+	}
+	toTime, err := parseUsageTime(*to, now)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid --to: %v\n", err)
+		return 2
+	}
+	if fromTime.IsZero() {
+		fromTime = now.Add(-24 * time.Hour)
+	}
+	if toTime.IsZero() {
+		toTime = now
+	}
+
+	endpoint := strings.TrimRight(*baseURL, "/")
+	if endpoint == "" {
+		endpoint = localServerURL(*configPath)
+	}
+	query := url.Values{}
+	query.Set("from", fromTime.UTC().Format(time.RFC3339))
+	query.Set("to", toTime.UTC().Format(time.RFC3339))
+	query.Set("bucket", *bucket)
+	if *model != "" {
+		query.Set("model", *model)
+	}
+
+	resp, err := newCLIClient(*insecure).Get(endpoint + "/_qs/metrics?" + query.Encode())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to query %s: %v\n", endpoint, err)
+		return 1
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read metrics: %v\n", err)
+		return 1
+	}
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "metrics endpoint returned %d: %s\n", resp.StatusCode, strings.TrimSpace(string(body)))
+		return 1
+	}
+	var metricsResp metrics.MetricsResponse
+	if err = json.Unmarshal(body, &metricsResp); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to decode metrics: %v\n", err)
+		return 1
+	}
+	renderUsage(os.Stdout, metricsResp, fromTime, toTime, *model)
+	return 0
+}
+
+// parseUsageTime accepts an RFC 3339 timestamp or a duration before now; empty yields zero.
+func parseUsageTime(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if ago, err := time.ParseDuration(value); err == nil {
+		return now.Add(-ago), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// newCLIClient returns the HTTP client subcommands use to query the running proxy.
+func newCLIClient(insecure bool) *http.Client {
+	client := &http.Client{Timeout: 30 * time.Second}
+	if insecure {
+		client.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	}
+	return client
+}
+
+// localServerURL derives the address of the local proxy from its configuration, falling back
+// to the default port when the configuration cannot be read.
+func localServerURL(configPath string) string {
+	scheme, port := "http", 8317
+	if configPath == "" {
+		configPath = "config.yaml"
+	}
+	if cfg, err := config.LoadConfigOptional(configPath, true); err == nil && cfg != nil {
+		if cfg.Port > 0 {
+			port = cfg.Port
+		}
+		if cfg.TLS.Enable {
+			scheme = "https"
+		}
+	}
+	return fmt.Sprintf("%s://127.0.0.1:%d", scheme, port)
+}
+
+// renderUsage writes the usage report for the queried period to out.
+func renderUsage(out io.Writer, resp metrics.MetricsResponse, from, to time.Time, model string) {
+	period := fmt.Sprintf("%s - %s", from.Local().Format("2006-01-02 15:04"), to.Local().Format("2006-01-02 15:04"))
+	if model != "" {
+		period += ", model " + model
+	}
+	_, _ = fmt.Fprintf(out, "Usage %s\n\n", period)
+
+	totals := resp.Totals
+	_, _ = fmt.Fprintf(out, "Requests  %d", totals.Requests)
+	if totals.Retries > 0 {
+		_, _ = fmt.Fprintf(out, " (%d retries)", totals.Retries)
+	}
+	if totals.Failures > 0 {
+		_, _ = fmt.Fprintf(out, ", %d failed", totals.Failures)
+	}
+	_, _ = fmt.Fprintf(out, "\nTokens    %d\nCost      $%.4f\n", totals.Tokens, totals.Cost)
+	if totals.TTFTMS != nil {
+		_, _ = fmt.Fprintf(out, "TTFT      p50 %.0fms  p95 %.0fms  p99 %.0fms\n", totals.TTFTMS.P50, totals.TTFTMS.P95, totals.TTFTMS.P99)
+	}
+	if totals.LatencyMS != nil {
+		_, _ = fmt.Fprintf(out, "Latency   p50 %.0fms  p90 %.0fms  p99 %.0fms\n", totals.LatencyMS.P50, totals.LatencyMS.P90, totals.LatencyMS.P99)
+	}
+
+	if len(resp.ByModel) > 0 {
+		_, _ = fmt.Fprintln(out)
+		tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "MODEL\tREQUESTS\tTOKENS\tCOST\t")
+		for _, m := range resp.ByModel {
+			_, _ = fmt.Fprintf(tw, "%s\t%d\t%d\t$%.4f\t\n", m.Model, m.Requests, m.Tokens, m.Cost)
+		}
+		_ = tw.Flush()
+	}
+
+	if len(resp.Errors) > 0 {
+		_, _ = fmt.Fprintln(out)
+		tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "ERROR\tPROVIDER\tMODEL\tCOUNT\t")
+		for _, e := range resp.Errors {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t\n", e.Code, e.Provider, e.Model, e.Count)
+		}
+		_ = tw.Flush()
+	}
+
+	if len(resp.Timeseries) > 0 {
+		requests := make([]int64, len(resp.Timeseries))
+		var peak int64
+		for i, b := range resp.Timeseries {
+			requests[i] = b.Requests
+			peak = max(peak, b.Requests)
+		}
+		_, _ = fmt.Fprintf(out, "\nRequests over time (peak %d per bucket)\n%s\n", peak, sparkline(requests))
+	}
+}
+
+// sparkline renders values as a line of block characters scaled to the largest value.
+func sparkline(values []int64) string {
+	var peak int64
+	for _, v := range values {
+		peak = max(peak, v)
+	}
+	var b strings.Builder
+	for _, v := range values {
+		if peak == 0 {
+			b.WriteRune(sparkBlocks[0])
+			continue
+		}
+		b.WriteRune(sparkBlocks[int(v*int64(len(sparkBlocks)-1)/peak)])
+	}
+	return b.String()
+}
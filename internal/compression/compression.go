@@ -0,0 +1,155 @@
+// Package compression negotiates and creates the encoders of compressed responses.
+package compression
+
+import (
+	"compress/gzip"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultMinSize       = 1024
+	defaultGzipLevel     = 6
+	defaultBrotliQuality = 4
+)
+
+// Compressor decides which responses are compressed and with which encoding. It is safe
+// for concurrent use.
+type Compressor struct {
+	mu            sync.RWMutex
+	enabled       bool
+	encodings     []string
+	minSize       int
+	gzipLevel     int
+	brotliQuality int
+	routes        []string
+	excludeRoutes []string
+}
+
+// New creates a compressor for cfg.
+func New(cfg config.CompressionConfig) *Compressor {
+	c := &Compressor{}
+	c.Configure(cfg)
+	return c
+}
+
+// Configure applies cfg.
+func (c *Compressor) Configure(cfg config.CompressionConfig) {
+	encodings := make([]string, 0, 2)
+	for _, encoding := range cfg.Encodings {
+		encoding = strings.ToLower(strings.TrimSpace(encoding))
+		switch encoding {
+		case config.CompressionBrotli, config.CompressionGzip:
+			encodings = append(encodings, encoding)
+		default:
+			log.Warnf("compression: skipping unsupported encoding %q", encoding)
+		}
+	}
+	if len(encodings) == 0 {
+		encodings = []string{config.CompressionBrotli, config.CompressionGzip}
+	}
+	minSize := cfg.MinSize
+	if minSize <= 0 {
+		minSize = defaultMinSize
+	}
+	gzipLevel := cfg.GzipLevel
+	if gzipLevel < gzip.BestSpeed || gzipLevel > gzip.BestCompression {
+		gzipLevel = defaultGzipLevel
+	}
+	brotliQuality := cfg.BrotliQuality
+	if brotliQuality <= 0 || brotliQuality > brotli.BestCompression {
+		brotliQuality = defaultBrotliQuality
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.enabled = cfg.Enable
+	c.encodings = encodings
+	c.minSize = minSize
+	c.gzipLevel = gzipLevel
+	c.brotliQuality = brotliQuality
+	c.routes = append([]string(nil), cfg.Routes...)
+	c.excludeRoutes = append([]string(nil), cfg.ExcludeRoutes...)
+}
+
+// Negotiate returns the encoding of the response to a request for path with the given
+// Accept-Encoding header and the minimum size to compress, or an empty encoding when the
+// response is sent uncompressed.
+func (c *Compressor) Negotiate(path, acceptEncoding string) (string, int) {
+	if c == nil || acceptEncoding == "" {
+		return "", 0
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.enabled || matchRoute(c.excludeRoutes, path) || (len(c.routes) > 0 && !matchRoute(c.routes, path)) {
+		return "", 0
+	}
+	accepted := parseAcceptEncoding(acceptEncoding)
+	best, bestQ := "", 0.0
+	for _, encoding := range c.encodings {
+		q, ok := accepted[encoding]
+		if !ok {
+			q, ok = accepted["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best, c.minSize
+}
+
+// NewEncoder returns a writer that compresses into w with encoding.
+func (c *Compressor) NewEncoder(w io.Writer, encoding string) io.WriteCloser {
+	c.mu.RLock()
+	gzipLevel, brotliQuality := c.gzipLevel, c.brotliQuality
+	c.mu.RUnlock()
+	if encoding == config.CompressionBrotli {
+		return brotli.NewWriterLevel(w, brotliQuality)
+	}
+	encoder, _ := gzip.NewWriterLevel(w, gzipLevel)
+	return encoder
+}
+
+// parseAcceptEncoding returns the quality of each coding in an Accept-Encoding header.
+func parseAcceptEncoding(header string) map[string]float64 {
+	accepted := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.EqualFold(name, "q") {
+				if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		accepted[coding] = q
+	}
+	return accepted
+}
+
+// matchRoute reports whether path matches one of patterns, exactly or by the prefix before
+// a trailing "*".
+func matchRoute(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		} else if pattern == path {
+			return true
+		}
+	}
+	return false
+}
@@ -7,11 +7,14 @@ package config
 import (
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
+	"regexp"
 	"strings"
 	"syscall"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/routingrule"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
 	"golang.org/x/crypto/bcrypt"
 	"gopkg.in/yaml.v3"
@@ -23,53 +26,1543 @@ type Config struct {
 	// Port is the network port on which the API server will listen.
 	Port int `yaml:"port" json:"-"`
 
+	// ReusePort opens the listening sockets with SO_REUSEPORT, so a second proxy process can
+	// bind the same port while the first one drains.
+	ReusePort bool `yaml:"reuse-port,omitempty" json:"-"`
+
+	// TLS serves the API over HTTPS, optionally requiring client certificates.
+	TLS TLSConfig `yaml:"tls,omitempty" json:"-"`
+
+	// UnixSocket serves the API on a Unix domain socket, in addition to or instead of TCP.
+	UnixSocket UnixSocketConfig `yaml:"unix-socket,omitempty" json:"-"`
+
+	// ManagementListener serves the management API and control panel on a listener of their
+	// own instead of the API port.
+	ManagementListener ManagementListenerConfig `yaml:"management-listener,omitempty" json:"-"`
+
 	// AuthDir is the directory where authentication token files are stored.
 	AuthDir string `yaml:"auth-dir" json:"-"`
 
-	// Debug enables or disables debug-level logging and other debug features.
-	Debug bool `yaml:"debug" json:"debug"`
+	// Debug enables or disables debug-level logging and other debug features.
+	Debug bool `yaml:"debug" json:"debug"`
+
+	// LoggingToFile controls whether application logs are written to rotating files or stdout.
+	LoggingToFile bool `yaml:"logging-to-file" json:"logging-to-file"`
+
+	// UsageStatisticsEnabled toggles in-memory usage aggregation; when false, usage data is discarded.
+	UsageStatisticsEnabled bool `yaml:"usage-statistics-enabled" json:"usage-statistics-enabled"`
+
+	// DisableCooling disables quota cooldown scheduling when true.
+	DisableCooling bool `yaml:"disable-cooling" json:"disable-cooling"`
+
+	// QuotaExceeded defines the behavior when a quota is exceeded.
+	QuotaExceeded QuotaExceeded `yaml:"quota-exceeded" json:"quota-exceeded"`
+
+	// WebsocketAuth enables or disables authentication for the WebSocket API.
+	WebsocketAuth bool `yaml:"ws-auth" json:"ws-auth"`
+
+	// GlAPIKey is the API key for the generative language API.
+	GlAPIKey []string `yaml:"generative-language-api-key" json:"generative-language-api-key"`
+
+	// RequestRetry defines the retry times when the request failed.
+	RequestRetry int `yaml:"request-retry" json:"request-retry"`
+
+	// ClaudeKey defines a list of Claude API key configurations as specified in the YAML configuration file.
+	ClaudeKey []ClaudeKey `yaml:"claude-api-key" json:"claude-api-key"`
+
+	// Codex defines a list of Codex API key configurations as specified in the YAML configuration file.
+	CodexKey []CodexKey `yaml:"codex-api-key" json:"codex-api-key"`
+
+	// BedrockKey defines a list of AWS Bedrock credential configurations as specified in the YAML configuration file.
+	BedrockKey []BedrockKey `yaml:"bedrock-api-key" json:"bedrock-api-key"`
+
+	// AzureOpenAI defines a list of Azure OpenAI resource configurations as specified in the YAML configuration file.
+	AzureOpenAI []AzureOpenAIKey `yaml:"azure-openai" json:"azure-openai"`
+
+	// VertexAI defines a list of Google Vertex AI project configurations as specified in the YAML configuration file.
+	VertexAI []VertexAIKey `yaml:"vertex-ai" json:"vertex-ai"`
+
+	// XAIKey defines a list of xAI API key configurations as specified in the YAML configuration file.
+	XAIKey []XAIKey `yaml:"xai-api-key" json:"xai-api-key"`
+
+	// OpenAICompatibility defines OpenAI API compatibility configurations for external providers.
+	OpenAICompatibility []OpenAICompatibility `yaml:"openai-compatibility" json:"openai-compatibility"`
+
+	// LocalBackends defines local inference servers, such as Ollama, llama.cpp or vLLM, whose
+	// models are discovered from the server and routed like those of the other providers.
+	LocalBackends []LocalBackend `yaml:"local-backends" json:"local-backends"`
+
+	// ProviderPlugins defines upstream providers implemented as plugins, either registered
+	// in-process through the plugin SDK or run as external processes.
+	ProviderPlugins []ProviderPlugin `yaml:"provider-plugins" json:"provider-plugins"`
+
+	// RemoteManagement nests management-related options under 'remote-management'.
+	RemoteManagement RemoteManagement `yaml:"remote-management" json:"-"`
+
+	// MetricsFile is the path to the JSON file where metrics will be stored.
+	MetricsFile string `yaml:"metrics-file,omitempty" json:"metrics-file,omitempty"`
+
+	// LoopDelay is the interval at which metrics are saved to the file.
+	LoopDelay time.Duration `yaml:"loop-delay,omitempty" json:"loop-delay,omitempty"`
+
+	// CrashOnError determines if the application should crash if saving metrics fails.
+	CrashOnError bool `yaml:"crash-on-error,omitempty" json:"crash-on-error,omitempty"`
+
+	// UsageStore configures the persistent usage statistics backend.
+	UsageStore UsageStore `yaml:"usage-store,omitempty" json:"usage-store,omitempty"`
+
+	// UsageRetention configures how long raw usage details and their hourly and daily
+	// aggregates are kept in memory.
+	UsageRetention UsageRetention `yaml:"usage-retention,omitempty" json:"usage-retention,omitempty"`
+
+	// Pricing lists per-model token prices used to estimate spend in the metrics endpoints.
+	Pricing []ModelPrice `yaml:"pricing,omitempty" json:"pricing,omitempty"`
+
+	// Tracing configures OpenTelemetry tracing of the request pipeline.
+	Tracing TracingConfig `yaml:"tracing,omitempty" json:"tracing,omitempty"`
+
+	// StatsD configures pushing request metrics to a StatsD or DogStatsD agent.
+	StatsD StatsDConfig `yaml:"statsd,omitempty" json:"statsd,omitempty"`
+
+	// AccessLog configures structured per-request access logging.
+	AccessLog AccessLogConfig `yaml:"access-log,omitempty" json:"access-log,omitempty"`
+
+	// AuditLog records management API changes.
+	AuditLog AuditLogConfig `yaml:"audit-log,omitempty" json:"audit-log,omitempty"`
+
+	// AuthEncryption encrypts auth files at rest.
+	AuthEncryption AuthEncryption `yaml:"auth-encryption,omitempty" json:"auth-encryption,omitempty"`
+
+	// Secrets configures the secrets managers that other settings can reference.
+	Secrets Secrets `yaml:"secrets,omitempty" json:"secrets,omitempty"`
+
+	// Compression compresses JSON responses for clients that accept it.
+	Compression CompressionConfig `yaml:"compression,omitempty" json:"compression,omitempty"`
+
+	// RequestLimits bounds the size of request bodies and how uploads are buffered.
+	RequestLimits RequestLimitsConfig `yaml:"request-limits,omitempty" json:"request-limits,omitempty"`
+
+	// BodyCapture keeps redacted request and response bodies for debugging.
+	BodyCapture BodyCaptureConfig `yaml:"body-capture,omitempty" json:"body-capture,omitempty"`
+
+	// APIKeyQuotas limits daily and monthly usage per inbound API key.
+	APIKeyQuotas []APIKeyQuota `yaml:"api-key-quotas,omitempty" json:"api-key-quotas,omitempty"`
+
+	// AccountQuotas describes the usage windows of upstream accounts, used to project when
+	// each account runs out of quota.
+	AccountQuotas []AccountQuota `yaml:"account-quotas,omitempty" json:"account-quotas,omitempty"`
+
+	// RateLimits caps requests and tokens per minute globally, per client API key and per model.
+	RateLimits []RateLimit `yaml:"rate-limits,omitempty" json:"rate-limits,omitempty"`
+
+	// Guardrails caps the output tokens and prompt sizes of requests per client API key.
+	Guardrails []Guardrail `yaml:"guardrails,omitempty" json:"guardrails,omitempty"`
+
+	// Moderation screens request content against blocklists and an external moderation
+	// endpoint before it is sent upstream.
+	Moderation Moderation `yaml:"moderation,omitempty" json:"moderation,omitempty"`
+
+	// PIIRedaction masks personal data in prompts before they are sent upstream.
+	PIIRedaction PIIRedaction `yaml:"pii-redaction,omitempty" json:"pii-redaction,omitempty"`
+
+	// Hooks call external HTTP endpoints or WASM modules before requests are dispatched
+	// and after responses are produced, to inspect, change or reject them.
+	Hooks []Hook `yaml:"hooks,omitempty" json:"hooks,omitempty"`
+
+	// SystemPrompts injects policy prompts into the requests of matching keys and models.
+	SystemPrompts SystemPromptsConfig `yaml:"system-prompts,omitempty" json:"system-prompts,omitempty"`
+
+	// Sessions keeps the conversation history of clients that send a session ID, so they
+	// only need to send their newest messages.
+	Sessions SessionsConfig `yaml:"sessions,omitempty" json:"sessions,omitempty"`
+
+	// Shadow duplicates a sample of requests to candidate models and stores both responses
+	// for offline comparison.
+	Shadow ShadowConfig `yaml:"shadow,omitempty" json:"shadow,omitempty"`
+
+	// Shutdown configures how in-flight responses are drained on shutdown.
+	Shutdown ShutdownConfig `yaml:"shutdown,omitempty" json:"shutdown,omitempty"`
+
+	// ModelDiscovery periodically queries upstreams for their models to keep /v1/models live.
+	ModelDiscovery ModelDiscovery `yaml:"model-discovery,omitempty" json:"model-discovery,omitempty"`
+
+	// Admission caps concurrent upstream requests and queues the excess by API key priority.
+	Admission Admission `yaml:"admission,omitempty" json:"admission,omitempty"`
+
+	// Files configures the OpenAI compatible Files API under /v1/files.
+	Files FilesConfig `yaml:"files,omitempty" json:"files,omitempty"`
+
+	// Prompts configures the prompt template library under /v1/prompts.
+	Prompts PromptsConfig `yaml:"prompts,omitempty" json:"prompts,omitempty"`
+
+	// Batch configures the OpenAI compatible Batch API under /v1/batches.
+	Batch BatchConfig `yaml:"batch,omitempty" json:"batch,omitempty"`
+
+	// GeminiContextCache moves large repeated system prompts of Gemini requests into
+	// upstream cachedContents.
+	GeminiContextCache GeminiContextCache `yaml:"gemini-context-cache,omitempty" json:"gemini-context-cache,omitempty"`
+
+	// Routing configures how requests are spread across the accounts of each provider.
+	Routing []RoutingPolicy `yaml:"routing,omitempty" json:"routing,omitempty"`
+
+	// Projects groups inbound API keys into isolated tenants with their own models,
+	// routing, quotas and usage statistics.
+	Projects []Project `yaml:"projects,omitempty" json:"projects,omitempty"`
+
+	// HealthCheck configures passive health tracking of upstream accounts.
+	HealthCheck HealthCheck `yaml:"health-check,omitempty" json:"health-check,omitempty"`
+
+	// AccountProxies routes the outbound traffic of matching upstream accounts through
+	// their own proxy instead of the global proxy-url.
+	AccountProxies []AccountProxy `yaml:"account-proxies,omitempty" json:"account-proxies,omitempty"`
+
+	// ProxyCheck configures active health checks of the proxies used by upstream accounts.
+	ProxyCheck ProxyCheck `yaml:"proxy-check,omitempty" json:"proxy-check,omitempty"`
+
+	// UpstreamTransport tunes the pooled HTTP connections to upstream providers.
+	UpstreamTransport UpstreamTransport `yaml:"upstream-transport,omitempty" json:"upstream-transport,omitempty"`
+
+	// UpstreamTimeouts bounds each phase of upstream requests, globally and per provider.
+	UpstreamTimeouts UpstreamTimeouts `yaml:"upstream-timeouts,omitempty" json:"upstream-timeouts,omitempty"`
+
+	// Retry configures backoff between request retries; the number of retries is RequestRetry.
+	Retry RetryPolicy `yaml:"retry,omitempty" json:"retry,omitempty"`
+
+	// CircuitBreaker configures per-account circuit breaking.
+	CircuitBreaker CircuitBreaker `yaml:"circuit-breaker,omitempty" json:"circuit-breaker,omitempty"`
+
+	// AccountConcurrency caps the simultaneous requests served by upstream accounts.
+	AccountConcurrency []AccountConcurrency `yaml:"account-concurrency,omitempty" json:"account-concurrency,omitempty"`
+
+	// TokenRefresh configures the background renewal of upstream OAuth tokens.
+	TokenRefresh TokenRefresh `yaml:"token-refresh,omitempty" json:"token-refresh,omitempty"`
+
+	// StickySessions pins conversations to the upstream account that served them first.
+	StickySessions StickySessions `yaml:"sticky-sessions,omitempty" json:"sticky-sessions,omitempty"`
+
+	// Redis configures the Redis server used by features with a shared backend.
+	Redis Redis `yaml:"redis,omitempty" json:"redis,omitempty"`
+
+	// ResponseCache configures caching of responses to deterministic requests.
+	ResponseCache ResponseCache `yaml:"response-cache,omitempty" json:"response-cache,omitempty"`
+
+	// RequestDedup attaches identical concurrent requests to one upstream call.
+	RequestDedup RequestDedup `yaml:"request-dedup,omitempty" json:"request-dedup,omitempty"`
+
+	// SharedState keeps usage statistics, quota counters and account cooldowns in Redis so
+	// that several proxy instances share one view.
+	SharedState SharedState `yaml:"shared-state,omitempty" json:"shared-state,omitempty"`
+
+	// secretRefs maps the values resolved from secret references back to the references,
+	// so that saving the configuration never writes a secret into the file.
+	secretRefs map[string]string
+
+	// envOverrides are the paths of the settings set by CLIPROXY_ environment variables,
+	// which are not written into the file either.
+	envOverrides [][]string
+}
+
+// SharedState configures state shared between proxy instances through the Redis server
+// configured under Redis. Changes take effect on restart.
+type SharedState struct {
+	// Enable stores usage statistics, API key quota counters and account cooldowns in Redis.
+	// The usage-store type and metrics-file are ignored while enabled.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// SyncInterval is how often statistics and cooldowns recorded by other instances are
+	// pulled, and local statistics are pushed; defaults to 5s.
+	SyncInterval time.Duration `yaml:"sync-interval,omitempty" json:"sync-interval,omitempty"`
+
+	// MaxUsageEntries caps the shared usage stream at about this many request details, the
+	// oldest being trimmed as new ones are added; defaults to 1000000.
+	MaxUsageEntries int64 `yaml:"max-usage-entries,omitempty" json:"max-usage-entries,omitempty"`
+}
+
+// Redis configures the connection to a Redis server.
+type Redis struct {
+	// Addr is the host:port of the server.
+	Addr string `yaml:"addr" json:"addr"`
+
+	// Username is the ACL user; leave empty to authenticate with the password only.
+	Username string `yaml:"username,omitempty" json:"username,omitempty"`
+
+	// Password authenticates the connection when set.
+	Password string `yaml:"password,omitempty" json:"-"`
+
+	// DB selects the logical database.
+	DB int `yaml:"db,omitempty" json:"db,omitempty"`
+
+	// TLS connects over TLS.
+	TLS bool `yaml:"tls,omitempty" json:"tls,omitempty"`
+
+	// KeyPrefix is prepended to every key written by the proxy; defaults to "cliproxy:".
+	KeyPrefix string `yaml:"key-prefix,omitempty" json:"key-prefix,omitempty"`
+
+	// PoolSize is the number of idle connections kept open; defaults to 8.
+	PoolSize int `yaml:"pool-size,omitempty" json:"pool-size,omitempty"`
+}
+
+// ResponseCache configures the response cache. Only deterministic requests, i.e. those that
+// set temperature to 0, are cached; the key covers the model and the normalized request body.
+type ResponseCache struct {
+	// Enable turns on response caching.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Backend selects where responses are stored: "memory" (default) or "redis".
+	Backend string `yaml:"backend,omitempty" json:"backend,omitempty"`
+
+	// TTL is how long a cached response is served; defaults to 10m.
+	TTL time.Duration `yaml:"ttl,omitempty" json:"ttl,omitempty"`
+
+	// MaxEntries caps the number of responses kept by the memory backend; defaults to 1000.
+	MaxEntries int `yaml:"max-entries,omitempty" json:"max-entries,omitempty"`
+
+	// MaxEntryBytes is the largest response that is cached; defaults to 1 MiB.
+	MaxEntryBytes int `yaml:"max-entry-bytes,omitempty" json:"max-entry-bytes,omitempty"`
+}
+
+// RequestDedup configures single-flight execution of identical requests. A request that
+// arrives while an identical one, i.e. one with the same inbound API key, model, format and
+// normalized body, is still running waits for that call and receives its response or stream
+// instead of calling the upstream again.
+type RequestDedup struct {
+	// Enable turns on request deduplication.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// MaxStreamBytes is how much of a stream is buffered for attached callers. Once a stream is
+	// larger, its oldest chunks are dropped: new identical requests run on their own and
+	// attached callers that fall further behind fail with a retryable error. Defaults to 1 MiB.
+	MaxStreamBytes int `yaml:"max-stream-bytes,omitempty" json:"max-stream-bytes,omitempty"`
+}
+
+// StickySessions configures conversation affinity. Requests of one conversation are routed to
+// the same account as long as it stays available, so provider-side context caching keeps working.
+// A conversation is identified by a client-provided session ID (X-Session-Id header,
+// conversation_id/session_id/prompt_cache_key fields or the Claude Code session) or, with
+// HashPrefix, by a hash of the system prompt and first message.
+type StickySessions struct {
+	// Enable turns on sticky sessions.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// TTL is how long an idle conversation stays pinned; defaults to 1h.
+	TTL time.Duration `yaml:"ttl,omitempty" json:"ttl,omitempty"`
+
+	// HashPrefix identifies conversations without a session ID by hashing their opening messages.
+	HashPrefix bool `yaml:"hash-prefix,omitempty" json:"hash-prefix,omitempty"`
+
+	// MaxSessions caps the number of pinned conversations; the least recently used is evicted. Defaults to 10000.
+	MaxSessions int `yaml:"max-sessions,omitempty" json:"max-sessions,omitempty"`
+}
+
+// RetryPolicy configures how failed upstream requests are retried.
+// A retry runs the whole request again, including failover across accounts and providers,
+// after an exponential backoff with jitter. A Retry-After hint from the upstream replaces the
+// backoff when it is longer; hints above MaxRetryAfter end retrying instead of waiting.
+type RetryPolicy struct {
+	// Backoff is the delay before the first retry, doubled on every further retry; defaults to 500ms.
+	Backoff time.Duration `yaml:"backoff,omitempty" json:"backoff,omitempty"`
+
+	// MaxBackoff caps the computed backoff; defaults to 10s.
+	MaxBackoff time.Duration `yaml:"max-backoff,omitempty" json:"max-backoff,omitempty"`
+
+	// MaxRetryAfter is the longest upstream Retry-After hint that is waited for; defaults to 30s.
+	MaxRetryAfter time.Duration `yaml:"max-retry-after,omitempty" json:"max-retry-after,omitempty"`
+
+	// RetryOn lists the HTTP status codes that trigger a retry; defaults to 403, 408, 500, 502, 503 and 504.
+	// Transport errors without a status code are always retried.
+	RetryOn []int `yaml:"retry-on,omitempty" json:"retry-on,omitempty"`
+}
+
+// CircuitBreaker configures a circuit breaker per upstream account.
+// A closed circuit opens after FailureThreshold consecutive failures or once the error rate
+// over the last Window requests reaches ErrorRate. An open circuit rejects the account until
+// Cooldown has elapsed, then half-opens and lets a single probe request through: success
+// closes the circuit, failure opens it again.
+type CircuitBreaker struct {
+	// Enable turns on circuit breaking.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// FailureThreshold is the number of consecutive failures that opens the circuit; defaults to 5.
+	FailureThreshold int `yaml:"failure-threshold,omitempty" json:"failure-threshold,omitempty"`
+
+	// ErrorRate is the failure ratio (0-1] over the window that opens the circuit; 0 disables the check.
+	ErrorRate float64 `yaml:"error-rate,omitempty" json:"error-rate,omitempty"`
+
+	// Window is the number of most recent requests the error rate is computed over; defaults to 20.
+	Window int `yaml:"window,omitempty" json:"window,omitempty"`
+
+	// MinRequests is the number of requests in the window required before the error rate applies; defaults to 10.
+	MinRequests int `yaml:"min-requests,omitempty" json:"min-requests,omitempty"`
+
+	// Cooldown is how long a circuit stays open before half-opening; defaults to 30s.
+	Cooldown time.Duration `yaml:"cooldown,omitempty" json:"cooldown,omitempty"`
+}
+
+// Account concurrency modes.
+const (
+	// AccountConcurrencyQueue waits for a slot when every account is at its limit.
+	AccountConcurrencyQueue = "queue"
+	// AccountConcurrencyFail rejects the request right away when every account is at its limit.
+	AccountConcurrencyFail = "fail"
+)
+
+// AccountConcurrency limits the number of requests, streams included, that each matching
+// account serves at the same time. A busy account is skipped in favour of other accounts
+// of the provider; Mode decides what happens when all of them are busy.
+type AccountConcurrency struct {
+	// Provider restricts the entry to one provider, e.g. "claude"; empty matches every provider.
+	Provider string `yaml:"provider,omitempty" json:"provider,omitempty"`
+
+	// Match selects accounts by ID, label, email or auth file name; a trailing "*" matches
+	// by prefix. Empty matches every account of the provider.
+	Match string `yaml:"match,omitempty" json:"match,omitempty"`
+
+	// MaxInFlight is the number of simultaneous requests per account.
+	MaxInFlight int `yaml:"max-in-flight" json:"max-in-flight"`
+
+	// Mode is "queue" (default) to wait for a free account or "fail" to answer 429 at once.
+	Mode string `yaml:"mode,omitempty" json:"mode,omitempty"`
+
+	// QueueTimeout is how long a queued request waits before it fails; defaults to 30s.
+	QueueTimeout time.Duration `yaml:"queue-timeout,omitempty" json:"queue-timeout,omitempty"`
+}
+
+// TokenRefresh configures how OAuth tokens are renewed in the background before they expire.
+// Failed refreshes are retried with exponential backoff; once MaxAttempts refreshes in a row
+// have failed, the refresh counts as permanently failed and is reported.
+type TokenRefresh struct {
+	// Lead renews tokens that expire within this duration even when the provider would
+	// refresh them later; defaults to 5m.
+	Lead time.Duration `yaml:"lead,omitempty" json:"lead,omitempty"`
+
+	// RetryBase is the delay before retrying a failed refresh, doubled on every further failure; defaults to 30s.
+	RetryBase time.Duration `yaml:"retry-base,omitempty" json:"retry-base,omitempty"`
+
+	// RetryMax caps the delay between refresh retries; defaults to 30m.
+	RetryMax time.Duration `yaml:"retry-max,omitempty" json:"retry-max,omitempty"`
+
+	// MaxAttempts is the number of consecutive failures after which a refresh counts as
+	// permanently failed; defaults to 5.
+	MaxAttempts int `yaml:"max-attempts,omitempty" json:"max-attempts,omitempty"`
+
+	// AlertWebhook receives a JSON POST when a refresh permanently fails.
+	AlertWebhook string `yaml:"alert-webhook,omitempty" json:"alert-webhook,omitempty"`
+}
+
+// HealthCheck configures passive error-rate tracking of upstream accounts.
+// An account whose recent error rate reaches the threshold is taken out of rotation for a
+// cooldown that doubles on every repeated exclusion, then automatically re-included.
+type HealthCheck struct {
+	// Enable turns on health tracking.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Window is the number of most recent requests considered per account; defaults to 20.
+	Window int `yaml:"window,omitempty" json:"window,omitempty"`
+
+	// MinRequests is the number of requests in the window required before an account can be excluded; defaults to 5.
+	MinRequests int `yaml:"min-requests,omitempty" json:"min-requests,omitempty"`
+
+	// ErrorRate is the failure ratio (0-1] at which an account is excluded; defaults to 0.5.
+	ErrorRate float64 `yaml:"error-rate,omitempty" json:"error-rate,omitempty"`
+
+	// Cooldown is the first exclusion period; defaults to 1m.
+	Cooldown time.Duration `yaml:"cooldown,omitempty" json:"cooldown,omitempty"`
+
+	// MaxCooldown caps the exclusion period of repeatedly failing accounts; defaults to 15m.
+	MaxCooldown time.Duration `yaml:"max-cooldown,omitempty" json:"max-cooldown,omitempty"`
+}
+
+// AccountProxy assigns an outbound proxy to the upstream accounts it matches. A proxy-url
+// set on the account itself, in its auth file or API key entry, takes precedence.
+type AccountProxy struct {
+	// Match selects accounts by ID, label, email or auth file name; a trailing "*" matches by prefix.
+	Match string `yaml:"match" json:"match"`
+
+	// ProxyURL is the proxy to egress through: socks5://, http:// or https://host:port.
+	ProxyURL string `yaml:"proxy-url" json:"proxy-url"`
+
+	// Username and Password authenticate against the proxy. They replace credentials
+	// embedded in ProxyURL.
+	Username string `yaml:"username,omitempty" json:"username,omitempty"`
+	Password string `yaml:"password,omitempty" json:"-"`
+}
+
+// URL returns the proxy URL with the configured credentials applied.
+func (p AccountProxy) URL() string {
+	if p.Username == "" && p.Password == "" {
+		return p.ProxyURL
+	}
+	parsed, err := url.Parse(p.ProxyURL)
+	if err != nil {
+		return p.ProxyURL
+	}
+	parsed.User = url.UserPassword(p.Username, p.Password)
+	return parsed.String()
+}
+
+// UpstreamTransport configures the HTTP transports shared by upstream requests, one per
+// proxy URL plus one for direct connections. Zero values take the defaults; changes apply
+// to new connections, while open streams keep theirs.
+type UpstreamTransport struct {
+	// MaxIdleConns caps the idle connections kept per transport across all hosts; defaults to 256.
+	MaxIdleConns int `yaml:"max-idle-conns,omitempty" json:"max-idle-conns,omitempty"`
+
+	// MaxIdleConnsPerHost caps the idle connections kept per upstream host; defaults to 64.
+	MaxIdleConnsPerHost int `yaml:"max-idle-conns-per-host,omitempty" json:"max-idle-conns-per-host,omitempty"`
+
+	// MaxConnsPerHost caps the connections per upstream host, including active ones;
+	// requests over the cap wait for a connection. 0 means no cap.
+	MaxConnsPerHost int `yaml:"max-conns-per-host,omitempty" json:"max-conns-per-host,omitempty"`
+
+	// IdleConnTimeout closes connections idle for longer; defaults to 90s.
+	IdleConnTimeout time.Duration `yaml:"idle-conn-timeout,omitempty" json:"idle-conn-timeout,omitempty"`
+
+	// DialTimeout bounds establishing a TCP connection; defaults to 30s.
+	DialTimeout time.Duration `yaml:"dial-timeout,omitempty" json:"dial-timeout,omitempty"`
+
+	// KeepAlive is the TCP keep-alive probe interval; defaults to 30s.
+	KeepAlive time.Duration `yaml:"keep-alive,omitempty" json:"keep-alive,omitempty"`
+
+	// TLSHandshakeTimeout bounds the TLS handshake; defaults to 10s.
+	TLSHandshakeTimeout time.Duration `yaml:"tls-handshake-timeout,omitempty" json:"tls-handshake-timeout,omitempty"`
+
+	// DisableHTTP2 restricts upstream connections to HTTP/1.1.
+	DisableHTTP2 bool `yaml:"disable-http2,omitempty" json:"disable-http2,omitempty"`
+
+	// TLSSessionCacheSize is the number of TLS sessions kept for resumption per transport;
+	// defaults to 256, negative disables resumption.
+	TLSSessionCacheSize int `yaml:"tls-session-cache-size,omitempty" json:"tls-session-cache-size,omitempty"`
+}
+
+// UpstreamTimeouts bounds each phase of an upstream request, so a hung upstream fails the
+// request instead of holding its connection. The top-level values apply to every provider
+// and Providers overrides them for some.
+type UpstreamTimeouts struct {
+	UpstreamTimeoutValues `yaml:",inline"`
+
+	// Providers overrides the timeouts per provider, keyed by the provider of the account:
+	// "gemini", "gemini-cli", "claude", "codex", "qwen" and so on, or the name of an
+	// OpenAI-compatible provider. Zero values keep the top-level timeout.
+	Providers map[string]UpstreamTimeoutValues `yaml:"providers,omitempty" json:"providers,omitempty"`
+}
+
+// UpstreamTimeoutValues are the timeouts of the phases of an upstream request. Zero takes
+// the default and a negative value disables the timeout.
+type UpstreamTimeoutValues struct {
+	// Connect bounds establishing the TCP connection; defaults to upstream-transport.dial-timeout.
+	Connect time.Duration `yaml:"connect,omitempty" json:"connect,omitempty"`
+
+	// TLSHandshake bounds the TLS handshake; defaults to upstream-transport.tls-handshake-timeout.
+	TLSHandshake time.Duration `yaml:"tls-handshake,omitempty" json:"tls-handshake,omitempty"`
+
+	// FirstByte bounds the wait for the response headers once the request is sent; defaults
+	// to 10m, as non-streaming responses of long generations only start when they are done.
+	FirstByte time.Duration `yaml:"first-byte,omitempty" json:"first-byte,omitempty"`
+
+	// ChunkIdle bounds the gap between two reads of the response body, such as the chunks
+	// of a stream; defaults to 5m.
+	ChunkIdle time.Duration `yaml:"chunk-idle,omitempty" json:"chunk-idle,omitempty"`
+
+	// Total bounds the whole request, including reading a streamed response; defaults to 1h.
+	Total time.Duration `yaml:"total,omitempty" json:"total,omitempty"`
+}
+
+// For returns the timeouts of provider: the top-level values with the non-zero values of
+// its Providers entry, matched case-insensitively, applied over them.
+func (t UpstreamTimeouts) For(provider string) UpstreamTimeoutValues {
+	values := t.UpstreamTimeoutValues
+	for name, override := range t.Providers {
+		if !strings.EqualFold(strings.TrimSpace(name), provider) {
+			continue
+		}
+		if override.Connect != 0 {
+			values.Connect = override.Connect
+		}
+		if override.TLSHandshake != 0 {
+			values.TLSHandshake = override.TLSHandshake
+		}
+		if override.FirstByte != 0 {
+			values.FirstByte = override.FirstByte
+		}
+		if override.ChunkIdle != 0 {
+			values.ChunkIdle = override.ChunkIdle
+		}
+		if override.Total != 0 {
+			values.Total = override.Total
+		}
+		break
+	}
+	return values
+}
+
+// ProxyCheck configures periodic probes of every distinct proxy assigned to an account.
+// Accounts whose proxy fails FailureThreshold probes in a row are taken out of rotation
+// until a probe succeeds again; their requests never fall back to a direct connection.
+type ProxyCheck struct {
+	// Enable turns on proxy health checks.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// URL is requested through each proxy; any HTTP response counts as healthy.
+	// Defaults to https://www.gstatic.com/generate_204.
+	URL string `yaml:"url,omitempty" json:"url,omitempty"`
+
+	// Interval is the time between probes of a proxy; defaults to 1m.
+	Interval time.Duration `yaml:"interval,omitempty" json:"interval,omitempty"`
+
+	// Timeout bounds a single probe; defaults to 10s.
+	Timeout time.Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+
+	// FailureThreshold is the number of consecutive failed probes that marks a proxy down; defaults to 2.
+	FailureThreshold int `yaml:"failure-threshold,omitempty" json:"failure-threshold,omitempty"`
+}
+
+// UnixSocketConfig serves the API on a Unix domain socket, e.g. for a sidecar that should
+// not open a network listener. Changes require a restart.
+type UnixSocketConfig struct {
+	// Path is the socket file; setting it enables the socket. A stale socket is replaced.
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+
+	// Mode is the octal file mode of the socket; defaults to "0660".
+	Mode string `yaml:"mode,omitempty" json:"mode,omitempty"`
+
+	// DisableTCP serves the API only on the socket, without listening on port.
+	DisableTCP bool `yaml:"disable-tcp,omitempty" json:"disable-tcp,omitempty"`
+}
+
+// ManagementListenerConfig binds the management plane to a separate address. When Port is
+// set, /v0/management and /management.html are only served there, never on the API port.
+// Changes require a restart.
+type ManagementListenerConfig struct {
+	// Host is the interface to bind; defaults to 127.0.0.1.
+	Host string `yaml:"host,omitempty" json:"host,omitempty"`
+
+	// Port enables the listener when non-zero.
+	Port int `yaml:"port,omitempty" json:"port,omitempty"`
+
+	// TLS serves the listener over HTTPS, e.g. with client-auth.mode "require" for mutual TLS.
+	// Client certificate identities do not apply; management requests still need a key.
+	TLS TLSConfig `yaml:"tls,omitempty" json:"tls,omitempty"`
+}
+
+// TLSConfig configures HTTPS on the API listener. Changes require a restart, except for
+// the client certificate identities, which are reloaded with the config.
+type TLSConfig struct {
+	// Enable serves HTTPS instead of plain HTTP.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Cert and Key are the PEM files of the server certificate chain and its private key.
+	Cert string `yaml:"cert" json:"cert"`
+	Key  string `yaml:"key" json:"key"`
+
+	// ClientAuth verifies client certificates (mutual TLS).
+	ClientAuth ClientAuthConfig `yaml:"client-auth,omitempty" json:"client-auth,omitempty"`
+}
+
+// ClientAuthConfig configures how client certificates are requested and what they prove.
+type ClientAuthConfig struct {
+	// Mode is "require" to reject connections without a valid client certificate,
+	// "optional" to verify certificates that are presented, or empty to not ask for one.
+	Mode string `yaml:"mode,omitempty" json:"mode,omitempty"`
+
+	// CAFile is the PEM bundle of the certificate authorities client certificates must chain to.
+	CAFile string `yaml:"ca-file,omitempty" json:"ca-file,omitempty"`
+
+	// Identities authenticate requests with a matching certificate as an API key, so that
+	// they need no bearer key. The first matching entry applies; requests whose certificate
+	// matches none still authenticate with a key.
+	Identities []ClientCertIdentity `yaml:"identities,omitempty" json:"identities,omitempty"`
+}
+
+// ClientCertIdentity maps client certificates to an API key identity. When both Subject
+// and SAN are set both must match; a trailing "*" matches by prefix.
+type ClientCertIdentity struct {
+	// Subject matches the certificate subject, either the full distinguished name
+	// (e.g. "CN=build-bot,O=Example") or the common name alone.
+	Subject string `yaml:"subject,omitempty" json:"subject,omitempty"`
+
+	// SAN matches any DNS name, email address, URI or IP address of the certificate.
+	SAN string `yaml:"san,omitempty" json:"san,omitempty"`
+
+	// APIKey is the key matching requests authenticate as, for quotas, projects and usage.
+	APIKey string `yaml:"api-key" json:"api-key"`
+}
+
+// Project is a tenant of the proxy: a team whose API keys share allowed models, routing
+// and quotas, and whose usage can be reported on its own.
+type Project struct {
+	// Name identifies the project in metrics filters and management responses.
+	Name string `yaml:"name" json:"name"`
+
+	// APIKeys lists the inbound API keys belonging to the project. A key belongs to at most
+	// one project; keys outside every project are served without project restrictions.
+	APIKeys []string `yaml:"api-keys" json:"api-keys"`
+
+	// AllowedModels restricts the models the project may request; a trailing "*" matches by
+	// prefix. An empty list allows every model.
+	AllowedModels []string `yaml:"allowed-models,omitempty" json:"allowed-models,omitempty"`
+
+	// Routing overrides the global routing policies for the project's requests. Providers
+	// without an entry here follow the global policies.
+	Routing []RoutingPolicy `yaml:"routing,omitempty" json:"routing,omitempty"`
+
+	// Quota limits the combined usage of all keys of the project, in addition to any
+	// per-key quota.
+	Quota ProjectQuota `yaml:"quota,omitempty" json:"quota,omitempty"`
+}
+
+// ProjectQuota defines request and token limits shared by the keys of a project.
+// A zero limit means unlimited. Days and months are calendar periods in server local time.
+type ProjectQuota struct {
+	// DailyRequests caps the number of requests per day.
+	DailyRequests int64 `yaml:"daily-requests,omitempty" json:"daily-requests,omitempty"`
+
+	// DailyTokens caps the number of tokens consumed per day.
+	DailyTokens int64 `yaml:"daily-tokens,omitempty" json:"daily-tokens,omitempty"`
+
+	// MonthlyRequests caps the number of requests per month.
+	MonthlyRequests int64 `yaml:"monthly-requests,omitempty" json:"monthly-requests,omitempty"`
+
+	// MonthlyTokens caps the number of tokens consumed per month.
+	MonthlyTokens int64 `yaml:"monthly-tokens,omitempty" json:"monthly-tokens,omitempty"`
+}
+
+// RoutingPolicy configures account selection for one provider.
+type RoutingPolicy struct {
+	// Provider is the provider key the policy applies to (e.g. "gemini-cli", "claude");
+	// "*" applies to every provider without its own entry.
+	Provider string `yaml:"provider" json:"provider"`
+
+	// Strategy picks among the available accounts of the best priority tier:
+	// "round-robin" (default), "weighted" or "least-recently-used".
+	Strategy string `yaml:"strategy,omitempty" json:"strategy,omitempty"`
+
+	// Accounts assigns priority tiers and weights to matching accounts.
+	// Accounts without a matching entry use priority 0 and weight 1.
+	Accounts []AccountRoute `yaml:"accounts,omitempty" json:"accounts,omitempty"`
+}
+
+// AccountRoute sets the routing attributes of the accounts it matches.
+type AccountRoute struct {
+	// Match selects accounts by ID, label, email or auth file name; a trailing "*" matches by prefix.
+	Match string `yaml:"match" json:"match"`
+
+	// Priority is the tier of the account. Lower tiers are used first; a higher tier is only
+	// used while every account in the lower tiers is unavailable (e.g. quota exhausted).
+	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
+
+	// Weight is the relative share of requests under the weighted strategy; defaults to 1.
+	Weight int `yaml:"weight,omitempty" json:"weight,omitempty"`
+}
+
+// APIKeyQuota defines request and token limits for one inbound API key.
+// A zero limit means unlimited. Days and months are calendar periods in server local time.
+type APIKeyQuota struct {
+	// APIKey is the client key the limits apply to; "*" applies to every key without its own entry.
+	APIKey string `yaml:"api-key" json:"api-key"`
+
+	// DailyRequests caps the number of requests per day.
+	DailyRequests int64 `yaml:"daily-requests,omitempty" json:"daily-requests,omitempty"`
+
+	// DailyTokens caps the number of tokens consumed per day.
+	DailyTokens int64 `yaml:"daily-tokens,omitempty" json:"daily-tokens,omitempty"`
+
+	// MonthlyRequests caps the number of requests per month.
+	MonthlyRequests int64 `yaml:"monthly-requests,omitempty" json:"monthly-requests,omitempty"`
+
+	// MonthlyTokens caps the number of tokens consumed per month.
+	MonthlyTokens int64 `yaml:"monthly-tokens,omitempty" json:"monthly-tokens,omitempty"`
+}
+
+// AccountQuota defines the limits of upstream accounts within a rolling five-hour window,
+// which starts with the first request after the previous one ended (as Claude's does), and
+// within a calendar day in server local time. The limits are not enforced; they are used
+// to project when an account will be exhausted. A zero limit means unknown.
+type AccountQuota struct {
+	// Provider applies the limits to every account of the provider without an auth-id entry.
+	Provider string `yaml:"provider,omitempty" json:"provider,omitempty"`
+
+	// AuthID applies the limits to a single account.
+	AuthID string `yaml:"auth-id,omitempty" json:"auth-id,omitempty"`
+
+	// FiveHourRequests is the number of requests allowed per five-hour window.
+	FiveHourRequests int64 `yaml:"five-hour-requests,omitempty" json:"five-hour-requests,omitempty"`
+
+	// FiveHourTokens is the number of tokens allowed per five-hour window.
+	FiveHourTokens int64 `yaml:"five-hour-tokens,omitempty" json:"five-hour-tokens,omitempty"`
+
+	// DailyRequests is the number of requests allowed per day.
+	DailyRequests int64 `yaml:"daily-requests,omitempty" json:"daily-requests,omitempty"`
+
+	// DailyTokens is the number of tokens allowed per day.
+	DailyTokens int64 `yaml:"daily-tokens,omitempty" json:"daily-tokens,omitempty"`
+}
+
+// RateLimit defines a token-bucket limit. Each bucket holds one minute worth of capacity and
+// refills continuously, so short bursts up to the limit are allowed. A zero limit means unlimited.
+type RateLimit struct {
+	// APIKey scopes the limit to one client key. Empty shares one bucket across all keys;
+	// "*" gives every key without its own entry a separate bucket.
+	APIKey string `yaml:"api-key,omitempty" json:"api-key,omitempty"`
+
+	// Model scopes the limit to one model. Empty shares one bucket across all models;
+	// "*" gives every model without its own entry a separate bucket.
+	Model string `yaml:"model,omitempty" json:"model,omitempty"`
+
+	// RequestsPerMinute caps the request rate.
+	RequestsPerMinute int64 `yaml:"requests-per-minute,omitempty" json:"requests-per-minute,omitempty"`
+
+	// TokensPerMinute caps the token rate. Tokens are charged once the response reports its
+	// usage, so requests are admitted while the bucket is not in debt.
+	TokensPerMinute int64 `yaml:"tokens-per-minute,omitempty" json:"tokens-per-minute,omitempty"`
+}
+
+// Guardrail strategies for prompts over MaxInputTokens.
+const (
+	// GuardrailReject rejects the request with 400.
+	GuardrailReject = "reject"
+	// GuardrailTruncateOldest drops the oldest messages after the system prompt.
+	GuardrailTruncateOldest = "truncate-oldest"
+	// GuardrailTruncateMiddle keeps the first message after the system prompt and drops
+	// the messages following it.
+	GuardrailTruncateMiddle = "truncate-middle"
+)
+
+// Guardrail bounds the size of the requests of an inbound API key. Prompt sizes are
+// estimated at four bytes per token. A zero limit means unlimited.
+type Guardrail struct {
+	// APIKey is the client key the guardrail applies to; "*" applies to every key without
+	// its own entry.
+	APIKey string `yaml:"api-key" json:"api-key"`
+
+	// MaxOutputTokens caps the output token limit of requests; larger or missing limits are
+	// lowered to it.
+	MaxOutputTokens int64 `yaml:"max-output-tokens,omitempty" json:"max-output-tokens,omitempty"`
+
+	// MaxInputTokens is the estimated prompt size from which Strategy applies.
+	MaxInputTokens int64 `yaml:"max-input-tokens,omitempty" json:"max-input-tokens,omitempty"`
+
+	// Strategy handles prompts over MaxInputTokens: "reject" (default), "truncate-oldest"
+	// or "truncate-middle". Truncation always keeps the latest message; prompts still over
+	// the limit are rejected.
+	Strategy string `yaml:"strategy,omitempty" json:"strategy,omitempty"`
+}
+
+// Moderation actions of blocklist rules.
+const (
+	// ModerationReject rejects the request with 400.
+	ModerationReject = "reject"
+	// ModerationRedact replaces the matched text and forwards the request.
+	ModerationRedact = "redact"
+)
+
+// Moderation configures content moderation of generation requests. Blocklist rules are
+// checked first, then the external endpoint.
+type Moderation struct {
+	// Enable turns on content moderation.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Blocklist holds regular expressions matched against the text of requests.
+	Blocklist []ModerationRule `yaml:"blocklist,omitempty" json:"blocklist,omitempty"`
+
+	// Endpoint sends the text of requests to an external moderation service.
+	Endpoint ModerationEndpoint `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`
+}
+
+// ModerationRule is a blocklist entry.
+type ModerationRule struct {
+	// Name identifies the rule in logs and metrics; defaults to its position, such as
+	// blocklist-1.
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+
+	// Pattern is a regular expression in Go syntax; prefix it with (?i) to ignore case.
+	Pattern string `yaml:"pattern" json:"pattern"`
+
+	// Action is "reject" (default) or "redact".
+	Action string `yaml:"action,omitempty" json:"action,omitempty"`
+
+	// Replacement substitutes redacted matches; defaults to "[REDACTED]".
+	Replacement string `yaml:"replacement,omitempty" json:"replacement,omitempty"`
+}
+
+// ModerationEndpoint configures an external moderation service compatible with the OpenAI
+// moderations API: requests whose text it flags are rejected.
+type ModerationEndpoint struct {
+	// URL is the moderation endpoint, such as https://api.openai.com/v1/moderations. Empty
+	// disables the external check.
+	URL string `yaml:"url,omitempty" json:"url,omitempty"`
+
+	// APIKey is sent as a bearer token.
+	APIKey string `yaml:"api-key,omitempty" json:"api-key,omitempty"`
+
+	// Model is sent as the model of the moderation request when set.
+	Model string `yaml:"model,omitempty" json:"model,omitempty"`
+
+	// Timeout bounds each moderation call; defaults to 10s.
+	Timeout time.Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+
+	// FailOpen forwards requests when the endpoint cannot be reached or fails. By default
+	// such requests are rejected with 503.
+	FailOpen bool `yaml:"fail-open,omitempty" json:"fail-open,omitempty"`
+}
+
+// Session history trimming strategies.
+const (
+	// SessionTrimOldest drops the oldest messages of the history.
+	SessionTrimOldest = "truncate-oldest"
+	// SessionTrimMiddle keeps the first message of the history and drops the ones after it.
+	SessionTrimMiddle = "truncate-middle"
+)
+
+// SessionsConfig configures server-side conversation history. Requests to the chat
+// completions, Claude messages and Gemini generateContent endpoints that carry an
+// X-CLIProxy-Session header are sent upstream with the stored history of that session
+// before their own messages, and the reply is added to the history. Sessions belong to the
+// API key that created them and expire after TTL without use.
+type SessionsConfig struct {
+	// Enable turns on session handling.
+	Enable bool `yaml:"enable,omitempty" json:"enable,omitempty"`
+
+	// Backend stores the sessions: "memory" (default) or "redis", which shares them
+	// between proxy instances.
+	Backend string `yaml:"backend,omitempty" json:"backend,omitempty"`
+
+	// TTL is how long a session is kept after its last request; defaults to 24h.
+	TTL time.Duration `yaml:"ttl,omitempty" json:"ttl,omitempty"`
+
+	// MaxSessions caps the sessions of the memory backend, evicting the least recently
+	// used; defaults to 10000.
+	MaxSessions int `yaml:"max-sessions,omitempty" json:"max-sessions,omitempty"`
+
+	// MaxMessages trims the history to this many messages; zero keeps every message.
+	MaxMessages int `yaml:"max-messages,omitempty" json:"max-messages,omitempty"`
+
+	// MaxTokens trims the history to this estimated size; zero disables the limit.
+	MaxTokens int64 `yaml:"max-tokens,omitempty" json:"max-tokens,omitempty"`
+
+	// Strategy chooses the messages trimming drops: "truncate-oldest" (default) or
+	// "truncate-middle", which keeps the first message of the conversation.
+	Strategy string `yaml:"strategy,omitempty" json:"strategy,omitempty"`
+}
+
+// Hook stages.
+const (
+	HookStagePreRequest   = "pre-request"
+	HookStagePostResponse = "post-response"
+)
+
+// Hook is an HTTP endpoint or WASM module called with each request before it is dispatched
+// or with each response before it is returned. Exactly one of URL and WASM is set.
+type Hook struct {
+	// Name identifies the hook in logs and errors; defaults to its position, such as hook-1.
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+
+	// Stage is "pre-request" or "post-response".
+	Stage string `yaml:"stage" json:"stage"`
+
+	// URL is the endpoint the event is posted to.
+	URL string `yaml:"url,omitempty" json:"url,omitempty"`
+
+	// Headers are sent with each call to URL, such as an Authorization header.
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+
+	// WASM is the path of a WASI command module that reads the event from standard input
+	// and writes the result to standard output.
+	WASM string `yaml:"wasm,omitempty" json:"wasm,omitempty"`
+
+	// Paths limits the hook to requests whose path starts with one of the prefixes. By
+	// default it applies to all /v1 and /v1beta requests.
+	Paths []string `yaml:"paths,omitempty" json:"paths,omitempty"`
+
+	// Timeout bounds each call; defaults to 5s.
+	Timeout time.Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+
+	// FailOpen lets requests and responses continue unchanged when the hook fails or times
+	// out. By default they are answered with 503.
+	FailOpen bool `yaml:"fail-open,omitempty" json:"fail-open,omitempty"`
+}
+
+// SystemPromptsConfig adds text before and after the system prompt of generation requests,
+// such as organization-wide policies, without changing the clients. Every matching rule
+// applies, in order.
+type SystemPromptsConfig struct {
+	// KeyNames names inbound API keys for the {key-name} placeholder; keys without a name
+	// are shown masked.
+	KeyNames map[string]string `yaml:"key-names,omitempty" json:"key-names,omitempty"`
+
+	// Rules select the requests to change and the text added to them.
+	Rules []SystemPromptRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+}
+
+// SystemPromptRule adds a prefix and suffix to the system prompt of the requests it
+// matches. Prefix and Suffix may contain the placeholders {date}, {time}, {weekday} (server
+// local time), {model}, {key} (masked), {key-name} and {project}.
+type SystemPromptRule struct {
+	// Name identifies the rule in logs; defaults to its position, such as system-prompt-1.
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+
+	// APIKeys limits the rule to these inbound API keys; empty matches every key.
+	APIKeys []string `yaml:"api-keys,omitempty" json:"api-keys,omitempty"`
+
+	// Projects limits the rule to the keys of these projects; empty matches every key.
+	Projects []string `yaml:"projects,omitempty" json:"projects,omitempty"`
+
+	// Models limits the rule to the requested models; a trailing "*" matches by prefix.
+	// Empty matches every model.
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// Prefix is added before the system prompt of the request.
+	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
 
-	// LoggingToFile controls whether application logs are written to rotating files or stdout.
-	LoggingToFile bool `yaml:"logging-to-file" json:"logging-to-file"`
+	// Suffix is added after the system prompt of the request.
+	Suffix string `yaml:"suffix,omitempty" json:"suffix,omitempty"`
+}
 
-	// UsageStatisticsEnabled toggles in-memory usage aggregation; when false, usage data is discarded.
-	UsageStatisticsEnabled bool `yaml:"usage-statistics-enabled" json:"usage-statistics-enabled"`
+// Built-in PII detectors.
+const (
+	PIIDetectorEmail      = "email"
+	PIIDetectorPhone      = "phone"
+	PIIDetectorCreditCard = "credit-card"
+)
 
-	// DisableCooling disables quota cooldown scheduling when true.
-	DisableCooling bool `yaml:"disable-cooling" json:"disable-cooling"`
+// PIIRedaction configures the masking of personal data in prompts. Detected values are
+// replaced with numbered placeholders such as [EMAIL_1] and, unless KeepPlaceholders is
+// set, the placeholders are replaced with the original values in responses.
+type PIIRedaction struct {
+	// Enable turns on PII redaction.
+	Enable bool `yaml:"enable" json:"enable"`
 
-	// QuotaExceeded defines the behavior when a quota is exceeded.
-	QuotaExceeded QuotaExceeded `yaml:"quota-exceeded" json:"quota-exceeded"`
+	// Detectors selects the built-in detectors: "email", "phone" and "credit-card". Empty
+	// enables all of them.
+	Detectors []string `yaml:"detectors,omitempty" json:"detectors,omitempty"`
 
-	// WebsocketAuth enables or disables authentication for the WebSocket API.
-	WebsocketAuth bool `yaml:"ws-auth" json:"ws-auth"`
+	// Patterns are additional values to mask, matched with regular expressions.
+	Patterns []PIIPattern `yaml:"patterns,omitempty" json:"patterns,omitempty"`
 
-	// GlAPIKey is the API key for the generative language API.
-	GlAPIKey []string `yaml:"generative-language-api-key" json:"generative-language-api-key"`
+	// KeepPlaceholders leaves the placeholders in responses instead of restoring the
+	// original values.
+	KeepPlaceholders bool `yaml:"keep-placeholders,omitempty" json:"keep-placeholders,omitempty"`
+}
 
-	// RequestRetry defines the retry times when the request failed.
-	RequestRetry int `yaml:"request-retry" json:"request-retry"`
+// PIIPattern is a custom PII detector.
+type PIIPattern struct {
+	// Name labels the placeholders of the pattern, such as EMPLOYEE_ID for
+	// [EMPLOYEE_ID_1].
+	Name string `yaml:"name" json:"name"`
 
-	// ClaudeKey defines a list of Claude API key configurations as specified in the YAML configuration file.
-	ClaudeKey []ClaudeKey `yaml:"claude-api-key" json:"claude-api-key"`
+	// Pattern is a regular expression in Go syntax.
+	Pattern string `yaml:"pattern" json:"pattern"`
+}
 
-	// Codex defines a list of Codex API key configurations as specified in the YAML configuration file.
-	CodexKey []CodexKey `yaml:"codex-api-key" json:"codex-api-key"`
+// ShadowConfig configures shadow traffic: a sample of the successful requests for a model is
+// sent again to a candidate model in the background, and the request, the response returned
+// to the client and the candidate's response are appended to a JSON Lines file per day.
+type ShadowConfig struct {
+	// Enable turns on shadow traffic.
+	Enable bool `yaml:"enable" json:"enable"`
 
-	// OpenAICompatibility defines OpenAI API compatibility configurations for external providers.
-	OpenAICompatibility []OpenAICompatibility `yaml:"openai-compatibility" json:"openai-compatibility"`
+	// Dir is the directory of the comparison files; defaults to logs/shadow.
+	Dir string `yaml:"dir,omitempty" json:"dir,omitempty"`
 
-	// RemoteManagement nests management-related options under 'remote-management'.
-	RemoteManagement RemoteManagement `yaml:"remote-management" json:"-"`
+	// Rules select the sampled models and their candidates.
+	Rules []ShadowRule `yaml:"rules,omitempty" json:"rules,omitempty"`
 
-	// MetricsFile is the path to the JSON file where metrics will be stored.
-	MetricsFile string `yaml:"metrics-file,omitempty" json:"metrics-file,omitempty"`
+	// MaxConcurrent is the number of shadow requests in flight; further samples are skipped.
+	// Defaults to 4.
+	MaxConcurrent int `yaml:"max-concurrent,omitempty" json:"max-concurrent,omitempty"`
 
-	// LoopDelay is the interval at which metrics are saved to the file.
-	LoopDelay time.Duration `yaml:"loop-delay,omitempty" json:"loop-delay,omitempty"`
+	// MaxBodyBytes truncates each stored request and response; defaults to 1 MiB.
+	MaxBodyBytes int `yaml:"max-body-bytes,omitempty" json:"max-body-bytes,omitempty"`
+}
 
-	// CrashOnError determines if the application should crash if saving metrics fails.
-	CrashOnError bool `yaml:"crash-on-error,omitempty" json:"crash-on-error,omitempty"`
+// ShadowRule duplicates requests for a model to a candidate model.
+type ShadowRule struct {
+	// Model is the model name requested by clients.
+	Model string `yaml:"model" json:"model"`
+
+	// ShadowModel is the candidate model the duplicated request is sent to.
+	ShadowModel string `yaml:"shadow-model" json:"shadow-model"`
+
+	// Percent is the share of requests duplicated, from 0 to 100.
+	Percent float64 `yaml:"percent" json:"percent"`
+}
+
+// ShutdownConfig configures graceful shutdown. On SIGTERM the server stops accepting
+// requests and waits for in-flight responses, including streams, to finish.
+type ShutdownConfig struct {
+	// DrainTimeout is how long in-flight responses may run before they are cut; defaults to 30s.
+	DrainTimeout time.Duration `yaml:"drain-timeout,omitempty" json:"drain-timeout,omitempty"`
+}
+
+// ModelDiscovery configures the periodic query of upstream model lists. Gemini and Claude API
+// keys and OpenAI-compatible providers report the models they serve; the results replace the
+// built-in model lists of those accounts, keeping context windows and capabilities.
+type ModelDiscovery struct {
+	// Enable turns on model discovery.
+	Enable bool `yaml:"enable,omitempty" json:"enable,omitempty"`
+
+	// Interval is the time between two discovery rounds; defaults to 1h.
+	Interval time.Duration `yaml:"interval,omitempty" json:"interval,omitempty"`
+
+	// Timeout bounds each upstream query; defaults to 30s.
+	Timeout time.Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
+// Admission configures admission control. At most MaxConcurrent requests are served at
+// once; further requests wait in a queue ordered by the priority of their API key, and
+// requests of equal priority are served first come, first served.
+type Admission struct {
+	// Enable turns on admission control.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// MaxConcurrent is the number of requests served at the same time; defaults to 64.
+	MaxConcurrent int `yaml:"max-concurrent,omitempty" json:"max-concurrent,omitempty"`
+
+	// QueueDepth is the number of requests that may wait; further requests are rejected
+	// with 503. Defaults to 256.
+	QueueDepth int `yaml:"queue-depth,omitempty" json:"queue-depth,omitempty"`
+
+	// Timeout is how long a request waits for a slot before it is rejected with 503; defaults to 30s.
+	Timeout time.Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+
+	// DefaultPriority applies to API keys without an entry in Priorities.
+	DefaultPriority int `yaml:"default-priority,omitempty" json:"default-priority,omitempty"`
+
+	// Priorities sets the priority of individual API keys; higher priorities are served first.
+	Priorities []KeyPriority `yaml:"priorities,omitempty" json:"priorities,omitempty"`
+}
+
+// File storage backends.
+const (
+	FilesStorageLocal = "local"
+	FilesStorageS3    = "s3"
+)
+
+// FilesConfig configures the Files API. Files are kept in a local directory or an
+// S3-compatible bucket; the backend is chosen at startup.
+type FilesConfig struct {
+	// Enable turns on the /v1/files endpoints. The Batch API enables them as well since
+	// batches read their input from uploaded files.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Storage selects the backend: "local" (default) or "s3".
+	Storage string `yaml:"storage,omitempty" json:"storage,omitempty"`
+
+	// Dir is the directory of the local backend; defaults to files under the writable path.
+	Dir string `yaml:"dir,omitempty" json:"dir,omitempty"`
+
+	// S3 configures the s3 backend.
+	S3 FilesS3 `yaml:"s3,omitempty" json:"s3,omitempty"`
+
+	// MaxFileBytes is the size limit of uploaded files; defaults to 100 MiB.
+	MaxFileBytes int64 `yaml:"max-file-bytes,omitempty" json:"max-file-bytes,omitempty"`
+
+	// Retention removes uploaded files after this long; zero keeps them until deleted.
+	Retention time.Duration `yaml:"retention,omitempty" json:"retention,omitempty"`
+
+	// UpstreamUpload sends large file contents of requests through the provider's Files API
+	// instead of inline, where the provider supports it: Gemini and Claude API keys.
+	// Uploads are cached per account for as long as the provider keeps them.
+	UpstreamUpload bool `yaml:"upstream-upload,omitempty" json:"upstream-upload,omitempty"`
+}
+
+// FilesS3 configures an S3-compatible bucket for the Files API.
+type FilesS3 struct {
+	Endpoint  string `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`
+	Bucket    string `yaml:"bucket,omitempty" json:"bucket,omitempty"`
+	Region    string `yaml:"region,omitempty" json:"region,omitempty"`
+	AccessKey string `yaml:"access-key,omitempty" json:"-"`
+	SecretKey string `yaml:"secret-key,omitempty" json:"-"`
+
+	// Prefix is prepended to every object key.
+	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
+
+	// UseSSL connects over HTTPS.
+	UseSSL bool `yaml:"use-ssl,omitempty" json:"use-ssl,omitempty"`
+
+	// PathStyle addresses the bucket in the path instead of the host name.
+	PathStyle bool `yaml:"path-style,omitempty" json:"path-style,omitempty"`
+}
+
+// PromptsConfig configures the prompt template library. Templates are named lists of
+// messages with {{variable}} placeholders, managed under /v1/prompts and expanded into the
+// generation requests that name them in metadata.prompt_template.
+type PromptsConfig struct {
+	// Enable turns on the /v1/prompts endpoints and template expansion.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Dir is the directory holding the templates; defaults to prompts under the writable
+	// path. It is read at startup only.
+	Dir string `yaml:"dir,omitempty" json:"dir,omitempty"`
+
+	// Editors lists the inbound API keys allowed to create, replace and delete templates.
+	// Without editors, templates can only be changed on disk.
+	Editors []string `yaml:"editors,omitempty" json:"editors,omitempty"`
+}
+
+// BatchConfig configures the Batch API. Batch state and partial results are kept on disk
+// so batches resume after a restart; input and result files live in the Files API storage.
+type BatchConfig struct {
+	// Enable turns on the /v1/batches endpoints, and with them the Files API.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Dir is the directory holding batch state; defaults to batches under the writable
+	// path. It is read at startup only.
+	Dir string `yaml:"dir,omitempty" json:"dir,omitempty"`
+
+	// Concurrency is the number of requests each batch runs at the same time; defaults to 4.
+	Concurrency int `yaml:"concurrency,omitempty" json:"concurrency,omitempty"`
+
+	// MaxAttempts is how often a request throttled or rejected as unavailable upstream is
+	// tried before it is reported as failed; defaults to 5.
+	MaxAttempts int `yaml:"max-attempts,omitempty" json:"max-attempts,omitempty"`
+
+	// Retention is how long finished batches and their result files are kept; defaults to 7 days.
+	Retention time.Duration `yaml:"retention,omitempty" json:"retention,omitempty"`
+}
+
+// GeminiContextCache configures context caching for Gemini API accounts. Once the same
+// system instruction and tools have been sent MinRepeats times to an account and model, a
+// cachedContents object holding them is created on that account and later requests
+// reference it instead of sending the prefix again.
+type GeminiContextCache struct {
+	// Enable turns on context caching.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// MinTokens is the estimated size a prefix needs to be cached; defaults to 4096, the
+	// minimum Gemini accepts for most models.
+	MinTokens int `yaml:"min-tokens,omitempty" json:"min-tokens,omitempty"`
+
+	// MinRepeats is how often a prefix is seen before it is cached; defaults to 2.
+	MinRepeats int `yaml:"min-repeats,omitempty" json:"min-repeats,omitempty"`
+
+	// TTL is the lifetime of created caches; it is extended while a cache is in use.
+	// Defaults to 1h.
+	TTL time.Duration `yaml:"ttl,omitempty" json:"ttl,omitempty"`
+}
+
+// KeyPriority assigns an admission priority to an inbound API key.
+type KeyPriority struct {
+	APIKey   string `yaml:"api-key" json:"api-key"`
+	Priority int    `yaml:"priority" json:"priority"`
+}
+
+// AuthEncryption configures AES-256-GCM encryption of auth files at rest. The key comes from
+// the first source that is set: Key, KeyFile, KeyCommand, then the AUTH_ENCRYPTION_KEY
+// environment variable. A base64 or hex encoded 32-byte key is used as is; any other value
+// is treated as a passphrase. The key is read at startup only.
+type AuthEncryption struct {
+	// Key is the encryption key itself; it is never returned by the management API.
+	Key string `yaml:"key,omitempty" json:"-"`
+
+	// KeyFile is a file holding the key.
+	KeyFile string `yaml:"key-file,omitempty" json:"key-file,omitempty"`
+
+	// KeyCommand is run through the shell and prints the key, for keys kept in an external
+	// KMS or secrets manager.
+	KeyCommand string `yaml:"key-command,omitempty" json:"key-command,omitempty"`
+}
+
+// Secrets configures the secrets managers queried for secret references. Any string setting
+// may be a reference instead of a literal value:
+//
+//	vault://<mount>/<path>#<field>         HashiCorp Vault KV secret
+//	aws-sm://<secret-id>[#<json-key>]       AWS Secrets Manager secret
+//	gcp-sm://<secret>[@<version>][#<json-key>]  GCP Secret Manager secret
+//
+// References are resolved whenever the configuration is loaded and re-read every
+// RefreshInterval; a changed secret reloads the configuration.
+type Secrets struct {
+	// RefreshInterval is how often referenced secrets are re-read; defaults to 5m.
+	RefreshInterval time.Duration `yaml:"refresh-interval,omitempty" json:"refresh-interval,omitempty"`
+
+	// Vault configures access to HashiCorp Vault.
+	Vault VaultSecrets `yaml:"vault,omitempty" json:"vault,omitempty"`
+
+	// AWS configures access to AWS Secrets Manager.
+	AWS AWSSecrets `yaml:"aws,omitempty" json:"aws,omitempty"`
+
+	// GCP configures access to GCP Secret Manager.
+	GCP GCPSecrets `yaml:"gcp,omitempty" json:"gcp,omitempty"`
+}
+
+// VaultSecrets configures HashiCorp Vault. Unset values fall back to the VAULT_ADDR,
+// VAULT_TOKEN and VAULT_NAMESPACE environment variables.
+type VaultSecrets struct {
+	// Address is the Vault server URL.
+	Address string `yaml:"address,omitempty" json:"address,omitempty"`
+
+	// Token authenticates the requests.
+	Token string `yaml:"token,omitempty" json:"-"`
+
+	// Namespace is the Vault Enterprise namespace.
+	Namespace string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+
+	// KVVersion is the version of the KV secrets engine, 1 or 2 (default).
+	KVVersion int `yaml:"kv-version,omitempty" json:"kv-version,omitempty"`
+}
+
+// AWSSecrets configures AWS Secrets Manager. Credentials are read from the
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables.
+type AWSSecrets struct {
+	// Region is the region of the secrets; defaults to AWS_REGION or AWS_DEFAULT_REGION.
+	Region string `yaml:"region,omitempty" json:"region,omitempty"`
+
+	// Endpoint overrides the Secrets Manager endpoint, e.g. for a VPC endpoint.
+	Endpoint string `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`
+}
+
+// GCPSecrets configures GCP Secret Manager. Requests use Application Default Credentials.
+type GCPSecrets struct {
+	// Project is the project of secrets referenced by name only; defaults to
+	// GOOGLE_CLOUD_PROJECT.
+	Project string `yaml:"project,omitempty" json:"project,omitempty"`
+}
+
+// Response compression encodings.
+const (
+	CompressionBrotli = "br"
+	CompressionGzip   = "gzip"
+)
+
+// CompressionConfig configures the compression of non-streaming JSON responses, negotiated
+// with the client's Accept-Encoding header. Streamed responses and responses that already
+// carry a Content-Encoding are sent as they are.
+type CompressionConfig struct {
+	// Enable turns on response compression.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Encodings are the offered encodings, "br" and "gzip", in order of preference for
+	// clients that accept several equally; defaults to both, brotli first.
+	Encodings []string `yaml:"encodings,omitempty" json:"encodings,omitempty"`
+
+	// MinSize is the smallest response in bytes that is compressed; defaults to 1024.
+	MinSize int `yaml:"min-size,omitempty" json:"min-size,omitempty"`
+
+	// GzipLevel is the gzip level from 1 (fastest) to 9 (smallest); defaults to 6.
+	GzipLevel int `yaml:"gzip-level,omitempty" json:"gzip-level,omitempty"`
+
+	// BrotliQuality is the brotli quality from 1 (fastest) to 11 (smallest); defaults to 4.
+	BrotliQuality int `yaml:"brotli-quality,omitempty" json:"brotli-quality,omitempty"`
+
+	// Routes are the request paths whose responses are compressed, exact or with a
+	// trailing "*" matching by prefix; empty means every route.
+	Routes []string `yaml:"routes,omitempty" json:"routes,omitempty"`
+
+	// ExcludeRoutes are request paths that are never compressed, matched like Routes.
+	ExcludeRoutes []string `yaml:"exclude-routes,omitempty" json:"exclude-routes,omitempty"`
+}
+
+// RequestLimitsConfig bounds the size of request bodies. JSON and other bodies are read
+// into memory up to their limit, while multipart uploads are streamed into a spool that
+// keeps small uploads in memory and writes larger ones to a temporary file, so that large
+// uploads are not held in memory by the proxy.
+type RequestLimitsConfig struct {
+	// MaxBodyBytes is the largest request body in bytes that is not a multipart upload;
+	// defaults to 32 MiB, negative means unlimited.
+	MaxBodyBytes int64 `yaml:"max-body-bytes,omitempty" json:"max-body-bytes,omitempty"`
+
+	// MaxUploadBytes is the largest multipart/form-data body in bytes, such as an audio
+	// transcription or a file upload; defaults to 128 MiB, negative means unlimited.
+	MaxUploadBytes int64 `yaml:"max-upload-bytes,omitempty" json:"max-upload-bytes,omitempty"`
+
+	// UploadMemoryBytes is the part of an upload kept in memory before it is spooled to a
+	// temporary file; defaults to 4 MiB.
+	UploadMemoryBytes int64 `yaml:"upload-memory-bytes,omitempty" json:"upload-memory-bytes,omitempty"`
+
+	// SpoolDir is the directory of the temporary upload files; defaults to the system
+	// temporary directory.
+	SpoolDir string `yaml:"spool-dir,omitempty" json:"spool-dir,omitempty"`
+
+	// Routes override the limit of the request paths they match, exact or with a trailing
+	// "*" matching by prefix. The first matching route applies.
+	Routes []RequestLimitRoute `yaml:"routes,omitempty" json:"routes,omitempty"`
+}
+
+// RequestLimitRoute is the body size limit of the request paths matching Path.
+type RequestLimitRoute struct {
+	// Path is the request path, exact or with a trailing "*" matching by prefix.
+	Path string `yaml:"path" json:"path"`
+
+	// MaxBodyBytes is the largest body in bytes of a matching request, multipart or not;
+	// 0 or negative means unlimited.
+	MaxBodyBytes int64 `yaml:"max-body-bytes" json:"max-body-bytes"`
+}
+
+// BodyCaptureConfig configures the debug capture of full request and response bodies,
+// including the upstream requests and responses, retrievable through the management API.
+type BodyCaptureConfig struct {
+	// Enable turns on body capture.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Storage selects where captures are kept: "memory" (default), a ring buffer, or "disk".
+	Storage string `yaml:"storage,omitempty" json:"storage,omitempty"`
+
+	// Dir is the directory of the disk storage; defaults to logs/captures.
+	Dir string `yaml:"dir,omitempty" json:"dir,omitempty"`
+
+	// MaxEntries is the number of captures kept, the oldest being dropped first; defaults to 100.
+	MaxEntries int `yaml:"max-entries,omitempty" json:"max-entries,omitempty"`
+
+	// MaxBodyBytes truncates each captured body; defaults to 1 MiB.
+	MaxBodyBytes int `yaml:"max-body-bytes,omitempty" json:"max-body-bytes,omitempty"`
+
+	// RedactPII also masks e-mail addresses and international phone numbers. Credentials
+	// such as API keys, bearer tokens and OAuth tokens are always masked.
+	RedactPII bool `yaml:"redact-pii,omitempty" json:"redact-pii,omitempty"`
+
+	// RedactPatterns lists additional regular expressions whose matches are masked.
+	RedactPatterns []string `yaml:"redact-patterns,omitempty" json:"redact-patterns,omitempty"`
+}
+
+// AccessLogConfig configures the structured access log, which emits one JSON record per proxied request.
+type AccessLogConfig struct {
+	// Enable turns on access logging.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Sink selects the destination: "stdout" (default), "file" or "syslog".
+	Sink string `yaml:"sink,omitempty" json:"sink,omitempty"`
+
+	// Path is the log file for the file sink; defaults to logs/access.log.
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+
+	// MaxSizeMB is the size at which the file sink rotates; defaults to 100.
+	MaxSizeMB int `yaml:"max-size-mb,omitempty" json:"max-size-mb,omitempty"`
+
+	// MaxBackups is the number of rotated files to keep; zero keeps all of them.
+	MaxBackups int `yaml:"max-backups,omitempty" json:"max-backups,omitempty"`
+
+	// MaxAgeDays removes rotated files older than this many days; zero disables age-based cleanup.
+	MaxAgeDays int `yaml:"max-age-days,omitempty" json:"max-age-days,omitempty"`
+
+	// Compress gzips rotated files.
+	Compress bool `yaml:"compress,omitempty" json:"compress,omitempty"`
+
+	// SyslogNetwork and SyslogAddress select a remote syslog daemon (e.g. "udp", "logs:514");
+	// leave both empty to use the local syslog socket.
+	SyslogNetwork string `yaml:"syslog-network,omitempty" json:"syslog-network,omitempty"`
+	SyslogAddress string `yaml:"syslog-address,omitempty" json:"syslog-address,omitempty"`
+
+	// SyslogTag is the program tag attached to syslog messages; defaults to cli-proxy-api.
+	SyslogTag string `yaml:"syslog-tag,omitempty" json:"syslog-tag,omitempty"`
+}
+
+// AuditLogConfig configures the audit log, which records every change made through the
+// management API with the caller and the settings it changed.
+type AuditLogConfig struct {
+	// Enable turns on audit logging.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// MaxEntries is the number of recent entries kept in memory for GET /audit-log; defaults to 1000.
+	MaxEntries int `yaml:"max-entries,omitempty" json:"max-entries,omitempty"`
+
+	// Sink additionally ships entries to "file" or "syslog"; empty keeps them in memory only.
+	Sink string `yaml:"sink,omitempty" json:"sink,omitempty"`
+
+	// Path is the append-only log file for the file sink; defaults to logs/audit.log.
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+
+	// SyslogNetwork and SyslogAddress select a remote syslog daemon (e.g. "udp", "logs:514");
+	// leave both empty to use the local syslog socket.
+	SyslogNetwork string `yaml:"syslog-network,omitempty" json:"syslog-network,omitempty"`
+	SyslogAddress string `yaml:"syslog-address,omitempty" json:"syslog-address,omitempty"`
+
+	// SyslogTag is the program tag attached to syslog messages; defaults to cli-proxy-api-audit.
+	SyslogTag string `yaml:"syslog-tag,omitempty" json:"syslog-tag,omitempty"`
+}
+
+// TracingConfig configures OpenTelemetry span export over OTLP/HTTP.
+type TracingConfig struct {
+	// Enable turns on span export.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Endpoint is the collector address, either host:port or a full URL such as
+	// "https://collector:4318/v1/traces". Empty falls back to the OTEL_EXPORTER_OTLP_* environment.
+	Endpoint string `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`
+
+	// Insecure disables TLS when Endpoint is given as host:port.
+	Insecure bool `yaml:"insecure,omitempty" json:"insecure,omitempty"`
+
+	// Headers are sent with every export request, e.g. for collector authentication.
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+
+	// ServiceName overrides the reported service.name resource attribute.
+	ServiceName string `yaml:"service-name,omitempty" json:"service-name,omitempty"`
+
+	// SampleRatio is the fraction of new traces to sample; zero or one samples everything.
+	SampleRatio float64 `yaml:"sample-ratio,omitempty" json:"sample-ratio,omitempty"`
+}
+
+// StatsDConfig configures the StatsD metrics emitter.
+type StatsDConfig struct {
+	// Enable turns on the emitter.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Address is the agent's UDP host:port; defaults to 127.0.0.1:8125.
+	Address string `yaml:"address,omitempty" json:"address,omitempty"`
+
+	// Prefix is prepended to every metric name; defaults to "cliproxy.".
+	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
+
+	// Protocol is "dogstatsd" (the default), which sends tags, or "statsd", which drops them.
+	Protocol string `yaml:"protocol,omitempty" json:"protocol,omitempty"`
+
+	// Tags are attached to every metric, e.g. env: prod.
+	Tags map[string]string `yaml:"tags,omitempty" json:"tags,omitempty"`
+
+	// FlushInterval is how often buffered metrics are sent; defaults to 10s.
+	FlushInterval time.Duration `yaml:"flush-interval,omitempty" json:"flush-interval,omitempty"`
+}
+
+// ModelPrice defines token prices for a model, expressed per one million tokens.
+type ModelPrice struct {
+	// Model is the model name; a trailing "*" matches any model with that prefix.
+	Model string `yaml:"model" json:"model"`
+
+	// InputPerMillion is the price of one million uncached input tokens.
+	InputPerMillion float64 `yaml:"input-per-million" json:"input-per-million"`
+
+	// OutputPerMillion is the price of one million output tokens.
+	OutputPerMillion float64 `yaml:"output-per-million" json:"output-per-million"`
+
+	// CachedPerMillion is the price of one million cached input tokens; defaults to the input price.
+	CachedPerMillion *float64 `yaml:"cached-per-million,omitempty" json:"cached-per-million,omitempty"`
+
+	// ReasoningPerMillion is the price of one million reasoning tokens; defaults to the output price.
+	ReasoningPerMillion *float64 `yaml:"reasoning-per-million,omitempty" json:"reasoning-per-million,omitempty"`
+
+	// PerImage is the price of one generated image.
+	PerImage float64 `yaml:"per-image,omitempty" json:"per-image,omitempty"`
+}
+
+// UsageStore configures durable storage of usage detail records.
+// When Type is empty the legacy JSON snapshot (metrics-file) is used instead.
+type UsageStore struct {
+	// Type selects the backend implementation ("bolt").
+	Type string `yaml:"type,omitempty" json:"type,omitempty"`
+
+	// Path is the database file location.
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+
+	// FlushInterval controls how often new records are written to the store.
+	FlushInterval time.Duration `yaml:"flush-interval,omitempty" json:"flush-interval,omitempty"`
+
+	// Retention is how long records are kept; zero keeps them indefinitely.
+	Retention time.Duration `yaml:"retention,omitempty" json:"retention,omitempty"`
+}
+
+// UsageRetention configures tiered retention of usage statistics. Requests are counted in
+// minute aggregates for a week, then in hourly and eventually daily aggregates, while their
+// raw details may be dropped much sooner.
+type UsageRetention struct {
+	// Raw is how long individual request details are kept; zero keeps them indefinitely.
+	Raw time.Duration `yaml:"raw,omitempty" json:"raw,omitempty"`
+
+	// Hourly is how long hourly aggregates are kept before they are merged into daily ones;
+	// defaults to 90 days.
+	Hourly time.Duration `yaml:"hourly,omitempty" json:"hourly,omitempty"`
+
+	// Daily is how long daily aggregates are kept; zero keeps them indefinitely.
+	Daily time.Duration `yaml:"daily,omitempty" json:"daily,omitempty"`
 }
 
 // RemoteManagement holds management API configuration under 'remote-management'.
@@ -80,6 +1573,33 @@ type RemoteManagement struct {
 	SecretKey string `yaml:"secret-key"`
 	// DisableControlPanel skips serving and syncing the bundled management UI when true.
 	DisableControlPanel bool `yaml:"disable-control-panel"`
+	// GRPCPort serves the management API over gRPC on this port when non-zero.
+	// Changing it requires a restart.
+	GRPCPort int `yaml:"grpc-port,omitempty"`
+	// Tokens are additional management keys restricted to a role. The secret key, the
+	// MANAGEMENT_PASSWORD and the local password always act as admin.
+	Tokens []ManagementToken `yaml:"tokens,omitempty"`
+}
+
+// Management roles, from most to least privileged.
+const (
+	// ManagementRoleAdmin may use every management endpoint.
+	ManagementRoleAdmin = "admin"
+	// ManagementRoleOperator may inspect the proxy and change operational settings such as
+	// account status and logging, but may not read or change credentials.
+	ManagementRoleOperator = "operator"
+	// ManagementRoleReadOnly may only inspect state that carries no credentials.
+	ManagementRoleReadOnly = "read-only"
+)
+
+// ManagementToken is a management key bound to a role.
+type ManagementToken struct {
+	// Name identifies the token in the audit log; defaults to "token-<n>".
+	Name string `yaml:"name,omitempty"`
+	// Key is the token (plaintext or bcrypt hashed).
+	Key string `yaml:"key"`
+	// Role is "admin", "operator" or "read-only".
+	Role string `yaml:"role"`
 }
 
 // QuotaExceeded defines the behavior when API quota limits are exceeded.
@@ -116,6 +1636,12 @@ type ClaudeModel struct {
 
 	// Alias is the client-facing model name that maps to Name.
 	Alias string `yaml:"alias" json:"alias"`
+	// Capabilities lists the features the model supports: "vision", "tools" and "json-mode".
+	// With capability routing enabled, requests needing a feature it lacks are not sent to it.
+	Capabilities []string `yaml:"capabilities,omitempty" json:"capabilities,omitempty"`
+
+	// ContextWindow is the number of input tokens the model accepts; zero when unknown.
+	ContextWindow int `yaml:"context-window,omitempty" json:"context-window,omitempty"`
 }
 
 // CodexKey represents the configuration for a Codex API key,
@@ -124,12 +1650,217 @@ type CodexKey struct {
 	// APIKey is the authentication key for accessing Codex API services.
 	APIKey string `yaml:"api-key" json:"api-key"`
 
-	// BaseURL is the base URL for the Codex API endpoint.
-	// If empty, the default Codex API URL will be used.
-	BaseURL string `yaml:"base-url" json:"base-url"`
+	// BaseURL is the base URL for the Codex API endpoint.
+	// If empty, the default Codex API URL will be used.
+	BaseURL string `yaml:"base-url" json:"base-url"`
+
+	// ProxyURL overrides the global proxy setting for this API key if provided.
+	ProxyURL string `yaml:"proxy-url" json:"proxy-url"`
+}
+
+// BedrockKey represents the configuration for AWS Bedrock credentials in one region.
+// Requests are signed with SigV4 using the access key, or authorized with a Bedrock API
+// key when APIKey is set.
+type BedrockKey struct {
+	// Region is the AWS region of the Bedrock runtime, such as "us-east-1".
+	Region string `yaml:"region" json:"region"`
+
+	// AccessKeyID is the AWS access key ID used to sign requests.
+	AccessKeyID string `yaml:"access-key-id,omitempty" json:"access-key-id,omitempty"`
+
+	// SecretAccessKey is the AWS secret access key used to sign requests.
+	SecretAccessKey string `yaml:"secret-access-key,omitempty" json:"secret-access-key,omitempty"`
+
+	// SessionToken is the session token of temporary AWS credentials.
+	SessionToken string `yaml:"session-token,omitempty" json:"session-token,omitempty"`
+
+	// APIKey is a Bedrock API key, sent as a bearer token instead of signing requests.
+	APIKey string `yaml:"api-key,omitempty" json:"api-key,omitempty"`
+
+	// BaseURL is the base URL for the Bedrock runtime endpoint.
+	// If empty, https://bedrock-runtime.<region>.amazonaws.com is used.
+	BaseURL string `yaml:"base-url,omitempty" json:"base-url,omitempty"`
+
+	// ProxyURL overrides the global proxy setting for these credentials if provided.
+	ProxyURL string `yaml:"proxy-url,omitempty" json:"proxy-url,omitempty"`
+
+	// Models defines the Bedrock model IDs or inference profiles and their aliases.
+	Models []BedrockModel `yaml:"models" json:"models"`
+}
+
+// BedrockModel describes a mapping between an alias and a Bedrock model.
+type BedrockModel struct {
+	// Name is the Bedrock model ID, inference profile ID or ARN used when issuing requests.
+	Name string `yaml:"name" json:"name"`
+
+	// Alias is the client-facing model name that maps to Name.
+	Alias string `yaml:"alias" json:"alias"`
+	// Capabilities lists the features the model supports: "vision", "tools" and "json-mode".
+	// With capability routing enabled, requests needing a feature it lacks are not sent to it.
+	Capabilities []string `yaml:"capabilities,omitempty" json:"capabilities,omitempty"`
+
+	// ContextWindow is the number of input tokens the model accepts; zero when unknown.
+	ContextWindow int `yaml:"context-window,omitempty" json:"context-window,omitempty"`
+}
+
+// AzureOpenAIKey represents the configuration for an Azure OpenAI resource. Requests are
+// routed to the deployment mapped to the requested model and authorized with the resource
+// API key, or with a Microsoft Entra ID (Azure AD) token obtained for a service principal.
+type AzureOpenAIKey struct {
+	// BaseURL is the endpoint of the resource, such as https://my-resource.openai.azure.com.
+	BaseURL string `yaml:"base-url" json:"base-url"`
+
+	// APIVersion is the api-version query parameter sent with every request.
+	// If empty, 2024-10-21 is used.
+	APIVersion string `yaml:"api-version,omitempty" json:"api-version,omitempty"`
+
+	// APIKey is the resource key, sent in the api-key header.
+	APIKey string `yaml:"api-key,omitempty" json:"api-key,omitempty"`
+
+	// AzureAD configures token authentication with a service principal instead of APIKey.
+	AzureAD AzureADCredentials `yaml:"azure-ad,omitempty" json:"azure-ad,omitempty"`
+
+	// ProxyURL overrides the global proxy setting for this resource if provided.
+	ProxyURL string `yaml:"proxy-url,omitempty" json:"proxy-url,omitempty"`
+
+	// Deployments maps client-facing model names to the deployments of the resource.
+	Deployments []AzureOpenAIDeployment `yaml:"deployments" json:"deployments"`
+}
+
+// AzureADCredentials holds the client credentials of a Microsoft Entra ID service principal.
+type AzureADCredentials struct {
+	// TenantID is the directory (tenant) ID of the service principal.
+	TenantID string `yaml:"tenant-id,omitempty" json:"tenant-id,omitempty"`
+
+	// ClientID is the application (client) ID of the service principal.
+	ClientID string `yaml:"client-id,omitempty" json:"client-id,omitempty"`
+
+	// ClientSecret is the client secret of the service principal.
+	ClientSecret string `yaml:"client-secret,omitempty" json:"client-secret,omitempty"`
+
+	// AuthorityHost is the token endpoint host, for sovereign clouds.
+	// If empty, https://login.microsoftonline.com is used.
+	AuthorityHost string `yaml:"authority-host,omitempty" json:"authority-host,omitempty"`
+}
+
+// AzureOpenAIDeployment describes a mapping between a model name and an Azure deployment.
+type AzureOpenAIDeployment struct {
+	// Name is the deployment name used in request paths.
+	Name string `yaml:"name" json:"name"`
+
+	// Alias is the client-facing model name that maps to the deployment; the deployment
+	// name itself is used when empty.
+	Alias string `yaml:"alias" json:"alias"`
+	// Capabilities lists the features the model supports: "vision", "tools" and "json-mode".
+	// With capability routing enabled, requests needing a feature it lacks are not sent to it.
+	Capabilities []string `yaml:"capabilities,omitempty" json:"capabilities,omitempty"`
+
+	// ContextWindow is the number of input tokens the model accepts; zero when unknown.
+	ContextWindow int `yaml:"context-window,omitempty" json:"context-window,omitempty"`
+}
+
+// VertexAIKey represents the configuration for a Google Cloud project serving models through
+// Vertex AI. Requests are authorized with a service account key, or with Application Default
+// Credentials (workload identity, the metadata server or GOOGLE_APPLICATION_CREDENTIALS) when
+// no key is configured, and fail over across the configured regions.
+type VertexAIKey struct {
+	// ProjectID is the Google Cloud project. If empty, the project of the credentials is used.
+	ProjectID string `yaml:"project-id,omitempty" json:"project-id,omitempty"`
+
+	// Regions lists the Vertex AI locations to send requests to, in order of preference, such
+	// as "us-central1" or "global". A request that fails with a rate limit or a server error is
+	// retried in the next region. If empty, us-central1 is used.
+	Regions []string `yaml:"regions,omitempty" json:"regions,omitempty"`
+
+	// CredentialsFile is the path of a service account JSON key file.
+	CredentialsFile string `yaml:"credentials-file,omitempty" json:"credentials-file,omitempty"`
+
+	// Credentials is a service account JSON key given inline instead of CredentialsFile.
+	Credentials string `yaml:"credentials,omitempty" json:"credentials,omitempty"`
+
+	// BaseURL overrides the regional endpoint, for private service connect endpoints.
+	// If empty, https://<region>-aiplatform.googleapis.com is used.
+	BaseURL string `yaml:"base-url,omitempty" json:"base-url,omitempty"`
+
+	// ProxyURL overrides the global proxy setting for this project if provided.
+	ProxyURL string `yaml:"proxy-url,omitempty" json:"proxy-url,omitempty"`
+
+	// Models defines the publisher models served by the project and their aliases. If empty,
+	// the built-in Gemini models are registered.
+	Models []VertexAIModel `yaml:"models,omitempty" json:"models,omitempty"`
+}
+
+// VertexAIModel describes a mapping between an alias and a Vertex AI publisher model.
+type VertexAIModel struct {
+	// Name is the publisher model ID used when issuing requests, such as "gemini-2.5-pro" or
+	// "claude-sonnet-4@20250514".
+	Name string `yaml:"name" json:"name"`
+
+	// Alias is the client-facing model name that maps to Name.
+	Alias string `yaml:"alias" json:"alias"`
+
+	// Publisher is "google" or "anthropic". If empty, it is derived from the model name.
+	Publisher string `yaml:"publisher,omitempty" json:"publisher,omitempty"`
+	// Capabilities lists the features the model supports: "vision", "tools" and "json-mode".
+	// With capability routing enabled, requests needing a feature it lacks are not sent to it.
+	Capabilities []string `yaml:"capabilities,omitempty" json:"capabilities,omitempty"`
+
+	// ContextWindow is the number of input tokens the model accepts; zero when unknown.
+	ContextWindow int `yaml:"context-window,omitempty" json:"context-window,omitempty"`
+}
+
+// XAIKey represents the configuration for an xAI API key. Requests use the OpenAI Chat
+// Completions schema of the xAI API.
+type XAIKey struct {
+	// APIKey is the authentication key for accessing the xAI API.
+	APIKey string `yaml:"api-key" json:"api-key"`
+
+	// BaseURL is the base URL for the xAI API endpoint.
+	// If empty, https://api.x.ai/v1 is used.
+	BaseURL string `yaml:"base-url,omitempty" json:"base-url,omitempty"`
+
+	// ProxyURL overrides the global proxy setting for this API key if provided.
+	ProxyURL string `yaml:"proxy-url,omitempty" json:"proxy-url,omitempty"`
+
+	// LiveSearch sets the search_parameters sent with requests that do not set them.
+	LiveSearch XAILiveSearch `yaml:"live-search,omitempty" json:"live-search,omitempty"`
+
+	// Models defines upstream model names and aliases for request routing. If empty, the
+	// built-in Grok models are registered.
+	Models []XAIModel `yaml:"models,omitempty" json:"models,omitempty"`
+}
+
+// XAILiveSearch configures xAI Live Search, which lets Grok models search the web, X and
+// news sources while answering.
+type XAILiveSearch struct {
+	// Mode is "off", "auto" or "on". When empty, search is only enabled, in auto mode, for
+	// requests that ask for web search themselves. "off" disables it for those as well.
+	Mode string `yaml:"mode,omitempty" json:"mode,omitempty"`
+
+	// Sources lists the data sources searched: "web", "x", "news" and "rss". If empty, xAI
+	// searches web and X.
+	Sources []string `yaml:"sources,omitempty" json:"sources,omitempty"`
+
+	// MaxSearchResults limits the number of sources considered; zero uses the xAI default.
+	MaxSearchResults int `yaml:"max-search-results,omitempty" json:"max-search-results,omitempty"`
+
+	// ReturnCitations controls whether responses include the URLs of the sources used.
+	ReturnCitations *bool `yaml:"return-citations,omitempty" json:"return-citations,omitempty"`
+}
+
+// XAIModel describes a mapping between an alias and an xAI model.
+type XAIModel struct {
+	// Name is the xAI model ID used when issuing requests, such as "grok-4".
+	Name string `yaml:"name" json:"name"`
+
+	// Alias is the client-facing model name that maps to Name.
+	Alias string `yaml:"alias" json:"alias"`
+	// Capabilities lists the features the model supports: "vision", "tools" and "json-mode".
+	// With capability routing enabled, requests needing a feature it lacks are not sent to it.
+	Capabilities []string `yaml:"capabilities,omitempty" json:"capabilities,omitempty"`
 
-	// ProxyURL overrides the global proxy setting for this API key if provided.
-	ProxyURL string `yaml:"proxy-url" json:"proxy-url"`
+	// ContextWindow is the number of input tokens the model accepts; zero when unknown.
+	ContextWindow int `yaml:"context-window,omitempty" json:"context-window,omitempty"`
 }
 
 // OpenAICompatibility represents the configuration for OpenAI API compatibility
@@ -150,6 +1881,13 @@ type OpenAICompatibility struct {
 
 	// Models defines the model configurations including aliases for routing.
 	Models []OpenAICompatibilityModel `yaml:"models" json:"models"`
+
+	// Headers are added to every request to the provider, such as HTTP-Referer and X-Title
+	// for OpenRouter. Values may contain the placeholders {api-key}, {model} (the upstream
+	// model) and {alias} (the model the client requested). A header whose value renders
+	// empty is removed, so Authorization: "" together with a custom key header replaces the
+	// default bearer token.
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
 }
 
 // OpenAICompatibilityAPIKey represents an API key configuration with optional proxy setting.
@@ -169,6 +1907,76 @@ type OpenAICompatibilityModel struct {
 
 	// Alias is the model name alias that clients will use to reference this model.
 	Alias string `yaml:"alias" json:"alias"`
+	// Capabilities lists the features the model supports: "vision", "tools" and "json-mode".
+	// With capability routing enabled, requests needing a feature it lacks are not sent to it.
+	Capabilities []string `yaml:"capabilities,omitempty" json:"capabilities,omitempty"`
+
+	// ContextWindow is the number of input tokens the model accepts; zero when unknown.
+	ContextWindow int `yaml:"context-window,omitempty" json:"context-window,omitempty"`
+
+	// BaseURL sends requests for this model to another endpoint than the provider's, so one
+	// entry can route models to different services such as Groq, Mistral or DeepSeek.
+	BaseURL string `yaml:"base-url,omitempty" json:"base-url,omitempty"`
+
+	// APIKey replaces the provider's API key for requests to this model.
+	APIKey string `yaml:"api-key,omitempty" json:"api-key,omitempty"`
+
+	// Headers are merged over the provider's headers for requests to this model.
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+}
+
+// Local backend types with their default endpoints.
+const (
+	LocalBackendOllama   = "ollama"
+	LocalBackendLlamaCpp = "llama.cpp"
+	LocalBackendVLLM     = "vllm"
+)
+
+// LocalBackend represents a local inference server that serves the OpenAI Chat Completions
+// API. Its models are discovered from the models endpoint of the server every minute, so
+// models pulled or loaded later become routable without a configuration change.
+type LocalBackend struct {
+	// Name identifies the backend in the routing table and logs. If empty, Type is used.
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+
+	// Type is "ollama", "llama.cpp" or "vllm" and selects the default BaseURL.
+	Type string `yaml:"type" json:"type"`
+
+	// BaseURL is the address of the server, with or without the /v1 suffix. If empty,
+	// http://127.0.0.1:11434 is used for Ollama, http://127.0.0.1:8080 for llama.cpp and
+	// http://127.0.0.1:8000 for vLLM.
+	BaseURL string `yaml:"base-url,omitempty" json:"base-url,omitempty"`
+
+	// APIKey is sent as a bearer token, for servers started with an API key.
+	APIKey string `yaml:"api-key,omitempty" json:"api-key,omitempty"`
+
+	// Models defines aliases and annotations for models of the server. Discovered models
+	// without an entry are listed under their own name.
+	Models []OpenAICompatibilityModel `yaml:"models,omitempty" json:"models,omitempty"`
+}
+
+// ProviderPlugin represents an upstream provider implemented as a plugin. Exactly one of
+// Type and Command is set: Type names a factory registered in-process with plugin.Register,
+// Command starts an external process that speaks the JSON-RPC protocol of the plugin SDK.
+type ProviderPlugin struct {
+	// Name identifies the provider in the routing table, logs and usage statistics.
+	Name string `yaml:"name" json:"name"`
+
+	// Type is the name of an in-process plugin factory.
+	Type string `yaml:"type,omitempty" json:"type,omitempty"`
+
+	// Command is the executable of an external plugin. It runs with the privileges of the
+	// proxy and is restarted when it exits.
+	Command string `yaml:"command,omitempty" json:"command,omitempty"`
+
+	// Args are the command line arguments of Command.
+	Args []string `yaml:"args,omitempty" json:"args,omitempty"`
+
+	// Env lists KEY=VALUE pairs added to the environment of Command.
+	Env []string `yaml:"env,omitempty" json:"env,omitempty"`
+
+	// Settings are passed to the plugin when it is created.
+	Settings map[string]string `yaml:"settings,omitempty" json:"settings,omitempty"`
 }
 
 // LoadConfig reads a YAML configuration file from the given path,
@@ -191,7 +1999,8 @@ func LoadConfig(configFile string) (*Config, error) {
 func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 	// Read the entire configuration file into memory.
 	data, err := os.ReadFile(configFile)
-	if err != nil {
+	envOnly := err != nil && os.IsNotExist(err) && hasEnvOverrides(os.Environ())
+	if err != nil && !envOnly {
 		if optional {
 			if os.IsNotExist(err) || errors.Is(err, syscall.EISDIR) {
 				// Missing and optional: return empty config (cloud deploy standby).
@@ -202,7 +2011,7 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 	}
 
 	// In cloud deploy mode (optional=true), if file is empty or contains only whitespace, return empty config.
-	if optional && len(data) == 0 {
+	if optional && len(data) == 0 && !envOnly {
 		return &Config{}, nil
 	}
 
@@ -212,13 +2021,54 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 	cfg.LoggingToFile = false
 	cfg.UsageStatisticsEnabled = false
 	cfg.DisableCooling = false
-	if err = yaml.Unmarshal(data, &cfg); err != nil {
+	var root yaml.Node
+	if err = yaml.Unmarshal(data, &root); err == nil && len(root.Content) > 0 && root.Content[0].Kind != yaml.MappingNode {
+		err = fmt.Errorf("expected a mapping at the top level")
+	}
+	if err != nil {
 		if optional {
 			// In cloud deploy mode, if YAML parsing fails, return empty config instead of error.
 			return &Config{}, nil
 		}
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
+	if len(root.Content) == 0 {
+		root = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+
+	// Expand ${VAR} references, then apply the CLIPROXY_ variables, which take precedence
+	// over the file.
+	secretKeyPath := []string{"remote-management", "secret-key"}
+	secretKeyRaw, secretKeyOverridden := "", false
+	if node := lookupNodePath(root.Content[0], secretKeyPath); node != nil && node.Kind == yaml.ScalarNode {
+		secretKeyRaw = node.Value
+	}
+	if err = interpolateEnv(root.Content[0]); err != nil {
+		return nil, fmt.Errorf("failed to expand config file: %w", err)
+	}
+	if cfg.envOverrides, err = applyEnvOverrides(root.Content[0], os.Environ()); err != nil {
+		return nil, fmt.Errorf("failed to apply environment overrides: %w", err)
+	}
+	for _, path := range cfg.envOverrides {
+		if strings.Join(path, ".") == strings.Join(secretKeyPath, ".") {
+			secretKeyOverridden = true
+		}
+	}
+	if err = root.Decode(&cfg); err != nil {
+		if optional {
+			return &Config{}, nil
+		}
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	// Replace secret references with the values held by the secrets managers.
+	secretKeyRef := ""
+	if IsSecretReference(cfg.RemoteManagement.SecretKey) {
+		secretKeyRef = cfg.RemoteManagement.SecretKey
+	}
+	if err = resolveSecretReferences(&cfg); err != nil {
+		return nil, fmt.Errorf("failed to resolve secrets: %w", err)
+	}
 
 	// Hash remote management key if plaintext is detected (nested)
 	// We consider a value to be already hashed if it looks like a bcrypt hash ($2a$, $2b$, or $2y$ prefix).
@@ -229,9 +2079,71 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 		}
 		cfg.RemoteManagement.SecretKey = hashed
 
-		// Persist the hashed value back to the config file to avoid re-hashing on next startup.
-		// Preserve YAML comments and ordering; update only the nested key.
-		_ = SaveConfigPreserveCommentsUpdateNestedScalar(configFile, []string{"remote-management", "secret-key"}, hashed)
+		if secretKeyRef == "" && strings.Contains(secretKeyRaw, "${") {
+			secretKeyRef = secretKeyRaw
+		}
+		switch {
+		case secretKeyRef != "":
+			// The key lives in a secrets manager or the environment; keep the reference in the file.
+			if cfg.secretRefs == nil {
+				cfg.secretRefs = make(map[string]string)
+			}
+			cfg.secretRefs[hashed] = secretKeyRef
+		case secretKeyOverridden:
+			// Set by an environment variable; saving restores the file's value.
+		default:
+			// Persist the hashed value back to the config file to avoid re-hashing on next startup.
+			// Preserve YAML comments and ordering; update only the nested key.
+			_ = SaveConfigPreserveCommentsUpdateNestedScalar(configFile, secretKeyPath, hashed)
+		}
+	}
+
+	if err = sanitizeManagementTokens(&cfg); err != nil {
+		return nil, err
+	}
+
+	if err = sanitizeAccountProxies(&cfg); err != nil {
+		return nil, err
+	}
+
+	if err = sanitizeAccountConcurrency(&cfg); err != nil {
+		return nil, err
+	}
+
+	if err = sanitizeFiles(&cfg); err != nil {
+		return nil, err
+	}
+
+	if err = sanitizeGuardrails(&cfg); err != nil {
+		return nil, err
+	}
+
+	if err = sanitizeModeration(&cfg); err != nil {
+		return nil, err
+	}
+
+	if err = sanitizePIIRedaction(&cfg); err != nil {
+		return nil, err
+	}
+
+	if err = sanitizeHooks(&cfg); err != nil {
+		return nil, err
+	}
+
+	if err = sanitizeRoutingRules(&cfg); err != nil {
+		return nil, err
+	}
+
+	if err = sanitizeSessions(&cfg); err != nil {
+		return nil, err
+	}
+
+	if err = sanitizeSystemPrompts(&cfg); err != nil {
+		return nil, err
+	}
+
+	if err = sanitizeContextCompaction(&cfg); err != nil {
+		return nil, err
 	}
 
 	// Sync request authentication providers with inline API keys for backwards compatibility.
@@ -242,6 +2154,12 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 
 	// Sanitize Codex keys: drop entries without base-url
 	sanitizeCodexKeys(&cfg)
+	sanitizeBedrockKeys(&cfg)
+	sanitizeAzureOpenAI(&cfg)
+	sanitizeVertexAI(&cfg)
+	sanitizeXAIKeys(&cfg)
+	sanitizeLocalBackends(&cfg)
+	sanitizeProviderPlugins(&cfg)
 
 	// Return the populated configuration struct.
 	return &cfg, nil
@@ -263,11 +2181,319 @@ func sanitizeOpenAICompatibility(cfg *Config) {
 			// Skip providers with no base-url; treated as removed
 			continue
 		}
+		for j := range e.Models {
+			e.Models[j].BaseURL = strings.TrimSpace(e.Models[j].BaseURL)
+			e.Models[j].APIKey = strings.TrimSpace(e.Models[j].APIKey)
+		}
 		out = append(out, e)
 	}
 	cfg.OpenAICompatibility = out
 }
 
+// sanitizeManagementTokens drops management tokens without a key, names unnamed ones and
+// normalizes their roles. An unknown role is an error rather than silently granting access.
+func sanitizeManagementTokens(cfg *Config) error {
+	if cfg == nil || len(cfg.RemoteManagement.Tokens) == 0 {
+		return nil
+	}
+	out := make([]ManagementToken, 0, len(cfg.RemoteManagement.Tokens))
+	for i, token := range cfg.RemoteManagement.Tokens {
+		token.Key = strings.TrimSpace(token.Key)
+		if token.Key == "" {
+			continue
+		}
+		token.Name = strings.TrimSpace(token.Name)
+		if token.Name == "" {
+			token.Name = fmt.Sprintf("token-%d", i+1)
+		}
+		token.Role = strings.ToLower(strings.TrimSpace(token.Role))
+		switch token.Role {
+		case ManagementRoleAdmin, ManagementRoleOperator, ManagementRoleReadOnly:
+		default:
+			return fmt.Errorf("remote-management.tokens[%d]: unknown role %q, expected admin, operator or read-only", i, token.Role)
+		}
+		out = append(out, token)
+	}
+	cfg.RemoteManagement.Tokens = out
+	return nil
+}
+
+// sanitizeAccountProxies trims the account proxy rules and rejects proxy URLs that cannot
+// be used. Accounts pinned to a proxy must not silently egress directly instead.
+func sanitizeAccountProxies(cfg *Config) error {
+	if cfg == nil || len(cfg.AccountProxies) == 0 {
+		return nil
+	}
+	for i := range cfg.AccountProxies {
+		p := &cfg.AccountProxies[i]
+		p.Match = strings.TrimSpace(p.Match)
+		p.ProxyURL = strings.TrimSpace(p.ProxyURL)
+		if p.Match == "" {
+			return fmt.Errorf("account-proxies[%d]: match is required", i)
+		}
+		parsed, err := url.Parse(p.ProxyURL)
+		if err != nil || parsed.Host == "" {
+			return fmt.Errorf("account-proxies[%d]: invalid proxy-url", i)
+		}
+		switch parsed.Scheme {
+		case "socks5", "http", "https":
+		default:
+			return fmt.Errorf("account-proxies[%d]: unsupported proxy scheme %q, expected socks5, http or https", i, parsed.Scheme)
+		}
+	}
+	return nil
+}
+
+// sanitizeAccountConcurrency normalizes the account concurrency entries, dropping those
+// without a limit. An unknown mode is an error.
+func sanitizeAccountConcurrency(cfg *Config) error {
+	if cfg == nil || len(cfg.AccountConcurrency) == 0 {
+		return nil
+	}
+	out := make([]AccountConcurrency, 0, len(cfg.AccountConcurrency))
+	for i, entry := range cfg.AccountConcurrency {
+		if entry.MaxInFlight <= 0 {
+			continue
+		}
+		entry.Provider = strings.ToLower(strings.TrimSpace(entry.Provider))
+		entry.Match = strings.TrimSpace(entry.Match)
+		entry.Mode = strings.ToLower(strings.TrimSpace(entry.Mode))
+		switch entry.Mode {
+		case "":
+			entry.Mode = AccountConcurrencyQueue
+		case AccountConcurrencyQueue, AccountConcurrencyFail:
+		default:
+			return fmt.Errorf("account-concurrency[%d]: unknown mode %q, expected queue or fail", i, entry.Mode)
+		}
+		out = append(out, entry)
+	}
+	cfg.AccountConcurrency = out
+	return nil
+}
+
+// sanitizeFiles normalizes the Files API storage settings. An unknown backend or an s3
+// backend without endpoint or bucket is an error.
+func sanitizeFiles(cfg *Config) error {
+	if cfg == nil {
+		return nil
+	}
+	files := &cfg.Files
+	files.Storage = strings.ToLower(strings.TrimSpace(files.Storage))
+	files.Dir = strings.TrimSpace(files.Dir)
+	switch files.Storage {
+	case "":
+		files.Storage = FilesStorageLocal
+	case FilesStorageLocal:
+	case FilesStorageS3:
+		files.S3.Endpoint = strings.TrimSpace(files.S3.Endpoint)
+		files.S3.Bucket = strings.TrimSpace(files.S3.Bucket)
+		files.S3.Prefix = strings.Trim(strings.TrimSpace(files.S3.Prefix), "/")
+		if files.S3.Endpoint == "" || files.S3.Bucket == "" {
+			return fmt.Errorf("files.s3: endpoint and bucket are required")
+		}
+	default:
+		return fmt.Errorf("files: unknown storage %q, expected local or s3", files.Storage)
+	}
+	return nil
+}
+
+// sanitizeGuardrails normalizes guardrail strategies. An unknown strategy is an error.
+func sanitizeGuardrails(cfg *Config) error {
+	if cfg == nil {
+		return nil
+	}
+	for i := range cfg.Guardrails {
+		entry := &cfg.Guardrails[i]
+		entry.APIKey = strings.TrimSpace(entry.APIKey)
+		entry.Strategy = strings.ToLower(strings.TrimSpace(entry.Strategy))
+		switch entry.Strategy {
+		case "":
+			entry.Strategy = GuardrailReject
+		case GuardrailReject, GuardrailTruncateOldest, GuardrailTruncateMiddle:
+		default:
+			return fmt.Errorf("guardrails[%d]: unknown strategy %q, expected reject, truncate-oldest or truncate-middle", i, entry.Strategy)
+		}
+	}
+	return nil
+}
+
+// sanitizeModeration normalizes blocklist actions. Invalid patterns and unknown actions are
+// errors.
+func sanitizeModeration(cfg *Config) error {
+	if cfg == nil {
+		return nil
+	}
+	moderation := &cfg.Moderation
+	for i := range moderation.Blocklist {
+		rule := &moderation.Blocklist[i]
+		rule.Name = strings.TrimSpace(rule.Name)
+		rule.Action = strings.ToLower(strings.TrimSpace(rule.Action))
+		if _, err := regexp.Compile(rule.Pattern); err != nil || rule.Pattern == "" {
+			return fmt.Errorf("moderation.blocklist[%d]: invalid pattern %q", i, rule.Pattern)
+		}
+		switch rule.Action {
+		case "":
+			rule.Action = ModerationReject
+		case ModerationReject, ModerationRedact:
+		default:
+			return fmt.Errorf("moderation.blocklist[%d]: unknown action %q, expected reject or redact", i, rule.Action)
+		}
+	}
+	moderation.Endpoint.URL = strings.TrimSpace(moderation.Endpoint.URL)
+	return nil
+}
+
+// sanitizeHooks fills in hook names and normalizes stages. Unknown stages and hooks with
+// neither or both of url and wasm are errors.
+func sanitizeHooks(cfg *Config) error {
+	if cfg == nil {
+		return nil
+	}
+	for i := range cfg.Hooks {
+		hook := &cfg.Hooks[i]
+		hook.Name = strings.TrimSpace(hook.Name)
+		if hook.Name == "" {
+			hook.Name = fmt.Sprintf("hook-%d", i+1)
+		}
+		hook.Stage = strings.ToLower(strings.TrimSpace(hook.Stage))
+		switch hook.Stage {
+		case HookStagePreRequest, HookStagePostResponse:
+		default:
+			return fmt.Errorf("hooks[%d]: unknown stage %q, expected pre-request or post-response", i, hook.Stage)
+		}
+		hook.URL = strings.TrimSpace(hook.URL)
+		hook.WASM = strings.TrimSpace(hook.WASM)
+		if (hook.URL == "") == (hook.WASM == "") {
+			return fmt.Errorf("hooks[%d]: set exactly one of url and wasm", i)
+		}
+	}
+	return nil
+}
+
+// sanitizeSystemPrompts fills in rule names. Rules without a prefix or suffix are errors.
+func sanitizeSystemPrompts(cfg *Config) error {
+	if cfg == nil {
+		return nil
+	}
+	for i := range cfg.SystemPrompts.Rules {
+		rule := &cfg.SystemPrompts.Rules[i]
+		rule.Name = strings.TrimSpace(rule.Name)
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("system-prompt-%d", i+1)
+		}
+		if strings.TrimSpace(rule.Prefix) == "" && strings.TrimSpace(rule.Suffix) == "" {
+			return fmt.Errorf("system-prompts.rules[%d]: prefix or suffix is required", i)
+		}
+	}
+	return nil
+}
+
+// sanitizeSessions normalizes the sessions backend and trimming strategy. Unknown backends
+// and strategies are errors.
+func sanitizeSessions(cfg *Config) error {
+	if cfg == nil {
+		return nil
+	}
+	sessions := &cfg.Sessions
+	sessions.Backend = strings.ToLower(strings.TrimSpace(sessions.Backend))
+	switch sessions.Backend {
+	case "", "memory", "redis":
+	default:
+		return fmt.Errorf("sessions: unknown backend %q, expected memory or redis", sessions.Backend)
+	}
+	sessions.Strategy = strings.ToLower(strings.TrimSpace(sessions.Strategy))
+	switch sessions.Strategy {
+	case "":
+		sessions.Strategy = SessionTrimOldest
+	case SessionTrimOldest, SessionTrimMiddle:
+	default:
+		return fmt.Errorf("sessions: unknown strategy %q, expected truncate-oldest or truncate-middle", sessions.Strategy)
+	}
+	return nil
+}
+
+// sanitizeContextCompaction normalizes the compaction strategy and defaults the summary
+// length. Unknown strategies and the summarize strategy without a summary model are errors.
+func sanitizeContextCompaction(cfg *Config) error {
+	if cfg == nil {
+		return nil
+	}
+	compaction := &cfg.ContextCompaction
+	compaction.Strategy = strings.ToLower(strings.TrimSpace(compaction.Strategy))
+	compaction.SummaryModel = strings.TrimSpace(compaction.SummaryModel)
+	switch compaction.Strategy {
+	case "":
+		compaction.Strategy = config.CompactionSlidingWindow
+	case config.CompactionSlidingWindow, config.CompactionDropOldest:
+	case config.CompactionSummarize:
+		if compaction.Enable && compaction.SummaryModel == "" {
+			return fmt.Errorf("context-compaction: the summarize strategy requires summary-model")
+		}
+	default:
+		return fmt.Errorf("context-compaction: unknown strategy %q, expected sliding-window, drop-oldest or summarize", compaction.Strategy)
+	}
+	if compaction.ReserveTokens < 0 {
+		compaction.ReserveTokens = 0
+	}
+	if compaction.SummaryMaxTokens <= 0 {
+		compaction.SummaryMaxTokens = 1024
+	}
+	return nil
+}
+
+// sanitizeRoutingRules fills in rule names and compiles the rule expressions. Rules without
+// an expression or model and expressions that do not compile to a bool are errors.
+func sanitizeRoutingRules(cfg *Config) error {
+	if cfg == nil {
+		return nil
+	}
+	for i := range cfg.RoutingRules {
+		entry := &cfg.RoutingRules[i]
+		entry.Name = strings.TrimSpace(entry.Name)
+		if entry.Name == "" {
+			entry.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		entry.When = strings.TrimSpace(entry.When)
+		entry.Model = strings.TrimSpace(entry.Model)
+		if entry.When == "" || entry.Model == "" {
+			return fmt.Errorf("routing-rules[%d]: when and model are required", i)
+		}
+	}
+	if _, err := routingrule.Compile(cfg.RoutingRules); err != nil {
+		return err
+	}
+	return nil
+}
+
+// sanitizePIIRedaction normalizes detector names and pattern labels. Unknown detectors,
+// unnamed patterns and invalid expressions are errors.
+func sanitizePIIRedaction(cfg *Config) error {
+	if cfg == nil {
+		return nil
+	}
+	pii := &cfg.PIIRedaction
+	for i, detector := range pii.Detectors {
+		detector = strings.ToLower(strings.TrimSpace(detector))
+		switch detector {
+		case PIIDetectorEmail, PIIDetectorPhone, PIIDetectorCreditCard:
+		default:
+			return fmt.Errorf("pii-redaction.detectors[%d]: unknown detector %q, expected email, phone or credit-card", i, detector)
+		}
+		pii.Detectors[i] = detector
+	}
+	for i := range pii.Patterns {
+		pattern := &pii.Patterns[i]
+		pattern.Name = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(pattern.Name), "-", "_"))
+		if pattern.Name == "" {
+			return fmt.Errorf("pii-redaction.patterns[%d]: name is required", i)
+		}
+		if _, err := regexp.Compile(pattern.Pattern); err != nil || pattern.Pattern == "" {
+			return fmt.Errorf("pii-redaction.patterns[%d]: invalid pattern %q", i, pattern.Pattern)
+		}
+	}
+	return nil
+}
+
 // sanitizeCodexKeys removes Codex API key entries missing a BaseURL.
 // It trims whitespace and preserves order for remaining entries.
 func sanitizeCodexKeys(cfg *Config) {
@@ -286,6 +2512,192 @@ func sanitizeCodexKeys(cfg *Config) {
 	cfg.CodexKey = out
 }
 
+// sanitizeBedrockKeys removes Bedrock entries missing a region or credentials, which are
+// either an API key or an access key ID with its secret. It trims whitespace and preserves
+// order for remaining entries.
+func sanitizeBedrockKeys(cfg *Config) {
+	if cfg == nil || len(cfg.BedrockKey) == 0 {
+		return
+	}
+	out := make([]BedrockKey, 0, len(cfg.BedrockKey))
+	for i := range cfg.BedrockKey {
+		e := cfg.BedrockKey[i]
+		e.Region = strings.TrimSpace(e.Region)
+		e.AccessKeyID = strings.TrimSpace(e.AccessKeyID)
+		e.SecretAccessKey = strings.TrimSpace(e.SecretAccessKey)
+		e.SessionToken = strings.TrimSpace(e.SessionToken)
+		e.APIKey = strings.TrimSpace(e.APIKey)
+		e.BaseURL = strings.TrimSpace(e.BaseURL)
+		if e.Region == "" {
+			continue
+		}
+		if e.APIKey == "" && (e.AccessKeyID == "" || e.SecretAccessKey == "") {
+			continue
+		}
+		out = append(out, e)
+	}
+	cfg.BedrockKey = out
+}
+
+// sanitizeAzureOpenAI removes Azure OpenAI entries missing a BaseURL or credentials, which
+// are either an API key or a tenant ID, client ID and client secret. It trims whitespace
+// and preserves order for remaining entries.
+func sanitizeAzureOpenAI(cfg *Config) {
+	if cfg == nil || len(cfg.AzureOpenAI) == 0 {
+		return
+	}
+	out := make([]AzureOpenAIKey, 0, len(cfg.AzureOpenAI))
+	for i := range cfg.AzureOpenAI {
+		e := cfg.AzureOpenAI[i]
+		e.BaseURL = strings.TrimSpace(e.BaseURL)
+		e.APIVersion = strings.TrimSpace(e.APIVersion)
+		e.APIKey = strings.TrimSpace(e.APIKey)
+		e.AzureAD.TenantID = strings.TrimSpace(e.AzureAD.TenantID)
+		e.AzureAD.ClientID = strings.TrimSpace(e.AzureAD.ClientID)
+		e.AzureAD.ClientSecret = strings.TrimSpace(e.AzureAD.ClientSecret)
+		e.AzureAD.AuthorityHost = strings.TrimSpace(e.AzureAD.AuthorityHost)
+		if e.BaseURL == "" {
+			continue
+		}
+		if e.APIKey == "" && (e.AzureAD.TenantID == "" || e.AzureAD.ClientID == "" || e.AzureAD.ClientSecret == "") {
+			continue
+		}
+		out = append(out, e)
+	}
+	cfg.AzureOpenAI = out
+}
+
+func sanitizeVertexAI(cfg *Config) {
+	if cfg == nil || len(cfg.VertexAI) == 0 {
+		return
+	}
+	for i := range cfg.VertexAI {
+		e := &cfg.VertexAI[i]
+		e.ProjectID = strings.TrimSpace(e.ProjectID)
+		e.CredentialsFile = strings.TrimSpace(e.CredentialsFile)
+		e.Credentials = strings.TrimSpace(e.Credentials)
+		e.BaseURL = strings.TrimSpace(e.BaseURL)
+		regions := make([]string, 0, len(e.Regions))
+		for _, region := range e.Regions {
+			if region = strings.ToLower(strings.TrimSpace(region)); region != "" {
+				regions = append(regions, region)
+			}
+		}
+		if len(regions) == 0 {
+			regions = append(regions, "us-central1")
+		}
+		e.Regions = regions
+	}
+}
+
+// sanitizeXAIKeys removes xAI entries without an API key, trims whitespace and normalizes
+// the live search mode and sources. It preserves order for remaining entries.
+func sanitizeXAIKeys(cfg *Config) {
+	if cfg == nil || len(cfg.XAIKey) == 0 {
+		return
+	}
+	out := make([]XAIKey, 0, len(cfg.XAIKey))
+	for i := range cfg.XAIKey {
+		e := cfg.XAIKey[i]
+		e.APIKey = strings.TrimSpace(e.APIKey)
+		e.BaseURL = strings.TrimSpace(e.BaseURL)
+		if e.APIKey == "" {
+			continue
+		}
+		e.LiveSearch.Mode = strings.ToLower(strings.TrimSpace(e.LiveSearch.Mode))
+		sources := make([]string, 0, len(e.LiveSearch.Sources))
+		for _, source := range e.LiveSearch.Sources {
+			if source = strings.ToLower(strings.TrimSpace(source)); source != "" {
+				sources = append(sources, source)
+			}
+		}
+		e.LiveSearch.Sources = sources
+		out = append(out, e)
+	}
+	cfg.XAIKey = out
+}
+
+// sanitizeLocalBackends fills in the default name and base URL of local backends and drops
+// the entries without a base URL or whose name is already taken.
+func sanitizeLocalBackends(cfg *Config) {
+	if cfg == nil || len(cfg.LocalBackends) == 0 {
+		return
+	}
+	seen := make(map[string]struct{}, len(cfg.LocalBackends))
+	out := make([]LocalBackend, 0, len(cfg.LocalBackends))
+	for i := range cfg.LocalBackends {
+		e := cfg.LocalBackends[i]
+		e.Type = strings.ToLower(strings.TrimSpace(e.Type))
+		e.Name = strings.TrimSpace(e.Name)
+		e.BaseURL = strings.TrimSpace(e.BaseURL)
+		e.APIKey = strings.TrimSpace(e.APIKey)
+		if e.BaseURL == "" {
+			switch e.Type {
+			case LocalBackendOllama:
+				e.BaseURL = "http://127.0.0.1:11434"
+			case LocalBackendLlamaCpp:
+				e.BaseURL = "http://127.0.0.1:8080"
+			case LocalBackendVLLM:
+				e.BaseURL = "http://127.0.0.1:8000"
+			default:
+				continue
+			}
+		}
+		if e.Name == "" {
+			e.Name = e.Type
+		}
+		if e.Name == "" {
+			e.Name = "local"
+		}
+		key := strings.ToLower(e.Name)
+		if _, exists := seen[key]; exists {
+			continue
+		}
+		seen[key] = struct{}{}
+		out = append(out, e)
+	}
+	cfg.LocalBackends = out
+}
+
+// builtinProviders lists the provider names served by built-in executors.
+var builtinProviders = []string{"gemini", "gemini-cli", "aistudio", "claude", "codex", "bedrock", "azure-openai", "vertex", "xai", "qwen", "iflow", "openai-compatibility"}
+
+// sanitizeProviderPlugins drops provider plugins without a name, with neither or both of
+// type and command, or whose name is already taken by a built-in provider, an
+// OpenAI-compatible provider, a local backend or an earlier plugin.
+func sanitizeProviderPlugins(cfg *Config) {
+	if cfg == nil || len(cfg.ProviderPlugins) == 0 {
+		return
+	}
+	seen := make(map[string]struct{}, len(cfg.ProviderPlugins)+len(builtinProviders))
+	for _, name := range builtinProviders {
+		seen[name] = struct{}{}
+	}
+	for i := range cfg.OpenAICompatibility {
+		seen[strings.ToLower(strings.TrimSpace(cfg.OpenAICompatibility[i].Name))] = struct{}{}
+	}
+	for i := range cfg.LocalBackends {
+		seen[strings.ToLower(cfg.LocalBackends[i].Name)] = struct{}{}
+	}
+	out := make([]ProviderPlugin, 0, len(cfg.ProviderPlugins))
+	for i := range cfg.ProviderPlugins {
+		e := cfg.ProviderPlugins[i]
+		e.Name = strings.TrimSpace(e.Name)
+		e.Type = strings.ToLower(strings.TrimSpace(e.Type))
+		e.Command = strings.TrimSpace(e.Command)
+		if e.Name == "" || (e.Type == "") == (e.Command == "") {
+			continue
+		}
+		key := strings.ToLower(e.Name)
+		if _, exists := seen[key]; exists {
+			continue
+		}
+		seen[key] = struct{}{}
+		out = append(out, e)
+	}
+	cfg.ProviderPlugins = out
+}
+
 func syncInlineAccessProvider(cfg *Config) {
 	if cfg == nil {
 		return
@@ -350,6 +2762,10 @@ func SaveConfigPreserveComments(configFile string, cfg *Config) error {
 		return fmt.Errorf("expected generated root mapping node")
 	}
 
+	// Write secret references, not the secrets they were resolved to.
+	restoreSecretReferences(generated.Content[0], cfg.secretRefs)
+	restoreEnvSources(generated.Content[0], original.Content[0], cfg.envOverrides)
+
 	// Remove deprecated auth block before merging to avoid persisting it again.
 	removeMapKey(original.Content[0], "auth")
 
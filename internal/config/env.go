@@ -0,0 +1,282 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts the names of the environment variables that override config.yaml
+// settings. The rest of the name is the setting's path: keys are separated by a double
+// underscore, single underscores stand for dashes and list items are addressed by index,
+// e.g. CLIPROXY_REMOTE_MANAGEMENT__SECRET_KEY or CLIPROXY_CLAUDE_API_KEY__0__API_KEY.
+// Values starting with [ or { are parsed as YAML flow lists and maps.
+const EnvPrefix = "CLIPROXY_"
+
+// envReference matches ${VAR} and ${VAR:-default} in config values; $${ escapes a literal ${.
+var envReference = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// expandEnv replaces the environment references in value. A variable that is not set
+// takes its default, and is an error without one.
+func expandEnv(value string) (string, error) {
+	var missing string
+	expanded := envReference.ReplaceAllStringFunc(value, func(match string) string {
+		if match == "$${" {
+			return "${"
+		}
+		groups := envReference.FindStringSubmatch(match)
+		if env, ok := os.LookupEnv(groups[1]); ok {
+			return env
+		}
+		if groups[2] != "" {
+			return groups[3]
+		}
+		if missing == "" {
+			missing = groups[1]
+		}
+		return ""
+	})
+	if missing != "" {
+		return "", fmt.Errorf("environment variable %s is not set", missing)
+	}
+	return expanded, nil
+}
+
+// interpolateEnv expands the environment references in the scalar values of node. Mapping
+// keys are left alone.
+func interpolateEnv(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if !strings.Contains(node.Value, "${") {
+			return nil
+		}
+		expanded, err := expandEnv(node.Value)
+		if err != nil {
+			return err
+		}
+		node.Value = expanded
+		if node.Style == 0 {
+			// Let a plain ${PORT} decode as the number it expands to.
+			node.Tag = ""
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := interpolateEnv(node.Content[i]); err != nil {
+				return err
+			}
+		}
+	default:
+		for _, child := range node.Content {
+			if err := interpolateEnv(child); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// applyEnvOverrides sets the settings named by the CLIPROXY_ variables of environ in the
+// root mapping and returns their paths. Variables whose first key is not a setting, such
+// as CLIPROXY_STATE_PASSPHRASE, are ignored.
+func applyEnvOverrides(root *yaml.Node, environ []string) ([][]string, error) {
+	topLevel := configKeys(reflect.TypeOf(Config{}))
+	sort.Strings(environ)
+	var paths [][]string
+	for _, entry := range environ {
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok || !strings.HasPrefix(name, EnvPrefix) || len(name) == len(EnvPrefix) {
+			continue
+		}
+		path := strings.Split(strings.ToLower(strings.TrimPrefix(name, EnvPrefix)), "__")
+		for i := range path {
+			path[i] = strings.ReplaceAll(path[i], "_", "-")
+		}
+		if _, known := topLevel[path[0]]; !known {
+			continue
+		}
+		// Scalars are taken as they are, so a secret is never read as YAML syntax; the
+		// decoder still turns numbers and booleans into the type of the setting.
+		valueNode := &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+		if trimmed := strings.TrimSpace(value); strings.HasPrefix(trimmed, "[") || strings.HasPrefix(trimmed, "{") {
+			var parsed yaml.Node
+			if err := yaml.Unmarshal([]byte(trimmed), &parsed); err != nil {
+				return nil, fmt.Errorf("invalid value of %s: %w", name, err)
+			}
+			valueNode = parsed.Content[0]
+		}
+		if err := setNodePath(root, path, valueNode); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// setNodePath stores value at path below node, creating the mappings and lists on the way.
+// A numeric key indexes a list and may append one item to it.
+func setNodePath(node *yaml.Node, path []string, value *yaml.Node) error {
+	for i, key := range path {
+		index, errIndex := strconv.Atoi(key)
+		numeric := errIndex == nil && index >= 0
+		if numeric && node.Kind != yaml.SequenceNode && (node.Kind != yaml.MappingNode || len(node.Content) == 0) {
+			*node = yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		} else if !numeric && node.Kind != yaml.MappingNode {
+			// An empty or scalar setting becomes a mapping of the overridden keys.
+			*node = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+
+		var child **yaml.Node
+		if node.Kind == yaml.SequenceNode {
+			if !numeric || index > len(node.Content) {
+				return fmt.Errorf("invalid list index %q", key)
+			}
+			if index == len(node.Content) {
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"})
+			}
+			child = &node.Content[index]
+		} else {
+			idx := findMapKeyIndex(node, key)
+			if idx < 0 {
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"})
+				idx = len(node.Content) - 2
+			}
+			child = &node.Content[idx+1]
+		}
+		if i == len(path)-1 {
+			*child = value
+			return nil
+		}
+		node = *child
+	}
+	return nil
+}
+
+// lookupNodePath returns the node at path below node, or nil.
+func lookupNodePath(node *yaml.Node, path []string) *yaml.Node {
+	for _, key := range path {
+		switch node.Kind {
+		case yaml.MappingNode:
+			idx := findMapKeyIndex(node, key)
+			if idx < 0 {
+				return nil
+			}
+			node = node.Content[idx+1]
+		case yaml.SequenceNode:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node.Content) {
+				return nil
+			}
+			node = node.Content[index]
+		default:
+			return nil
+		}
+	}
+	return node
+}
+
+// removeNodePath deletes the mapping key or the list item at path below node.
+func removeNodePath(node *yaml.Node, path []string) {
+	parent := lookupNodePath(node, path[:len(path)-1])
+	if parent == nil {
+		return
+	}
+	key := path[len(path)-1]
+	switch parent.Kind {
+	case yaml.MappingNode:
+		removeMapKey(parent, key)
+	case yaml.SequenceNode:
+		if index, err := strconv.Atoi(key); err == nil && index >= 0 && index < len(parent.Content) {
+			parent.Content = append(parent.Content[:index], parent.Content[index+1:]...)
+		}
+	}
+}
+
+// configKeys returns the YAML keys of the fields of a struct type, including those of
+// inlined structs.
+func configKeys(t reflect.Type) map[string]struct{} {
+	keys := make(map[string]struct{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if strings.Contains(options, "inline") && field.Type.Kind() == reflect.Struct {
+			for key := range configKeys(field.Type) {
+				keys[key] = struct{}{}
+			}
+			continue
+		}
+		if name != "" && name != "-" {
+			keys[name] = struct{}{}
+		}
+	}
+	return keys
+}
+
+// restoreEnvSources puts the settings the environment provided back to what the file
+// holds before a configuration is saved, so that overrides and expanded references are
+// not written into it. generated is the rendered configuration, original the file.
+func restoreEnvSources(generated, original *yaml.Node, overrides [][]string) {
+	restoreInterpolations(generated, original)
+	for _, path := range overrides {
+		// Drop what the file does not have from its first missing key or list item on,
+		// otherwise put the file's value back.
+		restored := false
+		for depth := 1; depth <= len(path) && !restored; depth++ {
+			if lookupNodePath(original, path[:depth]) == nil {
+				removeNodePath(generated, path[:depth])
+				restored = true
+			}
+		}
+		if !restored {
+			_ = setNodePath(generated, path, deepCopyNode(lookupNodePath(original, path)))
+		}
+	}
+}
+
+// restoreInterpolations writes the environment references of original back into the
+// matching scalars of generated that still hold their expansion.
+func restoreInterpolations(generated, original *yaml.Node) {
+	if generated == nil || original == nil || generated.Kind != original.Kind {
+		return
+	}
+	switch original.Kind {
+	case yaml.ScalarNode:
+		if !strings.Contains(original.Value, "${") {
+			return
+		}
+		if expanded, err := expandEnv(original.Value); err == nil && expanded == generated.Value {
+			generated.Value, generated.Tag, generated.Style = original.Value, original.Tag, original.Style
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(original.Content); i += 2 {
+			if idx := findMapKeyIndex(generated, original.Content[i].Value); idx >= 0 {
+				restoreInterpolations(generated.Content[idx+1], original.Content[i+1])
+			}
+		}
+	case yaml.SequenceNode:
+		for i := 0; i < len(original.Content) && i < len(generated.Content); i++ {
+			restoreInterpolations(generated.Content[i], original.Content[i])
+		}
+	}
+}
+
+// hasEnvOverrides reports whether environ sets any CLIPROXY_ setting.
+func hasEnvOverrides(environ []string) bool {
+	topLevel := configKeys(reflect.TypeOf(Config{}))
+	for _, entry := range environ {
+		name, _, _ := strings.Cut(entry, "=")
+		if !strings.HasPrefix(name, EnvPrefix) {
+			continue
+		}
+		key, _, _ := strings.Cut(strings.ToLower(strings.TrimPrefix(name, EnvPrefix)), "__")
+		if _, known := topLevel[strings.ReplaceAll(key, "_", "-")]; known {
+			return true
+		}
+	}
+	return false
+}
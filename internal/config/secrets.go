@@ -0,0 +1,134 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// secretSchemes are the URL schemes of secret references.
+var secretSchemes = []string{"vault://", "aws-sm://", "gcp-sm://", "file://", "env://"}
+
+// SecretResolver returns the value a secret reference points to, using the secrets managers
+// configured in secrets.
+type SecretResolver func(secrets Secrets, ref string) (string, error)
+
+var (
+	secretResolverMu sync.RWMutex
+	secretResolver   SecretResolver
+)
+
+// RegisterSecretResolver installs the resolver used for secret references when a
+// configuration is loaded.
+func RegisterSecretResolver(resolver SecretResolver) {
+	secretResolverMu.Lock()
+	secretResolver = resolver
+	secretResolverMu.Unlock()
+}
+
+// IsSecretReference reports whether value refers to a secret in a secrets manager, a
+// mounted secret file or an environment variable.
+func IsSecretReference(value string) bool {
+	value = strings.TrimSpace(value)
+	for _, scheme := range secretSchemes {
+		if strings.HasPrefix(value, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveSecretReferences replaces every secret reference among the string settings of cfg
+// with its value and remembers the reference for persisting.
+func resolveSecretReferences(cfg *Config) error {
+	secretResolverMu.RLock()
+	resolver := secretResolver
+	secretResolverMu.RUnlock()
+
+	var firstErr error
+	resolve := func(ref string) string {
+		if firstErr != nil {
+			return ref
+		}
+		if resolver == nil {
+			firstErr = fmt.Errorf("secret reference %s cannot be resolved: no secrets provider registered", ref)
+			return ref
+		}
+		value, err := resolver(cfg.Secrets, strings.TrimSpace(ref))
+		if err != nil {
+			firstErr = err
+			return ref
+		}
+		if cfg.secretRefs == nil {
+			cfg.secretRefs = make(map[string]string)
+		}
+		cfg.secretRefs[value] = ref
+		return value
+	}
+
+	root := reflect.ValueOf(cfg).Elem()
+	for i := 0; i < root.NumField(); i++ {
+		field := root.Field(i)
+		// The secrets manager settings themselves are not resolved.
+		if !field.CanSet() || root.Type().Field(i).Name == "Secrets" {
+			continue
+		}
+		walkSecretReferences(field, resolve)
+	}
+	return firstErr
+}
+
+// walkSecretReferences applies resolve to every string in v that is a secret reference.
+func walkSecretReferences(v reflect.Value, resolve func(string) string) {
+	switch v.Kind() {
+	case reflect.String:
+		if IsSecretReference(v.String()) && v.CanSet() {
+			v.SetString(resolve(v.String()))
+		}
+	case reflect.Pointer:
+		if !v.IsNil() {
+			walkSecretReferences(v.Elem(), resolve)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if field := v.Field(i); field.CanSet() {
+				walkSecretReferences(field, resolve)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			walkSecretReferences(v.Index(i), resolve)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			// Map elements are not addressable; resolve a copy and store it back.
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(iter.Value())
+			walkSecretReferences(elem, resolve)
+			v.SetMapIndex(iter.Key(), elem)
+		}
+	}
+}
+
+// restoreSecretReferences replaces the scalars in node that hold a resolved secret with the
+// reference it was resolved from.
+func restoreSecretReferences(node *yaml.Node, refs map[string]string) {
+	if node == nil || len(refs) == 0 {
+		return
+	}
+	if node.Kind == yaml.ScalarNode {
+		if ref, ok := refs[node.Value]; ok && node.Value != "" {
+			node.Value = ref
+			node.Tag = "!!str"
+			node.Style = 0
+		}
+		return
+	}
+	for _, child := range node.Content {
+		restoreSecretReferences(child, refs)
+	}
+}
@@ -19,6 +19,9 @@ const (
 	// OpenAI represents the OpenAI provider identifier.
 	OpenAI = "openai"
 
+	// Bedrock represents the AWS Bedrock Converse API provider identifier.
+	Bedrock = "bedrock"
+
 	// OpenaiResponse represents the OpenAI response format identifier.
 	OpenaiResponse = "openai-response"
 )
@@ -0,0 +1,138 @@
+// Package errorcode classifies the errors of upstream providers and of the proxy itself
+// under stable proxy error codes, independent of the provider that produced them.
+package errorcode
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// Code is a stable proxy error code.
+type Code string
+
+const (
+	InvalidRequest   Code = "invalid_request"
+	ContextLength    Code = "context_length_exceeded"
+	ContentFiltered  Code = "content_filtered"
+	RequestTooLarge  Code = "request_too_large"
+	Authentication   Code = "authentication_failed"
+	PermissionDenied Code = "permission_denied"
+	NotFound         Code = "not_found"
+	RateLimited      Code = "rate_limited"
+	QuotaExceeded    Code = "quota_exceeded"
+	Overloaded       Code = "overloaded"
+	Timeout          Code = "timeout"
+	NoAccount        Code = "no_available_account"
+	Upstream         Code = "upstream_error"
+	Internal         Code = "internal_error"
+)
+
+// keywords maps phrases of upstream error bodies to the code they indicate, checked in
+// order before falling back to the HTTP status.
+var keywords = []struct {
+	code    Code
+	phrases []string
+}{
+	{ContextLength, []string{"context_length_exceeded", "context length", "context window", "prompt is too long", "too many tokens", "exceeds the maximum number of tokens", "input token count"}},
+	{ContentFiltered, []string{"content_filter", "content filter", "content policy", "content_policy", "safety", "prohibited_content", "blocklist", "responsible ai"}},
+	{QuotaExceeded, []string{"insufficient_quota", "quota", "resource_exhausted", "usage limit", "billing", "credit balance"}},
+	{Overloaded, []string{"overloaded", "capacity"}},
+	{Timeout, []string{"deadline exceeded", "deadline_exceeded", "timed out", "timeout"}},
+}
+
+// Classify maps an error and the HTTP status it was reported with to a proxy error code.
+// A status of zero is read from the error when it carries one, and defaults to 500.
+func Classify(status int, err error) Code {
+	if status <= 0 {
+		status = http.StatusInternalServerError
+		if se, ok := err.(interface{ StatusCode() int }); ok && se != nil && se.StatusCode() > 0 {
+			status = se.StatusCode()
+		}
+	}
+
+	var authErr *coreauth.Error
+	var netErr net.Error
+	switch {
+	case err == nil:
+	case errors.Is(err, context.DeadlineExceeded):
+		return Timeout
+	case errors.As(err, &netErr):
+		// The upstream could not be reached or dropped the connection.
+		if netErr.Timeout() {
+			return Timeout
+		}
+		return Upstream
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return Upstream
+	case errors.As(err, &authErr):
+		switch authErr.Code {
+		case "auth_not_found", "auth_unavailable", "account_busy", "circuit_open", coreauth.CapabilityErrorCode:
+			return NoAccount
+		case "provider_not_found", "executor_not_found":
+			return NotFound
+		case "not_supported":
+			return InvalidRequest
+		}
+	}
+
+	if err != nil && status >= 400 {
+		body := strings.ToLower(err.Error())
+		for _, rule := range keywords {
+			for _, phrase := range rule.phrases {
+				if strings.Contains(body, phrase) && keywordApplies(rule.code, status) {
+					return rule.code
+				}
+			}
+		}
+	}
+	return statusCode(status)
+}
+
+// keywordApplies reports whether a keyword match is consistent with the status, so a
+// "quota" mentioned in an authentication failure does not reclassify it.
+func keywordApplies(code Code, status int) bool {
+	switch code {
+	case ContextLength, ContentFiltered:
+		return status < 500 && status != http.StatusUnauthorized && status != http.StatusTooManyRequests
+	case QuotaExceeded:
+		return status == http.StatusTooManyRequests || status == http.StatusForbidden || status == http.StatusPaymentRequired
+	case Overloaded:
+		return status == http.StatusTooManyRequests || status >= 500
+	case Timeout:
+		return status == http.StatusRequestTimeout || status >= 500
+	}
+	return true
+}
+
+func statusCode(status int) Code {
+	switch {
+	case status == http.StatusUnauthorized:
+		return Authentication
+	case status == http.StatusPaymentRequired:
+		return QuotaExceeded
+	case status == http.StatusForbidden:
+		return PermissionDenied
+	case status == http.StatusNotFound:
+		return NotFound
+	case status == http.StatusRequestTimeout, status == http.StatusGatewayTimeout:
+		return Timeout
+	case status == http.StatusRequestEntityTooLarge:
+		return RequestTooLarge
+	case status == http.StatusTooManyRequests:
+		return RateLimited
+	case status == http.StatusServiceUnavailable, status == 529:
+		return Overloaded
+	case status >= 400 && status < 500:
+		return InvalidRequest
+	case status == http.StatusInternalServerError:
+		return Internal
+	default:
+		return Upstream
+	}
+}
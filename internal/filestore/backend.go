@@ -0,0 +1,166 @@
+package filestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// errMissing is returned by backends for objects that do not exist.
+var errMissing = errors.New("object does not exist")
+
+// backend stores opaque objects by key. Keys are slash separated.
+type backend interface {
+	put(ctx context.Context, key string, content io.Reader) error
+	get(ctx context.Context, key string) (io.ReadCloser, error)
+	remove(ctx context.Context, key string) error
+	// list returns the keys below prefix.
+	list(ctx context.Context, prefix string) ([]string, error)
+}
+
+// localBackend keeps objects as files below dir, readable by the owner only.
+type localBackend struct {
+	dir string
+}
+
+func (b *localBackend) path(key string) string {
+	return filepath.Join(b.dir, filepath.FromSlash(key))
+}
+
+func (b *localBackend) put(_ context.Context, key string, content io.Reader) error {
+	target := b.path(key)
+	if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+		return err
+	}
+	tmp := target + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, content)
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, target)
+}
+
+func (b *localBackend) get(_ context.Context, key string) (io.ReadCloser, error) {
+	f, err := os.Open(b.path(key))
+	if os.IsNotExist(err) {
+		return nil, errMissing
+	}
+	return f, err
+}
+
+func (b *localBackend) remove(_ context.Context, key string) error {
+	if err := os.Remove(b.path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (b *localBackend) list(_ context.Context, prefix string) ([]string, error) {
+	entries, err := os.ReadDir(b.path(prefix))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	keys := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && !strings.HasSuffix(entry.Name(), ".tmp") {
+			keys = append(keys, path.Join(prefix, entry.Name()))
+		}
+	}
+	return keys, nil
+}
+
+// s3Backend keeps objects in an S3-compatible bucket.
+type s3Backend struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+func newS3Backend(cfg config.FilesS3) (*s3Backend, error) {
+	options := &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	}
+	if cfg.PathStyle {
+		options.BucketLookup = minio.BucketLookupPath
+	}
+	client, err := minio.New(cfg.Endpoint, options)
+	if err != nil {
+		return nil, fmt.Errorf("files: create s3 client: %w", err)
+	}
+	return &s3Backend{client: client, bucket: cfg.Bucket, prefix: cfg.Prefix}, nil
+}
+
+func (b *s3Backend) key(key string) string {
+	if b.prefix == "" {
+		return key
+	}
+	return b.prefix + "/" + key
+}
+
+func (b *s3Backend) put(ctx context.Context, key string, content io.Reader) error {
+	_, err := b.client.PutObject(ctx, b.bucket, b.key(key), content, -1, minio.PutObjectOptions{ContentType: "application/octet-stream"})
+	return err
+}
+
+func (b *s3Backend) get(ctx context.Context, key string) (io.ReadCloser, error) {
+	object, err := b.client.GetObject(ctx, b.bucket, b.key(key), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// GetObject is lazy; Stat surfaces a missing object before the caller reads.
+	if _, err = object.Stat(); err != nil {
+		_ = object.Close()
+		if s3NotFound(err) {
+			return nil, errMissing
+		}
+		return nil, err
+	}
+	return object, nil
+}
+
+func (b *s3Backend) remove(ctx context.Context, key string) error {
+	err := b.client.RemoveObject(ctx, b.bucket, b.key(key), minio.RemoveObjectOptions{})
+	if err != nil && !s3NotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func (b *s3Backend) list(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	for object := range b.client.ListObjects(ctx, b.bucket, minio.ListObjectsOptions{Prefix: b.key(prefix) + "/", Recursive: true}) {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+		keys = append(keys, strings.TrimPrefix(strings.TrimPrefix(object.Key, b.prefix), "/"))
+	}
+	return keys, nil
+}
+
+func s3NotFound(err error) bool {
+	resp := minio.ToErrorResponse(err)
+	return resp.StatusCode == http.StatusNotFound || resp.Code == "NoSuchKey"
+}
@@ -0,0 +1,431 @@
+// Package filestore implements the storage behind the OpenAI compatible Files API. Files
+// are kept in a local directory or an S3-compatible bucket together with a small metadata
+// object, and are visible to the client key that uploaded them only. Batches read their
+// input from and publish their results to the store, and requests may reference stored
+// files by ID instead of sending their content inline.
+package filestore
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultMaxFileBytes = 100 << 20
+	// janitorInterval is how often expired files are removed.
+	janitorInterval = time.Hour
+	// filesPrefix holds the metadata and content objects of every file.
+	filesPrefix = "files"
+)
+
+// File purposes.
+const (
+	PurposeBatch       = "batch"
+	PurposeBatchOutput = "batch_output"
+	PurposeUserData    = "user_data"
+	PurposeAssistants  = "assistants"
+	PurposeVision      = "vision"
+)
+
+// uploadPurposes lists the purposes clients may upload files for.
+var uploadPurposes = []string{PurposeBatch, PurposeUserData, PurposeAssistants, PurposeVision}
+
+var (
+	// ErrNotFound is returned for files that do not exist or belong to another client.
+	ErrNotFound = errors.New("file not found")
+	// ErrFileTooLarge is returned when an upload exceeds the configured size limit.
+	ErrFileTooLarge = errors.New("file exceeds the maximum size")
+	// ErrFileInUse is returned when deleting a file a running batch still reads.
+	ErrFileInUse = errors.New("file is the input of a running batch")
+	// ErrInvalidPurpose is returned for uploads with a purpose clients may not use.
+	ErrInvalidPurpose = errors.New("purpose must be one of " + strings.Join(uploadPurposes, ", "))
+)
+
+// File is a stored file in the shape of the OpenAI files API.
+type File struct {
+	ID        string `json:"id"`
+	Object    string `json:"object"`
+	Bytes     int64  `json:"bytes"`
+	CreatedAt int64  `json:"created_at"`
+	ExpiresAt int64  `json:"expires_at,omitempty"`
+	Filename  string `json:"filename"`
+	Purpose   string `json:"purpose"`
+}
+
+// record is the metadata object of a file.
+type record struct {
+	File
+	Owner string `json:"owner"`
+}
+
+// Store is the file store. It is safe for concurrent use.
+type Store struct {
+	mu      sync.Mutex
+	cfg     config.FilesConfig
+	backend backend
+	files   map[string]*record
+	// inUse reports whether a file must not be deleted yet.
+	inUse  func(id string) bool
+	cancel context.CancelFunc
+}
+
+// New creates a store for cfg. The backend is fixed for the lifetime of the store;
+// stored files are indexed by Start.
+func New(cfg config.FilesConfig) (*Store, error) {
+	cfg = normalize(cfg)
+	var b backend
+	switch cfg.Storage {
+	case config.FilesStorageS3:
+		s3, err := newS3Backend(cfg.S3)
+		if err != nil {
+			return nil, err
+		}
+		b = s3
+	default:
+		b = &localBackend{dir: cfg.Dir}
+	}
+	return &Store{cfg: cfg, backend: b, files: make(map[string]*record)}, nil
+}
+
+func normalize(cfg config.FilesConfig) config.FilesConfig {
+	if cfg.Storage == "" {
+		cfg.Storage = config.FilesStorageLocal
+	}
+	if cfg.Dir == "" {
+		cfg.Dir = "files"
+		if base := util.WritablePath(); base != "" {
+			cfg.Dir = filepath.Join(base, "files")
+		}
+	}
+	if cfg.MaxFileBytes <= 0 {
+		cfg.MaxFileBytes = defaultMaxFileBytes
+	}
+	return cfg
+}
+
+// Configure applies the limits of cfg; the backend stays as configured at startup.
+func (s *Store) Configure(cfg config.FilesConfig) {
+	cfg = normalize(cfg)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cfg.Storage, cfg.Dir, cfg.S3 = s.cfg.Storage, s.cfg.Dir, s.cfg.S3
+	s.cfg = cfg
+}
+
+// Enabled reports whether the Files API is on.
+func (s *Store) Enabled() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cfg.Enable
+}
+
+// MaxFileBytes returns the size limit of uploads.
+func (s *Store) MaxFileBytes() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cfg.MaxFileBytes
+}
+
+// SetInUse installs the check that keeps files from being deleted while they are needed.
+func (s *Store) SetInUse(inUse func(id string) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inUse = inUse
+}
+
+// Start indexes the stored files and removes expired ones periodically until ctx is done.
+func (s *Store) Start(parent context.Context) {
+	if err := s.load(parent); err != nil {
+		log.Warnf("files: failed to load stored files: %v", err)
+	}
+	ctx, cancel := context.WithCancel(parent)
+	s.mu.Lock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.cancel = cancel
+	s.mu.Unlock()
+	go func() {
+		ticker := time.NewTicker(janitorInterval)
+		defer ticker.Stop()
+		for {
+			s.cleanup(ctx, time.Now())
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop ends the cleanup loop.
+func (s *Store) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+		s.cancel = nil
+	}
+}
+
+func (s *Store) load(ctx context.Context) error {
+	keys, err := s.backend.list(ctx, filesPrefix)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	loaded := make(map[string]*record)
+	for _, key := range keys {
+		if !strings.HasSuffix(key, ".json") {
+			continue
+		}
+		rc, errGet := s.backend.get(ctx, key)
+		if errGet != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, errGet))
+			continue
+		}
+		rec := &record{}
+		errGet = json.NewDecoder(rc).Decode(rec)
+		_ = rc.Close()
+		if errGet != nil || rec.ID == "" {
+			errs = append(errs, fmt.Errorf("%s: invalid metadata", key))
+			continue
+		}
+		loaded[rec.ID] = rec
+	}
+	s.mu.Lock()
+	for id, rec := range loaded {
+		s.files[id] = rec
+	}
+	s.mu.Unlock()
+	return errors.Join(errs...)
+}
+
+func metaKey(id string) string    { return path.Join(filesPrefix, id+".json") }
+func contentKey(id string) string { return path.Join(filesPrefix, id+".bin") }
+
+// Create stores a file uploaded by owner. Only the purposes clients may upload are accepted.
+func (s *Store) Create(ctx context.Context, owner, filename, purpose string, content io.Reader) (File, error) {
+	valid := false
+	for _, p := range uploadPurposes {
+		valid = valid || p == purpose
+	}
+	if !valid {
+		return File{}, ErrInvalidPurpose
+	}
+	var expiresAt int64
+	s.mu.Lock()
+	if s.cfg.Retention > 0 {
+		expiresAt = time.Now().Add(s.cfg.Retention).Unix()
+	}
+	s.mu.Unlock()
+	return s.put(ctx, owner, filename, purpose, content, expiresAt, s.MaxFileBytes())
+}
+
+// Put stores a file of owner with any purpose and no size limit, such as the results of a
+// batch. A zero expiresAt keeps the file until it is deleted.
+func (s *Store) Put(ctx context.Context, owner, filename, purpose string, content io.Reader, expiresAt int64) (File, error) {
+	return s.put(ctx, owner, filename, purpose, content, expiresAt, -1)
+}
+
+// put stores a file; a negative limit disables the size check.
+func (s *Store) put(ctx context.Context, owner, filename, purpose string, content io.Reader, expiresAt, limit int64) (File, error) {
+	rec := &record{
+		File: File{
+			ID:        "file-" + strings.ReplaceAll(uuid.NewString(), "-", ""),
+			Object:    "file",
+			CreatedAt: time.Now().Unix(),
+			ExpiresAt: expiresAt,
+			Filename:  filepath.Base(filename),
+			Purpose:   purpose,
+		},
+		Owner: owner,
+	}
+	counter := &limitedReader{r: content, remaining: limit}
+	err := s.backend.put(ctx, contentKey(rec.ID), counter)
+	if err == nil {
+		rec.Bytes = counter.read
+		var meta []byte
+		if meta, err = json.Marshal(rec); err == nil {
+			err = s.backend.put(ctx, metaKey(rec.ID), bytes.NewReader(meta))
+		}
+	}
+	if err != nil {
+		s.removeObjects(ctx, rec.ID)
+		if counter.exceeded {
+			return File{}, ErrFileTooLarge
+		}
+		return File{}, err
+	}
+	s.mu.Lock()
+	s.files[rec.ID] = rec
+	s.mu.Unlock()
+	return rec.File, nil
+}
+
+// Get returns a file of owner.
+func (s *Store) Get(owner, id string) (File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.files[id]
+	if !ok || rec.Owner != owner {
+		return File{}, ErrNotFound
+	}
+	return rec.File, nil
+}
+
+// List returns the files of owner, newest first, optionally restricted to one purpose.
+func (s *Store) List(owner, purpose string) []File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]File, 0)
+	for _, rec := range s.files {
+		if rec.Owner == owner && (purpose == "" || rec.Purpose == purpose) {
+			out = append(out, rec.File)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].CreatedAt != out[j].CreatedAt {
+			return out[i].CreatedAt > out[j].CreatedAt
+		}
+		return out[i].ID > out[j].ID
+	})
+	return out
+}
+
+// Open returns the content of a file of owner.
+func (s *Store) Open(ctx context.Context, owner, id string) (io.ReadCloser, File, error) {
+	file, err := s.Get(owner, id)
+	if err != nil {
+		return nil, File{}, err
+	}
+	rc, err := s.backend.get(ctx, contentKey(id))
+	if errors.Is(err, errMissing) {
+		return nil, File{}, ErrNotFound
+	}
+	return rc, file, err
+}
+
+// DataURL returns the content of a file of owner as a base64 data URL, with the media
+// type derived from the file name or, failing that, from the content.
+func (s *Store) DataURL(ctx context.Context, owner, id string) (string, File, error) {
+	rc, file, err := s.Open(ctx, owner, id)
+	if err != nil {
+		return "", File{}, err
+	}
+	defer func() { _ = rc.Close() }()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return "", File{}, err
+	}
+	return "data:" + MediaType(file.Filename, data) + ";base64," + base64.StdEncoding.EncodeToString(data), file, nil
+}
+
+// MediaType guesses the media type of a file from its name, then from its content.
+func MediaType(filename string, data []byte) string {
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(filename)), ".")
+	if known, ok := misc.MimeTypes[ext]; ok {
+		return known
+	}
+	if byExt := mime.TypeByExtension("." + ext); ext != "" && byExt != "" {
+		return strings.TrimSpace(strings.SplitN(byExt, ";", 2)[0])
+	}
+	return strings.SplitN(http.DetectContentType(data), ";", 2)[0]
+}
+
+// Delete removes a file of owner.
+func (s *Store) Delete(ctx context.Context, owner, id string) error {
+	s.mu.Lock()
+	rec, ok := s.files[id]
+	if !ok || rec.Owner != owner {
+		s.mu.Unlock()
+		return ErrNotFound
+	}
+	if s.inUse != nil && s.inUse(id) {
+		s.mu.Unlock()
+		return ErrFileInUse
+	}
+	delete(s.files, id)
+	s.mu.Unlock()
+	s.removeObjects(ctx, id)
+	return nil
+}
+
+func (s *Store) removeObjects(ctx context.Context, id string) {
+	for _, key := range []string{metaKey(id), contentKey(id)} {
+		if err := s.backend.remove(ctx, key); err != nil {
+			log.Warnf("files: failed to remove %s: %v", key, err)
+		}
+	}
+}
+
+// cleanup removes files past their expiry that are not in use.
+func (s *Store) cleanup(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	var expired []string
+	for id, rec := range s.files {
+		if rec.ExpiresAt == 0 || rec.ExpiresAt > now.Unix() || (s.inUse != nil && s.inUse(id)) {
+			continue
+		}
+		expired = append(expired, id)
+		delete(s.files, id)
+	}
+	s.mu.Unlock()
+	for _, id := range expired {
+		s.removeObjects(ctx, id)
+	}
+	if len(expired) > 0 {
+		log.Debugf("files: removed %d expired files", len(expired))
+	}
+}
+
+// limitedReader counts the bytes read and fails once more than remaining bytes were read.
+// A negative remaining only counts.
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+	read      int64
+	exceeded  bool
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 && !l.exceeded {
+		n, err := l.r.Read(p)
+		l.read += int64(n)
+		return n, err
+	}
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		l.exceeded = true
+		return n, ErrFileTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,116 @@
+package grpcapi
+
+import (
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers/metrics"
+	pb "github.com/router-for-me/CLIProxyAPI/v6/internal/grpcapi/managementpb"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// toAccount builds the redacted view of auth. Like the HTTP API it never copies the
+// metadata, which carries tokens and cookies, and masks API keys.
+func toAccount(auth *coreauth.Auth, health *coreauth.AuthHealth) *pb.Account {
+	account := &pb.Account{
+		Id:              auth.ID,
+		Provider:        auth.Provider,
+		Label:           auth.Label,
+		Path:            auth.Attributes["path"],
+		Status:          string(auth.Status),
+		StatusMessage:   auth.StatusMessage,
+		Disabled:        auth.Disabled,
+		Unavailable:     auth.Unavailable,
+		CreatedAt:       timestamp(auth.CreatedAt),
+		UpdatedAt:       timestamp(auth.UpdatedAt),
+		LastRefreshedAt: timestamp(auth.LastRefreshedAt),
+		NextRetryAfter:  timestamp(auth.NextRetryAfter),
+	}
+	if email, ok := auth.Metadata["email"].(string); ok {
+		account.Email = email
+	}
+	if len(auth.Attributes) > 0 {
+		account.Attributes = make(map[string]string, len(auth.Attributes))
+		for key, value := range auth.Attributes {
+			if key == "api_key" {
+				value = util.HideAPIKey(value)
+			}
+			account.Attributes[key] = value
+		}
+	}
+	if auth.LastError != nil {
+		account.LastError = auth.LastError.Message
+	}
+	if health != nil {
+		account.Health = &pb.AccountHealth{
+			Healthy:             health.Healthy,
+			Samples:             int32(health.Samples),
+			ErrorRate:           health.ErrorRate,
+			ConsecutiveFailures: int32(health.ConsecutiveFailures),
+			Exclusions:          int32(health.Exclusions),
+			ExcludedUntil:       optionalTimestamp(health.ExcludedUntil),
+			LastSuccessAt:       optionalTimestamp(health.LastSuccessAt),
+			LastFailureAt:       optionalTimestamp(health.LastFailureAt),
+			LastError:           health.LastError,
+		}
+	}
+	return account
+}
+
+func optionalTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamp(*t)
+}
+
+func toMetrics(resp metrics.MetricsResponse) *pb.Metrics {
+	out := &pb.Metrics{
+		Totals: &pb.Totals{
+			Tokens:          resp.Totals.Tokens,
+			EmbeddingTokens: resp.Totals.EmbeddingTokens,
+			Images:          resp.Totals.Images,
+			Requests:        resp.Totals.Requests,
+			Retries:         resp.Totals.Retries,
+			Cost:            resp.Totals.Cost,
+			TtftMs:          toPercentiles(resp.Totals.TTFTMS),
+			TokensPerSecond: toPercentiles(resp.Totals.TokensPerSecond),
+		},
+		ByModel:     make([]*pb.ModelMetrics, 0, len(resp.ByModel)),
+		ByKey:       make([]*pb.KeyMetrics, 0, len(resp.ByKey)),
+		Timeseries:  make([]*pb.TimeseriesBucket, 0, len(resp.Timeseries)),
+		GeneratedAt: timestamppb.Now(),
+	}
+	for _, m := range resp.ByModel {
+		out.ByModel = append(out.ByModel, &pb.ModelMetrics{
+			Model:           m.Model,
+			Tokens:          m.Tokens,
+			EmbeddingTokens: m.EmbeddingTokens,
+			Images:          m.Images,
+			Requests:        m.Requests,
+			Retries:         m.Retries,
+			Cost:            m.Cost,
+			TtftMs:          toPercentiles(m.TTFTMS),
+			TokensPerSecond: toPercentiles(m.TokensPerSecond),
+		})
+	}
+	for _, k := range resp.ByKey {
+		out.ByKey = append(out.ByKey, &pb.KeyMetrics{Key: k.Key, Tokens: k.Tokens, Requests: k.Requests, Cost: k.Cost})
+	}
+	for _, b := range resp.Timeseries {
+		bucket := &pb.TimeseriesBucket{Tokens: b.Tokens, Requests: b.Requests, Cost: b.Cost}
+		if start, err := time.Parse(time.RFC3339, b.BucketStart); err == nil {
+			bucket.Start = timestamppb.New(start)
+		}
+		out.Timeseries = append(out.Timeseries, bucket)
+	}
+	return out
+}
+
+func toPercentiles(p *metrics.Percentiles) *pb.Percentiles {
+	if p == nil {
+		return nil
+	}
+	return &pb.Percentiles{P50: p.P50, P95: p.P95, P99: p.P99}
+}
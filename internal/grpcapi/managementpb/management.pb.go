@@ -0,0 +1,1729 @@
+// Management plane of CLIProxyAPI over gRPC.
+//
+// The service mirrors the account, configuration and metrics endpoints of the
+// HTTP management API and adds streaming watch calls, so that fleet automation
+// can follow state changes instead of polling. Every call must carry the
+// management key in the "authorization" metadata ("Bearer <key>") or in
+// "x-management-key".
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        (unknown)
+// source: cliproxy/management/v1/management.proto
+
+package managementpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type AccountEvent_Type int32
+
+const (
+	AccountEvent_TYPE_UNSPECIFIED AccountEvent_Type = 0
+	AccountEvent_ADDED            AccountEvent_Type = 1
+	AccountEvent_UPDATED          AccountEvent_Type = 2
+	AccountEvent_REMOVED          AccountEvent_Type = 3
+	// SYNCED follows the ADDED events for the accounts that existed when the
+	// watch started.
+	AccountEvent_SYNCED AccountEvent_Type = 4
+)
+
+// Enum value maps for AccountEvent_Type.
+var (
+	AccountEvent_Type_name = map[int32]string{
+		0: "TYPE_UNSPECIFIED",
+		1: "ADDED",
+		2: "UPDATED",
+		3: "REMOVED",
+		4: "SYNCED",
+	}
+	AccountEvent_Type_value = map[string]int32{
+		"TYPE_UNSPECIFIED": 0,
+		"ADDED":            1,
+		"UPDATED":          2,
+		"REMOVED":          3,
+		"SYNCED":           4,
+	}
+)
+
+func (x AccountEvent_Type) Enum() *AccountEvent_Type {
+	p := new(AccountEvent_Type)
+	*p = x
+	return p
+}
+
+func (x AccountEvent_Type) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (AccountEvent_Type) Descriptor() protoreflect.EnumDescriptor {
+	return file_cliproxy_management_v1_management_proto_enumTypes[0].Descriptor()
+}
+
+func (AccountEvent_Type) Type() protoreflect.EnumType {
+	return &file_cliproxy_management_v1_management_proto_enumTypes[0]
+}
+
+func (x AccountEvent_Type) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use AccountEvent_Type.Descriptor instead.
+func (AccountEvent_Type) EnumDescriptor() ([]byte, []int) {
+	return file_cliproxy_management_v1_management_proto_rawDescGZIP(), []int{7, 0}
+}
+
+// Account is the redacted runtime view of an upstream account. Tokens and
+// cookies are never exposed; API keys in attributes are masked.
+type Account struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Id       string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Provider string                 `protobuf:"bytes,2,opt,name=provider,proto3" json:"provider,omitempty"`
+	Label    string                 `protobuf:"bytes,3,opt,name=label,proto3" json:"label,omitempty"`
+	Email    string                 `protobuf:"bytes,4,opt,name=email,proto3" json:"email,omitempty"`
+	// Path of the backing auth file, empty for accounts from the config.
+	Path            string                 `protobuf:"bytes,5,opt,name=path,proto3" json:"path,omitempty"`
+	Status          string                 `protobuf:"bytes,6,opt,name=status,proto3" json:"status,omitempty"`
+	StatusMessage   string                 `protobuf:"bytes,7,opt,name=status_message,json=statusMessage,proto3" json:"status_message,omitempty"`
+	Disabled        bool                   `protobuf:"varint,8,opt,name=disabled,proto3" json:"disabled,omitempty"`
+	Unavailable     bool                   `protobuf:"varint,9,opt,name=unavailable,proto3" json:"unavailable,omitempty"`
+	Attributes      map[string]string      `protobuf:"bytes,10,rep,name=attributes,proto3" json:"attributes,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	LastError       string                 `protobuf:"bytes,11,opt,name=last_error,json=lastError,proto3" json:"last_error,omitempty"`
+	CreatedAt       *timestamppb.Timestamp `protobuf:"bytes,12,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt       *timestamppb.Timestamp `protobuf:"bytes,13,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	LastRefreshedAt *timestamppb.Timestamp `protobuf:"bytes,14,opt,name=last_refreshed_at,json=lastRefreshedAt,proto3" json:"last_refreshed_at,omitempty"`
+	NextRetryAfter  *timestamppb.Timestamp `protobuf:"bytes,15,opt,name=next_retry_after,json=nextRetryAfter,proto3" json:"next_retry_after,omitempty"`
+	Health          *AccountHealth         `protobuf:"bytes,16,opt,name=health,proto3" json:"health,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Account) Reset() {
+	*x = Account{}
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Account) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Account) ProtoMessage() {}
+
+func (x *Account) ProtoReflect() protoreflect.Message {
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Account.ProtoReflect.Descriptor instead.
+func (*Account) Descriptor() ([]byte, []int) {
+	return file_cliproxy_management_v1_management_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Account) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Account) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+func (x *Account) GetLabel() string {
+	if x != nil {
+		return x.Label
+	}
+	return ""
+}
+
+func (x *Account) GetEmail() string {
+	if x != nil {
+		return x.Email
+	}
+	return ""
+}
+
+func (x *Account) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *Account) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Account) GetStatusMessage() string {
+	if x != nil {
+		return x.StatusMessage
+	}
+	return ""
+}
+
+func (x *Account) GetDisabled() bool {
+	if x != nil {
+		return x.Disabled
+	}
+	return false
+}
+
+func (x *Account) GetUnavailable() bool {
+	if x != nil {
+		return x.Unavailable
+	}
+	return false
+}
+
+func (x *Account) GetAttributes() map[string]string {
+	if x != nil {
+		return x.Attributes
+	}
+	return nil
+}
+
+func (x *Account) GetLastError() string {
+	if x != nil {
+		return x.LastError
+	}
+	return ""
+}
+
+func (x *Account) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Account) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *Account) GetLastRefreshedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastRefreshedAt
+	}
+	return nil
+}
+
+func (x *Account) GetNextRetryAfter() *timestamppb.Timestamp {
+	if x != nil {
+		return x.NextRetryAfter
+	}
+	return nil
+}
+
+func (x *Account) GetHealth() *AccountHealth {
+	if x != nil {
+		return x.Health
+	}
+	return nil
+}
+
+// AccountHealth is the passive health state of an account.
+type AccountHealth struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	Healthy             bool                   `protobuf:"varint,1,opt,name=healthy,proto3" json:"healthy,omitempty"`
+	Samples             int32                  `protobuf:"varint,2,opt,name=samples,proto3" json:"samples,omitempty"`
+	ErrorRate           float64                `protobuf:"fixed64,3,opt,name=error_rate,json=errorRate,proto3" json:"error_rate,omitempty"`
+	ConsecutiveFailures int32                  `protobuf:"varint,4,opt,name=consecutive_failures,json=consecutiveFailures,proto3" json:"consecutive_failures,omitempty"`
+	Exclusions          int32                  `protobuf:"varint,5,opt,name=exclusions,proto3" json:"exclusions,omitempty"`
+	ExcludedUntil       *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=excluded_until,json=excludedUntil,proto3" json:"excluded_until,omitempty"`
+	LastSuccessAt       *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=last_success_at,json=lastSuccessAt,proto3" json:"last_success_at,omitempty"`
+	LastFailureAt       *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=last_failure_at,json=lastFailureAt,proto3" json:"last_failure_at,omitempty"`
+	LastError           string                 `protobuf:"bytes,9,opt,name=last_error,json=lastError,proto3" json:"last_error,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *AccountHealth) Reset() {
+	*x = AccountHealth{}
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AccountHealth) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AccountHealth) ProtoMessage() {}
+
+func (x *AccountHealth) ProtoReflect() protoreflect.Message {
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AccountHealth.ProtoReflect.Descriptor instead.
+func (*AccountHealth) Descriptor() ([]byte, []int) {
+	return file_cliproxy_management_v1_management_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *AccountHealth) GetHealthy() bool {
+	if x != nil {
+		return x.Healthy
+	}
+	return false
+}
+
+func (x *AccountHealth) GetSamples() int32 {
+	if x != nil {
+		return x.Samples
+	}
+	return 0
+}
+
+func (x *AccountHealth) GetErrorRate() float64 {
+	if x != nil {
+		return x.ErrorRate
+	}
+	return 0
+}
+
+func (x *AccountHealth) GetConsecutiveFailures() int32 {
+	if x != nil {
+		return x.ConsecutiveFailures
+	}
+	return 0
+}
+
+func (x *AccountHealth) GetExclusions() int32 {
+	if x != nil {
+		return x.Exclusions
+	}
+	return 0
+}
+
+func (x *AccountHealth) GetExcludedUntil() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExcludedUntil
+	}
+	return nil
+}
+
+func (x *AccountHealth) GetLastSuccessAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastSuccessAt
+	}
+	return nil
+}
+
+func (x *AccountHealth) GetLastFailureAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastFailureAt
+	}
+	return nil
+}
+
+func (x *AccountHealth) GetLastError() string {
+	if x != nil {
+		return x.LastError
+	}
+	return ""
+}
+
+type ListAccountsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only return accounts of this provider.
+	Provider string `protobuf:"bytes,1,opt,name=provider,proto3" json:"provider,omitempty"`
+	// Only return the account with this ID.
+	Id            string `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListAccountsRequest) Reset() {
+	*x = ListAccountsRequest{}
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListAccountsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListAccountsRequest) ProtoMessage() {}
+
+func (x *ListAccountsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListAccountsRequest.ProtoReflect.Descriptor instead.
+func (*ListAccountsRequest) Descriptor() ([]byte, []int) {
+	return file_cliproxy_management_v1_management_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ListAccountsRequest) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+func (x *ListAccountsRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type ListAccountsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Accounts      []*Account             `protobuf:"bytes,1,rep,name=accounts,proto3" json:"accounts,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListAccountsResponse) Reset() {
+	*x = ListAccountsResponse{}
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListAccountsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListAccountsResponse) ProtoMessage() {}
+
+func (x *ListAccountsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListAccountsResponse.ProtoReflect.Descriptor instead.
+func (*ListAccountsResponse) Descriptor() ([]byte, []int) {
+	return file_cliproxy_management_v1_management_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ListAccountsResponse) GetAccounts() []*Account {
+	if x != nil {
+		return x.Accounts
+	}
+	return nil
+}
+
+type SetAccountDisabledRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Disabled      bool                   `protobuf:"varint,2,opt,name=disabled,proto3" json:"disabled,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetAccountDisabledRequest) Reset() {
+	*x = SetAccountDisabledRequest{}
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetAccountDisabledRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetAccountDisabledRequest) ProtoMessage() {}
+
+func (x *SetAccountDisabledRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetAccountDisabledRequest.ProtoReflect.Descriptor instead.
+func (*SetAccountDisabledRequest) Descriptor() ([]byte, []int) {
+	return file_cliproxy_management_v1_management_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *SetAccountDisabledRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *SetAccountDisabledRequest) GetDisabled() bool {
+	if x != nil {
+		return x.Disabled
+	}
+	return false
+}
+
+type SetAccountDisabledResponse struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Account *Account               `protobuf:"bytes,1,opt,name=account,proto3" json:"account,omitempty"`
+	// Whether the flag was written to the auth file.
+	Persisted     bool `protobuf:"varint,2,opt,name=persisted,proto3" json:"persisted,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetAccountDisabledResponse) Reset() {
+	*x = SetAccountDisabledResponse{}
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetAccountDisabledResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetAccountDisabledResponse) ProtoMessage() {}
+
+func (x *SetAccountDisabledResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetAccountDisabledResponse.ProtoReflect.Descriptor instead.
+func (*SetAccountDisabledResponse) Descriptor() ([]byte, []int) {
+	return file_cliproxy_management_v1_management_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *SetAccountDisabledResponse) GetAccount() *Account {
+	if x != nil {
+		return x.Account
+	}
+	return nil
+}
+
+func (x *SetAccountDisabledResponse) GetPersisted() bool {
+	if x != nil {
+		return x.Persisted
+	}
+	return false
+}
+
+type WatchAccountsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only watch accounts of this provider.
+	Provider      string `protobuf:"bytes,1,opt,name=provider,proto3" json:"provider,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchAccountsRequest) Reset() {
+	*x = WatchAccountsRequest{}
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchAccountsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchAccountsRequest) ProtoMessage() {}
+
+func (x *WatchAccountsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchAccountsRequest.ProtoReflect.Descriptor instead.
+func (*WatchAccountsRequest) Descriptor() ([]byte, []int) {
+	return file_cliproxy_management_v1_management_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *WatchAccountsRequest) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+type AccountEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Type  AccountEvent_Type      `protobuf:"varint,1,opt,name=type,proto3,enum=cliproxy.management.v1.AccountEvent_Type" json:"type,omitempty"`
+	// The account after the change; only the ID is set for REMOVED.
+	Account       *Account `protobuf:"bytes,2,opt,name=account,proto3" json:"account,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AccountEvent) Reset() {
+	*x = AccountEvent{}
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AccountEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AccountEvent) ProtoMessage() {}
+
+func (x *AccountEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AccountEvent.ProtoReflect.Descriptor instead.
+func (*AccountEvent) Descriptor() ([]byte, []int) {
+	return file_cliproxy_management_v1_management_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *AccountEvent) GetType() AccountEvent_Type {
+	if x != nil {
+		return x.Type
+	}
+	return AccountEvent_TYPE_UNSPECIFIED
+}
+
+func (x *AccountEvent) GetAccount() *Account {
+	if x != nil {
+		return x.Account
+	}
+	return nil
+}
+
+type GetConfigRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetConfigRequest) Reset() {
+	*x = GetConfigRequest{}
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetConfigRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetConfigRequest) ProtoMessage() {}
+
+func (x *GetConfigRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetConfigRequest.ProtoReflect.Descriptor instead.
+func (*GetConfigRequest) Descriptor() ([]byte, []int) {
+	return file_cliproxy_management_v1_management_proto_rawDescGZIP(), []int{8}
+}
+
+type Config struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Raw contents of the configuration file.
+	Yaml string `protobuf:"bytes,1,opt,name=yaml,proto3" json:"yaml,omitempty"`
+	// Effective in-memory configuration as JSON, as returned by GET /config.
+	Json          string `protobuf:"bytes,2,opt,name=json,proto3" json:"json,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Config) Reset() {
+	*x = Config{}
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Config) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Config) ProtoMessage() {}
+
+func (x *Config) ProtoReflect() protoreflect.Message {
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Config.ProtoReflect.Descriptor instead.
+func (*Config) Descriptor() ([]byte, []int) {
+	return file_cliproxy_management_v1_management_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Config) GetYaml() string {
+	if x != nil {
+		return x.Yaml
+	}
+	return ""
+}
+
+func (x *Config) GetJson() string {
+	if x != nil {
+		return x.Json
+	}
+	return ""
+}
+
+type PutConfigRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Yaml          string                 `protobuf:"bytes,1,opt,name=yaml,proto3" json:"yaml,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PutConfigRequest) Reset() {
+	*x = PutConfigRequest{}
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PutConfigRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PutConfigRequest) ProtoMessage() {}
+
+func (x *PutConfigRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PutConfigRequest.ProtoReflect.Descriptor instead.
+func (*PutConfigRequest) Descriptor() ([]byte, []int) {
+	return file_cliproxy_management_v1_management_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *PutConfigRequest) GetYaml() string {
+	if x != nil {
+		return x.Yaml
+	}
+	return ""
+}
+
+type WatchConfigRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchConfigRequest) Reset() {
+	*x = WatchConfigRequest{}
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchConfigRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchConfigRequest) ProtoMessage() {}
+
+func (x *WatchConfigRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchConfigRequest.ProtoReflect.Descriptor instead.
+func (*WatchConfigRequest) Descriptor() ([]byte, []int) {
+	return file_cliproxy_management_v1_management_proto_rawDescGZIP(), []int{11}
+}
+
+type GetMetricsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Start of the period; defaults to 24 hours before now when neither bound is set.
+	From *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=from,proto3" json:"from,omitempty"`
+	// End of the period.
+	To *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=to,proto3" json:"to,omitempty"`
+	// Only aggregate requests for this model.
+	Model string `protobuf:"bytes,3,opt,name=model,proto3" json:"model,omitempty"`
+	// Timeseries bucket size: 1m, 5m, 1h (default) or 1d.
+	Bucket string `protobuf:"bytes,4,opt,name=bucket,proto3" json:"bucket,omitempty"`
+	// Only aggregate requests of the client keys belonging to this project.
+	Project       string `protobuf:"bytes,5,opt,name=project,proto3" json:"project,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetMetricsRequest) Reset() {
+	*x = GetMetricsRequest{}
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetMetricsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetMetricsRequest) ProtoMessage() {}
+
+func (x *GetMetricsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetMetricsRequest.ProtoReflect.Descriptor instead.
+func (*GetMetricsRequest) Descriptor() ([]byte, []int) {
+	return file_cliproxy_management_v1_management_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *GetMetricsRequest) GetFrom() *timestamppb.Timestamp {
+	if x != nil {
+		return x.From
+	}
+	return nil
+}
+
+func (x *GetMetricsRequest) GetTo() *timestamppb.Timestamp {
+	if x != nil {
+		return x.To
+	}
+	return nil
+}
+
+func (x *GetMetricsRequest) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *GetMetricsRequest) GetBucket() string {
+	if x != nil {
+		return x.Bucket
+	}
+	return ""
+}
+
+func (x *GetMetricsRequest) GetProject() string {
+	if x != nil {
+		return x.Project
+	}
+	return ""
+}
+
+type WatchMetricsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Seconds between snapshots; defaults to 10.
+	IntervalSeconds int32 `protobuf:"varint,1,opt,name=interval_seconds,json=intervalSeconds,proto3" json:"interval_seconds,omitempty"`
+	// Length of the trailing period each snapshot covers, in seconds; defaults to 24 hours.
+	WindowSeconds int32  `protobuf:"varint,2,opt,name=window_seconds,json=windowSeconds,proto3" json:"window_seconds,omitempty"`
+	Model         string `protobuf:"bytes,3,opt,name=model,proto3" json:"model,omitempty"`
+	Bucket        string `protobuf:"bytes,4,opt,name=bucket,proto3" json:"bucket,omitempty"`
+	Project       string `protobuf:"bytes,5,opt,name=project,proto3" json:"project,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchMetricsRequest) Reset() {
+	*x = WatchMetricsRequest{}
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchMetricsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchMetricsRequest) ProtoMessage() {}
+
+func (x *WatchMetricsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchMetricsRequest.ProtoReflect.Descriptor instead.
+func (*WatchMetricsRequest) Descriptor() ([]byte, []int) {
+	return file_cliproxy_management_v1_management_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *WatchMetricsRequest) GetIntervalSeconds() int32 {
+	if x != nil {
+		return x.IntervalSeconds
+	}
+	return 0
+}
+
+func (x *WatchMetricsRequest) GetWindowSeconds() int32 {
+	if x != nil {
+		return x.WindowSeconds
+	}
+	return 0
+}
+
+func (x *WatchMetricsRequest) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *WatchMetricsRequest) GetBucket() string {
+	if x != nil {
+		return x.Bucket
+	}
+	return ""
+}
+
+func (x *WatchMetricsRequest) GetProject() string {
+	if x != nil {
+		return x.Project
+	}
+	return ""
+}
+
+type Metrics struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Totals        *Totals                `protobuf:"bytes,1,opt,name=totals,proto3" json:"totals,omitempty"`
+	ByModel       []*ModelMetrics        `protobuf:"bytes,2,rep,name=by_model,json=byModel,proto3" json:"by_model,omitempty"`
+	ByKey         []*KeyMetrics          `protobuf:"bytes,3,rep,name=by_key,json=byKey,proto3" json:"by_key,omitempty"`
+	Timeseries    []*TimeseriesBucket    `protobuf:"bytes,4,rep,name=timeseries,proto3" json:"timeseries,omitempty"`
+	GeneratedAt   *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=generated_at,json=generatedAt,proto3" json:"generated_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Metrics) Reset() {
+	*x = Metrics{}
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Metrics) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Metrics) ProtoMessage() {}
+
+func (x *Metrics) ProtoReflect() protoreflect.Message {
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Metrics.ProtoReflect.Descriptor instead.
+func (*Metrics) Descriptor() ([]byte, []int) {
+	return file_cliproxy_management_v1_management_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *Metrics) GetTotals() *Totals {
+	if x != nil {
+		return x.Totals
+	}
+	return nil
+}
+
+func (x *Metrics) GetByModel() []*ModelMetrics {
+	if x != nil {
+		return x.ByModel
+	}
+	return nil
+}
+
+func (x *Metrics) GetByKey() []*KeyMetrics {
+	if x != nil {
+		return x.ByKey
+	}
+	return nil
+}
+
+func (x *Metrics) GetTimeseries() []*TimeseriesBucket {
+	if x != nil {
+		return x.Timeseries
+	}
+	return nil
+}
+
+func (x *Metrics) GetGeneratedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.GeneratedAt
+	}
+	return nil
+}
+
+type Totals struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Tokens          int64                  `protobuf:"varint,1,opt,name=tokens,proto3" json:"tokens,omitempty"`
+	EmbeddingTokens int64                  `protobuf:"varint,2,opt,name=embedding_tokens,json=embeddingTokens,proto3" json:"embedding_tokens,omitempty"`
+	Images          int64                  `protobuf:"varint,3,opt,name=images,proto3" json:"images,omitempty"`
+	Requests        int64                  `protobuf:"varint,4,opt,name=requests,proto3" json:"requests,omitempty"`
+	Retries         int64                  `protobuf:"varint,5,opt,name=retries,proto3" json:"retries,omitempty"`
+	Cost            float64                `protobuf:"fixed64,6,opt,name=cost,proto3" json:"cost,omitempty"`
+	TtftMs          *Percentiles           `protobuf:"bytes,7,opt,name=ttft_ms,json=ttftMs,proto3" json:"ttft_ms,omitempty"`
+	TokensPerSecond *Percentiles           `protobuf:"bytes,8,opt,name=tokens_per_second,json=tokensPerSecond,proto3" json:"tokens_per_second,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Totals) Reset() {
+	*x = Totals{}
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Totals) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Totals) ProtoMessage() {}
+
+func (x *Totals) ProtoReflect() protoreflect.Message {
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Totals.ProtoReflect.Descriptor instead.
+func (*Totals) Descriptor() ([]byte, []int) {
+	return file_cliproxy_management_v1_management_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *Totals) GetTokens() int64 {
+	if x != nil {
+		return x.Tokens
+	}
+	return 0
+}
+
+func (x *Totals) GetEmbeddingTokens() int64 {
+	if x != nil {
+		return x.EmbeddingTokens
+	}
+	return 0
+}
+
+func (x *Totals) GetImages() int64 {
+	if x != nil {
+		return x.Images
+	}
+	return 0
+}
+
+func (x *Totals) GetRequests() int64 {
+	if x != nil {
+		return x.Requests
+	}
+	return 0
+}
+
+func (x *Totals) GetRetries() int64 {
+	if x != nil {
+		return x.Retries
+	}
+	return 0
+}
+
+func (x *Totals) GetCost() float64 {
+	if x != nil {
+		return x.Cost
+	}
+	return 0
+}
+
+func (x *Totals) GetTtftMs() *Percentiles {
+	if x != nil {
+		return x.TtftMs
+	}
+	return nil
+}
+
+func (x *Totals) GetTokensPerSecond() *Percentiles {
+	if x != nil {
+		return x.TokensPerSecond
+	}
+	return nil
+}
+
+type ModelMetrics struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Model           string                 `protobuf:"bytes,1,opt,name=model,proto3" json:"model,omitempty"`
+	Tokens          int64                  `protobuf:"varint,2,opt,name=tokens,proto3" json:"tokens,omitempty"`
+	EmbeddingTokens int64                  `protobuf:"varint,3,opt,name=embedding_tokens,json=embeddingTokens,proto3" json:"embedding_tokens,omitempty"`
+	Images          int64                  `protobuf:"varint,4,opt,name=images,proto3" json:"images,omitempty"`
+	Requests        int64                  `protobuf:"varint,5,opt,name=requests,proto3" json:"requests,omitempty"`
+	Retries         int64                  `protobuf:"varint,6,opt,name=retries,proto3" json:"retries,omitempty"`
+	Cost            float64                `protobuf:"fixed64,7,opt,name=cost,proto3" json:"cost,omitempty"`
+	TtftMs          *Percentiles           `protobuf:"bytes,8,opt,name=ttft_ms,json=ttftMs,proto3" json:"ttft_ms,omitempty"`
+	TokensPerSecond *Percentiles           `protobuf:"bytes,9,opt,name=tokens_per_second,json=tokensPerSecond,proto3" json:"tokens_per_second,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *ModelMetrics) Reset() {
+	*x = ModelMetrics{}
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ModelMetrics) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ModelMetrics) ProtoMessage() {}
+
+func (x *ModelMetrics) ProtoReflect() protoreflect.Message {
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ModelMetrics.ProtoReflect.Descriptor instead.
+func (*ModelMetrics) Descriptor() ([]byte, []int) {
+	return file_cliproxy_management_v1_management_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ModelMetrics) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *ModelMetrics) GetTokens() int64 {
+	if x != nil {
+		return x.Tokens
+	}
+	return 0
+}
+
+func (x *ModelMetrics) GetEmbeddingTokens() int64 {
+	if x != nil {
+		return x.EmbeddingTokens
+	}
+	return 0
+}
+
+func (x *ModelMetrics) GetImages() int64 {
+	if x != nil {
+		return x.Images
+	}
+	return 0
+}
+
+func (x *ModelMetrics) GetRequests() int64 {
+	if x != nil {
+		return x.Requests
+	}
+	return 0
+}
+
+func (x *ModelMetrics) GetRetries() int64 {
+	if x != nil {
+		return x.Retries
+	}
+	return 0
+}
+
+func (x *ModelMetrics) GetCost() float64 {
+	if x != nil {
+		return x.Cost
+	}
+	return 0
+}
+
+func (x *ModelMetrics) GetTtftMs() *Percentiles {
+	if x != nil {
+		return x.TtftMs
+	}
+	return nil
+}
+
+func (x *ModelMetrics) GetTokensPerSecond() *Percentiles {
+	if x != nil {
+		return x.TokensPerSecond
+	}
+	return nil
+}
+
+type KeyMetrics struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Masked client API key.
+	Key           string  `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Tokens        int64   `protobuf:"varint,2,opt,name=tokens,proto3" json:"tokens,omitempty"`
+	Requests      int64   `protobuf:"varint,3,opt,name=requests,proto3" json:"requests,omitempty"`
+	Cost          float64 `protobuf:"fixed64,4,opt,name=cost,proto3" json:"cost,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *KeyMetrics) Reset() {
+	*x = KeyMetrics{}
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *KeyMetrics) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KeyMetrics) ProtoMessage() {}
+
+func (x *KeyMetrics) ProtoReflect() protoreflect.Message {
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KeyMetrics.ProtoReflect.Descriptor instead.
+func (*KeyMetrics) Descriptor() ([]byte, []int) {
+	return file_cliproxy_management_v1_management_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *KeyMetrics) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *KeyMetrics) GetTokens() int64 {
+	if x != nil {
+		return x.Tokens
+	}
+	return 0
+}
+
+func (x *KeyMetrics) GetRequests() int64 {
+	if x != nil {
+		return x.Requests
+	}
+	return 0
+}
+
+func (x *KeyMetrics) GetCost() float64 {
+	if x != nil {
+		return x.Cost
+	}
+	return 0
+}
+
+type TimeseriesBucket struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Start         *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=start,proto3" json:"start,omitempty"`
+	Tokens        int64                  `protobuf:"varint,2,opt,name=tokens,proto3" json:"tokens,omitempty"`
+	Requests      int64                  `protobuf:"varint,3,opt,name=requests,proto3" json:"requests,omitempty"`
+	Cost          float64                `protobuf:"fixed64,4,opt,name=cost,proto3" json:"cost,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TimeseriesBucket) Reset() {
+	*x = TimeseriesBucket{}
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TimeseriesBucket) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TimeseriesBucket) ProtoMessage() {}
+
+func (x *TimeseriesBucket) ProtoReflect() protoreflect.Message {
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TimeseriesBucket.ProtoReflect.Descriptor instead.
+func (*TimeseriesBucket) Descriptor() ([]byte, []int) {
+	return file_cliproxy_management_v1_management_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *TimeseriesBucket) GetStart() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Start
+	}
+	return nil
+}
+
+func (x *TimeseriesBucket) GetTokens() int64 {
+	if x != nil {
+		return x.Tokens
+	}
+	return 0
+}
+
+func (x *TimeseriesBucket) GetRequests() int64 {
+	if x != nil {
+		return x.Requests
+	}
+	return 0
+}
+
+func (x *TimeseriesBucket) GetCost() float64 {
+	if x != nil {
+		return x.Cost
+	}
+	return 0
+}
+
+type Percentiles struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	P50           float64                `protobuf:"fixed64,1,opt,name=p50,proto3" json:"p50,omitempty"`
+	P95           float64                `protobuf:"fixed64,2,opt,name=p95,proto3" json:"p95,omitempty"`
+	P99           float64                `protobuf:"fixed64,3,opt,name=p99,proto3" json:"p99,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Percentiles) Reset() {
+	*x = Percentiles{}
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Percentiles) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Percentiles) ProtoMessage() {}
+
+func (x *Percentiles) ProtoReflect() protoreflect.Message {
+	mi := &file_cliproxy_management_v1_management_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Percentiles.ProtoReflect.Descriptor instead.
+func (*Percentiles) Descriptor() ([]byte, []int) {
+	return file_cliproxy_management_v1_management_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *Percentiles) GetP50() float64 {
+	if x != nil {
+		return x.P50
+	}
+	return 0
+}
+
+func (x *Percentiles) GetP95() float64 {
+	if x != nil {
+		return x.P95
+	}
+	return 0
+}
+
+func (x *Percentiles) GetP99() float64 {
+	if x != nil {
+		return x.P99
+	}
+	return 0
+}
+
+var File_cliproxy_management_v1_management_proto protoreflect.FileDescriptor
+
+const file_cliproxy_management_v1_management_proto_rawDesc = "" +
+	"\n" +
+	"'cliproxy/management/v1/management.proto\x12\x16cliproxy.management.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xe4\x05\n" +
+	"\aAccount\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1a\n" +
+	"\bprovider\x18\x02 \x01(\tR\bprovider\x12\x14\n" +
+	"\x05label\x18\x03 \x01(\tR\x05label\x12\x14\n" +
+	"\x05email\x18\x04 \x01(\tR\x05email\x12\x12\n" +
+	"\x04path\x18\x05 \x01(\tR\x04path\x12\x16\n" +
+	"\x06status\x18\x06 \x01(\tR\x06status\x12%\n" +
+	"\x0estatus_message\x18\a \x01(\tR\rstatusMessage\x12\x1a\n" +
+	"\bdisabled\x18\b \x01(\bR\bdisabled\x12 \n" +
+	"\vunavailable\x18\t \x01(\bR\vunavailable\x12O\n" +
+	"\n" +
+	"attributes\x18\n" +
+	" \x03(\v2/.cliproxy.management.v1.Account.AttributesEntryR\n" +
+	"attributes\x12\x1d\n" +
+	"\n" +
+	"last_error\x18\v \x01(\tR\tlastError\x129\n" +
+	"\n" +
+	"created_at\x18\f \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\r \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12F\n" +
+	"\x11last_refreshed_at\x18\x0e \x01(\v2\x1a.google.protobuf.TimestampR\x0flastRefreshedAt\x12D\n" +
+	"\x10next_retry_after\x18\x0f \x01(\v2\x1a.google.protobuf.TimestampR\x0enextRetryAfter\x12=\n" +
+	"\x06health\x18\x10 \x01(\v2%.cliproxy.management.v1.AccountHealthR\x06health\x1a=\n" +
+	"\x0fAttributesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x9f\x03\n" +
+	"\rAccountHealth\x12\x18\n" +
+	"\ahealthy\x18\x01 \x01(\bR\ahealthy\x12\x18\n" +
+	"\asamples\x18\x02 \x01(\x05R\asamples\x12\x1d\n" +
+	"\n" +
+	"error_rate\x18\x03 \x01(\x01R\terrorRate\x121\n" +
+	"\x14consecutive_failures\x18\x04 \x01(\x05R\x13consecutiveFailures\x12\x1e\n" +
+	"\n" +
+	"exclusions\x18\x05 \x01(\x05R\n" +
+	"exclusions\x12A\n" +
+	"\x0eexcluded_until\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\rexcludedUntil\x12B\n" +
+	"\x0flast_success_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\rlastSuccessAt\x12B\n" +
+	"\x0flast_failure_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\rlastFailureAt\x12\x1d\n" +
+	"\n" +
+	"last_error\x18\t \x01(\tR\tlastError\"A\n" +
+	"\x13ListAccountsRequest\x12\x1a\n" +
+	"\bprovider\x18\x01 \x01(\tR\bprovider\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02id\"S\n" +
+	"\x14ListAccountsResponse\x12;\n" +
+	"\baccounts\x18\x01 \x03(\v2\x1f.cliproxy.management.v1.AccountR\baccounts\"G\n" +
+	"\x19SetAccountDisabledRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1a\n" +
+	"\bdisabled\x18\x02 \x01(\bR\bdisabled\"u\n" +
+	"\x1aSetAccountDisabledResponse\x129\n" +
+	"\aaccount\x18\x01 \x01(\v2\x1f.cliproxy.management.v1.AccountR\aaccount\x12\x1c\n" +
+	"\tpersisted\x18\x02 \x01(\bR\tpersisted\"2\n" +
+	"\x14WatchAccountsRequest\x12\x1a\n" +
+	"\bprovider\x18\x01 \x01(\tR\bprovider\"\xd7\x01\n" +
+	"\fAccountEvent\x12=\n" +
+	"\x04type\x18\x01 \x01(\x0e2).cliproxy.management.v1.AccountEvent.TypeR\x04type\x129\n" +
+	"\aaccount\x18\x02 \x01(\v2\x1f.cliproxy.management.v1.AccountR\aaccount\"M\n" +
+	"\x04Type\x12\x14\n" +
+	"\x10TYPE_UNSPECIFIED\x10\x00\x12\t\n" +
+	"\x05ADDED\x10\x01\x12\v\n" +
+	"\aUPDATED\x10\x02\x12\v\n" +
+	"\aREMOVED\x10\x03\x12\n" +
+	"\n" +
+	"\x06SYNCED\x10\x04\"\x12\n" +
+	"\x10GetConfigRequest\"0\n" +
+	"\x06Config\x12\x12\n" +
+	"\x04yaml\x18\x01 \x01(\tR\x04yaml\x12\x12\n" +
+	"\x04json\x18\x02 \x01(\tR\x04json\"&\n" +
+	"\x10PutConfigRequest\x12\x12\n" +
+	"\x04yaml\x18\x01 \x01(\tR\x04yaml\"\x14\n" +
+	"\x12WatchConfigRequest\"\xb7\x01\n" +
+	"\x11GetMetricsRequest\x12.\n" +
+	"\x04from\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x04from\x12*\n" +
+	"\x02to\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x02to\x12\x14\n" +
+	"\x05model\x18\x03 \x01(\tR\x05model\x12\x16\n" +
+	"\x06bucket\x18\x04 \x01(\tR\x06bucket\x12\x18\n" +
+	"\aproject\x18\x05 \x01(\tR\aproject\"\xaf\x01\n" +
+	"\x13WatchMetricsRequest\x12)\n" +
+	"\x10interval_seconds\x18\x01 \x01(\x05R\x0fintervalSeconds\x12%\n" +
+	"\x0ewindow_seconds\x18\x02 \x01(\x05R\rwindowSeconds\x12\x14\n" +
+	"\x05model\x18\x03 \x01(\tR\x05model\x12\x16\n" +
+	"\x06bucket\x18\x04 \x01(\tR\x06bucket\x12\x18\n" +
+	"\aproject\x18\x05 \x01(\tR\aproject\"\xc6\x02\n" +
+	"\aMetrics\x126\n" +
+	"\x06totals\x18\x01 \x01(\v2\x1e.cliproxy.management.v1.TotalsR\x06totals\x12?\n" +
+	"\bby_model\x18\x02 \x03(\v2$.cliproxy.management.v1.ModelMetricsR\abyModel\x129\n" +
+	"\x06by_key\x18\x03 \x03(\v2\".cliproxy.management.v1.KeyMetricsR\x05byKey\x12H\n" +
+	"\n" +
+	"timeseries\x18\x04 \x03(\v2(.cliproxy.management.v1.TimeseriesBucketR\n" +
+	"timeseries\x12=\n" +
+	"\fgenerated_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\vgeneratedAt\"\xbc\x02\n" +
+	"\x06Totals\x12\x16\n" +
+	"\x06tokens\x18\x01 \x01(\x03R\x06tokens\x12)\n" +
+	"\x10embedding_tokens\x18\x02 \x01(\x03R\x0fembeddingTokens\x12\x16\n" +
+	"\x06images\x18\x03 \x01(\x03R\x06images\x12\x1a\n" +
+	"\brequests\x18\x04 \x01(\x03R\brequests\x12\x18\n" +
+	"\aretries\x18\x05 \x01(\x03R\aretries\x12\x12\n" +
+	"\x04cost\x18\x06 \x01(\x01R\x04cost\x12<\n" +
+	"\attft_ms\x18\a \x01(\v2#.cliproxy.management.v1.PercentilesR\x06ttftMs\x12O\n" +
+	"\x11tokens_per_second\x18\b \x01(\v2#.cliproxy.management.v1.PercentilesR\x0ftokensPerSecond\"\xd8\x02\n" +
+	"\fModelMetrics\x12\x14\n" +
+	"\x05model\x18\x01 \x01(\tR\x05model\x12\x16\n" +
+	"\x06tokens\x18\x02 \x01(\x03R\x06tokens\x12)\n" +
+	"\x10embedding_tokens\x18\x03 \x01(\x03R\x0fembeddingTokens\x12\x16\n" +
+	"\x06images\x18\x04 \x01(\x03R\x06images\x12\x1a\n" +
+	"\brequests\x18\x05 \x01(\x03R\brequests\x12\x18\n" +
+	"\aretries\x18\x06 \x01(\x03R\aretries\x12\x12\n" +
+	"\x04cost\x18\a \x01(\x01R\x04cost\x12<\n" +
+	"\attft_ms\x18\b \x01(\v2#.cliproxy.management.v1.PercentilesR\x06ttftMs\x12O\n" +
+	"\x11tokens_per_second\x18\t \x01(\v2#.cliproxy.management.v1.PercentilesR\x0ftokensPerSecond\"f\n" +
+	"\n" +
+	"KeyMetrics\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x16\n" +
+	"\x06tokens\x18\x02 \x01(\x03R\x06tokens\x12\x1a\n" +
+	"\brequests\x18\x03 \x01(\x03R\brequests\x12\x12\n" +
+	"\x04cost\x18\x04 \x01(\x01R\x04cost\"\x8c\x01\n" +
+	"\x10TimeseriesBucket\x120\n" +
+	"\x05start\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x05start\x12\x16\n" +
+	"\x06tokens\x18\x02 \x01(\x03R\x06tokens\x12\x1a\n" +
+	"\brequests\x18\x03 \x01(\x03R\brequests\x12\x12\n" +
+	"\x04cost\x18\x04 \x01(\x01R\x04cost\"C\n" +
+	"\vPercentiles\x12\x10\n" +
+	"\x03p50\x18\x01 \x01(\x01R\x03p50\x12\x10\n" +
+	"\x03p95\x18\x02 \x01(\x01R\x03p95\x12\x10\n" +
+	"\x03p99\x18\x03 \x01(\x01R\x03p992\xa0\x06\n" +
+	"\n" +
+	"Management\x12i\n" +
+	"\fListAccounts\x12+.cliproxy.management.v1.ListAccountsRequest\x1a,.cliproxy.management.v1.ListAccountsResponse\x12{\n" +
+	"\x12SetAccountDisabled\x121.cliproxy.management.v1.SetAccountDisabledRequest\x1a2.cliproxy.management.v1.SetAccountDisabledResponse\x12e\n" +
+	"\rWatchAccounts\x12,.cliproxy.management.v1.WatchAccountsRequest\x1a$.cliproxy.management.v1.AccountEvent0\x01\x12U\n" +
+	"\tGetConfig\x12(.cliproxy.management.v1.GetConfigRequest\x1a\x1e.cliproxy.management.v1.Config\x12U\n" +
+	"\tPutConfig\x12(.cliproxy.management.v1.PutConfigRequest\x1a\x1e.cliproxy.management.v1.Config\x12[\n" +
+	"\vWatchConfig\x12*.cliproxy.management.v1.WatchConfigRequest\x1a\x1e.cliproxy.management.v1.Config0\x01\x12X\n" +
+	"\n" +
+	"GetMetrics\x12).cliproxy.management.v1.GetMetricsRequest\x1a\x1f.cliproxy.management.v1.Metrics\x12^\n" +
+	"\fWatchMetrics\x12+.cliproxy.management.v1.WatchMetricsRequest\x1a\x1f.cliproxy.management.v1.Metrics0\x01BGZEgithub.com/router-for-me/CLIProxyAPI/v6/internal/grpcapi/managementpbb\x06proto3"
+
+var (
+	file_cliproxy_management_v1_management_proto_rawDescOnce sync.Once
+	file_cliproxy_management_v1_management_proto_rawDescData []byte
+)
+
+func file_cliproxy_management_v1_management_proto_rawDescGZIP() []byte {
+	file_cliproxy_management_v1_management_proto_rawDescOnce.Do(func() {
+		file_cliproxy_management_v1_management_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_cliproxy_management_v1_management_proto_rawDesc), len(file_cliproxy_management_v1_management_proto_rawDesc)))
+	})
+	return file_cliproxy_management_v1_management_proto_rawDescData
+}
+
+var file_cliproxy_management_v1_management_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_cliproxy_management_v1_management_proto_msgTypes = make([]protoimpl.MessageInfo, 21)
+var file_cliproxy_management_v1_management_proto_goTypes = []any{
+	(AccountEvent_Type)(0),             // 0: cliproxy.management.v1.AccountEvent.Type
+	(*Account)(nil),                    // 1: cliproxy.management.v1.Account
+	(*AccountHealth)(nil),              // 2: cliproxy.management.v1.AccountHealth
+	(*ListAccountsRequest)(nil),        // 3: cliproxy.management.v1.ListAccountsRequest
+	(*ListAccountsResponse)(nil),       // 4: cliproxy.management.v1.ListAccountsResponse
+	(*SetAccountDisabledRequest)(nil),  // 5: cliproxy.management.v1.SetAccountDisabledRequest
+	(*SetAccountDisabledResponse)(nil), // 6: cliproxy.management.v1.SetAccountDisabledResponse
+	(*WatchAccountsRequest)(nil),       // 7: cliproxy.management.v1.WatchAccountsRequest
+	(*AccountEvent)(nil),               // 8: cliproxy.management.v1.AccountEvent
+	(*GetConfigRequest)(nil),           // 9: cliproxy.management.v1.GetConfigRequest
+	(*Config)(nil),                     // 10: cliproxy.management.v1.Config
+	(*PutConfigRequest)(nil),           // 11: cliproxy.management.v1.PutConfigRequest
+	(*WatchConfigRequest)(nil),         // 12: cliproxy.management.v1.WatchConfigRequest
+	(*GetMetricsRequest)(nil),          // 13: cliproxy.management.v1.GetMetricsRequest
+	(*WatchMetricsRequest)(nil),        // 14: cliproxy.management.v1.WatchMetricsRequest
+	(*Metrics)(nil),                    // 15: cliproxy.management.v1.Metrics
+	(*Totals)(nil),                     // 16: cliproxy.management.v1.Totals
+	(*ModelMetrics)(nil),               // 17: cliproxy.management.v1.ModelMetrics
+	(*KeyMetrics)(nil),                 // 18: cliproxy.management.v1.KeyMetrics
+	(*TimeseriesBucket)(nil),           // 19: cliproxy.management.v1.TimeseriesBucket
+	(*Percentiles)(nil),                // 20: cliproxy.management.v1.Percentiles
+	nil,                                // 21: cliproxy.management.v1.Account.AttributesEntry
+	(*timestamppb.Timestamp)(nil),      // 22: google.protobuf.Timestamp
+}
+var file_cliproxy_management_v1_management_proto_depIdxs = []int32{
+	21, // 0: cliproxy.management.v1.Account.attributes:type_name -> cliproxy.management.v1.Account.AttributesEntry
+	22, // 1: cliproxy.management.v1.Account.created_at:type_name -> google.protobuf.Timestamp
+	22, // 2: cliproxy.management.v1.Account.updated_at:type_name -> google.protobuf.Timestamp
+	22, // 3: cliproxy.management.v1.Account.last_refreshed_at:type_name -> google.protobuf.Timestamp
+	22, // 4: cliproxy.management.v1.Account.next_retry_after:type_name -> google.protobuf.Timestamp
+	2,  // 5: cliproxy.management.v1.Account.health:type_name -> cliproxy.management.v1.AccountHealth
+	22, // 6: cliproxy.management.v1.AccountHealth.excluded_until:type_name -> google.protobuf.Timestamp
+	22, // 7: cliproxy.management.v1.AccountHealth.last_success_at:type_name -> google.protobuf.Timestamp
+	22, // 8: cliproxy.management.v1.AccountHealth.last_failure_at:type_name -> google.protobuf.Timestamp
+	1,  // 9: cliproxy.management.v1.ListAccountsResponse.accounts:type_name -> cliproxy.management.v1.Account
+	1,  // 10: cliproxy.management.v1.SetAccountDisabledResponse.account:type_name -> cliproxy.management.v1.Account
+	0,  // 11: cliproxy.management.v1.AccountEvent.type:type_name -> cliproxy.management.v1.AccountEvent.Type
+	1,  // 12: cliproxy.management.v1.AccountEvent.account:type_name -> cliproxy.management.v1.Account
+	22, // 13: cliproxy.management.v1.GetMetricsRequest.from:type_name -> google.protobuf.Timestamp
+	22, // 14: cliproxy.management.v1.GetMetricsRequest.to:type_name -> google.protobuf.Timestamp
+	16, // 15: cliproxy.management.v1.Metrics.totals:type_name -> cliproxy.management.v1.Totals
+	17, // 16: cliproxy.management.v1.Metrics.by_model:type_name -> cliproxy.management.v1.ModelMetrics
+	18, // 17: cliproxy.management.v1.Metrics.by_key:type_name -> cliproxy.management.v1.KeyMetrics
+	19, // 18: cliproxy.management.v1.Metrics.timeseries:type_name -> cliproxy.management.v1.TimeseriesBucket
+	22, // 19: cliproxy.management.v1.Metrics.generated_at:type_name -> google.protobuf.Timestamp
+	20, // 20: cliproxy.management.v1.Totals.ttft_ms:type_name -> cliproxy.management.v1.Percentiles
+	20, // 21: cliproxy.management.v1.Totals.tokens_per_second:type_name -> cliproxy.management.v1.Percentiles
+	20, // 22: cliproxy.management.v1.ModelMetrics.ttft_ms:type_name -> cliproxy.management.v1.Percentiles
+	20, // 23: cliproxy.management.v1.ModelMetrics.tokens_per_second:type_name -> cliproxy.management.v1.Percentiles
+	22, // 24: cliproxy.management.v1.TimeseriesBucket.start:type_name -> google.protobuf.Timestamp
+	3,  // 25: cliproxy.management.v1.Management.ListAccounts:input_type -> cliproxy.management.v1.ListAccountsRequest
+	5,  // 26: cliproxy.management.v1.Management.SetAccountDisabled:input_type -> cliproxy.management.v1.SetAccountDisabledRequest
+	7,  // 27: cliproxy.management.v1.Management.WatchAccounts:input_type -> cliproxy.management.v1.WatchAccountsRequest
+	9,  // 28: cliproxy.management.v1.Management.GetConfig:input_type -> cliproxy.management.v1.GetConfigRequest
+	11, // 29: cliproxy.management.v1.Management.PutConfig:input_type -> cliproxy.management.v1.PutConfigRequest
+	12, // 30: cliproxy.management.v1.Management.WatchConfig:input_type -> cliproxy.management.v1.WatchConfigRequest
+	13, // 31: cliproxy.management.v1.Management.GetMetrics:input_type -> cliproxy.management.v1.GetMetricsRequest
+	14, // 32: cliproxy.management.v1.Management.WatchMetrics:input_type -> cliproxy.management.v1.WatchMetricsRequest
+	4,  // 33: cliproxy.management.v1.Management.ListAccounts:output_type -> cliproxy.management.v1.ListAccountsResponse
+	6,  // 34: cliproxy.management.v1.Management.SetAccountDisabled:output_type -> cliproxy.management.v1.SetAccountDisabledResponse
+	8,  // 35: cliproxy.management.v1.Management.WatchAccounts:output_type -> cliproxy.management.v1.AccountEvent
+	10, // 36: cliproxy.management.v1.Management.GetConfig:output_type -> cliproxy.management.v1.Config
+	10, // 37: cliproxy.management.v1.Management.PutConfig:output_type -> cliproxy.management.v1.Config
+	10, // 38: cliproxy.management.v1.Management.WatchConfig:output_type -> cliproxy.management.v1.Config
+	15, // 39: cliproxy.management.v1.Management.GetMetrics:output_type -> cliproxy.management.v1.Metrics
+	15, // 40: cliproxy.management.v1.Management.WatchMetrics:output_type -> cliproxy.management.v1.Metrics
+	33, // [33:41] is the sub-list for method output_type
+	25, // [25:33] is the sub-list for method input_type
+	25, // [25:25] is the sub-list for extension type_name
+	25, // [25:25] is the sub-list for extension extendee
+	0,  // [0:25] is the sub-list for field type_name
+}
+
+func init() { file_cliproxy_management_v1_management_proto_init() }
+func file_cliproxy_management_v1_management_proto_init() {
+	if File_cliproxy_management_v1_management_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_cliproxy_management_v1_management_proto_rawDesc), len(file_cliproxy_management_v1_management_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   21,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_cliproxy_management_v1_management_proto_goTypes,
+		DependencyIndexes: file_cliproxy_management_v1_management_proto_depIdxs,
+		EnumInfos:         file_cliproxy_management_v1_management_proto_enumTypes,
+		MessageInfos:      file_cliproxy_management_v1_management_proto_msgTypes,
+	}.Build()
+	File_cliproxy_management_v1_management_proto = out.File
+	file_cliproxy_management_v1_management_proto_goTypes = nil
+	file_cliproxy_management_v1_management_proto_depIdxs = nil
+}
@@ -0,0 +1,464 @@
+// Package grpcapi serves the management plane over gRPC for infrastructure automation.
+// It exposes the accounts, configuration and metrics of the HTTP management API together
+// with streaming watch calls, and authenticates callers with the management key.
+package grpcapi
+
+//go:generate protoc -I ../../proto --go_out=../.. --go_opt=module=github.com/router-for-me/CLIProxyAPI/v6 --go-grpc_out=../.. --go-grpc_opt=module=github.com/router-for-me/CLIProxyAPI/v6 cliproxy/management/v1/management.proto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers/management"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers/metrics"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	pb "github.com/router-for-me/CLIProxyAPI/v6/internal/grpcapi/managementpb"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// pollInterval is how often watch streams look for account and config changes.
+	pollInterval = time.Second
+
+	defaultMetricsInterval = 10 * time.Second
+	defaultMetricsWindow   = 24 * time.Hour
+)
+
+// Server implements the Management gRPC service on top of the HTTP management and
+// metrics handlers, so both transports share state, validation and access rules.
+type Server struct {
+	pb.UnimplementedManagementServer
+
+	mgmt    *management.Handler
+	metrics *metrics.Handler
+	grpc    *grpc.Server
+
+	// stopping is closed by Stop to end the watch streams.
+	stopping chan struct{}
+	stopOnce sync.Once
+}
+
+// NewServer creates a gRPC server for the management plane.
+//
+// Parameters:
+//   - mgmt: The management handler providing accounts, configuration and access control
+//   - metricsHandler: The metrics handler used to aggregate usage
+//
+// Returns:
+//   - *Server: A server ready to Serve on a listener
+func NewServer(mgmt *management.Handler, metricsHandler *metrics.Handler) *Server {
+	s := &Server{mgmt: mgmt, metrics: metricsHandler, stopping: make(chan struct{})}
+	s.grpc = grpc.NewServer(
+		grpc.ChainUnaryInterceptor(s.unaryAuth),
+		grpc.ChainStreamInterceptor(s.streamAuth),
+	)
+	pb.RegisterManagementServer(s.grpc, s)
+	return s
+}
+
+// Serve accepts connections on lis until Stop is called.
+func (s *Server) Serve(lis net.Listener) error {
+	if err := s.grpc.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return err
+	}
+	return nil
+}
+
+// Stop waits for unary calls to finish and closes watch streams, forcing the shutdown
+// when ctx ends first.
+func (s *Server) Stop(ctx context.Context) {
+	s.stopOnce.Do(func() { close(s.stopping) })
+	done := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.grpc.Stop()
+	}
+}
+
+type actorKey struct{}
+
+// methodRoutes maps every call to the HTTP endpoint it mirrors, so that both transports
+// share one role policy.
+var methodRoutes = map[string]struct{ method, route string }{
+	pb.Management_ListAccounts_FullMethodName:       {http.MethodGet, "/accounts"},
+	pb.Management_SetAccountDisabled_FullMethodName: {http.MethodPatch, "/accounts/status"},
+	pb.Management_WatchAccounts_FullMethodName:      {http.MethodGet, "/accounts"},
+	pb.Management_GetConfig_FullMethodName:          {http.MethodGet, "/config"},
+	pb.Management_PutConfig_FullMethodName:          {http.MethodPut, "/config.yaml"},
+	pb.Management_WatchConfig_FullMethodName:        {http.MethodGet, "/config"},
+	pb.Management_GetMetrics_FullMethodName:         {http.MethodGet, "/usage"},
+	pb.Management_WatchMetrics_FullMethodName:       {http.MethodGet, "/usage"},
+}
+
+func (s *Server) unaryAuth(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	actor, err := s.authorize(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(context.WithValue(ctx, actorKey{}, actor), req)
+}
+
+func (s *Server) streamAuth(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if _, err := s.authorize(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// audit records a change made by the caller of ctx in the audit log.
+func (s *Server) audit(ctx context.Context, action string, mutate func() error) error {
+	actor, _ := ctx.Value(actorKey{}).(logging.AuditActor)
+	return s.mgmt.Audit(actor, "grpc "+action, mutate)
+}
+
+// authorize checks the management key carried in the call metadata against the role the
+// called method requires and identifies the caller.
+func (s *Server) authorize(ctx context.Context, fullMethod string) (logging.AuditActor, error) {
+	var clientIP string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		clientIP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(clientIP); err == nil {
+			clientIP = host
+		}
+	}
+	var provided, userAgent string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("user-agent"); len(values) > 0 {
+			userAgent = values[0]
+		}
+		if values := md.Get("authorization"); len(values) > 0 {
+			provided = values[0]
+			if scheme, key, found := strings.Cut(provided, " "); found && strings.EqualFold(scheme, "bearer") {
+				provided = key
+			}
+		}
+		if values := md.Get("x-management-key"); provided == "" && len(values) > 0 {
+			provided = values[0]
+		}
+	}
+	credential, role, statusCode, err := s.mgmt.Authorize(clientIP, provided)
+	if err == nil {
+		required := config.ManagementRoleAdmin
+		if target, ok := methodRoutes[fullMethod]; ok {
+			required = management.RequiredRole(target.method, target.route)
+		}
+		if !management.RoleAllows(role, required) {
+			return logging.AuditActor{}, status.Errorf(codes.PermissionDenied, "role %s may not call this method, %s required", role, required)
+		}
+		return logging.AuditActor{Credential: credential, Role: role, Transport: "grpc", ClientIP: clientIP, UserAgent: userAgent}, nil
+	}
+	if statusCode == http.StatusUnauthorized {
+		return logging.AuditActor{}, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return logging.AuditActor{}, status.Error(codes.PermissionDenied, err.Error())
+}
+
+func (s *Server) authManager() (*coreauth.Manager, error) {
+	manager := s.mgmt.AuthManager()
+	if manager == nil {
+		return nil, status.Error(codes.Unavailable, "core auth manager unavailable")
+	}
+	return manager, nil
+}
+
+// ListAccounts implements pb.ManagementServer.
+func (s *Server) ListAccounts(_ context.Context, req *pb.ListAccountsRequest) (*pb.ListAccountsResponse, error) {
+	manager, err := s.authManager()
+	if err != nil {
+		return nil, err
+	}
+	health := healthByID(manager)
+	if id := strings.TrimSpace(req.GetId()); id != "" {
+		auth, ok := manager.GetByID(id)
+		if !ok {
+			return nil, status.Error(codes.NotFound, "account not found")
+		}
+		return &pb.ListAccountsResponse{Accounts: []*pb.Account{toAccount(auth, health[auth.ID])}}, nil
+	}
+	return &pb.ListAccountsResponse{Accounts: listAccounts(manager, req.GetProvider())}, nil
+}
+
+// SetAccountDisabled implements pb.ManagementServer.
+func (s *Server) SetAccountDisabled(ctx context.Context, req *pb.SetAccountDisabledRequest) (*pb.SetAccountDisabledResponse, error) {
+	manager, err := s.authManager()
+	if err != nil {
+		return nil, err
+	}
+	id := strings.TrimSpace(req.GetId())
+	if id == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	var (
+		updated   *coreauth.Auth
+		persisted bool
+	)
+	err = s.audit(ctx, fmt.Sprintf("SetAccountDisabled id=%s disabled=%t", id, req.GetDisabled()), func() error {
+		var errSet error
+		updated, persisted, errSet = s.mgmt.SetAccountDisabled(ctx, id, req.GetDisabled())
+		return errSet
+	})
+	if err != nil {
+		if errors.Is(err, management.ErrAccountNotFound) {
+			return nil, status.Error(codes.NotFound, "account not found")
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &pb.SetAccountDisabledResponse{Account: toAccount(updated, healthByID(manager)[updated.ID]), Persisted: persisted}, nil
+}
+
+// WatchAccounts implements pb.ManagementServer. Changes are detected by comparing the
+// accounts every pollInterval; health counters alone do not produce events.
+func (s *Server) WatchAccounts(req *pb.WatchAccountsRequest, stream grpc.ServerStreamingServer[pb.AccountEvent]) error {
+	manager, err := s.authManager()
+	if err != nil {
+		return err
+	}
+	known := make(map[string]*pb.Account)
+	for _, account := range listAccounts(manager, req.GetProvider()) {
+		known[account.Id] = account
+		if err = stream.Send(&pb.AccountEvent{Type: pb.AccountEvent_ADDED, Account: account}); err != nil {
+			return err
+		}
+	}
+	if err = stream.Send(&pb.AccountEvent{Type: pb.AccountEvent_SYNCED}); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-s.stopping:
+			return nil
+		case <-ticker.C:
+		}
+		if manager, err = s.authManager(); err != nil {
+			return err
+		}
+		current := make(map[string]bool)
+		for _, account := range listAccounts(manager, req.GetProvider()) {
+			current[account.Id] = true
+			event := pb.AccountEvent_UPDATED
+			previous, ok := known[account.Id]
+			if !ok {
+				event = pb.AccountEvent_ADDED
+			} else if sameAccount(previous, account) {
+				continue
+			}
+			known[account.Id] = account
+			if err = stream.Send(&pb.AccountEvent{Type: event, Account: account}); err != nil {
+				return err
+			}
+		}
+		for id := range known {
+			if current[id] {
+				continue
+			}
+			delete(known, id)
+			if err = stream.Send(&pb.AccountEvent{Type: pb.AccountEvent_REMOVED, Account: &pb.Account{Id: id}}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// listAccounts returns the accounts of provider, or of every provider, sorted by ID.
+func listAccounts(manager *coreauth.Manager, provider string) []*pb.Account {
+	provider = strings.ToLower(strings.TrimSpace(provider))
+	health := healthByID(manager)
+	auths := manager.List()
+	sort.Slice(auths, func(i, j int) bool { return auths[i].ID < auths[j].ID })
+	accounts := make([]*pb.Account, 0, len(auths))
+	for _, auth := range auths {
+		if provider != "" && strings.ToLower(auth.Provider) != provider {
+			continue
+		}
+		accounts = append(accounts, toAccount(auth, health[auth.ID]))
+	}
+	return accounts
+}
+
+func healthByID(manager *coreauth.Manager) map[string]*coreauth.AuthHealth {
+	snapshot := manager.HealthSnapshot()
+	health := make(map[string]*coreauth.AuthHealth, len(snapshot))
+	for i := range snapshot {
+		health[snapshot[i].ID] = &snapshot[i]
+	}
+	return health
+}
+
+// sameAccount compares two account views ignoring their health.
+func sameAccount(a, b *pb.Account) bool {
+	a, b = proto.CloneOf(a), proto.CloneOf(b)
+	a.Health, b.Health = nil, nil
+	return proto.Equal(a, b)
+}
+
+// GetConfig implements pb.ManagementServer.
+func (s *Server) GetConfig(context.Context, *pb.GetConfigRequest) (*pb.Config, error) {
+	return s.currentConfig()
+}
+
+// PutConfig implements pb.ManagementServer.
+func (s *Server) PutConfig(ctx context.Context, req *pb.PutConfigRequest) (*pb.Config, error) {
+	err := s.audit(ctx, "PutConfig", func() error { return s.mgmt.ReplaceConfigYAML([]byte(req.GetYaml())) })
+	if err != nil {
+		var errUpdate *management.ConfigUpdateError
+		if errors.As(err, &errUpdate) && errUpdate.Status < http.StatusInternalServerError {
+			return nil, status.Error(codes.InvalidArgument, errUpdate.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return s.currentConfig()
+}
+
+// WatchConfig implements pb.ManagementServer.
+func (s *Server) WatchConfig(_ *pb.WatchConfigRequest, stream grpc.ServerStreamingServer[pb.Config]) error {
+	cfg := s.mgmt.Config()
+	current, err := s.currentConfig()
+	if err != nil {
+		return err
+	}
+	if err = stream.Send(current); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-s.stopping:
+			return nil
+		case <-ticker.C:
+		}
+		// Every reload replaces the configuration the handler serves.
+		if s.mgmt.Config() == cfg {
+			continue
+		}
+		cfg = s.mgmt.Config()
+		if current, err = s.currentConfig(); err != nil {
+			return err
+		}
+		if err = stream.Send(current); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) currentConfig() (*pb.Config, error) {
+	data, err := s.mgmt.ReadConfigFile()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to read config: %v", err)
+	}
+	effective, err := json.Marshal(s.mgmt.Config())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to encode config: %v", err)
+	}
+	return &pb.Config{Yaml: string(data), Json: string(effective)}, nil
+}
+
+// GetMetrics implements pb.ManagementServer.
+func (s *Server) GetMetrics(_ context.Context, req *pb.GetMetricsRequest) (*pb.Metrics, error) {
+	bucket, err := bucketSize(req.GetBucket())
+	if err != nil {
+		return nil, err
+	}
+	query := metrics.Query{Model: req.GetModel(), Project: req.GetProject(), Bucket: bucket}
+	if req.From != nil {
+		query.From = req.From.AsTime()
+	}
+	if req.To != nil {
+		query.To = req.To.AsTime()
+	}
+	// Default to last 24 hours if no time range is given
+	if query.From.IsZero() && query.To.IsZero() {
+		query.To = time.Now()
+		query.From = query.To.Add(-defaultMetricsWindow)
+	}
+	if err = s.metrics.Validate(query); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return toMetrics(s.metrics.Compute(query)), nil
+}
+
+// WatchMetrics implements pb.ManagementServer.
+func (s *Server) WatchMetrics(req *pb.WatchMetricsRequest, stream grpc.ServerStreamingServer[pb.Metrics]) error {
+	bucket, err := bucketSize(req.GetBucket())
+	if err != nil {
+		return err
+	}
+	interval := defaultMetricsInterval
+	if req.GetIntervalSeconds() > 0 {
+		interval = time.Duration(req.GetIntervalSeconds()) * time.Second
+	}
+	window := defaultMetricsWindow
+	if req.GetWindowSeconds() > 0 {
+		window = time.Duration(req.GetWindowSeconds()) * time.Second
+	}
+	query := func() metrics.Query {
+		now := time.Now()
+		return metrics.Query{From: now.Add(-window), To: now, Model: req.GetModel(), Project: req.GetProject(), Bucket: bucket}
+	}
+	if err = s.metrics.Validate(query()); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err = stream.Send(toMetrics(s.metrics.Compute(query()))); err != nil {
+			return err
+		}
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-s.stopping:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func bucketSize(name string) (time.Duration, error) {
+	if name == "" {
+		name = "1h"
+	}
+	size, ok := metrics.BucketSize(name)
+	if !ok {
+		return 0, status.Error(codes.InvalidArgument, "invalid bucket value, expected one of 1m, 5m, 1h, 1d")
+	}
+	return size, nil
+}
+
+// timestamp converts t, leaving zero times unset.
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
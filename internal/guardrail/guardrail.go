@@ -0,0 +1,267 @@
+// Package guardrail bounds the size of requests per inbound API key: it lowers the output
+// token limit of requests to a configured cap and rejects or truncates prompts whose
+// estimated size exceeds a configured threshold, before they reach an upstream provider.
+package guardrail
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// wildcardKey selects the guardrail applied to keys without their own entry.
+const wildcardKey = "*"
+
+// bytesPerToken is the estimate used to size prompts without tokenizing them.
+const bytesPerToken = 4
+
+// Format identifies the request schema of an endpoint.
+type Format string
+
+// Request formats guardrails understand.
+const (
+	FormatOpenAIChat        Format = "openai-chat"
+	FormatOpenAICompletions Format = "openai-completions"
+	FormatOpenAIResponses   Format = "openai-responses"
+	FormatClaude            Format = "claude"
+	FormatGemini            Format = "gemini"
+)
+
+// LimitError reports a prompt over the input limit of its API key.
+type LimitError struct {
+	Estimated int64
+	Limit     int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("prompt of about %d tokens exceeds the limit of %d tokens for this API key", e.Estimated, e.Limit)
+}
+
+// Result is the outcome of applying a guardrail to a request.
+type Result struct {
+	// Body is the request body to forward.
+	Body []byte
+	// Dropped is the number of messages removed by truncation.
+	Dropped int
+	// CappedOutput reports whether the output token limit was lowered or set.
+	CappedOutput bool
+}
+
+// formatSpec describes where a format keeps its messages and output limit.
+type formatSpec struct {
+	// messages is the path of the message array; empty for formats that cannot be truncated.
+	messages string
+	// outputFields are the output limit fields; present ones are capped, otherwise the
+	// first is set.
+	outputFields []string
+	// pinned reports messages at the start that truncation keeps, such as system prompts.
+	pinned func(message gjson.Result) bool
+	// startsTurn reports whether a message may follow the pinned messages; tool results
+	// whose call was dropped may not.
+	startsTurn func(message gjson.Result) bool
+}
+
+var specs = map[Format]formatSpec{
+	FormatOpenAIChat: {
+		messages:     "messages",
+		outputFields: []string{"max_tokens", "max_completion_tokens"},
+		pinned: func(message gjson.Result) bool {
+			role := message.Get("role").String()
+			return role == "system" || role == "developer"
+		},
+		startsTurn: func(message gjson.Result) bool { return message.Get("role").String() == "user" },
+	},
+	FormatOpenAICompletions: {
+		outputFields: []string{"max_tokens"},
+	},
+	FormatOpenAIResponses: {
+		messages:     "input",
+		outputFields: []string{"max_output_tokens"},
+		pinned: func(message gjson.Result) bool {
+			role := message.Get("role").String()
+			return role == "system" || role == "developer"
+		},
+		startsTurn: func(message gjson.Result) bool {
+			typ := message.Get("type").String()
+			return (typ == "" || typ == "message") && message.Get("role").String() == "user"
+		},
+	},
+	FormatClaude: {
+		messages:     "messages",
+		outputFields: []string{"max_tokens"},
+		pinned:       func(gjson.Result) bool { return false },
+		startsTurn: func(message gjson.Result) bool {
+			return message.Get("role").String() == "user" && message.Get("content.0.type").String() != "tool_result"
+		},
+	},
+	FormatGemini: {
+		messages:     "contents",
+		outputFields: []string{"generationConfig.maxOutputTokens"},
+		pinned:       func(gjson.Result) bool { return false },
+		startsTurn: func(message gjson.Result) bool {
+			if role := message.Get("role").String(); role != "" && role != "user" {
+				return false
+			}
+			for _, part := range message.Get("parts").Array() {
+				if part.Get("functionResponse").Exists() || part.Get("function_response").Exists() {
+					return false
+				}
+			}
+			return true
+		},
+	},
+}
+
+// Guard holds the configured guardrails. It is safe for concurrent use.
+type Guard struct {
+	mu       sync.RWMutex
+	byKey    map[string]config.Guardrail
+	fallback *config.Guardrail
+}
+
+// New creates a guard for the configured guardrails.
+func New(entries []config.Guardrail) *Guard {
+	g := &Guard{}
+	g.SetLimits(entries)
+	return g
+}
+
+// SetLimits replaces the configured guardrails.
+func (g *Guard) SetLimits(entries []config.Guardrail) {
+	byKey := make(map[string]config.Guardrail, len(entries))
+	var fallback *config.Guardrail
+	for i := range entries {
+		entry := entries[i]
+		key := strings.TrimSpace(entry.APIKey)
+		if key == "" {
+			continue
+		}
+		if key == wildcardKey {
+			fallback = &entry
+			continue
+		}
+		byKey[key] = entry
+	}
+	g.mu.Lock()
+	g.byKey = byKey
+	g.fallback = fallback
+	g.mu.Unlock()
+}
+
+// Enabled reports whether any guardrail is configured.
+func (g *Guard) Enabled() bool {
+	if g == nil {
+		return false
+	}
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return len(g.byKey) > 0 || g.fallback != nil
+}
+
+func (g *Guard) limitFor(key string) (config.Guardrail, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if entry, ok := g.byKey[key]; ok {
+		return entry, true
+	}
+	if g.fallback != nil {
+		return *g.fallback, true
+	}
+	return config.Guardrail{}, false
+}
+
+// Apply enforces the guardrail of key on a request body of the given format. It returns
+// a *LimitError when the prompt is over the input limit and cannot be truncated to fit.
+func (g *Guard) Apply(key string, format Format, body []byte) (Result, error) {
+	result := Result{Body: body}
+	spec, known := specs[format]
+	if g == nil || !known || !gjson.ValidBytes(body) {
+		return result, nil
+	}
+	limit, ok := g.limitFor(key)
+	if !ok {
+		return result, nil
+	}
+
+	if limit.MaxInputTokens > 0 {
+		if estimated := int64(len(body) / bytesPerToken); estimated > limit.MaxInputTokens {
+			truncated, dropped, remaining := truncate(spec, limit, body, estimated)
+			if remaining > limit.MaxInputTokens {
+				return result, &LimitError{Estimated: estimated, Limit: limit.MaxInputTokens}
+			}
+			result.Body, result.Dropped = truncated, dropped
+		}
+	}
+	if limit.MaxOutputTokens > 0 {
+		result.Body, result.CappedOutput = capOutput(spec, limit.MaxOutputTokens, result.Body)
+	}
+	return result, nil
+}
+
+// truncate drops messages following the pinned ones until the estimated prompt fits the
+// input limit, as the strategy allows. The latest message is always kept. It returns the
+// body, the number of dropped messages and the remaining estimate.
+func truncate(spec formatSpec, limit config.Guardrail, body []byte, estimated int64) ([]byte, int, int64) {
+	if spec.messages == "" || (limit.Strategy != config.GuardrailTruncateOldest && limit.Strategy != config.GuardrailTruncateMiddle) {
+		return body, 0, estimated
+	}
+	messages := gjson.GetBytes(body, spec.messages).Array()
+	head := 0
+	for head < len(messages) && spec.pinned(messages[head]) {
+		head++
+	}
+	if limit.Strategy == config.GuardrailTruncateMiddle && head < len(messages)-1 {
+		head++
+	}
+	end := head
+	for end < len(messages)-1 && estimated > limit.MaxInputTokens {
+		estimated -= int64(len(messages[end].Raw) / bytesPerToken)
+		end++
+	}
+	// Drop tool results and replies whose preceding turn is gone.
+	for end > head && end < len(messages)-1 && !spec.startsTurn(messages[end]) {
+		estimated -= int64(len(messages[end].Raw) / bytesPerToken)
+		end++
+	}
+	if end == head {
+		return body, 0, estimated
+	}
+	kept := make([]string, 0, len(messages)-(end-head))
+	for _, message := range messages[:head] {
+		kept = append(kept, message.Raw)
+	}
+	for _, message := range messages[end:] {
+		kept = append(kept, message.Raw)
+	}
+	out, err := sjson.SetRawBytes(body, spec.messages, []byte("["+strings.Join(kept, ",")+"]"))
+	if err != nil {
+		return body, 0, estimated
+	}
+	return out, end - head, estimated
+}
+
+// capOutput lowers the output limits of a request to limit, setting one when none is given.
+func capOutput(spec formatSpec, limit int64, body []byte) ([]byte, bool) {
+	capped := false
+	present := false
+	for _, field := range spec.outputFields {
+		value := gjson.GetBytes(body, field)
+		if !value.Exists() || value.Type == gjson.Null {
+			continue
+		}
+		present = true
+		if value.Int() > limit || value.Int() <= 0 {
+			body, _ = sjson.SetBytes(body, field, limit)
+			capped = true
+		}
+	}
+	if !present && len(spec.outputFields) > 0 {
+		body, _ = sjson.SetBytes(body, spec.outputFields[0], limit)
+		capped = true
+	}
+	return body, capped
+}
@@ -0,0 +1,321 @@
+// Package hooks calls external code before requests are dispatched and after responses are
+// produced. A hook is an HTTP endpoint or a WASM module that receives an Event as JSON and
+// answers with a Result, which may reject the request or response, replace its body or
+// change its headers. Each hook has a timeout and fails open or closed.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// Hook stages.
+const (
+	StagePreRequest   = config.HookStagePreRequest
+	StagePostResponse = config.HookStagePostResponse
+)
+
+// defaultTimeout bounds a hook call without a configured timeout.
+const defaultTimeout = 5 * time.Second
+
+// Event is the input of a hook.
+type Event struct {
+	Stage  string `json:"stage"`
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Query  string `json:"query,omitempty"`
+	// APIKey is the client API key the request was authenticated with.
+	APIKey string `json:"api_key,omitempty"`
+	// Headers are the request headers, with repeated values joined by ", ".
+	Headers map[string]string `json:"headers"`
+	// Body is the request body; omitted when it is not JSON.
+	Body json.RawMessage `json:"body,omitempty"`
+
+	// Status, ResponseHeaders and ResponseBody describe the response in the post-response
+	// stage. ResponseBody is a JSON value, or a string for responses that are not JSON
+	// such as event streams.
+	Status          int               `json:"status,omitempty"`
+	ResponseHeaders map[string]string `json:"response_headers,omitempty"`
+	ResponseBody    json.RawMessage   `json:"response_body,omitempty"`
+	// Streamed reports that the response was streamed to the client; hooks can then only
+	// observe it.
+	Streamed bool `json:"streamed,omitempty"`
+}
+
+// Result is the output of a hook. An empty result lets the request or response continue
+// unchanged.
+type Result struct {
+	// Action is "continue" (default) or "reject".
+	Action string `json:"action,omitempty"`
+	// Status and Message are the error returned to the client on rejection; Status
+	// defaults to 403.
+	Status  int    `json:"status,omitempty"`
+	Message string `json:"message,omitempty"`
+	// Body replaces the request body in the pre-request stage and the response body in
+	// the post-response stage.
+	Body json.RawMessage `json:"body,omitempty"`
+	// Headers are set on the request or response; an empty value removes the header.
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// Rejection is a request or response rejected by a hook.
+type Rejection struct {
+	Hook    string
+	Status  int
+	Message string
+}
+
+// UnavailableError reports a hook failure while the hook does not fail open.
+type UnavailableError struct {
+	Hook string
+	Err  error
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("hook %s failed: %v", e.Hook, e.Err)
+}
+
+func (e *UnavailableError) Unwrap() error { return e.Err }
+
+// caller invokes a hook with the JSON encoded event and returns its JSON encoded result.
+type caller interface {
+	call(ctx context.Context, input []byte) ([]byte, error)
+}
+
+type hook struct {
+	name     string
+	stage    string
+	paths    []string
+	timeout  time.Duration
+	failOpen bool
+	caller   caller
+}
+
+func (h *hook) matches(path string) bool {
+	if len(h.paths) == 0 {
+		return true
+	}
+	for _, prefix := range h.paths {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Runner runs the configured hooks. It is safe for concurrent use.
+type Runner struct {
+	mu    sync.RWMutex
+	hooks []*hook
+	wasm  *wasmCache
+}
+
+// New creates a runner for the configured hooks.
+func New(cfg []config.Hook) *Runner {
+	r := &Runner{wasm: newWASMCache()}
+	r.Configure(cfg)
+	return r
+}
+
+// Configure replaces the hooks. WASM modules are compiled again only when their file
+// changed.
+func (r *Runner) Configure(cfg []config.Hook) {
+	hooks := make([]*hook, 0, len(cfg))
+	modules := make(map[string]struct{})
+	for i := range cfg {
+		entry := cfg[i]
+		h := &hook{
+			name:     entry.Name,
+			stage:    entry.Stage,
+			paths:    entry.Paths,
+			timeout:  entry.Timeout,
+			failOpen: entry.FailOpen,
+		}
+		if h.timeout <= 0 {
+			h.timeout = defaultTimeout
+		}
+		if entry.WASM != "" {
+			module, err := r.wasm.load(entry.WASM)
+			if err != nil {
+				log.Warnf("hooks: skipping hook %s: %v", entry.Name, err)
+				continue
+			}
+			modules[entry.WASM] = struct{}{}
+			h.caller = module
+		} else {
+			h.caller = &httpCaller{url: entry.URL, headers: entry.Headers, client: &http.Client{}}
+		}
+		hooks = append(hooks, h)
+	}
+	r.wasm.retain(modules)
+	r.mu.Lock()
+	r.hooks = hooks
+	r.mu.Unlock()
+}
+
+// Enabled reports whether any hook of stage applies to path.
+func (r *Runner) Enabled(stage, path string) bool {
+	return len(r.chain(stage, path)) > 0
+}
+
+func (r *Runner) chain(stage, path string) []*hook {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var out []*hook
+	for _, h := range r.hooks {
+		if h.stage == stage && h.matches(path) {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// Run calls the hooks of the event's stage in order. Each hook sees the body and headers
+// as changed by the previous ones, and the changes are applied to event. It returns a
+// *Rejection when a hook rejected the event and an *UnavailableError when a hook failed
+// and does not fail open.
+func (r *Runner) Run(ctx context.Context, event *Event) (*Rejection, error) {
+	for _, h := range r.chain(event.Stage, event.Path) {
+		result, err := h.run(ctx, event)
+		if err != nil {
+			if h.failOpen {
+				log.Warnf("hooks: %s hook %s failed, continuing: %v", event.Stage, h.name, err)
+				continue
+			}
+			return nil, &UnavailableError{Hook: h.name, Err: err}
+		}
+		if strings.EqualFold(result.Action, "reject") {
+			status := result.Status
+			if status < 400 || status > 599 {
+				status = http.StatusForbidden
+			}
+			message := result.Message
+			if message == "" {
+				message = "rejected by hook " + h.name
+			}
+			return &Rejection{Hook: h.name, Status: status, Message: message}, nil
+		}
+		if event.Streamed {
+			continue
+		}
+		headers := &event.Headers
+		if event.Stage == StagePostResponse {
+			headers = &event.ResponseHeaders
+		}
+		if *headers == nil && len(result.Headers) > 0 {
+			*headers = make(map[string]string, len(result.Headers))
+		}
+		for name, value := range result.Headers {
+			name = http.CanonicalHeaderKey(name)
+			if value == "" {
+				delete(*headers, name)
+				continue
+			}
+			(*headers)[name] = value
+		}
+		if len(result.Body) > 0 {
+			if event.Stage == StagePostResponse {
+				event.ResponseBody = result.Body
+			} else {
+				event.Body = result.Body
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (h *hook) run(ctx context.Context, event *Event) (Result, error) {
+	input, err := json.Marshal(event)
+	if err != nil {
+		return Result{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+	output, err := h.caller.call(ctx, input)
+	if err != nil {
+		return Result{}, err
+	}
+	var result Result
+	if len(bytes.TrimSpace(output)) == 0 {
+		return result, nil
+	}
+	if err = json.Unmarshal(output, &result); err != nil {
+		return Result{}, fmt.Errorf("invalid result: %w", err)
+	}
+	if len(result.Body) > 0 && !json.Valid(result.Body) {
+		return Result{}, fmt.Errorf("invalid body in result")
+	}
+	return result, nil
+}
+
+// httpCaller posts events to an HTTP endpoint.
+type httpCaller struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func (c *httpCaller) call(ctx context.Context, input []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(input))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range c.headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResultSize))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("endpoint returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
+
+// maxResultSize bounds the result read from a hook.
+const maxResultSize = 32 << 20
+
+// JSONValue returns data as a JSON value: data itself when it is valid JSON, otherwise
+// data as a JSON string.
+func JSONValue(data []byte) json.RawMessage {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	if json.Valid(data) {
+		return data
+	}
+	encoded, _ := json.Marshal(string(data))
+	return encoded
+}
+
+// FlattenHeaders joins repeated header values with ", ".
+func FlattenHeaders(header http.Header) map[string]string {
+	out := make(map[string]string, len(header))
+	for name, values := range header {
+		out[name] = strings.Join(values, ", ")
+	}
+	return out
+}
@@ -0,0 +1,140 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// wasmMemoryLimitPages caps the memory of a WASM hook at 256 MiB.
+const wasmMemoryLimitPages = 4096
+
+// wasmCache compiles WASM hook modules once per file version. Modules are WASI command
+// modules: each call instantiates the module, which reads the event from standard input
+// and writes the result to standard output before it exits.
+type wasmCache struct {
+	mu      sync.Mutex
+	runtime wazero.Runtime
+	modules map[string]*wasmModule
+}
+
+type wasmModule struct {
+	path     string
+	size     int64
+	modTime  time.Time
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+}
+
+func newWASMCache() *wasmCache {
+	return &wasmCache{modules: make(map[string]*wasmModule)}
+}
+
+// load returns the compiled module of path, compiling it when the file is new or changed.
+func (c *wasmCache) load(path string) (*wasmModule, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if module, ok := c.modules[path]; ok && module.size == info.Size() && module.modTime.Equal(info.ModTime()) {
+		return module, nil
+	}
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	if c.runtime == nil {
+		runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+			WithCloseOnContextDone(true).
+			WithMemoryLimitPages(wasmMemoryLimitPages))
+		if _, err = wasi_snapshot_preview1.Instantiate(ctx, runtime); err != nil {
+			_ = runtime.Close(ctx)
+			return nil, err
+		}
+		c.runtime = runtime
+	}
+	compiled, err := c.runtime.CompileModule(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("compile %s: %w", path, err)
+	}
+	if previous, ok := c.modules[path]; ok {
+		_ = previous.compiled.Close(ctx)
+	}
+	module := &wasmModule{path: path, size: info.Size(), modTime: info.ModTime(), runtime: c.runtime, compiled: compiled}
+	c.modules[path] = module
+	return module, nil
+}
+
+// retain releases the modules whose path is not in paths.
+func (c *wasmCache) retain(paths map[string]struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for path, module := range c.modules {
+		if _, ok := paths[path]; ok {
+			continue
+		}
+		_ = module.compiled.Close(context.Background())
+		delete(c.modules, path)
+	}
+}
+
+func (m *wasmModule) call(ctx context.Context, input []byte) ([]byte, error) {
+	stdout := &limitedBuffer{limit: maxResultSize}
+	var stderr bytes.Buffer
+	config := wazero.NewModuleConfig().
+		WithName("").
+		WithStdin(bytes.NewReader(input)).
+		WithStdout(stdout).
+		WithStderr(&stderr).
+		WithSysWalltime().
+		WithSysNanotime()
+	instance, err := m.runtime.InstantiateModule(ctx, m.compiled, config)
+	if instance != nil {
+		_ = instance.Close(context.Background())
+	}
+	if text := strings.TrimSpace(stderr.String()); text != "" {
+		log.Debugf("hooks: %s: %s", m.path, text)
+	}
+	var exitErr *sys.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 0 {
+		err = nil
+	}
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, err
+	}
+	if stdout.exceeded {
+		return nil, fmt.Errorf("result exceeds %d bytes", maxResultSize)
+	}
+	return stdout.Bytes(), nil
+}
+
+// limitedBuffer is a buffer that drops writes beyond limit.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit    int
+	exceeded bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.limit {
+		b.exceeded = true
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
@@ -0,0 +1,191 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// AccessRecord is the structured entry emitted once per proxied request.
+type AccessRecord struct {
+	Time            time.Time `json:"time"`
+	RequestID       string    `json:"request_id,omitempty"`
+	Method          string    `json:"method"`
+	Path            string    `json:"path"`
+	Status          int       `json:"status"`
+	LatencyMS       int64     `json:"latency_ms"`
+	ClientIP        string    `json:"client_ip,omitempty"`
+	ClientKey       string    `json:"client_key,omitempty"`
+	Model           string    `json:"model,omitempty"`
+	Provider        string    `json:"provider,omitempty"`
+	Account         string    `json:"account,omitempty"`
+	AuthID          string    `json:"auth_id,omitempty"`
+	InputTokens     int64     `json:"input_tokens,omitempty"`
+	OutputTokens    int64     `json:"output_tokens,omitempty"`
+	ReasoningTokens int64     `json:"reasoning_tokens,omitempty"`
+	CachedTokens    int64     `json:"cached_tokens,omitempty"`
+	TotalTokens     int64     `json:"total_tokens,omitempty"`
+	UpstreamFailed  bool      `json:"upstream_failed,omitempty"`
+}
+
+// AccessSink receives encoded access records, one JSON document per call.
+type AccessSink interface {
+	Write(line []byte) error
+	Close() error
+}
+
+// AccessLogger serialises access records to the configured sink.
+// The sink can be swapped at runtime when the configuration changes.
+type AccessLogger struct {
+	mu   sync.RWMutex
+	sink AccessSink
+}
+
+// NewAccessLogger creates an access logger for the given configuration.
+// A disabled configuration yields a logger that drops every record.
+func NewAccessLogger(cfg config.AccessLogConfig) (*AccessLogger, error) {
+	l := &AccessLogger{}
+	if err := l.Configure(cfg); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Configure replaces the active sink according to cfg, closing the previous one.
+func (l *AccessLogger) Configure(cfg config.AccessLogConfig) error {
+	var sink AccessSink
+	if cfg.Enable {
+		var err error
+		sink, err = newAccessSink(cfg)
+		if err != nil {
+			return err
+		}
+	}
+
+	l.mu.Lock()
+	previous := l.sink
+	l.sink = sink
+	l.mu.Unlock()
+
+	if previous != nil {
+		_ = previous.Close()
+	}
+	return nil
+}
+
+// IsEnabled reports whether records are currently written anywhere.
+func (l *AccessLogger) IsEnabled() bool {
+	if l == nil {
+		return false
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.sink != nil
+}
+
+// Log encodes and writes a single access record.
+func (l *AccessLogger) Log(record AccessRecord) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.sink == nil {
+		return nil
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("access log: encode record: %w", err)
+	}
+	if err = l.sink.Write(line); err != nil {
+		return fmt.Errorf("access log: %w", err)
+	}
+	return nil
+}
+
+// Close releases the active sink.
+func (l *AccessLogger) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	sink := l.sink
+	l.sink = nil
+	l.mu.Unlock()
+	if sink == nil {
+		return nil
+	}
+	return sink.Close()
+}
+
+func newAccessSink(cfg config.AccessLogConfig) (AccessSink, error) {
+	switch strings.ToLower(strings.TrimSpace(cfg.Sink)) {
+	case "", "stdout":
+		return &writerSink{w: os.Stdout}, nil
+	case "file":
+		path := strings.TrimSpace(cfg.Path)
+		if path == "" {
+			path = filepath.Join("logs", "access.log")
+			if base := util.WritablePath(); base != "" {
+				path = filepath.Join(base, path)
+			}
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, fmt.Errorf("access log: failed to create log directory: %w", err)
+		}
+		maxSize := cfg.MaxSizeMB
+		if maxSize <= 0 {
+			maxSize = 100
+		}
+		return &writerSink{w: &lumberjack.Logger{
+			Filename:   path,
+			MaxSize:    maxSize,
+			MaxBackups: cfg.MaxBackups,
+			MaxAge:     cfg.MaxAgeDays,
+			Compress:   cfg.Compress,
+		}}, nil
+	case "syslog":
+		tag := strings.TrimSpace(cfg.SyslogTag)
+		if tag == "" {
+			tag = "cli-proxy-api"
+		}
+		sink, err := newSyslogSink(cfg.SyslogNetwork, cfg.SyslogAddress, tag)
+		if err != nil {
+			return nil, fmt.Errorf("access log: %w", err)
+		}
+		return sink, nil
+	default:
+		return nil, fmt.Errorf("access log: unsupported sink %q", cfg.Sink)
+	}
+}
+
+// writerSink writes newline-delimited records to an io.Writer.
+type writerSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *writerSink) Write(line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.w.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("write record: %w", err)
+	}
+	return nil
+}
+
+func (s *writerSink) Close() error {
+	if closer, ok := s.w.(io.Closer); ok && s.w != os.Stdout {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build windows || plan9
+
+package logging
+
+import "errors"
+
+func newSyslogSink(_, _, _ string) (AccessSink, error) {
+	return nil, errors.New("syslog sink is not supported on this platform")
+}
@@ -0,0 +1,226 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+// defaultAuditEntries is the number of entries kept in memory when max-entries is unset.
+const defaultAuditEntries = 1000
+
+// AuditEntry records one change made through the management API.
+type AuditEntry struct {
+	ID     uint64     `json:"id"`
+	Time   time.Time  `json:"time"`
+	Actor  AuditActor `json:"actor"`
+	Action string     `json:"action"`
+	// Error is set when the change was rejected or failed.
+	Error   string        `json:"error,omitempty"`
+	Changes []AuditChange `json:"changes,omitempty"`
+}
+
+// AuditActor identifies who made a change. The management API has no user accounts, so
+// the caller is described by the credential it presented and where it connected from.
+type AuditActor struct {
+	// Credential is "secret-key", "env-password" (MANAGEMENT_PASSWORD), "local-password"
+	// or "token:<name>" for a management token.
+	Credential string `json:"credential"`
+	Role       string `json:"role,omitempty"`
+	Transport  string `json:"transport"`
+	ClientIP   string `json:"client_ip,omitempty"`
+	UserAgent  string `json:"user_agent,omitempty"`
+}
+
+// AuditChange is a single setting before and after a change. A missing Before means the
+// setting was added, a missing After that it was removed.
+type AuditChange struct {
+	Path   string `json:"path"`
+	Before any    `json:"before,omitempty"`
+	After  any    `json:"after,omitempty"`
+}
+
+// AuditQuery filters the entries returned by AuditLogger.Query.
+type AuditQuery struct {
+	Since time.Time
+	// Action matches entries whose action contains it.
+	Action string
+	Limit  int
+}
+
+// AuditLogger keeps recent audit entries in memory and appends every entry to the
+// configured sink. The sink can be swapped at runtime when the configuration changes.
+type AuditLogger struct {
+	mu         sync.RWMutex
+	enabled    bool
+	sink       AccessSink
+	entries    []AuditEntry
+	maxEntries int
+	nextID     uint64
+}
+
+// NewAuditLogger creates an audit logger for the given configuration.
+// A disabled configuration yields a logger that drops every entry.
+func NewAuditLogger(cfg config.AuditLogConfig) (*AuditLogger, error) {
+	l := &AuditLogger{}
+	if err := l.Configure(cfg); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Configure applies cfg, replacing the sink and closing the previous one. Entries kept in
+// memory survive reconfiguration.
+func (l *AuditLogger) Configure(cfg config.AuditLogConfig) error {
+	var sink AccessSink
+	if cfg.Enable {
+		var err error
+		sink, err = newAuditSink(cfg)
+		if err != nil {
+			return err
+		}
+	}
+	maxEntries := cfg.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = defaultAuditEntries
+	}
+
+	l.mu.Lock()
+	previous := l.sink
+	l.enabled = cfg.Enable
+	l.sink = sink
+	l.maxEntries = maxEntries
+	if len(l.entries) > maxEntries {
+		l.entries = append([]AuditEntry(nil), l.entries[len(l.entries)-maxEntries:]...)
+	}
+	l.mu.Unlock()
+
+	if previous != nil {
+		_ = previous.Close()
+	}
+	return nil
+}
+
+// IsEnabled reports whether changes are currently recorded.
+func (l *AuditLogger) IsEnabled() bool {
+	if l == nil {
+		return false
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.enabled
+}
+
+// Record stamps entry with an ID and time, keeps it in memory and writes it to the sink.
+func (l *AuditLogger) Record(entry AuditEntry) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.enabled {
+		return nil
+	}
+	l.nextID++
+	entry.ID = l.nextID
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	l.entries = append(l.entries, entry)
+	if len(l.entries) > l.maxEntries {
+		l.entries = l.entries[len(l.entries)-l.maxEntries:]
+	}
+	if l.sink == nil {
+		return nil
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("audit log: encode entry: %w", err)
+	}
+	if err = l.sink.Write(line); err != nil {
+		return fmt.Errorf("audit log: %w", err)
+	}
+	return nil
+}
+
+// Query returns the entries kept in memory that match q, newest first.
+func (l *AuditLogger) Query(q AuditQuery) []AuditEntry {
+	result := make([]AuditEntry, 0)
+	if l == nil {
+		return result
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		entry := l.entries[i]
+		if !q.Since.IsZero() && entry.Time.Before(q.Since) {
+			break
+		}
+		if q.Action != "" && !strings.Contains(entry.Action, q.Action) {
+			continue
+		}
+		result = append(result, entry)
+		if q.Limit > 0 && len(result) == q.Limit {
+			break
+		}
+	}
+	return result
+}
+
+// Close releases the active sink.
+func (l *AuditLogger) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	sink := l.sink
+	l.sink = nil
+	l.mu.Unlock()
+	if sink == nil {
+		return nil
+	}
+	return sink.Close()
+}
+
+func newAuditSink(cfg config.AuditLogConfig) (AccessSink, error) {
+	switch strings.ToLower(strings.TrimSpace(cfg.Sink)) {
+	case "":
+		return nil, nil
+	case "file":
+		path := strings.TrimSpace(cfg.Path)
+		if path == "" {
+			path = filepath.Join("logs", "audit.log")
+			if base := util.WritablePath(); base != "" {
+				path = filepath.Join(base, path)
+			}
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, fmt.Errorf("audit log: failed to create log directory: %w", err)
+		}
+		// The audit trail is append-only and never rotated by the proxy.
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("audit log: failed to open log file: %w", err)
+		}
+		return &writerSink{w: f}, nil
+	case "syslog":
+		tag := strings.TrimSpace(cfg.SyslogTag)
+		if tag == "" {
+			tag = "cli-proxy-api-audit"
+		}
+		sink, err := newSyslogSink(cfg.SyslogNetwork, cfg.SyslogAddress, tag)
+		if err != nil {
+			return nil, fmt.Errorf("audit log: %w", err)
+		}
+		return sink, nil
+	default:
+		return nil, fmt.Errorf("audit log: unsupported sink %q", cfg.Sink)
+	}
+}
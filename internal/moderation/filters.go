@@ -0,0 +1,154 @@
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	defaultReplacement     = "[REDACTED]"
+	defaultEndpointTimeout = 10 * time.Second
+)
+
+// blocklistFilter matches a regular expression against the text of requests.
+type blocklistFilter struct {
+	name        string
+	pattern     *regexp.Regexp
+	redact      bool
+	replacement string
+}
+
+func newBlocklistFilter(index int, rule config.ModerationRule) (*blocklistFilter, error) {
+	pattern, err := regexp.Compile(rule.Pattern)
+	if err != nil {
+		return nil, err
+	}
+	filter := &blocklistFilter{
+		name:        rule.Name,
+		pattern:     pattern,
+		redact:      rule.Action == config.ModerationRedact,
+		replacement: rule.Replacement,
+	}
+	if filter.name == "" {
+		filter.name = fmt.Sprintf("blocklist-%d", index+1)
+	}
+	if filter.replacement == "" {
+		filter.replacement = defaultReplacement
+	}
+	return filter, nil
+}
+
+func (f *blocklistFilter) Name() string { return f.name }
+
+func (f *blocklistFilter) Check(_ context.Context, texts []string) (Decision, error) {
+	matched := false
+	redacted := texts
+	for i, text := range texts {
+		if !f.pattern.MatchString(text) {
+			continue
+		}
+		if !f.redact {
+			return Decision{Action: ActionReject, Reason: "content matches blocklist rule " + f.name}, nil
+		}
+		if !matched {
+			redacted = append([]string(nil), texts...)
+			matched = true
+		}
+		redacted[i] = f.pattern.ReplaceAllLiteralString(text, f.replacement)
+	}
+	if !matched {
+		return Decision{Action: ActionAllow}, nil
+	}
+	return Decision{Action: ActionRedact, Reason: "content redacted by blocklist rule " + f.name, Texts: redacted}, nil
+}
+
+// endpointFilter sends the text of requests to an OpenAI compatible moderation endpoint
+// and rejects requests it flags.
+type endpointFilter struct {
+	cfg    config.ModerationEndpoint
+	client *http.Client
+}
+
+func newEndpointFilter(cfg config.ModerationEndpoint) *endpointFilter {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultEndpointTimeout
+	}
+	return &endpointFilter{cfg: cfg, client: &http.Client{Timeout: timeout}}
+}
+
+func (f *endpointFilter) Name() string { return "endpoint" }
+
+func (f *endpointFilter) Check(ctx context.Context, texts []string) (Decision, error) {
+	payload := map[string]any{"input": texts}
+	if f.cfg.Model != "" {
+		payload["model"] = f.cfg.Model
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return Decision{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return Decision{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if f.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+f.cfg.APIKey)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return Decision{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return Decision{}, err
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return Decision{}, fmt.Errorf("moderation endpoint returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	results := gjson.GetBytes(data, "results")
+	if !results.IsArray() {
+		return Decision{}, fmt.Errorf("moderation endpoint returned no results")
+	}
+
+	flagged := false
+	categories := make(map[string]bool)
+	for _, result := range results.Array() {
+		if !result.Get("flagged").Bool() {
+			continue
+		}
+		flagged = true
+		result.Get("categories").ForEach(func(key, value gjson.Result) bool {
+			if value.Bool() {
+				categories[key.String()] = true
+			}
+			return true
+		})
+	}
+	if !flagged {
+		return Decision{Action: ActionAllow}, nil
+	}
+	reason := "content flagged by the moderation endpoint"
+	if len(categories) > 0 {
+		names := make([]string, 0, len(categories))
+		for name := range categories {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		reason += ": " + strings.Join(names, ", ")
+	}
+	return Decision{Action: ActionReject, Reason: reason}, nil
+}
@@ -0,0 +1,246 @@
+// Package moderation screens the text of requests before they are dispatched upstream. A
+// moderator runs a chain of filters, built-in regular expression blocklists, an external
+// moderation endpoint and any registered filter, each of which may allow, reject or redact
+// a request, and counts their decisions for the metrics endpoint.
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Action is the decision of a filter.
+type Action string
+
+// Filter decisions.
+const (
+	ActionAllow  Action = "allow"
+	ActionReject Action = "reject"
+	ActionRedact Action = "redact"
+	// ActionError is counted when a filter fails.
+	ActionError Action = "error"
+)
+
+// Decision is the outcome of a filter for the text of a request.
+type Decision struct {
+	Action Action
+	// Reason explains a rejection or redaction in logs and error responses.
+	Reason string
+	// Texts holds the redacted texts for ActionRedact, in the order they were checked.
+	Texts []string
+}
+
+// Filter checks the text of a request before it is dispatched.
+type Filter interface {
+	// Name identifies the filter in logs and metrics.
+	Name() string
+	// Check decides on the texts of a request: the message contents, prompts and
+	// instructions in the order they appear in the body.
+	Check(ctx context.Context, texts []string) (Decision, error)
+}
+
+var (
+	registeredMu      sync.RWMutex
+	registeredFilters []Filter
+)
+
+// RegisterFilter adds a filter that runs after the configured blocklist and endpoint
+// whenever moderation is enabled.
+func RegisterFilter(filter Filter) {
+	if filter == nil {
+		return
+	}
+	registeredMu.Lock()
+	registeredFilters = append(registeredFilters, filter)
+	registeredMu.Unlock()
+}
+
+// Verdict is the outcome of moderating a request.
+type Verdict struct {
+	// Action is ActionReject when a filter rejected the request, ActionRedact when at least
+	// one filter redacted it and ActionAllow otherwise.
+	Action Action
+	// Filter is the name of the filter that rejected the request, or the filters that
+	// redacted it.
+	Filter string
+	// Reason is the reason given by the deciding filters.
+	Reason string
+}
+
+// DecisionCount is the number of decisions a filter made with an action.
+type DecisionCount struct {
+	Filter string `json:"filter"`
+	Action Action `json:"action"`
+	Count  int64  `json:"count"`
+}
+
+// UnavailableError reports a filter failure while moderation does not fail open.
+type UnavailableError struct {
+	Filter string
+	Err    error
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("moderation filter %s failed: %v", e.Filter, e.Err)
+}
+
+func (e *UnavailableError) Unwrap() error { return e.Err }
+
+// Moderator runs the moderation filters. It is safe for concurrent use.
+type Moderator struct {
+	mu       sync.RWMutex
+	enabled  bool
+	failOpen bool
+	filters  []Filter
+
+	statsMu sync.Mutex
+	stats   map[[2]string]int64
+}
+
+// New creates a moderator for the configured filters.
+func New(cfg config.Moderation) *Moderator {
+	m := &Moderator{stats: make(map[[2]string]int64)}
+	m.Configure(cfg)
+	return m
+}
+
+// Configure replaces the configured filters. Registered filters are kept.
+func (m *Moderator) Configure(cfg config.Moderation) {
+	filters := make([]Filter, 0, len(cfg.Blocklist)+1)
+	for i, rule := range cfg.Blocklist {
+		filter, err := newBlocklistFilter(i, rule)
+		if err != nil {
+			log.Warnf("moderation: skipping blocklist rule %d: %v", i+1, err)
+			continue
+		}
+		filters = append(filters, filter)
+	}
+	if cfg.Endpoint.URL != "" {
+		filters = append(filters, newEndpointFilter(cfg.Endpoint))
+	}
+	m.mu.Lock()
+	m.enabled = cfg.Enable
+	m.failOpen = cfg.Endpoint.FailOpen
+	m.filters = filters
+	m.mu.Unlock()
+}
+
+// Enabled reports whether moderation is turned on.
+func (m *Moderator) Enabled() bool {
+	if m == nil {
+		return false
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.enabled
+}
+
+func (m *Moderator) chain() ([]Filter, bool) {
+	m.mu.RLock()
+	filters := append([]Filter(nil), m.filters...)
+	failOpen := m.failOpen
+	m.mu.RUnlock()
+	registeredMu.RLock()
+	filters = append(filters, registeredFilters...)
+	registeredMu.RUnlock()
+	return filters, failOpen
+}
+
+// Moderate runs the filters over the text of a JSON request body and returns the body to
+// forward, with redactions applied. It returns an *UnavailableError when a filter fails
+// and moderation does not fail open.
+func (m *Moderator) Moderate(ctx context.Context, body []byte) ([]byte, Verdict, error) {
+	verdict := Verdict{Action: ActionAllow}
+	if !m.Enabled() || !gjson.ValidBytes(body) {
+		return body, verdict, nil
+	}
+	fields := util.RequestTexts(body)
+	if len(fields) == 0 {
+		return body, verdict, nil
+	}
+	texts := make([]string, len(fields))
+	for i, field := range fields {
+		texts[i] = field.Text
+	}
+
+	filters, failOpen := m.chain()
+	var redactedBy, reasons []string
+	for _, filter := range filters {
+		decision, err := filter.Check(ctx, texts)
+		if err != nil {
+			m.count(filter.Name(), ActionError)
+			if failOpen {
+				log.Warnf("moderation: filter %s failed, forwarding the request: %v", filter.Name(), err)
+				continue
+			}
+			return body, verdict, &UnavailableError{Filter: filter.Name(), Err: err}
+		}
+		switch decision.Action {
+		case ActionReject:
+			m.count(filter.Name(), ActionReject)
+			return body, Verdict{Action: ActionReject, Filter: filter.Name(), Reason: decision.Reason}, nil
+		case ActionRedact:
+			if len(decision.Texts) != len(texts) {
+				m.count(filter.Name(), ActionError)
+				log.Warnf("moderation: filter %s returned %d texts for %d, ignoring its redaction", filter.Name(), len(decision.Texts), len(texts))
+				continue
+			}
+			m.count(filter.Name(), ActionRedact)
+			texts = decision.Texts
+			redactedBy = append(redactedBy, filter.Name())
+			if decision.Reason != "" {
+				reasons = append(reasons, decision.Reason)
+			}
+		default:
+			m.count(filter.Name(), ActionAllow)
+		}
+	}
+	if len(redactedBy) == 0 {
+		return body, verdict, nil
+	}
+
+	for i, field := range fields {
+		if texts[i] == field.Text {
+			continue
+		}
+		if updated, err := sjson.SetBytes(body, field.Path, texts[i]); err == nil {
+			body = updated
+		}
+	}
+	return body, Verdict{Action: ActionRedact, Filter: strings.Join(redactedBy, ", "), Reason: strings.Join(reasons, "; ")}, nil
+}
+
+func (m *Moderator) count(filter string, action Action) {
+	m.statsMu.Lock()
+	m.stats[[2]string{filter, string(action)}]++
+	m.statsMu.Unlock()
+}
+
+// Stats returns the decisions made since startup, ordered by filter and action.
+func (m *Moderator) Stats() []DecisionCount {
+	if m == nil {
+		return nil
+	}
+	m.statsMu.Lock()
+	out := make([]DecisionCount, 0, len(m.stats))
+	for key, count := range m.stats {
+		out = append(out, DecisionCount{Filter: key[0], Action: Action(key[1]), Count: count})
+	}
+	m.statsMu.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Filter != out[j].Filter {
+			return out[i].Filter < out[j].Filter
+		}
+		return out[i].Action < out[j].Action
+	})
+	return out
+}
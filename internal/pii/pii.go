@@ -0,0 +1,236 @@
+// Package pii masks personal data in prompts before they are sent upstream. Detected values
+// are replaced with numbered placeholders such as [EMAIL_1], and the mapping of a request
+// restores the original values in its response.
+package pii
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/sjson"
+)
+
+// detector finds one kind of personal data.
+type detector struct {
+	label   string
+	pattern *regexp.Regexp
+	// valid rejects matches that only look like the data, such as card numbers failing the
+	// Luhn check.
+	valid func(match string) bool
+}
+
+var builtinDetectors = map[string]detector{
+	config.PIIDetectorCreditCard: {
+		label:   "CREDIT_CARD",
+		pattern: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+		valid:   luhnValid,
+	},
+	config.PIIDetectorEmail: {
+		label:   "EMAIL",
+		pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`),
+	},
+	config.PIIDetectorPhone: {
+		label:   "PHONE",
+		pattern: regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?(?:\(\d{2,4}\)[ .-]?|\b\d{2,4}[ .-])\d{3,4}[ .-]?\d{3,4}\b`),
+	},
+}
+
+// builtinOrder runs card numbers before phone numbers, whose pattern also matches parts of
+// card numbers.
+var builtinOrder = []string{config.PIIDetectorCreditCard, config.PIIDetectorEmail, config.PIIDetectorPhone}
+
+// Redactor masks personal data in request bodies. It is safe for concurrent use.
+type Redactor struct {
+	mu        sync.RWMutex
+	enabled   bool
+	restore   bool
+	detectors []detector
+}
+
+// New creates a redactor for the configured detectors.
+func New(cfg config.PIIRedaction) *Redactor {
+	r := &Redactor{}
+	r.Configure(cfg)
+	return r
+}
+
+// Configure replaces the configured detectors.
+func (r *Redactor) Configure(cfg config.PIIRedaction) {
+	detectors := make([]detector, 0, len(cfg.Patterns)+len(builtinOrder))
+	// Custom patterns run first so that they take precedence over the built-in detectors.
+	for _, custom := range cfg.Patterns {
+		pattern, err := regexp.Compile(custom.Pattern)
+		if err != nil {
+			log.Warnf("pii redaction: skipping pattern %s: %v", custom.Name, err)
+			continue
+		}
+		detectors = append(detectors, detector{label: custom.Name, pattern: pattern})
+	}
+	selected := make(map[string]bool, len(cfg.Detectors))
+	for _, name := range cfg.Detectors {
+		selected[name] = true
+	}
+	for _, name := range builtinOrder {
+		if len(selected) == 0 || selected[name] {
+			detectors = append(detectors, builtinDetectors[name])
+		}
+	}
+	r.mu.Lock()
+	r.enabled = cfg.Enable
+	r.restore = !cfg.KeepPlaceholders
+	r.detectors = detectors
+	r.mu.Unlock()
+}
+
+// Enabled reports whether PII redaction is turned on.
+func (r *Redactor) Enabled() bool {
+	if r == nil {
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.enabled
+}
+
+// Restores reports whether placeholders are restored in responses.
+func (r *Redactor) Restores() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.restore
+}
+
+// Redact masks the personal data in the text fields of a JSON request body. Equal values
+// share a placeholder. It returns the body to forward and the mapping of its placeholders,
+// which is empty when nothing was found.
+func (r *Redactor) Redact(body []byte) ([]byte, *Mapping) {
+	mapping := &Mapping{originals: make(map[string]string), placeholders: make(map[string]string), counts: make(map[string]int)}
+	if !r.Enabled() {
+		return body, mapping
+	}
+	r.mu.RLock()
+	detectors := r.detectors
+	r.mu.RUnlock()
+
+	for _, field := range util.RequestTexts(body) {
+		text := field.Text
+		for _, d := range detectors {
+			text = d.pattern.ReplaceAllStringFunc(text, func(match string) string {
+				if d.valid != nil && !d.valid(match) {
+					return match
+				}
+				return mapping.placeholder(d.label, match)
+			})
+		}
+		if text == field.Text {
+			continue
+		}
+		if updated, err := sjson.SetBytes(body, field.Path, text); err == nil {
+			body = updated
+		}
+	}
+	return body, mapping
+}
+
+// Mapping holds the placeholders of a request and the values they stand for.
+type Mapping struct {
+	// originals maps placeholders to values.
+	originals map[string]string
+	// placeholders maps values to placeholders.
+	placeholders map[string]string
+	// counts numbers the placeholders per label.
+	counts map[string]int
+}
+
+func (m *Mapping) placeholder(label, value string) string {
+	if placeholder, ok := m.placeholders[value]; ok {
+		return placeholder
+	}
+	m.counts[label]++
+	placeholder := fmt.Sprintf("[%s_%d]", label, m.counts[label])
+	m.placeholders[value] = placeholder
+	m.originals[placeholder] = value
+	return placeholder
+}
+
+// Len returns the number of masked values.
+func (m *Mapping) Len() int {
+	if m == nil {
+		return 0
+	}
+	return len(m.originals)
+}
+
+// Restore replaces the placeholders in a JSON response body with the values they stand for,
+// escaped for JSON strings. Streams are restored with a StreamRestorer, which also restores
+// placeholders split across events.
+func (m *Mapping) Restore(data []byte) []byte {
+	if m.Len() == 0 || !bytes.Contains(data, []byte("[")) {
+		return data
+	}
+	for placeholder, value := range m.originals {
+		if !bytes.Contains(data, []byte(placeholder)) {
+			continue
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			continue
+		}
+		data = bytes.ReplaceAll(data, []byte(placeholder), encoded[1:len(encoded)-1])
+	}
+	return data
+}
+
+// RestoreText replaces the placeholders in decoded text with the values they stand for.
+func (m *Mapping) RestoreText(text string) string {
+	if m.Len() == 0 || !strings.Contains(text, "[") {
+		return text
+	}
+	for placeholder, value := range m.originals {
+		text = strings.ReplaceAll(text, placeholder, value)
+	}
+	return text
+}
+
+// partialTail returns the length of the end of text that may be the start of a placeholder.
+func (m *Mapping) partialTail(text string) int {
+	start := strings.LastIndexByte(text, '[')
+	if start < 0 || strings.IndexByte(text[start:], ']') >= 0 {
+		return 0
+	}
+	for placeholder := range m.originals {
+		if strings.HasPrefix(placeholder, text[start:]) {
+			return len(text) - start
+		}
+	}
+	return 0
+}
+
+// luhnValid reports whether the digits of a card number candidate pass the Luhn check.
+func luhnValid(candidate string) bool {
+	sum, digits := 0, 0
+	double := false
+	for i := len(candidate) - 1; i >= 0; i-- {
+		c := candidate[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		n := int(c - '0')
+		if double {
+			n *= 2
+			if n > 9 {
+				n -= 9
+			}
+		}
+		sum += n
+		double = !double
+		digits++
+	}
+	return digits >= 13 && sum%10 == 0
+}
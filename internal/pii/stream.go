@@ -0,0 +1,202 @@
+package pii
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// StreamRestorer restores the placeholders in a server-sent event stream. Placeholders are
+// restored in the decoded text deltas of the events, and the start of a placeholder that the
+// model splits across events is held back until its remainder arrives.
+type StreamRestorer struct {
+	mapping *Mapping
+	pending []byte
+	held    map[string]*heldDelta
+	order   []string
+}
+
+// heldDelta is text held back from a delta, with the event it came from to resend it in.
+type heldDelta struct {
+	event  []byte
+	data   []byte
+	path   string
+	text   string
+	prefix []byte
+}
+
+// deltaText locates the text of a delta in an event. key identifies the output the delta
+// belongs to, so the deltas of interleaved outputs are kept apart.
+type deltaText struct {
+	key  string
+	path string
+	// last is set on the final event of the output, which must not hold text back.
+	last bool
+}
+
+// NewStreamRestorer returns a restorer for a streamed response to the request of m.
+func (m *Mapping) NewStreamRestorer() *StreamRestorer {
+	return &StreamRestorer{mapping: m, held: make(map[string]*heldDelta)}
+}
+
+// Write consumes stream data and returns the events it completed, restored.
+func (r *StreamRestorer) Write(data []byte) []byte {
+	r.pending = append(r.pending, data...)
+	var out []byte
+	for {
+		end := bytes.Index(r.pending, []byte("\n\n"))
+		if end < 0 {
+			return out
+		}
+		event := bytes.Clone(r.pending[:end+2])
+		r.pending = r.pending[end+2:]
+		out = append(out, r.restoreEvent(event)...)
+	}
+}
+
+// Flush returns the held text and any incomplete event at the end of the stream.
+func (r *StreamRestorer) Flush() []byte {
+	out := r.release()
+	out = append(out, r.mapping.Restore(r.pending)...)
+	r.pending = nil
+	return out
+}
+
+func (r *StreamRestorer) restoreEvent(event []byte) []byte {
+	lines := bytes.Split(event, []byte("\n"))
+	dataLine := -1
+	for i, line := range lines {
+		if bytes.HasPrefix(line, []byte("data:")) {
+			if dataLine >= 0 {
+				// Multi-line data is passed on as is.
+				return append(r.release(), r.mapping.Restore(event)...)
+			}
+			dataLine = i
+		}
+	}
+	if dataLine < 0 {
+		// Comments such as keep-alives.
+		return event
+	}
+	prefix, payload := splitDataLine(lines[dataLine])
+	if !gjson.ValidBytes(payload) {
+		return append(r.release(), r.mapping.Restore(event)...)
+	}
+	deltas := deltaTexts(payload)
+	if len(deltas) == 0 {
+		return append(r.release(), r.mapping.Restore(event)...)
+	}
+	payload = r.mapping.Restore(payload)
+	for _, delta := range deltas {
+		text := gjson.GetBytes(payload, delta.path).String()
+		if held, ok := r.held[delta.key]; ok {
+			text = held.text + text
+			r.drop(delta.key)
+		}
+		keep := len(text)
+		if !delta.last {
+			keep -= r.mapping.partialTail(text)
+		}
+		if keep < len(text) {
+			r.held[delta.key] = &heldDelta{event: event, data: bytes.Clone(payload), path: delta.path, text: text[keep:], prefix: prefix}
+			r.order = append(r.order, delta.key)
+		}
+		payload, _ = sjson.SetBytes(payload, delta.path, r.mapping.RestoreText(text[:keep]))
+	}
+	lines[dataLine] = append(append([]byte(nil), prefix...), payload...)
+	return bytes.Join(lines, []byte("\n"))
+}
+
+// release returns events carrying the text held back so far, in the order it was held.
+func (r *StreamRestorer) release() []byte {
+	var out []byte
+	for _, key := range r.order {
+		held, ok := r.held[key]
+		if !ok {
+			continue
+		}
+		payload, _ := sjson.SetBytes(held.data, held.path, held.text)
+		lines := bytes.Split(held.event, []byte("\n"))
+		for i, line := range lines {
+			if bytes.HasPrefix(line, []byte("data:")) {
+				lines[i] = append(append([]byte(nil), held.prefix...), payload...)
+			}
+		}
+		out = append(out, bytes.Join(lines, []byte("\n"))...)
+	}
+	r.held = make(map[string]*heldDelta)
+	r.order = nil
+	return out
+}
+
+func (r *StreamRestorer) drop(key string) {
+	delete(r.held, key)
+	for i, held := range r.order {
+		if held == key {
+			r.order = append(r.order[:i], r.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// splitDataLine splits a data line into its "data:" prefix, with the space after it, and
+// its payload.
+func splitDataLine(line []byte) ([]byte, []byte) {
+	n := len("data:")
+	if len(line) > n && line[n] == ' ' {
+		n++
+	}
+	return line[:n], bytes.TrimRight(line[n:], "\r")
+}
+
+// deltaTexts returns the text deltas of an OpenAI chat completion, OpenAI Responses, Claude
+// or Gemini stream event.
+func deltaTexts(payload []byte) []deltaText {
+	var out []deltaText
+	root := gjson.ParseBytes(payload)
+	root.Get("choices").ForEach(func(i, choice gjson.Result) bool {
+		last := choice.Get("finish_reason").Type == gjson.String
+		base := "choices." + i.String() + ".delta."
+		if choice.Get("delta.content").Type == gjson.String {
+			out = append(out, deltaText{key: "choice:" + choice.Get("index").String(), path: base + "content", last: last})
+		}
+		choice.Get("delta.tool_calls").ForEach(func(j, call gjson.Result) bool {
+			if call.Get("function.arguments").Type == gjson.String {
+				key := "choice:" + choice.Get("index").String() + ":call:" + call.Get("index").String()
+				out = append(out, deltaText{key: key, path: base + "tool_calls." + j.String() + ".function.arguments", last: last})
+			}
+			return true
+		})
+		return true
+	})
+	root.Get("candidates").ForEach(func(i, candidate gjson.Result) bool {
+		last := candidate.Get("finishReason").Exists()
+		candidate.Get("content.parts").ForEach(func(j, part gjson.Result) bool {
+			if part.Get("text").Type == gjson.String {
+				key := "candidate:" + candidate.Get("index").String()
+				if part.Get("thought").Bool() {
+					key += ":thought"
+				}
+				out = append(out, deltaText{key: key, path: "candidates." + i.String() + ".content.parts." + j.String() + ".text", last: last})
+			}
+			return true
+		})
+		return true
+	})
+	switch eventType := root.Get("type").String(); {
+	case eventType == "content_block_delta":
+		for _, field := range []string{"text", "partial_json"} {
+			if root.Get("delta."+field).Type == gjson.String {
+				out = append(out, deltaText{key: "block:" + root.Get("index").String(), path: "delta." + field})
+			}
+		}
+	case strings.HasPrefix(eventType, "response.") && strings.HasSuffix(eventType, ".delta"):
+		if root.Get("delta").Type == gjson.String {
+			key := eventType + ":" + root.Get("item_id").String() + ":" + root.Get("content_index").String()
+			out = append(out, deltaText{key: key, path: "delta"})
+		}
+	}
+	return out
+}
@@ -0,0 +1,226 @@
+// Package project groups inbound API keys into projects, the tenants of a shared proxy.
+// A Registry resolves the project of a client key, decides which models the project may
+// use and enforces the quotas its keys share. Per-project routing is applied by the auth
+// selector and per-project usage is reported by filtering the statistics of its keys.
+package project
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/quota"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
+)
+
+// quotaKeyPrefix namespaces project counters so they never collide with API key counters
+// when both are kept in the same shared store.
+const quotaKeyPrefix = "project:"
+
+// Registry holds the configured projects and their shared quota counters.
+// It is safe for concurrent use and can be reconfigured at runtime.
+type Registry struct {
+	mu     sync.RWMutex
+	byName map[string]config.Project
+	byKey  map[string]string
+	quotas *quota.Manager
+}
+
+// NewRegistry creates a registry for the configured projects.
+func NewRegistry(projects []config.Project) *Registry {
+	r := &Registry{quotas: quota.NewManager(nil)}
+	r.SetProjects(projects)
+	return r
+}
+
+// SetProjects replaces the configured projects. Accumulated quota usage is preserved for
+// projects that keep their name. Projects without a name are ignored, and a key listed by
+// several projects belongs to the first one.
+func (r *Registry) SetProjects(projects []config.Project) {
+	byName := make(map[string]config.Project, len(projects))
+	byKey := make(map[string]string)
+	limits := make([]config.APIKeyQuota, 0, len(projects))
+	for _, p := range projects {
+		p.Name = strings.TrimSpace(p.Name)
+		if p.Name == "" {
+			continue
+		}
+		if _, exists := byName[p.Name]; exists {
+			log.Warnf("project %q is defined more than once, ignoring the duplicate", p.Name)
+			continue
+		}
+		byName[p.Name] = p
+		for _, key := range p.APIKeys {
+			key = strings.TrimSpace(key)
+			if key == "" {
+				continue
+			}
+			if owner, exists := byKey[key]; exists {
+				log.Warnf("api key %s is listed by projects %q and %q, keeping %q", util.HideAPIKey(key), owner, p.Name, owner)
+				continue
+			}
+			byKey[key] = p.Name
+		}
+		if q := p.Quota; q.DailyRequests > 0 || q.DailyTokens > 0 || q.MonthlyRequests > 0 || q.MonthlyTokens > 0 {
+			limits = append(limits, config.APIKeyQuota{
+				APIKey:          quotaKeyPrefix + p.Name,
+				DailyRequests:   q.DailyRequests,
+				DailyTokens:     q.DailyTokens,
+				MonthlyRequests: q.MonthlyRequests,
+				MonthlyTokens:   q.MonthlyTokens,
+			})
+		}
+	}
+	r.mu.Lock()
+	r.byName = byName
+	r.byKey = byKey
+	r.mu.Unlock()
+	r.quotas.SetLimits(limits)
+}
+
+// SetSharedCounters makes project quotas count consumption in shared instead of in memory.
+// It should be called before traffic is served.
+func (r *Registry) SetSharedCounters(shared quota.SharedCounters) {
+	r.quotas.SetSharedCounters(shared)
+}
+
+// Enabled reports whether any project is configured.
+func (r *Registry) Enabled() bool {
+	if r == nil {
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.byName) > 0
+}
+
+// ForKey returns the project the client key belongs to.
+func (r *Registry) ForKey(apiKey string) (config.Project, bool) {
+	if r == nil || apiKey == "" {
+		return config.Project{}, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	name, ok := r.byKey[apiKey]
+	if !ok {
+		return config.Project{}, false
+	}
+	return r.byName[name], true
+}
+
+// Get returns the project with the given name.
+func (r *Registry) Get(name string) (config.Project, bool) {
+	if r == nil {
+		return config.Project{}, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.byName[name]
+	return p, ok
+}
+
+// Names lists the configured projects, sorted for stable output.
+func (r *Registry) Names() []string {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.byName))
+	for name := range r.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Keys returns the set of client keys that belong to the named project.
+func (r *Registry) Keys(name string) map[string]struct{} {
+	keys := make(map[string]struct{})
+	if r == nil {
+		return keys
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for key, owner := range r.byKey {
+		if owner == name {
+			keys[key] = struct{}{}
+		}
+	}
+	return keys
+}
+
+// Admit checks the named project against its shared quota and, when allowed, counts the request.
+func (r *Registry) Admit(name string) quota.Decision {
+	if r == nil {
+		return quota.Decision{Allowed: true}
+	}
+	return r.quotas.Admit(quotaKeyPrefix + name)
+}
+
+// QuotaStatus returns the state of every quota limit of the named project.
+func (r *Registry) QuotaStatus(name string) []quota.Status {
+	if r == nil {
+		return nil
+	}
+	return r.quotas.Status(quotaKeyPrefix + name)
+}
+
+// HandleUsage implements coreusage.Plugin and adds the tokens consumed by a project key to
+// the counters of its project.
+func (r *Registry) HandleUsage(ctx context.Context, record coreusage.Record) {
+	p, ok := r.ForKey(record.APIKey)
+	if !ok {
+		return
+	}
+	record.APIKey = quotaKeyPrefix + p.Name
+	r.quotas.HandleUsage(ctx, record)
+}
+
+// Seed initialises the project quota counters from the aggregate usage buckets by
+// attributing the usage of every key to its current project. It should be called before traffic is served.
+func (r *Registry) Seed(buckets []usage.Aggregate) {
+	if r == nil {
+		return
+	}
+	merged := make([]usage.Aggregate, 0, len(buckets))
+	for _, bucket := range buckets {
+		p, ok := r.ForKey(bucket.API)
+		if !ok {
+			continue
+		}
+		bucket.API = quotaKeyPrefix + p.Name
+		merged = append(merged, bucket)
+	}
+	r.quotas.Seed(merged)
+}
+
+// ModelAllowed reports whether p may request model. Matching is case-insensitive and a
+// trailing "*" in an allowed entry matches by prefix.
+func ModelAllowed(p config.Project, model string) bool {
+	if len(p.AllowedModels) == 0 {
+		return true
+	}
+	model = strings.ToLower(strings.TrimSpace(model))
+	for _, allowed := range p.AllowedModels {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if allowed == "" {
+			continue
+		}
+		if prefix, wildcard := strings.CutSuffix(allowed, "*"); wildcard {
+			if strings.HasPrefix(model, prefix) {
+				return true
+			}
+			continue
+		}
+		if model == allowed {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+package prompts
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/guardrail"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sysprompt"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Fields of generation requests that reference a template.
+const (
+	templateField  = "metadata.prompt_template"
+	variablesField = "metadata.prompt_variables"
+)
+
+// Expand replaces the template reference of a request body in format with the rendered
+// messages of the template: its system messages are added before the system prompt of the
+// request and its other messages before the conversation. Variables are read from
+// metadata.prompt_variables, an object or a JSON-encoded object of strings. It returns the
+// name of the expanded template, empty when the request references none.
+func (l *Library) Expand(format guardrail.Format, body []byte) ([]byte, string, error) {
+	name := gjson.GetBytes(body, templateField).String()
+	if name == "" {
+		return body, "", nil
+	}
+	if format == guardrail.FormatOpenAICompletions {
+		return nil, name, invalid("prompt templates are not supported for legacy completions")
+	}
+	template, err := l.Get(name)
+	if err != nil {
+		return nil, name, invalid("prompt template %s does not exist", name)
+	}
+	values, err := variables(gjson.GetBytes(body, variablesField))
+	if err != nil {
+		return nil, name, err
+	}
+	messages, err := template.Render(values)
+	if err != nil {
+		return nil, name, err
+	}
+
+	for _, path := range []string{templateField, variablesField} {
+		if body, err = sjson.DeleteBytes(body, path); err != nil {
+			return nil, name, err
+		}
+	}
+	if metadata := gjson.GetBytes(body, "metadata"); metadata.IsObject() && len(metadata.Map()) == 0 {
+		if body, err = sjson.DeleteBytes(body, "metadata"); err != nil {
+			return nil, name, err
+		}
+	}
+
+	var system []string
+	var conversation []Message
+	for _, message := range messages {
+		if message.Role == "system" {
+			system = append(system, message.Content)
+		} else {
+			conversation = append(conversation, message)
+		}
+	}
+	if len(conversation) > 0 {
+		if body, err = prependConversation(format, body, conversation); err != nil {
+			return nil, name, err
+		}
+	}
+	if len(system) > 0 {
+		if body, err = sysprompt.AddToSystem(format, body, strings.Join(system, "\n\n"), ""); err != nil {
+			return nil, name, err
+		}
+	}
+	return body, name, nil
+}
+
+// variables reads the variables of a reference.
+func variables(value gjson.Result) (map[string]string, error) {
+	if value.Type == gjson.String {
+		value = gjson.Parse(value.String())
+	}
+	if !value.Exists() {
+		return nil, nil
+	}
+	if !value.IsObject() {
+		return nil, invalid("%s must be an object", variablesField)
+	}
+	values := make(map[string]string)
+	value.ForEach(func(key, entry gjson.Result) bool {
+		values[key.String()] = entry.String()
+		return true
+	})
+	return values, nil
+}
+
+// prependConversation adds the user and assistant messages of a template before the
+// conversation of a request body in format.
+func prependConversation(format guardrail.Format, body []byte, messages []Message) ([]byte, error) {
+	path := "messages"
+	switch format {
+	case guardrail.FormatOpenAIChat, guardrail.FormatClaude:
+	case guardrail.FormatOpenAIResponses:
+		path = "input"
+	case guardrail.FormatGemini:
+		path = "contents"
+	default:
+		return nil, invalid("prompt templates are not supported for this endpoint")
+	}
+	var raws []string
+	for _, message := range messages {
+		var item any = message
+		if format == guardrail.FormatGemini {
+			role := message.Role
+			if role == "assistant" {
+				role = "model"
+			}
+			item = map[string]any{"role": role, "parts": []map[string]string{{"text": message.Content}}}
+		}
+		raw, err := json.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+		raws = append(raws, string(raw))
+	}
+	existing := gjson.GetBytes(body, path)
+	switch {
+	case existing.IsArray():
+		for _, entry := range existing.Array() {
+			raws = append(raws, entry.Raw)
+		}
+	case existing.Type == gjson.String && format == guardrail.FormatOpenAIResponses:
+		raw, err := json.Marshal(Message{Role: "user", Content: existing.String()})
+		if err != nil {
+			return nil, err
+		}
+		raws = append(raws, string(raw))
+	}
+	return sjson.SetRawBytes(body, path, []byte("["+strings.Join(raws, ",")+"]"))
+}
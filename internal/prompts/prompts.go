@@ -0,0 +1,306 @@
+// Package prompts keeps a library of named prompt templates: messages with {{variable}}
+// placeholders that generation requests reference by name and that are expanded into the
+// request before it is dispatched. Each template is stored as a JSON file.
+package prompts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrNotFound is returned for templates that do not exist.
+var ErrNotFound = errors.New("prompt template not found")
+
+// ValidationError reports a template or reference that cannot be used.
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string { return e.Message }
+
+func invalid(format string, args ...any) error {
+	return &ValidationError{Message: fmt.Sprintf(format, args...)}
+}
+
+var (
+	namePattern     = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+	variablePattern = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}`)
+)
+
+// roles are the message roles a template may use.
+var roles = map[string]bool{"system": true, "user": true, "assistant": true}
+
+// Message is one message of a template.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Template is a named prompt. Variables holds the defaults of its placeholders;
+// placeholders without a default must be given by each request.
+type Template struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Messages    []Message         `json:"messages"`
+	Variables   map[string]string `json:"variables,omitempty"`
+	Version     int               `json:"version"`
+	CreatedAt   int64             `json:"created_at"`
+	UpdatedAt   int64             `json:"updated_at"`
+}
+
+// Placeholders returns the names of the placeholders the messages of t use, in order of
+// first use.
+func (t *Template) Placeholders() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, message := range t.Messages {
+		for _, match := range variablePattern.FindAllStringSubmatch(message.Content, -1) {
+			if !seen[match[1]] {
+				seen[match[1]] = true
+				names = append(names, match[1])
+			}
+		}
+	}
+	return names
+}
+
+// Render returns the messages of t with their placeholders replaced by values or the
+// defaults of t. Placeholders with neither are an error.
+func (t *Template) Render(values map[string]string) ([]Message, error) {
+	var missing []string
+	for _, name := range t.Placeholders() {
+		if _, ok := values[name]; ok {
+			continue
+		}
+		if _, ok := t.Variables[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, invalid("prompt template %s: missing variables %s", t.Name, strings.Join(missing, ", "))
+	}
+	out := make([]Message, 0, len(t.Messages))
+	for _, message := range t.Messages {
+		content := variablePattern.ReplaceAllStringFunc(message.Content, func(match string) string {
+			name := variablePattern.FindStringSubmatch(match)[1]
+			if value, ok := values[name]; ok {
+				return value
+			}
+			return t.Variables[name]
+		})
+		out = append(out, Message{Role: message.Role, Content: content})
+	}
+	return out, nil
+}
+
+// PutRequest is the body of a template creation or replacement.
+type PutRequest struct {
+	Description string            `json:"description"`
+	Messages    []Message         `json:"messages"`
+	Variables   map[string]string `json:"variables"`
+}
+
+// Library stores the templates in memory and in a directory. It is safe for concurrent use.
+type Library struct {
+	mu        sync.RWMutex
+	cfg       config.PromptsConfig
+	editors   map[string]bool
+	templates map[string]*Template
+}
+
+// New creates a library for cfg and loads the templates stored in its directory.
+func New(cfg config.PromptsConfig) *Library {
+	cfg = normalize(cfg)
+	l := &Library{cfg: cfg, templates: make(map[string]*Template)}
+	l.editors = editorSet(cfg.Editors)
+	if err := l.load(); err != nil {
+		log.Warnf("prompts: load templates from %s: %v", cfg.Dir, err)
+	}
+	return l
+}
+
+func normalize(cfg config.PromptsConfig) config.PromptsConfig {
+	cfg.Dir = strings.TrimSpace(cfg.Dir)
+	if cfg.Dir == "" {
+		cfg.Dir = "prompts"
+		if base := util.WritablePath(); base != "" {
+			cfg.Dir = filepath.Join(base, "prompts")
+		}
+	}
+	return cfg
+}
+
+func editorSet(keys []string) map[string]bool {
+	editors := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if key = strings.TrimSpace(key); key != "" {
+			editors[key] = true
+		}
+	}
+	return editors
+}
+
+// Configure applies cfg. The directory is kept from startup.
+func (l *Library) Configure(cfg config.PromptsConfig) {
+	cfg = normalize(cfg)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	cfg.Dir = l.cfg.Dir
+	l.cfg = cfg
+	l.editors = editorSet(cfg.Editors)
+}
+
+// Enabled reports whether the library is turned on.
+func (l *Library) Enabled() bool {
+	if l == nil {
+		return false
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.cfg.Enable
+}
+
+// CanEdit reports whether the inbound API key may change templates. Templates are shared by
+// every key, so only the configured editors may; without editors the library is read-only.
+func (l *Library) CanEdit(apiKey string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return apiKey != "" && l.editors[apiKey]
+}
+
+// List returns every template, sorted by name.
+func (l *Library) List() []Template {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	out := make([]Template, 0, len(l.templates))
+	for _, template := range l.templates {
+		out = append(out, *template)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Get returns the template called name.
+func (l *Library) Get(name string) (Template, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	template, ok := l.templates[name]
+	if !ok {
+		return Template{}, ErrNotFound
+	}
+	return *template, nil
+}
+
+// Put creates or replaces the template called name and reports whether it was created.
+// Replacing a template increments its version.
+func (l *Library) Put(name string, req PutRequest) (Template, bool, error) {
+	if !namePattern.MatchString(name) {
+		return Template{}, false, invalid("invalid template name %q: use up to 128 letters, digits, '.', '_' and '-'", name)
+	}
+	if len(req.Messages) == 0 {
+		return Template{}, false, invalid("messages is required")
+	}
+	for i, message := range req.Messages {
+		if !roles[message.Role] {
+			return Template{}, false, invalid("messages[%d]: unknown role %q, expected system, user or assistant", i, message.Role)
+		}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now().Unix()
+	template := &Template{
+		Name:        name,
+		Description: req.Description,
+		Messages:    append([]Message(nil), req.Messages...),
+		Variables:   req.Variables,
+		Version:     1,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	existing, replaced := l.templates[name]
+	if replaced {
+		template.Version = existing.Version + 1
+		template.CreatedAt = existing.CreatedAt
+	}
+	if err := writeJSON(l.path(name), template); err != nil {
+		return Template{}, false, err
+	}
+	l.templates[name] = template
+	return *template, !replaced, nil
+}
+
+// Delete removes the template called name.
+func (l *Library) Delete(name string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.templates[name]; !ok {
+		return ErrNotFound
+	}
+	if err := os.Remove(l.path(name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	delete(l.templates, name)
+	return nil
+}
+
+func (l *Library) path(name string) string {
+	return filepath.Join(l.cfg.Dir, name+".json")
+}
+
+// load reads every stored template.
+func (l *Library) load() error {
+	entries, err := os.ReadDir(l.cfg.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var errs []error
+	for _, entry := range entries {
+		name, isJSON := strings.CutSuffix(entry.Name(), ".json")
+		if entry.IsDir() || !isJSON || !namePattern.MatchString(name) {
+			continue
+		}
+		data, errRead := os.ReadFile(filepath.Join(l.cfg.Dir, entry.Name()))
+		template := &Template{}
+		if errRead == nil {
+			errRead = json.Unmarshal(data, template)
+		}
+		if errRead != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", entry.Name(), errRead))
+			continue
+		}
+		template.Name = name
+		l.templates[name] = template
+	}
+	return errors.Join(errs...)
+}
+
+// writeJSON writes v to path through a temporary file so readers never see a partial write.
+func writeJSON(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
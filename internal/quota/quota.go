@@ -0,0 +1,398 @@
+// Package quota enforces daily and monthly request and token limits per inbound API key.
+// Request counts are taken when a request is admitted; token counts are fed from the
+// usage pipeline once the upstream response reports consumption, so a request that is
+// admitted just below its token limit may overshoot it.
+package quota
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
+)
+
+// Quota windows and metrics reported in Status.
+const (
+	WindowDaily    = "daily"
+	WindowMonthly  = "monthly"
+	MetricRequests = "requests"
+	MetricTokens   = "tokens"
+)
+
+// wildcardKey selects the default limits applied to keys without their own entry.
+const wildcardKey = "*"
+
+// Status describes the state of one configured limit for an API key.
+type Status struct {
+	Window    string    `json:"window"`
+	Metric    string    `json:"metric"`
+	Limit     int64     `json:"limit"`
+	Used      int64     `json:"used"`
+	Remaining int64     `json:"remaining"`
+	ResetAt   time.Time `json:"reset_at"`
+}
+
+// Decision is the outcome of admitting a request.
+type Decision struct {
+	// Allowed reports whether the request may proceed.
+	Allowed bool
+	// Limited reports whether any limit applies to the key at all.
+	Limited bool
+	// Binding is the exhausted limit when denied, otherwise the limit closest to exhaustion.
+	Binding Status
+}
+
+// Totals is the consumption of one key in the current day and month.
+type Totals struct {
+	DayRequests   int64
+	DayTokens     int64
+	MonthRequests int64
+	MonthTokens   int64
+}
+
+// SharedCounters keeps the counters of every proxy instance in one place so that limits
+// hold across replicas. Implementations must be safe for concurrent use.
+type SharedCounters interface {
+	// Add adds requests and tokens to the counters of key for the day and month starting at
+	// day and month, and returns the resulting totals. Deltas may be zero or negative.
+	Add(ctx context.Context, key string, day, month time.Time, requests, tokens int64) (Totals, error)
+}
+
+type counters struct {
+	dayStart      time.Time
+	monthStart    time.Time
+	dayRequests   int64
+	dayTokens     int64
+	monthRequests int64
+	monthTokens   int64
+}
+
+// roll resets the counters whose period has ended.
+func (c *counters) roll(now time.Time) {
+	if day := startOfDay(now); !c.dayStart.Equal(day) {
+		c.dayStart = day
+		c.dayRequests = 0
+		c.dayTokens = 0
+	}
+	if month := startOfMonth(now); !c.monthStart.Equal(month) {
+		c.monthStart = month
+		c.monthRequests = 0
+		c.monthTokens = 0
+	}
+}
+
+// apply replaces the counters with shared totals.
+func (c *counters) apply(totals Totals) {
+	c.dayRequests = totals.DayRequests
+	c.dayTokens = totals.DayTokens
+	c.monthRequests = totals.MonthRequests
+	c.monthTokens = totals.MonthTokens
+}
+
+// Manager tracks per-key consumption and evaluates it against the configured limits.
+// It implements coreusage.Plugin to receive token usage. With shared counters the local
+// counters mirror the last totals reported by the shared store.
+type Manager struct {
+	mu       sync.Mutex
+	limits   map[string]config.APIKeyQuota
+	fallback *config.APIKeyQuota
+	usage    map[string]*counters
+	shared   SharedCounters
+	now      func() time.Time
+}
+
+// NewManager creates a quota manager for the configured limits.
+func NewManager(entries []config.APIKeyQuota) *Manager {
+	m := &Manager{usage: make(map[string]*counters), now: time.Now}
+	m.SetLimits(entries)
+	return m
+}
+
+// SetLimits replaces the configured limits. Accumulated usage is preserved.
+func (m *Manager) SetLimits(entries []config.APIKeyQuota) {
+	limits := make(map[string]config.APIKeyQuota, len(entries))
+	var fallback *config.APIKeyQuota
+	for i := range entries {
+		entry := entries[i]
+		key := strings.TrimSpace(entry.APIKey)
+		if key == "" {
+			continue
+		}
+		if key == wildcardKey {
+			fallback = &entry
+			continue
+		}
+		limits[key] = entry
+	}
+	m.mu.Lock()
+	m.limits = limits
+	m.fallback = fallback
+	m.mu.Unlock()
+}
+
+// SetSharedCounters makes the manager count consumption in shared instead of in memory.
+// It should be called before traffic is served.
+func (m *Manager) SetSharedCounters(shared SharedCounters) {
+	m.mu.Lock()
+	m.shared = shared
+	m.mu.Unlock()
+}
+
+// Enabled reports whether any limits are configured.
+func (m *Manager) Enabled() bool {
+	if m == nil {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.limits) > 0 || m.fallback != nil
+}
+
+// Admit checks the key against its limits and, when allowed, counts the request.
+func (m *Manager) Admit(key string) Decision {
+	if m == nil {
+		return Decision{Allowed: true}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	limit, ok := m.limitFor(key)
+	if !ok {
+		return Decision{Allowed: true}
+	}
+	now := m.now()
+	if m.shared != nil {
+		return m.admitShared(key, limit, now)
+	}
+	return admit(limit, m.countersFor(key, now))
+}
+
+// admitShared counts the request in the shared counters first, so that concurrent requests
+// on other instances see it, and takes it back when a limit turns out to be exhausted.
+// When the shared store is unreachable the request is judged on the local counters.
+// Callers must hold m.mu; it is released while the shared store is contacted.
+func (m *Manager) admitShared(key string, limit config.APIKeyQuota, now time.Time) Decision {
+	shared := m.shared
+	day, month := startOfDay(now), startOfMonth(now)
+	m.mu.Unlock()
+	totals, err := shared.Add(context.Background(), key, day, month, 1, 0)
+	m.mu.Lock()
+	c := m.countersFor(key, now)
+	if err != nil {
+		log.Warnf("quota: shared counters unavailable, using local counters: %v", err)
+		return admit(limit, c)
+	}
+	c.apply(totals)
+	c.dayRequests--
+	c.monthRequests--
+	decision := admit(limit, c)
+	if !decision.Allowed {
+		m.mu.Unlock()
+		if _, errRollback := shared.Add(context.Background(), key, day, month, -1, 0); errRollback != nil {
+			log.Warnf("quota: failed to release rejected request: %v", errRollback)
+		}
+		m.mu.Lock()
+	}
+	return decision
+}
+
+// admit checks c against limit and counts the request when it is allowed.
+func admit(limit config.APIKeyQuota, c *counters) Decision {
+	statuses := buildStatuses(limit, c)
+	if len(statuses) == 0 {
+		return Decision{Allowed: true}
+	}
+	var exhausted *Status
+	for i := range statuses {
+		if statuses[i].Remaining <= 0 && (exhausted == nil || statuses[i].ResetAt.After(exhausted.ResetAt)) {
+			exhausted = &statuses[i]
+		}
+	}
+	if exhausted != nil {
+		return Decision{Allowed: false, Limited: true, Binding: *exhausted}
+	}
+
+	c.dayRequests++
+	c.monthRequests++
+	statuses = buildStatuses(limit, c)
+	binding := statuses[0]
+	for _, st := range statuses[1:] {
+		if float64(st.Remaining)/float64(st.Limit) < float64(binding.Remaining)/float64(binding.Limit) {
+			binding = st
+		}
+	}
+	return Decision{Allowed: true, Limited: true, Binding: binding}
+}
+
+// HandleUsage implements coreusage.Plugin and adds the consumed tokens to the key's counters.
+func (m *Manager) HandleUsage(_ context.Context, record coreusage.Record) {
+	if m == nil || record.APIKey == "" {
+		return
+	}
+	tokens := record.Detail.TotalTokens
+	if tokens == 0 {
+		tokens = record.Detail.InputTokens + record.Detail.OutputTokens + record.Detail.ReasoningTokens
+	}
+	if tokens <= 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	if _, limited := m.limitFor(record.APIKey); limited && m.shared != nil {
+		shared := m.shared
+		m.mu.Unlock()
+		totals, err := shared.Add(context.Background(), record.APIKey, startOfDay(now), startOfMonth(now), 0, tokens)
+		m.mu.Lock()
+		if err == nil {
+			m.countersFor(record.APIKey, now).apply(totals)
+			return
+		}
+		log.Warnf("quota: failed to add tokens to shared counters: %v", err)
+	}
+	c := m.countersFor(record.APIKey, now)
+	c.dayTokens += tokens
+	c.monthTokens += tokens
+}
+
+// Seed initialises the counters for the current day and month from the aggregate usage
+// buckets, so limits survive a restart when usage persistence is enabled. Requests counted
+// here are those that reported usage; it should be called before traffic is served.
+// Shared counters outlive restarts on their own and are not seeded.
+func (m *Manager) Seed(buckets []usage.Aggregate) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.shared != nil {
+		return
+	}
+	now := m.now()
+	day := startOfDay(now)
+	month := startOfMonth(now)
+	for _, bucket := range buckets {
+		key := bucket.API
+		// Requests without a client key are tracked under route identifiers; they carry no quota.
+		if strings.Contains(key, " ") || strings.HasPrefix(key, "/") {
+			continue
+		}
+		if bucket.Start.Before(month) {
+			continue
+		}
+		c := m.countersFor(key, now)
+		c.monthRequests += bucket.Requests
+		c.monthTokens += bucket.Tokens.TotalTokens
+		if !bucket.Start.Before(day) {
+			c.dayRequests += bucket.Requests
+			c.dayTokens += bucket.Tokens.TotalTokens
+		}
+	}
+}
+
+// Status returns the state of every limit that applies to key.
+func (m *Manager) Status(key string) []Status {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	limit, ok := m.limitFor(key)
+	if !ok {
+		return nil
+	}
+	now := m.now()
+	if shared := m.shared; shared != nil {
+		m.mu.Unlock()
+		totals, err := shared.Add(context.Background(), key, startOfDay(now), startOfMonth(now), 0, 0)
+		m.mu.Lock()
+		if err == nil {
+			m.countersFor(key, now).apply(totals)
+		}
+	}
+	return buildStatuses(limit, m.countersFor(key, now))
+}
+
+// Keys lists the explicitly configured keys together with any key that consumed quota
+// under the wildcard limits, sorted for stable output.
+func (m *Manager) Keys() []string {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	seen := make(map[string]struct{}, len(m.limits)+len(m.usage))
+	for key := range m.limits {
+		seen[key] = struct{}{}
+	}
+	if m.fallback != nil {
+		for key := range m.usage {
+			seen[key] = struct{}{}
+		}
+	}
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// limitFor resolves the limits for key. Callers must hold m.mu.
+func (m *Manager) limitFor(key string) (config.APIKeyQuota, bool) {
+	if limit, ok := m.limits[key]; ok {
+		return limit, true
+	}
+	if m.fallback != nil {
+		return *m.fallback, true
+	}
+	return config.APIKeyQuota{}, false
+}
+
+// countersFor returns the rolled counters for key, creating them on first use. Callers must hold m.mu.
+func (m *Manager) countersFor(key string, now time.Time) *counters {
+	c, ok := m.usage[key]
+	if !ok {
+		c = &counters{}
+		m.usage[key] = c
+	}
+	c.roll(now)
+	return c
+}
+
+func buildStatuses(limit config.APIKeyQuota, c *counters) []Status {
+	dayReset := c.dayStart.AddDate(0, 0, 1)
+	monthReset := c.monthStart.AddDate(0, 1, 0)
+	statuses := make([]Status, 0, 4)
+	add := func(window, metric string, max, used int64, reset time.Time) {
+		if max <= 0 {
+			return
+		}
+		remaining := max - used
+		if remaining < 0 {
+			remaining = 0
+		}
+		statuses = append(statuses, Status{Window: window, Metric: metric, Limit: max, Used: used, Remaining: remaining, ResetAt: reset})
+	}
+	add(WindowDaily, MetricRequests, limit.DailyRequests, c.dayRequests, dayReset)
+	add(WindowDaily, MetricTokens, limit.DailyTokens, c.dayTokens, dayReset)
+	add(WindowMonthly, MetricRequests, limit.MonthlyRequests, c.monthRequests, monthReset)
+	add(WindowMonthly, MetricTokens, limit.MonthlyTokens, c.monthTokens, monthReset)
+	return statuses
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+func startOfMonth(t time.Time) time.Time {
+	year, month, _ := t.Date()
+	return time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+}
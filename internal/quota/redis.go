@@ -0,0 +1,53 @@
+package quota
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/redis"
+)
+
+// RedisCounters keeps quota counters in Redis hashes, one per key and period, which expire
+// shortly after their period ends. API keys are hashed so they never appear in key names.
+type RedisCounters struct {
+	client *redis.Client
+}
+
+// NewRedisCounters returns shared counters stored through client.
+func NewRedisCounters(client *redis.Client) *RedisCounters {
+	return &RedisCounters{client: client}
+}
+
+// Add implements SharedCounters.
+func (r *RedisCounters) Add(ctx context.Context, key string, day, month time.Time, requests, tokens int64) (Totals, error) {
+	sum := sha256.Sum256([]byte(key))
+	base := r.client.KeyPrefix() + "quota:" + hex.EncodeToString(sum[:16]) + ":"
+	dayKey := base + "d:" + day.Format("20060102")
+	monthKey := base + "m:" + month.Format("200601")
+	dayTTL := time.Until(day.AddDate(0, 0, 1)) + time.Hour
+	monthTTL := time.Until(month.AddDate(0, 1, 0)) + time.Hour
+	replies, err := r.client.Pipeline(ctx,
+		[]string{"HINCRBY", dayKey, MetricRequests, strconv.FormatInt(requests, 10)},
+		[]string{"HINCRBY", dayKey, MetricTokens, strconv.FormatInt(tokens, 10)},
+		[]string{"HINCRBY", monthKey, MetricRequests, strconv.FormatInt(requests, 10)},
+		[]string{"HINCRBY", monthKey, MetricTokens, strconv.FormatInt(tokens, 10)},
+		[]string{"PEXPIRE", dayKey, strconv.FormatInt(dayTTL.Milliseconds(), 10)},
+		[]string{"PEXPIRE", monthKey, strconv.FormatInt(monthTTL.Milliseconds(), 10)},
+	)
+	if err != nil {
+		return Totals{}, err
+	}
+	values := make([]int64, 4)
+	for i := range values {
+		value, ok := replies[i].(int64)
+		if !ok {
+			return Totals{}, fmt.Errorf("quota: unexpected redis reply %v", replies[i])
+		}
+		values[i] = value
+	}
+	return Totals{DayRequests: values[0], DayTokens: values[1], MonthRequests: values[2], MonthTokens: values[3]}, nil
+}
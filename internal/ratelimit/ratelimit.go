@@ -0,0 +1,301 @@
+// Package ratelimit enforces requests-per-minute and tokens-per-minute limits with token
+// buckets, scoped globally, per inbound API key and per model. Request buckets are charged
+// when a request is admitted; token buckets are charged from the usage pipeline once the
+// upstream response reports consumption and may run into debt, which blocks further
+// requests until it has been refilled.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// Metrics reported in Status.
+const (
+	MetricRequests = "requests"
+	MetricTokens   = "tokens"
+)
+
+// wildcard gives every key or model without its own entry a separate bucket.
+const wildcard = "*"
+
+// idleBucketTTL is how long a full bucket is kept after its last use.
+const idleBucketTTL = 10 * time.Minute
+
+// Status describes one bucket that applies to a request.
+type Status struct {
+	// Scope names the dimensions the limit is scoped to: "global", "key", "model" or "key+model".
+	Scope     string    `json:"scope"`
+	Metric    string    `json:"metric"`
+	Limit     int64     `json:"limit"`
+	Remaining int64     `json:"remaining"`
+	ResetAt   time.Time `json:"reset_at"`
+	// RetryAfter is how long until the bucket admits a request again; zero when it does now.
+	RetryAfter time.Duration `json:"retry_after,omitempty"`
+}
+
+// Decision is the outcome of admitting a request.
+type Decision struct {
+	// Allowed reports whether the request may proceed.
+	Allowed bool
+	// Limited reports whether any limit applies to the request at all.
+	Limited bool
+	// Binding is the exhausted bucket with the longest wait when denied, otherwise the
+	// bucket closest to exhaustion.
+	Binding Status
+}
+
+// bucket is a token bucket holding up to capacity units and refilling capacity per minute.
+type bucket struct {
+	capacity float64
+	level    float64
+	updated  time.Time
+}
+
+func (b *bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.level = math.Min(b.capacity, b.level+elapsed.Minutes()*b.capacity)
+	}
+	b.updated = now
+}
+
+// wait returns how long until the bucket holds need units.
+func (b *bucket) wait(need float64) time.Duration {
+	if b.level >= need {
+		return 0
+	}
+	return time.Duration((need - b.level) / b.capacity * float64(time.Minute))
+}
+
+// Limiter tracks the buckets of the configured limits. It implements coreusage.Plugin to
+// charge token usage.
+type Limiter struct {
+	mu      sync.Mutex
+	entries []config.RateLimit
+	keys    map[string]struct{}
+	models  map[string]struct{}
+	buckets map[string]*bucket
+	pruned  time.Time
+	now     func() time.Time
+}
+
+// NewLimiter creates a limiter for the configured limits.
+func NewLimiter(entries []config.RateLimit) *Limiter {
+	l := &Limiter{buckets: make(map[string]*bucket), now: time.Now}
+	l.SetLimits(entries)
+	return l
+}
+
+// SetLimits replaces the configured limits. Buckets are reset when the limits change.
+func (l *Limiter) SetLimits(entries []config.RateLimit) {
+	cleaned := make([]config.RateLimit, 0, len(entries))
+	keys := make(map[string]struct{})
+	models := make(map[string]struct{})
+	for _, entry := range entries {
+		entry.APIKey = strings.TrimSpace(entry.APIKey)
+		entry.Model = strings.TrimSpace(entry.Model)
+		if entry.RequestsPerMinute <= 0 && entry.TokensPerMinute <= 0 {
+			continue
+		}
+		if entry.APIKey != "" && entry.APIKey != wildcard {
+			keys[entry.APIKey] = struct{}{}
+		}
+		if entry.Model != "" && entry.Model != wildcard {
+			models[entry.Model] = struct{}{}
+		}
+		cleaned = append(cleaned, entry)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if reflect.DeepEqual(cleaned, l.entries) {
+		return
+	}
+	l.entries = cleaned
+	l.keys = keys
+	l.models = models
+	l.buckets = make(map[string]*bucket)
+}
+
+// Enabled reports whether any limits are configured.
+func (l *Limiter) Enabled() bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.entries) > 0
+}
+
+// matched is one bucket of a limit that applies to a request.
+type matched struct {
+	scope  string
+	metric string
+	bucket *bucket
+}
+
+// Admit checks every bucket that applies to key and model and, when all of them admit the
+// request, charges it to the request buckets.
+func (l *Limiter) Admit(key, model string) Decision {
+	if l == nil {
+		return Decision{Allowed: true}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	buckets := l.match(key, model, now)
+	if len(buckets) == 0 {
+		return Decision{Allowed: true}
+	}
+
+	var denied *Status
+	for _, item := range buckets {
+		need := 1.0
+		if item.metric == MetricTokens {
+			// Any positive level admits; the response's tokens are charged afterwards.
+			need = math.SmallestNonzeroFloat64
+		}
+		if wait := item.bucket.wait(need); wait > 0 {
+			status := item.status(now)
+			status.RetryAfter = wait
+			if denied == nil || wait > denied.RetryAfter {
+				denied = &status
+			}
+		}
+	}
+	if denied != nil {
+		return Decision{Allowed: false, Limited: true, Binding: *denied}
+	}
+
+	var binding Status
+	ratio := math.Inf(1)
+	for _, item := range buckets {
+		if item.metric == MetricRequests {
+			item.bucket.level--
+		}
+		status := item.status(now)
+		if r := float64(status.Remaining) / float64(status.Limit); r < ratio {
+			ratio = r
+			binding = status
+		}
+	}
+	return Decision{Allowed: true, Limited: true, Binding: binding}
+}
+
+// HandleUsage implements coreusage.Plugin and charges the consumed tokens to the token buckets.
+func (l *Limiter) HandleUsage(_ context.Context, record coreusage.Record) {
+	if l == nil {
+		return
+	}
+	tokens := record.Detail.TotalTokens
+	if tokens == 0 {
+		tokens = record.Detail.InputTokens + record.Detail.OutputTokens + record.Detail.ReasoningTokens
+	}
+	if tokens <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, item := range l.match(record.APIKey, record.Model, l.now()) {
+		if item.metric == MetricTokens {
+			item.bucket.level -= float64(tokens)
+		}
+	}
+}
+
+// match returns the refilled buckets of every limit that applies to key and model, creating
+// them on first use. Callers must hold l.mu.
+func (l *Limiter) match(key, model string, now time.Time) []matched {
+	l.pruneIdle(now)
+	var out []matched
+	for i, entry := range l.entries {
+		keyPart, ok := scopePart(entry.APIKey, key, l.keys)
+		if !ok {
+			continue
+		}
+		modelPart, ok := scopePart(entry.Model, model, l.models)
+		if !ok {
+			continue
+		}
+		scope := scopeName(entry)
+		id := strconv.Itoa(i) + "\x00" + keyPart + "\x00" + modelPart
+		if entry.RequestsPerMinute > 0 {
+			out = append(out, matched{scope: scope, metric: MetricRequests, bucket: l.bucketFor(MetricRequests+id, entry.RequestsPerMinute, now)})
+		}
+		if entry.TokensPerMinute > 0 {
+			out = append(out, matched{scope: scope, metric: MetricTokens, bucket: l.bucketFor(MetricTokens+id, entry.TokensPerMinute, now)})
+		}
+	}
+	return out
+}
+
+// scopePart resolves one dimension of a limit for value: an empty pattern shares the bucket,
+// the wildcard applies to values without their own entry, anything else must match exactly.
+func scopePart(pattern, value string, named map[string]struct{}) (string, bool) {
+	switch pattern {
+	case "":
+		return "", true
+	case wildcard:
+		if _, ok := named[value]; ok {
+			return "", false
+		}
+		return value, true
+	default:
+		return value, pattern == value
+	}
+}
+
+func (l *Limiter) bucketFor(id string, limit int64, now time.Time) *bucket {
+	b, ok := l.buckets[id]
+	if !ok {
+		b = &bucket{capacity: float64(limit), level: float64(limit), updated: now}
+		l.buckets[id] = b
+	}
+	b.refill(now)
+	return b
+}
+
+// pruneIdle drops buckets that have been full for a while, bounding memory for wildcard limits.
+// It scans at most once a minute.
+func (l *Limiter) pruneIdle(now time.Time) {
+	if now.Sub(l.pruned) < time.Minute {
+		return
+	}
+	l.pruned = now
+	for id, b := range l.buckets {
+		if now.Sub(b.updated) > idleBucketTTL {
+			delete(l.buckets, id)
+		}
+	}
+}
+
+func (m matched) status(now time.Time) Status {
+	remaining := int64(math.Max(0, math.Floor(m.bucket.level)))
+	return Status{
+		Scope:     m.scope,
+		Metric:    m.metric,
+		Limit:     int64(m.bucket.capacity),
+		Remaining: remaining,
+		ResetAt:   now.Add(m.bucket.wait(m.bucket.capacity)),
+	}
+}
+
+func scopeName(entry config.RateLimit) string {
+	switch {
+	case entry.APIKey != "" && entry.Model != "":
+		return "key+model"
+	case entry.APIKey != "":
+		return "key"
+	case entry.Model != "":
+		return "model"
+	default:
+		return "global"
+	}
+}
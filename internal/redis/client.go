@@ -0,0 +1,294 @@
+// Package redis provides a minimal Redis client speaking the RESP2 protocol.
+// It covers the handful of commands the proxy needs for shared state without
+// pulling in a full client library.
+package redis
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+const (
+	defaultPoolSize    = 8
+	defaultDialTimeout = 5 * time.Second
+	defaultIOTimeout   = 3 * time.Second
+)
+
+// ErrNil is returned when a command replies with a nil bulk string, e.g. GET of a missing key.
+var ErrNil = errors.New("redis: nil reply")
+
+// Error is an error reply sent by the server.
+type Error string
+
+func (e Error) Error() string { return "redis: " + string(e) }
+
+// Client is a pooled Redis connection. It is safe for concurrent use.
+type Client struct {
+	cfg  config.Redis
+	pool chan *conn
+}
+
+type conn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// NewClient returns a client for cfg. Connections are opened lazily.
+func NewClient(cfg config.Redis) (*Client, error) {
+	if strings.TrimSpace(cfg.Addr) == "" {
+		return nil, fmt.Errorf("redis: address is empty")
+	}
+	size := cfg.PoolSize
+	if size <= 0 {
+		size = defaultPoolSize
+	}
+	return &Client{cfg: cfg, pool: make(chan *conn, size)}, nil
+}
+
+// KeyPrefix returns the configured prefix for all keys written by the proxy.
+func (c *Client) KeyPrefix() string {
+	if c.cfg.KeyPrefix != "" {
+		return c.cfg.KeyPrefix
+	}
+	return "cliproxy:"
+}
+
+// Do sends a command and returns its reply: string for simple and bulk strings,
+// int64 for integers, []any for arrays. Nil replies are reported as ErrNil.
+func (c *Client) Do(ctx context.Context, args ...string) (any, error) {
+	cn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := cn.roundTrip(ctx, args)
+	var replyErr Error
+	if err != nil && !errors.Is(err, ErrNil) && !errors.As(err, &replyErr) {
+		// The connection state is unknown after an I/O error; drop it.
+		_ = cn.Close()
+		return nil, err
+	}
+	c.put(cn)
+	return reply, err
+}
+
+// Pipeline sends several commands in one round trip and returns their replies in order.
+// Error replies are returned as Error values in the slice; the returned error reports
+// connection failures only.
+func (c *Client) Pipeline(ctx context.Context, commands ...[]string) ([]any, error) {
+	if len(commands) == 0 {
+		return nil, nil
+	}
+	cn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	replies, err := cn.pipeline(ctx, commands)
+	if err != nil {
+		_ = cn.Close()
+		return nil, err
+	}
+	c.put(cn)
+	return replies, nil
+}
+
+// Get returns the value stored at key; ok is false when the key does not exist.
+func (c *Client) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := c.Do(ctx, "GET", key)
+	if errors.Is(err, ErrNil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	value, _ := reply.(string)
+	return []byte(value), true, nil
+}
+
+// Set stores value at key, expiring after ttl when ttl is positive.
+func (c *Client) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", key, string(value)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+	_, err := c.Do(ctx, args...)
+	return err
+}
+
+// Ping checks that the server answers.
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.Do(ctx, "PING")
+	return err
+}
+
+// Close closes the idle connections of the pool.
+func (c *Client) Close() error {
+	for {
+		select {
+		case cn := <-c.pool:
+			_ = cn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+func (c *Client) get(ctx context.Context) (*conn, error) {
+	select {
+	case cn := <-c.pool:
+		return cn, nil
+	default:
+	}
+	return c.dial(ctx)
+}
+
+func (c *Client) put(cn *conn) {
+	select {
+	case c.pool <- cn:
+	default:
+		_ = cn.Close()
+	}
+}
+
+func (c *Client) dial(ctx context.Context) (*conn, error) {
+	dialer := &net.Dialer{Timeout: defaultDialTimeout}
+	var netConn net.Conn
+	var err error
+	if c.cfg.TLS {
+		tlsDialer := &tls.Dialer{NetDialer: dialer}
+		netConn, err = tlsDialer.DialContext(ctx, "tcp", c.cfg.Addr)
+	} else {
+		netConn, err = dialer.DialContext(ctx, "tcp", c.cfg.Addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redis: dial %s: %w", c.cfg.Addr, err)
+	}
+	cn := &conn{Conn: netConn, reader: bufio.NewReader(netConn)}
+	if c.cfg.Password != "" {
+		args := []string{"AUTH", c.cfg.Password}
+		if c.cfg.Username != "" {
+			args = []string{"AUTH", c.cfg.Username, c.cfg.Password}
+		}
+		if _, err = cn.roundTrip(ctx, args); err != nil {
+			_ = cn.Close()
+			return nil, fmt.Errorf("redis: auth: %w", err)
+		}
+	}
+	if c.cfg.DB != 0 {
+		if _, err = cn.roundTrip(ctx, []string{"SELECT", strconv.Itoa(c.cfg.DB)}); err != nil {
+			_ = cn.Close()
+			return nil, fmt.Errorf("redis: select db %d: %w", c.cfg.DB, err)
+		}
+	}
+	return cn, nil
+}
+
+func (cn *conn) roundTrip(ctx context.Context, args []string) (any, error) {
+	if err := cn.send(ctx, [][]string{args}); err != nil {
+		return nil, err
+	}
+	return cn.readReply()
+}
+
+func (cn *conn) pipeline(ctx context.Context, commands [][]string) ([]any, error) {
+	if err := cn.send(ctx, commands); err != nil {
+		return nil, err
+	}
+	replies := make([]any, 0, len(commands))
+	for range commands {
+		reply, err := cn.readReply()
+		var replyErr Error
+		switch {
+		case errors.As(err, &replyErr):
+			reply = replyErr
+		case errors.Is(err, ErrNil):
+			reply = nil
+		case err != nil:
+			return nil, err
+		}
+		replies = append(replies, reply)
+	}
+	return replies, nil
+}
+
+// send writes commands to the connection, refreshing its deadline.
+func (cn *conn) send(ctx context.Context, commands [][]string) error {
+	deadline := time.Now().Add(defaultIOTimeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := cn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	var buf strings.Builder
+	for _, args := range commands {
+		buf.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+		for _, arg := range args {
+			buf.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n")
+			buf.WriteString(arg)
+			buf.WriteString("\r\n")
+		}
+	}
+	_, err := io.WriteString(cn.Conn, buf.String())
+	return err
+}
+
+func (cn *conn) readReply() (any, error) {
+	line, err := cn.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("redis: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, Error(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, errSize := strconv.Atoi(line[1:])
+		if errSize != nil {
+			return nil, fmt.Errorf("redis: invalid bulk length %q", line[1:])
+		}
+		if size < 0 {
+			return nil, ErrNil
+		}
+		data := make([]byte, size+2)
+		if _, err = io.ReadFull(cn.reader, data); err != nil {
+			return nil, err
+		}
+		return string(data[:size]), nil
+	case '*':
+		count, errCount := strconv.Atoi(line[1:])
+		if errCount != nil {
+			return nil, fmt.Errorf("redis: invalid array length %q", line[1:])
+		}
+		if count < 0 {
+			return nil, ErrNil
+		}
+		items := make([]any, 0, count)
+		for i := 0; i < count; i++ {
+			item, errItem := cn.readReply()
+			if errItem != nil && !errors.Is(errItem, ErrNil) {
+				return nil, errItem
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
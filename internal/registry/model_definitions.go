@@ -10,60 +10,74 @@ func GetClaudeModels() []*ModelInfo {
 	return []*ModelInfo{
 
 		{
-			ID:          "claude-haiku-4-5-20251001",
-			Object:      "model",
-			Created:     1759276800, // 2025-10-01
-			OwnedBy:     "anthropic",
-			Type:        "claude",
-			DisplayName: "Claude 4.5 Haiku",
+			ID:            "claude-haiku-4-5-20251001",
+			Object:        "model",
+			Created:       1759276800, // 2025-10-01
+			OwnedBy:       "anthropic",
+			Type:          "claude",
+			DisplayName:   "Claude 4.5 Haiku",
+			ContextLength: 200000,
+			Capabilities:  &ModelCapabilities{Vision: true, Tools: true, JSONMode: true},
 		},
 		{
-			ID:          "claude-sonnet-4-5-20250929",
-			Object:      "model",
-			Created:     1759104000, // 2025-09-29
-			OwnedBy:     "anthropic",
-			Type:        "claude",
-			DisplayName: "Claude 4.5 Sonnet",
+			ID:            "claude-sonnet-4-5-20250929",
+			Object:        "model",
+			Created:       1759104000, // 2025-09-29
+			OwnedBy:       "anthropic",
+			Type:          "claude",
+			DisplayName:   "Claude 4.5 Sonnet",
+			ContextLength: 200000,
+			Capabilities:  &ModelCapabilities{Vision: true, Tools: true, JSONMode: true},
 		},
 		{
-			ID:          "claude-opus-4-1-20250805",
-			Object:      "model",
-			Created:     1722945600, // 2025-08-05
-			OwnedBy:     "anthropic",
-			Type:        "claude",
-			DisplayName: "Claude 4.1 Opus",
+			ID:            "claude-opus-4-1-20250805",
+			Object:        "model",
+			Created:       1722945600, // 2025-08-05
+			OwnedBy:       "anthropic",
+			Type:          "claude",
+			DisplayName:   "Claude 4.1 Opus",
+			ContextLength: 200000,
+			Capabilities:  &ModelCapabilities{Vision: true, Tools: true, JSONMode: true},
 		},
 		{
-			ID:          "claude-opus-4-20250514",
-			Object:      "model",
-			Created:     1715644800, // 2025-05-14
-			OwnedBy:     "anthropic",
-			Type:        "claude",
-			DisplayName: "Claude 4 Opus",
+			ID:            "claude-opus-4-20250514",
+			Object:        "model",
+			Created:       1715644800, // 2025-05-14
+			OwnedBy:       "anthropic",
+			Type:          "claude",
+			DisplayName:   "Claude 4 Opus",
+			ContextLength: 200000,
+			Capabilities:  &ModelCapabilities{Vision: true, Tools: true, JSONMode: true},
 		},
 		{
-			ID:          "claude-sonnet-4-20250514",
-			Object:      "model",
-			Created:     1715644800, // 2025-05-14
-			OwnedBy:     "anthropic",
-			Type:        "claude",
-			DisplayName: "Claude 4 Sonnet",
+			ID:            "claude-sonnet-4-20250514",
+			Object:        "model",
+			Created:       1715644800, // 2025-05-14
+			OwnedBy:       "anthropic",
+			Type:          "claude",
+			DisplayName:   "Claude 4 Sonnet",
+			ContextLength: 200000,
+			Capabilities:  &ModelCapabilities{Vision: true, Tools: true, JSONMode: true},
 		},
 		{
-			ID:          "claude-3-7-sonnet-20250219",
-			Object:      "model",
-			Created:     1708300800, // 2025-02-19
-			OwnedBy:     "anthropic",
-			Type:        "claude",
-			DisplayName: "Claude 3.7 Sonnet",
+			ID:            "claude-3-7-sonnet-20250219",
+			Object:        "model",
+			Created:       1708300800, // 2025-02-19
+			OwnedBy:       "anthropic",
+			Type:          "claude",
+			DisplayName:   "Claude 3.7 Sonnet",
+			ContextLength: 200000,
+			Capabilities:  &ModelCapabilities{Vision: true, Tools: true, JSONMode: true},
 		},
 		{
-			ID:          "claude-3-5-haiku-20241022",
-			Object:      "model",
-			Created:     1729555200, // 2024-10-22
-			OwnedBy:     "anthropic",
-			Type:        "claude",
-			DisplayName: "Claude 3.5 Haiku",
+			ID:            "claude-3-5-haiku-20241022",
+			Object:        "model",
+			Created:       1729555200, // 2024-10-22
+			OwnedBy:       "anthropic",
+			Type:          "claude",
+			DisplayName:   "Claude 3.5 Haiku",
+			ContextLength: 200000,
+			Capabilities:  &ModelCapabilities{Vision: true, Tools: true, JSONMode: true},
 		},
 	}
 }
@@ -84,6 +98,7 @@ func GeminiModels() []*ModelInfo {
 			InputTokenLimit:            1048576,
 			OutputTokenLimit:           65536,
 			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
+			Capabilities:               &ModelCapabilities{Vision: true, Tools: true, JSONMode: true},
 		},
 		{
 			ID:                         "gemini-2.5-pro",
@@ -98,6 +113,7 @@ func GeminiModels() []*ModelInfo {
 			InputTokenLimit:            1048576,
 			OutputTokenLimit:           65536,
 			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
+			Capabilities:               &ModelCapabilities{Vision: true, Tools: true, JSONMode: true},
 		},
 		{
 			ID:                         "gemini-2.5-flash-lite",
@@ -112,6 +128,7 @@ func GeminiModels() []*ModelInfo {
 			InputTokenLimit:            1048576,
 			OutputTokenLimit:           65536,
 			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
+			Capabilities:               &ModelCapabilities{Vision: true, Tools: true, JSONMode: true},
 		},
 		{
 			ID:                         "gemini-2.5-flash-image-preview",
@@ -126,6 +143,7 @@ func GeminiModels() []*ModelInfo {
 			InputTokenLimit:            1048576,
 			OutputTokenLimit:           8192,
 			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
+			Capabilities:               &ModelCapabilities{Vision: true, Tools: true, JSONMode: true},
 		},
 		{
 			ID:                         "gemini-2.5-flash-image",
@@ -140,12 +158,102 @@ func GeminiModels() []*ModelInfo {
 			InputTokenLimit:            1048576,
 			OutputTokenLimit:           8192,
 			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
+			Capabilities:               &ModelCapabilities{Vision: true, Tools: true, JSONMode: true},
 		},
 	}
 }
 
-// GetGeminiModels returns the standard Gemini model definitions
-func GetGeminiModels() []*ModelInfo { return GeminiModels() }
+// GeminiEmbeddingModels returns the Gemini embedding models served through /v1/embeddings.
+func GeminiEmbeddingModels() []*ModelInfo {
+	return []*ModelInfo{
+		{
+			ID:                         "gemini-embedding-001",
+			Object:                     "model",
+			Created:                    time.Now().Unix(),
+			OwnedBy:                    "google",
+			Type:                       "gemini",
+			Name:                       "models/gemini-embedding-001",
+			Version:                    "001",
+			DisplayName:                "Gemini Embedding 001",
+			Description:                "Obtain a distributed representation of a text.",
+			InputTokenLimit:            2048,
+			OutputTokenLimit:           1,
+			SupportedGenerationMethods: []string{"embedContent", "countTextTokens", "countTokens", "asyncBatchEmbedContent"},
+		},
+		{
+			ID:                         "text-embedding-004",
+			Object:                     "model",
+			Created:                    time.Now().Unix(),
+			OwnedBy:                    "google",
+			Type:                       "gemini",
+			Name:                       "models/text-embedding-004",
+			Version:                    "004",
+			DisplayName:                "Text Embedding 004",
+			Description:                "Obtain a distributed representation of a text.",
+			InputTokenLimit:            2048,
+			OutputTokenLimit:           1,
+			SupportedGenerationMethods: []string{"embedContent"},
+		},
+	}
+}
+
+// GeminiImagenModels returns the Imagen models served through /v1/images/generations.
+func GeminiImagenModels() []*ModelInfo {
+	return []*ModelInfo{
+		{
+			ID:                         "imagen-4.0-generate-001",
+			Object:                     "model",
+			Created:                    time.Now().Unix(),
+			OwnedBy:                    "google",
+			Type:                       "gemini",
+			Name:                       "models/imagen-4.0-generate-001",
+			Version:                    "001",
+			DisplayName:                "Imagen 4",
+			Description:                "Vertex served Imagen 4.0 model",
+			InputTokenLimit:            480,
+			OutputTokenLimit:           8192,
+			SupportedGenerationMethods: []string{"predict"},
+		},
+		{
+			ID:                         "imagen-4.0-ultra-generate-001",
+			Object:                     "model",
+			Created:                    time.Now().Unix(),
+			OwnedBy:                    "google",
+			Type:                       "gemini",
+			Name:                       "models/imagen-4.0-ultra-generate-001",
+			Version:                    "001",
+			DisplayName:                "Imagen 4 Ultra",
+			Description:                "Vertex served Imagen 4.0 ultra model",
+			InputTokenLimit:            480,
+			OutputTokenLimit:           8192,
+			SupportedGenerationMethods: []string{"predict"},
+		},
+		{
+			ID:                         "imagen-4.0-fast-generate-001",
+			Object:                     "model",
+			Created:                    time.Now().Unix(),
+			OwnedBy:                    "google",
+			Type:                       "gemini",
+			Name:                       "models/imagen-4.0-fast-generate-001",
+			Version:                    "001",
+			DisplayName:                "Imagen 4 Fast",
+			Description:                "Vertex served Imagen 4.0 Fast model",
+			InputTokenLimit:            480,
+			OutputTokenLimit:           8192,
+			SupportedGenerationMethods: []string{"predict"},
+		},
+	}
+}
+
+// GetGeminiModels returns the standard Gemini model definitions.
+// API-key accounts additionally expose the embedding and Imagen models, which the CLI backends do not serve.
+func GetGeminiModels() []*ModelInfo {
+	models := make([]*ModelInfo, 0, 11)
+	models = append(models, GeminiModels()...)
+	models = append(models, GeminiEmbeddingModels()...)
+	models = append(models, GeminiImagenModels()...)
+	return models
+}
 
 // GetGeminiCLIModels returns the standard Gemini model definitions
 func GetGeminiCLIModels() []*ModelInfo { return GeminiModels() }
@@ -168,6 +276,7 @@ func GetAIStudioModels() []*ModelInfo {
 			InputTokenLimit:            1048576,
 			OutputTokenLimit:           65536,
 			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
+			Capabilities:               &ModelCapabilities{Vision: true, Tools: true, JSONMode: true},
 		},
 		&ModelInfo{
 			ID:                         "gemini-flash-latest",
@@ -182,6 +291,7 @@ func GetAIStudioModels() []*ModelInfo {
 			InputTokenLimit:            1048576,
 			OutputTokenLimit:           65536,
 			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
+			Capabilities:               &ModelCapabilities{Vision: true, Tools: true, JSONMode: true},
 		},
 		&ModelInfo{
 			ID:                         "gemini-flash-lite-latest",
@@ -196,6 +306,7 @@ func GetAIStudioModels() []*ModelInfo {
 			InputTokenLimit:            1048576,
 			OutputTokenLimit:           65536,
 			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
+			Capabilities:               &ModelCapabilities{Vision: true, Tools: true, JSONMode: true},
 		},
 	)
 	return models
@@ -216,6 +327,7 @@ func GetOpenAIModels() []*ModelInfo {
 			ContextLength:       400000,
 			MaxCompletionTokens: 128000,
 			SupportedParameters: []string{"tools"},
+			Capabilities:        &ModelCapabilities{Vision: true, Tools: true, JSONMode: true},
 		},
 		{
 			ID:                  "gpt-5-minimal",
@@ -229,6 +341,7 @@ func GetOpenAIModels() []*ModelInfo {
 			ContextLength:       400000,
 			MaxCompletionTokens: 128000,
 			SupportedParameters: []string{"tools"},
+			Capabilities:        &ModelCapabilities{Vision: true, Tools: true, JSONMode: true},
 		},
 		{
 			ID:                  "gpt-5-low",
@@ -242,6 +355,7 @@ func GetOpenAIModels() []*ModelInfo {
 			ContextLength:       400000,
 			MaxCompletionTokens: 128000,
 			SupportedParameters: []string{"tools"},
+			Capabilities:        &ModelCapabilities{Vision: true, Tools: true, JSONMode: true},
 		},
 		{
 			ID:                  "gpt-5-medium",
@@ -255,6 +369,7 @@ func GetOpenAIModels() []*ModelInfo {
 			ContextLength:       400000,
 			MaxCompletionTokens: 128000,
 			SupportedParameters: []string{"tools"},
+			Capabilities:        &ModelCapabilities{Vision: true, Tools: true, JSONMode: true},
 		},
 		{
 			ID:                  "gpt-5-high",
@@ -268,6 +383,7 @@ func GetOpenAIModels() []*ModelInfo {
 			ContextLength:       400000,
 			MaxCompletionTokens: 128000,
 			SupportedParameters: []string{"tools"},
+			Capabilities:        &ModelCapabilities{Vision: true, Tools: true, JSONMode: true},
 		},
 		{
 			ID:                  "gpt-5-codex",
@@ -281,6 +397,7 @@ func GetOpenAIModels() []*ModelInfo {
 			ContextLength:       400000,
 			MaxCompletionTokens: 128000,
 			SupportedParameters: []string{"tools"},
+			Capabilities:        &ModelCapabilities{Vision: true, Tools: true, JSONMode: true},
 		},
 		{
 			ID:                  "gpt-5-codex-low",
@@ -294,6 +411,7 @@ func GetOpenAIModels() []*ModelInfo {
 			ContextLength:       400000,
 			MaxCompletionTokens: 128000,
 			SupportedParameters: []string{"tools"},
+			Capabilities:        &ModelCapabilities{Vision: true, Tools: true, JSONMode: true},
 		},
 		{
 			ID:                  "gpt-5-codex-medium",
@@ -307,6 +425,7 @@ func GetOpenAIModels() []*ModelInfo {
 			ContextLength:       400000,
 			MaxCompletionTokens: 128000,
 			SupportedParameters: []string{"tools"},
+			Capabilities:        &ModelCapabilities{Vision: true, Tools: true, JSONMode: true},
 		},
 		{
 			ID:                  "gpt-5-codex-high",
@@ -320,6 +439,7 @@ func GetOpenAIModels() []*ModelInfo {
 			ContextLength:       400000,
 			MaxCompletionTokens: 128000,
 			SupportedParameters: []string{"tools"},
+			Capabilities:        &ModelCapabilities{Vision: true, Tools: true, JSONMode: true},
 		},
 		{
 			ID:                  "codex-mini-latest",
@@ -333,6 +453,7 @@ func GetOpenAIModels() []*ModelInfo {
 			ContextLength:       4096,
 			MaxCompletionTokens: 2048,
 			SupportedParameters: []string{"temperature", "max_tokens", "stream", "stop"},
+			Capabilities:        &ModelCapabilities{Vision: true, Tools: true, JSONMode: true},
 		},
 	}
 }
@@ -352,6 +473,7 @@ func GetQwenModels() []*ModelInfo {
 			ContextLength:       32768,
 			MaxCompletionTokens: 8192,
 			SupportedParameters: []string{"temperature", "top_p", "max_tokens", "stream", "stop"},
+			Capabilities:        &ModelCapabilities{Tools: true},
 		},
 		{
 			ID:                  "qwen3-coder-flash",
@@ -365,6 +487,7 @@ func GetQwenModels() []*ModelInfo {
 			ContextLength:       8192,
 			MaxCompletionTokens: 2048,
 			SupportedParameters: []string{"temperature", "top_p", "max_tokens", "stream", "stop"},
+			Capabilities:        &ModelCapabilities{Tools: true},
 		},
 		{
 			ID:                  "vision-model",
@@ -378,6 +501,7 @@ func GetQwenModels() []*ModelInfo {
 			ContextLength:       32768,
 			MaxCompletionTokens: 2048,
 			SupportedParameters: []string{"temperature", "top_p", "max_tokens", "stream", "stop"},
+			Capabilities:        &ModelCapabilities{Vision: true},
 		},
 	}
 }
@@ -423,3 +547,37 @@ func GetIFlowModels() []*ModelInfo {
 	}
 	return models
 }
+
+// GetXAIModels returns the standard Grok model definitions for xAI API keys.
+func GetXAIModels() []*ModelInfo {
+	created := time.Now().Unix()
+	entries := []struct {
+		ID            string
+		DisplayName   string
+		Description   string
+		ContextLength int
+		Vision        bool
+	}{
+		{ID: "grok-4", DisplayName: "Grok 4", Description: "xAI Grok 4 reasoning model", ContextLength: 256000, Vision: true},
+		{ID: "grok-4-fast-reasoning", DisplayName: "Grok 4 Fast Reasoning", Description: "xAI Grok 4 Fast with reasoning", ContextLength: 2000000, Vision: true},
+		{ID: "grok-4-fast-non-reasoning", DisplayName: "Grok 4 Fast Non-Reasoning", Description: "xAI Grok 4 Fast without reasoning", ContextLength: 2000000, Vision: true},
+		{ID: "grok-code-fast-1", DisplayName: "Grok Code Fast 1", Description: "xAI Grok model for agentic coding", ContextLength: 256000},
+		{ID: "grok-3", DisplayName: "Grok 3", Description: "xAI Grok 3", ContextLength: 131072},
+		{ID: "grok-3-mini", DisplayName: "Grok 3 Mini", Description: "xAI Grok 3 Mini reasoning model", ContextLength: 131072},
+	}
+	models := make([]*ModelInfo, 0, len(entries))
+	for _, entry := range entries {
+		models = append(models, &ModelInfo{
+			ID:            entry.ID,
+			Object:        "model",
+			Created:       created,
+			OwnedBy:       "xai",
+			Type:          "xai",
+			DisplayName:   entry.DisplayName,
+			Description:   entry.Description,
+			ContextLength: entry.ContextLength,
+			Capabilities:  &ModelCapabilities{Vision: entry.Vision, Tools: true, JSONMode: true},
+		})
+	}
+	return models
+}
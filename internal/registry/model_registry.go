@@ -45,6 +45,29 @@ type ModelInfo struct {
 	MaxCompletionTokens int `json:"max_completion_tokens,omitempty"`
 	// SupportedParameters lists supported parameters
 	SupportedParameters []string `json:"supported_parameters,omitempty"`
+	// Capabilities describes the inputs and features the model supports; nil when unknown
+	Capabilities *ModelCapabilities `json:"capabilities,omitempty"`
+}
+
+// ModelCapabilities lists what a model accepts beyond plain text.
+type ModelCapabilities struct {
+	// Vision reports whether the model accepts images
+	Vision bool `json:"vision"`
+	// Tools reports whether the model supports tool calls
+	Tools bool `json:"tools"`
+	// JSONMode reports whether the model supports JSON or schema-constrained output
+	JSONMode bool `json:"json_mode"`
+}
+
+// ContextWindow returns the number of input tokens the model accepts; zero when unknown.
+func (m *ModelInfo) ContextWindow() int {
+	if m == nil {
+		return 0
+	}
+	if m.ContextLength > 0 {
+		return m.ContextLength
+	}
+	return m.InputTokenLimit
 }
 
 // ModelRegistration tracks a model's availability
@@ -71,6 +94,8 @@ type ModelRegistry struct {
 	clientModels map[string][]string
 	// clientProviders maps client ID to its provider identifier
 	clientProviders map[string]string
+	// clientModelInfo maps client ID to the metadata of each model it provides
+	clientModelInfo map[string]map[string]*ModelInfo
 	// mutex ensures thread-safe access to the registry
 	mutex *sync.RWMutex
 }
@@ -86,6 +111,7 @@ func GetGlobalRegistry() *ModelRegistry {
 			models:          make(map[string]*ModelRegistration),
 			clientModels:    make(map[string][]string),
 			clientProviders: make(map[string]string),
+			clientModelInfo: make(map[string]map[string]*ModelInfo),
 			mutex:           &sync.RWMutex{},
 		}
 	})
@@ -129,6 +155,11 @@ func (r *ModelRegistry) RegisterClient(clientID, clientProvider string, models [
 	}
 
 	now := time.Now()
+	clientInfo := make(map[string]*ModelInfo, len(newModels))
+	for id, model := range newModels {
+		clientInfo[id] = cloneModelInfo(model)
+	}
+	r.clientModelInfo[clientID] = clientInfo
 
 	oldModels, hadExisting := r.clientModels[clientID]
 	oldProvider, _ := r.clientProviders[clientID]
@@ -415,6 +446,7 @@ func (r *ModelRegistry) unregisterClientInternal(clientID string) {
 	}
 
 	delete(r.clientModels, clientID)
+	delete(r.clientModelInfo, clientID)
 	if hasProvider {
 		delete(r.clientProviders, clientID)
 	}
@@ -595,6 +627,33 @@ func (r *ModelRegistry) GetModelCount(modelID string) int {
 	return 0
 }
 
+// GetClientModelInfo returns the metadata a client registered for a model; nil when the
+// client does not provide it. The result must not be modified.
+func (r *ModelRegistry) GetClientModelInfo(clientID, modelID string) *ModelInfo {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.clientModelInfo[clientID][modelID]
+}
+
+// GetModelContextWindow returns the smallest context window any client registered for a
+// model, so a prompt within it fits every account; zero when no client reports one.
+func (r *ModelRegistry) GetModelContextWindow(modelID string) int {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	smallest := 0
+	for _, models := range r.clientModelInfo {
+		if window := models[modelID].ContextWindow(); window > 0 && (smallest == 0 || window < smallest) {
+			smallest = window
+		}
+	}
+	if smallest == 0 {
+		if registration, exists := r.models[modelID]; exists {
+			smallest = registration.Info.ContextWindow()
+		}
+	}
+	return smallest
+}
+
 // GetModelProviders returns provider identifiers that currently supply the given model
 // Parameters:
 //   - modelID: The model ID to check
@@ -689,6 +748,12 @@ func (r *ModelRegistry) convertModelToMap(model *ModelInfo, handlerType string)
 		if len(model.SupportedParameters) > 0 {
 			result["supported_parameters"] = model.SupportedParameters
 		}
+		if window := model.ContextWindow(); window > 0 {
+			result["context_window"] = window
+		}
+		if model.Capabilities != nil {
+			result["capabilities"] = *model.Capabilities
+		}
 		return result
 
 	case "claude":
@@ -706,6 +771,12 @@ func (r *ModelRegistry) convertModelToMap(model *ModelInfo, handlerType string)
 		if model.DisplayName != "" {
 			result["display_name"] = model.DisplayName
 		}
+		if window := model.ContextWindow(); window > 0 {
+			result["context_window"] = window
+		}
+		if model.Capabilities != nil {
+			result["capabilities"] = *model.Capabilities
+		}
 		return result
 
 	case "gemini":
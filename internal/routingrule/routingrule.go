@@ -0,0 +1,171 @@
+// Package routingrule evaluates the CEL expressions of the routing rules, which pick the
+// model of a request from its properties: the requested model, the inbound API key, its
+// size, tools and images, headers, body fields and the time of day.
+package routingrule
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/ext"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// costLimit bounds the work of evaluating one expression, so a rule iterating over a large
+// request cannot stall it.
+const costLimit = 1_000_000
+
+// Input describes the request the rules are evaluated against.
+type Input struct {
+	// Model is the model requested by the client.
+	Model string
+	// APIKey is the inbound API key of the request.
+	APIKey string
+	// Format is the API format of the request, such as "openai", "claude" or "gemini".
+	Format string
+	// Stream reports whether the response is streamed.
+	Stream bool
+	// Headers are the request headers, keyed by lower-case name.
+	Headers map[string]string
+	// Body is the JSON request body.
+	Body []byte
+	// Tools, Images and JSONOutput describe what the request uses.
+	Tools      bool
+	Images     bool
+	JSONOutput bool
+	// PromptTokens is the estimated size of the prompt.
+	PromptTokens int
+	// Messages is the number of messages, input items or contents of the request.
+	Messages int
+	// Time is the time the request arrived.
+	Time time.Time
+}
+
+// Rules is a compiled list of routing rules. It is safe for concurrent use.
+type Rules struct {
+	rules []rule
+}
+
+type rule struct {
+	name    string
+	model   string
+	program cel.Program
+}
+
+// environment declares the variables rules may use.
+var environment = sync.OnceValues(func() (*cel.Env, error) {
+	return cel.NewEnv(
+		ext.Strings(),
+		cel.Variable("model", cel.StringType),
+		cel.Variable("api_key", cel.StringType),
+		cel.Variable("format", cel.StringType),
+		cel.Variable("stream", cel.BoolType),
+		cel.Variable("headers", cel.MapType(cel.StringType, cel.StringType)),
+		cel.Variable("request", cel.DynType),
+		cel.Variable("tools", cel.BoolType),
+		cel.Variable("images", cel.BoolType),
+		cel.Variable("json_output", cel.BoolType),
+		cel.Variable("prompt_tokens", cel.IntType),
+		cel.Variable("messages", cel.IntType),
+		cel.Variable("now", cel.TimestampType),
+		cel.Variable("hour", cel.IntType),
+		cel.Variable("weekday", cel.IntType),
+	)
+})
+
+// Compile compiles the expressions of rules. Expressions that do not parse, use unknown
+// variables or do not evaluate to a bool are errors.
+func Compile(rules []config.RoutingRule) (*Rules, error) {
+	env, err := environment()
+	if err != nil {
+		return nil, err
+	}
+	out := &Rules{rules: make([]rule, 0, len(rules))}
+	for i := range rules {
+		entry := rules[i]
+		name := entry.Name
+		if name == "" {
+			name = fmt.Sprintf("rule-%d", i+1)
+		}
+		ast, issues := env.Compile(entry.When)
+		if issues != nil && issues.Err() != nil {
+			return nil, fmt.Errorf("routing rule %s: %w", name, issues.Err())
+		}
+		if ast.OutputType() != cel.BoolType {
+			return nil, fmt.Errorf("routing rule %s: expression evaluates to %s, expected bool", name, ast.OutputType())
+		}
+		program, err := env.Program(ast, cel.CostLimit(costLimit))
+		if err != nil {
+			return nil, fmt.Errorf("routing rule %s: %w", name, err)
+		}
+		out.rules = append(out.rules, rule{name: name, model: entry.Model, program: program})
+	}
+	return out, nil
+}
+
+// Len returns the number of rules.
+func (r *Rules) Len() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.rules)
+}
+
+// Route returns the name and model of the first rule matching in. Rules whose expression
+// fails, for instance on a body field the request lacks, do not match; their errors are
+// returned alongside the result so they can be logged.
+func (r *Rules) Route(in Input) (name, model string, matched bool, errs []error) {
+	if r.Len() == 0 {
+		return "", "", false, nil
+	}
+	activation := in.activation()
+	for _, rule := range r.rules {
+		value, _, err := rule.program.Eval(activation)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("routing rule %s: %w", rule.name, err))
+			continue
+		}
+		if ok, isBool := value.Value().(bool); isBool && ok {
+			return rule.name, rule.model, true, errs
+		}
+	}
+	return "", "", false, errs
+}
+
+func (in Input) activation() map[string]any {
+	var body any = map[string]any{}
+	if len(in.Body) > 0 {
+		var decoded any
+		if err := json.Unmarshal(in.Body, &decoded); err == nil && decoded != nil {
+			body = decoded
+		}
+	}
+	headers := make(map[string]string, len(in.Headers))
+	for name, value := range in.Headers {
+		headers[strings.ToLower(name)] = value
+	}
+	now := in.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	return map[string]any{
+		"model":         in.Model,
+		"api_key":       in.APIKey,
+		"format":        in.Format,
+		"stream":        in.Stream,
+		"headers":       headers,
+		"request":       body,
+		"tools":         in.Tools,
+		"images":        in.Images,
+		"json_output":   in.JSONOutput,
+		"prompt_tokens": in.PromptTokens,
+		"messages":      in.Messages,
+		"now":           now,
+		"hour":          now.Hour(),
+		"weekday":       int(now.Weekday()),
+	}
+}
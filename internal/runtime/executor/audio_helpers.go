@@ -0,0 +1,134 @@
+package executor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/tidwall/gjson"
+)
+
+// speechChunkSize is the read size used when relaying synthesized audio to the client.
+const speechChunkSize = 32 * 1024
+
+// transcriptionBody returns the body of an upstream transcription request and its
+// Content-Type, with the model field rewritten to modelOverride when it is set. The body
+// is opened with req.Body when it is set, otherwise it is req.Payload.
+func transcriptionBody(req cliproxyexecutor.Request, contentType, modelOverride string) (io.Reader, string, error) {
+	if req.Body == nil {
+		if modelOverride == "" {
+			return bytes.NewReader(req.Payload), contentType, nil
+		}
+		payload, rewrittenType, err := rewriteMultipartField(req.Payload, contentType, "model", modelOverride)
+		if err != nil {
+			return nil, "", err
+		}
+		return bytes.NewReader(payload), rewrittenType, nil
+	}
+	src, err := req.Body()
+	if err != nil {
+		return nil, "", err
+	}
+	if modelOverride == "" {
+		return src, contentType, nil
+	}
+	return streamMultipartField(src, contentType, "model", modelOverride)
+}
+
+// rewriteMultipartField returns a copy of a multipart/form-data body with the value of
+// field replaced, together with the Content-Type (including boundary) of the new body.
+// Other parts, including uploaded files, are copied unchanged.
+func rewriteMultipartField(body []byte, contentType, field, value string) ([]byte, string, error) {
+	boundary, err := multipartBoundary(contentType)
+	if err != nil {
+		return nil, "", err
+	}
+	var out bytes.Buffer
+	writer := multipart.NewWriter(&out)
+	if err = copyMultipartField(writer, bytes.NewReader(body), boundary, field, value); err != nil {
+		return nil, "", err
+	}
+	return out.Bytes(), writer.FormDataContentType(), nil
+}
+
+// streamMultipartField is rewriteMultipartField for a body read from src: the rewritten
+// body is produced as it is read, without holding the body in memory. src is closed once
+// it has been copied.
+func streamMultipartField(src io.ReadCloser, contentType, field, value string) (io.ReadCloser, string, error) {
+	boundary, err := multipartBoundary(contentType)
+	if err != nil {
+		_ = src.Close()
+		return nil, "", err
+	}
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	go func() {
+		errCopy := copyMultipartField(writer, src, boundary, field, value)
+		_ = src.Close()
+		_ = pw.CloseWithError(errCopy)
+	}()
+	return pr, writer.FormDataContentType(), nil
+}
+
+// multipartBoundary returns the boundary of a multipart/form-data Content-Type.
+func multipartBoundary(contentType string) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "multipart/form-data" || params["boundary"] == "" {
+		return "", statusErr{code: http.StatusBadRequest, msg: "request must be multipart/form-data"}
+	}
+	return params["boundary"], nil
+}
+
+// copyMultipartField copies the parts of the multipart body src into writer, replacing the
+// value of field, and closes writer.
+func copyMultipartField(writer *multipart.Writer, src io.Reader, boundary, field, value string) error {
+	reader := multipart.NewReader(src, boundary)
+	for {
+		part, errPart := reader.NextPart()
+		if errors.Is(errPart, io.EOF) {
+			break
+		}
+		if errPart != nil {
+			return statusErr{code: http.StatusBadRequest, msg: fmt.Sprintf("invalid multipart body: %v", errPart)}
+		}
+		header := make(textproto.MIMEHeader, len(part.Header))
+		for key, values := range part.Header {
+			header[key] = append([]string(nil), values...)
+		}
+		target, errCreate := writer.CreatePart(header)
+		if errCreate != nil {
+			return errCreate
+		}
+		var err error
+		if part.FormName() == field && part.FileName() == "" {
+			_, err = io.WriteString(target, value)
+		} else {
+			_, err = io.Copy(target, part)
+		}
+		if err != nil {
+			return statusErr{code: http.StatusBadRequest, msg: fmt.Sprintf("invalid multipart body: %v", err)}
+		}
+	}
+	return writer.Close()
+}
+
+// parseOpenAITranscriptionUsage reads the token usage of a JSON transcription response.
+// Duration based usage and plain text responses yield an empty detail.
+func parseOpenAITranscriptionUsage(data []byte) usage.Detail {
+	usageNode := gjson.GetBytes(data, "usage")
+	if !usageNode.Exists() || usageNode.Get("type").String() == "duration" {
+		return usage.Detail{}
+	}
+	return usage.Detail{
+		InputTokens:  usageNode.Get("input_tokens").Int(),
+		OutputTokens: usageNode.Get("output_tokens").Int(),
+		TotalTokens:  usageNode.Get("total_tokens").Int(),
+	}
+}
@@ -0,0 +1,370 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	defaultAzureOpenAIAPIVersion = "2024-10-21"
+	defaultAzureAuthorityHost    = "https://login.microsoftonline.com"
+	azureCognitiveServicesScope  = "https://cognitiveservices.azure.com/.default"
+)
+
+// AzureOpenAIExecutor is a stateless executor for Azure OpenAI resources. Requests use the
+// OpenAI Chat Completions and embeddings schemas and are sent to the deployment mapped to
+// the requested model, with the api-version of the resource, authorized with the resource
+// API key or a Microsoft Entra ID token.
+type AzureOpenAIExecutor struct {
+	cfg *config.Config
+}
+
+func NewAzureOpenAIExecutor(cfg *config.Config) *AzureOpenAIExecutor {
+	return &AzureOpenAIExecutor{cfg: cfg}
+}
+
+func (e *AzureOpenAIExecutor) Identifier() string { return "azure-openai" }
+
+func (e *AzureOpenAIExecutor) PrepareRequest(_ *http.Request, _ *cliproxyauth.Auth) error { return nil }
+
+func (e *AzureOpenAIExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (resp cliproxyexecutor.Response, err error) {
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	from := opts.SourceFormat
+	to := sdktranslator.FromString("openai")
+	body := sdktranslator.TranslateRequest(ctx, from, to, req.Model, bytes.Clone(req.Payload), false)
+
+	httpResp, err := e.send(ctx, auth, req.Model, "chat/completions", body, false)
+	if err != nil {
+		return resp, err
+	}
+	defer func() {
+		if errClose := httpResp.Body.Close(); errClose != nil {
+			log.Errorf("azure openai executor: close response body error: %v", errClose)
+		}
+	}()
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		recordAPIResponseError(ctx, e.cfg, err)
+		return resp, err
+	}
+	appendAPIResponseChunk(ctx, e.cfg, data)
+	reporter.publish(ctx, parseOpenAIUsage(data))
+	reporter.observeOutput(data)
+	reporter.publishEstimate(ctx, req.Payload)
+	var param any
+	out := sdktranslator.TranslateNonStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), body, data, &param)
+	resp = cliproxyexecutor.Response{Payload: []byte(out)}
+	return resp, nil
+}
+
+func (e *AzureOpenAIExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (stream <-chan cliproxyexecutor.StreamChunk, err error) {
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	from := opts.SourceFormat
+	to := sdktranslator.FromString("openai")
+	body := sdktranslator.TranslateRequest(ctx, from, to, req.Model, bytes.Clone(req.Payload), true)
+
+	httpResp, err := e.send(ctx, auth, req.Model, "chat/completions", body, true)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan cliproxyexecutor.StreamChunk)
+	stream = out
+	go func() {
+		defer close(out)
+		defer func() {
+			if errClose := httpResp.Body.Close(); errClose != nil {
+				log.Errorf("azure openai executor: close response body error: %v", errClose)
+			}
+		}()
+		scanner := bufio.NewScanner(httpResp.Body)
+		buf := make([]byte, 20_971_520)
+		scanner.Buffer(buf, 20_971_520)
+		var param any
+		for scanner.Scan() {
+			reporter.markFirstChunk()
+			line := scanner.Bytes()
+			appendAPIResponseChunk(ctx, e.cfg, line)
+			if detail, ok := parseOpenAIStreamUsage(line); ok {
+				reporter.publish(ctx, detail)
+			}
+			reporter.observeOutput(line)
+			if len(line) == 0 {
+				continue
+			}
+			chunks := sdktranslator.TranslateStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), body, bytes.Clone(line), &param)
+			for i := range chunks {
+				out <- cliproxyexecutor.StreamChunk{Payload: []byte(chunks[i])}
+			}
+		}
+		if errScan := scanner.Err(); errScan != nil {
+			recordAPIResponseError(ctx, e.cfg, errScan)
+			reporter.publishFailure(ctx, errScan)
+			out <- cliproxyexecutor.StreamChunk{Err: errScan}
+		}
+		reporter.publishEstimate(ctx, req.Payload)
+	}()
+	return stream, nil
+}
+
+// Embed forwards an OpenAI embeddings request to the embeddings endpoint of the deployment.
+func (e *AzureOpenAIExecutor) Embed(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, _ cliproxyexecutor.Options) (resp cliproxyexecutor.Response, err error) {
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	httpResp, err := e.send(ctx, auth, req.Model, "embeddings", bytes.Clone(req.Payload), false)
+	if err != nil {
+		return resp, err
+	}
+	defer func() {
+		if errClose := httpResp.Body.Close(); errClose != nil {
+			log.Errorf("azure openai executor: close response body error: %v", errClose)
+		}
+	}()
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		recordAPIResponseError(ctx, e.cfg, err)
+		return resp, err
+	}
+	appendAPIResponseChunk(ctx, e.cfg, data)
+	reporter.publish(ctx, parseOpenAIEmbeddingsUsage(data))
+	// Report the requested model rather than the deployment.
+	data, _ = sjson.SetBytes(data, "model", req.Model)
+	resp = cliproxyexecutor.Response{Payload: data}
+	return resp, nil
+}
+
+// send posts body to the operation endpoint, such as chat/completions, of the deployment
+// mapped to model and returns the response when its status is 2xx.
+func (e *AzureOpenAIExecutor) send(ctx context.Context, auth *cliproxyauth.Auth, model, operation string, body []byte, stream bool) (*http.Response, error) {
+	attrs := map[string]string{}
+	if auth != nil && auth.Attributes != nil {
+		attrs = auth.Attributes
+	}
+	baseURL := strings.TrimSuffix(attrs["base_url"], "/")
+	if baseURL == "" {
+		return nil, statusErr{code: http.StatusUnauthorized, msg: "missing azure openai base-url"}
+	}
+	apiVersion := attrs["api_version"]
+	if apiVersion == "" {
+		apiVersion = defaultAzureOpenAIAPIVersion
+	}
+	deployment := e.resolveDeployment(model, auth)
+	endpoint := fmt.Sprintf("%s/openai/deployments/%s/%s?api-version=%s", baseURL, url.PathEscape(deployment), operation, url.QueryEscape(apiVersion))
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("User-Agent", "cli-proxy-azure-openai")
+	if stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+		httpReq.Header.Set("Cache-Control", "no-cache")
+	}
+	if apiKey := attrs["api_key"]; apiKey != "" {
+		httpReq.Header.Set("Api-Key", apiKey)
+	} else {
+		token, errToken := e.bearerToken(ctx, auth)
+		if errToken != nil {
+			return nil, errToken
+		}
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+	}
+	var authID, authLabel, authType, authValue string
+	if auth != nil {
+		authID = auth.ID
+		authLabel = auth.Label
+		authType, authValue = auth.AccountInfo()
+	}
+	recordAPIRequest(ctx, e.cfg, upstreamRequestLog{
+		URL:       endpoint,
+		Method:    http.MethodPost,
+		Headers:   httpReq.Header.Clone(),
+		Body:      body,
+		Provider:  e.Identifier(),
+		AuthID:    authID,
+		AuthLabel: authLabel,
+		AuthType:  authType,
+		AuthValue: authValue,
+	})
+
+	httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
+	httpResp, err := httpClient.Do(httpReq)
+	if err != nil {
+		recordAPIResponseError(ctx, e.cfg, err)
+		return nil, err
+	}
+	recordAPIResponseMetadata(ctx, e.cfg, httpResp.StatusCode, httpResp.Header.Clone())
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		b, _ := io.ReadAll(httpResp.Body)
+		appendAPIResponseChunk(ctx, e.cfg, b)
+		log.Debugf("request error, error status: %d, error body: %s", httpResp.StatusCode, string(b))
+		if errClose := httpResp.Body.Close(); errClose != nil {
+			log.Errorf("azure openai executor: close response body error: %v", errClose)
+		}
+		return nil, statusErr{code: httpResp.StatusCode, msg: string(b), retryAfter: parseRetryAfter(httpResp.Header)}
+	}
+	return httpResp, nil
+}
+
+func (e *AzureOpenAIExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	from := opts.SourceFormat
+	to := sdktranslator.FromString("openai")
+	body := sdktranslator.TranslateRequest(ctx, from, to, req.Model, bytes.Clone(req.Payload), false)
+
+	enc, err := tokenizerForModel(req.Model)
+	if err != nil {
+		return cliproxyexecutor.Response{}, fmt.Errorf("azure openai executor: tokenizer init failed: %w", err)
+	}
+
+	count, err := countOpenAIChatTokens(enc, body)
+	if err != nil {
+		return cliproxyexecutor.Response{}, fmt.Errorf("azure openai executor: token counting failed: %w", err)
+	}
+
+	usageJSON := buildOpenAIUsageJSON(count)
+	translated := sdktranslator.TranslateTokenCount(ctx, to, from, count, usageJSON)
+	return cliproxyexecutor.Response{Payload: []byte(translated)}, nil
+}
+
+// Refresh is a no-op: Entra ID tokens are obtained and renewed when requests are sent.
+func (e *AzureOpenAIExecutor) Refresh(_ context.Context, auth *cliproxyauth.Auth) (*cliproxyauth.Auth, error) {
+	log.Debugf("azure openai executor: refresh called")
+	return auth, nil
+}
+
+// resolveDeployment returns the deployment configured for model, or model itself, which
+// then has to be the name of a deployment.
+func (e *AzureOpenAIExecutor) resolveDeployment(model string, auth *cliproxyauth.Auth) string {
+	entry := e.resolveAzureConfig(auth)
+	if entry == nil {
+		return model
+	}
+	for i := range entry.Deployments {
+		deployment := entry.Deployments[i]
+		if deployment.Alias != "" {
+			if strings.EqualFold(deployment.Alias, model) && deployment.Name != "" {
+				return deployment.Name
+			}
+			continue
+		}
+		if deployment.Name != "" && strings.EqualFold(deployment.Name, model) {
+			return deployment.Name
+		}
+	}
+	return model
+}
+
+func (e *AzureOpenAIExecutor) resolveAzureConfig(auth *cliproxyauth.Auth) *config.AzureOpenAIKey {
+	if auth == nil || auth.Attributes == nil || e.cfg == nil {
+		return nil
+	}
+	baseURL, apiKey, clientID := auth.Attributes["base_url"], auth.Attributes["api_key"], auth.Attributes["client_id"]
+	for i := range e.cfg.AzureOpenAI {
+		entry := &e.cfg.AzureOpenAI[i]
+		if entry.BaseURL != baseURL {
+			continue
+		}
+		if (apiKey != "" && entry.APIKey == apiKey) || (apiKey == "" && entry.AzureAD.ClientID == clientID) {
+			return entry
+		}
+	}
+	return nil
+}
+
+// azureADTokens caches Entra ID access tokens by tenant and client, shared by the executor
+// instances that are created as the configuration is reloaded.
+var azureADTokens = struct {
+	sync.Mutex
+	tokens map[string]azureADToken
+}{tokens: make(map[string]azureADToken)}
+
+type azureADToken struct {
+	value   string
+	expires time.Time
+}
+
+// bearerToken returns an Entra ID access token for the service principal of auth, using
+// the client credentials grant. Tokens are reused until five minutes before they expire.
+func (e *AzureOpenAIExecutor) bearerToken(ctx context.Context, auth *cliproxyauth.Auth) (string, error) {
+	if auth == nil || auth.Attributes == nil {
+		return "", statusErr{code: http.StatusUnauthorized, msg: "missing azure openai credentials"}
+	}
+	attrs := auth.Attributes
+	tenantID, clientID, clientSecret := attrs["tenant_id"], attrs["client_id"], attrs["client_secret"]
+	if tenantID == "" || clientID == "" || clientSecret == "" {
+		return "", statusErr{code: http.StatusUnauthorized, msg: "missing azure openai credentials"}
+	}
+	authority := strings.TrimSuffix(attrs["authority_host"], "/")
+	if authority == "" {
+		authority = defaultAzureAuthorityHost
+	}
+	key := authority + "|" + tenantID + "|" + clientID
+	azureADTokens.Lock()
+	cached, ok := azureADTokens.tokens[key]
+	azureADTokens.Unlock()
+	if ok && time.Until(cached.expires) > 5*time.Minute {
+		return cached.value, nil
+	}
+
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {clientID},
+		"client_secret": {clientSecret},
+		"scope":         {azureCognitiveServicesScope},
+	}
+	tokenURL := authority + "/" + url.PathEscape(tenantID) + "/oauth2/v2.0/token"
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpReq.Header.Set("Accept", "application/json")
+	httpResp, err := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0).Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("azure openai executor: token request failed: %w", err)
+	}
+	defer func() {
+		if errClose := httpResp.Body.Close(); errClose != nil {
+			log.Errorf("azure openai executor: close token response body error: %v", errClose)
+		}
+	}()
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return "", fmt.Errorf("azure openai executor: read token response: %w", err)
+	}
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		return "", statusErr{code: http.StatusUnauthorized, msg: fmt.Sprintf("azure openai executor: token request failed with status %d: %s", httpResp.StatusCode, string(data))}
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err = json.Unmarshal(data, &token); err != nil || token.AccessToken == "" {
+		return "", statusErr{code: http.StatusUnauthorized, msg: "azure openai executor: token response has no access_token"}
+	}
+	azureADTokens.Lock()
+	azureADTokens.tokens[key] = azureADToken{value: token.AccessToken, expires: time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)}
+	azureADTokens.Unlock()
+	return token.AccessToken, nil
+}
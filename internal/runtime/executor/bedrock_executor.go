@@ -0,0 +1,295 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+// BedrockExecutor is a stateless executor for the AWS Bedrock Converse API. Requests are
+// translated to OpenAI Chat Completions first and from there to Converse, so every model
+// Bedrock serves through Converse, Anthropic and Llama models among them, is reachable from
+// all client formats. Requests are signed with SigV4, or authorized with a Bedrock API key.
+type BedrockExecutor struct {
+	cfg *config.Config
+}
+
+func NewBedrockExecutor(cfg *config.Config) *BedrockExecutor { return &BedrockExecutor{cfg: cfg} }
+
+func (e *BedrockExecutor) Identifier() string { return "bedrock" }
+
+func (e *BedrockExecutor) PrepareRequest(_ *http.Request, _ *cliproxyauth.Auth) error { return nil }
+
+func (e *BedrockExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (resp cliproxyexecutor.Response, err error) {
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	from := opts.SourceFormat
+	openAI := sdktranslator.FromString("openai")
+	to := sdktranslator.FromString("bedrock")
+	modelID := e.resolveUpstreamModel(req.Model, auth)
+	openAIBody := sdktranslator.TranslateRequest(ctx, from, openAI, req.Model, bytes.Clone(req.Payload), false)
+	body := sdktranslator.TranslateRequest(ctx, openAI, to, modelID, openAIBody, false)
+
+	httpResp, err := e.send(ctx, auth, modelID, "converse", body)
+	if err != nil {
+		return resp, err
+	}
+	defer func() {
+		if errClose := httpResp.Body.Close(); errClose != nil {
+			log.Errorf("bedrock executor: close response body error: %v", errClose)
+		}
+	}()
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		recordAPIResponseError(ctx, e.cfg, err)
+		return resp, err
+	}
+	appendAPIResponseChunk(ctx, e.cfg, data)
+	if usageNode := gjson.GetBytes(data, "usage"); usageNode.Exists() {
+		reporter.publish(ctx, parseBedrockUsage(usageNode))
+	}
+	var param any
+	translated := sdktranslator.TranslateNonStream(ctx, to, openAI, req.Model, openAIBody, body, data, &param)
+	reporter.observeOutput([]byte(translated))
+	reporter.publishEstimate(ctx, req.Payload)
+	var clientParam any
+	out := sdktranslator.TranslateNonStream(ctx, openAI, from, req.Model, bytes.Clone(opts.OriginalRequest), openAIBody, []byte(translated), &clientParam)
+	resp = cliproxyexecutor.Response{Payload: []byte(out)}
+	return resp, nil
+}
+
+func (e *BedrockExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (stream <-chan cliproxyexecutor.StreamChunk, err error) {
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	from := opts.SourceFormat
+	openAI := sdktranslator.FromString("openai")
+	to := sdktranslator.FromString("bedrock")
+	modelID := e.resolveUpstreamModel(req.Model, auth)
+	openAIBody := sdktranslator.TranslateRequest(ctx, from, openAI, req.Model, bytes.Clone(req.Payload), true)
+	body := sdktranslator.TranslateRequest(ctx, openAI, to, modelID, openAIBody, true)
+
+	httpResp, err := e.send(ctx, auth, modelID, "converse-stream", body)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan cliproxyexecutor.StreamChunk)
+	stream = out
+	go func() {
+		defer close(out)
+		defer func() {
+			if errClose := httpResp.Body.Close(); errClose != nil {
+				log.Errorf("bedrock executor: close response body error: %v", errClose)
+			}
+		}()
+		var param, clientParam any
+		emit := func(line []byte) {
+			chunks := sdktranslator.TranslateStream(ctx, openAI, from, req.Model, bytes.Clone(opts.OriginalRequest), openAIBody, line, &clientParam)
+			for i := range chunks {
+				out <- cliproxyexecutor.StreamChunk{Payload: []byte(chunks[i])}
+			}
+		}
+		events := newAWSEventReader(httpResp.Body)
+		for {
+			message, errNext := events.Next()
+			if errNext == io.EOF {
+				break
+			}
+			if errNext != nil {
+				recordAPIResponseError(ctx, e.cfg, errNext)
+				reporter.publishFailure(ctx, errNext)
+				out <- cliproxyexecutor.StreamChunk{Err: errNext}
+				return
+			}
+			reporter.markFirstChunk()
+			appendAPIResponseChunk(ctx, e.cfg, message.Payload)
+			if messageType := message.Headers[":message-type"]; messageType != "event" {
+				errEvent := bedrockStreamError(message)
+				recordAPIResponseError(ctx, e.cfg, errEvent)
+				reporter.publishFailure(ctx, errEvent)
+				out <- cliproxyexecutor.StreamChunk{Err: errEvent}
+				return
+			}
+			eventType := message.Headers[":event-type"]
+			if eventType == "" || !gjson.ValidBytes(message.Payload) {
+				continue
+			}
+			event := fmt.Appendf(nil, `{%q:%s}`, eventType, message.Payload)
+			if usageNode := gjson.GetBytes(event, "metadata.usage"); usageNode.Exists() {
+				reporter.publish(ctx, parseBedrockUsage(usageNode))
+			}
+			translated := sdktranslator.TranslateStream(ctx, to, openAI, req.Model, openAIBody, body, event, &param)
+			for i := range translated {
+				line := []byte("data: " + translated[i])
+				reporter.observeOutput(line)
+				emit(line)
+			}
+		}
+		emit([]byte("data: [DONE]"))
+		reporter.publishEstimate(ctx, req.Payload)
+	}()
+	return stream, nil
+}
+
+// send posts a Converse request for modelID to the action endpoint, converse or
+// converse-stream, and returns the response when its status is 2xx.
+func (e *BedrockExecutor) send(ctx context.Context, auth *cliproxyauth.Auth, modelID, action string, body []byte) (*http.Response, error) {
+	creds, region, apiKey, baseURL := bedrockCreds(auth)
+	if region == "" {
+		return nil, statusErr{code: http.StatusUnauthorized, msg: "bedrock executor: missing region"}
+	}
+	if baseURL == "" {
+		baseURL = fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com", region)
+	}
+	url := strings.TrimSuffix(baseURL, "/") + "/model/" + awsEscape(modelID) + "/" + action
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if action == "converse-stream" {
+		httpReq.Header.Set("Accept", "application/vnd.amazon.eventstream")
+	} else {
+		httpReq.Header.Set("Accept", "application/json")
+	}
+	if apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+apiKey)
+	} else {
+		signAWSRequest(httpReq, body, creds, region, "bedrock", time.Now())
+	}
+	var authID, authLabel, authType, authValue string
+	if auth != nil {
+		authID = auth.ID
+		authLabel = auth.Label
+		authType, authValue = auth.AccountInfo()
+	}
+	recordAPIRequest(ctx, e.cfg, upstreamRequestLog{
+		URL:       url,
+		Method:    http.MethodPost,
+		Headers:   httpReq.Header.Clone(),
+		Body:      body,
+		Provider:  e.Identifier(),
+		AuthID:    authID,
+		AuthLabel: authLabel,
+		AuthType:  authType,
+		AuthValue: authValue,
+	})
+
+	httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
+	httpResp, err := httpClient.Do(httpReq)
+	if err != nil {
+		recordAPIResponseError(ctx, e.cfg, err)
+		return nil, err
+	}
+	recordAPIResponseMetadata(ctx, e.cfg, httpResp.StatusCode, httpResp.Header.Clone())
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		b, _ := io.ReadAll(httpResp.Body)
+		appendAPIResponseChunk(ctx, e.cfg, b)
+		log.Debugf("request error, error status: %d, error body: %s", httpResp.StatusCode, string(b))
+		if errClose := httpResp.Body.Close(); errClose != nil {
+			log.Errorf("bedrock executor: close response body error: %v", errClose)
+		}
+		return nil, statusErr{code: httpResp.StatusCode, msg: string(b), retryAfter: parseRetryAfter(httpResp.Header)}
+	}
+	return httpResp, nil
+}
+
+func (e *BedrockExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	from := opts.SourceFormat
+	to := sdktranslator.FromString("openai")
+	body := sdktranslator.TranslateRequest(ctx, from, to, req.Model, bytes.Clone(req.Payload), false)
+
+	enc, err := tokenizerForModel(e.resolveUpstreamModel(req.Model, auth))
+	if err != nil {
+		return cliproxyexecutor.Response{}, fmt.Errorf("bedrock executor: tokenizer init failed: %w", err)
+	}
+
+	count, err := countOpenAIChatTokens(enc, body)
+	if err != nil {
+		return cliproxyexecutor.Response{}, fmt.Errorf("bedrock executor: token counting failed: %w", err)
+	}
+
+	usageJSON := buildOpenAIUsageJSON(count)
+	translated := sdktranslator.TranslateTokenCount(ctx, to, from, count, usageJSON)
+	return cliproxyexecutor.Response{Payload: []byte(translated)}, nil
+}
+
+func (e *BedrockExecutor) Refresh(_ context.Context, auth *cliproxyauth.Auth) (*cliproxyauth.Auth, error) {
+	log.Debugf("bedrock executor: refresh called")
+	// Static AWS credentials and API keys have nothing to refresh.
+	return auth, nil
+}
+
+// resolveUpstreamModel returns the Bedrock model ID configured for alias, or alias itself.
+func (e *BedrockExecutor) resolveUpstreamModel(alias string, auth *cliproxyauth.Auth) string {
+	entry := e.resolveBedrockConfig(auth)
+	if entry == nil {
+		return alias
+	}
+	for i := range entry.Models {
+		model := entry.Models[i]
+		if model.Alias != "" {
+			if strings.EqualFold(model.Alias, alias) && model.Name != "" {
+				return model.Name
+			}
+			continue
+		}
+		if model.Name != "" && strings.EqualFold(model.Name, alias) {
+			return model.Name
+		}
+	}
+	return alias
+}
+
+func (e *BedrockExecutor) resolveBedrockConfig(auth *cliproxyauth.Auth) *config.BedrockKey {
+	if auth == nil || auth.Attributes == nil || e.cfg == nil {
+		return nil
+	}
+	creds, region, apiKey, baseURL := bedrockCreds(auth)
+	for i := range e.cfg.BedrockKey {
+		entry := &e.cfg.BedrockKey[i]
+		if entry.Region != region || entry.BaseURL != baseURL {
+			continue
+		}
+		if (apiKey != "" && entry.APIKey == apiKey) || (apiKey == "" && entry.AccessKeyID == creds.AccessKeyID) {
+			return entry
+		}
+	}
+	return nil
+}
+
+func bedrockCreds(a *cliproxyauth.Auth) (creds awsCredentials, region, apiKey, baseURL string) {
+	if a == nil || a.Attributes == nil {
+		return
+	}
+	creds = awsCredentials{
+		AccessKeyID:     a.Attributes["access_key_id"],
+		SecretAccessKey: a.Attributes["secret_access_key"],
+		SessionToken:    a.Attributes["session_token"],
+	}
+	return creds, a.Attributes["region"], a.Attributes["api_key"], a.Attributes["base_url"]
+}
+
+// bedrockStreamError converts an exception or error message of a ConverseStream.
+func bedrockStreamError(message *awsEventMessage) error {
+	if exceptionType := message.Headers[":exception-type"]; exceptionType != "" {
+		msg := gjson.GetBytes(message.Payload, "message").String()
+		if msg == "" {
+			msg = string(message.Payload)
+		}
+		return statusErr{code: bedrockExceptionStatus(exceptionType), msg: fmt.Sprintf("%s: %s", exceptionType, msg)}
+	}
+	return statusErr{code: http.StatusBadGateway, msg: fmt.Sprintf("%s: %s", message.Headers[":error-code"], message.Headers[":error-message"])}
+}
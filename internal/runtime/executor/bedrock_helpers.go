@@ -0,0 +1,241 @@
+package executor
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// awsCredentials holds the AWS credentials used to sign a request with SigV4.
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// signAWSRequest signs req for service in region with AWS Signature Version 4, setting the
+// X-Amz-Date, X-Amz-Security-Token and Authorization headers. body is the request payload.
+func signAWSRequest(req *http.Request, body []byte, creds awsCredentials, region, service string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	if req.Host != "" {
+		headers["host"] = req.Host
+	}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name != "content-type" && !strings.HasPrefix(name, "x-amz-") {
+			continue
+		}
+		trimmed := make([]string, len(values))
+		for i, v := range values {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+		headers[name] = strings.Join(trimmed, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	// Services other than S3 sign the path with each segment escaped once more.
+	segments := strings.Split(req.URL.EscapedPath(), "/")
+	for i := range segments {
+		segments[i] = awsEscape(segments[i])
+	}
+	canonicalURI := strings.Join(segments, "/")
+	if canonicalURI == "" {
+		canonicalURI = "/"
+	}
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI,
+		awsCanonicalQuery(req),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// awsCanonicalQuery returns the query of req with escaped, sorted parameters.
+func awsCanonicalQuery(req *http.Request) string {
+	query := req.URL.Query()
+	pairs := make([]string, 0, len(query))
+	for name, values := range query {
+		for _, v := range values {
+			pairs = append(pairs, awsEscape(name)+"="+awsEscape(v))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// awsEscape percent-encodes every byte of s except the unreserved characters, as SigV4 requires.
+func awsEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// awsEventMessage is a message of the AWS event stream encoding.
+type awsEventMessage struct {
+	Headers map[string]string
+	Payload []byte
+}
+
+// maxAWSEventMessageSize bounds the messages read from an event stream.
+const maxAWSEventMessageSize = 24 << 20
+
+// awsEventReader decodes the binary AWS event stream encoding
+// (application/vnd.amazon.eventstream) used by streaming Bedrock responses.
+type awsEventReader struct {
+	r *bufio.Reader
+}
+
+func newAWSEventReader(r io.Reader) *awsEventReader {
+	return &awsEventReader{r: bufio.NewReader(r)}
+}
+
+// Next reads the next message. It returns io.EOF at the end of the stream.
+func (d *awsEventReader) Next() (*awsEventMessage, error) {
+	prelude := make([]byte, 12)
+	if _, err := io.ReadFull(d.r, prelude); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("event stream: truncated prelude")
+		}
+		return nil, err
+	}
+	totalLength := binary.BigEndian.Uint32(prelude[0:4])
+	headersLength := binary.BigEndian.Uint32(prelude[4:8])
+	if crc32.ChecksumIEEE(prelude[0:8]) != binary.BigEndian.Uint32(prelude[8:12]) {
+		return nil, fmt.Errorf("event stream: prelude checksum mismatch")
+	}
+	if totalLength < 16 || totalLength > maxAWSEventMessageSize || headersLength > totalLength-16 {
+		return nil, fmt.Errorf("event stream: invalid message length %d", totalLength)
+	}
+	message := make([]byte, totalLength)
+	copy(message, prelude)
+	if _, err := io.ReadFull(d.r, message[12:]); err != nil {
+		return nil, fmt.Errorf("event stream: truncated message: %w", err)
+	}
+	if crc32.ChecksumIEEE(message[:totalLength-4]) != binary.BigEndian.Uint32(message[totalLength-4:]) {
+		return nil, fmt.Errorf("event stream: message checksum mismatch")
+	}
+	headers, err := parseAWSEventHeaders(message[12 : 12+headersLength])
+	if err != nil {
+		return nil, err
+	}
+	return &awsEventMessage{Headers: headers, Payload: message[12+headersLength : totalLength-4]}, nil
+}
+
+// parseAWSEventHeaders decodes the headers of an event stream message. Only string values
+// are kept; the other value types are skipped.
+func parseAWSEventHeaders(data []byte) (map[string]string, error) {
+	headers := make(map[string]string)
+	for len(data) > 0 {
+		nameLength := int(data[0])
+		if len(data) < 2+nameLength {
+			return nil, fmt.Errorf("event stream: truncated header")
+		}
+		name := string(data[1 : 1+nameLength])
+		valueType := data[1+nameLength]
+		data = data[2+nameLength:]
+		size := 0
+		switch valueType {
+		case 0, 1: // boolean true and false carry no value
+		case 2:
+			size = 1
+		case 3:
+			size = 2
+		case 4:
+			size = 4
+		case 5, 8:
+			size = 8
+		case 9:
+			size = 16
+		case 6, 7:
+			if len(data) < 2 {
+				return nil, fmt.Errorf("event stream: truncated header")
+			}
+			size = 2 + int(binary.BigEndian.Uint16(data[0:2]))
+		default:
+			return nil, fmt.Errorf("event stream: unknown header type %d", valueType)
+		}
+		if len(data) < size {
+			return nil, fmt.Errorf("event stream: truncated header")
+		}
+		if valueType == 7 {
+			headers[name] = string(data[2:size])
+		}
+		data = data[size:]
+	}
+	return headers, nil
+}
+
+// bedrockExceptionStatus maps the Bedrock exception of a stream to an HTTP status code.
+func bedrockExceptionStatus(exceptionType string) int {
+	switch exceptionType {
+	case "throttlingException":
+		return http.StatusTooManyRequests
+	case "validationException":
+		return http.StatusBadRequest
+	case "accessDeniedException":
+		return http.StatusForbidden
+	case "resourceNotFoundException":
+		return http.StatusNotFound
+	case "serviceUnavailableException":
+		return http.StatusServiceUnavailable
+	case "modelTimeoutException":
+		return http.StatusGatewayTimeout
+	case "internalServerException":
+		return http.StatusInternalServerError
+	default:
+		return http.StatusBadGateway
+	}
+}
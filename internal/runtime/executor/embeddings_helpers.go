@@ -0,0 +1,130 @@
+package executor
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"math"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// geminiEmbedBatchSize is the maximum number of inputs accepted by one batchEmbedContents call.
+const geminiEmbedBatchSize = 100
+
+// embeddingInputs extracts the texts of an OpenAI embeddings request.
+// Token array inputs are rejected because they cannot be translated to other providers.
+func embeddingInputs(payload []byte) ([]string, error) {
+	input := gjson.GetBytes(payload, "input")
+	switch {
+	case input.Type == gjson.String:
+		return []string{input.String()}, nil
+	case input.IsArray():
+		items := input.Array()
+		texts := make([]string, 0, len(items))
+		for _, item := range items {
+			if item.Type != gjson.String {
+				return nil, statusErr{code: http.StatusBadRequest, msg: "embeddings input must be a string or an array of strings for this provider"}
+			}
+			texts = append(texts, item.String())
+		}
+		if len(texts) == 0 {
+			return nil, statusErr{code: http.StatusBadRequest, msg: "embeddings input must not be empty"}
+		}
+		return texts, nil
+	default:
+		return nil, statusErr{code: http.StatusBadRequest, msg: "embeddings input is required"}
+	}
+}
+
+// buildGeminiBatchEmbedRequest converts a batch of texts to a Gemini batchEmbedContents body.
+func buildGeminiBatchEmbedRequest(model string, texts []string, dimensions int64) []byte {
+	out := []byte(`{"requests":[]}`)
+	for _, text := range texts {
+		entry := `{"model":"","content":{"parts":[{"text":""}]}}`
+		entry, _ = sjson.Set(entry, "model", "models/"+model)
+		entry, _ = sjson.Set(entry, "content.parts.0.text", text)
+		if dimensions > 0 {
+			entry, _ = sjson.Set(entry, "outputDimensionality", dimensions)
+		}
+		out, _ = sjson.SetRawBytes(out, "requests.-1", []byte(entry))
+	}
+	return out
+}
+
+// appendGeminiEmbeddings appends the vectors of a batchEmbedContents response to an
+// OpenAI embeddings response, continuing the index sequence.
+func appendGeminiEmbeddings(out []byte, data []byte, base64Format bool) []byte {
+	index := len(gjson.GetBytes(out, "data").Array())
+	for _, embedding := range gjson.GetBytes(data, "embeddings").Array() {
+		entry := []byte(`{"object":"embedding","index":0}`)
+		entry, _ = sjson.SetBytes(entry, "index", index)
+		values := embedding.Get("values")
+		if base64Format {
+			entry, _ = sjson.SetBytes(entry, "embedding", encodeEmbeddingBase64(values))
+		} else {
+			entry, _ = sjson.SetRawBytes(entry, "embedding", []byte(values.Raw))
+		}
+		out, _ = sjson.SetRawBytes(out, "data.-1", entry)
+		index++
+	}
+	return out
+}
+
+// encodeEmbeddingBase64 packs a vector as little-endian float32 values, matching the
+// OpenAI encoding_format=base64 output.
+func encodeEmbeddingBase64(values gjson.Result) string {
+	items := values.Array()
+	buf := make([]byte, 4*len(items))
+	for i, value := range items {
+		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(float32(value.Float())))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+// estimateEmbeddingTokens approximates the input tokens of texts for providers that do not
+// report embedding usage, using the common four characters per token heuristic.
+func estimateEmbeddingTokens(texts []string) int64 {
+	var tokens int64
+	for _, text := range texts {
+		runes := int64(utf8.RuneCountInString(text))
+		tokens += max((runes+3)/4, 1)
+	}
+	return tokens
+}
+
+// newOpenAIEmbeddingsResponse starts an OpenAI embeddings response for model.
+func newOpenAIEmbeddingsResponse(model string) []byte {
+	out := []byte(`{"object":"list","data":[],"model":"","usage":{"prompt_tokens":0,"total_tokens":0}}`)
+	out, _ = sjson.SetBytes(out, "model", model)
+	return out
+}
+
+// setEmbeddingsUsage fills the usage block of an OpenAI embeddings response.
+func setEmbeddingsUsage(out []byte, tokens int64) []byte {
+	out, _ = sjson.SetBytes(out, "usage.prompt_tokens", tokens)
+	out, _ = sjson.SetBytes(out, "usage.total_tokens", tokens)
+	return out
+}
+
+// parseOpenAIEmbeddingsUsage reads the usage block of an OpenAI embeddings response.
+func parseOpenAIEmbeddingsUsage(data []byte) usage.Detail {
+	tokens := gjson.GetBytes(data, "usage.prompt_tokens").Int()
+	if tokens == 0 {
+		tokens = gjson.GetBytes(data, "usage.total_tokens").Int()
+	}
+	return usage.Detail{EmbeddingTokens: tokens, TotalTokens: tokens}
+}
+
+// embeddingBatches splits texts into consecutive batches of at most size entries.
+func embeddingBatches(texts []string, size int) [][]string {
+	batches := make([][]string, 0, (len(texts)+size-1)/size)
+	for start := 0; start < len(texts); start += size {
+		end := min(start+size, len(texts))
+		batches = append(batches, texts[start:end])
+	}
+	return batches
+}
@@ -0,0 +1,312 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	defaultGeminiCacheMinTokens  = 4096
+	defaultGeminiCacheMinRepeats = 2
+	defaultGeminiCacheTTL        = time.Hour
+	// geminiCacheExpiryMargin stops using a cache shortly before it expires upstream.
+	geminiCacheExpiryMargin = time.Minute
+	// geminiCachePruneInterval is how often forgotten prefixes and expired caches are dropped.
+	geminiCachePruneInterval = time.Minute
+	// geminiCacheRetryDelay holds back creating a cache for a prefix after a creation failed,
+	// for instance for a model without context caching.
+	geminiCacheRetryDelay = 10 * time.Minute
+)
+
+// geminiCachedContent is a cachedContents object created on an account.
+type geminiCachedContent struct {
+	name       string
+	expire     time.Time
+	refreshing bool
+}
+
+// geminiPrefix counts how often a prefix was sent to an account and model.
+type geminiPrefix struct {
+	count    int
+	lastSeen time.Time
+	creating bool
+	// retryAt holds back another creation attempt after one failed.
+	retryAt time.Time
+}
+
+// geminiContextCaches tracks repeated prompt prefixes and the caches created for them,
+// keyed by account, model and prefix hash.
+var geminiContextCaches = struct {
+	sync.Mutex
+	prefixes  map[string]*geminiPrefix
+	caches    map[string]*geminiCachedContent
+	lastPrune time.Time
+}{prefixes: map[string]*geminiPrefix{}, caches: map[string]*geminiCachedContent{}}
+
+// geminiCacheSettings returns cfg with defaults applied.
+func geminiCacheSettings(cfg config.GeminiContextCache) config.GeminiContextCache {
+	if cfg.MinTokens <= 0 {
+		cfg.MinTokens = defaultGeminiCacheMinTokens
+	}
+	if cfg.MinRepeats <= 0 {
+		cfg.MinRepeats = defaultGeminiCacheMinRepeats
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultGeminiCacheTTL
+	}
+	return cfg
+}
+
+// geminiCacheablePrefix returns the parts of a Gemini request a cache holds: the system
+// instruction, tools and tool config. Requests referencing a cache may not set them.
+func geminiCacheablePrefix(body []byte) map[string]gjson.Result {
+	prefix := make(map[string]gjson.Result)
+	for _, field := range []string{"systemInstruction", "system_instruction", "tools", "toolConfig", "tool_config"} {
+		if value := gjson.GetBytes(body, field); value.Exists() {
+			prefix[field] = value
+		}
+	}
+	return prefix
+}
+
+// applyGeminiContextCache replaces the system instruction and tools of a Gemini request
+// with a cachedContents reference when context caching is enabled and the prefix is large
+// and repeated. The cache is created on the request's account the first time the prefix
+// reaches MinRepeats and its TTL is extended while it is in use. It returns the body and
+// the key of the cache used, empty when the request is sent unchanged.
+func applyGeminiContextCache(ctx context.Context, cfg *config.Config, auth *cliproxyauth.Auth, apiKey, bearer, model string, body []byte) ([]byte, string) {
+	if cfg == nil || !cfg.GeminiContextCache.Enable || (apiKey == "" && bearer == "") {
+		return body, ""
+	}
+	if gjson.GetBytes(body, "cachedContent").Exists() || gjson.GetBytes(body, "cached_content").Exists() {
+		return body, ""
+	}
+	settings := geminiCacheSettings(cfg.GeminiContextCache)
+	prefix := geminiCacheablePrefix(body)
+	system, ok := prefix["systemInstruction"]
+	if !ok {
+		system, ok = prefix["system_instruction"]
+	}
+	if !ok {
+		return body, ""
+	}
+	size := 0
+	for _, value := range prefix {
+		size += len(value.Raw)
+	}
+	if size/4 < settings.MinTokens {
+		return body, ""
+	}
+
+	hash := sha256.New()
+	for _, field := range []string{"systemInstruction", "system_instruction", "tools", "toolConfig", "tool_config"} {
+		hash.Write([]byte(field))
+		hash.Write([]byte(prefix[field].Raw))
+	}
+	authID := ""
+	if auth != nil {
+		authID = auth.ID
+	}
+	key := authID + "|" + model + "|" + hex.EncodeToString(hash.Sum(nil))
+
+	name, create, refresh := lookupGeminiCache(key, settings)
+	if refresh {
+		go refreshGeminiCache(context.WithoutCancel(ctx), cfg, auth, apiKey, bearer, key, name, settings.TTL)
+	}
+	if create {
+		created, expire, err := createGeminiCache(ctx, cfg, auth, apiKey, bearer, model, system, prefix, settings.TTL)
+		storeGeminiCache(key, created, expire, err)
+		if err != nil {
+			log.Debugf("gemini context cache: create failed, sending the prompt inline: %v", err)
+			return body, ""
+		}
+		name = created
+	}
+	if name == "" {
+		return body, ""
+	}
+	for field := range prefix {
+		body, _ = sjson.DeleteBytes(body, field)
+	}
+	body, _ = sjson.SetBytes(body, "cachedContent", name)
+	return body, key
+}
+
+// lookupGeminiCache returns the cache of key if it is usable, otherwise counts the prefix
+// and reports whether the caller should create the cache. refresh reports that the cache
+// is past half of its lifetime and its TTL should be extended.
+func lookupGeminiCache(key string, settings config.GeminiContextCache) (name string, create, refresh bool) {
+	caches := &geminiContextCaches
+	caches.Lock()
+	defer caches.Unlock()
+	now := time.Now()
+	pruneGeminiCaches(now, settings.TTL)
+	if entry, ok := caches.caches[key]; ok && now.Before(entry.expire.Add(-geminiCacheExpiryMargin)) {
+		if !entry.refreshing && entry.expire.Sub(now) < settings.TTL/2 {
+			entry.refreshing = true
+			refresh = true
+		}
+		return entry.name, false, refresh
+	}
+	delete(caches.caches, key)
+	seen, ok := caches.prefixes[key]
+	if !ok {
+		seen = &geminiPrefix{}
+		caches.prefixes[key] = seen
+	}
+	seen.count++
+	seen.lastSeen = now
+	if seen.count < settings.MinRepeats || seen.creating || now.Before(seen.retryAt) {
+		return "", false, false
+	}
+	seen.creating = true
+	return "", true, false
+}
+
+// storeGeminiCache records the outcome of a cache creation for key.
+func storeGeminiCache(key, name string, expire time.Time, err error) {
+	caches := &geminiContextCaches
+	caches.Lock()
+	defer caches.Unlock()
+	if seen, ok := caches.prefixes[key]; ok {
+		seen.creating = false
+		if err != nil {
+			seen.retryAt = time.Now().Add(geminiCacheRetryDelay)
+		} else {
+			delete(caches.prefixes, key)
+		}
+	}
+	if err == nil {
+		caches.caches[key] = &geminiCachedContent{name: name, expire: expire}
+	}
+}
+
+// pruneGeminiCaches drops expired caches and prefixes not seen within ttl. Callers must
+// hold the lock.
+func pruneGeminiCaches(now time.Time, ttl time.Duration) {
+	caches := &geminiContextCaches
+	if now.Sub(caches.lastPrune) < geminiCachePruneInterval {
+		return
+	}
+	caches.lastPrune = now
+	for key, entry := range caches.caches {
+		if now.After(entry.expire) {
+			delete(caches.caches, key)
+		}
+	}
+	for key, seen := range caches.prefixes {
+		if !seen.creating && now.Sub(seen.lastSeen) > ttl && now.After(seen.retryAt) {
+			delete(caches.prefixes, key)
+		}
+	}
+}
+
+// invalidateGeminiCache forgets the cache of key after the upstream rejected a request
+// referencing it, so the next request sends the prompt inline and recreates it.
+func invalidateGeminiCache(key string, status int, body []byte) {
+	if key == "" || (status != http.StatusBadRequest && status != http.StatusForbidden && status != http.StatusNotFound) {
+		return
+	}
+	if !strings.Contains(strings.ToLower(string(body)), "cachedcontent") && !strings.Contains(strings.ToLower(string(body)), "cached content") {
+		return
+	}
+	caches := &geminiContextCaches
+	caches.Lock()
+	defer caches.Unlock()
+	delete(caches.caches, key)
+}
+
+// createGeminiCache creates a cachedContents object holding the prefix of a request.
+func createGeminiCache(ctx context.Context, cfg *config.Config, auth *cliproxyauth.Auth, apiKey, bearer, model string, system gjson.Result, prefix map[string]gjson.Result, ttl time.Duration) (string, time.Time, error) {
+	payload := []byte(`{}`)
+	payload, _ = sjson.SetBytes(payload, "model", "models/"+model)
+	payload, _ = sjson.SetRawBytes(payload, "systemInstruction", []byte(system.Raw))
+	if tools, ok := prefix["tools"]; ok {
+		payload, _ = sjson.SetRawBytes(payload, "tools", []byte(tools.Raw))
+	}
+	if toolConfig, ok := prefix["toolConfig"]; ok {
+		payload, _ = sjson.SetRawBytes(payload, "toolConfig", []byte(toolConfig.Raw))
+	} else if toolConfig, ok = prefix["tool_config"]; ok {
+		payload, _ = sjson.SetRawBytes(payload, "toolConfig", []byte(toolConfig.Raw))
+	}
+	payload, _ = sjson.SetBytes(payload, "ttl", geminiDuration(ttl))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, glEndpoint+"/"+glAPIVersion+"/cachedContents", bytes.NewReader(payload))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	setGeminiCacheAuth(req, apiKey, bearer)
+	resp, err := doUpstreamFileRequest(newProxyAwareHTTPClient(ctx, cfg, auth, 0), req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	name := gjson.GetBytes(resp.body, "name").String()
+	if name == "" {
+		return "", time.Time{}, errors.New("gemini context cache: missing cache name")
+	}
+	return name, geminiCacheExpiry(resp.body, ttl), nil
+}
+
+// refreshGeminiCache extends the TTL of a cache that is still in use.
+func refreshGeminiCache(ctx context.Context, cfg *config.Config, auth *cliproxyauth.Auth, apiKey, bearer, key, name string, ttl time.Duration) {
+	payload, _ := sjson.SetBytes([]byte(`{}`), "ttl", geminiDuration(ttl))
+	expire := time.Time{}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, glEndpoint+"/"+glAPIVersion+"/"+name+"?updateMask=ttl", bytes.NewReader(payload))
+	if err == nil {
+		setGeminiCacheAuth(req, apiKey, bearer)
+		var resp *upstreamFileResponse
+		if resp, err = doUpstreamFileRequest(newProxyAwareHTTPClient(ctx, cfg, auth, 0), req); err == nil {
+			expire = geminiCacheExpiry(resp.body, ttl)
+		}
+	}
+	if err != nil {
+		log.Debugf("gemini context cache: extending %s failed: %v", name, err)
+	}
+
+	caches := &geminiContextCaches
+	caches.Lock()
+	defer caches.Unlock()
+	if entry, ok := caches.caches[key]; ok && entry.name == name {
+		entry.refreshing = false
+		if !expire.IsZero() {
+			entry.expire = expire
+		}
+	}
+}
+
+func setGeminiCacheAuth(req *http.Request, apiKey, bearer string) {
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("x-goog-api-key", apiKey)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+}
+
+// geminiCacheExpiry returns the expireTime of a cachedContents response, falling back to
+// now plus ttl.
+func geminiCacheExpiry(body []byte, ttl time.Duration) time.Time {
+	if expire, err := time.Parse(time.RFC3339Nano, gjson.GetBytes(body, "expireTime").String()); err == nil {
+		return expire
+	}
+	return time.Now().Add(ttl)
+}
+
+// geminiDuration formats d as a protobuf duration.
+func geminiDuration(d time.Duration) string {
+	return fmt.Sprintf("%ds", int64(d.Seconds()))
+}
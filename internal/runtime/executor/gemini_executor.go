@@ -10,12 +10,15 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
@@ -77,7 +80,7 @@ func (e *GeminiExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, r
 	// Official Gemini API via API key or OAuth bearer
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("gemini")
-	body := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), false)
+	body := sdktranslator.TranslateRequest(ctx, from, to, req.Model, util.InlineRemoteImages(ctx, from.String(), bytes.Clone(req.Payload)), false)
 	if budgetOverride, includeOverride, ok := util.GeminiThinkingFromMetadata(req.Metadata); ok {
 		body = util.ApplyGeminiThinkingConfig(body, budgetOverride, includeOverride)
 	}
@@ -96,6 +99,11 @@ func (e *GeminiExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, r
 	}
 
 	body, _ = sjson.DeleteBytes(body, "session_id")
+	body = offloadGeminiInlineData(ctx, e.cfg, auth, apiKey, body)
+	var cacheKey string
+	if action != "countTokens" {
+		body, cacheKey = applyGeminiContextCache(ctx, e.cfg, auth, apiKey, bearer, req.Model, body)
+	}
 
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
 	if err != nil {
@@ -141,7 +149,8 @@ func (e *GeminiExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, r
 		b, _ := io.ReadAll(httpResp.Body)
 		appendAPIResponseChunk(ctx, e.cfg, b)
 		log.Debugf("request error, error status: %d, error body: %s", httpResp.StatusCode, string(b))
-		err = statusErr{code: httpResp.StatusCode, msg: string(b)}
+		invalidateGeminiCache(cacheKey, httpResp.StatusCode, b)
+		err = statusErr{code: httpResp.StatusCode, msg: string(b), retryAfter: parseRetryAfter(httpResp.Header)}
 		return resp, err
 	}
 	data, err := io.ReadAll(httpResp.Body)
@@ -151,6 +160,8 @@ func (e *GeminiExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, r
 	}
 	appendAPIResponseChunk(ctx, e.cfg, data)
 	reporter.publish(ctx, parseGeminiUsage(data))
+	reporter.observeOutput(data)
+	reporter.publishEstimate(ctx, req.Payload)
 	var param any
 	out := sdktranslator.TranslateNonStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), body, data, &param)
 	resp = cliproxyexecutor.Response{Payload: []byte(out)}
@@ -165,7 +176,7 @@ func (e *GeminiExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("gemini")
-	body := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), true)
+	body := sdktranslator.TranslateRequest(ctx, from, to, req.Model, util.InlineRemoteImages(ctx, from.String(), bytes.Clone(req.Payload)), true)
 	if budgetOverride, includeOverride, ok := util.GeminiThinkingFromMetadata(req.Metadata); ok {
 		body = util.ApplyGeminiThinkingConfig(body, budgetOverride, includeOverride)
 	}
@@ -180,6 +191,8 @@ func (e *GeminiExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 	}
 
 	body, _ = sjson.DeleteBytes(body, "session_id")
+	body = offloadGeminiInlineData(ctx, e.cfg, auth, apiKey, body)
+	body, cacheKey := applyGeminiContextCache(ctx, e.cfg, auth, apiKey, bearer, req.Model, body)
 
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
 	if err != nil {
@@ -220,10 +233,11 @@ func (e *GeminiExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 		b, _ := io.ReadAll(httpResp.Body)
 		appendAPIResponseChunk(ctx, e.cfg, b)
 		log.Debugf("request error, error status: %d, error body: %s", httpResp.StatusCode, string(b))
+		invalidateGeminiCache(cacheKey, httpResp.StatusCode, b)
 		if errClose := httpResp.Body.Close(); errClose != nil {
 			log.Errorf("gemini executor: close response body error: %v", errClose)
 		}
-		err = statusErr{code: httpResp.StatusCode, msg: string(b)}
+		err = statusErr{code: httpResp.StatusCode, msg: string(b), retryAfter: parseRetryAfter(httpResp.Header)}
 		return nil, err
 	}
 	out := make(chan cliproxyexecutor.StreamChunk)
@@ -240,11 +254,13 @@ func (e *GeminiExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 		scanner.Buffer(buf, 20_971_520)
 		var param any
 		for scanner.Scan() {
+			reporter.markFirstChunk()
 			line := scanner.Bytes()
 			appendAPIResponseChunk(ctx, e.cfg, line)
 			if detail, ok := parseGeminiStreamUsage(line); ok {
 				reporter.publish(ctx, detail)
 			}
+			reporter.observeOutput(line)
 			lines := sdktranslator.TranslateStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), body, bytes.Clone(line), &param)
 			for i := range lines {
 				out <- cliproxyexecutor.StreamChunk{Payload: []byte(lines[i])}
@@ -256,9 +272,10 @@ func (e *GeminiExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 		}
 		if errScan := scanner.Err(); errScan != nil {
 			recordAPIResponseError(ctx, e.cfg, errScan)
-			reporter.publishFailure(ctx)
+			reporter.publishFailure(ctx, errScan)
 			out <- cliproxyexecutor.StreamChunk{Err: errScan}
 		}
+		reporter.publishEstimate(ctx, req.Payload)
 	}()
 	return stream, nil
 }
@@ -268,7 +285,7 @@ func (e *GeminiExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Aut
 
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("gemini")
-	translatedReq := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), false)
+	translatedReq := sdktranslator.TranslateRequest(ctx, from, to, req.Model, util.InlineRemoteImages(ctx, from.String(), bytes.Clone(req.Payload)), false)
 	if budgetOverride, includeOverride, ok := util.GeminiThinkingFromMetadata(req.Metadata); ok {
 		translatedReq = util.ApplyGeminiThinkingConfig(translatedReq, budgetOverride, includeOverride)
 	}
@@ -327,7 +344,7 @@ func (e *GeminiExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Aut
 	appendAPIResponseChunk(ctx, e.cfg, data)
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		log.Debugf("request error, error status: %d, error body: %s", resp.StatusCode, string(data))
-		return cliproxyexecutor.Response{}, statusErr{code: resp.StatusCode, msg: string(data)}
+		return cliproxyexecutor.Response{}, statusErr{code: resp.StatusCode, msg: string(data), retryAfter: parseRetryAfter(resp.Header)}
 	}
 
 	count := gjson.GetBytes(data, "totalTokens").Int()
@@ -335,6 +352,166 @@ func (e *GeminiExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Aut
 	return cliproxyexecutor.Response{Payload: []byte(translated)}, nil
 }
 
+// Embed creates embeddings for an OpenAI embeddings request using batchEmbedContents.
+// Inputs are split into batches of geminiEmbedBatchSize and the vectors are merged
+// into a single OpenAI embeddings response.
+func (e *GeminiExecutor) Embed(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (resp cliproxyexecutor.Response, err error) {
+	apiKey, bearer := geminiCreds(auth)
+
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	texts, err := embeddingInputs(req.Payload)
+	if err != nil {
+		return resp, err
+	}
+	dimensions := gjson.GetBytes(req.Payload, "dimensions").Int()
+	base64Format := gjson.GetBytes(req.Payload, "encoding_format").String() == "base64"
+
+	var authID, authLabel, authType, authValue string
+	if auth != nil {
+		authID = auth.ID
+		authLabel = auth.Label
+		authType, authValue = auth.AccountInfo()
+	}
+	url := fmt.Sprintf("%s/%s/models/%s:%s", glEndpoint, glAPIVersion, req.Model, "batchEmbedContents")
+	httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
+
+	out := newOpenAIEmbeddingsResponse(req.Model)
+	for _, batch := range embeddingBatches(texts, geminiEmbedBatchSize) {
+		body := buildGeminiBatchEmbedRequest(req.Model, batch, dimensions)
+		httpReq, errReq := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if errReq != nil {
+			return resp, errReq
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+		if apiKey != "" {
+			httpReq.Header.Set("x-goog-api-key", apiKey)
+		} else {
+			httpReq.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		recordAPIRequest(ctx, e.cfg, upstreamRequestLog{
+			URL:       url,
+			Method:    http.MethodPost,
+			Headers:   httpReq.Header.Clone(),
+			Body:      body,
+			Provider:  e.Identifier(),
+			AuthID:    authID,
+			AuthLabel: authLabel,
+			AuthType:  authType,
+			AuthValue: authValue,
+		})
+
+		httpResp, errDo := httpClient.Do(httpReq)
+		if errDo != nil {
+			recordAPIResponseError(ctx, e.cfg, errDo)
+			return resp, errDo
+		}
+		recordAPIResponseMetadata(ctx, e.cfg, httpResp.StatusCode, httpResp.Header.Clone())
+		data, errRead := io.ReadAll(httpResp.Body)
+		if errClose := httpResp.Body.Close(); errClose != nil {
+			log.Errorf("gemini executor: close response body error: %v", errClose)
+		}
+		if errRead != nil {
+			recordAPIResponseError(ctx, e.cfg, errRead)
+			return resp, errRead
+		}
+		appendAPIResponseChunk(ctx, e.cfg, data)
+		if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+			log.Debugf("request error, error status: %d, error body: %s", httpResp.StatusCode, string(data))
+			err = statusErr{code: httpResp.StatusCode, msg: string(data), retryAfter: parseRetryAfter(httpResp.Header)}
+			return resp, err
+		}
+		out = appendGeminiEmbeddings(out, data, base64Format)
+	}
+
+	// batchEmbedContents does not report usage, so the input tokens are estimated.
+	tokens := estimateEmbeddingTokens(texts)
+	out = setEmbeddingsUsage(out, tokens)
+	reporter.publish(ctx, usage.Detail{EmbeddingTokens: tokens, TotalTokens: tokens})
+	resp = cliproxyexecutor.Response{Payload: out}
+	return resp, nil
+}
+
+// GenerateImages creates images for an OpenAI images generation request using the Imagen
+// predict endpoint and converts the predictions back to the OpenAI images format.
+func (e *GeminiExecutor) GenerateImages(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (resp cliproxyexecutor.Response, err error) {
+	apiKey, bearer := geminiCreds(auth)
+
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	if !strings.HasPrefix(req.Model, "imagen-") {
+		err = statusErr{code: http.StatusBadRequest, msg: fmt.Sprintf("model %s does not support the images API", req.Model)}
+		return resp, err
+	}
+	body, err := buildImagenPredictRequest(req.Payload)
+	if err != nil {
+		return resp, err
+	}
+
+	url := fmt.Sprintf("%s/%s/models/%s:%s", glEndpoint, glAPIVersion, req.Model, "predict")
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return resp, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		httpReq.Header.Set("x-goog-api-key", apiKey)
+	} else {
+		httpReq.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	var authID, authLabel, authType, authValue string
+	if auth != nil {
+		authID = auth.ID
+		authLabel = auth.Label
+		authType, authValue = auth.AccountInfo()
+	}
+	recordAPIRequest(ctx, e.cfg, upstreamRequestLog{
+		URL:       url,
+		Method:    http.MethodPost,
+		Headers:   httpReq.Header.Clone(),
+		Body:      body,
+		Provider:  e.Identifier(),
+		AuthID:    authID,
+		AuthLabel: authLabel,
+		AuthType:  authType,
+		AuthValue: authValue,
+	})
+
+	httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
+	httpResp, err := httpClient.Do(httpReq)
+	if err != nil {
+		recordAPIResponseError(ctx, e.cfg, err)
+		return resp, err
+	}
+	defer func() {
+		if errClose := httpResp.Body.Close(); errClose != nil {
+			log.Errorf("gemini executor: close response body error: %v", errClose)
+		}
+	}()
+	recordAPIResponseMetadata(ctx, e.cfg, httpResp.StatusCode, httpResp.Header.Clone())
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		b, _ := io.ReadAll(httpResp.Body)
+		appendAPIResponseChunk(ctx, e.cfg, b)
+		log.Debugf("request error, error status: %d, error body: %s", httpResp.StatusCode, string(b))
+		err = statusErr{code: httpResp.StatusCode, msg: string(b), retryAfter: parseRetryAfter(httpResp.Header)}
+		return resp, err
+	}
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		recordAPIResponseError(ctx, e.cfg, err)
+		return resp, err
+	}
+	appendAPIResponseChunk(ctx, e.cfg, data)
+
+	responseFormat := gjson.GetBytes(req.Payload, "response_format").String()
+	out, images := convertImagenResponse(data, responseFormat, time.Now().Unix())
+	reporter.publish(ctx, usage.Detail{Images: images})
+	resp = cliproxyexecutor.Response{Payload: out}
+	return resp, nil
+}
+
 func (e *GeminiExecutor) Refresh(ctx context.Context, auth *cliproxyauth.Auth) (*cliproxyauth.Auth, error) {
 	log.Debugf("gemini executor: refresh called")
 	// OAuth bearer token refresh for official Gemini API.
@@ -438,6 +615,26 @@ func (e *GeminiExecutor) Refresh(ctx context.Context, auth *cliproxyauth.Auth) (
 	return auth, nil
 }
 
+// ListModels queries the Generative Language API for the models available to an API key.
+// OAuth credentials are not listed.
+func (e *GeminiExecutor) ListModels(ctx context.Context, auth *cliproxyauth.Auth) ([]*registry.ModelInfo, error) {
+	apiKey, _ := geminiCreds(auth)
+	if apiKey == "" {
+		return nil, nil
+	}
+	url := fmt.Sprintf("%s/%s/models?pageSize=1000", glEndpoint, glAPIVersion)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("x-goog-api-key", apiKey)
+	data, err := fetchModelList(ctx, e.cfg, auth, httpReq)
+	if err != nil {
+		return nil, modelListError(e.Identifier(), err)
+	}
+	return parseGeminiModelList(data), nil
+}
+
 func geminiCreds(a *cliproxyauth.Auth) (apiKey, bearer string) {
 	if a == nil {
 		return "", ""
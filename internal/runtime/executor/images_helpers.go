@@ -0,0 +1,148 @@
+package executor
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// imagenMaxSamples is the maximum number of images Imagen returns for one predict call.
+const imagenMaxSamples = 4
+
+// imagenAspectRatios lists the aspect ratios accepted by Imagen with their width/height value.
+var imagenAspectRatios = []struct {
+	name  string
+	ratio float64
+}{
+	{"1:1", 1},
+	{"3:4", 3.0 / 4.0},
+	{"4:3", 4.0 / 3.0},
+	{"9:16", 9.0 / 16.0},
+	{"16:9", 16.0 / 9.0},
+}
+
+// imagenAspectRatio maps an OpenAI size such as "1792x1024" to the closest Imagen aspect ratio.
+// An empty string is returned for "auto" or unparsable sizes so Imagen applies its default.
+func imagenAspectRatio(size string) string {
+	width, height, ok := parseImageSize(size)
+	if !ok {
+		return ""
+	}
+	target := float64(width) / float64(height)
+	best := imagenAspectRatios[0].name
+	bestDiff := math.MaxFloat64
+	for _, candidate := range imagenAspectRatios {
+		if diff := math.Abs(candidate.ratio - target); diff < bestDiff {
+			best, bestDiff = candidate.name, diff
+		}
+	}
+	return best
+}
+
+// imagenImageSize maps OpenAI quality and size values to an Imagen sampleImageSize.
+// High quality requests and sizes above 1024 pixels use the 2K output; others keep the default.
+func imagenImageSize(quality, size string) string {
+	switch strings.ToLower(quality) {
+	case "hd", "high":
+		return "2K"
+	}
+	if width, height, ok := parseImageSize(size); ok && max(width, height) > 1024 {
+		return "2K"
+	}
+	return ""
+}
+
+func parseImageSize(size string) (int, int, bool) {
+	w, h, found := strings.Cut(strings.ToLower(strings.TrimSpace(size)), "x")
+	if !found {
+		return 0, 0, false
+	}
+	width, errW := strconv.Atoi(w)
+	height, errH := strconv.Atoi(h)
+	if errW != nil || errH != nil || width <= 0 || height <= 0 {
+		return 0, 0, false
+	}
+	return width, height, true
+}
+
+// buildImagenPredictRequest converts an OpenAI images generation request to an Imagen predict body.
+func buildImagenPredictRequest(payload []byte) ([]byte, error) {
+	prompt := gjson.GetBytes(payload, "prompt").String()
+	if strings.TrimSpace(prompt) == "" {
+		return nil, statusErr{code: http.StatusBadRequest, msg: "prompt is required"}
+	}
+	samples := gjson.GetBytes(payload, "n").Int()
+	if samples <= 0 {
+		samples = 1
+	}
+	if samples > imagenMaxSamples {
+		return nil, statusErr{code: http.StatusBadRequest, msg: "n must be at most " + strconv.Itoa(imagenMaxSamples) + " for Imagen models"}
+	}
+
+	out := []byte(`{"instances":[{"prompt":""}],"parameters":{"sampleCount":1}}`)
+	out, _ = sjson.SetBytes(out, "instances.0.prompt", prompt)
+	out, _ = sjson.SetBytes(out, "parameters.sampleCount", samples)
+	size := gjson.GetBytes(payload, "size").String()
+	if ratio := imagenAspectRatio(size); ratio != "" {
+		out, _ = sjson.SetBytes(out, "parameters.aspectRatio", ratio)
+	}
+	if imageSize := imagenImageSize(gjson.GetBytes(payload, "quality").String(), size); imageSize != "" {
+		out, _ = sjson.SetBytes(out, "parameters.sampleImageSize", imageSize)
+	}
+	switch strings.ToLower(gjson.GetBytes(payload, "output_format").String()) {
+	case "jpeg", "jpg":
+		out, _ = sjson.SetBytes(out, "parameters.outputOptions.mimeType", "image/jpeg")
+	case "png":
+		out, _ = sjson.SetBytes(out, "parameters.outputOptions.mimeType", "image/png")
+	}
+	return out, nil
+}
+
+// convertImagenResponse converts an Imagen predict response to an OpenAI images response.
+// Imagen only returns inline bytes, so response_format=url yields data URLs.
+func convertImagenResponse(data []byte, responseFormat string, created int64) ([]byte, int64) {
+	out := []byte(`{"created":0,"data":[]}`)
+	out, _ = sjson.SetBytes(out, "created", created)
+	var images int64
+	for _, prediction := range gjson.GetBytes(data, "predictions").Array() {
+		encoded := prediction.Get("bytesBase64Encoded").String()
+		if encoded == "" {
+			continue
+		}
+		entry := []byte(`{}`)
+		if responseFormat == "url" {
+			mimeType := prediction.Get("mimeType").String()
+			if mimeType == "" {
+				mimeType = "image/png"
+			}
+			entry, _ = sjson.SetBytes(entry, "url", "data:"+mimeType+";base64,"+encoded)
+		} else {
+			entry, _ = sjson.SetBytes(entry, "b64_json", encoded)
+		}
+		if revised := prediction.Get("prompt").String(); revised != "" {
+			entry, _ = sjson.SetBytes(entry, "revised_prompt", revised)
+		}
+		out, _ = sjson.SetRawBytes(out, "data.-1", entry)
+		images++
+	}
+	return out, images
+}
+
+// parseOpenAIImagesUsage reads the image count and optional token usage of an OpenAI images response.
+func parseOpenAIImagesUsage(data []byte) usage.Detail {
+	detail := usage.Detail{
+		Images:       int64(len(gjson.GetBytes(data, "data").Array())),
+		InputTokens:  gjson.GetBytes(data, "usage.input_tokens").Int(),
+		OutputTokens: gjson.GetBytes(data, "usage.output_tokens").Int(),
+		TotalTokens:  gjson.GetBytes(data, "usage.total_tokens").Int(),
+	}
+	if cached := gjson.GetBytes(data, "usage.input_tokens_details.cached_tokens"); cached.Exists() {
+		detail.CachedTokens = cached.Int()
+	}
+	return detail
+}
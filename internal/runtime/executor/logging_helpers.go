@@ -18,6 +18,7 @@ const (
 	apiAttemptsKey = "API_UPSTREAM_ATTEMPTS"
 	apiRequestKey  = "API_REQUEST"
 	apiResponseKey = "API_RESPONSE"
+	apiUsageKey    = "API_USAGE"
 )
 
 // upstreamRequestLog captures the outbound upstream request details for logging.
@@ -45,9 +46,15 @@ type upstreamAttempt struct {
 	errorWritten         bool
 }
 
+// upstreamLoggingEnabled reports whether upstream exchanges are recorded, which the request
+// log and body capture both rely on.
+func upstreamLoggingEnabled(cfg *config.Config) bool {
+	return cfg != nil && (cfg.RequestLog || cfg.BodyCapture.Enable)
+}
+
 // recordAPIRequest stores the upstream request metadata in Gin context for request logging.
 func recordAPIRequest(ctx context.Context, cfg *config.Config, info upstreamRequestLog) {
-	if cfg == nil || !cfg.RequestLog {
+	if !upstreamLoggingEnabled(cfg) {
 		return
 	}
 	ginCtx := ginContextFrom(ctx)
@@ -94,7 +101,7 @@ func recordAPIRequest(ctx context.Context, cfg *config.Config, info upstreamRequ
 
 // recordAPIResponseMetadata captures upstream response status/header information for the latest attempt.
 func recordAPIResponseMetadata(ctx context.Context, cfg *config.Config, status int, headers http.Header) {
-	if cfg == nil || !cfg.RequestLog {
+	if !upstreamLoggingEnabled(cfg) {
 		return
 	}
 	ginCtx := ginContextFrom(ctx)
@@ -120,7 +127,7 @@ func recordAPIResponseMetadata(ctx context.Context, cfg *config.Config, status i
 
 // recordAPIResponseError adds an error entry for the latest attempt when no HTTP response is available.
 func recordAPIResponseError(ctx context.Context, cfg *config.Config, err error) {
-	if cfg == nil || !cfg.RequestLog || err == nil {
+	if !upstreamLoggingEnabled(cfg) || err == nil {
 		return
 	}
 	ginCtx := ginContextFrom(ctx)
@@ -145,7 +152,7 @@ func recordAPIResponseError(ctx context.Context, cfg *config.Config, err error)
 
 // appendAPIResponseChunk appends an upstream response chunk to Gin context for request logging.
 func appendAPIResponseChunk(ctx context.Context, cfg *config.Config, chunk []byte) {
-	if cfg == nil || !cfg.RequestLog {
+	if !upstreamLoggingEnabled(cfg) {
 		return
 	}
 	data := bytes.TrimSpace(bytes.Clone(chunk))
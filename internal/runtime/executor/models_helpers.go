@@ -0,0 +1,165 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+// maxModelListSize bounds the size of an upstream model list response.
+const maxModelListSize = 8 << 20
+
+// fetchModelList sends a prepared model list request and returns the response body.
+func fetchModelList(ctx context.Context, cfg *config.Config, auth *cliproxyauth.Auth, req *http.Request) ([]byte, error) {
+	httpResp, err := newProxyAwareHTTPClient(ctx, cfg, auth, 0).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if errClose := httpResp.Body.Close(); errClose != nil {
+			log.Errorf("model list: close response body error: %v", errClose)
+		}
+	}()
+	data, err := io.ReadAll(io.LimitReader(httpResp.Body, maxModelListSize))
+	if err != nil {
+		return nil, err
+	}
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		return nil, statusErr{code: httpResp.StatusCode, msg: string(data)}
+	}
+	return data, nil
+}
+
+// parseGeminiModelList converts a Gemini models.list response, keeping the models that can
+// generate content, embeddings or images.
+func parseGeminiModelList(data []byte) []*registry.ModelInfo {
+	models := make([]*registry.ModelInfo, 0)
+	for _, item := range gjson.GetBytes(data, "models").Array() {
+		name := item.Get("name").String()
+		id := strings.TrimPrefix(name, "models/")
+		if id == "" {
+			continue
+		}
+		var methods []string
+		for _, method := range item.Get("supportedGenerationMethods").Array() {
+			methods = append(methods, method.String())
+		}
+		generative := slices.Contains(methods, "generateContent")
+		if !generative && !slices.Contains(methods, "embedContent") && !slices.Contains(methods, "predict") {
+			continue
+		}
+		model := &registry.ModelInfo{
+			ID:                         id,
+			Object:                     "model",
+			Created:                    time.Now().Unix(),
+			OwnedBy:                    "google",
+			Type:                       "gemini",
+			Name:                       name,
+			Version:                    item.Get("version").String(),
+			DisplayName:                item.Get("displayName").String(),
+			Description:                item.Get("description").String(),
+			InputTokenLimit:            int(item.Get("inputTokenLimit").Int()),
+			OutputTokenLimit:           int(item.Get("outputTokenLimit").Int()),
+			SupportedGenerationMethods: methods,
+		}
+		if generative {
+			model.Capabilities = &registry.ModelCapabilities{Vision: true, Tools: true, JSONMode: true}
+		}
+		models = append(models, model)
+	}
+	return models
+}
+
+// parseClaudeModelList converts an Anthropic models list response.
+func parseClaudeModelList(data []byte) []*registry.ModelInfo {
+	models := make([]*registry.ModelInfo, 0)
+	for _, item := range gjson.GetBytes(data, "data").Array() {
+		id := item.Get("id").String()
+		if id == "" {
+			continue
+		}
+		created := time.Now().Unix()
+		if ts, err := time.Parse(time.RFC3339, item.Get("created_at").String()); err == nil {
+			created = ts.Unix()
+		}
+		models = append(models, &registry.ModelInfo{
+			ID:           id,
+			Object:       "model",
+			Created:      created,
+			OwnedBy:      "anthropic",
+			Type:         "claude",
+			DisplayName:  item.Get("display_name").String(),
+			Capabilities: &registry.ModelCapabilities{Vision: true, Tools: true, JSONMode: true},
+		})
+	}
+	return models
+}
+
+// parseOpenAIModelList converts an OpenAI-style models list response. Context windows and
+// capabilities are read from the fields OpenRouter-style gateways add, when present.
+func parseOpenAIModelList(data []byte, provider string) []*registry.ModelInfo {
+	models := make([]*registry.ModelInfo, 0)
+	for _, item := range gjson.GetBytes(data, "data").Array() {
+		id := item.Get("id").String()
+		if id == "" {
+			continue
+		}
+		ownedBy := item.Get("owned_by").String()
+		if ownedBy == "" {
+			ownedBy = provider
+		}
+		created := item.Get("created").Int()
+		if created == 0 {
+			created = time.Now().Unix()
+		}
+		model := &registry.ModelInfo{
+			ID:            id,
+			Object:        "model",
+			Created:       created,
+			OwnedBy:       ownedBy,
+			Type:          "openai-compatibility",
+			DisplayName:   item.Get("name").String(),
+			ContextLength: int(item.Get("context_length").Int()),
+		}
+		if model.ContextLength == 0 {
+			model.ContextLength = int(item.Get("context_window").Int())
+		}
+		if model.ContextLength == 0 {
+			// vLLM reports the context length it was started with as max_model_len.
+			model.ContextLength = int(item.Get("max_model_len").Int())
+		}
+		for _, param := range item.Get("supported_parameters").Array() {
+			model.SupportedParameters = append(model.SupportedParameters, param.String())
+		}
+		modalities := item.Get("architecture.input_modalities")
+		if modalities.Exists() || len(model.SupportedParameters) > 0 {
+			capabilities := &registry.ModelCapabilities{
+				Tools:    slices.Contains(model.SupportedParameters, "tools"),
+				JSONMode: slices.Contains(model.SupportedParameters, "response_format") || slices.Contains(model.SupportedParameters, "structured_outputs"),
+			}
+			for _, modality := range modalities.Array() {
+				if modality.String() == "image" {
+					capabilities.Vision = true
+				}
+			}
+			model.Capabilities = capabilities
+		}
+		models = append(models, model)
+	}
+	return models
+}
+
+// modelListError wraps a model list failure with the provider that reported it.
+func modelListError(provider string, err error) error {
+	return fmt.Errorf("%s executor: list models: %w", provider, err)
+}
@@ -7,9 +7,12 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
@@ -42,7 +45,7 @@ func (e *OpenAICompatExecutor) Execute(ctx context.Context, auth *cliproxyauth.A
 	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
 	defer reporter.trackFailure(ctx, &err)
 
-	baseURL, apiKey := e.resolveCredentials(auth)
+	baseURL, apiKey := e.resolveCredentials(auth, req.Model)
 	if baseURL == "" {
 		err = statusErr{code: http.StatusUnauthorized, msg: "missing provider baseURL"}
 		return
@@ -51,7 +54,7 @@ func (e *OpenAICompatExecutor) Execute(ctx context.Context, auth *cliproxyauth.A
 	// Translate inbound request to OpenAI format
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("openai")
-	translated := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), opts.Stream)
+	translated := sdktranslator.TranslateRequest(ctx, from, to, req.Model, bytes.Clone(req.Payload), opts.Stream)
 	if modelOverride := e.resolveUpstreamModel(req.Model, auth); modelOverride != "" {
 		translated = e.overrideModel(translated, modelOverride)
 	}
@@ -62,10 +65,7 @@ func (e *OpenAICompatExecutor) Execute(ctx context.Context, auth *cliproxyauth.A
 		return resp, err
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
-	if apiKey != "" {
-		httpReq.Header.Set("Authorization", "Bearer "+apiKey)
-	}
-	httpReq.Header.Set("User-Agent", "cli-proxy-openai-compat")
+	e.applyHeaders(httpReq, auth, req.Model, apiKey)
 	var authID, authLabel, authType, authValue string
 	if auth != nil {
 		authID = auth.ID
@@ -100,7 +100,7 @@ func (e *OpenAICompatExecutor) Execute(ctx context.Context, auth *cliproxyauth.A
 		b, _ := io.ReadAll(httpResp.Body)
 		appendAPIResponseChunk(ctx, e.cfg, b)
 		log.Debugf("request error, error status: %d, error body: %s", httpResp.StatusCode, string(b))
-		err = statusErr{code: httpResp.StatusCode, msg: string(b)}
+		err = statusErr{code: httpResp.StatusCode, msg: string(b), retryAfter: parseRetryAfter(httpResp.Header)}
 		return resp, err
 	}
 	body, err := io.ReadAll(httpResp.Body)
@@ -110,6 +110,8 @@ func (e *OpenAICompatExecutor) Execute(ctx context.Context, auth *cliproxyauth.A
 	}
 	appendAPIResponseChunk(ctx, e.cfg, body)
 	reporter.publish(ctx, parseOpenAIUsage(body))
+	reporter.observeOutput(body)
+	reporter.publishEstimate(ctx, req.Payload)
 	// Translate response back to source format when needed
 	var param any
 	out := sdktranslator.TranslateNonStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), translated, body, &param)
@@ -121,14 +123,14 @@ func (e *OpenAICompatExecutor) ExecuteStream(ctx context.Context, auth *cliproxy
 	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
 	defer reporter.trackFailure(ctx, &err)
 
-	baseURL, apiKey := e.resolveCredentials(auth)
+	baseURL, apiKey := e.resolveCredentials(auth, req.Model)
 	if baseURL == "" {
 		err = statusErr{code: http.StatusUnauthorized, msg: "missing provider baseURL"}
 		return nil, err
 	}
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("openai")
-	translated := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), true)
+	translated := sdktranslator.TranslateRequest(ctx, from, to, req.Model, bytes.Clone(req.Payload), true)
 	if modelOverride := e.resolveUpstreamModel(req.Model, auth); modelOverride != "" {
 		translated = e.overrideModel(translated, modelOverride)
 	}
@@ -139,10 +141,7 @@ func (e *OpenAICompatExecutor) ExecuteStream(ctx context.Context, auth *cliproxy
 		return nil, err
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
-	if apiKey != "" {
-		httpReq.Header.Set("Authorization", "Bearer "+apiKey)
-	}
-	httpReq.Header.Set("User-Agent", "cli-proxy-openai-compat")
+	e.applyHeaders(httpReq, auth, req.Model, apiKey)
 	httpReq.Header.Set("Accept", "text/event-stream")
 	httpReq.Header.Set("Cache-Control", "no-cache")
 	var authID, authLabel, authType, authValue string
@@ -177,7 +176,7 @@ func (e *OpenAICompatExecutor) ExecuteStream(ctx context.Context, auth *cliproxy
 		if errClose := httpResp.Body.Close(); errClose != nil {
 			log.Errorf("openai compat executor: close response body error: %v", errClose)
 		}
-		err = statusErr{code: httpResp.StatusCode, msg: string(b)}
+		err = statusErr{code: httpResp.StatusCode, msg: string(b), retryAfter: parseRetryAfter(httpResp.Header)}
 		return nil, err
 	}
 	out := make(chan cliproxyexecutor.StreamChunk)
@@ -194,11 +193,13 @@ func (e *OpenAICompatExecutor) ExecuteStream(ctx context.Context, auth *cliproxy
 		scanner.Buffer(buf, 20_971_520)
 		var param any
 		for scanner.Scan() {
+			reporter.markFirstChunk()
 			line := scanner.Bytes()
 			appendAPIResponseChunk(ctx, e.cfg, line)
 			if detail, ok := parseOpenAIStreamUsage(line); ok {
 				reporter.publish(ctx, detail)
 			}
+			reporter.observeOutput(line)
 			if len(line) == 0 {
 				continue
 			}
@@ -211,9 +212,10 @@ func (e *OpenAICompatExecutor) ExecuteStream(ctx context.Context, auth *cliproxy
 		}
 		if errScan := scanner.Err(); errScan != nil {
 			recordAPIResponseError(ctx, e.cfg, errScan)
-			reporter.publishFailure(ctx)
+			reporter.publishFailure(ctx, errScan)
 			out <- cliproxyexecutor.StreamChunk{Err: errScan}
 		}
+		reporter.publishEstimate(ctx, req.Payload)
 	}()
 	return stream, nil
 }
@@ -221,7 +223,7 @@ func (e *OpenAICompatExecutor) ExecuteStream(ctx context.Context, auth *cliproxy
 func (e *OpenAICompatExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("openai")
-	translated := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), false)
+	translated := sdktranslator.TranslateRequest(ctx, from, to, req.Model, bytes.Clone(req.Payload), false)
 
 	modelForCounting := req.Model
 	if modelOverride := e.resolveUpstreamModel(req.Model, auth); modelOverride != "" {
@@ -244,6 +246,330 @@ func (e *OpenAICompatExecutor) CountTokens(ctx context.Context, auth *cliproxyau
 	return cliproxyexecutor.Response{Payload: []byte(translatedUsage)}, nil
 }
 
+// Embed forwards an OpenAI embeddings request to the provider's /embeddings endpoint.
+func (e *OpenAICompatExecutor) Embed(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (resp cliproxyexecutor.Response, err error) {
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	baseURL, apiKey := e.resolveCredentials(auth, req.Model)
+	if baseURL == "" {
+		err = statusErr{code: http.StatusUnauthorized, msg: "missing provider baseURL"}
+		return
+	}
+
+	payload := bytes.Clone(req.Payload)
+	if modelOverride := e.resolveUpstreamModel(req.Model, auth); modelOverride != "" {
+		payload = e.overrideModel(payload, modelOverride)
+	}
+
+	url := strings.TrimSuffix(baseURL, "/") + "/embeddings"
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return resp, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	e.applyHeaders(httpReq, auth, req.Model, apiKey)
+	var authID, authLabel, authType, authValue string
+	if auth != nil {
+		authID = auth.ID
+		authLabel = auth.Label
+		authType, authValue = auth.AccountInfo()
+	}
+	recordAPIRequest(ctx, e.cfg, upstreamRequestLog{
+		URL:       url,
+		Method:    http.MethodPost,
+		Headers:   httpReq.Header.Clone(),
+		Body:      payload,
+		Provider:  e.Identifier(),
+		AuthID:    authID,
+		AuthLabel: authLabel,
+		AuthType:  authType,
+		AuthValue: authValue,
+	})
+
+	httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
+	httpResp, err := httpClient.Do(httpReq)
+	if err != nil {
+		recordAPIResponseError(ctx, e.cfg, err)
+		return resp, err
+	}
+	defer func() {
+		if errClose := httpResp.Body.Close(); errClose != nil {
+			log.Errorf("openai compat executor: close response body error: %v", errClose)
+		}
+	}()
+	recordAPIResponseMetadata(ctx, e.cfg, httpResp.StatusCode, httpResp.Header.Clone())
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		b, _ := io.ReadAll(httpResp.Body)
+		appendAPIResponseChunk(ctx, e.cfg, b)
+		log.Debugf("request error, error status: %d, error body: %s", httpResp.StatusCode, string(b))
+		err = statusErr{code: httpResp.StatusCode, msg: string(b), retryAfter: parseRetryAfter(httpResp.Header)}
+		return resp, err
+	}
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		recordAPIResponseError(ctx, e.cfg, err)
+		return resp, err
+	}
+	appendAPIResponseChunk(ctx, e.cfg, body)
+	reporter.publish(ctx, parseOpenAIEmbeddingsUsage(body))
+	// Report the requested alias rather than the upstream model name.
+	body, _ = sjson.SetBytes(body, "model", req.Model)
+	resp = cliproxyexecutor.Response{Payload: body}
+	return resp, nil
+}
+
+// GenerateImages forwards an OpenAI images generation request to the provider's /images/generations endpoint.
+func (e *OpenAICompatExecutor) GenerateImages(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (resp cliproxyexecutor.Response, err error) {
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	baseURL, apiKey := e.resolveCredentials(auth, req.Model)
+	if baseURL == "" {
+		err = statusErr{code: http.StatusUnauthorized, msg: "missing provider baseURL"}
+		return
+	}
+
+	payload := bytes.Clone(req.Payload)
+	if modelOverride := e.resolveUpstreamModel(req.Model, auth); modelOverride != "" {
+		payload = e.overrideModel(payload, modelOverride)
+	}
+
+	url := strings.TrimSuffix(baseURL, "/") + "/images/generations"
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return resp, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	e.applyHeaders(httpReq, auth, req.Model, apiKey)
+	var authID, authLabel, authType, authValue string
+	if auth != nil {
+		authID = auth.ID
+		authLabel = auth.Label
+		authType, authValue = auth.AccountInfo()
+	}
+	recordAPIRequest(ctx, e.cfg, upstreamRequestLog{
+		URL:       url,
+		Method:    http.MethodPost,
+		Headers:   httpReq.Header.Clone(),
+		Body:      payload,
+		Provider:  e.Identifier(),
+		AuthID:    authID,
+		AuthLabel: authLabel,
+		AuthType:  authType,
+		AuthValue: authValue,
+	})
+
+	httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
+	httpResp, err := httpClient.Do(httpReq)
+	if err != nil {
+		recordAPIResponseError(ctx, e.cfg, err)
+		return resp, err
+	}
+	defer func() {
+		if errClose := httpResp.Body.Close(); errClose != nil {
+			log.Errorf("openai compat executor: close response body error: %v", errClose)
+		}
+	}()
+	recordAPIResponseMetadata(ctx, e.cfg, httpResp.StatusCode, httpResp.Header.Clone())
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		b, _ := io.ReadAll(httpResp.Body)
+		appendAPIResponseChunk(ctx, e.cfg, b)
+		log.Debugf("request error, error status: %d, error body: %s", httpResp.StatusCode, string(b))
+		err = statusErr{code: httpResp.StatusCode, msg: string(b), retryAfter: parseRetryAfter(httpResp.Header)}
+		return resp, err
+	}
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		recordAPIResponseError(ctx, e.cfg, err)
+		return resp, err
+	}
+	appendAPIResponseChunk(ctx, e.cfg, body)
+	reporter.publish(ctx, parseOpenAIImagesUsage(body))
+	resp = cliproxyexecutor.Response{Payload: body}
+	return resp, nil
+}
+
+// Transcribe forwards a multipart audio transcription upload to the provider's
+// /audio/transcriptions endpoint, rewriting the model field for aliased models.
+func (e *OpenAICompatExecutor) Transcribe(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (resp cliproxyexecutor.Response, err error) {
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	baseURL, apiKey := e.resolveCredentials(auth, req.Model)
+	if baseURL == "" {
+		err = statusErr{code: http.StatusUnauthorized, msg: "missing provider baseURL"}
+		return
+	}
+
+	upload, contentType, err := transcriptionBody(req, opts.Headers.Get("Content-Type"), e.resolveUpstreamModel(req.Model, auth))
+	if err != nil {
+		return resp, err
+	}
+
+	url := strings.TrimSuffix(baseURL, "/") + "/audio/transcriptions"
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, upload)
+	if err != nil {
+		if closer, ok := upload.(io.Closer); ok {
+			_ = closer.Close()
+		}
+		return resp, err
+	}
+	if sized, ok := upload.(interface{ Size() int64 }); ok && httpReq.ContentLength == 0 {
+		httpReq.ContentLength = sized.Size()
+	}
+	httpReq.Header.Set("Content-Type", contentType)
+	e.applyHeaders(httpReq, auth, req.Model, apiKey)
+	var authID, authLabel, authType, authValue string
+	if auth != nil {
+		authID = auth.ID
+		authLabel = auth.Label
+		authType, authValue = auth.AccountInfo()
+	}
+	// The uploaded audio is not written to the request log.
+	recordAPIRequest(ctx, e.cfg, upstreamRequestLog{
+		URL:       url,
+		Method:    http.MethodPost,
+		Headers:   httpReq.Header.Clone(),
+		Provider:  e.Identifier(),
+		AuthID:    authID,
+		AuthLabel: authLabel,
+		AuthType:  authType,
+		AuthValue: authValue,
+	})
+
+	httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
+	httpResp, err := httpClient.Do(httpReq)
+	if err != nil {
+		recordAPIResponseError(ctx, e.cfg, err)
+		return resp, err
+	}
+	defer func() {
+		if errClose := httpResp.Body.Close(); errClose != nil {
+			log.Errorf("openai compat executor: close response body error: %v", errClose)
+		}
+	}()
+	recordAPIResponseMetadata(ctx, e.cfg, httpResp.StatusCode, httpResp.Header.Clone())
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		b, _ := io.ReadAll(httpResp.Body)
+		appendAPIResponseChunk(ctx, e.cfg, b)
+		log.Debugf("request error, error status: %d, error body: %s", httpResp.StatusCode, string(b))
+		err = statusErr{code: httpResp.StatusCode, msg: string(b), retryAfter: parseRetryAfter(httpResp.Header)}
+		return resp, err
+	}
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		recordAPIResponseError(ctx, e.cfg, err)
+		return resp, err
+	}
+	appendAPIResponseChunk(ctx, e.cfg, body)
+	reporter.publish(ctx, parseOpenAITranscriptionUsage(body))
+	resp = cliproxyexecutor.Response{Payload: body}
+	return resp, nil
+}
+
+// Speech forwards an OpenAI speech request to the provider's /audio/speech endpoint and
+// relays the synthesized audio as it arrives.
+func (e *OpenAICompatExecutor) Speech(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (stream *cliproxyexecutor.BinaryStream, err error) {
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	baseURL, apiKey := e.resolveCredentials(auth, req.Model)
+	if baseURL == "" {
+		err = statusErr{code: http.StatusUnauthorized, msg: "missing provider baseURL"}
+		return nil, err
+	}
+
+	payload := bytes.Clone(req.Payload)
+	if modelOverride := e.resolveUpstreamModel(req.Model, auth); modelOverride != "" {
+		payload = e.overrideModel(payload, modelOverride)
+	}
+
+	url := strings.TrimSuffix(baseURL, "/") + "/audio/speech"
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	e.applyHeaders(httpReq, auth, req.Model, apiKey)
+	var authID, authLabel, authType, authValue string
+	if auth != nil {
+		authID = auth.ID
+		authLabel = auth.Label
+		authType, authValue = auth.AccountInfo()
+	}
+	recordAPIRequest(ctx, e.cfg, upstreamRequestLog{
+		URL:       url,
+		Method:    http.MethodPost,
+		Headers:   httpReq.Header.Clone(),
+		Body:      payload,
+		Provider:  e.Identifier(),
+		AuthID:    authID,
+		AuthLabel: authLabel,
+		AuthType:  authType,
+		AuthValue: authValue,
+	})
+
+	httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
+	httpResp, err := httpClient.Do(httpReq)
+	if err != nil {
+		recordAPIResponseError(ctx, e.cfg, err)
+		return nil, err
+	}
+	recordAPIResponseMetadata(ctx, e.cfg, httpResp.StatusCode, httpResp.Header.Clone())
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		b, _ := io.ReadAll(httpResp.Body)
+		appendAPIResponseChunk(ctx, e.cfg, b)
+		log.Debugf("request error, error status: %d, error body: %s", httpResp.StatusCode, string(b))
+		if errClose := httpResp.Body.Close(); errClose != nil {
+			log.Errorf("openai compat executor: close response body error: %v", errClose)
+		}
+		err = statusErr{code: httpResp.StatusCode, msg: string(b), retryAfter: parseRetryAfter(httpResp.Header)}
+		return nil, err
+	}
+	out := make(chan cliproxyexecutor.StreamChunk)
+	go func() {
+		defer close(out)
+		defer func() {
+			if errClose := httpResp.Body.Close(); errClose != nil {
+				log.Errorf("openai compat executor: close response body error: %v", errClose)
+			}
+		}()
+		// Audio bytes are relayed as-is and not written to the request log. Sends give up
+		// once ctx is done, so a client that stops reading does not leave this goroutine
+		// blocked with the upstream body open.
+		buf := make([]byte, speechChunkSize)
+		for {
+			n, errRead := httpResp.Body.Read(buf)
+			if n > 0 {
+				reporter.markFirstChunk()
+				select {
+				case out <- cliproxyexecutor.StreamChunk{Payload: bytes.Clone(buf[:n])}:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if errRead == io.EOF {
+				return
+			}
+			if errRead != nil {
+				recordAPIResponseError(ctx, e.cfg, errRead)
+				reporter.publishFailure(ctx, errRead)
+				select {
+				case out <- cliproxyexecutor.StreamChunk{Err: errRead}:
+				case <-ctx.Done():
+				}
+				return
+			}
+		}
+	}()
+	contentType := httpResp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return &cliproxyexecutor.BinaryStream{ContentType: contentType, Chunks: out}, nil
+}
+
 // Refresh is a no-op for API-key based compatibility providers.
 func (e *OpenAICompatExecutor) Refresh(ctx context.Context, auth *cliproxyauth.Auth) (*cliproxyauth.Auth, error) {
 	log.Debugf("openai compat executor: refresh called")
@@ -251,7 +577,27 @@ func (e *OpenAICompatExecutor) Refresh(ctx context.Context, auth *cliproxyauth.A
 	return auth, nil
 }
 
-func (e *OpenAICompatExecutor) resolveCredentials(auth *cliproxyauth.Auth) (baseURL, apiKey string) {
+// ListModels queries the models endpoint of the OpenAI-compatible upstream.
+func (e *OpenAICompatExecutor) ListModels(ctx context.Context, auth *cliproxyauth.Auth) ([]*registry.ModelInfo, error) {
+	baseURL, apiKey := e.resolveCredentials(auth, "")
+	if baseURL == "" {
+		return nil, nil
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(baseURL, "/")+"/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	e.applyHeaders(httpReq, auth, "", apiKey)
+	data, err := fetchModelList(ctx, e.cfg, auth, httpReq)
+	if err != nil {
+		return nil, modelListError(e.Identifier(), err)
+	}
+	return parseOpenAIModelList(data, e.Identifier()), nil
+}
+
+// resolveCredentials returns the base URL and API key for requests to model. The base-url
+// and api-key configured on the model take precedence over those of the provider.
+func (e *OpenAICompatExecutor) resolveCredentials(auth *cliproxyauth.Auth, model string) (baseURL, apiKey string) {
 	if auth == nil {
 		return "", ""
 	}
@@ -259,33 +605,99 @@ func (e *OpenAICompatExecutor) resolveCredentials(auth *cliproxyauth.Auth) (base
 		baseURL = strings.TrimSpace(auth.Attributes["base_url"])
 		apiKey = strings.TrimSpace(auth.Attributes["api_key"])
 	}
+	if m := e.matchModel(model, auth); m != nil {
+		if m.BaseURL != "" {
+			baseURL = m.BaseURL
+		}
+		if m.APIKey != "" {
+			apiKey = m.APIKey
+		}
+	}
 	return
 }
 
-func (e *OpenAICompatExecutor) resolveUpstreamModel(alias string, auth *cliproxyauth.Auth) string {
-	if alias == "" || auth == nil || e.cfg == nil {
-		return ""
+// applyHeaders sets the bearer token, the user agent and the headers configured for the
+// provider and model, rendering their {api-key}, {model} and {alias} placeholders.
+func (e *OpenAICompatExecutor) applyHeaders(httpReq *http.Request, auth *cliproxyauth.Auth, alias, apiKey string) {
+	if apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+apiKey)
 	}
+	httpReq.Header.Set("User-Agent", "cli-proxy-openai-compat")
 	compat := e.resolveCompatConfig(auth)
 	if compat == nil {
+		return
+	}
+	headers := make(map[string]string, len(compat.Headers))
+	for name, value := range compat.Headers {
+		headers[name] = value
+	}
+	upstream := alias
+	if m := e.matchModel(alias, auth); m != nil {
+		for name, value := range m.Headers {
+			headers[name] = value
+		}
+		if m.Name != "" {
+			upstream = m.Name
+		}
+	}
+	replacer := strings.NewReplacer("{api-key}", apiKey, "{model}", upstream, "{alias}", alias)
+	for name, value := range headers {
+		if value = strings.TrimSpace(replacer.Replace(value)); value == "" {
+			httpReq.Header.Del(name)
+			continue
+		}
+		httpReq.Header.Set(name, value)
+	}
+}
+
+func (e *OpenAICompatExecutor) resolveUpstreamModel(alias string, auth *cliproxyauth.Auth) string {
+	model := e.matchModel(alias, auth)
+	if model == nil {
 		return ""
 	}
-	for i := range compat.Models {
-		model := compat.Models[i]
+	if model.Name != "" {
+		return model.Name
+	}
+	return alias
+}
+
+// matchModel returns the configured model that alias refers to, or nil.
+func (e *OpenAICompatExecutor) matchModel(alias string, auth *cliproxyauth.Auth) *config.OpenAICompatibilityModel {
+	if alias == "" || auth == nil || e.cfg == nil {
+		return nil
+	}
+	models := e.configuredModels(auth)
+	for i := range models {
+		model := &models[i]
 		if model.Alias != "" {
 			if strings.EqualFold(model.Alias, alias) {
-				if model.Name != "" {
-					return model.Name
-				}
-				return alias
+				return model
 			}
 			continue
 		}
 		if strings.EqualFold(model.Name, alias) {
-			return model.Name
+			return model
 		}
 	}
-	return ""
+	return nil
+}
+
+// configuredModels returns the models configured for the provider of auth, an OpenAI
+// compatibility provider or a local backend.
+func (e *OpenAICompatExecutor) configuredModels(auth *cliproxyauth.Auth) []config.OpenAICompatibilityModel {
+	if auth.Attributes != nil && auth.Attributes["local_type"] != "" {
+		name := auth.Attributes["compat_name"]
+		for i := range e.cfg.LocalBackends {
+			if strings.EqualFold(e.cfg.LocalBackends[i].Name, name) {
+				return e.cfg.LocalBackends[i].Models
+			}
+		}
+		return nil
+	}
+	if compat := e.resolveCompatConfig(auth); compat != nil {
+		return compat.Models
+	}
+	return nil
 }
 
 func (e *OpenAICompatExecutor) resolveCompatConfig(auth *cliproxyauth.Auth) *config.OpenAICompatibility {
@@ -326,6 +738,8 @@ func (e *OpenAICompatExecutor) overrideModel(payload []byte, model string) []byt
 type statusErr struct {
 	code int
 	msg  string
+	// retryAfter is the upstream Retry-After hint; zero when absent.
+	retryAfter time.Duration
 }
 
 func (e statusErr) Error() string {
@@ -335,3 +749,21 @@ func (e statusErr) Error() string {
 	return fmt.Sprintf("status %d", e.code)
 }
 func (e statusErr) StatusCode() int { return e.code }
+
+// RetryAfter implements cliproxyexecutor.RetryAfterError.
+func (e statusErr) RetryAfter() time.Duration { return e.retryAfter }
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an HTTP date.
+func parseRetryAfter(header http.Header) time.Duration {
+	value := strings.TrimSpace(header.Get("Retry-After"))
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
@@ -0,0 +1,223 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/plugin"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+// PluginResolver returns the running plugin of a provider and its description.
+type PluginResolver func(ctx context.Context, provider string) (plugin.Plugin, plugin.Info, error)
+
+// PluginExecutor serves a provider implemented as a plugin. Requests are translated into
+// the format the plugin declares and its responses back into the client format, so the
+// plugin only has to talk to its upstream.
+type PluginExecutor struct {
+	provider string
+	cfg      *config.Config
+	resolve  PluginResolver
+}
+
+// NewPluginExecutor creates an executor for provider that obtains the plugin from resolve.
+func NewPluginExecutor(provider string, cfg *config.Config, resolve PluginResolver) *PluginExecutor {
+	return &PluginExecutor{provider: provider, cfg: cfg, resolve: resolve}
+}
+
+func (e *PluginExecutor) Identifier() string { return e.provider }
+
+func (e *PluginExecutor) PrepareRequest(_ *http.Request, _ *cliproxyauth.Auth) error { return nil }
+
+func (e *PluginExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (resp cliproxyexecutor.Response, err error) {
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	p, info, err := e.resolve(ctx, e.provider)
+	if err != nil {
+		return resp, err
+	}
+	from := opts.SourceFormat
+	to := sdktranslator.FromString(info.Format)
+	body := sdktranslator.TranslateRequest(ctx, from, to, req.Model, bytes.Clone(req.Payload), false)
+	e.recordRequest(ctx, auth, body)
+
+	result, err := p.Execute(ctx, plugin.Request{Model: req.Model, Payload: body})
+	if err != nil {
+		recordAPIResponseError(ctx, e.cfg, err)
+		return resp, pluginStatusErr(err)
+	}
+	recordAPIResponseMetadata(ctx, e.cfg, http.StatusOK, nil)
+	data := []byte(result.Payload)
+	appendAPIResponseChunk(ctx, e.cfg, data)
+	reporter.publish(ctx, parsePluginUsage(info.Format, data))
+	reporter.observeOutput(data)
+	reporter.publishEstimate(ctx, req.Payload)
+	var param any
+	out := sdktranslator.TranslateNonStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), body, data, &param)
+	resp = cliproxyexecutor.Response{Payload: []byte(out)}
+	return resp, nil
+}
+
+func (e *PluginExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (stream <-chan cliproxyexecutor.StreamChunk, err error) {
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	p, info, err := e.resolve(ctx, e.provider)
+	if err != nil {
+		return nil, err
+	}
+	from := opts.SourceFormat
+	to := sdktranslator.FromString(info.Format)
+	body := sdktranslator.TranslateRequest(ctx, from, to, req.Model, bytes.Clone(req.Payload), true)
+	e.recordRequest(ctx, auth, body)
+
+	chunks, err := p.ExecuteStream(ctx, plugin.Request{Model: req.Model, Payload: body, Stream: true})
+	if err != nil {
+		recordAPIResponseError(ctx, e.cfg, err)
+		return nil, pluginStatusErr(err)
+	}
+	recordAPIResponseMetadata(ctx, e.cfg, http.StatusOK, nil)
+	out := make(chan cliproxyexecutor.StreamChunk)
+	stream = out
+	go func() {
+		defer close(out)
+		var param any
+		for chunk := range chunks {
+			if chunk.Err != nil {
+				recordAPIResponseError(ctx, e.cfg, chunk.Err)
+				reporter.publishFailure(ctx, chunk.Err)
+				out <- cliproxyexecutor.StreamChunk{Err: pluginStatusErr(chunk.Err)}
+				return
+			}
+			reporter.markFirstChunk()
+			for _, line := range strings.Split(chunk.Data, "\n") {
+				line = strings.TrimRight(line, "\r")
+				if strings.TrimSpace(line) == "" {
+					continue
+				}
+				// Plugins may send bare JSON events; the stream translators expect SSE lines.
+				if !strings.HasPrefix(line, "data:") && !strings.HasPrefix(line, "event:") {
+					line = "data: " + line
+				}
+				data := []byte(line)
+				appendAPIResponseChunk(ctx, e.cfg, data)
+				if detail, ok := parsePluginStreamUsage(info.Format, data); ok {
+					reporter.publish(ctx, detail)
+				}
+				reporter.observeOutput(data)
+				translated := sdktranslator.TranslateStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), body, data, &param)
+				for i := range translated {
+					out <- cliproxyexecutor.StreamChunk{Payload: []byte(translated[i])}
+				}
+			}
+		}
+		reporter.publishEstimate(ctx, req.Payload)
+	}()
+	return stream, nil
+}
+
+// CountTokens estimates the prompt tokens with the OpenAI tokenizer, since plugins do not
+// count tokens.
+func (e *PluginExecutor) CountTokens(ctx context.Context, _ *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	from := opts.SourceFormat
+	to := sdktranslator.FromString("openai")
+	body := sdktranslator.TranslateRequest(ctx, from, to, req.Model, bytes.Clone(req.Payload), false)
+
+	enc, err := tokenizerForModel(req.Model)
+	if err != nil {
+		return cliproxyexecutor.Response{}, fmt.Errorf("plugin executor: tokenizer init failed: %w", err)
+	}
+
+	count, err := countOpenAIChatTokens(enc, body)
+	if err != nil {
+		return cliproxyexecutor.Response{}, fmt.Errorf("plugin executor: token counting failed: %w", err)
+	}
+
+	usageJSON := buildOpenAIUsageJSON(count)
+	translated := sdktranslator.TranslateTokenCount(ctx, to, from, count, usageJSON)
+	return cliproxyexecutor.Response{Payload: []byte(translated)}, nil
+}
+
+// Refresh is a no-op for plugins.
+func (e *PluginExecutor) Refresh(_ context.Context, auth *cliproxyauth.Auth) (*cliproxyauth.Auth, error) {
+	log.Debugf("plugin executor: refresh called")
+	return auth, nil
+}
+
+func (e *PluginExecutor) recordRequest(ctx context.Context, auth *cliproxyauth.Auth, body []byte) {
+	var authID, authLabel, authType, authValue string
+	if auth != nil {
+		authID = auth.ID
+		authLabel = auth.Label
+		authType, authValue = auth.AccountInfo()
+	}
+	recordAPIRequest(ctx, e.cfg, upstreamRequestLog{
+		URL:       "plugin://" + e.provider,
+		Method:    http.MethodPost,
+		Body:      body,
+		Provider:  e.Identifier(),
+		AuthID:    authID,
+		AuthLabel: authLabel,
+		AuthType:  authType,
+		AuthValue: authValue,
+	})
+}
+
+// pluginStatusErr converts a plugin error with a status code into a statusErr, so retries
+// and cooldowns treat it like an upstream response.
+func pluginStatusErr(err error) error {
+	var pluginErr *plugin.Error
+	if !errors.As(err, &pluginErr) || pluginErr.Status <= 0 {
+		return err
+	}
+	return statusErr{
+		code:       pluginErr.Status,
+		msg:        pluginErr.Message,
+		retryAfter: time.Duration(pluginErr.RetryAfter) * time.Second,
+	}
+}
+
+// parsePluginUsage reads the usage of a non-streaming response in format.
+func parsePluginUsage(format string, data []byte) usage.Detail {
+	switch format {
+	case "claude":
+		return parseClaudeUsage(data)
+	case "gemini":
+		return parseGeminiUsage(data)
+	case "codex":
+		detail, _ := parseCodexUsage(data)
+		return detail
+	default:
+		return parseOpenAIUsage(data)
+	}
+}
+
+// parsePluginStreamUsage reads the usage of a stream line in format.
+func parsePluginStreamUsage(format string, line []byte) (usage.Detail, bool) {
+	switch format {
+	case "claude":
+		return parseClaudeStreamUsage(line)
+	case "gemini":
+		return parseGeminiStreamUsage(line)
+	case "codex":
+		payload := jsonPayload(line)
+		if gjson.GetBytes(payload, "type").String() != "response.completed" {
+			return usage.Detail{}, false
+		}
+		return parseCodexUsage(payload)
+	default:
+		return parseOpenAIStreamUsage(line)
+	}
+}
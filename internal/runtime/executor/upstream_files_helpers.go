@@ -0,0 +1,298 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	// upstreamFileMinBytes is the decoded size from which inline data is uploaded to the
+	// provider's Files API instead of being sent with every request.
+	upstreamFileMinBytes = 256 << 10
+	// geminiUploadTTL stays below the 48 hours Gemini keeps uploaded files.
+	geminiUploadTTL = 47 * time.Hour
+	// claudeUploadTTL bounds how long Claude uploads are reused; they are kept until deleted.
+	claudeUploadTTL = 24 * time.Hour
+	// geminiFileActiveTimeout bounds the wait for an uploaded file to be processed.
+	geminiFileActiveTimeout = 30 * time.Second
+	// claudeFilesBeta enables file sources in Claude messages.
+	claudeFilesBeta = "files-api-2025-04-14"
+)
+
+type upstreamFile struct {
+	ref    string
+	expire time.Time
+}
+
+// upstreamFiles caches uploads by account and content hash.
+var (
+	upstreamFilesMu sync.Mutex
+	upstreamFiles   = map[string]upstreamFile{}
+)
+
+func upstreamFileKey(auth *cliproxyauth.Auth, data []byte) string {
+	sum := sha256.Sum256(data)
+	id := ""
+	if auth != nil {
+		id = auth.ID
+	}
+	return id + ":" + hex.EncodeToString(sum[:])
+}
+
+func cachedUpstreamFile(key string) (string, bool) {
+	upstreamFilesMu.Lock()
+	defer upstreamFilesMu.Unlock()
+	entry, ok := upstreamFiles[key]
+	if !ok || time.Now().After(entry.expire) {
+		return "", false
+	}
+	return entry.ref, true
+}
+
+func storeUpstreamFile(key, ref string, ttl time.Duration) {
+	upstreamFilesMu.Lock()
+	defer upstreamFilesMu.Unlock()
+	now := time.Now()
+	for k, entry := range upstreamFiles {
+		if now.After(entry.expire) {
+			delete(upstreamFiles, k)
+		}
+	}
+	upstreamFiles[key] = upstreamFile{ref: ref, expire: now.Add(ttl)}
+}
+
+// largeInlineData decodes base64 data of at least upstreamFileMinBytes.
+func largeInlineData(encoded string) ([]byte, bool) {
+	if len(encoded) < upstreamFileMinBytes*4/3 {
+		return nil, false
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// offloadGeminiInlineData replaces large inline parts of a Gemini request with files
+// uploaded through the Gemini Files API when upstream uploads are enabled. Only API keys
+// can upload; parts whose upload fails are sent inline.
+func offloadGeminiInlineData(ctx context.Context, cfg *config.Config, auth *cliproxyauth.Auth, apiKey string, body []byte) []byte {
+	if cfg == nil || !cfg.Files.UpstreamUpload || apiKey == "" {
+		return body
+	}
+	for i, content := range gjson.GetBytes(body, "contents").Array() {
+		for j, part := range content.Get("parts").Array() {
+			field := "inlineData"
+			inline := part.Get(field)
+			if !inline.Exists() {
+				field = "inline_data"
+				inline = part.Get(field)
+			}
+			data, ok := largeInlineData(inline.Get("data").String())
+			if !ok {
+				continue
+			}
+			mimeType := inline.Get("mime_type").String()
+			if mimeType == "" {
+				mimeType = inline.Get("mimeType").String()
+			}
+			uri, err := geminiUploadFile(ctx, cfg, auth, apiKey, data, mimeType)
+			if err != nil {
+				log.Debugf("gemini file upload failed, sending the file inline: %v", err)
+				continue
+			}
+			path := fmt.Sprintf("contents.%d.parts.%d", i, j)
+			body, _ = sjson.DeleteBytes(body, path+"."+field)
+			body, _ = sjson.SetBytes(body, path+".fileData.mimeType", mimeType)
+			body, _ = sjson.SetBytes(body, path+".fileData.fileUri", uri)
+		}
+	}
+	return body
+}
+
+// geminiUploadFile uploads data with the resumable upload protocol and waits until the
+// file is processed. It returns the file URI.
+func geminiUploadFile(ctx context.Context, cfg *config.Config, auth *cliproxyauth.Auth, apiKey string, data []byte, mimeType string) (string, error) {
+	key := upstreamFileKey(auth, data)
+	if uri, ok := cachedUpstreamFile(key); ok {
+		return uri, nil
+	}
+	client := newProxyAwareHTTPClient(ctx, cfg, auth, 0)
+
+	startReq, err := http.NewRequestWithContext(ctx, http.MethodPost, glEndpoint+"/upload/"+glAPIVersion+"/files", bytes.NewReader([]byte(`{"file":{"display_name":"cli-proxy-api"}}`)))
+	if err != nil {
+		return "", err
+	}
+	startReq.Header.Set("x-goog-api-key", apiKey)
+	startReq.Header.Set("Content-Type", "application/json")
+	startReq.Header.Set("X-Goog-Upload-Protocol", "resumable")
+	startReq.Header.Set("X-Goog-Upload-Command", "start")
+	startReq.Header.Set("X-Goog-Upload-Header-Content-Length", strconv.Itoa(len(data)))
+	startReq.Header.Set("X-Goog-Upload-Header-Content-Type", mimeType)
+	startResp, err := doUpstreamFileRequest(client, startReq)
+	if err != nil {
+		return "", err
+	}
+	uploadURL := startResp.header.Get("X-Goog-Upload-Url")
+	if uploadURL == "" {
+		return "", errors.New("gemini upload: missing upload url")
+	}
+
+	uploadReq, err := http.NewRequestWithContext(ctx, http.MethodPost, uploadURL, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	uploadReq.Header.Set("X-Goog-Upload-Offset", "0")
+	uploadReq.Header.Set("X-Goog-Upload-Command", "upload, finalize")
+	uploadResp, err := doUpstreamFileRequest(client, uploadReq)
+	if err != nil {
+		return "", err
+	}
+	file := gjson.GetBytes(uploadResp.body, "file")
+	uri, name := file.Get("uri").String(), file.Get("name").String()
+	if uri == "" {
+		return "", errors.New("gemini upload: missing file uri")
+	}
+
+	deadline := time.Now().Add(geminiFileActiveTimeout)
+	for state := file.Get("state").String(); state == "PROCESSING"; {
+		if time.Now().After(deadline) {
+			return "", errors.New("gemini upload: file is still processing")
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(time.Second):
+		}
+		getReq, errReq := http.NewRequestWithContext(ctx, http.MethodGet, glEndpoint+"/"+glAPIVersion+"/"+name, nil)
+		if errReq != nil {
+			return "", errReq
+		}
+		getReq.Header.Set("x-goog-api-key", apiKey)
+		getResp, errGet := doUpstreamFileRequest(client, getReq)
+		if errGet != nil {
+			return "", errGet
+		}
+		state = gjson.GetBytes(getResp.body, "state").String()
+		if state == "FAILED" {
+			return "", errors.New("gemini upload: file processing failed")
+		}
+	}
+	storeUpstreamFile(key, uri, geminiUploadTTL)
+	return uri, nil
+}
+
+// offloadClaudeDocuments replaces large base64 document and image sources of a Claude
+// request with files uploaded through the Claude Files API when upstream uploads are
+// enabled. Only API key accounts upload. It reports whether the request references
+// uploaded files, which requires the files beta header.
+func offloadClaudeDocuments(ctx context.Context, cfg *config.Config, auth *cliproxyauth.Auth, baseURL string, body []byte) ([]byte, bool) {
+	if cfg == nil || !cfg.Files.UpstreamUpload || auth == nil || auth.Attributes["api_key"] == "" {
+		return body, false
+	}
+	apiKey := auth.Attributes["api_key"]
+	changed := false
+	for i, message := range gjson.GetBytes(body, "messages").Array() {
+		for j, block := range message.Get("content").Array() {
+			blockType := block.Get("type").String()
+			if (blockType != "document" && blockType != "image") || block.Get("source.type").String() != "base64" {
+				continue
+			}
+			data, ok := largeInlineData(block.Get("source.data").String())
+			if !ok {
+				continue
+			}
+			id, err := claudeUploadFile(ctx, cfg, auth, apiKey, baseURL, data, block.Get("source.media_type").String())
+			if err != nil {
+				log.Debugf("claude file upload failed, sending the file inline: %v", err)
+				continue
+			}
+			path := fmt.Sprintf("messages.%d.content.%d.source", i, j)
+			body, _ = sjson.SetRawBytes(body, path, []byte(`{"type":"file"}`))
+			body, _ = sjson.SetBytes(body, path+".file_id", id)
+			changed = true
+		}
+	}
+	return body, changed
+}
+
+// claudeUploadFile uploads data to the Claude Files API and returns the file ID.
+func claudeUploadFile(ctx context.Context, cfg *config.Config, auth *cliproxyauth.Auth, apiKey, baseURL string, data []byte, mediaType string) (string, error) {
+	key := upstreamFileKey(auth, data)
+	if id, ok := cachedUpstreamFile(key); ok {
+		return id, nil
+	}
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", `form-data; name="file"; filename="upload"`)
+	header.Set("Content-Type", mediaType)
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return "", err
+	}
+	if _, err = part.Write(data); err != nil {
+		return "", err
+	}
+	if err = writer.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"/v1/files", &form)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("x-api-key", apiKey)
+	req.Header.Set("Anthropic-Version", "2023-06-01")
+	req.Header.Set("Anthropic-Beta", claudeFilesBeta)
+	resp, err := doUpstreamFileRequest(newProxyAwareHTTPClient(ctx, cfg, auth, 0), req)
+	if err != nil {
+		return "", err
+	}
+	id := gjson.GetBytes(resp.body, "id").String()
+	if id == "" {
+		return "", errors.New("claude upload: missing file id")
+	}
+	storeUpstreamFile(key, id, claudeUploadTTL)
+	return id, nil
+}
+
+type upstreamFileResponse struct {
+	header http.Header
+	body   []byte
+}
+
+func doUpstreamFileRequest(client *http.Client, req *http.Request) (*upstreamFileResponse, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s %s: status %d: %s", req.Method, req.URL.Path, resp.StatusCode, bytes.TrimSpace(body))
+	}
+	return &upstreamFileResponse{header: resp.Header, body: body}, nil
+}
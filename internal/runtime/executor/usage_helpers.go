@@ -3,15 +3,20 @@ package executor
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/errorcode"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tokencount"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 )
 
@@ -22,7 +27,21 @@ type usageReporter struct {
 	apiKey      string
 	source      string
 	requestedAt time.Time
+	retry       bool
+	split       string
+	variant     string
+	diagnostics *cliproxyexecutor.Diagnostics
 	once        sync.Once
+
+	firstChunkOnce sync.Once
+	firstChunkAt   time.Time
+
+	// published is set once a record was published; output accumulates the response text
+	// for estimating usage the upstream does not report.
+	published bool
+	output    strings.Builder
+	// errorCode is the proxy error code of a failed request.
+	errorCode string
 }
 
 func newUsageReporter(ctx context.Context, provider, model string, auth *cliproxyauth.Auth) *usageReporter {
@@ -33,18 +52,40 @@ func newUsageReporter(ctx context.Context, provider, model string, auth *cliprox
 		requestedAt: time.Now(),
 		apiKey:      apiKey,
 		source:      util.HideAPIKey(resolveUsageSource(auth, apiKey)),
+		retry:       cliproxyexecutor.RetryAttempt(ctx) > 0,
 	}
+	reporter.split, reporter.variant = cliproxyexecutor.Split(ctx)
 	if auth != nil {
 		reporter.authID = auth.ID
 	}
+	reporter.diagnostics = cliproxyexecutor.DiagnosticsFrom(ctx)
+	reporter.diagnostics.StartAttempt(provider, reporter.authID)
 	return reporter
 }
 
+// markFirstChunk records when the first streamed payload arrived from upstream.
+// Only the first call has an effect; it must run on the goroutine that publishes usage.
+func (r *usageReporter) markFirstChunk() {
+	if r == nil {
+		return
+	}
+	r.firstChunkOnce.Do(func() {
+		r.firstChunkAt = time.Now()
+	})
+}
+
 func (r *usageReporter) publish(ctx context.Context, detail usage.Detail) {
 	r.publishWithOutcome(ctx, detail, false)
 }
 
-func (r *usageReporter) publishFailure(ctx context.Context) {
+// publishFailure publishes a failed record, classified under the proxy error code of err.
+func (r *usageReporter) publishFailure(ctx context.Context, err error) {
+	if r == nil {
+		return
+	}
+	if !r.published {
+		r.errorCode = string(errorcode.Classify(0, err))
+	}
 	r.publishWithOutcome(ctx, usage.Detail{}, true)
 }
 
@@ -53,7 +94,7 @@ func (r *usageReporter) trackFailure(ctx context.Context, errPtr *error) {
 		return
 	}
 	if *errPtr != nil {
-		r.publishFailure(ctx)
+		r.publishFailure(ctx, *errPtr)
 	}
 }
 
@@ -62,28 +103,90 @@ func (r *usageReporter) publishWithOutcome(ctx context.Context, detail usage.Det
 		return
 	}
 	if detail.TotalTokens == 0 {
-		total := detail.InputTokens + detail.OutputTokens + detail.ReasoningTokens
+		total := detail.InputTokens + detail.OutputTokens + detail.ReasoningTokens + detail.EmbeddingTokens
 		if total > 0 {
 			detail.TotalTokens = total
 		}
 	}
-	if detail.InputTokens == 0 && detail.OutputTokens == 0 && detail.ReasoningTokens == 0 && detail.CachedTokens == 0 && detail.TotalTokens == 0 && !failed {
+	if detail.InputTokens == 0 && detail.OutputTokens == 0 && detail.ReasoningTokens == 0 && detail.CachedTokens == 0 && detail.TotalTokens == 0 && detail.Images == 0 && !failed {
 		return
 	}
+	r.publishRecord(ctx, detail, failed, false)
+}
+
+func (r *usageReporter) publishRecord(ctx context.Context, detail usage.Detail, failed, estimated bool) {
 	r.once.Do(func() {
-		usage.PublishRecord(ctx, usage.Record{
-			Provider:    r.provider,
-			Model:       r.model,
-			Source:      r.source,
-			APIKey:      r.apiKey,
-			AuthID:      r.authID,
-			RequestedAt: r.requestedAt,
-			Failed:      failed,
-			Detail:      detail,
-		})
+		r.published = true
+		record := usage.Record{
+			Provider:     r.provider,
+			Model:        r.model,
+			Source:       r.source,
+			APIKey:       r.apiKey,
+			AuthID:       r.authID,
+			RequestedAt:  r.requestedAt,
+			FirstChunkAt: r.firstChunkAt,
+			Failed:       failed,
+			Retry:        r.retry,
+			Split:        r.split,
+			SplitVariant: r.variant,
+			Estimated:    estimated,
+			ErrorCode:    r.errorCode,
+			Detail:       detail,
+		}
+		usage.PublishRecord(ctx, record)
+		r.diagnostics.FinishAttempt(time.Since(r.requestedAt), detail.InputTokens, detail.OutputTokens, detail.TotalTokens)
+		// Expose the final attempt's usage to the access log middleware.
+		if ginCtx := ginContextFrom(ctx); ginCtx != nil {
+			ginCtx.Set(apiUsageKey, record)
+		}
 	})
 }
 
+// observeOutput accumulates the generated text of a response body or stream line, for
+// publishEstimate. It has no effect once usage was published.
+func (r *usageReporter) observeOutput(data []byte) {
+	if r == nil || r.published {
+		return
+	}
+	payload := jsonPayload(data)
+	if trimmed := bytes.TrimSpace(data); payload == nil && bytes.HasPrefix(trimmed, []byte("[")) {
+		// Gemini CLI returns the chunks of a non-streamed response as a JSON array.
+		payload = trimmed
+	}
+	if payload == nil {
+		return
+	}
+	if text := tokencount.ResponseText(payload); text != "" {
+		r.output.WriteString(text)
+		r.output.WriteByte('\n')
+	}
+}
+
+// publishEstimate publishes usage counted locally from the prompt and the observed output
+// when a request completed without the upstream reporting any, flagging the record as
+// estimated. It has no effect once usage was published.
+func (r *usageReporter) publishEstimate(ctx context.Context, prompt []byte) {
+	if r == nil || r.published {
+		return
+	}
+	providers := []string{r.provider}
+	input, errInput := tokencount.Tokens(r.model, providers, prompt)
+	output, _, errOutput := tokencount.TextTokens(r.model, providers, r.output.String())
+	if errInput != nil || errOutput != nil {
+		log.Debugf("usage estimate for %s failed: %v", r.model, errors.Join(errInput, errOutput))
+		return
+	}
+	detail := usage.Detail{
+		InputTokens:  int64(input.InputTokens),
+		OutputTokens: int64(output),
+		TotalTokens:  int64(input.InputTokens + output),
+	}
+	if detail.TotalTokens == 0 {
+		return
+	}
+	r.publishRecord(ctx, detail, false, true)
+}
+
 func apiKeyFromContext(ctx context.Context) string {
 	if ctx == nil {
 		return ""
@@ -195,17 +298,7 @@ func parseClaudeUsage(data []byte) usage.Detail {
 	if !usageNode.Exists() {
 		return usage.Detail{}
 	}
-	detail := usage.Detail{
-		InputTokens:  usageNode.Get("input_tokens").Int(),
-		OutputTokens: usageNode.Get("output_tokens").Int(),
-		CachedTokens: usageNode.Get("cache_read_input_tokens").Int(),
-	}
-	if detail.CachedTokens == 0 {
-		// fall back to creation tokens when read tokens are absent
-		detail.CachedTokens = usageNode.Get("cache_creation_input_tokens").Int()
-	}
-	detail.TotalTokens = detail.InputTokens + detail.OutputTokens
-	return detail
+	return claudeUsageDetail(usageNode)
 }
 
 func parseClaudeStreamUsage(line []byte) (usage.Detail, bool) {
@@ -217,16 +310,35 @@ func parseClaudeStreamUsage(line []byte) (usage.Detail, bool) {
 	if !usageNode.Exists() {
 		return usage.Detail{}, false
 	}
+	return claudeUsageDetail(usageNode), true
+}
+
+// claudeUsageDetail converts a Claude usage object. Claude reports prompt cache reads and
+// writes apart from input_tokens; they are counted as input tokens, as OpenAI does, so
+// token totals and cost estimates cover the whole prompt.
+func claudeUsageDetail(usageNode gjson.Result) usage.Detail {
 	detail := usage.Detail{
-		InputTokens:  usageNode.Get("input_tokens").Int(),
-		OutputTokens: usageNode.Get("output_tokens").Int(),
-		CachedTokens: usageNode.Get("cache_read_input_tokens").Int(),
+		OutputTokens:        usageNode.Get("output_tokens").Int(),
+		CachedTokens:        usageNode.Get("cache_read_input_tokens").Int(),
+		CacheCreationTokens: usageNode.Get("cache_creation_input_tokens").Int(),
 	}
-	if detail.CachedTokens == 0 {
-		detail.CachedTokens = usageNode.Get("cache_creation_input_tokens").Int()
+	detail.InputTokens = usageNode.Get("input_tokens").Int() + detail.CachedTokens + detail.CacheCreationTokens
+	detail.TotalTokens = detail.InputTokens + detail.OutputTokens
+	return detail
+}
+
+// parseBedrockUsage converts the usage of a Converse response or of the metadata event of a
+// ConverseStream. Like Claude, Converse reports prompt cache reads and writes apart from
+// inputTokens.
+func parseBedrockUsage(usageNode gjson.Result) usage.Detail {
+	detail := usage.Detail{
+		OutputTokens:        usageNode.Get("outputTokens").Int(),
+		CachedTokens:        usageNode.Get("cacheReadInputTokens").Int(),
+		CacheCreationTokens: usageNode.Get("cacheWriteInputTokens").Int(),
 	}
+	detail.InputTokens = usageNode.Get("inputTokens").Int() + detail.CachedTokens + detail.CacheCreationTokens
 	detail.TotalTokens = detail.InputTokens + detail.OutputTokens
-	return detail, true
+	return detail
 }
 
 func parseGeminiCLIUsage(data []byte) usage.Detail {
@@ -242,6 +354,7 @@ func parseGeminiCLIUsage(data []byte) usage.Detail {
 		InputTokens:     node.Get("promptTokenCount").Int(),
 		OutputTokens:    node.Get("candidatesTokenCount").Int(),
 		ReasoningTokens: node.Get("thoughtsTokenCount").Int(),
+		CachedTokens:    node.Get("cachedContentTokenCount").Int(),
 		TotalTokens:     node.Get("totalTokenCount").Int(),
 	}
 	if detail.TotalTokens == 0 {
@@ -263,6 +376,7 @@ func parseGeminiUsage(data []byte) usage.Detail {
 		InputTokens:     node.Get("promptTokenCount").Int(),
 		OutputTokens:    node.Get("candidatesTokenCount").Int(),
 		ReasoningTokens: node.Get("thoughtsTokenCount").Int(),
+		CachedTokens:    node.Get("cachedContentTokenCount").Int(),
 		TotalTokens:     node.Get("totalTokenCount").Int(),
 	}
 	if detail.TotalTokens == 0 {
@@ -287,6 +401,7 @@ func parseGeminiStreamUsage(line []byte) (usage.Detail, bool) {
 		InputTokens:     node.Get("promptTokenCount").Int(),
 		OutputTokens:    node.Get("candidatesTokenCount").Int(),
 		ReasoningTokens: node.Get("thoughtsTokenCount").Int(),
+		CachedTokens:    node.Get("cachedContentTokenCount").Int(),
 		TotalTokens:     node.Get("totalTokenCount").Int(),
 	}
 	if detail.TotalTokens == 0 {
@@ -311,6 +426,7 @@ func parseGeminiCLIStreamUsage(line []byte) (usage.Detail, bool) {
 		InputTokens:     node.Get("promptTokenCount").Int(),
 		OutputTokens:    node.Get("candidatesTokenCount").Int(),
 		ReasoningTokens: node.Get("thoughtsTokenCount").Int(),
+		CachedTokens:    node.Get("cachedContentTokenCount").Int(),
 		TotalTokens:     node.Get("totalTokenCount").Int(),
 	}
 	if detail.TotalTokens == 0 {
@@ -0,0 +1,530 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	vertexScope            = "https://www.googleapis.com/auth/cloud-platform"
+	vertexAnthropicVersion = "vertex-2023-10-16"
+	defaultVertexRegion    = "us-central1"
+
+	// vertexRegionCooldown is how long a region that failed over is tried after the others,
+	// unless the upstream asked for a longer wait with Retry-After.
+	vertexRegionCooldown = 30 * time.Second
+)
+
+// VertexExecutor is a stateless executor for Google Cloud Vertex AI. Gemini models are called
+// through the generateContent API of the google publisher and Claude models through the
+// rawPredict API of the anthropic publisher. Requests are authorized with OAuth tokens of a
+// service account key or of Application Default Credentials, and fail over across regions.
+type VertexExecutor struct {
+	cfg *config.Config
+}
+
+func NewVertexExecutor(cfg *config.Config) *VertexExecutor { return &VertexExecutor{cfg: cfg} }
+
+func (e *VertexExecutor) Identifier() string { return "vertex" }
+
+func (e *VertexExecutor) PrepareRequest(_ *http.Request, _ *cliproxyauth.Auth) error { return nil }
+
+// vertexTarget is a publisher model of Vertex AI.
+type vertexTarget struct {
+	model     string
+	publisher string
+}
+
+func (t vertexTarget) anthropic() bool { return t.publisher == "anthropic" }
+
+func (e *VertexExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (resp cliproxyexecutor.Response, err error) {
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	target := e.resolveTarget(req.Model, auth)
+	from := opts.SourceFormat
+	// Claude requests use streaming translation to preserve function calling, as the Claude executor does.
+	to, body := e.translateRequest(ctx, target, req, from, target.anthropic() && from != sdktranslator.FromString("claude"))
+	action := "generateContent"
+	if target.anthropic() {
+		action = "rawPredict"
+		if gjson.GetBytes(body, "stream").Bool() {
+			action = "streamRawPredict"
+		}
+	}
+
+	httpResp, err := e.send(ctx, auth, target, action, body)
+	if err != nil {
+		return resp, err
+	}
+	defer func() {
+		if errClose := httpResp.Body.Close(); errClose != nil {
+			log.Errorf("vertex executor: close response body error: %v", errClose)
+		}
+	}()
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		recordAPIResponseError(ctx, e.cfg, err)
+		return resp, err
+	}
+	appendAPIResponseChunk(ctx, e.cfg, data)
+	switch {
+	case action == "streamRawPredict":
+		for _, line := range bytes.Split(data, []byte("\n")) {
+			if detail, ok := parseClaudeStreamUsage(line); ok {
+				reporter.publish(ctx, detail)
+			}
+			reporter.observeOutput(line)
+		}
+	case target.anthropic():
+		reporter.publish(ctx, parseClaudeUsage(data))
+		reporter.observeOutput(data)
+	default:
+		reporter.publish(ctx, parseGeminiUsage(data))
+		reporter.observeOutput(data)
+	}
+	reporter.publishEstimate(ctx, req.Payload)
+	var param any
+	out := sdktranslator.TranslateNonStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), body, data, &param)
+	resp = cliproxyexecutor.Response{Payload: []byte(out)}
+	return resp, nil
+}
+
+func (e *VertexExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (stream <-chan cliproxyexecutor.StreamChunk, err error) {
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	target := e.resolveTarget(req.Model, auth)
+	from := opts.SourceFormat
+	to, body := e.translateRequest(ctx, target, req, from, true)
+	action := "streamGenerateContent?alt=sse"
+	if target.anthropic() {
+		action = "streamRawPredict"
+	}
+
+	httpResp, err := e.send(ctx, auth, target, action, body)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan cliproxyexecutor.StreamChunk)
+	stream = out
+	go func() {
+		defer close(out)
+		defer func() {
+			if errClose := httpResp.Body.Close(); errClose != nil {
+				log.Errorf("vertex executor: close response body error: %v", errClose)
+			}
+		}()
+		scanner := bufio.NewScanner(httpResp.Body)
+		buf := make([]byte, 20_971_520)
+		scanner.Buffer(buf, 20_971_520)
+		var param any
+		for scanner.Scan() {
+			reporter.markFirstChunk()
+			line := scanner.Bytes()
+			appendAPIResponseChunk(ctx, e.cfg, line)
+			if target.anthropic() {
+				if detail, ok := parseClaudeStreamUsage(line); ok {
+					reporter.publish(ctx, detail)
+				}
+			} else if detail, ok := parseGeminiStreamUsage(line); ok {
+				reporter.publish(ctx, detail)
+			}
+			reporter.observeOutput(line)
+			// Claude to Claude streams are forwarded as-is to preserve the SSE format.
+			if from == to {
+				cloned := make([]byte, len(line)+1)
+				copy(cloned, line)
+				cloned[len(line)] = '\n'
+				out <- cliproxyexecutor.StreamChunk{Payload: cloned}
+				continue
+			}
+			chunks := sdktranslator.TranslateStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), body, bytes.Clone(line), &param)
+			for i := range chunks {
+				out <- cliproxyexecutor.StreamChunk{Payload: []byte(chunks[i])}
+			}
+		}
+		if !target.anthropic() {
+			chunks := sdktranslator.TranslateStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), body, []byte("[DONE]"), &param)
+			for i := range chunks {
+				out <- cliproxyexecutor.StreamChunk{Payload: []byte(chunks[i])}
+			}
+		}
+		if errScan := scanner.Err(); errScan != nil {
+			recordAPIResponseError(ctx, e.cfg, errScan)
+			reporter.publishFailure(ctx, errScan)
+			out <- cliproxyexecutor.StreamChunk{Err: errScan}
+		}
+		reporter.publishEstimate(ctx, req.Payload)
+	}()
+	return stream, nil
+}
+
+// CountTokens counts prompt tokens with the countTokens API of Gemini models or the
+// count-tokens model of the anthropic publisher.
+func (e *VertexExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	target := e.resolveTarget(req.Model, auth)
+	from := opts.SourceFormat
+	to, body := e.translateRequest(ctx, target, req, from, false)
+	action := "countTokens"
+	countTarget := target
+	if target.anthropic() {
+		action = "rawPredict"
+		countTarget.model = "count-tokens"
+		body, _ = sjson.DeleteBytes(body, "anthropic_version")
+		body, _ = sjson.DeleteBytes(body, "max_tokens")
+		body, _ = sjson.DeleteBytes(body, "stream")
+		body, _ = sjson.SetBytes(body, "model", target.model)
+	} else {
+		body, _ = sjson.DeleteBytes(body, "tools")
+		body, _ = sjson.DeleteBytes(body, "generationConfig")
+	}
+
+	httpResp, err := e.send(ctx, auth, countTarget, action, body)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	defer func() {
+		if errClose := httpResp.Body.Close(); errClose != nil {
+			log.Errorf("vertex executor: close response body error: %v", errClose)
+		}
+	}()
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		recordAPIResponseError(ctx, e.cfg, err)
+		return cliproxyexecutor.Response{}, err
+	}
+	appendAPIResponseChunk(ctx, e.cfg, data)
+	if target.anthropic() {
+		count := gjson.GetBytes(data, "input_tokens").Int()
+		return cliproxyexecutor.Response{Payload: []byte(sdktranslator.TranslateTokenCount(ctx, to, from, count, data))}, nil
+	}
+	respCtx := context.WithValue(ctx, "alt", opts.Alt)
+	count := gjson.GetBytes(data, "totalTokens").Int()
+	return cliproxyexecutor.Response{Payload: []byte(sdktranslator.TranslateTokenCount(respCtx, to, from, count, data))}, nil
+}
+
+// Refresh is a no-op: access tokens are obtained and renewed when requests are sent.
+func (e *VertexExecutor) Refresh(_ context.Context, auth *cliproxyauth.Auth) (*cliproxyauth.Auth, error) {
+	log.Debugf("vertex executor: refresh called")
+	return auth, nil
+}
+
+// translateRequest translates the request into the schema of the publisher of target: the
+// Gemini schema for google models and the Anthropic Messages schema, with the Vertex AI
+// anthropic_version and without the model, which is part of the path, for Claude models.
+func (e *VertexExecutor) translateRequest(ctx context.Context, target vertexTarget, req cliproxyexecutor.Request, from sdktranslator.Format, stream bool) (sdktranslator.Format, []byte) {
+	if target.anthropic() {
+		to := sdktranslator.FromString("claude")
+		body := sdktranslator.TranslateRequest(ctx, from, to, req.Model, bytes.Clone(req.Payload), stream)
+		body, _ = sjson.DeleteBytes(body, "model")
+		body, _ = sjson.SetBytes(body, "anthropic_version", vertexAnthropicVersion)
+		return to, body
+	}
+	to := sdktranslator.FromString("gemini")
+	body := sdktranslator.TranslateRequest(ctx, from, to, req.Model, util.InlineRemoteImages(ctx, from.String(), bytes.Clone(req.Payload)), stream)
+	if budgetOverride, includeOverride, ok := util.GeminiThinkingFromMetadata(req.Metadata); ok {
+		body = util.ApplyGeminiThinkingConfig(body, budgetOverride, includeOverride)
+	}
+	body = disableGeminiThinkingConfig(body, target.model)
+	body = fixGeminiImageAspectRatio(target.model, body)
+	body, _ = sjson.DeleteBytes(body, "session_id")
+	body, _ = sjson.DeleteBytes(body, "model")
+	return to, body
+}
+
+// send posts body to action, such as generateContent, of the publisher model in the first
+// available region of auth, failing over to the next region when a region is rate limited,
+// unavailable or unreachable. It returns the response when its status is 2xx.
+func (e *VertexExecutor) send(ctx context.Context, auth *cliproxyauth.Auth, target vertexTarget, action string, body []byte) (*http.Response, error) {
+	attrs := map[string]string{}
+	if auth != nil && auth.Attributes != nil {
+		attrs = auth.Attributes
+	}
+	credential, err := e.credential(ctx, auth)
+	if err != nil {
+		return nil, err
+	}
+	projectID := attrs["project_id"]
+	if projectID == "" {
+		projectID = credential.projectID
+	}
+	if projectID == "" {
+		return nil, statusErr{code: http.StatusUnauthorized, msg: "vertex executor: missing project-id"}
+	}
+	token, err := credential.tokens.Token()
+	if err != nil {
+		return nil, statusErr{code: http.StatusUnauthorized, msg: fmt.Sprintf("vertex executor: access token: %v", err)}
+	}
+
+	regions := vertexRegions.order(auth, strings.Split(attrs["regions"], ","))
+	for i, region := range regions {
+		httpResp, errSend := e.sendRegion(ctx, auth, attrs["base_url"], projectID, region, target, action, token, body)
+		if errSend == nil {
+			return httpResp, nil
+		}
+		if i == len(regions)-1 || !vertexShouldFailover(ctx, errSend) {
+			return nil, errSend
+		}
+		var retryAfter time.Duration
+		var se statusErr
+		if errors.As(errSend, &se) {
+			retryAfter = se.retryAfter
+		}
+		vertexRegions.cool(auth, region, retryAfter)
+		log.Debugf("vertex executor: region %s failed (%v), trying %s", region, errSend, regions[i+1])
+	}
+	return nil, statusErr{code: http.StatusServiceUnavailable, msg: "vertex executor: no region configured"}
+}
+
+func (e *VertexExecutor) sendRegion(ctx context.Context, auth *cliproxyauth.Auth, baseURL, projectID, region string, target vertexTarget, action string, token *oauth2.Token, body []byte) (*http.Response, error) {
+	endpoint := vertexEndpoint(baseURL, projectID, region, target, action)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if strings.HasPrefix(action, "stream") {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+	token.SetAuthHeader(httpReq)
+	var authID, authLabel, authType, authValue string
+	if auth != nil {
+		authID = auth.ID
+		authLabel = auth.Label
+		authType, authValue = auth.AccountInfo()
+	}
+	recordAPIRequest(ctx, e.cfg, upstreamRequestLog{
+		URL:       endpoint,
+		Method:    http.MethodPost,
+		Headers:   httpReq.Header.Clone(),
+		Body:      body,
+		Provider:  e.Identifier(),
+		AuthID:    authID,
+		AuthLabel: authLabel,
+		AuthType:  authType,
+		AuthValue: authValue,
+	})
+
+	httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
+	httpResp, err := httpClient.Do(httpReq)
+	if err != nil {
+		recordAPIResponseError(ctx, e.cfg, err)
+		return nil, err
+	}
+	recordAPIResponseMetadata(ctx, e.cfg, httpResp.StatusCode, httpResp.Header.Clone())
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		b, _ := io.ReadAll(httpResp.Body)
+		appendAPIResponseChunk(ctx, e.cfg, b)
+		log.Debugf("request error, error status: %d, error body: %s", httpResp.StatusCode, string(b))
+		if errClose := httpResp.Body.Close(); errClose != nil {
+			log.Errorf("vertex executor: close response body error: %v", errClose)
+		}
+		return nil, statusErr{code: httpResp.StatusCode, msg: string(b), retryAfter: parseRetryAfter(httpResp.Header)}
+	}
+	return httpResp, nil
+}
+
+// vertexEndpoint returns the URL of action of the publisher model in region. The global
+// location is served by the aiplatform.googleapis.com host without a region prefix.
+func vertexEndpoint(baseURL, projectID, region string, target vertexTarget, action string) string {
+	host := strings.TrimSuffix(baseURL, "/")
+	if host == "" {
+		if region == "global" {
+			host = "https://aiplatform.googleapis.com"
+		} else {
+			host = "https://" + region + "-aiplatform.googleapis.com"
+		}
+	}
+	return fmt.Sprintf("%s/v1/projects/%s/locations/%s/publishers/%s/models/%s:%s",
+		host, url.PathEscape(projectID), url.PathEscape(region), target.publisher, url.PathEscape(target.model), action)
+}
+
+// vertexShouldFailover reports whether a request that failed with err may succeed in another
+// region: on rate limits, server errors and transport errors, but not when ctx is done.
+func vertexShouldFailover(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var se statusErr
+	if errors.As(err, &se) {
+		return se.code == http.StatusTooManyRequests || se.code >= http.StatusInternalServerError
+	}
+	return true
+}
+
+// vertexRegions remembers the regions that recently failed over, so that requests try the
+// healthy regions first instead of waiting for the same failure again.
+var vertexRegions = &vertexRegionState{cooling: make(map[string]time.Time)}
+
+type vertexRegionState struct {
+	mu      sync.Mutex
+	cooling map[string]time.Time
+}
+
+func vertexRegionKey(auth *cliproxyauth.Auth, region string) string {
+	if auth == nil {
+		return region
+	}
+	return auth.ID + "|" + region
+}
+
+// order returns the configured regions with the cooling ones moved behind the others,
+// keeping the configured order within both groups.
+func (s *vertexRegionState) order(auth *cliproxyauth.Auth, configured []string) []string {
+	regions := make([]string, 0, len(configured))
+	for _, region := range configured {
+		if region = strings.TrimSpace(region); region != "" {
+			regions = append(regions, region)
+		}
+	}
+	if len(regions) == 0 {
+		return []string{defaultVertexRegion}
+	}
+	now := time.Now()
+	ready := make([]string, 0, len(regions))
+	var cooling []string
+	s.mu.Lock()
+	for _, region := range regions {
+		key := vertexRegionKey(auth, region)
+		if until, ok := s.cooling[key]; ok && now.Before(until) {
+			cooling = append(cooling, region)
+			continue
+		}
+		delete(s.cooling, key)
+		ready = append(ready, region)
+	}
+	s.mu.Unlock()
+	return append(ready, cooling...)
+}
+
+func (s *vertexRegionState) cool(auth *cliproxyauth.Auth, region string, retryAfter time.Duration) {
+	s.mu.Lock()
+	s.cooling[vertexRegionKey(auth, region)] = time.Now().Add(max(retryAfter, vertexRegionCooldown))
+	s.mu.Unlock()
+}
+
+// vertexCredential is the token source of a Vertex AI credential and the project it belongs to.
+type vertexCredential struct {
+	tokens    oauth2.TokenSource
+	projectID string
+}
+
+// vertexCredentials caches token sources by credential, shared by the executor instances
+// that are created as the configuration is reloaded.
+var vertexCredentials = struct {
+	sync.Mutex
+	sources map[string]*vertexCredential
+}{sources: make(map[string]*vertexCredential)}
+
+// credential returns the token source for the service account key of auth, given inline or
+// as a file, or for Application Default Credentials when auth has no key. Token requests go
+// through the proxy of auth.
+func (e *VertexExecutor) credential(ctx context.Context, auth *cliproxyauth.Auth) (*vertexCredential, error) {
+	var inline, file string
+	if auth != nil && auth.Attributes != nil {
+		inline, file = auth.Attributes["credentials"], auth.Attributes["credentials_file"]
+	}
+	key := "adc"
+	switch {
+	case inline != "":
+		sum := sha256.Sum256([]byte(inline))
+		key = "json:" + hex.EncodeToString(sum[:])
+	case file != "":
+		key = "file:" + file
+	}
+	if auth != nil && auth.ProxyURL != "" {
+		key += "|" + auth.ProxyURL
+	}
+	vertexCredentials.Lock()
+	defer vertexCredentials.Unlock()
+	if cached, ok := vertexCredentials.sources[key]; ok {
+		return cached, nil
+	}
+
+	// Token sources outlive the request, so they must not use its context.
+	tokenCtx := context.WithValue(context.Background(), oauth2.HTTPClient, newProxyAwareHTTPClient(ctx, e.cfg, auth, 0))
+	var creds *google.Credentials
+	var err error
+	switch {
+	case inline != "":
+		creds, err = google.CredentialsFromJSON(tokenCtx, []byte(inline), vertexScope)
+	case file != "":
+		var data []byte
+		if data, err = os.ReadFile(file); err == nil {
+			creds, err = google.CredentialsFromJSON(tokenCtx, data, vertexScope)
+		}
+	default:
+		creds, err = google.FindDefaultCredentials(tokenCtx, vertexScope)
+	}
+	if err != nil {
+		return nil, statusErr{code: http.StatusUnauthorized, msg: fmt.Sprintf("vertex executor: load credentials: %v", err)}
+	}
+	credential := &vertexCredential{tokens: creds.TokenSource, projectID: creds.ProjectID}
+	vertexCredentials.sources[key] = credential
+	return credential, nil
+}
+
+// resolveTarget returns the publisher model configured for model, or model itself. The
+// publisher is the configured one, or anthropic for Claude models and google otherwise.
+func (e *VertexExecutor) resolveTarget(model string, auth *cliproxyauth.Auth) vertexTarget {
+	target := vertexTarget{model: model}
+	if entry := e.resolveVertexConfig(auth); entry != nil {
+		for i := range entry.Models {
+			candidate := entry.Models[i]
+			name := strings.TrimSpace(candidate.Name)
+			alias := strings.TrimSpace(candidate.Alias)
+			matched := strings.EqualFold(alias, model) || (alias == "" && strings.EqualFold(name, model))
+			if name == "" || !matched {
+				continue
+			}
+			target = vertexTarget{model: name, publisher: strings.ToLower(strings.TrimSpace(candidate.Publisher))}
+			break
+		}
+	}
+	if target.publisher == "" {
+		target.publisher = "google"
+		if strings.HasPrefix(strings.ToLower(target.model), "claude") {
+			target.publisher = "anthropic"
+		}
+	}
+	return target
+}
+
+func (e *VertexExecutor) resolveVertexConfig(auth *cliproxyauth.Auth) *config.VertexAIKey {
+	if auth == nil || auth.Attributes == nil || e.cfg == nil {
+		return nil
+	}
+	attrs := auth.Attributes
+	for i := range e.cfg.VertexAI {
+		entry := &e.cfg.VertexAI[i]
+		if entry.ProjectID == attrs["project_id"] && entry.CredentialsFile == attrs["credentials_file"] &&
+			entry.Credentials == attrs["credentials"] && entry.BaseURL == attrs["base_url"] {
+			return entry
+		}
+	}
+	return nil
+}
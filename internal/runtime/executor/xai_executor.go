@@ -0,0 +1,357 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const defaultXAIBaseURL = "https://api.x.ai/v1"
+
+// XAIExecutor is a stateless executor for the xAI API. Requests use the OpenAI Chat
+// Completions schema, adjusted to the parameters Grok models accept, and reasoning_content
+// in the responses is translated like that of other OpenAI-compatible upstreams.
+type XAIExecutor struct {
+	cfg *config.Config
+}
+
+func NewXAIExecutor(cfg *config.Config) *XAIExecutor { return &XAIExecutor{cfg: cfg} }
+
+func (e *XAIExecutor) Identifier() string { return "xai" }
+
+func (e *XAIExecutor) PrepareRequest(_ *http.Request, _ *cliproxyauth.Auth) error { return nil }
+
+func (e *XAIExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (resp cliproxyexecutor.Response, err error) {
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	from := opts.SourceFormat
+	to := sdktranslator.FromString("openai")
+	body := sdktranslator.TranslateRequest(ctx, from, to, req.Model, bytes.Clone(req.Payload), false)
+	body = e.prepareBody(body, req, auth)
+
+	httpResp, err := e.send(ctx, auth, body, false)
+	if err != nil {
+		return resp, err
+	}
+	defer func() {
+		if errClose := httpResp.Body.Close(); errClose != nil {
+			log.Errorf("xai executor: close response body error: %v", errClose)
+		}
+	}()
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		recordAPIResponseError(ctx, e.cfg, err)
+		return resp, err
+	}
+	appendAPIResponseChunk(ctx, e.cfg, data)
+	reporter.publish(ctx, parseOpenAIUsage(data))
+	reporter.observeOutput(data)
+	reporter.publishEstimate(ctx, req.Payload)
+	var param any
+	out := sdktranslator.TranslateNonStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), body, data, &param)
+	resp = cliproxyexecutor.Response{Payload: []byte(out)}
+	return resp, nil
+}
+
+func (e *XAIExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (stream <-chan cliproxyexecutor.StreamChunk, err error) {
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	from := opts.SourceFormat
+	to := sdktranslator.FromString("openai")
+	body := sdktranslator.TranslateRequest(ctx, from, to, req.Model, bytes.Clone(req.Payload), true)
+	body = e.prepareBody(body, req, auth)
+
+	httpResp, err := e.send(ctx, auth, body, true)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan cliproxyexecutor.StreamChunk)
+	stream = out
+	go func() {
+		defer close(out)
+		defer func() {
+			if errClose := httpResp.Body.Close(); errClose != nil {
+				log.Errorf("xai executor: close response body error: %v", errClose)
+			}
+		}()
+		scanner := bufio.NewScanner(httpResp.Body)
+		buf := make([]byte, 20_971_520)
+		scanner.Buffer(buf, 20_971_520)
+		var param any
+		for scanner.Scan() {
+			reporter.markFirstChunk()
+			line := scanner.Bytes()
+			appendAPIResponseChunk(ctx, e.cfg, line)
+			if detail, ok := parseOpenAIStreamUsage(line); ok {
+				reporter.publish(ctx, detail)
+			}
+			reporter.observeOutput(line)
+			if len(line) == 0 {
+				continue
+			}
+			chunks := sdktranslator.TranslateStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), body, bytes.Clone(line), &param)
+			for i := range chunks {
+				out <- cliproxyexecutor.StreamChunk{Payload: []byte(chunks[i])}
+			}
+		}
+		if errScan := scanner.Err(); errScan != nil {
+			recordAPIResponseError(ctx, e.cfg, errScan)
+			reporter.publishFailure(ctx, errScan)
+			out <- cliproxyexecutor.StreamChunk{Err: errScan}
+		}
+		reporter.publishEstimate(ctx, req.Payload)
+	}()
+	return stream, nil
+}
+
+func (e *XAIExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	from := opts.SourceFormat
+	to := sdktranslator.FromString("openai")
+	body := sdktranslator.TranslateRequest(ctx, from, to, req.Model, bytes.Clone(req.Payload), false)
+
+	enc, err := tokenizerForModel(e.resolveUpstreamModel(req.Model, auth))
+	if err != nil {
+		return cliproxyexecutor.Response{}, fmt.Errorf("xai executor: tokenizer init failed: %w", err)
+	}
+
+	count, err := countOpenAIChatTokens(enc, body)
+	if err != nil {
+		return cliproxyexecutor.Response{}, fmt.Errorf("xai executor: token counting failed: %w", err)
+	}
+
+	usageJSON := buildOpenAIUsageJSON(count)
+	translated := sdktranslator.TranslateTokenCount(ctx, to, from, count, usageJSON)
+	return cliproxyexecutor.Response{Payload: []byte(translated)}, nil
+}
+
+// Refresh is a no-op for API-key based xAI credentials.
+func (e *XAIExecutor) Refresh(_ context.Context, auth *cliproxyauth.Auth) (*cliproxyauth.Auth, error) {
+	log.Debugf("xai executor: refresh called")
+	return auth, nil
+}
+
+// ListModels queries the models endpoint of the xAI API.
+func (e *XAIExecutor) ListModels(ctx context.Context, auth *cliproxyauth.Auth) ([]*registry.ModelInfo, error) {
+	baseURL, apiKey := xaiCredentials(auth)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+apiKey)
+	httpReq.Header.Set("User-Agent", "cli-proxy-xai")
+	data, err := fetchModelList(ctx, e.cfg, auth, httpReq)
+	if err != nil {
+		return nil, modelListError(e.Identifier(), err)
+	}
+	return parseOpenAIModelList(data, e.Identifier()), nil
+}
+
+// send posts body to the chat completions endpoint and returns the response when its
+// status is 2xx.
+func (e *XAIExecutor) send(ctx context.Context, auth *cliproxyauth.Auth, body []byte, stream bool) (*http.Response, error) {
+	baseURL, apiKey := xaiCredentials(auth)
+	if apiKey == "" {
+		return nil, statusErr{code: http.StatusUnauthorized, msg: "missing xai api key"}
+	}
+	url := baseURL + "/chat/completions"
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+apiKey)
+	httpReq.Header.Set("User-Agent", "cli-proxy-xai")
+	if stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+		httpReq.Header.Set("Cache-Control", "no-cache")
+	}
+	var authID, authLabel, authType, authValue string
+	if auth != nil {
+		authID = auth.ID
+		authLabel = auth.Label
+		authType, authValue = auth.AccountInfo()
+	}
+	recordAPIRequest(ctx, e.cfg, upstreamRequestLog{
+		URL:       url,
+		Method:    http.MethodPost,
+		Headers:   httpReq.Header.Clone(),
+		Body:      body,
+		Provider:  e.Identifier(),
+		AuthID:    authID,
+		AuthLabel: authLabel,
+		AuthType:  authType,
+		AuthValue: authValue,
+	})
+
+	httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
+	httpResp, err := httpClient.Do(httpReq)
+	if err != nil {
+		recordAPIResponseError(ctx, e.cfg, err)
+		return nil, err
+	}
+	recordAPIResponseMetadata(ctx, e.cfg, httpResp.StatusCode, httpResp.Header.Clone())
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		b, _ := io.ReadAll(httpResp.Body)
+		appendAPIResponseChunk(ctx, e.cfg, b)
+		log.Debugf("request error, error status: %d, error body: %s", httpResp.StatusCode, string(b))
+		if errClose := httpResp.Body.Close(); errClose != nil {
+			log.Errorf("xai executor: close response body error: %v", errClose)
+		}
+		return nil, statusErr{code: httpResp.StatusCode, msg: string(b), retryAfter: parseRetryAfter(httpResp.Header)}
+	}
+	return httpResp, nil
+}
+
+// prepareBody sets the upstream model and adapts the translated request to xAI: reasoning
+// parameters are limited to what the model accepts and web search requested by the client
+// or configured for the key is sent as search_parameters.
+func (e *XAIExecutor) prepareBody(body []byte, req cliproxyexecutor.Request, auth *cliproxyauth.Auth) []byte {
+	model := e.resolveUpstreamModel(req.Model, auth)
+	body, _ = sjson.SetBytes(body, "model", model)
+
+	// Only grok-3-mini takes reasoning_effort, with "low" or "high"; the other reasoning
+	// models reject it along with the penalty and stop parameters.
+	if effort := gjson.GetBytes(body, "reasoning_effort"); effort.Exists() {
+		switch {
+		case !strings.HasPrefix(model, "grok-3-mini"), effort.String() == "none":
+			body, _ = sjson.DeleteBytes(body, "reasoning_effort")
+		case effort.String() == "minimal" || effort.String() == "low":
+			body, _ = sjson.SetBytes(body, "reasoning_effort", "low")
+		default:
+			body, _ = sjson.SetBytes(body, "reasoning_effort", "high")
+		}
+	}
+	if xaiReasoningModel(model) {
+		for _, field := range []string{"presence_penalty", "frequency_penalty", "stop"} {
+			body, _ = sjson.DeleteBytes(body, field)
+		}
+	}
+
+	requested := xaiSearchRequested(req.Payload)
+	body, _ = sjson.DeleteBytes(body, "web_search_options")
+	if gjson.GetBytes(body, "search_parameters").Exists() {
+		return body
+	}
+	var search config.XAILiveSearch
+	if entry := e.resolveXAIConfig(auth); entry != nil {
+		search = entry.LiveSearch
+	}
+	mode := search.Mode
+	switch {
+	case mode == "off":
+		return body
+	case mode == "" && !requested:
+		return body
+	case mode == "":
+		mode = "auto"
+	}
+	params := map[string]any{"mode": mode}
+	if len(search.Sources) > 0 {
+		sources := make([]map[string]string, 0, len(search.Sources))
+		for _, source := range search.Sources {
+			sources = append(sources, map[string]string{"type": source})
+		}
+		params["sources"] = sources
+	}
+	if search.MaxSearchResults > 0 {
+		params["max_search_results"] = search.MaxSearchResults
+	}
+	if search.ReturnCitations != nil {
+		params["return_citations"] = *search.ReturnCitations
+	}
+	body, _ = sjson.SetBytes(body, "search_parameters", params)
+	return body
+}
+
+// xaiReasoningModel reports whether model always reasons, in which case xAI rejects the
+// presence_penalty, frequency_penalty and stop parameters.
+func xaiReasoningModel(model string) bool {
+	switch {
+	case strings.HasPrefix(model, "grok-3-mini"), strings.HasPrefix(model, "grok-code"):
+		return true
+	case strings.HasPrefix(model, "grok-4"):
+		return !strings.Contains(model, "non-reasoning")
+	default:
+		return false
+	}
+}
+
+// xaiSearchRequested reports whether an inbound request asks for web search: with
+// web_search_options in the Chat Completions API, a web_search tool in the Responses and
+// Claude APIs or a googleSearch tool in the Gemini API.
+func xaiSearchRequested(payload []byte) bool {
+	if gjson.GetBytes(payload, "web_search_options").Exists() {
+		return true
+	}
+	for _, tool := range gjson.GetBytes(payload, "tools").Array() {
+		if strings.HasPrefix(tool.Get("type").String(), "web_search") {
+			return true
+		}
+		if tool.Get("googleSearch").Exists() || tool.Get("google_search").Exists() {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveUpstreamModel returns the xAI model configured for alias, or alias itself.
+func (e *XAIExecutor) resolveUpstreamModel(alias string, auth *cliproxyauth.Auth) string {
+	entry := e.resolveXAIConfig(auth)
+	if entry == nil {
+		return alias
+	}
+	for i := range entry.Models {
+		model := entry.Models[i]
+		if model.Alias != "" {
+			if strings.EqualFold(model.Alias, alias) && model.Name != "" {
+				return model.Name
+			}
+			continue
+		}
+		if model.Name != "" && strings.EqualFold(model.Name, alias) {
+			return model.Name
+		}
+	}
+	return alias
+}
+
+func (e *XAIExecutor) resolveXAIConfig(auth *cliproxyauth.Auth) *config.XAIKey {
+	if auth == nil || auth.Attributes == nil || e.cfg == nil {
+		return nil
+	}
+	apiKey, baseURL := auth.Attributes["api_key"], auth.Attributes["base_url"]
+	for i := range e.cfg.XAIKey {
+		entry := &e.cfg.XAIKey[i]
+		if entry.APIKey == apiKey && entry.BaseURL == baseURL {
+			return entry
+		}
+	}
+	return nil
+}
+
+// xaiCredentials returns the base URL without a trailing slash and the API key of auth.
+func xaiCredentials(auth *cliproxyauth.Auth) (baseURL, apiKey string) {
+	if auth != nil && auth.Attributes != nil {
+		baseURL = strings.TrimSpace(auth.Attributes["base_url"])
+		apiKey = strings.TrimSpace(auth.Attributes["api_key"])
+	}
+	if baseURL == "" {
+		baseURL = defaultXAIBaseURL
+	}
+	return strings.TrimSuffix(baseURL, "/"), apiKey
+}
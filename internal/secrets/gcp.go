@@ -0,0 +1,73 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const gcpScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// gcpClient reads GCP Secret Manager secrets with Application Default Credentials.
+type gcpClient struct {
+	mu     sync.Mutex
+	tokens oauth2.TokenSource
+}
+
+// fetch reads a secret version; name is "secret[@version]" in the configured project or a
+// full "projects/<project>/secrets/<secret>" resource name.
+func (c *gcpClient) fetch(ctx context.Context, client *http.Client, cfg config.GCPSecrets, name string) (string, error) {
+	name, version, found := strings.Cut(name, "@")
+	if !found || version == "" {
+		version = "latest"
+	}
+	resource := name
+	if !strings.HasPrefix(name, "projects/") {
+		project := firstNonEmpty(cfg.Project, os.Getenv("GOOGLE_CLOUD_PROJECT"))
+		if project == "" {
+			return "", fmt.Errorf("secrets: gcp project not configured")
+		}
+		resource = "projects/" + project + "/secrets/" + name
+	}
+	token, err := c.token()
+	if err != nil {
+		return "", fmt.Errorf("secrets: gcp credentials: %w", err)
+	}
+	url := "https://secretmanager.googleapis.com/v1/" + resource + "/versions/" + version + ":access"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("secrets: gcp: %w", err)
+	}
+	token.SetAuthHeader(req)
+	body, err := doRequest(client, req)
+	if err != nil {
+		return "", fmt.Errorf("secrets: gcp %s: %w", resource, err)
+	}
+	data, err := base64.StdEncoding.DecodeString(gjson.GetBytes(body, "payload.data").String())
+	if err != nil {
+		return "", fmt.Errorf("secrets: gcp %s: invalid payload: %w", resource, err)
+	}
+	return string(data), nil
+}
+
+func (c *gcpClient) token() (*oauth2.Token, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tokens == nil {
+		source, err := google.DefaultTokenSource(context.Background(), gcpScope)
+		if err != nil {
+			return nil, err
+		}
+		c.tokens = source
+	}
+	return c.tokens.Token()
+}
@@ -0,0 +1,26 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// fetchFile reads a secret file, such as a Kubernetes or Docker secret mounted into the
+// container. The trailing newline editors and kubectl leave is dropped.
+func fetchFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("secrets: read %s: %w", path, err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// fetchEnv reads a secret from an environment variable, which must be set.
+func fetchEnv(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("secrets: environment variable %s is not set", name)
+	}
+	return value, nil
+}
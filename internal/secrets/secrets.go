@@ -0,0 +1,159 @@
+// Package secrets resolves the secret references in the configuration against HashiCorp
+// Vault, AWS Secrets Manager, GCP Secret Manager, mounted secret files and environment
+// variables, so that upstream API keys and other credentials do not have to be written
+// into config.yaml. Resolved values are kept and re-read periodically to pick up rotated
+// secrets.
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	// DefaultRefreshInterval is how often secrets are re-read when no interval is configured.
+	DefaultRefreshInterval = 5 * time.Minute
+
+	fetchTimeout = 30 * time.Second
+)
+
+func init() {
+	config.RegisterSecretResolver(defaultResolver.Resolve)
+}
+
+var defaultResolver = newResolver()
+
+// resolver fetches secrets and remembers them by reference.
+type resolver struct {
+	mu      sync.Mutex
+	secrets config.Secrets
+	values  map[string]string
+	client  *http.Client
+	gcp     *gcpClient
+}
+
+func newResolver() *resolver {
+	return &resolver{
+		values: make(map[string]string),
+		client: &http.Client{Timeout: fetchTimeout},
+		gcp:    &gcpClient{},
+	}
+}
+
+// Resolve implements config.SecretResolver. Secrets fetched before are served from memory;
+// Refresh keeps them current.
+func (r *resolver) Resolve(secrets config.Secrets, ref string) (string, error) {
+	r.mu.Lock()
+	r.secrets = secrets
+	value, ok := r.values[ref]
+	r.mu.Unlock()
+	if ok {
+		return value, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+	value, err := r.fetch(ctx, secrets, ref)
+	if err != nil {
+		return "", err
+	}
+	r.mu.Lock()
+	r.values[ref] = value
+	r.mu.Unlock()
+	return value, nil
+}
+
+// Refresh re-reads every secret resolved so far and reports whether any of them changed.
+// Secrets that cannot be read keep their previous value.
+func Refresh(ctx context.Context) bool {
+	return defaultResolver.refresh(ctx)
+}
+
+// RefreshInterval returns the configured refresh interval, or the default.
+func RefreshInterval(cfg *config.Config) time.Duration {
+	if cfg != nil && cfg.Secrets.RefreshInterval > 0 {
+		return cfg.Secrets.RefreshInterval
+	}
+	return DefaultRefreshInterval
+}
+
+func (r *resolver) refresh(ctx context.Context) bool {
+	r.mu.Lock()
+	secrets := r.secrets
+	refs := make([]string, 0, len(r.values))
+	for ref := range r.values {
+		refs = append(refs, ref)
+	}
+	r.mu.Unlock()
+
+	changed := false
+	for _, ref := range refs {
+		fetchCtx, cancel := context.WithTimeout(ctx, fetchTimeout)
+		value, err := r.fetch(fetchCtx, secrets, ref)
+		cancel()
+		if err != nil {
+			log.Warnf("secrets: failed to refresh %s: %v", ref, err)
+			continue
+		}
+		r.mu.Lock()
+		if r.values[ref] != value {
+			r.values[ref] = value
+			changed = true
+			log.Infof("secrets: %s changed", ref)
+		}
+		r.mu.Unlock()
+	}
+	return changed
+}
+
+// fetch reads the secret ref points to from its secrets manager.
+func (r *resolver) fetch(ctx context.Context, secrets config.Secrets, ref string) (string, error) {
+	scheme, rest, _ := strings.Cut(ref, "://")
+	name, field := rest, ""
+	if i := strings.LastIndex(rest, "#"); i >= 0 {
+		name, field = rest[:i], rest[i+1:]
+	}
+	if name == "" {
+		return "", fmt.Errorf("secrets: invalid reference %s", ref)
+	}
+	var (
+		value string
+		err   error
+	)
+	switch scheme {
+	case "vault":
+		// Vault secrets are always key/value maps.
+		if field == "" {
+			field = "value"
+		}
+		return fetchVault(ctx, r.client, secrets.Vault, name, field)
+	case "aws-sm":
+		value, err = fetchAWS(ctx, r.client, secrets.AWS, name)
+	case "gcp-sm":
+		value, err = r.gcp.fetch(ctx, r.client, secrets.GCP, name)
+	case "file":
+		value, err = fetchFile(name)
+	case "env":
+		value, err = fetchEnv(name)
+	default:
+		return "", fmt.Errorf("secrets: unsupported reference %s", ref)
+	}
+	if err != nil {
+		return "", err
+	}
+	if field == "" {
+		return value, nil
+	}
+	result := gjson.Get(value, gjson.Escape(field))
+	if !result.Exists() {
+		return "", fmt.Errorf("secrets: %s has no key %q", name, field)
+	}
+	return result.String(), nil
+}
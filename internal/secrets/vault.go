@@ -0,0 +1,80 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+// fetchVault reads field of the KV secret at path, whose first segment is the mount.
+func fetchVault(ctx context.Context, client *http.Client, cfg config.VaultSecrets, path, field string) (string, error) {
+	address := firstNonEmpty(cfg.Address, os.Getenv("VAULT_ADDR"))
+	if address == "" {
+		return "", fmt.Errorf("secrets: vault address not configured")
+	}
+	token := firstNonEmpty(cfg.Token, os.Getenv("VAULT_TOKEN"))
+	if token == "" {
+		return "", fmt.Errorf("secrets: vault token not configured")
+	}
+	path = strings.Trim(path, "/")
+	dataPath := "data"
+	url := strings.TrimRight(address, "/") + "/v1/" + path
+	if cfg.KVVersion != 1 {
+		mount, rest, found := strings.Cut(path, "/")
+		if !found || rest == "" {
+			return "", fmt.Errorf("secrets: vault path %s has no secret name after the mount", path)
+		}
+		url = strings.TrimRight(address, "/") + "/v1/" + mount + "/data/" + rest
+		dataPath = "data.data"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("secrets: vault: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if namespace := firstNonEmpty(cfg.Namespace, os.Getenv("VAULT_NAMESPACE")); namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+	body, err := doRequest(client, req)
+	if err != nil {
+		return "", fmt.Errorf("secrets: vault %s: %w", path, err)
+	}
+	value := gjson.GetBytes(body, dataPath+"."+gjson.Escape(field))
+	if !value.Exists() {
+		return "", fmt.Errorf("secrets: vault %s has no field %q", path, field)
+	}
+	return value.String(), nil
+}
+
+// doRequest sends req and returns the body of a successful response.
+func doRequest(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
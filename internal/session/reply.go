@@ -0,0 +1,231 @@
+package session
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// Reply extracts the message the model answered with from a response body in format,
+// reassembling streamed responses from their events. It returns nil when the response holds
+// no reply.
+func Reply(format Format, body []byte, streamed bool) json.RawMessage {
+	if streamed {
+		events := streamEvents(body)
+		switch format {
+		case FormatOpenAIChat:
+			return openAIStreamReply(events)
+		case FormatClaude:
+			return claudeStreamReply(events)
+		case FormatGemini:
+			return geminiReply(events)
+		}
+		return nil
+	}
+	root := gjson.ParseBytes(body)
+	switch format {
+	case FormatOpenAIChat:
+		message := root.Get("choices.0.message")
+		if !message.Exists() {
+			return nil
+		}
+		return openAIReply(message.Get("content").String(), message.Get("tool_calls"))
+	case FormatClaude:
+		content := root.Get("content")
+		if !content.IsArray() || len(content.Array()) == 0 {
+			return nil
+		}
+		return marshal(map[string]any{"role": "assistant", "content": json.RawMessage(content.Raw)})
+	case FormatGemini:
+		if root.IsArray() {
+			return geminiReply(root.Array())
+		}
+		return geminiReply([]gjson.Result{root})
+	}
+	return nil
+}
+
+// streamEvents returns the JSON payloads of a streamed response: the data of its SSE events,
+// or the elements of a JSON array as Gemini streams without alt=sse.
+func streamEvents(body []byte) []gjson.Result {
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		return gjson.ParseBytes(trimmed).Array()
+	}
+	var out []gjson.Result
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			continue
+		}
+		data := bytes.TrimSpace(line[len("data:"):])
+		if gjson.ValidBytes(data) {
+			out = append(out, gjson.ParseBytes(data))
+		}
+	}
+	return out
+}
+
+func openAIReply(content string, toolCalls gjson.Result) json.RawMessage {
+	message := map[string]any{"role": "assistant", "content": content}
+	if toolCalls.IsArray() && len(toolCalls.Array()) > 0 {
+		message["tool_calls"] = json.RawMessage(toolCalls.Raw)
+		if content == "" {
+			message["content"] = nil
+		}
+	} else if content == "" {
+		return nil
+	}
+	return marshal(message)
+}
+
+func openAIStreamReply(events []gjson.Result) json.RawMessage {
+	var content strings.Builder
+	type toolCall struct {
+		ID        string
+		Type      string
+		Name      string
+		Arguments strings.Builder
+	}
+	calls := make(map[int64]*toolCall)
+	for _, event := range events {
+		delta := event.Get("choices.0.delta")
+		content.WriteString(delta.Get("content").String())
+		for _, call := range delta.Get("tool_calls").Array() {
+			index := call.Get("index").Int()
+			entry, ok := calls[index]
+			if !ok {
+				entry = &toolCall{Type: "function"}
+				calls[index] = entry
+			}
+			if id := call.Get("id").String(); id != "" {
+				entry.ID = id
+			}
+			if typ := call.Get("type").String(); typ != "" {
+				entry.Type = typ
+			}
+			entry.Name += call.Get("function.name").String()
+			entry.Arguments.WriteString(call.Get("function.arguments").String())
+		}
+	}
+	indexes := make([]int64, 0, len(calls))
+	for index := range calls {
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+	toolCalls := make([]map[string]any, 0, len(indexes))
+	for _, index := range indexes {
+		call := calls[index]
+		toolCalls = append(toolCalls, map[string]any{
+			"id":       call.ID,
+			"type":     call.Type,
+			"function": map[string]string{"name": call.Name, "arguments": call.Arguments.String()},
+		})
+	}
+	return openAIReply(content.String(), gjson.ParseBytes(marshal(toolCalls)))
+}
+
+func claudeStreamReply(events []gjson.Result) json.RawMessage {
+	type block struct {
+		value map[string]any
+		text  strings.Builder
+		input strings.Builder
+	}
+	blocks := make(map[int64]*block)
+	var order []int64
+	for _, event := range events {
+		index := event.Get("index").Int()
+		switch event.Get("type").String() {
+		case "content_block_start":
+			var value map[string]any
+			if err := json.Unmarshal([]byte(event.Get("content_block").Raw), &value); err != nil {
+				continue
+			}
+			if _, ok := blocks[index]; !ok {
+				order = append(order, index)
+			}
+			blocks[index] = &block{value: value}
+		case "content_block_delta":
+			entry, ok := blocks[index]
+			if !ok {
+				continue
+			}
+			delta := event.Get("delta")
+			switch delta.Get("type").String() {
+			case "text_delta":
+				entry.text.WriteString(delta.Get("text").String())
+			case "thinking_delta":
+				entry.text.WriteString(delta.Get("thinking").String())
+			case "signature_delta":
+				entry.value["signature"] = delta.Get("signature").String()
+			case "input_json_delta":
+				entry.input.WriteString(delta.Get("partial_json").String())
+			}
+		}
+	}
+	content := make([]map[string]any, 0, len(order))
+	for _, index := range order {
+		entry := blocks[index]
+		switch entry.value["type"] {
+		case "text":
+			if entry.text.Len() == 0 {
+				continue
+			}
+			entry.value["text"] = entry.text.String()
+		case "thinking":
+			entry.value["thinking"] = entry.text.String()
+		case "tool_use", "server_tool_use":
+			input := json.RawMessage(entry.input.String())
+			if len(input) == 0 || !json.Valid(input) {
+				input = json.RawMessage("{}")
+			}
+			entry.value["input"] = input
+		}
+		content = append(content, entry.value)
+	}
+	if len(content) == 0 {
+		return nil
+	}
+	return marshal(map[string]any{"role": "assistant", "content": content})
+}
+
+// geminiReply merges the parts of one or more Gemini responses into a model turn: text
+// parts are joined and thoughts are dropped, while function calls are kept as they are.
+func geminiReply(responses []gjson.Result) json.RawMessage {
+	var parts []json.RawMessage
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			parts = append(parts, marshal(map[string]string{"text": text.String()}))
+			text.Reset()
+		}
+	}
+	for _, response := range responses {
+		for _, part := range response.Get("candidates.0.content.parts").Array() {
+			if part.Get("thought").Bool() {
+				continue
+			}
+			if value := part.Get("text"); value.Exists() && len(part.Map()) == 1 {
+				text.WriteString(value.String())
+				continue
+			}
+			flush()
+			parts = append(parts, json.RawMessage(part.Raw))
+		}
+	}
+	flush()
+	if len(parts) == 0 {
+		return nil
+	}
+	return marshal(map[string]any{"role": "model", "parts": parts})
+}
+
+func marshal(value any) json.RawMessage {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	return data
+}
@@ -0,0 +1,471 @@
+// Package session keeps conversation history on the server. A client that names a session
+// sends only its newest messages; the stored history of the session is put in front of them
+// before the request goes upstream, and the messages and the model's reply are added to the
+// history once the response completes. Histories are trimmed to a configured size.
+package session
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/redis"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	defaultTTL         = 24 * time.Hour
+	defaultMaxSessions = 10000
+	// bytesPerToken is the estimate used to size histories without tokenizing them.
+	bytesPerToken = 4
+)
+
+// Format identifies the request schema of a session.
+type Format string
+
+// Formats sessions support.
+const (
+	FormatOpenAIChat Format = "openai-chat"
+	FormatClaude     Format = "claude"
+	FormatGemini     Format = "gemini"
+)
+
+// Session is a stored conversation.
+type Session struct {
+	// Ref identifies the session across API keys; see Ref.
+	Ref string `json:"ref"`
+	// ID is the session ID the client sent.
+	ID string `json:"id"`
+	// APIKey is the masked API key the session belongs to.
+	APIKey string `json:"api_key"`
+	Format Format `json:"format"`
+	// System is the latest system prompt of the conversation: the system messages of OpenAI
+	// requests as an array, the system field of Claude requests or the system instruction
+	// of Gemini requests.
+	System   json.RawMessage   `json:"system,omitempty"`
+	Messages []json.RawMessage `json:"messages"`
+	Created  time.Time         `json:"created"`
+	Updated  time.Time         `json:"updated"`
+}
+
+// Summary is the listing view of a session.
+type Summary struct {
+	Ref             string    `json:"ref"`
+	ID              string    `json:"id"`
+	APIKey          string    `json:"api_key"`
+	Format          Format    `json:"format"`
+	Messages        int       `json:"messages"`
+	EstimatedTokens int64     `json:"estimated_tokens"`
+	Created         time.Time `json:"created"`
+	Updated         time.Time `json:"updated"`
+}
+
+func (s *Session) summary() Summary {
+	return Summary{
+		Ref:             s.Ref,
+		ID:              s.ID,
+		APIKey:          s.APIKey,
+		Format:          s.Format,
+		Messages:        len(s.Messages),
+		EstimatedTokens: estimate(s.Messages),
+		Created:         s.Created,
+		Updated:         s.Updated,
+	}
+}
+
+// FormatError reports a request whose format differs from the format of its session.
+type FormatError struct {
+	ID      string
+	Session Format
+	Request Format
+}
+
+func (e *FormatError) Error() string {
+	return fmt.Sprintf("session %s holds a conversation in %s format and cannot continue in %s format", e.ID, e.Session, e.Request)
+}
+
+// Ref returns the reference of the session id of apiKey. Sessions of different API keys
+// never share history, even when their IDs are equal.
+func Ref(apiKey, id string) string {
+	sum := sha256.Sum256([]byte(apiKey + "\x00" + id))
+	return hex.EncodeToString(sum[:16])
+}
+
+// store persists sessions.
+type store interface {
+	get(ctx context.Context, ref string) (*Session, bool, error)
+	// update replaces the session at ref with the result of change atomically; change gets
+	// the stored session and whether it exists, and may run again when the update races.
+	update(ctx context.Context, ref string, ttl time.Duration, change func(*Session, bool) *Session) error
+	delete(ctx context.Context, ref string) (bool, error)
+	list(ctx context.Context) ([]*Session, error)
+	clear(ctx context.Context) error
+}
+
+// Manager expands requests with their session history and records replies. The zero
+// value, and a disabled manager, leave requests alone.
+type Manager struct {
+	mu    sync.RWMutex
+	cfg   config.SessionsConfig
+	redis config.Redis
+	store store
+}
+
+// New creates a manager for the sessions configuration of cfg. A backend that cannot be
+// set up is reported and leaves sessions disabled.
+func New(cfg *config.Config) (*Manager, error) {
+	m := &Manager{}
+	if err := m.Configure(cfg); err != nil {
+		return m, err
+	}
+	return m, nil
+}
+
+// Configure applies a new configuration. Stored sessions are kept unless the backend
+// changes.
+func (m *Manager) Configure(cfg *config.Config) error {
+	sessions := cfg.Sessions
+	if sessions.TTL <= 0 {
+		sessions.TTL = defaultTTL
+	}
+	if sessions.MaxSessions <= 0 {
+		sessions.MaxSessions = defaultMaxSessions
+	}
+	if sessions.Backend == "" {
+		sessions.Backend = "memory"
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current := m.store
+	switch sessions.Backend {
+	case "memory":
+		if memory, ok := current.(*memoryStore); ok {
+			memory.resize(sessions.MaxSessions)
+		} else {
+			current = newMemoryStore(sessions.MaxSessions)
+		}
+	case "redis":
+		if _, ok := current.(*redisStore); !ok || m.redis != cfg.Redis {
+			if !sessions.Enable {
+				current = nil
+				break
+			}
+			client, err := redis.NewClient(cfg.Redis)
+			if err != nil {
+				m.cfg.Enable = false
+				return fmt.Errorf("sessions: %w", err)
+			}
+			current = newRedisStore(client)
+		}
+	default:
+		return fmt.Errorf("sessions: unknown backend %q", sessions.Backend)
+	}
+	if previous, ok := m.store.(*redisStore); ok && current != m.store {
+		_ = previous.close()
+	}
+	m.cfg = sessions
+	m.redis = cfg.Redis
+	m.store = current
+	return nil
+}
+
+// Enabled reports whether requests with a session ID are expanded.
+func (m *Manager) Enabled() bool {
+	if m == nil {
+		return false
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.cfg.Enable && m.store != nil
+}
+
+func (m *Manager) current() (config.SessionsConfig, store) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.cfg, m.store
+}
+
+// Turn is one request of a session between Prepare and Complete.
+type Turn struct {
+	manager *Manager
+	ref     string
+	id      string
+	apiKey  string
+	format  Format
+	system  json.RawMessage
+	added   []json.RawMessage
+	// Body is the request body with the history of the session put before its messages.
+	Body []byte
+	// History is the number of stored messages put before the messages of the request.
+	History int
+}
+
+// Prepare expands the request body of session id of apiKey with the stored history. A
+// session that does not exist yet starts with the messages of the request; system messages
+// of the request replace the stored system prompt.
+func (m *Manager) Prepare(ctx context.Context, apiKey, id string, format Format, body []byte) (*Turn, error) {
+	_, target := m.current()
+	if target == nil {
+		return nil, errors.New("sessions are disabled")
+	}
+	spec, ok := specs[format]
+	if !ok {
+		return nil, fmt.Errorf("sessions do not support %s requests", format)
+	}
+	ref := Ref(apiKey, id)
+	stored, found, err := target.get(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if found && stored.Format != format {
+		return nil, &FormatError{ID: id, Session: stored.Format, Request: format}
+	}
+	turn := &Turn{manager: m, ref: ref, id: id, apiKey: apiKey, format: format}
+	system, messages := spec.split(body)
+	turn.added = messages
+	turn.system = system
+	if len(system) == 0 && found {
+		system = stored.System
+	}
+	var history []json.RawMessage
+	if found {
+		history = stored.Messages
+	}
+	out, err := spec.join(body, system, append(append([]json.RawMessage{}, history...), messages...))
+	if err != nil {
+		return nil, err
+	}
+	turn.Body = out
+	turn.History = len(history)
+	return turn, nil
+}
+
+// Complete adds the messages of the request and the reply to the session and trims its
+// history. The session is updated atomically, so turns completing concurrently are all
+// kept. Replies without content leave the session unchanged.
+func (t *Turn) Complete(ctx context.Context, reply json.RawMessage) error {
+	if t == nil || len(reply) == 0 {
+		return nil
+	}
+	cfg, target := t.manager.current()
+	if target == nil {
+		return nil
+	}
+	return target.update(ctx, t.ref, cfg.TTL, func(stored *Session, found bool) *Session {
+		now := time.Now()
+		if !found || stored.Format != t.format {
+			stored = &Session{Ref: t.ref, ID: t.id, APIKey: util.HideAPIKey(t.apiKey), Format: t.format, Created: now}
+		}
+		if len(t.system) > 0 {
+			stored.System = t.system
+		}
+		stored.Messages = append(append(stored.Messages, t.added...), reply)
+		stored.Messages = trim(specs[t.format], cfg, stored.Messages)
+		stored.Updated = now
+		return stored
+	})
+}
+
+// List returns the stored sessions, most recently used first.
+func (m *Manager) List(ctx context.Context) ([]Summary, error) {
+	if m == nil {
+		return nil, nil
+	}
+	_, target := m.current()
+	if target == nil {
+		return nil, nil
+	}
+	sessions, err := target.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Summary, 0, len(sessions))
+	for _, s := range sessions {
+		out = append(out, s.summary())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Updated.After(out[j].Updated) })
+	return out, nil
+}
+
+// Get returns the session with the given reference.
+func (m *Manager) Get(ctx context.Context, ref string) (*Session, bool, error) {
+	if m == nil {
+		return nil, false, nil
+	}
+	_, target := m.current()
+	if target == nil {
+		return nil, false, nil
+	}
+	return target.get(ctx, ref)
+}
+
+// Delete removes the session with the given reference and reports whether it existed.
+func (m *Manager) Delete(ctx context.Context, ref string) (bool, error) {
+	if m == nil {
+		return false, nil
+	}
+	_, target := m.current()
+	if target == nil {
+		return false, nil
+	}
+	return target.delete(ctx, ref)
+}
+
+// Clear removes every session.
+func (m *Manager) Clear(ctx context.Context) error {
+	if m == nil {
+		return nil
+	}
+	_, target := m.current()
+	if target == nil {
+		return nil
+	}
+	return target.clear(ctx)
+}
+
+// formatSpec describes where a format keeps its system prompt and messages.
+type formatSpec struct {
+	messages string
+	// system is the path of the system prompt; empty when system prompts are messages.
+	system string
+	// startsTurn reports whether a trimmed history may start with a message; tool results
+	// whose call was dropped and replies may not.
+	startsTurn func(message gjson.Result) bool
+}
+
+var specs = map[Format]formatSpec{
+	FormatOpenAIChat: {
+		messages:   "messages",
+		startsTurn: func(message gjson.Result) bool { return message.Get("role").String() == "user" },
+	},
+	FormatClaude: {
+		messages: "messages",
+		system:   "system",
+		startsTurn: func(message gjson.Result) bool {
+			return message.Get("role").String() == "user" && message.Get("content.0.type").String() != "tool_result"
+		},
+	},
+	FormatGemini: {
+		messages: "contents",
+		system:   "systemInstruction",
+		startsTurn: func(message gjson.Result) bool {
+			if role := message.Get("role").String(); role != "" && role != "user" {
+				return false
+			}
+			for _, part := range message.Get("parts").Array() {
+				if part.Get("functionResponse").Exists() || part.Get("function_response").Exists() {
+					return false
+				}
+			}
+			return true
+		},
+	},
+}
+
+// split returns the system prompt and the messages of a request. OpenAI system and
+// developer messages at the start of the conversation form its system prompt.
+func (spec formatSpec) split(body []byte) (json.RawMessage, []json.RawMessage) {
+	var system json.RawMessage
+	var messages []json.RawMessage
+	items := gjson.GetBytes(body, spec.messages).Array()
+	switch spec.system {
+	case "":
+		head := 0
+		for head < len(items) {
+			role := items[head].Get("role").String()
+			if role != "system" && role != "developer" {
+				break
+			}
+			head++
+		}
+		if head > 0 {
+			raws := make([]string, 0, head)
+			for _, item := range items[:head] {
+				raws = append(raws, item.Raw)
+			}
+			system = json.RawMessage("[" + strings.Join(raws, ",") + "]")
+		}
+		items = items[head:]
+	default:
+		value := gjson.GetBytes(body, spec.system)
+		if !value.Exists() && spec.system == "systemInstruction" {
+			value = gjson.GetBytes(body, "system_instruction")
+		}
+		if value.Exists() && value.Type != gjson.Null {
+			system = json.RawMessage(value.Raw)
+		}
+	}
+	for _, item := range items {
+		messages = append(messages, json.RawMessage(item.Raw))
+	}
+	return system, messages
+}
+
+// join sets the system prompt and messages of a request body.
+func (spec formatSpec) join(body []byte, system json.RawMessage, messages []json.RawMessage) ([]byte, error) {
+	raws := make([]string, 0, len(messages)+1)
+	if spec.system == "" {
+		for _, item := range gjson.ParseBytes(system).Array() {
+			raws = append(raws, item.Raw)
+		}
+	} else if len(system) > 0 && !gjson.GetBytes(body, spec.system).Exists() && !gjson.GetBytes(body, "system_instruction").Exists() {
+		var err error
+		if body, err = sjson.SetRawBytes(body, spec.system, system); err != nil {
+			return nil, err
+		}
+	}
+	for _, message := range messages {
+		raws = append(raws, string(message))
+	}
+	return sjson.SetRawBytes(body, spec.messages, []byte("["+strings.Join(raws, ",")+"]"))
+}
+
+// trim drops messages until the history fits the configured limits, then drops the replies
+// and tool results that lost the message they answer.
+func trim(spec formatSpec, cfg config.SessionsConfig, messages []json.RawMessage) []json.RawMessage {
+	if cfg.MaxMessages <= 0 && cfg.MaxTokens <= 0 {
+		return messages
+	}
+	head := 0
+	if cfg.Strategy == config.SessionTrimMiddle && len(messages) > 0 {
+		head = 1
+	}
+	tokens := estimate(messages)
+	end := head
+	over := func() bool {
+		remaining := len(messages) - (end - head)
+		return (cfg.MaxMessages > 0 && remaining > cfg.MaxMessages) || (cfg.MaxTokens > 0 && tokens > cfg.MaxTokens)
+	}
+	for end < len(messages) && over() {
+		tokens -= int64(len(messages[end]) / bytesPerToken)
+		end++
+	}
+	for end > head && end < len(messages) && !spec.startsTurn(gjson.ParseBytes(messages[end])) {
+		end++
+	}
+	if end == head {
+		return messages
+	}
+	out := make([]json.RawMessage, 0, len(messages)-(end-head))
+	out = append(out, messages[:head]...)
+	return append(out, messages[end:]...)
+}
+
+// estimate returns the estimated number of tokens of messages.
+func estimate(messages []json.RawMessage) int64 {
+	size := 0
+	for _, message := range messages {
+		size += len(message)
+	}
+	return int64(size / bytesPerToken)
+}
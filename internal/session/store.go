@@ -0,0 +1,285 @@
+package session
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/redis"
+)
+
+// memoryStore keeps sessions in process, evicting the least recently used beyond max.
+type memoryStore struct {
+	mu       sync.Mutex
+	max      int
+	sessions map[string]*list.Element
+	order    *list.List // front is the most recently used session
+}
+
+type memoryEntry struct {
+	session   []byte
+	ref       string
+	expiresAt time.Time
+}
+
+func newMemoryStore(max int) *memoryStore {
+	return &memoryStore{max: max, sessions: make(map[string]*list.Element), order: list.New()}
+}
+
+func (s *memoryStore) resize(max int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.max = max
+	s.evict()
+}
+
+func (s *memoryStore) evict() {
+	for s.max > 0 && s.order.Len() > s.max {
+		s.remove(s.order.Back())
+	}
+}
+
+func (s *memoryStore) remove(element *list.Element) {
+	s.order.Remove(element)
+	delete(s.sessions, element.Value.(*memoryEntry).ref)
+}
+
+// Sessions are stored encoded, so callers never share the slices of a stored session.
+func (s *memoryStore) get(_ context.Context, ref string) (*Session, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.getLocked(ref)
+}
+
+func (s *memoryStore) getLocked(ref string) (*Session, bool, error) {
+	element, ok := s.sessions[ref]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := element.Value.(*memoryEntry)
+	if time.Now().After(entry.expiresAt) {
+		s.remove(element)
+		return nil, false, nil
+	}
+	s.order.MoveToFront(element)
+	return decode(entry.session)
+}
+
+func (s *memoryStore) putLocked(ref string, data []byte, ttl time.Duration) {
+	entry := &memoryEntry{session: data, ref: ref, expiresAt: time.Now().Add(ttl)}
+	if element, ok := s.sessions[ref]; ok {
+		element.Value = entry
+		s.order.MoveToFront(element)
+		return
+	}
+	s.sessions[ref] = s.order.PushFront(entry)
+	s.evict()
+}
+
+// update applies change to the session under the store lock, so concurrent updates of a
+// session are applied one after the other.
+func (s *memoryStore) update(_ context.Context, ref string, ttl time.Duration, change func(*Session, bool) *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, found, err := s.getLocked(ref)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(change(current, found))
+	if err != nil {
+		return err
+	}
+	s.putLocked(ref, data, ttl)
+	return nil
+}
+
+func (s *memoryStore) delete(_ context.Context, ref string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	element, ok := s.sessions[ref]
+	if !ok {
+		return false, nil
+	}
+	s.remove(element)
+	return true, nil
+}
+
+func (s *memoryStore) list(context.Context) ([]*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	out := make([]*Session, 0, len(s.sessions))
+	for element := s.order.Front(); element != nil; {
+		next := element.Next()
+		entry := element.Value.(*memoryEntry)
+		if now.After(entry.expiresAt) {
+			s.remove(element)
+		} else if session, ok, err := decode(entry.session); err == nil && ok {
+			out = append(out, session)
+		}
+		element = next
+	}
+	return out, nil
+}
+
+func (s *memoryStore) clear(context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions = make(map[string]*list.Element)
+	s.order.Init()
+	return nil
+}
+
+// redisStore keeps sessions in Redis, shared by every proxy instance using the server.
+// Each session is one key that expires after the session TTL.
+type redisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+func newRedisStore(client *redis.Client) *redisStore {
+	return &redisStore{client: client, prefix: client.KeyPrefix() + "sessions:"}
+}
+
+func (s *redisStore) get(ctx context.Context, ref string) (*Session, bool, error) {
+	data, ok, err := s.client.Get(ctx, s.prefix+ref)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return decode(data)
+}
+
+// compareAndSetScript stores ARGV[2] at KEYS[1], expiring after ARGV[3] milliseconds, when
+// the key still holds ARGV[1]; an empty ARGV[1] stands for a key that does not exist.
+const compareAndSetScript = `local current = redis.call('GET', KEYS[1])
+if (current or '') ~= ARGV[1] then
+  return 0
+end
+redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+return 1`
+
+// redisUpdateAttempts bounds the retries of an update that raced with another instance.
+const redisUpdateAttempts = 5
+
+// update applies change to the session and stores the result only when no other request
+// or instance changed the session in the meantime, retrying otherwise.
+func (s *redisStore) update(ctx context.Context, ref string, ttl time.Duration, change func(*Session, bool) *Session) error {
+	key := s.prefix + ref
+	ttlMillis := strconv.FormatInt(max(ttl.Milliseconds(), 1), 10)
+	for attempt := 0; attempt < redisUpdateAttempts; attempt++ {
+		previous, found, err := s.client.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+		var current *Session
+		if found {
+			if current, found, err = decode(previous); err != nil {
+				return err
+			}
+		}
+		data, err := json.Marshal(change(current, found))
+		if err != nil {
+			return err
+		}
+		reply, err := s.client.Do(ctx, "EVAL", compareAndSetScript, "1", key, string(previous), string(data), ttlMillis)
+		if err != nil {
+			return err
+		}
+		if stored, _ := reply.(int64); stored == 1 {
+			return nil
+		}
+	}
+	return fmt.Errorf("sessions: session %s changed concurrently, giving up after %d attempts", ref, redisUpdateAttempts)
+}
+
+func (s *redisStore) delete(ctx context.Context, ref string) (bool, error) {
+	reply, err := s.client.Do(ctx, "DEL", s.prefix+ref)
+	if err != nil {
+		return false, err
+	}
+	count, _ := reply.(int64)
+	return count > 0, nil
+}
+
+// keys returns the keys of every stored session.
+func (s *redisStore) keys(ctx context.Context) ([]string, error) {
+	var keys []string
+	cursor := "0"
+	for {
+		reply, err := s.client.Do(ctx, "SCAN", cursor, "MATCH", s.prefix+"*", "COUNT", "500")
+		if err != nil {
+			return nil, err
+		}
+		parts, ok := reply.([]any)
+		if !ok || len(parts) != 2 {
+			return nil, errors.New("sessions: unexpected SCAN reply")
+		}
+		cursor, _ = parts[0].(string)
+		batch, _ := parts[1].([]any)
+		for _, key := range batch {
+			if name, isString := key.(string); isString {
+				keys = append(keys, name)
+			}
+		}
+		if cursor == "0" || cursor == "" {
+			return keys, nil
+		}
+	}
+}
+
+func (s *redisStore) list(ctx context.Context) ([]*Session, error) {
+	keys, err := s.keys(ctx)
+	if err != nil || len(keys) == 0 {
+		return nil, err
+	}
+	commands := make([][]string, 0, len(keys))
+	for _, key := range keys {
+		commands = append(commands, []string{"GET", key})
+	}
+	replies, err := s.client.Pipeline(ctx, commands...)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*Session, 0, len(replies))
+	for _, reply := range replies {
+		data, ok := reply.(string)
+		if !ok {
+			continue
+		}
+		if session, found, errDecode := decode([]byte(data)); errDecode == nil && found {
+			out = append(out, session)
+		}
+	}
+	return out, nil
+}
+
+func (s *redisStore) clear(ctx context.Context) error {
+	keys, err := s.keys(ctx)
+	if err != nil {
+		return err
+	}
+	for start := 0; start < len(keys); start += 500 {
+		batch := keys[start:min(start+500, len(keys))]
+		if _, err = s.client.Do(ctx, append([]string{"DEL"}, batch...)...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *redisStore) close() error {
+	return s.client.Close()
+}
+
+func decode(data []byte) (*Session, bool, error) {
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, false, fmt.Errorf("sessions: decode session: %w", err)
+	}
+	return &session, true, nil
+}
@@ -0,0 +1,188 @@
+// Package shadow stores shadow traffic for offline model comparison. A sample of the
+// requests for configured models is sent again to a candidate model in the background,
+// and the request, the response returned to the client and the candidate's response are
+// appended to a JSON Lines file per day.
+package shadow
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+const (
+	defaultMaxConcurrent = 4
+	defaultMaxBodyBytes  = 1 << 20
+)
+
+// Entry is one stored comparison.
+type Entry struct {
+	ID          string    `json:"id"`
+	Timestamp   time.Time `json:"timestamp"`
+	Handler     string    `json:"handler"`
+	Model       string    `json:"model"`
+	ShadowModel string    `json:"shadow_model"`
+	Stream      bool      `json:"stream,omitempty"`
+	Request     string    `json:"request"`
+	// Response is the response returned to the client; streamed responses are the
+	// concatenated chunks.
+	Response   string `json:"response"`
+	DurationMs int64  `json:"duration_ms"`
+	// ShadowResponse is the non-streaming response of the candidate model.
+	ShadowResponse   string `json:"shadow_response,omitempty"`
+	ShadowDurationMs int64  `json:"shadow_duration_ms"`
+	ShadowStatus     int    `json:"shadow_status"`
+	ShadowError      string `json:"shadow_error,omitempty"`
+	Truncated        bool   `json:"truncated,omitempty"`
+}
+
+// Recorder samples requests for shadow traffic and stores the comparisons. It is safe for
+// concurrent use.
+type Recorder struct {
+	mu       sync.RWMutex
+	enabled  bool
+	dir      string
+	rules    map[string]config.ShadowRule
+	maxBytes int
+	slots    chan struct{}
+
+	writeMu sync.Mutex
+	seq     atomic.Uint64
+}
+
+// NewRecorder creates a recorder for the given configuration.
+func NewRecorder(cfg config.ShadowConfig) *Recorder {
+	r := &Recorder{}
+	r.Configure(cfg)
+	return r
+}
+
+// Configure applies cfg. Shadow requests in flight finish against the previous limits.
+func (r *Recorder) Configure(cfg config.ShadowConfig) {
+	dir := strings.TrimSpace(cfg.Dir)
+	if dir == "" {
+		dir = filepath.Join("logs", "shadow")
+		if base := util.WritablePath(); base != "" {
+			dir = filepath.Join(base, dir)
+		}
+	}
+	rules := make(map[string]config.ShadowRule, len(cfg.Rules))
+	for _, rule := range cfg.Rules {
+		rule.Model = strings.TrimSpace(rule.Model)
+		rule.ShadowModel = strings.TrimSpace(rule.ShadowModel)
+		if rule.Model == "" || rule.ShadowModel == "" || rule.Percent <= 0 {
+			continue
+		}
+		if _, exists := rules[rule.Model]; !exists {
+			rules[rule.Model] = rule
+		}
+	}
+	maxConcurrent := cfg.MaxConcurrent
+	if maxConcurrent <= 0 {
+		maxConcurrent = defaultMaxConcurrent
+	}
+	maxBytes := cfg.MaxBodyBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxBodyBytes
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.enabled = cfg.Enable && len(rules) > 0
+	r.dir = dir
+	r.rules = rules
+	r.maxBytes = maxBytes
+	if r.slots == nil || cap(r.slots) != maxConcurrent {
+		r.slots = make(chan struct{}, maxConcurrent)
+	}
+}
+
+// Sample decides whether a request for model is duplicated and returns its candidate model.
+func (r *Recorder) Sample(model string) (string, bool) {
+	if r == nil {
+		return "", false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if !r.enabled {
+		return "", false
+	}
+	rule, ok := r.rules[model]
+	if !ok || rand.Float64()*100 >= rule.Percent {
+		return "", false
+	}
+	return rule.ShadowModel, true
+}
+
+// MaxBodyBytes returns the size bodies are truncated to.
+func (r *Recorder) MaxBodyBytes() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.maxBytes
+}
+
+// Acquire reserves a slot for a shadow request. It returns false when MaxConcurrent shadow
+// requests are already in flight; the release function must be called once the request is
+// stored.
+func (r *Recorder) Acquire() (func(), bool) {
+	r.mu.RLock()
+	slots := r.slots
+	r.mu.RUnlock()
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, true
+	default:
+		return nil, false
+	}
+}
+
+// Record truncates the bodies of entry, stamps it with an ID and appends it to the file of
+// its day.
+func (r *Recorder) Record(entry Entry) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	dir, limit := r.dir, r.maxBytes
+	r.mu.RUnlock()
+
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	for _, body := range []*string{&entry.Request, &entry.Response, &entry.ShadowResponse} {
+		if len(*body) > limit {
+			*body = (*body)[:limit]
+			entry.Truncated = true
+		}
+	}
+	entry.ID = fmt.Sprintf("%s-%06d", entry.Timestamp.UTC().Format("20060102T150405.000"), r.seq.Add(1)%1000000)
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("shadow: encode entry: %w", err)
+	}
+
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("shadow: create directory: %w", err)
+	}
+	path := filepath.Join(dir, "shadow-"+entry.Timestamp.Format("2006-01-02")+".jsonl")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("shadow: open file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	if _, err = f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("shadow: write entry: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,284 @@
+// Package statearchive bundles the state a proxy needs to move to a new host — its
+// configuration with the client API keys, the auth files of every upstream account and
+// the usage history — into one archive encrypted with a passphrase, so a migration does
+// not require signing in to every provider again.
+//
+// An archive is a gzipped tar file sealed with AES-256-GCM under a key derived from the
+// passphrase with scrypt and a random salt. Auth files are stored decrypted inside it and
+// re-encrypted with the destination's auth-encryption key when restored.
+package statearchive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	"golang.org/x/crypto/scrypt"
+)
+
+// magic starts every archive and names its format version.
+const magic = "CLIPROXY-STATE/v1\n"
+
+const (
+	saltSize      = 16
+	manifestEntry = "manifest.json"
+	configEntry   = "config.yaml"
+	usageEntry    = "usage.json"
+	authPrefix    = "auths/"
+)
+
+// ErrPassphrase is returned when an archive cannot be decrypted with the given passphrase.
+var ErrPassphrase = errors.New("statearchive: wrong passphrase or corrupted archive")
+
+// State is the content of an archive.
+type State struct {
+	// CreatedAt is when the state was collected.
+	CreatedAt time.Time
+	// Config is the raw configuration file, comments included.
+	Config []byte
+	// AuthFiles maps auth file names to their decrypted content.
+	AuthFiles map[string][]byte
+	// Usage is the usage history, oldest first.
+	Usage []usage.StoredDetail
+}
+
+// manifest summarises an archive so it can be inspected before it is restored.
+type manifest struct {
+	CreatedAt    time.Time `json:"created_at"`
+	AuthFiles    int       `json:"auth_files"`
+	UsageRecords int       `json:"usage_records"`
+}
+
+// Collect reads the state of the proxy that uses the given configuration file, auth
+// directory and usage statistics.
+//
+// Parameters:
+//   - configPath: The configuration file
+//   - authDir: The directory holding the auth files
+//   - stats: The usage statistics; nil exports no usage history
+//
+// Returns:
+//   - *State: The collected state
+//   - error: An error if a file cannot be read or decrypted
+func Collect(configPath, authDir string, stats *usage.RequestStatistics) (*State, error) {
+	state := &State{CreatedAt: time.Now().UTC(), AuthFiles: make(map[string][]byte)}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("statearchive: read config: %w", err)
+	}
+	state.Config = data
+	if authDir != "" {
+		entries, errRead := os.ReadDir(authDir)
+		if errRead != nil && !errors.Is(errRead, os.ErrNotExist) {
+			return nil, fmt.Errorf("statearchive: read auth directory: %w", errRead)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasSuffix(strings.ToLower(entry.Name()), ".json") {
+				continue
+			}
+			content, errFile := authcrypt.ReadFile(filepath.Join(authDir, entry.Name()))
+			if errFile != nil {
+				return nil, fmt.Errorf("statearchive: read auth file %s: %w", entry.Name(), errFile)
+			}
+			state.AuthFiles[entry.Name()] = content
+		}
+	}
+	if stats != nil {
+		state.Usage = stats.Details()
+	}
+	return state, nil
+}
+
+// Seal encodes state as an archive encrypted with passphrase.
+func Seal(state *State, passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, errors.New("statearchive: passphrase is empty")
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	add := func(name string, content []byte) error {
+		header := &tar.Header{Name: name, Mode: 0o600, Size: int64(len(content)), ModTime: state.CreatedAt}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := tw.Write(content)
+		return err
+	}
+	usageJSON, err := json.Marshal(state.Usage)
+	if err != nil {
+		return nil, fmt.Errorf("statearchive: encode usage: %w", err)
+	}
+	manifestJSON, err := json.Marshal(manifest{CreatedAt: state.CreatedAt, AuthFiles: len(state.AuthFiles), UsageRecords: len(state.Usage)})
+	if err != nil {
+		return nil, fmt.Errorf("statearchive: encode manifest: %w", err)
+	}
+	if err = add(manifestEntry, manifestJSON); err != nil {
+		return nil, fmt.Errorf("statearchive: write archive: %w", err)
+	}
+	if err = add(configEntry, state.Config); err != nil {
+		return nil, fmt.Errorf("statearchive: write archive: %w", err)
+	}
+	for _, name := range slices.Sorted(maps.Keys(state.AuthFiles)) {
+		if err = add(authPrefix+name, state.AuthFiles[name]); err != nil {
+			return nil, fmt.Errorf("statearchive: write archive: %w", err)
+		}
+	}
+	if err = add(usageEntry, usageJSON); err != nil {
+		return nil, fmt.Errorf("statearchive: write archive: %w", err)
+	}
+	if err = tw.Close(); err != nil {
+		return nil, fmt.Errorf("statearchive: write archive: %w", err)
+	}
+	if err = gz.Close(); err != nil {
+		return nil, fmt.Errorf("statearchive: write archive: %w", err)
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err = rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("statearchive: generate salt: %w", err)
+	}
+	aead, err := newAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("statearchive: generate nonce: %w", err)
+	}
+	out := make([]byte, 0, len(magic)+len(salt)+len(nonce)+buf.Len()+aead.Overhead())
+	out = append(out, magic...)
+	out = append(out, salt...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, buf.Bytes(), []byte(magic)), nil
+}
+
+// Open decrypts and decodes an archive produced by Seal.
+func Open(data []byte, passphrase string) (*State, error) {
+	if !bytes.HasPrefix(data, []byte(magic)) {
+		return nil, errors.New("statearchive: not a state archive")
+	}
+	data = data[len(magic):]
+	if len(data) < saltSize {
+		return nil, ErrPassphrase
+	}
+	salt := data[:saltSize]
+	aead, err := newAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	data = data[saltSize:]
+	if len(data) < aead.NonceSize() {
+		return nil, ErrPassphrase
+	}
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(magic))
+	if err != nil {
+		return nil, ErrPassphrase
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(plain))
+	if err != nil {
+		return nil, fmt.Errorf("statearchive: read archive: %w", err)
+	}
+	state := &State{AuthFiles: make(map[string][]byte)}
+	tr := tar.NewReader(gz)
+	for {
+		header, errNext := tr.Next()
+		if errors.Is(errNext, io.EOF) {
+			break
+		}
+		if errNext != nil {
+			return nil, fmt.Errorf("statearchive: read archive: %w", errNext)
+		}
+		content, errRead := io.ReadAll(tr)
+		if errRead != nil {
+			return nil, fmt.Errorf("statearchive: read archive: %w", errRead)
+		}
+		switch name := header.Name; {
+		case name == manifestEntry:
+			var m manifest
+			if err = json.Unmarshal(content, &m); err != nil {
+				return nil, fmt.Errorf("statearchive: invalid manifest: %w", err)
+			}
+			state.CreatedAt = m.CreatedAt
+		case name == configEntry:
+			state.Config = content
+		case name == usageEntry:
+			if err = json.Unmarshal(content, &state.Usage); err != nil {
+				return nil, fmt.Errorf("statearchive: invalid usage history: %w", err)
+			}
+		case strings.HasPrefix(name, authPrefix):
+			file := strings.TrimPrefix(name, authPrefix)
+			if file == "" || path.Base(file) != file || !strings.HasSuffix(strings.ToLower(file), ".json") {
+				return nil, fmt.Errorf("statearchive: invalid auth file name %q", name)
+			}
+			state.AuthFiles[file] = content
+		}
+	}
+	if len(state.Config) == 0 {
+		return nil, errors.New("statearchive: archive holds no configuration")
+	}
+	return state, nil
+}
+
+// WriteAuthFiles writes the auth files of state into authDir, encrypted with the configured
+// auth-encryption key, replacing files of the same name.
+//
+// Returns:
+//   - []string: The paths of the written files
+//   - error: An error if a file cannot be written
+func WriteAuthFiles(state *State, authDir string) ([]string, error) {
+	if len(state.AuthFiles) == 0 {
+		return nil, nil
+	}
+	if err := os.MkdirAll(authDir, 0o700); err != nil {
+		return nil, fmt.Errorf("statearchive: create auth directory: %w", err)
+	}
+	paths := make([]string, 0, len(state.AuthFiles))
+	for name, content := range state.AuthFiles {
+		dst := filepath.Join(authDir, name)
+		tmp := dst + ".tmp"
+		if err := authcrypt.WriteFile(tmp, content, 0o600); err != nil {
+			return paths, fmt.Errorf("statearchive: write auth file %s: %w", name, err)
+		}
+		if err := os.Rename(tmp, dst); err != nil {
+			_ = os.Remove(tmp)
+			return paths, fmt.Errorf("statearchive: replace auth file %s: %w", name, err)
+		}
+		paths = append(paths, dst)
+	}
+	return paths, nil
+}
+
+// newAEAD derives the archive key from passphrase and salt.
+func newAEAD(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, fmt.Errorf("statearchive: derive key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("statearchive: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("statearchive: %w", err)
+	}
+	return aead, nil
+}
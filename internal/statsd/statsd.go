@@ -0,0 +1,303 @@
+// Package statsd pushes request metrics to a StatsD or DogStatsD agent. Counters are summed
+// in memory and sent together with the buffered timings once per flush interval, packed
+// into UDP datagrams, so the request path never waits on the network.
+package statsd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultAddress       = "127.0.0.1:8125"
+	defaultPrefix        = "cliproxy."
+	defaultFlushInterval = 10 * time.Second
+
+	// maxPacketSize keeps datagrams below the common 1500 byte MTU.
+	maxPacketSize = 1432
+	// maxTimings caps the timing samples buffered between flushes; further samples are dropped.
+	maxTimings = 10000
+)
+
+// Emitter is a usage plugin that reports request counters, token counts and latency
+// timings to a StatsD agent. A disabled emitter drops every record.
+type Emitter struct {
+	mu         sync.Mutex
+	conn       net.Conn
+	prefix     string
+	tags       bool
+	globalTags string
+	counters   map[metricKey]int64
+	timings    []timing
+	dropped    int64
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+type metricKey struct {
+	name string
+	tags string
+}
+
+type timing struct {
+	metricKey
+	ms int64
+}
+
+// New returns a disabled emitter; call Configure to start it.
+func New() *Emitter {
+	return &Emitter{}
+}
+
+// Configure applies cfg, flushing the metrics buffered for the previous agent first.
+func (e *Emitter) Configure(cfg config.StatsDConfig) error {
+	if err := e.Close(); err != nil {
+		log.Debugf("statsd: close previous connection: %v", err)
+	}
+	if !cfg.Enable {
+		return nil
+	}
+
+	protocol := strings.ToLower(strings.TrimSpace(cfg.Protocol))
+	if protocol != "" && protocol != "dogstatsd" && protocol != "statsd" {
+		return fmt.Errorf("statsd: unknown protocol %q, expected dogstatsd or statsd", cfg.Protocol)
+	}
+	address := strings.TrimSpace(cfg.Address)
+	if address == "" {
+		address = defaultAddress
+	}
+	prefix := cfg.Prefix
+	if prefix == "" {
+		prefix = defaultPrefix
+	}
+	interval := cfg.FlushInterval
+	if interval <= 0 {
+		interval = defaultFlushInterval
+	}
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return fmt.Errorf("statsd: dial %s: %w", address, err)
+	}
+
+	globalTags := make([]string, 0, len(cfg.Tags))
+	for key, value := range cfg.Tags {
+		globalTags = append(globalTags, sanitize(key)+":"+sanitize(value))
+	}
+	sort.Strings(globalTags)
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	e.mu.Lock()
+	e.conn = conn
+	e.prefix = prefix
+	e.tags = protocol != "statsd"
+	e.globalTags = strings.Join(globalTags, ",")
+	e.counters = make(map[metricKey]int64)
+	e.timings = nil
+	e.dropped = 0
+	e.stop, e.done = stop, done
+	e.mu.Unlock()
+
+	go e.run(interval, stop, done)
+	return nil
+}
+
+// Close sends the buffered metrics and releases the connection.
+func (e *Emitter) Close() error {
+	if e == nil {
+		return nil
+	}
+	e.mu.Lock()
+	stop, done := e.stop, e.done
+	e.stop, e.done = nil, nil
+	e.mu.Unlock()
+	if stop == nil {
+		return nil
+	}
+	close(stop)
+	<-done
+
+	e.mu.Lock()
+	conn := e.conn
+	e.conn = nil
+	e.mu.Unlock()
+	return conn.Close()
+}
+
+func (e *Emitter) run(interval time.Duration, stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.flush()
+		case <-stop:
+			e.flush()
+			return
+		}
+	}
+}
+
+// HandleUsage implements coreusage.Plugin.
+func (e *Emitter) HandleUsage(_ context.Context, record coreusage.Record) {
+	if e == nil {
+		return
+	}
+	now := time.Now()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.conn == nil {
+		return
+	}
+
+	status := "success"
+	if record.Failed {
+		status = "failed"
+	}
+	base := e.tagList("provider", record.Provider, "model", record.Model)
+	withStatus := e.tagList("provider", record.Provider, "model", record.Model, "status", status)
+	requestTags := withStatus
+	if record.Failed && record.ErrorCode != "" {
+		requestTags = e.tagList("provider", record.Provider, "model", record.Model, "status", status, "error_code", record.ErrorCode)
+	}
+
+	e.counters[metricKey{name: "requests", tags: requestTags}]++
+	if record.Retry {
+		e.counters[metricKey{name: "retries", tags: base}]++
+	}
+	for _, token := range []struct {
+		name  string
+		value int64
+	}{
+		{"tokens.input", record.Detail.InputTokens},
+		{"tokens.output", record.Detail.OutputTokens},
+		{"tokens.reasoning", record.Detail.ReasoningTokens},
+		{"tokens.cached", record.Detail.CachedTokens},
+		{"tokens.cache_creation", record.Detail.CacheCreationTokens},
+		{"tokens.embedding", record.Detail.EmbeddingTokens},
+		{"tokens.total", record.Detail.TotalTokens},
+		{"images", record.Detail.Images},
+	} {
+		if token.value > 0 {
+			e.counters[metricKey{name: token.name, tags: base}] += token.value
+		}
+	}
+
+	if record.RequestedAt.IsZero() {
+		return
+	}
+	e.addTiming(metricKey{name: "latency", tags: withStatus}, now.Sub(record.RequestedAt))
+	if !record.FirstChunkAt.IsZero() {
+		e.addTiming(metricKey{name: "ttft", tags: base}, record.FirstChunkAt.Sub(record.RequestedAt))
+	}
+}
+
+func (e *Emitter) addTiming(key metricKey, d time.Duration) {
+	if len(e.timings) >= maxTimings {
+		e.dropped++
+		return
+	}
+	e.timings = append(e.timings, timing{metricKey: key, ms: d.Milliseconds()})
+}
+
+// tagList renders name/value pairs as DogStatsD tags, skipping empty values. It returns
+// nothing for plain StatsD, which has no tags.
+func (e *Emitter) tagList(pairs ...string) string {
+	if !e.tags {
+		return ""
+	}
+	var b strings.Builder
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] == "" {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(pairs[i])
+		b.WriteByte(':')
+		b.WriteString(sanitize(pairs[i+1]))
+	}
+	return b.String()
+}
+
+func (e *Emitter) flush() {
+	e.mu.Lock()
+	conn := e.conn
+	counters, timings, dropped := e.counters, e.timings, e.dropped
+	e.counters, e.timings, e.dropped = make(map[metricKey]int64), nil, 0
+	prefix, tags, globalTags := e.prefix, e.tags, e.globalTags
+	e.mu.Unlock()
+	if conn == nil || (len(counters) == 0 && len(timings) == 0) {
+		return
+	}
+	if dropped > 0 {
+		log.Debugf("statsd: dropped %d timing samples over the per-flush limit", dropped)
+	}
+
+	line := func(key metricKey, value int64, kind string) string {
+		text := prefix + key.name + ":" + strconv.FormatInt(value, 10) + "|" + kind
+		if !tags {
+			return text
+		}
+		all := key.tags
+		if globalTags != "" {
+			if all != "" {
+				all += ","
+			}
+			all += globalTags
+		}
+		if all != "" {
+			text += "|#" + all
+		}
+		return text
+	}
+
+	var packet []byte
+	send := func() {
+		if len(packet) == 0 {
+			return
+		}
+		if _, err := conn.Write(packet); err != nil {
+			log.Debugf("statsd: send metrics: %v", err)
+		}
+		packet = packet[:0]
+	}
+	appendLine := func(text string) {
+		if len(packet) > 0 && len(packet)+1+len(text) > maxPacketSize {
+			send()
+		}
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+		packet = append(packet, text...)
+	}
+	for key, value := range counters {
+		appendLine(line(key, value, "c"))
+	}
+	for _, sample := range timings {
+		appendLine(line(sample.metricKey, sample.ms, "ms"))
+	}
+	send()
+}
+
+// sanitize replaces the characters that delimit StatsD lines and tags.
+func sanitize(value string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '|', ',', '#', ':', '\n', ' ':
+			return '_'
+		}
+		return r
+	}, value)
+}
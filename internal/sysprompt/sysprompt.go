@@ -0,0 +1,273 @@
+// Package sysprompt adds configured text before and after the system prompt of generation
+// requests, so organization-wide policies reach the upstream without changing every client.
+package sysprompt
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/guardrail"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// separator joins the added text and the system prompt of the request.
+const separator = "\n\n"
+
+// Request describes the request a rule is matched against and its placeholders rendered for.
+type Request struct {
+	APIKey  string
+	Project string
+	Model   string
+	Time    time.Time
+}
+
+// Injector applies the configured system prompt rules. It is safe for concurrent use.
+type Injector struct {
+	mu       sync.RWMutex
+	rules    []config.SystemPromptRule
+	keyNames map[string]string
+}
+
+// New creates an injector for the configured rules.
+func New(cfg config.SystemPromptsConfig) *Injector {
+	injector := &Injector{}
+	injector.Configure(cfg)
+	return injector
+}
+
+// Configure replaces the configured rules and key names.
+func (i *Injector) Configure(cfg config.SystemPromptsConfig) {
+	rules := append([]config.SystemPromptRule(nil), cfg.Rules...)
+	keyNames := make(map[string]string, len(cfg.KeyNames))
+	for key, name := range cfg.KeyNames {
+		keyNames[key] = name
+	}
+	i.mu.Lock()
+	i.rules = rules
+	i.keyNames = keyNames
+	i.mu.Unlock()
+}
+
+// Enabled reports whether any rule is configured.
+func (i *Injector) Enabled() bool {
+	if i == nil {
+		return false
+	}
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return len(i.rules) > 0
+}
+
+// Apply adds the prefixes and suffixes of the rules matching req to the system prompt of a
+// request body in format with AddToSystem. It returns the body to forward and the names of
+// the rules applied.
+func (i *Injector) Apply(format guardrail.Format, body []byte, req Request) ([]byte, []string, error) {
+	i.mu.RLock()
+	var prefixes, suffixes, applied []string
+	keyName := i.keyNames[req.APIKey]
+	for _, rule := range i.rules {
+		if !matches(rule, req) {
+			continue
+		}
+		applied = append(applied, rule.Name)
+		if text := strings.TrimSpace(rule.Prefix); text != "" {
+			prefixes = append(prefixes, text)
+		}
+		if text := strings.TrimSpace(rule.Suffix); text != "" {
+			suffixes = append(suffixes, text)
+		}
+	}
+	i.mu.RUnlock()
+	if len(applied) == 0 {
+		return body, nil, nil
+	}
+
+	if keyName == "" {
+		keyName = util.HideAPIKey(req.APIKey)
+	}
+	now := req.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	replacer := strings.NewReplacer(
+		"{date}", now.Format("2006-01-02"),
+		"{time}", now.Format("15:04"),
+		"{weekday}", now.Weekday().String(),
+		"{model}", req.Model,
+		"{key}", util.HideAPIKey(req.APIKey),
+		"{key-name}", keyName,
+		"{project}", req.Project,
+	)
+	prefix := replacer.Replace(strings.Join(prefixes, separator))
+	suffix := replacer.Replace(strings.Join(suffixes, separator))
+	out, err := AddToSystem(format, body, prefix, suffix)
+	if err != nil {
+		return body, nil, fmt.Errorf("system prompts: %w", err)
+	}
+	return out, applied, nil
+}
+
+// AddToSystem adds prefix and suffix to the system prompt of a request body in format,
+// creating the system prompt when the request has none. Formats without a system prompt,
+// such as legacy completions, are returned unchanged.
+func AddToSystem(format guardrail.Format, body []byte, prefix, suffix string) ([]byte, error) {
+	switch format {
+	case guardrail.FormatOpenAIChat:
+		return applyOpenAIChat(body, prefix, suffix)
+	case guardrail.FormatOpenAIResponses:
+		return applyField(body, "instructions", prefix, suffix, nil)
+	case guardrail.FormatClaude:
+		return applyField(body, "system", prefix, suffix, textPart)
+	case guardrail.FormatGemini:
+		path := "systemInstruction"
+		if !gjson.GetBytes(body, path).Exists() && gjson.GetBytes(body, "system_instruction").Exists() {
+			path = "system_instruction"
+		}
+		return applyGemini(body, path, prefix, suffix)
+	}
+	return body, nil
+}
+
+// matches reports whether a rule applies to req.
+func matches(rule config.SystemPromptRule, req Request) bool {
+	if len(rule.APIKeys) > 0 && !contains(rule.APIKeys, req.APIKey) {
+		return false
+	}
+	if len(rule.Projects) > 0 && (req.Project == "" || !contains(rule.Projects, req.Project)) {
+		return false
+	}
+	if len(rule.Models) == 0 {
+		return true
+	}
+	model := strings.ToLower(strings.TrimSpace(req.Model))
+	for _, pattern := range rule.Models {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if prefix, wildcard := strings.CutSuffix(pattern, "*"); wildcard {
+			if strings.HasPrefix(model, prefix) {
+				return true
+			}
+		} else if pattern != "" && model == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if strings.TrimSpace(candidate) == value {
+			return true
+		}
+	}
+	return false
+}
+
+// applyOpenAIChat adds the prefix to the first and the suffix to the last of the system
+// messages starting the conversation, or starts it with a system message holding both.
+func applyOpenAIChat(body []byte, prefix, suffix string) ([]byte, error) {
+	messages := gjson.GetBytes(body, "messages").Array()
+	leading := 0
+	for leading < len(messages) {
+		role := messages[leading].Get("role").String()
+		if role != "system" && role != "developer" {
+			break
+		}
+		leading++
+	}
+	raws := make([]string, 0, len(messages)+1)
+	for _, message := range messages {
+		raws = append(raws, message.Raw)
+	}
+	if leading == 0 {
+		message, err := json.Marshal(map[string]string{"role": "system", "content": join(prefix, "", suffix)})
+		if err != nil {
+			return nil, err
+		}
+		raws = append([]string{string(message)}, raws...)
+	} else {
+		first, err := withText(messages[0].Raw, "content", prefix, "", textPart)
+		if err != nil {
+			return nil, err
+		}
+		raws[0] = first
+		last, err := withText(raws[leading-1], "content", "", suffix, textPart)
+		if err != nil {
+			return nil, err
+		}
+		raws[leading-1] = last
+	}
+	return sjson.SetRawBytes(body, "messages", []byte("["+strings.Join(raws, ",")+"]"))
+}
+
+// applyField adds the prefix and suffix to a system prompt field holding a string or, when
+// part is set, an array of content parts.
+func applyField(body []byte, path, prefix, suffix string, part func(string) any) ([]byte, error) {
+	out, err := withText(string(body), path, prefix, suffix, part)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(out), nil
+}
+
+// applyGemini adds the prefix and suffix as text parts of the system instruction.
+func applyGemini(body []byte, path, prefix, suffix string) ([]byte, error) {
+	if !gjson.GetBytes(body, path+".parts").IsArray() {
+		instruction := map[string]any{"parts": []map[string]string{{"text": join(prefix, "", suffix)}}}
+		return sjson.SetBytes(body, path, instruction)
+	}
+	return applyField(body, path+".parts", prefix, suffix, func(text string) any { return map[string]string{"text": text} })
+}
+
+// withText adds prefix and suffix to the field at path of a JSON document: joined to a
+// string, or as parts at the start and end of an array. Missing or empty fields are set
+// to the joined text.
+func withText(document, path, prefix, suffix string, part func(string) any) (string, error) {
+	value := gjson.Get(document, path)
+	switch {
+	case value.IsArray() && part != nil:
+		var elements []string
+		if prefix != "" {
+			raw, err := json.Marshal(part(prefix))
+			if err != nil {
+				return "", err
+			}
+			elements = append(elements, string(raw))
+		}
+		for _, element := range value.Array() {
+			elements = append(elements, element.Raw)
+		}
+		if suffix != "" {
+			raw, err := json.Marshal(part(suffix))
+			if err != nil {
+				return "", err
+			}
+			elements = append(elements, string(raw))
+		}
+		return sjson.SetRaw(document, path, "["+strings.Join(elements, ",")+"]")
+	case value.Type == gjson.String, !value.Exists(), value.Type == gjson.Null:
+		return sjson.Set(document, path, join(prefix, value.String(), suffix))
+	default:
+		return "", fmt.Errorf("unsupported %s value", path)
+	}
+}
+
+func textPart(text string) any {
+	return map[string]string{"type": "text", "text": text}
+}
+
+// join concatenates the non-empty texts with the separator.
+func join(texts ...string) string {
+	parts := make([]string, 0, len(texts))
+	for _, text := range texts {
+		if strings.TrimSpace(text) != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, separator)
+}
@@ -0,0 +1,233 @@
+// Package tokencount counts the prompt tokens of OpenAI, Claude and Gemini requests locally,
+// with the tokenizer of the model family serving the request.
+package tokencount
+
+import (
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tidwall/gjson"
+	"github.com/tiktoken-go/tokenizer"
+)
+
+const (
+	// claudeCharsPerToken approximates the Anthropic tokenizer, which is not published.
+	claudeCharsPerToken = 3.5
+	// geminiCharsPerToken approximates the Gemini SentencePiece vocabulary, which is not
+	// bundled with the proxy; Google documents about four characters per token.
+	geminiCharsPerToken = 4.0
+	// messageOverheadTokens is the framing OpenAI chat models add around each message, and
+	// once more to prime the reply.
+	messageOverheadTokens = 3
+)
+
+// Count is the prompt size of a request.
+type Count struct {
+	// Model is the model the request was counted for.
+	Model string `json:"model"`
+	// InputTokens is the number of prompt tokens.
+	InputTokens int `json:"input_tokens"`
+	// Tokenizer names the tokenizer or approximation used.
+	Tokenizer string `json:"tokenizer"`
+	// Estimated reports that the count approximates the provider's tokenizer.
+	Estimated bool `json:"estimated"`
+}
+
+// skippedKeys are request and response fields that carry no prompt or generated text.
+var skippedKeys = map[string]struct{}{
+	"model": {}, "stream": {}, "type": {}, "id": {}, "tool_call_id": {}, "tool_use_id": {},
+	"call_id": {}, "cache_control": {}, "media_type": {}, "mime_type": {}, "mimeType": {},
+	"data": {}, "file_id": {}, "fileUri": {}, "file_uri": {}, "metadata": {}, "user": {},
+	"safetySettings": {}, "safety_settings": {}, "generationConfig": {}, "generation_config": {},
+	"stream_options": {}, "reasoning": {}, "thinking": {}, "store": {}, "signature": {},
+	"thoughtSignature": {}, "previous_response_id": {}, "service_tier": {}, "object": {},
+	"finish_reason": {}, "stop_reason": {}, "finishReason": {}, "system_fingerprint": {},
+	"modelVersion": {}, "responseId": {}, "usage": {}, "usageMetadata": {},
+}
+
+// Tokens counts the prompt tokens of an OpenAI chat, Responses, Claude messages or Gemini
+// request for model. providers are the providers serving the model and pick the tokenizer
+// when the model name does not name its family. Images and files are not counted.
+func Tokens(model string, providers []string, payload []byte) (Count, error) {
+	root := gjson.ParseBytes(payload)
+	if model == "" {
+		model = root.Get("model").String()
+	}
+	var segments []string
+	collectText(root, &segments)
+	text := strings.Join(segments, "\n")
+
+	count := Count{Model: model}
+	switch family(model, providers) {
+	case "claude":
+		count.Tokenizer = "anthropic-approximation"
+		count.Estimated = true
+		count.InputTokens = charTokens(text, claudeCharsPerToken)
+	case "gemini":
+		count.Tokenizer = "gemini-approximation"
+		count.Estimated = true
+		count.InputTokens = charTokens(text, geminiCharsPerToken)
+	default:
+		enc, name, exact := openAITokenizer(model)
+		codec, err := enc()
+		if err != nil {
+			return Count{}, err
+		}
+		tokens, err := codec.Count(text)
+		if err != nil {
+			return Count{}, err
+		}
+		if messages := root.Get("messages"); messages.IsArray() {
+			tokens += messageOverheadTokens * (len(messages.Array()) + 1)
+		}
+		count.Tokenizer = name
+		count.Estimated = !exact
+		count.InputTokens = tokens
+	}
+	return count, nil
+}
+
+// TextTokens counts the tokens of plain text, such as a model's response, with the tokenizer
+// Tokens would use for model. The count is estimated unless the model is an OpenAI model.
+func TextTokens(model string, providers []string, text string) (int, bool, error) {
+	switch family(model, providers) {
+	case "claude":
+		return charTokens(text, claudeCharsPerToken), true, nil
+	case "gemini":
+		return charTokens(text, geminiCharsPerToken), true, nil
+	}
+	enc, _, exact := openAITokenizer(model)
+	codec, err := enc()
+	if err != nil {
+		return 0, false, err
+	}
+	tokens, err := codec.Count(text)
+	if err != nil {
+		return 0, false, err
+	}
+	return tokens, !exact, nil
+}
+
+// ResponseText returns the generated text of a response body or a streamed event in the
+// OpenAI, Claude or Gemini format. Of Responses API events only the deltas count, since the
+// other events repeat text already streamed.
+func ResponseText(payload []byte) string {
+	root := gjson.ParseBytes(payload)
+	if eventType := root.Get("type").String(); strings.HasPrefix(eventType, "response.") && !strings.HasSuffix(eventType, ".delta") {
+		return ""
+	}
+	if output := root.Get("output"); output.IsArray() {
+		// A Responses API body echoes the instructions and settings of the request.
+		root = output
+	}
+	var segments []string
+	collectText(root, &segments)
+	return strings.Join(segments, "\n")
+}
+
+// family returns the tokenizer family of a model: "claude", "gemini" or "openai".
+func family(model string, providers []string) string {
+	name := strings.ToLower(strings.TrimSpace(model))
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	switch {
+	case strings.HasPrefix(name, "claude"):
+		return "claude"
+	case strings.HasPrefix(name, "gemini"), strings.HasPrefix(name, "gemma"):
+		return "gemini"
+	case isOpenAIModel(name):
+		return "openai"
+	}
+	for _, provider := range providers {
+		switch strings.ToLower(provider) {
+		case "claude":
+			return "claude"
+		case "gemini", "gemini-cli", "aistudio", "vertex":
+			return "gemini"
+		}
+	}
+	return "openai"
+}
+
+func isOpenAIModel(name string) bool {
+	for _, prefix := range []string{"gpt-", "chatgpt", "codex", "o1", "o3", "o4", "text-embedding"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// openAITokenizer returns the tiktoken codec of a model, its name and whether it is the
+// model's own tokenizer rather than a stand-in for an unknown model.
+func openAITokenizer(model string) (func() (tokenizer.Codec, error), string, bool) {
+	name := strings.ToLower(strings.TrimSpace(model))
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	forModel := func(m tokenizer.Model) func() (tokenizer.Codec, error) {
+		return func() (tokenizer.Codec, error) { return tokenizer.ForModel(m) }
+	}
+	switch {
+	case strings.HasPrefix(name, "gpt-5"), strings.HasPrefix(name, "codex"):
+		return forModel(tokenizer.GPT5), string(tokenizer.O200kBase), true
+	case strings.HasPrefix(name, "gpt-4.1"):
+		return forModel(tokenizer.GPT41), string(tokenizer.O200kBase), true
+	case strings.HasPrefix(name, "gpt-4o"), strings.HasPrefix(name, "chatgpt"):
+		return forModel(tokenizer.GPT4o), string(tokenizer.O200kBase), true
+	case strings.HasPrefix(name, "gpt-4"), strings.HasPrefix(name, "text-embedding"):
+		return forModel(tokenizer.GPT4), string(tokenizer.Cl100kBase), true
+	case strings.HasPrefix(name, "gpt-3"):
+		return forModel(tokenizer.GPT35Turbo), string(tokenizer.Cl100kBase), true
+	case strings.HasPrefix(name, "o1"):
+		return forModel(tokenizer.O1), string(tokenizer.O200kBase), true
+	case strings.HasPrefix(name, "o3"):
+		return forModel(tokenizer.O3), string(tokenizer.O200kBase), true
+	case strings.HasPrefix(name, "o4"):
+		return forModel(tokenizer.O4Mini), string(tokenizer.O200kBase), true
+	default:
+		return func() (tokenizer.Codec, error) { return tokenizer.Get(tokenizer.O200kBase) }, string(tokenizer.O200kBase), false
+	}
+}
+
+// collectText gathers the text of a request: message contents, instructions, tool
+// definitions and tool calls, skipping identifiers, settings and inline media.
+func collectText(value gjson.Result, segments *[]string) {
+	switch {
+	case value.IsObject():
+		value.ForEach(func(key, child gjson.Result) bool {
+			if _, skip := skippedKeys[key.String()]; skip {
+				return true
+			}
+			// Tool schemas count by their property names as well as their descriptions.
+			if key.String() == "properties" && child.IsObject() {
+				child.ForEach(func(name, _ gjson.Result) bool {
+					*segments = append(*segments, name.String())
+					return true
+				})
+			}
+			collectText(child, segments)
+			return true
+		})
+	case value.IsArray():
+		value.ForEach(func(_, child gjson.Result) bool {
+			collectText(child, segments)
+			return true
+		})
+	case value.Type == gjson.String:
+		text := value.String()
+		if text != "" && !strings.HasPrefix(text, "data:") {
+			*segments = append(*segments, text)
+		}
+	}
+}
+
+func charTokens(text string, charsPerToken float64) int {
+	chars := utf8.RuneCountInString(text)
+	if chars == 0 {
+		return 0
+	}
+	return int(math.Ceil(float64(chars) / charsPerToken))
+}
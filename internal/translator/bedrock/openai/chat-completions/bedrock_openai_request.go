@@ -0,0 +1,305 @@
+// Package chat_completions provides request translation functionality for OpenAI to the AWS
+// Bedrock Converse API. It handles parsing and transforming OpenAI Chat Completions requests
+// into Converse requests, converting system instructions, message contents, images and
+// documents, tool declarations, tool calls and tool results. The model is not part of a
+// Converse body: it is addressed by the request path.
+package chat_completions
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// emptyToolResult replaces the empty output of a tool, as Converse rejects empty text blocks.
+const emptyToolResult = "(empty)"
+
+// ConvertOpenAIRequestToBedrock parses and transforms an OpenAI Chat Completions API request
+// into a Bedrock Converse request. The function performs the following conversions:
+// 1. max_tokens, temperature, top_p and stop into inferenceConfig
+// 2. System and developer messages into the system blocks
+// 3. Text, image and file parts into text, image and document blocks
+// 4. Assistant tool calls into toolUse blocks and tool messages into toolResult blocks
+// 5. Tools and tool_choice into toolConfig
+// 6. reasoning_effort into the extended thinking of Anthropic models
+// Consecutive messages of the same role are merged, as Converse requires alternating roles.
+//
+// Parameters:
+//   - modelName: The Bedrock model ID, used to recognise Anthropic models
+//   - rawJSON: The raw JSON request data from the OpenAI API
+//   - stream: A boolean indicating if the request is for a streaming response
+//
+// Returns:
+//   - []byte: The transformed request data in Converse format
+func ConvertOpenAIRequestToBedrock(modelName string, inputRawJSON []byte, _ bool) []byte {
+	root := gjson.ParseBytes(inputRawJSON)
+	out := `{"messages":[]}`
+
+	if v := root.Get("max_completion_tokens"); v.Exists() {
+		out, _ = sjson.Set(out, "inferenceConfig.maxTokens", v.Int())
+	} else if v = root.Get("max_tokens"); v.Exists() {
+		out, _ = sjson.Set(out, "inferenceConfig.maxTokens", v.Int())
+	}
+	if v := root.Get("temperature"); v.Exists() {
+		out, _ = sjson.Set(out, "inferenceConfig.temperature", v.Float())
+	}
+	if v := root.Get("top_p"); v.Exists() {
+		out, _ = sjson.Set(out, "inferenceConfig.topP", v.Float())
+	}
+	if stop := root.Get("stop"); stop.Exists() {
+		if stop.IsArray() {
+			for _, s := range stop.Array() {
+				out, _ = sjson.Set(out, "inferenceConfig.stopSequences.-1", s.String())
+			}
+		} else if stop.String() != "" {
+			out, _ = sjson.Set(out, "inferenceConfig.stopSequences.-1", stop.String())
+		}
+	}
+	if effort := root.Get("reasoning_effort"); effort.Exists() && isAnthropicModel(modelName) {
+		budget := 0
+		switch effort.String() {
+		case "low":
+			budget = 1024
+		case "medium":
+			budget = 8192
+		case "high":
+			budget = 24576
+		}
+		if budget > 0 {
+			out, _ = sjson.Set(out, "additionalModelRequestFields.thinking.type", "enabled")
+			out, _ = sjson.Set(out, "additionalModelRequestFields.thinking.budget_tokens", budget)
+			// The output budget must leave room for the answer after the thinking.
+			if maxTokens := gjson.Get(out, "inferenceConfig.maxTokens"); !maxTokens.Exists() || maxTokens.Int() <= int64(budget) {
+				out, _ = sjson.Set(out, "inferenceConfig.maxTokens", budget+8192)
+			}
+			// Sampling parameters are not supported together with extended thinking.
+			out, _ = sjson.Delete(out, "inferenceConfig.temperature")
+			out, _ = sjson.Delete(out, "inferenceConfig.topP")
+		}
+	}
+
+	var roles []string
+	var contents [][]string
+	appendMessage := func(role string, blocks []string) {
+		if len(blocks) == 0 {
+			return
+		}
+		if n := len(roles); n > 0 && roles[n-1] == role {
+			contents[n-1] = append(contents[n-1], blocks...)
+			return
+		}
+		roles = append(roles, role)
+		contents = append(contents, blocks)
+	}
+	usesTools := false
+
+	for _, message := range root.Get("messages").Array() {
+		role := message.Get("role").String()
+		switch role {
+		case "system", "developer":
+			for _, block := range contentBlocks(message.Get("content")) {
+				if gjson.Get(block, "text").Exists() {
+					out, _ = sjson.SetRaw(out, "system.-1", block)
+				}
+			}
+		case "user":
+			appendMessage("user", contentBlocks(message.Get("content")))
+		case "assistant":
+			blocks := contentBlocks(message.Get("content"))
+			for _, call := range message.Get("tool_calls").Array() {
+				if call.Get("type").String() != "" && call.Get("type").String() != "function" {
+					continue
+				}
+				block := `{"toolUse":{"toolUseId":"","name":"","input":{}}}`
+				block, _ = sjson.Set(block, "toolUse.toolUseId", call.Get("id").String())
+				block, _ = sjson.Set(block, "toolUse.name", call.Get("function.name").String())
+				if args := call.Get("function.arguments").String(); gjson.Valid(args) && gjson.Parse(args).IsObject() {
+					block, _ = sjson.SetRaw(block, "toolUse.input", args)
+				}
+				blocks = append(blocks, block)
+				usesTools = true
+			}
+			appendMessage("assistant", blocks)
+		case "tool":
+			text := toolResultText(message.Get("content"))
+			if text == "" {
+				text = emptyToolResult
+			}
+			block := `{"toolResult":{"toolUseId":"","content":[]}}`
+			block, _ = sjson.Set(block, "toolResult.toolUseId", message.Get("tool_call_id").String())
+			block, _ = sjson.Set(block, "toolResult.content.-1.text", text)
+			appendMessage("user", []string{block})
+			usesTools = true
+		}
+	}
+	for i, role := range roles {
+		message := `{"role":"","content":[]}`
+		message, _ = sjson.Set(message, "role", role)
+		for _, block := range contents[i] {
+			message, _ = sjson.SetRaw(message, "content.-1", block)
+		}
+		out, _ = sjson.SetRaw(out, "messages.-1", message)
+	}
+
+	toolChoice := root.Get("tool_choice")
+	if tools := root.Get("tools"); tools.IsArray() && len(tools.Array()) > 0 && (toolChoice.String() != "none" || usesTools) {
+		for _, tool := range tools.Array() {
+			if tool.Get("type").String() != "function" {
+				continue
+			}
+			fn := tool.Get("function")
+			spec := `{"toolSpec":{"name":"","inputSchema":{"json":{"type":"object","properties":{}}}}}`
+			spec, _ = sjson.Set(spec, "toolSpec.name", fn.Get("name").String())
+			if description := fn.Get("description").String(); description != "" {
+				spec, _ = sjson.Set(spec, "toolSpec.description", description)
+			}
+			if params := fn.Get("parameters"); params.IsObject() {
+				spec, _ = sjson.SetRaw(spec, "toolSpec.inputSchema.json", params.Raw)
+			}
+			out, _ = sjson.SetRaw(out, "toolConfig.tools.-1", spec)
+		}
+		switch {
+		case toolChoice.String() == "required":
+			out, _ = sjson.SetRaw(out, "toolConfig.toolChoice", `{"any":{}}`)
+		case toolChoice.IsObject() && toolChoice.Get("function.name").String() != "":
+			out, _ = sjson.Set(out, "toolConfig.toolChoice.tool.name", toolChoice.Get("function.name").String())
+		}
+	}
+
+	return []byte(out)
+}
+
+// contentBlocks converts the content of an OpenAI message, a string or an array of parts,
+// into Converse content blocks. Images and files are only supported as data URLs.
+func contentBlocks(content gjson.Result) []string {
+	var blocks []string
+	if content.Type == gjson.String {
+		if text := content.String(); text != "" {
+			block, _ := sjson.Set(`{"text":""}`, "text", text)
+			blocks = append(blocks, block)
+		}
+		return blocks
+	}
+	for _, part := range content.Array() {
+		switch part.Get("type").String() {
+		case "text":
+			if text := part.Get("text").String(); text != "" {
+				block, _ := sjson.Set(`{"text":""}`, "text", text)
+				blocks = append(blocks, block)
+			}
+		case "image_url":
+			url := part.Get("image_url.url").String()
+			if url == "" {
+				url = part.Get("image_url").String()
+			}
+			if mediaType, data, ok := parseDataURL(url); ok {
+				block := `{"image":{"format":"","source":{"bytes":""}}}`
+				block, _ = sjson.Set(block, "image.format", imageFormat(mediaType))
+				block, _ = sjson.Set(block, "image.source.bytes", data)
+				blocks = append(blocks, block)
+			}
+		case "file":
+			if mediaType, data, ok := parseDataURL(part.Get("file.file_data").String()); ok {
+				block := `{"document":{"format":"","name":"","source":{"bytes":""}}}`
+				block, _ = sjson.Set(block, "document.format", documentFormat(mediaType))
+				block, _ = sjson.Set(block, "document.name", documentName(part.Get("file.filename").String(), len(blocks)))
+				block, _ = sjson.Set(block, "document.source.bytes", data)
+				blocks = append(blocks, block)
+			}
+		}
+	}
+	return blocks
+}
+
+// toolResultText returns the text of a tool message, whose content is a string or an array
+// of text parts.
+func toolResultText(content gjson.Result) string {
+	if content.Type == gjson.String {
+		return content.String()
+	}
+	var parts []string
+	for _, part := range content.Array() {
+		if text := part.Get("text").String(); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// parseDataURL splits a base64 data URL into its media type and data.
+func parseDataURL(url string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(url, "data:")
+	if !ok {
+		return "", "", false
+	}
+	meta, data, ok := strings.Cut(rest, ",")
+	if !ok || !strings.HasSuffix(meta, ";base64") {
+		return "", "", false
+	}
+	return strings.TrimSuffix(meta, ";base64"), data, true
+}
+
+// imageFormat returns the Converse image format of an image media type.
+func imageFormat(mediaType string) string {
+	format := strings.TrimPrefix(strings.ToLower(mediaType), "image/")
+	if format == "jpg" {
+		return "jpeg"
+	}
+	return format
+}
+
+// documentFormat returns the Converse document format of a file media type.
+func documentFormat(mediaType string) string {
+	switch strings.ToLower(mediaType) {
+	case "application/pdf":
+		return "pdf"
+	case "text/csv":
+		return "csv"
+	case "text/html":
+		return "html"
+	case "text/markdown":
+		return "md"
+	case "application/msword":
+		return "doc"
+	case "application/vnd.openxmlformats-officedocument.wordprocessingml.document":
+		return "docx"
+	case "application/vnd.ms-excel":
+		return "xls"
+	case "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":
+		return "xlsx"
+	default:
+		return "txt"
+	}
+}
+
+// documentName derives a Converse document name, which may only contain letters, digits,
+// spaces, hyphens, parentheses and square brackets, from a file name.
+func documentName(filename string, index int) string {
+	if dot := strings.LastIndex(filename, "."); dot > 0 {
+		filename = filename[:dot]
+	}
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r == ' ', r == '-', r == '(', r == ')', r == '[', r == ']':
+			return r
+		}
+		return '-'
+	}, strings.TrimSpace(filename))
+	if name == "" {
+		name = "document"
+	}
+	if index > 0 {
+		name += "-" + strconv.Itoa(index)
+	}
+	return name
+}
+
+// isAnthropicModel reports whether a Bedrock model ID or inference profile names a Claude model.
+func isAnthropicModel(modelName string) bool {
+	modelName = strings.ToLower(modelName)
+	return strings.Contains(modelName, "anthropic") || strings.Contains(modelName, "claude")
+}
@@ -0,0 +1,194 @@
+// Package chat_completions provides response translation functionality for the AWS Bedrock
+// Converse API to OpenAI API compatibility. It converts Converse responses and the events of
+// ConverseStream, which the executor decodes from the AWS event stream into one JSON object
+// per event keyed by the event type, into OpenAI Chat Completions responses and chunks.
+package chat_completions
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// ConvertBedrockResponseToOpenAIParams holds parameters for response conversion
+type ConvertBedrockResponseToOpenAIParams struct {
+	CreatedAt    int64
+	ResponseID   string
+	FinishReason string
+	// ToolCalls maps Converse content block indexes to OpenAI tool call indexes
+	ToolCalls map[int64]int
+}
+
+// ConvertBedrockResponseToOpenAI converts a ConverseStream event to OpenAI Chat Completions
+// chunks. The finish reason of messageStop is held back and sent with the usage of the
+// metadata event that follows it, in the last chunk of the stream.
+//
+// Parameters:
+//   - ctx: The context for the request, used for cancellation and timeout handling
+//   - modelName: The name of the model being used for the response
+//   - rawJSON: A ConverseStream event, such as {"contentBlockDelta":{...}}
+//   - param: A pointer to a parameter object for maintaining state between calls
+//
+// Returns:
+//   - []string: A slice of strings, each containing an OpenAI-compatible JSON response
+func ConvertBedrockResponseToOpenAI(_ context.Context, modelName string, _, _, rawJSON []byte, param *any) []string {
+	if *param == nil {
+		*param = &ConvertBedrockResponseToOpenAIParams{
+			CreatedAt:  time.Now().Unix(),
+			ResponseID: fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano()),
+			ToolCalls:  make(map[int64]int),
+		}
+	}
+	state := (*param).(*ConvertBedrockResponseToOpenAIParams)
+
+	root := gjson.ParseBytes(rawJSON)
+	template := `{"id":"","object":"chat.completion.chunk","created":0,"model":"","choices":[{"index":0,"delta":{},"finish_reason":null}]}`
+	template, _ = sjson.Set(template, "id", state.ResponseID)
+	template, _ = sjson.Set(template, "created", state.CreatedAt)
+	template, _ = sjson.Set(template, "model", modelName)
+
+	switch {
+	case root.Get("messageStart").Exists():
+		template, _ = sjson.Set(template, "choices.0.delta.role", "assistant")
+		return []string{template}
+
+	case root.Get("contentBlockStart").Exists():
+		event := root.Get("contentBlockStart")
+		toolUse := event.Get("start.toolUse")
+		if !toolUse.Exists() {
+			return []string{}
+		}
+		index := len(state.ToolCalls)
+		state.ToolCalls[event.Get("contentBlockIndex").Int()] = index
+		template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.index", index)
+		template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.id", toolUse.Get("toolUseId").String())
+		template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.type", "function")
+		template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.function.name", toolUse.Get("name").String())
+		template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.function.arguments", "")
+		return []string{template}
+
+	case root.Get("contentBlockDelta").Exists():
+		event := root.Get("contentBlockDelta")
+		delta := event.Get("delta")
+		switch {
+		case delta.Get("text").Exists():
+			template, _ = sjson.Set(template, "choices.0.delta.content", delta.Get("text").String())
+		case delta.Get("reasoningContent.text").Exists():
+			template, _ = sjson.Set(template, "choices.0.delta.reasoning_content", delta.Get("reasoningContent.text").String())
+		case delta.Get("toolUse.input").Exists():
+			index, ok := state.ToolCalls[event.Get("contentBlockIndex").Int()]
+			if !ok {
+				return []string{}
+			}
+			template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.index", index)
+			template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.function.arguments", delta.Get("toolUse.input").String())
+		default:
+			return []string{}
+		}
+		return []string{template}
+
+	case root.Get("messageStop").Exists():
+		state.FinishReason = mapBedrockStopReasonToOpenAI(root.Get("messageStop.stopReason").String())
+		return []string{}
+
+	case root.Get("metadata").Exists():
+		finishReason := state.FinishReason
+		if finishReason == "" {
+			finishReason = "stop"
+		}
+		template, _ = sjson.Set(template, "choices.0.finish_reason", finishReason)
+		if usage := root.Get("metadata.usage"); usage.Exists() {
+			template = setBedrockUsage(template, usage)
+		}
+		return []string{template}
+
+	default:
+		return []string{}
+	}
+}
+
+// ConvertBedrockResponseToOpenAINonStream converts a Converse response to a non-streaming
+// OpenAI Chat Completions response.
+//
+// Parameters:
+//   - ctx: The context for the request, used for cancellation and timeout handling
+//   - modelName: The name of the model being used for the response
+//   - rawJSON: The raw JSON response from the Converse API
+//   - param: A pointer to a parameter object for the conversion (unused in current implementation)
+//
+// Returns:
+//   - string: An OpenAI-compatible JSON response containing all message content and metadata
+func ConvertBedrockResponseToOpenAINonStream(_ context.Context, modelName string, _, _, rawJSON []byte, _ *any) string {
+	root := gjson.ParseBytes(rawJSON)
+	out := `{"id":"","object":"chat.completion","created":0,"model":"","choices":[{"index":0,"message":{"role":"assistant","content":null},"finish_reason":"stop"}],"usage":{"prompt_tokens":0,"completion_tokens":0,"total_tokens":0}}`
+	out, _ = sjson.Set(out, "id", fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano()))
+	out, _ = sjson.Set(out, "created", time.Now().Unix())
+	out, _ = sjson.Set(out, "model", modelName)
+
+	var text, reasoning string
+	toolCalls := 0
+	for _, block := range root.Get("output.message.content").Array() {
+		switch {
+		case block.Get("text").Exists():
+			text += block.Get("text").String()
+		case block.Get("reasoningContent.reasoningText.text").Exists():
+			reasoning += block.Get("reasoningContent.reasoningText.text").String()
+		case block.Get("toolUse").Exists():
+			toolUse := block.Get("toolUse")
+			arguments := toolUse.Get("input").Raw
+			if arguments == "" {
+				arguments = "{}"
+			}
+			call := `{"id":"","type":"function","function":{"name":"","arguments":""}}`
+			call, _ = sjson.Set(call, "id", toolUse.Get("toolUseId").String())
+			call, _ = sjson.Set(call, "function.name", toolUse.Get("name").String())
+			call, _ = sjson.Set(call, "function.arguments", arguments)
+			out, _ = sjson.SetRaw(out, "choices.0.message.tool_calls.-1", call)
+			toolCalls++
+		}
+	}
+	if text != "" || toolCalls == 0 {
+		out, _ = sjson.Set(out, "choices.0.message.content", text)
+	}
+	if reasoning != "" {
+		out, _ = sjson.Set(out, "choices.0.message.reasoning_content", reasoning)
+	}
+	out, _ = sjson.Set(out, "choices.0.finish_reason", mapBedrockStopReasonToOpenAI(root.Get("stopReason").String()))
+	if usage := root.Get("usage"); usage.Exists() {
+		out = setBedrockUsage(out, usage)
+	}
+	return out
+}
+
+// setBedrockUsage writes a Converse usage object to the usage of an OpenAI response. Like
+// Claude, Converse reports prompt cache reads and writes apart from inputTokens.
+func setBedrockUsage(out string, usage gjson.Result) string {
+	prompt := util.ClaudePromptUsage{
+		InputTokens:         usage.Get("inputTokens").Int(),
+		CacheReadTokens:     usage.Get("cacheReadInputTokens").Int(),
+		CacheCreationTokens: usage.Get("cacheWriteInputTokens").Int(),
+	}
+	completion := usage.Get("outputTokens").Int()
+	out, _ = sjson.Set(out, "usage.prompt_tokens", prompt.PromptTokens())
+	out, _ = sjson.Set(out, "usage.completion_tokens", completion)
+	out, _ = sjson.Set(out, "usage.total_tokens", prompt.PromptTokens()+completion)
+	return prompt.SetDetails(out, "usage.prompt_tokens_details")
+}
+
+// mapBedrockStopReasonToOpenAI maps Converse stop reasons to OpenAI finish reasons
+func mapBedrockStopReasonToOpenAI(stopReason string) string {
+	switch stopReason {
+	case "tool_use":
+		return "tool_calls"
+	case "max_tokens":
+		return "length"
+	case "content_filtered", "guardrail_intervened":
+		return "content_filter"
+	default:
+		return "stop"
+	}
+}
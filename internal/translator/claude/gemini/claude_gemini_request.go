@@ -45,7 +45,7 @@ var (
 // Returns:
 //   - []byte: The transformed request data in Claude Code API format
 func ConvertGeminiRequestToClaude(modelName string, inputRawJSON []byte, stream bool) []byte {
-	rawJSON := bytes.Clone(inputRawJSON)
+	rawJSON := util.NormalizeGeminiRequest(bytes.Clone(inputRawJSON))
 
 	if account == "" {
 		u, _ := uuid.NewRandom()
@@ -115,7 +115,7 @@ func ConvertGeminiRequestToClaude(modelName string, inputRawJSON []byte, stream
 		}
 		// Include thoughts configuration for reasoning process visibility
 		if thinkingConfig := genConfig.Get("thinkingConfig"); thinkingConfig.Exists() && thinkingConfig.IsObject() {
-			if includeThoughts := thinkingConfig.Get("include_thoughts"); includeThoughts.Exists() {
+			if includeThoughts := thinkingConfig.Get("includeThoughts"); includeThoughts.Exists() {
 				if includeThoughts.Type == gjson.True {
 					out, _ = sjson.Set(out, "thinking.type", "enabled")
 					if thinkingBudget := thinkingConfig.Get("thinkingBudget"); thinkingBudget.Exists() {
@@ -127,7 +127,7 @@ func ConvertGeminiRequestToClaude(modelName string, inputRawJSON []byte, stream
 	}
 
 	// System instruction conversion to Claude Code format
-	if sysInstr := root.Get("system_instruction"); sysInstr.Exists() {
+	if sysInstr := root.Get("systemInstruction"); sysInstr.Exists() {
 		if parts := sysInstr.Get("parts"); parts.Exists() && parts.IsArray() {
 			var systemText strings.Builder
 			parts.ForEach(func(_, part gjson.Result) bool {
@@ -226,10 +226,13 @@ func ConvertGeminiRequestToClaude(modelName string, inputRawJSON []byte, stream
 						return true
 					}
 
-					// Image content (inline_data) conversion to Claude Code format
-					if inlineData := part.Get("inline_data"); inlineData.Exists() {
+					// Image content (inlineData) conversion to Claude Code format
+					if inlineData := part.Get("inlineData"); inlineData.Exists() {
 						imageContent := `{"type":"image","source":{"type":"base64","media_type":"","data":""}}`
-						if mimeType := inlineData.Get("mime_type"); mimeType.Exists() {
+						if inlineData.Get("mimeType").String() == "application/pdf" {
+							imageContent, _ = sjson.Set(imageContent, "type", "document")
+						}
+						if mimeType := inlineData.Get("mimeType"); mimeType.Exists() {
 							imageContent, _ = sjson.Set(imageContent, "source.media_type", mimeType.String())
 						}
 						if data := inlineData.Get("data"); data.Exists() {
@@ -240,11 +243,19 @@ func ConvertGeminiRequestToClaude(modelName string, inputRawJSON []byte, stream
 					}
 
 					// File data conversion to text content with file info
-					if fileData := part.Get("file_data"); fileData.Exists() {
+					if fileData := part.Get("fileData"); fileData.Exists() {
+						// Claude fetches remote images itself
+						fileURI := fileData.Get("fileUri").String()
+						if strings.HasPrefix(fileData.Get("mimeType").String(), "image/") && util.IsRemoteURL(fileURI) {
+							imageContent := `{"type":"image","source":{"type":"url","url":""}}`
+							imageContent, _ = sjson.Set(imageContent, "source.url", fileURI)
+							msg, _ = sjson.SetRaw(msg, "content.-1", imageContent)
+							return true
+						}
 						// For file data, we'll convert to text content with file info
 						textContent := `{"type":"text","text":""}`
-						fileInfo := "File: " + fileData.Get("file_uri").String()
-						if mimeType := fileData.Get("mime_type"); mimeType.Exists() {
+						fileInfo := "File: " + fileData.Get("fileUri").String()
+						if mimeType := fileData.Get("mimeType"); mimeType.Exists() {
 							fileInfo += " (Type: " + mimeType.String() + ")"
 						}
 						textContent, _ = sjson.Set(textContent, "text", fileInfo)
@@ -307,21 +318,28 @@ func ConvertGeminiRequestToClaude(modelName string, inputRawJSON []byte, stream
 	}
 
 	// Tool config mapping from Gemini format to Claude Code format
-	if toolConfig := root.Get("tool_config"); toolConfig.Exists() {
-		if funcCalling := toolConfig.Get("function_calling_config"); funcCalling.Exists() {
-			if mode := funcCalling.Get("mode"); mode.Exists() {
-				switch mode.String() {
-				case "AUTO":
-					out, _ = sjson.Set(out, "tool_choice", map[string]interface{}{"type": "auto"})
-				case "NONE":
-					out, _ = sjson.Set(out, "tool_choice", map[string]interface{}{"type": "none"})
-				case "ANY":
-					out, _ = sjson.Set(out, "tool_choice", map[string]interface{}{"type": "any"})
+	if funcCalling, allowed := util.GeminiRequestFunctionCalling(root); funcCalling.Exists() {
+		if mode := funcCalling.Get("mode"); mode.Exists() {
+			switch mode.String() {
+			case "AUTO":
+				out, _ = sjson.Set(out, "tool_choice", map[string]interface{}{"type": "auto"})
+			case "NONE":
+				out, _ = sjson.Set(out, "tool_choice", map[string]interface{}{"type": "none"})
+			case "ANY":
+				out, _ = sjson.Set(out, "tool_choice", map[string]interface{}{"type": "any"})
+				// A single allowed function is a forced call of that function
+				if len(allowed) == 1 {
+					out, _ = sjson.Set(out, "tool_choice", map[string]interface{}{"type": "tool", "name": allowed[0].String()})
 				}
 			}
 		}
 	}
 
+	// Structured output: responseMimeType + schema -> a tool whose input is the JSON response
+	if format, ok := util.GeminiResponseFormat(root.Get("generationConfig")); ok {
+		out = format.ApplyClaude(out)
+	}
+
 	// Stream setting configuration
 	out, _ = sjson.Set(out, "stream", stream)
 
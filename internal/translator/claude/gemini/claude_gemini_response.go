@@ -13,6 +13,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -37,6 +38,10 @@ type ConvertAnthropicResponseToGeminiParams struct {
 	// Keyed by content_block index from Claude SSE events
 	ToolUseNames map[int]string           // function/tool name per block index
 	ToolUseArgs  map[int]*strings.Builder // accumulates partial_json across deltas
+
+	// StructuredOutput is set when the client asked for a JSON response, which is served
+	// through the structured output tool
+	StructuredOutput bool
 }
 
 // ConvertClaudeResponseToGemini converts Claude Code streaming response format to Gemini format.
@@ -56,9 +61,10 @@ type ConvertAnthropicResponseToGeminiParams struct {
 func ConvertClaudeResponseToGemini(_ context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) []string {
 	if *param == nil {
 		*param = &ConvertAnthropicResponseToGeminiParams{
-			Model:      modelName,
-			CreatedAt:  0,
-			ResponseID: "",
+			Model:            modelName,
+			CreatedAt:        0,
+			ResponseID:       "",
+			StructuredOutput: structuredOutputRequested(originalRequestRawJSON),
 		}
 	}
 
@@ -169,6 +175,13 @@ func ConvertClaudeResponseToGemini(_ context.Context, modelName string, original
 				argsTrim = strings.TrimSpace(b.String())
 			}
 		}
+		if name == util.StructuredOutputToolName && (*param).(*ConvertAnthropicResponseToGeminiParams).StructuredOutput {
+			// The structured output tool input is the response text, not a function call
+			template, _ = sjson.SetRaw(template, "candidates.0.content.parts.-1", structuredOutputPart(argsTrim))
+			delete((*param).(*ConvertAnthropicResponseToGeminiParams).ToolUseArgs, idx)
+			delete((*param).(*ConvertAnthropicResponseToGeminiParams).ToolUseNames, idx)
+			return []string{template}
+		}
 		if name != "" || argsTrim != "" {
 			functionCall := `{"functionCall":{"name":"","args":{}}}`
 			if name != "" {
@@ -353,6 +366,7 @@ func ConvertClaudeResponseToGeminiNonStream(_ context.Context, modelName string,
 		IsStreaming:       false,
 		ToolUseNames:      nil,
 		ToolUseArgs:       nil,
+		StructuredOutput:  structuredOutputRequested(originalRequestRawJSON),
 	}
 
 	// Process each streaming event and collect parts
@@ -448,7 +462,11 @@ func ConvertClaudeResponseToGeminiNonStream(_ context.Context, modelName string,
 					argsTrim = strings.TrimSpace(b.String())
 				}
 			}
-			if name != "" || argsTrim != "" {
+			if name == util.StructuredOutputToolName && newParam.StructuredOutput {
+				allParts = append(allParts, gjson.Parse(structuredOutputPart(argsTrim)).Value())
+				delete(newParam.ToolUseArgs, idx)
+				delete(newParam.ToolUseNames, idx)
+			} else if name != "" || argsTrim != "" {
 				functionCallJSON := `{"functionCall":{"name":"","args":{}}}`
 				if name != "" {
 					functionCallJSON, _ = sjson.Set(functionCallJSON, "functionCall.name", name)
@@ -531,6 +549,26 @@ func ConvertClaudeResponseToGeminiNonStream(_ context.Context, modelName string,
 	return template
 }
 
+// structuredOutputRequested reports whether the Gemini request asked for a JSON response.
+// Gemini CLI requests carry the Gemini request under "request".
+func structuredOutputRequested(originalRequestRawJSON []byte) bool {
+	request := originalRequestRawJSON
+	if inner := gjson.GetBytes(originalRequestRawJSON, "request"); inner.IsObject() {
+		request = []byte(inner.Raw)
+	}
+	_, ok := util.GeminiResponseFormat(gjson.GetBytes(util.NormalizeGeminiRequest(request), "generationConfig"))
+	return ok
+}
+
+// structuredOutputPart returns the text part carrying the input of the structured output tool.
+func structuredOutputPart(args string) string {
+	if args == "" {
+		args = "{}"
+	}
+	part, _ := sjson.Set(`{"text":""}`, "text", args)
+	return part
+}
+
 func GeminiTokenCount(ctx context.Context, count int64) string {
 	return fmt.Sprintf(`{"totalTokens":%d,"promptTokensDetails":[{"modality":"TEXT","tokenCount":%d}]}`, count, count)
 }
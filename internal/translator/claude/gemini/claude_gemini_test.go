@@ -0,0 +1,31 @@
+package gemini
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/translatortest"
+)
+
+var (
+	toolUseID  = regexp.MustCompile(`toolu_[A-Za-z0-9]+`)
+	userID     = regexp.MustCompile(`"user_id":"([^"]+)"`)
+	createTime = regexp.MustCompile(`"createTime":"([^"]+)"`)
+)
+
+func TestConvertGeminiRequestToClaude(t *testing.T) {
+	translatortest.Run(t, "request_", func(input []byte) []byte {
+		return ConvertGeminiRequestToClaude("claude-sonnet-4", input, true)
+	}, toolUseID, userID)
+}
+
+func TestConvertClaudeResponseToGemini(t *testing.T) {
+	translatortest.Run(t, "stream_", func(input []byte) []byte {
+		var param any
+		request := []byte(`{"stream":true}`)
+		return translatortest.Stream(input, func(chunk []byte) []string {
+			return ConvertClaudeResponseToGemini(context.Background(), "claude-sonnet-4", request, request, chunk, &param)
+		})
+	}, createTime)
+}
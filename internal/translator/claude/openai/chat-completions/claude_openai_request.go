@@ -16,6 +16,7 @@ import (
 	"strings"
 
 	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -165,6 +166,7 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 					var contentParts []interface{}
 					contentResult.ForEach(func(_, part gjson.Result) bool {
 						partType := part.Get("type").String()
+						before := len(contentParts)
 
 						switch partType {
 						case "text":
@@ -177,24 +179,42 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 						case "image_url":
 							// Convert OpenAI image format to Claude Code format
 							imageURL := part.Get("image_url.url").String()
-							if strings.HasPrefix(imageURL, "data:") {
-								// Extract base64 data and media type from data URL
-								parts := strings.Split(imageURL, ",")
-								if len(parts) == 2 {
-									mediaTypePart := strings.Split(parts[0], ";")[0]
-									mediaType := strings.TrimPrefix(mediaTypePart, "data:")
-									data := parts[1]
-
-									contentParts = append(contentParts, map[string]interface{}{
-										"type": "image",
-										"source": map[string]interface{}{
-											"type":       "base64",
-											"media_type": mediaType,
-											"data":       data,
-										},
-									})
-								}
+							if mediaType, data, ok := util.ParseDataURL(imageURL); ok {
+								contentParts = append(contentParts, map[string]interface{}{
+									"type": "image",
+									"source": map[string]interface{}{
+										"type":       "base64",
+										"media_type": mediaType,
+										"data":       data,
+									},
+								})
+							} else if util.IsRemoteURL(imageURL) {
+								// Claude fetches remote images itself
+								contentParts = append(contentParts, map[string]interface{}{
+									"type": "image",
+									"source": map[string]interface{}{
+										"type": "url",
+										"url":  imageURL,
+									},
+								})
 							}
+
+						case "file":
+							// Inline PDF files map to Claude document blocks
+							if mediaType, data, ok := util.ParseDataURL(part.Get("file.file_data").String()); ok && mediaType == "application/pdf" {
+								contentParts = append(contentParts, map[string]interface{}{
+									"type": "document",
+									"source": map[string]interface{}{
+										"type":       "base64",
+										"media_type": mediaType,
+										"data":       data,
+									},
+								})
+							}
+						}
+						// Carry prompt caching breakpoints over to the converted block
+						if cacheControl, ok := util.ClaudeCacheControl(part); ok && len(contentParts) > before {
+							contentParts[len(contentParts)-1].(map[string]interface{})["cache_control"] = cacheControl
 						}
 						return true
 					})
@@ -230,22 +250,8 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 								"name": function.Get("name").String(),
 							}
 
-							// Parse arguments for the tool call
-							if args := function.Get("arguments"); args.Exists() {
-								argsStr := args.String()
-								if argsStr != "" {
-									var argsMap map[string]interface{}
-									if err := json.Unmarshal([]byte(argsStr), &argsMap); err == nil {
-										toolUse["input"] = argsMap
-									} else {
-										toolUse["input"] = map[string]interface{}{}
-									}
-								} else {
-									toolUse["input"] = map[string]interface{}{}
-								}
-							} else {
-								toolUse["input"] = map[string]interface{}{}
-							}
+							// Parse arguments for the tool call, keeping malformed ones rather than dropping them
+							toolUse["input"] = json.RawMessage(util.ToolCallArgs(function.Get("arguments").String()))
 
 							contentParts = append(contentParts, toolUse)
 						}
@@ -254,23 +260,42 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 					msg["content"] = contentParts
 				}
 
+				// A breakpoint on the message caches everything up to its last block
+				if cacheControl, ok := util.ClaudeCacheControl(message); ok {
+					if blocks, isBlocks := msg["content"].([]interface{}); isBlocks && len(blocks) > 0 {
+						blocks[len(blocks)-1].(map[string]interface{})["cache_control"] = cacheControl
+					}
+				}
+
 				anthropicMessages = append(anthropicMessages, msg)
 
 			case "tool":
 				// Handle tool result messages conversion
-				toolCallID := message.Get("tool_call_id").String()
-				content := message.Get("content").String()
+				toolResult := map[string]interface{}{
+					"type":        "tool_result",
+					"tool_use_id": message.Get("tool_call_id").String(),
+					"content":     util.ToolResultText(contentResult),
+				}
+				if cacheControl, ok := util.ClaudeCacheControl(message); ok {
+					toolResult["cache_control"] = cacheControl
+				}
+
+				// Results of parallel tool calls belong in a single user message
+				if n := len(anthropicMessages); n > 0 {
+					if previous, ok := anthropicMessages[n-1].(map[string]interface{}); ok && previous["role"] == "user" {
+						if blocks, isBlocks := previous["content"].([]interface{}); isBlocks && len(blocks) > 0 {
+							if first, isMap := blocks[0].(map[string]interface{}); isMap && first["type"] == "tool_result" {
+								previous["content"] = append(blocks, toolResult)
+								return true
+							}
+						}
+					}
+				}
 
 				// Create tool result message in Claude Code format
 				msg := map[string]interface{}{
-					"role": "user",
-					"content": []interface{}{
-						map[string]interface{}{
-							"type":        "tool_result",
-							"tool_use_id": toolCallID,
-							"content":     content,
-						},
-					},
+					"role":    "user",
+					"content": []interface{}{toolResult},
 				}
 
 				anthropicMessages = append(anthropicMessages, msg)
@@ -303,6 +328,13 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 					anthropicTool["input_schema"] = parameters.Value()
 				}
 
+				// Caching the last tool caches every tool definition
+				if cacheControl, ok := util.ClaudeCacheControl(tool); ok {
+					anthropicTool["cache_control"] = cacheControl
+				} else if cacheControl, ok = util.ClaudeCacheControl(function); ok {
+					anthropicTool["cache_control"] = cacheControl
+				}
+
 				anthropicTools = append(anthropicTools, anthropicTool)
 			}
 			return true
@@ -321,7 +353,7 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 			choice := toolChoice.String()
 			switch choice {
 			case "none":
-				// Don't set tool_choice, Claude Code will not use tools
+				out, _ = sjson.Set(out, "tool_choice", map[string]interface{}{"type": "none"})
 			case "auto":
 				out, _ = sjson.Set(out, "tool_choice", map[string]interface{}{"type": "auto"})
 			case "required":
@@ -340,5 +372,20 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 		}
 	}
 
+	// parallel_tool_calls: false -> disable_parallel_tool_use on the (default auto) tool choice
+	if parallel := root.Get("parallel_tool_calls"); parallel.Exists() && !parallel.Bool() && gjson.Get(out, "tools").Exists() {
+		if choiceType := gjson.Get(out, "tool_choice.type").String(); choiceType != "none" {
+			if choiceType == "" {
+				out, _ = sjson.Set(out, "tool_choice.type", "auto")
+			}
+			out, _ = sjson.Set(out, "tool_choice.disable_parallel_tool_use", true)
+		}
+	}
+
+	// Structured output: response_format -> a tool whose input is the JSON response
+	if format, ok := util.OpenAIResponseFormat(root.Get("response_format")); ok {
+		out = format.ApplyClaude(out)
+	}
+
 	return []byte(out)
 }
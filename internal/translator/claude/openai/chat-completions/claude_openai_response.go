@@ -12,6 +12,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -25,15 +26,26 @@ type ConvertAnthropicResponseToOpenAIParams struct {
 	CreatedAt    int64
 	ResponseID   string
 	FinishReason string
-	// Tool calls accumulator for streaming
+	// Tool calls accumulator for streaming, keyed by Claude content block index
 	ToolCallsAccumulator map[int]*ToolCallAccumulator
+	// ToolCallCount numbers tool calls in the order they start, as OpenAI clients expect
+	ToolCallCount int
+	// StructuredOutput is set when the client asked for a JSON response_format, which is
+	// served through the structured output tool
+	StructuredOutput bool
+	// Prompt accumulates the prompt and prompt cache token counts reported by message_start
+	Prompt util.ClaudePromptUsage
 }
 
 // ToolCallAccumulator holds the state for accumulating tool call data
 type ToolCallAccumulator struct {
-	ID        string
-	Name      string
-	Arguments strings.Builder
+	// Index is the OpenAI tool call index, distinct from the Claude content block index
+	Index int
+	ID    string
+	Name  string
+	// Structured marks the structured output tool, whose input is streamed as content
+	Structured bool
+	Arguments  strings.Builder
 }
 
 // ConvertClaudeResponseToOpenAI converts Claude Code streaming response format to OpenAI Chat Completions format.
@@ -51,10 +63,12 @@ type ToolCallAccumulator struct {
 //   - []string: A slice of strings, each containing an OpenAI-compatible JSON response
 func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) []string {
 	if *param == nil {
+		_, structured := util.OpenAIResponseFormat(gjson.GetBytes(originalRequestRawJSON, "response_format"))
 		*param = &ConvertAnthropicResponseToOpenAIParams{
-			CreatedAt:    0,
-			ResponseID:   "",
-			FinishReason: "",
+			CreatedAt:        0,
+			ResponseID:       "",
+			FinishReason:     "",
+			StructuredOutput: structured,
 		}
 	}
 
@@ -88,6 +102,7 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 		if message := root.Get("message"); message.Exists() {
 			(*param).(*ConvertAnthropicResponseToOpenAIParams).ResponseID = message.Get("id").String()
 			(*param).(*ConvertAnthropicResponseToOpenAIParams).CreatedAt = time.Now().Unix()
+			(*param).(*ConvertAnthropicResponseToOpenAIParams).Prompt.Merge(message.Get("usage"))
 
 			template, _ = sjson.Set(template, "id", (*param).(*ConvertAnthropicResponseToOpenAIParams).ResponseID)
 			template, _ = sjson.Set(template, "model", modelName)
@@ -118,13 +133,33 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 					(*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator = make(map[int]*ToolCallAccumulator)
 				}
 
+				if (*param).(*ConvertAnthropicResponseToOpenAIParams).StructuredOutput && toolName == util.StructuredOutputToolName {
+					// The structured output tool input is the response content, not a tool call
+					(*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator[index] = &ToolCallAccumulator{Structured: true}
+					return []string{}
+				}
+
+				toolCallIndex := (*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallCount
+				(*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallCount++
 				(*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator[index] = &ToolCallAccumulator{
-					ID:   toolCallID,
-					Name: toolName,
+					Index: toolCallIndex,
+					ID:    toolCallID,
+					Name:  toolName,
 				}
 
-				// Don't output anything yet - wait for complete tool call
-				return []string{}
+				// Announce the tool call; its arguments follow as deltas
+				toolCall := map[string]interface{}{
+					"index": toolCallIndex,
+					"id":    toolCallID,
+					"type":  "function",
+					"function": map[string]interface{}{
+						"name":      toolName,
+						"arguments": "",
+					},
+				}
+				template, _ = sjson.Set(template, "choices.0.delta.role", "assistant")
+				template, _ = sjson.Set(template, "choices.0.delta.tool_calls", []interface{}{toolCall})
+				return []string{template}
 			}
 		}
 		return []string{}
@@ -149,16 +184,27 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 					hasContent = true
 				}
 			case "input_json_delta":
-				// Tool use input delta - accumulate arguments for tool calls
-				if partialJSON := delta.Get("partial_json"); partialJSON.Exists() {
+				// Tool use input delta - forward as an incremental tool call arguments update
+				if partialJSON := delta.Get("partial_json"); partialJSON.Exists() && partialJSON.String() != "" {
 					index := int(root.Get("index").Int())
 					if (*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator != nil {
 						if accumulator, exists := (*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator[index]; exists {
 							accumulator.Arguments.WriteString(partialJSON.String())
+							if accumulator.Structured {
+								template, _ = sjson.Set(template, "choices.0.delta.content", partialJSON.String())
+								return []string{template}
+							}
+							toolCall := map[string]interface{}{
+								"index": accumulator.Index,
+								"function": map[string]interface{}{
+									"arguments": partialJSON.String(),
+								},
+							}
+							template, _ = sjson.Set(template, "choices.0.delta.tool_calls", []interface{}{toolCall})
+							return []string{template}
 						}
 					}
 				}
-				// Don't output anything yet - wait for complete tool call
 				return []string{}
 			}
 		}
@@ -169,32 +215,27 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 		}
 
 	case "content_block_stop":
-		// End of content block - output complete tool call if it's a tool_use block
+		// End of content block - a tool call without input still needs valid JSON arguments
 		index := int(root.Get("index").Int())
 		if (*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator != nil {
 			if accumulator, exists := (*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator[index]; exists {
-				// Build complete tool call with accumulated arguments
-				arguments := accumulator.Arguments.String()
-				if arguments == "" {
-					arguments = "{}"
-				}
-
-				toolCall := map[string]interface{}{
-					"index": index,
-					"id":    accumulator.ID,
-					"type":  "function",
-					"function": map[string]interface{}{
-						"name":      accumulator.Name,
-						"arguments": arguments,
-					},
-				}
-
-				template, _ = sjson.Set(template, "choices.0.delta.tool_calls", []interface{}{toolCall})
-
 				// Clean up the accumulator for this index
 				delete((*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator, index)
 
-				return []string{template}
+				if accumulator.Arguments.Len() == 0 && accumulator.Structured {
+					template, _ = sjson.Set(template, "choices.0.delta.content", "{}")
+					return []string{template}
+				}
+				if accumulator.Arguments.Len() == 0 {
+					toolCall := map[string]interface{}{
+						"index": accumulator.Index,
+						"function": map[string]interface{}{
+							"arguments": "{}",
+						},
+					}
+					template, _ = sjson.Set(template, "choices.0.delta.tool_calls", []interface{}{toolCall})
+					return []string{template}
+				}
 			}
 		}
 		return []string{}
@@ -204,18 +245,25 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 		if delta := root.Get("delta"); delta.Exists() {
 			if stopReason := delta.Get("stop_reason"); stopReason.Exists() {
 				(*param).(*ConvertAnthropicResponseToOpenAIParams).FinishReason = mapAnthropicStopReasonToOpenAI(stopReason.String())
+				if stopReason.String() == "tool_use" && (*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallCount == 0 {
+					// Only the structured output tool was called
+					(*param).(*ConvertAnthropicResponseToOpenAIParams).FinishReason = "stop"
+				}
 				template, _ = sjson.Set(template, "choices.0.finish_reason", (*param).(*ConvertAnthropicResponseToOpenAIParams).FinishReason)
 			}
 		}
 
 		// Handle usage information for token counts
 		if usage := root.Get("usage"); usage.Exists() {
+			prompt := &(*param).(*ConvertAnthropicResponseToOpenAIParams).Prompt
+			prompt.Merge(usage)
 			usageObj := map[string]interface{}{
-				"prompt_tokens":     usage.Get("input_tokens").Int(),
+				"prompt_tokens":     prompt.PromptTokens(),
 				"completion_tokens": usage.Get("output_tokens").Int(),
-				"total_tokens":      usage.Get("input_tokens").Int() + usage.Get("output_tokens").Int(),
+				"total_tokens":      prompt.PromptTokens() + usage.Get("output_tokens").Int(),
 			}
 			template, _ = sjson.Set(template, "usage", usageObj)
+			template = prompt.SetDetails(template, "usage.prompt_tokens_details")
 		}
 		return []string{template}
 
@@ -293,7 +341,8 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 	var messageID string
 	var model string
 	var createdAt int64
-	var inputTokens, outputTokens int64
+	var prompt util.ClaudePromptUsage
+	var outputTokens int64
 	var reasoningTokens int64
 	var stopReason string
 	var contentParts []string
@@ -301,7 +350,10 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 	// Use map to track tool calls by index for proper merging
 	toolCallsMap := make(map[int]map[string]interface{})
 	// Track tool call arguments accumulation
-	toolCallArgsMap := make(map[int]strings.Builder)
+	toolCallArgsMap := make(map[int]*strings.Builder)
+	// Content blocks of the structured output tool, whose input is the response content
+	_, structuredOutput := util.OpenAIResponseFormat(gjson.GetBytes(originalRequestRawJSON, "response_format"))
+	structuredBlocks := make(map[int]bool)
 
 	for _, chunk := range chunks {
 		root := gjson.ParseBytes(chunk)
@@ -314,9 +366,7 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 				messageID = message.Get("id").String()
 				model = message.Get("model").String()
 				createdAt = time.Now().Unix()
-				if usage := message.Get("usage"); usage.Exists() {
-					inputTokens = usage.Get("input_tokens").Int()
-				}
+				prompt.Merge(message.Get("usage"))
 			}
 
 		case "content_block_start":
@@ -326,6 +376,8 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 				if blockType == "thinking" {
 					// Start of thinking/reasoning content - skip for now as it's handled in delta
 					continue
+				} else if blockType == "tool_use" && structuredOutput && contentBlock.Get("name").String() == util.StructuredOutputToolName {
+					structuredBlocks[int(root.Get("index").Int())] = true
 				} else if blockType == "tool_use" {
 					// Initialize tool call tracking for this index
 					index := int(root.Get("index").Int())
@@ -338,7 +390,7 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 						},
 					}
 					// Initialize arguments builder for this tool call
-					toolCallArgsMap[index] = strings.Builder{}
+					toolCallArgsMap[index] = &strings.Builder{}
 				}
 			}
 
@@ -361,9 +413,10 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 					// Accumulate tool call arguments
 					if partialJSON := delta.Get("partial_json"); partialJSON.Exists() {
 						index := int(root.Get("index").Int())
-						if builder, exists := toolCallArgsMap[index]; exists {
+						if structuredBlocks[index] {
+							contentParts = append(contentParts, partialJSON.String())
+						} else if builder, exists := toolCallArgsMap[index]; exists {
 							builder.WriteString(partialJSON.String())
-							toolCallArgsMap[index] = builder
 						}
 					}
 				}
@@ -391,6 +444,7 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 				}
 			}
 			if usage := root.Get("usage"); usage.Exists() {
+				prompt.Merge(usage)
 				outputTokens = usage.Get("output_tokens").Int()
 				// Estimate reasoning tokens from accumulated thinking content
 				if len(reasoningParts) > 0 {
@@ -439,15 +493,19 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 		} else {
 			out, _ = sjson.Set(out, "choices.0.finish_reason", mapAnthropicStopReasonToOpenAI(stopReason))
 		}
+	} else if len(structuredBlocks) > 0 && stopReason == "tool_use" {
+		out, _ = sjson.Set(out, "choices.0.finish_reason", "stop")
 	} else {
 		out, _ = sjson.Set(out, "choices.0.finish_reason", mapAnthropicStopReasonToOpenAI(stopReason))
 	}
 
 	// Set usage information including prompt tokens, completion tokens, and total tokens
-	totalTokens := inputTokens + outputTokens
-	out, _ = sjson.Set(out, "usage.prompt_tokens", inputTokens)
+	// Prompt tokens include the tokens read from and written to the prompt cache
+	totalTokens := prompt.PromptTokens() + outputTokens
+	out, _ = sjson.Set(out, "usage.prompt_tokens", prompt.PromptTokens())
 	out, _ = sjson.Set(out, "usage.completion_tokens", outputTokens)
 	out, _ = sjson.Set(out, "usage.total_tokens", totalTokens)
+	out = prompt.SetDetails(out, "usage.prompt_tokens_details")
 
 	// Add reasoning tokens to usage details if any reasoning content was processed
 	if reasoningTokens > 0 {
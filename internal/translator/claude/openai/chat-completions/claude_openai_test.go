@@ -0,0 +1,30 @@
+package chat_completions
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/translatortest"
+)
+
+var (
+	userID  = regexp.MustCompile(`"user_id":"([^"]+)"`)
+	created = regexp.MustCompile(`"created":([0-9]+)`)
+)
+
+func TestConvertOpenAIRequestToClaude(t *testing.T) {
+	translatortest.Run(t, "request_", func(input []byte) []byte {
+		return ConvertOpenAIRequestToClaude("claude-sonnet-4", input, true)
+	}, userID)
+}
+
+func TestConvertClaudeResponseToOpenAI(t *testing.T) {
+	translatortest.Run(t, "stream_", func(input []byte) []byte {
+		var param any
+		request := []byte(`{"stream":true}`)
+		return translatortest.Stream(input, func(chunk []byte) []string {
+			return ConvertClaudeResponseToOpenAI(context.Background(), "claude-sonnet-4", request, request, chunk, &param)
+		})
+	}, created)
+}
@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -148,6 +149,15 @@ func ConvertOpenAIResponsesRequestToClaude(modelName string, inputRawJSON []byte
 				var textAggregate strings.Builder
 				var partsJSON []string
 				hasImage := false
+				hasCacheControl := false
+				// withCacheControl carries a prompt caching breakpoint over to the converted block
+				withCacheControl := func(contentPart string, part gjson.Result) string {
+					if cacheControl, ok := util.ClaudeCacheControl(part); ok {
+						contentPart, _ = sjson.Set(contentPart, "cache_control", cacheControl)
+						hasCacheControl = true
+					}
+					return contentPart
+				}
 				if parts := item.Get("content"); parts.Exists() && parts.IsArray() {
 					parts.ForEach(func(_, part gjson.Result) bool {
 						ptype := part.Get("type").String()
@@ -158,7 +168,7 @@ func ConvertOpenAIResponsesRequestToClaude(modelName string, inputRawJSON []byte
 								textAggregate.WriteString(txt)
 								contentPart := `{"type":"text","text":""}`
 								contentPart, _ = sjson.Set(contentPart, "text", txt)
-								partsJSON = append(partsJSON, contentPart)
+								partsJSON = append(partsJSON, withCacheControl(contentPart, part))
 							}
 							if ptype == "input_text" {
 								role = "user"
@@ -193,7 +203,7 @@ func ConvertOpenAIResponsesRequestToClaude(modelName string, inputRawJSON []byte
 									contentPart, _ = sjson.Set(contentPart, "source.url", url)
 								}
 								if contentPart != "" {
-									partsJSON = append(partsJSON, contentPart)
+									partsJSON = append(partsJSON, withCacheControl(contentPart, part))
 									if role == "" {
 										role = "user"
 									}
@@ -219,7 +229,7 @@ func ConvertOpenAIResponsesRequestToClaude(modelName string, inputRawJSON []byte
 				if len(partsJSON) > 0 {
 					msg := `{"role":"","content":[]}`
 					msg, _ = sjson.Set(msg, "role", role)
-					if len(partsJSON) == 1 && !hasImage {
+					if len(partsJSON) == 1 && !hasImage && !hasCacheControl {
 						// Preserve legacy behavior for single text content
 						msg, _ = sjson.Delete(msg, "content")
 						textPart := gjson.Parse(partsJSON[0])
@@ -264,6 +274,9 @@ func ConvertOpenAIResponsesRequestToClaude(modelName string, inputRawJSON []byte
 				toolResult := `{"type":"tool_result","tool_use_id":"","content":""}`
 				toolResult, _ = sjson.Set(toolResult, "tool_use_id", callID)
 				toolResult, _ = sjson.Set(toolResult, "content", outputStr)
+				if cacheControl, ok := util.ClaudeCacheControl(item); ok {
+					toolResult, _ = sjson.Set(toolResult, "cache_control", cacheControl)
+				}
 
 				usr := `{"role":"user","content":[]}`
 				usr, _ = sjson.SetRaw(usr, "content.-1", toolResult)
@@ -290,6 +303,9 @@ func ConvertOpenAIResponsesRequestToClaude(modelName string, inputRawJSON []byte
 			} else if params = tool.Get("parametersJsonSchema"); params.Exists() {
 				tJSON, _ = sjson.SetRaw(tJSON, "input_schema", params.Raw)
 			}
+			if cacheControl, ok := util.ClaudeCacheControl(tool); ok {
+				tJSON, _ = sjson.Set(tJSON, "cache_control", cacheControl)
+			}
 
 			toolsJSON, _ = sjson.SetRaw(toolsJSON, "-1", tJSON)
 			return true
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -32,8 +33,8 @@ type claudeToResponsesState struct {
 	ReasoningBuf       strings.Builder
 	ReasoningPartAdded bool
 	ReasoningIndex     int
-	// usage aggregation
-	InputTokens  int64
+	// usage aggregation; Prompt holds the input and prompt cache token counts
+	Prompt       util.ClaudePromptUsage
 	OutputTokens int64
 	UsageSeen    bool
 }
@@ -81,14 +82,14 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 			st.FuncArgsBuf = make(map[int]*strings.Builder)
 			st.FuncNames = make(map[int]string)
 			st.FuncCallIDs = make(map[int]string)
-			st.InputTokens = 0
+			st.Prompt = util.ClaudePromptUsage{}
 			st.OutputTokens = 0
 			st.UsageSeen = false
 			if usage := msg.Get("usage"); usage.Exists() {
 				if v := usage.Get("input_tokens"); v.Exists() {
-					st.InputTokens = v.Int()
 					st.UsageSeen = true
 				}
+				st.Prompt.Merge(usage)
 				if v := usage.Get("output_tokens"); v.Exists() {
 					st.OutputTokens = v.Int()
 					st.UsageSeen = true
@@ -267,9 +268,9 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 				st.UsageSeen = true
 			}
 			if v := usage.Get("input_tokens"); v.Exists() {
-				st.InputTokens = v.Int()
 				st.UsageSeen = true
 			}
+			st.Prompt.Merge(usage)
 		}
 	case "message_stop":
 
@@ -416,13 +417,13 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 		}
 		usagePresent := st.UsageSeen || reasoningTokens > 0
 		if usagePresent {
-			completed, _ = sjson.Set(completed, "response.usage.input_tokens", st.InputTokens)
-			completed, _ = sjson.Set(completed, "response.usage.input_tokens_details.cached_tokens", 0)
+			completed, _ = sjson.Set(completed, "response.usage.input_tokens", st.Prompt.PromptTokens())
+			completed = st.Prompt.SetDetails(completed, "response.usage.input_tokens_details")
 			completed, _ = sjson.Set(completed, "response.usage.output_tokens", st.OutputTokens)
 			if reasoningTokens > 0 {
 				completed, _ = sjson.Set(completed, "response.usage.output_tokens_details.reasoning_tokens", reasoningTokens)
 			}
-			total := st.InputTokens + st.OutputTokens
+			total := st.Prompt.PromptTokens() + st.OutputTokens
 			if total > 0 || st.UsageSeen {
 				completed, _ = sjson.Set(completed, "response.usage.total_tokens", total)
 			}
@@ -469,7 +470,7 @@ func ConvertClaudeResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 		reasoningBuf    strings.Builder
 		reasoningActive bool
 		reasoningItemID string
-		inputTokens     int64
+		prompt          util.ClaudePromptUsage
 		outputTokens    int64
 	)
 
@@ -491,9 +492,7 @@ func ConvertClaudeResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 			if msg := root.Get("message"); msg.Exists() {
 				responseID = msg.Get("id").String()
 				createdAt = time.Now().Unix()
-				if usage := msg.Get("usage"); usage.Exists() {
-					inputTokens = usage.Get("input_tokens").Int()
-				}
+				prompt.Merge(msg.Get("usage"))
 			}
 
 		case "content_block_start":
@@ -553,6 +552,7 @@ func ConvertClaudeResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 
 		case "message_delta":
 			if usage := root.Get("usage"); usage.Exists() {
+				prompt.Merge(usage)
 				outputTokens = usage.Get("output_tokens").Int()
 			}
 		}
@@ -684,8 +684,9 @@ func ConvertClaudeResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 	}
 
 	// Usage
-	total := inputTokens + outputTokens
-	out, _ = sjson.Set(out, "usage.input_tokens", inputTokens)
+	total := prompt.PromptTokens() + outputTokens
+	out, _ = sjson.Set(out, "usage.input_tokens", prompt.PromptTokens())
+	out = prompt.SetDetails(out, "usage.input_tokens_details")
 	out, _ = sjson.Set(out, "usage.output_tokens", outputTokens)
 	out, _ = sjson.Set(out, "usage.total_tokens", total)
 	if reasoningBuf.Len() > 0 {
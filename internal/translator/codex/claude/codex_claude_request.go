@@ -12,6 +12,7 @@ import (
 	"strings"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -134,6 +135,8 @@ func ConvertClaudeRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 								}
 								dataURL := fmt.Sprintf("data:%s;base64,%s", mediaType, data)
 								appendImageContent(dataURL)
+							} else if url := sourceResult.Get("url").String(); sourceResult.Get("type").String() == "url" && url != "" {
+								appendImageContent(url)
 							}
 						}
 					case "tool_use":
@@ -215,11 +218,19 @@ func ConvertClaudeRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 	// Add additional configuration parameters for the Codex API.
 	template, _ = sjson.Set(template, "parallel_tool_calls", true)
 	template, _ = sjson.Set(template, "reasoning.effort", "low")
+	if effort := util.ClaudeReasoningEffort(rootResult.Get("thinking")); effort != "" {
+		template, _ = sjson.Set(template, "reasoning.effort", effort)
+	}
 	template, _ = sjson.Set(template, "reasoning.summary", "auto")
 	template, _ = sjson.Set(template, "stream", true)
 	template, _ = sjson.Set(template, "store", false)
 	template, _ = sjson.Set(template, "include", []string{"reasoning.encrypted_content"})
 
+	// Structured output: output_format -> text.format
+	if format, ok := util.ClaudeOutputFormat(rootResult.Get("output_format")); ok {
+		template, _ = sjson.SetRaw(template, "text.format", format.ResponsesText())
+	}
+
 	// Add a first message to ignore system instructions and ensure proper execution.
 	inputResult := gjson.Get(template, "input")
 	if inputResult.Exists() && inputResult.IsArray() {
@@ -37,7 +37,7 @@ import (
 // Returns:
 //   - []byte: The transformed request data in Codex API format
 func ConvertGeminiRequestToCodex(modelName string, inputRawJSON []byte, _ bool) []byte {
-	rawJSON := bytes.Clone(inputRawJSON)
+	rawJSON := util.NormalizeGeminiRequest(bytes.Clone(inputRawJSON))
 	// Base template
 	out := `{"model":"","instructions":"","input":[]}`
 
@@ -90,7 +90,7 @@ func ConvertGeminiRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 	out, _ = sjson.Set(out, "model", modelName)
 
 	// System instruction -> as a user message with input_text parts
-	sysParts := root.Get("system_instruction.parts")
+	sysParts := root.Get("systemInstruction.parts")
 	if sysParts.IsArray() {
 		msg := `{"type":"message","role":"user","content":[]}`
 		arr := sysParts.Array()
@@ -142,6 +142,21 @@ func ConvertGeminiRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 					continue
 				}
 
+				// inline image from user
+				if inline := p.Get("inlineData"); inline.Exists() && role != "assistant" {
+					mimeType := inline.Get("mimeType").String()
+					if mimeType == "" {
+						mimeType = "application/octet-stream"
+					}
+					msg := `{"type":"message","role":"","content":[]}`
+					msg, _ = sjson.Set(msg, "role", role)
+					part := `{"type":"input_image"}`
+					part, _ = sjson.Set(part, "image_url", "data:"+mimeType+";base64,"+inline.Get("data").String())
+					msg, _ = sjson.SetRaw(msg, "content.-1", part)
+					out, _ = sjson.SetRaw(out, "input.-1", msg)
+					continue
+				}
+
 				// function call from model
 				if fc := p.Get("functionCall"); fc.Exists() {
 					fn := `{"type":"function_call"}`
@@ -246,11 +261,19 @@ func ConvertGeminiRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 	// Fixed flags aligning with Codex expectations
 	out, _ = sjson.Set(out, "parallel_tool_calls", true)
 	out, _ = sjson.Set(out, "reasoning.effort", "low")
+	if effort := util.GeminiReasoningEffort(root.Get("generationConfig.thinkingConfig")); effort != "" {
+		out, _ = sjson.Set(out, "reasoning.effort", effort)
+	}
 	out, _ = sjson.Set(out, "reasoning.summary", "auto")
 	out, _ = sjson.Set(out, "stream", true)
 	out, _ = sjson.Set(out, "store", false)
 	out, _ = sjson.Set(out, "include", []string{"reasoning.encrypted_content"})
 
+	// Structured output: responseMimeType + schema -> text.format
+	if format, ok := util.GeminiResponseFormat(root.Get("generationConfig")); ok {
+		out, _ = sjson.SetRaw(out, "text.format", format.ResponsesText())
+	}
+
 	var pathsToLower []string
 	toolsResult := gjson.Get(out, "tools")
 	util.Walk(toolsResult, "", "type", &pathsToLower)
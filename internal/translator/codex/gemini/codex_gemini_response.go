@@ -126,6 +126,7 @@ func ConvertCodexResponseToGemini(_ context.Context, modelName string, originalR
 		template, _ = sjson.Set(template, "usageMetadata.candidatesTokenCount", rootResult.Get("response.usage.output_tokens").Int())
 		totalTokens := rootResult.Get("response.usage.input_tokens").Int() + rootResult.Get("response.usage.output_tokens").Int()
 		template, _ = sjson.Set(template, "usageMetadata.totalTokenCount", totalTokens)
+		template = setThoughtsTokenCount(template, rootResult.Get("response.usage"))
 	} else {
 		return []string{}
 	}
@@ -187,6 +188,7 @@ func ConvertCodexResponseToGeminiNonStream(_ context.Context, modelName string,
 			template, _ = sjson.Set(template, "usageMetadata.promptTokenCount", inputTokens)
 			template, _ = sjson.Set(template, "usageMetadata.candidatesTokenCount", outputTokens)
 			template, _ = sjson.Set(template, "usageMetadata.totalTokenCount", totalTokens)
+			template = setThoughtsTokenCount(template, usage)
 		}
 
 		// Process output content to build parts array
@@ -335,3 +337,16 @@ func mustMarshalJSON(v interface{}) string {
 func GeminiTokenCount(ctx context.Context, count int64) string {
 	return fmt.Sprintf(`{"totalTokens":%d,"promptTokensDetails":[{"modality":"TEXT","tokenCount":%d}]}`, count, count)
 }
+
+// setThoughtsTokenCount moves the reasoning tokens Codex counts within output_tokens to
+// thoughtsTokenCount, which Gemini reports apart from candidatesTokenCount.
+func setThoughtsTokenCount(template string, usage gjson.Result) string {
+	reasoningTokens := usage.Get("output_tokens_details.reasoning_tokens").Int()
+	outputTokens := usage.Get("output_tokens").Int()
+	if reasoningTokens <= 0 || reasoningTokens > outputTokens {
+		return template
+	}
+	template, _ = sjson.Set(template, "usageMetadata.candidatesTokenCount", outputTokens-reasoningTokens)
+	template, _ = sjson.Set(template, "usageMetadata.thoughtsTokenCount", reasoningTokens)
+	return template
+}
@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	client "github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -62,6 +63,15 @@ func ConvertClaudeRequestToCLI(modelName string, inputRawJSON []byte, _ bool) []
 	messagesResult := gjson.GetBytes(rawJSON, "messages")
 	if messagesResult.IsArray() {
 		messageResults := messagesResult.Array()
+		// tool_use id -> name, so tool results can name the function they answer
+		toolUseNames := map[string]string{}
+		for _, messageResult := range messageResults {
+			for _, contentResult := range messageResult.Get("content").Array() {
+				if contentResult.Get("type").String() == "tool_use" {
+					toolUseNames[contentResult.Get("id").String()] = contentResult.Get("name").String()
+				}
+			}
+		}
 		for i := 0; i < len(messageResults); i++ {
 			messageResult := messageResults[i]
 			roleResult := messageResult.Get("role")
@@ -82,9 +92,13 @@ func ConvertClaudeRequestToCLI(modelName string, inputRawJSON []byte, _ bool) []
 					if contentTypeResult.Type == gjson.String && contentTypeResult.String() == "text" {
 						prompt := contentResult.Get("text").String()
 						clientContent.Parts = append(clientContent.Parts, client.Part{Text: prompt})
+					} else if contentTypeResult.Type == gjson.String && (contentTypeResult.String() == "image" || contentTypeResult.String() == "document") {
+						if mimeType, data, ok := util.ClaudeMediaSource(contentResult.Get("source")); ok {
+							clientContent.Parts = append(clientContent.Parts, client.Part{InlineData: &client.InlineData{MimeType: mimeType, Data: data}})
+						}
 					} else if contentTypeResult.Type == gjson.String && contentTypeResult.String() == "tool_use" {
 						functionName := contentResult.Get("name").String()
-						functionArgs := contentResult.Get("input").String()
+						functionArgs := util.ToolCallArgs(contentResult.Get("input").Raw)
 						var args map[string]any
 						if err := json.Unmarshal([]byte(functionArgs), &args); err == nil {
 							clientContent.Parts = append(clientContent.Parts, client.Part{FunctionCall: &client.FunctionCall{Name: functionName, Args: args}})
@@ -92,12 +106,16 @@ func ConvertClaudeRequestToCLI(modelName string, inputRawJSON []byte, _ bool) []
 					} else if contentTypeResult.Type == gjson.String && contentTypeResult.String() == "tool_result" {
 						toolCallID := contentResult.Get("tool_use_id").String()
 						if toolCallID != "" {
-							funcName := toolCallID
-							toolCallIDs := strings.Split(toolCallID, "-")
-							if len(toolCallIDs) > 1 {
-								funcName = strings.Join(toolCallIDs[0:len(toolCallIDs)-1], "-")
+							funcName, found := toolUseNames[toolCallID]
+							if !found {
+								// IDs issued for Gemini function calls have the form "<name>-<timestamp>".
+								funcName = toolCallID
+								toolCallIDs := strings.Split(toolCallID, "-")
+								if len(toolCallIDs) > 1 {
+									funcName = strings.Join(toolCallIDs[0:len(toolCallIDs)-1], "-")
+								}
 							}
-							responseData := contentResult.Get("content").String()
+							responseData := util.ToolResultText(contentResult.Get("content"))
 							functionResponse := client.FunctionResponse{Name: funcName, Response: map[string]interface{}{"result": responseData}}
 							clientContent.Parts = append(clientContent.Parts, client.Part{FunctionResponse: &functionResponse})
 						}
@@ -149,6 +167,9 @@ func ConvertClaudeRequestToCLI(modelName string, inputRawJSON []byte, _ bool) []
 	if len(tools) > 0 && len(tools[0].FunctionDeclarations) > 0 {
 		b, _ := json.Marshal(tools)
 		out, _ = sjson.SetRaw(out, "request.tools", string(b))
+		if fcc := util.GeminiFunctionCallingConfig(gjson.GetBytes(rawJSON, "tool_choice")); fcc != "" {
+			out, _ = sjson.SetRaw(out, "request.toolConfig.functionCallingConfig", fcc)
+		}
 	}
 
 	// Map reasoning and sampling configs
@@ -177,5 +198,10 @@ func ConvertClaudeRequestToCLI(modelName string, inputRawJSON []byte, _ bool) []
 		out, _ = sjson.Set(out, "request.generationConfig.topK", v.Num)
 	}
 
+	// Structured output: output_format -> responseMimeType + responseJsonSchema
+	if format, ok := util.ClaudeOutputFormat(gjson.GetBytes(rawJSON, "output_format")); ok {
+		return format.ApplyGemini([]byte(out), "request.generationConfig")
+	}
+
 	return []byte(out)
 }
@@ -4,6 +4,7 @@ package chat_completions
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"strings"
 
@@ -90,6 +91,11 @@ func ConvertOpenAIRequestToGeminiCLI(modelName string, inputRawJSON []byte, _ bo
 		}
 	}
 
+	// Structured output: response_format -> responseMimeType + responseJsonSchema
+	if format, ok := util.OpenAIResponseFormat(gjson.GetBytes(rawJSON, "response_format")); ok {
+		out = format.ApplyGemini(out, "request.generationConfig")
+	}
+
 	// messages -> systemInstruction + contents
 	messages := gjson.GetBytes(rawJSON, "messages")
 	if messages.IsArray() {
@@ -122,12 +128,7 @@ func ConvertOpenAIRequestToGeminiCLI(modelName string, inputRawJSON []byte, _ bo
 			if role == "tool" {
 				toolCallID := m.Get("tool_call_id").String()
 				if toolCallID != "" {
-					c := m.Get("content")
-					if c.Type == gjson.String {
-						toolResponses[toolCallID] = c.String()
-					} else if c.IsObject() && c.Get("type").String() == "text" {
-						toolResponses[toolCallID] = c.Get("text").String()
-					}
+					toolResponses[toolCallID] = util.ToolResultText(m.Get("content"))
 				}
 			}
 		}
@@ -160,16 +161,11 @@ func ConvertOpenAIRequestToGeminiCLI(modelName string, inputRawJSON []byte, _ bo
 							node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".text", item.Get("text").String())
 							p++
 						case "image_url":
-							imageURL := item.Get("image_url.url").String()
-							if len(imageURL) > 5 {
-								pieces := strings.SplitN(imageURL[5:], ";", 2)
-								if len(pieces) == 2 && len(pieces[1]) > 7 {
-									mime := pieces[0]
-									data := pieces[1][7:]
-									node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.mime_type", mime)
-									node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.data", data)
-									p++
-								}
+							// Gemini only takes inline data, so remote URLs are fetched and base64 encoded
+							if mime, data, ok := util.InlineMedia(item.Get("image_url.url").String()); ok {
+								node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.mime_type", mime)
+								node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.data", data)
+								p++
 							}
 						case "file":
 							filename := item.Get("file.filename").String()
@@ -178,7 +174,15 @@ func ConvertOpenAIRequestToGeminiCLI(modelName string, inputRawJSON []byte, _ bo
 							if sp := strings.Split(filename, "."); len(sp) > 1 {
 								ext = sp[len(sp)-1]
 							}
-							if mimeType, ok := misc.MimeTypes[ext]; ok {
+							if mimeType, data, ok := util.ParseDataURL(fileData); ok {
+								// file_data is usually a data URL; keep only its payload
+								if known, found := misc.MimeTypes[ext]; found && mimeType == "application/octet-stream" {
+									mimeType = known
+								}
+								node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.mime_type", mimeType)
+								node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.data", data)
+								p++
+							} else if mimeType, ok := misc.MimeTypes[ext]; ok {
 								node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.mime_type", mimeType)
 								node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.data", fileData)
 								p++
@@ -190,53 +194,69 @@ func ConvertOpenAIRequestToGeminiCLI(modelName string, inputRawJSON []byte, _ bo
 				}
 				out, _ = sjson.SetRawBytes(out, "request.contents.-1", node)
 			} else if role == "assistant" {
+				// Assistant text, images and tool calls -> single model content
+				node := []byte(`{"role":"model","parts":[]}`)
+				p := 0
+				tcs := m.Get("tool_calls")
 				if content.Type == gjson.String {
-					// Assistant text -> single model content
-					node := []byte(`{"role":"model","parts":[{"text":""}]}`)
-					node, _ = sjson.SetBytes(node, "parts.0.text", content.String())
-					out, _ = sjson.SetRawBytes(out, "request.contents.-1", node)
-				} else if !content.Exists() || content.Type == gjson.Null {
-					// Tool calls -> single model content with functionCall parts
-					tcs := m.Get("tool_calls")
-					if tcs.IsArray() {
-						node := []byte(`{"role":"model","parts":[]}`)
-						p := 0
-						fIDs := make([]string, 0)
-						for _, tc := range tcs.Array() {
-							if tc.Get("type").String() != "function" {
-								continue
-							}
-							fid := tc.Get("id").String()
-							fname := tc.Get("function.name").String()
-							fargs := tc.Get("function.arguments").String()
-							node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".functionCall.name", fname)
-							node, _ = sjson.SetRawBytes(node, "parts."+itoa(p)+".functionCall.args", []byte(fargs))
+					if content.String() != "" || !tcs.IsArray() {
+						node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".text", content.String())
+						p++
+					}
+				} else if content.IsArray() {
+					for _, item := range content.Array() {
+						switch item.Get("type").String() {
+						case "text":
+							node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".text", item.Get("text").String())
 							p++
-							if fid != "" {
-								fIDs = append(fIDs, fid)
+						case "image_url":
+							// If the assistant returned an inline data URL, preserve it for history fidelity.
+							if mime, data, ok := util.ParseDataURL(item.Get("image_url.url").String()); ok {
+								node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.mime_type", mime)
+								node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.data", data)
+								p++
 							}
 						}
-						out, _ = sjson.SetRawBytes(out, "request.contents.-1", node)
+					}
+				}
 
-						// Append a single tool content combining name + response per function
-						toolNode := []byte(`{"role":"tool","parts":[]}`)
-						pp := 0
-						for _, fid := range fIDs {
-							if name, ok := tcID2Name[fid]; ok {
-								toolNode, _ = sjson.SetBytes(toolNode, "parts."+itoa(pp)+".functionResponse.name", name)
-								resp := toolResponses[fid]
-								if resp == "" {
-									resp = "{}"
-								}
-								toolNode, _ = sjson.SetRawBytes(toolNode, "parts."+itoa(pp)+".functionResponse.response", []byte(`{"result":`+quoteIfNeeded(resp)+`}`))
-								pp++
-							}
-						}
-						if pp > 0 {
-							out, _ = sjson.SetRawBytes(out, "request.contents.-1", toolNode)
+				// Tool calls -> functionCall parts; arguments that are not a JSON object are kept rather than dropped
+				fIDs := make([]string, 0)
+				for _, tc := range tcs.Array() {
+					if tc.Get("type").String() != "function" {
+						continue
+					}
+					fid := tc.Get("id").String()
+					fname := tc.Get("function.name").String()
+					fargs := util.ToolCallArgs(tc.Get("function.arguments").String())
+					node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".functionCall.name", fname)
+					node, _ = sjson.SetRawBytes(node, "parts."+itoa(p)+".functionCall.args", []byte(fargs))
+					p++
+					if fid != "" {
+						fIDs = append(fIDs, fid)
+					}
+				}
+				if p > 0 {
+					out, _ = sjson.SetRawBytes(out, "request.contents.-1", node)
+				}
+
+				// Append a single tool content combining name + response per function
+				toolNode := []byte(`{"role":"tool","parts":[]}`)
+				pp := 0
+				for _, fid := range fIDs {
+					if name, ok := tcID2Name[fid]; ok {
+						toolNode, _ = sjson.SetBytes(toolNode, "parts."+itoa(pp)+".functionResponse.name", name)
+						resp := toolResponses[fid]
+						if resp == "" {
+							resp = "{}"
 						}
+						toolNode, _ = sjson.SetRawBytes(toolNode, "parts."+itoa(pp)+".functionResponse.response", []byte(`{"result":`+quoteIfNeeded(resp)+`}`))
+						pp++
 					}
 				}
+				if pp > 0 {
+					out, _ = sjson.SetRawBytes(out, "request.contents.-1", toolNode)
+				}
 			}
 		}
 	}
@@ -283,6 +303,13 @@ func ConvertOpenAIRequestToGeminiCLI(modelName string, inputRawJSON []byte, _ bo
 		}
 	}
 
+	// tool_choice -> toolConfig.functionCallingConfig
+	if gjson.GetBytes(out, "request.tools").Exists() {
+		if fcc := util.GeminiFunctionCallingConfig(gjson.GetBytes(rawJSON, "tool_choice")); fcc != "" {
+			out, _ = sjson.SetRawBytes(out, "request.toolConfig.functionCallingConfig", []byte(fcc))
+		}
+	}
+
 	return out
 }
 
@@ -295,11 +322,9 @@ func quoteIfNeeded(s string) string {
 	if s == "" {
 		return "\"\""
 	}
-	if len(s) > 0 && (s[0] == '{' || s[0] == '[') {
+	if (s[0] == '{' || s[0] == '[') && gjson.Valid(s) {
 		return s
 	}
-	// escape quotes minimally
-	s = strings.ReplaceAll(s, "\\", "\\\\")
-	s = strings.ReplaceAll(s, "\"", "\\\"")
-	return "\"" + s + "\""
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
 }
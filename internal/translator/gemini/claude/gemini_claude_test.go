@@ -0,0 +1,28 @@
+package claude
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/translatortest"
+)
+
+// functionCallID matches the tool call ids generated for Gemini function calls.
+var functionCallID = regexp.MustCompile(`"id":"([^"]+-[0-9]+)"`)
+
+func TestConvertClaudeRequestToGemini(t *testing.T) {
+	translatortest.Run(t, "request_", func(input []byte) []byte {
+		return ConvertClaudeRequestToGemini("gemini-2.5-pro", input, true)
+	})
+}
+
+func TestConvertGeminiResponseToClaude(t *testing.T) {
+	translatortest.Run(t, "stream_", func(input []byte) []byte {
+		var param any
+		request := []byte(`{"stream":true}`)
+		return translatortest.Stream(input, func(chunk []byte) []string {
+			return ConvertGeminiResponseToClaude(context.Background(), "gemini-2.5-pro", request, request, chunk, &param)
+		})
+	}, functionCallID)
+}
@@ -4,6 +4,7 @@ package chat_completions
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"strings"
 
@@ -90,6 +91,11 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 		}
 	}
 
+	// Structured output: response_format -> responseMimeType + responseJsonSchema
+	if format, ok := util.OpenAIResponseFormat(gjson.GetBytes(rawJSON, "response_format")); ok {
+		out = format.ApplyGemini(out, "generationConfig")
+	}
+
 	// messages -> systemInstruction + contents
 	messages := gjson.GetBytes(rawJSON, "messages")
 	if messages.IsArray() {
@@ -122,12 +128,7 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 			if role == "tool" {
 				toolCallID := m.Get("tool_call_id").String()
 				if toolCallID != "" {
-					c := m.Get("content")
-					if c.Type == gjson.String {
-						toolResponses[toolCallID] = c.String()
-					} else if c.IsObject() && c.Get("type").String() == "text" {
-						toolResponses[toolCallID] = c.Get("text").String()
-					}
+					toolResponses[toolCallID] = util.ToolResultText(m.Get("content"))
 				}
 			}
 		}
@@ -160,16 +161,11 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 							node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".text", item.Get("text").String())
 							p++
 						case "image_url":
-							imageURL := item.Get("image_url.url").String()
-							if len(imageURL) > 5 {
-								pieces := strings.SplitN(imageURL[5:], ";", 2)
-								if len(pieces) == 2 && len(pieces[1]) > 7 {
-									mime := pieces[0]
-									data := pieces[1][7:]
-									node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.mime_type", mime)
-									node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.data", data)
-									p++
-								}
+							// Gemini only takes inline data, so remote URLs are fetched and base64 encoded
+							if mime, data, ok := util.InlineMedia(item.Get("image_url.url").String()); ok {
+								node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.mime_type", mime)
+								node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.data", data)
+								p++
 							}
 						case "file":
 							filename := item.Get("file.filename").String()
@@ -178,7 +174,15 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 							if sp := strings.Split(filename, "."); len(sp) > 1 {
 								ext = sp[len(sp)-1]
 							}
-							if mimeType, ok := misc.MimeTypes[ext]; ok {
+							if mimeType, data, ok := util.ParseDataURL(fileData); ok {
+								// file_data is usually a data URL; keep only its payload
+								if known, found := misc.MimeTypes[ext]; found && mimeType == "application/octet-stream" {
+									mimeType = known
+								}
+								node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.mime_type", mimeType)
+								node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.data", data)
+								p++
+							} else if mimeType, ok := misc.MimeTypes[ext]; ok {
 								node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.mime_type", mimeType)
 								node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.data", fileData)
 								p++
@@ -190,15 +194,16 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 				}
 				out, _ = sjson.SetRawBytes(out, "contents.-1", node)
 			} else if role == "assistant" {
+				// Assistant text, images and tool calls -> single model content
+				node := []byte(`{"role":"model","parts":[]}`)
+				p := 0
+				tcs := m.Get("tool_calls")
 				if content.Type == gjson.String {
-					// Assistant text -> single model content
-					node := []byte(`{"role":"model","parts":[{"text":""}]}`)
-					node, _ = sjson.SetBytes(node, "parts.0.text", content.String())
-					out, _ = sjson.SetRawBytes(out, "contents.-1", node)
+					if content.String() != "" || !tcs.IsArray() {
+						node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".text", content.String())
+						p++
+					}
 				} else if content.IsArray() {
-					// Assistant multimodal content (e.g. text + image) -> single model content with parts
-					node := []byte(`{"role":"model","parts":[]}`)
-					p := 0
 					for _, item := range content.Array() {
 						switch item.Get("type").String() {
 						case "text":
@@ -206,62 +211,52 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 							p++
 						case "image_url":
 							// If the assistant returned an inline data URL, preserve it for history fidelity.
-							imageURL := item.Get("image_url.url").String()
-							if len(imageURL) > 5 { // expect data:...
-								pieces := strings.SplitN(imageURL[5:], ";", 2)
-								if len(pieces) == 2 && len(pieces[1]) > 7 {
-									mime := pieces[0]
-									data := pieces[1][7:]
-									node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.mime_type", mime)
-									node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.data", data)
-									p++
-								}
+							if mime, data, ok := util.ParseDataURL(item.Get("image_url.url").String()); ok {
+								node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.mime_type", mime)
+								node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.data", data)
+								p++
 							}
 						}
 					}
+				}
+
+				// Tool calls -> functionCall parts; arguments that are not a JSON object are kept rather than dropped
+				fIDs := make([]string, 0)
+				for _, tc := range tcs.Array() {
+					if tc.Get("type").String() != "function" {
+						continue
+					}
+					fid := tc.Get("id").String()
+					fname := tc.Get("function.name").String()
+					fargs := util.ToolCallArgs(tc.Get("function.arguments").String())
+					node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".functionCall.name", fname)
+					node, _ = sjson.SetRawBytes(node, "parts."+itoa(p)+".functionCall.args", []byte(fargs))
+					p++
+					if fid != "" {
+						fIDs = append(fIDs, fid)
+					}
+				}
+				if p > 0 {
 					out, _ = sjson.SetRawBytes(out, "contents.-1", node)
-				} else if !content.Exists() || content.Type == gjson.Null {
-					// Tool calls -> single model content with functionCall parts
-					tcs := m.Get("tool_calls")
-					if tcs.IsArray() {
-						node := []byte(`{"role":"model","parts":[]}`)
-						p := 0
-						fIDs := make([]string, 0)
-						for _, tc := range tcs.Array() {
-							if tc.Get("type").String() != "function" {
-								continue
-							}
-							fid := tc.Get("id").String()
-							fname := tc.Get("function.name").String()
-							fargs := tc.Get("function.arguments").String()
-							node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".functionCall.name", fname)
-							node, _ = sjson.SetRawBytes(node, "parts."+itoa(p)+".functionCall.args", []byte(fargs))
-							p++
-							if fid != "" {
-								fIDs = append(fIDs, fid)
-							}
-						}
-						out, _ = sjson.SetRawBytes(out, "contents.-1", node)
+				}
 
-						// Append a single tool content combining name + response per function
-						toolNode := []byte(`{"role":"tool","parts":[]}`)
-						pp := 0
-						for _, fid := range fIDs {
-							if name, ok := tcID2Name[fid]; ok {
-								toolNode, _ = sjson.SetBytes(toolNode, "parts."+itoa(pp)+".functionResponse.name", name)
-								resp := toolResponses[fid]
-								if resp == "" {
-									resp = "{}"
-								}
-								toolNode, _ = sjson.SetRawBytes(toolNode, "parts."+itoa(pp)+".functionResponse.response", []byte(`{"result":`+quoteIfNeeded(resp)+`}`))
-								pp++
-							}
-						}
-						if pp > 0 {
-							out, _ = sjson.SetRawBytes(out, "contents.-1", toolNode)
+				// Append a single tool content combining name + response per function
+				toolNode := []byte(`{"role":"tool","parts":[]}`)
+				pp := 0
+				for _, fid := range fIDs {
+					if name, ok := tcID2Name[fid]; ok {
+						toolNode, _ = sjson.SetBytes(toolNode, "parts."+itoa(pp)+".functionResponse.name", name)
+						resp := toolResponses[fid]
+						if resp == "" {
+							resp = "{}"
 						}
+						toolNode, _ = sjson.SetRawBytes(toolNode, "parts."+itoa(pp)+".functionResponse.response", []byte(`{"result":`+quoteIfNeeded(resp)+`}`))
+						pp++
 					}
 				}
+				if pp > 0 {
+					out, _ = sjson.SetRawBytes(out, "contents.-1", toolNode)
+				}
 			}
 		}
 	}
@@ -282,6 +277,13 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 		}
 	}
 
+	// tool_choice -> toolConfig.functionCallingConfig
+	if gjson.GetBytes(out, "tools").Exists() {
+		if fcc := util.GeminiFunctionCallingConfig(gjson.GetBytes(rawJSON, "tool_choice")); fcc != "" {
+			out, _ = sjson.SetRawBytes(out, "toolConfig.functionCallingConfig", []byte(fcc))
+		}
+	}
+
 	return out
 }
 
@@ -294,11 +296,9 @@ func quoteIfNeeded(s string) string {
 	if s == "" {
 		return "\"\""
 	}
-	if len(s) > 0 && (s[0] == '{' || s[0] == '[') {
+	if (s[0] == '{' || s[0] == '[') && gjson.Valid(s) {
 		return s
 	}
-	// escape quotes minimally
-	s = strings.ReplaceAll(s, "\\", "\\\\")
-	s = strings.ReplaceAll(s, "\"", "\\\"")
-	return "\"" + s + "\""
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
 }
@@ -0,0 +1,28 @@
+package chat_completions
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/translatortest"
+)
+
+// functionCallID matches the tool call ids generated for Gemini function calls.
+var functionCallID = regexp.MustCompile(`"id":"([^"]+-[0-9]+)"`)
+
+func TestConvertOpenAIRequestToGemini(t *testing.T) {
+	translatortest.Run(t, "request_", func(input []byte) []byte {
+		return ConvertOpenAIRequestToGemini("gemini-2.5-pro", input, true)
+	})
+}
+
+func TestConvertGeminiResponseToOpenAI(t *testing.T) {
+	translatortest.Run(t, "stream_", func(input []byte) []byte {
+		var param any
+		request := []byte(`{"stream":true}`)
+		return translatortest.Stream(input, func(chunk []byte) []string {
+			return ConvertGeminiResponseToOpenAI(context.Background(), "gemini-2.5-pro", request, request, chunk, &param)
+		})
+	}, functionCallID)
+}
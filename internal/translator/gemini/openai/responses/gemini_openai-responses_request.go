@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"strings"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -261,5 +262,10 @@ func ConvertOpenAIResponsesRequestToGemini(modelName string, inputRawJSON []byte
 			out, _ = sjson.Set(out, "generationConfig.thinkingConfig.thinkingBudget", -1)
 		}
 	}
+
+	// Structured output: text.format -> responseMimeType + responseJsonSchema
+	if format, ok := util.OpenAIResponsesTextFormat(root.Get("text.format")); ok {
+		return format.ApplyGemini([]byte(out), "generationConfig")
+	}
 	return []byte(out)
 }
@@ -425,15 +425,11 @@ func ConvertGeminiResponseToOpenAIResponses(_ context.Context, modelName string,
 
 		// usage mapping
 		if um := root.Get("usageMetadata"); um.Exists() {
-			// input tokens = prompt + thoughts
-			input := um.Get("promptTokenCount").Int() + um.Get("thoughtsTokenCount").Int()
-			completed, _ = sjson.Set(completed, "response.usage.input_tokens", input)
+			completed, _ = sjson.Set(completed, "response.usage.input_tokens", um.Get("promptTokenCount").Int())
 			// cached_tokens not provided by Gemini; default to 0 for structure compatibility
 			completed, _ = sjson.Set(completed, "response.usage.input_tokens_details.cached_tokens", 0)
-			// output tokens
-			if v := um.Get("candidatesTokenCount"); v.Exists() {
-				completed, _ = sjson.Set(completed, "response.usage.output_tokens", v.Int())
-			}
+			// output tokens include reasoning tokens, which Gemini reports apart
+			completed, _ = sjson.Set(completed, "response.usage.output_tokens", um.Get("candidatesTokenCount").Int()+um.Get("thoughtsTokenCount").Int())
 			if v := um.Get("thoughtsTokenCount"); v.Exists() {
 				completed, _ = sjson.Set(completed, "response.usage.output_tokens_details.reasoning_tokens", v.Int())
 			}
@@ -632,15 +628,11 @@ func ConvertGeminiResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 
 	// usage mapping
 	if um := root.Get("usageMetadata"); um.Exists() {
-		// input tokens = prompt + thoughts
-		input := um.Get("promptTokenCount").Int() + um.Get("thoughtsTokenCount").Int()
-		resp, _ = sjson.Set(resp, "usage.input_tokens", input)
+		resp, _ = sjson.Set(resp, "usage.input_tokens", um.Get("promptTokenCount").Int())
 		// cached_tokens not provided by Gemini; default to 0 for structure compatibility
 		resp, _ = sjson.Set(resp, "usage.input_tokens_details.cached_tokens", 0)
-		// output tokens
-		if v := um.Get("candidatesTokenCount"); v.Exists() {
-			resp, _ = sjson.Set(resp, "usage.output_tokens", v.Int())
-		}
+		// output tokens include reasoning tokens, which Gemini reports apart
+		resp, _ = sjson.Set(resp, "usage.output_tokens", um.Get("candidatesTokenCount").Int()+um.Get("thoughtsTokenCount").Int())
 		if v := um.Get("thoughtsTokenCount"); v.Exists() {
 			resp, _ = sjson.Set(resp, "usage.output_tokens_details.reasoning_tokens", v.Int())
 		}
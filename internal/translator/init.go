@@ -1,6 +1,8 @@
 package translator
 
 import (
+	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/bedrock/openai/chat-completions"
+
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/claude/gemini"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/claude/gemini-cli"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/claude/openai/chat-completions"
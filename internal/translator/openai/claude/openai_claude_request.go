@@ -8,7 +8,9 @@ package claude
 import (
 	"bytes"
 	"encoding/json"
+	"strings"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -31,6 +33,11 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 		out, _ = sjson.Set(out, "max_tokens", maxTokens.Int())
 	}
 
+	// Extended thinking budget -> reasoning_effort
+	if effort := util.ClaudeReasoningEffort(root.Get("thinking")); effort != "" {
+		out, _ = sjson.Set(out, "reasoning_effort", effort)
+	}
+
 	// Temperature
 	if temp := root.Get("temperature"); temp.Exists() {
 		out, _ = sjson.Set(out, "temperature", temp.Float())
@@ -113,16 +120,8 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 						toolCallJSON, _ = sjson.Set(toolCallJSON, "id", part.Get("id").String())
 						toolCallJSON, _ = sjson.Set(toolCallJSON, "function.name", part.Get("name").String())
 
-						// Convert input to arguments JSON string
-						if input := part.Get("input"); input.Exists() {
-							if inputJSON, err := json.Marshal(input.Value()); err == nil {
-								toolCallJSON, _ = sjson.Set(toolCallJSON, "function.arguments", string(inputJSON))
-							} else {
-								toolCallJSON, _ = sjson.Set(toolCallJSON, "function.arguments", "{}")
-							}
-						} else {
-							toolCallJSON, _ = sjson.Set(toolCallJSON, "function.arguments", "{}")
-						}
+						// Convert input to arguments JSON string, keeping the raw JSON so numbers keep their precision
+						toolCallJSON, _ = sjson.Set(toolCallJSON, "function.arguments", util.ToolCallArgs(part.Get("input").Raw))
 
 						toolCalls = append(toolCalls, gjson.Parse(toolCallJSON).Value())
 
@@ -130,7 +129,7 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 						// Convert to OpenAI tool message format and add immediately to preserve order
 						toolResultJSON := `{"role":"tool","tool_call_id":"","content":""}`
 						toolResultJSON, _ = sjson.Set(toolResultJSON, "tool_call_id", part.Get("tool_use_id").String())
-						toolResultJSON, _ = sjson.Set(toolResultJSON, "content", part.Get("content").String())
+						toolResultJSON, _ = sjson.Set(toolResultJSON, "content", util.ToolResultText(part.Get("content")))
 						messagesJSON, _ = sjson.Set(messagesJSON, "-1", gjson.Parse(toolResultJSON).Value())
 					}
 					return true
@@ -198,6 +197,10 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 		var toolsJSON = "[]"
 
 		tools.ForEach(func(_, tool gjson.Result) bool {
+			// The server-side web search tool has no OpenAI function equivalent
+			if strings.HasPrefix(tool.Get("type").String(), "web_search") {
+				return true
+			}
 			openAIToolJSON := `{"type":"function","function":{"name":"","description":""}}`
 			openAIToolJSON, _ = sjson.Set(openAIToolJSON, "function.name", tool.Get("name").String())
 			openAIToolJSON, _ = sjson.Set(openAIToolJSON, "function.description", tool.Get("description").String())
@@ -219,6 +222,8 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 	// Tool choice mapping - convert Anthropic tool_choice to OpenAI format
 	if toolChoice := root.Get("tool_choice"); toolChoice.Exists() {
 		switch toolChoice.Get("type").String() {
+		case "none":
+			out, _ = sjson.Set(out, "tool_choice", "none")
 		case "auto":
 			out, _ = sjson.Set(out, "tool_choice", "auto")
 		case "any":
@@ -233,6 +238,14 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 			// Default to auto if not specified
 			out, _ = sjson.Set(out, "tool_choice", "auto")
 		}
+		if toolChoice.Get("disable_parallel_tool_use").Bool() {
+			out, _ = sjson.Set(out, "parallel_tool_calls", false)
+		}
+	}
+
+	// Structured output: output_format -> response_format
+	if format, ok := util.ClaudeOutputFormat(root.Get("output_format")); ok {
+		out, _ = sjson.SetRaw(out, "response_format", format.OpenAI())
 	}
 
 	// Handle user parameter (for tracking)
@@ -10,6 +10,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
@@ -40,6 +41,15 @@ type ConvertOpenAIResponseToAnthropicParams struct {
 	MessageDeltaSent bool
 	// Track if message_start has been sent
 	MessageStarted bool
+	// NextContentBlockIndex is the index of the next content block to start; Claude clients
+	// expect blocks numbered 0, 1, 2... in the order they start
+	NextContentBlockIndex int
+	// TextContentBlockIndex is the index of the open text content block
+	TextContentBlockIndex int
+	// ThinkingContentBlockStarted and ThinkingContentBlockIndex track the open thinking
+	// block that carries reasoning_content
+	ThinkingContentBlockStarted bool
+	ThinkingContentBlockIndex   int
 }
 
 // ToolCallAccumulator holds the state for accumulating tool call data
@@ -47,6 +57,9 @@ type ToolCallAccumulator struct {
 	ID        string
 	Name      string
 	Arguments strings.Builder
+	// BlockIndex is the content block index of the tool_use block once it has started
+	BlockIndex int
+	Started    bool
 }
 
 // ConvertOpenAIResponseToClaude converts OpenAI streaming response format to Anthropic API format.
@@ -111,6 +124,18 @@ func convertOpenAIStreamingChunkToAnthropic(rawJSON []byte, param *ConvertOpenAI
 		param.CreatedAt = root.Get("created").Int()
 	}
 
+	stopThinking := func() {
+		if param.ThinkingContentBlockStarted {
+			param.ThinkingContentBlockStarted = false
+			contentBlockStop := map[string]interface{}{
+				"type":  "content_block_stop",
+				"index": param.ThinkingContentBlockIndex,
+			}
+			contentBlockStopJSON, _ := json.Marshal(contentBlockStop)
+			results = append(results, "event: content_block_stop\ndata: "+string(contentBlockStopJSON)+"\n\n")
+		}
+	}
+
 	// Check if this is the first chunk (has role)
 	if delta := root.Get("choices.0.delta"); delta.Exists() {
 		if role := delta.Get("role"); role.Exists() && role.String() == "assistant" && !param.MessageStarted {
@@ -138,13 +163,50 @@ func convertOpenAIStreamingChunkToAnthropic(rawJSON []byte, param *ConvertOpenAI
 			// Don't send content_block_start for text here - wait for actual content
 		}
 
+		// Handle reasoning delta; providers name it reasoning_content or reasoning
+		reasoning := delta.Get("reasoning_content")
+		if !reasoning.Exists() {
+			reasoning = delta.Get("reasoning")
+		}
+		if reasoning.Type == gjson.String && reasoning.String() != "" {
+			if !param.ThinkingContentBlockStarted {
+				param.ThinkingContentBlockIndex = param.NextContentBlockIndex
+				param.NextContentBlockIndex++
+				contentBlockStart := map[string]interface{}{
+					"type":  "content_block_start",
+					"index": param.ThinkingContentBlockIndex,
+					"content_block": map[string]interface{}{
+						"type":     "thinking",
+						"thinking": "",
+					},
+				}
+				contentBlockStartJSON, _ := json.Marshal(contentBlockStart)
+				results = append(results, "event: content_block_start\ndata: "+string(contentBlockStartJSON)+"\n\n")
+				param.ThinkingContentBlockStarted = true
+			}
+
+			thinkingDelta := map[string]interface{}{
+				"type":  "content_block_delta",
+				"index": param.ThinkingContentBlockIndex,
+				"delta": map[string]interface{}{
+					"type":     "thinking_delta",
+					"thinking": reasoning.String(),
+				},
+			}
+			thinkingDeltaJSON, _ := json.Marshal(thinkingDelta)
+			results = append(results, "event: content_block_delta\ndata: "+string(thinkingDeltaJSON)+"\n\n")
+		}
+
 		// Handle content delta
 		if content := delta.Get("content"); content.Exists() && content.String() != "" {
+			stopThinking()
 			// Send content_block_start for text if not already sent
 			if !param.TextContentBlockStarted {
+				param.TextContentBlockIndex = param.NextContentBlockIndex
+				param.NextContentBlockIndex++
 				contentBlockStart := map[string]interface{}{
 					"type":  "content_block_start",
-					"index": 0,
+					"index": param.TextContentBlockIndex,
 					"content_block": map[string]interface{}{
 						"type": "text",
 						"text": "",
@@ -157,7 +219,7 @@ func convertOpenAIStreamingChunkToAnthropic(rawJSON []byte, param *ConvertOpenAI
 
 			contentDelta := map[string]interface{}{
 				"type":  "content_block_delta",
-				"index": 0,
+				"index": param.TextContentBlockIndex,
 				"delta": map[string]interface{}{
 					"type": "text_delta",
 					"text": content.String(),
@@ -193,23 +255,28 @@ func convertOpenAIStreamingChunkToAnthropic(rawJSON []byte, param *ConvertOpenAI
 
 				// Handle function name
 				if function := toolCall.Get("function"); function.Exists() {
-					if name := function.Get("name"); name.Exists() {
+					if name := function.Get("name"); name.Exists() && name.String() != "" && !accumulator.Started {
 						accumulator.Name = name.String()
 
+						stopThinking()
 						if param.TextContentBlockStarted {
 							param.TextContentBlockStarted = false
 							contentBlockStop := map[string]interface{}{
 								"type":  "content_block_stop",
-								"index": index,
+								"index": param.TextContentBlockIndex,
 							}
 							contentBlockStopJSON, _ := json.Marshal(contentBlockStop)
 							results = append(results, "event: content_block_stop\ndata: "+string(contentBlockStopJSON)+"\n\n")
 						}
 
+						accumulator.Started = true
+						accumulator.BlockIndex = param.NextContentBlockIndex
+						param.NextContentBlockIndex++
+
 						// Send content_block_start for tool_use
 						contentBlockStart := map[string]interface{}{
 							"type":  "content_block_start",
-							"index": index + 1, // Offset by 1 since text is at index 0
+							"index": accumulator.BlockIndex,
 							"content_block": map[string]interface{}{
 								"type":  "tool_use",
 								"id":    accumulator.ID,
@@ -240,11 +307,13 @@ func convertOpenAIStreamingChunkToAnthropic(rawJSON []byte, param *ConvertOpenAI
 		reason := finishReason.String()
 		param.FinishReason = reason
 
+		stopThinking()
 		// Send content_block_stop for text if text content block was started
 		if param.TextContentBlockStarted && !param.ContentBlocksStopped {
+			param.TextContentBlockStarted = false
 			contentBlockStop := map[string]interface{}{
 				"type":  "content_block_stop",
-				"index": 0,
+				"index": param.TextContentBlockIndex,
 			}
 			contentBlockStopJSON, _ := json.Marshal(contentBlockStop)
 			results = append(results, "event: content_block_stop\ndata: "+string(contentBlockStopJSON)+"\n\n")
@@ -252,14 +321,22 @@ func convertOpenAIStreamingChunkToAnthropic(rawJSON []byte, param *ConvertOpenAI
 
 		// Send content_block_stop for any tool calls
 		if !param.ContentBlocksStopped {
-			for index := range param.ToolCallsAccumulator {
+			// Stop tool blocks in the order the calls were made
+			indexes := make([]int, 0, len(param.ToolCallsAccumulator))
+			for index, accumulator := range param.ToolCallsAccumulator {
+				if accumulator.Started {
+					indexes = append(indexes, index)
+				}
+			}
+			sort.Ints(indexes)
+			for _, index := range indexes {
 				accumulator := param.ToolCallsAccumulator[index]
 
 				// Send complete input_json_delta with all accumulated arguments
 				if accumulator.Arguments.Len() > 0 {
 					inputDelta := map[string]interface{}{
 						"type":  "content_block_delta",
-						"index": index + 1,
+						"index": accumulator.BlockIndex,
 						"delta": map[string]interface{}{
 							"type":         "input_json_delta",
 							"partial_json": util.FixJSON(accumulator.Arguments.String()),
@@ -271,7 +348,7 @@ func convertOpenAIStreamingChunkToAnthropic(rawJSON []byte, param *ConvertOpenAI
 
 				contentBlockStop := map[string]interface{}{
 					"type":  "content_block_stop",
-					"index": index + 1,
+					"index": accumulator.BlockIndex,
 				}
 				contentBlockStopJSON, _ := json.Marshal(contentBlockStop)
 				results = append(results, "event: content_block_stop\ndata: "+string(contentBlockStopJSON)+"\n\n")
@@ -362,6 +439,18 @@ func convertOpenAINonStreamingToAnthropic(rawJSON []byte) []string {
 	if choices := root.Get("choices"); choices.Exists() && choices.IsArray() {
 		choice := choices.Array()[0] // Take first choice
 
+		// Handle reasoning content
+		reasoning := choice.Get("message.reasoning_content")
+		if !reasoning.Exists() {
+			reasoning = choice.Get("message.reasoning")
+		}
+		if reasoning.Type == gjson.String && reasoning.String() != "" {
+			contentBlocks = append(contentBlocks, map[string]interface{}{
+				"type":     "thinking",
+				"thinking": reasoning.String(),
+			})
+		}
+
 		// Handle text content
 		if content := choice.Get("message.content"); content.Exists() && content.String() != "" {
 			textBlock := map[string]interface{}{
@@ -478,6 +567,16 @@ func ConvertOpenAIResponseToClaudeNonStream(_ context.Context, _ string, origina
 		}
 
 		if message := choice.Get("message"); message.Exists() {
+			reasoning := message.Get("reasoning_content")
+			if !reasoning.Exists() {
+				reasoning = message.Get("reasoning")
+			}
+			if reasoning.Type == gjson.String && reasoning.String() != "" {
+				contentBlocks = append(contentBlocks, map[string]interface{}{
+					"type":     "thinking",
+					"thinking": reasoning.String(),
+				})
+			}
 			if contentResult := message.Get("content"); contentResult.Exists() {
 				if contentResult.IsArray() {
 					var textBuilder strings.Builder
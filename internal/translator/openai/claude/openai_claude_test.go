@@ -0,0 +1,24 @@
+package claude
+
+import (
+	"context"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/translatortest"
+)
+
+func TestConvertClaudeRequestToOpenAI(t *testing.T) {
+	translatortest.Run(t, "request_", func(input []byte) []byte {
+		return ConvertClaudeRequestToOpenAI("gpt-4.1", input, true)
+	})
+}
+
+func TestConvertOpenAIResponseToClaude(t *testing.T) {
+	translatortest.Run(t, "stream_", func(input []byte) []byte {
+		var param any
+		request := []byte(`{"stream":true}`)
+		return translatortest.Stream(input, func(chunk []byte) []string {
+			return ConvertOpenAIResponseToClaude(context.Background(), "gpt-4.1", request, request, chunk, &param)
+		})
+	})
+}
@@ -13,6 +13,7 @@ import (
 	"math/big"
 	"strings"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -21,7 +22,7 @@ import (
 // It extracts the model name, generation config, message contents, and tool declarations
 // from the raw JSON request and returns them in the format expected by the OpenAI API.
 func ConvertGeminiRequestToOpenAI(modelName string, inputRawJSON []byte, stream bool) []byte {
-	rawJSON := bytes.Clone(inputRawJSON)
+	rawJSON := util.NormalizeGeminiRequest(bytes.Clone(inputRawJSON))
 	// Base OpenAI Chat Completions API template
 	out := `{"model":"","messages":[]}`
 
@@ -54,6 +55,11 @@ func ConvertGeminiRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 			out, _ = sjson.Set(out, "max_tokens", maxTokens.Int())
 		}
 
+		// Thinking budget -> reasoning_effort
+		if effort := util.GeminiReasoningEffort(genConfig.Get("thinkingConfig")); effort != "" {
+			out, _ = sjson.Set(out, "reasoning_effort", effort)
+		}
+
 		// Top P
 		if topP := genConfig.Get("topP"); topP.Exists() {
 			out, _ = sjson.Set(out, "top_p", topP.Float())
@@ -65,6 +71,24 @@ func ConvertGeminiRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 			out, _ = sjson.Set(out, "top_k", topK.Int())
 		}
 
+		// Candidate count
+		if candidateCount := genConfig.Get("candidateCount"); candidateCount.Exists() {
+			out, _ = sjson.Set(out, "n", candidateCount.Int())
+		}
+
+		// Presence and frequency penalties
+		if presencePenalty := genConfig.Get("presencePenalty"); presencePenalty.Exists() {
+			out, _ = sjson.Set(out, "presence_penalty", presencePenalty.Float())
+		}
+		if frequencyPenalty := genConfig.Get("frequencyPenalty"); frequencyPenalty.Exists() {
+			out, _ = sjson.Set(out, "frequency_penalty", frequencyPenalty.Float())
+		}
+
+		// Structured output: JSON mime type with an optional schema
+		if format, ok := util.GeminiResponseFormat(genConfig); ok {
+			out, _ = sjson.SetRaw(out, "response_format", format.OpenAI())
+		}
+
 		// Stop sequences
 		if stopSequences := genConfig.Get("stopSequences"); stopSequences.Exists() && stopSequences.IsArray() {
 			var stops []string
@@ -83,7 +107,28 @@ func ConvertGeminiRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 
 	// Process contents (Gemini messages) -> OpenAI messages
 	var openAIMessages []interface{}
-	var toolCallIDs []string // Track tool call IDs for matching with tool results
+	var toolCallIDs []string                    // Track tool call IDs for matching with tool results
+	pendingCallIDs := make(map[string][]string) // Unanswered tool call IDs keyed by function name
+
+	// System instruction -> system message
+	if systemInstruction := root.Get("systemInstruction"); systemInstruction.Exists() {
+		var systemText strings.Builder
+		systemInstruction.Get("parts").ForEach(func(_, part gjson.Result) bool {
+			if text := part.Get("text"); text.Exists() {
+				if systemText.Len() > 0 {
+					systemText.WriteString("\n")
+				}
+				systemText.WriteString(text.String())
+			}
+			return true
+		})
+		if systemText.Len() > 0 {
+			openAIMessages = append(openAIMessages, map[string]interface{}{
+				"role":    "system",
+				"content": systemText.String(),
+			})
+		}
+	}
 
 	if contents := root.Get("contents"); contents.Exists() && contents.IsArray() {
 		contents.ForEach(func(_, content gjson.Result) bool {
@@ -137,10 +182,37 @@ func ConvertGeminiRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 						})
 					}
 
+					// Handle file references; images are passed by URL, anything else is described in text
+					if fileData := part.Get("fileData"); fileData.Exists() {
+						fileURI := fileData.Get("fileUri").String()
+						mimeType := fileData.Get("mimeType").String()
+						if strings.HasPrefix(mimeType, "image/") {
+							onlyTextContent = false
+							aggregatedParts = append(aggregatedParts, map[string]interface{}{
+								"type": "image_url",
+								"image_url": map[string]interface{}{
+									"url": fileURI,
+								},
+							})
+						} else {
+							fileInfo := "File: " + fileURI
+							if mimeType != "" {
+								fileInfo += " (Type: " + mimeType + ")"
+							}
+							textBuilder.WriteString(fileInfo)
+							aggregatedParts = append(aggregatedParts, map[string]interface{}{
+								"type": "text",
+								"text": fileInfo,
+							})
+						}
+					}
+
 					// Handle function calls (Gemini) -> tool calls (OpenAI)
 					if functionCall := part.Get("functionCall"); functionCall.Exists() {
 						toolCallID := genToolCallID()
 						toolCallIDs = append(toolCallIDs, toolCallID)
+						name := functionCall.Get("name").String()
+						pendingCallIDs[name] = append(pendingCallIDs[name], toolCallID)
 
 						toolCall := map[string]interface{}{
 							"id":   toolCallID,
@@ -183,9 +255,12 @@ func ConvertGeminiRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 							}
 						}
 
-						// Try to match with previous tool call ID
-						_ = functionResponse.Get("name").String() // functionName not used for now
-						if len(toolCallIDs) > 0 {
+						// Match the oldest unanswered call of the same function, falling back to the latest call
+						name := functionResponse.Get("name").String()
+						if pending := pendingCallIDs[name]; len(pending) > 0 {
+							toolMsg["tool_call_id"] = pending[0]
+							pendingCallIDs[name] = pending[1:]
+						} else if len(toolCallIDs) > 0 {
 							// Use the last tool call ID (simple matching by function name)
 							// In a real implementation, you might want more sophisticated matching
 							toolMsg["tool_call_id"] = toolCallIDs[len(toolCallIDs)-1]
@@ -215,6 +290,10 @@ func ConvertGeminiRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 				msg["tool_calls"] = toolCalls
 			}
 
+			// Contents holding only function responses were already emitted as tool messages
+			if len(aggregatedParts) == 0 && len(toolCalls) == 0 {
+				return true
+			}
 			openAIMessages = append(openAIMessages, msg)
 
 			// switch role {
@@ -369,16 +448,21 @@ func ConvertGeminiRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 	}
 
 	// Tool choice mapping (Gemini doesn't have direct equivalent, but we can handle it)
-	if toolConfig := root.Get("toolConfig"); toolConfig.Exists() {
-		if functionCallingConfig := toolConfig.Get("functionCallingConfig"); functionCallingConfig.Exists() {
-			mode := functionCallingConfig.Get("mode").String()
-			switch mode {
-			case "NONE":
-				out, _ = sjson.Set(out, "tool_choice", "none")
-			case "AUTO":
-				out, _ = sjson.Set(out, "tool_choice", "auto")
-			case "ANY":
-				out, _ = sjson.Set(out, "tool_choice", "required")
+	if functionCallingConfig, allowed := util.GeminiRequestFunctionCalling(root); functionCallingConfig.Exists() {
+		mode := functionCallingConfig.Get("mode").String()
+		switch mode {
+		case "NONE":
+			out, _ = sjson.Set(out, "tool_choice", "none")
+		case "AUTO":
+			out, _ = sjson.Set(out, "tool_choice", "auto")
+		case "ANY":
+			out, _ = sjson.Set(out, "tool_choice", "required")
+			// A single allowed function is a forced call of that function
+			if len(allowed) == 1 {
+				out, _ = sjson.Set(out, "tool_choice", map[string]interface{}{
+					"type":     "function",
+					"function": map[string]interface{}{"name": allowed[0].String()},
+				})
 			}
 		}
 	}
@@ -84,11 +84,7 @@ func ConvertOpenAIResponseToGemini(_ context.Context, _ string, originalRequestR
 					template, _ = sjson.Set(template, "model", model.String())
 				}
 
-				usageObj := map[string]interface{}{
-					"promptTokenCount":     usage.Get("prompt_tokens").Int(),
-					"candidatesTokenCount": usage.Get("completion_tokens").Int(),
-					"totalTokenCount":      usage.Get("total_tokens").Int(),
-				}
+				usageObj := geminiUsageMetadata(usage)
 				template, _ = sjson.Set(template, "usageMetadata", usageObj)
 				return []string{template}
 			}
@@ -120,6 +116,23 @@ func ConvertOpenAIResponseToGemini(_ context.Context, _ string, originalRequestR
 				return true
 			}
 
+			// Handle reasoning delta; providers name it reasoning_content or reasoning
+			reasoning := delta.Get("reasoning_content")
+			if !reasoning.Exists() {
+				reasoning = delta.Get("reasoning")
+			}
+			if reasoning.Type == gjson.String && reasoning.String() != "" {
+				parts := []interface{}{
+					map[string]interface{}{
+						"thought": true,
+						"text":    reasoning.String(),
+					},
+				}
+				template, _ = sjson.Set(template, "candidates.0.content.parts", parts)
+				results = append(results, template)
+				return true
+			}
+
 			// Handle content delta
 			if content := delta.Get("content"); content.Exists() && content.String() != "" {
 				contentText := content.String()
@@ -226,11 +239,7 @@ func ConvertOpenAIResponseToGemini(_ context.Context, _ string, originalRequestR
 
 			// Handle usage information
 			if usage := root.Get("usage"); usage.Exists() {
-				usageObj := map[string]interface{}{
-					"promptTokenCount":     usage.Get("prompt_tokens").Int(),
-					"candidatesTokenCount": usage.Get("completion_tokens").Int(),
-					"totalTokenCount":      usage.Get("total_tokens").Int(),
-				}
+				usageObj := geminiUsageMetadata(usage)
 				template, _ = sjson.Set(template, "usageMetadata", usageObj)
 				results = append(results, template)
 				return true
@@ -243,6 +252,25 @@ func ConvertOpenAIResponseToGemini(_ context.Context, _ string, originalRequestR
 	return []string{}
 }
 
+// geminiUsageMetadata converts OpenAI usage to Gemini usageMetadata. OpenAI counts reasoning
+// tokens within completion_tokens while Gemini reports them apart as thoughtsTokenCount.
+func geminiUsageMetadata(usage gjson.Result) map[string]interface{} {
+	completionTokens := usage.Get("completion_tokens").Int()
+	usageObj := map[string]interface{}{
+		"promptTokenCount":     usage.Get("prompt_tokens").Int(),
+		"candidatesTokenCount": completionTokens,
+		"totalTokenCount":      usage.Get("total_tokens").Int(),
+	}
+	if reasoningTokens := usage.Get("completion_tokens_details.reasoning_tokens").Int(); reasoningTokens > 0 && reasoningTokens <= completionTokens {
+		usageObj["candidatesTokenCount"] = completionTokens - reasoningTokens
+		usageObj["thoughtsTokenCount"] = reasoningTokens
+	}
+	if cachedTokens := usage.Get("prompt_tokens_details.cached_tokens").Int(); cachedTokens > 0 {
+		usageObj["cachedContentTokenCount"] = cachedTokens
+	}
+	return usageObj
+}
+
 // mapOpenAIFinishReasonToGemini maps OpenAI finish reasons to Gemini finish reasons
 func mapOpenAIFinishReasonToGemini(openAIReason string) string {
 	switch openAIReason {
@@ -549,7 +577,19 @@ func ConvertOpenAIResponseToGeminiNonStream(_ context.Context, _ string, origina
 
 			var parts []interface{}
 
-			// Handle content first
+			// Handle reasoning first
+			reasoning := message.Get("reasoning_content")
+			if !reasoning.Exists() {
+				reasoning = message.Get("reasoning")
+			}
+			if reasoning.Type == gjson.String && reasoning.String() != "" {
+				parts = append(parts, map[string]interface{}{
+					"thought": true,
+					"text":    reasoning.String(),
+				})
+			}
+
+			// Handle content
 			if content := message.Get("content"); content.Exists() && content.String() != "" {
 				parts = append(parts, map[string]interface{}{
 					"text": content.String(),
@@ -600,11 +640,7 @@ func ConvertOpenAIResponseToGeminiNonStream(_ context.Context, _ string, origina
 
 	// Handle usage information
 	if usage := root.Get("usage"); usage.Exists() {
-		usageObj := map[string]interface{}{
-			"promptTokenCount":     usage.Get("prompt_tokens").Int(),
-			"candidatesTokenCount": usage.Get("completion_tokens").Int(),
-			"totalTokenCount":      usage.Get("total_tokens").Int(),
-		}
+		usageObj := geminiUsageMetadata(usage)
 		out, _ = sjson.Set(out, "usageMetadata", usageObj)
 	}
 
@@ -0,0 +1,28 @@
+package gemini
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/translatortest"
+)
+
+// toolCallID matches the tool call ids generated for Gemini function calls.
+var toolCallID = regexp.MustCompile(`call_[A-Za-z0-9]{24}`)
+
+func TestConvertGeminiRequestToOpenAI(t *testing.T) {
+	translatortest.Run(t, "request_", func(input []byte) []byte {
+		return ConvertGeminiRequestToOpenAI("gpt-4.1", input, true)
+	}, toolCallID)
+}
+
+func TestConvertOpenAIResponseToGemini(t *testing.T) {
+	translatortest.Run(t, "stream_", func(input []byte) []byte {
+		var param any
+		request := []byte(`{"stream":true}`)
+		return translatortest.Stream(input, func(chunk []byte) []string {
+			return ConvertOpenAIResponseToGemini(context.Background(), "gpt-4.1", request, request, chunk, &param)
+		})
+	})
+}
@@ -154,6 +154,10 @@ func ConvertOpenAIResponsesRequestToOpenAIChatCompletions(modelName string, inpu
 		var chatCompletionsTools []interface{}
 
 		tools.ForEach(func(_, tool gjson.Result) bool {
+			// Built-in tools such as web_search have no chat completions function equivalent
+			if toolType := tool.Get("type").String(); toolType != "" && toolType != "function" {
+				return true
+			}
 			chatTool := `{"type":"function","function":{}}`
 
 			// Convert tool structure from responses format to chat completions format
@@ -30,14 +30,15 @@ func Register(from, to string, request interfaces.TranslateRequestFunc, response
 // Parameters:
 //   - from: The source API format identifier
 //   - to: The target API format identifier
+//   - ctx: The context for the translation
 //   - modelName: The model name for the request
 //   - rawJSON: The raw JSON request data
 //   - stream: Whether this is a streaming request
 //
 // Returns:
 //   - []byte: The translated request JSON
-func Request(from, to, modelName string, rawJSON []byte, stream bool) []byte {
-	return registry.TranslateRequest(sdktranslator.FromString(from), sdktranslator.FromString(to), modelName, rawJSON, stream)
+func Request(from, to string, ctx context.Context, modelName string, rawJSON []byte, stream bool) []byte {
+	return registry.TranslateRequest(ctx, sdktranslator.FromString(from), sdktranslator.FromString(to), modelName, rawJSON, stream)
 }
 
 // NeedConvert checks if a response translation is needed between two API formats.
@@ -0,0 +1,165 @@
+// Package translatortest provides golden-file helpers for the translator tests.
+// Each test case is a pair of files in the testdata directory of the package under test:
+// <name>.input.json holds the translator input and <name>.golden.json the expected output.
+// Run the tests with -update to rewrite the golden files from the current output.
+package translatortest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the translator golden files")
+
+// Run converts every testdata/<prefix>*.input.json with convert and compares the output
+// with the matching golden file. Outputs are compared as JSON values, so key order and
+// formatting do not matter. Generated ids and timestamps are matched by masks and replaced
+// before the comparison; see Mask.
+func Run(t *testing.T, prefix string, convert func(input []byte) []byte, masks ...*regexp.Regexp) {
+	t.Helper()
+	inputs, err := filepath.Glob(filepath.Join("testdata", prefix+"*.input.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		t.Fatalf("no test cases for testdata/%s*.input.json", prefix)
+	}
+	for _, inputPath := range inputs {
+		name := strings.TrimSuffix(filepath.Base(inputPath), ".input.json")
+		t.Run(name, func(t *testing.T) {
+			input, err := os.ReadFile(inputPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := Mask(convert(input), masks...)
+			goldenPath := filepath.Join("testdata", name+".golden.json")
+			if *update {
+				var out bytes.Buffer
+				if err := json.Indent(&out, got, "", "  "); err != nil {
+					t.Fatalf("output is not valid JSON: %v\n%s", err, got)
+				}
+				out.WriteByte('\n')
+				if err := os.WriteFile(goldenPath, out.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			var gotValue, wantValue any
+			if err := json.Unmarshal(got, &gotValue); err != nil {
+				t.Fatalf("output is not valid JSON: %v\n%s", err, got)
+			}
+			if err := json.Unmarshal(want, &wantValue); err != nil {
+				t.Fatalf("golden file is not valid JSON: %v", err)
+			}
+			if !reflect.DeepEqual(gotValue, wantValue) {
+				var out bytes.Buffer
+				_ = json.Indent(&out, got, "", "  ")
+				t.Errorf("output differs from %s:\n%s", goldenPath, out.String())
+			}
+		})
+	}
+}
+
+// Mask replaces the generated values in out matched by masks. The first submatch of a mask
+// is replaced if it has one, the whole match otherwise. Numbers become 0 and other values
+// mask-N, numbered in the order they first appear, so values that must match each other,
+// such as a tool call id and the id of its result, still do.
+func Mask(out []byte, masks ...*regexp.Regexp) []byte {
+	names := make(map[string]string)
+	for _, re := range masks {
+		out = re.ReplaceAllFunc(out, func(match []byte) []byte {
+			start, end := 0, len(match)
+			if loc := re.FindSubmatchIndex(match); len(loc) >= 4 && loc[2] >= 0 {
+				start, end = loc[2], loc[3]
+			}
+			value := string(match[start:end])
+			name, ok := names[value]
+			if !ok {
+				if _, err := strconv.ParseFloat(value, 64); err == nil {
+					name = "0"
+				} else {
+					name = "mask-" + strconv.Itoa(len(names)+1)
+				}
+				names[value] = name
+			}
+			return append(append(append([]byte(nil), match[:start]...), name...), match[end:]...)
+		})
+	}
+	return out
+}
+
+// Stream feeds the events of a JSON array to a stream translator as "data:" lines and
+// returns everything it emitted as a JSON array. Emitted JSON chunks are kept as JSON
+// values, server-sent events become {"event": ..., "data": ...} objects.
+func Stream(input []byte, convert func(chunk []byte) []string) []byte {
+	var events []json.RawMessage
+	if err := json.Unmarshal(input, &events); err != nil {
+		panic("translatortest: stream input must be a JSON array: " + err.Error())
+	}
+	outputs := make([]json.RawMessage, 0, len(events))
+	for _, event := range events {
+		var text string
+		if err := json.Unmarshal(event, &text); err != nil {
+			var compact bytes.Buffer
+			_ = json.Compact(&compact, event)
+			text = compact.String()
+		}
+		for _, chunk := range convert([]byte("data: " + text)) {
+			outputs = append(outputs, chunkValues(chunk)...)
+		}
+	}
+	out, _ := json.Marshal(outputs)
+	return out
+}
+
+// chunkValues splits an emitted chunk into its server-sent events.
+func chunkValues(chunk string) []json.RawMessage {
+	chunk = strings.TrimSpace(chunk)
+	if json.Valid([]byte(chunk)) {
+		return []json.RawMessage{json.RawMessage(chunk)}
+	}
+	var values []json.RawMessage
+	for _, block := range strings.Split(chunk, "\n\n") {
+		var event, data json.RawMessage
+		for _, line := range strings.Split(block, "\n") {
+			name, value, ok := strings.Cut(line, ":")
+			if !ok {
+				continue
+			}
+			value = strings.TrimSpace(value)
+			raw := json.RawMessage(value)
+			if !json.Valid(raw) {
+				raw, _ = json.Marshal(value)
+			}
+			switch name {
+			case "event":
+				event = raw
+			case "data":
+				data = raw
+			}
+		}
+		if event == nil && data == nil {
+			quoted, _ := json.Marshal(block)
+			values = append(values, quoted)
+			continue
+		}
+		value, _ := json.Marshal(struct {
+			Event json.RawMessage `json:"event,omitempty"`
+			Data  json.RawMessage `json:"data,omitempty"`
+		}{event, data})
+		values = append(values, value)
+	}
+	return values
+}
@@ -0,0 +1,95 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// WithIdleTimeout wraps rt so that a response body that yields no data for idle fails its
+// next read with a timeout error and the request is cancelled, releasing its connection.
+// rt is returned as it is when idle is not positive.
+func WithIdleTimeout(rt http.RoundTripper, idle time.Duration) http.RoundTripper {
+	if idle <= 0 {
+		return rt
+	}
+	return &idleTimeout{base: rt, idle: idle}
+}
+
+type idleTimeout struct {
+	base http.RoundTripper
+	idle time.Duration
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *idleTimeout) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Upgrade") != "" {
+		// The body of a protocol upgrade is the connection itself.
+		return t.base.RoundTrip(req)
+	}
+	ctx, cancel := context.WithCancel(req.Context())
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	body := &idleTimeoutBody{ReadCloser: resp.Body, idle: t.idle, cancel: cancel}
+	body.timer = time.AfterFunc(t.idle, func() {
+		body.expired.Store(true)
+		cancel()
+	})
+	resp.Body = body
+	return resp, nil
+}
+
+// CloseIdleConnections closes the idle connections of the underlying transport.
+func (t *idleTimeout) CloseIdleConnections() {
+	if closer, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+type idleTimeoutBody struct {
+	io.ReadCloser
+	idle    time.Duration
+	cancel  context.CancelFunc
+	timer   *time.Timer
+	expired atomic.Bool
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if b.expired.Load() {
+		return n, &IdleTimeoutError{Idle: b.idle}
+	}
+	if n > 0 {
+		b.timer.Reset(b.idle)
+	}
+	return n, err
+}
+
+func (b *idleTimeoutBody) Close() error {
+	b.timer.Stop()
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// IdleTimeoutError reports a response body that yielded no data for Idle. It is a net.Error
+// whose Timeout method reports true.
+type IdleTimeoutError struct {
+	Idle time.Duration
+}
+
+func (e *IdleTimeoutError) Error() string {
+	return fmt.Sprintf("upstream response idle for %s", e.Idle)
+}
+
+// Timeout reports true.
+func (e *IdleTimeoutError) Timeout() bool { return true }
+
+// Temporary reports true.
+func (e *IdleTimeoutError) Temporary() bool { return true }
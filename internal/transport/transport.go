@@ -0,0 +1,314 @@
+// Package transport provides the HTTP transports of upstream requests. A transport is
+// shared per proxy URL, so connections are pooled across requests instead of being dialed
+// for each one, and is tuned by the upstream-transport settings. Connections and requests
+// are counted per upstream host for the connection pool metrics.
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"golang.org/x/net/proxy"
+)
+
+const (
+	defaultMaxIdleConns        = 256
+	defaultMaxIdleConnsPerHost = 64
+	defaultIdleConnTimeout     = 90 * time.Second
+	defaultDialTimeout         = 30 * time.Second
+	defaultKeepAlive           = 30 * time.Second
+	defaultTLSHandshakeTimeout = 10 * time.Second
+	defaultTLSSessionCacheSize = 256
+)
+
+var (
+	mu         sync.Mutex
+	settings   config.UpstreamTransport
+	transports = make(map[transportKey]*instrumented)
+
+	statsMu sync.RWMutex
+	hosts   = make(map[string]*hostCounters)
+)
+
+// Configure applies the upstream-transport settings. When they change, the transports
+// are rebuilt: new requests use the new settings and the idle connections of the old
+// transports are closed, while requests in flight finish on their connections.
+func Configure(cfg config.UpstreamTransport) {
+	mu.Lock()
+	defer mu.Unlock()
+	if cfg == settings {
+		return
+	}
+	settings = cfg
+	for key, t := range transports {
+		t.base.CloseIdleConnections()
+		delete(transports, key)
+	}
+}
+
+// Timeouts are the connection timeouts of a transport. Zero takes the upstream-transport
+// setting and a negative value disables the timeout.
+type Timeouts struct {
+	// Connect bounds establishing the TCP connection.
+	Connect time.Duration
+	// TLSHandshake bounds the TLS handshake.
+	TLSHandshake time.Duration
+	// ResponseHeader bounds the wait for the response headers once the request is sent.
+	ResponseHeader time.Duration
+}
+
+type transportKey struct {
+	proxyURL string
+	timeouts Timeouts
+}
+
+// Direct returns the transport for upstream requests without a proxy.
+func Direct() http.RoundTripper {
+	rt, _ := For("")
+	return rt
+}
+
+// For returns the shared transport that routes through proxyURL, or connects directly
+// when proxyURL is empty. SOCKS5, HTTP and HTTPS proxies are supported.
+func For(proxyURL string) (http.RoundTripper, error) {
+	return WithTimeouts(proxyURL, Timeouts{})
+}
+
+// WithTimeouts is For with the connection timeouts of timeouts. Requests with the same
+// proxy URL and timeouts share a transport.
+func WithTimeouts(proxyURL string, timeouts Timeouts) (http.RoundTripper, error) {
+	key := transportKey{proxyURL: strings.TrimSpace(proxyURL), timeouts: timeouts}
+	mu.Lock()
+	defer mu.Unlock()
+	if t, ok := transports[key]; ok {
+		return t, nil
+	}
+	base, err := build(key.proxyURL, settings, timeouts)
+	if err != nil {
+		return nil, err
+	}
+	t := &instrumented{base: base}
+	transports[key] = t
+	return t, nil
+}
+
+// build creates a transport for proxyURL with the settings of cfg and timeouts.
+func build(proxyURL string, cfg config.UpstreamTransport, timeouts Timeouts) (*http.Transport, error) {
+	dialer := &countingDialer{dialer: net.Dialer{
+		Timeout:   timeoutOr(timeouts.Connect, durationOr(cfg.DialTimeout, defaultDialTimeout)),
+		KeepAlive: durationOr(cfg.KeepAlive, defaultKeepAlive),
+	}}
+	t := &http.Transport{
+		DialContext:           dialer.DialContext,
+		MaxIdleConns:          intOr(cfg.MaxIdleConns, defaultMaxIdleConns),
+		MaxIdleConnsPerHost:   intOr(cfg.MaxIdleConnsPerHost, defaultMaxIdleConnsPerHost),
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		IdleConnTimeout:       durationOr(cfg.IdleConnTimeout, defaultIdleConnTimeout),
+		TLSHandshakeTimeout:   timeoutOr(timeouts.TLSHandshake, durationOr(cfg.TLSHandshakeTimeout, defaultTLSHandshakeTimeout)),
+		ResponseHeaderTimeout: timeoutOr(timeouts.ResponseHeader, 0),
+		ExpectContinueTimeout: time.Second,
+		ForceAttemptHTTP2:     !cfg.DisableHTTP2,
+		TLSClientConfig:       &tls.Config{},
+	}
+	if cfg.DisableHTTP2 {
+		// A non-nil empty map turns off the HTTP/2 upgrade during the TLS handshake.
+		t.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+	if size := intOr(cfg.TLSSessionCacheSize, defaultTLSSessionCacheSize); size > 0 {
+		t.TLSClientConfig.ClientSessionCache = tls.NewLRUClientSessionCache(size)
+	}
+
+	if proxyURL == "" {
+		// Direct connections still honour HTTP_PROXY and friends, like the default transport.
+		t.Proxy = http.ProxyFromEnvironment
+		return t, nil
+	}
+	parsed, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse proxy URL failed: %w", err)
+	}
+	switch parsed.Scheme {
+	case "socks5":
+		var proxyAuth *proxy.Auth
+		if parsed.User != nil {
+			password, _ := parsed.User.Password()
+			proxyAuth = &proxy.Auth{User: parsed.User.Username(), Password: password}
+		}
+		socks, errSOCKS5 := proxy.SOCKS5("tcp", parsed.Host, proxyAuth, dialer)
+		if errSOCKS5 != nil {
+			return nil, fmt.Errorf("create SOCKS5 dialer failed: %w", errSOCKS5)
+		}
+		if contextDialer, ok := socks.(proxy.ContextDialer); ok {
+			t.DialContext = contextDialer.DialContext
+		} else {
+			t.DialContext = func(_ context.Context, network, addr string) (net.Conn, error) {
+				return socks.Dial(network, addr)
+			}
+		}
+	case "http", "https":
+		t.Proxy = http.ProxyURL(parsed)
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme: %s", parsed.Scheme)
+	}
+	return t, nil
+}
+
+// instrumented counts whether each request got a new or a reused connection.
+type instrumented struct {
+	base *http.Transport
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *instrumented) RoundTrip(req *http.Request) (*http.Response, error) {
+	counters := countersFor(hostPort(req.URL))
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				counters.reused.Add(1)
+			} else {
+				counters.fresh.Add(1)
+			}
+		},
+	}
+	return t.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+}
+
+// CloseIdleConnections closes the idle connections of the underlying transport.
+func (t *instrumented) CloseIdleConnections() {
+	t.base.CloseIdleConnections()
+}
+
+// countingDialer counts the connections it opens and closes per address. Behind a proxy
+// the address is the proxy's.
+type countingDialer struct {
+	dialer net.Dialer
+}
+
+func (d *countingDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+func (d *countingDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	counters := countersFor(addr)
+	conn, err := d.dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		counters.dialErrors.Add(1)
+		return nil, err
+	}
+	counters.dialed.Add(1)
+	counters.open.Add(1)
+	return &countedConn{Conn: conn, counters: counters}, nil
+}
+
+type countedConn struct {
+	net.Conn
+	counters *hostCounters
+	closed   sync.Once
+}
+
+func (c *countedConn) Close() error {
+	c.closed.Do(func() { c.counters.open.Add(-1) })
+	return c.Conn.Close()
+}
+
+type hostCounters struct {
+	open, dialed, dialErrors, reused, fresh atomic.Int64
+}
+
+func countersFor(host string) *hostCounters {
+	statsMu.RLock()
+	counters, ok := hosts[host]
+	statsMu.RUnlock()
+	if ok {
+		return counters
+	}
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	if counters, ok = hosts[host]; !ok {
+		counters = &hostCounters{}
+		hosts[host] = counters
+	}
+	return counters
+}
+
+// HostStats is the connection pool state of one upstream host, or of a proxy for the
+// connections opened through it.
+type HostStats struct {
+	// Host is the host:port of the upstream or proxy.
+	Host string `json:"host"`
+	// Open is the number of connections currently open.
+	Open int64 `json:"open"`
+	// Dialed and DialErrors count the connection attempts that succeeded and failed.
+	Dialed     int64 `json:"dialed"`
+	DialErrors int64 `json:"dial_errors"`
+	// Reused and New count the requests sent on a pooled connection and on a new one.
+	Reused int64 `json:"reused"`
+	New    int64 `json:"new"`
+}
+
+// Stats returns the connection pool state per host, sorted by host.
+func Stats() []HostStats {
+	statsMu.RLock()
+	out := make([]HostStats, 0, len(hosts))
+	for host, counters := range hosts {
+		out = append(out, HostStats{
+			Host:       host,
+			Open:       counters.open.Load(),
+			Dialed:     counters.dialed.Load(),
+			DialErrors: counters.dialErrors.Load(),
+			Reused:     counters.reused.Load(),
+			New:        counters.fresh.Load(),
+		})
+	}
+	statsMu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Host < out[j].Host })
+	return out
+}
+
+// hostPort returns the host:port a request URL connects to.
+func hostPort(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	port := "443"
+	if u.Scheme == "http" {
+		port = "80"
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+func intOr(value, fallback int) int {
+	if value == 0 {
+		return fallback
+	}
+	return value
+}
+
+// timeoutOr returns value, fallback when it is 0, or 0, no timeout, when it is negative.
+func timeoutOr(value, fallback time.Duration) time.Duration {
+	switch {
+	case value < 0:
+		return 0
+	case value == 0:
+		return fallback
+	}
+	return value
+}
+
+func durationOr(value, fallback time.Duration) time.Duration {
+	if value <= 0 {
+		return fallback
+	}
+	return value
+}
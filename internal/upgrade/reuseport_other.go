@@ -0,0 +1,17 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package upgrade
+
+import (
+	"net"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// listen opens a TCP listener for addr. SO_REUSEPORT is not supported on this platform.
+func listen(addr string, reusePort bool) (net.Listener, error) {
+	if reusePort {
+		log.Warn("upgrade: reuse-port is not supported on this platform, ignoring it")
+	}
+	return net.Listen("tcp", addr)
+}
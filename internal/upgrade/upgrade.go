@@ -0,0 +1,143 @@
+// Package upgrade lets a new proxy binary take over the listening sockets of a running one
+// without refusing connections. On SIGUSR2 the running process re-executes its binary and
+// passes its listeners as inherited file descriptors; once the new process reports that it
+// is serving, the old one stops accepting requests and drains. Alternatively, listeners can
+// be opened with SO_REUSEPORT so independently started processes share a port.
+package upgrade
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// listenersEnv lists the addresses of the inherited listeners, in file descriptor order
+	// starting at 3.
+	listenersEnv = "CLIPROXY_UPGRADE_LISTENERS"
+	// readyEnv is the file descriptor the new process writes to once it is serving.
+	readyEnv = "CLIPROXY_UPGRADE_READY_FD"
+	// readyTimeout bounds how long the old process waits for the new one to start serving.
+	readyTimeout = 30 * time.Second
+)
+
+var (
+	mu        sync.Mutex
+	listeners []namedListener
+	inherited map[string]*os.File
+	readyOnce sync.Once
+	readyFile *os.File
+)
+
+// namedListener is a listener opened through Listen, together with its address.
+type namedListener struct {
+	addr string
+	ln   net.Listener
+}
+
+func init() {
+	addrs := os.Getenv(listenersEnv)
+	if addrs == "" {
+		return
+	}
+	inherited = make(map[string]*os.File)
+	for i, addr := range strings.Split(addrs, ",") {
+		inherited[addr] = os.NewFile(uintptr(3+i), "listener:"+addr)
+	}
+	if fd, err := strconv.Atoi(os.Getenv(readyEnv)); err == nil {
+		readyFile = os.NewFile(uintptr(fd), "upgrade-ready")
+	}
+	_ = os.Unsetenv(listenersEnv)
+	_ = os.Unsetenv(readyEnv)
+}
+
+// Listen returns a TCP listener for addr. It reuses the listener inherited from the
+// previous process when there is one, and otherwise opens a new one, with SO_REUSEPORT
+// when reusePort is set. The listener is handed over on the next upgrade.
+func Listen(addr string, reusePort bool) (net.Listener, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if file, ok := inherited[addr]; ok {
+		delete(inherited, addr)
+		ln, err := net.FileListener(file)
+		_ = file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("upgrade: inherit listener %s: %w", addr, err)
+		}
+		log.Infof("upgrade: took over listener %s", addr)
+		listeners = append(listeners, namedListener{addr: addr, ln: ln})
+		return ln, nil
+	}
+	ln, err := listen(addr, reusePort)
+	if err != nil {
+		return nil, err
+	}
+	listeners = append(listeners, namedListener{addr: addr, ln: ln})
+	return ln, nil
+}
+
+// ListenUnix returns a listener on the Unix domain socket at path, with the file mode
+// mode. Like Listen it reuses an inherited listener and hands the socket over on the next
+// upgrade. Closing the listener leaves the socket file in place, so that closing it after a
+// handover does not unlink the socket the new process serves; a stale socket left by a
+// previous process is replaced when the socket is opened.
+func ListenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	addr := "unix:" + path
+	mu.Lock()
+	defer mu.Unlock()
+	if file, ok := inherited[addr]; ok {
+		delete(inherited, addr)
+		ln, err := net.FileListener(file)
+		_ = file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("upgrade: inherit listener %s: %w", addr, err)
+		}
+		if unixLn, isUnix := ln.(*net.UnixListener); isUnix {
+			unixLn.SetUnlinkOnClose(false)
+		}
+		log.Infof("upgrade: took over listener %s", addr)
+		listeners = append(listeners, namedListener{addr: addr, ln: ln})
+		return ln, nil
+	}
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("upgrade: %s exists and is not a socket", path)
+		}
+		if err = os.Remove(path); err != nil {
+			return nil, fmt.Errorf("upgrade: remove stale socket %s: %w", path, err)
+		}
+	}
+	ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	ln.SetUnlinkOnClose(false)
+	if err = os.Chmod(path, mode); err != nil {
+		_ = ln.Close()
+		_ = os.Remove(path)
+		return nil, fmt.Errorf("upgrade: set mode of %s: %w", path, err)
+	}
+	listeners = append(listeners, namedListener{addr: addr, ln: ln})
+	return ln, nil
+}
+
+// Ready tells the process that started this one that it is serving, so the previous
+// process can start draining. It does nothing when the process was not started by an
+// upgrade, and only the first call has an effect.
+func Ready() {
+	readyOnce.Do(func() {
+		if readyFile == nil {
+			return
+		}
+		if _, err := readyFile.Write([]byte{1}); err != nil {
+			log.Warnf("upgrade: failed to signal readiness: %v", err)
+		}
+		_ = readyFile.Close()
+	})
+}
@@ -0,0 +1,16 @@
+//go:build windows || plan9
+
+package upgrade
+
+import (
+	"context"
+	"errors"
+)
+
+// WatchSignal is a no-op on platforms without SIGUSR2 and file descriptor inheritance.
+func WatchSignal(_ context.Context, _ func()) {}
+
+// Handover is not supported on this platform.
+func Handover() (int, error) {
+	return 0, errors.New("listener handover is not supported on this platform")
+}
@@ -0,0 +1,115 @@
+//go:build !windows && !plan9
+
+package upgrade
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// WatchSignal hands the listeners over to a new process whenever the process receives
+// SIGUSR2. After a successful handover it calls onHandover, which should start the graceful
+// shutdown of this process, and stops watching. A failed handover leaves this process
+// serving.
+func WatchSignal(ctx context.Context, onHandover func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR2)
+	defer signal.Stop(signals)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			log.Info("received SIGUSR2, handing listeners over to a new process")
+			pid, err := Handover()
+			if err != nil {
+				log.Errorf("upgrade: handover failed, keeping this process serving: %v", err)
+				continue
+			}
+			log.Infof("upgrade: process %d took over, draining this process", pid)
+			onHandover()
+			return
+		}
+	}
+}
+
+// Handover starts the current binary with the same arguments, passing it the listeners
+// opened through Listen, and waits until it reports that it is serving. It returns the
+// process ID of the new process.
+func Handover() (int, error) {
+	mu.Lock()
+	current := append([]namedListener(nil), listeners...)
+	mu.Unlock()
+	if len(current) == 0 {
+		return 0, errors.New("no listeners to hand over")
+	}
+
+	addrs := make([]string, 0, len(current))
+	files := make([]*os.File, 0, len(current)+1)
+	defer func() {
+		for _, file := range files {
+			_ = file.Close()
+		}
+	}()
+	for _, l := range current {
+		filer, ok := l.ln.(interface{ File() (*os.File, error) })
+		if !ok {
+			return 0, fmt.Errorf("listener %s cannot be handed over", l.addr)
+		}
+		file, err := filer.File()
+		if err != nil {
+			return 0, fmt.Errorf("duplicate listener %s: %w", l.addr, err)
+		}
+		addrs = append(addrs, l.addr)
+		files = append(files, file)
+	}
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return 0, fmt.Errorf("create readiness pipe: %w", err)
+	}
+	defer func() { _ = readyR.Close() }()
+	files = append(files, readyW)
+
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, fmt.Errorf("locate executable: %w", err)
+	}
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = files
+	cmd.Env = append(os.Environ(),
+		listenersEnv+"="+strings.Join(addrs, ","),
+		readyEnv+"="+strconv.Itoa(3+len(files)-1),
+	)
+	if err = cmd.Start(); err != nil {
+		return 0, fmt.Errorf("start %s: %w", exe, err)
+	}
+	// Only the new process may hold the write end, so a read sees EOF if it exits early.
+	_ = readyW.Close()
+	files = files[:len(files)-1]
+
+	_ = readyR.SetReadDeadline(time.Now().Add(readyTimeout))
+	if _, err = readyR.Read(make([]byte, 1)); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		if errors.Is(err, io.EOF) {
+			return 0, errors.New("new process exited before it started serving")
+		}
+		return 0, fmt.Errorf("new process did not start serving: %w", err)
+	}
+	go func() { _ = cmd.Wait() }()
+	return cmd.Process.Pid, nil
+}
@@ -0,0 +1,275 @@
+package usage
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// Account usage windows reported in AccountWindow.
+const (
+	WindowFiveHour = "5h"
+	WindowDaily    = "daily"
+)
+
+// fiveHourWindow is the length of a rolling session window.
+const fiveHourWindow = 5 * time.Hour
+
+// minProjectionElapsed keeps a burst right after a window opens from projecting an
+// imminent exhaustion.
+const minProjectionElapsed = time.Minute
+
+// AccountWindow describes the consumption of an upstream account within one window.
+type AccountWindow struct {
+	Window       string    `json:"window"`
+	Start        time.Time `json:"start"`
+	ResetAt      time.Time `json:"reset_at"`
+	Requests     int64     `json:"requests"`
+	Tokens       int64     `json:"tokens"`
+	RequestLimit int64     `json:"request_limit,omitempty"`
+	TokenLimit   int64     `json:"token_limit,omitempty"`
+	Exhausted    bool      `json:"exhausted"`
+	// ProjectedExhaustion is when a limit will be reached at the consumption rate seen so far
+	// in the window; nil when no limit is known or the window resets first.
+	ProjectedExhaustion *time.Time `json:"projected_exhaustion,omitempty"`
+}
+
+// AccountUsage is the consumption of one upstream account.
+type AccountUsage struct {
+	AuthID   string          `json:"auth_id"`
+	Provider string          `json:"provider"`
+	LastUsed time.Time       `json:"last_used"`
+	Windows  []AccountWindow `json:"windows"`
+	// ProjectedExhaustion is the earliest projected exhaustion across the windows.
+	ProjectedExhaustion *time.Time `json:"projected_exhaustion,omitempty"`
+	// PromptCache summarises prompt caching since the server started; nil until the
+	// account served a request with input tokens.
+	PromptCache *AccountPromptCache `json:"prompt_cache,omitempty"`
+}
+
+// AccountPromptCache reports how much of the prompts an upstream account served were read
+// from the provider's prompt cache.
+type AccountPromptCache struct {
+	InputTokens         int64 `json:"input_tokens"`
+	CacheReadTokens     int64 `json:"cache_read_tokens"`
+	CacheCreationTokens int64 `json:"cache_creation_tokens"`
+	// HitRatio is the share of input tokens read from the cache.
+	HitRatio float64 `json:"hit_ratio"`
+}
+
+type accountCounters struct {
+	provider        string
+	lastUsed        time.Time
+	sessionStart    time.Time
+	sessionRequests int64
+	sessionTokens   int64
+	dayStart        time.Time
+	dayRequests     int64
+	dayTokens       int64
+	// Prompt cache counters accumulate across windows.
+	inputTokens         int64
+	cacheReadTokens     int64
+	cacheCreationTokens int64
+}
+
+// roll resets the windows that have ended.
+func (c *accountCounters) roll(now time.Time) {
+	if !c.sessionStart.IsZero() && !now.Before(c.sessionStart.Add(fiveHourWindow)) {
+		c.sessionStart = time.Time{}
+		c.sessionRequests = 0
+		c.sessionTokens = 0
+	}
+	if day := startOfDay(now); !c.dayStart.Equal(day) {
+		c.dayStart = day
+		c.dayRequests = 0
+		c.dayTokens = 0
+	}
+}
+
+// AccountTracker tracks consumption per upstream account in five-hour and daily windows
+// and projects when each account will exhaust its configured limits. It implements
+// coreusage.Plugin to receive usage records.
+type AccountTracker struct {
+	mu       sync.Mutex
+	byAuth   map[string]config.AccountQuota
+	provider map[string]config.AccountQuota
+	accounts map[string]*accountCounters
+	now      func() time.Time
+}
+
+// NewAccountTracker creates a tracker for the configured account limits.
+func NewAccountTracker(entries []config.AccountQuota) *AccountTracker {
+	t := &AccountTracker{accounts: make(map[string]*accountCounters), now: time.Now}
+	t.SetLimits(entries)
+	return t
+}
+
+// SetLimits replaces the configured limits. Accumulated usage is preserved.
+func (t *AccountTracker) SetLimits(entries []config.AccountQuota) {
+	byAuth := make(map[string]config.AccountQuota)
+	provider := make(map[string]config.AccountQuota)
+	for _, entry := range entries {
+		if id := strings.TrimSpace(entry.AuthID); id != "" {
+			byAuth[id] = entry
+			continue
+		}
+		if name := strings.ToLower(strings.TrimSpace(entry.Provider)); name != "" {
+			provider[name] = entry
+		}
+	}
+	t.mu.Lock()
+	t.byAuth = byAuth
+	t.provider = provider
+	t.mu.Unlock()
+}
+
+// HandleUsage implements coreusage.Plugin and counts the request against its account.
+// Failed requests are not counted since upstream providers do not charge them.
+func (t *AccountTracker) HandleUsage(_ context.Context, record coreusage.Record) {
+	if t == nil || record.AuthID == "" || record.Failed {
+		return
+	}
+	tokens := record.Detail.TotalTokens
+	if tokens == 0 {
+		tokens = record.Detail.InputTokens + record.Detail.OutputTokens + record.Detail.ReasoningTokens
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	c, ok := t.accounts[record.AuthID]
+	if !ok {
+		c = &accountCounters{}
+		t.accounts[record.AuthID] = c
+	}
+	c.roll(now)
+	if c.sessionStart.IsZero() {
+		c.sessionStart = now
+	}
+	if record.Provider != "" {
+		c.provider = record.Provider
+	}
+	c.lastUsed = now
+	c.sessionRequests++
+	c.sessionTokens += tokens
+	c.dayRequests++
+	c.dayTokens += tokens
+	c.inputTokens += record.Detail.InputTokens
+	c.cacheReadTokens += record.Detail.CachedTokens
+	c.cacheCreationTokens += record.Detail.CacheCreationTokens
+}
+
+// Snapshot returns the usage of every account that served a request, sorted by the
+// earliest projected exhaustion and then by account ID.
+func (t *AccountTracker) Snapshot() []AccountUsage {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	out := make([]AccountUsage, 0, len(t.accounts))
+	for id, c := range t.accounts {
+		c.roll(now)
+		out = append(out, buildAccountUsage(id, c, t.limitFor(id, c.provider), now))
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i].ProjectedExhaustion, out[j].ProjectedExhaustion
+		switch {
+		case a != nil && b != nil && !a.Equal(*b):
+			return a.Before(*b)
+		case (a == nil) != (b == nil):
+			return a != nil
+		}
+		return out[i].AuthID < out[j].AuthID
+	})
+	return out
+}
+
+// limitFor resolves the limits of an account. Callers must hold t.mu.
+func (t *AccountTracker) limitFor(authID, provider string) config.AccountQuota {
+	if limit, ok := t.byAuth[authID]; ok {
+		return limit
+	}
+	return t.provider[strings.ToLower(provider)]
+}
+
+func buildAccountUsage(id string, c *accountCounters, limit config.AccountQuota, now time.Time) AccountUsage {
+	session := AccountWindow{Window: WindowFiveHour, RequestLimit: limit.FiveHourRequests, TokenLimit: limit.FiveHourTokens}
+	if !c.sessionStart.IsZero() {
+		session.Start = c.sessionStart
+		session.ResetAt = c.sessionStart.Add(fiveHourWindow)
+		session.Requests = c.sessionRequests
+		session.Tokens = c.sessionTokens
+	}
+	daily := AccountWindow{
+		Window:       WindowDaily,
+		Start:        c.dayStart,
+		ResetAt:      c.dayStart.AddDate(0, 0, 1),
+		Requests:     c.dayRequests,
+		Tokens:       c.dayTokens,
+		RequestLimit: limit.DailyRequests,
+		TokenLimit:   limit.DailyTokens,
+	}
+	account := AccountUsage{AuthID: id, Provider: c.provider, LastUsed: c.lastUsed}
+	if c.inputTokens > 0 {
+		account.PromptCache = &AccountPromptCache{
+			InputTokens:         c.inputTokens,
+			CacheReadTokens:     c.cacheReadTokens,
+			CacheCreationTokens: c.cacheCreationTokens,
+			HitRatio:            float64(c.cacheReadTokens) / float64(c.inputTokens),
+		}
+	}
+	for _, window := range []AccountWindow{session, daily} {
+		if !window.Start.IsZero() {
+			project(&window, now)
+		}
+		if p := window.ProjectedExhaustion; p != nil && (account.ProjectedExhaustion == nil || p.Before(*account.ProjectedExhaustion)) {
+			account.ProjectedExhaustion = p
+		}
+		account.Windows = append(account.Windows, window)
+	}
+	return account
+}
+
+// project fills in whether the window is exhausted and when it will be, assuming the
+// consumption continues at the average rate since the window started.
+func project(window *AccountWindow, now time.Time) {
+	elapsed := now.Sub(window.Start)
+	if elapsed < minProjectionElapsed {
+		elapsed = minProjectionElapsed
+	}
+	var earliest *time.Time
+	check := func(limit, used int64) {
+		if limit <= 0 {
+			return
+		}
+		var at time.Time
+		if used >= limit {
+			window.Exhausted = true
+			at = now
+		} else if used > 0 {
+			at = now.Add(time.Duration(float64(limit-used) / float64(used) * float64(elapsed)))
+			if !at.Before(window.ResetAt) {
+				return
+			}
+		} else {
+			return
+		}
+		if earliest == nil || at.Before(*earliest) {
+			earliest = &at
+		}
+	}
+	check(window.RequestLimit, window.Requests)
+	check(window.TokenLimit, window.Tokens)
+	window.ProjectedExhaustion = earliest
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
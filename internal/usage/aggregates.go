@@ -0,0 +1,363 @@
+package usage
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// AggregateResolution is the width of the buckets recent requests are pre-aggregated into.
+const AggregateResolution = time.Minute
+
+// minuteTierRetention is the age past which minute buckets are merged into hourly ones.
+const minuteTierRetention = 7 * 24 * time.Hour
+
+// defaultHourlyRetention is the age past which hourly buckets are merged into daily ones
+// unless configured otherwise.
+const defaultHourlyRetention = 90 * 24 * time.Hour
+
+// RetentionPolicy bounds how long each tier of the usage statistics is kept. Requests are
+// counted in minute buckets for a week, hourly buckets until Hourly and daily buckets
+// until Daily, independently of how long their raw details are kept.
+type RetentionPolicy struct {
+	// Raw is how long individual request details are kept; zero keeps them indefinitely.
+	Raw time.Duration
+	// Hourly is how long hourly buckets are kept before they are merged into daily ones;
+	// zero means 90 days.
+	Hourly time.Duration
+	// Daily is how long daily buckets are kept; zero keeps them indefinitely.
+	Daily time.Duration
+}
+
+// Aggregate sums the requests of one client key and model within one bucket of time, so
+// metrics queries cost the number of buckets in their period rather than the number of
+// requests.
+type Aggregate struct {
+	// Start and Width bound the bucket: a minute, an hour or a day depending on the tier.
+	// Daily buckets start at local midnight.
+	Start    time.Time     `json:"start"`
+	Width    time.Duration `json:"width"`
+	API      string        `json:"api"`
+	Model    string        `json:"model"`
+	Requests int64         `json:"requests"`
+	Failures int64         `json:"failures,omitempty"`
+	Retries  int64         `json:"retries,omitempty"`
+	// Tokens sums the token usage of the requests.
+	Tokens TokenStats `json:"tokens"`
+	// Latency, TTFT and TokensPerSecond are the distributions of the request durations,
+	// the times to the first streamed chunk and the streaming throughput.
+	Latency         Histogram `json:"latency"`
+	TTFT            Histogram `json:"ttft"`
+	TokensPerSecond Histogram `json:"tokens_per_second"`
+	// Providers breaks the requests down by the upstream provider that served them; requests
+	// without a provider are keyed by the empty string.
+	Providers map[string]*ProviderAggregate `json:"providers,omitempty"`
+	// Errors counts the failed requests per proxy error code and provider.
+	Errors map[ErrorKey]int64 `json:"-"`
+}
+
+// aggregateJSON is the persisted form of an Aggregate, listing its error counts since JSON
+// objects cannot be keyed by ErrorKey.
+type aggregateJSON struct {
+	aggregateFields
+	Errors []errorCount `json:"errors,omitempty"`
+}
+
+type aggregateFields Aggregate
+
+type errorCount struct {
+	ErrorKey
+	Count int64 `json:"count"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (a Aggregate) MarshalJSON() ([]byte, error) {
+	out := aggregateJSON{aggregateFields: aggregateFields(a)}
+	for key, count := range a.Errors {
+		out.Errors = append(out.Errors, errorCount{ErrorKey: key, Count: count})
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (a *Aggregate) UnmarshalJSON(data []byte) error {
+	var raw aggregateJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*a = Aggregate(raw.aggregateFields)
+	if a.Providers == nil {
+		a.Providers = make(map[string]*ProviderAggregate)
+	}
+	a.Errors = make(map[ErrorKey]int64, len(raw.Errors))
+	for _, entry := range raw.Errors {
+		a.Errors[entry.ErrorKey] += entry.Count
+	}
+	return nil
+}
+
+// ProviderAggregate sums the requests of one upstream provider within an Aggregate.
+type ProviderAggregate struct {
+	Requests int64     `json:"requests"`
+	Failures int64     `json:"failures,omitempty"`
+	Latency  Histogram `json:"latency"`
+}
+
+// ErrorKey identifies the failures of one proxy error code at one upstream provider.
+type ErrorKey struct {
+	Code     string `json:"code"`
+	Provider string `json:"provider"`
+}
+
+type aggregateKey struct {
+	start int64
+	width time.Duration
+	api   string
+	model string
+}
+
+func (a *Aggregate) add(detail RequestDetail) {
+	a.Requests++
+	if detail.Failed {
+		a.Failures++
+		a.Errors[ErrorKey{Code: detail.ErrorCode, Provider: detail.Provider}]++
+	}
+	if detail.Retry {
+		a.Retries++
+	}
+	a.Tokens = a.Tokens.Add(detail.Tokens)
+	a.Latency.Add(float64(detail.LatencyMS))
+	a.TTFT.Add(float64(detail.TTFTMS))
+	a.TokensPerSecond.Add(detail.TokensPerSecond)
+
+	provider, ok := a.Providers[detail.Provider]
+	if !ok {
+		provider = &ProviderAggregate{}
+		a.Providers[detail.Provider] = provider
+	}
+	provider.Requests++
+	if detail.Failed {
+		provider.Failures++
+	}
+	provider.Latency.Add(float64(detail.LatencyMS))
+}
+
+func (a *Aggregate) merge(other *Aggregate) {
+	a.Requests += other.Requests
+	a.Failures += other.Failures
+	a.Retries += other.Retries
+	a.Tokens = a.Tokens.Add(other.Tokens)
+	a.Latency.Merge(other.Latency)
+	a.TTFT.Merge(other.TTFT)
+	a.TokensPerSecond.Merge(other.TokensPerSecond)
+	for name, other := range other.Providers {
+		provider, ok := a.Providers[name]
+		if !ok {
+			provider = &ProviderAggregate{}
+			a.Providers[name] = provider
+		}
+		provider.Requests += other.Requests
+		provider.Failures += other.Failures
+		provider.Latency.Merge(other.Latency)
+	}
+	for key, count := range other.Errors {
+		a.Errors[key] += count
+	}
+}
+
+func (a *Aggregate) clone() Aggregate {
+	out := *a
+	out.Latency = a.Latency.clone()
+	out.TTFT = a.TTFT.clone()
+	out.TokensPerSecond = a.TokensPerSecond.clone()
+	out.Providers = make(map[string]*ProviderAggregate, len(a.Providers))
+	for name, provider := range a.Providers {
+		out.Providers[name] = &ProviderAggregate{Requests: provider.Requests, Failures: provider.Failures, Latency: provider.Latency.clone()}
+	}
+	out.Errors = make(map[ErrorKey]int64, len(a.Errors))
+	for key, count := range a.Errors {
+		out.Errors[key] = count
+	}
+	return out
+}
+
+// Add returns the sum of two token usages.
+func (t TokenStats) Add(o TokenStats) TokenStats {
+	t.InputTokens += o.InputTokens
+	t.OutputTokens += o.OutputTokens
+	t.ReasoningTokens += o.ReasoningTokens
+	t.CachedTokens += o.CachedTokens
+	t.CacheCreationTokens += o.CacheCreationTokens
+	t.TotalTokens += o.TotalTokens
+	t.EmbeddingTokens += o.EmbeddingTokens
+	t.Images += o.Images
+	return t
+}
+
+func newAggregate(key aggregateKey) *Aggregate {
+	return &Aggregate{
+		Start:     time.Unix(0, key.start),
+		Width:     key.width,
+		API:       key.api,
+		Model:     key.model,
+		Providers: make(map[string]*ProviderAggregate),
+		Errors:    make(map[ErrorKey]int64),
+	}
+}
+
+// NewRetentionPolicy builds the retention policy of the usage-retention configuration.
+func NewRetentionPolicy(cfg config.UsageRetention) RetentionPolicy {
+	return RetentionPolicy{Raw: cfg.Raw, Hourly: cfg.Hourly, Daily: cfg.Daily}
+}
+
+// tierWidth returns the width of the bucket a request of the given age is counted in,
+// or zero when it is older than the retention of every tier.
+func (p RetentionPolicy) tierWidth(age time.Duration) time.Duration {
+	hourly := max(p.Hourly, minuteTierRetention)
+	if p.Hourly <= 0 {
+		hourly = defaultHourlyRetention
+	}
+	switch {
+	case p.Daily > 0 && age > max(p.Daily, hourly):
+		return 0
+	case age > hourly:
+		return 24 * time.Hour
+	case age > minuteTierRetention:
+		return time.Hour
+	default:
+		return AggregateResolution
+	}
+}
+
+// bucketStart aligns ts to the start of its bucket; daily buckets start at local midnight.
+func bucketStart(ts time.Time, width time.Duration) time.Time {
+	if width == 24*time.Hour {
+		year, month, day := ts.Local().Date()
+		return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+	}
+	return ts.Truncate(width)
+}
+
+// SetRetention replaces the retention policy and applies it at once.
+func (s *RequestStatistics) SetRetention(policy RetentionPolicy) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retention = policy
+	s.compact(time.Now())
+}
+
+// aggregateInto folds a detail into the bucket of its tier. Callers must hold s.mu for
+// writing.
+func (s *RequestStatistics) aggregateInto(statsKey, modelName string, detail RequestDetail, now time.Time) {
+	width := s.retention.tierWidth(now.Sub(detail.Timestamp))
+	if width == 0 {
+		return
+	}
+	key := aggregateKey{start: bucketStart(detail.Timestamp, width).UnixNano(), width: width, api: statsKey, model: modelName}
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = newAggregate(key)
+		s.buckets[key] = bucket
+	}
+	bucket.add(detail)
+}
+
+// maybeCompact applies the retention policy at most once an hour. Callers must hold s.mu
+// for writing.
+func (s *RequestStatistics) maybeCompact(now time.Time) {
+	if now.Sub(s.compactedAt) >= time.Hour {
+		s.compact(now)
+	}
+}
+
+// compact merges the buckets that outgrew their tier into the next coarser one, drops the
+// buckets older than every tier and the details older than the raw retention. Callers must
+// hold s.mu for writing.
+func (s *RequestStatistics) compact(now time.Time) {
+	s.compactedAt = now
+	for key, bucket := range s.buckets {
+		width := s.retention.tierWidth(now.Sub(bucket.Start))
+		if width == 0 {
+			delete(s.buckets, key)
+			continue
+		}
+		// Buckets are never split, even when the policy changed to keep finer ones longer.
+		if width <= key.width {
+			continue
+		}
+		delete(s.buckets, key)
+		coarseKey := aggregateKey{start: bucketStart(bucket.Start, width).UnixNano(), width: width, api: key.api, model: key.model}
+		coarse, ok := s.buckets[coarseKey]
+		if !ok {
+			coarse = newAggregate(coarseKey)
+			s.buckets[coarseKey] = coarse
+		}
+		coarse.merge(bucket)
+	}
+
+	if s.retention.Raw <= 0 {
+		return
+	}
+	cutoff := now.Add(-s.retention.Raw)
+	for _, stats := range s.apis {
+		for _, modelStatsValue := range stats.Models {
+			kept := modelStatsValue.Details[:0]
+			for _, detail := range modelStatsValue.Details {
+				if !detail.Timestamp.Before(cutoff) {
+					kept = append(kept, detail)
+				}
+			}
+			clear(modelStatsValue.Details[len(kept):])
+			modelStatsValue.Details = kept
+		}
+	}
+}
+
+// Aggregates returns copies of the buckets overlapping the period from to to; zero bounds
+// leave the period open. The period is resolved to whole buckets of whichever tier holds
+// it: minutes for the last week, then hours and eventually days.
+func (s *RequestStatistics) Aggregates(from, to time.Time) []Aggregate {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Aggregate, 0, len(s.buckets))
+	for _, bucket := range s.buckets {
+		if !from.IsZero() && !bucket.Start.Add(bucket.Width).After(from) {
+			continue
+		}
+		if !to.IsZero() && bucket.Start.After(to) {
+			continue
+		}
+		out = append(out, bucket.clone())
+	}
+	return out
+}
+
+// exportAggregates returns copies of every bucket for persistence.
+func (s *RequestStatistics) exportAggregates() []Aggregate {
+	return s.Aggregates(time.Time{}, time.Time{})
+}
+
+// restoreAggregates replaces the buckets with persisted ones, which cover the requests whose
+// details are no longer kept.
+func (s *RequestStatistics) restoreAggregates(buckets []Aggregate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buckets = make(map[aggregateKey]*Aggregate, len(buckets))
+	for i := range buckets {
+		bucket := buckets[i]
+		key := aggregateKey{start: bucket.Start.UnixNano(), width: bucket.Width, api: bucket.API, model: bucket.Model}
+		if existing, ok := s.buckets[key]; ok {
+			existing.merge(&bucket)
+			continue
+		}
+		s.buckets[key] = &bucket
+	}
+	s.compact(time.Now())
+}
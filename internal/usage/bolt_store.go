@@ -0,0 +1,126 @@
+package usage
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var boltDetailsBucket = []byte("details")
+
+// BoltStore persists request details in a BoltDB file.
+// Keys are the big-endian UnixNano timestamp followed by a sequence number,
+// so cursor order matches chronological order.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore opens (or creates) the BoltDB file at path.
+func NewBoltStore(path string) (*BoltStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("usage: bolt store path is empty")
+	}
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("usage: failed to create bolt store directory: %w", err)
+		}
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("usage: failed to open bolt store: %w", err)
+	}
+	if err = db.Update(func(tx *bolt.Tx) error {
+		_, errCreate := tx.CreateBucketIfNotExists(boltDetailsBucket)
+		return errCreate
+	}); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("usage: failed to initialise bolt store: %w", err)
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// Append implements Store.
+func (b *BoltStore) Append(_ context.Context, details []StoredDetail) error {
+	if len(details) == 0 {
+		return nil
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltDetailsBucket)
+		for i := range details {
+			seq, err := bucket.NextSequence()
+			if err != nil {
+				return err
+			}
+			value, err := json.Marshal(details[i])
+			if err != nil {
+				return err
+			}
+			if err = bucket.Put(boltKey(details[i].Detail.Timestamp, seq), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Load implements Store.
+func (b *BoltStore) Load(_ context.Context, since time.Time) ([]StoredDetail, error) {
+	var out []StoredDetail
+	err := b.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(boltDetailsBucket).Cursor()
+		var k, v []byte
+		if since.IsZero() {
+			k, v = cursor.First()
+		} else {
+			k, v = cursor.Seek(boltKey(since, 0))
+		}
+		for ; k != nil; k, v = cursor.Next() {
+			var item StoredDetail
+			if errUnmarshal := json.Unmarshal(v, &item); errUnmarshal != nil {
+				continue
+			}
+			out = append(out, item)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// Prune implements Store.
+func (b *BoltStore) Prune(_ context.Context, before time.Time) error {
+	limit := boltKey(before, 0)
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltDetailsBucket)
+		// Collect keys first; deleting while advancing a bolt cursor skips entries.
+		var expired [][]byte
+		cursor := bucket.Cursor()
+		for k, _ := cursor.First(); k != nil && bytes.Compare(k, limit) < 0; k, _ = cursor.Next() {
+			expired = append(expired, append([]byte(nil), k...))
+		}
+		for _, k := range expired {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Close implements Store.
+func (b *BoltStore) Close() error {
+	return b.db.Close()
+}
+
+func boltKey(ts time.Time, seq uint64) []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key[:8], uint64(ts.UnixNano()))
+	binary.BigEndian.PutUint64(key[8:], seq)
+	return key
+}
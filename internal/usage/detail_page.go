@@ -0,0 +1,145 @@
+package usage
+
+import (
+	"container/heap"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"sort"
+	"time"
+)
+
+// DetailQuery selects a page of recorded request details, oldest first.
+type DetailQuery struct {
+	// From and To bound the period; zero bounds leave it open.
+	From  time.Time
+	To    time.Time
+	Model string
+	// APIs restricts the page to these client keys; nil includes every key.
+	APIs map[string]struct{}
+	// After is the cursor returned with the previous page; empty starts at the oldest detail.
+	After string
+	// Limit caps the number of details in the page.
+	Limit int
+}
+
+// ErrInvalidCursor is returned for a page cursor that was not produced by DetailPage.
+var ErrInvalidCursor = errors.New("usage: invalid page cursor")
+
+// detailPosition orders details by time, then by client key, model and arrival. The key is
+// hashed so cursors do not reveal it.
+type detailPosition struct {
+	Timestamp int64  `json:"t"`
+	Key       uint64 `json:"k"`
+	Model     string `json:"m"`
+	Index     int    `json:"i"`
+}
+
+func (p detailPosition) less(o detailPosition) bool {
+	if p.Timestamp != o.Timestamp {
+		return p.Timestamp < o.Timestamp
+	}
+	if p.Key != o.Key {
+		return p.Key < o.Key
+	}
+	if p.Model != o.Model {
+		return p.Model < o.Model
+	}
+	return p.Index < o.Index
+}
+
+type pageEntry struct {
+	position detailPosition
+	detail   StoredDetail
+}
+
+// pageHeap is a max-heap of the smallest positions seen so far.
+type pageHeap []pageEntry
+
+func (h pageHeap) Len() int           { return len(h) }
+func (h pageHeap) Less(i, j int) bool { return h[j].position.less(h[i].position) }
+func (h pageHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *pageHeap) Push(x any)        { *h = append(*h, x.(pageEntry)) }
+func (h *pageHeap) Pop() any {
+	old := *h
+	entry := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return entry
+}
+
+// DetailPage returns the next page of details matching q and the cursor of the page after
+// it, which is empty on the last page. Only the page is held in memory, however many
+// details are recorded.
+func (s *RequestStatistics) DetailPage(q DetailQuery) ([]StoredDetail, string, error) {
+	var after *detailPosition
+	if q.After != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(q.After)
+		if err != nil {
+			return nil, "", ErrInvalidCursor
+		}
+		var position detailPosition
+		if err = json.Unmarshal(raw, &position); err != nil {
+			return nil, "", ErrInvalidCursor
+		}
+		after = &position
+	}
+	if s == nil || q.Limit <= 0 {
+		return nil, "", nil
+	}
+
+	s.mu.RLock()
+	page := make(pageHeap, 0, q.Limit+1)
+	for apiName, stats := range s.apis {
+		if q.APIs != nil {
+			if _, ok := q.APIs[apiName]; !ok {
+				continue
+			}
+		}
+		keyHash := fnv.New64a()
+		_, _ = keyHash.Write([]byte(apiName))
+		key := keyHash.Sum64()
+		for modelName, modelStatsValue := range stats.Models {
+			if q.Model != "" && q.Model != modelName {
+				continue
+			}
+			for i, detail := range modelStatsValue.Details {
+				if !q.From.IsZero() && detail.Timestamp.Before(q.From) {
+					continue
+				}
+				if !q.To.IsZero() && detail.Timestamp.After(q.To) {
+					continue
+				}
+				position := detailPosition{Timestamp: detail.Timestamp.UnixNano(), Key: key, Model: modelName, Index: i}
+				if after != nil && !after.less(position) {
+					continue
+				}
+				if len(page) > q.Limit && !position.less(page[0].position) {
+					continue
+				}
+				heap.Push(&page, pageEntry{position: position, detail: StoredDetail{API: apiName, Model: modelName, Detail: detail}})
+				if len(page) > q.Limit+1 {
+					heap.Pop(&page)
+				}
+			}
+		}
+	}
+	s.mu.RUnlock()
+
+	// One entry beyond the limit tells that another page follows.
+	more := len(page) > q.Limit
+	if more {
+		heap.Pop(&page)
+	}
+	sort.Slice(page, func(i, j int) bool { return page[i].position.less(page[j].position) })
+	details := make([]StoredDetail, len(page))
+	for i, entry := range page {
+		details[i] = entry.detail
+	}
+	var next string
+	if more && len(page) > 0 {
+		raw, _ := json.Marshal(page[len(page)-1].position)
+		next = base64.RawURLEncoding.EncodeToString(raw)
+	}
+	return details, next, nil
+}
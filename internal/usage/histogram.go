@@ -0,0 +1,136 @@
+package usage
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+)
+
+// histogramAccuracy is the relative error of the quantiles a Histogram reports.
+const histogramAccuracy = 0.01
+
+// histogramLogGamma is the logarithm of the ratio between the bounds of consecutive buckets.
+var histogramLogGamma = math.Log((1 + histogramAccuracy) / (1 - histogramAccuracy))
+
+// Histogram is a streaming histogram of positive measurements such as durations. Its buckets
+// grow logarithmically, so its size depends on the range of the values rather than on their
+// number, and histograms of adjacent periods merge without loss.
+type Histogram struct {
+	counts map[int]int64
+	total  int64
+	sum    float64
+}
+
+// Add records one value. Values that were not measured (zero or less) are ignored.
+func (h *Histogram) Add(value float64) {
+	if value <= 0 {
+		return
+	}
+	if h.counts == nil {
+		h.counts = make(map[int]int64)
+	}
+	h.counts[int(math.Ceil(math.Log(value)/histogramLogGamma))]++
+	h.total++
+	h.sum += value
+}
+
+// Merge adds the values recorded by other.
+func (h *Histogram) Merge(other Histogram) {
+	if other.total == 0 {
+		return
+	}
+	if h.counts == nil {
+		h.counts = make(map[int]int64, len(other.counts))
+	}
+	for index, count := range other.counts {
+		h.counts[index] += count
+	}
+	h.total += other.total
+	h.sum += other.sum
+}
+
+// Count returns the number of values recorded.
+func (h *Histogram) Count() int64 { return h.total }
+
+// Sum returns the sum of the values recorded.
+func (h *Histogram) Sum() float64 { return h.sum }
+
+// CountAtMost returns the number of values recorded up to bound, within the accuracy of
+// the buckets.
+func (h *Histogram) CountAtMost(bound float64) int64 {
+	if bound <= 0 {
+		return 0
+	}
+	limit := int(math.Ceil(math.Log(bound) / histogramLogGamma))
+	var count int64
+	for index, n := range h.counts {
+		if index <= limit {
+			count += n
+		}
+	}
+	return count
+}
+
+// Quantiles returns the nearest-rank quantiles qs of the recorded values, each within one
+// percent of the true value, or nil when no values were recorded.
+func (h *Histogram) Quantiles(qs ...float64) []float64 {
+	if h.total == 0 {
+		return nil
+	}
+	indexes := make([]int, 0, len(h.counts))
+	for index := range h.counts {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	out := make([]float64, len(qs))
+	for i, q := range qs {
+		rank := max(int64(math.Ceil(q*float64(h.total))), 1)
+		var seen int64
+		for _, index := range indexes {
+			seen += h.counts[index]
+			if seen >= rank {
+				// The midpoint of the bucket is within histogramAccuracy of every value in it.
+				value := 2 * math.Exp(float64(index)*histogramLogGamma) / (1 + math.Exp(histogramLogGamma))
+				out[i] = math.Round(value*10) / 10
+				break
+			}
+		}
+	}
+	return out
+}
+
+// histogramJSON is the persisted form of a Histogram.
+type histogramJSON struct {
+	Counts map[int]int64 `json:"counts,omitempty"`
+	Sum    float64       `json:"sum,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (h Histogram) MarshalJSON() ([]byte, error) {
+	return json.Marshal(histogramJSON{Counts: h.counts, Sum: h.sum})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (h *Histogram) UnmarshalJSON(data []byte) error {
+	var raw histogramJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*h = Histogram{counts: raw.Counts, sum: raw.Sum}
+	for _, count := range raw.Counts {
+		h.total += count
+	}
+	return nil
+}
+
+func (h Histogram) clone() Histogram {
+	out := Histogram{total: h.total, sum: h.sum}
+	if h.counts != nil {
+		out.counts = make(map[int]int64, len(h.counts))
+		for index, count := range h.counts {
+			out.counts[index] = count
+		}
+	}
+	return out
+}
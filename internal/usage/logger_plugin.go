@@ -66,6 +66,7 @@ type RequestStatistics struct {
 	successCount  int64
 	failureCount  int64
 	totalTokens   int64
+	totalImages   int64
 
 	apis map[string]*apiStats
 
@@ -73,6 +74,20 @@ type RequestStatistics struct {
 	requestsByHour map[int]int64
 	tokensByDay    map[string]int64
 	tokensByHour   map[int]int64
+
+	// buckets pre-aggregates the details per client key, model and tier bucket for metrics
+	// queries; compactedAt is when the retention policy was last applied.
+	buckets     map[aggregateKey]*Aggregate
+	retention   RetentionPolicy
+	compactedAt time.Time
+
+	// trackPending enables buffering of new details for a persistence backend. A failed
+	// flush puts the details back, keeping at most maxPending of them; droppedPending counts
+	// the details dropped beyond that.
+	trackPending   bool
+	pending        []StoredDetail
+	maxPending     int
+	droppedPending int64
 }
 
 // apiStats holds aggregated metrics for a single API key.
@@ -86,6 +101,7 @@ type apiStats struct {
 type modelStats struct {
 	TotalRequests int64
 	TotalTokens   int64
+	TotalImages   int64
 	Details       []RequestDetail
 }
 
@@ -97,6 +113,22 @@ type RequestDetail struct {
 	Failed    bool       `json:"failed"`
 	RequestID string     `json:"request_id,omitempty"`
 	LatencyMS int64      `json:"latency_ms,omitempty"`
+	// TTFTMS is the time to the first streamed chunk; zero for non-streaming requests.
+	TTFTMS int64 `json:"ttft_ms,omitempty"`
+	// TokensPerSecond is the output throughput measured from the first streamed chunk onwards.
+	TokensPerSecond float64 `json:"tokens_per_second,omitempty"`
+	// Retry marks requests made by a retry attempt rather than the original request.
+	Retry bool `json:"retry,omitempty"`
+	// Split and SplitVariant record the traffic split that routed the request and the model
+	// it was assigned to.
+	Split        string `json:"split,omitempty"`
+	SplitVariant string `json:"split_variant,omitempty"`
+	// Estimated marks token counts estimated locally because the upstream reported no usage.
+	Estimated bool `json:"estimated,omitempty"`
+	// Provider is the upstream provider that served or failed the request.
+	Provider string `json:"provider,omitempty"`
+	// ErrorCode is the proxy error code of a failed request.
+	ErrorCode string `json:"error_code,omitempty"`
 }
 
 // TokenStats captures the token usage breakdown for a request.
@@ -105,7 +137,11 @@ type TokenStats struct {
 	OutputTokens    int64 `json:"output_tokens"`
 	ReasoningTokens int64 `json:"reasoning_tokens"`
 	CachedTokens    int64 `json:"cached_tokens"`
-	TotalTokens     int64 `json:"total_tokens"`
+	// CacheCreationTokens counts input tokens written to the prompt cache.
+	CacheCreationTokens int64 `json:"cache_creation_tokens,omitempty"`
+	TotalTokens         int64 `json:"total_tokens"`
+	EmbeddingTokens     int64 `json:"embedding_tokens,omitempty"`
+	Images              int64 `json:"images,omitempty"`
 }
 
 // StatisticsSnapshot represents an immutable view of the aggregated metrics.
@@ -114,6 +150,7 @@ type StatisticsSnapshot struct {
 	SuccessCount  int64 `json:"success_count"`
 	FailureCount  int64 `json:"failure_count"`
 	TotalTokens   int64 `json:"total_tokens"`
+	TotalImages   int64 `json:"total_images,omitempty"`
 
 	APIs map[string]APISnapshot `json:"apis"`
 
@@ -134,6 +171,7 @@ type APISnapshot struct {
 type ModelSnapshot struct {
 	TotalRequests int64           `json:"total_requests"`
 	TotalTokens   int64           `json:"total_tokens"`
+	TotalImages   int64           `json:"total_images,omitempty"`
 	Details       []RequestDetail `json:"details"`
 }
 
@@ -150,6 +188,7 @@ func NewRequestStatistics() *RequestStatistics {
 		requestsByHour: make(map[int]int64),
 		tokensByDay:    make(map[string]int64),
 		tokensByHour:   make(map[int]int64),
+		buckets:        make(map[aggregateKey]*Aggregate),
 	}
 }
 
@@ -166,8 +205,19 @@ func (s *RequestStatistics) Record(ctx context.Context, record coreusage.Record)
 		timestamp = time.Now()
 	}
 	detail := normaliseDetail(record.Detail)
-	totalTokens := detail.TotalTokens
-	latency := time.Since(record.RequestedAt)
+	now := time.Now()
+	var latency time.Duration
+	if !record.RequestedAt.IsZero() {
+		latency = now.Sub(record.RequestedAt)
+	}
+	var ttft time.Duration
+	var tokensPerSecond float64
+	if !record.FirstChunkAt.IsZero() {
+		ttft = record.FirstChunkAt.Sub(record.RequestedAt)
+		if generation := now.Sub(record.FirstChunkAt); generation > 0 && detail.OutputTokens > 0 {
+			tokensPerSecond = float64(detail.OutputTokens) / generation.Seconds()
+		}
+	}
 
 	statsKey := record.APIKey
 	var requestID string
@@ -190,38 +240,60 @@ func (s *RequestStatistics) Record(ctx context.Context, record coreusage.Record)
 	if !failed {
 		failed = !resolveSuccess(ctx)
 	}
-	success := !failed
 	modelName := record.Model
 	if modelName == "" {
 		modelName = "unknown"
 	}
-	dayKey := timestamp.Format("2006-01-02")
-	hourKey := timestamp.Hour()
+	requestDetail := RequestDetail{
+		Timestamp:       timestamp,
+		Source:          record.Source,
+		Tokens:          detail,
+		Failed:          failed,
+		RequestID:       requestID,
+		LatencyMS:       latency.Milliseconds(),
+		TTFTMS:          ttft.Milliseconds(),
+		TokensPerSecond: tokensPerSecond,
+		Retry:           record.Retry,
+		Split:           record.Split,
+		SplitVariant:    record.SplitVariant,
+		Estimated:       record.Estimated,
+		Provider:        record.Provider,
+		ErrorCode:       record.ErrorCode,
+	}
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	s.aggregate(statsKey, modelName, requestDetail)
+	s.maybeCompact(now)
+	if s.trackPending {
+		s.pending = append(s.pending, StoredDetail{API: statsKey, Model: modelName, Detail: requestDetail})
+	}
+}
+
+// aggregate folds a single request detail into the in-memory aggregates.
+// Callers must hold s.mu for writing.
+func (s *RequestStatistics) aggregate(statsKey, modelName string, detail RequestDetail) {
+	totalTokens := detail.Tokens.TotalTokens
+	dayKey := detail.Timestamp.Format("2006-01-02")
+	hourKey := detail.Timestamp.Hour()
+
 	s.totalRequests++
-	if success {
-		s.successCount++
-	} else {
+	if detail.Failed {
 		s.failureCount++
+	} else {
+		s.successCount++
 	}
 	s.totalTokens += totalTokens
+	s.totalImages += detail.Tokens.Images
 
 	stats, ok := s.apis[statsKey]
 	if !ok {
 		stats = &apiStats{Models: make(map[string]*modelStats)}
 		s.apis[statsKey] = stats
 	}
-	s.updateAPIStats(stats, modelName, RequestDetail{
-		Timestamp: timestamp,
-		Source:    record.Source,
-		Tokens:    detail,
-		Failed:    failed,
-		RequestID: requestID,
-		LatencyMS: latency.Milliseconds(),
-	})
+	s.updateAPIStats(stats, modelName, detail)
+	s.aggregateInto(statsKey, modelName, detail, time.Now())
 
 	s.requestsByDay[dayKey]++
 	s.requestsByHour[hourKey]++
@@ -239,7 +311,12 @@ func (s *RequestStatistics) updateAPIStats(stats *apiStats, model string, detail
 	}
 	modelStatsValue.TotalRequests++
 	modelStatsValue.TotalTokens += detail.Tokens.TotalTokens
-	modelStatsValue.Details = append(modelStatsValue.Details, detail)
+	modelStatsValue.TotalImages += detail.Tokens.Images
+	// Details already past the raw retention, such as replayed history, only count in the
+	// aggregates.
+	if s.retention.Raw <= 0 || time.Since(detail.Timestamp) <= s.retention.Raw {
+		modelStatsValue.Details = append(modelStatsValue.Details, detail)
+	}
 }
 
 // Snapshot returns a copy of the aggregated metrics for external consumption.
@@ -256,6 +333,7 @@ func (s *RequestStatistics) Snapshot() StatisticsSnapshot {
 	result.SuccessCount = s.successCount
 	result.FailureCount = s.failureCount
 	result.TotalTokens = s.totalTokens
+	result.TotalImages = s.totalImages
 
 	result.APIs = make(map[string]APISnapshot, len(s.apis))
 	for apiName, stats := range s.apis {
@@ -270,6 +348,7 @@ func (s *RequestStatistics) Snapshot() StatisticsSnapshot {
 			apiSnapshot.Models[modelName] = ModelSnapshot{
 				TotalRequests: modelStatsValue.TotalRequests,
 				TotalTokens:   modelStatsValue.TotalTokens,
+				TotalImages:   modelStatsValue.TotalImages,
 				Details:       requestDetails,
 			}
 		}
@@ -343,14 +422,17 @@ const httpStatusBadRequest = 400
 
 func normaliseDetail(detail coreusage.Detail) TokenStats {
 	tokens := TokenStats{
-		InputTokens:     detail.InputTokens,
-		OutputTokens:    detail.OutputTokens,
-		ReasoningTokens: detail.ReasoningTokens,
-		CachedTokens:    detail.CachedTokens,
-		TotalTokens:     detail.TotalTokens,
+		InputTokens:         detail.InputTokens,
+		OutputTokens:        detail.OutputTokens,
+		ReasoningTokens:     detail.ReasoningTokens,
+		CachedTokens:        detail.CachedTokens,
+		CacheCreationTokens: detail.CacheCreationTokens,
+		TotalTokens:         detail.TotalTokens,
+		EmbeddingTokens:     detail.EmbeddingTokens,
+		Images:              detail.Images,
 	}
 	if tokens.TotalTokens == 0 {
-		tokens.TotalTokens = detail.InputTokens + detail.OutputTokens + detail.ReasoningTokens
+		tokens.TotalTokens = detail.InputTokens + detail.OutputTokens + detail.ReasoningTokens + detail.EmbeddingTokens
 	}
 	if tokens.TotalTokens == 0 {
 		tokens.TotalTokens = detail.InputTokens + detail.OutputTokens + detail.ReasoningTokens + detail.CachedTokens
@@ -373,12 +455,22 @@ func LoadMetricsFromFile(filePath string) {
 		return
 	}
 
-	var snapshot StatisticsSnapshot
+	var snapshot persistedSnapshot
 	if err := json.Unmarshal(data, &snapshot); err != nil {
 		return
 	}
 
-	defaultRequestStatistics.LoadFromSnapshot(&snapshot)
+	defaultRequestStatistics.LoadFromSnapshot(&snapshot.StatisticsSnapshot)
+	if len(snapshot.Aggregates) > 0 {
+		defaultRequestStatistics.restoreAggregates(snapshot.Aggregates)
+	}
+}
+
+// persistedSnapshot is the format of the metrics file: the snapshot plus the aggregate
+// buckets, which outlive the details kept under a raw retention.
+type persistedSnapshot struct {
+	StatisticsSnapshot
+	Aggregates []Aggregate `json:"aggregates,omitempty"`
 }
 
 // LoadFromSnapshot populates the in-memory statistics from a snapshot.
@@ -394,6 +486,7 @@ func (s *RequestStatistics) LoadFromSnapshot(snapshot *StatisticsSnapshot) {
 	s.successCount = snapshot.SuccessCount
 	s.failureCount = snapshot.FailureCount
 	s.totalTokens = snapshot.TotalTokens
+	s.totalImages = snapshot.TotalImages
 	s.requestsByDay = snapshot.RequestsByDay
 	s.tokensByDay = snapshot.TokensByDay
 	s.requestsByHour = make(map[int]int64)
@@ -410,6 +503,8 @@ func (s *RequestStatistics) LoadFromSnapshot(snapshot *StatisticsSnapshot) {
 	}
 
 	s.apis = make(map[string]*apiStats)
+	s.buckets = make(map[aggregateKey]*Aggregate)
+	now := time.Now()
 	for apiKey, apiSnap := range snapshot.APIs {
 		apiStat := &apiStats{
 			TotalRequests: apiSnap.TotalRequests,
@@ -420,20 +515,25 @@ func (s *RequestStatistics) LoadFromSnapshot(snapshot *StatisticsSnapshot) {
 			modelStat := &modelStats{
 				TotalRequests: modelSnap.TotalRequests,
 				TotalTokens:   modelSnap.TotalTokens,
+				TotalImages:   modelSnap.TotalImages,
 				Details:       make([]RequestDetail, len(modelSnap.Details)),
 			}
 			copy(modelStat.Details, modelSnap.Details)
+			for _, detail := range modelStat.Details {
+				s.aggregateInto(apiKey, modelName, detail, now)
+			}
 			apiStat.Models[modelName] = modelStat
 		}
 		s.apis[apiKey] = apiStat
 	}
+	s.compact(now)
 }
 
 func (s *RequestStatistics) saveSnapshotToFile(filePath string) error {
 	if s == nil {
 		return fmt.Errorf("statistics store is nil")
 	}
-	snapshot := s.Snapshot()
+	snapshot := persistedSnapshot{StatisticsSnapshot: s.Snapshot(), Aggregates: s.exportAggregates()}
 	data, err := json.MarshalIndent(snapshot, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal metrics snapshot: %w", err)
@@ -0,0 +1,82 @@
+package usage
+
+import (
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// PriceTable resolves per-model token prices used to estimate spend.
+// Exact model names take precedence over prefix patterns ending in "*";
+// among prefix patterns the longest match wins.
+type PriceTable struct {
+	exact    map[string]config.ModelPrice
+	prefixes []config.ModelPrice
+}
+
+// NewPriceTable builds a lookup table from the configured pricing entries.
+func NewPriceTable(entries []config.ModelPrice) *PriceTable {
+	table := &PriceTable{exact: make(map[string]config.ModelPrice, len(entries))}
+	for _, entry := range entries {
+		name := strings.TrimSpace(entry.Model)
+		if name == "" {
+			continue
+		}
+		if strings.HasSuffix(name, "*") {
+			entry.Model = strings.TrimSuffix(name, "*")
+			table.prefixes = append(table.prefixes, entry)
+			continue
+		}
+		table.exact[name] = entry
+	}
+	return table
+}
+
+// Lookup returns the price entry for a model, if any.
+func (t *PriceTable) Lookup(model string) (config.ModelPrice, bool) {
+	if t == nil {
+		return config.ModelPrice{}, false
+	}
+	if price, ok := t.exact[model]; ok {
+		return price, true
+	}
+	var best config.ModelPrice
+	found := false
+	for _, candidate := range t.prefixes {
+		if strings.HasPrefix(model, candidate.Model) && (!found || len(candidate.Model) > len(best.Model)) {
+			best = candidate
+			found = true
+		}
+	}
+	return best, found
+}
+
+// EstimateCost returns the estimated spend for a request's token usage.
+// Cached input tokens are billed at the cached rate (falling back to the input rate),
+// and reasoning tokens at the reasoning rate (falling back to the output rate).
+// Generated images are billed per image on top of any token cost.
+// Models without a price entry cost zero.
+func (t *PriceTable) EstimateCost(model string, tokens TokenStats) float64 {
+	price, ok := t.Lookup(model)
+	if !ok {
+		return 0
+	}
+	cachedRate := price.InputPerMillion
+	if price.CachedPerMillion != nil {
+		cachedRate = *price.CachedPerMillion
+	}
+	reasoningRate := price.OutputPerMillion
+	if price.ReasoningPerMillion != nil {
+		reasoningRate = *price.ReasoningPerMillion
+	}
+	uncached := tokens.InputTokens - tokens.CachedTokens
+	if uncached < 0 {
+		uncached = 0
+	}
+	cost := float64(uncached)*price.InputPerMillion +
+		float64(tokens.CachedTokens)*cachedRate +
+		float64(tokens.OutputTokens)*price.OutputPerMillion +
+		float64(tokens.ReasoningTokens)*reasoningRate +
+		float64(tokens.EmbeddingTokens)*price.InputPerMillion
+	return cost/1_000_000 + float64(tokens.Images)*price.PerImage
+}
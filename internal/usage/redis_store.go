@@ -0,0 +1,163 @@
+package usage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/redis"
+)
+
+// redisPageSize bounds the number of stream entries read per XRANGE call.
+const redisPageSize = 1000
+
+// defaultRedisMaxEntries is the stream length cap used when none is configured.
+const defaultRedisMaxEntries = 1000000
+
+// SharedStore is a Store written by several proxy instances at once.
+type SharedStore interface {
+	Store
+	// Pull returns the details appended by other instances since the last Load or Pull.
+	Pull(ctx context.Context) ([]StoredDetail, error)
+}
+
+// RedisStore persists request details in a Redis stream shared by all proxy instances.
+// Each entry records the instance that wrote it, so an instance can replay the details
+// of its peers without counting its own twice. Stream IDs follow insertion order, which
+// lets Pull resume exactly where the previous read stopped. The stream is capped at about
+// maxEntries entries, so it stays bounded even when no retention is configured.
+type RedisStore struct {
+	client     *redis.Client
+	key        string
+	instance   string
+	lastID     string
+	maxEntries int64
+}
+
+// NewRedisStore returns a store writing to the usage stream of client, keeping about
+// maxEntries details; zero or less uses defaultRedisMaxEntries.
+func NewRedisStore(client *redis.Client, maxEntries int64) (*RedisStore, error) {
+	if client == nil {
+		return nil, fmt.Errorf("usage: redis client is nil")
+	}
+	if maxEntries <= 0 {
+		maxEntries = defaultRedisMaxEntries
+	}
+	return &RedisStore{client: client, key: client.KeyPrefix() + "usage", instance: newInstanceID(), maxEntries: maxEntries}, nil
+}
+
+// newInstanceID identifies this process among the instances sharing the stream.
+func newInstanceID() string {
+	host, _ := os.Hostname()
+	var suffix [4]byte
+	_, _ = rand.Read(suffix[:])
+	return host + "-" + strconv.Itoa(os.Getpid()) + "-" + hex.EncodeToString(suffix[:])
+}
+
+// Append implements Store.
+func (r *RedisStore) Append(ctx context.Context, details []StoredDetail) error {
+	if len(details) == 0 {
+		return nil
+	}
+	commands := make([][]string, 0, len(details))
+	maxEntries := strconv.FormatInt(r.maxEntries, 10)
+	for i := range details {
+		value, err := json.Marshal(details[i])
+		if err != nil {
+			return err
+		}
+		commands = append(commands, []string{"XADD", r.key, "MAXLEN", "~", maxEntries, "*", "instance", r.instance, "detail", string(value)})
+	}
+	replies, err := r.client.Pipeline(ctx, commands...)
+	if err != nil {
+		return err
+	}
+	for _, reply := range replies {
+		if replyErr, ok := reply.(redis.Error); ok {
+			return replyErr
+		}
+	}
+	return nil
+}
+
+// Load implements Store. It returns the details of every instance and positions Pull after them.
+func (r *RedisStore) Load(ctx context.Context, since time.Time) ([]StoredDetail, error) {
+	start := "-"
+	if !since.IsZero() {
+		start = strconv.FormatInt(since.UnixMilli(), 10)
+	}
+	return r.read(ctx, start, true)
+}
+
+// Pull implements SharedStore.
+func (r *RedisStore) Pull(ctx context.Context) ([]StoredDetail, error) {
+	start := "-"
+	if r.lastID != "" {
+		start = "(" + r.lastID
+	}
+	return r.read(ctx, start, false)
+}
+
+// read pages through the stream from start, skipping this instance's entries unless own is set.
+func (r *RedisStore) read(ctx context.Context, start string, own bool) ([]StoredDetail, error) {
+	var out []StoredDetail
+	for {
+		reply, err := r.client.Do(ctx, "XRANGE", r.key, start, "+", "COUNT", strconv.Itoa(redisPageSize))
+		if err != nil {
+			return out, err
+		}
+		entries, _ := reply.([]any)
+		for _, item := range entries {
+			id, fields := parseStreamEntry(item)
+			if id == "" {
+				continue
+			}
+			r.lastID = id
+			if !own && fields["instance"] == r.instance {
+				continue
+			}
+			var detail StoredDetail
+			if errUnmarshal := json.Unmarshal([]byte(fields["detail"]), &detail); errUnmarshal != nil {
+				continue
+			}
+			out = append(out, detail)
+		}
+		if len(entries) < redisPageSize || r.lastID == "" {
+			return out, nil
+		}
+		start = "(" + r.lastID
+	}
+}
+
+// parseStreamEntry decodes one XRANGE entry into its ID and field map.
+func parseStreamEntry(item any) (string, map[string]string) {
+	entry, ok := item.([]any)
+	if !ok || len(entry) != 2 {
+		return "", nil
+	}
+	id, _ := entry[0].(string)
+	values, _ := entry[1].([]any)
+	fields := make(map[string]string, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		name, _ := values[i].(string)
+		value, _ := values[i+1].(string)
+		fields[name] = value
+	}
+	return id, fields
+}
+
+// Prune implements Store. Entries are trimmed by the time they were appended.
+func (r *RedisStore) Prune(ctx context.Context, before time.Time) error {
+	_, err := r.client.Do(ctx, "XTRIM", r.key, "MINID", strconv.FormatInt(before.UnixMilli(), 10))
+	return err
+}
+
+// Close implements Store.
+func (r *RedisStore) Close() error {
+	return r.client.Close()
+}
@@ -0,0 +1,271 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// StoredDetail couples a request detail with the aggregation keys required to rebuild snapshots.
+type StoredDetail struct {
+	API    string        `json:"api"`
+	Model  string        `json:"model"`
+	Detail RequestDetail `json:"detail"`
+}
+
+// Store persists request details so statistics survive restarts.
+// Implementations must be safe for use by a single flushing goroutine.
+type Store interface {
+	// Append durably writes the provided details.
+	Append(ctx context.Context, details []StoredDetail) error
+	// Load returns all persisted details with a timestamp at or after since, ordered by time.
+	Load(ctx context.Context, since time.Time) ([]StoredDetail, error)
+	// Prune removes persisted details older than before.
+	Prune(ctx context.Context, before time.Time) error
+	// Close releases resources held by the store.
+	Close() error
+}
+
+// StoreOptions controls how a persistence backend is flushed and pruned.
+type StoreOptions struct {
+	// FlushInterval is how often pending details are written to the store.
+	FlushInterval time.Duration
+	// Retention bounds how long details are kept; zero keeps them forever.
+	Retention time.Duration
+	// MaxPending bounds how many unflushed details are kept while the store is failing;
+	// the oldest are dropped beyond it. Zero uses defaultMaxPending.
+	MaxPending int
+	// CrashOnError panics when a periodic flush fails instead of logging the error.
+	CrashOnError bool
+}
+
+// defaultMaxPending is the pending detail limit used when StoreOptions.MaxPending is zero.
+const defaultMaxPending = 100000
+
+// NewStore constructs a persistence backend by type name.
+// Supported types: "bolt" (alias "boltdb"). The Redis store is shared between instances
+// and is created with NewRedisStore instead.
+func NewStore(kind, path string) (Store, error) {
+	switch strings.ToLower(strings.TrimSpace(kind)) {
+	case "bolt", "boltdb":
+		return NewBoltStore(path)
+	default:
+		return nil, fmt.Errorf("usage: unsupported store type %q", kind)
+	}
+}
+
+// Replay folds previously persisted details into the aggregates without re-queuing them for persistence.
+func (s *RequestStatistics) Replay(details []StoredDetail) {
+	if s == nil || len(details) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, item := range details {
+		s.aggregate(item.API, item.Model, item.Detail)
+	}
+	s.maybeCompact(time.Now())
+}
+
+// Details returns every recorded request detail with its aggregation keys, oldest first.
+func (s *RequestStatistics) Details() []StoredDetail {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []StoredDetail
+	for apiName, stats := range s.apis {
+		for modelName, modelStatsValue := range stats.Models {
+			for _, detail := range modelStatsValue.Details {
+				out = append(out, StoredDetail{API: apiName, Model: modelName, Detail: detail})
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Detail.Timestamp.Before(out[j].Detail.Timestamp) })
+	return out
+}
+
+// Import folds details recorded by another instance into the aggregates and queues them for
+// persistence. Details whose request ID is already recorded are skipped, so importing the
+// same history twice does not count it twice. It returns the number of details added.
+func (s *RequestStatistics) Import(details []StoredDetail) int {
+	if s == nil || len(details) == 0 {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seen := make(map[string]struct{})
+	for _, stats := range s.apis {
+		for _, modelStatsValue := range stats.Models {
+			for _, detail := range modelStatsValue.Details {
+				if detail.RequestID != "" {
+					seen[detail.RequestID] = struct{}{}
+				}
+			}
+		}
+	}
+	added := 0
+	for _, item := range details {
+		if id := item.Detail.RequestID; id != "" {
+			if _, dup := seen[id]; dup {
+				continue
+			}
+			seen[id] = struct{}{}
+		}
+		s.aggregate(item.API, item.Model, item.Detail)
+		if s.trackPending {
+			s.pending = append(s.pending, item)
+		}
+		added++
+	}
+	s.maybeCompact(time.Now())
+	return added
+}
+
+// EnablePendingTracking starts buffering newly recorded details for a persistence backend.
+// At most maxPending details are kept when flushes fail; zero or less keeps them all.
+func (s *RequestStatistics) EnablePendingTracking(maxPending int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.trackPending = true
+	s.maxPending = maxPending
+	s.mu.Unlock()
+}
+
+// DroppedPendingDetails returns how many details were dropped without being persisted
+// because the pending buffer was full.
+func (s *RequestStatistics) DroppedPendingDetails() int64 {
+	if s == nil {
+		return 0
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.droppedPending
+}
+
+// drainPending returns and clears the details recorded since the last drain.
+func (s *RequestStatistics) drainPending() []StoredDetail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pending) == 0 {
+		return nil
+	}
+	out := s.pending
+	s.pending = nil
+	return out
+}
+
+// requeuePending puts details back at the front of the pending buffer after a failed flush.
+// The oldest details beyond the pending limit are dropped; it returns how many were.
+func (s *RequestStatistics) requeuePending(details []StoredDetail) int {
+	if len(details) == 0 {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = append(details, s.pending...)
+	dropped := 0
+	if s.maxPending > 0 && len(s.pending) > s.maxPending {
+		dropped = len(s.pending) - s.maxPending
+		s.pending = append([]StoredDetail(nil), s.pending[dropped:]...)
+		s.droppedPending += int64(dropped)
+	}
+	return dropped
+}
+
+// StartStorePersistence restores the default statistics from store and starts a background
+// goroutine that periodically flushes new details and prunes expired ones. The final flush
+// and store shutdown happen when StopMetricsPersistence is called.
+func StartStorePersistence(store Store, opts StoreOptions) error {
+	if store == nil {
+		return fmt.Errorf("usage: store is nil")
+	}
+	interval := opts.FlushInterval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	var since time.Time
+	if opts.Retention > 0 {
+		since = time.Now().Add(-opts.Retention)
+	}
+	details, err := store.Load(context.Background(), since)
+	if err != nil {
+		return fmt.Errorf("usage: failed to load persisted details: %w", err)
+	}
+	defaultRequestStatistics.Replay(details)
+	maxPending := opts.MaxPending
+	if maxPending <= 0 {
+		maxPending = defaultMaxPending
+	}
+	defaultRequestStatistics.EnablePendingTracking(maxPending)
+	log.Infof("usage store restored %d request details", len(details))
+
+	flush := func(crashOnError bool) {
+		pending := defaultRequestStatistics.drainPending()
+		if len(pending) == 0 {
+			return
+		}
+		errAppend := store.Append(context.Background(), pending)
+		if errAppend == nil {
+			return
+		}
+		if crashOnError {
+			panic(fmt.Sprintf("failed to flush usage details: %v", errAppend))
+		}
+		log.Errorf("failed to flush usage details: %v", errAppend)
+		if dropped := defaultRequestStatistics.requeuePending(pending); dropped > 0 {
+			log.Errorf("usage store pending buffer is full: dropped %d details (%d in total)", dropped, defaultRequestStatistics.DroppedPendingDetails())
+		}
+	}
+	prune := func() {
+		if opts.Retention <= 0 {
+			return
+		}
+		if errPrune := store.Prune(context.Background(), time.Now().Add(-opts.Retention)); errPrune != nil {
+			log.Warnf("failed to prune usage store: %v", errPrune)
+		}
+	}
+	prune()
+	pull := func() {}
+	if shared, ok := store.(SharedStore); ok {
+		// Fold in what the other instances recorded so every instance reports the same totals.
+		pull = func() {
+			details, errPull := shared.Pull(context.Background())
+			defaultRequestStatistics.Replay(details)
+			if errPull != nil {
+				log.Warnf("failed to pull shared usage details: %v", errPull)
+			}
+		}
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				flush(opts.CrashOnError)
+				pull()
+				prune()
+			case <-shutdownChan:
+				flush(false)
+				if errClose := store.Close(); errClose != nil {
+					log.Errorf("failed to close usage store: %v", errClose)
+				}
+				return
+			}
+		}
+	}()
+	return nil
+}
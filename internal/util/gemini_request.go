@@ -0,0 +1,123 @@
+package util
+
+import (
+	"fmt"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// The Gemini REST API accepts both snake_case and lowerCamelCase field names.
+// These tables list the structural fields that are normalised to lowerCamelCase;
+// user supplied payloads such as function arguments and schemas are left untouched.
+var (
+	geminiRequestFields = map[string]string{
+		"system_instruction": "systemInstruction",
+		"generation_config":  "generationConfig",
+		"safety_settings":    "safetySettings",
+		"tool_config":        "toolConfig",
+		"cached_content":     "cachedContent",
+	}
+	geminiGenerationConfigFields = map[string]string{
+		"max_output_tokens":    "maxOutputTokens",
+		"top_p":                "topP",
+		"top_k":                "topK",
+		"stop_sequences":       "stopSequences",
+		"candidate_count":      "candidateCount",
+		"presence_penalty":     "presencePenalty",
+		"frequency_penalty":    "frequencyPenalty",
+		"response_mime_type":   "responseMimeType",
+		"response_schema":      "responseSchema",
+		"response_json_schema": "responseJsonSchema",
+		"response_modalities":  "responseModalities",
+		"thinking_config":      "thinkingConfig",
+	}
+	geminiThinkingConfigFields = map[string]string{
+		"thinking_budget":  "thinkingBudget",
+		"include_thoughts": "includeThoughts",
+	}
+	geminiPartFields = map[string]string{
+		"inline_data":           "inlineData",
+		"file_data":             "fileData",
+		"function_call":         "functionCall",
+		"function_response":     "functionResponse",
+		"executable_code":       "executableCode",
+		"code_execution_result": "codeExecutionResult",
+	}
+	geminiBlobFields = map[string]string{
+		"mime_type": "mimeType",
+		"file_uri":  "fileUri",
+	}
+	geminiToolFields = map[string]string{
+		"function_declarations":   "functionDeclarations",
+		"google_search":           "googleSearch",
+		"google_search_retrieval": "googleSearchRetrieval",
+		"code_execution":          "codeExecution",
+		"url_context":             "urlContext",
+	}
+	geminiToolConfigFields = map[string]string{
+		"function_calling_config": "functionCallingConfig",
+	}
+	geminiFunctionCallingConfigFields = map[string]string{
+		"allowed_function_names": "allowedFunctionNames",
+	}
+)
+
+// NormalizeGeminiRequest rewrites the snake_case field names of a Gemini generateContent
+// request to their lowerCamelCase form so translators only need to handle one spelling.
+func NormalizeGeminiRequest(rawJSON []byte) []byte {
+	out := string(rawJSON)
+	out = renameGeminiFields(out, "", geminiRequestFields)
+
+	out = renameGeminiFields(out, "generationConfig", geminiGenerationConfigFields)
+	out = renameGeminiFields(out, "generationConfig.thinkingConfig", geminiThinkingConfigFields)
+
+	out = normalizeGeminiContent(out, "systemInstruction")
+	for i := range gjson.Get(out, "contents").Array() {
+		out = normalizeGeminiContent(out, fmt.Sprintf("contents.%d", i))
+	}
+
+	for i := range gjson.Get(out, "tools").Array() {
+		out = renameGeminiFields(out, fmt.Sprintf("tools.%d", i), geminiToolFields)
+	}
+	out = renameGeminiFields(out, "toolConfig", geminiToolConfigFields)
+	out = renameGeminiFields(out, "toolConfig.functionCallingConfig", geminiFunctionCallingConfigFields)
+	return []byte(out)
+}
+
+func normalizeGeminiContent(jsonStr, contentPath string) string {
+	for i := range gjson.Get(jsonStr, contentPath+".parts").Array() {
+		partPath := fmt.Sprintf("%s.parts.%d", contentPath, i)
+		jsonStr = renameGeminiFields(jsonStr, partPath, geminiPartFields)
+		jsonStr = renameGeminiFields(jsonStr, partPath+".inlineData", geminiBlobFields)
+		jsonStr = renameGeminiFields(jsonStr, partPath+".fileData", geminiBlobFields)
+	}
+	return jsonStr
+}
+
+// renameGeminiFields renames the fields of the object at path according to names.
+// A field is only renamed when its lowerCamelCase counterpart is absent.
+func renameGeminiFields(jsonStr, path string, names map[string]string) string {
+	object := gjson.Parse(jsonStr)
+	if path != "" {
+		object = gjson.Get(jsonStr, path)
+	}
+	if !object.IsObject() {
+		return jsonStr
+	}
+	prefix := ""
+	if path != "" {
+		prefix = path + "."
+	}
+	for snake, camel := range names {
+		value := object.Get(snake)
+		if !value.Exists() {
+			continue
+		}
+		if !object.Get(camel).Exists() {
+			jsonStr, _ = sjson.SetRaw(jsonStr, prefix+camel, value.Raw)
+		}
+		jsonStr, _ = sjson.Delete(jsonStr, prefix+snake)
+	}
+	return jsonStr
+}
@@ -0,0 +1,433 @@
+package util
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Defaults of the remote media fetch limits.
+const (
+	defaultMediaMaxImages     = 8
+	defaultMediaMaxImageBytes = 20 << 20
+	defaultMediaMaxTotalBytes = 50 << 20
+	defaultMediaTimeout       = 30 * time.Second
+	maxMediaRedirects         = 5
+)
+
+var (
+	mediaClientMu sync.RWMutex
+	mediaClient   *http.Client
+	mediaLimits   config.MediaFetchConfig
+)
+
+// reservedBlocks lists the non-public ranges the standard library does not classify.
+var reservedBlocks = func() []*net.IPNet {
+	var blocks []*net.IPNet
+	for _, cidr := range []string{"0.0.0.0/8", "100.64.0.0/10", "192.0.0.0/24", "192.0.2.0/24", "198.18.0.0/15", "198.51.100.0/24", "203.0.113.0/24", "240.0.0.0/4", "64:ff9b::/96", "2001:db8::/32"} {
+		if _, block, err := net.ParseCIDR(cidr); err == nil {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}()
+
+// ConfigureMediaFetch applies the remote media fetch settings and routes fetches through
+// the configured proxy. Fetching stays off unless media-fetch.enable is set.
+//
+// Parameters:
+//   - cfg: The SDK configuration holding the media fetch settings and proxy URL
+func ConfigureMediaFetch(cfg *config.SDKConfig) {
+	var limits config.MediaFetchConfig
+	if cfg != nil {
+		limits = cfg.MediaFetch
+	}
+	if limits.MaxImages <= 0 {
+		limits.MaxImages = defaultMediaMaxImages
+	}
+	if limits.MaxImageBytes <= 0 {
+		limits.MaxImageBytes = defaultMediaMaxImageBytes
+	}
+	if limits.MaxTotalBytes <= 0 {
+		limits.MaxTotalBytes = defaultMediaMaxTotalBytes
+	}
+	if limits.Timeout <= 0 {
+		limits.Timeout = defaultMediaTimeout
+	}
+	var httpClient *http.Client
+	if limits.Enable {
+		httpClient = newMediaClient(cfg, limits.Timeout)
+	}
+	mediaClientMu.Lock()
+	mediaClient = httpClient
+	mediaLimits = limits
+	mediaClientMu.Unlock()
+}
+
+// newMediaClient returns a client that only connects to public addresses. Direct connections
+// check the resolved address in the dialer; through a proxy, the target host is resolved and
+// checked before each request, redirects included.
+func newMediaClient(cfg *config.SDKConfig, timeout time.Duration) *http.Client {
+	httpClient := &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxMediaRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxMediaRedirects)
+			}
+			if !IsRemoteURL(req.URL.String()) {
+				return fmt.Errorf("redirect to unsupported URL scheme %q", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+	if cfg != nil && cfg.ProxyURL != "" {
+		httpClient = SetProxy(cfg, httpClient)
+	}
+	if httpClient.Transport == nil {
+		httpClient.Transport = &http.Transport{
+			DialContext:         dialPublic,
+			TLSHandshakeTimeout: 10 * time.Second,
+		}
+		return httpClient
+	}
+	httpClient.Transport = &publicHostTransport{base: httpClient.Transport}
+	return httpClient
+}
+
+// dialPublic connects to addr after checking that every address it resolves to is public.
+func dialPublic(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := resolvePublic(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var lastErr error
+	for _, ip := range ips {
+		conn, errDial := dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if errDial == nil {
+			return conn, nil
+		}
+		lastErr = errDial
+	}
+	return nil, lastErr
+}
+
+// publicHostTransport refuses requests whose host resolves to a non-public address, for
+// fetches through a proxy where the dialer only sees the proxy.
+type publicHostTransport struct {
+	base http.RoundTripper
+}
+
+func (t *publicHostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if _, err := resolvePublic(req.Context(), req.URL.Hostname()); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
+// resolvePublic resolves host and fails when any of its addresses is not public.
+func resolvePublic(ctx context.Context, host string) ([]net.IP, error) {
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("%s has no addresses", host)
+	}
+	for _, ip := range ips {
+		if !isPublicIP(ip) {
+			return nil, fmt.Errorf("%s resolves to non-public address %s", host, ip)
+		}
+	}
+	return ips, nil
+}
+
+// isPublicIP reports whether ip is a globally routable unicast address.
+func isPublicIP(ip net.IP) bool {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, block := range reservedBlocks {
+		if block.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+type mediaBudgetKey struct{}
+
+// mediaBudget tracks the images fetched for one request, so retries and fallbacks reuse
+// them and the per-request limits hold across attempts.
+type mediaBudget struct {
+	mu      sync.Mutex
+	fetched map[string]fetchedMedia
+	images  int
+	bytes   int64
+}
+
+type fetchedMedia struct {
+	mimeType string
+	data     string
+	err      error
+}
+
+// WithMediaBudget returns a context whose remote media fetches share one set of per-request
+// limits and are fetched at most once.
+func WithMediaBudget(ctx context.Context) context.Context {
+	return context.WithValue(ctx, mediaBudgetKey{}, &mediaBudget{fetched: make(map[string]fetchedMedia)})
+}
+
+func mediaBudgetFrom(ctx context.Context) *mediaBudget {
+	if budget, ok := ctx.Value(mediaBudgetKey{}).(*mediaBudget); ok {
+		return budget
+	}
+	return &mediaBudget{fetched: make(map[string]fetchedMedia)}
+}
+
+// InlineRemoteImages replaces the remote image URLs of an OpenAI chat or Claude messages
+// request with the fetched images, for providers that only accept inline data. Images that
+// cannot be fetched, or exceed the per-request limits, are left as URLs. The payload is
+// returned unchanged when media fetching is disabled.
+//
+// Parameters:
+//   - ctx: The request context, which bounds the fetches and carries the media budget
+//   - format: The schema of payload, "openai" or "claude"
+//   - payload: The request body
+//
+// Returns:
+//   - []byte: The request body with the images inlined
+func InlineRemoteImages(ctx context.Context, format string, payload []byte) []byte {
+	mediaClientMu.RLock()
+	enabled := mediaClient != nil
+	mediaClientMu.RUnlock()
+	if !enabled || (format != "openai" && format != "claude") {
+		return payload
+	}
+	var budget *mediaBudget
+	out := payload
+	gjson.GetBytes(payload, "messages").ForEach(func(messageIndex, message gjson.Result) bool {
+		message.Get("content").ForEach(func(partIndex, part gjson.Result) bool {
+			var path string
+			var url string
+			switch {
+			case format == "openai" && part.Get("type").String() == "image_url":
+				path, url = "image_url.url", part.Get("image_url.url").String()
+				if part.Get("image_url").Type == gjson.String {
+					path, url = "image_url", part.Get("image_url").String()
+				}
+			case format == "claude" && part.Get("type").String() == "image" && part.Get("source.type").String() == "url":
+				path, url = "source", part.Get("source.url").String()
+			default:
+				return true
+			}
+			if !IsRemoteURL(url) {
+				return true
+			}
+			if budget == nil {
+				budget = mediaBudgetFrom(ctx)
+			}
+			mimeType, data, err := budget.fetch(ctx, url)
+			if err != nil {
+				log.Warnf("failed to fetch image %s: %v", maskedMediaURL(url), err)
+				return true
+			}
+			prefix := fmt.Sprintf("messages.%d.content.%d.%s", messageIndex.Int(), partIndex.Int(), path)
+			var value any = "data:" + mimeType + ";base64," + data
+			if format == "claude" {
+				value = map[string]string{"type": "base64", "media_type": mimeType, "data": data}
+			}
+			if updated, errSet := sjson.SetBytes(out, prefix, value); errSet == nil {
+				out = updated
+			}
+			return true
+		})
+		return true
+	})
+	return out
+}
+
+// fetch returns the image at url, fetching it unless this request already did.
+func (b *mediaBudget) fetch(ctx context.Context, url string) (string, string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if cached, ok := b.fetched[url]; ok {
+		return cached.mimeType, cached.data, cached.err
+	}
+	mediaClientMu.RLock()
+	httpClient, limits := mediaClient, mediaLimits
+	mediaClientMu.RUnlock()
+	if httpClient == nil {
+		return "", "", errors.New("remote media fetching is disabled")
+	}
+	if b.images >= limits.MaxImages {
+		return "", "", fmt.Errorf("request exceeds %d remote images", limits.MaxImages)
+	}
+	limit := min(limits.MaxImageBytes, limits.MaxTotalBytes-b.bytes)
+	if limit <= 0 {
+		return "", "", fmt.Errorf("request exceeds %d bytes of remote images", limits.MaxTotalBytes)
+	}
+	b.images++
+	mimeType, body, err := fetchMedia(ctx, httpClient, url, limit)
+	b.bytes += int64(len(body))
+	result := fetchedMedia{mimeType: mimeType, err: err}
+	if err == nil {
+		result.data = base64.StdEncoding.EncodeToString(body)
+	}
+	b.fetched[url] = result
+	return result.mimeType, result.data, result.err
+}
+
+// ParseDataURL splits a base64 data URL ("data:<mime>;base64,<data>") into its media type
+// and payload.
+//
+// Parameters:
+//   - url: The data URL
+//
+// Returns:
+//   - string: The media type, "application/octet-stream" when the URL names none
+//   - string: The base64 payload
+//   - bool: False when url is not a base64 data URL
+func ParseDataURL(url string) (string, string, bool) {
+	if !strings.HasPrefix(url, "data:") {
+		return "", "", false
+	}
+	header, data, found := strings.Cut(url[len("data:"):], ",")
+	if !found || data == "" {
+		return "", "", false
+	}
+	params := strings.Split(header, ";")
+	if params[len(params)-1] != "base64" {
+		return "", "", false
+	}
+	mimeType := params[0]
+	if mimeType == "" || mimeType == "base64" {
+		mimeType = "application/octet-stream"
+	}
+	return mimeType, data, true
+}
+
+// InlineMedia returns an image as base64 with its media type, for providers that only
+// accept inline data. Only data URLs are resolved: remote URLs are inlined before
+// translation by InlineRemoteImages, which has the request context and limits, and are
+// dropped here when that did not happen.
+//
+// Parameters:
+//   - url: A data URL or a remote URL
+//
+// Returns:
+//   - string: The media type
+//   - string: The base64 payload
+//   - bool: False when the image could not be resolved
+func InlineMedia(url string) (string, string, bool) {
+	if mimeType, data, ok := ParseDataURL(url); ok {
+		return mimeType, data, true
+	}
+	if IsRemoteURL(url) {
+		log.Debugf("dropping remote image %s that was not fetched", maskedMediaURL(url))
+	}
+	return "", "", false
+}
+
+// maskedMediaURL returns url with the values of sensitive query parameters masked, for logs.
+func maskedMediaURL(url string) string {
+	target, query, _ := strings.Cut(url, "?")
+	if query = MaskSensitiveQuery(query); query != "" {
+		target += "?" + query
+	}
+	return target
+}
+
+// IsRemoteURL reports whether url is an http or https URL.
+func IsRemoteURL(url string) bool {
+	lower := strings.ToLower(url)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+func fetchMedia(ctx context.Context, httpClient *http.Client, url string, limit int64) (string, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer func() {
+		if errClose := resp.Body.Close(); errClose != nil {
+			log.Errorf("media fetch: close response body error: %v", errClose)
+		}
+	}()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return "", nil, err
+	}
+	if int64(len(body)) > limit {
+		return "", nil, fmt.Errorf("larger than %d bytes", limit)
+	}
+	mimeType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mimeType == "" || mimeType == "application/octet-stream" || mimeType == "binary/octet-stream" {
+		mimeType, _, _ = mime.ParseMediaType(http.DetectContentType(body))
+	}
+	return mimeType, body, nil
+}
+
+// ClaudeMediaSource resolves the source of a Claude image or document block to base64
+// data. Base64 sources are returned as they are and URL sources are fetched.
+//
+// Parameters:
+//   - source: The "source" object of the block
+//
+// Returns:
+//   - string: The media type
+//   - string: The base64 payload
+//   - bool: False when the source could not be resolved
+func ClaudeMediaSource(source gjson.Result) (string, string, bool) {
+	switch source.Get("type").String() {
+	case "base64":
+		data := source.Get("data").String()
+		if data == "" {
+			return "", "", false
+		}
+		mimeType := source.Get("media_type").String()
+		if mimeType == "" {
+			mimeType = "application/octet-stream"
+		}
+		return mimeType, data, true
+	case "url":
+		return InlineMedia(source.Get("url").String())
+	}
+	return "", "", false
+}
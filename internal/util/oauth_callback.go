@@ -0,0 +1,73 @@
+package util
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// OAuthCallback is the outcome of an OAuth authorization as carried by the redirect to the
+// client's callback URL.
+type OAuthCallback struct {
+	Code  string
+	State string
+	Error string
+}
+
+// ParseOAuthCallback reads the authorization result pasted by a user who completed an OAuth
+// login in a browser on another machine. It accepts the full URL the browser was redirected
+// to, its query string, or a "code#state" pair as displayed by some providers.
+//
+// Parameters:
+//   - input: The pasted text
+//
+// Returns:
+//   - OAuthCallback: The code, state and provider error from the redirect
+//   - error: An error when the input carries neither a code nor a provider error
+func ParseOAuthCallback(input string) (OAuthCallback, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return OAuthCallback{}, fmt.Errorf("no callback URL provided")
+	}
+	if !strings.Contains(input, "code=") && !strings.Contains(input, "error=") {
+		code, state, found := strings.Cut(input, "#")
+		if !found || code == "" || state == "" {
+			return OAuthCallback{}, fmt.Errorf("paste the full URL from the browser's address bar")
+		}
+		return OAuthCallback{Code: code, State: state}, nil
+	}
+	query := input
+	if _, rest, found := strings.Cut(input, "?"); found {
+		query = rest
+	}
+	query, _, _ = strings.Cut(query, "#")
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return OAuthCallback{}, fmt.Errorf("invalid callback URL: %w", err)
+	}
+	result := OAuthCallback{Code: values.Get("code"), State: values.Get("state"), Error: values.Get("error")}
+	if description := values.Get("error_description"); result.Error != "" && description != "" {
+		result.Error += ": " + description
+	}
+	if result.Code == "" && result.Error == "" {
+		return OAuthCallback{}, fmt.Errorf("callback URL has no authorization code")
+	}
+	return result, nil
+}
+
+// PrintHeadlessLoginInstructions prints how to complete an OAuth login from a browser on
+// another machine when no callback server can be reached on this one.
+//
+// Parameters:
+//   - provider: The provider name shown to the user
+//   - authURL: The authorization URL to open
+func PrintHeadlessLoginInstructions(provider, authURL string) {
+	border := "================================================================================"
+	fmt.Println(border)
+	fmt.Printf("  Open the following URL in a browser on any machine and sign in to %s:\n\n", provider)
+	fmt.Printf("  %s\n\n", authURL)
+	fmt.Println("  After you approve access the browser is redirected to a localhost address that")
+	fmt.Println("  fails to load; this is expected. Copy the full address from the address bar and")
+	fmt.Println("  paste it below.")
+	fmt.Println(border)
+}
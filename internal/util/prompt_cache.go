@@ -0,0 +1,73 @@
+package util
+
+import (
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// ClaudePromptUsage accumulates the prompt token counts of a Claude response. Claude reports
+// tokens read from and written to the prompt cache apart from input_tokens, while OpenAI
+// counts them in the prompt tokens and reports the cached share in the token details.
+type ClaudePromptUsage struct {
+	InputTokens         int64
+	CacheReadTokens     int64
+	CacheCreationTokens int64
+}
+
+// Merge updates the counts from a Claude usage object. Absent fields keep their value, so
+// the counts of message_start survive a message_delta that only reports output tokens.
+//
+// Parameters:
+//   - usage: The usage object of a Claude message or event
+func (u *ClaudePromptUsage) Merge(usage gjson.Result) {
+	if v := usage.Get("input_tokens"); v.Exists() {
+		u.InputTokens = v.Int()
+	}
+	if v := usage.Get("cache_read_input_tokens"); v.Exists() {
+		u.CacheReadTokens = v.Int()
+	}
+	if v := usage.Get("cache_creation_input_tokens"); v.Exists() {
+		u.CacheCreationTokens = v.Int()
+	}
+}
+
+// PromptTokens returns the prompt tokens as OpenAI counts them, cached tokens included.
+func (u ClaudePromptUsage) PromptTokens() int64 {
+	return u.InputTokens + u.CacheReadTokens + u.CacheCreationTokens
+}
+
+// SetDetails writes the cache counts to an OpenAI token details object at path, such as
+// usage.prompt_tokens_details or usage.input_tokens_details: cached_tokens for cache reads
+// and cache_creation_tokens for cache writes.
+//
+// Parameters:
+//   - out: The JSON document to update
+//   - path: The path of the token details object
+//
+// Returns:
+//   - string: The updated JSON document
+func (u ClaudePromptUsage) SetDetails(out, path string) string {
+	out, _ = sjson.Set(out, path+".cached_tokens", u.CacheReadTokens)
+	if u.CacheCreationTokens > 0 {
+		out, _ = sjson.Set(out, path+".cache_creation_tokens", u.CacheCreationTokens)
+	}
+	return out
+}
+
+// ClaudeCacheControl returns the Anthropic cache_control breakpoint set on an OpenAI message,
+// content part or tool, which OpenAI-compatible clients send to cache the prompt prefix up
+// to that point when it is served by Claude.
+//
+// Parameters:
+//   - node: The message, content part or tool
+//
+// Returns:
+//   - any: The cache_control object
+//   - bool: Whether a cache_control object was set
+func ClaudeCacheControl(node gjson.Result) (any, bool) {
+	cacheControl := node.Get("cache_control")
+	if !cacheControl.IsObject() || cacheControl.Get("type").String() == "" {
+		return nil, false
+	}
+	return cacheControl.Value(), true
+}
@@ -0,0 +1,55 @@
+package util
+
+import "github.com/tidwall/gjson"
+
+// ThinkingBudgetToReasoningEffort maps a thinking token budget (Claude budget_tokens or
+// Gemini thinkingBudget) to the closest OpenAI reasoning_effort. Budgets that are not
+// positive, dynamic (-1) or disabled (0), have no effort equivalent and return "".
+//
+// Parameters:
+//   - budget: The thinking budget in tokens
+//
+// Returns:
+//   - string: "low", "medium", "high" or ""
+func ThinkingBudgetToReasoningEffort(budget int64) string {
+	switch {
+	case budget <= 0:
+		return ""
+	case budget <= 2048:
+		return "low"
+	case budget <= 12288:
+		return "medium"
+	default:
+		return "high"
+	}
+}
+
+// ClaudeReasoningEffort returns the reasoning_effort matching a Claude thinking setting,
+// or "" when thinking is not enabled with a budget.
+//
+// Parameters:
+//   - thinking: The thinking value of the request
+//
+// Returns:
+//   - string: The reasoning effort
+func ClaudeReasoningEffort(thinking gjson.Result) string {
+	if thinking.Get("type").String() != "enabled" {
+		return ""
+	}
+	return ThinkingBudgetToReasoningEffort(thinking.Get("budget_tokens").Int())
+}
+
+// GeminiReasoningEffort returns the reasoning_effort matching a Gemini thinkingConfig,
+// or "" when it sets no positive budget.
+//
+// Parameters:
+//   - thinkingConfig: The generationConfig.thinkingConfig value of the request
+//
+// Returns:
+//   - string: The reasoning effort
+func GeminiReasoningEffort(thinkingConfig gjson.Result) string {
+	if thinkingConfig.Get("includeThoughts").Exists() && !thinkingConfig.Get("includeThoughts").Bool() {
+		return ""
+	}
+	return ThinkingBudgetToReasoningEffort(thinkingConfig.Get("thinkingBudget").Int())
+}
@@ -0,0 +1,71 @@
+package util
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// requestTextKeys are the request fields holding text written by users or clients, across
+// the OpenAI, Claude and Gemini formats.
+var requestTextKeys = map[string]bool{
+	"content":      true,
+	"text":         true,
+	"input":        true,
+	"prompt":       true,
+	"instructions": true,
+	"system":       true,
+}
+
+// RequestText is a string of a request body and its gjson/sjson path.
+type RequestText struct {
+	Path string
+	Text string
+}
+
+// RequestTexts returns the non-empty strings of a JSON request held in text fields, directly
+// or in arrays, in document order: message contents, content part texts, prompts, inputs,
+// instructions and system prompts. Image URLs, inline data and other fields are skipped.
+//
+// Parameters:
+//   - body: The JSON request body
+//
+// Returns:
+//   - []RequestText: The texts with the paths to update them at
+func RequestTexts(body []byte) []RequestText {
+	if !gjson.ValidBytes(body) {
+		return nil
+	}
+	var fields []RequestText
+	var walk func(value gjson.Result, path string, textual bool)
+	walk = func(value gjson.Result, path string, textual bool) {
+		switch {
+		case value.Type == gjson.String:
+			if textual && path != "" && value.String() != "" {
+				fields = append(fields, RequestText{Path: path, Text: value.String()})
+			}
+		case value.IsArray():
+			for i, elem := range value.Array() {
+				walk(elem, joinRequestPath(path, strconv.Itoa(i)), textual)
+			}
+		case value.IsObject():
+			value.ForEach(func(key, child gjson.Result) bool {
+				walk(child, joinRequestPath(path, requestPathEscaper.Replace(key.String())), requestTextKeys[key.String()])
+				return true
+			})
+		}
+	}
+	walk(gjson.ParseBytes(body), "", false)
+	return fields
+}
+
+func joinRequestPath(path, component string) string {
+	if path == "" {
+		return component
+	}
+	return path + "." + component
+}
+
+// requestPathEscaper escapes the characters gjson and sjson treat as path syntax.
+var requestPathEscaper = strings.NewReplacer(`\`, `\\`, ".", `\.`, "*", `\*`, "?", `\?`, "|", `\|`, "#", `\#`, "@", `\@`)
@@ -0,0 +1,189 @@
+package util
+
+import (
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// StructuredOutputToolName names the tool that carries structured output on Claude, which
+// has no JSON response mode: the schema becomes the tool's input schema, the tool is forced
+// and its input is returned to the client as the response text.
+const StructuredOutputToolName = "json_response"
+
+// ResponseFormat is a JSON output constraint in provider-neutral form.
+type ResponseFormat struct {
+	// Name identifies the schema; OpenAI requires one.
+	Name string
+	// Schema is the raw JSON Schema, or "" when any JSON object is accepted.
+	Schema string
+	// Strict asks for exact schema adherence where the provider supports it.
+	Strict bool
+}
+
+// OpenAIResponseFormat reads a Chat Completions response_format.
+//
+// Parameters:
+//   - responseFormat: The response_format value of the request
+//
+// Returns:
+//   - ResponseFormat: The constraint
+//   - bool: False when the request does not ask for JSON output
+func OpenAIResponseFormat(responseFormat gjson.Result) (ResponseFormat, bool) {
+	switch responseFormat.Get("type").String() {
+	case "json_object":
+		return ResponseFormat{}, true
+	case "json_schema":
+		schema := responseFormat.Get("json_schema")
+		format := ResponseFormat{Name: schema.Get("name").String(), Strict: schema.Get("strict").Bool()}
+		if s := schema.Get("schema"); s.IsObject() {
+			format.Schema = s.Raw
+		}
+		return format, true
+	}
+	return ResponseFormat{}, false
+}
+
+// OpenAIResponsesTextFormat reads a Responses API text.format, which carries the
+// json_schema fields inline.
+//
+// Parameters:
+//   - textFormat: The text.format value of the request
+//
+// Returns:
+//   - ResponseFormat: The constraint
+//   - bool: False when the request does not ask for JSON output
+func OpenAIResponsesTextFormat(textFormat gjson.Result) (ResponseFormat, bool) {
+	switch textFormat.Get("type").String() {
+	case "json_object":
+		return ResponseFormat{}, true
+	case "json_schema":
+		format := ResponseFormat{Name: textFormat.Get("name").String(), Strict: textFormat.Get("strict").Bool()}
+		if s := textFormat.Get("schema"); s.IsObject() {
+			format.Schema = s.Raw
+		}
+		return format, true
+	}
+	return ResponseFormat{}, false
+}
+
+// GeminiResponseFormat reads the JSON output settings of a Gemini generationConfig,
+// preferring responseJsonSchema over the OpenAPI-style responseSchema.
+//
+// Parameters:
+//   - generationConfig: The generationConfig of the request
+//
+// Returns:
+//   - ResponseFormat: The constraint
+//   - bool: False when the request does not ask for JSON output
+func GeminiResponseFormat(generationConfig gjson.Result) (ResponseFormat, bool) {
+	if generationConfig.Get("responseMimeType").String() != "application/json" {
+		return ResponseFormat{}, false
+	}
+	format := ResponseFormat{}
+	if schema := generationConfig.Get("responseJsonSchema"); schema.IsObject() {
+		format.Schema = schema.Raw
+	} else if schema = generationConfig.Get("responseSchema"); schema.IsObject() {
+		format.Schema = LowercaseSchemaTypes(schema)
+	}
+	return format, true
+}
+
+// ClaudeOutputFormat reads a Claude output_format ({"type":"json_schema","schema":{...}}).
+//
+// Parameters:
+//   - outputFormat: The output_format value of the request
+//
+// Returns:
+//   - ResponseFormat: The constraint
+//   - bool: False when the request does not ask for JSON output
+func ClaudeOutputFormat(outputFormat gjson.Result) (ResponseFormat, bool) {
+	if outputFormat.Get("type").String() != "json_schema" {
+		return ResponseFormat{}, false
+	}
+	format := ResponseFormat{}
+	if schema := outputFormat.Get("schema"); schema.IsObject() {
+		format.Schema = schema.Raw
+	}
+	return format, true
+}
+
+// OpenAI returns the constraint as a Chat Completions response_format.
+func (f ResponseFormat) OpenAI() string {
+	if f.Schema == "" {
+		return `{"type":"json_object"}`
+	}
+	out := `{"type":"json_schema","json_schema":{"name":"response"}}`
+	if f.Name != "" {
+		out, _ = sjson.Set(out, "json_schema.name", f.Name)
+	}
+	if f.Strict {
+		out, _ = sjson.Set(out, "json_schema.strict", true)
+	}
+	out, _ = sjson.SetRaw(out, "json_schema.schema", f.Schema)
+	return out
+}
+
+// ResponsesText returns the constraint as a Responses API text.format.
+func (f ResponseFormat) ResponsesText() string {
+	if f.Schema == "" {
+		return `{"type":"json_object"}`
+	}
+	out := `{"type":"json_schema","name":"response"}`
+	if f.Name != "" {
+		out, _ = sjson.Set(out, "name", f.Name)
+	}
+	if f.Strict {
+		out, _ = sjson.Set(out, "strict", true)
+	}
+	out, _ = sjson.SetRaw(out, "schema", f.Schema)
+	return out
+}
+
+// ApplyGemini sets the constraint on the generationConfig found at path in a Gemini request.
+func (f ResponseFormat) ApplyGemini(request []byte, path string) []byte {
+	request, _ = sjson.SetBytes(request, path+".responseMimeType", "application/json")
+	if f.Schema != "" {
+		request, _ = sjson.SetRawBytes(request, path+".responseJsonSchema", []byte(f.Schema))
+	}
+	return request
+}
+
+// ClaudeTool returns the tool that carries the constraint on Claude.
+func (f ResponseFormat) ClaudeTool() string {
+	tool := `{"name":"","description":"Respond with a JSON object. Call this tool exactly once with the complete response as its input.","input_schema":{"type":"object"}}`
+	tool, _ = sjson.Set(tool, "name", StructuredOutputToolName)
+	if f.Schema != "" {
+		tool, _ = sjson.SetRaw(tool, "input_schema", f.Schema)
+	}
+	return tool
+}
+
+// ApplyClaude adds the structured output tool to a Claude request. The tool is forced
+// unless the client brought tools of its own or extended thinking is enabled, which does
+// not allow a forced tool choice.
+func (f ResponseFormat) ApplyClaude(request string) string {
+	forced := !gjson.Get(request, "tools").IsArray() && gjson.Get(request, "thinking.type").String() != "enabled"
+	request, _ = sjson.SetRaw(request, "tools.-1", f.ClaudeTool())
+	if forced {
+		choice := `{"type":"tool","name":""}`
+		choice, _ = sjson.Set(choice, "name", StructuredOutputToolName)
+		request, _ = sjson.SetRaw(request, "tool_choice", choice)
+	}
+	return request
+}
+
+// LowercaseSchemaTypes converts the upper-case OpenAPI type names used by Gemini
+// schemas (e.g. "OBJECT") to the JSON Schema spelling expected by OpenAI and Claude.
+func LowercaseSchemaTypes(schema gjson.Result) string {
+	out := schema.Raw
+	var paths []string
+	Walk(schema, "", "type", &paths)
+	for _, p := range paths {
+		if value := gjson.Get(out, p); value.Type == gjson.String {
+			out, _ = sjson.Set(out, p, strings.ToLower(value.String()))
+		}
+	}
+	return out
+}
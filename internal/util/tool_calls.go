@@ -0,0 +1,134 @@
+package util
+
+import (
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// ToolCallArgs normalizes tool call arguments for providers that expect a JSON object,
+// such as Gemini functionCall.args and Claude tool_use.input. OpenAI clients send the
+// arguments as a string that may be empty, single-quoted or otherwise malformed; rather
+// than dropping what cannot be parsed, it is kept under an "arguments" key so the model
+// still sees it.
+//
+// Parameters:
+//   - raw: The raw arguments, either a JSON string or an encoded JSON value
+//
+// Returns:
+//   - string: A JSON object
+func ToolCallArgs(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "{}"
+	}
+	if gjson.Valid(raw) && gjson.Parse(raw).IsObject() {
+		return raw
+	}
+	if fixed := FixJSON(raw); gjson.Valid(fixed) && gjson.Parse(fixed).IsObject() {
+		return fixed
+	}
+	if gjson.Valid(raw) {
+		out, _ := sjson.SetRaw("{}", "arguments", raw)
+		return out
+	}
+	out, _ := sjson.Set("{}", "arguments", raw)
+	return out
+}
+
+// ToolResultText flattens tool result content into text. Tool results arrive as a plain
+// string, a single text part or an array of parts (OpenAI "text" parts and Claude "text"
+// blocks); text parts are joined with newlines and any other value is kept as raw JSON.
+//
+// Parameters:
+//   - content: The tool result content
+//
+// Returns:
+//   - string: The textual result
+func ToolResultText(content gjson.Result) string {
+	switch {
+	case !content.Exists() || content.Type == gjson.Null:
+		return ""
+	case content.Type == gjson.String:
+		return content.String()
+	case content.IsObject():
+		if content.Get("type").String() == "text" {
+			return content.Get("text").String()
+		}
+		return content.Raw
+	case content.IsArray():
+		texts := make([]string, 0, len(content.Array()))
+		for _, part := range content.Array() {
+			if part.Type == gjson.String {
+				texts = append(texts, part.String())
+			} else if part.Get("type").String() == "text" {
+				texts = append(texts, part.Get("text").String())
+			} else {
+				return content.Raw
+			}
+		}
+		return strings.Join(texts, "\n")
+	default:
+		return content.Raw
+	}
+}
+
+// GeminiFunctionCallingConfig maps an OpenAI or Claude tool_choice to a Gemini
+// functionCallingConfig object. OpenAI uses "none", "auto", "required" or a named
+// function; Claude uses {"type":"none"|"auto"|"any"|"tool"}.
+//
+// Parameters:
+//   - toolChoice: The tool_choice value of the request
+//
+// Returns:
+//   - string: The functionCallingConfig JSON, or "" when tool_choice is absent or unknown
+func GeminiFunctionCallingConfig(toolChoice gjson.Result) string {
+	mode, name := "", ""
+	if toolChoice.Type == gjson.String {
+		mode = toolChoice.String()
+	} else if toolChoice.IsObject() {
+		mode = toolChoice.Get("type").String()
+		name = toolChoice.Get("name").String()
+		if mode == "function" {
+			name = toolChoice.Get("function.name").String()
+		}
+	}
+	var out string
+	switch mode {
+	case "none":
+		out = `{"mode":"NONE"}`
+	case "auto":
+		out = `{"mode":"AUTO"}`
+	case "required", "any":
+		out = `{"mode":"ANY"}`
+	case "function", "tool":
+		if name == "" {
+			return ""
+		}
+		out, _ = sjson.Set(`{"mode":"ANY"}`, "allowedFunctionNames", []string{name})
+	}
+	return out
+}
+
+// GeminiRequestFunctionCalling returns the function calling config of a Gemini request and
+// the functions it restricts calls to. Gemini accepts both the camelCase and the snake_case
+// spelling of the fields, so both are read.
+//
+// Parameters:
+//   - root: The Gemini request
+//
+// Returns:
+//   - gjson.Result: The functionCallingConfig object; it does not exist when none is set
+//   - []gjson.Result: The allowed function names
+func GeminiRequestFunctionCalling(root gjson.Result) (gjson.Result, []gjson.Result) {
+	config := root.Get("toolConfig.functionCallingConfig")
+	if !config.Exists() {
+		config = root.Get("tool_config.function_calling_config")
+	}
+	allowed := config.Get("allowedFunctionNames")
+	if !allowed.Exists() {
+		allowed = config.Get("allowed_function_names")
+	}
+	return config, allowed.Array()
+}
@@ -0,0 +1,28 @@
+package watcher
+
+import (
+	"context"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/secrets"
+	log "github.com/sirupsen/logrus"
+)
+
+// watchSecrets periodically re-reads the secrets referenced by the configuration and
+// reloads it when one of them was rotated.
+func (w *Watcher) watchSecrets(ctx context.Context) {
+	for {
+		w.clientsMutex.RLock()
+		interval := secrets.RefreshInterval(w.config)
+		w.clientsMutex.RUnlock()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		if secrets.Refresh(ctx) {
+			log.Info("referenced secrets changed, reloading configuration")
+			w.TriggerReload()
+		}
+	}
+}
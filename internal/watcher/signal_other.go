@@ -0,0 +1,8 @@
+//go:build windows || plan9
+
+package watcher
+
+import "context"
+
+// watchReloadSignal is a no-op on platforms without SIGHUP; the file watcher still applies changes.
+func (w *Watcher) watchReloadSignal(_ context.Context) {}
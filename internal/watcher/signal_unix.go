@@ -0,0 +1,28 @@
+//go:build !windows && !plan9
+
+package watcher
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// watchReloadSignal reloads the configuration whenever the process receives SIGHUP.
+func (w *Watcher) watchReloadSignal(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			log.Info("received SIGHUP, reloading configuration")
+			w.TriggerReload()
+		}
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"gopkg.in/yaml.v3"
 
@@ -59,6 +60,8 @@ type Watcher struct {
 	storePersister  storePersister
 	mirroredAuthDir string
 	oldConfigYaml   []byte
+	// reloadMu serialises config reloads triggered by file events and SIGHUP.
+	reloadMu sync.Mutex
 }
 
 type stableIDGenerator struct {
@@ -147,9 +150,12 @@ func NewWatcher(configPath, authDir string, reloadCallback func(*config.Config))
 
 // Start begins watching the configuration file and authentication directory
 func (w *Watcher) Start(ctx context.Context) error {
-	// Watch the config file
-	if errAddConfig := w.watcher.Add(w.configPath); errAddConfig != nil {
-		log.Errorf("failed to watch config file %s: %v", w.configPath, errAddConfig)
+	// Watch the directory holding the config file rather than the file itself, so edits
+	// saved via write-to-temp-and-rename keep being observed after the inode changes.
+	w.configPath = filepath.Clean(w.configPath)
+	configDir := filepath.Dir(w.configPath)
+	if errAddConfig := w.watcher.Add(configDir); errAddConfig != nil {
+		log.Errorf("failed to watch config directory %s: %v", configDir, errAddConfig)
 		return errAddConfig
 	}
 	log.Debugf("watching config file: %s", w.configPath)
@@ -163,6 +169,8 @@ func (w *Watcher) Start(ctx context.Context) error {
 
 	// Start the event processing goroutine
 	go w.processEvents(ctx)
+	go w.watchReloadSignal(ctx)
+	go w.watchSecrets(ctx)
 
 	// Perform an initial full reload based on current config and auth dir
 	w.reloadClients(true)
@@ -436,6 +444,68 @@ func computeClaudeModelsHash(models []config.ClaudeModel) string {
 	return hex.EncodeToString(sum[:])
 }
 
+// computeBedrockModelsHash returns a stable hash for Bedrock model aliases.
+func computeBedrockModelsHash(models []config.BedrockModel) string {
+	if len(models) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(models)
+	if err != nil || len(data) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// computeAzureDeploymentsHash returns a stable hash for Azure OpenAI deployment mappings.
+func computeAzureDeploymentsHash(deployments []config.AzureOpenAIDeployment) string {
+	if len(deployments) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(deployments)
+	if err != nil || len(data) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// computeVertexModelsHash returns a stable hash for Vertex AI model mappings.
+func computeVertexModelsHash(models []config.VertexAIModel) string {
+	if len(models) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(models)
+	if err != nil || len(data) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// computeXAIModelsHash returns a stable hash for xAI model aliases.
+func computeXAIModelsHash(models []config.XAIModel) string {
+	if len(models) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(models)
+	if err != nil || len(data) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// computeProviderPluginHash returns a stable hash for the definition of a provider plugin.
+func computeProviderPluginHash(entry config.ProviderPlugin) string {
+	data, err := json.Marshal(entry)
+	if err != nil || len(data) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
 // SetClients sets the file-based clients.
 // SetClients removed
 // SetAPIKeyClients removed
@@ -463,7 +533,7 @@ func (w *Watcher) processEvents(ctx context.Context) {
 // handleEvent processes individual file system events
 func (w *Watcher) handleEvent(event fsnotify.Event) {
 	// Filter only relevant events: config file or auth-dir JSON files.
-	isConfigEvent := event.Name == w.configPath && (event.Op&fsnotify.Write == fsnotify.Write || event.Op&fsnotify.Create == fsnotify.Create)
+	isConfigEvent := filepath.Clean(event.Name) == w.configPath && (event.Op&fsnotify.Write == fsnotify.Write || event.Op&fsnotify.Create == fsnotify.Create)
 	isAuthJSON := strings.HasPrefix(event.Name, w.authDir) && strings.HasSuffix(event.Name, ".json")
 	if !isConfigEvent && !isAuthJSON {
 		// Ignore unrelated files (e.g., cookie snapshots *.cookie) and other noise.
@@ -476,40 +546,7 @@ func (w *Watcher) handleEvent(event fsnotify.Event) {
 	// Handle config file changes
 	if isConfigEvent {
 		log.Debugf("config file change details - operation: %s, timestamp: %s", event.Op.String(), now.Format("2006-01-02 15:04:05.000"))
-		data, err := os.ReadFile(w.configPath)
-		if err != nil {
-			log.Errorf("failed to read config file for hash check: %v", err)
-			return
-		}
-		if len(data) == 0 {
-			log.Debugf("ignoring empty config file write event")
-			return
-		}
-		sum := sha256.Sum256(data)
-		newHash := hex.EncodeToString(sum[:])
-
-		w.clientsMutex.RLock()
-		currentHash := w.lastConfigHash
-		w.clientsMutex.RUnlock()
-
-		if currentHash != "" && currentHash == newHash {
-			log.Debugf("config file content unchanged (hash match), skipping reload")
-			return
-		}
-		fmt.Printf("config file changed, reloading: %s\n", w.configPath)
-		if w.reloadConfig() {
-			finalHash := newHash
-			if updatedData, errRead := os.ReadFile(w.configPath); errRead == nil && len(updatedData) > 0 {
-				sumUpdated := sha256.Sum256(updatedData)
-				finalHash = hex.EncodeToString(sumUpdated[:])
-			} else if errRead != nil {
-				log.WithError(errRead).Debug("failed to compute updated config hash after reload")
-			}
-			w.clientsMutex.Lock()
-			w.lastConfigHash = finalHash
-			w.clientsMutex.Unlock()
-			w.persistConfigAsync()
-		}
+		w.handleConfigChange(false)
 		return
 	}
 
@@ -530,6 +567,54 @@ func (w *Watcher) handleEvent(event fsnotify.Event) {
 	}
 }
 
+// TriggerReload re-reads the configuration file and applies it even when its content
+// hash is unchanged. It is used for SIGHUP-initiated reloads.
+func (w *Watcher) TriggerReload() {
+	w.handleConfigChange(true)
+}
+
+// handleConfigChange reloads the config file when its content changed (or force is set).
+// Reloads are serialised so concurrent triggers never interleave their application.
+func (w *Watcher) handleConfigChange(force bool) {
+	w.reloadMu.Lock()
+	defer w.reloadMu.Unlock()
+
+	data, err := os.ReadFile(w.configPath)
+	if err != nil {
+		log.Errorf("failed to read config file for hash check: %v", err)
+		return
+	}
+	if len(data) == 0 {
+		log.Debugf("ignoring empty config file write event")
+		return
+	}
+	sum := sha256.Sum256(data)
+	newHash := hex.EncodeToString(sum[:])
+
+	w.clientsMutex.RLock()
+	currentHash := w.lastConfigHash
+	w.clientsMutex.RUnlock()
+
+	if !force && currentHash != "" && currentHash == newHash {
+		log.Debugf("config file content unchanged (hash match), skipping reload")
+		return
+	}
+	fmt.Printf("config file changed, reloading: %s\n", w.configPath)
+	if w.reloadConfig() {
+		finalHash := newHash
+		if updatedData, errRead := os.ReadFile(w.configPath); errRead == nil && len(updatedData) > 0 {
+			sumUpdated := sha256.Sum256(updatedData)
+			finalHash = hex.EncodeToString(sumUpdated[:])
+		} else if errRead != nil {
+			log.WithError(errRead).Debug("failed to compute updated config hash after reload")
+		}
+		w.clientsMutex.Lock()
+		w.lastConfigHash = finalHash
+		w.clientsMutex.Unlock()
+		w.persistConfigAsync()
+	}
+}
+
 // reloadConfig reloads the configuration and triggers a full reload
 func (w *Watcher) reloadConfig() bool {
 	log.Debug("=========================== CONFIG RELOAD ============================")
@@ -604,8 +689,8 @@ func (w *Watcher) reloadClients(rescanAuth bool) {
 	// no legacy clients to unregister
 
 	// Create new API key clients based on the new config
-	glAPIKeyCount, claudeAPIKeyCount, codexAPIKeyCount, openAICompatCount := BuildAPIKeyClients(cfg)
-	totalAPIKeyClients := glAPIKeyCount + claudeAPIKeyCount + codexAPIKeyCount + openAICompatCount
+	glAPIKeyCount, claudeAPIKeyCount, codexAPIKeyCount, bedrockKeyCount, azureOpenAICount, vertexAICount, xaiKeyCount, openAICompatCount, localBackendCount, providerPluginCount := BuildAPIKeyClients(cfg)
+	totalAPIKeyClients := glAPIKeyCount + claudeAPIKeyCount + codexAPIKeyCount + bedrockKeyCount + azureOpenAICount + vertexAICount + xaiKeyCount + openAICompatCount + localBackendCount + providerPluginCount
 	log.Debugf("loaded %d API key clients", totalAPIKeyClients)
 
 	var authFileCount int
@@ -648,7 +733,7 @@ func (w *Watcher) reloadClients(rescanAuth bool) {
 		w.clientsMutex.Unlock()
 	}
 
-	totalNewClients := authFileCount + glAPIKeyCount + claudeAPIKeyCount + codexAPIKeyCount + openAICompatCount
+	totalNewClients := authFileCount + glAPIKeyCount + claudeAPIKeyCount + codexAPIKeyCount + bedrockKeyCount + azureOpenAICount + vertexAICount + xaiKeyCount + openAICompatCount + localBackendCount + providerPluginCount
 
 	// Ensure consumers observe the new configuration before auth updates dispatch.
 	if w.reloadCallback != nil {
@@ -658,13 +743,19 @@ func (w *Watcher) reloadClients(rescanAuth bool) {
 
 	w.refreshAuthState()
 
-	log.Infof("full client load complete - %d clients (%d auth files + %d GL API keys + %d Claude API keys + %d Codex keys + %d OpenAI-compat)",
+	log.Infof("full client load complete - %d clients (%d auth files + %d GL API keys + %d Claude API keys + %d Codex keys + %d Bedrock keys + %d Azure OpenAI + %d Vertex AI + %d xAI keys + %d OpenAI-compat + %d local + %d plugins)",
 		totalNewClients,
 		authFileCount,
 		glAPIKeyCount,
 		claudeAPIKeyCount,
 		codexAPIKeyCount,
+		bedrockKeyCount,
+		azureOpenAICount,
+		vertexAICount,
+		xaiKeyCount,
 		openAICompatCount,
+		localBackendCount,
+		providerPluginCount,
 	)
 }
 
@@ -826,6 +917,150 @@ func (w *Watcher) SnapshotCoreAuths() []*coreauth.Auth {
 			}
 			out = append(out, a)
 		}
+		// Bedrock credentials -> synthesize auths
+		for i := range cfg.BedrockKey {
+			bk := cfg.BedrockKey[i]
+			credential := bk.APIKey
+			if credential == "" {
+				credential = bk.AccessKeyID
+			}
+			id, token := idGen.next("bedrock:credentials", credential, bk.Region, bk.BaseURL)
+			attrs := map[string]string{
+				"source": fmt.Sprintf("config:bedrock[%s]", token),
+				"region": bk.Region,
+			}
+			if bk.APIKey != "" {
+				attrs["api_key"] = bk.APIKey
+			} else {
+				attrs["access_key_id"] = bk.AccessKeyID
+				attrs["secret_access_key"] = bk.SecretAccessKey
+				if bk.SessionToken != "" {
+					attrs["session_token"] = bk.SessionToken
+				}
+			}
+			if bk.BaseURL != "" {
+				attrs["base_url"] = bk.BaseURL
+			}
+			if hash := computeBedrockModelsHash(bk.Models); hash != "" {
+				attrs["models_hash"] = hash
+			}
+			a := &coreauth.Auth{
+				ID:         id,
+				Provider:   "bedrock",
+				Label:      "bedrock-" + bk.Region,
+				Status:     coreauth.StatusActive,
+				ProxyURL:   strings.TrimSpace(bk.ProxyURL),
+				Attributes: attrs,
+				CreatedAt:  now,
+				UpdatedAt:  now,
+			}
+			out = append(out, a)
+		}
+		// Azure OpenAI resources -> synthesize auths
+		for i := range cfg.AzureOpenAI {
+			ak := cfg.AzureOpenAI[i]
+			credential := ak.APIKey
+			if credential == "" {
+				credential = ak.AzureAD.TenantID + "/" + ak.AzureAD.ClientID
+			}
+			id, token := idGen.next("azure-openai", credential, ak.BaseURL)
+			attrs := map[string]string{
+				"source":   fmt.Sprintf("config:azure-openai[%s]", token),
+				"base_url": ak.BaseURL,
+			}
+			if ak.APIVersion != "" {
+				attrs["api_version"] = ak.APIVersion
+			}
+			if ak.APIKey != "" {
+				attrs["api_key"] = ak.APIKey
+			} else {
+				attrs["tenant_id"] = ak.AzureAD.TenantID
+				attrs["client_id"] = ak.AzureAD.ClientID
+				attrs["client_secret"] = ak.AzureAD.ClientSecret
+				if ak.AzureAD.AuthorityHost != "" {
+					attrs["authority_host"] = ak.AzureAD.AuthorityHost
+				}
+			}
+			if hash := computeAzureDeploymentsHash(ak.Deployments); hash != "" {
+				attrs["models_hash"] = hash
+			}
+			a := &coreauth.Auth{
+				ID:         id,
+				Provider:   "azure-openai",
+				Label:      "azure-openai",
+				Status:     coreauth.StatusActive,
+				ProxyURL:   strings.TrimSpace(ak.ProxyURL),
+				Attributes: attrs,
+				CreatedAt:  now,
+				UpdatedAt:  now,
+			}
+			out = append(out, a)
+		}
+		// Vertex AI projects -> synthesize auths
+		for i := range cfg.VertexAI {
+			vk := cfg.VertexAI[i]
+			id, token := idGen.next("vertex", vk.ProjectID, vk.CredentialsFile, vk.Credentials, vk.BaseURL)
+			attrs := map[string]string{
+				"source":  fmt.Sprintf("config:vertex-ai[%s]", token),
+				"regions": strings.Join(vk.Regions, ","),
+			}
+			if vk.ProjectID != "" {
+				attrs["project_id"] = vk.ProjectID
+			}
+			if vk.CredentialsFile != "" {
+				attrs["credentials_file"] = vk.CredentialsFile
+			}
+			if vk.Credentials != "" {
+				attrs["credentials"] = vk.Credentials
+			}
+			if vk.BaseURL != "" {
+				attrs["base_url"] = vk.BaseURL
+			}
+			if hash := computeVertexModelsHash(vk.Models); hash != "" {
+				attrs["models_hash"] = hash
+			}
+			label := "vertex"
+			if vk.ProjectID != "" {
+				label = "vertex-" + vk.ProjectID
+			}
+			a := &coreauth.Auth{
+				ID:         id,
+				Provider:   "vertex",
+				Label:      label,
+				Status:     coreauth.StatusActive,
+				ProxyURL:   strings.TrimSpace(vk.ProxyURL),
+				Attributes: attrs,
+				CreatedAt:  now,
+				UpdatedAt:  now,
+			}
+			out = append(out, a)
+		}
+		// xAI API keys -> synthesize auths
+		for i := range cfg.XAIKey {
+			xk := cfg.XAIKey[i]
+			id, token := idGen.next("xai:apikey", xk.APIKey, xk.BaseURL)
+			attrs := map[string]string{
+				"source":  fmt.Sprintf("config:xai[%s]", token),
+				"api_key": xk.APIKey,
+			}
+			if xk.BaseURL != "" {
+				attrs["base_url"] = xk.BaseURL
+			}
+			if hash := computeXAIModelsHash(xk.Models); hash != "" {
+				attrs["models_hash"] = hash
+			}
+			a := &coreauth.Auth{
+				ID:         id,
+				Provider:   "xai",
+				Label:      "xai-apikey",
+				Status:     coreauth.StatusActive,
+				ProxyURL:   strings.TrimSpace(xk.ProxyURL),
+				Attributes: attrs,
+				CreatedAt:  now,
+				UpdatedAt:  now,
+			}
+			out = append(out, a)
+		}
 		for i := range cfg.OpenAICompatibility {
 			compat := &cfg.OpenAICompatibility[i]
 			providerName := strings.ToLower(strings.TrimSpace(compat.Name))
@@ -924,6 +1159,60 @@ func (w *Watcher) SnapshotCoreAuths() []*coreauth.Auth {
 				out = append(out, a)
 			}
 		}
+		// Local backends -> synthesize OpenAI-compatible auths served at <base-url>/v1
+		for i := range cfg.LocalBackends {
+			lb := &cfg.LocalBackends[i]
+			providerName := strings.ToLower(lb.Name)
+			base := strings.TrimSuffix(lb.BaseURL, "/")
+			if !strings.HasSuffix(base, "/v1") {
+				base += "/v1"
+			}
+			id, token := idGen.next("local:"+providerName, base)
+			attrs := map[string]string{
+				"source":       fmt.Sprintf("config:local-backends[%s]", token),
+				"base_url":     base,
+				"compat_name":  lb.Name,
+				"provider_key": providerName,
+				"local_type":   lb.Type,
+			}
+			if lb.APIKey != "" {
+				attrs["api_key"] = lb.APIKey
+			}
+			if hash := computeOpenAICompatModelsHash(lb.Models); hash != "" {
+				attrs["models_hash"] = hash
+			}
+			a := &coreauth.Auth{
+				ID:         id,
+				Provider:   providerName,
+				Label:      lb.Name,
+				Status:     coreauth.StatusActive,
+				Attributes: attrs,
+				CreatedAt:  now,
+				UpdatedAt:  now,
+			}
+			out = append(out, a)
+		}
+		// Provider plugins -> one auth per plugin, served by the plugin executor
+		for i := range cfg.ProviderPlugins {
+			pp := &cfg.ProviderPlugins[i]
+			providerName := strings.ToLower(pp.Name)
+			id, token := idGen.next("plugin:"+providerName, pp.Type+pp.Command)
+			attrs := map[string]string{
+				"source":          fmt.Sprintf("config:provider-plugins[%s]", token),
+				"provider_plugin": providerName,
+				"plugin_hash":     computeProviderPluginHash(*pp),
+			}
+			a := &coreauth.Auth{
+				ID:         id,
+				Provider:   providerName,
+				Label:      pp.Name,
+				Status:     coreauth.StatusActive,
+				Attributes: attrs,
+				CreatedAt:  now,
+				UpdatedAt:  now,
+			}
+			out = append(out, a)
+		}
 	}
 	// Also synthesize auth entries directly from auth files (for OAuth/file-backed providers)
 	entries, _ := os.ReadDir(w.authDir)
@@ -936,7 +1225,7 @@ func (w *Watcher) SnapshotCoreAuths() []*coreauth.Auth {
 			continue
 		}
 		full := filepath.Join(w.authDir, name)
-		data, err := os.ReadFile(full)
+		data, err := authcrypt.ReadFile(full)
 		if err != nil || len(data) == 0 {
 			continue
 		}
@@ -981,11 +1270,36 @@ func (w *Watcher) SnapshotCoreAuths() []*coreauth.Auth {
 			CreatedAt: now,
 			UpdatedAt: now,
 		}
+		// Accounts disabled through the management API keep the flag in their file.
+		if disabled, _ := metadata["disabled"].(bool); disabled {
+			a.Disabled = true
+			a.Status = coreauth.StatusDisabled
+		}
 		out = append(out, a)
 	}
+	applyAccountProxies(cfg, out)
 	return out
 }
 
+// applyAccountProxies assigns the first matching account-proxies entry to every auth that
+// does not set its own proxy-url.
+func applyAccountProxies(cfg *config.Config, auths []*coreauth.Auth) {
+	if cfg == nil || len(cfg.AccountProxies) == 0 {
+		return
+	}
+	for _, auth := range auths {
+		if strings.TrimSpace(auth.ProxyURL) != "" {
+			continue
+		}
+		for _, rule := range cfg.AccountProxies {
+			if coreauth.MatchAccount(rule.Match, auth) {
+				auth.ProxyURL = rule.URL()
+				break
+			}
+		}
+	}
+}
+
 // buildCombinedClientMap merges file-based clients with API key clients from the cache.
 // buildCombinedClientMap removed
 
@@ -1029,11 +1343,17 @@ func (w *Watcher) loadFileClients(cfg *config.Config) int {
 	return authFileCount
 }
 
-func BuildAPIKeyClients(cfg *config.Config) (int, int, int, int) {
+func BuildAPIKeyClients(cfg *config.Config) (int, int, int, int, int, int, int, int, int, int) {
 	glAPIKeyCount := 0
 	claudeAPIKeyCount := 0
 	codexAPIKeyCount := 0
+	bedrockKeyCount := 0
+	azureOpenAICount := 0
+	vertexAICount := 0
+	xaiKeyCount := 0
 	openAICompatCount := 0
+	localBackendCount := 0
+	providerPluginCount := 0
 
 	if len(cfg.GlAPIKey) > 0 {
 		// Stateless executor handles Gemini API keys; avoid constructing legacy clients.
@@ -1045,6 +1365,24 @@ func BuildAPIKeyClients(cfg *config.Config) (int, int, int, int) {
 	if len(cfg.CodexKey) > 0 {
 		codexAPIKeyCount += len(cfg.CodexKey)
 	}
+	if len(cfg.BedrockKey) > 0 {
+		bedrockKeyCount += len(cfg.BedrockKey)
+	}
+	if len(cfg.AzureOpenAI) > 0 {
+		azureOpenAICount += len(cfg.AzureOpenAI)
+	}
+	if len(cfg.VertexAI) > 0 {
+		vertexAICount += len(cfg.VertexAI)
+	}
+	if len(cfg.XAIKey) > 0 {
+		xaiKeyCount += len(cfg.XAIKey)
+	}
+	if len(cfg.LocalBackends) > 0 {
+		localBackendCount += len(cfg.LocalBackends)
+	}
+	if len(cfg.ProviderPlugins) > 0 {
+		providerPluginCount += len(cfg.ProviderPlugins)
+	}
 	if len(cfg.OpenAICompatibility) > 0 {
 		// Do not construct legacy clients for OpenAI-compat providers; these are handled by the stateless executor.
 		for _, compatConfig := range cfg.OpenAICompatibility {
@@ -1056,7 +1394,7 @@ func BuildAPIKeyClients(cfg *config.Config) (int, int, int, int) {
 			}
 		}
 	}
-	return glAPIKeyCount, claudeAPIKeyCount, codexAPIKeyCount, openAICompatCount
+	return glAPIKeyCount, claudeAPIKeyCount, codexAPIKeyCount, bedrockKeyCount, azureOpenAICount, vertexAICount, xaiKeyCount, openAICompatCount, localBackendCount, providerPluginCount
 }
 
 func diffOpenAICompatibility(oldList, newList []config.OpenAICompatibility) []string {
@@ -1113,13 +1451,16 @@ func describeOpenAICompatibilityUpdate(oldEntry, newEntry config.OpenAICompatibi
 	newKeyCount := countAPIKeys(newEntry)
 	oldModelCount := countOpenAIModels(oldEntry.Models)
 	newModelCount := countOpenAIModels(newEntry.Models)
-	details := make([]string, 0, 2)
+	details := make([]string, 0, 3)
 	if oldKeyCount != newKeyCount {
 		details = append(details, fmt.Sprintf("api-keys %d -> %d", oldKeyCount, newKeyCount))
 	}
 	if oldModelCount != newModelCount {
 		details = append(details, fmt.Sprintf("models %d -> %d", oldModelCount, newModelCount))
 	}
+	if !reflect.DeepEqual(oldEntry.Headers, newEntry.Headers) {
+		details = append(details, "headers updated")
+	}
 	if len(details) == 0 {
 		return ""
 	}
@@ -1209,6 +1550,14 @@ func buildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	if oldCfg.UsageStatisticsEnabled != newCfg.UsageStatisticsEnabled {
 		changes = append(changes, fmt.Sprintf("usage-statistics-enabled: %t -> %t", oldCfg.UsageStatisticsEnabled, newCfg.UsageStatisticsEnabled))
 	}
+	if !reflect.DeepEqual(oldCfg.StatsD, newCfg.StatsD) {
+		changes = append(changes, fmt.Sprintf("statsd: enable %t -> %t, address %s -> %s", oldCfg.StatsD.Enable, newCfg.StatsD.Enable, oldCfg.StatsD.Address, newCfg.StatsD.Address))
+	}
+	if oldCfg.UsageRetention != newCfg.UsageRetention {
+		changes = append(changes, fmt.Sprintf("usage-retention: raw %s/hourly %s/daily %s -> raw %s/hourly %s/daily %s",
+			oldCfg.UsageRetention.Raw, oldCfg.UsageRetention.Hourly, oldCfg.UsageRetention.Daily,
+			newCfg.UsageRetention.Raw, newCfg.UsageRetention.Hourly, newCfg.UsageRetention.Daily))
+	}
 	if oldCfg.DisableCooling != newCfg.DisableCooling {
 		changes = append(changes, fmt.Sprintf("disable-cooling: %t -> %t", oldCfg.DisableCooling, newCfg.DisableCooling))
 	}
@@ -1239,6 +1588,27 @@ func buildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	} else if !reflect.DeepEqual(trimStrings(oldCfg.APIKeys), trimStrings(newCfg.APIKeys)) {
 		changes = append(changes, "api-keys: values updated (count unchanged, redacted)")
 	}
+	if oldCfg.CapabilityRouting.Enable != newCfg.CapabilityRouting.Enable {
+		changes = append(changes, fmt.Sprintf("capability-routing.enable: %t -> %t", oldCfg.CapabilityRouting.Enable, newCfg.CapabilityRouting.Enable))
+	}
+	if oldCfg.MediaFetch != newCfg.MediaFetch {
+		changes = append(changes, fmt.Sprintf("media-fetch: enable %t -> %t, max-images %d -> %d", oldCfg.MediaFetch.Enable, newCfg.MediaFetch.Enable, oldCfg.MediaFetch.MaxImages, newCfg.MediaFetch.MaxImages))
+	}
+	if !reflect.DeepEqual(oldCfg.Realtime.AllowedOrigins, newCfg.Realtime.AllowedOrigins) {
+		changes = append(changes, fmt.Sprintf("realtime.allowed-origins: %d -> %d", len(oldCfg.Realtime.AllowedOrigins), len(newCfg.Realtime.AllowedOrigins)))
+	}
+	if !reflect.DeepEqual(oldCfg.ContextCompaction, newCfg.ContextCompaction) {
+		changes = append(changes, fmt.Sprintf("context-compaction: enable %t -> %t, strategy %s -> %s", oldCfg.ContextCompaction.Enable, newCfg.ContextCompaction.Enable, oldCfg.ContextCompaction.Strategy, newCfg.ContextCompaction.Strategy))
+	}
+	if !reflect.DeepEqual(oldCfg.RoutingRules, newCfg.RoutingRules) {
+		changes = append(changes, fmt.Sprintf("routing-rules: updated (%d -> %d rules)", len(oldCfg.RoutingRules), len(newCfg.RoutingRules)))
+	}
+	if !reflect.DeepEqual(oldCfg.RoutingHeaders, newCfg.RoutingHeaders) {
+		changes = append(changes, fmt.Sprintf("routing-headers.api-keys count: %d -> %d (redacted)", len(oldCfg.RoutingHeaders.APIKeys), len(newCfg.RoutingHeaders.APIKeys)))
+	}
+	if !reflect.DeepEqual(oldCfg.Diagnostics.AccountAPIKeys, newCfg.Diagnostics.AccountAPIKeys) {
+		changes = append(changes, fmt.Sprintf("diagnostics.account-api-keys count: %d -> %d (redacted)", len(oldCfg.Diagnostics.AccountAPIKeys), len(newCfg.Diagnostics.AccountAPIKeys)))
+	}
 	if len(oldCfg.GlAPIKey) != len(newCfg.GlAPIKey) {
 		changes = append(changes, fmt.Sprintf("generative-language-api-key count: %d -> %d", len(oldCfg.GlAPIKey), len(newCfg.GlAPIKey)))
 	} else if !reflect.DeepEqual(trimStrings(oldCfg.GlAPIKey), trimStrings(newCfg.GlAPIKey)) {
@@ -1289,6 +1659,246 @@ func buildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 		}
 	}
 
+	// Bedrock credentials (do not print key material)
+	if len(oldCfg.BedrockKey) != len(newCfg.BedrockKey) {
+		changes = append(changes, fmt.Sprintf("bedrock-api-key count: %d -> %d", len(oldCfg.BedrockKey), len(newCfg.BedrockKey)))
+	} else {
+		for i := range oldCfg.BedrockKey {
+			o := oldCfg.BedrockKey[i]
+			n := newCfg.BedrockKey[i]
+			if o.Region != n.Region {
+				changes = append(changes, fmt.Sprintf("bedrock[%d].region: %s -> %s", i, o.Region, n.Region))
+			}
+			if o.BaseURL != n.BaseURL {
+				changes = append(changes, fmt.Sprintf("bedrock[%d].base-url: %s -> %s", i, o.BaseURL, n.BaseURL))
+			}
+			if strings.TrimSpace(o.ProxyURL) != strings.TrimSpace(n.ProxyURL) {
+				changes = append(changes, fmt.Sprintf("bedrock[%d].proxy-url: %s -> %s", i, strings.TrimSpace(o.ProxyURL), strings.TrimSpace(n.ProxyURL)))
+			}
+			if o.APIKey != n.APIKey || o.AccessKeyID != n.AccessKeyID || o.SecretAccessKey != n.SecretAccessKey || o.SessionToken != n.SessionToken {
+				changes = append(changes, fmt.Sprintf("bedrock[%d].credentials: updated", i))
+			}
+			if !reflect.DeepEqual(o.Models, n.Models) {
+				changes = append(changes, fmt.Sprintf("bedrock[%d].models: updated", i))
+			}
+		}
+	}
+
+	// Azure OpenAI resources (do not print key material)
+	if len(oldCfg.AzureOpenAI) != len(newCfg.AzureOpenAI) {
+		changes = append(changes, fmt.Sprintf("azure-openai count: %d -> %d", len(oldCfg.AzureOpenAI), len(newCfg.AzureOpenAI)))
+	} else {
+		for i := range oldCfg.AzureOpenAI {
+			o := oldCfg.AzureOpenAI[i]
+			n := newCfg.AzureOpenAI[i]
+			if o.BaseURL != n.BaseURL {
+				changes = append(changes, fmt.Sprintf("azure-openai[%d].base-url: %s -> %s", i, o.BaseURL, n.BaseURL))
+			}
+			if o.APIVersion != n.APIVersion {
+				changes = append(changes, fmt.Sprintf("azure-openai[%d].api-version: %s -> %s", i, o.APIVersion, n.APIVersion))
+			}
+			if strings.TrimSpace(o.ProxyURL) != strings.TrimSpace(n.ProxyURL) {
+				changes = append(changes, fmt.Sprintf("azure-openai[%d].proxy-url: %s -> %s", i, strings.TrimSpace(o.ProxyURL), strings.TrimSpace(n.ProxyURL)))
+			}
+			if o.APIKey != n.APIKey || o.AzureAD != n.AzureAD {
+				changes = append(changes, fmt.Sprintf("azure-openai[%d].credentials: updated", i))
+			}
+			if !reflect.DeepEqual(o.Deployments, n.Deployments) {
+				changes = append(changes, fmt.Sprintf("azure-openai[%d].deployments: updated", i))
+			}
+		}
+	}
+
+	// Vertex AI projects (do not print key material)
+	if len(oldCfg.VertexAI) != len(newCfg.VertexAI) {
+		changes = append(changes, fmt.Sprintf("vertex-ai count: %d -> %d", len(oldCfg.VertexAI), len(newCfg.VertexAI)))
+	} else {
+		for i := range oldCfg.VertexAI {
+			o := oldCfg.VertexAI[i]
+			n := newCfg.VertexAI[i]
+			if o.ProjectID != n.ProjectID {
+				changes = append(changes, fmt.Sprintf("vertex-ai[%d].project-id: %s -> %s", i, o.ProjectID, n.ProjectID))
+			}
+			if !reflect.DeepEqual(o.Regions, n.Regions) {
+				changes = append(changes, fmt.Sprintf("vertex-ai[%d].regions: %s -> %s", i, strings.Join(o.Regions, ","), strings.Join(n.Regions, ",")))
+			}
+			if o.BaseURL != n.BaseURL {
+				changes = append(changes, fmt.Sprintf("vertex-ai[%d].base-url: %s -> %s", i, o.BaseURL, n.BaseURL))
+			}
+			if strings.TrimSpace(o.ProxyURL) != strings.TrimSpace(n.ProxyURL) {
+				changes = append(changes, fmt.Sprintf("vertex-ai[%d].proxy-url: %s -> %s", i, strings.TrimSpace(o.ProxyURL), strings.TrimSpace(n.ProxyURL)))
+			}
+			if o.CredentialsFile != n.CredentialsFile || o.Credentials != n.Credentials {
+				changes = append(changes, fmt.Sprintf("vertex-ai[%d].credentials: updated", i))
+			}
+			if !reflect.DeepEqual(o.Models, n.Models) {
+				changes = append(changes, fmt.Sprintf("vertex-ai[%d].models: updated", i))
+			}
+		}
+	}
+
+	// xAI keys (do not print key material)
+	if len(oldCfg.XAIKey) != len(newCfg.XAIKey) {
+		changes = append(changes, fmt.Sprintf("xai-api-key count: %d -> %d", len(oldCfg.XAIKey), len(newCfg.XAIKey)))
+	} else {
+		for i := range oldCfg.XAIKey {
+			o := oldCfg.XAIKey[i]
+			n := newCfg.XAIKey[i]
+			if o.BaseURL != n.BaseURL {
+				changes = append(changes, fmt.Sprintf("xai[%d].base-url: %s -> %s", i, o.BaseURL, n.BaseURL))
+			}
+			if strings.TrimSpace(o.ProxyURL) != strings.TrimSpace(n.ProxyURL) {
+				changes = append(changes, fmt.Sprintf("xai[%d].proxy-url: %s -> %s", i, strings.TrimSpace(o.ProxyURL), strings.TrimSpace(n.ProxyURL)))
+			}
+			if o.APIKey != n.APIKey {
+				changes = append(changes, fmt.Sprintf("xai[%d].api-key: updated", i))
+			}
+			if !reflect.DeepEqual(o.LiveSearch, n.LiveSearch) {
+				changes = append(changes, fmt.Sprintf("xai[%d].live-search: updated", i))
+			}
+			if !reflect.DeepEqual(o.Models, n.Models) {
+				changes = append(changes, fmt.Sprintf("xai[%d].models: updated", i))
+			}
+		}
+	}
+
+	// Local backends (do not print key material)
+	if len(oldCfg.LocalBackends) != len(newCfg.LocalBackends) {
+		changes = append(changes, fmt.Sprintf("local-backends count: %d -> %d", len(oldCfg.LocalBackends), len(newCfg.LocalBackends)))
+	} else {
+		for i := range oldCfg.LocalBackends {
+			o := oldCfg.LocalBackends[i]
+			n := newCfg.LocalBackends[i]
+			if o.Name != n.Name {
+				changes = append(changes, fmt.Sprintf("local-backends[%d].name: %s -> %s", i, o.Name, n.Name))
+			}
+			if o.Type != n.Type {
+				changes = append(changes, fmt.Sprintf("local-backends[%d].type: %s -> %s", i, o.Type, n.Type))
+			}
+			if o.BaseURL != n.BaseURL {
+				changes = append(changes, fmt.Sprintf("local-backends[%d].base-url: %s -> %s", i, o.BaseURL, n.BaseURL))
+			}
+			if o.APIKey != n.APIKey {
+				changes = append(changes, fmt.Sprintf("local-backends[%d].api-key: updated", i))
+			}
+			if !reflect.DeepEqual(o.Models, n.Models) {
+				changes = append(changes, fmt.Sprintf("local-backends[%d].models: updated", i))
+			}
+		}
+	}
+
+	// Provider plugins (do not print settings or environment)
+	if len(oldCfg.ProviderPlugins) != len(newCfg.ProviderPlugins) {
+		changes = append(changes, fmt.Sprintf("provider-plugins count: %d -> %d", len(oldCfg.ProviderPlugins), len(newCfg.ProviderPlugins)))
+	} else {
+		for i := range oldCfg.ProviderPlugins {
+			o := oldCfg.ProviderPlugins[i]
+			n := newCfg.ProviderPlugins[i]
+			if o.Name != n.Name {
+				changes = append(changes, fmt.Sprintf("provider-plugins[%d].name: %s -> %s", i, o.Name, n.Name))
+			}
+			if o.Type != n.Type {
+				changes = append(changes, fmt.Sprintf("provider-plugins[%d].type: %s -> %s", i, o.Type, n.Type))
+			}
+			if o.Command != n.Command || !reflect.DeepEqual(o.Args, n.Args) {
+				changes = append(changes, fmt.Sprintf("provider-plugins[%d].command: updated", i))
+			}
+			if !reflect.DeepEqual(o.Env, n.Env) {
+				changes = append(changes, fmt.Sprintf("provider-plugins[%d].env: updated", i))
+			}
+			if !reflect.DeepEqual(o.Settings, n.Settings) {
+				changes = append(changes, fmt.Sprintf("provider-plugins[%d].settings: updated", i))
+			}
+		}
+	}
+
+	// TLS settings other than client identities only apply to a new listener.
+	if oldCfg.TLS.Enable != newCfg.TLS.Enable || oldCfg.TLS.Cert != newCfg.TLS.Cert || oldCfg.TLS.Key != newCfg.TLS.Key ||
+		oldCfg.TLS.ClientAuth.Mode != newCfg.TLS.ClientAuth.Mode || oldCfg.TLS.ClientAuth.CAFile != newCfg.TLS.ClientAuth.CAFile {
+		changes = append(changes, "tls: updated (takes effect after restart)")
+	}
+	if !reflect.DeepEqual(oldCfg.TLS.ClientAuth.Identities, newCfg.TLS.ClientAuth.Identities) {
+		changes = append(changes, fmt.Sprintf("tls.client-auth.identities: %d -> %d", len(oldCfg.TLS.ClientAuth.Identities), len(newCfg.TLS.ClientAuth.Identities)))
+	}
+
+	if !reflect.DeepEqual(oldCfg.UnixSocket, newCfg.UnixSocket) {
+		changes = append(changes, "unix-socket: updated (takes effect after restart)")
+	}
+	if !reflect.DeepEqual(oldCfg.ManagementListener, newCfg.ManagementListener) {
+		changes = append(changes, "management-listener: updated (takes effect after restart)")
+	}
+
+	if !reflect.DeepEqual(oldCfg.Admission, newCfg.Admission) {
+		changes = append(changes, fmt.Sprintf("admission: enable %t -> %t, max-concurrent %d -> %d", oldCfg.Admission.Enable, newCfg.Admission.Enable, oldCfg.Admission.MaxConcurrent, newCfg.Admission.MaxConcurrent))
+	}
+	if !reflect.DeepEqual(oldCfg.Files, newCfg.Files) {
+		changes = append(changes, fmt.Sprintf("files: enable %t -> %t, storage %s -> %s, upstream-upload %t -> %t", oldCfg.Files.Enable, newCfg.Files.Enable, oldCfg.Files.Storage, newCfg.Files.Storage, oldCfg.Files.UpstreamUpload, newCfg.Files.UpstreamUpload))
+	}
+	if oldCfg.GeminiContextCache != newCfg.GeminiContextCache {
+		changes = append(changes, fmt.Sprintf("gemini-context-cache: enable %t -> %t, min-tokens %d -> %d, ttl %s -> %s", oldCfg.GeminiContextCache.Enable, newCfg.GeminiContextCache.Enable, oldCfg.GeminiContextCache.MinTokens, newCfg.GeminiContextCache.MinTokens, oldCfg.GeminiContextCache.TTL, newCfg.GeminiContextCache.TTL))
+	}
+	if !reflect.DeepEqual(oldCfg.Guardrails, newCfg.Guardrails) {
+		changes = append(changes, fmt.Sprintf("guardrails: %d -> %d entries", len(oldCfg.Guardrails), len(newCfg.Guardrails)))
+	}
+	if !reflect.DeepEqual(oldCfg.Moderation, newCfg.Moderation) {
+		changes = append(changes, fmt.Sprintf("moderation: enable %t -> %t, blocklist %d -> %d rules, endpoint %t -> %t", oldCfg.Moderation.Enable, newCfg.Moderation.Enable, len(oldCfg.Moderation.Blocklist), len(newCfg.Moderation.Blocklist), oldCfg.Moderation.Endpoint.URL != "", newCfg.Moderation.Endpoint.URL != ""))
+	}
+	if !reflect.DeepEqual(oldCfg.Compression, newCfg.Compression) {
+		changes = append(changes, fmt.Sprintf("compression: enable %t -> %t", oldCfg.Compression.Enable, newCfg.Compression.Enable))
+	}
+	if !reflect.DeepEqual(oldCfg.RequestLimits, newCfg.RequestLimits) {
+		changes = append(changes, fmt.Sprintf("request-limits: max-body-bytes %d -> %d, max-upload-bytes %d -> %d", oldCfg.RequestLimits.MaxBodyBytes, newCfg.RequestLimits.MaxBodyBytes, oldCfg.RequestLimits.MaxUploadBytes, newCfg.RequestLimits.MaxUploadBytes))
+	}
+	if !reflect.DeepEqual(oldCfg.PIIRedaction, newCfg.PIIRedaction) {
+		changes = append(changes, fmt.Sprintf("pii-redaction: enable %t -> %t, patterns %d -> %d", oldCfg.PIIRedaction.Enable, newCfg.PIIRedaction.Enable, len(oldCfg.PIIRedaction.Patterns), len(newCfg.PIIRedaction.Patterns)))
+	}
+	if !reflect.DeepEqual(oldCfg.Hooks, newCfg.Hooks) {
+		changes = append(changes, fmt.Sprintf("hooks: updated (%d -> %d hooks)", len(oldCfg.Hooks), len(newCfg.Hooks)))
+	}
+	if oldCfg.Prompts.Enable != newCfg.Prompts.Enable || !reflect.DeepEqual(oldCfg.Prompts.Editors, newCfg.Prompts.Editors) {
+		changes = append(changes, fmt.Sprintf("prompts: enable %t -> %t, editors %d -> %d", oldCfg.Prompts.Enable, newCfg.Prompts.Enable, len(oldCfg.Prompts.Editors), len(newCfg.Prompts.Editors)))
+	}
+	if !reflect.DeepEqual(oldCfg.SystemPrompts, newCfg.SystemPrompts) {
+		changes = append(changes, fmt.Sprintf("system-prompts: updated (%d -> %d rules)", len(oldCfg.SystemPrompts.Rules), len(newCfg.SystemPrompts.Rules)))
+	}
+	if !reflect.DeepEqual(oldCfg.Sessions, newCfg.Sessions) {
+		changes = append(changes, fmt.Sprintf("sessions: enable %t -> %t, backend %s -> %s, ttl %s -> %s", oldCfg.Sessions.Enable, newCfg.Sessions.Enable, oldCfg.Sessions.Backend, newCfg.Sessions.Backend, oldCfg.Sessions.TTL, newCfg.Sessions.TTL))
+	}
+	if oldCfg.RequestDedup != newCfg.RequestDedup {
+		changes = append(changes, fmt.Sprintf("request-dedup: enable %t -> %t, max-stream-bytes %d -> %d", oldCfg.RequestDedup.Enable, newCfg.RequestDedup.Enable, oldCfg.RequestDedup.MaxStreamBytes, newCfg.RequestDedup.MaxStreamBytes))
+	}
+	if !reflect.DeepEqual(oldCfg.Shadow, newCfg.Shadow) {
+		changes = append(changes, fmt.Sprintf("shadow: enable %t -> %t, rules %d -> %d", oldCfg.Shadow.Enable, newCfg.Shadow.Enable, len(oldCfg.Shadow.Rules), len(newCfg.Shadow.Rules)))
+	}
+	if oldCfg.ReusePort != newCfg.ReusePort {
+		changes = append(changes, fmt.Sprintf("reuse-port: %t -> %t (takes effect after restart)", oldCfg.ReusePort, newCfg.ReusePort))
+	}
+	if oldCfg.Shutdown.DrainTimeout != newCfg.Shutdown.DrainTimeout {
+		changes = append(changes, fmt.Sprintf("shutdown.drain-timeout: %s -> %s", oldCfg.Shutdown.DrainTimeout, newCfg.Shutdown.DrainTimeout))
+	}
+	if oldCfg.ModelDiscovery != newCfg.ModelDiscovery {
+		changes = append(changes, fmt.Sprintf("model-discovery: enable %t -> %t, interval %s -> %s", oldCfg.ModelDiscovery.Enable, newCfg.ModelDiscovery.Enable, oldCfg.ModelDiscovery.Interval, newCfg.ModelDiscovery.Interval))
+	}
+	if !reflect.DeepEqual(oldCfg.Batch, newCfg.Batch) {
+		changes = append(changes, fmt.Sprintf("batch: enable %t -> %t, concurrency %d -> %d", oldCfg.Batch.Enable, newCfg.Batch.Enable, oldCfg.Batch.Concurrency, newCfg.Batch.Concurrency))
+	}
+	if !reflect.DeepEqual(oldCfg.AccountConcurrency, newCfg.AccountConcurrency) {
+		changes = append(changes, fmt.Sprintf("account-concurrency: updated (%d -> %d)", len(oldCfg.AccountConcurrency), len(newCfg.AccountConcurrency)))
+	}
+	// Account proxies carry credentials; only summarize them.
+	if !reflect.DeepEqual(oldCfg.AccountProxies, newCfg.AccountProxies) {
+		changes = append(changes, fmt.Sprintf("account-proxies: updated (%d -> %d)", len(oldCfg.AccountProxies), len(newCfg.AccountProxies)))
+	}
+	if oldCfg.ProxyCheck != newCfg.ProxyCheck {
+		changes = append(changes, "proxy-check: updated")
+	}
+	if oldCfg.UpstreamTransport != newCfg.UpstreamTransport {
+		changes = append(changes, "upstream-transport: updated")
+	}
+	if !reflect.DeepEqual(oldCfg.UpstreamTimeouts, newCfg.UpstreamTimeouts) {
+		changes = append(changes, "upstream-timeouts: updated")
+	}
+
 	// Remote management (never print the key)
 	if oldCfg.RemoteManagement.AllowRemote != newCfg.RemoteManagement.AllowRemote {
 		changes = append(changes, fmt.Sprintf("remote-management.allow-remote: %t -> %t", oldCfg.RemoteManagement.AllowRemote, newCfg.RemoteManagement.AllowRemote))
@@ -1306,6 +1916,9 @@ func buildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 			changes = append(changes, "remote-management.secret-key: updated")
 		}
 	}
+	if !reflect.DeepEqual(oldCfg.RemoteManagement.Tokens, newCfg.RemoteManagement.Tokens) {
+		changes = append(changes, fmt.Sprintf("remote-management.tokens: updated (%d -> %d)", len(oldCfg.RemoteManagement.Tokens), len(newCfg.RemoteManagement.Tokens)))
+	}
 
 	// OpenAI compatibility providers (summarized)
 	if compat := diffOpenAICompatibility(oldCfg.OpenAICompatibility, newCfg.OpenAICompatibility); len(compat) > 0 {
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"strings"
+
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+)
+
+// promptBytesPerToken is the estimate used to size prompts without tokenizing them.
+const promptBytesPerToken = 4
+
+// withRequirements attaches the features the request uses to metadata when capability
+// routing is enabled, so the auth manager skips accounts whose model lacks them.
+func (h *BaseAPIHandler) withRequirements(metadata map[string]any, rawJSON []byte) map[string]any {
+	if h.Cfg == nil || !h.Cfg.CapabilityRouting.Enable {
+		return metadata
+	}
+	if metadata == nil {
+		metadata = make(map[string]any, 1)
+	}
+	metadata[coreexecutor.RequirementsMetadataKey] = requestRequirements(rawJSON)
+	return metadata
+}
+
+// requestRequirements detects the images, tools and JSON output mode of an OpenAI, Claude or
+// Gemini request and estimates its prompt size, not counting inline image data.
+func requestRequirements(rawJSON []byte) *coreexecutor.Requirements {
+	root := gjson.ParseBytes(rawJSON)
+	requirements := &coreexecutor.Requirements{}
+
+	requirements.Tools = len(root.Get("tools").Array()) > 0 || len(root.Get("functions").Array()) > 0
+
+	switch {
+	case isJSONFormat(root.Get("response_format.type").String()), isJSONFormat(root.Get("text.format.type").String()):
+		requirements.JSONMode = true
+	case strings.EqualFold(generationConfig(root, "responseMimeType", "response_mime_type").String(), "application/json"):
+		requirements.JSONMode = true
+	case generationConfig(root, "responseSchema", "response_schema").Exists(), generationConfig(root, "responseJsonSchema", "response_json_schema").Exists():
+		requirements.JSONMode = true
+	}
+
+	imageBytes := 0
+	for _, path := range []string{"messages", "input", "contents"} {
+		for _, message := range root.Get(path).Array() {
+			for _, part := range messageParts(message) {
+				if size, ok := imagePart(part); ok {
+					requirements.Vision = true
+					imageBytes += size
+				}
+				// Claude tool results may carry images of their own.
+				for _, nested := range part.Get("content").Array() {
+					if size, ok := imagePart(nested); ok {
+						requirements.Vision = true
+						imageBytes += size
+					}
+				}
+			}
+		}
+	}
+	requirements.InputTokens = max(len(rawJSON)-imageBytes, 0) / promptBytesPerToken
+	return requirements
+}
+
+// messageParts returns the content parts of a chat message, Responses input item or
+// Gemini content.
+func messageParts(message gjson.Result) []gjson.Result {
+	if parts := message.Get("parts"); parts.IsArray() {
+		return parts.Array()
+	}
+	if content := message.Get("content"); content.IsArray() {
+		return content.Array()
+	}
+	return nil
+}
+
+// imagePart reports whether a content part is an image and returns the size of its inline data.
+func imagePart(part gjson.Result) (int, bool) {
+	switch part.Get("type").String() {
+	case "image_url":
+		return len(part.Get("image_url.url").String()), true
+	case "input_image":
+		return len(part.Get("image_url").String()), true
+	case "image":
+		return len(part.Get("source.data").String()), true
+	}
+	for _, key := range []string{"inlineData", "inline_data"} {
+		if data := part.Get(key); data.Exists() && strings.HasPrefix(data.Get("mimeType").String()+data.Get("mime_type").String(), "image/") {
+			return len(data.Get("data").String()), true
+		}
+	}
+	for _, key := range []string{"fileData", "file_data"} {
+		if data := part.Get(key); data.Exists() && strings.HasPrefix(data.Get("mimeType").String()+data.Get("mime_type").String(), "image/") {
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+// generationConfig returns a Gemini generation config field under either spelling.
+func generationConfig(root gjson.Result, camel, snake string) gjson.Result {
+	if value := root.Get("generationConfig." + camel); value.Exists() {
+		return value
+	}
+	return root.Get("generation_config." + snake)
+}
+
+func isJSONFormat(formatType string) bool {
+	return formatType == "json_object" || formatType == "json_schema"
+}
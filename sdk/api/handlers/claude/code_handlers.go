@@ -19,6 +19,7 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 )
 
@@ -51,7 +52,7 @@ func (h *ClaudeCodeAPIHandler) HandlerType() string {
 func (h *ClaudeCodeAPIHandler) Models() []map[string]any {
 	// Get dynamic models from the global registry
 	modelRegistry := registry.GetGlobalRegistry()
-	return modelRegistry.GetAvailableModels("claude")
+	return h.WithMappedModels(modelRegistry.GetAvailableModels("claude"))
 }
 
 // ClaudeMessages handles Claude-compatible streaming chat completions.
@@ -65,10 +66,11 @@ func (h *ClaudeCodeAPIHandler) ClaudeMessages(c *gin.Context) {
 	rawJSON, err := c.GetRawData()
 	// If data retrieval fails, return a 400 Bad Request error.
 	if err != nil {
-		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
-			Error: handlers.ErrorDetail{
-				Message: fmt.Sprintf("Invalid request: %v", err),
+		c.JSON(http.StatusBadRequest, claudeErrorResponse{
+			Type: "error",
+			Error: claudeErrorDetail{
 				Type:    "invalid_request_error",
+				Message: fmt.Sprintf("Invalid request: %v", err),
 			},
 		})
 		return
@@ -94,10 +96,11 @@ func (h *ClaudeCodeAPIHandler) ClaudeCountTokens(c *gin.Context) {
 	rawJSON, err := c.GetRawData()
 	// If data retrieval fails, return a 400 Bad Request error.
 	if err != nil {
-		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
-			Error: handlers.ErrorDetail{
-				Message: fmt.Sprintf("Invalid request: %v", err),
+		c.JSON(http.StatusBadRequest, claudeErrorResponse{
+			Type: "error",
+			Error: claudeErrorDetail{
 				Type:    "invalid_request_error",
+				Message: fmt.Sprintf("Invalid request: %v", err),
 			},
 		})
 		return
@@ -111,8 +114,19 @@ func (h *ClaudeCodeAPIHandler) ClaudeCountTokens(c *gin.Context) {
 	modelName := gjson.GetBytes(rawJSON, "model").String()
 
 	resp, errMsg := h.ExecuteCountWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, alt)
+	if errMsg != nil && countFallbackEligible(errMsg) {
+		// The upstream could not count; answer with a local estimate so clients sizing
+		// their prompts are not blocked by an unavailable account.
+		if count, errLocal := h.CountTokensLocally(c.Request.Context(), modelName, rawJSON); errLocal == nil {
+			log.Debugf("count_tokens for %s failed upstream (%v), using the local %s count", modelName, errMsg.Error, count.Tokenizer)
+			c.Header("X-Token-Count-Source", "local")
+			c.JSON(http.StatusOK, gin.H{"input_tokens": count.InputTokens})
+			cliCancel()
+			return
+		}
+	}
 	if errMsg != nil {
-		h.WriteErrorResponse(c, errMsg)
+		h.writeClaudeError(c, errMsg)
 		cliCancel(errMsg.Error)
 		return
 	}
@@ -120,6 +134,13 @@ func (h *ClaudeCodeAPIHandler) ClaudeCountTokens(c *gin.Context) {
 	cliCancel()
 }
 
+// countFallbackEligible reports whether a failed upstream count is answered locally: the
+// upstream was unreachable, overloaded or rate limited, or no account could serve it.
+// Rejected requests and forbidden models keep their error.
+func countFallbackEligible(errMsg *interfaces.ErrorMessage) bool {
+	return errMsg.StatusCode == 0 || errMsg.StatusCode == http.StatusTooManyRequests || errMsg.StatusCode >= http.StatusInternalServerError
+}
+
 // ClaudeModels handles the Claude models listing endpoint.
 // It returns a JSON response containing available Claude models and their specifications.
 //
@@ -149,7 +170,7 @@ func (h *ClaudeCodeAPIHandler) handleNonStreamingResponse(c *gin.Context, rawJSO
 
 	resp, errMsg := h.ExecuteWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, alt)
 	if errMsg != nil {
-		h.WriteErrorResponse(c, errMsg)
+		h.writeClaudeError(c, errMsg)
 		cliCancel(errMsg.Error)
 		return
 	}
@@ -177,10 +198,11 @@ func (h *ClaudeCodeAPIHandler) handleStreamingResponse(c *gin.Context, rawJSON [
 	// This is crucial for streaming as it allows immediate sending of data chunks
 	flusher, ok := c.Writer.(http.Flusher)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, handlers.ErrorResponse{
-			Error: handlers.ErrorDetail{
+		c.JSON(http.StatusInternalServerError, claudeErrorResponse{
+			Type: "error",
+			Error: claudeErrorDetail{
+				Type:    "api_error",
 				Message: "Streaming not supported",
-				Type:    "server_error",
 			},
 		})
 		return
@@ -207,6 +229,8 @@ func (h *ClaudeCodeAPIHandler) forwardClaudeStream(c *gin.Context, flusher http.
 	defer ticker.Stop()
 
 	var chunkIdx int
+	guard := h.NewStreamGuard()
+	defer guard.Stop()
 
 	for {
 		select {
@@ -216,6 +240,17 @@ func (h *ClaudeCodeAPIHandler) forwardClaudeStream(c *gin.Context, flusher http.
 			cancel(c.Request.Context().Err())
 			return
 
+		case <-guard.KeepAlive():
+			_, _ = writer.Write(handlers.KeepAliveComment)
+			_ = writer.Flush()
+			flusher.Flush()
+
+		case errMsg := <-guard.Timeout():
+			h.writeErrorEvent(writer, errMsg)
+			flusher.Flush()
+			cancel(errMsg.Error)
+			return
+
 		case <-ticker.C:
 			// Smart flush: only flush when buffer has sufficient data (≥50% full)
 			// This reduces flush frequency while ensuring data flows naturally
@@ -232,6 +267,7 @@ func (h *ClaudeCodeAPIHandler) forwardClaudeStream(c *gin.Context, flusher http.
 		case chunk, ok := <-data:
 			if !ok {
 				// Stream ended, flush remaining data
+				_, _ = writer.Write(h.DiagnosticsEvent(c))
 				_ = writer.Flush()
 				cancel(nil)
 				return
@@ -244,19 +280,14 @@ func (h *ClaudeCodeAPIHandler) forwardClaudeStream(c *gin.Context, flusher http.
 				_, _ = writer.Write(chunk)
 			}
 			chunkIdx++
+			guard.Touch()
 
 		case errMsg, ok := <-errs:
 			if !ok {
 				continue
 			}
 			if errMsg != nil {
-				// An error occurred: emit as a proper SSE error event
-				errorBytes, _ := json.Marshal(h.toClaudeError(errMsg))
-				_, _ = writer.WriteString("event: error\n")
-				_, _ = writer.WriteString("data: ")
-				_, _ = writer.Write(errorBytes)
-				_, _ = writer.WriteString("\n\n")
-				_ = writer.Flush()
+				h.writeErrorEvent(writer, errMsg)
 			}
 			var execErr error
 			if errMsg != nil {
@@ -268,6 +299,16 @@ func (h *ClaudeCodeAPIHandler) forwardClaudeStream(c *gin.Context, flusher http.
 	}
 }
 
+// writeErrorEvent emits an error as a proper SSE error event and flushes it.
+func (h *ClaudeCodeAPIHandler) writeErrorEvent(writer *bufio.Writer, errMsg *interfaces.ErrorMessage) {
+	errorBytes, _ := json.Marshal(toClaudeError(h.NormalizeError(errMsg)))
+	_, _ = writer.WriteString("event: error\n")
+	_, _ = writer.WriteString("data: ")
+	_, _ = writer.Write(errorBytes)
+	_, _ = writer.WriteString("\n\n")
+	_ = writer.Flush()
+}
+
 type claudeErrorDetail struct {
 	Type    string `json:"type"`
 	Message string `json:"message"`
@@ -278,12 +319,53 @@ type claudeErrorResponse struct {
 	Error claudeErrorDetail `json:"error"`
 }
 
-func (h *ClaudeCodeAPIHandler) toClaudeError(msg *interfaces.ErrorMessage) claudeErrorResponse {
+func toClaudeError(apiErr handlers.APIError) claudeErrorResponse {
 	return claudeErrorResponse{
 		Type: "error",
 		Error: claudeErrorDetail{
-			Type:    "api_error",
-			Message: msg.Error.Error(),
+			Type:    claudeErrorType(apiErr.Code),
+			Message: apiErr.Message,
 		},
 	}
 }
+
+// writeClaudeError writes msg in the Anthropic error envelope so Claude SDKs can parse it,
+// regardless of which upstream provider produced the failure.
+func (h *ClaudeCodeAPIHandler) writeClaudeError(c *gin.Context, msg *interfaces.ErrorMessage) {
+	apiErr := h.NormalizeError(msg)
+	if msg != nil {
+		for key, values := range msg.Addon {
+			if len(values) == 0 {
+				continue
+			}
+			c.Writer.Header().Del(key)
+			for _, value := range values {
+				c.Writer.Header().Add(key, value)
+			}
+		}
+	}
+	c.Header(handlers.ErrorCodeHeader, string(apiErr.Code))
+	c.JSON(apiErr.Status, toClaudeError(apiErr))
+}
+
+// claudeErrorType maps a proxy error code to the Anthropic error type.
+func claudeErrorType(code handlers.ErrorCode) string {
+	switch code {
+	case handlers.ErrorCodeInvalidRequest, handlers.ErrorCodeContextLength, handlers.ErrorCodeContentFiltered:
+		return "invalid_request_error"
+	case handlers.ErrorCodeAuthentication:
+		return "authentication_error"
+	case handlers.ErrorCodePermissionDenied:
+		return "permission_error"
+	case handlers.ErrorCodeNotFound:
+		return "not_found_error"
+	case handlers.ErrorCodeRequestTooLarge:
+		return "request_too_large"
+	case handlers.ErrorCodeRateLimited, handlers.ErrorCodeQuotaExceeded:
+		return "rate_limit_error"
+	case handlers.ErrorCodeOverloaded, handlers.ErrorCodeNoAccount:
+		return "overloaded_error"
+	default:
+		return "api_error"
+	}
+}
@@ -0,0 +1,367 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"golang.org/x/net/context"
+)
+
+const (
+	// compactedHeader reports how a conversation was shortened to fit the context window.
+	compactedHeader = "X-Context-Compacted"
+	// summaryCacheSize bounds the summaries kept for requests that are retried.
+	summaryCacheSize = 128
+	// maxTranscriptPartBytes bounds each tool call or result copied into a summary request.
+	maxTranscriptPartBytes = 2000
+
+	summaryInstructions = "Summarize the conversation below so it can continue without it. " +
+		"Keep facts, decisions, names, numbers, code identifiers, tool results and open questions; " +
+		"omit pleasantries. Answer with the summary only."
+	summaryPreamble = "Summary of the earlier conversation, shortened to fit the context window:\n\n"
+)
+
+// summaryRequestKey marks the contexts of summary requests, which are never compacted.
+type summaryRequestKey struct{}
+
+// summaryCache keeps the summaries written for recent conversations, so retries and
+// fallbacks of a request do not summarize it again.
+type summaryCache struct {
+	mu      sync.Mutex
+	entries map[string]string
+	order   []string
+}
+
+func (c *summaryCache) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	summary, ok := c.entries[key]
+	return summary, ok
+}
+
+func (c *summaryCache) put(key, summary string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]string, summaryCacheSize)
+	}
+	if _, ok := c.entries[key]; ok {
+		return
+	}
+	if len(c.order) >= summaryCacheSize {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.entries[key] = summary
+	c.order = append(c.order, key)
+}
+
+// conversationLayout describes where a request format keeps its messages and system prompt.
+type conversationLayout struct {
+	format string
+	// messages is the path of the message array.
+	messages string
+	// system is the path of the system prompt; empty when system prompts are messages.
+	system string
+}
+
+func layoutFor(handlerType string, root gjson.Result) (conversationLayout, bool) {
+	switch handlerType {
+	case "openai":
+		return conversationLayout{format: handlerType, messages: "messages"}, root.Get("messages").IsArray()
+	case "openai-response":
+		return conversationLayout{format: handlerType, messages: "input"}, root.Get("input").IsArray()
+	case "claude":
+		return conversationLayout{format: handlerType, messages: "messages", system: "system"}, root.Get("messages").IsArray()
+	case "gemini", "gemini-cli":
+		prefix := ""
+		if root.Get("request.contents").IsArray() {
+			prefix = "request."
+		}
+		system := prefix + "systemInstruction"
+		if !root.Get(system).Exists() && root.Get(prefix+"system_instruction").Exists() {
+			system = prefix + "system_instruction"
+		}
+		return conversationLayout{format: "gemini", messages: prefix + "contents", system: system}, root.Get(prefix + "contents").IsArray()
+	}
+	return conversationLayout{}, false
+}
+
+// compactContext shortens the conversation of a request whose estimated prompt exceeds the
+// context window of modelName with the configured strategy. Requests that fit, models
+// without a known window and formats without a message list are returned unchanged.
+func (h *BaseAPIHandler) compactContext(ctx context.Context, handlerType, modelName string, rawJSON []byte) []byte {
+	if h.Cfg == nil || !h.Cfg.ContextCompaction.Enable || ctx.Value(summaryRequestKey{}) != nil {
+		return rawJSON
+	}
+	cfg := h.Cfg.ContextCompaction
+	window := registry.GetGlobalRegistry().GetModelContextWindow(modelName)
+	if window <= 0 {
+		return rawJSON
+	}
+	limit := window - cfg.ReserveTokens
+	tokens := requestRequirements(rawJSON).InputTokens
+	if tokens <= limit {
+		return rawJSON
+	}
+	root := gjson.ParseBytes(rawJSON)
+	layout, ok := layoutFor(handlerType, root)
+	if !ok {
+		return rawJSON
+	}
+	messages := root.Get(layout.messages).Array()
+	budget := limit
+	if cfg.Strategy == config.CompactionSummarize {
+		budget -= cfg.SummaryMaxTokens
+	}
+	drop, remaining := compactionDrops(messages, tokens, budget, cfg.Strategy != config.CompactionDropOldest)
+	if drop == nil {
+		log.Warnf("context compaction: %s prompt of about %d tokens exceeds its %d token window and cannot be shortened", modelName, tokens, window)
+		return rawJSON
+	}
+	applied := cfg.Strategy
+	summary := ""
+	if cfg.Strategy == config.CompactionSummarize {
+		if summary = h.summarizeMessages(ctx, messages, drop); summary == "" {
+			applied = config.CompactionSlidingWindow
+		}
+	}
+	out, err := rebuildConversation(rawJSON, layout, messages, drop, summary)
+	if err != nil {
+		log.Warnf("context compaction: rebuild %s request: %v", modelName, err)
+		return rawJSON
+	}
+	dropped := 0
+	for _, removed := range drop {
+		if removed {
+			dropped++
+		}
+	}
+	if remaining > budget {
+		log.Warnf("context compaction: %s prompt still exceeds its %d token window after dropping %d messages", modelName, window, dropped)
+	} else {
+		log.Infof("context compaction: %s prompt of about %d tokens exceeds its %d token window, %d messages removed (%s)", modelName, tokens, window, dropped, applied)
+	}
+	if ginCtx, okGin := ctx.Value("gin").(*gin.Context); okGin && ginCtx != nil {
+		ginCtx.Header(compactedHeader, fmt.Sprintf("%s; removed=%d", applied, dropped))
+	}
+	return out
+}
+
+// compactionDrops marks the oldest messages to remove until the estimated prompt fits
+// budget, removing whole turns so the conversation resumes at a user message. The latest
+// turn is never removed, and system messages are kept when keepSystem is set. It returns nil
+// when nothing can be removed, along with the estimate after the removal.
+func compactionDrops(messages []gjson.Result, tokens, budget int, keepSystem bool) ([]bool, int) {
+	last := len(messages) - 1
+	for last > 0 && !startsTurn(messages[last]) {
+		last--
+	}
+	drop := make([]bool, len(messages))
+	kept := func(message gjson.Result) bool { return keepSystem && isSystemMessage(message) }
+	removed := false
+	for i := 0; i < last && tokens > budget; i++ {
+		if kept(messages[i]) {
+			continue
+		}
+		drop[i], removed = true, true
+		tokens -= messageTokens(messages[i])
+		for i+1 < last && !startsTurn(messages[i+1]) && !kept(messages[i+1]) {
+			i++
+			drop[i] = true
+			tokens -= messageTokens(messages[i])
+		}
+	}
+	if !removed {
+		return nil, tokens
+	}
+	return drop, tokens
+}
+
+// rebuildConversation removes the dropped messages from a request and adds the summary of
+// them, if any, to its system prompt: as a system message in place of the removed messages
+// for OpenAI formats, or appended to the system prompt field of Claude and Gemini.
+func rebuildConversation(rawJSON []byte, layout conversationLayout, messages []gjson.Result, drop []bool, summary string) ([]byte, error) {
+	var array strings.Builder
+	array.WriteByte('[')
+	first := true
+	write := func(raw string) {
+		if !first {
+			array.WriteByte(',')
+		}
+		array.WriteString(raw)
+		first = false
+	}
+	summarized := summary == ""
+	for i, message := range messages {
+		if drop[i] {
+			if !summarized && layout.system == "" {
+				write(string(marshal(map[string]string{"role": "system", "content": summaryPreamble + summary})))
+				summarized = true
+			}
+			continue
+		}
+		write(message.Raw)
+	}
+	array.WriteByte(']')
+	out, err := sjson.SetRawBytes(rawJSON, layout.messages, []byte(array.String()))
+	if err != nil || summarized {
+		return out, err
+	}
+	text := summaryPreamble + summary
+	system := gjson.GetBytes(out, layout.system)
+	switch {
+	case layout.format == "claude" && system.Type == gjson.String && system.String() != "":
+		return sjson.SetBytes(out, layout.system, system.String()+"\n\n"+text)
+	case layout.format == "claude" && system.IsArray():
+		return sjson.SetRawBytes(out, layout.system+".-1", marshal(map[string]string{"type": "text", "text": text}))
+	case layout.format == "claude":
+		return sjson.SetBytes(out, layout.system, text)
+	case system.Get("parts").IsArray():
+		return sjson.SetRawBytes(out, layout.system+".parts.-1", marshal(map[string]string{"text": text}))
+	default:
+		return sjson.SetRawBytes(out, layout.system, marshal(map[string]any{"parts": []map[string]string{{"text": text}}}))
+	}
+}
+
+// summarizeMessages asks the summary model for a summary of the dropped messages; empty
+// when the request fails.
+func (h *BaseAPIHandler) summarizeMessages(ctx context.Context, messages []gjson.Result, drop []bool) string {
+	var transcript strings.Builder
+	for i, message := range messages {
+		if drop[i] {
+			writeTranscript(&transcript, message)
+		}
+	}
+	model := h.Cfg.ContextCompaction.SummaryModel
+	digest := sha256.Sum256([]byte(model + "\x00" + transcript.String()))
+	key := hex.EncodeToString(digest[:])
+	if summary, ok := h.summaries.get(key); ok {
+		return summary
+	}
+	body := marshal(map[string]any{
+		"model": model,
+		"messages": []map[string]string{
+			{"role": "system", "content": summaryInstructions},
+			{"role": "user", "content": transcript.String()},
+		},
+		"max_tokens": h.Cfg.ContextCompaction.SummaryMaxTokens,
+	})
+	resp, errMsg := h.executeModel(context.WithValue(ctx, summaryRequestKey{}, true), "openai", model, body, "")
+	if errMsg != nil {
+		log.Warnf("context compaction: summary by %s failed with status %d: %v", model, errMsg.StatusCode, errMsg.Error)
+		return ""
+	}
+	summary := strings.TrimSpace(gjson.GetBytes(resp, "choices.0.message.content").String())
+	if summary == "" {
+		log.Warnf("context compaction: summary by %s returned no text", model)
+		return ""
+	}
+	h.summaries.put(key, summary)
+	return summary
+}
+
+// writeTranscript appends a message to a summary transcript as "role: text", with images
+// replaced by a marker and tool calls and results copied as shortened JSON.
+func writeTranscript(out *strings.Builder, message gjson.Result) {
+	role := message.Get("role").String()
+	switch role {
+	case "":
+		role = message.Get("type").String()
+	case "model":
+		role = "assistant"
+	}
+	out.WriteString(role)
+	out.WriteString(":")
+	if content := message.Get("content"); content.Type == gjson.String {
+		out.WriteString(" ")
+		out.WriteString(content.String())
+	}
+	parts := messageParts(message)
+	if len(parts) == 0 && message.Get("role").String() == "" {
+		parts = []gjson.Result{message}
+	}
+	for _, part := range parts {
+		out.WriteString(" ")
+		if _, isImage := imagePart(part); isImage {
+			out.WriteString("[image]")
+			continue
+		}
+		switch text := part.Get("text"); {
+		case text.Exists() && !part.Get("thought").Bool():
+			out.WriteString(text.String())
+		case part.Get("type").String() == "thinking", part.Get("thought").Bool():
+		default:
+			out.WriteString(shortenPart(part.Raw))
+		}
+	}
+	for _, call := range message.Get("tool_calls").Array() {
+		out.WriteString(" ")
+		out.WriteString(shortenPart(call.Raw))
+	}
+	out.WriteString("\n\n")
+}
+
+func shortenPart(raw string) string {
+	if len(raw) <= maxTranscriptPartBytes {
+		return raw
+	}
+	return raw[:maxTranscriptPartBytes] + "…"
+}
+
+// isSystemMessage reports whether a message of an OpenAI request is a system prompt.
+func isSystemMessage(message gjson.Result) bool {
+	role := message.Get("role").String()
+	return role == "system" || role == "developer"
+}
+
+// startsTurn reports whether a message is a user message that starts a turn, rather than a
+// reply, a tool result or a system message.
+func startsTurn(message gjson.Result) bool {
+	if message.Get("role").String() != "user" {
+		return false
+	}
+	if typ := message.Get("type").String(); typ != "" && typ != "message" {
+		return false
+	}
+	for _, part := range messageParts(message) {
+		if part.Get("type").String() == "tool_result" || part.Get("functionResponse").Exists() || part.Get("function_response").Exists() {
+			return false
+		}
+	}
+	return true
+}
+
+// messageTokens estimates the size of a message the way requestRequirements sizes prompts.
+func messageTokens(message gjson.Result) int {
+	imageBytes := 0
+	for _, part := range messageParts(message) {
+		if size, ok := imagePart(part); ok {
+			imageBytes += size
+		}
+		for _, nested := range part.Get("content").Array() {
+			if size, ok := imagePart(nested); ok {
+				imageBytes += size
+			}
+		}
+	}
+	return max(len(message.Raw)+1-imageBytes, 0) / promptBytesPerToken
+}
+
+func marshal(value any) []byte {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	return data
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"golang.org/x/net/context"
+)
+
+// Response headers carrying the routing diagnostics of a request, next to ProviderHeader and
+// AccountHeader, which name the provider and account that served it.
+const (
+	DiagnosticsRetriesHeader      = "X-CLIProxy-Retries"
+	DiagnosticsLatencyHeader      = "X-CLIProxy-Upstream-Latency-Ms"
+	DiagnosticsInputTokensHeader  = "X-CLIProxy-Input-Tokens"
+	DiagnosticsOutputTokensHeader = "X-CLIProxy-Output-Tokens"
+	DiagnosticsTotalTokensHeader  = "X-CLIProxy-Total-Tokens"
+)
+
+// diagnosticsKey is the Gin context key of the request's diagnostics.
+const diagnosticsKey = "cliproxy.diagnostics"
+
+// diagnosticsEvent names the SSE event that ends a stream with its diagnostics.
+const diagnosticsEvent = "cliproxy.diagnostics"
+
+// attachDiagnostics starts collecting the routing diagnostics of a request when they are
+// enabled, and arranges for them to be sent as response headers.
+func (h *BaseAPIHandler) attachDiagnostics(ctx context.Context, c *gin.Context) context.Context {
+	if h.Cfg == nil || (!h.Cfg.Diagnostics.Headers && !h.Cfg.Diagnostics.StreamEvent) {
+		return ctx
+	}
+	// Realtime sessions create a context per response on the same Gin context.
+	value, _ := c.Get(diagnosticsKey)
+	diagnostics, ok := value.(*coreexecutor.Diagnostics)
+	if !ok {
+		diagnostics = &coreexecutor.Diagnostics{}
+		c.Set(diagnosticsKey, diagnostics)
+		if h.Cfg.Diagnostics.Headers && c.Writer != nil && !c.IsWebsocket() {
+			c.Writer = &diagnosticsWriter{ResponseWriter: c.Writer, diagnostics: diagnostics, showAccount: h.diagnosticsAccountAllowed(c)}
+		}
+	}
+	return coreexecutor.WithDiagnostics(ctx, diagnostics)
+}
+
+// DiagnosticsEvent returns the SSE event that ends a stream with its diagnostics, or nil
+// when stream events are disabled.
+func (h *BaseAPIHandler) DiagnosticsEvent(c *gin.Context) []byte {
+	if h.Cfg == nil || !h.Cfg.Diagnostics.StreamEvent {
+		return nil
+	}
+	value, _ := c.Get(diagnosticsKey)
+	diagnostics, ok := value.(*coreexecutor.Diagnostics)
+	if !ok {
+		return nil
+	}
+	snapshot := diagnostics.Snapshot()
+	if !h.diagnosticsAccountAllowed(c) {
+		snapshot.AuthID = ""
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil
+	}
+	return []byte("event: " + diagnosticsEvent + "\ndata: " + string(data) + "\n\n")
+}
+
+// diagnosticsAccountAllowed reports whether the API key of the request is shown the account
+// that served it.
+func (h *BaseAPIHandler) diagnosticsAccountAllowed(c *gin.Context) bool {
+	if h.Cfg == nil || len(h.Cfg.Diagnostics.AccountAPIKeys) == 0 {
+		return false
+	}
+	value, exists := c.Get("apiKey")
+	if !exists {
+		return false
+	}
+	apiKey := fmt.Sprint(value)
+	return apiKey != "" && slices.Contains(h.Cfg.Diagnostics.AccountAPIKeys, apiKey)
+}
+
+// diagnosticsWriter sets the diagnostics headers just before the response headers are sent.
+type diagnosticsWriter struct {
+	gin.ResponseWriter
+	diagnostics *coreexecutor.Diagnostics
+	showAccount bool
+	once        sync.Once
+}
+
+func (w *diagnosticsWriter) setHeaders() {
+	w.once.Do(func() {
+		if w.ResponseWriter.Written() {
+			return
+		}
+		snapshot := w.diagnostics.Snapshot()
+		if snapshot.Attempts == 0 {
+			return
+		}
+		header := w.Header()
+		header.Set(ProviderHeader, snapshot.Provider)
+		if w.showAccount && snapshot.AuthID != "" {
+			header.Set(AccountHeader, snapshot.AuthID)
+		}
+		header.Set(DiagnosticsRetriesHeader, strconv.Itoa(snapshot.Attempts-1))
+		if snapshot.LatencyMs > 0 {
+			header.Set(DiagnosticsLatencyHeader, strconv.FormatInt(snapshot.LatencyMs, 10))
+		}
+		if snapshot.TotalTokens > 0 {
+			header.Set(DiagnosticsInputTokensHeader, strconv.FormatInt(snapshot.InputTokens, 10))
+			header.Set(DiagnosticsOutputTokensHeader, strconv.FormatInt(snapshot.OutputTokens, 10))
+			header.Set(DiagnosticsTotalTokensHeader, strconv.FormatInt(snapshot.TotalTokens, 10))
+		}
+	})
+}
+
+func (w *diagnosticsWriter) Write(data []byte) (int, error) {
+	w.setHeaders()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *diagnosticsWriter) WriteString(s string) (int, error) {
+	w.setHeaders()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *diagnosticsWriter) WriteHeaderNow() {
+	w.setHeaders()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *diagnosticsWriter) Flush() {
+	w.setHeaders()
+	w.ResponseWriter.Flush()
+}
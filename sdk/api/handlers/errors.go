@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/errorcode"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/tidwall/gjson"
+)
+
+// ErrorCode is the stable proxy code an error is classified as, independent of the
+// provider that produced it.
+type ErrorCode = errorcode.Code
+
+const (
+	ErrorCodeInvalidRequest   = errorcode.InvalidRequest
+	ErrorCodeContextLength    = errorcode.ContextLength
+	ErrorCodeContentFiltered  = errorcode.ContentFiltered
+	ErrorCodeRequestTooLarge  = errorcode.RequestTooLarge
+	ErrorCodeAuthentication   = errorcode.Authentication
+	ErrorCodePermissionDenied = errorcode.PermissionDenied
+	ErrorCodeNotFound         = errorcode.NotFound
+	ErrorCodeRateLimited      = errorcode.RateLimited
+	ErrorCodeQuotaExceeded    = errorcode.QuotaExceeded
+	ErrorCodeOverloaded       = errorcode.Overloaded
+	ErrorCodeTimeout          = errorcode.Timeout
+	ErrorCodeNoAccount        = errorcode.NoAccount
+	ErrorCodeUpstream         = errorcode.Upstream
+	ErrorCodeInternal         = errorcode.Internal
+)
+
+// ErrorCodeHeader carries the proxy error code of a failed request, in every client schema.
+const ErrorCodeHeader = "X-CLIProxy-Error-Code"
+
+// APIError is an error classified into the proxy taxonomy, ready to be written in the
+// schema of the client's API.
+type APIError struct {
+	Status  int
+	Code    ErrorCode
+	Message string
+}
+
+// ClassifyError maps an error from the auth manager, an executor or an upstream to a
+// stable proxy error code and extracts a human readable message from it.
+func ClassifyError(msg *interfaces.ErrorMessage) APIError {
+	apiErr := APIError{Status: http.StatusInternalServerError, Code: ErrorCodeInternal}
+	if msg == nil {
+		apiErr.Message = http.StatusText(apiErr.Status)
+		return apiErr
+	}
+	if msg.StatusCode > 0 {
+		apiErr.Status = msg.StatusCode
+	}
+	apiErr.Message = http.StatusText(apiErr.Status)
+	if msg.Error != nil {
+		apiErr.Message = upstreamMessage(msg.Error.Error())
+	}
+	apiErr.Code = errorcode.Classify(apiErr.Status, msg.Error)
+	if apiErr.Code == ErrorCodeNoAccount && apiErr.Status == http.StatusInternalServerError {
+		apiErr.Status = http.StatusServiceUnavailable
+	}
+	return apiErr
+}
+
+// upstreamMessage returns the message of a provider error body in the OpenAI, Claude or
+// Gemini shape; other text is returned as is.
+func upstreamMessage(text string) string {
+	if !gjson.Valid(text) {
+		return text
+	}
+	for _, path := range []string{"error.message", "message", "0.error.message", "error"} {
+		if value := gjson.Get(text, path); value.Type == gjson.String && value.String() != "" {
+			return value.String()
+		}
+	}
+	return text
+}
+
+// errorCounter counts the errors written to clients per proxy error code.
+type errorCounter struct {
+	mu     sync.Mutex
+	counts map[ErrorCode]int64
+}
+
+func (e *errorCounter) add(code ErrorCode) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.counts == nil {
+		e.counts = make(map[ErrorCode]int64)
+	}
+	e.counts[code]++
+}
+
+func (e *errorCounter) snapshot() map[string]int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	counts := make(map[string]int64, len(e.counts))
+	for code, count := range e.counts {
+		counts[string(code)] = count
+	}
+	return counts
+}
+
+// NormalizeError classifies msg and counts it under its proxy error code. Handlers call it
+// once per error they return to a client.
+func (h *BaseAPIHandler) NormalizeError(msg *interfaces.ErrorMessage) APIError {
+	apiErr := ClassifyError(msg)
+	h.errorCounts.add(apiErr.Code)
+	return apiErr
+}
+
+// ErrorCounts returns the number of errors returned to clients per proxy error code.
+func (h *BaseAPIHandler) ErrorCounts() map[string]int64 { return h.errorCounts.snapshot() }
+
+// OpenAIErrorType returns the OpenAI error type of a proxy error code.
+func OpenAIErrorType(code ErrorCode) string {
+	switch code {
+	case ErrorCodeInvalidRequest, ErrorCodeContextLength, ErrorCodeContentFiltered, ErrorCodeRequestTooLarge, ErrorCodeNotFound:
+		return "invalid_request_error"
+	case ErrorCodeAuthentication:
+		return "authentication_error"
+	case ErrorCodePermissionDenied:
+		return "permission_error"
+	case ErrorCodeRateLimited:
+		return "rate_limit_error"
+	case ErrorCodeQuotaExceeded:
+		return "insufficient_quota"
+	case ErrorCodeTimeout:
+		return "timeout_error"
+	default:
+		return "server_error"
+	}
+}
+
+// GeminiErrorStatus returns the google.rpc status name of a proxy error code.
+func GeminiErrorStatus(code ErrorCode) string {
+	switch code {
+	case ErrorCodeInvalidRequest, ErrorCodeContextLength, ErrorCodeContentFiltered, ErrorCodeRequestTooLarge:
+		return "INVALID_ARGUMENT"
+	case ErrorCodeAuthentication:
+		return "UNAUTHENTICATED"
+	case ErrorCodePermissionDenied:
+		return "PERMISSION_DENIED"
+	case ErrorCodeNotFound:
+		return "NOT_FOUND"
+	case ErrorCodeRateLimited, ErrorCodeQuotaExceeded:
+		return "RESOURCE_EXHAUSTED"
+	case ErrorCodeOverloaded, ErrorCodeNoAccount:
+		return "UNAVAILABLE"
+	case ErrorCodeTimeout:
+		return "DEADLINE_EXCEEDED"
+	default:
+		return "INTERNAL"
+	}
+}
+
+// WriteErrorResponse writes msg as an OpenAI error object with the HTTP status embedded in
+// the message and the proxy error code in the code field. Once a stream has started the
+// error is sent as a final SSE event instead.
+func (h *BaseAPIHandler) WriteErrorResponse(c *gin.Context, msg *interfaces.ErrorMessage) {
+	apiErr := h.NormalizeError(msg)
+	body, _ := json.Marshal(ErrorResponse{
+		Error: ErrorDetail{
+			Message: apiErr.Message,
+			Type:    OpenAIErrorType(apiErr.Code),
+			Code:    string(apiErr.Code),
+		},
+	})
+	writeError(c, msg, apiErr, body)
+}
+
+// WriteGeminiErrorResponse writes msg as a Gemini error object. Once a stream has started
+// the error is sent as a final SSE event instead.
+func (h *BaseAPIHandler) WriteGeminiErrorResponse(c *gin.Context, msg *interfaces.ErrorMessage) {
+	apiErr := h.NormalizeError(msg)
+	writeError(c, msg, apiErr, GeminiErrorBody(apiErr))
+}
+
+// GeminiErrorBody renders an error as a Gemini error object, with the proxy error code as
+// the reason of its ErrorInfo detail.
+func GeminiErrorBody(apiErr APIError) []byte {
+	body, _ := json.Marshal(gin.H{
+		"error": gin.H{
+			"code":    apiErr.Status,
+			"message": apiErr.Message,
+			"status":  GeminiErrorStatus(apiErr.Code),
+			"details": []gin.H{{
+				"@type":  "type.googleapis.com/google.rpc.ErrorInfo",
+				"reason": strings.ToUpper(string(apiErr.Code)),
+				"domain": "cliproxy",
+			}},
+		},
+	})
+	return body
+}
+
+// writeError sends an error body with the headers msg carries.
+func writeError(c *gin.Context, msg *interfaces.ErrorMessage, apiErr APIError, body []byte) {
+	if c.Writer.Written() {
+		_, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", body)
+		return
+	}
+	if msg != nil {
+		for key, values := range msg.Addon {
+			if len(values) == 0 {
+				continue
+			}
+			c.Writer.Header().Del(key)
+			for _, value := range values {
+				c.Writer.Header().Add(key, value)
+			}
+		}
+	}
+	c.Header(ErrorCodeHeader, string(apiErr.Code))
+	c.Header("Content-Type", "application/json")
+	c.Status(apiErr.Status)
+	_, _ = c.Writer.Write(body)
+}
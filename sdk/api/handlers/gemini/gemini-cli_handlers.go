@@ -173,7 +173,7 @@ func (h *GeminiCLIAPIHandler) handleInternalGenerateContent(c *gin.Context, rawJ
 	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
 	resp, errMsg := h.ExecuteWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, "")
 	if errMsg != nil {
-		h.WriteErrorResponse(c, errMsg)
+		h.WriteGeminiErrorResponse(c, errMsg)
 		cliCancel(errMsg.Error)
 		return
 	}
@@ -182,16 +182,36 @@ func (h *GeminiCLIAPIHandler) handleInternalGenerateContent(c *gin.Context, rawJ
 }
 
 func (h *GeminiCLIAPIHandler) forwardCLIStream(c *gin.Context, flusher http.Flusher, alt string, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage) {
+	guard := h.NewStreamGuard()
+	defer guard.Stop()
+	keepAlive := guard.KeepAlive()
+	if alt != "" {
+		// Only SSE streams can carry keep-alive comments.
+		keepAlive = nil
+	}
 	for {
 		select {
 		case <-c.Request.Context().Done():
 			cancel(c.Request.Context().Err())
 			return
+		case <-keepAlive:
+			_, _ = c.Writer.Write(handlers.KeepAliveComment)
+			flusher.Flush()
+		case errMsg := <-guard.Timeout():
+			writeStreamErrorEvent(c, alt, h.NormalizeError(errMsg))
+			flusher.Flush()
+			cancel(errMsg.Error)
+			return
 		case chunk, ok := <-data:
 			if !ok {
+				if alt == "" {
+					_, _ = c.Writer.Write(h.DiagnosticsEvent(c))
+					flusher.Flush()
+				}
 				cancel(nil)
 				return
 			}
+			guard.Touch()
 			if alt == "" {
 				if bytes.Equal(chunk, []byte("data: [DONE]")) || bytes.Equal(chunk, []byte("[DONE]")) {
 					continue
@@ -212,7 +232,7 @@ func (h *GeminiCLIAPIHandler) forwardCLIStream(c *gin.Context, flusher http.Flus
 				continue
 			}
 			if errMsg != nil {
-				h.WriteErrorResponse(c, errMsg)
+				h.WriteGeminiErrorResponse(c, errMsg)
 				flusher.Flush()
 			}
 			var execErr error
@@ -227,7 +227,7 @@ func (h *GeminiAPIHandler) handleCountTokens(c *gin.Context, modelName string, r
 	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
 	resp, errMsg := h.ExecuteCountWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, alt)
 	if errMsg != nil {
-		h.WriteErrorResponse(c, errMsg)
+		h.WriteGeminiErrorResponse(c, errMsg)
 		cliCancel(errMsg.Error)
 		return
 	}
@@ -250,7 +250,7 @@ func (h *GeminiAPIHandler) handleGenerateContent(c *gin.Context, modelName strin
 	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
 	resp, errMsg := h.ExecuteWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, alt)
 	if errMsg != nil {
-		h.WriteErrorResponse(c, errMsg)
+		h.WriteGeminiErrorResponse(c, errMsg)
 		cliCancel(errMsg.Error)
 		return
 	}
@@ -259,16 +259,36 @@ func (h *GeminiAPIHandler) handleGenerateContent(c *gin.Context, modelName strin
 }
 
 func (h *GeminiAPIHandler) forwardGeminiStream(c *gin.Context, flusher http.Flusher, alt string, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage) {
+	guard := h.NewStreamGuard()
+	defer guard.Stop()
+	keepAlive := guard.KeepAlive()
+	if alt != "" {
+		// Only SSE streams can carry keep-alive comments.
+		keepAlive = nil
+	}
 	for {
 		select {
 		case <-c.Request.Context().Done():
 			cancel(c.Request.Context().Err())
 			return
+		case <-keepAlive:
+			_, _ = c.Writer.Write(handlers.KeepAliveComment)
+			flusher.Flush()
+		case errMsg := <-guard.Timeout():
+			writeStreamErrorEvent(c, alt, h.NormalizeError(errMsg))
+			flusher.Flush()
+			cancel(errMsg.Error)
+			return
 		case chunk, ok := <-data:
 			if !ok {
+				if alt == "" {
+					_, _ = c.Writer.Write(h.DiagnosticsEvent(c))
+					flusher.Flush()
+				}
 				cancel(nil)
 				return
 			}
+			guard.Touch()
 			if alt == "" {
 				_, _ = c.Writer.Write([]byte("data: "))
 				_, _ = c.Writer.Write(chunk)
@@ -282,7 +302,7 @@ func (h *GeminiAPIHandler) forwardGeminiStream(c *gin.Context, flusher http.Flus
 				continue
 			}
 			if errMsg != nil {
-				h.WriteErrorResponse(c, errMsg)
+				h.WriteGeminiErrorResponse(c, errMsg)
 				flusher.Flush()
 			}
 			var execErr error
@@ -295,3 +315,14 @@ func (h *GeminiAPIHandler) forwardGeminiStream(c *gin.Context, flusher http.Flus
 		}
 	}
 }
+
+// writeStreamErrorEvent ends a Gemini stream with an error object, framed as an SSE event
+// unless the client asked for another alt format.
+func writeStreamErrorEvent(c *gin.Context, alt string, apiErr handlers.APIError) {
+	body := handlers.GeminiErrorBody(apiErr)
+	if alt == "" {
+		_, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", body)
+		return
+	}
+	_, _ = c.Writer.Write(body)
+}
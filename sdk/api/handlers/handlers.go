@@ -5,16 +5,22 @@ package handlers
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/shadow"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
 	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
 	"golang.org/x/net/context"
 )
 
@@ -50,6 +56,34 @@ type BaseAPIHandler struct {
 
 	// OpenAICompatProviders is a list of provider names for OpenAI compatibility.
 	OpenAICompatProviders []string
+
+	// ModelAllowed, when set, rejects requests for models the caller may not use, such as
+	// models outside the allow-list of the caller's project.
+	ModelAllowed func(ctx context.Context, model string) bool
+
+	// Shadow, when set, duplicates a sample of successful requests to candidate models and
+	// stores both responses for comparison.
+	Shadow *shadow.Recorder
+
+	// Dispatch, when set, serves the requests a handler makes on behalf of its client, such
+	// as the turns of a realtime session, so they pass the same middleware as the client's
+	// own requests.
+	Dispatch http.Handler
+
+	// activeStreams counts the streamed responses in flight, so shutdown can drain them.
+	activeStreams atomic.Int64
+
+	// clientCancellations counts requests aborted because the client disconnected.
+	clientCancellations atomic.Int64
+
+	// errorCounts counts the errors returned to clients per proxy error code.
+	errorCounts errorCounter
+
+	// routingRules caches the compiled routing rules of Cfg.
+	routingRules routingRuleCache
+
+	// summaries caches the summaries written by context compaction.
+	summaries summaryCache
 }
 
 // NewBaseAPIHandlers creates a new API handlers instance.
@@ -77,6 +111,13 @@ func NewBaseAPIHandlers(cfg *config.SDKConfig, authManager *coreauth.Manager, op
 //   - cfg: The new application configuration
 func (h *BaseAPIHandler) UpdateClients(cfg *config.SDKConfig) { h.Cfg = cfg }
 
+// ActiveStreams returns the number of streamed responses in flight.
+func (h *BaseAPIHandler) ActiveStreams() int64 { return h.activeStreams.Load() }
+
+// ClientCancellations returns the number of requests aborted because the client
+// disconnected before the response completed.
+func (h *BaseAPIHandler) ClientCancellations() int64 { return h.clientCancellations.Load() }
+
 // GetAlt extracts the 'alt' parameter from the request query string.
 // It checks both 'alt' and '$alt' parameters and returns the appropriate value.
 //
@@ -99,7 +140,7 @@ func (h *BaseAPIHandler) GetAlt(c *gin.Context) string {
 }
 
 // GetContextWithCancel creates a new context with cancellation capabilities.
-// It embeds the Gin context and the API handler into the new context for later use.
+// It embeds the Gin context, the API handler and the caller's project into the new context for later use.
 // The returned cancel function also handles logging the API response if request logging is enabled.
 //
 // Parameters:
@@ -114,7 +155,38 @@ func (h *BaseAPIHandler) GetContextWithCancel(handler interfaces.APIHandler, c *
 	newCtx, cancel := context.WithCancel(ctx)
 	newCtx = context.WithValue(newCtx, "gin", c)
 	newCtx = context.WithValue(newCtx, "handler", handler)
+	if project := c.GetString("project"); project != "" {
+		newCtx = coreexecutor.WithProject(newCtx, project)
+	}
+	if apiKey := c.GetString("apiKey"); apiKey != "" {
+		newCtx = coreexecutor.WithAPIKey(newCtx, apiKey)
+	}
+	newCtx = h.attachDiagnostics(newCtx, c)
+	newCtx = util.WithMediaBudget(newCtx)
+	// Abort the upstream request as soon as the client goes away, so it stops generating
+	// tokens nobody reads.
+	var counted sync.Once
+	countCancellation := func() { counted.Do(func() { h.clientCancellations.Add(1) }) }
+	var clientCtx context.Context
+	if c.Request != nil {
+		clientCtx = c.Request.Context()
+		go func() {
+			select {
+			case <-clientCtx.Done():
+				if newCtx.Err() == nil {
+					countCancellation()
+					log.Debugf("client disconnected, aborting upstream request for %s", c.Request.URL.Path)
+					cancel()
+				}
+			case <-newCtx.Done():
+			}
+		}()
+	}
 	return newCtx, func(params ...interface{}) {
+		// A handler that noticed the disconnect itself still counts it.
+		if clientCtx != nil && clientCtx.Err() != nil {
+			countCancellation()
+		}
 		if h.Cfg.RequestLog {
 			if len(params) == 1 {
 				data := params[0]
@@ -136,12 +208,38 @@ func (h *BaseAPIHandler) GetContextWithCancel(handler interfaces.APIHandler, c *
 }
 
 // ExecuteWithAuthManager executes a non-streaming request via the core auth manager.
-// This path is the only supported execution route.
+// This path is the only supported execution route. When the model fails and has a fallback
+// chain, the request is retried against each fallback in turn.
 func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
-	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(modelName)
+	shadowDone := h.startShadow(ctx, handlerType, modelName, rawJSON, alt, false)
+	ctx, chain := h.modelChain(ctx, handlerType, modelName, rawJSON, false)
+	var errMsg *interfaces.ErrorMessage
+	for i, model := range chain {
+		var resp []byte
+		resp, errMsg = h.executeModel(ctx, handlerType, model, withFallbackModel(rawJSON, modelName, model), alt)
+		if errMsg == nil {
+			annotateServedModel(ctx, chain, model)
+			if shadowDone != nil {
+				shadowDone(resp)
+			}
+			return resp, nil
+		}
+		if i == len(chain)-1 || !fallbackEligible(ctx, errMsg) {
+			break
+		}
+		log.Warnf("model fallback: %s failed with status %d, trying %s", model, errMsg.StatusCode, chain[i+1])
+	}
+	return nil, errMsg
+}
+
+// executeModel executes a non-streaming request for one model of a fallback chain.
+func (h *BaseAPIHandler) executeModel(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
+	rawJSON = h.rewriteMappedModel(modelName, rawJSON)
+	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(ctx, modelName)
 	if errMsg != nil {
 		return nil, errMsg
 	}
+	rawJSON = h.compactContext(ctx, handlerType, normalizedModel, rawJSON)
 	req := coreexecutor.Request{
 		Model:   normalizedModel,
 		Payload: cloneBytes(rawJSON),
@@ -158,6 +256,8 @@ func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType
 	if cloned := cloneMetadata(metadata); cloned != nil {
 		opts.Metadata = cloned
 	}
+	opts.Metadata = withSessionID(ctx, opts.Metadata, rawJSON)
+	opts.Metadata = h.withRequirements(opts.Metadata, rawJSON)
 	resp, err := h.AuthManager.Execute(ctx, providers, req, opts)
 	if err != nil {
 		status := http.StatusInternalServerError
@@ -180,7 +280,8 @@ func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType
 // ExecuteCountWithAuthManager executes a non-streaming request via the core auth manager.
 // This path is the only supported execution route.
 func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
-	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(modelName)
+	rawJSON = h.rewriteMappedModel(modelName, rawJSON)
+	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(ctx, modelName)
 	if errMsg != nil {
 		return nil, errMsg
 	}
@@ -200,6 +301,7 @@ func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handle
 	if cloned := cloneMetadata(metadata); cloned != nil {
 		opts.Metadata = cloned
 	}
+	opts.Metadata = withSessionID(ctx, opts.Metadata, rawJSON)
 	resp, err := h.AuthManager.ExecuteCount(ctx, providers, req, opts)
 	if err != nil {
 		status := http.StatusInternalServerError
@@ -219,15 +321,45 @@ func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handle
 	return cloneBytes(resp.Payload), nil
 }
 
-// ExecuteStreamWithAuthManager executes a streaming request via the core auth manager.
-// This path is the only supported execution route.
-func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
-	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(modelName)
+// ExecuteEmbedWithAuthManager executes an embeddings request via the core auth manager.
+// The payload is an OpenAI embeddings request; executors translate it to their provider.
+func (h *BaseAPIHandler) ExecuteEmbedWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte) ([]byte, *interfaces.ErrorMessage) {
+	return h.executeDirectWithAuthManager(ctx, handlerType, modelName, rawJSON, nil, nil, h.AuthManager.ExecuteEmbed)
+}
+
+// ExecuteImagesWithAuthManager executes an image generation request via the core auth manager.
+// The payload is an OpenAI images generation request; executors translate it to their provider.
+func (h *BaseAPIHandler) ExecuteImagesWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte) ([]byte, *interfaces.ErrorMessage) {
+	return h.executeDirectWithAuthManager(ctx, handlerType, modelName, rawJSON, nil, nil, h.AuthManager.ExecuteImages)
+}
+
+// ExecuteTranscriptionWithAuthManager executes an audio transcription request via the core auth manager.
+// body is the multipart upload and contentType its Content-Type header including the boundary.
+func (h *BaseAPIHandler) ExecuteTranscriptionWithAuthManager(ctx context.Context, handlerType, modelName string, body []byte, contentType string) ([]byte, *interfaces.ErrorMessage) {
+	headers := http.Header{}
+	headers.Set("Content-Type", contentType)
+	return h.executeDirectWithAuthManager(ctx, handlerType, modelName, body, nil, headers, h.AuthManager.ExecuteTranscription)
+}
+
+// ExecuteTranscriptionBodyWithAuthManager executes an audio transcription request whose
+// multipart upload is not held in memory: open returns a new reader of the whole upload
+// each time it is sent upstream. contentType is its Content-Type header including the boundary.
+func (h *BaseAPIHandler) ExecuteTranscriptionBodyWithAuthManager(ctx context.Context, handlerType, modelName string, open func() (io.ReadCloser, error), contentType string) ([]byte, *interfaces.ErrorMessage) {
+	headers := http.Header{}
+	headers.Set("Content-Type", contentType)
+	return h.executeDirectWithAuthManager(ctx, handlerType, modelName, nil, open, headers, h.AuthManager.ExecuteTranscription)
+}
+
+// ExecuteSpeechWithAuthManager executes a text-to-speech request via the core auth manager.
+// It returns the media type of the audio and channels carrying the audio bytes and any error.
+func (h *BaseAPIHandler) ExecuteSpeechWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte) (string, <-chan []byte, <-chan *interfaces.ErrorMessage) {
+	errChan := make(chan *interfaces.ErrorMessage, 1)
+	rawJSON = h.rewriteMappedModel(modelName, rawJSON)
+	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(ctx, modelName)
 	if errMsg != nil {
-		errChan := make(chan *interfaces.ErrorMessage, 1)
 		errChan <- errMsg
 		close(errChan)
-		return nil, errChan
+		return "", nil, errChan
 	}
 	req := coreexecutor.Request{
 		Model:   normalizedModel,
@@ -236,6 +368,149 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 	if cloned := cloneMetadata(metadata); cloned != nil {
 		req.Metadata = cloned
 	}
+	opts := coreexecutor.Options{
+		Stream:          true,
+		OriginalRequest: cloneBytes(rawJSON),
+		SourceFormat:    sdktranslator.FromString(handlerType),
+	}
+	if cloned := cloneMetadata(metadata); cloned != nil {
+		opts.Metadata = cloned
+	}
+	stream, err := h.AuthManager.ExecuteSpeech(ctx, providers, req, opts)
+	if err != nil {
+		errChan <- toErrorMessage(err)
+		close(errChan)
+		return "", nil, errChan
+	}
+	dataChan := make(chan []byte)
+	go func() {
+		defer close(dataChan)
+		defer close(errChan)
+		for chunk := range stream.Chunks {
+			if chunk.Err != nil {
+				errChan <- toErrorMessage(chunk.Err)
+				return
+			}
+			if len(chunk.Payload) > 0 {
+				dataChan <- chunk.Payload
+			}
+		}
+	}()
+	return stream.ContentType, dataChan, errChan
+}
+
+// toErrorMessage wraps an execution error, keeping its status code and extra headers.
+func toErrorMessage(err error) *interfaces.ErrorMessage {
+	status := http.StatusInternalServerError
+	if se, ok := err.(interface{ StatusCode() int }); ok && se != nil {
+		if code := se.StatusCode(); code > 0 {
+			status = code
+		}
+	}
+	var addon http.Header
+	if he, ok := err.(interface{ Headers() http.Header }); ok && he != nil {
+		if hdr := he.Headers(); hdr != nil {
+			addon = hdr.Clone()
+		}
+	}
+	return &interfaces.ErrorMessage{StatusCode: status, Error: err, Addon: addon}
+}
+
+// executeDirectWithAuthManager runs a non-streaming request whose payload is passed to the
+// executor untranslated, such as embeddings, image generation and audio transcription.
+// A non-nil body opens the payload instead of rawJSON.
+func (h *BaseAPIHandler) executeDirectWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, body func() (io.ReadCloser, error), headers http.Header, execute func(context.Context, []string, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error)) ([]byte, *interfaces.ErrorMessage) {
+	rawJSON = h.rewriteMappedModel(modelName, rawJSON)
+	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(ctx, modelName)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	req := coreexecutor.Request{
+		Model:   normalizedModel,
+		Payload: cloneBytes(rawJSON),
+		Body:    body,
+	}
+	if cloned := cloneMetadata(metadata); cloned != nil {
+		req.Metadata = cloned
+	}
+	opts := coreexecutor.Options{
+		Stream:          false,
+		Headers:         headers,
+		OriginalRequest: cloneBytes(rawJSON),
+		SourceFormat:    sdktranslator.FromString(handlerType),
+	}
+	if cloned := cloneMetadata(metadata); cloned != nil {
+		opts.Metadata = cloned
+	}
+	resp, err := execute(ctx, providers, req, opts)
+	if err != nil {
+		return nil, toErrorMessage(err)
+	}
+	return cloneBytes(resp.Payload), nil
+}
+
+// ExecuteStreamWithAuthManager executes a streaming request via the core auth manager.
+// This path is the only supported execution route. When the model fails before its stream
+// starts and has a fallback chain, the request is retried against each fallback in turn; a
+// stream that fails after it started is resumed when stream recovery is enabled.
+func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
+	shadowDone := h.startShadow(ctx, handlerType, modelName, rawJSON, alt, true)
+	ctx, chain := h.modelChain(ctx, handlerType, modelName, rawJSON, true)
+	var errMsg *interfaces.ErrorMessage
+	for i, model := range chain {
+		var chunks <-chan coreexecutor.StreamChunk
+		chunks, errMsg = h.startStream(ctx, handlerType, model, withFallbackModel(rawJSON, modelName, model), alt)
+		if errMsg == nil {
+			annotateServedModel(ctx, chain, model)
+			var limit int
+			if shadowDone != nil {
+				limit = h.Shadow.MaxBodyBytes()
+			}
+			return forwardStream(chunks, shadowDone, limit, h.streamRecovery(ctx, handlerType, modelName, rawJSON, alt, chain, i))
+		}
+		if i == len(chain)-1 || !fallbackEligible(ctx, errMsg) {
+			break
+		}
+		log.Warnf("model fallback: %s failed with status %d, trying %s", model, errMsg.StatusCode, chain[i+1])
+	}
+	errChan := make(chan *interfaces.ErrorMessage, 1)
+	errChan <- errMsg
+	close(errChan)
+	return nil, errChan
+}
+
+// streamRecovery returns the recovery of a stream served by chain[served], or nil when
+// stream recovery is disabled.
+func (h *BaseAPIHandler) streamRecovery(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string, chain []string, served int) *streamRecovery {
+	if h.Cfg == nil {
+		return nil
+	}
+	fallback := h.Cfg.Streaming.RecoveryFallback
+	return newStreamRecovery(handlerType, rawJSON, alt, h.Cfg.Streaming.RecoveryAttempts, func(attempt int, body []byte) (<-chan coreexecutor.StreamChunk, *interfaces.ErrorMessage) {
+		model := chain[served]
+		if fallback {
+			model = chain[min(served+attempt, len(chain)-1)]
+		}
+		log.Warnf("stream recovery: resuming %s stream with %s (attempt %d)", modelName, model, attempt)
+		return h.startStream(ctx, handlerType, model, withFallbackModel(body, modelName, model), alt)
+	})
+}
+
+// startStream opens the upstream stream of a request for one model of a fallback chain.
+func (h *BaseAPIHandler) startStream(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) (<-chan coreexecutor.StreamChunk, *interfaces.ErrorMessage) {
+	rawJSON = h.rewriteMappedModel(modelName, rawJSON)
+	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(ctx, modelName)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	rawJSON = h.compactContext(ctx, handlerType, normalizedModel, rawJSON)
+	req := coreexecutor.Request{
+		Model:   normalizedModel,
+		Payload: cloneBytes(rawJSON),
+	}
+	if cloned := cloneMetadata(metadata); cloned != nil {
+		req.Metadata = cloned
+	}
 	opts := coreexecutor.Options{
 		Stream:          true,
 		Alt:             alt,
@@ -245,9 +520,10 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 	if cloned := cloneMetadata(metadata); cloned != nil {
 		opts.Metadata = cloned
 	}
+	opts.Metadata = withSessionID(ctx, opts.Metadata, rawJSON)
+	opts.Metadata = h.withRequirements(opts.Metadata, rawJSON)
 	chunks, err := h.AuthManager.ExecuteStream(ctx, providers, req, opts)
 	if err != nil {
-		errChan := make(chan *interfaces.ErrorMessage, 1)
 		status := http.StatusInternalServerError
 		if se, ok := err.(interface{ StatusCode() int }); ok && se != nil {
 			if code := se.StatusCode(); code > 0 {
@@ -260,41 +536,88 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 				addon = hdr.Clone()
 			}
 		}
-		errChan <- &interfaces.ErrorMessage{StatusCode: status, Error: err, Addon: addon}
-		close(errChan)
-		return nil, errChan
+		return nil, &interfaces.ErrorMessage{StatusCode: status, Error: err, Addon: addon}
 	}
+	return chunks, nil
+}
+
+// forwardStream relays the chunks of an upstream stream to the data and error channels
+// consumed by the handlers. When done is set, the first limit bytes of the stream are
+// collected and passed to it once the stream completes without error. When recovery is
+// set, a stream that fails is continued from the text already relayed.
+func forwardStream(chunks <-chan coreexecutor.StreamChunk, done func(response []byte), limit int, recovery *streamRecovery) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
 	dataChan := make(chan []byte)
 	errChan := make(chan *interfaces.ErrorMessage, 1)
 	go func() {
 		defer close(dataChan)
 		defer close(errChan)
-		for chunk := range chunks {
-			if chunk.Err != nil {
-				status := http.StatusInternalServerError
-				if se, ok := chunk.Err.(interface{ StatusCode() int }); ok && se != nil {
-					if code := se.StatusCode(); code > 0 {
-						status = code
+		var collected []byte
+		failed := false
+		defer func() {
+			if done != nil && !failed {
+				done(collected)
+			}
+		}()
+	relay:
+		for {
+			for chunk := range chunks {
+				if chunk.Err != nil {
+					if next, ok := recovery.resume(); ok {
+						log.Warnf("stream recovery: upstream stream failed: %v", chunk.Err)
+						if stop := recovery.closeOpenBlock(); len(stop) > 0 {
+							dataChan <- stop
+						}
+						chunks = next
+						continue relay
+					}
+					status := http.StatusInternalServerError
+					if se, ok := chunk.Err.(interface{ StatusCode() int }); ok && se != nil {
+						if code := se.StatusCode(); code > 0 {
+							status = code
+						}
+					}
+					var addon http.Header
+					if he, ok := chunk.Err.(interface{ Headers() http.Header }); ok && he != nil {
+						if hdr := he.Headers(); hdr != nil {
+							addon = hdr.Clone()
+						}
 					}
+					failed = true
+					errChan <- &interfaces.ErrorMessage{StatusCode: status, Error: chunk.Err, Addon: addon}
+					return
 				}
-				var addon http.Header
-				if he, ok := chunk.Err.(interface{ Headers() http.Header }); ok && he != nil {
-					if hdr := he.Headers(); hdr != nil {
-						addon = hdr.Clone()
+				if len(chunk.Payload) == 0 {
+					continue
+				}
+				payload := cloneBytes(chunk.Payload)
+				if recovery != nil {
+					if payload = recovery.process(payload); len(payload) == 0 {
+						continue
 					}
 				}
-				errChan <- &interfaces.ErrorMessage{StatusCode: status, Error: chunk.Err, Addon: addon}
-				return
-			}
-			if len(chunk.Payload) > 0 {
-				dataChan <- cloneBytes(chunk.Payload)
+				if done != nil && len(collected) < limit {
+					collected = append(collected, payload[:min(len(payload), limit-len(collected))]...)
+				}
+				dataChan <- payload
 			}
+			return
 		}
 	}()
 	return dataChan, errChan
 }
 
-func (h *BaseAPIHandler) getRequestDetails(modelName string) (providers []string, normalizedModel string, metadata map[string]any, err *interfaces.ErrorMessage) {
+func (h *BaseAPIHandler) getRequestDetails(ctx context.Context, modelName string) (providers []string, normalizedModel string, metadata map[string]any, err *interfaces.ErrorMessage) {
+	if h.ModelAllowed != nil && !h.ModelAllowed(ctx, modelName) {
+		return nil, "", nil, &interfaces.ErrorMessage{StatusCode: http.StatusForbidden, Error: fmt.Errorf("model %s is not allowed for this API key", modelName)}
+	}
+	var pinnedProvider string
+	if h.Cfg != nil && len(h.Cfg.ModelMappings) > 0 {
+		requested := modelName
+		modelName, pinnedProvider = mapModel(h.Cfg.ModelMappings, modelName)
+		if modelName != requested || pinnedProvider != "" {
+			log.Debugf("model mapping: %s -> %s (provider=%q)", requested, modelName, pinnedProvider)
+		}
+	}
 	providerName, extractedModelName, isDynamic := h.parseDynamicModel(modelName)
 
 	// First, normalize the model name to handle suffixes like "-thinking-128"
@@ -310,11 +633,25 @@ func (h *BaseAPIHandler) getRequestDetails(modelName string) (providers []string
 		// For non-dynamic models, use the normalizedModel to get the provider name.
 		providers = util.GetProviderName(normalizedModel)
 	}
+	if pinnedProvider != "" {
+		providers = []string{pinnedProvider}
+	}
 
 	if len(providers) == 0 {
 		return nil, "", nil, &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: fmt.Errorf("unknown provider for model %s", modelName)}
 	}
 
+	providers, pinnedAuth, errMsg := h.applyRoutingHeaders(ctx, modelName, providers)
+	if errMsg != nil {
+		return nil, "", nil, errMsg
+	}
+	if pinnedAuth != "" {
+		if metadata == nil {
+			metadata = make(map[string]any, 1)
+		}
+		metadata[coreexecutor.PinnedAuthMetadataKey] = pinnedAuth
+	}
+
 	// If it's a dynamic model, the normalizedModel was already set to extractedModelName.
 	// If it's a non-dynamic model, normalizedModel was set by normalizeModelMetadata.
 	// So, normalizedModel is already correctly set at this point.
@@ -383,31 +720,6 @@ func cloneMetadata(src map[string]any) map[string]any {
 	return dst
 }
 
-// WriteErrorResponse writes an error message to the response writer using the HTTP status embedded in the message.
-func (h *BaseAPIHandler) WriteErrorResponse(c *gin.Context, msg *interfaces.ErrorMessage) {
-	status := http.StatusInternalServerError
-	if msg != nil && msg.StatusCode > 0 {
-		status = msg.StatusCode
-	}
-	if msg != nil && msg.Addon != nil {
-		for key, values := range msg.Addon {
-			if len(values) == 0 {
-				continue
-			}
-			c.Writer.Header().Del(key)
-			for _, value := range values {
-				c.Writer.Header().Add(key, value)
-			}
-		}
-	}
-	c.Status(status)
-	if msg != nil && msg.Error != nil {
-		_, _ = c.Writer.Write([]byte(msg.Error.Error()))
-	} else {
-		_, _ = c.Writer.Write([]byte(http.StatusText(status)))
-	}
-}
-
 func (h *BaseAPIHandler) LoggingAPIResponseError(ctx context.Context, err *interfaces.ErrorMessage) {
 	if h.Cfg.RequestLog {
 		if ginContext, ok := ctx.Value("gin").(*gin.Context); ok {
@@ -427,3 +739,52 @@ func (h *BaseAPIHandler) LoggingAPIResponseError(ctx context.Context, err *inter
 // APIHandlerCancelFunc is a function type for canceling an API handler's context.
 // It can optionally accept parameters, which are used for logging the response.
 type APIHandlerCancelFunc func(params ...interface{})
+
+// sessionIDHeaders lists the request headers clients use to identify a conversation.
+var sessionIDHeaders = []string{"X-Session-Id", "X-Conversation-Id", "Session_id", "Conversation_id"}
+
+// sessionIDFields lists the request body fields clients use to identify a conversation.
+var sessionIDFields = []string{"conversation_id", "session_id", "prompt_cache_key", "metadata.session_id", "metadata.conversation_id"}
+
+// withSessionID records the client-provided conversation identifier in the execution metadata
+// so the auth manager can keep the conversation on one account.
+func withSessionID(ctx context.Context, metadata map[string]any, rawJSON []byte) map[string]any {
+	sessionID := requestSessionID(ctx, rawJSON)
+	if sessionID == "" {
+		return metadata
+	}
+	if metadata == nil {
+		metadata = make(map[string]any, 1)
+	}
+	metadata[coreexecutor.SessionIDMetadataKey] = sessionID
+	return metadata
+}
+
+// requestSessionID returns the conversation identifier a client sent in the headers or body
+// of a request; empty when there is none.
+func requestSessionID(ctx context.Context, rawJSON []byte) string {
+	sessionID := ""
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil && ginCtx.Request != nil {
+		for _, header := range sessionIDHeaders {
+			if value := strings.TrimSpace(ginCtx.GetHeader(header)); value != "" {
+				sessionID = value
+				break
+			}
+		}
+	}
+	if sessionID == "" && len(rawJSON) > 0 {
+		for _, field := range sessionIDFields {
+			if value := strings.TrimSpace(gjson.GetBytes(rawJSON, field).String()); value != "" {
+				sessionID = value
+				break
+			}
+		}
+	}
+	// Claude Code embeds its session in metadata.user_id as "user_<hash>_account_<uuid>_session_<uuid>".
+	if sessionID == "" && len(rawJSON) > 0 {
+		if _, session, found := strings.Cut(gjson.GetBytes(rawJSON, "metadata.user_id").String(), "_session_"); found {
+			sessionID = strings.TrimSpace(session)
+		}
+	}
+	return sessionID
+}
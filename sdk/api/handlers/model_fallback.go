@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"golang.org/x/net/context"
+)
+
+const (
+	// servedModelHeader names the model that produced a response whose model has a fallback
+	// chain.
+	servedModelHeader = "X-Served-Model"
+	// fallbackFromHeader names the requested model when a fallback served the response.
+	fallbackFromHeader = "X-Fallback-From"
+)
+
+// modelChain returns the models to try for a requested model, in order: the model chosen by
+// its traffic split followed by its fallbacks. A matching routing rule replaces the requested
+// model first. A split target that fails falls back to the requested model and its own
+// chain. The returned context records the split assignment.
+func (h *BaseAPIHandler) modelChain(ctx context.Context, handlerType, modelName string, rawJSON []byte, stream bool) (context.Context, []string) {
+	modelName = h.applyRoutingRules(ctx, handlerType, modelName, rawJSON, stream)
+	assigned, ctx := h.assignSplit(ctx, modelName, rawJSON)
+	chain := h.fallbackChain(assigned)
+	if assigned == modelName {
+		return ctx, chain
+	}
+	seen := make(map[string]bool, len(chain))
+	for _, model := range chain {
+		seen[model] = true
+	}
+	for _, model := range h.fallbackChain(modelName) {
+		if !seen[model] {
+			chain = append(chain, model)
+		}
+	}
+	return ctx, chain
+}
+
+// fallbackChain returns the models to try for a requested model: the model itself followed
+// by its configured fallbacks.
+func (h *BaseAPIHandler) fallbackChain(modelName string) []string {
+	chain := []string{modelName}
+	if h.Cfg == nil {
+		return chain
+	}
+	for _, entry := range h.Cfg.ModelFallbacks {
+		if strings.TrimSpace(entry.Model) != modelName {
+			continue
+		}
+		for _, fallback := range entry.Fallbacks {
+			if fallback = strings.TrimSpace(fallback); fallback != "" && fallback != modelName {
+				chain = append(chain, fallback)
+			}
+		}
+		break
+	}
+	return chain
+}
+
+// fallbackEligible reports whether a failed request may be retried against the next model
+// of its chain: server errors, rate limits, timeouts, models the caller's accounts cannot
+// serve and models lacking a feature the request uses qualify, while invalid requests and
+// requests the client abandoned do not.
+func fallbackEligible(ctx context.Context, errMsg *interfaces.ErrorMessage) bool {
+	if errMsg == nil || ctx.Err() != nil {
+		return false
+	}
+	var authErr *coreauth.Error
+	if errors.As(errMsg.Error, &authErr) && authErr.Code == coreauth.CapabilityErrorCode {
+		return true
+	}
+	switch status := errMsg.StatusCode; {
+	case status == http.StatusUnauthorized, status == http.StatusForbidden, status == http.StatusNotFound:
+		return true
+	case status == http.StatusRequestTimeout, status == http.StatusTooManyRequests:
+		return true
+	default:
+		return status == 0 || status >= http.StatusInternalServerError
+	}
+}
+
+// withFallbackModel points the model field of a request body at the fallback model.
+func withFallbackModel(rawJSON []byte, requested, fallback string) []byte {
+	if requested == fallback || gjson.GetBytes(rawJSON, "model").String() != requested {
+		return rawJSON
+	}
+	if out, err := sjson.SetBytes(rawJSON, "model", fallback); err == nil {
+		return out
+	}
+	return rawJSON
+}
+
+// annotateServedModel records on the response which model of the chain served it.
+func annotateServedModel(ctx context.Context, chain []string, served string) {
+	if len(chain) < 2 {
+		return
+	}
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil {
+		return
+	}
+	ginCtx.Header(servedModelHeader, served)
+	if served != chain[0] {
+		ginCtx.Header(fallbackFromHeader, chain[0])
+	}
+}
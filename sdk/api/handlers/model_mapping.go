@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// rewriteMappedModel replaces the model named in a JSON request body with its mapping, so
+// executors that forward the body unchanged request the mapped model upstream too.
+func (h *BaseAPIHandler) rewriteMappedModel(modelName string, rawJSON []byte) []byte {
+	if h.Cfg == nil || len(h.Cfg.ModelMappings) == 0 || gjson.GetBytes(rawJSON, "model").String() != modelName {
+		return rawJSON
+	}
+	mapped, _ := mapModel(h.Cfg.ModelMappings, modelName)
+	if mapped == modelName {
+		return rawJSON
+	}
+	if out, err := sjson.SetBytes(rawJSON, "model", mapped); err == nil {
+		return out
+	}
+	return rawJSON
+}
+
+// WithMappedModels appends an entry for every exact model mapping whose target is listed in
+// models, so clients see mapped names alongside the models that serve them. The entry copies
+// the metadata of its target and names it in "alias_of".
+func (h *BaseAPIHandler) WithMappedModels(models []map[string]any) []map[string]any {
+	if h.Cfg == nil || len(h.Cfg.ModelMappings) == 0 {
+		return models
+	}
+	byID := make(map[string]map[string]any, len(models))
+	for _, model := range models {
+		if id, ok := model["id"].(string); ok {
+			byID[id] = model
+		}
+	}
+	for _, mapping := range h.Cfg.ModelMappings {
+		from, to := strings.TrimSpace(mapping.From), strings.TrimSpace(mapping.To)
+		if from == "" || strings.Contains(from, "*") || byID[from] != nil {
+			continue
+		}
+		target := byID[to]
+		if target == nil {
+			continue
+		}
+		alias := make(map[string]any, len(target)+1)
+		for k, v := range target {
+			alias[k] = v
+		}
+		alias["id"] = from
+		alias["alias_of"] = to
+		if _, ok := alias["name"]; ok {
+			alias["name"] = from
+		}
+		byID[from] = alias
+		models = append(models, alias)
+	}
+	return models
+}
+
+// mapModel applies the configured model mappings to a client-facing model name. It returns
+// the model to route and, when the mapping pins one, the provider to route it to.
+func mapModel(mappings []config.ModelMapping, modelName string) (model, provider string) {
+	var wildcard *config.ModelMapping
+	var captures []string
+	for i := range mappings {
+		mapping := &mappings[i]
+		from := strings.TrimSpace(mapping.From)
+		if from == "" || strings.TrimSpace(mapping.To) == "" {
+			continue
+		}
+		if !strings.Contains(from, "*") {
+			if from == modelName {
+				return strings.TrimSpace(mapping.To), strings.TrimSpace(mapping.Provider)
+			}
+			continue
+		}
+		if wildcard == nil {
+			if matched, ok := matchWildcard(from, modelName); ok {
+				wildcard = mapping
+				captures = matched
+			}
+		}
+	}
+	if wildcard == nil {
+		return modelName, ""
+	}
+	var out strings.Builder
+	parts := strings.Split(strings.TrimSpace(wildcard.To), "*")
+	for i, part := range parts {
+		out.WriteString(part)
+		if i < len(parts)-1 && i < len(captures) {
+			out.WriteString(captures[i])
+		}
+	}
+	return out.String(), strings.TrimSpace(wildcard.Provider)
+}
+
+// matchWildcard matches value against pattern, where "*" matches any run of characters,
+// and returns the text matched by each "*".
+func matchWildcard(pattern, value string) ([]string, bool) {
+	star := strings.IndexByte(pattern, '*')
+	if star < 0 {
+		return nil, pattern == value
+	}
+	prefix := pattern[:star]
+	if !strings.HasPrefix(value, prefix) {
+		return nil, false
+	}
+	rest := pattern[star+1:]
+	// Prefer the shortest capture so later literals anchor as early as possible.
+	for end := len(prefix); end <= len(value); end++ {
+		if captures, ok := matchWildcard(rest, value[end:]); ok {
+			return append([]string{value[len(prefix):end]}, captures...), true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+// assignSplit applies the traffic split of a requested model, if any. It returns the model
+// the request is assigned to and ctx marked with the assignment, so the usage of the request
+// records it.
+func (h *BaseAPIHandler) assignSplit(ctx context.Context, modelName string, rawJSON []byte) (string, context.Context) {
+	if h.Cfg == nil || len(h.Cfg.ModelSplits) == 0 {
+		return modelName, ctx
+	}
+	for i := range h.Cfg.ModelSplits {
+		split := &h.Cfg.ModelSplits[i]
+		if strings.TrimSpace(split.Model) != modelName || len(split.Targets) == 0 {
+			continue
+		}
+		name := strings.TrimSpace(split.Name)
+		if name == "" {
+			name = modelName
+		}
+		assigned := pickSplitTarget(split, splitDraw(ctx, name, split.Sticky, rawJSON), modelName)
+		if assigned != modelName {
+			log.Debugf("traffic split %s: %s -> %s", name, modelName, assigned)
+		}
+		return assigned, coreexecutor.WithSplit(ctx, name, assigned)
+	}
+	return modelName, ctx
+}
+
+// pickSplitTarget returns the target whose share of [0, 100) contains draw, or the
+// requested model when draw falls past every target.
+func pickSplitTarget(split *config.ModelSplit, draw float64, modelName string) string {
+	upper := 0.0
+	for _, target := range split.Targets {
+		model := strings.TrimSpace(target.Model)
+		if model == "" || target.Percent <= 0 {
+			continue
+		}
+		upper += target.Percent
+		if draw < upper {
+			return model
+		}
+	}
+	return modelName
+}
+
+// splitDraw returns a number in [0, 100) that decides the variant of a request: derived from
+// the conversation or API key of the request when the split is sticky, random otherwise.
+func splitDraw(ctx context.Context, name string, sticky bool, rawJSON []byte) float64 {
+	if !sticky {
+		return rand.Float64() * 100
+	}
+	key := requestSessionID(ctx, rawJSON)
+	if key == "" {
+		if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+			if value, exists := ginCtx.Get("apiKey"); exists {
+				key = fmt.Sprint(value)
+			}
+		}
+	}
+	if key == "" {
+		return rand.Float64() * 100
+	}
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(name + "\x00" + key))
+	return float64(hash.Sum64()%10000) / 100
+}
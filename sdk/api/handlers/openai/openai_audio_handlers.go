@@ -0,0 +1,209 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+)
+
+// multipartFieldLimit bounds how much of a text form field is read when looking up the model.
+const multipartFieldLimit = 4096
+
+// AudioTranscriptions handles the /v1/audio/transcriptions endpoint.
+// The multipart upload is forwarded unchanged to a provider serving the requested model.
+// An upload spooled by the request body limits is sent from its spool instead of being
+// read into memory.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIAPIHandler) AudioTranscriptions(c *gin.Context) {
+	contentType := c.GetHeader("Content-Type")
+	spooled, isSpooled := c.Request.Body.(replayableBody)
+	var body []byte
+	var fields map[string]string
+	var err error
+	if isSpooled {
+		fields, err = spooledTextFields(spooled, contentType, "model", "response_format")
+	} else if body, err = c.GetRawData(); err == nil {
+		fields, err = multipartTextFields(bytes.NewReader(body), contentType, "model", "response_format")
+	}
+	// If data retrieval fails, return a 400 Bad Request error.
+	if err != nil {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: fmt.Sprintf("Invalid request: %v", err),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	var resp []byte
+	var errMsg *interfaces.ErrorMessage
+	if isSpooled {
+		resp, errMsg = h.ExecuteTranscriptionBodyWithAuthManager(cliCtx, h.HandlerType(), fields["model"], spooled.Open, contentType)
+	} else {
+		resp, errMsg = h.ExecuteTranscriptionWithAuthManager(cliCtx, h.HandlerType(), fields["model"], body, contentType)
+	}
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(errMsg.Error)
+		return
+	}
+	c.Data(http.StatusOK, transcriptionContentType(fields["response_format"]), resp)
+	cliCancel()
+}
+
+// AudioSpeech handles the /v1/audio/speech endpoint.
+// The synthesized audio is streamed to the client as the provider produces it.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIAPIHandler) AudioSpeech(c *gin.Context) {
+	rawJSON, err := c.GetRawData()
+	// If data retrieval fails, return a 400 Bad Request error.
+	if err != nil {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: fmt.Sprintf("Invalid request: %v", err),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: "Streaming not supported",
+				Type:    "server_error",
+			},
+		})
+		return
+	}
+
+	modelName := gjson.GetBytes(rawJSON, "model").String()
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	contentType, dataChan, errChan := h.ExecuteSpeechWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON)
+
+	started := false
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			cliCancel(c.Request.Context().Err())
+			return
+		case chunk, isOk := <-dataChan:
+			if !isOk {
+				// Drain a terminal error reported after the last chunk.
+				if errChan != nil {
+					if errMsg, hasErr := <-errChan; hasErr && errMsg != nil {
+						if !started {
+							h.WriteErrorResponse(c, errMsg)
+						}
+						cliCancel(errMsg.Error)
+						return
+					}
+				}
+				if !started {
+					c.Header("Content-Type", contentType)
+					c.Status(http.StatusOK)
+				}
+				cliCancel()
+				return
+			}
+			if !started {
+				started = true
+				c.Header("Content-Type", contentType)
+				c.Status(http.StatusOK)
+			}
+			_, _ = c.Writer.Write(chunk)
+			flusher.Flush()
+		case errMsg, isOk := <-errChan:
+			if !isOk {
+				errChan = nil
+				continue
+			}
+			// Once audio has been sent the status is committed; the truncated body signals the failure.
+			if errMsg != nil && !started {
+				h.WriteErrorResponse(c, errMsg)
+			}
+			var execErr error
+			if errMsg != nil {
+				execErr = errMsg.Error
+			}
+			cliCancel(execErr)
+			return
+		}
+	}
+}
+
+// replayableBody is a request body that can be read again from the start, such as an
+// upload spooled by the request body limits.
+type replayableBody interface {
+	Open() (io.ReadCloser, error)
+}
+
+// spooledTextFields returns the values of the named text fields of a spooled multipart body.
+func spooledTextFields(body replayableBody, contentType string, names ...string) (map[string]string, error) {
+	reader, err := body.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = reader.Close() }()
+	return multipartTextFields(reader, contentType, names...)
+}
+
+// multipartTextFields returns the values of the named text fields of a multipart/form-data body.
+// File parts are skipped without being buffered.
+func multipartTextFields(body io.Reader, contentType string, names ...string) (map[string]string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "multipart/form-data" || params["boundary"] == "" {
+		return nil, errors.New("request must be multipart/form-data")
+	}
+	wanted := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		wanted[name] = struct{}{}
+	}
+	fields := make(map[string]string, len(names))
+	reader := multipart.NewReader(body, params["boundary"])
+	for {
+		part, errPart := reader.NextPart()
+		if errors.Is(errPart, io.EOF) {
+			return fields, nil
+		}
+		if errPart != nil {
+			return nil, errPart
+		}
+		if _, ok := wanted[part.FormName()]; !ok || part.FileName() != "" {
+			continue
+		}
+		value, errRead := io.ReadAll(io.LimitReader(part, multipartFieldLimit))
+		if errRead != nil {
+			return nil, errRead
+		}
+		fields[part.FormName()] = strings.TrimSpace(string(value))
+	}
+}
+
+// transcriptionContentType returns the media type of a transcription for its response_format.
+func transcriptionContentType(responseFormat string) string {
+	switch responseFormat {
+	case "", "json", "verbose_json", "diarized_json":
+		return "application/json"
+	default:
+		return "text/plain; charset=utf-8"
+	}
+}
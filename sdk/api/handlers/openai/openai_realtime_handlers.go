@@ -0,0 +1,1099 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	// realtimeTranscriptionModel transcribes committed input audio when the session does not
+	// name an input_audio_transcription model.
+	realtimeTranscriptionModel = "whisper-1"
+
+	// realtimeSpeechModel synthesizes the audio of responses with the audio modality.
+	realtimeSpeechModel = "tts-1"
+
+	// realtimeSampleRate is the sample rate of the pcm16 audio format of the Realtime API.
+	realtimeSampleRate = 24000
+
+	// realtimeReadLimit bounds a single client event, which may carry a chunk of audio.
+	realtimeReadLimit = 16 << 20
+
+	// realtimeAudioLimit bounds the uncommitted input audio buffer (about five minutes).
+	realtimeAudioLimit = 15 << 20
+
+	// realtimeErrorBodyLimit bounds the error body kept from a failed dispatched request.
+	realtimeErrorBodyLimit = 64 << 10
+)
+
+// realtimeDispatchSkippedHeaders are the headers of the upgrade request that are not copied
+// to the requests dispatched for its turns. The session header is dropped because the
+// realtime session already sends the whole conversation.
+var realtimeDispatchSkippedHeaders = []string{"Upgrade", "Connection", "Sec-WebSocket-Key", "Sec-WebSocket-Version", "Sec-WebSocket-Extensions", "Accept-Encoding", "Content-Length", "X-CLIProxy-Session"}
+
+var realtimeUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+	Subprotocols:    []string{"realtime"},
+	// Realtime checks the origin against the configured allow-list before upgrading.
+	CheckOrigin: func(r *http.Request) bool {
+		return true
+	},
+}
+
+// realtimeOriginAllowed reports whether the page that opened the connection may use it.
+// Connections without an Origin header come from non-browser clients.
+func realtimeOriginAllowed(r *http.Request, allowed []string) bool {
+	origin := strings.TrimSpace(r.Header.Get("Origin"))
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, candidate := range allowed {
+		candidate = strings.TrimRight(strings.TrimSpace(candidate), "/")
+		if candidate == "*" || strings.EqualFold(candidate, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Realtime handles the /v1/realtime WebSocket endpoint of the OpenAI Realtime API.
+// None of the upstream providers offer a realtime session, so conversations are run turn
+// by turn: each response.create streams a chat completion of the conversation, committed
+// input audio is transcribed and audio output is synthesized from the response text.
+// Server-side voice activity detection is not available; clients commit the input audio
+// buffer themselves and the session reports turn_detection as null. Every turn is
+// dispatched as its own request through the server's middleware, so rate limits, quotas,
+// guardrails and the other request checks apply to each turn rather than only the upgrade.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIAPIHandler) Realtime(c *gin.Context) {
+	model := strings.TrimSpace(c.Query("model"))
+	if model == "" {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: "Missing required parameter: 'model'",
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+	var allowedOrigins []string
+	if h.Cfg != nil {
+		allowedOrigins = h.Cfg.Realtime.AllowedOrigins
+	}
+	if !realtimeOriginAllowed(c.Request, allowedOrigins) {
+		c.JSON(http.StatusForbidden, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: "Origin not allowed",
+				Type:    "invalid_request_error",
+				Code:    "origin_not_allowed",
+			},
+		})
+		return
+	}
+	conn, err := realtimeUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already written the error response.
+		log.Debugf("realtime: upgrade failed: %v", err)
+		return
+	}
+	conn.SetReadLimit(realtimeReadLimit)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &realtimeSession{h: h, c: c, conn: conn, ctx: ctx}
+	s.session = newRealtimeSessionConfig(model)
+	defer func() {
+		cancel()
+		_ = conn.Close()
+	}()
+
+	s.send(s.withSession(`{"type":"session.created"}`))
+	for {
+		_, message, errRead := conn.ReadMessage()
+		if errRead != nil {
+			if !websocket.IsCloseError(errRead, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Debugf("realtime: connection closed: %v", errRead)
+			}
+			s.cancelResponse()
+			return
+		}
+		s.handleEvent(message)
+	}
+}
+
+// realtimeSession is the state of one Realtime API connection.
+type realtimeSession struct {
+	h    *OpenAIAPIHandler
+	c    *gin.Context
+	conn *websocket.Conn
+	ctx  context.Context
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	session string
+	items   []string
+	audio   []byte
+	cancel  context.CancelFunc
+}
+
+// newRealtimeSessionConfig returns the default session object for model.
+func newRealtimeSessionConfig(model string) string {
+	session := `{"object":"realtime.session","modalities":["text"],"instructions":"","voice":"alloy","input_audio_format":"pcm16","output_audio_format":"pcm16","input_audio_transcription":null,"turn_detection":null,"tools":[],"tool_choice":"auto","temperature":0.8,"max_response_output_tokens":"inf"}`
+	session, _ = sjson.Set(session, "id", realtimeID("sess_"))
+	session, _ = sjson.Set(session, "model", model)
+	return session
+}
+
+// realtimeSessionFields are the session settings a client may change with session.update.
+var realtimeSessionFields = []string{"model", "modalities", "instructions", "voice", "input_audio_transcription", "tools", "tool_choice", "temperature", "max_response_output_tokens"}
+
+func realtimeID(prefix string) string {
+	return prefix + strings.ReplaceAll(uuid.NewString(), "-", "")[:24]
+}
+
+// send writes a server event, assigning its event_id.
+func (s *realtimeSession) send(event string) {
+	event, _ = sjson.Set(event, "event_id", realtimeID("event_"))
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if err := s.conn.WriteMessage(websocket.TextMessage, []byte(event)); err != nil {
+		log.Debugf("realtime: write failed: %v", err)
+	}
+}
+
+// sendError reports a client error as an error event.
+func (s *realtimeSession) sendError(clientEventID, code, message string) {
+	event := `{"type":"error","error":{"type":"invalid_request_error"}}`
+	event, _ = sjson.Set(event, "error.code", code)
+	event, _ = sjson.Set(event, "error.message", message)
+	if clientEventID != "" {
+		event, _ = sjson.Set(event, "error.event_id", clientEventID)
+	}
+	s.send(event)
+}
+
+func (s *realtimeSession) withSession(event string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	event, _ = sjson.SetRaw(event, "session", s.session)
+	return event
+}
+
+// handleEvent dispatches one client event.
+func (s *realtimeSession) handleEvent(message []byte) {
+	if !gjson.ValidBytes(message) {
+		s.sendError("", "invalid_json", "The event is not valid JSON.")
+		return
+	}
+	event := gjson.ParseBytes(message)
+	eventID := event.Get("event_id").String()
+	switch eventType := event.Get("type").String(); eventType {
+	case "session.update":
+		s.updateSession(eventID, event.Get("session"))
+	case "conversation.item.create":
+		s.createItem(eventID, event.Get("previous_item_id").String(), event.Get("item"))
+	case "conversation.item.delete":
+		s.deleteItem(eventID, event.Get("item_id").String())
+	case "conversation.item.retrieve":
+		s.retrieveItem(eventID, event.Get("item_id").String())
+	case "conversation.item.truncate":
+		// Only the transcript is kept; there is no played-back audio to truncate.
+		out := `{"type":"conversation.item.truncated"}`
+		out, _ = sjson.Set(out, "item_id", event.Get("item_id").String())
+		out, _ = sjson.Set(out, "content_index", event.Get("content_index").Int())
+		out, _ = sjson.Set(out, "audio_end_ms", event.Get("audio_end_ms").Int())
+		s.send(out)
+	case "input_audio_buffer.append":
+		s.appendAudio(eventID, event.Get("audio").String())
+	case "input_audio_buffer.clear":
+		s.mu.Lock()
+		s.audio = nil
+		s.mu.Unlock()
+		s.send(`{"type":"input_audio_buffer.cleared"}`)
+	case "input_audio_buffer.commit":
+		s.commitAudio(eventID)
+	case "response.create":
+		s.createResponse(eventID, event.Get("response"))
+	case "response.cancel":
+		if !s.cancelResponse() {
+			s.sendError(eventID, "response_cancel_not_active", "There is no active response to cancel.")
+		}
+	default:
+		s.sendError(eventID, "invalid_event_type", fmt.Sprintf("Unsupported event type %q.", eventType))
+	}
+}
+
+func (s *realtimeSession) updateSession(eventID string, update gjson.Result) {
+	if !update.IsObject() {
+		s.sendError(eventID, "invalid_session", "session.update requires a session object.")
+		return
+	}
+	if format := update.Get("input_audio_format"); format.Exists() && format.String() != "pcm16" {
+		s.sendError(eventID, "unsupported_audio_format", "Only the pcm16 input audio format is supported.")
+		return
+	}
+	s.mu.Lock()
+	for _, field := range realtimeSessionFields {
+		if value := update.Get(field); value.Exists() {
+			s.session, _ = sjson.SetRaw(s.session, field, value.Raw)
+		}
+	}
+	// The GA event shape names the modalities output_modalities.
+	if value := update.Get("output_modalities"); value.IsArray() {
+		s.session, _ = sjson.SetRaw(s.session, "modalities", value.Raw)
+	}
+	s.mu.Unlock()
+	s.send(s.withSession(`{"type":"session.updated"}`))
+}
+
+// normalizeItem fills in the fields the server adds to a client-created item.
+func normalizeItem(item gjson.Result) (string, error) {
+	if !item.IsObject() {
+		return "", fmt.Errorf("an item object is required")
+	}
+	out := item.Raw
+	switch item.Get("type").String() {
+	case "message":
+		switch item.Get("role").String() {
+		case "user", "assistant", "system":
+		default:
+			return "", fmt.Errorf("message items need a user, assistant or system role")
+		}
+	case "function_call":
+		if item.Get("call_id").String() == "" || item.Get("name").String() == "" {
+			return "", fmt.Errorf("function_call items need a call_id and name")
+		}
+	case "function_call_output":
+		if item.Get("call_id").String() == "" {
+			return "", fmt.Errorf("function_call_output items need a call_id")
+		}
+	default:
+		return "", fmt.Errorf("unsupported item type %q", item.Get("type").String())
+	}
+	if item.Get("id").String() == "" {
+		out, _ = sjson.Set(out, "id", realtimeID("item_"))
+	}
+	out, _ = sjson.Set(out, "object", "realtime.item")
+	out, _ = sjson.Set(out, "status", "completed")
+	return out, nil
+}
+
+func (s *realtimeSession) createItem(eventID, previousID string, item gjson.Result) {
+	normalized, err := normalizeItem(item)
+	if err != nil {
+		s.sendError(eventID, "invalid_item", err.Error())
+		return
+	}
+	s.mu.Lock()
+	previousID, ok := s.insertItemLocked(previousID, normalized)
+	s.mu.Unlock()
+	if !ok {
+		s.sendError(eventID, "item_not_found", fmt.Sprintf("Item %q does not exist.", previousID))
+		return
+	}
+	s.sendItemCreated(previousID, normalized)
+}
+
+// insertItemLocked inserts item after previousID, or appends it when previousID is empty,
+// and returns the ID of the item it follows. Callers must hold s.mu.
+func (s *realtimeSession) insertItemLocked(previousID, item string) (string, bool) {
+	if previousID == "" {
+		if len(s.items) > 0 {
+			previousID = gjson.Get(s.items[len(s.items)-1], "id").String()
+		}
+		s.items = append(s.items, item)
+		return previousID, true
+	}
+	if previousID == "root" {
+		s.items = append([]string{item}, s.items...)
+		return "", true
+	}
+	for i := range s.items {
+		if gjson.Get(s.items[i], "id").String() == previousID {
+			s.items = append(s.items[:i+1], append([]string{item}, s.items[i+1:]...)...)
+			return previousID, true
+		}
+	}
+	return previousID, false
+}
+
+func (s *realtimeSession) sendItemCreated(previousID, item string) {
+	out := `{"type":"conversation.item.created","previous_item_id":null}`
+	if previousID != "" {
+		out, _ = sjson.Set(out, "previous_item_id", previousID)
+	}
+	out, _ = sjson.SetRaw(out, "item", item)
+	s.send(out)
+}
+
+func (s *realtimeSession) deleteItem(eventID, itemID string) {
+	s.mu.Lock()
+	deleted := false
+	for i := range s.items {
+		if gjson.Get(s.items[i], "id").String() == itemID {
+			s.items = append(s.items[:i], s.items[i+1:]...)
+			deleted = true
+			break
+		}
+	}
+	s.mu.Unlock()
+	if !deleted {
+		s.sendError(eventID, "item_not_found", fmt.Sprintf("Item %q does not exist.", itemID))
+		return
+	}
+	out, _ := sjson.Set(`{"type":"conversation.item.deleted"}`, "item_id", itemID)
+	s.send(out)
+}
+
+func (s *realtimeSession) retrieveItem(eventID, itemID string) {
+	s.mu.Lock()
+	var found string
+	for _, item := range s.items {
+		if gjson.Get(item, "id").String() == itemID {
+			found = item
+			break
+		}
+	}
+	s.mu.Unlock()
+	if found == "" {
+		s.sendError(eventID, "item_not_found", fmt.Sprintf("Item %q does not exist.", itemID))
+		return
+	}
+	out, _ := sjson.SetRaw(`{"type":"conversation.item.retrieved"}`, "item", found)
+	s.send(out)
+}
+
+func (s *realtimeSession) appendAudio(eventID, audio string) {
+	chunk, err := base64.StdEncoding.DecodeString(audio)
+	if err != nil {
+		s.sendError(eventID, "invalid_audio", "The audio is not valid base64.")
+		return
+	}
+	s.mu.Lock()
+	full := len(s.audio)+len(chunk) > realtimeAudioLimit
+	if !full {
+		s.audio = append(s.audio, chunk...)
+	}
+	s.mu.Unlock()
+	if full {
+		s.sendError(eventID, "input_audio_buffer_full", "The input audio buffer is full; commit or clear it first.")
+	}
+}
+
+// commitAudio turns the input audio buffer into a user message and transcribes it, since
+// the transcript is what the chat completion sees.
+func (s *realtimeSession) commitAudio(eventID string) {
+	s.mu.Lock()
+	audio := s.audio
+	s.audio = nil
+	transcriptionModel := gjson.Get(s.session, "input_audio_transcription.model").String()
+	language := gjson.Get(s.session, "input_audio_transcription.language").String()
+	s.mu.Unlock()
+	if len(audio) == 0 {
+		s.sendError(eventID, "input_audio_buffer_commit_empty", "The input audio buffer is empty.")
+		return
+	}
+	if transcriptionModel == "" {
+		transcriptionModel = realtimeTranscriptionModel
+	}
+
+	itemID := realtimeID("item_")
+	item := `{"object":"realtime.item","type":"message","status":"completed","role":"user","content":[{"type":"input_audio","transcript":null}]}`
+	item, _ = sjson.Set(item, "id", itemID)
+	s.mu.Lock()
+	previousID, _ := s.insertItemLocked("", item)
+	s.mu.Unlock()
+	committed := `{"type":"input_audio_buffer.committed","previous_item_id":null}`
+	if previousID != "" {
+		committed, _ = sjson.Set(committed, "previous_item_id", previousID)
+	}
+	committed, _ = sjson.Set(committed, "item_id", itemID)
+	s.send(committed)
+	s.sendItemCreated(previousID, item)
+
+	transcript, errMsg := s.transcribe(audio, transcriptionModel, language)
+	if errMsg != nil {
+		out := `{"type":"conversation.item.input_audio_transcription.failed","content_index":0,"error":{"type":"transcription_error"}}`
+		out, _ = sjson.Set(out, "item_id", itemID)
+		out, _ = sjson.Set(out, "error.message", errMsg.Error.Error())
+		s.send(out)
+		return
+	}
+	s.mu.Lock()
+	for i := range s.items {
+		if gjson.Get(s.items[i], "id").String() == itemID {
+			s.items[i], _ = sjson.Set(s.items[i], "content.0.transcript", transcript)
+		}
+	}
+	s.mu.Unlock()
+	out := `{"type":"conversation.item.input_audio_transcription.completed","content_index":0}`
+	out, _ = sjson.Set(out, "item_id", itemID)
+	out, _ = sjson.Set(out, "transcript", transcript)
+	s.send(out)
+}
+
+// transcribe sends pcm16 audio to the transcription endpoint of model as a WAV upload.
+func (s *realtimeSession) transcribe(pcm []byte, model, language string) (string, *interfaces.ErrorMessage) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	_ = writer.WriteField("model", model)
+	_ = writer.WriteField("response_format", "json")
+	if language != "" {
+		_ = writer.WriteField("language", language)
+	}
+	part, _ := writer.CreateFormFile("file", "audio.wav")
+	_, _ = part.Write(wavFile(pcm))
+	_ = writer.Close()
+
+	if s.h.Dispatch != nil {
+		var resp bytes.Buffer
+		status, failure := s.dispatch(s.ctx, "/v1/audio/transcriptions", writer.FormDataContentType(), body.Bytes(), func(p []byte) { resp.Write(p) })
+		if status != http.StatusOK {
+			return "", &interfaces.ErrorMessage{StatusCode: status, Error: errors.New(realtimeErrorMessage(status, failure))}
+		}
+		return strings.TrimSpace(gjson.GetBytes(resp.Bytes(), "text").String()), nil
+	}
+	ctx, cancel := s.h.GetContextWithCancel(s.h, s.c, s.ctx)
+	resp, errMsg := s.h.ExecuteTranscriptionWithAuthManager(ctx, s.h.HandlerType(), model, body.Bytes(), writer.FormDataContentType())
+	if errMsg != nil {
+		cancel(errMsg.Error)
+		return "", errMsg
+	}
+	cancel()
+	return strings.TrimSpace(gjson.GetBytes(resp, "text").String()), nil
+}
+
+// dispatch serves a request of the session through the server's handler as the client that
+// opened the connection, so it passes the same access checks and middleware as the client's
+// own requests. write receives the body of a successful response as it is written; the
+// status is returned with the body of a failed response.
+func (s *realtimeSession) dispatch(ctx context.Context, path, contentType string, body []byte, write func([]byte)) (int, []byte) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, path, bytes.NewReader(body))
+	if err != nil {
+		return http.StatusInternalServerError, []byte(err.Error())
+	}
+	req.Header = s.c.Request.Header.Clone()
+	for _, name := range realtimeDispatchSkippedHeaders {
+		req.Header.Del(name)
+	}
+	req.Header.Set("Content-Type", contentType)
+	// The query carries the API key of clients that authenticate with ?key=.
+	req.URL.RawQuery = s.c.Request.URL.RawQuery
+	req.Host = s.c.Request.Host
+	req.RemoteAddr = s.c.Request.RemoteAddr
+	w := &realtimeDispatchWriter{header: make(http.Header), write: write}
+	s.h.Dispatch.ServeHTTP(w, req)
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.status, w.failure.Bytes()
+}
+
+// realtimeDispatchWriter receives the response of a request dispatched for a session.
+type realtimeDispatchWriter struct {
+	header  http.Header
+	status  int
+	write   func([]byte)
+	failure bytes.Buffer
+}
+
+func (w *realtimeDispatchWriter) Header() http.Header { return w.header }
+
+func (w *realtimeDispatchWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *realtimeDispatchWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.status == http.StatusOK {
+		w.write(p)
+	} else if w.failure.Len() < realtimeErrorBodyLimit {
+		w.failure.Write(p)
+	}
+	return len(p), nil
+}
+
+// Flush implements http.Flusher; writes are delivered as they happen.
+func (w *realtimeDispatchWriter) Flush() {}
+
+// realtimeErrorMessage returns the message of an error response body.
+func realtimeErrorMessage(status int, body []byte) string {
+	if message := gjson.GetBytes(body, "error.message").String(); message != "" {
+		return message
+	}
+	if message := gjson.GetBytes(body, "error").String(); message != "" && !gjson.GetBytes(body, "error").IsObject() {
+		return message
+	}
+	if text := http.StatusText(status); text != "" {
+		return text
+	}
+	return "upstream request failed"
+}
+
+// realtimeEventStream splits a dispatched chat completion stream into its data events.
+type realtimeEventStream struct {
+	pending []byte
+	handle  func([]byte)
+	done    bool
+	err     string
+}
+
+func (e *realtimeEventStream) write(p []byte) {
+	e.pending = append(e.pending, p...)
+	for {
+		i := bytes.IndexByte(e.pending, '\n')
+		if i < 0 {
+			return
+		}
+		line := bytes.TrimSpace(e.pending[:i])
+		e.pending = e.pending[i+1:]
+		data, ok := bytes.CutPrefix(line, []byte("data:"))
+		if !ok {
+			continue
+		}
+		data = bytes.TrimSpace(data)
+		switch {
+		case bytes.Equal(data, []byte("[DONE]")):
+			e.done = true
+		case gjson.GetBytes(data, "error").Exists():
+			e.err = realtimeErrorMessage(http.StatusBadGateway, data)
+		default:
+			e.handle(data)
+		}
+	}
+}
+
+// wavFile wraps mono pcm16 samples in a WAV container.
+func wavFile(pcm []byte) []byte {
+	out := make([]byte, 44, 44+len(pcm))
+	copy(out[0:], "RIFF")
+	binary.LittleEndian.PutUint32(out[4:], uint32(36+len(pcm)))
+	copy(out[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(out[16:], 16)
+	binary.LittleEndian.PutUint16(out[20:], 1)
+	binary.LittleEndian.PutUint16(out[22:], 1)
+	binary.LittleEndian.PutUint32(out[24:], realtimeSampleRate)
+	binary.LittleEndian.PutUint32(out[28:], realtimeSampleRate*2)
+	binary.LittleEndian.PutUint16(out[32:], 2)
+	binary.LittleEndian.PutUint16(out[34:], 16)
+	copy(out[36:], "data")
+	binary.LittleEndian.PutUint32(out[40:], uint32(len(pcm)))
+	return append(out, pcm...)
+}
+
+// cancelResponse cancels the active response and reports whether there was one.
+func (s *realtimeSession) cancelResponse() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel == nil {
+		return false
+	}
+	s.cancel()
+	return true
+}
+
+func (s *realtimeSession) createResponse(eventID string, overrides gjson.Result) {
+	s.mu.Lock()
+	if s.cancel != nil {
+		s.mu.Unlock()
+		s.sendError(eventID, "conversation_already_has_active_response", "Conversation already has an active response.")
+		return
+	}
+	settings := s.session
+	for _, field := range []string{"modalities", "instructions", "voice", "tools", "tool_choice", "temperature", "max_response_output_tokens"} {
+		if value := overrides.Get(field); value.Exists() {
+			settings, _ = sjson.SetRaw(settings, field, value.Raw)
+		}
+	}
+	if value := overrides.Get("output_modalities"); value.IsArray() {
+		settings, _ = sjson.SetRaw(settings, "modalities", value.Raw)
+	}
+	// Out-of-band responses run on their own input and leave the conversation untouched.
+	outOfBand := overrides.Get("conversation").String() == "none"
+	var items []string
+	if outOfBand {
+		for _, item := range overrides.Get("input").Array() {
+			if normalized, err := normalizeItem(item); err == nil {
+				items = append(items, normalized)
+			}
+		}
+	} else {
+		items = append(items, s.items...)
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.cancel = cancel
+	s.mu.Unlock()
+
+	r := &realtimeResponse{
+		session:   s,
+		id:        realtimeID("resp_"),
+		settings:  settings,
+		outOfBand: outOfBand,
+		audio:     realtimeHasModality(settings, "audio"),
+		metadata:  overrides.Get("metadata"),
+		tools:     make(map[int]*realtimeOutput),
+	}
+	go func() {
+		defer func() {
+			cancel()
+			s.mu.Lock()
+			s.cancel = nil
+			s.mu.Unlock()
+		}()
+		r.run(ctx, buildRealtimeChatRequest(settings, items))
+	}()
+}
+
+func realtimeHasModality(settings, modality string) bool {
+	for _, m := range gjson.Get(settings, "modalities").Array() {
+		if m.String() == modality {
+			return true
+		}
+	}
+	return false
+}
+
+// buildRealtimeChatRequest converts a realtime conversation to a streamed chat completion.
+func buildRealtimeChatRequest(settings string, items []string) []byte {
+	out := `{"stream":true,"stream_options":{"include_usage":true},"messages":[]}`
+	out, _ = sjson.Set(out, "model", gjson.Get(settings, "model").String())
+	if instructions := gjson.Get(settings, "instructions").String(); instructions != "" {
+		message, _ := sjson.Set(`{"role":"system"}`, "content", instructions)
+		out, _ = sjson.SetRaw(out, "messages.-1", message)
+	}
+	lastToolCalls := false
+	for _, raw := range items {
+		item := gjson.Parse(raw)
+		switch item.Get("type").String() {
+		case "message":
+			var text strings.Builder
+			for _, part := range item.Get("content").Array() {
+				value := part.Get("text")
+				if !value.Exists() {
+					value = part.Get("transcript")
+				}
+				if value.String() == "" {
+					continue
+				}
+				if text.Len() > 0 {
+					text.WriteString("\n")
+				}
+				text.WriteString(value.String())
+			}
+			if text.Len() == 0 {
+				continue
+			}
+			message, _ := sjson.Set(`{}`, "role", item.Get("role").String())
+			message, _ = sjson.Set(message, "content", text.String())
+			out, _ = sjson.SetRaw(out, "messages.-1", message)
+			lastToolCalls = false
+		case "function_call":
+			call := `{"type":"function","function":{}}`
+			call, _ = sjson.Set(call, "id", item.Get("call_id").String())
+			call, _ = sjson.Set(call, "function.name", item.Get("name").String())
+			call, _ = sjson.Set(call, "function.arguments", item.Get("arguments").String())
+			if lastToolCalls {
+				// Parallel calls of one turn belong to a single assistant message.
+				last := gjson.Get(out, "messages.#").Int() - 1
+				out, _ = sjson.SetRaw(out, fmt.Sprintf("messages.%d.tool_calls.-1", last), call)
+				continue
+			}
+			message, _ := sjson.SetRaw(`{"role":"assistant","content":null,"tool_calls":[]}`, "tool_calls.-1", call)
+			out, _ = sjson.SetRaw(out, "messages.-1", message)
+			lastToolCalls = true
+		case "function_call_output":
+			message, _ := sjson.Set(`{"role":"tool"}`, "tool_call_id", item.Get("call_id").String())
+			message, _ = sjson.Set(message, "content", item.Get("output").String())
+			out, _ = sjson.SetRaw(out, "messages.-1", message)
+			lastToolCalls = false
+		}
+	}
+	for _, tool := range gjson.Get(settings, "tools").Array() {
+		fn := `{"type":"function","function":{}}`
+		fn, _ = sjson.Set(fn, "function.name", tool.Get("name").String())
+		if description := tool.Get("description"); description.Exists() {
+			fn, _ = sjson.Set(fn, "function.description", description.String())
+		}
+		if parameters := tool.Get("parameters"); parameters.IsObject() {
+			fn, _ = sjson.SetRaw(fn, "function.parameters", parameters.Raw)
+		}
+		out, _ = sjson.SetRaw(out, "tools.-1", fn)
+	}
+	if gjson.Get(out, "tools").IsArray() {
+		switch choice := gjson.Get(settings, "tool_choice"); {
+		case choice.Type == gjson.String:
+			out, _ = sjson.Set(out, "tool_choice", choice.String())
+		case choice.IsObject():
+			out, _ = sjson.Set(out, "tool_choice", map[string]any{"type": "function", "function": map[string]string{"name": choice.Get("name").String()}})
+		}
+	}
+	if temperature := gjson.Get(settings, "temperature"); temperature.Type == gjson.Number {
+		out, _ = sjson.Set(out, "temperature", temperature.Float())
+	}
+	if maxTokens := gjson.Get(settings, "max_response_output_tokens"); maxTokens.Type == gjson.Number {
+		out, _ = sjson.Set(out, "max_tokens", maxTokens.Int())
+	}
+	return []byte(out)
+}
+
+// realtimeOutput is an output item of a response being streamed.
+type realtimeOutput struct {
+	item  string
+	index int
+	text  strings.Builder
+}
+
+// realtimeResponse streams one response of a realtime session.
+type realtimeResponse struct {
+	session   *realtimeSession
+	id        string
+	settings  string
+	outOfBand bool
+	audio     bool
+	metadata  gjson.Result
+
+	message *realtimeOutput
+	tools   map[int]*realtimeOutput
+	outputs []*realtimeOutput
+	usage   gjson.Result
+}
+
+func (r *realtimeResponse) event(eventType string, output *realtimeOutput) string {
+	out, _ := sjson.Set(`{}`, "type", eventType)
+	out, _ = sjson.Set(out, "response_id", r.id)
+	if output != nil {
+		out, _ = sjson.Set(out, "item_id", gjson.Get(output.item, "id").String())
+		out, _ = sjson.Set(out, "output_index", output.index)
+	}
+	return out
+}
+
+func (r *realtimeResponse) object(status string) string {
+	out := `{"object":"realtime.response","output":[]}`
+	out, _ = sjson.Set(out, "id", r.id)
+	out, _ = sjson.Set(out, "status", status)
+	if r.outOfBand {
+		out, _ = sjson.Set(out, "conversation_id", nil)
+	}
+	if r.metadata.IsObject() {
+		out, _ = sjson.SetRaw(out, "metadata", r.metadata.Raw)
+	}
+	out, _ = sjson.SetRaw(out, "modalities", gjson.Get(r.settings, "modalities").Raw)
+	for _, output := range r.outputs {
+		out, _ = sjson.SetRaw(out, "output.-1", output.item)
+	}
+	return out
+}
+
+// addOutput registers a new output item and announces it.
+func (r *realtimeResponse) addOutput(item string) *realtimeOutput {
+	output := &realtimeOutput{item: item, index: len(r.outputs)}
+	r.outputs = append(r.outputs, output)
+	added := r.event("response.output_item.added", nil)
+	added, _ = sjson.Set(added, "output_index", output.index)
+	added, _ = sjson.SetRaw(added, "item", item)
+	r.session.send(added)
+	if !r.outOfBand {
+		r.session.mu.Lock()
+		previousID, _ := r.session.insertItemLocked("", item)
+		r.session.mu.Unlock()
+		r.session.sendItemCreated(previousID, item)
+	}
+	return output
+}
+
+func (r *realtimeResponse) contentPart() string {
+	if r.audio {
+		return `{"type":"audio","transcript":""}`
+	}
+	return `{"type":"text","text":""}`
+}
+
+func (r *realtimeResponse) textDelta(delta string) {
+	if r.message == nil {
+		item := `{"object":"realtime.item","type":"message","status":"in_progress","role":"assistant","content":[]}`
+		item, _ = sjson.Set(item, "id", realtimeID("item_"))
+		r.message = r.addOutput(item)
+		added := r.event("response.content_part.added", r.message)
+		added, _ = sjson.Set(added, "content_index", 0)
+		added, _ = sjson.SetRaw(added, "part", r.contentPart())
+		r.session.send(added)
+	}
+	r.message.text.WriteString(delta)
+	eventType := "response.text.delta"
+	if r.audio {
+		eventType = "response.audio_transcript.delta"
+	}
+	out := r.event(eventType, r.message)
+	out, _ = sjson.Set(out, "content_index", 0)
+	out, _ = sjson.Set(out, "delta", delta)
+	r.session.send(out)
+}
+
+func (r *realtimeResponse) toolCallDelta(call gjson.Result) {
+	index := int(call.Get("index").Int())
+	output, ok := r.tools[index]
+	if !ok {
+		callID := call.Get("id").String()
+		if callID == "" {
+			callID = realtimeID("call_")
+		}
+		item := `{"object":"realtime.item","type":"function_call","status":"in_progress","arguments":""}`
+		item, _ = sjson.Set(item, "id", realtimeID("item_"))
+		item, _ = sjson.Set(item, "call_id", callID)
+		item, _ = sjson.Set(item, "name", call.Get("function.name").String())
+		output = r.addOutput(item)
+		r.tools[index] = output
+	}
+	delta := call.Get("function.arguments").String()
+	if delta == "" {
+		return
+	}
+	output.text.WriteString(delta)
+	out := r.event("response.function_call_arguments.delta", output)
+	out, _ = sjson.Set(out, "call_id", gjson.Get(output.item, "call_id").String())
+	out, _ = sjson.Set(out, "delta", delta)
+	r.session.send(out)
+}
+
+// run streams the chat completion and converts it to realtime events.
+func (r *realtimeResponse) run(ctx context.Context, request []byte) {
+	s := r.session
+	s.send(r.eventWithResponse("response.created", "in_progress"))
+
+	if s.h.Dispatch != nil {
+		stream := &realtimeEventStream{handle: r.handleChunk}
+		status, failure := s.dispatch(ctx, "/v1/chat/completions", "application/json", request, stream.write)
+		message := stream.err
+		switch {
+		case ctx.Err() != nil:
+			r.finish("cancelled", `{"type":"cancelled","reason":"client_cancelled"}`)
+			return
+		case status != http.StatusOK:
+			message = realtimeErrorMessage(status, failure)
+		case message == "" && !stream.done:
+			message = "the response stream ended unexpectedly"
+		}
+		if message != "" {
+			details, _ := sjson.Set(`{"type":"failed","error":{"type":"server_error"}}`, "error.message", message)
+			r.finish("failed", details)
+			return
+		}
+		r.finish("completed", "")
+		return
+	}
+
+	model := gjson.GetBytes(request, "model").String()
+	cliCtx, cliCancel := s.h.GetContextWithCancel(s.h, s.c, ctx)
+	dataChan, errChan := s.h.ExecuteStreamWithAuthManager(cliCtx, s.h.HandlerType(), model, request, "")
+	for {
+		select {
+		case <-ctx.Done():
+			cliCancel(ctx.Err())
+			r.finish("cancelled", `{"type":"cancelled","reason":"client_cancelled"}`)
+			return
+		case chunk, ok := <-dataChan:
+			if !ok {
+				cliCancel()
+				r.finish("completed", "")
+				return
+			}
+			r.handleChunk(chunk)
+		case errMsg, ok := <-errChan:
+			if !ok {
+				errChan = nil
+				continue
+			}
+			var execErr error
+			message := "upstream request failed"
+			if errMsg != nil && errMsg.Error != nil {
+				execErr = errMsg.Error
+				message = errMsg.Error.Error()
+			}
+			cliCancel(execErr)
+			details, _ := sjson.Set(`{"type":"failed","error":{"type":"server_error"}}`, "error.message", message)
+			r.finish("failed", details)
+			return
+		}
+	}
+}
+
+func (r *realtimeResponse) eventWithResponse(eventType, status string) string {
+	out, _ := sjson.Set(`{}`, "type", eventType)
+	out, _ = sjson.SetRaw(out, "response", r.object(status))
+	return out
+}
+
+func (r *realtimeResponse) handleChunk(chunk []byte) {
+	if usage := gjson.GetBytes(chunk, "usage"); usage.IsObject() {
+		r.usage = usage
+	}
+	delta := gjson.GetBytes(chunk, "choices.0.delta")
+	if content := delta.Get("content").String(); content != "" {
+		r.textDelta(content)
+	}
+	for _, call := range delta.Get("tool_calls").Array() {
+		r.toolCallDelta(call)
+	}
+}
+
+// finish closes the output items and sends response.done.
+func (r *realtimeResponse) finish(status, statusDetails string) {
+	itemStatus := "completed"
+	if status != "completed" {
+		itemStatus = "incomplete"
+	}
+	if r.message != nil {
+		r.finishMessage(status == "completed", itemStatus)
+	}
+	for _, output := range r.outputs {
+		if output == r.message {
+			continue
+		}
+		arguments := output.text.String()
+		output.item, _ = sjson.Set(output.item, "arguments", arguments)
+		output.item, _ = sjson.Set(output.item, "status", itemStatus)
+		done := r.event("response.function_call_arguments.done", output)
+		done, _ = sjson.Set(done, "call_id", gjson.Get(output.item, "call_id").String())
+		done, _ = sjson.Set(done, "name", gjson.Get(output.item, "name").String())
+		done, _ = sjson.Set(done, "arguments", arguments)
+		r.session.send(done)
+		r.finishOutput(output)
+	}
+
+	out := r.eventWithResponse("response.done", status)
+	if statusDetails != "" {
+		out, _ = sjson.SetRaw(out, "response.status_details", statusDetails)
+	}
+	if r.usage.IsObject() {
+		usage := `{}`
+		usage, _ = sjson.Set(usage, "total_tokens", r.usage.Get("total_tokens").Int())
+		usage, _ = sjson.Set(usage, "input_tokens", r.usage.Get("prompt_tokens").Int())
+		usage, _ = sjson.Set(usage, "output_tokens", r.usage.Get("completion_tokens").Int())
+		out, _ = sjson.SetRaw(out, "response.usage", usage)
+	}
+	r.session.send(out)
+}
+
+// finishMessage closes the assistant message, synthesizing its audio when requested.
+func (r *realtimeResponse) finishMessage(completed bool, itemStatus string) {
+	output := r.message
+	text := output.text.String()
+	part := r.contentPart()
+	if r.audio {
+		part, _ = sjson.Set(part, "transcript", text)
+		if completed && text != "" {
+			r.synthesize(output, text)
+		}
+		done := r.event("response.audio_transcript.done", output)
+		done, _ = sjson.Set(done, "content_index", 0)
+		done, _ = sjson.Set(done, "transcript", text)
+		r.session.send(done)
+	} else {
+		part, _ = sjson.Set(part, "text", text)
+		done := r.event("response.text.done", output)
+		done, _ = sjson.Set(done, "content_index", 0)
+		done, _ = sjson.Set(done, "text", text)
+		r.session.send(done)
+	}
+	partDone := r.event("response.content_part.done", output)
+	partDone, _ = sjson.Set(partDone, "content_index", 0)
+	partDone, _ = sjson.SetRaw(partDone, "part", part)
+	r.session.send(partDone)
+
+	output.item, _ = sjson.SetRaw(output.item, "content.-1", part)
+	output.item, _ = sjson.Set(output.item, "status", itemStatus)
+	r.finishOutput(output)
+}
+
+// finishOutput announces a completed output item and stores it in the conversation.
+func (r *realtimeResponse) finishOutput(output *realtimeOutput) {
+	done := r.event("response.output_item.done", nil)
+	done, _ = sjson.Set(done, "output_index", output.index)
+	done, _ = sjson.SetRaw(done, "item", output.item)
+	r.session.send(done)
+	if r.outOfBand {
+		return
+	}
+	id := gjson.Get(output.item, "id").String()
+	r.session.mu.Lock()
+	for i := range r.session.items {
+		if gjson.Get(r.session.items[i], "id").String() == id {
+			r.session.items[i] = output.item
+		}
+	}
+	r.session.mu.Unlock()
+}
+
+// synthesize streams the speech of text as response.audio.delta events.
+func (r *realtimeResponse) synthesize(output *realtimeOutput, text string) {
+	s := r.session
+	request := `{"response_format":"pcm"}`
+	request, _ = sjson.Set(request, "model", realtimeSpeechModel)
+	request, _ = sjson.Set(request, "voice", gjson.Get(r.settings, "voice").String())
+	request, _ = sjson.Set(request, "input", text)
+
+	if s.h.Dispatch != nil {
+		status, failure := s.dispatch(s.ctx, "/v1/audio/speech", "application/json", []byte(request), func(chunk []byte) {
+			delta := r.event("response.audio.delta", output)
+			delta, _ = sjson.Set(delta, "content_index", 0)
+			delta, _ = sjson.Set(delta, "delta", base64.StdEncoding.EncodeToString(chunk))
+			s.send(delta)
+		})
+		if status != http.StatusOK {
+			s.sendError("", "audio_synthesis_failed", fmt.Sprintf("Audio could not be synthesized: %s", realtimeErrorMessage(status, failure)))
+		}
+		done := r.event("response.audio.done", output)
+		done, _ = sjson.Set(done, "content_index", 0)
+		s.send(done)
+		return
+	}
+	ctx, cancel := s.h.GetContextWithCancel(s.h, s.c, s.ctx)
+	_, dataChan, errChan := s.h.ExecuteSpeechWithAuthManager(ctx, s.h.HandlerType(), realtimeSpeechModel, []byte(request))
+	var execErr error
+	// dataChan is nil when the request could not be routed; the error follows on errChan.
+	if dataChan != nil {
+		for chunk := range dataChan {
+			delta := r.event("response.audio.delta", output)
+			delta, _ = sjson.Set(delta, "content_index", 0)
+			delta, _ = sjson.Set(delta, "delta", base64.StdEncoding.EncodeToString(chunk))
+			s.send(delta)
+		}
+	}
+	if errMsg, ok := <-errChan; ok && errMsg != nil {
+		execErr = errMsg.Error
+		s.sendError("", "audio_synthesis_failed", fmt.Sprintf("Audio could not be synthesized: %v", errMsg.Error))
+	}
+	cancel(execErr)
+	done := r.event("response.audio.done", output)
+	done, _ = sjson.Set(done, "content_index", 0)
+	s.send(done)
+}
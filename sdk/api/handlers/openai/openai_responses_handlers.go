@@ -19,12 +19,16 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
 	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
 )
 
 // OpenAIResponsesAPIHandler contains the handlers for OpenAIResponses API endpoints.
 // It holds a pool of clients to interact with the backend service.
 type OpenAIResponsesAPIHandler struct {
 	*handlers.BaseAPIHandler
+
+	// responses keeps completed responses so follow-ups can chain via previous_response_id.
+	responses *responseStore
 }
 
 // NewOpenAIResponsesAPIHandler creates a new OpenAIResponses API handlers instance.
@@ -38,6 +42,7 @@ type OpenAIResponsesAPIHandler struct {
 func NewOpenAIResponsesAPIHandler(apiHandlers *handlers.BaseAPIHandler) *OpenAIResponsesAPIHandler {
 	return &OpenAIResponsesAPIHandler{
 		BaseAPIHandler: apiHandlers,
+		responses:      newResponseStore(),
 	}
 }
 
@@ -50,7 +55,7 @@ func (h *OpenAIResponsesAPIHandler) HandlerType() string {
 func (h *OpenAIResponsesAPIHandler) Models() []map[string]any {
 	// Get dynamic models from the global registry
 	modelRegistry := registry.GetGlobalRegistry()
-	return modelRegistry.GetAvailableModels("openai")
+	return h.WithMappedModels(modelRegistry.GetAvailableModels("openai"))
 }
 
 // OpenAIResponsesModels handles the /v1/models endpoint.
@@ -82,6 +87,20 @@ func (h *OpenAIResponsesAPIHandler) Responses(c *gin.Context) {
 		return
 	}
 
+	if previousID := gjson.GetBytes(rawJSON, "previous_response_id").String(); previousID != "" {
+		history, ok := h.responses.get(c.GetString("apiKey"), previousID)
+		if !ok {
+			c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+				Error: handlers.ErrorDetail{
+					Message: fmt.Sprintf("Previous response with id '%s' not found.", previousID),
+					Type:    "invalid_request_error",
+				},
+			})
+			return
+		}
+		rawJSON, _ = sjson.SetRawBytes(rawJSON, "input", []byte(appendItems(history, normalizeResponsesInput(rawJSON))))
+	}
+
 	// Check if the client requested a streaming response.
 	streamResult := gjson.GetBytes(rawJSON, "stream")
 	if streamResult.Type == gjson.True {
@@ -92,6 +111,20 @@ func (h *OpenAIResponsesAPIHandler) Responses(c *gin.Context) {
 
 }
 
+// storeResponse records a completed response so later requests can reference it by id.
+// rawJSON is the request with any previous_response_id history already expanded. Responses
+// are stored for the client API key of c, the only key that may continue them.
+func (h *OpenAIResponsesAPIHandler) storeResponse(c *gin.Context, rawJSON []byte, response gjson.Result) {
+	if h.responses == nil || !shouldStoreResponse(rawJSON) {
+		return
+	}
+	id := response.Get("id").String()
+	if id == "" {
+		return
+	}
+	h.responses.put(c.GetString("apiKey"), id, appendItems(normalizeResponsesInput(rawJSON), replayableOutput(response)))
+}
+
 // handleNonStreamingResponse handles non-streaming chat completion responses
 // for Gemini models. It selects a client from the pool, sends the request, and
 // aggregates the response before sending it back to the client in OpenAIResponses format.
@@ -113,6 +146,7 @@ func (h *OpenAIResponsesAPIHandler) handleNonStreamingResponse(c *gin.Context, r
 		h.WriteErrorResponse(c, errMsg)
 		return
 	}
+	h.storeResponse(c, rawJSON, gjson.ParseBytes(resp))
 	_, _ = c.Writer.Write(resp)
 	return
 
@@ -149,24 +183,43 @@ func (h *OpenAIResponsesAPIHandler) handleStreamingResponse(c *gin.Context, rawJ
 	modelName := gjson.GetBytes(rawJSON, "model").String()
 	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
 	dataChan, errChan := h.ExecuteStreamWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, "")
-	h.forwardResponsesStream(c, flusher, func(err error) { cliCancel(err) }, dataChan, errChan)
+	onCompleted := func(response gjson.Result) { h.storeResponse(c, rawJSON, response) }
+	h.forwardResponsesStream(c, flusher, func(err error) { cliCancel(err) }, onCompleted, dataChan, errChan)
 	return
 }
 
-func (h *OpenAIResponsesAPIHandler) forwardResponsesStream(c *gin.Context, flusher http.Flusher, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage) {
+func (h *OpenAIResponsesAPIHandler) forwardResponsesStream(c *gin.Context, flusher http.Flusher, cancel func(error), onCompleted func(gjson.Result), data <-chan []byte, errs <-chan *interfaces.ErrorMessage) {
+	guard := h.NewStreamGuard()
+	defer guard.Stop()
 	for {
 		select {
 		case <-c.Request.Context().Done():
 			cancel(c.Request.Context().Err())
 			return
+		case <-guard.KeepAlive():
+			_, _ = c.Writer.Write(handlers.KeepAliveComment)
+			flusher.Flush()
+		case errMsg := <-guard.Timeout():
+			apiErr := h.NormalizeError(errMsg)
+			event, _ := sjson.SetBytes([]byte(`{"type":"error"}`), "code", apiErr.Code)
+			event, _ = sjson.SetBytes(event, "message", apiErr.Message)
+			_, _ = fmt.Fprintf(c.Writer, "\nevent: error\ndata: %s\n\n", event)
+			flusher.Flush()
+			cancel(errMsg.Error)
+			return
 		case chunk, ok := <-data:
 			if !ok {
 				_, _ = c.Writer.Write([]byte("\n"))
+				_, _ = c.Writer.Write(h.DiagnosticsEvent(c))
 				flusher.Flush()
 				cancel(nil)
 				return
 			}
+			guard.Touch()
 
+			if completed, found := completedResponse(chunk); found {
+				onCompleted(completed)
+			}
 			if bytes.HasPrefix(chunk, []byte("event:")) {
 				_, _ = c.Writer.Write([]byte("\n"))
 			}
@@ -192,3 +245,18 @@ func (h *OpenAIResponsesAPIHandler) forwardResponsesStream(c *gin.Context, flush
 		}
 	}
 }
+
+// completedResponse extracts the response object from a response.completed stream event.
+func completedResponse(chunk []byte) (gjson.Result, bool) {
+	for _, line := range bytes.Split(chunk, []byte("\n")) {
+		payload, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data:"))
+		if !ok {
+			continue
+		}
+		payload = bytes.TrimSpace(payload)
+		if gjson.GetBytes(payload, "type").String() == "response.completed" {
+			return gjson.GetBytes(payload, "response"), true
+		}
+	}
+	return gjson.Result{}, false
+}
@@ -0,0 +1,134 @@
+package openai
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	// responseStoreCapacity bounds the number of conversations kept for previous_response_id chaining.
+	responseStoreCapacity = 1024
+	// responseStoreTTL is how long a stored response can be referenced by a follow-up request.
+	responseStoreTTL = 24 * time.Hour
+)
+
+// storedResponse holds the conversation items visible to a follow-up request:
+// the expanded input of the stored request followed by the response output.
+type storedResponse struct {
+	key       string
+	items     string
+	expiresAt time.Time
+}
+
+// responseStore is a bounded in-memory LRU of completed responses keyed by the client API key
+// and response id, so a client can only continue its own conversations. Upstreams are
+// stateless, so chaining is resolved locally by replaying the stored items.
+type responseStore struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+	now     func() time.Time
+}
+
+func newResponseStore() *responseStore {
+	return &responseStore{
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+// responseKey returns the store key of response id of apiKey.
+func responseKey(apiKey, id string) string {
+	return apiKey + "\x00" + id
+}
+
+// get returns the conversation items apiKey stored for id as a JSON array.
+func (s *responseStore) get(apiKey, id string) (string, bool) {
+	key := responseKey(apiKey, id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elem, ok := s.entries[key]
+	if !ok {
+		return "", false
+	}
+	entry := elem.Value.(*storedResponse)
+	if s.now().After(entry.expiresAt) {
+		s.order.Remove(elem)
+		delete(s.entries, key)
+		return "", false
+	}
+	s.order.MoveToFront(elem)
+	return entry.items, true
+}
+
+// put stores items under id for apiKey, evicting the least recently used entries beyond capacity.
+func (s *responseStore) put(apiKey, id, items string) {
+	if id == "" {
+		return
+	}
+	key := responseKey(apiKey, id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := &storedResponse{key: key, items: items, expiresAt: s.now().Add(responseStoreTTL)}
+	if elem, ok := s.entries[key]; ok {
+		elem.Value = entry
+		s.order.MoveToFront(elem)
+		return
+	}
+	s.entries[key] = s.order.PushFront(entry)
+	for s.order.Len() > responseStoreCapacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*storedResponse).key)
+	}
+}
+
+// normalizeResponsesInput returns the request input as a JSON array of items.
+// A plain string input becomes a single user message.
+func normalizeResponsesInput(rawJSON []byte) string {
+	input := gjson.GetBytes(rawJSON, "input")
+	switch {
+	case input.IsArray():
+		return input.Raw
+	case input.Type == gjson.String:
+		item := `{"type":"message","role":"user","content":[{"type":"input_text","text":""}]}`
+		item, _ = sjson.Set(item, "content.0.text", input.String())
+		return "[" + item + "]"
+	default:
+		return "[]"
+	}
+}
+
+// appendItems concatenates two JSON arrays of items.
+func appendItems(first, second string) string {
+	out := first
+	for _, item := range gjson.Parse(second).Array() {
+		out, _ = sjson.SetRaw(out, "-1", item.Raw)
+	}
+	return out
+}
+
+// replayableOutput returns the response output items that can be sent back as input.
+// Reasoning items are dropped because most upstreams cannot accept them as input.
+func replayableOutput(response gjson.Result) string {
+	out := "[]"
+	for _, item := range response.Get("output").Array() {
+		if strings.EqualFold(item.Get("type").String(), "reasoning") {
+			continue
+		}
+		out, _ = sjson.SetRaw(out, "-1", item.Raw)
+	}
+	return out
+}
+
+// shouldStoreResponse reports whether the request opted into server-side storage (the default).
+func shouldStoreResponse(rawJSON []byte) bool {
+	store := gjson.GetBytes(rawJSON, "store")
+	return !store.Exists() || store.Type != gjson.False
+}
@@ -0,0 +1,52 @@
+package openai
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+)
+
+// CountTokens handles the /v1/tokens/count endpoint. It counts the prompt tokens of an
+// OpenAI chat, Responses, Claude messages or Gemini request body locally, with the
+// tokenizer of the model family serving the requested model, and sends nothing upstream.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIAPIHandler) CountTokens(c *gin.Context) {
+	rawJSON, err := c.GetRawData()
+	// If data retrieval fails, return a 400 Bad Request error.
+	if err != nil {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: fmt.Sprintf("Invalid request: %v", err),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+	if !gjson.ValidBytes(rawJSON) {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: "Invalid request: body must be a JSON object",
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	count, errMsg := h.CountTokensLocally(c.Request.Context(), gjson.GetBytes(rawJSON, "model").String(), rawJSON)
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"object":       "token_count",
+		"model":        count.Model,
+		"input_tokens": count.InputTokens,
+		"tokenizer":    count.Tokenizer,
+		"estimated":    count.Estimated,
+	})
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"golang.org/x/net/context"
+)
+
+const (
+	// ProviderHeader pins a request to one upstream provider.
+	ProviderHeader = "X-CLIProxy-Provider"
+	// AccountHeader pins a request to one upstream account, named by its auth ID.
+	AccountHeader = "X-CLIProxy-Account"
+)
+
+// applyRoutingHeaders narrows the providers of a request to the ones its routing headers
+// pin it to, and returns the auth ID the request must be served by, if any. Only the API
+// keys listed under routing-headers may send the headers.
+func (h *BaseAPIHandler) applyRoutingHeaders(ctx context.Context, modelName string, providers []string) ([]string, string, *interfaces.ErrorMessage) {
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil || ginCtx.Request == nil {
+		return providers, "", nil
+	}
+	provider := strings.ToLower(strings.TrimSpace(ginCtx.GetHeader(ProviderHeader)))
+	account := strings.TrimSpace(ginCtx.GetHeader(AccountHeader))
+	if provider == "" && account == "" {
+		return providers, "", nil
+	}
+	if !h.routingHeadersAllowed(ginCtx) {
+		return nil, "", &interfaces.ErrorMessage{StatusCode: http.StatusForbidden, Error: fmt.Errorf("%s and %s are not permitted for this API key", ProviderHeader, AccountHeader)}
+	}
+	if account != "" {
+		auth, found := h.AuthManager.GetByID(account)
+		if !found {
+			return nil, "", &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: fmt.Errorf("account %s not found", account)}
+		}
+		if provider != "" && provider != auth.Provider {
+			return nil, "", &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: fmt.Errorf("account %s belongs to provider %s, not %s", account, auth.Provider, provider)}
+		}
+		provider = auth.Provider
+	}
+	if !slices.Contains(providers, provider) {
+		return nil, "", &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: fmt.Errorf("provider %s does not serve model %s", provider, modelName)}
+	}
+	return []string{provider}, account, nil
+}
+
+// routingHeadersAllowed reports whether the API key of the request may send routing headers.
+func (h *BaseAPIHandler) routingHeadersAllowed(ginCtx *gin.Context) bool {
+	if h.Cfg == nil || len(h.Cfg.RoutingHeaders.APIKeys) == 0 {
+		return false
+	}
+	value, exists := ginCtx.Get("apiKey")
+	if !exists {
+		return false
+	}
+	apiKey := fmt.Sprint(value)
+	return apiKey != "" && slices.Contains(h.Cfg.RoutingHeaders.APIKeys, apiKey)
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/routingrule"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"golang.org/x/net/context"
+)
+
+// routingRuleCache holds the compiled routing rules of the current configuration and
+// compiles them again when the rules change.
+type routingRuleCache struct {
+	mu       sync.Mutex
+	source   []config.RoutingRule
+	compiled *routingrule.Rules
+}
+
+func (c *routingRuleCache) get(rules []config.RoutingRule) *routingrule.Rules {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.compiled != nil && slices.Equal(c.source, rules) {
+		return c.compiled
+	}
+	compiled, err := routingrule.Compile(rules)
+	if err != nil {
+		// The configuration loader rejects invalid rules, so this only happens for
+		// configurations built in code.
+		log.Errorf("routing rules disabled: %v", err)
+		compiled = &routingrule.Rules{}
+	}
+	c.source = slices.Clone(rules)
+	c.compiled = compiled
+	return compiled
+}
+
+// applyRoutingRules returns the model the first matching routing rule sends the request to,
+// or modelName when no rule matches.
+func (h *BaseAPIHandler) applyRoutingRules(ctx context.Context, handlerType, modelName string, rawJSON []byte, stream bool) string {
+	if h.Cfg == nil || len(h.Cfg.RoutingRules) == 0 {
+		return modelName
+	}
+	rules := h.routingRules.get(h.Cfg.RoutingRules)
+	if rules.Len() == 0 {
+		return modelName
+	}
+	requirements := requestRequirements(rawJSON)
+	root := gjson.ParseBytes(rawJSON)
+	input := routingrule.Input{
+		Model:        modelName,
+		Format:       handlerType,
+		Stream:       stream,
+		Body:         rawJSON,
+		Tools:        requirements.Tools,
+		Images:       requirements.Vision,
+		JSONOutput:   requirements.JSONMode,
+		PromptTokens: requirements.InputTokens,
+		Messages:     len(root.Get("messages").Array()) + len(root.Get("input").Array()) + len(root.Get("contents").Array()),
+		Time:         time.Now(),
+	}
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		if value, exists := ginCtx.Get("apiKey"); exists {
+			input.APIKey = fmt.Sprint(value)
+		}
+		if ginCtx.Request != nil {
+			input.Headers = make(map[string]string, len(ginCtx.Request.Header))
+			for name := range ginCtx.Request.Header {
+				input.Headers[name] = ginCtx.Request.Header.Get(name)
+			}
+		}
+	}
+	name, routed, matched, errs := rules.Route(input)
+	for _, err := range errs {
+		log.Debugf("%v", err)
+	}
+	if !matched || routed == modelName {
+		return modelName
+	}
+	log.Debugf("routing rule %s: %s -> %s", name, modelName, routed)
+	return routed
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/shadow"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"golang.org/x/net/context"
+)
+
+// shadowTimeout bounds a shadow request, which no client waits for.
+const shadowTimeout = 10 * time.Minute
+
+// startShadow samples a request for shadow traffic. When it is sampled, it returns the
+// function to call with the response returned to the client, which sends the request to the
+// candidate model in the background and stores both responses; otherwise it returns nil.
+func (h *BaseAPIHandler) startShadow(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string, stream bool) func(response []byte) {
+	shadowModel, ok := h.Shadow.Sample(modelName)
+	if !ok {
+		return nil
+	}
+	started := time.Now()
+	request := cloneBytes(rawJSON)
+	project := coreexecutor.Project(ctx)
+	return func(response []byte) {
+		entry := shadow.Entry{
+			Timestamp:   started,
+			Handler:     handlerType,
+			Model:       modelName,
+			ShadowModel: shadowModel,
+			Stream:      stream,
+			Request:     string(request),
+			Response:    string(response),
+			DurationMs:  time.Since(started).Milliseconds(),
+		}
+		release, acquired := h.Shadow.Acquire()
+		if !acquired {
+			log.Debugf("shadow: skipping %s -> %s, too many shadow requests in flight", modelName, shadowModel)
+			return
+		}
+		go func() {
+			defer release()
+			// The shadow request outlives the client request, so it must not carry the
+			// request's Gin context.
+			shadowCtx, cancel := context.WithTimeout(coreexecutor.WithProject(context.Background(), project), shadowTimeout)
+			defer cancel()
+			body := withFallbackModel(request, modelName, shadowModel)
+			if gjson.GetBytes(body, "stream").Bool() {
+				body, _ = sjson.SetBytes(body, "stream", false)
+			}
+			shadowStarted := time.Now()
+			resp, errMsg := h.executeModel(shadowCtx, handlerType, shadowModel, body, alt)
+			entry.ShadowDurationMs = time.Since(shadowStarted).Milliseconds()
+			if errMsg != nil {
+				entry.ShadowStatus = errMsg.StatusCode
+				if errMsg.Error != nil {
+					entry.ShadowError = errMsg.Error.Error()
+				}
+			} else {
+				entry.ShadowStatus = 200
+				entry.ShadowResponse = string(resp)
+			}
+			if err := h.Shadow.Record(entry); err != nil {
+				log.Warnf("shadow: %v", err)
+			}
+		}()
+	}
+}
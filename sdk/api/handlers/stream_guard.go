@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+)
+
+// KeepAliveComment is the SSE comment written to streams while no data is being sent.
+var KeepAliveComment = []byte(": ping\n\n")
+
+// StreamGuard applies the streaming keep-alive and timeout settings to one stream.
+// Handlers select on KeepAlive and Timeout next to their data and error channels and
+// call Touch whenever a chunk arrives. A guard with every setting disabled never fires.
+// A guard also counts its stream as in flight until it is stopped.
+type StreamGuard struct {
+	active            *atomic.Int64
+	stopped           atomic.Bool
+	keepAliveInterval time.Duration
+	idleTimeout       time.Duration
+	keepAlive         *time.Ticker
+	idle              *time.Timer
+	deadline          *time.Timer
+	timeout           chan *interfaces.ErrorMessage
+}
+
+// NewStreamGuard starts a guard for a stream beginning now. Callers must Stop it.
+func (h *BaseAPIHandler) NewStreamGuard() *StreamGuard {
+	g := &StreamGuard{active: &h.activeStreams, timeout: make(chan *interfaces.ErrorMessage, 1)}
+	g.active.Add(1)
+	if h.Cfg == nil {
+		return g
+	}
+	cfg := h.Cfg.Streaming
+	if cfg.KeepAliveSeconds > 0 {
+		g.keepAliveInterval = time.Duration(cfg.KeepAliveSeconds) * time.Second
+		g.keepAlive = time.NewTicker(g.keepAliveInterval)
+	}
+	if cfg.IdleTimeoutSeconds > 0 {
+		g.idleTimeout = time.Duration(cfg.IdleTimeoutSeconds) * time.Second
+		g.idle = time.AfterFunc(g.idleTimeout, func() {
+			g.fire(fmt.Errorf("stream idle timeout: no data received from upstream for %s", g.idleTimeout))
+		})
+	}
+	if cfg.MaxDurationSeconds > 0 {
+		maxDuration := time.Duration(cfg.MaxDurationSeconds) * time.Second
+		g.deadline = time.AfterFunc(maxDuration, func() {
+			g.fire(fmt.Errorf("stream exceeded maximum duration of %s", maxDuration))
+		})
+	}
+	return g
+}
+
+// KeepAlive fires when a keep-alive comment is due. It never fires when keep-alives are disabled.
+func (g *StreamGuard) KeepAlive() <-chan time.Time {
+	if g.keepAlive == nil {
+		return nil
+	}
+	return g.keepAlive.C
+}
+
+// Timeout delivers the error ending a stream that stalled or ran too long.
+func (g *StreamGuard) Timeout() <-chan *interfaces.ErrorMessage { return g.timeout }
+
+// Touch records that data was sent, restarting the idle timeout and the keep-alive interval.
+func (g *StreamGuard) Touch() {
+	if g.idle != nil {
+		g.idle.Reset(g.idleTimeout)
+	}
+	if g.keepAlive != nil {
+		g.keepAlive.Reset(g.keepAliveInterval)
+	}
+}
+
+// Stop releases the guard's timers and marks its stream as finished.
+func (g *StreamGuard) Stop() {
+	if g.stopped.Swap(true) {
+		return
+	}
+	g.active.Add(-1)
+	if g.keepAlive != nil {
+		g.keepAlive.Stop()
+	}
+	if g.idle != nil {
+		g.idle.Stop()
+	}
+	if g.deadline != nil {
+		g.deadline.Stop()
+	}
+}
+
+func (g *StreamGuard) fire(err error) {
+	select {
+	case g.timeout <- &interfaces.ErrorMessage{StatusCode: http.StatusGatewayTimeout, Error: err}:
+	default:
+	}
+}
@@ -0,0 +1,296 @@
+package handlers
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Stream formats that support recovery, named by handler type.
+const (
+	recoveryFormatOpenAI = "openai"
+	recoveryFormatClaude = "claude"
+	recoveryFormatGemini = "gemini"
+)
+
+// streamRecovery continues a stream that failed after part of the response was sent. It
+// follows the text streamed to the client and, on failure, re-issues the request with that
+// text as a trailing assistant turn, rewriting the continuation so the client sees one
+// uninterrupted response. Streams that produced tool calls cannot be continued.
+type streamRecovery struct {
+	format  string
+	rawJSON []byte
+	// restart opens the continuation stream for the given attempt, starting at 1.
+	restart  func(attempt int, body []byte) (<-chan coreexecutor.StreamChunk, *interfaces.ErrorMessage)
+	attempts int
+	max      int
+
+	text        strings.Builder
+	unsupported bool
+	// resumed is set while continuation chunks are rewritten.
+	resumed bool
+
+	// OpenAI chat completions state.
+	id string
+
+	// Claude messages state: the block open when the stream failed, the index emitted next
+	// and the mapping of continuation block indexes to emitted ones.
+	openIndex int
+	openType  string
+	nextIndex int
+	indexMap  map[int64]int
+	started   bool
+	// trimLeft drops the leading whitespace of the continuation, whose prefix was sent
+	// without the whitespace the client already received.
+	trimLeft bool
+}
+
+// newStreamRecovery returns the recovery of a stream in format, or nil when recovery is
+// disabled or the format cannot be continued.
+func newStreamRecovery(format string, rawJSON []byte, alt string, max int, restart func(int, []byte) (<-chan coreexecutor.StreamChunk, *interfaces.ErrorMessage)) *streamRecovery {
+	if max <= 0 {
+		return nil
+	}
+	switch format {
+	case recoveryFormatOpenAI, recoveryFormatClaude:
+	case recoveryFormatGemini:
+		if alt != "" {
+			return nil
+		}
+	default:
+		return nil
+	}
+	rawJSON = cloneBytes(rawJSON)
+	if format == recoveryFormatOpenAI && gjson.GetBytes(rawJSON, "n").Int() > 1 {
+		return nil
+	}
+	return &streamRecovery{format: format, rawJSON: rawJSON, restart: restart, max: max, openIndex: -1}
+}
+
+// resume opens the continuation of a failed stream. It reports false when the stream cannot
+// be continued or every attempt is used up.
+func (r *streamRecovery) resume() (<-chan coreexecutor.StreamChunk, bool) {
+	if r == nil || r.unsupported {
+		return nil, false
+	}
+	for r.attempts < r.max {
+		r.attempts++
+		body, ok := r.continuation()
+		if !ok {
+			return nil, false
+		}
+		chunks, errMsg := r.restart(r.attempts, body)
+		if errMsg == nil {
+			r.resumed = true
+			r.indexMap = make(map[int64]int)
+			return chunks, true
+		}
+	}
+	return nil, false
+}
+
+// continuation builds the request that continues the response after the streamed text.
+func (r *streamRecovery) continuation() ([]byte, bool) {
+	prefix := r.text.String()
+	if prefix == "" {
+		return r.rawJSON, true
+	}
+	var out []byte
+	var err error
+	switch r.format {
+	case recoveryFormatOpenAI:
+		out, err = sjson.SetBytes(r.rawJSON, "messages.-1", map[string]any{"role": "assistant", "content": prefix})
+	case recoveryFormatClaude:
+		// Claude rejects a final assistant turn ending in whitespace, and prefilled turns
+		// cannot be combined with extended thinking.
+		trimmed := strings.TrimRight(prefix, " \t\r\n")
+		if trimmed == "" {
+			return r.rawJSON, true
+		}
+		r.trimLeft = trimmed != prefix
+		out, err = sjson.SetBytes(r.rawJSON, "messages.-1", map[string]any{"role": "assistant", "content": []map[string]any{{"type": "text", "text": trimmed}}})
+		if err == nil {
+			out, err = sjson.DeleteBytes(out, "thinking")
+		}
+	case recoveryFormatGemini:
+		out, err = sjson.SetBytes(r.rawJSON, "contents.-1", map[string]any{"role": "model", "parts": []map[string]any{{"text": prefix}}})
+	}
+	if err != nil {
+		return nil, false
+	}
+	return out, true
+}
+
+// process follows a chunk of the stream and returns the chunk to send to the client, which
+// is empty when the chunk must be dropped.
+func (r *streamRecovery) process(chunk []byte) []byte {
+	if r == nil || r.unsupported {
+		return chunk
+	}
+	switch r.format {
+	case recoveryFormatOpenAI:
+		return r.processOpenAI(chunk)
+	case recoveryFormatClaude:
+		return r.processClaude(chunk)
+	case recoveryFormatGemini:
+		return r.processGemini(chunk)
+	}
+	return chunk
+}
+
+func (r *streamRecovery) processOpenAI(chunk []byte) []byte {
+	if !gjson.ValidBytes(chunk) {
+		return chunk
+	}
+	if r.resumed {
+		if r.id != "" && gjson.GetBytes(chunk, "id").Exists() {
+			chunk, _ = sjson.SetBytes(chunk, "id", r.id)
+		}
+		if gjson.GetBytes(chunk, "choices.0.delta.role").Exists() {
+			chunk, _ = sjson.DeleteBytes(chunk, "choices.0.delta.role")
+		}
+	} else if r.id == "" {
+		r.id = gjson.GetBytes(chunk, "id").String()
+	}
+	delta := gjson.GetBytes(chunk, "choices.0.delta")
+	if delta.Get("tool_calls").Exists() || delta.Get("function_call").Exists() {
+		r.unsupported = true
+	}
+	r.text.WriteString(delta.Get("content").String())
+	return chunk
+}
+
+func (r *streamRecovery) processGemini(chunk []byte) []byte {
+	if !gjson.ValidBytes(chunk) {
+		return chunk
+	}
+	for _, part := range gjson.GetBytes(chunk, "candidates.0.content.parts").Array() {
+		if part.Get("functionCall").Exists() {
+			r.unsupported = true
+		}
+		if !part.Get("thought").Bool() {
+			r.text.WriteString(part.Get("text").String())
+		}
+	}
+	return chunk
+}
+
+// processClaude follows the events of a Claude chunk, which holds one or more complete SSE
+// events, and rewrites those of a continuation.
+func (r *streamRecovery) processClaude(chunk []byte) []byte {
+	var out bytes.Buffer
+	for _, block := range bytes.Split(chunk, []byte("\n\n")) {
+		if len(bytes.TrimSpace(block)) == 0 {
+			continue
+		}
+		event, data := parseSSEEvent(block)
+		if data == nil || !gjson.ValidBytes(data) {
+			out.Write(block)
+			out.WriteString("\n\n")
+			continue
+		}
+		if r.resumed {
+			var keep bool
+			if data, keep = r.rewriteClaudeEvent(data); !keep {
+				continue
+			}
+		} else {
+			r.followClaudeEvent(data)
+		}
+		if event == "" {
+			event = gjson.GetBytes(data, "type").String()
+		}
+		out.WriteString("event: " + event + "\ndata: ")
+		out.Write(data)
+		out.WriteString("\n\n")
+	}
+	return out.Bytes()
+}
+
+// followClaudeEvent records the text and open block of the original stream.
+func (r *streamRecovery) followClaudeEvent(data []byte) {
+	switch gjson.GetBytes(data, "type").String() {
+	case "message_start":
+		r.started = true
+	case "content_block_start":
+		index := int(gjson.GetBytes(data, "index").Int())
+		r.openIndex = index
+		r.openType = gjson.GetBytes(data, "content_block.type").String()
+		if r.openType == "tool_use" || r.openType == "server_tool_use" {
+			r.unsupported = true
+		}
+		if index >= r.nextIndex {
+			r.nextIndex = index + 1
+		}
+	case "content_block_delta":
+		if gjson.GetBytes(data, "delta.type").String() == "text_delta" {
+			r.text.WriteString(gjson.GetBytes(data, "delta.text").String())
+		}
+	case "content_block_stop":
+		if int(gjson.GetBytes(data, "index").Int()) == r.openIndex {
+			r.openIndex, r.openType = -1, ""
+		}
+	}
+}
+
+// rewriteClaudeEvent maps an event of the continuation onto the original stream: the new
+// message_start is dropped, a first text block continues the text block left open and
+// later blocks are numbered after the original ones.
+func (r *streamRecovery) rewriteClaudeEvent(data []byte) ([]byte, bool) {
+	switch gjson.GetBytes(data, "type").String() {
+	case "message_start":
+		if r.started {
+			return data, false
+		}
+	case "content_block_start":
+		source := gjson.GetBytes(data, "index").Int()
+		if gjson.GetBytes(data, "content_block.type").String() == "text" && r.openType == "text" && len(r.indexMap) == 0 {
+			r.indexMap[source] = r.openIndex
+			return data, false
+		}
+		r.indexMap[source] = r.nextIndex
+		data, _ = sjson.SetBytes(data, "index", r.nextIndex)
+	case "content_block_delta", "content_block_stop":
+		if index, ok := r.indexMap[gjson.GetBytes(data, "index").Int()]; ok {
+			data, _ = sjson.SetBytes(data, "index", index)
+		}
+		if r.trimLeft && gjson.GetBytes(data, "delta.type").String() == "text_delta" {
+			r.trimLeft = false
+			text := strings.TrimLeft(gjson.GetBytes(data, "delta.text").String(), " \t\r\n")
+			data, _ = sjson.SetBytes(data, "delta.text", text)
+		}
+	}
+	r.followClaudeEvent(data)
+	return data, true
+}
+
+// closeOpenBlock returns the events that end a non-text block left open by the failed
+// stream, since the continuation cannot extend it.
+func (r *streamRecovery) closeOpenBlock() []byte {
+	if r == nil || r.format != recoveryFormatClaude || r.openIndex < 0 || r.openType == "text" {
+		return nil
+	}
+	data, _ := sjson.SetBytes([]byte(`{"type":"content_block_stop"}`), "index", r.openIndex)
+	r.openIndex, r.openType = -1, ""
+	return append(append([]byte("event: content_block_stop\ndata: "), data...), '\n', '\n')
+}
+
+// parseSSEEvent returns the event name and data of an SSE event block.
+func parseSSEEvent(block []byte) (string, []byte) {
+	var event string
+	var data []byte
+	for _, line := range bytes.Split(block, []byte("\n")) {
+		line = bytes.TrimRight(line, "\r")
+		switch {
+		case bytes.HasPrefix(line, []byte("event:")):
+			event = strings.TrimSpace(string(line[len("event:"):]))
+		case bytes.HasPrefix(line, []byte("data:")):
+			data = bytes.TrimSpace(line[len("data:"):])
+		}
+	}
+	return event, data
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tokencount"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+// CountTokensLocally counts the prompt tokens of a request without contacting an upstream,
+// using the tokenizer of the model family serving modelName. Model mappings apply, and
+// models without a provider are counted with the OpenAI tokenizer.
+func (h *BaseAPIHandler) CountTokensLocally(ctx context.Context, modelName string, rawJSON []byte) (tokencount.Count, *interfaces.ErrorMessage) {
+	if h.ModelAllowed != nil && !h.ModelAllowed(ctx, modelName) {
+		return tokencount.Count{}, &interfaces.ErrorMessage{StatusCode: http.StatusForbidden, Error: fmt.Errorf("model %s is not allowed for this API key", modelName)}
+	}
+	requested := modelName
+	var pinnedProvider string
+	if h.Cfg != nil && len(h.Cfg.ModelMappings) > 0 {
+		modelName, pinnedProvider = mapModel(h.Cfg.ModelMappings, modelName)
+	}
+	providerName, extractedModelName, isDynamic := h.parseDynamicModel(modelName)
+	normalizedModel, _ := normalizeModelMetadata(modelName)
+	var providers []string
+	if isDynamic {
+		providers = []string{providerName}
+		normalizedModel = extractedModelName
+	} else {
+		providers = util.GetProviderName(normalizedModel)
+	}
+	if pinnedProvider != "" {
+		providers = []string{pinnedProvider}
+	}
+	count, err := tokencount.Tokens(normalizedModel, providers, rawJSON)
+	if err != nil {
+		return tokencount.Count{}, &interfaces.ErrorMessage{StatusCode: http.StatusInternalServerError, Error: err}
+	}
+	count.Model = requested
+	return count, nil
+}
@@ -57,21 +57,6 @@ func (a *ClaudeAuthenticator) Login(ctx context.Context, cfg *config.Config, opt
 		return nil, fmt.Errorf("claude state generation failed: %w", err)
 	}
 
-	oauthServer := claude.NewOAuthServer(a.CallbackPort)
-	if err = oauthServer.Start(); err != nil {
-		if strings.Contains(err.Error(), "already in use") {
-			return nil, claude.NewAuthenticationError(claude.ErrPortInUse, err)
-		}
-		return nil, claude.NewAuthenticationError(claude.ErrServerStartFailed, err)
-	}
-	defer func() {
-		stopCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-		defer cancel()
-		if stopErr := oauthServer.Stop(stopCtx); stopErr != nil {
-			log.Warnf("claude oauth server stop error: %v", stopErr)
-		}
-	}()
-
 	authSvc := claude.NewClaudeAuth(cfg)
 
 	authURL, returnedState, err := authSvc.GenerateAuthURL(state, pkceCodes)
@@ -80,30 +65,18 @@ func (a *ClaudeAuthenticator) Login(ctx context.Context, cfg *config.Config, opt
 	}
 	state = returnedState
 
-	if !opts.NoBrowser {
-		fmt.Println("Opening browser for Claude authentication")
-		if !browser.IsAvailable() {
-			log.Warn("No browser available; please open the URL manually")
-			util.PrintSSHTunnelInstructions(a.CallbackPort)
-			fmt.Printf("Visit the following URL to continue authentication:\n%s\n", authURL)
-		} else if err = browser.OpenURL(authURL); err != nil {
-			log.Warnf("Failed to open browser automatically: %v", err)
-			util.PrintSSHTunnelInstructions(a.CallbackPort)
-			fmt.Printf("Visit the following URL to continue authentication:\n%s\n", authURL)
+	var result *claude.OAuthResult
+	if opts.Headless {
+		pasted, errPaste := readHeadlessCallback("Claude", authURL, opts)
+		if errPaste != nil {
+			return nil, fmt.Errorf("claude headless login failed: %w", errPaste)
 		}
+		result = &claude.OAuthResult{Code: pasted.Code, State: pasted.State, Error: pasted.Error}
 	} else {
-		util.PrintSSHTunnelInstructions(a.CallbackPort)
-		fmt.Printf("Visit the following URL to continue authentication:\n%s\n", authURL)
-	}
-
-	fmt.Println("Waiting for Claude authentication callback...")
-
-	result, err := oauthServer.WaitForCallback(5 * time.Minute)
-	if err != nil {
-		if strings.Contains(err.Error(), "timeout") {
-			return nil, claude.NewAuthenticationError(claude.ErrCallbackTimeout, err)
+		result, err = a.waitForCallback(authURL, opts)
+		if err != nil {
+			return nil, err
 		}
-		return nil, err
 	}
 
 	if result.Error != "" {
@@ -145,3 +118,49 @@ func (a *ClaudeAuthenticator) Login(ctx context.Context, cfg *config.Config, opt
 		Metadata: metadata,
 	}, nil
 }
+
+// waitForCallback serves the local OAuth callback, sends the user to authURL and waits for
+// the redirect.
+func (a *ClaudeAuthenticator) waitForCallback(authURL string, opts *LoginOptions) (*claude.OAuthResult, error) {
+	oauthServer := claude.NewOAuthServer(a.CallbackPort)
+	if err := oauthServer.Start(); err != nil {
+		if strings.Contains(err.Error(), "already in use") {
+			return nil, claude.NewAuthenticationError(claude.ErrPortInUse, err)
+		}
+		return nil, claude.NewAuthenticationError(claude.ErrServerStartFailed, err)
+	}
+	defer func() {
+		stopCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if stopErr := oauthServer.Stop(stopCtx); stopErr != nil {
+			log.Warnf("claude oauth server stop error: %v", stopErr)
+		}
+	}()
+
+	if !opts.NoBrowser {
+		fmt.Println("Opening browser for Claude authentication")
+		if !browser.IsAvailable() {
+			log.Warn("No browser available; please open the URL manually")
+			util.PrintSSHTunnelInstructions(a.CallbackPort)
+			fmt.Printf("Visit the following URL to continue authentication:\n%s\n", authURL)
+		} else if err := browser.OpenURL(authURL); err != nil {
+			log.Warnf("Failed to open browser automatically: %v", err)
+			util.PrintSSHTunnelInstructions(a.CallbackPort)
+			fmt.Printf("Visit the following URL to continue authentication:\n%s\n", authURL)
+		}
+	} else {
+		util.PrintSSHTunnelInstructions(a.CallbackPort)
+		fmt.Printf("Visit the following URL to continue authentication:\n%s\n", authURL)
+	}
+
+	fmt.Println("Waiting for Claude authentication callback...")
+
+	result, err := oauthServer.WaitForCallback(5 * time.Minute)
+	if err != nil {
+		if strings.Contains(err.Error(), "timeout") {
+			return nil, claude.NewAuthenticationError(claude.ErrCallbackTimeout, err)
+		}
+		return nil, err
+	}
+	return result, nil
+}
@@ -57,21 +57,6 @@ func (a *CodexAuthenticator) Login(ctx context.Context, cfg *config.Config, opts
 		return nil, fmt.Errorf("codex state generation failed: %w", err)
 	}
 
-	oauthServer := codex.NewOAuthServer(a.CallbackPort)
-	if err = oauthServer.Start(); err != nil {
-		if strings.Contains(err.Error(), "already in use") {
-			return nil, codex.NewAuthenticationError(codex.ErrPortInUse, err)
-		}
-		return nil, codex.NewAuthenticationError(codex.ErrServerStartFailed, err)
-	}
-	defer func() {
-		stopCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-		defer cancel()
-		if stopErr := oauthServer.Stop(stopCtx); stopErr != nil {
-			log.Warnf("codex oauth server stop error: %v", stopErr)
-		}
-	}()
-
 	authSvc := codex.NewCodexAuth(cfg)
 
 	authURL, err := authSvc.GenerateAuthURL(state, pkceCodes)
@@ -79,30 +64,18 @@ func (a *CodexAuthenticator) Login(ctx context.Context, cfg *config.Config, opts
 		return nil, fmt.Errorf("codex authorization url generation failed: %w", err)
 	}
 
-	if !opts.NoBrowser {
-		fmt.Println("Opening browser for Codex authentication")
-		if !browser.IsAvailable() {
-			log.Warn("No browser available; please open the URL manually")
-			util.PrintSSHTunnelInstructions(a.CallbackPort)
-			fmt.Printf("Visit the following URL to continue authentication:\n%s\n", authURL)
-		} else if err = browser.OpenURL(authURL); err != nil {
-			log.Warnf("Failed to open browser automatically: %v", err)
-			util.PrintSSHTunnelInstructions(a.CallbackPort)
-			fmt.Printf("Visit the following URL to continue authentication:\n%s\n", authURL)
+	var result *codex.OAuthResult
+	if opts.Headless {
+		pasted, errPaste := readHeadlessCallback("Codex", authURL, opts)
+		if errPaste != nil {
+			return nil, fmt.Errorf("codex headless login failed: %w", errPaste)
 		}
+		result = &codex.OAuthResult{Code: pasted.Code, State: pasted.State, Error: pasted.Error}
 	} else {
-		util.PrintSSHTunnelInstructions(a.CallbackPort)
-		fmt.Printf("Visit the following URL to continue authentication:\n%s\n", authURL)
-	}
-
-	fmt.Println("Waiting for Codex authentication callback...")
-
-	result, err := oauthServer.WaitForCallback(5 * time.Minute)
-	if err != nil {
-		if strings.Contains(err.Error(), "timeout") {
-			return nil, codex.NewAuthenticationError(codex.ErrCallbackTimeout, err)
+		result, err = a.waitForCallback(authURL, opts)
+		if err != nil {
+			return nil, err
 		}
-		return nil, err
 	}
 
 	if result.Error != "" {
@@ -144,3 +117,49 @@ func (a *CodexAuthenticator) Login(ctx context.Context, cfg *config.Config, opts
 		Metadata: metadata,
 	}, nil
 }
+
+// waitForCallback serves the local OAuth callback, sends the user to authURL and waits for
+// the redirect.
+func (a *CodexAuthenticator) waitForCallback(authURL string, opts *LoginOptions) (*codex.OAuthResult, error) {
+	oauthServer := codex.NewOAuthServer(a.CallbackPort)
+	if err := oauthServer.Start(); err != nil {
+		if strings.Contains(err.Error(), "already in use") {
+			return nil, codex.NewAuthenticationError(codex.ErrPortInUse, err)
+		}
+		return nil, codex.NewAuthenticationError(codex.ErrServerStartFailed, err)
+	}
+	defer func() {
+		stopCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if stopErr := oauthServer.Stop(stopCtx); stopErr != nil {
+			log.Warnf("codex oauth server stop error: %v", stopErr)
+		}
+	}()
+
+	if !opts.NoBrowser {
+		fmt.Println("Opening browser for Codex authentication")
+		if !browser.IsAvailable() {
+			log.Warn("No browser available; please open the URL manually")
+			util.PrintSSHTunnelInstructions(a.CallbackPort)
+			fmt.Printf("Visit the following URL to continue authentication:\n%s\n", authURL)
+		} else if err := browser.OpenURL(authURL); err != nil {
+			log.Warnf("Failed to open browser automatically: %v", err)
+			util.PrintSSHTunnelInstructions(a.CallbackPort)
+			fmt.Printf("Visit the following URL to continue authentication:\n%s\n", authURL)
+		}
+	} else {
+		util.PrintSSHTunnelInstructions(a.CallbackPort)
+		fmt.Printf("Visit the following URL to continue authentication:\n%s\n", authURL)
+	}
+
+	fmt.Println("Waiting for Codex authentication callback...")
+
+	result, err := oauthServer.WaitForCallback(5 * time.Minute)
+	if err != nil {
+		if strings.Contains(err.Error(), "timeout") {
+			return nil, codex.NewAuthenticationError(codex.ErrCallbackTimeout, err)
+		}
+		return nil, err
+	}
+	return result, nil
+}
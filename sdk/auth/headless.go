@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+// headlessPasteAttempts is how often an unusable paste is asked for again.
+const headlessPasteAttempts = 3
+
+// readHeadlessCallback prints the authorization URL for a headless login and reads back the
+// redirect URL the user pastes once they have authorized in a browser elsewhere.
+func readHeadlessCallback(provider, authURL string, opts *LoginOptions) (util.OAuthCallback, error) {
+	util.PrintHeadlessLoginInstructions(provider, authURL)
+	prompt := opts.Prompt
+	if prompt == nil {
+		prompt = stdinPrompt()
+	}
+	var lastErr error
+	for attempt := 0; attempt < headlessPasteAttempts; attempt++ {
+		input, err := prompt("Callback URL: ")
+		if err != nil {
+			return util.OAuthCallback{}, err
+		}
+		result, err := util.ParseOAuthCallback(input)
+		if err == nil {
+			return result, nil
+		}
+		lastErr = err
+		fmt.Printf("%v, please try again.\n", err)
+	}
+	return util.OAuthCallback{}, lastErr
+}
+
+func stdinPrompt() func(string) (string, error) {
+	reader := bufio.NewReader(os.Stdin)
+	return func(prompt string) (string, error) {
+		fmt.Print(prompt)
+		line, err := reader.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+			return "", err
+		}
+		return line, nil
+	}
+}
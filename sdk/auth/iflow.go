@@ -44,21 +44,6 @@ func (a *IFlowAuthenticator) Login(ctx context.Context, cfg *config.Config, opts
 
 	authSvc := iflow.NewIFlowAuth(cfg)
 
-	oauthServer := iflow.NewOAuthServer(iflow.CallbackPort)
-	if err := oauthServer.Start(); err != nil {
-		if strings.Contains(err.Error(), "already in use") {
-			return nil, fmt.Errorf("iflow authentication server port in use: %w", err)
-		}
-		return nil, fmt.Errorf("iflow authentication server failed: %w", err)
-	}
-	defer func() {
-		stopCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-		defer cancel()
-		if stopErr := oauthServer.Stop(stopCtx); stopErr != nil {
-			log.Warnf("iflow oauth server stop error: %v", stopErr)
-		}
-	}()
-
 	state, err := misc.GenerateRandomState()
 	if err != nil {
 		return nil, fmt.Errorf("iflow auth: failed to generate state: %w", err)
@@ -66,28 +51,20 @@ func (a *IFlowAuthenticator) Login(ctx context.Context, cfg *config.Config, opts
 
 	authURL, redirectURI := authSvc.AuthorizationURL(state, iflow.CallbackPort)
 
-	if !opts.NoBrowser {
-		fmt.Println("Opening browser for iFlow authentication")
-		if !browser.IsAvailable() {
-			log.Warn("No browser available; please open the URL manually")
-			util.PrintSSHTunnelInstructions(iflow.CallbackPort)
-			fmt.Printf("Visit the following URL to continue authentication:\n%s\n", authURL)
-		} else if err = browser.OpenURL(authURL); err != nil {
-			log.Warnf("Failed to open browser automatically: %v", err)
-			util.PrintSSHTunnelInstructions(iflow.CallbackPort)
-			fmt.Printf("Visit the following URL to continue authentication:\n%s\n", authURL)
+	var result *iflow.OAuthResult
+	if opts.Headless {
+		pasted, errPaste := readHeadlessCallback("iFlow", authURL, opts)
+		if errPaste != nil {
+			return nil, fmt.Errorf("iflow headless login failed: %w", errPaste)
 		}
+		result = &iflow.OAuthResult{Code: pasted.Code, State: pasted.State, Error: pasted.Error}
 	} else {
-		util.PrintSSHTunnelInstructions(iflow.CallbackPort)
-		fmt.Printf("Visit the following URL to continue authentication:\n%s\n", authURL)
+		result, err = waitForIFlowCallback(authURL, opts)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	fmt.Println("Waiting for iFlow authentication callback...")
-
-	result, err := oauthServer.WaitForCallback(5 * time.Minute)
-	if err != nil {
-		return nil, fmt.Errorf("iflow auth: callback wait failed: %w", err)
-	}
 	if result.Error != "" {
 		return nil, fmt.Errorf("iflow auth: provider returned error %s", result.Error)
 	}
@@ -129,3 +106,46 @@ func (a *IFlowAuthenticator) Login(ctx context.Context, cfg *config.Config, opts
 		},
 	}, nil
 }
+
+// waitForIFlowCallback serves the local OAuth callback, sends the user to authURL and waits
+// for the redirect.
+func waitForIFlowCallback(authURL string, opts *LoginOptions) (*iflow.OAuthResult, error) {
+	oauthServer := iflow.NewOAuthServer(iflow.CallbackPort)
+	if err := oauthServer.Start(); err != nil {
+		if strings.Contains(err.Error(), "already in use") {
+			return nil, fmt.Errorf("iflow authentication server port in use: %w", err)
+		}
+		return nil, fmt.Errorf("iflow authentication server failed: %w", err)
+	}
+	defer func() {
+		stopCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if stopErr := oauthServer.Stop(stopCtx); stopErr != nil {
+			log.Warnf("iflow oauth server stop error: %v", stopErr)
+		}
+	}()
+
+	if !opts.NoBrowser {
+		fmt.Println("Opening browser for iFlow authentication")
+		if !browser.IsAvailable() {
+			log.Warn("No browser available; please open the URL manually")
+			util.PrintSSHTunnelInstructions(iflow.CallbackPort)
+			fmt.Printf("Visit the following URL to continue authentication:\n%s\n", authURL)
+		} else if err := browser.OpenURL(authURL); err != nil {
+			log.Warnf("Failed to open browser automatically: %v", err)
+			util.PrintSSHTunnelInstructions(iflow.CallbackPort)
+			fmt.Printf("Visit the following URL to continue authentication:\n%s\n", authURL)
+		}
+	} else {
+		util.PrintSSHTunnelInstructions(iflow.CallbackPort)
+		fmt.Printf("Visit the following URL to continue authentication:\n%s\n", authURL)
+	}
+
+	fmt.Println("Waiting for iFlow authentication callback...")
+
+	result, err := oauthServer.WaitForCallback(5 * time.Minute)
+	if err != nil {
+		return nil, fmt.Errorf("iflow auth: callback wait failed: %w", err)
+	}
+	return result, nil
+}
@@ -15,6 +15,9 @@ var ErrRefreshNotSupported = errors.New("cliproxy auth: refresh not supported")
 // Provider-specific logic can inspect Metadata for extra parameters.
 type LoginOptions struct {
 	NoBrowser bool
+	// Headless completes OAuth logins without a browser or callback server on this machine:
+	// the authorization URL is printed and the redirect URL is pasted back through Prompt.
+	Headless  bool
 	ProjectID string
 	Metadata  map[string]string
 	Prompt    func(prompt string) (string, error)
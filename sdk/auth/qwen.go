@@ -51,7 +51,12 @@ func (a *QwenAuthenticator) Login(ctx context.Context, cfg *config.Config, opts
 
 	authURL := deviceFlow.VerificationURIComplete
 
-	if !opts.NoBrowser {
+	if opts.Headless {
+		fmt.Printf("Open the following URL in a browser on any machine and sign in to Qwen:\n%s\n", authURL)
+		if deviceFlow.UserCode != "" {
+			fmt.Printf("If asked for a code, enter: %s\n", deviceFlow.UserCode)
+		}
+	} else if !opts.NoBrowser {
 		fmt.Println("Opening browser for Qwen authentication")
 		if !browser.IsAvailable() {
 			log.Warn("No browser available; please open the URL manually")
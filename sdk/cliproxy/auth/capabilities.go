@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// CapabilityErrorCode identifies errors for requests no account of a provider can serve
+// because its model lacks a feature the request uses.
+const CapabilityErrorCode = "capability_unsupported"
+
+// requirementsFrom returns the requirements attached to a request; nil when capability
+// routing is disabled.
+func requirementsFrom(opts cliproxyexecutor.Options) *cliproxyexecutor.Requirements {
+	requirements, _ := opts.Metadata[cliproxyexecutor.RequirementsMetadataKey].(*cliproxyexecutor.Requirements)
+	return requirements
+}
+
+// unmetRequirement describes the first requirement the model registered by an auth does not
+// meet; empty when it meets them all or its capabilities are unknown.
+func unmetRequirement(authID, model string, requirements *cliproxyexecutor.Requirements) string {
+	if requirements == nil {
+		return ""
+	}
+	info := registry.GetGlobalRegistry().GetClientModelInfo(authID, model)
+	if info == nil {
+		return ""
+	}
+	if window := info.ContextWindow(); window > 0 && requirements.InputTokens > window {
+		return fmt.Sprintf("a context window of %d tokens, smaller than the prompt of about %d tokens", window, requirements.InputTokens)
+	}
+	capabilities := info.Capabilities
+	switch {
+	case capabilities == nil:
+		return ""
+	case requirements.Vision && !capabilities.Vision:
+		return "no image input"
+	case requirements.Tools && !capabilities.Tools:
+		return "no tool calls"
+	case requirements.JSONMode && !capabilities.JSONMode:
+		return "no JSON output mode"
+	}
+	return ""
+}
+
+// capabilityError reports that no account of provider can serve model for the given reason.
+func capabilityError(provider, model, reason string) *Error {
+	return &Error{
+		Code:       CapabilityErrorCode,
+		Message:    fmt.Sprintf("model %s on provider %s cannot serve this request: it has %s", model, provider, reason),
+		HTTPStatus: http.StatusBadRequest,
+	}
+}
@@ -0,0 +1,293 @@
+package auth
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultCircuitFailureThreshold = 5
+	defaultCircuitWindow           = 20
+	defaultCircuitMinRequests      = 10
+	defaultCircuitCooldown         = 30 * time.Second
+)
+
+// CircuitState is the state of an account's circuit breaker.
+type CircuitState string
+
+const (
+	// CircuitClosed lets requests through normally.
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen rejects the account until the cooldown elapses.
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen lets a single probe request through to decide whether to close again.
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// CircuitStatus is the circuit breaker view of one upstream account.
+type CircuitStatus struct {
+	ID                  string                 `json:"id"`
+	Provider            string                 `json:"provider"`
+	Label               string                 `json:"label,omitempty"`
+	State               CircuitState           `json:"state"`
+	ConsecutiveFailures int                    `json:"consecutive_failures"`
+	ErrorRate           float64                `json:"error_rate"`
+	OpenedAt            *time.Time             `json:"opened_at,omitempty"`
+	HalfOpenAt          *time.Time             `json:"half_open_at,omitempty"`
+	Transitions         map[CircuitState]int64 `json:"transitions,omitempty"`
+}
+
+// circuit is the breaker state of one account.
+type circuit struct {
+	state       CircuitState
+	window      outcomeWindow
+	consecutive int
+	openedAt    time.Time
+	// probeAt is when the in-flight half-open probe was let through; zero when none is running.
+	probeAt     time.Time
+	transitions map[CircuitState]int64
+}
+
+// circuitTransition describes a state change, logged once the breaker lock is released.
+type circuitTransition struct {
+	authID string
+	from   CircuitState
+	to     CircuitState
+	reason string
+}
+
+// circuitBreakers holds one circuit per account.
+type circuitBreakers struct {
+	mu       sync.Mutex
+	cfg      config.CircuitBreaker
+	accounts map[string]*circuit
+}
+
+func newCircuitBreakers() *circuitBreakers {
+	return &circuitBreakers{accounts: make(map[string]*circuit)}
+}
+
+// setConfig applies a new configuration. Circuits are reset when the window size changes
+// or breaking is disabled.
+func (b *circuitBreakers) setConfig(cfg config.CircuitBreaker) {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = defaultCircuitFailureThreshold
+	}
+	if cfg.ErrorRate < 0 || cfg.ErrorRate > 1 {
+		cfg.ErrorRate = 0
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = defaultCircuitWindow
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = defaultCircuitMinRequests
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = defaultCircuitCooldown
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if cfg.Window != b.cfg.Window || !cfg.Enable {
+		b.accounts = make(map[string]*circuit)
+	}
+	b.cfg = cfg
+}
+
+// available reports whether the account may be selected: its circuit is closed, or its
+// cooldown has elapsed and no probe is running.
+func (b *circuitBreakers) available(authID string, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.cfg.Enable {
+		return true
+	}
+	c, ok := b.accounts[authID]
+	if !ok {
+		return true
+	}
+	return b.admits(c, now)
+}
+
+func (b *circuitBreakers) admits(c *circuit, now time.Time) bool {
+	switch c.state {
+	case CircuitOpen:
+		return !now.Before(c.openedAt.Add(b.cfg.Cooldown))
+	case CircuitHalfOpen:
+		// A probe that never reported back must not block the account forever.
+		return c.probeAt.IsZero() || !now.Before(c.probeAt.Add(b.cfg.Cooldown))
+	default:
+		return true
+	}
+}
+
+// acquire claims the account for a request. For an open circuit past its cooldown this
+// half-opens it and reserves the probe; it reports false when another request won the probe.
+func (b *circuitBreakers) acquire(authID string, now time.Time) bool {
+	b.mu.Lock()
+	if !b.cfg.Enable {
+		b.mu.Unlock()
+		return true
+	}
+	c, ok := b.accounts[authID]
+	if !ok || c.state == CircuitClosed {
+		b.mu.Unlock()
+		return true
+	}
+	if !b.admits(c, now) {
+		b.mu.Unlock()
+		return false
+	}
+	var transition *circuitTransition
+	if c.state == CircuitOpen {
+		transition = c.transition(authID, CircuitHalfOpen, "cooldown elapsed")
+	}
+	c.probeAt = now
+	b.mu.Unlock()
+	logCircuitTransition(transition)
+	return true
+}
+
+// record folds a request outcome into the account's circuit.
+func (b *circuitBreakers) record(authID string, result Result, now time.Time) {
+	b.mu.Lock()
+	if !b.cfg.Enable {
+		b.mu.Unlock()
+		return
+	}
+	c, ok := b.accounts[authID]
+	if !ok {
+		c = &circuit{state: CircuitClosed, window: newOutcomeWindow(b.cfg.Window)}
+		b.accounts[authID] = c
+	}
+	failed := !result.Success && countsAgainstHealth(result.Error)
+	neutral := !result.Success && !failed
+
+	var transition *circuitTransition
+	switch c.state {
+	case CircuitHalfOpen:
+		c.probeAt = time.Time{}
+		switch {
+		case neutral:
+			// The probe failed for a reason unrelated to the account; let the next one decide.
+		case failed:
+			c.openedAt = now
+			transition = c.transition(authID, CircuitOpen, "probe failed: "+resultMessage(result))
+		default:
+			c.window.reset()
+			c.consecutive = 0
+			transition = c.transition(authID, CircuitClosed, "probe succeeded")
+		}
+	case CircuitClosed:
+		if neutral {
+			break
+		}
+		c.window.add(failed)
+		if !failed {
+			c.consecutive = 0
+			break
+		}
+		c.consecutive++
+		reason := ""
+		if c.consecutive >= b.cfg.FailureThreshold {
+			reason = "consecutive failures reached threshold"
+		} else if b.cfg.ErrorRate > 0 && c.window.filled >= b.cfg.MinRequests && c.window.errorRate() >= b.cfg.ErrorRate {
+			reason = "error rate reached threshold"
+		}
+		if reason != "" {
+			c.openedAt = now
+			transition = c.transition(authID, CircuitOpen, reason+": "+resultMessage(result))
+		}
+	case CircuitOpen:
+		// Outcomes of requests that started before the circuit opened are ignored.
+	}
+	b.mu.Unlock()
+	logCircuitTransition(transition)
+}
+
+func (c *circuit) transition(authID string, to CircuitState, reason string) *circuitTransition {
+	from := c.state
+	c.state = to
+	if c.transitions == nil {
+		c.transitions = make(map[CircuitState]int64)
+	}
+	c.transitions[to]++
+	return &circuitTransition{authID: authID, from: from, to: to, reason: reason}
+}
+
+func (b *circuitBreakers) state(authID string) (CircuitState, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.cfg.Enable {
+		return "", false
+	}
+	if c, ok := b.accounts[authID]; ok {
+		return c.state, true
+	}
+	return CircuitClosed, true
+}
+
+func (b *circuitBreakers) snapshot(auths []*Auth) []CircuitStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.cfg.Enable {
+		return nil
+	}
+	out := make([]CircuitStatus, 0, len(auths))
+	for _, auth := range auths {
+		view := CircuitStatus{ID: auth.ID, Provider: auth.Provider, Label: auth.Label, State: CircuitClosed}
+		if c, ok := b.accounts[auth.ID]; ok {
+			view.State = c.state
+			view.ConsecutiveFailures = c.consecutive
+			view.ErrorRate = c.window.errorRate()
+			if c.state != CircuitClosed {
+				openedAt := c.openedAt
+				halfOpenAt := c.openedAt.Add(b.cfg.Cooldown)
+				view.OpenedAt = &openedAt
+				view.HalfOpenAt = &halfOpenAt
+			}
+			if len(c.transitions) > 0 {
+				view.Transitions = make(map[CircuitState]int64, len(c.transitions))
+				for state, count := range c.transitions {
+					view.Transitions[state] = count
+				}
+			}
+		}
+		out = append(out, view)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+func resultMessage(result Result) string {
+	if result.Error == nil || result.Error.Message == "" {
+		return "request failed"
+	}
+	return result.Error.Message
+}
+
+func logCircuitTransition(transition *circuitTransition) {
+	if transition == nil {
+		return
+	}
+	entry := log.WithFields(log.Fields{"auth": transition.authID, "from": transition.from, "to": transition.to})
+	if transition.to == CircuitOpen {
+		entry.Warnf("circuit opened: %s", transition.reason)
+		return
+	}
+	entry.Infof("circuit %s: %s", transition.to, transition.reason)
+}
+
+// SetCircuitBreakerConfig applies the circuit breaker configuration.
+func (m *Manager) SetCircuitBreakerConfig(cfg config.CircuitBreaker) {
+	m.circuits.setConfig(cfg)
+}
+
+// CircuitSnapshot returns the circuit breaker state of every registered account, sorted by ID.
+// It returns nil when circuit breaking is disabled.
+func (m *Manager) CircuitSnapshot() []CircuitStatus {
+	return m.circuits.snapshot(m.List())
+}
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+const defaultConcurrencyQueueTimeout = 30 * time.Second
+
+// concurrencyLimits counts the requests each account is serving and caps them at the
+// limit of the first matching account-concurrency entry.
+type concurrencyLimits struct {
+	mu       sync.Mutex
+	rules    []config.AccountConcurrency
+	inFlight map[string]int
+	// freed is closed and replaced whenever a slot is released, waking queued requests.
+	freed chan struct{}
+}
+
+func newConcurrencyLimits() *concurrencyLimits {
+	return &concurrencyLimits{inFlight: make(map[string]int), freed: make(chan struct{})}
+}
+
+func (l *concurrencyLimits) setRules(rules []config.AccountConcurrency) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rules = append([]config.AccountConcurrency(nil), rules...)
+	// A raised or removed limit may unblock queued requests.
+	l.wakeLocked()
+}
+
+// ruleLocked returns the entry limiting auth.
+func (l *concurrencyLimits) ruleLocked(auth *Auth) (config.AccountConcurrency, bool) {
+	for _, rule := range l.rules {
+		if rule.Provider != "" && !strings.EqualFold(rule.Provider, auth.Provider) {
+			continue
+		}
+		if rule.Match != "" && !MatchAccount(rule.Match, auth) {
+			continue
+		}
+		return rule, true
+	}
+	return config.AccountConcurrency{}, false
+}
+
+// full reports whether auth is serving as many requests as its limit allows, together
+// with the entry that limits it.
+func (l *concurrencyLimits) full(auth *Auth) (config.AccountConcurrency, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rule, ok := l.ruleLocked(auth)
+	if !ok {
+		return rule, false
+	}
+	return rule, l.inFlight[auth.ID] >= rule.MaxInFlight
+}
+
+// acquire takes a slot of auth. It fails when the account is at its limit.
+func (l *concurrencyLimits) acquire(auth *Auth) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if rule, ok := l.ruleLocked(auth); ok && l.inFlight[auth.ID] >= rule.MaxInFlight {
+		return false
+	}
+	l.inFlight[auth.ID]++
+	return true
+}
+
+// release returns a slot taken by acquire.
+func (l *concurrencyLimits) release(authID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight[authID] <= 1 {
+		delete(l.inFlight, authID)
+	} else {
+		l.inFlight[authID]--
+	}
+	l.wakeLocked()
+}
+
+func (l *concurrencyLimits) wakeLocked() {
+	close(l.freed)
+	l.freed = make(chan struct{})
+}
+
+// waitChan returns a channel that is closed on the next released slot.
+func (l *concurrencyLimits) waitChan() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.freed
+}
+
+// usage returns the requests in flight and the limit of auth; the limit is 0 when none applies.
+func (l *concurrencyLimits) usage(auth *Auth) (int, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rule, _ := l.ruleLocked(auth)
+	return l.inFlight[auth.ID], rule.MaxInFlight
+}
+
+// busyWait decides how a request proceeds when every remaining account is busy: it
+// queues for the longest timeout among the busy accounts in queue mode, and fails fast
+// when none of them queues.
+func busyWait(rules []config.AccountConcurrency) (time.Duration, bool) {
+	var timeout time.Duration
+	queue := false
+	for _, rule := range rules {
+		if rule.Mode == config.AccountConcurrencyFail {
+			continue
+		}
+		queue = true
+		t := rule.QueueTimeout
+		if t <= 0 {
+			t = defaultConcurrencyQueueTimeout
+		}
+		timeout = max(timeout, t)
+	}
+	return timeout, queue
+}
+
+// SetAccountConcurrency applies the per-account concurrency limits.
+func (m *Manager) SetAccountConcurrency(rules []config.AccountConcurrency) {
+	m.limits.setRules(rules)
+}
@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultCooldownSyncInterval = 5 * time.Second
+
+// SharedCooldown is the state of one model of one auth as published to other instances.
+type SharedCooldown struct {
+	AuthID string     `json:"auth_id"`
+	Model  string     `json:"model"`
+	State  ModelState `json:"state"`
+}
+
+// CooldownStore shares per-model cooldowns between proxy instances, so an account that one
+// instance saw rate limited or failing is skipped by all of them.
+// Implementations must be safe for concurrent use.
+type CooldownStore interface {
+	// Save publishes the state of one model, replacing the previous one.
+	Save(ctx context.Context, cooldown SharedCooldown) error
+	// Load returns the states published by all instances.
+	Load(ctx context.Context) ([]SharedCooldown, error)
+}
+
+func newSharedCooldown(authID, model string, state *ModelState) *SharedCooldown {
+	shared := &SharedCooldown{AuthID: authID, Model: model, State: *state}
+	shared.State.LastError = cloneError(state.LastError)
+	return shared
+}
+
+// SetCooldownStore makes the manager publish model cooldowns to store. Call
+// StartCooldownSync to apply the cooldowns published by other instances.
+func (m *Manager) SetCooldownStore(store CooldownStore) {
+	m.mu.Lock()
+	m.cooldownStore = store
+	m.mu.Unlock()
+}
+
+func (m *Manager) shareCooldown(ctx context.Context, store CooldownStore, cooldown SharedCooldown) {
+	if err := store.Save(context.WithoutCancel(ctx), cooldown); err != nil {
+		log.Warnf("failed to share cooldown of %s for model %s: %v", cooldown.AuthID, cooldown.Model, err)
+	}
+}
+
+// StartCooldownSync periodically applies the cooldowns published by other instances.
+func (m *Manager) StartCooldownSync(parent context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultCooldownSyncInterval
+	}
+	m.StopCooldownSync()
+	ctx, cancel := context.WithCancel(parent)
+	m.mu.Lock()
+	m.cooldownSyncCancel = cancel
+	m.mu.Unlock()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			m.syncCooldowns(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// StopCooldownSync cancels the cooldown sync loop, if running.
+func (m *Manager) StopCooldownSync() {
+	m.mu.Lock()
+	cancel := m.cooldownSyncCancel
+	m.cooldownSyncCancel = nil
+	m.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// syncCooldowns adopts every published state that is newer than the local one.
+func (m *Manager) syncCooldowns(ctx context.Context) {
+	m.mu.RLock()
+	store := m.cooldownStore
+	m.mu.RUnlock()
+	if store == nil {
+		return
+	}
+	cooldowns, err := store.Load(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Warnf("failed to load shared cooldowns: %v", err)
+		}
+		return
+	}
+
+	var exceeded, recovered []SharedCooldown
+	now := time.Now()
+	m.mu.Lock()
+	for _, cooldown := range cooldowns {
+		auth, ok := m.auths[cooldown.AuthID]
+		if !ok || auth == nil || cooldown.Model == "" {
+			continue
+		}
+		if local, ok := auth.ModelStates[cooldown.Model]; ok && local != nil && !cooldown.State.UpdatedAt.After(local.UpdatedAt) {
+			continue
+		}
+		state := ensureModelState(auth, cooldown.Model)
+		*state = cooldown.State
+		state.LastError = cloneError(cooldown.State.LastError)
+		updateAggregatedAvailability(auth, now)
+		if state.Unavailable {
+			auth.Status = StatusError
+		} else if !hasModelError(auth, now) {
+			auth.LastError = nil
+			auth.StatusMessage = ""
+			auth.Status = StatusActive
+		}
+		switch {
+		case state.Quota.Exceeded:
+			exceeded = append(exceeded, cooldown)
+		case !state.Unavailable:
+			recovered = append(recovered, cooldown)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, cooldown := range exceeded {
+		registry.GetGlobalRegistry().SetModelQuotaExceeded(cooldown.AuthID, cooldown.Model)
+		registry.GetGlobalRegistry().SuspendClientModel(cooldown.AuthID, cooldown.Model, "quota")
+	}
+	for _, cooldown := range recovered {
+		registry.GetGlobalRegistry().ClearModelQuotaExceeded(cooldown.AuthID, cooldown.Model)
+		registry.GetGlobalRegistry().ResumeClientModel(cooldown.AuthID, cooldown.Model)
+	}
+}
@@ -0,0 +1,337 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+const defaultDedupMaxStreamBytes = 1 << 20
+
+// errFlightIncomplete is the outcome recorded when the owner of a call returns without one,
+// such as after a panic, so the attached callers do not wait for it forever.
+var errFlightIncomplete = &Error{Code: "dedup_owner_failed", Message: "the identical request this call was attached to did not complete", Retryable: true, HTTPStatus: http.StatusBadGateway}
+
+// RequestDedupStats counts requests attached to an identical running call.
+type RequestDedupStats struct {
+	Joined int64 `json:"joined"`
+}
+
+// dedupState is the active request deduplication configuration.
+type dedupState struct {
+	maxStreamBytes int
+}
+
+// flightGroup holds the running calls by request key.
+type flightGroup struct {
+	mu     sync.Mutex
+	calls  map[string]*flight
+	joined atomic.Int64
+}
+
+// flight is one upstream call shared by identical requests. The caller that started it owns
+// it; the others wait for its response or replay its stream chunks.
+type flight struct {
+	group    *flightGroup
+	key      string
+	owner    context.Context
+	maxBytes int
+
+	mu sync.Mutex
+	// changed is closed and replaced on every update.
+	changed chan struct{}
+	opened  bool
+	done    bool
+	payload []byte
+	// chunks holds the latest stream chunks, at most maxBytes of them; base counts the chunks
+	// dropped before chunks[0].
+	chunks [][]byte
+	base   int
+	size   int
+	err    error
+}
+
+// SetRequestDedup applies the request deduplication configuration.
+func (m *Manager) SetRequestDedup(cfg config.RequestDedup) {
+	if !cfg.Enable {
+		m.dedup.Store(nil)
+		return
+	}
+	state := &dedupState{maxStreamBytes: cfg.MaxStreamBytes}
+	if state.maxStreamBytes <= 0 {
+		state.maxStreamBytes = defaultDedupMaxStreamBytes
+	}
+	m.dedup.Store(state)
+}
+
+// RequestDedupStats returns the number of deduplicated requests, and false when deduplication is disabled.
+func (m *Manager) RequestDedupStats() (RequestDedupStats, bool) {
+	stats := RequestDedupStats{Joined: m.flights.joined.Load()}
+	return stats, m.dedup.Load() != nil
+}
+
+// joinFlight returns the running call of an identical request and false, or a new call owned
+// by ctx and true. It returns nil when deduplication is disabled or the request has no JSON body.
+func (m *Manager) joinFlight(ctx context.Context, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (*flight, bool) {
+	state := m.dedup.Load()
+	if state == nil || len(opts.OriginalRequest) == 0 {
+		return nil, false
+	}
+	pinned, _ := opts.Metadata[cliproxyexecutor.PinnedAuthMetadataKey].(string)
+	key := requestDigest(req, opts, cliproxyexecutor.APIKey(ctx), pinned)
+	if key == "" {
+		return nil, false
+	}
+	return m.flights.join(ctx, key, state.maxStreamBytes)
+}
+
+func (g *flightGroup) join(ctx context.Context, key string, maxStreamBytes int) (*flight, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if f, ok := g.calls[key]; ok && f.joinable() {
+		g.joined.Add(1)
+		return f, false
+	}
+	if g.calls == nil {
+		g.calls = make(map[string]*flight)
+	}
+	f := &flight{group: g, key: key, owner: ctx, maxBytes: maxStreamBytes, changed: make(chan struct{})}
+	g.calls[key] = f
+	return f, true
+}
+
+// joinable reports whether a request may still attach to f: late callers replay a stream
+// from its first chunk, so none may have been dropped yet.
+func (f *flight) joinable() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return !f.done && f.base == 0
+}
+
+// update applies change and wakes the waiting callers.
+func (f *flight) update(change func()) {
+	f.mu.Lock()
+	change()
+	close(f.changed)
+	f.changed = make(chan struct{})
+	f.mu.Unlock()
+}
+
+// finish records the outcome of the call and stops identical requests from attaching to it.
+func (f *flight) finish(payload []byte, err error) {
+	f.group.mu.Lock()
+	if f.group.calls[f.key] == f {
+		delete(f.group.calls, f.key)
+	}
+	f.group.mu.Unlock()
+	f.update(func() {
+		f.done = true
+		f.payload = payload
+		f.err = err
+	})
+}
+
+// own runs the call of the owner and records its outcome, also when call panics.
+func (f *flight) own(call func() (cliproxyexecutor.Response, error)) (resp cliproxyexecutor.Response, err error) {
+	err = errFlightIncomplete
+	defer func() { f.finish(resp.Payload, err) }()
+	return call()
+}
+
+// ownStream opens the stream of the owner and relays it, finishing the call when the
+// stream does not open, also when open panics.
+func (f *flight) ownStream(ctx context.Context, open func() (<-chan cliproxyexecutor.StreamChunk, error)) (<-chan cliproxyexecutor.StreamChunk, error) {
+	err := error(errFlightIncomplete)
+	defer func() {
+		if err != nil {
+			f.finish(nil, err)
+		}
+	}()
+	var chunks <-chan cliproxyexecutor.StreamChunk
+	if chunks, err = open(); err != nil {
+		return nil, err
+	}
+	return f.relay(ctx, chunks), nil
+}
+
+// abandoned reports whether the call failed because its owner went away, in which case the
+// attached callers run their requests themselves. The caller must hold f.mu.
+func (f *flight) abandoned() bool {
+	return f.err != nil && f.owner.Err() != nil
+}
+
+// waitResponse waits for the response of a non-streaming call. joined is false when the
+// owner went away before it finished.
+func (f *flight) waitResponse(ctx context.Context) ([]byte, bool, error) {
+	for {
+		f.mu.Lock()
+		if f.done {
+			payload, err, abandoned := f.payload, f.err, f.abandoned()
+			f.mu.Unlock()
+			if abandoned {
+				return nil, false, nil
+			}
+			return bytes.Clone(payload), true, err
+		}
+		changed := f.changed
+		f.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, true, ctx.Err()
+		}
+	}
+}
+
+// waitStream waits until the stream of a call opened and returns the error that kept it from
+// opening. joined is false when the owner went away before the stream produced a chunk.
+func (f *flight) waitStream(ctx context.Context) (bool, error) {
+	for {
+		f.mu.Lock()
+		if f.done && f.base+len(f.chunks) == 0 && f.abandoned() {
+			f.mu.Unlock()
+			return false, nil
+		}
+		if f.opened || f.done {
+			opened, err := f.opened, f.err
+			f.mu.Unlock()
+			if opened {
+				return true, nil
+			}
+			return true, err
+		}
+		changed := f.changed
+		f.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return true, ctx.Err()
+		}
+	}
+}
+
+// relay forwards the stream of the owner while recording its chunks for the attached callers.
+// Only the latest maxBytes are kept: once the stream is larger, the oldest chunks are dropped,
+// so identical requests no longer attach and attached callers that fall that far behind
+// fail. The stream is read to its end even when the owner stops listening.
+func (f *flight) relay(ctx context.Context, chunks <-chan cliproxyexecutor.StreamChunk) <-chan cliproxyexecutor.StreamChunk {
+	f.update(func() { f.opened = true })
+	out := make(chan cliproxyexecutor.StreamChunk)
+	go func() {
+		defer close(out)
+		var streamErr error
+		listening := true
+		for chunk := range chunks {
+			if chunk.Err != nil {
+				streamErr = chunk.Err
+			} else {
+				payload := bytes.Clone(chunk.Payload)
+				f.update(func() {
+					f.chunks = append(f.chunks, payload)
+					f.size += len(payload)
+					for f.size > f.maxBytes && len(f.chunks) > 1 {
+						f.size -= len(f.chunks[0])
+						f.chunks[0] = nil
+						f.chunks = f.chunks[1:]
+						f.base++
+					}
+				})
+			}
+			if listening {
+				select {
+				case out <- chunk:
+				case <-ctx.Done():
+					listening = false
+				}
+			}
+		}
+		if streamErr == nil && ctx.Err() != nil {
+			// The stream was cut short by the owner's client; it must not be replayed as complete.
+			streamErr = ctx.Err()
+		}
+		f.finish(nil, streamErr)
+	}()
+	return out
+}
+
+// subscribe replays the stream of a call from its first chunk and follows it to its end. When
+// the owner went away before any chunk was replayed, the stream of own is forwarded instead.
+func (f *flight) subscribe(ctx context.Context, own func() (<-chan cliproxyexecutor.StreamChunk, error)) <-chan cliproxyexecutor.StreamChunk {
+	out := make(chan cliproxyexecutor.StreamChunk)
+	go func() {
+		defer close(out)
+		sent := 0
+		for {
+			f.mu.Lock()
+			if sent < f.base {
+				f.mu.Unlock()
+				select {
+				case out <- cliproxyexecutor.StreamChunk{Err: &Error{Code: "dedup_stream_overrun", Message: "the identical request this stream was attached to produced output faster than it was read", Retryable: true, HTTPStatus: http.StatusBadGateway}}:
+				case <-ctx.Done():
+				}
+				return
+			}
+			// Copied, since the relay drops chunks from the front of the buffer.
+			pending := append([][]byte(nil), f.chunks[sent-f.base:]...)
+			done, err, abandoned, changed := f.done, f.err, f.abandoned(), f.changed
+			f.mu.Unlock()
+			for _, payload := range pending {
+				select {
+				case out <- cliproxyexecutor.StreamChunk{Payload: payload}:
+					sent++
+				case <-ctx.Done():
+					return
+				}
+			}
+			if done {
+				switch {
+				case abandoned && sent == 0:
+					forwardOwnStream(ctx, out, own)
+					return
+				case abandoned:
+					err = &Error{Code: "dedup_owner_canceled", Message: "the identical request this stream was attached to was canceled by its client", Retryable: true, HTTPStatus: http.StatusBadGateway}
+				}
+				if err != nil {
+					select {
+					case out <- cliproxyexecutor.StreamChunk{Err: err}:
+					case <-ctx.Done():
+					}
+				}
+				return
+			}
+			select {
+			case <-changed:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// forwardOwnStream runs the request of an attached caller itself and forwards its stream.
+func forwardOwnStream(ctx context.Context, out chan<- cliproxyexecutor.StreamChunk, own func() (<-chan cliproxyexecutor.StreamChunk, error)) {
+	chunks, err := own()
+	if err != nil {
+		select {
+		case out <- cliproxyexecutor.StreamChunk{Err: err}:
+		case <-ctx.Done():
+		}
+		return
+	}
+	for chunk := range chunks {
+		select {
+		case out <- chunk:
+		case <-ctx.Done():
+			// Drain so the producer can finish.
+			for range chunks {
+			}
+			return
+		}
+	}
+}
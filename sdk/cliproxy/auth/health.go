@@ -0,0 +1,280 @@
+package auth
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultHealthWindow      = 20
+	defaultHealthMinRequests = 5
+	defaultHealthErrorRate   = 0.5
+	defaultHealthCooldown    = time.Minute
+	defaultHealthMaxCooldown = 15 * time.Minute
+)
+
+// AuthHealth is the health view of one upstream account.
+type AuthHealth struct {
+	ID                  string     `json:"id"`
+	Provider            string     `json:"provider"`
+	Label               string     `json:"label,omitempty"`
+	Healthy             bool       `json:"healthy"`
+	Samples             int        `json:"samples"`
+	ErrorRate           float64    `json:"error_rate"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	Exclusions          int        `json:"exclusions"`
+	ExcludedUntil       *time.Time `json:"excluded_until,omitempty"`
+	LastSuccessAt       *time.Time `json:"last_success_at,omitempty"`
+	LastFailureAt       *time.Time `json:"last_failure_at,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	// Circuit is the account's circuit breaker state; empty when circuit breaking is disabled.
+	Circuit CircuitState `json:"circuit,omitempty"`
+	// Proxy is the account's outbound proxy with its password redacted; ProxyDown is set
+	// while proxy checks report it unreachable.
+	Proxy     string `json:"proxy,omitempty"`
+	ProxyDown bool   `json:"proxy_down,omitempty"`
+	// InFlight is the number of requests the account is serving; MaxInFlight its
+	// concurrency limit, omitted when none applies.
+	InFlight    int `json:"in_flight"`
+	MaxInFlight int `json:"max_in_flight,omitempty"`
+}
+
+// outcomeWindow is a ring buffer of the most recent request outcomes of one account.
+type outcomeWindow struct {
+	outcomes []bool // true marks a failure
+	next     int
+	filled   int
+	failures int
+}
+
+func newOutcomeWindow(size int) outcomeWindow {
+	return outcomeWindow{outcomes: make([]bool, size)}
+}
+
+// add records an outcome, evicting the oldest one once the window is full.
+func (w *outcomeWindow) add(failed bool) {
+	if w.filled == len(w.outcomes) {
+		if w.outcomes[w.next] {
+			w.failures--
+		}
+	} else {
+		w.filled++
+	}
+	w.outcomes[w.next] = failed
+	w.next = (w.next + 1) % len(w.outcomes)
+	if failed {
+		w.failures++
+	}
+}
+
+// errorRate returns the failure ratio of the recorded outcomes.
+func (w *outcomeWindow) errorRate() float64 {
+	if w.filled == 0 {
+		return 0
+	}
+	return float64(w.failures) / float64(w.filled)
+}
+
+func (w *outcomeWindow) reset() {
+	clear(w.outcomes)
+	w.next, w.filled, w.failures = 0, 0, 0
+}
+
+// authHealth tracks the recent outcomes of one account.
+type authHealth struct {
+	window        outcomeWindow
+	consecutive   int
+	level         int
+	exclusions    int
+	excludedUntil time.Time
+	lastSuccess   time.Time
+	lastFailure   time.Time
+	lastError     string
+}
+
+// healthTracker implements passive health checking: accounts whose recent error rate
+// reaches the threshold are excluded from selection until their cooldown elapses.
+type healthTracker struct {
+	mu       sync.Mutex
+	cfg      config.HealthCheck
+	accounts map[string]*authHealth
+}
+
+func newHealthTracker() *healthTracker {
+	return &healthTracker{accounts: make(map[string]*authHealth)}
+}
+
+// setConfig applies a new configuration. Tracked outcomes are discarded when the
+// window size changes.
+func (t *healthTracker) setConfig(cfg config.HealthCheck) {
+	if cfg.Window <= 0 {
+		cfg.Window = defaultHealthWindow
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = defaultHealthMinRequests
+	}
+	if cfg.ErrorRate <= 0 || cfg.ErrorRate > 1 {
+		cfg.ErrorRate = defaultHealthErrorRate
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = defaultHealthCooldown
+	}
+	if cfg.MaxCooldown < cfg.Cooldown {
+		cfg.MaxCooldown = max(defaultHealthMaxCooldown, cfg.Cooldown)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if cfg.Window != t.cfg.Window || !cfg.Enable {
+		t.accounts = make(map[string]*authHealth)
+	}
+	t.cfg = cfg
+}
+
+// record folds a request outcome into the account's window.
+// It reports the exclusion deadline when this outcome took the account out of rotation.
+func (t *healthTracker) record(authID string, result Result, now time.Time) (time.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.cfg.Enable {
+		return time.Time{}, false
+	}
+	health, ok := t.accounts[authID]
+	if !ok {
+		health = &authHealth{window: newOutcomeWindow(t.cfg.Window)}
+		t.accounts[authID] = health
+	}
+	failed := !result.Success && countsAgainstHealth(result.Error)
+	if !result.Success && !failed {
+		// Client errors say nothing about the account; leave the window untouched.
+		return time.Time{}, false
+	}
+	health.window.add(failed)
+	if !failed {
+		health.consecutive = 0
+		health.level = 0
+		health.lastSuccess = now
+		return time.Time{}, false
+	}
+	health.consecutive++
+	health.lastFailure = now
+	if result.Error != nil {
+		health.lastError = result.Error.Message
+	}
+	if health.window.filled < t.cfg.MinRequests || health.window.errorRate() < t.cfg.ErrorRate {
+		return time.Time{}, false
+	}
+
+	cooldown := t.cfg.Cooldown << health.level
+	if cooldown <= 0 || cooldown > t.cfg.MaxCooldown {
+		cooldown = t.cfg.MaxCooldown
+	} else {
+		health.level++
+	}
+	health.excludedUntil = now.Add(cooldown)
+	health.exclusions++
+	// Start the next period with a clean window so re-inclusion is judged on fresh traffic.
+	health.window.reset()
+	return health.excludedUntil, true
+}
+
+// excluded reports whether the account is currently out of rotation.
+func (t *healthTracker) excluded(authID string, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.cfg.Enable {
+		return false
+	}
+	health, ok := t.accounts[authID]
+	return ok && health.excludedUntil.After(now)
+}
+
+func (t *healthTracker) snapshot(auths []*Auth, now time.Time) []AuthHealth {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]AuthHealth, 0, len(auths))
+	for _, auth := range auths {
+		view := AuthHealth{ID: auth.ID, Provider: auth.Provider, Label: auth.Label, Healthy: true}
+		if health, ok := t.accounts[auth.ID]; ok && t.cfg.Enable {
+			view.Samples = health.window.filled
+			view.ErrorRate = health.window.errorRate()
+			view.ConsecutiveFailures = health.consecutive
+			view.Exclusions = health.exclusions
+			view.LastError = health.lastError
+			if health.excludedUntil.After(now) {
+				view.Healthy = false
+				until := health.excludedUntil
+				view.ExcludedUntil = &until
+			}
+			if !health.lastSuccess.IsZero() {
+				at := health.lastSuccess
+				view.LastSuccessAt = &at
+			}
+			if !health.lastFailure.IsZero() {
+				at := health.lastFailure
+				view.LastFailureAt = &at
+			}
+		}
+		out = append(out, view)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// countsAgainstHealth reports whether a failure reflects on the account rather than the request.
+// Transport errors, authentication and quota failures and server errors count;
+// other client errors such as malformed requests do not.
+func countsAgainstHealth(err *Error) bool {
+	status := statusCodeFromResult(err)
+	switch {
+	case status == 0:
+		return true
+	case status == 401, status == 402, status == 403, status == 408, status == 429:
+		return true
+	case status >= 500:
+		return true
+	default:
+		return false
+	}
+}
+
+// SetHealthCheckConfig applies the passive health check configuration.
+func (m *Manager) SetHealthCheckConfig(cfg config.HealthCheck) {
+	m.health.setConfig(cfg)
+}
+
+// HealthSnapshot returns the health state of every registered account, sorted by ID.
+func (m *Manager) HealthSnapshot() []AuthHealth {
+	auths := m.List()
+	out := m.health.snapshot(auths, time.Now())
+	byID := make(map[string]*Auth, len(auths))
+	for _, auth := range auths {
+		byID[auth.ID] = auth
+	}
+	for i := range out {
+		auth := byID[out[i].ID]
+		if state, ok := m.circuits.state(out[i].ID); ok {
+			out[i].Circuit = state
+		}
+		out[i].InFlight, out[i].MaxInFlight = m.limits.usage(auth)
+		if proxyURL := strings.TrimSpace(auth.ProxyURL); proxyURL != "" {
+			out[i].Proxy = RedactProxyURL(proxyURL)
+			if m.proxies.down(proxyURL) {
+				out[i].ProxyDown = true
+				out[i].Healthy = false
+			}
+		}
+	}
+	return out
+}
+
+func (m *Manager) recordHealth(result Result) {
+	until, excluded := m.health.record(result.AuthID, result, time.Now())
+	if excluded {
+		log.Warnf("auth %s (%s) excluded from rotation until %s: error rate above threshold", result.AuthID, result.Provider, until.Format(time.RFC3339))
+	}
+}
@@ -12,10 +12,14 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // ProviderExecutor defines the contract required by Manager to execute provider calls.
@@ -32,6 +36,32 @@ type ProviderExecutor interface {
 	CountTokens(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error)
 }
 
+// EmbeddingExecutor is implemented by provider executors whose upstream offers embeddings.
+// Payloads use the OpenAI embeddings schema in both directions.
+type EmbeddingExecutor interface {
+	Embed(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error)
+}
+
+// ImageExecutor is implemented by provider executors whose upstream can generate images.
+// Payloads use the OpenAI images generation schema in both directions.
+type ImageExecutor interface {
+	GenerateImages(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error)
+}
+
+// AudioExecutor is implemented by provider executors whose upstream offers speech endpoints.
+// Transcribe receives the multipart upload unchanged, with its Content-Type in opts.Headers;
+// Speech receives an OpenAI speech request and streams the synthesized audio.
+type AudioExecutor interface {
+	Transcribe(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error)
+	Speech(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (*cliproxyexecutor.BinaryStream, error)
+}
+
+// ModelLister is implemented by provider executors that can query their upstream for the
+// models an auth may use.
+type ModelLister interface {
+	ListModels(ctx context.Context, auth *Auth) ([]*registry.ModelInfo, error)
+}
+
 // RefreshEvaluator allows runtime state to override refresh decisions.
 type RefreshEvaluator interface {
 	ShouldRefresh(now time.Time, auth *Auth) bool
@@ -40,7 +70,6 @@ type RefreshEvaluator interface {
 const (
 	refreshCheckInterval  = 5 * time.Second
 	refreshPendingBackoff = time.Minute
-	refreshFailureBackoff = 5 * time.Minute
 	quotaBackoffBase      = time.Second
 	quotaBackoffMax       = 30 * time.Minute
 )
@@ -107,8 +136,37 @@ type Manager struct {
 	// Optional HTTP RoundTripper provider injected by host.
 	rtProvider RoundTripperProvider
 
+	// health tracks recent outcomes per auth and excludes failing auths from selection.
+	health *healthTracker
+	// circuits holds the per-auth circuit breakers.
+	circuits *circuitBreakers
+	// proxies probes the outbound proxies of auths and excludes auths behind a failing one.
+	proxies *proxyChecker
+	// limits caps the requests each auth serves at the same time.
+	limits *concurrencyLimits
+	// sticky pins conversations to the auth that served them.
+	sticky *stickySessions
+	// retry holds the request retry policy; nil disables retries.
+	retry atomic.Pointer[retryPolicy]
+	// responseCache holds the response cache; nil disables caching.
+	responseCache atomic.Pointer[responseCacheState]
+	cacheCounters responseCacheCounters
+	// dedup holds the request deduplication configuration; nil disables it.
+	dedup   atomic.Pointer[dedupState]
+	flights flightGroup
+	// cooldownStore shares per-model cooldowns with other instances; nil keeps them local.
+	cooldownStore CooldownStore
+
 	// Auto refresh state
 	refreshCancel context.CancelFunc
+	// refreshCfg holds the token refresh lead and retry settings; nil uses the defaults.
+	refreshCfg atomic.Pointer[config.TokenRefresh]
+	// refreshAlertClient posts refresh failure alerts through the configured proxy.
+	refreshAlertClient atomic.Pointer[http.Client]
+	// refreshFailures counts consecutive refresh failures per auth, guarded by mu.
+	refreshFailures map[string]int
+	// cooldownSyncCancel stops the shared cooldown sync loop.
+	cooldownSyncCancel context.CancelFunc
 }
 
 // NewManager constructs a manager with optional custom selector and hook.
@@ -126,6 +184,12 @@ func NewManager(store Store, selector Selector, hook Hook) *Manager {
 		hook:            hook,
 		auths:           make(map[string]*Auth),
 		providerOffsets: make(map[string]int),
+		refreshFailures: make(map[string]int),
+		health:          newHealthTracker(),
+		circuits:        newCircuitBreakers(),
+		proxies:         newProxyChecker(),
+		limits:          newConcurrencyLimits(),
+		sticky:          newStickySessions(),
 	}
 }
 
@@ -143,6 +207,26 @@ func (m *Manager) SetRoundTripperProvider(p RoundTripperProvider) {
 	m.mu.Unlock()
 }
 
+// SetRoutingPolicies forwards routing policies to the selector when it supports them.
+func (m *Manager) SetRoutingPolicies(policies []config.RoutingPolicy) {
+	m.mu.RLock()
+	selector := m.selector
+	m.mu.RUnlock()
+	if receiver, ok := selector.(RoutingPolicyReceiver); ok {
+		receiver.SetRoutingPolicies(policies)
+	}
+}
+
+// SetProjectRoutingPolicies forwards per-project routing policies to the selector when it supports them.
+func (m *Manager) SetProjectRoutingPolicies(projects []config.Project) {
+	m.mu.RLock()
+	selector := m.selector
+	m.mu.RUnlock()
+	if receiver, ok := selector.(ProjectRoutingReceiver); ok {
+		receiver.SetProjectRoutingPolicies(projects)
+	}
+}
+
 // RegisterExecutor registers a provider executor with the manager.
 func (m *Manager) RegisterExecutor(executor ProviderExecutor) {
 	if executor == nil {
@@ -221,21 +305,50 @@ func (m *Manager) Execute(ctx context.Context, providers []string, req cliproxye
 	if len(normalized) == 0 {
 		return cliproxyexecutor.Response{}, &Error{Code: "provider_not_found", Message: "no provider supplied"}
 	}
+	cacheKey := m.responseCacheKey(ctx, req, opts)
+	if cached, ok := m.lookupResponse(ctx, cacheKey); ok && len(cached.Payload) > 0 {
+		return cliproxyexecutor.Response{Payload: cached.Payload}, nil
+	}
+	if f, owner := m.joinFlight(ctx, req, opts); f != nil {
+		if owner {
+			return f.own(func() (cliproxyexecutor.Response, error) {
+				return m.execute(ctx, normalized, req, opts, cacheKey)
+			})
+		}
+		log.Debugf("request dedup: waiting for an identical running request for model %s", req.Model)
+		if payload, joined, err := f.waitResponse(ctx); joined {
+			return cliproxyexecutor.Response{Payload: payload}, err
+		}
+	}
+	return m.execute(ctx, normalized, req, opts, cacheKey)
+}
+
+// execute runs a non-streaming request against the providers and caches its response.
+func (m *Manager) execute(ctx context.Context, normalized []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, cacheKey string) (cliproxyexecutor.Response, error) {
 	rotated := m.rotateProviders(req.Model, normalized)
 	defer m.advanceProviderCursor(req.Model, normalized)
 
-	var lastErr error
-	for _, provider := range rotated {
-		resp, errExec := m.executeWithProvider(ctx, provider, req, opts)
-		if errExec == nil {
-			return resp, nil
+	resp, err := withRetries(ctx, m, req.Model, func(ctx context.Context) (cliproxyexecutor.Response, error) {
+		var lastErr error
+		for _, provider := range rotated {
+			resp, errExec := m.executeWithProvider(ctx, provider, req, opts)
+			if errExec == nil {
+				return resp, nil
+			}
+			lastErr = errExec
+			if ctx.Err() != nil {
+				break
+			}
 		}
-		lastErr = errExec
-	}
-	if lastErr != nil {
-		return cliproxyexecutor.Response{}, lastErr
+		if lastErr != nil {
+			return cliproxyexecutor.Response{}, lastErr
+		}
+		return cliproxyexecutor.Response{}, &Error{Code: "auth_not_found", Message: "no auth available"}
+	})
+	if err == nil {
+		m.storeResponse(ctx, cacheKey, cachedResponse{Payload: resp.Payload})
 	}
-	return cliproxyexecutor.Response{}, &Error{Code: "auth_not_found", Message: "no auth available"}
+	return resp, err
 }
 
 // ExecuteCount performs a non-streaming execution using the configured selector and executor.
@@ -248,18 +361,124 @@ func (m *Manager) ExecuteCount(ctx context.Context, providers []string, req clip
 	rotated := m.rotateProviders(req.Model, normalized)
 	defer m.advanceProviderCursor(req.Model, normalized)
 
-	var lastErr error
-	for _, provider := range rotated {
-		resp, errExec := m.executeCountWithProvider(ctx, provider, req, opts)
-		if errExec == nil {
-			return resp, nil
+	return withRetries(ctx, m, req.Model, func(ctx context.Context) (cliproxyexecutor.Response, error) {
+		var lastErr error
+		for _, provider := range rotated {
+			resp, errExec := m.executeCountWithProvider(ctx, provider, req, opts)
+			if errExec == nil {
+				return resp, nil
+			}
+			lastErr = errExec
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		if lastErr != nil {
+			return cliproxyexecutor.Response{}, lastErr
+		}
+		return cliproxyexecutor.Response{}, &Error{Code: "auth_not_found", Message: "no auth available"}
+	})
+}
+
+// ExecuteEmbed performs an embeddings request using the configured selector and executor.
+// Providers whose executor does not implement EmbeddingExecutor are rejected as not supported.
+func (m *Manager) ExecuteEmbed(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return m.executeOptional(ctx, providers, req, opts, "embeddings", "provider.embed", func(execCtx context.Context, executor ProviderExecutor, auth *Auth) (cliproxyexecutor.Response, bool, error) {
+		embedder, ok := executor.(EmbeddingExecutor)
+		if !ok {
+			return cliproxyexecutor.Response{}, false, nil
+		}
+		resp, err := embedder.Embed(execCtx, auth, req, opts)
+		return resp, true, err
+	})
+}
+
+// ExecuteImages performs an image generation request using the configured selector and executor.
+// Providers whose executor does not implement ImageExecutor are rejected as not supported.
+func (m *Manager) ExecuteImages(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return m.executeOptional(ctx, providers, req, opts, "image generation", "provider.images", func(execCtx context.Context, executor ProviderExecutor, auth *Auth) (cliproxyexecutor.Response, bool, error) {
+		generator, ok := executor.(ImageExecutor)
+		if !ok {
+			return cliproxyexecutor.Response{}, false, nil
+		}
+		resp, err := generator.GenerateImages(execCtx, auth, req, opts)
+		return resp, true, err
+	})
+}
+
+// ExecuteTranscription performs an audio transcription request using the configured selector and executor.
+// Providers whose executor does not implement AudioExecutor are rejected as not supported.
+func (m *Manager) ExecuteTranscription(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return m.executeOptional(ctx, providers, req, opts, "audio transcription", "provider.transcribe", func(execCtx context.Context, executor ProviderExecutor, auth *Auth) (cliproxyexecutor.Response, bool, error) {
+		audio, ok := executor.(AudioExecutor)
+		if !ok {
+			return cliproxyexecutor.Response{}, false, nil
 		}
-		lastErr = errExec
+		resp, err := audio.Transcribe(execCtx, auth, req, opts)
+		return resp, true, err
+	})
+}
+
+// ExecuteSpeech performs a text-to-speech request and streams the synthesized audio.
+// Failover across auths only happens before the first audio chunk is returned.
+func (m *Manager) ExecuteSpeech(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (*cliproxyexecutor.BinaryStream, error) {
+	normalized := m.normalizeProviders(providers)
+	if len(normalized) == 0 {
+		return nil, &Error{Code: "provider_not_found", Message: "no provider supplied"}
 	}
-	if lastErr != nil {
-		return cliproxyexecutor.Response{}, lastErr
+	rotated := m.rotateProviders(req.Model, normalized)
+	defer m.advanceProviderCursor(req.Model, normalized)
+
+	return withRetries(ctx, m, req.Model, func(ctx context.Context) (*cliproxyexecutor.BinaryStream, error) {
+		var lastErr error
+		for _, provider := range rotated {
+			stream, errStream := m.executeSpeechWithProvider(ctx, provider, req, opts)
+			if errStream == nil {
+				return stream, nil
+			}
+			lastErr = errStream
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		if lastErr != nil {
+			return nil, lastErr
+		}
+		return nil, &Error{Code: "auth_not_found", Message: "no auth available"}
+	})
+}
+
+// optionalCall invokes an optional executor capability for one auth attempt.
+// ok is false when the executor does not implement the capability.
+type optionalCall func(ctx context.Context, executor ProviderExecutor, auth *Auth) (resp cliproxyexecutor.Response, ok bool, err error)
+
+// executeOptional runs a non-streaming request for a capability that only some executors offer,
+// rotating providers the same way Execute does.
+func (m *Manager) executeOptional(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, feature, spanName string, call optionalCall) (cliproxyexecutor.Response, error) {
+	normalized := m.normalizeProviders(providers)
+	if len(normalized) == 0 {
+		return cliproxyexecutor.Response{}, &Error{Code: "provider_not_found", Message: "no provider supplied"}
 	}
-	return cliproxyexecutor.Response{}, &Error{Code: "auth_not_found", Message: "no auth available"}
+	rotated := m.rotateProviders(req.Model, normalized)
+	defer m.advanceProviderCursor(req.Model, normalized)
+
+	return withRetries(ctx, m, req.Model, func(ctx context.Context) (cliproxyexecutor.Response, error) {
+		var lastErr error
+		for _, provider := range rotated {
+			resp, errExec := m.executeOptionalWithProvider(ctx, provider, req, opts, feature, spanName, call)
+			if errExec == nil {
+				return resp, nil
+			}
+			lastErr = errExec
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		if lastErr != nil {
+			return cliproxyexecutor.Response{}, lastErr
+		}
+		return cliproxyexecutor.Response{}, &Error{Code: "auth_not_found", Message: "no auth available"}
+	})
 }
 
 // ExecuteStream performs a streaming execution using the configured selector and executor.
@@ -269,21 +488,55 @@ func (m *Manager) ExecuteStream(ctx context.Context, providers []string, req cli
 	if len(normalized) == 0 {
 		return nil, &Error{Code: "provider_not_found", Message: "no provider supplied"}
 	}
+	cacheKey := m.responseCacheKey(ctx, req, opts)
+	if cached, ok := m.lookupResponse(ctx, cacheKey); ok && len(cached.Chunks) > 0 {
+		return replayStream(cached.Chunks), nil
+	}
+	if f, owner := m.joinFlight(ctx, req, opts); f != nil {
+		if owner {
+			return f.ownStream(ctx, func() (<-chan cliproxyexecutor.StreamChunk, error) {
+				return m.executeStream(ctx, normalized, req, opts, cacheKey)
+			})
+		}
+		log.Debugf("request dedup: attaching to the stream of an identical running request for model %s", req.Model)
+		if joined, err := f.waitStream(ctx); joined {
+			if err != nil {
+				return nil, err
+			}
+			return f.subscribe(ctx, func() (<-chan cliproxyexecutor.StreamChunk, error) {
+				return m.executeStream(ctx, normalized, req, opts, cacheKey)
+			}), nil
+		}
+	}
+	return m.executeStream(ctx, normalized, req, opts, cacheKey)
+}
+
+// executeStream opens the stream of a request against the providers and caches it once complete.
+func (m *Manager) executeStream(ctx context.Context, normalized []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, cacheKey string) (<-chan cliproxyexecutor.StreamChunk, error) {
 	rotated := m.rotateProviders(req.Model, normalized)
 	defer m.advanceProviderCursor(req.Model, normalized)
 
-	var lastErr error
-	for _, provider := range rotated {
-		chunks, errStream := m.executeStreamWithProvider(ctx, provider, req, opts)
-		if errStream == nil {
-			return chunks, nil
+	chunks, err := withRetries(ctx, m, req.Model, func(ctx context.Context) (<-chan cliproxyexecutor.StreamChunk, error) {
+		var lastErr error
+		for _, provider := range rotated {
+			chunks, errStream := m.executeStreamWithProvider(ctx, provider, req, opts)
+			if errStream == nil {
+				return chunks, nil
+			}
+			lastErr = errStream
+			if ctx.Err() != nil {
+				break
+			}
 		}
-		lastErr = errStream
-	}
-	if lastErr != nil {
-		return nil, lastErr
+		if lastErr != nil {
+			return nil, lastErr
+		}
+		return nil, &Error{Code: "auth_not_found", Message: "no auth available"}
+	})
+	if err != nil {
+		return nil, err
 	}
-	return nil, &Error{Code: "auth_not_found", Message: "no auth available"}
+	return m.captureStream(ctx, cacheKey, chunks), nil
 }
 
 func (m *Manager) executeWithProvider(ctx context.Context, provider string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
@@ -314,9 +567,17 @@ func (m *Manager) executeWithProvider(ctx context.Context, provider string, req
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
 			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
 		}
+		execCtx, span := startAttemptSpan(execCtx, "provider.execute", provider, auth, req.Model)
 		resp, errExec := executor.Execute(execCtx, auth, req, opts)
+		m.limits.release(auth.ID)
+		tracing.RecordError(span, errExec)
+		span.End()
 		result := Result{AuthID: auth.ID, Provider: provider, Model: req.Model, Success: errExec == nil}
 		if errExec != nil {
+			if ctx.Err() != nil {
+				// The caller went away; the failure says nothing about the account.
+				return cliproxyexecutor.Response{}, errExec
+			}
 			result.Error = &Error{Message: errExec.Error()}
 			var se cliproxyexecutor.StatusError
 			if errors.As(errExec, &se) && se != nil {
@@ -359,9 +620,67 @@ func (m *Manager) executeCountWithProvider(ctx context.Context, provider string,
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
 			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
 		}
+		execCtx, span := startAttemptSpan(execCtx, "provider.count_tokens", provider, auth, req.Model)
 		resp, errExec := executor.CountTokens(execCtx, auth, req, opts)
+		m.limits.release(auth.ID)
+		tracing.RecordError(span, errExec)
+		span.End()
+		result := Result{AuthID: auth.ID, Provider: provider, Model: req.Model, Success: errExec == nil}
+		if errExec != nil {
+			if ctx.Err() != nil {
+				// The caller went away; the failure says nothing about the account.
+				return cliproxyexecutor.Response{}, errExec
+			}
+			result.Error = &Error{Message: errExec.Error()}
+			var se cliproxyexecutor.StatusError
+			if errors.As(errExec, &se) && se != nil {
+				result.Error.HTTPStatus = se.StatusCode()
+			}
+			m.MarkResult(execCtx, result)
+			lastErr = errExec
+			continue
+		}
+		m.MarkResult(execCtx, result)
+		return resp, nil
+	}
+}
+
+func (m *Manager) executeOptionalWithProvider(ctx context.Context, provider string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, feature, spanName string, call optionalCall) (cliproxyexecutor.Response, error) {
+	if provider == "" {
+		return cliproxyexecutor.Response{}, &Error{Code: "provider_not_found", Message: "provider identifier is empty"}
+	}
+	tried := make(map[string]struct{})
+	var lastErr error
+	for {
+		auth, executor, errPick := m.pickNext(ctx, provider, req.Model, opts, tried)
+		if errPick != nil {
+			if lastErr != nil {
+				return cliproxyexecutor.Response{}, lastErr
+			}
+			return cliproxyexecutor.Response{}, errPick
+		}
+
+		tried[auth.ID] = struct{}{}
+		execCtx := ctx
+		if rt := m.roundTripperFor(auth); rt != nil {
+			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
+			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
+		}
+		execCtx, span := startAttemptSpan(execCtx, spanName, provider, auth, req.Model)
+		resp, supported, errExec := call(execCtx, executor, auth)
+		m.limits.release(auth.ID)
+		if !supported {
+			span.End()
+			return cliproxyexecutor.Response{}, &Error{Code: "not_supported", Message: "provider " + provider + " does not support " + feature, HTTPStatus: http.StatusBadRequest}
+		}
+		tracing.RecordError(span, errExec)
+		span.End()
 		result := Result{AuthID: auth.ID, Provider: provider, Model: req.Model, Success: errExec == nil}
 		if errExec != nil {
+			if ctx.Err() != nil {
+				// The caller went away; the failure says nothing about the account.
+				return cliproxyexecutor.Response{}, errExec
+			}
 			result.Error = &Error{Message: errExec.Error()}
 			var se cliproxyexecutor.StatusError
 			if errors.As(errExec, &se) && se != nil {
@@ -404,8 +723,16 @@ func (m *Manager) executeStreamWithProvider(ctx context.Context, provider string
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
 			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
 		}
+		execCtx, span := startAttemptSpan(execCtx, "provider.execute_stream", provider, auth, req.Model)
 		chunks, errStream := executor.ExecuteStream(execCtx, auth, req, opts)
 		if errStream != nil {
+			m.limits.release(auth.ID)
+			tracing.RecordError(span, errStream)
+			span.End()
+			if ctx.Err() != nil {
+				// The caller went away; the failure says nothing about the account.
+				return nil, errStream
+			}
 			rerr := &Error{Message: errStream.Error()}
 			var se cliproxyexecutor.StatusError
 			if errors.As(errStream, &se) && se != nil {
@@ -416,30 +743,113 @@ func (m *Manager) executeStreamWithProvider(ctx context.Context, provider string
 			lastErr = errStream
 			continue
 		}
-		out := make(chan cliproxyexecutor.StreamChunk)
-		go func(streamCtx context.Context, streamAuth *Auth, streamProvider string, streamChunks <-chan cliproxyexecutor.StreamChunk) {
-			defer close(out)
-			var failed bool
-			for chunk := range streamChunks {
-				if chunk.Err != nil && !failed {
-					failed = true
-					rerr := &Error{Message: chunk.Err.Error()}
-					var se cliproxyexecutor.StatusError
-					if errors.As(chunk.Err, &se) && se != nil {
-						rerr.HTTPStatus = se.StatusCode()
-					}
-					m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: req.Model, Success: false, Error: rerr})
-				}
-				out <- chunk
+		return m.trackStream(execCtx, auth.Clone(), provider, req.Model, chunks, span), nil
+	}
+}
+
+func (m *Manager) executeSpeechWithProvider(ctx context.Context, provider string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (*cliproxyexecutor.BinaryStream, error) {
+	if provider == "" {
+		return nil, &Error{Code: "provider_not_found", Message: "provider identifier is empty"}
+	}
+	tried := make(map[string]struct{})
+	var lastErr error
+	for {
+		auth, executor, errPick := m.pickNext(ctx, provider, req.Model, opts, tried)
+		if errPick != nil {
+			if lastErr != nil {
+				return nil, lastErr
+			}
+			return nil, errPick
+		}
+		audio, ok := executor.(AudioExecutor)
+		if !ok {
+			m.limits.release(auth.ID)
+			return nil, &Error{Code: "not_supported", Message: "provider " + provider + " does not support speech synthesis", HTTPStatus: http.StatusBadRequest}
+		}
+
+		tried[auth.ID] = struct{}{}
+		execCtx := ctx
+		if rt := m.roundTripperFor(auth); rt != nil {
+			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
+			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
+		}
+		execCtx, span := startAttemptSpan(execCtx, "provider.speech", provider, auth, req.Model)
+		stream, errStream := audio.Speech(execCtx, auth, req, opts)
+		if errStream != nil {
+			m.limits.release(auth.ID)
+			tracing.RecordError(span, errStream)
+			span.End()
+			if ctx.Err() != nil {
+				// The caller went away; the failure says nothing about the account.
+				return nil, errStream
 			}
-			if !failed {
-				m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: req.Model, Success: true})
+			rerr := &Error{Message: errStream.Error()}
+			var se cliproxyexecutor.StatusError
+			if errors.As(errStream, &se) && se != nil {
+				rerr.HTTPStatus = se.StatusCode()
 			}
-		}(execCtx, auth.Clone(), provider, chunks)
-		return out, nil
+			m.MarkResult(execCtx, Result{AuthID: auth.ID, Provider: provider, Model: req.Model, Success: false, Error: rerr})
+			lastErr = errStream
+			continue
+		}
+		return &cliproxyexecutor.BinaryStream{
+			ContentType: stream.ContentType,
+			Chunks:      m.trackStream(execCtx, auth.Clone(), provider, req.Model, stream.Chunks, span),
+		}, nil
 	}
 }
 
+// trackStream relays chunks from an upstream stream and records the attempt result
+// once the stream fails or completes. The auth's concurrency slot is held until the
+// upstream stream ends.
+func (m *Manager) trackStream(streamCtx context.Context, streamAuth *Auth, streamProvider, model string, streamChunks <-chan cliproxyexecutor.StreamChunk, streamSpan trace.Span) <-chan cliproxyexecutor.StreamChunk {
+	out := make(chan cliproxyexecutor.StreamChunk)
+	go func() {
+		defer close(out)
+		defer streamSpan.End()
+		defer m.limits.release(streamAuth.ID)
+		var failed bool
+		var received bool
+		for chunk := range streamChunks {
+			if !received {
+				received = true
+				streamSpan.AddEvent("first_chunk")
+			}
+			if chunk.Err != nil && !failed {
+				failed = true
+				tracing.RecordError(streamSpan, chunk.Err)
+				rerr := &Error{Message: chunk.Err.Error()}
+				var se cliproxyexecutor.StatusError
+				if errors.As(chunk.Err, &se) && se != nil {
+					rerr.HTTPStatus = se.StatusCode()
+				}
+				// A stream the consumer cancelled says nothing about the account.
+				if streamCtx.Err() == nil {
+					m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: model, Success: false, Error: rerr})
+				}
+			}
+			select {
+			case out <- chunk:
+			case <-streamCtx.Done():
+				// The consumer is gone; keep draining so the upstream stream ends and frees its slot.
+			}
+		}
+		if !failed && streamCtx.Err() == nil {
+			m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: model, Success: true})
+		}
+	}()
+	return out
+}
+
+// startAttemptSpan opens a span covering a single upstream attempt with the selected credential.
+func startAttemptSpan(ctx context.Context, name, provider string, auth *Auth, model string) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, name, trace.WithAttributes(
+		attribute.String("cliproxy.provider", provider),
+		attribute.String("cliproxy.auth_id", auth.ID),
+		attribute.String("cliproxy.model", model),
+	))
+}
+
 func (m *Manager) normalizeProviders(providers []string) []string {
 	if len(providers) == 0 {
 		return nil
@@ -506,15 +916,21 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 	suspendReason := ""
 	clearModelQuota := false
 	setModelQuota := false
+	var shared *SharedCooldown
 
 	m.mu.Lock()
+	cooldownStore := m.cooldownStore
 	if auth, ok := m.auths[result.AuthID]; ok && auth != nil {
 		now := time.Now()
 
 		if result.Success {
 			if result.Model != "" {
 				state := ensureModelState(auth, result.Model)
+				cooling := state.Unavailable || !state.NextRetryAfter.IsZero() || state.Quota.Exceeded
 				resetModelState(state, now)
+				if cooling {
+					shared = newSharedCooldown(result.AuthID, result.Model, state)
+				}
 				updateAggregatedAvailability(auth, now)
 				if !hasModelError(auth, now) {
 					auth.LastError = nil
@@ -578,6 +994,7 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 				auth.Status = StatusError
 				auth.UpdatedAt = now
 				updateAggregatedAvailability(auth, now)
+				shared = newSharedCooldown(result.AuthID, result.Model, state)
 			} else {
 				applyAuthFailureState(auth, result.Error, now)
 			}
@@ -598,6 +1015,11 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 	} else if shouldSuspendModel {
 		registry.GetGlobalRegistry().SuspendClientModel(result.AuthID, result.Model, suspendReason)
 	}
+	if shared != nil && cooldownStore != nil {
+		m.shareCooldown(ctx, cooldownStore, *shared)
+	}
+	m.recordHealth(result)
+	m.circuits.record(result.AuthID, result, time.Now())
 
 	m.hook.OnResult(ctx, result)
 }
@@ -840,34 +1262,137 @@ func (m *Manager) pickNext(ctx context.Context, provider, model string, opts cli
 		m.mu.RUnlock()
 		return nil, nil, &Error{Code: "executor_not_found", Message: "executor not registered"}
 	}
-	candidates := make([]*Auth, 0, len(m.auths))
-	for _, candidate := range m.auths {
-		if candidate.Provider != provider || candidate.Disabled {
+	now := time.Now()
+	stickyKey := m.sticky.key(provider, model, opts)
+	pinnedID, pinned := m.sticky.lookup(stickyKey, now)
+	// An auth pinned by the client replaces account selection and leaves sticky sessions alone.
+	requiredID, _ := opts.Metadata[cliproxyexecutor.PinnedAuthMetadataKey].(string)
+	if requiredID != "" {
+		stickyKey, pinned = "", false
+	}
+	requirements := requirementsFrom(opts)
+	// lostProbe holds auths whose half-open probe was claimed by a concurrent request.
+	var lostProbe map[string]struct{}
+	// queueDeadline bounds the wait for a free auth while every auth is at its concurrency limit.
+	var queueDeadline time.Time
+	var selected *Auth
+	for selected == nil {
+		// Taken before inspecting the auths so a slot released meanwhile is not missed.
+		freed := m.limits.waitChan()
+		candidates := make([]*Auth, 0, len(m.auths))
+		var unhealthy []*Auth
+		var busy []config.AccountConcurrency
+		circuitOpen := false
+		// unmet describes why the last auth whose model lacks a required feature was skipped.
+		unmet := ""
+		for _, candidate := range m.auths {
+			if candidate.Provider != provider || candidate.Disabled {
+				continue
+			}
+			if requiredID != "" && candidate.ID != requiredID {
+				continue
+			}
+			if _, used := tried[candidate.ID]; used {
+				continue
+			}
+			if reason := unmetRequirement(candidate.ID, model, requirements); reason != "" {
+				unmet = reason
+				continue
+			}
+			if _, lost := lostProbe[candidate.ID]; lost || !m.circuits.available(candidate.ID, now) {
+				circuitOpen = true
+				continue
+			}
+			if rule, full := m.limits.full(candidate); full {
+				busy = append(busy, rule)
+				continue
+			}
+			if m.health.excluded(candidate.ID, now) || m.proxies.down(candidate.ProxyURL) {
+				unhealthy = append(unhealthy, candidate)
+				continue
+			}
+			candidates = append(candidates, candidate)
+		}
+		// Fail open: when every remaining auth is excluded, trying one beats rejecting the request.
+		if len(candidates) == 0 {
+			candidates = unhealthy
+		}
+		if len(candidates) == 0 && len(busy) > 0 {
+			timeout, queue := busyWait(busy)
+			if !queue {
+				m.mu.RUnlock()
+				return nil, nil, &Error{Code: "account_busy", Message: "every account is at its concurrency limit", HTTPStatus: http.StatusTooManyRequests}
+			}
+			if queueDeadline.IsZero() {
+				queueDeadline = now.Add(timeout)
+			}
+			m.mu.RUnlock()
+			if errWait := waitForSlot(ctx, freed, queueDeadline); errWait != nil {
+				return nil, nil, errWait
+			}
+			m.mu.RLock()
+			now = time.Now()
 			continue
 		}
-		if _, used := tried[candidate.ID]; used {
+		if len(candidates) == 0 {
+			m.mu.RUnlock()
+			if circuitOpen {
+				return nil, nil, &Error{Code: "circuit_open", Message: "circuit open for every remaining auth", HTTPStatus: http.StatusServiceUnavailable}
+			}
+			if unmet != "" {
+				return nil, nil, capabilityError(provider, model, unmet)
+			}
+			return nil, nil, &Error{Code: "auth_not_found", Message: "no auth available"}
+		}
+		picked := pinnedCandidate(candidates, pinnedID, pinned, model, now)
+		if picked == nil {
+			var errPick error
+			picked, errPick = m.selector.Pick(ctx, provider, model, opts, candidates)
+			if errPick != nil {
+				m.mu.RUnlock()
+				return nil, nil, errPick
+			}
+			if picked == nil {
+				m.mu.RUnlock()
+				return nil, nil, &Error{Code: "auth_not_found", Message: "selector returned no auth"}
+			}
+		}
+		if !m.limits.acquire(picked) {
+			// A concurrent request took the last slot; the next pass sees the auth as busy.
+			pinned = false
 			continue
 		}
-		candidates = append(candidates, candidate)
-	}
-	if len(candidates) == 0 {
-		m.mu.RUnlock()
-		return nil, nil, &Error{Code: "auth_not_found", Message: "no auth available"}
-	}
-	selected, errPick := m.selector.Pick(ctx, provider, model, opts, candidates)
-	if errPick != nil {
-		m.mu.RUnlock()
-		return nil, nil, errPick
-	}
-	if selected == nil {
-		m.mu.RUnlock()
-		return nil, nil, &Error{Code: "auth_not_found", Message: "selector returned no auth"}
+		if !m.circuits.acquire(picked.ID, now) {
+			m.limits.release(picked.ID)
+			if lostProbe == nil {
+				lostProbe = make(map[string]struct{})
+			}
+			lostProbe[picked.ID] = struct{}{}
+			pinned = false
+			continue
+		}
+		selected = picked
 	}
+	m.sticky.bind(stickyKey, selected.ID, now)
 	authCopy := selected.Clone()
 	m.mu.RUnlock()
 	return authCopy, executor, nil
 }
 
+// waitForSlot blocks until freed is closed, the deadline passes or ctx is done.
+func waitForSlot(ctx context.Context, freed <-chan struct{}, deadline time.Time) error {
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case <-freed:
+		return nil
+	case <-timer.C:
+		return &Error{Code: "account_busy", Message: "timed out waiting for an account below its concurrency limit", HTTPStatus: http.StatusTooManyRequests}
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (m *Manager) persist(ctx context.Context, auth *Auth) error {
 	if m.store == nil || auth == nil {
 		return nil
@@ -991,6 +1516,9 @@ func (m *Manager) shouldRefresh(a *Auth, now time.Time) bool {
 	if lead == nil {
 		return false
 	}
+	if hasExpiry && !expiry.IsZero() && expiry.Sub(now) <= m.tokenRefreshConfig().Lead {
+		return true
+	}
 	if *lead <= 0 {
 		if hasExpiry && !expiry.IsZero() {
 			return now.After(expiry)
@@ -1187,15 +1715,37 @@ func (m *Manager) refreshAuth(ctx context.Context, id string) {
 	log.Debugf("refreshed %s, %s, %v", auth.Provider, auth.ID, err)
 	now := time.Now()
 	if err != nil {
+		cfg := m.tokenRefreshConfig()
 		m.mu.Lock()
+		m.refreshFailures[id]++
+		failures := m.refreshFailures[id]
+		next := now.Add(refreshBackoff(cfg, failures))
 		if current := m.auths[id]; current != nil {
-			current.NextRefreshAfter = now.Add(refreshFailureBackoff)
+			current.NextRefreshAfter = next
 			current.LastError = &Error{Message: err.Error()}
 			m.auths[id] = current
 		}
 		m.mu.Unlock()
+		log.Warnf("token refresh for %s (%s) failed (attempt %d), retrying at %s: %v", auth.ID, auth.Provider, failures, next.Format(time.RFC3339), err)
+		if failures == cfg.MaxAttempts {
+			m.reportRefreshFailure(ctx, RefreshFailure{
+				AuthID:        auth.ID,
+				Provider:      auth.Provider,
+				Label:         auth.Label,
+				Attempts:      failures,
+				Error:         err.Error(),
+				FailedAt:      now,
+				NextAttemptAt: next,
+			}, cfg.AlertWebhook)
+		}
 		return
 	}
+	m.mu.Lock()
+	if failures := m.refreshFailures[id]; failures > 0 {
+		delete(m.refreshFailures, id)
+		log.Infof("token refresh for %s (%s) succeeded after %d failed attempts", auth.ID, auth.Provider, failures)
+	}
+	m.mu.Unlock()
 	if updated == nil {
 		updated = cloned
 	}
@@ -1264,3 +1814,29 @@ func (m *Manager) InjectCredentials(req *http.Request, authID string) error {
 	}
 	return nil
 }
+
+// ListModels queries the upstream of the given auth for its available models. It returns
+// nil without an error when the auth is unknown, disabled or its executor does not
+// implement ModelLister.
+func (m *Manager) ListModels(ctx context.Context, authID string) ([]*registry.ModelInfo, error) {
+	m.mu.RLock()
+	a := m.auths[authID]
+	var exec ProviderExecutor
+	if a != nil {
+		exec = m.executors[a.Provider]
+		a = a.Clone()
+	}
+	m.mu.RUnlock()
+	if a == nil || a.Disabled || exec == nil {
+		return nil, nil
+	}
+	lister, ok := exec.(ModelLister)
+	if !ok {
+		return nil, nil
+	}
+	if rt := m.roundTripperFor(a); rt != nil {
+		ctx = context.WithValue(ctx, roundTripperContextKey{}, rt)
+		ctx = context.WithValue(ctx, "cliproxy.roundtripper", rt)
+	}
+	return lister.ListModels(ctx, a)
+}
@@ -0,0 +1,292 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultProxyCheckURL       = "https://www.gstatic.com/generate_204"
+	defaultProxyCheckInterval  = time.Minute
+	defaultProxyCheckTimeout   = 10 * time.Second
+	defaultProxyCheckThreshold = 2
+	// proxyCheckTick is how often the checker looks for proxies that are due for a probe.
+	proxyCheckTick = 5 * time.Second
+)
+
+// ProxyHealth is the health view of one outbound proxy and the accounts that use it.
+type ProxyHealth struct {
+	// Proxy is the proxy URL with its password redacted.
+	Proxy               string     `json:"proxy"`
+	Healthy             bool       `json:"healthy"`
+	Accounts            []string   `json:"accounts"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LatencyMS           int64      `json:"latency_ms,omitempty"`
+	CheckedAt           *time.Time `json:"checked_at,omitempty"`
+	LastSuccessAt       *time.Time `json:"last_success_at,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+}
+
+// proxyState tracks the probes of one proxy URL.
+type proxyState struct {
+	consecutive int
+	latency     time.Duration
+	checkedAt   time.Time
+	lastSuccess time.Time
+	lastError   string
+}
+
+// proxyChecker implements active health checking of the proxies assigned to accounts:
+// every distinct proxy is probed periodically and accounts behind a proxy that keeps
+// failing are excluded from selection.
+type proxyChecker struct {
+	mu      sync.Mutex
+	cfg     config.ProxyCheck
+	proxies map[string]*proxyState
+	cancel  context.CancelFunc
+}
+
+func newProxyChecker() *proxyChecker {
+	return &proxyChecker{proxies: make(map[string]*proxyState)}
+}
+
+func (c *proxyChecker) setConfig(cfg config.ProxyCheck) {
+	cfg.URL = strings.TrimSpace(cfg.URL)
+	if cfg.URL == "" {
+		cfg.URL = defaultProxyCheckURL
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultProxyCheckInterval
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultProxyCheckTimeout
+	}
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = defaultProxyCheckThreshold
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !cfg.Enable || cfg.URL != c.cfg.URL {
+		c.proxies = make(map[string]*proxyState)
+	}
+	c.cfg = cfg
+}
+
+// down reports whether the proxy failed enough consecutive probes to be out of rotation.
+func (c *proxyChecker) down(proxyURL string) bool {
+	proxyURL = strings.TrimSpace(proxyURL)
+	if proxyURL == "" {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.cfg.Enable {
+		return false
+	}
+	state, ok := c.proxies[proxyURL]
+	return ok && state.consecutive >= c.cfg.FailureThreshold
+}
+
+// due returns the proxies whose last probe is older than the interval and forgets the
+// proxies no account uses anymore.
+func (c *proxyChecker) due(inUse map[string]*Auth, now time.Time) (config.ProxyCheck, []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cfg := c.cfg
+	if !cfg.Enable {
+		return cfg, nil
+	}
+	for proxyURL := range c.proxies {
+		if _, ok := inUse[proxyURL]; !ok {
+			delete(c.proxies, proxyURL)
+		}
+	}
+	var due []string
+	for proxyURL := range inUse {
+		state, ok := c.proxies[proxyURL]
+		if !ok || now.Sub(state.checkedAt) >= cfg.Interval {
+			due = append(due, proxyURL)
+		}
+	}
+	return cfg, due
+}
+
+// record stores a probe result. It reports when the proxy went down or came back up.
+func (c *proxyChecker) record(proxyURL string, latency time.Duration, err error, now time.Time) (changed, healthy bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	state, ok := c.proxies[proxyURL]
+	if !ok {
+		state = &proxyState{}
+		c.proxies[proxyURL] = state
+	}
+	wasDown := state.consecutive >= c.cfg.FailureThreshold
+	state.checkedAt = now
+	state.latency = latency
+	if err != nil {
+		state.consecutive++
+		state.lastError = err.Error()
+	} else {
+		state.consecutive = 0
+		state.lastSuccess = now
+		state.lastError = ""
+	}
+	isDown := state.consecutive >= c.cfg.FailureThreshold
+	return wasDown != isDown, !isDown
+}
+
+func (c *proxyChecker) snapshot(auths []*Auth) []ProxyHealth {
+	accounts := make(map[string][]string)
+	for _, auth := range auths {
+		if proxyURL := strings.TrimSpace(auth.ProxyURL); proxyURL != "" {
+			accounts[proxyURL] = append(accounts[proxyURL], auth.ID)
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]ProxyHealth, 0, len(accounts))
+	for proxyURL, ids := range accounts {
+		sort.Strings(ids)
+		view := ProxyHealth{Proxy: RedactProxyURL(proxyURL), Healthy: true, Accounts: ids}
+		if state, ok := c.proxies[proxyURL]; ok && c.cfg.Enable {
+			view.Healthy = state.consecutive < c.cfg.FailureThreshold
+			view.ConsecutiveFailures = state.consecutive
+			view.LatencyMS = state.latency.Milliseconds()
+			view.LastError = state.lastError
+			checked := state.checkedAt
+			view.CheckedAt = &checked
+			if !state.lastSuccess.IsZero() {
+				at := state.lastSuccess
+				view.LastSuccessAt = &at
+			}
+		}
+		out = append(out, view)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Proxy < out[j].Proxy })
+	return out
+}
+
+// RedactProxyURL masks the password of a proxy URL for display.
+func RedactProxyURL(proxyURL string) string {
+	parsed, err := url.Parse(strings.TrimSpace(proxyURL))
+	if err != nil {
+		return "<invalid proxy url>"
+	}
+	return parsed.Redacted()
+}
+
+// SetProxyCheckConfig applies the proxy health check configuration.
+func (m *Manager) SetProxyCheckConfig(cfg config.ProxyCheck) {
+	m.proxies.setConfig(cfg)
+}
+
+// ProxyHealthSnapshot returns the health state of every proxy assigned to an account.
+func (m *Manager) ProxyHealthSnapshot() []ProxyHealth {
+	return m.proxies.snapshot(m.List())
+}
+
+// StartProxyChecks launches the background loop that probes account proxies while
+// proxy checks are enabled. Starting a new loop cancels the previous one.
+func (m *Manager) StartProxyChecks(parent context.Context) {
+	m.StopProxyChecks()
+	ctx, cancel := context.WithCancel(parent)
+	m.proxies.mu.Lock()
+	m.proxies.cancel = cancel
+	m.proxies.mu.Unlock()
+	go func() {
+		ticker := time.NewTicker(proxyCheckTick)
+		defer ticker.Stop()
+		for {
+			m.checkProxies(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// StopProxyChecks cancels the proxy check loop, if running.
+func (m *Manager) StopProxyChecks() {
+	m.proxies.mu.Lock()
+	cancel := m.proxies.cancel
+	m.proxies.cancel = nil
+	m.proxies.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// checkProxies probes every proxy that is due, once per proxy regardless of how many
+// accounts share it.
+func (m *Manager) checkProxies(ctx context.Context) {
+	inUse := make(map[string]*Auth)
+	for _, auth := range m.snapshotAuths() {
+		if auth.Disabled {
+			continue
+		}
+		if proxyURL := strings.TrimSpace(auth.ProxyURL); proxyURL != "" {
+			if _, ok := inUse[proxyURL]; !ok {
+				inUse[proxyURL] = auth
+			}
+		}
+	}
+	cfg, due := m.proxies.due(inUse, time.Now())
+	var wg sync.WaitGroup
+	for _, proxyURL := range due {
+		wg.Add(1)
+		go func(proxyURL string, auth *Auth) {
+			defer wg.Done()
+			start := time.Now()
+			err := m.probeProxy(ctx, cfg, auth)
+			if ctx.Err() != nil {
+				return
+			}
+			changed, healthy := m.proxies.record(proxyURL, time.Since(start), err, time.Now())
+			if !changed {
+				return
+			}
+			if healthy {
+				log.Infof("proxy %s is reachable again", RedactProxyURL(proxyURL))
+			} else {
+				log.Warnf("proxy %s is down, excluding its accounts from rotation: %v", RedactProxyURL(proxyURL), err)
+			}
+		}(proxyURL, inUse[proxyURL])
+	}
+	wg.Wait()
+}
+
+// probeProxy requests the check URL through the transport the account would use.
+func (m *Manager) probeProxy(ctx context.Context, cfg config.ProxyCheck, auth *Auth) error {
+	rt := m.roundTripperFor(auth)
+	if rt == nil {
+		return errors.New("unsupported proxy url")
+	}
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := (&http.Client{Transport: rt}).Do(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	_ = resp.Body.Close()
+	if resp.StatusCode == http.StatusProxyAuthRequired {
+		return errors.New("proxy authentication failed")
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultRefreshLead        = 5 * time.Minute
+	defaultRefreshRetryBase   = 30 * time.Second
+	defaultRefreshRetryMax    = 30 * time.Minute
+	defaultRefreshMaxAttempts = 5
+	refreshAlertTimeout       = 10 * time.Second
+)
+
+// RefreshFailure describes a token refresh that has failed MaxAttempts times in a row.
+// Refreshing continues at the maximum retry delay, so the account recovers on its own if
+// the cause was transient.
+type RefreshFailure struct {
+	AuthID        string    `json:"auth_id"`
+	Provider      string    `json:"provider"`
+	Label         string    `json:"label,omitempty"`
+	Attempts      int       `json:"attempts"`
+	Error         string    `json:"error"`
+	FailedAt      time.Time `json:"failed_at"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+}
+
+// RefreshFailureHook is an optional extension of Hook notified when a token refresh
+// permanently fails.
+type RefreshFailureHook interface {
+	OnRefreshFailed(ctx context.Context, failure RefreshFailure)
+}
+
+// SetTokenRefreshConfig configures the refresh lead time, retry backoff and failure alerting.
+// Alerts are posted through proxyURL when it is set.
+func (m *Manager) SetTokenRefreshConfig(cfg config.TokenRefresh, proxyURL string) {
+	if cfg.Lead <= 0 {
+		cfg.Lead = defaultRefreshLead
+	}
+	if cfg.RetryBase <= 0 {
+		cfg.RetryBase = defaultRefreshRetryBase
+	}
+	if cfg.RetryMax <= 0 {
+		cfg.RetryMax = defaultRefreshRetryMax
+	}
+	if cfg.RetryMax < cfg.RetryBase {
+		cfg.RetryMax = cfg.RetryBase
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultRefreshMaxAttempts
+	}
+	m.refreshCfg.Store(&cfg)
+	m.refreshAlertClient.Store(newRefreshAlertClient(proxyURL))
+}
+
+// newRefreshAlertClient returns the client posting refresh alerts, bounded by
+// refreshAlertTimeout and routed through proxyURL when it is set.
+func newRefreshAlertClient(proxyURL string) *http.Client {
+	return util.SetProxy(&sdkconfig.SDKConfig{ProxyURL: proxyURL}, &http.Client{Timeout: refreshAlertTimeout})
+}
+
+// tokenRefreshConfig returns the refresh configuration, with defaults when none was set.
+func (m *Manager) tokenRefreshConfig() config.TokenRefresh {
+	if cfg := m.refreshCfg.Load(); cfg != nil {
+		return *cfg
+	}
+	return config.TokenRefresh{
+		Lead:        defaultRefreshLead,
+		RetryBase:   defaultRefreshRetryBase,
+		RetryMax:    defaultRefreshRetryMax,
+		MaxAttempts: defaultRefreshMaxAttempts,
+	}
+}
+
+// refreshBackoff is the delay before the next refresh after failures consecutive failures.
+func refreshBackoff(cfg config.TokenRefresh, failures int) time.Duration {
+	delay := cfg.RetryBase
+	for i := 1; i < failures && delay < cfg.RetryMax; i++ {
+		delay *= 2
+	}
+	if delay > cfg.RetryMax {
+		delay = cfg.RetryMax
+	}
+	return delay
+}
+
+// reportRefreshFailure logs a permanently failed refresh and notifies the hook and webhook.
+func (m *Manager) reportRefreshFailure(ctx context.Context, failure RefreshFailure, webhook string) {
+	log.Errorf("token refresh for %s (%s) failed %d times in a row, retrying at %s: %s",
+		failure.AuthID, failure.Provider, failure.Attempts, failure.NextAttemptAt.Format(time.RFC3339), failure.Error)
+	if hook, ok := m.hook.(RefreshFailureHook); ok {
+		hook.OnRefreshFailed(ctx, failure)
+	}
+	if webhook != "" {
+		client := m.refreshAlertClient.Load()
+		if client == nil {
+			client = newRefreshAlertClient("")
+		}
+		go postRefreshAlert(client, webhook, failure)
+	}
+}
+
+// postRefreshAlert sends failure to webhook as a JSON event.
+func postRefreshAlert(client *http.Client, webhook string, failure RefreshFailure) {
+	payload, err := json.Marshal(struct {
+		Event string `json:"event"`
+		RefreshFailure
+	}{Event: "token_refresh_failed", RefreshFailure: failure})
+	if err != nil {
+		log.Errorf("token refresh alert: encode payload: %v", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), refreshAlertTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(payload))
+	if err != nil {
+		log.Errorf("token refresh alert: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err == nil {
+		_ = resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			err = fmt.Errorf("unexpected status %d", resp.StatusCode)
+		}
+	}
+	if err != nil {
+		log.Warnf("token refresh alert: webhook delivery failed: %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	defaultResponseCacheTTL      = 10 * time.Minute
+	defaultResponseCacheMaxBytes = 1 << 20
+)
+
+// responseTemperaturePaths lists where the supported request formats carry the sampling temperature.
+var responseTemperaturePaths = []string{"temperature", "generationConfig.temperature", "generation_config.temperature", "request.generationConfig.temperature"}
+
+// ResponseCache stores the responses of deterministic requests.
+// Implementations must be safe for concurrent use; failures are reported as misses.
+type ResponseCache interface {
+	Get(ctx context.Context, key string) ([]byte, bool)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration)
+}
+
+// ResponseCacheStats counts response cache lookups.
+type ResponseCacheStats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+}
+
+// responseCacheState is the active cache with its limits.
+type responseCacheState struct {
+	cache    ResponseCache
+	ttl      time.Duration
+	maxBytes int
+}
+
+// cachedResponse is the stored form of a response: a payload for non-streaming requests,
+// the chunk payloads for streaming ones.
+type cachedResponse struct {
+	Payload []byte   `json:"payload,omitempty"`
+	Chunks  [][]byte `json:"chunks,omitempty"`
+}
+
+// responseCacheCounters is shared by all caches installed on a manager.
+type responseCacheCounters struct {
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// SetResponseCache installs the response cache; a nil cache or disabled config turns caching off.
+func (m *Manager) SetResponseCache(cache ResponseCache, cfg config.ResponseCache) {
+	if cache == nil || !cfg.Enable {
+		m.responseCache.Store(nil)
+		return
+	}
+	state := &responseCacheState{cache: cache, ttl: cfg.TTL, maxBytes: cfg.MaxEntryBytes}
+	if state.ttl <= 0 {
+		state.ttl = defaultResponseCacheTTL
+	}
+	if state.maxBytes <= 0 {
+		state.maxBytes = defaultResponseCacheMaxBytes
+	}
+	m.responseCache.Store(state)
+}
+
+// ResponseCacheStats returns the response cache hit and miss counts, and false when caching is disabled.
+func (m *Manager) ResponseCacheStats() (ResponseCacheStats, bool) {
+	stats := ResponseCacheStats{Hits: m.cacheCounters.hits.Load(), Misses: m.cacheCounters.misses.Load()}
+	return stats, m.responseCache.Load() != nil
+}
+
+// responseCacheKey returns the cache key of a request, or "" when it must not be cached.
+// Only requests with an explicit temperature of 0 qualify; the body is normalized by
+// re-encoding it with sorted keys and canonical numbers so formatting differences do not matter.
+// Entries are scoped to the client API key and project, so a response is never served to
+// another client.
+func (m *Manager) responseCacheKey(ctx context.Context, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) string {
+	if m.responseCache.Load() == nil || len(opts.OriginalRequest) == 0 {
+		return ""
+	}
+	// Requests pinned to an account are meant to reach it.
+	if pinned, _ := opts.Metadata[cliproxyexecutor.PinnedAuthMetadataKey].(string); pinned != "" {
+		return ""
+	}
+	deterministic := false
+	for _, path := range responseTemperaturePaths {
+		if temperature := gjson.GetBytes(opts.OriginalRequest, path); temperature.Exists() {
+			deterministic = temperature.Type == gjson.Number && temperature.Float() == 0
+			break
+		}
+	}
+	if !deterministic {
+		return ""
+	}
+	return requestDigest(req, opts, cliproxyexecutor.APIKey(ctx), cliproxyexecutor.Project(ctx))
+}
+
+// requestDigest hashes the model, format and normalized body of a request together with
+// extra, or returns "" when the body is not JSON.
+func requestDigest(req cliproxyexecutor.Request, opts cliproxyexecutor.Options, extra ...string) string {
+	var body any
+	if err := json.Unmarshal(opts.OriginalRequest, &body); err != nil {
+		return ""
+	}
+	normalized, err := json.Marshal(body)
+	if err != nil {
+		return ""
+	}
+	hash := sha256.New()
+	for _, part := range append([]string{req.Model, opts.SourceFormat.String(), strconv.FormatBool(opts.Stream), opts.Alt}, extra...) {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	hash.Write(normalized)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// lookupResponse returns the cached response for key.
+func (m *Manager) lookupResponse(ctx context.Context, key string) (cachedResponse, bool) {
+	state := m.responseCache.Load()
+	if key == "" || state == nil {
+		return cachedResponse{}, false
+	}
+	raw, ok := state.cache.Get(ctx, key)
+	var cached cachedResponse
+	if ok && json.Unmarshal(raw, &cached) == nil && (len(cached.Payload) > 0 || len(cached.Chunks) > 0) {
+		m.cacheCounters.hits.Add(1)
+		return cached, true
+	}
+	m.cacheCounters.misses.Add(1)
+	return cachedResponse{}, false
+}
+
+// storeResponse caches a response unless it exceeds the size limit.
+func (m *Manager) storeResponse(ctx context.Context, key string, cached cachedResponse) {
+	state := m.responseCache.Load()
+	if key == "" || state == nil {
+		return
+	}
+	raw, err := json.Marshal(cached)
+	if err != nil || len(raw) > state.maxBytes {
+		return
+	}
+	state.cache.Set(context.WithoutCancel(ctx), key, raw, state.ttl)
+}
+
+// replayStream emits cached chunk payloads as a stream.
+func replayStream(chunks [][]byte) <-chan cliproxyexecutor.StreamChunk {
+	out := make(chan cliproxyexecutor.StreamChunk, len(chunks))
+	for _, payload := range chunks {
+		out <- cliproxyexecutor.StreamChunk{Payload: payload}
+	}
+	close(out)
+	return out
+}
+
+// captureStream forwards a stream while recording its payloads, and caches them once the stream
+// completed without error. Streams cut short by the client or exceeding the size limit are not cached.
+func (m *Manager) captureStream(ctx context.Context, key string, chunks <-chan cliproxyexecutor.StreamChunk) <-chan cliproxyexecutor.StreamChunk {
+	state := m.responseCache.Load()
+	if key == "" || state == nil {
+		return chunks
+	}
+	out := make(chan cliproxyexecutor.StreamChunk)
+	go func() {
+		defer close(out)
+		var recorded [][]byte
+		size := 0
+		complete := true
+		for chunk := range chunks {
+			if chunk.Err != nil {
+				complete = false
+			} else if complete {
+				size += len(chunk.Payload)
+				if size > state.maxBytes {
+					complete = false
+					recorded = nil
+				} else {
+					recorded = append(recorded, bytes.Clone(chunk.Payload))
+				}
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				// Drain so the producer can finish.
+				for range chunks {
+				}
+				return
+			}
+		}
+		if complete && ctx.Err() == nil && len(recorded) > 0 {
+			m.storeResponse(ctx, key, cachedResponse{Chunks: recorded})
+		}
+	}()
+	return out
+}
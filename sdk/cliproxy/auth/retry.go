@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultRetryBackoff       = 500 * time.Millisecond
+	defaultRetryMaxBackoff    = 10 * time.Second
+	defaultRetryMaxRetryAfter = 30 * time.Second
+)
+
+// defaultRetryOn mirrors the status codes documented for request-retry.
+var defaultRetryOn = []int{403, 408, 500, 502, 503, 504}
+
+// retryPolicy is the resolved retry configuration.
+type retryPolicy struct {
+	retries       int
+	backoff       time.Duration
+	maxBackoff    time.Duration
+	maxRetryAfter time.Duration
+	retryOn       map[int]struct{}
+}
+
+func newRetryPolicy(retries int, cfg config.RetryPolicy) *retryPolicy {
+	policy := &retryPolicy{
+		retries:       max(retries, 0),
+		backoff:       cfg.Backoff,
+		maxBackoff:    cfg.MaxBackoff,
+		maxRetryAfter: cfg.MaxRetryAfter,
+	}
+	if policy.backoff <= 0 {
+		policy.backoff = defaultRetryBackoff
+	}
+	if policy.maxBackoff < policy.backoff {
+		policy.maxBackoff = max(defaultRetryMaxBackoff, policy.backoff)
+	}
+	if policy.maxRetryAfter <= 0 {
+		policy.maxRetryAfter = defaultRetryMaxRetryAfter
+	}
+	codes := cfg.RetryOn
+	if len(codes) == 0 {
+		codes = defaultRetryOn
+	}
+	policy.retryOn = make(map[int]struct{}, len(codes))
+	for _, code := range codes {
+		policy.retryOn[code] = struct{}{}
+	}
+	return policy
+}
+
+// delay returns how long to wait before retry number attempt (1-based) after err,
+// or false when err must not be retried.
+func (p *retryPolicy) delay(err error, attempt int) (time.Duration, bool) {
+	if p == nil || attempt > p.retries || err == nil {
+		return 0, false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return 0, false
+	}
+	// Errors raised by the manager itself (no auth, circuit open, unsupported) are not upstream flakes.
+	var managerErr *Error
+	if errors.As(err, &managerErr) {
+		return 0, false
+	}
+	var statusErr cliproxyexecutor.StatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode() > 0 {
+		if _, ok := p.retryOn[statusErr.StatusCode()]; !ok {
+			return 0, false
+		}
+	}
+
+	backoff := p.backoff << (attempt - 1)
+	if backoff <= 0 || backoff > p.maxBackoff {
+		backoff = p.maxBackoff
+	}
+	// Equal jitter: keep half of the backoff and randomise the rest.
+	wait := backoff/2 + rand.N(backoff/2+1)
+
+	var retryAfterErr cliproxyexecutor.RetryAfterError
+	if errors.As(err, &retryAfterErr) {
+		if hint := retryAfterErr.RetryAfter(); hint > 0 {
+			if hint > p.maxRetryAfter {
+				return 0, false
+			}
+			wait = max(wait, hint)
+		}
+	}
+	return wait, true
+}
+
+// SetRetryPolicy configures request retries: retries is the number of additional attempts
+// (request-retry) and cfg controls the backoff between them.
+func (m *Manager) SetRetryPolicy(retries int, cfg config.RetryPolicy) {
+	m.retry.Store(newRetryPolicy(retries, cfg))
+}
+
+// withRetries runs call and repeats it according to the retry policy while it fails with a
+// retryable error. Retry attempts are tagged on the context so usage records can attribute them.
+func withRetries[T any](ctx context.Context, m *Manager, model string, call func(ctx context.Context) (T, error)) (T, error) {
+	policy := m.retry.Load()
+	result, err := call(ctx)
+	for attempt := 1; err != nil; attempt++ {
+		wait, ok := policy.delay(err, attempt)
+		if !ok {
+			break
+		}
+		log.Debugf("retrying request for model %s in %s (attempt %d/%d): %v", model, wait, attempt, policy.retries, err)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return result, err
+		case <-timer.C:
+		}
+		result, err = call(cliproxyexecutor.WithRetryAttempt(ctx, attempt))
+	}
+	return result, err
+}
@@ -0,0 +1,240 @@
+package auth
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// Routing strategies supported by PolicySelector.
+const (
+	RoutingRoundRobin         = "round-robin"
+	RoutingWeighted           = "weighted"
+	RoutingLeastRecentlyUsed  = "least-recently-used"
+	routingPolicyAnyProvider  = "*"
+	defaultAccountRouteWeight = 1
+)
+
+// RoutingPolicyReceiver is implemented by selectors whose behaviour follows the routing configuration.
+type RoutingPolicyReceiver interface {
+	SetRoutingPolicies(policies []config.RoutingPolicy)
+}
+
+// ProjectRoutingReceiver is implemented by selectors that apply per-project routing policies.
+type ProjectRoutingReceiver interface {
+	SetProjectRoutingPolicies(projects []config.Project)
+}
+
+// PolicySelector selects auths according to per-provider routing policies.
+// Available auths are grouped into priority tiers and only the lowest tier with an available
+// auth is considered; the policy strategy then picks within that tier.
+// Providers without a policy behave exactly like RoundRobinSelector.
+type PolicySelector struct {
+	roundRobin RoundRobinSelector
+
+	mu       sync.Mutex
+	policies map[string]config.RoutingPolicy
+	// projectPolicies holds the per-provider policies of projects that override the global ones.
+	projectPolicies map[string]map[string]config.RoutingPolicy
+	// current holds the smooth weighted round-robin state per provider:model and auth ID.
+	current map[string]map[string]int
+	// lastUsed records when each auth was last picked, for least-recently-used selection.
+	lastUsed map[string]time.Time
+}
+
+// NewPolicySelector constructs a selector applying the given routing policies.
+func NewPolicySelector(policies []config.RoutingPolicy) *PolicySelector {
+	s := &PolicySelector{
+		current:  make(map[string]map[string]int),
+		lastUsed: make(map[string]time.Time),
+	}
+	s.SetRoutingPolicies(policies)
+	return s
+}
+
+// SetRoutingPolicies replaces the routing policies, e.g. after a config reload.
+func (s *PolicySelector) SetRoutingPolicies(policies []config.RoutingPolicy) {
+	byProvider := policiesByProvider(policies)
+	s.mu.Lock()
+	s.policies = byProvider
+	s.current = make(map[string]map[string]int)
+	s.mu.Unlock()
+}
+
+// SetProjectRoutingPolicies replaces the routing policies of projects. Requests of a project
+// use its policy for a provider when it has one and the global policies otherwise.
+func (s *PolicySelector) SetProjectRoutingPolicies(projects []config.Project) {
+	byProject := make(map[string]map[string]config.RoutingPolicy, len(projects))
+	for _, project := range projects {
+		name := strings.TrimSpace(project.Name)
+		if name == "" || len(project.Routing) == 0 {
+			continue
+		}
+		byProject[name] = policiesByProvider(project.Routing)
+	}
+	s.mu.Lock()
+	s.projectPolicies = byProject
+	s.current = make(map[string]map[string]int)
+	s.mu.Unlock()
+}
+
+func policiesByProvider(policies []config.RoutingPolicy) map[string]config.RoutingPolicy {
+	byProvider := make(map[string]config.RoutingPolicy, len(policies))
+	for _, policy := range policies {
+		key := strings.ToLower(strings.TrimSpace(policy.Provider))
+		if key == "" {
+			continue
+		}
+		byProvider[key] = policy
+	}
+	return byProvider
+}
+
+// Pick implements Selector.
+func (s *PolicySelector) Pick(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, auths []*Auth) (*Auth, error) {
+	_ = opts
+	now := time.Now()
+	available, err := availableAuths(provider, model, auths, now, cliproxyexecutor.RetryAttempt(ctx) > 0)
+	if err != nil {
+		return nil, err
+	}
+	key := provider + ":" + model
+
+	s.mu.Lock()
+	policy, ok := lookupRoutingPolicy(s.policies, provider)
+	if project := cliproxyexecutor.Project(ctx); project != "" {
+		if projectPolicy, found := lookupRoutingPolicy(s.projectPolicies[project], provider); found {
+			policy, ok = projectPolicy, true
+			// Keep the weighted state of the project apart from the global one.
+			key = project + "/" + key
+		}
+	}
+	s.mu.Unlock()
+	if !ok {
+		return s.roundRobin.next(key, available), nil
+	}
+
+	tier, weights := bestRoutingTier(policy, available)
+	switch strings.ToLower(strings.TrimSpace(policy.Strategy)) {
+	case RoutingWeighted:
+		return s.pickWeighted(key, tier, weights), nil
+	case RoutingLeastRecentlyUsed:
+		return s.pickLeastRecentlyUsed(tier, now), nil
+	default:
+		return s.roundRobin.next(key, tier), nil
+	}
+}
+
+func lookupRoutingPolicy(policies map[string]config.RoutingPolicy, provider string) (config.RoutingPolicy, bool) {
+	policy, ok := policies[strings.ToLower(provider)]
+	if !ok {
+		policy, ok = policies[routingPolicyAnyProvider]
+	}
+	return policy, ok
+}
+
+// pickWeighted implements smooth weighted round-robin: every candidate gains its weight,
+// the one with the highest running total is picked and pays back the total weight.
+func (s *PolicySelector) pickWeighted(key string, tier []*Auth, weights []int) *Auth {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.current[key]
+	if !ok {
+		state = make(map[string]int)
+		s.current[key] = state
+	}
+	total := 0
+	best := -1
+	for i, auth := range tier {
+		state[auth.ID] += weights[i]
+		total += weights[i]
+		if best < 0 || state[auth.ID] > state[tier[best].ID] {
+			best = i
+		}
+	}
+	state[tier[best].ID] -= total
+	return tier[best]
+}
+
+func (s *PolicySelector) pickLeastRecentlyUsed(tier []*Auth, now time.Time) *Auth {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	best := tier[0]
+	for _, auth := range tier[1:] {
+		if s.lastUsed[auth.ID].Before(s.lastUsed[best.ID]) {
+			best = auth
+		}
+	}
+	s.lastUsed[best.ID] = now
+	return best
+}
+
+// bestRoutingTier returns the available auths of the lowest priority tier with their weights.
+// available must be sorted by ID; the order is preserved.
+func bestRoutingTier(policy config.RoutingPolicy, available []*Auth) ([]*Auth, []int) {
+	var tier []*Auth
+	var weights []int
+	bestPriority := 0
+	for _, auth := range available {
+		priority, weight := accountRoute(policy, auth)
+		if len(tier) == 0 || priority < bestPriority {
+			bestPriority = priority
+			tier = tier[:0]
+			weights = weights[:0]
+		} else if priority > bestPriority {
+			continue
+		}
+		tier = append(tier, auth)
+		weights = append(weights, weight)
+	}
+	return tier, weights
+}
+
+// accountRoute returns the priority and weight of the first route matching auth.
+func accountRoute(policy config.RoutingPolicy, auth *Auth) (int, int) {
+	for _, route := range policy.Accounts {
+		if !MatchAccount(route.Match, auth) {
+			continue
+		}
+		weight := route.Weight
+		if weight <= 0 {
+			weight = defaultAccountRouteWeight
+		}
+		return route.Priority, weight
+	}
+	return 0, defaultAccountRouteWeight
+}
+
+// MatchAccount reports whether pattern selects auth by its ID, label, email or auth file
+// name. Exact names compare case-insensitively; a trailing "*" matches by prefix.
+func MatchAccount(pattern string, auth *Auth) bool {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" || auth == nil {
+		return false
+	}
+	candidates := []string{auth.ID, auth.Label}
+	if email, ok := auth.Metadata["email"].(string); ok {
+		candidates = append(candidates, email)
+	}
+	if path := auth.Attributes["path"]; path != "" {
+		candidates = append(candidates, filepath.Base(path))
+	}
+	prefix, wildcard := strings.CutSuffix(pattern, "*")
+	for _, candidate := range candidates {
+		if candidate == "" {
+			continue
+		}
+		if wildcard && strings.HasPrefix(candidate, prefix) {
+			return true
+		}
+		if !wildcard && strings.EqualFold(candidate, pattern) {
+			return true
+		}
+	}
+	return false
+}
@@ -100,22 +100,47 @@ func (e *modelCooldownError) Headers() http.Header {
 
 // Pick selects the next available auth for the provider in a round-robin manner.
 func (s *RoundRobinSelector) Pick(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, auths []*Auth) (*Auth, error) {
-	_ = ctx
 	_ = opts
-	if len(auths) == 0 {
-		return nil, &Error{Code: "auth_not_found", Message: "no auth candidates"}
+	available, err := availableAuths(provider, model, auths, time.Now(), cliproxyexecutor.RetryAttempt(ctx) > 0)
+	if err != nil {
+		return nil, err
 	}
+	return s.next(provider+":"+model, available), nil
+}
+
+// next returns the auth at the cursor for key and advances it.
+func (s *RoundRobinSelector) next(key string, available []*Auth) *Auth {
+	s.mu.Lock()
 	if s.cursors == nil {
 		s.cursors = make(map[string]int)
 	}
+	index := s.cursors[key]
+
+	if index >= 2_147_483_640 {
+		index = 0
+	}
+
+	s.cursors[key] = index + 1
+	s.mu.Unlock()
+	// log.Debugf("available: %d, index: %d, key: %d", len(available), index, index%len(available))
+	return available[index%len(available)]
+}
+
+// availableAuths filters auths down to those usable for model, sorted by ID so that
+// selection is deterministic even if the caller's candidate order is unstable.
+// When every candidate is cooling down a modelCooldownError is returned.
+// Retry attempts may reuse auths that are only held back by a transient upstream error.
+func availableAuths(provider, model string, auths []*Auth, now time.Time, retrying bool) ([]*Auth, error) {
+	if len(auths) == 0 {
+		return nil, &Error{Code: "auth_not_found", Message: "no auth candidates"}
+	}
 	available := make([]*Auth, 0, len(auths))
-	now := time.Now()
 	cooldownCount := 0
 	var earliest time.Time
 	for i := 0; i < len(auths); i++ {
 		candidate := auths[i]
 		blocked, reason, next := isAuthBlockedForModel(candidate, model, now)
-		if !blocked {
+		if !blocked || (retrying && reason == blockReasonOther && blockedByTransientError(candidate, model)) {
 			available = append(available, candidate)
 			continue
 		}
@@ -136,22 +161,10 @@ func (s *RoundRobinSelector) Pick(ctx context.Context, provider, model string, o
 		}
 		return nil, &Error{Code: "auth_unavailable", Message: "no auth available"}
 	}
-	// Make round-robin deterministic even if caller's candidate order is unstable.
 	if len(available) > 1 {
 		sort.Slice(available, func(i, j int) bool { return available[i].ID < available[j].ID })
 	}
-	key := provider + ":" + model
-	s.mu.Lock()
-	index := s.cursors[key]
-
-	if index >= 2_147_483_640 {
-		index = 0
-	}
-
-	s.cursors[key] = index + 1
-	s.mu.Unlock()
-	// log.Debugf("available: %d, index: %d, key: %d", len(available), index, index%len(available))
-	return available[index%len(available)], nil
+	return available, nil
 }
 
 func isAuthBlockedForModel(auth *Auth, model string, now time.Time) (bool, blockReason, time.Time) {
@@ -205,3 +218,14 @@ func isAuthBlockedForModel(auth *Auth, model string, now time.Time) (bool, block
 	}
 	return false, blockReasonNone, time.Time{}
 }
+
+// blockedByTransientError reports whether the last failure recorded for auth and model was a
+// timeout or server error, whose short cooldown a retry attempt is allowed to skip.
+func blockedByTransientError(auth *Auth, model string) bool {
+	lastErr := auth.LastError
+	if state, ok := auth.ModelStates[model]; ok && state != nil && model != "" {
+		lastErr = state.LastError
+	}
+	status := statusCodeFromResult(lastErr)
+	return status == 408 || status >= 500
+}
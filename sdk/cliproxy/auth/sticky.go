@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	defaultStickyTTL         = time.Hour
+	defaultStickyMaxSessions = 10000
+)
+
+// stickyBinding pins one conversation to an auth.
+type stickyBinding struct {
+	key      string
+	authID   string
+	lastUsed time.Time
+}
+
+// stickySessions maps conversations to the auth that served them, evicting the least
+// recently used binding once MaxSessions is reached.
+type stickySessions struct {
+	mu       sync.Mutex
+	cfg      config.StickySessions
+	bindings map[string]*list.Element
+	order    *list.List // front is the most recently used binding
+}
+
+func newStickySessions() *stickySessions {
+	return &stickySessions{bindings: make(map[string]*list.Element), order: list.New()}
+}
+
+func (s *stickySessions) setConfig(cfg config.StickySessions) {
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultStickyTTL
+	}
+	if cfg.MaxSessions <= 0 {
+		cfg.MaxSessions = defaultStickyMaxSessions
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !cfg.Enable {
+		s.bindings = make(map[string]*list.Element)
+		s.order.Init()
+	}
+	s.cfg = cfg
+	for s.order.Len() > s.cfg.MaxSessions {
+		s.evict(s.order.Back())
+	}
+}
+
+// key returns the binding key of a request, or "" when it cannot be attributed to a conversation.
+func (s *stickySessions) key(provider, model string, opts cliproxyexecutor.Options) string {
+	s.mu.Lock()
+	cfg := s.cfg
+	s.mu.Unlock()
+	if !cfg.Enable {
+		return ""
+	}
+	session, _ := opts.Metadata[cliproxyexecutor.SessionIDMetadataKey].(string)
+	if session == "" && cfg.HashPrefix {
+		session = conversationPrefixHash(opts.OriginalRequest)
+	}
+	if session == "" {
+		return ""
+	}
+	return provider + ":" + model + ":" + session
+}
+
+// lookup returns the auth pinned to key, if the binding has not expired.
+func (s *stickySessions) lookup(key string, now time.Time) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	element, ok := s.bindings[key]
+	if !ok {
+		return "", false
+	}
+	binding := element.Value.(*stickyBinding)
+	if now.Sub(binding.lastUsed) > s.cfg.TTL {
+		s.evict(element)
+		return "", false
+	}
+	return binding.authID, true
+}
+
+// bind pins key to authID, replacing any previous binding.
+func (s *stickySessions) bind(key, authID string, now time.Time) {
+	if key == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.cfg.Enable {
+		return
+	}
+	if element, ok := s.bindings[key]; ok {
+		binding := element.Value.(*stickyBinding)
+		binding.authID = authID
+		binding.lastUsed = now
+		s.order.MoveToFront(element)
+		return
+	}
+	s.bindings[key] = s.order.PushFront(&stickyBinding{key: key, authID: authID, lastUsed: now})
+	for s.order.Len() > s.cfg.MaxSessions {
+		s.evict(s.order.Back())
+	}
+}
+
+func (s *stickySessions) evict(element *list.Element) {
+	if element == nil {
+		return
+	}
+	s.order.Remove(element)
+	delete(s.bindings, element.Value.(*stickyBinding).key)
+}
+
+// conversationPrefixHash identifies a conversation that carries its full history by hashing
+// the system prompt and the messages up to and including the first user turn, which stay the
+// same on every later turn. It returns "" when the request has no message list.
+func conversationPrefixHash(rawJSON []byte) string {
+	if len(rawJSON) == 0 {
+		return ""
+	}
+	var prefix strings.Builder
+	for _, path := range []string{"system", "instructions", "systemInstruction", "system_instruction"} {
+		if value := gjson.GetBytes(rawJSON, path); value.Exists() {
+			prefix.WriteString(value.Raw)
+			prefix.WriteByte('\n')
+		}
+	}
+	messages := 0
+	for _, path := range []string{"messages", "contents", "input"} {
+		history := gjson.GetBytes(rawJSON, path)
+		if !history.IsArray() {
+			continue
+		}
+		for _, message := range history.Array() {
+			prefix.WriteString(message.Raw)
+			prefix.WriteByte('\n')
+			messages++
+			if message.Get("role").String() == "user" {
+				break
+			}
+		}
+		break
+	}
+	if messages == 0 {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(prefix.String()))
+	return "prefix-" + hex.EncodeToString(sum[:16])
+}
+
+// pinnedCandidate returns the pinned auth when it is among the candidates and usable for model.
+func pinnedCandidate(candidates []*Auth, pinnedID string, pinned bool, model string, now time.Time) *Auth {
+	if !pinned {
+		return nil
+	}
+	for _, candidate := range candidates {
+		if candidate.ID != pinnedID {
+			continue
+		}
+		if blocked, _, _ := isAuthBlockedForModel(candidate, model, now); blocked {
+			return nil
+		}
+		return candidate
+	}
+	return nil
+}
+
+// SetStickySessionsConfig applies the sticky session configuration.
+func (m *Manager) SetStickySessionsConfig(cfg config.StickySessions) {
+	m.sticky.setConfig(cfg)
+}
@@ -197,10 +197,26 @@ func (b *Builder) Build() (*Service, error) {
 		if dirSetter, ok := tokenStore.(interface{ SetBaseDir(string) }); ok && b.cfg != nil {
 			dirSetter.SetBaseDir(b.cfg.AuthDir)
 		}
-		coreManager = coreauth.NewManager(tokenStore, nil, nil)
+		var routing []config.RoutingPolicy
+		if b.cfg != nil {
+			routing = b.cfg.Routing
+		}
+		coreManager = coreauth.NewManager(tokenStore, coreauth.NewPolicySelector(routing), nil)
 	}
 	// Attach a default RoundTripper provider so providers can opt-in per-auth transports.
 	coreManager.SetRoundTripperProvider(newDefaultRoundTripperProvider())
+	if b.cfg != nil {
+		coreManager.SetProjectRoutingPolicies(b.cfg.Projects)
+		coreManager.SetHealthCheckConfig(b.cfg.HealthCheck)
+		coreManager.SetProxyCheckConfig(b.cfg.ProxyCheck)
+		coreManager.SetCircuitBreakerConfig(b.cfg.CircuitBreaker)
+		coreManager.SetAccountConcurrency(b.cfg.AccountConcurrency)
+		coreManager.SetTokenRefreshConfig(b.cfg.TokenRefresh, b.cfg.ProxyURL)
+		coreManager.SetRetryPolicy(b.cfg.RequestRetry, b.cfg.Retry)
+		coreManager.SetStickySessionsConfig(b.cfg.StickySessions)
+		coreManager.SetRequestDedup(b.cfg.RequestDedup)
+	}
+	applyResponseCache(coreManager, nil, b.cfg)
 
 	service := &Service{
 		cfg:            b.cfg,
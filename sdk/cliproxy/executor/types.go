@@ -1,8 +1,12 @@
 package executor
 
 import (
+	"context"
+	"io"
 	"net/http"
 	"net/url"
+	"sync"
+	"time"
 
 	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
 )
@@ -13,12 +17,41 @@ type Request struct {
 	Model string
 	// Payload is the provider specific JSON payload.
 	Payload []byte
+	// Body, when set, opens the request body instead of Payload, for uploads that are not
+	// held in memory. Each call returns a new reader of the whole body, so an executor can
+	// send it again on a retry.
+	Body func() (io.ReadCloser, error)
 	// Format represents the provider payload schema.
 	Format sdktranslator.Format
 	// Metadata carries optional provider specific execution hints.
 	Metadata map[string]any
 }
 
+// SessionIDMetadataKey is the Options.Metadata key carrying the client-provided session or
+// conversation identifier used for sticky account selection.
+const SessionIDMetadataKey = "session_id"
+
+// PinnedAuthMetadataKey is the Options.Metadata key carrying the ID of the only auth a
+// request may be served by, set from the X-CLIProxy-Account header.
+const PinnedAuthMetadataKey = "pinned_auth_id"
+
+// RequirementsMetadataKey is the Options.Metadata key carrying the Requirements of a request,
+// set when capability routing is enabled.
+const RequirementsMetadataKey = "requirements"
+
+// Requirements lists the model features a request uses, so it is only routed to accounts
+// whose model supports them.
+type Requirements struct {
+	// Vision is set when the request contains images.
+	Vision bool
+	// Tools is set when the request declares tools.
+	Tools bool
+	// JSONMode is set when the request asks for JSON or schema-constrained output.
+	JSONMode bool
+	// InputTokens is the estimated size of the prompt.
+	InputTokens int
+}
+
 // Options controls execution behavior for both streaming and non-streaming calls.
 type Options struct {
 	// Stream toggles streaming mode.
@@ -53,6 +86,14 @@ type StreamChunk struct {
 	Err error
 }
 
+// BinaryStream is a streamed non-JSON provider response such as synthesized audio.
+type BinaryStream struct {
+	// ContentType is the media type reported by the provider.
+	ContentType string
+	// Chunks delivers the response body; a chunk carrying Err terminates the stream.
+	Chunks <-chan StreamChunk
+}
+
 // StatusError represents an error that carries an HTTP-like status code.
 // Provider executors should implement this when possible to enable
 // better auth state updates on failures (e.g., 401/402/429).
@@ -60,3 +101,153 @@ type StatusError interface {
 	error
 	StatusCode() int
 }
+
+// RetryAfterError is implemented by errors that carry an upstream Retry-After hint.
+type RetryAfterError interface {
+	error
+	RetryAfter() time.Duration
+}
+
+type retryAttemptContextKey struct{}
+
+// WithRetryAttempt marks ctx as belonging to the given retry attempt (1 for the first retry).
+func WithRetryAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, retryAttemptContextKey{}, attempt)
+}
+
+// RetryAttempt returns the retry attempt ctx belongs to; 0 for the original request.
+func RetryAttempt(ctx context.Context) int {
+	if ctx == nil {
+		return 0
+	}
+	attempt, _ := ctx.Value(retryAttemptContextKey{}).(int)
+	return attempt
+}
+
+type projectContextKey struct{}
+
+// WithProject marks ctx as serving a request of the named project.
+func WithProject(ctx context.Context, project string) context.Context {
+	return context.WithValue(ctx, projectContextKey{}, project)
+}
+
+// Project returns the project ctx serves; empty for keys outside every project.
+func Project(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	project, _ := ctx.Value(projectContextKey{}).(string)
+	return project
+}
+
+type apiKeyContextKey struct{}
+
+// WithAPIKey marks ctx as serving a request authenticated with the inbound API key.
+func WithAPIKey(ctx context.Context, apiKey string) context.Context {
+	return context.WithValue(ctx, apiKeyContextKey{}, apiKey)
+}
+
+// APIKey returns the inbound API key of the request ctx serves; empty when unknown.
+func APIKey(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	apiKey, _ := ctx.Value(apiKeyContextKey{}).(string)
+	return apiKey
+}
+
+type splitContextKey struct{}
+
+// splitAssignment is the traffic split variant a request was assigned to.
+type splitAssignment struct {
+	name    string
+	variant string
+}
+
+// WithSplit marks ctx as serving a request that the named traffic split assigned to variant.
+func WithSplit(ctx context.Context, name, variant string) context.Context {
+	return context.WithValue(ctx, splitContextKey{}, splitAssignment{name: name, variant: variant})
+}
+
+// Split returns the traffic split and variant ctx was assigned to; empty outside every split.
+func Split(ctx context.Context) (name, variant string) {
+	if ctx == nil {
+		return "", ""
+	}
+	assignment, _ := ctx.Value(splitContextKey{}).(splitAssignment)
+	return assignment.name, assignment.variant
+}
+
+type diagnosticsContextKey struct{}
+
+// Diagnostics collects how a request was served upstream: the provider and account of the
+// last attempt, how many attempts were made, the upstream latency and the token usage. It
+// is safe for concurrent use.
+type Diagnostics struct {
+	mu       sync.Mutex
+	snapshot DiagnosticsSnapshot
+}
+
+// DiagnosticsSnapshot is the state of Diagnostics at one point in time.
+type DiagnosticsSnapshot struct {
+	Provider string `json:"provider,omitempty"`
+	AuthID   string `json:"account,omitempty"`
+	// Attempts counts the upstream attempts, including the one that served the request.
+	Attempts int `json:"attempts"`
+	// LatencyMs is the upstream time of the last attempt; zero until it completed.
+	LatencyMs    int64 `json:"upstream_latency_ms,omitempty"`
+	InputTokens  int64 `json:"input_tokens,omitempty"`
+	OutputTokens int64 `json:"output_tokens,omitempty"`
+	TotalTokens  int64 `json:"total_tokens,omitempty"`
+}
+
+// WithDiagnostics attaches d to ctx, so the executors serving ctx can record into it.
+func WithDiagnostics(ctx context.Context, d *Diagnostics) context.Context {
+	return context.WithValue(ctx, diagnosticsContextKey{}, d)
+}
+
+// DiagnosticsFrom returns the Diagnostics attached to ctx, or nil.
+func DiagnosticsFrom(ctx context.Context) *Diagnostics {
+	if ctx == nil {
+		return nil
+	}
+	d, _ := ctx.Value(diagnosticsContextKey{}).(*Diagnostics)
+	return d
+}
+
+// StartAttempt records an upstream attempt against the given provider and account.
+func (d *Diagnostics) StartAttempt(provider, authID string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.snapshot.Provider = provider
+	d.snapshot.AuthID = authID
+	d.snapshot.Attempts++
+	d.snapshot.LatencyMs = 0
+	d.snapshot.InputTokens, d.snapshot.OutputTokens, d.snapshot.TotalTokens = 0, 0, 0
+}
+
+// FinishAttempt records the latency and token usage of the current attempt.
+func (d *Diagnostics) FinishAttempt(latency time.Duration, inputTokens, outputTokens, totalTokens int64) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.snapshot.LatencyMs = latency.Milliseconds()
+	d.snapshot.InputTokens = inputTokens
+	d.snapshot.OutputTokens = outputTokens
+	d.snapshot.TotalTokens = totalTokens
+}
+
+// Snapshot returns the recorded state.
+func (d *Diagnostics) Snapshot() DiagnosticsSnapshot {
+	if d == nil {
+		return DiagnosticsSnapshot{}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.snapshot
+}
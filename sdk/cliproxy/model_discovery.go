@@ -0,0 +1,254 @@
+package cliproxy
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultModelDiscoveryInterval = time.Hour
+	defaultModelDiscoveryTimeout  = 30 * time.Second
+	// modelDiscoveryIdleCheck is how often a disabled discovery checks whether it was enabled.
+	modelDiscoveryIdleCheck = time.Minute
+	// modelDiscoveryStartDelay lets the watcher register the configured auths before the
+	// first discovery round.
+	modelDiscoveryStartDelay = 5 * time.Second
+)
+
+// startModelDiscovery runs the model discovery loop until Shutdown. The loop follows the
+// current configuration, so enabling discovery or changing its interval needs no restart.
+// Local backends are discovered on every pass, at least once a minute, even when discovery
+// is disabled, as their models change whenever one is pulled or loaded.
+func (s *Service) startModelDiscovery() {
+	if s.coreManager == nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.discoveryCancel = cancel
+	go func() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(modelDiscoveryStartDelay):
+		}
+		var nextFull time.Time
+		for {
+			wait := modelDiscoveryIdleCheck
+			cfg := s.currentConfig()
+			if cfg != nil && cfg.ModelDiscovery.Enable {
+				interval := cfg.ModelDiscovery.Interval
+				if interval <= 0 {
+					interval = defaultModelDiscoveryInterval
+				}
+				if now := time.Now(); !now.Before(nextFull) {
+					s.discoverModels(ctx, cfg.ModelDiscovery, nil)
+					nextFull = now.Add(interval)
+				} else if len(cfg.LocalBackends) > 0 {
+					s.discoverModels(ctx, cfg.ModelDiscovery, isLocalBackendAuth)
+				}
+				wait = min(wait, time.Until(nextFull))
+			} else {
+				nextFull = time.Time{}
+				s.clearDiscoveredModels()
+				if cfg != nil && len(cfg.LocalBackends) > 0 {
+					s.discoverModels(ctx, cfg.ModelDiscovery, isLocalBackendAuth)
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+		}
+	}()
+}
+
+// stopModelDiscovery stops the model discovery loop.
+func (s *Service) stopModelDiscovery() {
+	if s.discoveryCancel != nil {
+		s.discoveryCancel()
+		s.discoveryCancel = nil
+	}
+}
+
+func (s *Service) currentConfig() *config.Config {
+	s.cfgMu.RLock()
+	defer s.cfgMu.RUnlock()
+	return s.cfg
+}
+
+// discoverModels queries the upstream of every enabled auth whose executor can list models,
+// limited to the auths accepted by only when it is not nil, and re-registers the models of
+// the auths whose list changed. An auth keeps its previous list when its upstream fails or reports
+// no models.
+func (s *Service) discoverModels(ctx context.Context, cfg config.ModelDiscovery, only func(*coreauth.Auth) bool) {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultModelDiscoveryTimeout
+	}
+	auths := s.coreManager.List()
+	present := make(map[string]struct{}, len(auths))
+	for _, a := range auths {
+		present[a.ID] = struct{}{}
+		if a.Disabled || (only != nil && !only(a)) {
+			continue
+		}
+		queryCtx, cancel := context.WithTimeout(ctx, timeout)
+		models, err := s.coreManager.ListModels(queryCtx, a.ID)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Warnf("model discovery for %s failed: %v", a.ID, err)
+			continue
+		}
+		if len(models) == 0 {
+			continue
+		}
+		s.discoveredMu.Lock()
+		if s.discovered == nil {
+			s.discovered = make(map[string][]*ModelInfo)
+		}
+		s.discovered[a.ID] = models
+		s.discoveredMu.Unlock()
+		log.Debugf("model discovery for %s: %d models", a.ID, len(models))
+		s.registerModelsForAuth(a)
+	}
+	s.discoveredMu.Lock()
+	for id := range s.discovered {
+		if _, ok := present[id]; !ok {
+			delete(s.discovered, id)
+		}
+	}
+	s.discoveredMu.Unlock()
+}
+
+// clearDiscoveredModels drops the discovered models, except those of local backends, and
+// restores the built-in model lists.
+func (s *Service) clearDiscoveredModels() {
+	s.discoveredMu.RLock()
+	ids := make([]string, 0, len(s.discovered))
+	for id := range s.discovered {
+		ids = append(ids, id)
+	}
+	s.discoveredMu.RUnlock()
+	cleared := ids[:0]
+	for _, id := range ids {
+		if a, ok := s.coreManager.GetByID(id); ok && isLocalBackendAuth(a) {
+			continue
+		}
+		cleared = append(cleared, id)
+	}
+	s.discoveredMu.Lock()
+	for _, id := range cleared {
+		delete(s.discovered, id)
+	}
+	s.discoveredMu.Unlock()
+	for _, id := range cleared {
+		if a, ok := s.coreManager.GetByID(id); ok {
+			s.registerModelsForAuth(a)
+		}
+	}
+}
+
+// isLocalBackendAuth reports whether a was synthesized for a local backend.
+func isLocalBackendAuth(a *coreauth.Auth) bool {
+	return a != nil && a.Attributes != nil && a.Attributes["local_type"] != ""
+}
+
+// discoveredModels returns the models last discovered for an auth; nil when none were.
+func (s *Service) discoveredModels(authID string) []*ModelInfo {
+	s.discoveredMu.RLock()
+	defer s.discoveredMu.RUnlock()
+	return s.discovered[authID]
+}
+
+// mergeDiscoveredModels replaces a built-in model list with the discovered one. Models
+// known to both keep their built-in metadata and gain the limits and descriptions the
+// upstream reported; built-in capabilities take precedence over discovered ones.
+func mergeDiscoveredModels(builtin, discovered []*ModelInfo) []*ModelInfo {
+	known := make(map[string]*ModelInfo, len(builtin))
+	for _, model := range builtin {
+		known[model.ID] = model
+	}
+	merged := make([]*ModelInfo, 0, len(discovered))
+	for _, found := range discovered {
+		base, ok := known[found.ID]
+		if !ok {
+			merged = append(merged, found)
+			continue
+		}
+		model := *base
+		if found.DisplayName != "" {
+			model.DisplayName = found.DisplayName
+		}
+		if found.Description != "" {
+			model.Description = found.Description
+		}
+		if found.InputTokenLimit > 0 {
+			model.InputTokenLimit = found.InputTokenLimit
+		}
+		if found.OutputTokenLimit > 0 {
+			model.OutputTokenLimit = found.OutputTokenLimit
+		}
+		if found.ContextLength > 0 {
+			model.ContextLength = found.ContextLength
+		}
+		if len(found.SupportedGenerationMethods) > 0 {
+			model.SupportedGenerationMethods = found.SupportedGenerationMethods
+		}
+		if model.Capabilities == nil {
+			model.Capabilities = found.Capabilities
+		}
+		merged = append(merged, &model)
+	}
+	return merged
+}
+
+// mergeCompatModels adds the discovered models of an OpenAI-compatible provider to its
+// configured ones. Configured aliases gain the metadata of the upstream model they name,
+// unless annotated in the configuration; discovered models without an alias are listed
+// under their upstream name.
+func mergeCompatModels(configured, discovered []*ModelInfo, ownedBy string) []*ModelInfo {
+	if len(discovered) == 0 {
+		return configured
+	}
+	byName := make(map[string]*ModelInfo, len(discovered))
+	for _, model := range discovered {
+		byName[strings.ToLower(model.ID)] = model
+	}
+	listed := make(map[string]struct{}, len(configured)+len(discovered))
+	merged := make([]*ModelInfo, 0, len(configured)+len(discovered))
+	for _, model := range configured {
+		listed[strings.ToLower(model.ID)] = struct{}{}
+		listed[strings.ToLower(model.DisplayName)] = struct{}{}
+		if found := byName[strings.ToLower(model.DisplayName)]; found != nil {
+			entry := *model
+			entry.SupportedParameters = found.SupportedParameters
+			// Annotations in the configuration take precedence over discovered metadata.
+			if entry.ContextLength == 0 {
+				entry.ContextLength = found.ContextLength
+			}
+			if entry.Capabilities == nil {
+				entry.Capabilities = found.Capabilities
+			}
+			model = &entry
+		}
+		merged = append(merged, model)
+	}
+	for _, found := range discovered {
+		if _, ok := listed[strings.ToLower(found.ID)]; ok {
+			continue
+		}
+		entry := *found
+		entry.OwnedBy = ownedBy
+		merged = append(merged, &entry)
+	}
+	return merged
+}
@@ -0,0 +1,219 @@
+package cliproxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/plugin"
+	log "github.com/sirupsen/logrus"
+)
+
+// pluginLaunchTimeout bounds starting an external plugin and reading its description.
+const pluginLaunchTimeout = 30 * time.Second
+
+// pluginHost runs the provider plugins of the configuration. A plugin is created on first
+// use and replaced when its configuration entry changes or its process exits.
+type pluginHost struct {
+	mu        sync.Mutex
+	instances map[string]*pluginInstance
+}
+
+type pluginInstance struct {
+	hash   string
+	plugin plugin.Plugin
+	info   plugin.Info
+	stderr *io.PipeWriter
+}
+
+func (i *pluginInstance) close(name string) {
+	if closer, ok := i.plugin.(plugin.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Warnf("provider plugin %s: close failed: %v", name, err)
+		}
+	}
+	if i.stderr != nil {
+		_ = i.stderr.Close()
+	}
+}
+
+// get returns the running plugin of entry, creating it when needed.
+func (h *pluginHost) get(ctx context.Context, entry config.ProviderPlugin) (plugin.Plugin, plugin.Info, error) {
+	name := strings.ToLower(entry.Name)
+	hash := providerPluginHash(entry)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if inst, ok := h.instances[name]; ok {
+		process, external := inst.plugin.(*plugin.Process)
+		if inst.hash == hash && (!external || !process.Exited()) {
+			return inst.plugin, inst.info, nil
+		}
+		if external && process.Exited() {
+			log.Warnf("provider plugin %s: process exited, restarting", entry.Name)
+		}
+		inst.close(entry.Name)
+		delete(h.instances, name)
+	}
+	inst, err := startPlugin(ctx, entry)
+	if err != nil {
+		return nil, plugin.Info{}, &coreauth.Error{
+			Code:       "plugin_unavailable",
+			Message:    fmt.Sprintf("provider plugin %s: %v", entry.Name, err),
+			Retryable:  true,
+			HTTPStatus: http.StatusServiceUnavailable,
+		}
+	}
+	inst.hash = hash
+	if h.instances == nil {
+		h.instances = make(map[string]*pluginInstance)
+	}
+	h.instances[name] = inst
+	log.Infof("provider plugin %s started (%s format, %d models)", entry.Name, inst.info.Format, len(inst.info.Models))
+	return inst.plugin, inst.info, nil
+}
+
+func startPlugin(ctx context.Context, entry config.ProviderPlugin) (*pluginInstance, error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), pluginLaunchTimeout)
+	defer cancel()
+	inst := &pluginInstance{}
+	if entry.Type != "" {
+		factory, ok := plugin.Lookup(entry.Type)
+		if !ok {
+			return nil, fmt.Errorf("no plugin registered as %q", entry.Type)
+		}
+		p, err := factory(entry.Settings)
+		if err != nil {
+			return nil, err
+		}
+		inst.plugin = p
+	} else {
+		inst.stderr = log.WithField("plugin", entry.Name).WriterLevel(log.InfoLevel)
+		p, err := plugin.Launch(ctx, entry.Command, entry.Args, entry.Env, entry.Settings, inst.stderr)
+		if err != nil {
+			_ = inst.stderr.Close()
+			return nil, err
+		}
+		inst.plugin = p
+	}
+	info, err := inst.plugin.Describe(ctx)
+	if err == nil {
+		info.Format = strings.ToLower(strings.TrimSpace(info.Format))
+		switch info.Format {
+		case "openai", "claude", "gemini", "codex":
+		default:
+			err = fmt.Errorf("unsupported format %q", info.Format)
+		}
+	}
+	if err != nil {
+		inst.close(entry.Name)
+		return nil, err
+	}
+	inst.info = info
+	return inst, nil
+}
+
+// sync stops the plugins that are no longer configured or whose entry changed.
+func (h *pluginHost) sync(cfg *config.Config) {
+	current := make(map[string]string)
+	if cfg != nil {
+		for i := range cfg.ProviderPlugins {
+			current[strings.ToLower(cfg.ProviderPlugins[i].Name)] = providerPluginHash(cfg.ProviderPlugins[i])
+		}
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for name, inst := range h.instances {
+		if hash, ok := current[name]; ok && hash == inst.hash {
+			continue
+		}
+		inst.close(name)
+		delete(h.instances, name)
+	}
+}
+
+// closeAll stops all plugins.
+func (h *pluginHost) closeAll() {
+	h.sync(nil)
+}
+
+func providerPluginHash(entry config.ProviderPlugin) string {
+	data, _ := json.Marshal(entry)
+	return string(data)
+}
+
+// resolvePlugin returns the running plugin of provider; it backs the plugin executors.
+func (s *Service) resolvePlugin(ctx context.Context, provider string) (plugin.Plugin, plugin.Info, error) {
+	s.cfgMu.RLock()
+	cfg := s.cfg
+	s.cfgMu.RUnlock()
+	if entry := findProviderPlugin(cfg, provider); entry != nil {
+		return s.plugins.get(ctx, *entry)
+	}
+	return nil, plugin.Info{}, &coreauth.Error{
+		Code:       "plugin_not_configured",
+		Message:    fmt.Sprintf("provider plugin %s is not configured", provider),
+		HTTPStatus: http.StatusServiceUnavailable,
+	}
+}
+
+// buildPluginModels converts the models a plugin describes.
+func buildPluginModels(provider string, info plugin.Info) []*ModelInfo {
+	now := time.Now().Unix()
+	out := make([]*ModelInfo, 0, len(info.Models))
+	seen := make(map[string]struct{}, len(info.Models))
+	for _, model := range info.Models {
+		id := strings.TrimSpace(model.ID)
+		if id == "" {
+			continue
+		}
+		key := strings.ToLower(id)
+		if _, exists := seen[key]; exists {
+			continue
+		}
+		seen[key] = struct{}{}
+		display := model.DisplayName
+		if display == "" {
+			display = id
+		}
+		out = append(out, &ModelInfo{
+			ID:            id,
+			Object:        "model",
+			Created:       now,
+			OwnedBy:       provider,
+			Type:          provider,
+			DisplayName:   display,
+			ContextLength: model.ContextLength,
+			Capabilities:  configModelCapabilities(model.Capabilities),
+		})
+	}
+	return out
+}
+
+// providerPluginFromAuth returns the name of the provider plugin an auth was synthesized
+// for, or "" when it belongs to another provider.
+func providerPluginFromAuth(a *coreauth.Auth) string {
+	if a == nil || a.Attributes == nil {
+		return ""
+	}
+	return strings.TrimSpace(a.Attributes["provider_plugin"])
+}
+
+// findProviderPlugin returns the provider plugin entry named name.
+func findProviderPlugin(cfg *config.Config, name string) *config.ProviderPlugin {
+	if cfg == nil {
+		return nil
+	}
+	for i := range cfg.ProviderPlugins {
+		if strings.EqualFold(cfg.ProviderPlugins[i].Name, name) {
+			return &cfg.ProviderPlugins[i]
+		}
+	}
+	return nil
+}
@@ -29,7 +29,7 @@ func NewAPIKeyClientProvider() APIKeyClientProvider {
 type apiKeyClientProvider struct{}
 
 func (p *apiKeyClientProvider) Load(ctx context.Context, cfg *config.Config) (*APIKeyClientResult, error) {
-	glCount, claudeCount, codexCount, openAICompat := watcher.BuildAPIKeyClients(cfg)
+	glCount, claudeCount, codexCount, bedrockCount, azureCount, vertexCount, xaiCount, openAICompat, localCount, pluginCount := watcher.BuildAPIKeyClients(cfg)
 	if ctx != nil {
 		select {
 		case <-ctx.Done():
@@ -41,6 +41,12 @@ func (p *apiKeyClientProvider) Load(ctx context.Context, cfg *config.Config) (*A
 		GeminiKeyCount:    glCount,
 		ClaudeKeyCount:    claudeCount,
 		CodexKeyCount:     codexCount,
+		BedrockKeyCount:   bedrockCount,
+		AzureOpenAICount:  azureCount,
+		VertexAICount:     vertexCount,
+		XAIKeyCount:       xaiCount,
 		OpenAICompatCount: openAICompat,
+		LocalBackendCount: localCount,
+		PluginCount:       pluginCount,
 	}, nil
 }
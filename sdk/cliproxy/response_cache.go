@@ -0,0 +1,56 @@
+package cliproxy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/redis"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultResponseCacheEntries = 1000
+
+// newResponseCache builds the response cache backend selected in cfg.
+func newResponseCache(cfg *config.Config) (coreauth.ResponseCache, error) {
+	switch backend := strings.ToLower(strings.TrimSpace(cfg.ResponseCache.Backend)); backend {
+	case "", "memory":
+		entries := cfg.ResponseCache.MaxEntries
+		if entries <= 0 {
+			entries = defaultResponseCacheEntries
+		}
+		return cache.NewMemory(entries), nil
+	case "redis":
+		client, err := redis.NewClient(cfg.Redis)
+		if err != nil {
+			return nil, err
+		}
+		return cache.NewRedis(client, "response-cache"), nil
+	default:
+		return nil, fmt.Errorf("unknown response cache backend %q", backend)
+	}
+}
+
+// applyResponseCache installs the response cache configured in cfg on the manager.
+// The current cache, and its entries, are kept when the relevant settings did not change.
+func applyResponseCache(manager *coreauth.Manager, previous, cfg *config.Config) {
+	if manager == nil || cfg == nil {
+		return
+	}
+	if previous != nil && previous.ResponseCache == cfg.ResponseCache && previous.Redis == cfg.Redis {
+		return
+	}
+	if !cfg.ResponseCache.Enable {
+		manager.SetResponseCache(nil, cfg.ResponseCache)
+		return
+	}
+	responseCache, err := newResponseCache(cfg)
+	if err != nil {
+		log.Errorf("response cache disabled: %v", err)
+		manager.SetResponseCache(nil, cfg.ResponseCache)
+		return
+	}
+	manager.SetResponseCache(responseCache, cfg.ResponseCache)
+}
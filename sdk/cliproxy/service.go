@@ -14,6 +14,7 @@ import (
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/redis"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
@@ -26,6 +27,9 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// shutdownGrace is the time allowed for shutdown steps other than draining responses.
+const shutdownGrace = 10 * time.Second
+
 // Service wraps the proxy server lifecycle so external programs can embed the CLI proxy.
 // It manages the complete lifecycle including authentication, file watching, HTTP server,
 // and integration with various AI service providers.
@@ -57,6 +61,9 @@ type Service struct {
 	// server is the HTTP API server instance.
 	server *api.Server
 
+	// sharedState is the Redis connection used for state shared between instances.
+	sharedState *redis.Client
+
 	// serverErr channel for server startup/shutdown errors.
 	serverErr chan error
 
@@ -86,6 +93,18 @@ type Service struct {
 
 	// wsGateway manages websocket Gemini providers.
 	wsGateway *wsrelay.Manager
+
+	// discoveryCancel stops the model discovery loop.
+	discoveryCancel context.CancelFunc
+
+	// discoveredMu protects discovered.
+	discoveredMu sync.RWMutex
+
+	// discovered holds the models last discovered upstream, keyed by auth ID.
+	discovered map[string][]*ModelInfo
+
+	// plugins runs the configured provider plugins.
+	plugins pluginHost
 }
 
 // RegisterUsagePlugin registers a usage plugin on the global usage manager.
@@ -248,7 +267,11 @@ func (s *Service) applyCoreAuthAddOrUpdate(ctx context.Context, auth *coreauth.A
 	}
 	auth = auth.Clone()
 	s.ensureExecutorsForAuth(auth)
-	s.registerModelsForAuth(auth)
+	if auth.Disabled {
+		GlobalModelRegistry().UnregisterClient(auth.ID)
+	} else {
+		s.registerModelsForAuth(auth)
+	}
 	if existing, ok := s.coreManager.GetByID(auth.ID); ok && existing != nil {
 		auth.CreatedAt = existing.CreatedAt
 		auth.LastRefreshedAt = existing.LastRefreshedAt
@@ -304,6 +327,10 @@ func (s *Service) ensureExecutorsForAuth(a *coreauth.Auth) {
 	if s == nil || a == nil {
 		return
 	}
+	if name := providerPluginFromAuth(a); name != "" {
+		s.coreManager.RegisterExecutor(executor.NewPluginExecutor(name, s.cfg, s.resolvePlugin))
+		return
+	}
 	if compatProviderKey, _, isCompat := openAICompatInfoFromAuth(a); isCompat {
 		if compatProviderKey == "" {
 			compatProviderKey = strings.ToLower(strings.TrimSpace(a.Provider))
@@ -328,6 +355,14 @@ func (s *Service) ensureExecutorsForAuth(a *coreauth.Auth) {
 		s.coreManager.RegisterExecutor(executor.NewClaudeExecutor(s.cfg))
 	case "codex":
 		s.coreManager.RegisterExecutor(executor.NewCodexExecutor(s.cfg))
+	case "bedrock":
+		s.coreManager.RegisterExecutor(executor.NewBedrockExecutor(s.cfg))
+	case "azure-openai":
+		s.coreManager.RegisterExecutor(executor.NewAzureOpenAIExecutor(s.cfg))
+	case "vertex":
+		s.coreManager.RegisterExecutor(executor.NewVertexExecutor(s.cfg))
+	case "xai":
+		s.coreManager.RegisterExecutor(executor.NewXAIExecutor(s.cfg))
 	case "qwen":
 		s.coreManager.RegisterExecutor(executor.NewQwenExecutor(s.cfg))
 	case "iflow":
@@ -371,9 +406,10 @@ func (s *Service) Run(ctx context.Context) error {
 
 	usage.StartDefault(ctx)
 
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer shutdownCancel()
 	defer func() {
+		// The shutdown deadline starts when the service stops, not when it starts.
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), s.shutdownTimeout())
+		defer shutdownCancel()
 		if err := s.Shutdown(shutdownCtx); err != nil {
 			log.Errorf("service shutdown returned error: %v", err)
 		}
@@ -407,6 +443,10 @@ func (s *Service) Run(ctx context.Context) error {
 
 	// legacy clients removed; no caches to refresh
 
+	if err = s.startSharedState(); err != nil {
+		return fmt.Errorf("cliproxy: failed to start shared state: %w", err)
+	}
+
 	// handlers no longer depend on legacy clients; pass nil slice initially
 	s.server = api.NewServer(s.cfg, s.coreManager, s.accessManager, s.configPath, s.serverOptions...)
 
@@ -469,8 +509,23 @@ func (s *Service) Run(ctx context.Context) error {
 			s.server.UpdateClients(newCfg)
 		}
 		s.cfgMu.Lock()
+		previousCfg := s.cfg
 		s.cfg = newCfg
 		s.cfgMu.Unlock()
+		if s.coreManager != nil {
+			s.coreManager.SetRoutingPolicies(newCfg.Routing)
+			s.coreManager.SetProjectRoutingPolicies(newCfg.Projects)
+			s.coreManager.SetHealthCheckConfig(newCfg.HealthCheck)
+			s.coreManager.SetProxyCheckConfig(newCfg.ProxyCheck)
+			s.coreManager.SetCircuitBreakerConfig(newCfg.CircuitBreaker)
+			s.coreManager.SetAccountConcurrency(newCfg.AccountConcurrency)
+			s.coreManager.SetTokenRefreshConfig(newCfg.TokenRefresh, newCfg.ProxyURL)
+			s.coreManager.SetRetryPolicy(newCfg.RequestRetry, newCfg.Retry)
+			s.coreManager.SetStickySessionsConfig(newCfg.StickySessions)
+			s.coreManager.SetRequestDedup(newCfg.RequestDedup)
+			applyResponseCache(s.coreManager, previousCfg, newCfg)
+		}
+		s.plugins.sync(newCfg)
 		s.rebindExecutors()
 	}
 
@@ -497,6 +552,8 @@ func (s *Service) Run(ctx context.Context) error {
 		interval := 15 * time.Minute
 		s.coreManager.StartAutoRefresh(context.Background(), interval)
 		log.Infof("core auth auto-refresh started (interval=%s)", interval)
+		s.coreManager.StartProxyChecks(context.Background())
+		s.startModelDiscovery()
 	}
 
 	select {
@@ -527,6 +584,17 @@ func (s *Service) Shutdown(ctx context.Context) error {
 			ctx = context.Background()
 		}
 
+		// Drain the API server first, while the backends in-flight streams depend on are
+		// still running.
+		if s.server != nil {
+			shutdownCtx, cancel := context.WithTimeout(ctx, s.shutdownTimeout())
+			defer cancel()
+			if err := s.server.Stop(shutdownCtx); err != nil {
+				log.Errorf("error stopping API server: %v", err)
+				shutdownErr = err
+			}
+		}
+
 		// legacy refresh loop removed; only stopping core auth manager below
 
 		if s.watcherCancel != nil {
@@ -534,11 +602,17 @@ func (s *Service) Shutdown(ctx context.Context) error {
 		}
 		if s.coreManager != nil {
 			s.coreManager.StopAutoRefresh()
+			s.coreManager.StopProxyChecks()
 		}
+		s.stopModelDiscovery()
+		s.plugins.closeAll()
+		s.stopSharedState()
 		if s.watcher != nil {
 			if err := s.watcher.Stop(); err != nil {
 				log.Errorf("failed to stop file watcher: %v", err)
-				shutdownErr = err
+				if shutdownErr == nil {
+					shutdownErr = err
+				}
 			}
 		}
 		if s.wsGateway != nil {
@@ -556,22 +630,20 @@ func (s *Service) Shutdown(ctx context.Context) error {
 
 		// no legacy clients to persist
 
-		if s.server != nil {
-			shutdownCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-			defer cancel()
-			if err := s.server.Stop(shutdownCtx); err != nil {
-				log.Errorf("error stopping API server: %v", err)
-				if shutdownErr == nil {
-					shutdownErr = err
-				}
-			}
-		}
-
 		usage.StopDefault()
 	})
 	return shutdownErr
 }
 
+// shutdownTimeout bounds a shutdown: the drain timeout of in-flight responses plus the time
+// needed to stop the remaining workers.
+func (s *Service) shutdownTimeout() time.Duration {
+	s.cfgMu.RLock()
+	cfg := s.cfg
+	s.cfgMu.RUnlock()
+	return api.DrainTimeout(cfg) + shutdownGrace
+}
+
 func (s *Service) ensureAuthDir() error {
 	info, err := os.Stat(s.cfg.AuthDir)
 	if err != nil {
@@ -603,12 +675,27 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 			}
 		}
 	}
+	if name := providerPluginFromAuth(a); name != "" {
+		_, info, err := s.resolvePlugin(context.Background(), name)
+		if err != nil {
+			log.Warnf("failed to register plugin models: %v", err)
+			GlobalModelRegistry().UnregisterClient(a.ID)
+			return
+		}
+		if models := buildPluginModels(name, info); len(models) > 0 {
+			GlobalModelRegistry().RegisterClient(a.ID, name, models)
+		} else {
+			GlobalModelRegistry().UnregisterClient(a.ID)
+		}
+		return
+	}
 	provider := strings.ToLower(strings.TrimSpace(a.Provider))
 	compatProviderKey, compatDisplayName, compatDetected := openAICompatInfoFromAuth(a)
 	if compatDetected {
 		provider = "openai-compatibility"
 	}
 	var models []*ModelInfo
+	discovered := s.discoveredModels(a.ID)
 	switch provider {
 	case "gemini":
 		models = registry.GetGeminiModels()
@@ -620,9 +707,27 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 		models = registry.GetClaudeModels()
 		if entry := s.resolveConfigClaudeKey(a); entry != nil && len(entry.Models) > 0 {
 			models = buildClaudeConfigModels(entry)
+			// Explicitly configured models take precedence over discovered ones.
+			discovered = nil
 		}
 	case "codex":
 		models = registry.GetOpenAIModels()
+	case "bedrock":
+		models = buildBedrockConfigModels(s.resolveConfigBedrockKey(a))
+	case "azure-openai":
+		models = buildAzureOpenAIConfigModels(s.resolveConfigAzureOpenAI(a))
+	case "vertex":
+		models = registry.GetGeminiModels()
+		if entry := s.resolveConfigVertexAI(a); entry != nil && len(entry.Models) > 0 {
+			models = buildVertexConfigModels(entry)
+		}
+	case "xai":
+		models = registry.GetXAIModels()
+		if entry := s.resolveConfigXAIKey(a); entry != nil && len(entry.Models) > 0 {
+			models = buildXAIConfigModels(entry)
+			// Explicitly configured models take precedence over discovered ones.
+			discovered = nil
+		}
 	case "qwen":
 		models = registry.GetQwenModels()
 	case "iflow":
@@ -666,41 +771,38 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 					isCompatAuth = true
 				}
 			}
-			for i := range s.cfg.OpenAICompatibility {
-				compat := &s.cfg.OpenAICompatibility[i]
-				if strings.EqualFold(compat.Name, compatName) {
-					isCompatAuth = true
-					// Convert compatibility models to registry models
-					ms := make([]*ModelInfo, 0, len(compat.Models))
-					for j := range compat.Models {
-						m := compat.Models[j]
-						// Use alias as model ID, fallback to name if alias is empty
-						modelID := m.Alias
-						if modelID == "" {
-							modelID = m.Name
-						}
-						ms = append(ms, &ModelInfo{
-							ID:          modelID,
-							Object:      "model",
-							Created:     time.Now().Unix(),
-							OwnedBy:     compat.Name,
-							Type:        "openai-compatibility",
-							DisplayName: m.Name,
-						})
+			var compatModels []config.OpenAICompatibilityModel
+			ownedBy := ""
+			if a.Attributes != nil && a.Attributes["local_type"] != "" {
+				// Local backends list their discovered models next to the configured aliases.
+				for i := range s.cfg.LocalBackends {
+					if strings.EqualFold(s.cfg.LocalBackends[i].Name, compatName) {
+						compatModels, ownedBy = s.cfg.LocalBackends[i].Models, s.cfg.LocalBackends[i].Name
+						break
 					}
-					// Register and return
-					if len(ms) > 0 {
-						if providerKey == "" {
-							providerKey = "openai-compatibility"
-						}
-						GlobalModelRegistry().RegisterClient(a.ID, providerKey, ms)
-					} else {
-						// Ensure stale registrations are cleared when model list becomes empty.
-						GlobalModelRegistry().UnregisterClient(a.ID)
+				}
+			} else {
+				for i := range s.cfg.OpenAICompatibility {
+					if strings.EqualFold(s.cfg.OpenAICompatibility[i].Name, compatName) {
+						compatModels, ownedBy = s.cfg.OpenAICompatibility[i].Models, s.cfg.OpenAICompatibility[i].Name
+						break
 					}
-					return
 				}
 			}
+			if ownedBy != "" {
+				ms := mergeCompatModels(buildCompatConfigModels(compatModels, ownedBy), discovered, ownedBy)
+				// Register and return
+				if len(ms) > 0 {
+					if providerKey == "" {
+						providerKey = "openai-compatibility"
+					}
+					GlobalModelRegistry().RegisterClient(a.ID, providerKey, ms)
+				} else {
+					// Ensure stale registrations are cleared when model list becomes empty.
+					GlobalModelRegistry().UnregisterClient(a.ID)
+				}
+				return
+			}
 			if isCompatAuth {
 				// No matching provider found or models removed entirely; drop any prior registration.
 				GlobalModelRegistry().UnregisterClient(a.ID)
@@ -708,6 +810,9 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 			}
 		}
 	}
+	if len(discovered) > 0 {
+		models = mergeDiscoveredModels(models, discovered)
+	}
 	if len(models) > 0 {
 		key := provider
 		if key == "" {
@@ -783,13 +888,282 @@ func buildClaudeConfigModels(entry *config.ClaudeKey) []*ModelInfo {
 			display = alias
 		}
 		out = append(out, &ModelInfo{
-			ID:          alias,
-			Object:      "model",
-			Created:     now,
-			OwnedBy:     "claude",
-			Type:        "claude",
-			DisplayName: display,
+			ID:            alias,
+			Object:        "model",
+			Created:       now,
+			OwnedBy:       "claude",
+			Type:          "claude",
+			DisplayName:   display,
+			ContextLength: model.ContextWindow,
+			Capabilities:  configModelCapabilities(model.Capabilities),
+		})
+	}
+	return out
+}
+
+func (s *Service) resolveConfigBedrockKey(auth *coreauth.Auth) *config.BedrockKey {
+	if auth == nil || auth.Attributes == nil || s.cfg == nil {
+		return nil
+	}
+	region, baseURL := auth.Attributes["region"], auth.Attributes["base_url"]
+	apiKey, accessKeyID := auth.Attributes["api_key"], auth.Attributes["access_key_id"]
+	for i := range s.cfg.BedrockKey {
+		entry := &s.cfg.BedrockKey[i]
+		if entry.Region != region || entry.BaseURL != baseURL {
+			continue
+		}
+		if (apiKey != "" && entry.APIKey == apiKey) || (apiKey == "" && entry.AccessKeyID == accessKeyID) {
+			return entry
+		}
+	}
+	return nil
+}
+
+func buildBedrockConfigModels(entry *config.BedrockKey) []*ModelInfo {
+	if entry == nil || len(entry.Models) == 0 {
+		return nil
+	}
+	now := time.Now().Unix()
+	out := make([]*ModelInfo, 0, len(entry.Models))
+	seen := make(map[string]struct{}, len(entry.Models))
+	for i := range entry.Models {
+		model := entry.Models[i]
+		name := strings.TrimSpace(model.Name)
+		alias := strings.TrimSpace(model.Alias)
+		if alias == "" {
+			alias = name
+		}
+		if alias == "" {
+			continue
+		}
+		key := strings.ToLower(alias)
+		if _, exists := seen[key]; exists {
+			continue
+		}
+		seen[key] = struct{}{}
+		display := name
+		if display == "" {
+			display = alias
+		}
+		out = append(out, &ModelInfo{
+			ID:            alias,
+			Object:        "model",
+			Created:       now,
+			OwnedBy:       "bedrock",
+			Type:          "bedrock",
+			DisplayName:   display,
+			ContextLength: model.ContextWindow,
+			Capabilities:  configModelCapabilities(model.Capabilities),
+		})
+	}
+	return out
+}
+
+func (s *Service) resolveConfigAzureOpenAI(auth *coreauth.Auth) *config.AzureOpenAIKey {
+	if auth == nil || auth.Attributes == nil || s.cfg == nil {
+		return nil
+	}
+	baseURL, apiKey, clientID := auth.Attributes["base_url"], auth.Attributes["api_key"], auth.Attributes["client_id"]
+	for i := range s.cfg.AzureOpenAI {
+		entry := &s.cfg.AzureOpenAI[i]
+		if entry.BaseURL != baseURL {
+			continue
+		}
+		if (apiKey != "" && entry.APIKey == apiKey) || (apiKey == "" && entry.AzureAD.ClientID == clientID) {
+			return entry
+		}
+	}
+	return nil
+}
+
+func buildAzureOpenAIConfigModels(entry *config.AzureOpenAIKey) []*ModelInfo {
+	if entry == nil || len(entry.Deployments) == 0 {
+		return nil
+	}
+	now := time.Now().Unix()
+	out := make([]*ModelInfo, 0, len(entry.Deployments))
+	seen := make(map[string]struct{}, len(entry.Deployments))
+	for i := range entry.Deployments {
+		deployment := entry.Deployments[i]
+		name := strings.TrimSpace(deployment.Name)
+		alias := strings.TrimSpace(deployment.Alias)
+		if alias == "" {
+			alias = name
+		}
+		if alias == "" {
+			continue
+		}
+		key := strings.ToLower(alias)
+		if _, exists := seen[key]; exists {
+			continue
+		}
+		seen[key] = struct{}{}
+		out = append(out, &ModelInfo{
+			ID:            alias,
+			Object:        "model",
+			Created:       now,
+			OwnedBy:       "azure-openai",
+			Type:          "azure-openai",
+			DisplayName:   alias,
+			ContextLength: deployment.ContextWindow,
+			Capabilities:  configModelCapabilities(deployment.Capabilities),
+		})
+	}
+	return out
+}
+
+func (s *Service) resolveConfigVertexAI(auth *coreauth.Auth) *config.VertexAIKey {
+	if auth == nil || auth.Attributes == nil || s.cfg == nil {
+		return nil
+	}
+	attrs := auth.Attributes
+	for i := range s.cfg.VertexAI {
+		entry := &s.cfg.VertexAI[i]
+		if entry.ProjectID == attrs["project_id"] && entry.CredentialsFile == attrs["credentials_file"] &&
+			entry.Credentials == attrs["credentials"] && entry.BaseURL == attrs["base_url"] {
+			return entry
+		}
+	}
+	return nil
+}
+
+func buildVertexConfigModels(entry *config.VertexAIKey) []*ModelInfo {
+	if entry == nil || len(entry.Models) == 0 {
+		return nil
+	}
+	now := time.Now().Unix()
+	out := make([]*ModelInfo, 0, len(entry.Models))
+	seen := make(map[string]struct{}, len(entry.Models))
+	for i := range entry.Models {
+		model := entry.Models[i]
+		name := strings.TrimSpace(model.Name)
+		alias := strings.TrimSpace(model.Alias)
+		if alias == "" {
+			alias = name
+		}
+		if alias == "" {
+			continue
+		}
+		key := strings.ToLower(alias)
+		if _, exists := seen[key]; exists {
+			continue
+		}
+		seen[key] = struct{}{}
+		display := name
+		if display == "" {
+			display = alias
+		}
+		out = append(out, &ModelInfo{
+			ID:            alias,
+			Object:        "model",
+			Created:       now,
+			OwnedBy:       "vertex",
+			Type:          "vertex",
+			Name:          "models/" + alias,
+			DisplayName:   display,
+			ContextLength: model.ContextWindow,
+			Capabilities:  configModelCapabilities(model.Capabilities),
+		})
+	}
+	return out
+}
+
+// buildCompatConfigModels converts the models configured for an OpenAI-compatible provider
+// or local backend to registry models listed under their alias, or their name without one.
+func buildCompatConfigModels(models []config.OpenAICompatibilityModel, ownedBy string) []*ModelInfo {
+	out := make([]*ModelInfo, 0, len(models))
+	for i := range models {
+		m := models[i]
+		modelID := m.Alias
+		if modelID == "" {
+			modelID = m.Name
+		}
+		out = append(out, &ModelInfo{
+			ID:            modelID,
+			Object:        "model",
+			Created:       time.Now().Unix(),
+			OwnedBy:       ownedBy,
+			Type:          "openai-compatibility",
+			DisplayName:   m.Name,
+			ContextLength: m.ContextWindow,
+			Capabilities:  configModelCapabilities(m.Capabilities),
 		})
 	}
 	return out
 }
+
+func (s *Service) resolveConfigXAIKey(auth *coreauth.Auth) *config.XAIKey {
+	if auth == nil || auth.Attributes == nil || s.cfg == nil {
+		return nil
+	}
+	apiKey, baseURL := auth.Attributes["api_key"], auth.Attributes["base_url"]
+	for i := range s.cfg.XAIKey {
+		entry := &s.cfg.XAIKey[i]
+		if entry.APIKey == apiKey && entry.BaseURL == baseURL {
+			return entry
+		}
+	}
+	return nil
+}
+
+func buildXAIConfigModels(entry *config.XAIKey) []*ModelInfo {
+	if entry == nil || len(entry.Models) == 0 {
+		return nil
+	}
+	now := time.Now().Unix()
+	out := make([]*ModelInfo, 0, len(entry.Models))
+	seen := make(map[string]struct{}, len(entry.Models))
+	for i := range entry.Models {
+		model := entry.Models[i]
+		name := strings.TrimSpace(model.Name)
+		alias := strings.TrimSpace(model.Alias)
+		if alias == "" {
+			alias = name
+		}
+		if alias == "" {
+			continue
+		}
+		key := strings.ToLower(alias)
+		if _, exists := seen[key]; exists {
+			continue
+		}
+		seen[key] = struct{}{}
+		display := name
+		if display == "" {
+			display = alias
+		}
+		out = append(out, &ModelInfo{
+			ID:            alias,
+			Object:        "model",
+			Created:       now,
+			OwnedBy:       "xai",
+			Type:          "xai",
+			DisplayName:   display,
+			ContextLength: model.ContextWindow,
+			Capabilities:  configModelCapabilities(model.Capabilities),
+		})
+	}
+	return out
+}
+
+// configModelCapabilities converts the features listed for a configured model; nil when
+// none are listed, leaving the capabilities unknown.
+func configModelCapabilities(features []string) *registry.ModelCapabilities {
+	if len(features) == 0 {
+		return nil
+	}
+	capabilities := &registry.ModelCapabilities{}
+	for _, feature := range features {
+		switch strings.ToLower(strings.TrimSpace(feature)) {
+		case "vision":
+			capabilities.Vision = true
+		case "tools":
+			capabilities.Tools = true
+		case "json-mode":
+			capabilities.JSONMode = true
+		default:
+			log.Warnf("unknown model capability %q", feature)
+		}
+	}
+	return capabilities
+}
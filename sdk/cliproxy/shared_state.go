@@ -0,0 +1,101 @@
+package cliproxy
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/quota"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/redis"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
+)
+
+// sharedCooldownRetention is how long a published state is kept after its last update.
+const sharedCooldownRetention = 24 * time.Hour
+
+// redisCooldownStore keeps the shared model cooldowns in one Redis hash.
+type redisCooldownStore struct {
+	client *redis.Client
+	key    string
+}
+
+func newRedisCooldownStore(client *redis.Client) *redisCooldownStore {
+	return &redisCooldownStore{client: client, key: client.KeyPrefix() + "cooldowns"}
+}
+
+// Save implements coreauth.CooldownStore.
+func (r *redisCooldownStore) Save(ctx context.Context, cooldown coreauth.SharedCooldown) error {
+	value, err := json.Marshal(cooldown)
+	if err != nil {
+		return err
+	}
+	_, err = r.client.Do(ctx, "HSET", r.key, cooldown.AuthID+"\n"+cooldown.Model, string(value))
+	return err
+}
+
+// Load implements coreauth.CooldownStore. States not updated within the retention are removed.
+func (r *redisCooldownStore) Load(ctx context.Context) ([]coreauth.SharedCooldown, error) {
+	reply, err := r.client.Do(ctx, "HGETALL", r.key)
+	if err != nil {
+		return nil, err
+	}
+	values, _ := reply.([]any)
+	cutoff := time.Now().Add(-sharedCooldownRetention)
+	out := make([]coreauth.SharedCooldown, 0, len(values)/2)
+	stale := []string{"HDEL", r.key}
+	for i := 0; i+1 < len(values); i += 2 {
+		field, _ := values[i].(string)
+		raw, _ := values[i+1].(string)
+		var cooldown coreauth.SharedCooldown
+		if errUnmarshal := json.Unmarshal([]byte(raw), &cooldown); errUnmarshal != nil || cooldown.State.UpdatedAt.Before(cutoff) {
+			stale = append(stale, field)
+			continue
+		}
+		out = append(out, cooldown)
+	}
+	if len(stale) > 2 {
+		if _, errDel := r.client.Do(ctx, stale...); errDel != nil {
+			log.Debugf("failed to remove stale shared cooldowns: %v", errDel)
+		}
+	}
+	return out, nil
+}
+
+// startSharedState connects to Redis when shared state is enabled, shares the account
+// cooldowns of the core manager and registers the shared quota counters with the server.
+// It must run before the server is created.
+func (s *Service) startSharedState() error {
+	if s.cfg == nil || !s.cfg.SharedState.Enable {
+		return nil
+	}
+	client, err := redis.NewClient(s.cfg.Redis)
+	if err != nil {
+		return err
+	}
+	s.sharedState = client
+	s.serverOptions = append(s.serverOptions,
+		api.WithQuotaCounters(quota.NewRedisCounters(client)),
+		api.WithReadinessCheck("redis", client.Ping))
+	if s.coreManager != nil {
+		s.coreManager.SetCooldownStore(newRedisCooldownStore(client))
+		s.coreManager.StartCooldownSync(context.Background(), s.cfg.SharedState.SyncInterval)
+	}
+	log.Infof("shared state enabled (redis=%s)", s.cfg.Redis.Addr)
+	return nil
+}
+
+// stopSharedState stops the cooldown sync and closes the Redis connections.
+func (s *Service) stopSharedState() {
+	if s.sharedState == nil {
+		return
+	}
+	if s.coreManager != nil {
+		s.coreManager.StopCooldownSync()
+	}
+	if err := s.sharedState.Close(); err != nil {
+		log.Errorf("failed to close shared state connection: %v", err)
+	}
+	s.sharedState = nil
+}
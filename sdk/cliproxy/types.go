@@ -61,8 +61,26 @@ type APIKeyClientResult struct {
 	// CodexKeyCount is the number of Codex API key clients loaded.
 	CodexKeyCount int
 
+	// BedrockKeyCount is the number of AWS Bedrock credential clients loaded.
+	BedrockKeyCount int
+
+	// AzureOpenAICount is the number of Azure OpenAI resource clients loaded.
+	AzureOpenAICount int
+
+	// VertexAICount is the number of Vertex AI project clients loaded.
+	VertexAICount int
+
+	// XAIKeyCount is the number of xAI API key clients loaded.
+	XAIKeyCount int
+
 	// OpenAICompatCount is the number of OpenAI-compatible API key clients loaded.
 	OpenAICompatCount int
+
+	// LocalBackendCount is the number of local inference server clients loaded.
+	LocalBackendCount int
+
+	// PluginCount is the number of provider plugin clients loaded.
+	PluginCount int
 }
 
 // WatcherFactory creates a watcher for configuration and token changes.
@@ -16,8 +16,20 @@ type Record struct {
 	AuthID      string
 	Source      string
 	RequestedAt time.Time
-	Failed      bool
-	Detail      Detail
+	// FirstChunkAt is when the first streamed payload arrived; zero for non-streaming requests.
+	FirstChunkAt time.Time
+	Failed       bool
+	// Retry marks records produced by a retry attempt of the request policy.
+	Retry bool
+	// Split names the traffic split that routed the request and SplitVariant the model it
+	// assigned the request to; both are empty outside every split.
+	Split        string
+	SplitVariant string
+	// Estimated marks usage counted locally because the upstream reported none.
+	Estimated bool
+	// ErrorCode is the proxy error code a failed request was classified as.
+	ErrorCode string
+	Detail    Detail
 }
 
 // Detail holds the token usage breakdown.
@@ -25,8 +37,15 @@ type Detail struct {
 	InputTokens     int64
 	OutputTokens    int64
 	ReasoningTokens int64
-	CachedTokens    int64
-	TotalTokens     int64
+	// CachedTokens counts input tokens read from the provider's prompt cache.
+	CachedTokens int64
+	// CacheCreationTokens counts input tokens written to the provider's prompt cache.
+	CacheCreationTokens int64
+	TotalTokens         int64
+	// EmbeddingTokens counts input tokens of embedding requests, kept apart from chat input.
+	EmbeddingTokens int64
+	// Images counts the images returned by an image generation request.
+	Images int64
 }
 
 // Plugin consumes usage records emitted by the proxy runtime.
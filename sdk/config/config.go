@@ -4,6 +4,8 @@
 // debug settings, proxy configuration, and API keys.
 package config
 
+import "time"
+
 // SDKConfig represents the application's configuration, loaded from a YAML file.
 type SDKConfig struct {
 	// ProxyURL is the URL of an optional proxy server to use for outbound requests.
@@ -17,6 +19,176 @@ type SDKConfig struct {
 
 	// Access holds request authentication provider configuration.
 	Access AccessConfig `yaml:"auth,omitempty" json:"auth,omitempty"`
+
+	// ModelMappings rewrites client-facing model names before a request is routed.
+	ModelMappings []ModelMapping `yaml:"model-mappings,omitempty" json:"model-mappings,omitempty"`
+
+	// ModelFallbacks lists the models a request is retried against when its model fails.
+	ModelFallbacks []ModelFallback `yaml:"model-fallbacks,omitempty" json:"model-fallbacks,omitempty"`
+
+	// ModelSplits sends a share of the requests for a model to alternative models.
+	ModelSplits []ModelSplit `yaml:"model-splits,omitempty" json:"model-splits,omitempty"`
+
+	// RoutingRules pick the model of a request with CEL expressions evaluated per request.
+	RoutingRules []RoutingRule `yaml:"routing-rules,omitempty" json:"routing-rules,omitempty"`
+
+	// Streaming configures keep-alive comments and timeouts of streamed responses.
+	Streaming StreamingConfig `yaml:"streaming,omitempty" json:"streaming,omitempty"`
+
+	// RoutingHeaders lets trusted API keys pin requests to an upstream provider or account.
+	RoutingHeaders RoutingHeadersConfig `yaml:"routing-headers,omitempty" json:"routing-headers,omitempty"`
+
+	// Diagnostics tells clients how their requests were served upstream.
+	Diagnostics DiagnosticsConfig `yaml:"diagnostics,omitempty" json:"diagnostics,omitempty"`
+
+	// CapabilityRouting keeps requests away from accounts whose model lacks a feature the
+	// request uses.
+	CapabilityRouting CapabilityRoutingConfig `yaml:"capability-routing,omitempty" json:"capability-routing,omitempty"`
+
+	// ContextCompaction shortens conversations that exceed the context window of their model.
+	ContextCompaction ContextCompactionConfig `yaml:"context-compaction,omitempty" json:"context-compaction,omitempty"`
+
+	// MediaFetch lets the proxy download remote images for providers that only accept
+	// inline data. Disabled by default.
+	MediaFetch MediaFetchConfig `yaml:"media-fetch,omitempty" json:"media-fetch,omitempty"`
+
+	// Realtime configures the /v1/realtime WebSocket endpoint.
+	Realtime RealtimeConfig `yaml:"realtime,omitempty" json:"realtime,omitempty"`
+}
+
+// RealtimeConfig configures the Realtime API WebSocket endpoint.
+type RealtimeConfig struct {
+	// AllowedOrigins lists the browser origins, such as "https://app.example.com", that may
+	// open a realtime session. Connections without an Origin header, as opened by non-browser
+	// clients, and same-origin connections are always accepted; "*" accepts every origin.
+	AllowedOrigins []string `yaml:"allowed-origins,omitempty" json:"allowed-origins,omitempty"`
+}
+
+// MediaFetchConfig limits the remote images the proxy downloads for a request. Images are
+// only fetched from public addresses; loopback, private, link-local and other reserved
+// addresses are refused, including after redirects.
+type MediaFetchConfig struct {
+	// Enable turns on fetching of remote image URLs. Without it, remote images are dropped
+	// from requests to providers that only accept inline data.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// MaxImages caps the images fetched per request; defaults to 8.
+	MaxImages int `yaml:"max-images,omitempty" json:"max-images,omitempty"`
+
+	// MaxImageBytes is the largest image fetched; defaults to 20 MiB.
+	MaxImageBytes int64 `yaml:"max-image-bytes,omitempty" json:"max-image-bytes,omitempty"`
+
+	// MaxTotalBytes caps the bytes fetched per request; defaults to 50 MiB.
+	MaxTotalBytes int64 `yaml:"max-total-bytes,omitempty" json:"max-total-bytes,omitempty"`
+
+	// Timeout bounds each fetch; defaults to 30s.
+	Timeout time.Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
+// Context compaction strategies.
+const (
+	// CompactionDropOldest drops the oldest messages, system messages included.
+	CompactionDropOldest = "drop-oldest"
+	// CompactionSlidingWindow keeps the system prompt and drops the oldest other messages.
+	CompactionSlidingWindow = "sliding-window"
+	// CompactionSummarize replaces the oldest messages with a summary written by another model.
+	CompactionSummarize = "summarize"
+)
+
+// ContextCompactionConfig shortens conversations whose estimated prompt exceeds the context
+// window of the model serving them, instead of letting the upstream reject them. Whole turns
+// are removed from the start of the conversation until the prompt fits; the latest turn is
+// always kept. Models without a known context window are not compacted.
+type ContextCompactionConfig struct {
+	// Enable turns on context compaction.
+	Enable bool `yaml:"enable,omitempty" json:"enable,omitempty"`
+
+	// Strategy is "sliding-window" (default), "drop-oldest" or "summarize".
+	Strategy string `yaml:"strategy,omitempty" json:"strategy,omitempty"`
+
+	// ReserveTokens is kept free below the context window, e.g. for the reply of models whose
+	// window covers input and output.
+	ReserveTokens int `yaml:"reserve-tokens,omitempty" json:"reserve-tokens,omitempty"`
+
+	// SummaryModel writes the summaries of the summarize strategy; it is requested through
+	// the proxy in the OpenAI chat format with the caller's API key.
+	SummaryModel string `yaml:"summary-model,omitempty" json:"summary-model,omitempty"`
+
+	// SummaryMaxTokens bounds the length of a summary; defaults to 1024.
+	SummaryMaxTokens int `yaml:"summary-max-tokens,omitempty" json:"summary-max-tokens,omitempty"`
+}
+
+// CapabilityRoutingConfig matches the features a request uses — images, tools, JSON output
+// and its estimated size — against the capabilities and context window of the model on each
+// account. Accounts that cannot serve the request are skipped; when none can, the request is
+// rejected with an error naming the missing feature, or moves on to its fallback models.
+// Models without known capabilities are not restricted.
+type CapabilityRoutingConfig struct {
+	// Enable turns on capability-aware routing.
+	Enable bool `yaml:"enable,omitempty" json:"enable,omitempty"`
+}
+
+// DiagnosticsConfig exposes the routing of each request to the client: the provider and
+// account that served it, the retries performed, the upstream latency and the token counts.
+type DiagnosticsConfig struct {
+	// Headers adds X-CLIProxy-* response headers. Streamed responses send their headers
+	// before the upstream finishes, so they carry the routing but not latency or tokens.
+	Headers bool `yaml:"headers,omitempty" json:"headers,omitempty"`
+
+	// StreamEvent ends SSE streams with a "cliproxy.diagnostics" event carrying the same
+	// data as JSON, including latency and tokens.
+	StreamEvent bool `yaml:"stream-event,omitempty" json:"stream-event,omitempty"`
+
+	// AccountAPIKeys lists the inbound API keys shown the account that served their
+	// requests. Auth IDs usually name the account's email, so other keys only see the provider.
+	AccountAPIKeys []string `yaml:"account-api-keys,omitempty" json:"account-api-keys,omitempty"`
+}
+
+// RoutingHeadersConfig gates the X-CLIProxy-Provider and X-CLIProxy-Account request headers,
+// which pin a request to one upstream provider or account for debugging and load tests.
+// Requests from other keys that send either header are rejected.
+type RoutingHeadersConfig struct {
+	// APIKeys lists the inbound API keys allowed to send the routing headers.
+	APIKeys []string `yaml:"api-keys,omitempty" json:"api-keys,omitempty"`
+}
+
+// StreamingConfig keeps long streamed responses alive through intermediaries and bounds
+// how long a stream may stall or run. Zero disables each setting.
+type StreamingConfig struct {
+	// KeepAliveSeconds is the interval at which ": ping" SSE comments are written while
+	// no data is being sent.
+	KeepAliveSeconds int `yaml:"keepalive-seconds,omitempty" json:"keepalive-seconds,omitempty"`
+
+	// IdleTimeoutSeconds ends a stream that has received no upstream data for this long.
+	IdleTimeoutSeconds int `yaml:"idle-timeout-seconds,omitempty" json:"idle-timeout-seconds,omitempty"`
+
+	// MaxDurationSeconds ends a stream that has been running for this long.
+	MaxDurationSeconds int `yaml:"max-duration-seconds,omitempty" json:"max-duration-seconds,omitempty"`
+
+	// RecoveryAttempts is how many times a stream that fails after it started is re-issued
+	// with the text already streamed, so the client receives the rest of the response.
+	RecoveryAttempts int `yaml:"recovery-attempts,omitempty" json:"recovery-attempts,omitempty"`
+
+	// RecoveryFallback re-issues failed streams to the next model of the fallback chain
+	// instead of the model that failed.
+	RecoveryFallback bool `yaml:"recovery-fallback,omitempty" json:"recovery-fallback,omitempty"`
+}
+
+// ModelMapping rewrites one client-facing model name, or a family of names, to the model
+// that serves it. Exact mappings take precedence over wildcard ones; otherwise the first
+// matching entry wins.
+type ModelMapping struct {
+	// From is the model name requested by clients. Each "*" matches any run of characters.
+	From string `yaml:"from" json:"from"`
+
+	// To is the model that serves the request. Each "*" is replaced with the text matched by
+	// the corresponding "*" in From. It may use the "provider://model" form of
+	// OpenAI-compatible providers.
+	To string `yaml:"to" json:"to"`
+
+	// Provider optionally pins the request to one provider, e.g. "gemini-cli" or "claude",
+	// instead of every provider that serves To.
+	Provider string `yaml:"provider,omitempty" json:"provider,omitempty"`
 }
 
 // AccessConfig groups request authentication providers.
@@ -80,3 +252,58 @@ func MakeInlineAPIKeyProvider(keys []string) *AccessProvider {
 	}
 	return provider
 }
+
+// ModelFallback is an ordered fallback chain for a client-facing model. When the model fails
+// with a server error, is rate limited or times out, the request is sent to each fallback in
+// turn until one succeeds.
+type ModelFallback struct {
+	// Model is the model name requested by clients.
+	Model string `yaml:"model" json:"model"`
+
+	// Fallbacks are the models tried after Model, in order. Model mappings apply to them as
+	// to any requested model.
+	Fallbacks []string `yaml:"fallbacks" json:"fallbacks"`
+}
+
+// ModelSplit routes a percentage of the requests for a client-facing model to alternative
+// models, for instance to A/B test a new model. Requests not assigned to a target are served
+// by Model. The assignment is recorded in the usage details of each request.
+type ModelSplit struct {
+	// Name identifies the split in usage details; defaults to Model.
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+
+	// Model is the model name requested by clients.
+	Model string `yaml:"model" json:"model"`
+
+	// Targets receive their percentage of the requests, checked in order.
+	Targets []ModelSplitTarget `yaml:"targets" json:"targets"`
+
+	// Sticky assigns every conversation, or every API key for requests without a session
+	// identifier, to the same variant instead of drawing one per request.
+	Sticky bool `yaml:"sticky,omitempty" json:"sticky,omitempty"`
+}
+
+// ModelSplitTarget is an alternative model of a traffic split.
+type ModelSplitTarget struct {
+	// Model serves the requests assigned to the target. Model mappings apply to it as to any
+	// requested model.
+	Model string `yaml:"model" json:"model"`
+
+	// Percent is the share of requests assigned to the target, from 0 to 100.
+	Percent float64 `yaml:"percent" json:"percent"`
+}
+
+// RoutingRule sends the requests matched by a CEL expression to another model. Rules are
+// checked in order and the first match wins; its model then goes through model mappings,
+// traffic splits and fallbacks as if the client had requested it.
+type RoutingRule struct {
+	// Name identifies the rule in logs; defaults to its position, such as rule-1.
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+
+	// When is a CEL expression over the request that evaluates to a bool, such as
+	// `model == "auto" && prompt_tokens > 32000`.
+	When string `yaml:"when" json:"when"`
+
+	// Model serves the matched requests. It may name a provider with "provider://model".
+	Model string `yaml:"model" json:"model"`
+}
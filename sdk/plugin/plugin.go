@@ -0,0 +1,147 @@
+// Package plugin defines the interface for upstream providers that are added to the proxy
+// without changing its router or translators. A plugin receives requests already translated
+// into one of the built-in schemas ("openai", "claude", "gemini" or "codex") and returns
+// responses in that schema; the proxy translates them for the client, records usage and
+// handles retries and cooldowns like for the built-in providers.
+//
+// Plugins run in-process, registered with Register, or as external processes that speak
+// JSON-RPC over their standard input and output, usually by calling Serve from main.
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Info describes a provider plugin.
+type Info struct {
+	// Format is the schema requests are translated into before they reach the plugin and
+	// that responses are read in: "openai", "claude", "gemini" or "codex".
+	Format string `json:"format"`
+
+	// Models lists the models the plugin serves.
+	Models []Model `json:"models"`
+}
+
+// Model describes a model served by a plugin.
+type Model struct {
+	// ID is the model name clients request.
+	ID string `json:"id"`
+
+	// DisplayName is the human-readable name of the model.
+	DisplayName string `json:"display_name,omitempty"`
+
+	// ContextLength is the number of input tokens the model accepts; zero when unknown.
+	ContextLength int `json:"context_length,omitempty"`
+
+	// Capabilities lists the features the model supports: "vision", "tools" and "json-mode".
+	Capabilities []string `json:"capabilities,omitempty"`
+}
+
+// Request is a request for a plugin, with the payload in the plugin's format.
+type Request struct {
+	// Model is the requested model.
+	Model string `json:"model"`
+
+	// Payload is the request body.
+	Payload json.RawMessage `json:"payload"`
+
+	// Stream reports whether a streamed response was requested.
+	Stream bool `json:"stream,omitempty"`
+}
+
+// Response is the response of a plugin to a non-streaming request.
+type Response struct {
+	// Payload is the response body in the plugin's format; for "codex" it is the
+	// response.completed event.
+	Payload json.RawMessage `json:"payload"`
+}
+
+// Chunk is one event of a streamed response. Data is a JSON event of the plugin's format,
+// with or without the SSE "data:" prefix. A chunk with Err ends the stream.
+type Chunk struct {
+	Data string
+	Err  error
+}
+
+// Plugin is an upstream provider. Its methods may be called concurrently.
+type Plugin interface {
+	// Describe returns the format and the models of the plugin.
+	Describe(ctx context.Context) (Info, error)
+	// Execute handles a non-streaming request.
+	Execute(ctx context.Context, req Request) (Response, error)
+	// ExecuteStream handles a streaming request. The channel is closed when the response
+	// is complete; the plugin stops sending when ctx is done.
+	ExecuteStream(ctx context.Context, req Request) (<-chan Chunk, error)
+}
+
+// Closer is implemented by plugins that hold resources, such as external processes. The
+// proxy calls Close when the plugin is removed from the configuration or the proxy stops.
+type Closer interface {
+	Close() error
+}
+
+// Factory creates a plugin from the settings of its configuration entry.
+type Factory func(settings map[string]string) (Plugin, error)
+
+// Error is an upstream error with an HTTP status code. Returning it from Execute or
+// ExecuteStream lets the proxy retry and cool down like for the built-in providers.
+type Error struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+
+	// RetryAfter is the number of seconds after which the request may be retried, as in a
+	// Retry-After header; zero when unknown.
+	RetryAfter int `json:"retry_after,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return fmt.Sprintf("status %d", e.Status)
+}
+
+// StatusCode returns the HTTP status code of the error.
+func (e *Error) StatusCode() int { return e.Status }
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register registers an in-process plugin factory under name, which configuration entries
+// reference with their type. Call it from an init function before the service starts.
+func Register(name string, factory Factory) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || factory == nil {
+		return
+	}
+	registryMu.Lock()
+	registry[name] = factory
+	registryMu.Unlock()
+}
+
+// Lookup returns the factory registered under name.
+func Lookup(name string) (Factory, bool) {
+	registryMu.RLock()
+	factory, ok := registry[strings.ToLower(strings.TrimSpace(name))]
+	registryMu.RUnlock()
+	return factory, ok
+}
+
+// Registered returns the names of the registered plugin factories in sorted order.
+func Registered() []string {
+	registryMu.RLock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	registryMu.RUnlock()
+	sort.Strings(names)
+	return names
+}
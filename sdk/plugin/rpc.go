@@ -0,0 +1,381 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// External plugins speak JSON-RPC 1.0, as implemented by net/rpc/jsonrpc, over their
+// standard input and output: one JSON object per call, such as
+//
+//	{"method":"Plugin.Execute","params":[{"model":"m","payload":{...}}],"id":3}
+//
+// answered with {"id":3,"result":{"payload":{...}},"error":null}. The proxy first calls
+// Plugin.Configure with the settings of the configuration entry, then Plugin.Describe, and
+// streams responses with Plugin.OpenStream, Plugin.NextChunks until done and, when the
+// client goes away, Plugin.CloseStream. Errors of the upstream are returned in the error
+// field of the result so that their status code is kept. Anything written to standard
+// error is logged by the proxy.
+
+// ConfigureArgs are the parameters of Plugin.Configure.
+type ConfigureArgs struct {
+	Settings map[string]string `json:"settings"`
+}
+
+// Empty is the parameter or result of calls that carry none.
+type Empty struct{}
+
+// ExecuteReply is the result of Plugin.Execute.
+type ExecuteReply struct {
+	Response
+	Error *Error `json:"error,omitempty"`
+}
+
+// StreamArgs are the parameters of Plugin.NextChunks and Plugin.CloseStream.
+type StreamArgs struct {
+	ID string `json:"id"`
+}
+
+// StreamReply is the result of Plugin.OpenStream.
+type StreamReply struct {
+	ID    string `json:"id"`
+	Error *Error `json:"error,omitempty"`
+}
+
+// ChunksReply is the result of Plugin.NextChunks. It holds at least one chunk unless Done
+// is set; Error ends the stream.
+type ChunksReply struct {
+	Chunks []string `json:"chunks,omitempty"`
+	Done   bool     `json:"done,omitempty"`
+	Error  *Error   `json:"error,omitempty"`
+}
+
+// maxChunksPerReply bounds the chunks NextChunks returns at once.
+const maxChunksPerReply = 64
+
+// Serve serves the plugin created by factory over standard input and output until the
+// proxy closes standard input. Plugins written in Go call it from main.
+func Serve(factory Factory) error {
+	return ServeConn(stdioConn{}, factory)
+}
+
+// ServeConn serves the plugin created by factory on conn until conn is closed.
+func ServeConn(conn io.ReadWriteCloser, factory Factory) error {
+	if factory == nil {
+		return errors.New("plugin: nil factory")
+	}
+	server := rpc.NewServer()
+	if err := server.RegisterName("Plugin", &rpcServer{factory: factory, streams: make(map[string]*serverStream)}); err != nil {
+		return err
+	}
+	server.ServeCodec(jsonrpc.NewServerCodec(conn))
+	return nil
+}
+
+type stdioConn struct{}
+
+func (stdioConn) Read(p []byte) (int, error)  { return os.Stdin.Read(p) }
+func (stdioConn) Write(p []byte) (int, error) { return os.Stdout.Write(p) }
+func (stdioConn) Close() error                { return os.Stdin.Close() }
+
+type rpcServer struct {
+	factory Factory
+
+	mu      sync.Mutex
+	plugin  Plugin
+	streams map[string]*serverStream
+	nextID  int
+}
+
+type serverStream struct {
+	chunks <-chan Chunk
+	cancel context.CancelFunc
+}
+
+func (s *rpcServer) Configure(args ConfigureArgs, _ *Empty) error {
+	p, err := s.factory(args.Settings)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.plugin = p
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *rpcServer) current() (Plugin, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.plugin == nil {
+		return nil, errors.New("plugin: not configured")
+	}
+	return s.plugin, nil
+}
+
+func (s *rpcServer) Describe(_ Empty, reply *Info) error {
+	p, err := s.current()
+	if err != nil {
+		return err
+	}
+	info, err := p.Describe(context.Background())
+	if err != nil {
+		return err
+	}
+	*reply = info
+	return nil
+}
+
+func (s *rpcServer) Execute(req Request, reply *ExecuteReply) error {
+	p, err := s.current()
+	if err != nil {
+		return err
+	}
+	resp, err := p.Execute(context.Background(), req)
+	if err != nil {
+		reply.Error = toError(err)
+		return nil
+	}
+	reply.Response = resp
+	return nil
+}
+
+func (s *rpcServer) OpenStream(req Request, reply *StreamReply) error {
+	p, err := s.current()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	chunks, err := p.ExecuteStream(ctx, req)
+	if err != nil {
+		cancel()
+		reply.Error = toError(err)
+		return nil
+	}
+	s.mu.Lock()
+	s.nextID++
+	id := strconv.Itoa(s.nextID)
+	s.streams[id] = &serverStream{chunks: chunks, cancel: cancel}
+	s.mu.Unlock()
+	reply.ID = id
+	return nil
+}
+
+func (s *rpcServer) NextChunks(args StreamArgs, reply *ChunksReply) error {
+	s.mu.Lock()
+	stream, ok := s.streams[args.ID]
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("plugin: unknown stream %q", args.ID)
+	}
+	// Block for the first chunk, then return what is already available.
+	for len(reply.Chunks) < maxChunksPerReply {
+		var chunk Chunk
+		var open bool
+		if len(reply.Chunks) == 0 {
+			chunk, open = <-stream.chunks
+		} else {
+			select {
+			case chunk, open = <-stream.chunks:
+			default:
+				return nil
+			}
+		}
+		if !open {
+			reply.Done = true
+			s.closeStream(args.ID)
+			return nil
+		}
+		if chunk.Err != nil {
+			reply.Error = toError(chunk.Err)
+			s.closeStream(args.ID)
+			return nil
+		}
+		reply.Chunks = append(reply.Chunks, chunk.Data)
+	}
+	return nil
+}
+
+func (s *rpcServer) CloseStream(args StreamArgs, _ *Empty) error {
+	s.closeStream(args.ID)
+	return nil
+}
+
+func (s *rpcServer) closeStream(id string) {
+	s.mu.Lock()
+	stream, ok := s.streams[id]
+	delete(s.streams, id)
+	s.mu.Unlock()
+	if ok {
+		stream.cancel()
+	}
+}
+
+func toError(err error) *Error {
+	var pluginErr *Error
+	if errors.As(err, &pluginErr) {
+		return pluginErr
+	}
+	return &Error{Message: err.Error()}
+}
+
+func fromError(err *Error) error {
+	if err.Status > 0 {
+		return err
+	}
+	return errors.New(err.Message)
+}
+
+// Process is a plugin running as an external process.
+type Process struct {
+	cmd    *exec.Cmd
+	client *rpc.Client
+	done   chan struct{}
+}
+
+// Launch starts command with args, adding env to the environment of the proxy, and
+// configures the plugin with settings. The standard error of the process is copied to
+// stderr when it is not nil. The process runs until Close is called.
+func Launch(ctx context.Context, command string, args, env []string, settings map[string]string, stderr io.Writer) (*Process, error) {
+	cmd := exec.Command(command, args...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stderr = stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		return nil, fmt.Errorf("plugin: start %s: %w", command, err)
+	}
+	p := &Process{
+		cmd:    cmd,
+		client: jsonrpc.NewClient(processConn{Reader: stdout, WriteCloser: stdin}),
+		done:   make(chan struct{}),
+	}
+	go func() {
+		_ = cmd.Wait()
+		close(p.done)
+	}()
+	if err = p.call(ctx, "Configure", ConfigureArgs{Settings: settings}, &Empty{}); err != nil {
+		_ = p.Close()
+		return nil, fmt.Errorf("plugin: configure %s: %w", command, err)
+	}
+	return p, nil
+}
+
+type processConn struct {
+	io.Reader
+	io.WriteCloser
+}
+
+// Exited reports whether the process has exited.
+func (p *Process) Exited() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close stops the process: its standard input is closed and, if it has not exited within
+// five seconds, it is killed.
+func (p *Process) Close() error {
+	exited := p.Exited()
+	err := p.client.Close()
+	select {
+	case <-p.done:
+	case <-time.After(5 * time.Second):
+		_ = p.cmd.Process.Kill()
+		<-p.done
+	}
+	if exited || errors.Is(err, rpc.ErrShutdown) {
+		return nil
+	}
+	return err
+}
+
+func (p *Process) call(ctx context.Context, method string, args, reply any) error {
+	call := p.client.Go("Plugin."+method, args, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-call.Done:
+		return call.Error
+	}
+}
+
+// Describe implements Plugin.
+func (p *Process) Describe(ctx context.Context) (Info, error) {
+	var info Info
+	err := p.call(ctx, "Describe", Empty{}, &info)
+	return info, err
+}
+
+// Execute implements Plugin.
+func (p *Process) Execute(ctx context.Context, req Request) (Response, error) {
+	var reply ExecuteReply
+	if err := p.call(ctx, "Execute", req, &reply); err != nil {
+		return Response{}, err
+	}
+	if reply.Error != nil {
+		return Response{}, fromError(reply.Error)
+	}
+	return reply.Response, nil
+}
+
+// ExecuteStream implements Plugin.
+func (p *Process) ExecuteStream(ctx context.Context, req Request) (<-chan Chunk, error) {
+	var opened StreamReply
+	if err := p.call(ctx, "OpenStream", req, &opened); err != nil {
+		return nil, err
+	}
+	if opened.Error != nil {
+		return nil, fromError(opened.Error)
+	}
+	out := make(chan Chunk)
+	go func() {
+		defer close(out)
+		for {
+			var reply ChunksReply
+			if err := p.call(ctx, "NextChunks", StreamArgs{ID: opened.ID}, &reply); err != nil {
+				if ctx.Err() != nil {
+					closeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+					_ = p.call(closeCtx, "CloseStream", StreamArgs{ID: opened.ID}, &Empty{})
+					cancel()
+					return
+				}
+				out <- Chunk{Err: err}
+				return
+			}
+			for _, data := range reply.Chunks {
+				select {
+				case out <- Chunk{Data: data}:
+				case <-ctx.Done():
+					_ = p.call(context.Background(), "CloseStream", StreamArgs{ID: opened.ID}, &Empty{})
+					return
+				}
+			}
+			if reply.Error != nil {
+				out <- Chunk{Err: fromError(reply.Error)}
+				return
+			}
+			if reply.Done {
+				return
+			}
+		}
+	}()
+	return out, nil
+}
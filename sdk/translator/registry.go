@@ -3,6 +3,10 @@ package translator
 import (
 	"context"
 	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Registry manages translation functions across schemas.
@@ -40,12 +44,14 @@ func (r *Registry) Register(from, to Format, request RequestTransform, response
 
 // TranslateRequest converts a payload between schemas, returning the original payload
 // if no translator is registered.
-func (r *Registry) TranslateRequest(from, to Format, model string, rawJSON []byte, stream bool) []byte {
+func (r *Registry) TranslateRequest(ctx context.Context, from, to Format, model string, rawJSON []byte, stream bool) []byte {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	if byTarget, ok := r.requests[from]; ok {
 		if fn, isOk := byTarget[to]; isOk && fn != nil {
+			_, span := startTranslateSpan(ctx, "translator.request", from, to)
+			defer span.End()
 			return fn(model, rawJSON, stream)
 		}
 	}
@@ -72,6 +78,8 @@ func (r *Registry) TranslateStream(ctx context.Context, from, to Format, model s
 
 	if byTarget, ok := r.responses[to]; ok {
 		if fn, isOk := byTarget[from]; isOk && fn.Stream != nil {
+			ctx, span := startTranslateSpan(ctx, "translator.stream", from, to)
+			defer span.End()
 			return fn.Stream(ctx, model, originalRequestRawJSON, requestRawJSON, rawJSON, param)
 		}
 	}
@@ -85,13 +93,23 @@ func (r *Registry) TranslateNonStream(ctx context.Context, from, to Format, mode
 
 	if byTarget, ok := r.responses[to]; ok {
 		if fn, isOk := byTarget[from]; isOk && fn.NonStream != nil {
+			ctx, span := startTranslateSpan(ctx, "translator.response", from, to)
+			defer span.End()
 			return fn.NonStream(ctx, model, originalRequestRawJSON, requestRawJSON, rawJSON, param)
 		}
 	}
 	return string(rawJSON)
 }
 
-// TranslateNonStream applies the registered non-stream response translator.
+// startTranslateSpan opens a span covering one translator call between two formats.
+func startTranslateSpan(ctx context.Context, name string, from, to Format) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, name, trace.WithAttributes(
+		attribute.String("cliproxy.translator.from", from.String()),
+		attribute.String("cliproxy.translator.to", to.String()),
+	))
+}
+
+// TranslateTokenCount applies the registered token count translator.
 func (r *Registry) TranslateTokenCount(ctx context.Context, from, to Format, count int64, rawJSON []byte) string {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -117,8 +135,8 @@ func Register(from, to Format, request RequestTransform, response ResponseTransf
 }
 
 // TranslateRequest is a helper on the default registry.
-func TranslateRequest(from, to Format, model string, rawJSON []byte, stream bool) []byte {
-	return defaultRegistry.TranslateRequest(from, to, model, rawJSON, stream)
+func TranslateRequest(ctx context.Context, from, to Format, model string, rawJSON []byte, stream bool) []byte {
+	return defaultRegistry.TranslateRequest(ctx, from, to, model, rawJSON, stream)
 }
 
 // HasResponseTransformer inspects the default registry.
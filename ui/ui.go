@@ -0,0 +1,9 @@
+// Package ui embeds the static metrics pages so they are served from the proxy binary.
+package ui
+
+import "embed"
+
+// Assets holds the HTML pages in this directory.
+//
+//go:embed *.html
+var Assets embed.FS